@@ -0,0 +1,83 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package consensus
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// BatchPolicy bounds the batches of transactions a consensus plugin cuts into blocks. A batch is
+// cut once it holds MaxMessageCount transactions, once the transactions of the batch reach MaxBytes
+// bytes, or Timeout after its first transaction arrived, whichever comes first. MaxBytes is not
+// enforced if 0; a single transaction larger than MaxBytes makes a batch of its own.
+type BatchPolicy struct {
+	MaxMessageCount int
+	MaxBytes        int
+	Timeout         time.Duration
+}
+
+// Validate checks that the policy bounds the batches
+func (p BatchPolicy) Validate() error {
+	if p.MaxMessageCount <= 0 {
+		return fmt.Errorf("Invalid batch policy: the maximum message count must be positive, found %d", p.MaxMessageCount)
+	}
+	if p.MaxBytes < 0 {
+		return fmt.Errorf("Invalid batch policy: the maximum bytes must not be negative, found %d", p.MaxBytes)
+	}
+	if p.Timeout <= 0 {
+		return fmt.Errorf("Invalid batch policy: the timeout must be positive, found %s", p.Timeout)
+	}
+	return nil
+}
+
+// Overflows tells whether a batch of count transactions of bytes bytes has to be cut before a
+// transaction of size bytes is added to it
+func (p BatchPolicy) Overflows(count int, bytes int, size int) bool {
+	return count > 0 && p.MaxBytes > 0 && bytes+size > p.MaxBytes
+}
+
+// Full tells whether a batch of count transactions of bytes bytes has to be cut
+func (p BatchPolicy) Full(count int, bytes int) bool {
+	return count >= p.MaxMessageCount || (p.MaxBytes > 0 && bytes >= p.MaxBytes)
+}
+
+var batchPolicy = struct {
+	sync.RWMutex
+	policy BatchPolicy
+}{}
+
+// GetBatchPolicy returns the batch policy in force
+func GetBatchPolicy() BatchPolicy {
+	batchPolicy.RLock()
+	defer batchPolicy.RUnlock()
+	return batchPolicy.policy
+}
+
+// SetBatchPolicy changes the batch policy, which the consensus plugin applies from the next
+// transaction it adds to a batch on. The plugin sets the policy of its configuration when it
+// starts, the admin service of the peer changes it at runtime.
+func SetBatchPolicy(policy BatchPolicy) error {
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+	batchPolicy.Lock()
+	defer batchPolicy.Unlock()
+	batchPolicy.policy = policy
+	return nil
+}
@@ -0,0 +1,49 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package consensus
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBatchPolicy(t *testing.T) {
+	if err := SetBatchPolicy(BatchPolicy{MaxMessageCount: 0, Timeout: time.Second}); err == nil {
+		t.Fatal("Expected a policy without a maximum message count to be rejected")
+	}
+	if err := SetBatchPolicy(BatchPolicy{MaxMessageCount: 10, MaxBytes: 100, Timeout: time.Second}); err != nil {
+		t.Fatalf("Error setting the batch policy: %s", err)
+	}
+	policy := GetBatchPolicy()
+	if policy.MaxMessageCount != 10 || policy.MaxBytes != 100 || policy.Timeout != time.Second {
+		t.Fatalf("Unexpected batch policy %+v", policy)
+	}
+
+	if policy.Overflows(0, 0, 200) {
+		t.Error("A transaction larger than the maximum bytes should make a batch of its own")
+	}
+	if !policy.Overflows(1, 60, 50) {
+		t.Error("A batch should be cut before it goes beyond the maximum bytes")
+	}
+	if policy.Full(9, 99) || !policy.Full(10, 0) || !policy.Full(1, 100) {
+		t.Error("A batch should be full once it reaches the maximum message count or bytes")
+	}
+	policy.MaxBytes = 0
+	if policy.Overflows(5, 1000, 1000) || policy.Full(5, 1000) {
+		t.Error("The maximum bytes should not be enforced if 0")
+	}
+}
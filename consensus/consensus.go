@@ -22,6 +22,12 @@ import (
 
 // Consenter is used to receive messages from the network
 // Every consensus plugin needs to implement this interface
+//
+// RecvMsg is given the Message_CHAIN_TRANSACTION messages of the transactions submitted to the
+// validating peer, with the validating peer itself as sender, and the Message_CONSENSUS messages
+// the other validating peers sent to it with Broadcast or Unicast. A plugin should not block in
+// RecvMsg, which holds up the delivery of the following messages, but hand the message to its own
+// goroutine instead.
 type Consenter interface {
 	RecvMsg(msg *pb.Message, senderHandle *pb.PeerID) error // Called serially with incoming messages from gRPC
 	StateUpdated(tag uint64, id []byte)                     // Called when state transfer completes, serial with StateUpdating
@@ -29,6 +35,7 @@ type Consenter interface {
 }
 
 // Inquirer is used to retrieve info about the validating network
+// The network returned includes the validating peer itself, once
 type Inquirer interface {
 	GetNetworkInfo() (self *pb.PeerEndpoint, network []*pb.PeerEndpoint, err error)
 	GetNetworkHandles() (self *pb.PeerID, network []*pb.PeerID, err error)
@@ -61,6 +68,10 @@ type ReadOnlyLedger interface {
 }
 
 // Executor is used to invoke transactions, potentially modifying the backing ledger
+// A batch is begun, has its transactions executed, and is either committed as a block or rolled
+// back. The id given to BeginTxBatch identifies the batch in the following calls; only one batch
+// is in progress at a time. The metadata given to CommitTxBatch is recorded in the block, where
+// GetBlockHeadMetadata returns it: it is the checkpoint from which a plugin resumes after a restart
 type Executor interface {
 	BeginTxBatch(id interface{}) error
 	ExecTxs(id interface{}, txs []*pb.Transaction) ([]byte, error)
@@ -70,6 +81,9 @@ type Executor interface {
 }
 
 // LedgerManager is used to manipulate the state of the ledger
+// SkipTo brings the ledger to the blockchain described by id, a marshalled pb.BlockchainInfo, which
+// the peers given have. The plugin is told of the progress through StateUpdating, then StateUpdated,
+// which are given back the tag passed to SkipTo
 type LedgerManager interface {
 	SkipTo(tag uint64, id []byte, peers []*pb.PeerID) // SkipTo tells state transfer to bring the ledger to a particular state, it should generally be preceeded/proceeded by Invalidate/Validate
 	InvalidateState()                                 // Invalidate informs the ledger that it is out of date and should reject queries
@@ -77,6 +91,7 @@ type LedgerManager interface {
 }
 
 // StatePersistor is used to store consensus state which should survive a process crash
+// ReadState returns a nil value for a key which is not stored
 type StatePersistor interface {
 	StoreState(key string, value []byte) error
 	ReadState(key string) ([]byte, error)
@@ -25,11 +25,19 @@ import (
 	"github.com/hyperledger/fabric/consensus"
 	"github.com/hyperledger/fabric/consensus/noops"
 	"github.com/hyperledger/fabric/consensus/obcpbft"
+	"github.com/hyperledger/fabric/consensus/raft"
 )
 
 var logger *logging.Logger // package-level logger
 var consenter consensus.Consenter
 
+// plugins maps the name of each consensus plugin to its constructor
+var plugins = map[string]func(consensus.Stack) consensus.Consenter{
+	"noops": noops.GetNoops,
+	"pbft":  obcpbft.GetPlugin,
+	"raft":  raft.GetPlugin,
+}
+
 func init() {
 	logger = logging.MustGetLogger("consensus/controller")
 }
@@ -38,11 +46,11 @@ func init() {
 func NewConsenter(stack consensus.Stack) consensus.Consenter {
 
 	plugin := strings.ToLower(viper.GetString("peer.validator.consensus.plugin"))
-	if plugin == "pbft" {
+	if getPlugin, ok := plugins[plugin]; ok {
 		logger.Info("Creating consensus plugin %s", plugin)
-		return obcpbft.GetPlugin(stack)
+		return getPlugin(stack)
 	}
-	logger.Info("Creating default consensus plugin (noops)")
+	logger.Warning("Unknown consensus plugin %q, creating default consensus plugin (noops)", plugin)
 	return noops.GetNoops(stack)
 
 }
@@ -24,9 +24,13 @@ import (
 	"github.com/hyperledger/fabric/consensus/controller"
 	"github.com/hyperledger/fabric/consensus/util"
 	"github.com/hyperledger/fabric/core/chaincode"
+	"github.com/hyperledger/fabric/core/ledger"
+	"github.com/hyperledger/fabric/core/tracing"
 	pb "github.com/hyperledger/fabric/protos"
+	"github.com/spf13/viper"
 	"golang.org/x/net/context"
 	"sync"
+	"time"
 )
 
 // EngineImpl implements a struct to hold consensus.Consenter, PeerEndpoint and MessageFan
@@ -35,6 +39,8 @@ type EngineImpl struct {
 	helper       *Helper
 	peerEndpoint *pb.PeerEndpoint
 	consensusFan *util.MessageFan
+	recent       *recentTxs // nil unless the recently admitted transactions are indexed
+	maxClockSkew time.Duration
 }
 
 // GetHandlerFactory returns new NewConsensusHandler
@@ -55,7 +61,7 @@ func (eng *EngineImpl) ProcessTransactionMsg(msg *pb.Message, tx *pb.Transaction
 		// The secHelper is set during creat ChaincodeSupport, so we don't need this step
 		// cxt := context.WithValue(context.Background(), "security", secHelper)
 		cxt := context.Background()
-		result, err := chaincode.Execute(cxt, chaincode.GetChain(chaincode.DefaultChain), tx)
+		result, _, err := chaincode.Execute(cxt, chaincode.GetChain(chaincode.DefaultChain), tx)
 		if err != nil {
 			response = &pb.Response{Status: pb.Response_FAILURE,
 				Msg: []byte(fmt.Sprintf("Error:%s", err))}
@@ -76,17 +82,64 @@ func (eng *EngineImpl) ProcessTransactionMsg(msg *pb.Message, tx *pb.Transaction
 		if eng.consenter == nil {
 			return &pb.Response{Status: pb.Response_FAILURE, Msg: []byte("Engine not initialized")}
 		}
+		span := tracing.StartTxSpan(tx.Uuid, "submit")
+		defer span.Finish()
+		// A transaction submitted again, or replayed, is rejected rather than executed twice
+		if err := eng.admit(tx); err != nil {
+			span.SetError(err)
+			logger.Warning("Rejecting transaction %s: %s", tx.Uuid, err)
+			return &pb.Response{Status: pb.Response_FAILURE, Msg: []byte(err.Error())}
+		}
 		// TODO, do we want to put these requests into a queue? This will block until
 		// the consenter gets around to handling the message, but it also provides some
 		// natural feedback to the REST API to determine how long it takes to queue messages
 		err := eng.consenter.RecvMsg(msg, eng.peerEndpoint.ID)
 		if err != nil {
+			if eng.recent != nil {
+				eng.recent.forget(tx.Uuid)
+			}
+			span.SetError(err)
 			response = &pb.Response{Status: pb.Response_FAILURE, Msg: []byte(err.Error())}
 		}
 	}
 	return response
 }
 
+// admit returns an error of type ErrorTypeDuplicateTransaction if a transaction of the blockchain,
+// or one recently admitted, has the uuid of the transaction, and an error if the transaction is
+// already expired or stamped too far in the future
+func (eng *EngineImpl) admit(tx *pb.Transaction) error {
+	lgr, err := ledger.GetLedger()
+	if err != nil {
+		return err
+	}
+	if err = tx.CheckExpiry(lgr.GetBlockchainSize(), time.Now()); err != nil {
+		return err
+	}
+	if eng.maxClockSkew > 0 {
+		if err = tx.CheckTimestamp(time.Now(), eng.maxClockSkew); err != nil {
+			return err
+		}
+	}
+	if err = lgr.CheckTxUUID(tx.Uuid); err != nil {
+		return err
+	}
+	if eng.recent != nil && !eng.recent.admit(tx.Uuid, time.Now()) {
+		return ledger.NewDuplicateTransactionError(tx.Uuid)
+	}
+	return nil
+}
+
+// ProcessSimulation simulates an invoke transaction against the committed state, as a dry
+// run which is never handed to consensus
+func (eng *EngineImpl) ProcessSimulation(tx *pb.Transaction) (*pb.SimulationResult, error) {
+	if !engine.helper.valid {
+		logger.Warning("Rejecting simulation because state is currently not valid")
+		return nil, fmt.Errorf("state may be inconsistent, cannot simulate")
+	}
+	return chaincode.SimulateTransaction(context.Background(), chaincode.GetChain(chaincode.DefaultChain), tx)
+}
+
 func (eng *EngineImpl) setConsenter(consenter consensus.Consenter) *EngineImpl {
 	eng.consenter = consenter
 	return eng
@@ -115,6 +168,10 @@ func GetEngine(coord peer.MessageHandlerCoordinator) (peer.Engine, error) {
 		engine.helper.setConsenter(engine.consenter)
 		engine.peerEndpoint, err = coord.GetPeerEndpoint()
 		engine.consensusFan = util.NewMessageFan()
+		if window := viper.GetDuration("peer.validator.consensus.duplicatewindow"); window > 0 {
+			engine.recent = newRecentTxs(window)
+		}
+		engine.maxClockSkew = viper.GetDuration("peer.validator.consensus.maxclockskew")
 
 		go func() {
 			logger.Debug("Starting up message thread for consenter")
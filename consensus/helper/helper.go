@@ -18,6 +18,7 @@ package helper
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/golang/protobuf/proto"
 	"github.com/spf13/viper"
@@ -30,6 +31,7 @@ import (
 	"github.com/hyperledger/fabric/core/ledger"
 	"github.com/hyperledger/fabric/core/peer"
 	"github.com/hyperledger/fabric/core/peer/statetransfer"
+	"github.com/hyperledger/fabric/core/tracing"
 	pb "github.com/hyperledger/fabric/protos"
 )
 
@@ -99,7 +101,6 @@ func (h *Helper) GetNetworkHandles() (self *pb.PeerID, network []*pb.PeerID, err
 	for _, endpoint := range networkEP {
 		network = append(network, endpoint.ID)
 	}
-	network = append(network, self)
 
 	return
 }
@@ -175,11 +176,20 @@ func (h *Helper) BeginTxBatch(id interface{}) error {
 func (h *Helper) ExecTxs(id interface{}, txs []*pb.Transaction) ([]byte, error) {
 	// TODO id is currently ignored, fix once the underlying implementation accepts id
 
+	// the ordering of a transaction spans from its timestamp, set when the transaction
+	// was created, to its execution
+	now := time.Now()
+	for _, tx := range txs {
+		if ts := tx.GetTimestamp(); ts != nil {
+			tracing.RecordTxSpan(tx.Uuid, "ordering", time.Unix(ts.Seconds, int64(ts.Nanos)), now, nil)
+		}
+	}
+
 	// The secHelper is set during creat ChaincodeSupport, so we don't need this step
 	// cxt := context.WithValue(context.Background(), "security", h.coordinator.GetSecHelper())
 	// TODO return directly once underlying implementation no longer returns []error
 
-	res, txerrs, err := chaincode.ExecuteTransactions(context.Background(), chaincode.DefaultChain, txs)
+	res, ccevents, txerrs, err := chaincode.ExecuteTransactions(context.Background(), chaincode.DefaultChain, txs)
 	h.curBatch = append(h.curBatch, txs...) // TODO, remove after issue 579
 
 	//copy errs to results
@@ -191,7 +201,7 @@ func (h *Helper) ExecTxs(id interface{}, txs []*pb.Transaction) ([]byte, error)
 		if txerrs[i] != nil {
 			txresults[i] = &pb.TransactionResult{Uuid: txs[i].Uuid, Error: e.Error(), ErrorCode: 1}
 		} else {
-			txresults[i] = &pb.TransactionResult{Uuid: txs[i].Uuid}
+			txresults[i] = &pb.TransactionResult{Uuid: txs[i].Uuid, ChaincodeEvent: ccevents[i]}
 		}
 	}
 	h.curBatchErrs = append(h.curBatchErrs, txresults...) // TODO, remove after issue 579
@@ -223,6 +233,12 @@ func (h *Helper) CommitTxBatch(id interface{}, metadata []byte) (*pb.Block, erro
 		return nil, fmt.Errorf("Failed to get the block at the head of the chain: %v", err)
 	}
 
+	go func(blockNumber uint64) {
+		if err := h.coordinator.GossipBlock(blockNumber); err != nil {
+			logger.Warning("Failed to gossip block %d to the non-validating peers: %v", blockNumber, err)
+		}
+	}(size - 1)
+
 	return block, nil
 }
 
@@ -20,39 +20,38 @@ import (
 	"github.com/hyperledger/fabric/core/db"
 )
 
-// Helper provides an abstraction to access the Persist column family
+// Helper provides an abstraction to access the Consensus column family
 // in the database.
 type Helper struct{}
 
 // StoreState stores a key,value pair
 func (h *Helper) StoreState(key string, value []byte) error {
 	db := db.GetDBHandle()
-	return db.Put(db.PersistCF, []byte("consensus."+key), value)
+	return db.Put(db.ConsensusCF, []byte(key), value)
 }
 
 // DelState removes a key,value pair
 func (h *Helper) DelState(key string) {
 	db := db.GetDBHandle()
-	db.Delete(db.PersistCF, []byte("consensus."+key))
+	db.Delete(db.ConsensusCF, []byte(key))
 }
 
 // ReadState retrieves a value to a key
 func (h *Helper) ReadState(key string) ([]byte, error) {
 	db := db.GetDBHandle()
-	return db.Get(db.PersistCF, []byte("consensus."+key))
+	return db.Get(db.ConsensusCF, []byte(key))
 }
 
 // ReadStateSet retrieves all key,value pairs where the key starts with prefix
 func (h *Helper) ReadStateSet(prefix string) (map[string][]byte, error) {
 	db := db.GetDBHandle()
-	prefixRaw := []byte("consensus." + prefix)
+	prefixRaw := []byte(prefix)
 
 	ret := make(map[string][]byte)
-	it := db.GetIterator(db.PersistCF)
+	it := db.GetIterator(db.ConsensusCF)
 	defer it.Close()
 	for it.Seek(prefixRaw); it.ValidForPrefix(prefixRaw); it.Next() {
 		key := string(it.Key().Data())
-		key = key[len("consensus."):]
 		// copy data from the slice!
 		ret[key] = append([]byte(nil), it.Value().Data()...)
 	}
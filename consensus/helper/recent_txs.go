@@ -0,0 +1,80 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package helper
+
+import (
+	"sync"
+	"time"
+)
+
+// recentTxs indexes the uuids of the transactions recently admitted by the engine, so that a
+// transaction submitted again before it is committed is not ordered twice. A uuid is only
+// remembered for the window, by which time the transaction is committed, and then rejected
+// from the blockchain, or lost. Being local to the peer, the index only spares the consenter
+// the duplicates: the peers reject the ones ordered anyway when they execute them
+type recentTxs struct {
+	lock     sync.Mutex
+	window   time.Duration
+	admitted map[string]time.Time
+	// order lists the admissions from the oldest, for them to expire
+	order []admission
+}
+
+type admission struct {
+	uuid string
+	at   time.Time
+}
+
+func newRecentTxs(window time.Duration) *recentTxs {
+	return &recentTxs{window: window, admitted: make(map[string]time.Time)}
+}
+
+// admit records the uuid, unless it was admitted within the window in which case false is returned
+func (r *recentTxs) admit(uuid string, now time.Time) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.expire(now)
+	if _, ok := r.admitted[uuid]; ok {
+		return false
+	}
+	r.admitted[uuid] = now
+	r.order = append(r.order, admission{uuid, now})
+	return true
+}
+
+// forget removes the uuid of a transaction which could not be handed to the consenter, so that
+// it can be submitted again
+func (r *recentTxs) forget(uuid string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	delete(r.admitted, uuid)
+}
+
+func (r *recentTxs) expire(now time.Time) {
+	expired := 0
+	for _, a := range r.order {
+		if now.Sub(a.at) < r.window {
+			break
+		}
+		// the uuid may have been forgotten, then admitted again
+		if at, ok := r.admitted[a.uuid]; ok && at.Equal(a.at) {
+			delete(r.admitted, a.uuid)
+		}
+		expired++
+	}
+	r.order = r.order[expired:]
+}
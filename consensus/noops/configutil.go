@@ -20,7 +20,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -51,3 +53,16 @@ func loadConfig() (config *viper.Viper) {
 	}
 	return config
 }
+
+// getDuration reads the duration of the given key, in seconds when it has no unit of
+// measure, and 0 when it is not set
+func getDuration(config *viper.Viper, key string) (time.Duration, error) {
+	value := config.GetString(key)
+	if value == "" {
+		return 0, nil
+	}
+	if _, err := strconv.Atoi(value); err == nil {
+		value = value + "s" //if string does not have unit of measure, default to seconds
+	}
+	return time.ParseDuration(value)
+}
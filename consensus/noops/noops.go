@@ -18,7 +18,7 @@ package noops
 
 import (
 	"fmt"
-	"strconv"
+	"math/rand"
 	"time"
 
 	"github.com/golang/protobuf/proto"
@@ -39,11 +39,13 @@ func init() {
 
 // Noops is a plugin object implementing the consensus.Consenter interface.
 type Noops struct {
-	stack    consensus.Stack
-	txQ      *txq
-	timer    *time.Timer
-	duration time.Duration
-	channel  chan *pb.Transaction
+	stack   consensus.Stack
+	txQ     *txq
+	timer   *time.Timer
+	ticker  *time.Ticker // nil unless the blocks are cut at a fixed interval
+	latency time.Duration
+	jitter  time.Duration
+	channel chan *pb.Transaction
 }
 
 // Setting up a singleton NOOPS consenter
@@ -67,24 +69,40 @@ func newNoops(c consensus.Stack) consensus.Consenter {
 	i.stack = c
 	config := loadConfig()
 	blockSize := config.GetInt("block.size")
-	blockTimeout := config.GetString("block.timeout")
-	if _, err = strconv.Atoi(blockTimeout); err == nil {
-		blockTimeout = blockTimeout + "s" //if string does not have unit of measure, default to seconds
-	}
-	i.duration, err = time.ParseDuration(blockTimeout)
-	if err != nil || i.duration == 0 {
+	blockMaxBytes := config.GetInt("block.maxbytes")
+	duration, err := getDuration(config, "block.timeout")
+	if err != nil || duration == 0 {
 		panic(fmt.Errorf("Cannot parse block timeout: %s", err))
 	}
+	if err = consensus.SetBatchPolicy(consensus.BatchPolicy{MaxMessageCount: blockSize, MaxBytes: blockMaxBytes, Timeout: duration}); err != nil {
+		panic(err)
+	}
+	interval, err := getDuration(config, "block.interval")
+	if err != nil || interval < 0 {
+		panic(fmt.Errorf("Cannot parse block interval: %s", err))
+	}
+	if i.latency, err = getDuration(config, "block.latency"); err != nil || i.latency < 0 {
+		panic(fmt.Errorf("Cannot parse block latency: %s", err))
+	}
+	if i.jitter, err = getDuration(config, "block.jitter"); err != nil || i.jitter < 0 {
+		panic(fmt.Errorf("Cannot parse block jitter: %s", err))
+	}
 
 	logger.Info("NOOPS consensus type = %T", i)
 	logger.Info("NOOPS block size = %v", blockSize)
-	logger.Info("NOOPS block timeout = %v", i.duration)
+	logger.Info("NOOPS block max bytes = %v", blockMaxBytes)
+	logger.Info("NOOPS block timeout = %v", duration)
+	logger.Info("NOOPS block interval = %v", interval)
+	logger.Info("NOOPS block latency = %v, jitter = %v", i.latency, i.jitter)
 
-	i.txQ = newTXQ(blockSize)
+	i.txQ = newTXQ()
 
 	i.channel = make(chan *pb.Transaction, 100)
-	i.timer = time.NewTimer(i.duration) // start timer now so we can just reset it
+	i.timer = time.NewTimer(duration) // start timer now so we can just reset it
 	i.timer.Stop()
+	if interval > 0 {
+		i.ticker = time.NewTicker(interval)
+	}
 	go i.handleChannels()
 	return i
 }
@@ -153,21 +171,39 @@ func (i *Noops) canProcessBlock(tx *pb.Transaction) bool {
 
 	// TODO: Ask coordinator if we need to start sync
 
+	policy := consensus.GetBatchPolicy()
 	i.txQ.append(tx)
 
 	// start timer if we get a tx
 	if i.txQ.size() == 1 {
-		i.timer.Reset(i.duration)
+		i.timer.Reset(policy.Timeout)
 	}
-	return i.txQ.isFull()
+	return policy.Full(i.txQ.size(), i.txQ.bytes)
 }
 
 func (i *Noops) handleChannels() {
 	// Noops is a singleton object and only exits when peer exits, so we
 	// don't need a condition to exit this loop
+	var tick <-chan time.Time
+	if i.ticker != nil {
+		tick = i.ticker.C
+	}
 	for {
 		select {
 		case tx := <-i.channel:
+			if i.ticker != nil {
+				// the blocks are only cut at the ticks of the interval
+				i.txQ.append(tx)
+				continue
+			}
+			if consensus.GetBatchPolicy().Overflows(i.txQ.size(), i.txQ.bytes, proto.Size(tx)) {
+				if logger.IsEnabledFor(logging.DEBUG) {
+					logger.Debug("Process block due to bytes")
+				}
+				if err := i.processBlock(); nil != err {
+					logger.Error(err.Error())
+				}
+			}
 			if i.canProcessBlock(tx) {
 				if logger.IsEnabledFor(logging.DEBUG) {
 					logger.Debug("Process block due to size")
@@ -183,6 +219,13 @@ func (i *Noops) handleChannels() {
 			if err := i.processBlock(); nil != err {
 				logger.Error(err.Error())
 			}
+		case <-tick:
+			if logger.IsEnabledFor(logging.DEBUG) {
+				logger.Debug("Process block due to interval")
+			}
+			if err := i.processBlock(); nil != err {
+				logger.Error(err.Error())
+			}
 		}
 	}
 }
@@ -200,7 +243,23 @@ func (i *Noops) processBlock() error {
 	var delta *statemgmt.StateDelta
 	var err error
 
-	if err = i.processTransactions(); nil != err {
+	// Grab the transactions of the block from the FIFO queue, at a fixed interval
+	// the block takes as many as the batch policy allows, the rest wait for the next tick
+	var txarr []*pb.Transaction
+	if i.ticker != nil {
+		txarr = i.txQ.getBatch(consensus.GetBatchPolicy())
+	} else {
+		txarr = i.txQ.getTXs()
+	}
+
+	if delay := i.commitDelay(); delay > 0 {
+		if logger.IsEnabledFor(logging.DEBUG) {
+			logger.Debug("Delaying the block of %d transactions by %v", len(txarr), delay)
+		}
+		time.Sleep(delay)
+	}
+
+	if err = i.processTransactions(txarr); nil != err {
 		return err
 	}
 	if data, delta, err = i.getBlockData(); nil != err {
@@ -210,7 +269,21 @@ func (i *Noops) processBlock() error {
 	return nil
 }
 
-func (i *Noops) processTransactions() error {
+// commitDelay returns the latency injected before a block is executed and committed, to
+// emulate the commit timing of a consensus network: the configured latency, give or take
+// a random jitter
+func (i *Noops) commitDelay() time.Duration {
+	delay := i.latency
+	if i.jitter > 0 {
+		delay += time.Duration(rand.Int63n(2*int64(i.jitter)+1)) - i.jitter
+	}
+	if delay < 0 {
+		return 0
+	}
+	return delay
+}
+
+func (i *Noops) processTransactions(txarr []*pb.Transaction) error {
 	timestamp := util.CreateUtcTimestamp()
 	if logger.IsEnabledFor(logging.DEBUG) {
 		logger.Debug("Starting TX batch with timestamp: %v", timestamp)
@@ -219,8 +292,7 @@ func (i *Noops) processTransactions() error {
 		return err
 	}
 
-	// Grab all transactions from the FIFO queue and run them in order
-	txarr := i.txQ.getTXs()
+	// Run the transactions in order
 	if logger.IsEnabledFor(logging.DEBUG) {
 		logger.Debug("Executing batch of %d transactions with timestamp %v", len(txarr), timestamp)
 	}
@@ -17,48 +17,51 @@ limitations under the License.
 package noops
 
 import (
+	"github.com/golang/protobuf/proto"
+
+	"github.com/hyperledger/fabric/consensus"
 	pb "github.com/hyperledger/fabric/protos"
 )
 
 type txq struct {
-	i int
-	q []*pb.Transaction
+	q     []*pb.Transaction
+	bytes int // marshalled size of the transactions in the queue
 }
 
-func newTXQ(size int) *txq {
-	o := &txq{}
-	o.i = 0
-	if size < 1 {
-		size = 1
-	}
-	o.q = make([]*pb.Transaction, size)
-	return o
+func newTXQ() *txq {
+	return &txq{}
 }
 
 func (o *txq) append(tx *pb.Transaction) {
-	if cap(o.q) > o.i {
-		o.q[o.i] = tx
-		o.i++
-	}
+	o.q = append(o.q, tx)
+	o.bytes += proto.Size(tx)
 }
 
 func (o *txq) getTXs() []*pb.Transaction {
-	length := o.i
-	o.i = 0
-	return o.q[:length]
+	txs := o.q
+	o.reset()
+	return txs
 }
 
-func (o *txq) isFull() bool {
-	if cap(o.q) == o.i {
-		return true
+// getBatch removes the transactions at the head of the queue which make a batch
+// within the given policy, and returns them
+func (o *txq) getBatch(policy consensus.BatchPolicy) []*pb.Transaction {
+	count, bytes := 0, 0
+	for count < len(o.q) && !policy.Full(count, bytes) && !policy.Overflows(count, bytes, proto.Size(o.q[count])) {
+		bytes += proto.Size(o.q[count])
+		count++
 	}
-	return false
+	txs := o.q[:count]
+	o.q = o.q[count:]
+	o.bytes -= bytes
+	return txs
 }
 
 func (o *txq) size() int {
-	return o.i
+	return len(o.q)
 }
 
 func (o *txq) reset() {
-	o.i = 0
+	o.q = nil
+	o.bytes = 0
 }
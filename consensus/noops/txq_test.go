@@ -0,0 +1,72 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package noops
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/hyperledger/fabric/consensus"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+func TestGetBatch(t *testing.T) {
+	q := newTXQ()
+	for _, uuid := range []string{"tx1", "tx2", "tx3", "tx4"} {
+		q.append(&pb.Transaction{Uuid: uuid})
+	}
+	size := proto.Size(&pb.Transaction{Uuid: "tx1"})
+
+	txs := q.getBatch(consensus.BatchPolicy{MaxMessageCount: 3, Timeout: time.Second})
+	if len(txs) != 3 || txs[0].Uuid != "tx1" || txs[2].Uuid != "tx3" {
+		t.Fatalf("Expected the first 3 transactions in the batch, got %v", txs)
+	}
+	if q.size() != 1 || q.bytes != size {
+		t.Fatalf("Expected 1 transaction of %d bytes left in the queue, got %d of %d bytes", size, q.size(), q.bytes)
+	}
+
+	q.append(&pb.Transaction{Uuid: "tx5"})
+	txs = q.getBatch(consensus.BatchPolicy{MaxMessageCount: 3, MaxBytes: size + 1, Timeout: time.Second})
+	if len(txs) != 1 || txs[0].Uuid != "tx4" {
+		t.Fatalf("Expected the batch to be bounded by the bytes, got %v", txs)
+	}
+
+	txs = q.getBatch(consensus.BatchPolicy{MaxMessageCount: 3, MaxBytes: 1, Timeout: time.Second})
+	if len(txs) != 1 || txs[0].Uuid != "tx5" {
+		t.Fatalf("Expected a transaction larger than the bytes to make a batch of its own, got %v", txs)
+	}
+	if q.size() != 0 || q.bytes != 0 {
+		t.Fatalf("Expected the queue to be empty, got %d transactions of %d bytes", q.size(), q.bytes)
+	}
+}
+
+func TestCommitDelay(t *testing.T) {
+	i := &Noops{latency: 100 * time.Millisecond, jitter: 20 * time.Millisecond}
+	for n := 0; n < 100; n++ {
+		if delay := i.commitDelay(); delay < 80*time.Millisecond || delay > 120*time.Millisecond {
+			t.Fatalf("Expected a delay between 80ms and 120ms, got %v", delay)
+		}
+	}
+	i = &Noops{jitter: 20 * time.Millisecond}
+	for n := 0; n < 100; n++ {
+		if delay := i.commitDelay(); delay < 0 || delay > 20*time.Millisecond {
+			t.Fatalf("Expected a delay between 0 and 20ms, got %v", delay)
+		}
+	}
+}
@@ -18,6 +18,7 @@ It has these top-level messages:
 	Checkpoint
 	ViewChange
 	PQset
+	ViewState
 	NewView
 	FetchRequest
 	RequestBlock
@@ -471,6 +472,17 @@ func (m *PQset) GetSet() []*ViewChange_PQ {
 	return nil
 }
 
+// view of a replica and last sequence number it assigned, persisted to
+// rejoin the same view after a restart
+type ViewState struct {
+	View  uint64 `protobuf:"varint,1,opt,name=view" json:"view,omitempty"`
+	SeqNo uint64 `protobuf:"varint,2,opt,name=seqNo" json:"seqNo,omitempty"`
+}
+
+func (m *ViewState) Reset()         { *m = ViewState{} }
+func (m *ViewState) String() string { return proto.CompactTextString(m) }
+func (*ViewState) ProtoMessage()    {}
+
 type NewView struct {
 	View      uint64            `protobuf:"varint,1,opt,name=view" json:"view,omitempty"`
 	Vset      []*ViewChange     `protobuf:"bytes,2,rep,name=vset" json:"vset,omitempty"`
@@ -139,6 +139,7 @@ type omniProto struct {
 	CommitStateDeltaImpl       func(id interface{}) error
 	RollbackStateDeltaImpl     func(id interface{}) error
 	EmptyStateImpl             func() error
+	RewindStateImpl            func(stateBlockNumber, blockNumber uint64) error
 	BeginTxBatchImpl           func(id interface{}) error
 	ExecTxsImpl                func(id interface{}, txs []*pb.Transaction) ([]byte, error)
 	CommitTxBatchImpl          func(id interface{}, metadata []byte) (*pb.Block, error)
@@ -304,6 +305,13 @@ func (op *omniProto) EmptyState() error {
 
 	panic("Unimplemented")
 }
+func (op *omniProto) RewindState(stateBlockNumber, blockNumber uint64) error {
+	if nil != op.RewindStateImpl {
+		return op.RewindStateImpl(stateBlockNumber, blockNumber)
+	}
+
+	panic("Unimplemented")
+}
 func (op *omniProto) BeginTxBatch(id interface{}) error {
 	if nil != op.BeginTxBatchImpl {
 		return op.BeginTxBatchImpl(id)
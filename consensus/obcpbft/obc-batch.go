@@ -33,11 +33,10 @@ type obcBatch struct {
 	externalEventReceiver
 	pbft *pbftCore
 
-	batchSize        int
 	batchStore       []*Request
+	batchBytes       int // marshalled size of the requests in batchStore
 	batchTimer       eventTimer
 	batchTimerActive bool
-	batchTimeout     time.Duration
 	inViewChange     bool
 
 	incomingChan chan *batchMessage // Queues messages for processing by main thread
@@ -84,12 +83,19 @@ func newObcBatch(id uint64, config *viper.Viper, stack consensus.Stack) *obcBatc
 	op.pbft.manager.start()
 	op.externalEventReceiver.manager = op.pbft.manager
 
-	op.batchSize = config.GetInt("general.batchSize")
 	op.batchStore = nil
-	op.batchTimeout, err = time.ParseDuration(config.GetString("general.timeout.batch"))
+	batchTimeout, err := time.ParseDuration(config.GetString("general.timeout.batch"))
 	if err != nil {
 		panic(fmt.Errorf("Cannot parse batch timeout: %s", err))
 	}
+	err = consensus.SetBatchPolicy(consensus.BatchPolicy{
+		MaxMessageCount: config.GetInt("general.batchsize"),
+		MaxBytes:        config.GetInt("general.batchbytes"),
+		Timeout:         batchTimeout,
+	})
+	if err != nil {
+		panic(err)
+	}
 
 	op.incomingChan = make(chan *batchMessage)
 
@@ -101,6 +107,9 @@ func newObcBatch(id uint64, config *viper.Viper, stack consensus.Stack) *obcBatc
 	op.idleChan = make(chan struct{})
 	close(op.idleChan) // TODO remove eventually
 
+	// execute the requests committed before a restart
+	op.pbft.inject(op.pbft.executeOutstanding)
+
 	return op
 }
 
@@ -255,14 +264,22 @@ func (op *obcBatch) leaderProcReq(req *Request) error {
 
 	hash := hashReq(req)
 
+	policy := consensus.GetBatchPolicy()
+	size := proto.Size(req)
+	if policy.Overflows(len(op.batchStore), op.batchBytes, size) {
+		logger.Debug("Batch primary %d request %s does not fit the pending batch, sending it", op.pbft.id, hash)
+		op.sendBatch()
+	}
+
 	logger.Debug("Batch primary %d queueing new request %s", op.pbft.id, hash)
 	op.batchStore = append(op.batchStore, req)
+	op.batchBytes += size
 
 	if !op.batchTimerActive {
 		op.startBatchTimer()
 	}
 
-	if len(op.batchStore) >= op.batchSize {
+	if policy.Full(len(op.batchStore), op.batchBytes) {
 		op.sendBatch()
 	}
 
@@ -274,6 +291,7 @@ func (op *obcBatch) sendBatch() error {
 
 	reqBlock := &RequestBlock{op.batchStore}
 	op.batchStore = nil
+	op.batchBytes = 0
 
 	reqsPacked, err := proto.Marshal(reqBlock)
 	if err != nil {
@@ -448,7 +466,7 @@ func (op *obcBatch) processEvent(event interface{}) interface{} {
 }
 
 func (op *obcBatch) startBatchTimer() {
-	op.batchTimer.reset(op.batchTimeout, batchTimerEvent{})
+	op.batchTimer.reset(consensus.GetBatchPolicy().Timeout, batchTimerEvent{})
 	logger.Debug("Replica %d started the batch timer", op.pbft.id)
 	op.batchTimerActive = true
 }
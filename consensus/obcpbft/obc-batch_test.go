@@ -41,7 +41,9 @@ func TestNetworkBatch(t *testing.T) {
 	batchSize := 2
 	validatorCount := 4
 	net := makeConsumerNetwork(validatorCount, obcBatchHelper, func(ce *consumerEndpoint) {
-		ce.consumer.(*obcBatch).batchSize = batchSize
+		policy := consensus.GetBatchPolicy()
+		policy.MaxMessageCount = batchSize
+		consensus.SetBatchPolicy(policy)
 	})
 	defer net.stop()
 
@@ -81,6 +83,39 @@ func TestNetworkBatch(t *testing.T) {
 	}
 }
 
+func TestNetworkBatchBytes(t *testing.T) {
+	validatorCount := 4
+	net := makeConsumerNetwork(validatorCount, obcBatchHelper, func(ce *consumerEndpoint) {
+		// room for one request, but not for two
+		policy := consensus.GetBatchPolicy()
+		policy.MaxMessageCount = 10
+		policy.MaxBytes = proto.Size(ce.consumer.(*obcBatch).txToReq(createOcMsgWithChainTx(1).Payload)) * 3 / 2
+		consensus.SetBatchPolicy(policy)
+	})
+	defer net.stop()
+
+	broadcaster := net.endpoints[generateBroadcaster(validatorCount)].getHandle()
+	net.endpoints[1].(*consumerEndpoint).consumer.RecvMsg(createOcMsgWithChainTx(1), broadcaster)
+	net.process()
+	net.endpoints[2].(*consumerEndpoint).consumer.RecvMsg(createOcMsgWithChainTx(2), broadcaster)
+	net.process()
+
+	if l := len(net.endpoints[0].(*consumerEndpoint).consumer.(*obcBatch).batchStore); l != 1 {
+		t.Fatalf("%d message expected in primary's batchStore, found %d", 1, l)
+	}
+
+	for _, ep := range net.endpoints {
+		ce := ep.(*consumerEndpoint)
+		block, err := ce.consumer.(*obcBatch).stack.GetBlock(1)
+		if nil != err {
+			t.Fatalf("Replica %d executed requests, expected a new block on the chain, but could not retrieve it : %s", ce.id, err)
+		}
+		if numTrans := len(block.Transactions); numTrans != 1 {
+			t.Fatalf("Replica %d executed %d requests, expected %d", ce.id, numTrans, 1)
+		}
+	}
+}
+
 func TestBatchCustody(t *testing.T) {
 	t.Skip("test is racy")
 	validatorCount := 4
@@ -51,6 +51,9 @@ func newObcClassic(id uint64, config *viper.Viper, stack consensus.Stack) *obcCl
 	op.idleChan = make(chan struct{})
 	close(op.idleChan)
 
+	// execute the requests committed before a restart
+	op.pbft.inject(op.pbft.executeOutstanding)
+
 	return op
 }
 
@@ -115,6 +115,7 @@ type pbftCore struct {
 	chkpts        map[uint64]string // state checkpoints; map lastExec to global hash
 	pset          map[uint64]*ViewChange_PQ
 	qset          map[qidx]*ViewChange_PQ
+	cset          map[uint64]*ViewChange_PQ // committed requests above the low watermark
 
 	skipInProgress bool              // Set when we have detected a fall behind scenario until we pick a new starting point
 	hChkpts        map[uint64]uint64 // highest checkpoint sequence number observed for each replica
@@ -261,6 +262,7 @@ func newPbftCore(id uint64, config *viper.Viper, consumer innerStack) *pbftCore
 	instance.viewChangeStore = make(map[vcidx]*ViewChange)
 	instance.pset = make(map[uint64]*ViewChange_PQ)
 	instance.qset = make(map[qidx]*ViewChange_PQ)
+	instance.cset = make(map[uint64]*ViewChange_PQ)
 	instance.newViewStore = make(map[uint64]*NewView)
 
 	// initialize state transfer
@@ -466,6 +468,10 @@ func (instance *pbftCore) committed(digest string, v uint64, n uint64) bool {
 		return false
 	}
 
+	if c, ok := instance.cset[n]; ok && c.View == v && c.Digest == digest {
+		return true
+	}
+
 	quorum := 0
 	cert := instance.certStore[msgID{v, n}]
 	if cert == nil {
@@ -629,6 +635,7 @@ func (instance *pbftCore) sendPrePrepare(req *Request, digest string) {
 	logger.Debug("Primary %d broadcasting pre-prepare for view=%d/seqNo=%d and digest %s",
 		instance.id, instance.view, n, digest)
 	instance.seqNo = n
+	instance.persistViewState()
 	preprep := &PrePrepare{
 		View:           instance.view,
 		SequenceNumber: n,
@@ -823,6 +830,14 @@ func (instance *pbftCore) recvCommit(commit *Commit) error {
 	cert.commit = append(cert.commit, commit)
 
 	if instance.committed(commit.RequestDigest, commit.View, commit.SequenceNumber) {
+		if _, ok := instance.cset[commit.SequenceNumber]; !ok {
+			instance.cset[commit.SequenceNumber] = &ViewChange_PQ{
+				SequenceNumber: commit.SequenceNumber,
+				Digest:         commit.RequestDigest,
+				View:           commit.View,
+			}
+			instance.persistCSet()
+		}
 		instance.stopTimer()
 		instance.lastNewViewTimeout = instance.newViewTimeout
 		delete(instance.outstandingReqs, commit.RequestDigest)
@@ -974,6 +989,17 @@ func (instance *pbftCore) moveWatermarks(n uint64) {
 		}
 	}
 
+	csetChanged := false
+	for n := range instance.cset {
+		if n <= h {
+			delete(instance.cset, n)
+			csetChanged = true
+		}
+	}
+	if csetChanged {
+		instance.persistCSet()
+	}
+
 	for n := range instance.chkpts {
 		if n < h {
 			delete(instance.chkpts, n)
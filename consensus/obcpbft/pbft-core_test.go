@@ -1266,6 +1266,94 @@ func TestReplicaPersistQSet(t *testing.T) {
 	}
 }
 
+func TestReplicaPersistViewAndCommits(t *testing.T) {
+	persist := make(map[string][]byte)
+	executed := make(chan uint64, 10)
+
+	stack := &omniProto{
+		validateImpl: func(b []byte) error {
+			return nil
+		},
+		broadcastImpl: func(msg []byte) {
+		},
+		executeImpl: func(seqNo uint64, txRaw []byte) {
+			executed <- seqNo
+		},
+		StoreStateImpl: func(key string, value []byte) error {
+			persist[key] = value
+			return nil
+		},
+		DelStateImpl: func(key string) {
+			delete(persist, key)
+		},
+		ReadStateImpl: func(key string) ([]byte, error) {
+			if val, ok := persist[key]; ok {
+				return val, nil
+			}
+			return nil, fmt.Errorf("key not found")
+		},
+		ReadStateSetImpl: func(prefix string) (map[string][]byte, error) {
+			r := make(map[string][]byte)
+			for k, v := range persist {
+				if len(k) >= len(prefix) && k[0:len(prefix)] == prefix {
+					r[k] = v
+				}
+			}
+			return r, nil
+		},
+	}
+	p := newPbftCore(1, loadConfig(), stack)
+	req := &Request{
+		Timestamp: &gp.Timestamp{Seconds: 1, Nanos: 0},
+		Payload:   []byte("foo"),
+		ReplicaId: uint64(0),
+	}
+	digest := hashReq(req)
+	sendEvent(p, &PrePrepare{
+		View:           0,
+		SequenceNumber: 1,
+		RequestDigest:  digest,
+		Request:        req,
+		ReplicaId:      uint64(0),
+	})
+	for _, id := range []uint64{2, 3} {
+		sendEvent(p, &Prepare{View: 0, SequenceNumber: 1, RequestDigest: digest, ReplicaId: id})
+	}
+	for _, id := range []uint64{0, 2} {
+		sendEvent(p, &Commit{View: 0, SequenceNumber: 1, RequestDigest: digest, ReplicaId: id})
+	}
+	select {
+	case n := <-executed:
+		if n != 1 {
+			t.Fatalf("Expected execution of seqNo 1, got %d", n)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Committed request was not executed")
+	}
+	p.view = 2
+	p.seqNo = 5
+	p.persistViewState()
+	p.close()
+
+	// the replica restarts before the execution completes
+	p = newPbftCore(1, loadConfig(), stack)
+	if p.view != 2 || p.seqNo != 5 {
+		t.Errorf("Expected to restore view 2 and seqNo 5, got view %d and seqNo %d", p.view, p.seqNo)
+	}
+	if !p.committed(digest, 0, 1) {
+		t.Fatalf("did not restore cset properly")
+	}
+	p.executeOutstanding()
+	select {
+	case n := <-executed:
+		if n != 1 {
+			t.Fatalf("Expected execution of seqNo 1 after restart, got %d", n)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Restored committed request was not executed")
+	}
+}
+
 func TestReplicaPersistDelete(t *testing.T) {
 	persist := make(map[string][]byte)
 
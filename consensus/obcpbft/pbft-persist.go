@@ -43,6 +43,16 @@ func (instance *pbftCore) persistPSet() {
 	instance.persistPQSet("pset", pset)
 }
 
+func (instance *pbftCore) persistCSet() {
+	var cset []*ViewChange_PQ
+
+	for _, c := range instance.cset {
+		cset = append(cset, c)
+	}
+
+	instance.persistPQSet("cset", cset)
+}
+
 func (instance *pbftCore) persistPQSet(key string, set []*ViewChange_PQ) {
 	raw, err := proto.Marshal(&PQset{set})
 	if err != nil {
@@ -67,6 +77,15 @@ func (instance *pbftCore) restorePQSet(key string) []*ViewChange_PQ {
 	return val.GetSet()
 }
 
+func (instance *pbftCore) persistViewState() {
+	raw, err := proto.Marshal(&ViewState{View: instance.view, SeqNo: instance.seqNo})
+	if err != nil {
+		logger.Warning("Replica %d could not persist view state: %s", instance.id, err)
+		return
+	}
+	instance.consumer.StoreState("view", raw)
+}
+
 func (instance *pbftCore) persistRequest(digest string) {
 	req := instance.reqStore[digest]
 	raw, err := proto.Marshal(req)
@@ -124,6 +143,15 @@ func (instance *pbftCore) restoreState() {
 	}
 	updateSeqView(set)
 
+	if raw, err := instance.consumer.ReadState("view"); err == nil && raw != nil {
+		state := &ViewState{}
+		if err = proto.Unmarshal(raw, state); err != nil {
+			logger.Error("Replica %d could not unmarshal view state - local state is damaged: %s", instance.id, err)
+		} else {
+			updateSeqView([]*ViewChange_PQ{{View: state.View, SequenceNumber: state.SeqNo}})
+		}
+	}
+
 	reqs, err := instance.consumer.ReadStateSet("req.")
 	if err == nil {
 		for k, v := range reqs {
@@ -162,8 +190,26 @@ func (instance *pbftCore) restoreState() {
 
 	instance.restoreLastSeqNo()
 
-	logger.Info("Replica %d restored state: view: %d, seqNo: %d, pset: %d, qset: %d, reqs: %d, chkpts: %d",
-		instance.id, instance.view, instance.seqNo, len(instance.pset), len(instance.qset), len(instance.reqStore), len(instance.chkpts))
+	// the requests committed but not executed yet are executed without collecting their commits again
+	for _, c := range instance.restorePQSet("cset") {
+		if c.SequenceNumber <= instance.h {
+			continue
+		}
+		instance.cset[c.SequenceNumber] = c
+		cert := instance.getCert(c.View, c.SequenceNumber)
+		cert.prePrepare = &PrePrepare{
+			View:           c.View,
+			SequenceNumber: c.SequenceNumber,
+			RequestDigest:  c.Digest,
+			ReplicaId:      instance.primary(c.View),
+		}
+		cert.digest = c.Digest
+		cert.sentPrepare = true
+		cert.sentCommit = true
+	}
+
+	logger.Info("Replica %d restored state: view: %d, seqNo: %d, pset: %d, qset: %d, cset: %d, reqs: %d, chkpts: %d",
+		instance.id, instance.view, instance.seqNo, len(instance.pset), len(instance.qset), len(instance.cset), len(instance.reqStore), len(instance.chkpts))
 }
 
 func (instance *pbftCore) restoreLastSeqNo() {
@@ -412,6 +412,7 @@ func (instance *pbftCore) processNewView2(nv *NewView) error {
 		}
 		instance.persistQSet()
 	}
+	instance.persistViewState()
 
 	if instance.primary(instance.view) != instance.id {
 		for n, d := range nv.Xset {
@@ -0,0 +1,119 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package raft
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/hyperledger/fabric/consensus"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+type committedEntry struct {
+	index uint64
+	entry *Entry
+}
+
+// executor executes the committed entries in order, apart from the event loop of the node so that
+// the node keeps up with the other ones while executing
+type executor struct {
+	stack consensus.Stack
+	queue chan *committedEntry
+
+	lock        sync.Mutex
+	applied     uint64
+	appliedTerm uint64
+	info        []byte // the blockchain info after the applied entry
+}
+
+func newExecutor(stack consensus.Stack) *executor {
+	return &executor{stack: stack, queue: make(chan *committedEntry, 1000)}
+}
+
+// restore resumes from the entry executed by the head block of the ledger
+func (ex *executor) restore() (uint64, uint64) {
+	raw, err := ex.stack.GetBlockHeadMetadata()
+	if err != nil {
+		panic(fmt.Errorf("Cannot get the consensus metadata of the head block: %s", err))
+	}
+	metadata := &Metadata{}
+	if err = proto.Unmarshal(raw, metadata); err != nil {
+		panic(fmt.Errorf("Cannot unmarshal the consensus metadata of the head block: %s", err))
+	}
+	ex.setApplied(metadata.Index, metadata.Term)
+	return metadata.Index, metadata.Term
+}
+
+func (ex *executor) run() {
+	for committed := range ex.queue {
+		ex.apply(committed)
+	}
+}
+
+func (ex *executor) apply(committed *committedEntry) {
+	if applied, _, _ := ex.getApplied(); committed.index <= applied {
+		return
+	}
+	if committed.entry == nil || len(committed.entry.Batch) == 0 {
+		// leader no-ops do not produce blocks
+		term := uint64(0)
+		if committed.entry != nil {
+			term = committed.entry.Term
+		}
+		ex.lock.Lock()
+		ex.applied, ex.appliedTerm = committed.index, term
+		ex.lock.Unlock()
+		return
+	}
+
+	batch := &pb.TransactionBlock{}
+	if err := proto.Unmarshal(committed.entry.Batch, batch); err != nil {
+		panic(fmt.Errorf("Cannot unmarshal the batch of raft log entry %d: %s", committed.index, err))
+	}
+	metadata, _ := proto.Marshal(&Metadata{Index: committed.index, Term: committed.entry.Term})
+	id := committed.index
+	if err := ex.stack.BeginTxBatch(id); err != nil {
+		panic(fmt.Errorf("Cannot begin the batch of raft log entry %d: %s", committed.index, err))
+	}
+	if _, err := ex.stack.ExecTxs(id, batch.Transactions); err != nil {
+		ex.stack.RollbackTxBatch(id)
+		panic(fmt.Errorf("Cannot execute the batch of raft log entry %d: %s", committed.index, err))
+	}
+	if _, err := ex.stack.CommitTxBatch(id, metadata); err != nil {
+		ex.stack.RollbackTxBatch(id)
+		panic(fmt.Errorf("Cannot commit the batch of raft log entry %d: %s", committed.index, err))
+	}
+	logger.Debug("Committed the batch of %d transactions of raft log entry %d", len(batch.Transactions), committed.index)
+	ex.setApplied(committed.index, committed.entry.Term)
+}
+
+// setApplied records the last entry executed, and the blockchain it produced
+func (ex *executor) setApplied(index uint64, term uint64) {
+	info := ex.stack.GetBlockchainInfoBlob()
+	ex.lock.Lock()
+	defer ex.lock.Unlock()
+	ex.applied, ex.appliedTerm, ex.info = index, term, info
+}
+
+func (ex *executor) getApplied() (uint64, uint64, []byte) {
+	ex.lock.Lock()
+	defer ex.lock.Unlock()
+	return ex.applied, ex.appliedTerm, ex.info
+}
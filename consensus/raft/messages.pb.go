@@ -0,0 +1,176 @@
+// Code generated by protoc-gen-go.
+// source: raft/messages.proto
+// DO NOT EDIT!
+
+/*
+Package raft is a generated protocol buffer package.
+
+It is generated from these files:
+	raft/messages.proto
+
+It has these top-level messages:
+	Message
+	RequestVote
+	Vote
+	AppendEntries
+	AppendResult
+	Snapshot
+	Entry
+	HardState
+	Metadata
+*/
+package raft
+
+import proto "github.com/golang/protobuf/proto"
+import fmt "fmt"
+import math "math"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// Message is a raft message between validating peers. Exactly one of the
+// fields after term is set.
+type Message struct {
+	Term          uint64         `protobuf:"varint,1,opt,name=term" json:"term,omitempty"`
+	RequestVote   *RequestVote   `protobuf:"bytes,2,opt,name=requestVote" json:"requestVote,omitempty"`
+	Vote          *Vote          `protobuf:"bytes,3,opt,name=vote" json:"vote,omitempty"`
+	AppendEntries *AppendEntries `protobuf:"bytes,4,opt,name=appendEntries" json:"appendEntries,omitempty"`
+	AppendResult  *AppendResult  `protobuf:"bytes,5,opt,name=appendResult" json:"appendResult,omitempty"`
+	Snapshot      *Snapshot      `protobuf:"bytes,6,opt,name=snapshot" json:"snapshot,omitempty"`
+	// transaction forwarded to the leader, marshalled
+	Request []byte `protobuf:"bytes,7,opt,name=request,proto3" json:"request,omitempty"`
+}
+
+func (m *Message) Reset()         { *m = Message{} }
+func (m *Message) String() string { return proto.CompactTextString(m) }
+func (*Message) ProtoMessage()    {}
+
+func (m *Message) GetRequestVote() *RequestVote {
+	if m != nil {
+		return m.RequestVote
+	}
+	return nil
+}
+
+func (m *Message) GetVote() *Vote {
+	if m != nil {
+		return m.Vote
+	}
+	return nil
+}
+
+func (m *Message) GetAppendEntries() *AppendEntries {
+	if m != nil {
+		return m.AppendEntries
+	}
+	return nil
+}
+
+func (m *Message) GetAppendResult() *AppendResult {
+	if m != nil {
+		return m.AppendResult
+	}
+	return nil
+}
+
+func (m *Message) GetSnapshot() *Snapshot {
+	if m != nil {
+		return m.Snapshot
+	}
+	return nil
+}
+
+type RequestVote struct {
+	LastLogIndex uint64 `protobuf:"varint,1,opt,name=lastLogIndex" json:"lastLogIndex,omitempty"`
+	LastLogTerm  uint64 `protobuf:"varint,2,opt,name=lastLogTerm" json:"lastLogTerm,omitempty"`
+}
+
+func (m *RequestVote) Reset()         { *m = RequestVote{} }
+func (m *RequestVote) String() string { return proto.CompactTextString(m) }
+func (*RequestVote) ProtoMessage()    {}
+
+type Vote struct {
+	Granted bool `protobuf:"varint,1,opt,name=granted" json:"granted,omitempty"`
+}
+
+func (m *Vote) Reset()         { *m = Vote{} }
+func (m *Vote) String() string { return proto.CompactTextString(m) }
+func (*Vote) ProtoMessage()    {}
+
+type AppendEntries struct {
+	PrevLogIndex uint64   `protobuf:"varint,1,opt,name=prevLogIndex" json:"prevLogIndex,omitempty"`
+	PrevLogTerm  uint64   `protobuf:"varint,2,opt,name=prevLogTerm" json:"prevLogTerm,omitempty"`
+	Entries      []*Entry `protobuf:"bytes,3,rep,name=entries" json:"entries,omitempty"`
+	CommitIndex  uint64   `protobuf:"varint,4,opt,name=commitIndex" json:"commitIndex,omitempty"`
+}
+
+func (m *AppendEntries) Reset()         { *m = AppendEntries{} }
+func (m *AppendEntries) String() string { return proto.CompactTextString(m) }
+func (*AppendEntries) ProtoMessage()    {}
+
+func (m *AppendEntries) GetEntries() []*Entry {
+	if m != nil {
+		return m.Entries
+	}
+	return nil
+}
+
+// AppendResult acknowledges the entries up to matchIndex on success. On
+// failure, matchIndex is the last entry the follower may hold in common with
+// the leader.
+type AppendResult struct {
+	Success    bool   `protobuf:"varint,1,opt,name=success" json:"success,omitempty"`
+	MatchIndex uint64 `protobuf:"varint,2,opt,name=matchIndex" json:"matchIndex,omitempty"`
+}
+
+func (m *AppendResult) Reset()         { *m = AppendResult{} }
+func (m *AppendResult) String() string { return proto.CompactTextString(m) }
+func (*AppendResult) ProtoMessage()    {}
+
+// Snapshot tells a follower to transfer the state of the leader, which has
+// discarded the entries the follower lacks. blockchainInfo is the marshalled
+// BlockchainInfo of the leader once it applied the entry index.
+type Snapshot struct {
+	Index          uint64 `protobuf:"varint,1,opt,name=index" json:"index,omitempty"`
+	Term           uint64 `protobuf:"varint,2,opt,name=term" json:"term,omitempty"`
+	BlockchainInfo []byte `protobuf:"bytes,3,opt,name=blockchainInfo,proto3" json:"blockchainInfo,omitempty"`
+}
+
+func (m *Snapshot) Reset()         { *m = Snapshot{} }
+func (m *Snapshot) String() string { return proto.CompactTextString(m) }
+func (*Snapshot) ProtoMessage()    {}
+
+// Entry of the replicated log: a batch of transactions, the marshalled
+// TransactionBlock, empty for the entry a leader appends when elected.
+type Entry struct {
+	Term  uint64 `protobuf:"varint,1,opt,name=term" json:"term,omitempty"`
+	Batch []byte `protobuf:"bytes,2,opt,name=batch,proto3" json:"batch,omitempty"`
+}
+
+func (m *Entry) Reset()         { *m = Entry{} }
+func (m *Entry) String() string { return proto.CompactTextString(m) }
+func (*Entry) ProtoMessage()    {}
+
+// HardState is the state a node persists before answering: its term, the
+// node it voted for in the term, and the last entry discarded from its log.
+type HardState struct {
+	Term          uint64 `protobuf:"varint,1,opt,name=term" json:"term,omitempty"`
+	VotedFor      string `protobuf:"bytes,2,opt,name=votedFor" json:"votedFor,omitempty"`
+	SnapshotIndex uint64 `protobuf:"varint,3,opt,name=snapshotIndex" json:"snapshotIndex,omitempty"`
+	SnapshotTerm  uint64 `protobuf:"varint,4,opt,name=snapshotTerm" json:"snapshotTerm,omitempty"`
+}
+
+func (m *HardState) Reset()         { *m = HardState{} }
+func (m *HardState) String() string { return proto.CompactTextString(m) }
+func (*HardState) ProtoMessage()    {}
+
+type Metadata struct {
+	Index uint64 `protobuf:"varint,1,opt,name=index" json:"index,omitempty"`
+	Term  uint64 `protobuf:"varint,2,opt,name=term" json:"term,omitempty"`
+}
+
+func (m *Metadata) Reset()         { *m = Metadata{} }
+func (m *Metadata) String() string { return proto.CompactTextString(m) }
+func (*Metadata) ProtoMessage()    {}
@@ -0,0 +1,282 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package raft
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/hyperledger/fabric/consensus"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+type testMessage struct {
+	from    string
+	to      string
+	payload []byte
+}
+
+// testNetwork connects validating peers running raft, whose messages are delivered by the test
+type testNetwork struct {
+	stacks map[string]*testStack
+	names  []string
+	queue  []*testMessage
+	down   map[string]bool
+}
+
+func newTestNetwork(n int, config *config) *testNetwork {
+	net := &testNetwork{stacks: make(map[string]*testStack), down: make(map[string]bool)}
+	for i := 0; i < n; i++ {
+		net.names = append(net.names, fmt.Sprintf("vp%d", i))
+	}
+	for _, name := range net.names {
+		stack := &testStack{net: net, name: name, state: make(map[string][]byte), blocks: []*pb.Block{{}}}
+		net.stacks[name] = stack
+		stack.restart(config)
+	}
+	return net
+}
+
+func testConfig(nodes int) *config {
+	return &config{
+		nodes:          nodes,
+		tick:           time.Hour,
+		electionTicks:  10,
+		heartbeatTicks: 1,
+		batch:          consensus.BatchPolicy{MaxMessageCount: 3, Timeout: 2 * time.Hour},
+		logRetain:      1000,
+		maxAppend:      2,
+	}
+}
+
+// deliver delivers the messages in flight until there are none left
+func (net *testNetwork) deliver() {
+	for len(net.queue) > 0 {
+		m := net.queue[0]
+		net.queue = net.queue[1:]
+		if net.down[m.to] || net.down[m.from] {
+			continue
+		}
+		stack := net.stacks[m.to]
+		if err := stack.node.RecvMsg(&pb.Message{Type: pb.Message_CONSENSUS, Payload: m.payload}, &pb.PeerID{Name: m.from}); err != nil {
+			panic(err)
+		}
+		stack.settle()
+	}
+}
+
+// tick ticks the running peers and delivers the messages they send
+func (net *testNetwork) tick(n int) {
+	for i := 0; i < n; i++ {
+		for _, name := range net.names {
+			if !net.down[name] {
+				net.stacks[name].node.tick()
+				net.stacks[name].settle()
+			}
+		}
+		net.deliver()
+	}
+}
+
+// electLeader ticks until a running peer is the leader, and returns it
+func (net *testNetwork) electLeader() *testStack {
+	for i := 0; i < 100; i++ {
+		net.tick(1)
+		for _, name := range net.names {
+			if stack := net.stacks[name]; !net.down[name] && stack.node.role == leader {
+				return stack
+			}
+		}
+	}
+	return nil
+}
+
+func (net *testNetwork) follower(leader *testStack) *testStack {
+	for _, name := range net.names {
+		if name != leader.name && !net.down[name] {
+			return net.stacks[name]
+		}
+	}
+	return nil
+}
+
+// testStack is the stack of a validating peer, with an in memory ledger and persisted state
+type testStack struct {
+	net    *testNetwork
+	name   string
+	node   *raftNode
+	state  map[string][]byte
+	blocks []*pb.Block
+	batch  []*pb.Transaction
+}
+
+// restart creates the raft node of the peer again, from its persisted state and ledger
+func (s *testStack) restart(config *config) {
+	s.node = newRaftNode(s, config)
+}
+
+// settle runs the events and executes the entries the node queued, in place of its goroutines
+func (s *testStack) settle() {
+	for {
+		select {
+		case event := <-s.node.events:
+			s.node.handle(event)
+		case committed := <-s.node.exec.queue:
+			s.node.exec.apply(committed)
+		default:
+			return
+		}
+	}
+}
+
+func (s *testStack) propose(uuid string) {
+	raw, _ := proto.Marshal(&pb.Transaction{Uuid: uuid})
+	if err := s.node.RecvMsg(&pb.Message{Type: pb.Message_CHAIN_TRANSACTION, Payload: raw}, &pb.PeerID{Name: s.name}); err != nil {
+		panic(err)
+	}
+	s.settle()
+}
+
+// executed returns the uuids of the transactions in the blockchain of the peer
+func (s *testStack) executed() []string {
+	var uuids []string
+	for _, block := range s.blocks {
+		for _, tx := range block.Transactions {
+			uuids = append(uuids, tx.Uuid)
+		}
+	}
+	return uuids
+}
+
+func (s *testStack) GetNetworkInfo() (*pb.PeerEndpoint, []*pb.PeerEndpoint, error) {
+	return nil, nil, fmt.Errorf("Not implemented")
+}
+
+func (s *testStack) GetNetworkHandles() (*pb.PeerID, []*pb.PeerID, error) {
+	var network []*pb.PeerID
+	for _, name := range s.net.names {
+		network = append(network, &pb.PeerID{Name: name})
+	}
+	return &pb.PeerID{Name: s.name}, network, nil
+}
+
+func (s *testStack) Broadcast(msg *pb.Message, peerType pb.PeerEndpoint_Type) error {
+	return fmt.Errorf("Not implemented")
+}
+
+func (s *testStack) Unicast(msg *pb.Message, receiverHandle *pb.PeerID) error {
+	s.net.queue = append(s.net.queue, &testMessage{from: s.name, to: receiverHandle.Name, payload: msg.Payload})
+	return nil
+}
+
+func (s *testStack) Sign(msg []byte) ([]byte, error) {
+	return msg, nil
+}
+
+func (s *testStack) Verify(peerID *pb.PeerID, signature []byte, message []byte) error {
+	return nil
+}
+
+func (s *testStack) BeginTxBatch(id interface{}) error {
+	if s.batch != nil {
+		return fmt.Errorf("Batch already started")
+	}
+	s.batch = []*pb.Transaction{}
+	return nil
+}
+
+func (s *testStack) ExecTxs(id interface{}, txs []*pb.Transaction) ([]byte, error) {
+	s.batch = append(s.batch, txs...)
+	return nil, nil
+}
+
+func (s *testStack) CommitTxBatch(id interface{}, metadata []byte) (*pb.Block, error) {
+	block := &pb.Block{Transactions: s.batch, ConsensusMetadata: metadata}
+	s.blocks = append(s.blocks, block)
+	s.batch = nil
+	return block, nil
+}
+
+func (s *testStack) RollbackTxBatch(id interface{}) error {
+	s.batch = nil
+	return nil
+}
+
+func (s *testStack) PreviewCommitTxBatch(id interface{}, metadata []byte) ([]byte, error) {
+	return nil, fmt.Errorf("Not implemented")
+}
+
+// SkipTo copies the blockchain of the first peer up to the height of id
+func (s *testStack) SkipTo(tag uint64, id []byte, peers []*pb.PeerID) {
+	info := &pb.BlockchainInfo{}
+	if err := proto.Unmarshal(id, info); err != nil {
+		panic(err)
+	}
+	source := s.net.stacks[peers[0].Name]
+	s.blocks = append([]*pb.Block(nil), source.blocks[:info.Height]...)
+	s.node.StateUpdated(tag, id)
+}
+
+func (s *testStack) InvalidateState() {}
+
+func (s *testStack) ValidateState() {}
+
+func (s *testStack) GetBlock(id uint64) (*pb.Block, error) {
+	if id >= uint64(len(s.blocks)) {
+		return nil, fmt.Errorf("Block %d not found", id)
+	}
+	return s.blocks[id], nil
+}
+
+func (s *testStack) GetBlockchainSize() uint64 {
+	return uint64(len(s.blocks))
+}
+
+func (s *testStack) GetBlockchainInfoBlob() []byte {
+	raw, _ := proto.Marshal(&pb.BlockchainInfo{Height: uint64(len(s.blocks))})
+	return raw
+}
+
+func (s *testStack) GetBlockHeadMetadata() ([]byte, error) {
+	return s.blocks[len(s.blocks)-1].ConsensusMetadata, nil
+}
+
+func (s *testStack) StoreState(key string, value []byte) error {
+	s.state[key] = value
+	return nil
+}
+
+func (s *testStack) ReadState(key string) ([]byte, error) {
+	return s.state[key], nil
+}
+
+func (s *testStack) ReadStateSet(prefix string) (map[string][]byte, error) {
+	set := make(map[string][]byte)
+	for key, value := range s.state {
+		if strings.HasPrefix(key, prefix) {
+			set[key] = value
+		}
+	}
+	return set, nil
+}
+
+func (s *testStack) DelState(key string) {
+	delete(s.state, key)
+}
@@ -0,0 +1,760 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package raft
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/op/go-logging"
+	"github.com/spf13/viper"
+
+	"github.com/hyperledger/fabric/consensus"
+	"github.com/hyperledger/fabric/core/util"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// Raft orders the transactions with the raft algorithm, which tolerates the crash of a minority of
+// the validating peers, but not byzantine ones. The validating peers elect a leader, to which the
+// others forward the transactions they receive. The leader cuts the transactions into batches,
+// which it appends to a replicated log; a batch is executed as a block by each validating peer once
+// a majority of them has it in their log. The index of the entry of the last block executed is
+// recorded in the consensus metadata of the block, which is where a restarted validating peer
+// resumes from. The log is persisted, and the leader discards the entries every validating peer is
+// expected to have executed; a validating peer lagging further behind transfers the state.
+
+var logger *logging.Logger // package-level logger
+
+func init() {
+	logger = logging.MustGetLogger("consensus/raft")
+}
+
+var pluginInstance consensus.Consenter // singleton service
+
+// GetPlugin returns the handle to the Consenter singleton
+func GetPlugin(c consensus.Stack) consensus.Consenter {
+	if pluginInstance == nil {
+		node := newRaftNode(c, loadConfig())
+		node.start()
+		pluginInstance = node
+	}
+	return pluginInstance
+}
+
+type config struct {
+	nodes          int
+	tick           time.Duration
+	electionTicks  int
+	heartbeatTicks int
+	batch          consensus.BatchPolicy // initial batch policy
+	logRetain      uint64
+	maxAppend      int
+}
+
+func loadConfig() *config {
+	c := &config{
+		nodes:          viper.GetInt("peer.validator.consensus.raft.nodes"),
+		tick:           viper.GetDuration("peer.validator.consensus.raft.tick"),
+		electionTicks:  viper.GetInt("peer.validator.consensus.raft.electionticks"),
+		heartbeatTicks: viper.GetInt("peer.validator.consensus.raft.heartbeatticks"),
+		batch: consensus.BatchPolicy{
+			MaxMessageCount: viper.GetInt("peer.validator.consensus.raft.batchsize"),
+			MaxBytes:        viper.GetInt("peer.validator.consensus.raft.batchbytes"),
+			Timeout:         viper.GetDuration("peer.validator.consensus.raft.batchtimeout"),
+		},
+		logRetain: uint64(viper.GetInt("peer.validator.consensus.raft.logretain")),
+		maxAppend: viper.GetInt("peer.validator.consensus.raft.maxappend"),
+	}
+	if c.nodes <= 0 || c.tick <= 0 || c.electionTicks <= c.heartbeatTicks || c.heartbeatTicks <= 0 || c.batch.Validate() != nil || c.maxAppend <= 0 {
+		panic(fmt.Errorf("Invalid raft configuration: %+v", *c))
+	}
+	return c
+}
+
+type role int
+
+const (
+	follower role = iota
+	candidate
+	leader
+)
+
+type messageEvent struct {
+	from string
+	msg  *Message
+}
+
+type requestEvent struct {
+	tx *pb.Transaction
+}
+
+type stateUpdatedEvent struct {
+	tag uint64
+}
+
+// raftNode is the raft state machine of a validating peer. All its methods but the ones of the
+// Consenter interface run on the goroutine of its event loop
+type raftNode struct {
+	stack  consensus.Stack
+	config *config
+	self   string
+	events chan interface{}
+	exec   *executor
+
+	// persisted, see HardState
+	term          uint64
+	votedFor      string
+	snapshotIndex uint64
+	snapshotTerm  uint64
+	entries       []*Entry // the entries following snapshotIndex
+
+	role             role
+	leader           string
+	votes            map[string]bool
+	nextIndex        map[string]uint64
+	matchIndex       map[string]uint64
+	commitIndex      uint64
+	pushedIndex      uint64 // last committed entry handed to the executor
+	transferring     bool
+	electionElapsed  int
+	electionTimeout  int
+	heartbeatElapsed int
+	batchElapsed     int
+	pending          []*pb.Transaction // batch the leader is cutting
+	pendingBytes     int               // marshalled size of the pending transactions
+	forwarding       []*pb.Transaction // transactions waiting for a leader to be known
+}
+
+func newRaftNode(stack consensus.Stack, config *config) *raftNode {
+	self, _, err := stack.GetNetworkHandles()
+	if err != nil {
+		panic(fmt.Errorf("Cannot get the handle of the validating peer: %s", err))
+	}
+	if err = consensus.SetBatchPolicy(config.batch); err != nil {
+		panic(err)
+	}
+	rn := &raftNode{stack: stack, config: config, self: self.Name, events: make(chan interface{}, 1000), exec: newExecutor(stack)}
+	rn.restore()
+	rn.resetElectionTimeout()
+	logger.Info("Raft node %s of %d: term %d, log [%d, %d], applied %d", rn.self, config.nodes, rn.term, rn.snapshotIndex, rn.lastIndex(), rn.pushedIndex)
+	return rn
+}
+
+// restore loads the persisted state of the node, and resumes after the last block executed
+func (rn *raftNode) restore() {
+	if raw, err := rn.stack.ReadState("raft.hardstate"); err == nil && raw != nil {
+		state := &HardState{}
+		if err = proto.Unmarshal(raw, state); err != nil {
+			panic(fmt.Errorf("Cannot unmarshal the raft state: %s", err))
+		}
+		rn.term, rn.votedFor, rn.snapshotIndex, rn.snapshotTerm = state.Term, state.VotedFor, state.SnapshotIndex, state.SnapshotTerm
+	}
+	stored, err := rn.stack.ReadStateSet("raft.entry.")
+	if err != nil {
+		panic(fmt.Errorf("Cannot read the raft log: %s", err))
+	}
+	var indexes []uint64
+	entries := make(map[uint64]*Entry)
+	for key, raw := range stored {
+		index, err := strconv.ParseUint(strings.TrimPrefix(key, "raft.entry."), 10, 64)
+		entry := &Entry{}
+		if err == nil {
+			err = proto.Unmarshal(raw, entry)
+		}
+		if err != nil {
+			panic(fmt.Errorf("Cannot read raft log entry %s: %s", key, err))
+		}
+		indexes = append(indexes, index)
+		entries[index] = entry
+	}
+	sort.Sort(uint64Slice(indexes))
+	for _, index := range indexes {
+		if index == rn.lastIndex()+1 {
+			rn.entries = append(rn.entries, entries[index])
+		} else if index > rn.snapshotIndex {
+			rn.stack.DelState(entryKey(index))
+		}
+	}
+
+	applied, appliedTerm := rn.exec.restore()
+	if applied > rn.lastIndex() {
+		// the log of the node is behind its ledger, which received the state of another node
+		rn.discardLog(applied, appliedTerm)
+	}
+	rn.commitIndex = applied
+	rn.pushedIndex = applied
+}
+
+func (rn *raftNode) start() {
+	go rn.exec.run()
+	go rn.run()
+}
+
+func (rn *raftNode) run() {
+	ticker := time.NewTicker(rn.config.tick)
+	for {
+		select {
+		case event := <-rn.events:
+			rn.handle(event)
+		case <-ticker.C:
+			rn.tick()
+		}
+	}
+}
+
+// RecvMsg is called for Message_CHAIN_TRANSACTION and Message_CONSENSUS messages.
+func (rn *raftNode) RecvMsg(msg *pb.Message, senderHandle *pb.PeerID) error {
+	switch msg.Type {
+	case pb.Message_CHAIN_TRANSACTION:
+		tx := &pb.Transaction{}
+		if err := proto.Unmarshal(msg.Payload, tx); err != nil {
+			return fmt.Errorf("Error unmarshalling transaction: %s", err)
+		}
+		rn.events <- &requestEvent{tx}
+	case pb.Message_CONSENSUS:
+		m := &Message{}
+		if err := proto.Unmarshal(msg.Payload, m); err != nil {
+			return fmt.Errorf("Error unmarshalling raft message from %s: %s", senderHandle.Name, err)
+		}
+		rn.events <- &messageEvent{senderHandle.Name, m}
+	default:
+		return fmt.Errorf("Unexpected message type %s", msg.Type)
+	}
+	return nil
+}
+
+// StateUpdating is called once state transfer is initiated
+func (rn *raftNode) StateUpdating(tag uint64, id []byte) {
+	logger.Info("Transferring the state up to entry %d", tag)
+}
+
+// StateUpdated is called once state transfer finishes, tag is the index of the entry reached
+func (rn *raftNode) StateUpdated(tag uint64, id []byte) {
+	rn.events <- &stateUpdatedEvent{tag}
+}
+
+func (rn *raftNode) handle(event interface{}) {
+	switch e := event.(type) {
+	case *messageEvent:
+		rn.step(e.from, e.msg)
+	case *requestEvent:
+		rn.propose(e.tx)
+	case *stateUpdatedEvent:
+		rn.stateUpdated(e.tag)
+	}
+}
+
+func (rn *raftNode) tick() {
+	if rn.role == leader {
+		rn.heartbeatElapsed++
+		if rn.heartbeatElapsed >= rn.config.heartbeatTicks {
+			rn.heartbeatElapsed = 0
+			rn.broadcastAppend()
+		}
+		if len(rn.pending) > 0 {
+			rn.batchElapsed++
+			if time.Duration(rn.batchElapsed)*rn.config.tick >= consensus.GetBatchPolicy().Timeout {
+				rn.cutBatch()
+			}
+		}
+	} else {
+		rn.electionElapsed++
+		if rn.electionElapsed >= rn.electionTimeout {
+			rn.campaign()
+		}
+	}
+	rn.compact()
+}
+
+// step handles a raft message from another validating peer
+func (rn *raftNode) step(from string, msg *Message) {
+	if msg.Request != nil {
+		tx := &pb.Transaction{}
+		if err := proto.Unmarshal(msg.Request, tx); err != nil {
+			logger.Warning("Dropping invalid transaction forwarded by %s: %s", from, err)
+			return
+		}
+		rn.propose(tx)
+		return
+	}
+
+	if msg.Term > rn.term {
+		newLeader := ""
+		if msg.AppendEntries != nil || msg.Snapshot != nil {
+			newLeader = from
+		}
+		rn.becomeFollower(msg.Term, newLeader)
+	} else if msg.Term < rn.term {
+		// the sender learns of the new term from the answer
+		if msg.RequestVote != nil {
+			rn.send(from, &Message{Vote: &Vote{Granted: false}})
+		} else if msg.AppendEntries != nil {
+			rn.send(from, &Message{AppendResult: &AppendResult{Success: false}})
+		}
+		return
+	}
+
+	switch {
+	case msg.RequestVote != nil:
+		rn.handleRequestVote(from, msg.RequestVote)
+	case msg.Vote != nil:
+		rn.handleVote(from, msg.Vote)
+	case msg.AppendEntries != nil:
+		rn.handleAppendEntries(from, msg.AppendEntries)
+	case msg.AppendResult != nil:
+		rn.handleAppendResult(from, msg.AppendResult)
+	case msg.Snapshot != nil:
+		rn.handleSnapshot(from, msg.Snapshot)
+	}
+}
+
+func (rn *raftNode) campaign() {
+	rn.role = candidate
+	rn.term++
+	rn.votedFor = rn.self
+	rn.leader = ""
+	rn.persistHardState()
+	rn.votes = map[string]bool{rn.self: true}
+	rn.resetElectionTimeout()
+	logger.Info("Raft node %s campaigns in term %d", rn.self, rn.term)
+	if len(rn.votes) >= rn.quorum() {
+		rn.becomeLeader()
+		return
+	}
+	for _, peer := range rn.peers() {
+		rn.send(peer, &Message{RequestVote: &RequestVote{LastLogIndex: rn.lastIndex(), LastLogTerm: rn.termAt(rn.lastIndex())}})
+	}
+}
+
+func (rn *raftNode) handleRequestVote(from string, rv *RequestVote) {
+	lastTerm := rn.termAt(rn.lastIndex())
+	upToDate := rv.LastLogTerm > lastTerm || (rv.LastLogTerm == lastTerm && rv.LastLogIndex >= rn.lastIndex())
+	granted := (rn.votedFor == "" || rn.votedFor == from) && upToDate
+	if granted {
+		rn.votedFor = from
+		rn.persistHardState()
+		rn.electionElapsed = 0
+	}
+	rn.send(from, &Message{Vote: &Vote{Granted: granted}})
+}
+
+func (rn *raftNode) handleVote(from string, vote *Vote) {
+	if rn.role != candidate || !vote.Granted {
+		return
+	}
+	rn.votes[from] = true
+	if len(rn.votes) >= rn.quorum() {
+		rn.becomeLeader()
+	}
+}
+
+func (rn *raftNode) becomeLeader() {
+	logger.Info("Raft node %s is the leader of term %d", rn.self, rn.term)
+	rn.role = leader
+	rn.leader = rn.self
+	rn.nextIndex = make(map[string]uint64)
+	rn.matchIndex = make(map[string]uint64)
+	rn.heartbeatElapsed = 0
+	// the entries of the previous terms are committed along with one of the term
+	rn.appendEntry(&Entry{Term: rn.term})
+	rn.broadcastAppend()
+	rn.maybeCommit()
+	forwarding := rn.forwarding
+	rn.forwarding = nil
+	for _, tx := range forwarding {
+		rn.propose(tx)
+	}
+}
+
+func (rn *raftNode) becomeFollower(term uint64, newLeader string) {
+	if term > rn.term {
+		rn.term = term
+		rn.votedFor = ""
+		rn.persistHardState()
+	}
+	if rn.role == leader {
+		// the transactions not yet in the log are handed to the new leader
+		rn.forwarding = append(rn.forwarding, rn.pending...)
+		rn.pending = nil
+		rn.pendingBytes = 0
+	}
+	rn.role = follower
+	rn.leader = newLeader
+	rn.electionElapsed = 0
+	if newLeader != "" {
+		forwarding := rn.forwarding
+		rn.forwarding = nil
+		for _, tx := range forwarding {
+			rn.propose(tx)
+		}
+	}
+}
+
+// propose orders a transaction: the leader adds it to the batch it is cutting, the other nodes
+// forward it to the leader
+func (rn *raftNode) propose(tx *pb.Transaction) {
+	switch {
+	case rn.role == leader:
+		policy := consensus.GetBatchPolicy()
+		size := proto.Size(tx)
+		if policy.Overflows(len(rn.pending), rn.pendingBytes, size) {
+			rn.cutBatch()
+		}
+		rn.pending = append(rn.pending, tx)
+		rn.pendingBytes += size
+		if len(rn.pending) == 1 {
+			rn.batchElapsed = 0
+		}
+		if policy.Full(len(rn.pending), rn.pendingBytes) {
+			rn.cutBatch()
+		}
+	case rn.leader != "":
+		raw, err := proto.Marshal(tx)
+		if err != nil {
+			logger.Error("Cannot marshal transaction %s: %s", tx.Uuid, err)
+			return
+		}
+		rn.send(rn.leader, &Message{Request: raw})
+	default:
+		rn.forwarding = append(rn.forwarding, tx)
+	}
+}
+
+func (rn *raftNode) cutBatch() {
+	raw, err := proto.Marshal(&pb.TransactionBlock{Transactions: rn.pending})
+	rn.pending = nil
+	rn.pendingBytes = 0
+	if err != nil {
+		logger.Error("Cannot marshal batch: %s", err)
+		return
+	}
+	rn.appendEntry(&Entry{Term: rn.term, Batch: raw})
+	rn.broadcastAppend()
+	rn.maybeCommit()
+}
+
+func (rn *raftNode) broadcastAppend() {
+	for _, peer := range rn.peers() {
+		rn.sendAppend(peer)
+	}
+}
+
+// sendAppend sends to a follower the entries it lacks, or the state of the leader if the leader
+// discarded them
+func (rn *raftNode) sendAppend(to string) {
+	next, ok := rn.nextIndex[to]
+	if !ok {
+		next = rn.lastIndex() + 1
+		rn.nextIndex[to] = next
+	}
+	if next <= rn.snapshotIndex {
+		index, term, info := rn.exec.getApplied()
+		rn.send(to, &Message{Snapshot: &Snapshot{Index: index, Term: term, BlockchainInfo: info}})
+		rn.nextIndex[to] = index + 1
+		return
+	}
+	last := rn.lastIndex()
+	if max := next - 1 + uint64(rn.config.maxAppend); last > max {
+		last = max
+	}
+	prev := next - 1
+	rn.send(to, &Message{AppendEntries: &AppendEntries{
+		PrevLogIndex: prev,
+		PrevLogTerm:  rn.termAt(prev),
+		Entries:      rn.entries[prev-rn.snapshotIndex : last-rn.snapshotIndex],
+		CommitIndex:  rn.commitIndex,
+	}})
+}
+
+func (rn *raftNode) handleAppendEntries(from string, ae *AppendEntries) {
+	if rn.role != follower || rn.leader != from {
+		rn.becomeFollower(rn.term, from)
+	}
+	rn.electionElapsed = 0
+
+	prev, entries := ae.PrevLogIndex, ae.Entries
+	if prev < rn.snapshotIndex {
+		// the discarded entries were committed, so the leader has them too
+		skip := rn.snapshotIndex - prev
+		if uint64(len(entries)) <= skip {
+			rn.send(from, &Message{AppendResult: &AppendResult{Success: true, MatchIndex: rn.snapshotIndex}})
+			return
+		}
+		prev, entries = rn.snapshotIndex, entries[skip:]
+	} else if prev > rn.lastIndex() || rn.termAt(prev) != ae.PrevLogTerm {
+		hint := prev - 1
+		if prev > rn.lastIndex() {
+			hint = rn.lastIndex()
+		}
+		rn.send(from, &Message{AppendResult: &AppendResult{Success: false, MatchIndex: hint}})
+		return
+	}
+
+	for i, entry := range entries {
+		index := prev + 1 + uint64(i)
+		if index <= rn.lastIndex() {
+			if rn.termAt(index) == entry.Term {
+				continue
+			}
+			rn.truncate(index)
+		}
+		rn.appendEntry(entry)
+	}
+	last := prev + uint64(len(entries))
+	if commit := ae.CommitIndex; commit > rn.commitIndex {
+		if commit > last {
+			commit = last
+		}
+		if commit > rn.commitIndex {
+			rn.commitIndex = commit
+			rn.applyCommitted()
+		}
+	}
+	rn.send(from, &Message{AppendResult: &AppendResult{Success: true, MatchIndex: last}})
+}
+
+func (rn *raftNode) handleAppendResult(from string, result *AppendResult) {
+	if rn.role != leader {
+		return
+	}
+	if result.Success {
+		if result.MatchIndex > rn.matchIndex[from] {
+			rn.matchIndex[from] = result.MatchIndex
+		}
+		if result.MatchIndex+1 > rn.nextIndex[from] {
+			rn.nextIndex[from] = result.MatchIndex + 1
+		}
+		rn.maybeCommit()
+		if rn.nextIndex[from] <= rn.lastIndex() {
+			rn.sendAppend(from)
+		}
+		return
+	}
+	next := rn.nextIndex[from] - 1
+	if result.MatchIndex+1 < next {
+		next = result.MatchIndex + 1
+	}
+	if next < 1 {
+		next = 1
+	}
+	rn.nextIndex[from] = next
+	rn.sendAppend(from)
+}
+
+// handleSnapshot has the follower transfer the state of the leader, which discarded the entries
+// the follower lacks
+func (rn *raftNode) handleSnapshot(from string, snapshot *Snapshot) {
+	rn.electionElapsed = 0
+	if rn.transferring || snapshot.Index <= rn.commitIndex {
+		return
+	}
+	if applied, _, _ := rn.exec.getApplied(); applied < rn.pushedIndex {
+		// the state is transferred once the executor is done with the committed entries
+		return
+	}
+	logger.Info("Raft node %s transfers the state of %s up to entry %d", rn.self, from, snapshot.Index)
+	rn.discardLog(snapshot.Index, snapshot.Term)
+	rn.commitIndex = snapshot.Index
+	rn.pushedIndex = snapshot.Index
+	rn.transferring = true
+	rn.stack.InvalidateState()
+	rn.stack.SkipTo(snapshot.Index, snapshot.BlockchainInfo, []*pb.PeerID{{Name: from}})
+}
+
+func (rn *raftNode) stateUpdated(tag uint64) {
+	if !rn.transferring {
+		return
+	}
+	logger.Info("Raft node %s transferred the state up to entry %d", rn.self, tag)
+	rn.transferring = false
+	rn.exec.setApplied(tag, rn.termAt(tag))
+	rn.stack.ValidateState()
+	if tag > rn.pushedIndex {
+		rn.pushedIndex = tag
+	}
+	if tag > rn.commitIndex {
+		rn.commitIndex = tag
+	}
+	rn.applyCommitted()
+}
+
+// maybeCommit commits the entries of the leader a majority of the nodes have in their log
+func (rn *raftNode) maybeCommit() {
+	for index := rn.lastIndex(); index > rn.commitIndex; index-- {
+		if rn.termAt(index) != rn.term {
+			return
+		}
+		count := 1
+		for _, match := range rn.matchIndex {
+			if match >= index {
+				count++
+			}
+		}
+		if count >= rn.quorum() {
+			rn.commitIndex = index
+			rn.applyCommitted()
+			// the followers learn of the commit at once rather than with the next heartbeat
+			rn.broadcastAppend()
+			return
+		}
+	}
+}
+
+// applyCommitted hands the committed entries to the executor
+func (rn *raftNode) applyCommitted() {
+	if rn.transferring {
+		return
+	}
+	for rn.pushedIndex < rn.commitIndex {
+		index := rn.pushedIndex + 1
+		rn.exec.queue <- &committedEntry{index: index, entry: rn.entryAt(index)}
+		rn.pushedIndex = index
+	}
+}
+
+// compact discards the entries of the log which are executed, but the logRetain last ones
+func (rn *raftNode) compact() {
+	applied, _, _ := rn.exec.getApplied()
+	if applied < rn.snapshotIndex+2*rn.config.logRetain || applied > rn.lastIndex() {
+		return
+	}
+	index := applied - rn.config.logRetain
+	term := rn.termAt(index)
+	for i := rn.snapshotIndex + 1; i <= index; i++ {
+		rn.stack.DelState(entryKey(i))
+	}
+	rn.entries = append([]*Entry(nil), rn.entries[index-rn.snapshotIndex:]...)
+	rn.snapshotIndex, rn.snapshotTerm = index, term
+	rn.persistHardState()
+}
+
+// discardLog empties the log, whose last entry is then index
+func (rn *raftNode) discardLog(index uint64, term uint64) {
+	for i := rn.snapshotIndex + 1; i <= rn.lastIndex(); i++ {
+		rn.stack.DelState(entryKey(i))
+	}
+	rn.entries = nil
+	rn.snapshotIndex, rn.snapshotTerm = index, term
+	rn.persistHardState()
+}
+
+func (rn *raftNode) appendEntry(entry *Entry) {
+	index := rn.lastIndex() + 1
+	raw, err := proto.Marshal(entry)
+	if err == nil {
+		err = rn.stack.StoreState(entryKey(index), raw)
+	}
+	if err != nil {
+		panic(fmt.Errorf("Cannot persist raft log entry %d: %s", index, err))
+	}
+	rn.entries = append(rn.entries, entry)
+}
+
+// truncate removes the entries from index on
+func (rn *raftNode) truncate(index uint64) {
+	for i := index; i <= rn.lastIndex(); i++ {
+		rn.stack.DelState(entryKey(i))
+	}
+	rn.entries = rn.entries[:index-rn.snapshotIndex-1]
+}
+
+func (rn *raftNode) persistHardState() {
+	raw, err := proto.Marshal(&HardState{Term: rn.term, VotedFor: rn.votedFor, SnapshotIndex: rn.snapshotIndex, SnapshotTerm: rn.snapshotTerm})
+	if err == nil {
+		err = rn.stack.StoreState("raft.hardstate", raw)
+	}
+	if err != nil {
+		panic(fmt.Errorf("Cannot persist the raft state: %s", err))
+	}
+}
+
+func (rn *raftNode) lastIndex() uint64 {
+	return rn.snapshotIndex + uint64(len(rn.entries))
+}
+
+// termAt returns the term of the entry index, 0 if the entry is unknown
+func (rn *raftNode) termAt(index uint64) uint64 {
+	if index == rn.snapshotIndex {
+		return rn.snapshotTerm
+	}
+	if entry := rn.entryAt(index); entry != nil {
+		return entry.Term
+	}
+	return 0
+}
+
+func (rn *raftNode) entryAt(index uint64) *Entry {
+	if index <= rn.snapshotIndex || index > rn.lastIndex() {
+		return nil
+	}
+	return rn.entries[index-rn.snapshotIndex-1]
+}
+
+func (rn *raftNode) quorum() int {
+	return rn.config.nodes/2 + 1
+}
+
+func (rn *raftNode) resetElectionTimeout() {
+	rn.electionElapsed = 0
+	rn.electionTimeout = rn.config.electionTicks + rand.Intn(rn.config.electionTicks)
+}
+
+// peers returns the names of the other validating peers
+func (rn *raftNode) peers() []string {
+	_, network, err := rn.stack.GetNetworkHandles()
+	if err != nil {
+		logger.Warning("Cannot get the validating network: %s", err)
+		return nil
+	}
+	var peers []string
+	seen := map[string]bool{rn.self: true}
+	for _, handle := range network {
+		if !seen[handle.Name] {
+			seen[handle.Name] = true
+			peers = append(peers, handle.Name)
+		}
+	}
+	sort.Strings(peers)
+	return peers
+}
+
+func (rn *raftNode) send(to string, msg *Message) {
+	msg.Term = rn.term
+	raw, err := proto.Marshal(msg)
+	if err != nil {
+		logger.Error("Cannot marshal raft message: %s", err)
+		return
+	}
+	if err = rn.stack.Unicast(&pb.Message{Type: pb.Message_CONSENSUS, Payload: raw, Timestamp: util.CreateUtcTimestamp()}, &pb.PeerID{Name: to}); err != nil {
+		logger.Debug("Cannot send raft message to %s: %s", to, err)
+	}
+}
+
+func entryKey(index uint64) string {
+	return fmt.Sprintf("raft.entry.%020d", index)
+}
+
+type uint64Slice []uint64
+
+func (s uint64Slice) Len() int           { return len(s) }
+func (s uint64Slice) Less(i, j int) bool { return s[i] < s[j] }
+func (s uint64Slice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
@@ -0,0 +1,181 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package raft
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+)
+
+func checkExecuted(t *testing.T, net *testNetwork, expected []string) {
+	for _, name := range net.names {
+		if net.down[name] {
+			continue
+		}
+		if executed := net.stacks[name].executed(); !reflect.DeepEqual(executed, expected) {
+			t.Errorf("%s executed %v, expected %v", name, executed, expected)
+		}
+	}
+}
+
+func TestReplication(t *testing.T) {
+	net := newTestNetwork(3, testConfig(3))
+	leader := net.electLeader()
+	if leader == nil {
+		t.Fatal("No leader elected")
+	}
+	for _, name := range net.names {
+		if node := net.stacks[name].node; node.leader != leader.name || node.term != leader.node.term {
+			t.Errorf("%s follows %s in term %d, expected %s in term %d", name, node.leader, node.term, leader.name, leader.node.term)
+		}
+	}
+
+	// a full batch is cut at once, the rest after the batch timeout
+	follower := net.follower(leader)
+	leader.propose("tx0")
+	follower.propose("tx1")
+	net.deliver()
+	leader.propose("tx2")
+	follower.propose("tx3")
+	net.deliver()
+	checkExecuted(t, net, []string{"tx0", "tx1", "tx2"})
+	net.tick(3)
+	checkExecuted(t, net, []string{"tx0", "tx1", "tx2", "tx3"})
+
+	for _, name := range net.names {
+		stack := net.stacks[name]
+		metadata := &Metadata{}
+		if err := proto.Unmarshal(stack.blocks[len(stack.blocks)-1].ConsensusMetadata, metadata); err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		if metadata.Index != leader.node.lastIndex() || metadata.Term != leader.node.term {
+			t.Errorf("%s checkpointed entry %d of term %d, expected %d of term %d", name, metadata.Index, metadata.Term, leader.node.lastIndex(), leader.node.term)
+		}
+	}
+}
+
+func TestForwardWithoutLeader(t *testing.T) {
+	net := newTestNetwork(3, testConfig(3))
+	net.stacks["vp1"].propose("tx0")
+	leader := net.electLeader()
+	if leader == nil {
+		t.Fatal("No leader elected")
+	}
+	net.tick(3)
+	checkExecuted(t, net, []string{"tx0"})
+}
+
+func TestLeaderFailure(t *testing.T) {
+	net := newTestNetwork(3, testConfig(3))
+	leader := net.electLeader()
+	leader.propose("tx0")
+	net.tick(3)
+	checkExecuted(t, net, []string{"tx0"})
+
+	net.down[leader.name] = true
+	newLeader := net.electLeader()
+	if newLeader == nil {
+		t.Fatal("No leader elected after the failure of the leader")
+	}
+	if newLeader.node.term <= leader.node.term {
+		t.Errorf("New leader elected in term %d, the failed one in term %d", newLeader.node.term, leader.node.term)
+	}
+	net.follower(newLeader).propose("tx1")
+	net.tick(3)
+	checkExecuted(t, net, []string{"tx0", "tx1"})
+
+	// the failed leader steps down and catches up once back
+	net.down[leader.name] = false
+	net.tick(3)
+	if leader.node.role != follower || leader.node.leader != newLeader.name {
+		t.Errorf("Former leader still has role %d and follows %s", leader.node.role, leader.node.leader)
+	}
+	checkExecuted(t, net, []string{"tx0", "tx1"})
+}
+
+func TestNoQuorum(t *testing.T) {
+	net := newTestNetwork(3, testConfig(3))
+	leader := net.electLeader()
+	for _, name := range net.names {
+		if name != leader.name {
+			net.down[name] = true
+		}
+	}
+	leader.propose("tx0")
+	net.tick(3)
+	if executed := leader.executed(); len(executed) != 0 {
+		t.Errorf("Leader executed %v without a quorum", executed)
+	}
+}
+
+func TestRestart(t *testing.T) {
+	config := testConfig(3)
+	net := newTestNetwork(3, config)
+	leader := net.electLeader()
+	for i := 0; i < 5; i++ {
+		leader.propose(fmt.Sprintf("tx%d", i))
+	}
+	net.tick(3)
+
+	follower := net.follower(leader)
+	term, votedFor, last := follower.node.term, follower.node.votedFor, follower.node.lastIndex()
+	follower.restart(config)
+	if follower.node.term != term || follower.node.votedFor != votedFor || follower.node.lastIndex() != last || follower.node.pushedIndex != last {
+		t.Errorf("Restarted in term %d having voted for %s with log up to %d executed up to %d, expected term %d, %s and %d",
+			follower.node.term, follower.node.votedFor, follower.node.lastIndex(), follower.node.pushedIndex, term, votedFor, last)
+	}
+
+	leader.propose("tx5")
+	net.tick(3)
+	checkExecuted(t, net, []string{"tx0", "tx1", "tx2", "tx3", "tx4", "tx5"})
+}
+
+func TestStateTransfer(t *testing.T) {
+	config := testConfig(3)
+	config.logRetain = 1
+	net := newTestNetwork(3, config)
+	net.down["vp2"] = true
+	leader := net.electLeader()
+	var expected []string
+	for i := 0; i < 9; i++ {
+		uuid := fmt.Sprintf("tx%d", i)
+		leader.propose(uuid)
+		expected = append(expected, uuid)
+	}
+	net.tick(3)
+	if leader.node.snapshotIndex == 0 {
+		t.Fatal("Leader did not compact its log")
+	}
+	if _, ok := leader.state[entryKey(1)]; ok {
+		t.Error("Leader still persists the compacted entries")
+	}
+
+	net.down["vp2"] = false
+	net.tick(3)
+	checkExecuted(t, net, expected)
+	lagging := net.stacks["vp2"]
+	if lagging.node.transferring || lagging.node.pushedIndex != leader.node.lastIndex() {
+		t.Errorf("vp2 executed up to %d, expected %d", lagging.node.pushedIndex, leader.node.lastIndex())
+	}
+
+	leader.propose("tx9")
+	net.tick(3)
+	checkExecuted(t, net, append(expected, "tx9"))
+}
@@ -0,0 +1,345 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package acl
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/op/go-logging"
+	"github.com/spf13/viper"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+var aclLogger = logging.MustGetLogger("acl")
+
+// Role is the role of a client of the peer. A role is granted the permissions of the
+// roles below it
+type Role int
+
+const (
+	// None is granted no permission
+	None Role = iota
+	// Reader queries the state and listens to the events
+	Reader
+	// Submitter also submits transactions
+	Submitter
+	// Admin also deploys chaincodes and administers the peer
+	Admin
+)
+
+var roleNames = []string{"", "reader", "submitter", "admin"}
+
+func (r Role) String() string {
+	if r < None || int(r) >= len(roleNames) {
+		return fmt.Sprintf("Role(%d)", int(r))
+	}
+	return roleNames[r]
+}
+
+// ParseRole returns the role of the given name, None for ""
+func ParseRole(name string) (Role, error) {
+	for i, n := range roleNames {
+		if strings.EqualFold(n, name) {
+			return Role(i), nil
+		}
+	}
+	return None, fmt.Errorf("Unknown role [%s], expected one of reader, submitter and admin", name)
+}
+
+// The APIs the permissions apply to
+const (
+	// DeployAPI is the deployment of chaincodes: deploy, install, instantiate and upgrade
+	DeployAPI = "deploy"
+	// InvokeAPI is the submission of transactions: invoke and endorsed transactions
+	InvokeAPI = "invoke"
+	// QueryAPI is the queries and simulations
+	QueryAPI = "query"
+	// AdminAPI is the Admin service
+	AdminAPI = "admin"
+	// EventsAPI is the event streams of the event hub
+	EventsAPI = "events"
+)
+
+var defaultPermissions = map[string]Role{
+	DeployAPI: Admin,
+	InvokeAPI: Submitter,
+	QueryAPI:  Reader,
+	AdminAPI:  Admin,
+	EventsAPI: Reader,
+}
+
+// Policy is an access control list of the client APIs of the peer: the role of each client
+// identity and the lowest role allowed to call each API
+type Policy struct {
+	Enabled     bool
+	DefaultRole Role            // of the clients not in Members
+	Members     map[string]Role // by identity
+	Permissions map[string]Role // by API, the default ones for the APIs not listed
+	Tokens      map[string]string
+}
+
+// NewPolicy returns a disabled policy granting the default permissions
+func NewPolicy() *Policy {
+	p := &Policy{Members: make(map[string]Role), Permissions: make(map[string]Role), Tokens: make(map[string]string)}
+	for api, role := range defaultPermissions {
+		p.Permissions[api] = role
+	}
+	return p
+}
+
+// PolicyFromConfig returns the policy of peer.acl
+func PolicyFromConfig() (*Policy, error) {
+	p := NewPolicy()
+	p.Enabled = viper.GetBool("peer.acl.enabled")
+	var err error
+	if p.DefaultRole, err = ParseRole(viper.GetString("peer.acl.defaultRole")); err != nil {
+		return nil, err
+	}
+	for _, role := range []Role{Reader, Submitter, Admin} {
+		for _, identity := range viper.GetStringSlice("peer.acl.roles." + role.String()) {
+			p.Members[identity] = role
+		}
+	}
+	for api, name := range viper.GetStringMapString("peer.acl.permissions") {
+		if err = p.setPermission(api, name); err != nil {
+			return nil, err
+		}
+	}
+	for _, entry := range viper.GetStringSlice("peer.acl.tokens") {
+		i := strings.Index(entry, ":")
+		if i <= 0 || i == len(entry)-1 {
+			return nil, fmt.Errorf("Invalid token entry in peer.acl.tokens, expected <identity>:<token>")
+		}
+		p.Tokens[entry[i+1:]] = entry[:i]
+	}
+	return p, nil
+}
+
+func (p *Policy) setPermission(api string, name string) error {
+	if _, ok := defaultPermissions[api]; !ok {
+		return fmt.Errorf("Unknown API [%s] in ACL permissions", api)
+	}
+	role, err := ParseRole(name)
+	if err != nil {
+		return err
+	}
+	p.Permissions[api] = role
+	return nil
+}
+
+// Role returns the role of the client identity
+func (p *Policy) Role(identity string) Role {
+	if role, ok := p.Members[identity]; ok && identity != "" {
+		return role
+	}
+	return p.DefaultRole
+}
+
+// Allowed tells whether the client identity may call the API
+func (p *Policy) Allowed(identity string, api string) bool {
+	if !p.Enabled {
+		return true
+	}
+	required, ok := p.Permissions[api]
+	if !ok {
+		return false
+	}
+	role := p.Role(identity)
+	return role != None && role >= required
+}
+
+// ToMessage returns the policy as a message, without its tokens
+func (p *Policy) ToMessage() *pb.ACLPolicy {
+	msg := &pb.ACLPolicy{Enabled: p.Enabled, DefaultRole: p.DefaultRole.String()}
+	for identity, role := range p.Members {
+		msg.Members = append(msg.Members, &pb.ACLPolicy_Member{Identity: identity, Role: role.String()})
+	}
+	sort.Sort(membersByIdentity(msg.Members))
+	for api, role := range p.Permissions {
+		msg.Permissions = append(msg.Permissions, &pb.ACLPolicy_Permission{Api: api, Role: role.String()})
+	}
+	sort.Sort(permissionsByAPI(msg.Permissions))
+	return msg
+}
+
+// PolicyFromMessage returns the policy of the message. The APIs it doesn't list are given
+// their default permissions
+func PolicyFromMessage(msg *pb.ACLPolicy) (*Policy, error) {
+	p := NewPolicy()
+	p.Enabled = msg.Enabled
+	var err error
+	if p.DefaultRole, err = ParseRole(msg.DefaultRole); err != nil {
+		return nil, err
+	}
+	for _, member := range msg.Members {
+		if member.Identity == "" {
+			return nil, fmt.Errorf("Empty identity in ACL members")
+		}
+		if p.Members[member.Identity], err = ParseRole(member.Role); err != nil {
+			return nil, err
+		}
+	}
+	for _, permission := range msg.Permissions {
+		if err = p.setPermission(permission.Api, permission.Role); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+type membersByIdentity []*pb.ACLPolicy_Member
+
+func (m membersByIdentity) Len() int           { return len(m) }
+func (m membersByIdentity) Swap(i, j int)      { m[i], m[j] = m[j], m[i] }
+func (m membersByIdentity) Less(i, j int) bool { return m[i].Identity < m[j].Identity }
+
+type permissionsByAPI []*pb.ACLPolicy_Permission
+
+func (p permissionsByAPI) Len() int           { return len(p) }
+func (p permissionsByAPI) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+func (p permissionsByAPI) Less(i, j int) bool { return p[i].Api < p[j].Api }
+
+var (
+	policyLock    sync.RWMutex
+	currentPolicy *Policy
+)
+
+// GetPolicy returns the policy in effect, the one of peer.acl until replaced
+func GetPolicy() *Policy {
+	policyLock.RLock()
+	p := currentPolicy
+	policyLock.RUnlock()
+	if p != nil {
+		return p
+	}
+	policyLock.Lock()
+	defer policyLock.Unlock()
+	if currentPolicy == nil {
+		var err error
+		if currentPolicy, err = PolicyFromConfig(); err != nil {
+			// access is denied rather than granted on a broken configuration
+			aclLogger.Error("Invalid peer.acl configuration, denying all access: %s", err)
+			currentPolicy = NewPolicy()
+			currentPolicy.Enabled = true
+		}
+	}
+	return currentPolicy
+}
+
+// SetPolicy replaces the policy in effect. It keeps the tokens of the current policy, which
+// are only configured in peer.acl
+func SetPolicy(p *Policy) {
+	current := GetPolicy()
+	policyLock.Lock()
+	defer policyLock.Unlock()
+	p.Tokens = current.Tokens
+	currentPolicy = p
+}
+
+// identityKey is the key of the client identity of the contexts returned by NewContext
+type identityKey struct{}
+
+// NewContext returns a context carrying the identity of the client of an in-process call,
+// such as a call of the REST server serving an HTTP request whose client it authenticated
+// (see HTTPClientIdentity)
+func NewContext(ctx context.Context, identity string) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// ClientIdentity returns the identity of the client of a call: the identity carried by the
+// context (see NewContext), else the common name of the certificate it presented over
+// mutual TLS, else the identity of the bearer token of its authorization metadata. "" if the
+// client is anonymous. The enrollment ID a request asserts in its security context is not
+// authenticated, so it never identifies the client
+func ClientIdentity(ctx context.Context) string {
+	if identity, ok := ctx.Value(identityKey{}).(string); ok {
+		return identity
+	}
+	if authInfo, ok := credentials.FromContext(ctx); ok {
+		if tlsInfo, ok := authInfo.(credentials.TLSInfo); ok && len(tlsInfo.State.PeerCertificates) > 0 {
+			return tlsInfo.State.PeerCertificates[0].Subject.CommonName
+		}
+	}
+	if md, ok := metadata.FromContext(ctx); ok {
+		if identity, ok := tokenIdentity(md["authorization"]); ok {
+			return identity
+		}
+	}
+	return ""
+}
+
+// tokenIdentity returns the identity of the first known bearer token of the authorization
+// values
+func tokenIdentity(authorization []string) (string, bool) {
+	for _, value := range authorization {
+		if strings.HasPrefix(value, "Bearer ") {
+			if identity, ok := GetPolicy().Tokens[strings.TrimPrefix(value, "Bearer ")]; ok {
+				return identity, true
+			}
+		}
+	}
+	return "", false
+}
+
+// HTTPClientIdentity returns the identity of the client of an HTTP request: the common
+// name of the certificate it presented over mutual TLS, else the identity of the bearer
+// token of its Authorization header. "" if the client is anonymous
+func HTTPClientIdentity(req *http.Request) string {
+	if req.TLS != nil && len(req.TLS.PeerCertificates) > 0 {
+		return req.TLS.PeerCertificates[0].Subject.CommonName
+	}
+	identity, _ := tokenIdentity(req.Header["Authorization"])
+	return identity
+}
+
+// Check returns a codes.PermissionDenied error if the client of the call may not call the API
+func Check(ctx context.Context, api string) error {
+	p := GetPolicy()
+	if !p.Enabled {
+		return nil
+	}
+	identity := ClientIdentity(ctx)
+	if !p.Allowed(identity, api) {
+		aclLogger.Warning("Access to the %s API denied to client [%s]", api, identity)
+		return grpc.Errorf(codes.PermissionDenied, "Access to the %s API denied to client [%s]", api, identity)
+	}
+	return nil
+}
+
+// CheckHTTP returns an error if the client of the HTTP request may not call the API
+func CheckHTTP(req *http.Request, api string) error {
+	p := GetPolicy()
+	if !p.Enabled {
+		return nil
+	}
+	identity := HTTPClientIdentity(req)
+	if !p.Allowed(identity, api) {
+		aclLogger.Warning("Access to the %s API denied to client [%s]", api, identity)
+		return fmt.Errorf("Access to the %s API denied to client [%s]", api, identity)
+	}
+	return nil
+}
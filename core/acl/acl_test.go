@@ -0,0 +1,141 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package acl
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/spf13/viper"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+func TestPolicyFromConfig(t *testing.T) {
+	viper.Set("peer.acl.enabled", true)
+	viper.Set("peer.acl.defaultRole", "reader")
+	viper.Set("peer.acl.roles.admin", []string{"ops"})
+	viper.Set("peer.acl.roles.submitter", []string{"alice"})
+	viper.Set("peer.acl.permissions", map[string]string{"query": "submitter"})
+	viper.Set("peer.acl.tokens", []string{"bob:s3cr:et"})
+	defer func() {
+		for _, key := range []string{"enabled", "defaultRole", "roles.admin", "roles.submitter", "permissions", "tokens"} {
+			viper.Set("peer.acl."+key, nil)
+		}
+	}()
+
+	p, err := PolicyFromConfig()
+	if err != nil {
+		t.Fatalf("Error reading the policy: %s", err)
+	}
+	for _, c := range []struct {
+		identity string
+		api      string
+		allowed  bool
+	}{
+		{"ops", DeployAPI, true},
+		{"ops", AdminAPI, true},
+		{"alice", DeployAPI, false},
+		{"alice", InvokeAPI, true},
+		{"alice", QueryAPI, true},
+		{"carol", QueryAPI, false}, // query raised to submitter
+		{"carol", EventsAPI, true},
+		{"", EventsAPI, true},
+	} {
+		if allowed := p.Allowed(c.identity, c.api); allowed != c.allowed {
+			t.Errorf("Expected access of [%s] to %s to be %t", c.identity, c.api, c.allowed)
+		}
+	}
+	if p.Tokens["s3cr:et"] != "bob" {
+		t.Fatalf("Expected the token of bob, got %v", p.Tokens)
+	}
+
+	viper.Set("peer.acl.permissions", map[string]string{"ledger": "reader"})
+	if _, err := PolicyFromConfig(); err == nil {
+		t.Fatal("Expected an unknown API to be rejected")
+	}
+}
+
+func TestPolicyMessage(t *testing.T) {
+	p := NewPolicy()
+	p.Enabled = true
+	p.Members["alice"] = Submitter
+	p.Permissions[EventsAPI] = Submitter
+	msg := p.ToMessage()
+	if len(msg.Permissions) != len(defaultPermissions) || msg.Members[0].Role != "submitter" {
+		t.Fatalf("Unexpected message %v", msg)
+	}
+	back, err := PolicyFromMessage(msg)
+	if err != nil {
+		t.Fatalf("Error reading the message: %s", err)
+	}
+	if !back.Enabled || back.Role("alice") != Submitter || back.Permissions[EventsAPI] != Submitter || back.Permissions[QueryAPI] != Reader {
+		t.Fatalf("Expected the policy to round trip, got %+v", back)
+	}
+	if _, err := PolicyFromMessage(&pb.ACLPolicy{Members: []*pb.ACLPolicy_Member{{Identity: "alice", Role: "owner"}}}); err == nil {
+		t.Fatal("Expected an unknown role to be rejected")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	p := NewPolicy()
+	p.Enabled = true
+	p.Members["alice"] = Submitter
+	p.Members["bob"] = Reader
+	currentPolicy = &Policy{Tokens: map[string]string{"t0k3n": "bob", "s3cr3t": "alice"}}
+	SetPolicy(p)
+	defer func() { currentPolicy = nil }()
+
+	if err := Check(context.Background(), InvokeAPI); grpc.Code(err) != codes.PermissionDenied {
+		t.Fatalf("Expected the anonymous client to be denied, got %v", err)
+	}
+	// the tokens survived the replacement
+	ctx := metadata.NewContext(context.Background(), metadata.MD{"authorization": []string{"Bearer s3cr3t"}})
+	if err := Check(ctx, InvokeAPI); err != nil {
+		t.Fatalf("Expected alice to invoke, got %s", err)
+	}
+	// the identity authenticated by an in-process caller, such as the REST server
+	if err := Check(NewContext(context.Background(), "alice"), InvokeAPI); err != nil {
+		t.Fatalf("Expected alice to invoke in process, got %s", err)
+	}
+	ctx = metadata.NewContext(context.Background(), metadata.MD{"authorization": []string{"Bearer t0k3n"}})
+	if identity := ClientIdentity(ctx); identity != "bob" {
+		t.Fatalf("Expected the client of the token to be bob, got %s", identity)
+	}
+	if err := Check(ctx, QueryAPI); err != nil {
+		t.Fatalf("Expected bob to query, got %s", err)
+	}
+	if err := Check(ctx, InvokeAPI); grpc.Code(err) != codes.PermissionDenied {
+		t.Fatalf("Expected bob not to invoke, got %v", err)
+	}
+
+	req, _ := http.NewRequest("GET", "/debug/pprof/", nil)
+	if err := CheckHTTP(req, AdminAPI); err == nil {
+		t.Fatal("Expected the anonymous HTTP client to be denied")
+	}
+	req.Header.Set("Authorization", "Bearer t0k3n")
+	if identity := HTTPClientIdentity(req); identity != "bob" {
+		t.Fatalf("Expected the HTTP client of the token to be bob, got %s", identity)
+	}
+	if err := CheckHTTP(req, QueryAPI); err != nil {
+		t.Fatalf("Expected bob to query over HTTP, got %s", err)
+	}
+}
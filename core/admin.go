@@ -17,9 +17,19 @@ limitations under the License.
 package core
 
 import (
+	"bytes"
+	"errors"
 	"os"
 	"runtime"
+	"sort"
+	"time"
 
+	"github.com/hyperledger/fabric/consensus"
+	"github.com/hyperledger/fabric/core/acl"
+	"github.com/hyperledger/fabric/core/db"
+	"github.com/hyperledger/fabric/core/debug"
+	"github.com/hyperledger/fabric/core/ledger"
+	"github.com/hyperledger/fabric/core/peer"
 	"github.com/op/go-logging"
 	"github.com/spf13/viper"
 	"golang.org/x/net/context"
@@ -31,14 +41,24 @@ import (
 
 var log = logging.MustGetLogger("server")
 
+var errNoDiscovery = errors.New("The discovery of the peers is not managed by this server")
+
 // NewAdminServer creates and returns a Admin service instance.
 func NewAdminServer() *ServerAdmin {
 	s := new(ServerAdmin)
 	return s
 }
 
+// NewAdminServerWithPeer creates and returns a Admin service instance which manages the
+// peers the supplied peer connects to.
+func NewAdminServerWithPeer(discovery peer.DiscoveryManager) *ServerAdmin {
+	s := &ServerAdmin{discovery: discovery}
+	return s
+}
+
 // ServerAdmin implementation of the Admin service for the Peer
 type ServerAdmin struct {
+	discovery peer.DiscoveryManager
 }
 
 func worker(id int, die chan struct{}) {
@@ -55,21 +75,30 @@ func worker(id int, die chan struct{}) {
 }
 
 // GetStatus reports the status of the server
-func (*ServerAdmin) GetStatus(context.Context, *google_protobuf.Empty) (*pb.ServerStatus, error) {
+func (*ServerAdmin) GetStatus(ctx context.Context, _ *google_protobuf.Empty) (*pb.ServerStatus, error) {
+	if err := acl.Check(ctx, acl.AdminAPI); err != nil {
+		return nil, err
+	}
 	status := &pb.ServerStatus{Status: pb.ServerStatus_STARTED}
 	log.Debug("returning status: %s", status)
 	return status, nil
 }
 
 // StartServer starts the server
-func (*ServerAdmin) StartServer(context.Context, *google_protobuf.Empty) (*pb.ServerStatus, error) {
+func (*ServerAdmin) StartServer(ctx context.Context, _ *google_protobuf.Empty) (*pb.ServerStatus, error) {
+	if err := acl.Check(ctx, acl.AdminAPI); err != nil {
+		return nil, err
+	}
 	status := &pb.ServerStatus{Status: pb.ServerStatus_STARTED}
 	log.Debug("returning status: %s", status)
 	return status, nil
 }
 
 // StopServer stops the server
-func (*ServerAdmin) StopServer(context.Context, *google_protobuf.Empty) (*pb.ServerStatus, error) {
+func (*ServerAdmin) StopServer(ctx context.Context, _ *google_protobuf.Empty) (*pb.ServerStatus, error) {
+	if err := acl.Check(ctx, acl.AdminAPI); err != nil {
+		return nil, err
+	}
 	status := &pb.ServerStatus{Status: pb.ServerStatus_STOPPED}
 	log.Debug("returning status: %s", status)
 
@@ -79,3 +108,276 @@ func (*ServerAdmin) StopServer(context.Context, *google_protobuf.Empty) (*pb.Ser
 	defer os.Exit(0)
 	return status, nil
 }
+
+// CreateBackup takes a consistent backup of the DB of the peer in the requested directory
+func (*ServerAdmin) CreateBackup(ctx context.Context, req *pb.BackupRequest) (*pb.BackupInfo, error) {
+	if err := acl.Check(ctx, acl.AdminAPI); err != nil {
+		return nil, err
+	}
+	if req.BackupDir == "" {
+		return nil, errors.New("Backup dir not specified")
+	}
+	info, err := db.CreateBackup(req.BackupDir)
+	if err != nil {
+		return nil, err
+	}
+	log.Info("Created backup [%d] in [%s]", info.ID, req.BackupDir)
+	return &pb.BackupInfo{BackupID: info.ID, Timestamp: info.Timestamp, Size: info.Size, NumFiles: info.NumFiles}, nil
+}
+
+// CompactDB compacts the requested column families of the DB of the peer
+func (*ServerAdmin) CompactDB(ctx context.Context, req *pb.CompactionRequest) (*pb.CompactionResult, error) {
+	if err := acl.Check(ctx, acl.AdminAPI); err != nil {
+		return nil, err
+	}
+	allStats, err := db.GetDBHandle().CompactColumnFamilies(req.ColumnFamilies...)
+	if err != nil {
+		return nil, err
+	}
+	result := &pb.CompactionResult{}
+	for _, stats := range allStats {
+		result.ColumnFamilies = append(result.ColumnFamilies, &pb.CompactionResult_ColumnFamily{
+			Name:           stats.Name,
+			BytesBefore:    stats.BytesBefore,
+			BytesAfter:     stats.BytesAfter,
+			DurationMillis: int64(stats.Duration / time.Millisecond),
+		})
+	}
+	return result, nil
+}
+
+// PruneState removes the orphaned nodes of the state of the requested chain
+func (*ServerAdmin) PruneState(ctx context.Context, req *pb.PruneStateRequest) (*pb.PruneStateResult, error) {
+	if err := acl.Check(ctx, acl.AdminAPI); err != nil {
+		return nil, err
+	}
+	chainLedger, err := ledger.GetLedgerByChainID(req.ChainID)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := chainLedger.PruneState()
+	if err != nil {
+		return nil, err
+	}
+	return &pb.PruneStateResult{
+		DataNodesRemoved:         stats.DataNodesRemoved,
+		IntermediateNodesRemoved: stats.IntermediateNodesRemoved,
+		OtherNodesRemoved:        stats.OtherNodesRemoved,
+		BytesReclaimed:           stats.BytesReclaimed,
+	}, nil
+}
+
+// GetBatchPolicy returns the batch policy by which the consensus plugin forms blocks
+func (*ServerAdmin) GetBatchPolicy(ctx context.Context, _ *google_protobuf.Empty) (*pb.BatchPolicy, error) {
+	if err := acl.Check(ctx, acl.AdminAPI); err != nil {
+		return nil, err
+	}
+	return toBatchPolicyMessage(consensus.GetBatchPolicy()), nil
+}
+
+// SetBatchPolicy replaces the batch policy by which the consensus plugin forms blocks
+func (*ServerAdmin) SetBatchPolicy(ctx context.Context, req *pb.BatchPolicy) (*pb.BatchPolicy, error) {
+	if err := acl.Check(ctx, acl.AdminAPI); err != nil {
+		return nil, err
+	}
+	policy := consensus.BatchPolicy{
+		MaxMessageCount: int(req.MaxMessageCount),
+		MaxBytes:        int(req.MaxBytes),
+		Timeout:         time.Duration(req.TimeoutMillis) * time.Millisecond,
+	}
+	if err := consensus.SetBatchPolicy(policy); err != nil {
+		return nil, err
+	}
+	log.Info("Batch policy set to %+v", policy)
+	return toBatchPolicyMessage(consensus.GetBatchPolicy()), nil
+}
+
+func toBatchPolicyMessage(policy consensus.BatchPolicy) *pb.BatchPolicy {
+	return &pb.BatchPolicy{
+		MaxMessageCount: uint32(policy.MaxMessageCount),
+		MaxBytes:        uint64(policy.MaxBytes),
+		TimeoutMillis:   int64(policy.Timeout / time.Millisecond),
+	}
+}
+
+// GetDiscoveryList returns the addresses of the peers the peer reconnects to and of the ones it bans
+func (s *ServerAdmin) GetDiscoveryList(ctx context.Context, _ *google_protobuf.Empty) (*pb.DiscoveryList, error) {
+	if err := acl.Check(ctx, acl.AdminAPI); err != nil {
+		return nil, err
+	}
+	if s.discovery == nil {
+		return nil, errNoDiscovery
+	}
+	return s.discovery.GetDiscoveryList(), nil
+}
+
+// AddPeer adds a peer address to the discovery list of the peer, which connects to it
+func (s *ServerAdmin) AddPeer(ctx context.Context, req *pb.DiscoveryRequest) (*pb.DiscoveryList, error) {
+	if err := acl.Check(ctx, acl.AdminAPI); err != nil {
+		return nil, err
+	}
+	if s.discovery == nil {
+		return nil, errNoDiscovery
+	}
+	if err := s.discovery.AddPeer(req.Address); err != nil {
+		return nil, err
+	}
+	log.Info("Peer address [%s] added", req.Address)
+	return s.discovery.GetDiscoveryList(), nil
+}
+
+// RemovePeer removes a peer address from the discovery list of the peer
+func (s *ServerAdmin) RemovePeer(ctx context.Context, req *pb.DiscoveryRequest) (*pb.DiscoveryList, error) {
+	if err := acl.Check(ctx, acl.AdminAPI); err != nil {
+		return nil, err
+	}
+	if s.discovery == nil {
+		return nil, errNoDiscovery
+	}
+	if err := s.discovery.RemovePeer(req.Address); err != nil {
+		return nil, err
+	}
+	log.Info("Peer address [%s] removed", req.Address)
+	return s.discovery.GetDiscoveryList(), nil
+}
+
+// BanPeer bans a peer address, the peer disconnects from it and refuses its connections
+func (s *ServerAdmin) BanPeer(ctx context.Context, req *pb.DiscoveryRequest) (*pb.DiscoveryList, error) {
+	if err := acl.Check(ctx, acl.AdminAPI); err != nil {
+		return nil, err
+	}
+	if s.discovery == nil {
+		return nil, errNoDiscovery
+	}
+	if err := s.discovery.BanPeer(req.Address); err != nil {
+		return nil, err
+	}
+	log.Info("Peer address [%s] banned", req.Address)
+	return s.discovery.GetDiscoveryList(), nil
+}
+
+// GetAdmissionStats returns the counters of the admission control of the client requests:
+// the requests admitted, rejected and in flight, by kind
+func (*ServerAdmin) GetAdmissionStats(ctx context.Context, _ *google_protobuf.Empty) (*pb.AdmissionStats, error) {
+	if err := acl.Check(ctx, acl.AdminAPI); err != nil {
+		return nil, err
+	}
+	return getAdmissionController().stats(), nil
+}
+
+// GetACLPolicy returns the access control list of the client APIs of the peer
+func (*ServerAdmin) GetACLPolicy(ctx context.Context, _ *google_protobuf.Empty) (*pb.ACLPolicy, error) {
+	if err := acl.Check(ctx, acl.AdminAPI); err != nil {
+		return nil, err
+	}
+	return acl.GetPolicy().ToMessage(), nil
+}
+
+// SetACLPolicy replaces the access control list of the client APIs of the peer. The change
+// applies to this peer only and lasts until it restarts
+func (*ServerAdmin) SetACLPolicy(ctx context.Context, req *pb.ACLPolicy) (*pb.ACLPolicy, error) {
+	if err := acl.Check(ctx, acl.AdminAPI); err != nil {
+		return nil, err
+	}
+	policy, err := acl.PolicyFromMessage(req)
+	if err != nil {
+		return nil, err
+	}
+	acl.SetPolicy(policy)
+	log.Info("ACL policy set to %s", req)
+	return acl.GetPolicy().ToMessage(), nil
+}
+
+// GetLogLevels returns the logging levels of the modules of the peer
+func (*ServerAdmin) GetLogLevels(ctx context.Context, _ *google_protobuf.Empty) (*pb.LogLevels, error) {
+	if err := acl.Check(ctx, acl.AdminAPI); err != nil {
+		return nil, err
+	}
+	return toLogLevelsMessage(), nil
+}
+
+// SetLogLevel sets the logging level of a module and of its submodules, or the default
+// level if the module is empty. The change lasts until the peer restarts
+func (*ServerAdmin) SetLogLevel(ctx context.Context, req *pb.LogLevel) (*pb.LogLevels, error) {
+	if err := acl.Check(ctx, acl.AdminAPI); err != nil {
+		return nil, err
+	}
+	if err := SetModuleLevel(req.Module, req.Level); err != nil {
+		return nil, err
+	}
+	return toLogLevelsMessage(), nil
+}
+
+func toLogLevelsMessage() *pb.LogLevels {
+	defaultLevel, levels := GetModuleLevels()
+	modules := make([]string, 0, len(levels))
+	for module := range levels {
+		modules = append(modules, module)
+	}
+	sort.Strings(modules)
+	msg := &pb.LogLevels{DefaultLevel: defaultLevel}
+	for _, module := range modules {
+		msg.Modules = append(msg.Modules, &pb.LogLevel{Module: module, Level: levels[module]})
+	}
+	return msg
+}
+
+// GetProfile returns a pprof profile of the peer, such as the goroutine dump
+func (*ServerAdmin) GetProfile(ctx context.Context, req *pb.ProfileRequest) (*pb.Profile, error) {
+	if err := acl.Check(ctx, acl.AdminAPI); err != nil {
+		return nil, err
+	}
+	var buffer bytes.Buffer
+	if err := debug.WriteProfile(&buffer, req.Name, time.Duration(req.Seconds)*time.Second, int(req.Debug)); err != nil {
+		return nil, err
+	}
+	return &pb.Profile{Data: buffer.Bytes()}, nil
+}
+
+// GetLedgerRuntimeStats returns the statistics of the transaction-batch in progress of the
+// requested chain
+func (*ServerAdmin) GetLedgerRuntimeStats(ctx context.Context, req *pb.LedgerRuntimeStatsRequest) (*pb.LedgerRuntimeStats, error) {
+	if err := acl.Check(ctx, acl.AdminAPI); err != nil {
+		return nil, err
+	}
+	stats, err := debug.GetLedgerStats(req.ChainID)
+	if err != nil {
+		return nil, err
+	}
+	msg := &pb.LedgerRuntimeStats{
+		BlockchainSize: stats.BlockchainSize,
+		TxInProgress:   stats.State.TxInProgress,
+		BatchTxs:       uint32(stats.State.BatchTxs),
+		PendingKeys:    uint64(stats.State.PendingKeys),
+		StagedChanges:  stats.State.StagedChanges,
+		WalInUse:       stats.State.WALInUse,
+	}
+	if !stats.LastBlockCommitted.IsZero() {
+		msg.LastBlockCommitted = stats.LastBlockCommitted.UnixNano() / int64(time.Millisecond)
+	}
+	if !stats.State.TxInProgressSince.IsZero() {
+		msg.TxInProgressMillis = int64(time.Since(stats.State.TxInProgressSince) / time.Millisecond)
+	}
+	return msg, nil
+}
+
+// GetDBProperties returns the values of rocksdb properties of the column families of the
+// DB of the requested chain
+func (*ServerAdmin) GetDBProperties(ctx context.Context, req *pb.DBPropertiesRequest) (*pb.DBProperties, error) {
+	if err := acl.Check(ctx, acl.AdminAPI); err != nil {
+		return nil, err
+	}
+	properties, err := debug.GetDBProperties(req.ChainID, req.Properties, req.ColumnFamilies)
+	if err != nil {
+		return nil, err
+	}
+	msg := &pb.DBProperties{}
+	for _, property := range properties {
+		msg.Properties = append(msg.Properties, &pb.DBProperties_Property{
+			ColumnFamily: property.ColumnFamily,
+			Name:         property.Name,
+			Value:        property.Value,
+		})
+	}
+	return msg, nil
+}
@@ -16,9 +16,77 @@ limitations under the License.
 
 package core
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/op/go-logging"
+	"golang.org/x/net/context"
+
+	google_protobuf "google/protobuf"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
 
 func TestServer_Status(t *testing.T) {
 	t.Skip("TBD")
 	//performHandshake(t, peerClientConn)
 }
+
+func TestServer_BatchPolicy(t *testing.T) {
+	admin := NewAdminServer()
+	policy := &pb.BatchPolicy{MaxMessageCount: 10, MaxBytes: 1 << 20, TimeoutMillis: 500}
+	if _, err := admin.SetBatchPolicy(context.Background(), policy); err != nil {
+		t.Fatalf("Failed to set the batch policy: %s", err)
+	}
+	got, err := admin.GetBatchPolicy(context.Background(), &google_protobuf.Empty{})
+	if err != nil {
+		t.Fatalf("Failed to get the batch policy: %s", err)
+	}
+	if *got != *policy {
+		t.Fatalf("Expected batch policy %v, got %v", policy, got)
+	}
+
+	if _, err := admin.SetBatchPolicy(context.Background(), &pb.BatchPolicy{MaxMessageCount: 10}); err == nil {
+		t.Fatal("Expected a batch policy without timeout to be rejected")
+	}
+	if got, _ = admin.GetBatchPolicy(context.Background(), &google_protobuf.Empty{}); *got != *policy {
+		t.Fatalf("Expected the rejected batch policy to leave %v, got %v", policy, got)
+	}
+}
+
+func TestServer_LogLevels(t *testing.T) {
+	admin := NewAdminServer()
+	defer SetModuleLevel("", loggingDefaultLevel.String())
+	if _, err := admin.SetLogLevel(context.Background(), &pb.LogLevel{Module: "consensus", Level: "debug"}); err != nil {
+		t.Fatalf("Failed to set the logging level: %s", err)
+	}
+	if _, err := admin.SetLogLevel(context.Background(), &pb.LogLevel{Level: "warning"}); err != nil {
+		t.Fatalf("Failed to set the default logging level: %s", err)
+	}
+	if _, err := admin.SetLogLevel(context.Background(), &pb.LogLevel{Module: "db", Level: "verbose"}); err == nil {
+		t.Fatal("Expected an invalid logging level to be rejected")
+	}
+	levels, err := admin.GetLogLevels(context.Background(), &google_protobuf.Empty{})
+	if err != nil {
+		t.Fatalf("Failed to get the logging levels: %s", err)
+	}
+	if levels.DefaultLevel != "WARNING" {
+		t.Fatalf("Expected the default level WARNING, got %s", levels.DefaultLevel)
+	}
+	found := false
+	for _, module := range levels.Modules {
+		found = found || (module.Module == "consensus" && module.Level == "DEBUG")
+		if module.Module == "db" {
+			t.Fatal("Expected the rejected logging level to be ignored")
+		}
+	}
+	if !found {
+		t.Fatalf("Expected consensus at DEBUG in %v", levels.Modules)
+	}
+	if !logging.MustGetLogger("consensus/obcpbft").IsEnabledFor(logging.DEBUG) {
+		t.Fatal("Expected the level of consensus to apply to consensus/obcpbft")
+	}
+	if logging.MustGetLogger("state").IsEnabledFor(logging.INFO) {
+		t.Fatal("Expected the default level to apply to state")
+	}
+}
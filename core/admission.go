@@ -0,0 +1,241 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+
+	"github.com/hyperledger/fabric/core/acl"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// requestClass is the kind of client request an admission limit applies to
+type requestClass int
+
+const (
+	// submitRequest is a transaction submitted for ordering: deploy, invoke...
+	submitRequest requestClass = iota
+	// queryRequest is answered by the peer without changing the state
+	queryRequest
+)
+
+var requestClassNames = [...]string{"submit", "query"}
+
+// maxIdleClients is the number of per client buckets beyond which the full ones, of the
+// clients idle for long enough, are dropped
+const maxIdleClients = 10000
+
+// admissionLimits bounds the requests of a class: their rate, overall and per client, in
+// requests per second with bursts of up to burst requests, and the number of them being
+// processed at once. A zero value is no limit
+type admissionLimits struct {
+	rate           float64
+	burst          int
+	perClientRate  float64
+	perClientBurst int
+	maxInFlight    int
+}
+
+func admissionLimitsFromConfig(class requestClass) admissionLimits {
+	key := "peer.admission." + requestClassNames[class] + "."
+	return admissionLimits{
+		rate:           viper.GetFloat64(key + "rate"),
+		burst:          viper.GetInt(key + "burst"),
+		perClientRate:  viper.GetFloat64(key + "perClientRate"),
+		perClientBurst: viper.GetInt(key + "perClientBurst"),
+		maxInFlight:    viper.GetInt(key + "maxInFlight"),
+	}
+}
+
+// tokenBucket admits requests at rate per second, and bursts of up to burst requests
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int, now time.Time) *tokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: now}
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens += elapsed * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+	}
+	b.last = now
+}
+
+func (b *tokenBucket) take(now time.Time) bool {
+	b.refill(now)
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// admissionClass holds the limits and counters of a request class
+type admissionClass struct {
+	limits      admissionLimits
+	global      *tokenBucket // nil if the rate is unlimited
+	clients     map[string]*tokenBucket
+	inFlight    int
+	maxInFlight int // highest number of requests processed at once
+	admitted    uint64
+	rejected    uint64
+}
+
+// admissionController admits the client requests within their limits, so that a client
+// flooding the peer can't starve the others nor the formation of blocks. The requests over
+// a limit are rejected at once with codes.ResourceExhausted, the client is to retry later
+type admissionController struct {
+	sync.Mutex
+	classes [len(requestClassNames)]*admissionClass
+	now     func() time.Time
+}
+
+func newAdmissionController(limits [len(requestClassNames)]admissionLimits) *admissionController {
+	c := &admissionController{now: time.Now}
+	for i, l := range limits {
+		class := &admissionClass{limits: l, clients: make(map[string]*tokenBucket)}
+		if l.rate > 0 {
+			class.global = newTokenBucket(l.rate, l.burst, c.now())
+		}
+		c.classes[i] = class
+	}
+	return c
+}
+
+var (
+	theAdmissionController *admissionController
+	admissionOnce          sync.Once
+)
+
+// getAdmissionController returns the admission controller of the peer, with the limits of
+// peer.admission
+func getAdmissionController() *admissionController {
+	admissionOnce.Do(func() {
+		var limits [len(requestClassNames)]admissionLimits
+		for i := range limits {
+			limits[i] = admissionLimitsFromConfig(requestClass(i))
+		}
+		theAdmissionController = newAdmissionController(limits)
+	})
+	return theAdmissionController
+}
+
+// admit admits a request of the client, identified by client, "" for a client known to no
+// per client limit. The returned function is to be called once the request is processed
+func (c *admissionController) admit(class requestClass, client string) (func(), error) {
+	c.Lock()
+	defer c.Unlock()
+	now := c.now()
+	ac := c.classes[class]
+	name := requestClassNames[class]
+	if ac.limits.maxInFlight > 0 && ac.inFlight >= ac.limits.maxInFlight {
+		ac.rejected++
+		return nil, grpc.Errorf(codes.ResourceExhausted, "Too many %s requests in progress (%d), retry later", name, ac.inFlight)
+	}
+	var clientBucket *tokenBucket
+	if client != "" && ac.limits.perClientRate > 0 {
+		if clientBucket = ac.clients[client]; clientBucket == nil {
+			if len(ac.clients) >= maxIdleClients {
+				ac.dropIdleClients(now)
+			}
+			clientBucket = newTokenBucket(ac.limits.perClientRate, ac.limits.perClientBurst, now)
+			ac.clients[client] = clientBucket
+		}
+		// the client is checked first so that its rejected requests don't use up the global rate
+		if !clientBucket.take(now) {
+			ac.rejected++
+			return nil, grpc.Errorf(codes.ResourceExhausted, "Rate of %s requests of client %s exceeded, retry later", name, client)
+		}
+	}
+	if ac.global != nil && !ac.global.take(now) {
+		if clientBucket != nil {
+			clientBucket.tokens++
+		}
+		ac.rejected++
+		return nil, grpc.Errorf(codes.ResourceExhausted, "Rate of %s requests exceeded, retry later", name)
+	}
+	ac.admitted++
+	ac.inFlight++
+	if ac.inFlight > ac.maxInFlight {
+		ac.maxInFlight = ac.inFlight
+	}
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			c.Lock()
+			defer c.Unlock()
+			ac.inFlight--
+		})
+	}, nil
+}
+
+// dropIdleClients drops the buckets of the clients which have been idle long enough for
+// their buckets to be full again, they are recreated full on their next request
+func (ac *admissionClass) dropIdleClients(now time.Time) {
+	for client, bucket := range ac.clients {
+		bucket.refill(now)
+		if bucket.tokens >= bucket.burst {
+			delete(ac.clients, client)
+		}
+	}
+}
+
+// stats returns the counters of the request classes
+func (c *admissionController) stats() *pb.AdmissionStats {
+	c.Lock()
+	defer c.Unlock()
+	stats := &pb.AdmissionStats{}
+	for i, ac := range c.classes {
+		stats.Classes = append(stats.Classes, &pb.AdmissionStats_Class{
+			Name:        requestClassNames[i],
+			Admitted:    ac.admitted,
+			Rejected:    ac.rejected,
+			InFlight:    uint32(ac.inFlight),
+			MaxInFlight: uint32(ac.maxInFlight),
+			Clients:     uint32(len(ac.clients)),
+		})
+	}
+	return stats
+}
+
+// admit admits a client request through the admission controller of the peer. The client is
+// identified as by the access control list
+func admit(ctx context.Context, class requestClass) (func(), error) {
+	client := acl.ClientIdentity(ctx)
+	release, err := getAdmissionController().admit(class, client)
+	if err != nil {
+		devopsLogger.Debug("Request of client [%s] rejected: %s", client, err)
+	}
+	return release, err
+}
@@ -0,0 +1,94 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+func TestAdmissionController(t *testing.T) {
+	now := time.Unix(1000, 0)
+	var limits [len(requestClassNames)]admissionLimits
+	limits[submitRequest] = admissionLimits{rate: 10, burst: 4, perClientRate: 1, perClientBurst: 2, maxInFlight: 3}
+	c := newAdmissionController(limits)
+	c.now = func() time.Time { return now }
+	c.classes[submitRequest].global = newTokenBucket(10, 4, now)
+
+	expectAdmitted := func(class requestClass, client string) func() {
+		release, err := c.admit(class, client)
+		if err != nil {
+			t.Fatalf("Expected the request of client [%s] to be admitted, got %s", client, err)
+		}
+		return release
+	}
+	expectRejected := func(class requestClass, client string) {
+		if _, err := c.admit(class, client); grpc.Code(err) != codes.ResourceExhausted {
+			t.Fatalf("Expected the request of client [%s] to be rejected, got %v", client, err)
+		}
+	}
+
+	// the burst of the client, then its rate
+	expectAdmitted(submitRequest, "alice")()
+	expectAdmitted(submitRequest, "alice")()
+	expectRejected(submitRequest, "alice")
+	// the other clients are not affected
+	expectAdmitted(submitRequest, "bob")()
+	// the global burst is used up by the admitted requests only
+	expectAdmitted(submitRequest, "")()
+	expectRejected(submitRequest, "carol")
+	if c.classes[submitRequest].clients["carol"].tokens != 2 {
+		t.Fatal("Expected the request rejected by the global rate not to use up the rate of the client")
+	}
+
+	// the rates refill over time
+	now = now.Add(time.Second)
+	release1 := expectAdmitted(submitRequest, "alice")
+	release2 := expectAdmitted(submitRequest, "bob")
+	release3 := expectAdmitted(submitRequest, "carol")
+	// in-flight limit
+	expectRejected(submitRequest, "carol")
+	release1()
+	release1()
+	expectAdmitted(submitRequest, "")()
+	release2()
+	release3()
+
+	// the queries are not limited
+	for i := 0; i < 100; i++ {
+		expectAdmitted(queryRequest, "alice")()
+	}
+
+	stats := c.stats()
+	submit, query := stats.Classes[submitRequest], stats.Classes[queryRequest]
+	if submit.Name != "submit" || submit.Admitted != 8 || submit.Rejected != 3 || submit.InFlight != 0 || submit.MaxInFlight != 3 || submit.Clients != 3 {
+		t.Fatalf("Unexpected submit stats %v", submit)
+	}
+	if query.Name != "query" || query.Admitted != 100 || query.Rejected != 0 || query.Clients != 0 {
+		t.Fatalf("Unexpected query stats %v", query)
+	}
+
+	// the idle clients are dropped
+	now = now.Add(time.Minute)
+	c.classes[submitRequest].dropIdleClients(now)
+	if n := len(c.classes[submitRequest].clients); n != 0 {
+		t.Fatalf("Expected the idle clients to be dropped, %d left", n)
+	}
+}
@@ -20,6 +20,8 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"runtime"
+	"sort"
 	"sync"
 	"time"
 
@@ -27,10 +29,12 @@ import (
 	"github.com/spf13/viper"
 	"golang.org/x/net/context"
 
+	"github.com/hyperledger/fabric/core/comm"
 	"github.com/hyperledger/fabric/core/container"
 	"github.com/hyperledger/fabric/core/container/ccintf"
 	"github.com/hyperledger/fabric/core/crypto"
 	"github.com/hyperledger/fabric/core/ledger"
+	sysccapi "github.com/hyperledger/fabric/core/system_chaincode/api"
 	pb "github.com/hyperledger/fabric/protos"
 )
 
@@ -43,6 +47,7 @@ const (
 	// DevModeUserRunsChaincode property allows user to run chaincode in development environment
 	DevModeUserRunsChaincode       string = "dev"
 	chaincodeStartupTimeoutDefault int    = 5000
+	chaincodeExecuteTimeoutDefault int    = 30000
 	chaincodeInstallPathDefault    string = "/opt/gopath/bin/"
 	peerAddressDefault             string = "0.0.0.0:30303"
 )
@@ -59,6 +64,10 @@ func init() {
 //This is where the VM that's running the chaincode would hook in
 type chaincodeRTEnv struct {
 	handler *Handler
+	// cds is the deployment spec of the chaincode, set once it has been launched
+	cds *pb.ChaincodeDeploymentSpec
+	// depUUID is the uuid of the deploy or upgrade transaction whose code was launched
+	depUUID string
 }
 
 // runningChaincodes contains maps of chaincodeIDs to their chaincodeRTEs
@@ -94,7 +103,7 @@ func NewChaincodeSupport(chainname ChainName, getPeerEndpoint func() (*pb.PeerEn
 	pnid := viper.GetString("peer.networkId")
 	pid := viper.GetString("peer.id")
 
-	s := &ChaincodeSupport{name: chainname, runningChaincodes: &runningChaincodes{chaincodeMap: make(map[string]*chaincodeRTEnv)}, secHelper: secHelper, peerNetworkID: pnid, peerID: pid}
+	s := &ChaincodeSupport{name: chainname, runningChaincodes: &runningChaincodes{chaincodeMap: make(map[string]*chaincodeRTEnv)}, secHelper: secHelper, peerNetworkID: pnid, peerID: pid, txLocks: newTxLocks()}
 
 	//initialize global chain
 	chains[chainname] = s
@@ -116,12 +125,32 @@ func NewChaincodeSupport(chainname ChainName, getPeerEndpoint func() (*pb.PeerEn
 
 	s.ccStartupTimeout = ccstartuptimeout * time.Millisecond
 
+	executetimeout := viper.GetInt("chaincode.executetimeout")
+	if executetimeout <= 0 {
+		executetimeout = chaincodeExecuteTimeoutDefault
+	}
+	s.ccExecuteTimeout = time.Duration(executetimeout) * time.Millisecond
+
 	//TODO I'm not sure if this needs to be on a per chain basis... too lowel and just needs to be a global default ?
 	s.chaincodeInstallPath = viper.GetString("chaincode.installpath")
 	if s.chaincodeInstallPath == "" {
 		s.chaincodeInstallPath = chaincodeInstallPathDefault
 	}
 
+	if viper.GetBool("chaincode.parallelExecution.enabled") {
+		s.simulationWorkers = viper.GetInt("chaincode.parallelExecution.workers")
+		if s.simulationWorkers <= 0 {
+			s.simulationWorkers = runtime.NumCPU()
+		}
+	}
+
+	if viper.GetBool("chaincode.endorsedTransactions.enabled") {
+		s.minEndorsers = viper.GetInt("chaincode.endorsedTransactions.minEndorsers")
+		if s.minEndorsers <= 0 {
+			s.minEndorsers = 1
+		}
+	}
+
 	return s
 }
 
@@ -137,11 +166,58 @@ type ChaincodeSupport struct {
 	runningChaincodes    *runningChaincodes
 	peerAddress          string
 	ccStartupTimeout     time.Duration
+	ccExecuteTimeout     time.Duration
 	chaincodeInstallPath string
 	userRunsCC           bool
 	secHelper            crypto.Peer
 	peerNetworkID        string
 	peerID               string
+	// simulationWorkers is the number of chaincodes whose transactions are simulated
+	// at once when executing a batch, zero if the transactions are executed serially
+	simulationWorkers int
+	// minEndorsers is the number of distinct validating peers which have to endorse the
+	// changes of an endorsed transaction, zero if endorsed transactions are rejected
+	minEndorsers int
+	txLocks      *txLocks
+}
+
+// txLocks serializes the transactions sent to each chaincode, which executes one transaction
+// at a time. Transactions are only executed concurrently with simulations of transactions
+// run on demand (see SimulateTransaction), which must wait for the chaincode to be free. A
+// transaction calling back a chaincode it is already executing in does not wait
+type txLocks struct {
+	sync.Mutex
+	cond   *sync.Cond
+	owners map[string]string
+}
+
+func newTxLocks() *txLocks {
+	l := &txLocks{owners: make(map[string]string)}
+	l.cond = sync.NewCond(&l.Mutex)
+	return l
+}
+
+// lock waits until the chaincode is free, or executing the transaction uuid,
+// and returns the function releasing it
+func (l *txLocks) lock(chaincode string, uuid string) func() {
+	l.Lock()
+	defer l.Unlock()
+	for {
+		owner, busy := l.owners[chaincode]
+		if !busy {
+			l.owners[chaincode] = uuid
+			return func() {
+				l.Lock()
+				delete(l.owners, chaincode)
+				l.cond.Broadcast()
+				l.Unlock()
+			}
+		}
+		if owner == uuid {
+			return func() {}
+		}
+		l.cond.Wait()
+	}
 }
 
 // DuplicateChaincodeHandlerError returned if attempt to register same chaincodeID while a stream already exists.
@@ -165,9 +241,14 @@ func (chaincodeSupport *ChaincodeSupport) registerHandler(chaincodehandler *Hand
 
 	chrte2, ok := chaincodeSupport.chaincodeHasBeenLaunched(key)
 	if ok && chrte2.handler.registered == true {
-		chaincodeLogger.Debug("duplicate registered handler(key:%s) return error", key)
-		// Duplicate, return error
-		return newDuplicateChaincodeHandlerError(chaincodehandler)
+		if !chaincodeSupport.userRunsCC {
+			chaincodeLogger.Debug("duplicate registered handler(key:%s) return error", key)
+			// Duplicate, return error
+			return newDuplicateChaincodeHandlerError(chaincodehandler)
+		}
+		//in dev mode the chaincode relaunched by the user, e.g. after a change of its code,
+		//takes over from the previous process, which may not have disconnected yet
+		chaincodeLogger.Info("chaincode %s registered again, replacing its handler", key)
 	}
 	//a placeholder, unregistered handler will be setup by query or transaction processing that comes
 	//through via consensus. In this case we swap the handler and give it the notify channel
@@ -203,10 +284,16 @@ func (chaincodeSupport *ChaincodeSupport) deregisterHandler(chaincodehandler *Ha
 	chaincodeLogger.Debug("Deregister handler: %s", key)
 	chaincodeSupport.runningChaincodes.Lock()
 	defer chaincodeSupport.runningChaincodes.Unlock()
-	if _, ok := chaincodeSupport.chaincodeHasBeenLaunched(key); !ok {
+	chrte, ok := chaincodeSupport.chaincodeHasBeenLaunched(key)
+	if !ok {
 		// Handler NOT found
 		return fmt.Errorf("Error deregistering handler, could not find handler with key: %s", key)
 	}
+	if chrte.handler != chaincodehandler {
+		//the chaincode registered again in dev mode and the new handler replaced this one
+		chaincodeLogger.Debug("Handler with key %s was replaced, nothing to deregister", key)
+		return nil
+	}
 	delete(chaincodeSupport.runningChaincodes.chaincodeMap, key)
 	chaincodeLogger.Debug("Deregistered handler with key: %s", key)
 	return nil
@@ -247,18 +334,56 @@ func (chaincodeSupport *ChaincodeSupport) sendInitOrReady(context context.Contex
 	return err
 }
 
-//get args and env given chaincodeID
-func (chaincodeSupport *ChaincodeSupport) getArgsAndEnv(cID *pb.ChaincodeID) (args []string, envs []string, err error) {
+//get args and env given chaincodeID and the language of the chaincode
+func (chaincodeSupport *ChaincodeSupport) getArgsAndEnv(cID *pb.ChaincodeID, cLang pb.ChaincodeSpec_Type) (args []string, envs []string, err error) {
 	envs = []string{"CORE_CHAINCODE_ID_NAME=" + cID.Name}
+	if comm.TLSEnabled() {
+		envs = append(envs, tlsEnv()...)
+	}
 
-	//chaincode executable will be same as the name of the chaincode
-	args = []string{chaincodeSupport.chaincodeInstallPath + cID.Name, fmt.Sprintf("-peer.address=%s", chaincodeSupport.peerAddress)}
+	//chaincode executable will be same as the hash of the code of the chaincode,
+	//a jar or a package directory run by the java or node runtime for those languages
+	peerAddressArg := fmt.Sprintf("-peer.address=%s", chaincodeSupport.peerAddress)
+	switch cLang {
+	case pb.ChaincodeSpec_JAVA:
+		args = []string{"java", "-jar", chaincodeSupport.chaincodeInstallPath + getCodeHash(cID) + ".jar", peerAddressArg}
+	case pb.ChaincodeSpec_NODE:
+		args = []string{"node", chaincodeSupport.chaincodeInstallPath + getCodeHash(cID), peerAddressArg}
+	default:
+		args = []string{chaincodeSupport.chaincodeInstallPath + getCodeHash(cID), peerAddressArg}
+	}
 
-	chaincodeLogger.Debug("Executable is %s", args[0])
+	chaincodeLogger.Debug("Executable is %v", args[:len(args)-1])
 
 	return args, envs, nil
 }
 
+//tlsEnv returns the environment the shim of a chaincode container connects to the peer
+//over TLS with: the files of chaincode.tls are paths inside the container
+func tlsEnv() []string {
+	envs := []string{"CORE_PEER_TLS_ENABLED=true"}
+	for env, key := range map[string]string{
+		"CORE_PEER_TLS_SERVERHOSTOVERRIDE": "peer.tls.serverhostoverride",
+		"CORE_PEER_TLS_ROOTCERT_FILE":      "chaincode.tls.rootcert.file",
+		"CORE_PEER_TLS_CLIENTCERT_FILE":    "chaincode.tls.clientCert.file",
+		"CORE_PEER_TLS_CLIENTKEY_FILE":     "chaincode.tls.clientKey.file",
+	} {
+		if value := viper.GetString(key); value != "" {
+			envs = append(envs, env+"="+value)
+		}
+	}
+	sort.Strings(envs)
+	return envs
+}
+
+//getCodeHash returns the hash of the code of the chaincode: its name, or its version once upgraded
+func getCodeHash(cID *pb.ChaincodeID) string {
+	if cID.Version != "" {
+		return cID.Version
+	}
+	return cID.Name
+}
+
 // launchAndWaitForRegister will launch container if not already running
 func (chaincodeSupport *ChaincodeSupport) launchAndWaitForRegister(ctxt context.Context, cds *pb.ChaincodeDeploymentSpec, cID *pb.ChaincodeID, uuid string) (bool, error) {
 	chaincode := cID.Name
@@ -280,7 +405,7 @@ func (chaincodeSupport *ChaincodeSupport) launchAndWaitForRegister(ctxt context.
 
 	//launch the chaincode
 
-	args, env, err := chaincodeSupport.getArgsAndEnv(cID)
+	args, env, err := chaincodeSupport.getArgsAndEnv(cID, cds.ChaincodeSpec.Type)
 	if err != nil {
 		return alreadyRunning, err
 	}
@@ -365,15 +490,21 @@ func (chaincodeSupport *ChaincodeSupport) Launch(context context.Context, t *pb.
 	var initargs []string
 
 	cds := &pb.ChaincodeDeploymentSpec{}
-	if t.Type == pb.Transaction_CHAINCODE_DEPLOY {
+	if t.Type == pb.Transaction_CHAINCODE_DEPLOY || t.Type == pb.Transaction_CHAINCODE_UPGRADE {
 		err := proto.Unmarshal(t.Payload, cds)
 		if err != nil {
 			return nil, nil, err
 		}
 		cID = cds.ChaincodeSpec.ChaincodeID
 		cMsg = cds.ChaincodeSpec.CtorMsg
-		f = &cMsg.Function
-		initargs = cMsg.Args
+		if t.Type == pb.Transaction_CHAINCODE_DEPLOY {
+			f = &cMsg.Function
+			initargs = cMsg.Args
+		} else {
+			//the new code is initialized by migrating the state of the chaincode
+			migrate := pb.ChaincodeMigrateFunction
+			f = &migrate
+		}
 	} else if t.Type == pb.Transaction_CHAINCODE_INVOKE || t.Type == pb.Transaction_CHAINCODE_QUERY {
 		ci := &pb.ChaincodeInvocationSpec{}
 		err := proto.Unmarshal(t.Payload, ci)
@@ -387,6 +518,20 @@ func (chaincodeSupport *ChaincodeSupport) Launch(context context.Context, t *pb.
 		return nil, nil, fmt.Errorf("invalid transaction type: %d", t.Type)
 	}
 	chaincode := cID.Name
+
+	//the transaction whose code has to run: the deploy or upgrade itself, or the
+	//latest upgrade of the chaincode recorded in the ledger
+	depUUID := t.Uuid
+	if t.Type != pb.Transaction_CHAINCODE_DEPLOY && t.Type != pb.Transaction_CHAINCODE_UPGRADE {
+		ledger, ledgerErr := ledger.GetLedger()
+		if ledgerErr != nil {
+			return cID, cMsg, fmt.Errorf("Failed to get handle to ledger (%s)", ledgerErr)
+		}
+		if depUUID, ledgerErr = ledger.GetChaincodeDeployment(chaincode, false); ledgerErr != nil {
+			return cID, cMsg, fmt.Errorf("Could not get deployment of %s - %s", chaincode, ledgerErr)
+		}
+	}
+
 	chaincodeSupport.runningChaincodes.Lock()
 	var chrte *chaincodeRTEnv
 	var ok bool
@@ -400,11 +545,28 @@ func (chaincodeSupport *ChaincodeSupport) Launch(context context.Context, t *pb.
 			return cID, cMsg, err
 		}
 		if chrte.handler.isRunning() {
-			chaincodeLogger.Debug("chaincode is running(no need to launch) : %s", chaincode)
+			if t.Type == pb.Transaction_CHAINCODE_UPGRADE {
+				//can only happen in dev mode, the container of the chaincode is stopped otherwise
+				chaincodeSupport.runningChaincodes.Unlock()
+				return cID, cMsg, fmt.Errorf("chaincode %s has to be restarted with its new code before being upgraded", chaincode)
+			}
+			if chaincodeSupport.userRunsCC || chrte.cds == nil || chrte.depUUID == depUUID {
+				chaincodeLogger.Debug("chaincode is running(no need to launch) : %s", chaincode)
+				chaincodeSupport.runningChaincodes.Unlock()
+				return cID, cMsg, nil
+			}
+			//the upgrade that launched the running code was rolled back
+			stale := chrte.cds
 			chaincodeSupport.runningChaincodes.Unlock()
-			return cID, cMsg, nil
+			chaincodeLogger.Info("Stopping chaincode %s which does not run the code of %s", chaincode, depUUID)
+			if err = chaincodeSupport.Stop(context, stale); err != nil {
+				return cID, cMsg, err
+			}
+			chaincodeSupport.runningChaincodes.Lock()
+			chrte = nil
+		} else {
+			chaincodeLogger.Debug("Container not in READY state(%s)...send init/ready", chrte.handler.FSM.Current())
 		}
-		chaincodeLogger.Debug("Container not in READY state(%s)...send init/ready", chrte.handler.FSM.Current())
 	}
 	chaincodeSupport.runningChaincodes.Unlock()
 
@@ -422,14 +584,17 @@ func (chaincodeSupport *ChaincodeSupport) Launch(context context.Context, t *pb.
 	//         5) query successfully retrives committed tx and calls sendInitOrReady
 	// See issue #710
 
-	if t.Type != pb.Transaction_CHAINCODE_DEPLOY {
+	if syscc := sysccapi.GetSysCC(chaincode); syscc != nil && t.Type != pb.Transaction_CHAINCODE_DEPLOY && t.Type != pb.Transaction_CHAINCODE_UPGRADE {
+		//system chaincodes compiled into the peer are launched without a deploy transaction
+		cds = syscc.DeploymentSpec()
+	} else if t.Type != pb.Transaction_CHAINCODE_DEPLOY && t.Type != pb.Transaction_CHAINCODE_UPGRADE {
 		ledger, ledgerErr := ledger.GetLedger()
 		if ledgerErr != nil {
 			return cID, cMsg, fmt.Errorf("Failed to get handle to ledger (%s)", ledgerErr)
 		}
 
 		//hopefully we are restarting from existing image and the deployed transaction exists
-		depTx, ledgerErr = ledger.GetTransactionByUUID(chaincode)
+		depTx, ledgerErr = ledger.GetTransactionByUUID(depUUID)
 		if ledgerErr != nil {
 			return cID, cMsg, fmt.Errorf("Could not get deployment transaction for %s - %s", chaincode, ledgerErr)
 		}
@@ -471,6 +636,8 @@ func (chaincodeSupport *ChaincodeSupport) Launch(context context.Context, t *pb.
 			if errIgnore != nil {
 				chaincodeLogger.Debug("stop failed %s(%s)", errIgnore, err)
 			}
+		} else {
+			chaincodeSupport.setLaunchedSpec(chaincode, cds, depUUID)
 		}
 		chaincodeLogger.Debug("sending init completed")
 	}
@@ -480,6 +647,29 @@ func (chaincodeSupport *ChaincodeSupport) Launch(context context.Context, t *pb.
 	return cID, cMsg, err
 }
 
+//setLaunchedSpec records the spec of a chaincode which has been launched, without
+//its code package, and the transaction it comes from
+func (chaincodeSupport *ChaincodeSupport) setLaunchedSpec(chaincode string, cds *pb.ChaincodeDeploymentSpec, depUUID string) {
+	chaincodeSupport.runningChaincodes.Lock()
+	defer chaincodeSupport.runningChaincodes.Unlock()
+	if chrte, ok := chaincodeSupport.chaincodeHasBeenLaunched(chaincode); ok {
+		chrte.cds = &pb.ChaincodeDeploymentSpec{ChaincodeSpec: cds.ChaincodeSpec, ExecEnv: cds.ExecEnv}
+		chrte.depUUID = depUUID
+	}
+}
+
+// GetExecuteTimeout returns the timeout of the execution of a transaction or query
+// by the chaincode: the one of its spec if set when deployed, chaincode.executetimeout
+// otherwise
+func (chaincodeSupport *ChaincodeSupport) GetExecuteTimeout(chaincode string) time.Duration {
+	chaincodeSupport.runningChaincodes.RLock()
+	defer chaincodeSupport.runningChaincodes.RUnlock()
+	if chrte, ok := chaincodeSupport.chaincodeHasBeenLaunched(chaincode); ok && chrte.cds != nil && chrte.cds.ChaincodeSpec.Timeout > 0 {
+		return time.Duration(chrte.cds.ChaincodeSpec.Timeout) * time.Millisecond
+	}
+	return chaincodeSupport.ccExecuteTimeout
+}
+
 // getSecHelper returns the security help set from NewChaincodeSupport
 func (chaincodeSupport *ChaincodeSupport) getSecHelper() crypto.Peer {
 	return chaincodeSupport.secHelper
@@ -522,12 +712,25 @@ func (chaincodeSupport *ChaincodeSupport) Deploy(context context.Context, t *pb.
 	}
 	chaincodeSupport.runningChaincodes.Unlock()
 
-	args, envs, err := chaincodeSupport.getArgsAndEnv(cID)
+	args, envs, err := chaincodeSupport.getArgsAndEnv(cID, cds.ChaincodeSpec.Type)
 	if err != nil {
 		return cds, fmt.Errorf("error getting args for chaincode %s", err)
 	}
 
-	var targz io.Reader = bytes.NewBuffer(cds.CodePackage)
+	codePackage := cds.CodePackage
+	if len(codePackage) == 0 && cds.ExecEnv != pb.ChaincodeDeploymentSpec_SYSTEM {
+		//the transaction instantiates a chaincode installed on the peer
+		installed, err := GetInstalledChaincode(getCodeHash(cID))
+		if err != nil {
+			return cds, err
+		}
+		if installed == nil {
+			return cds, fmt.Errorf("chaincode %s is not installed on this peer", getCodeHash(cID))
+		}
+		codePackage = installed.CodePackage
+	}
+
+	var targz io.Reader = bytes.NewBuffer(codePackage)
 	cir := &container.CreateImageReq{CCID: ccintf.CCID{ChaincodeSpec: cds.ChaincodeSpec, NetworkID: chaincodeSupport.peerNetworkID, PeerID: chaincodeSupport.peerID}, Args: args, Reader: targz, Env: envs}
 
 	vmtype, _ := chaincodeSupport.getVMType(cds)
@@ -543,6 +746,57 @@ func (chaincodeSupport *ChaincodeSupport) Deploy(context context.Context, t *pb.
 	return cds, err
 }
 
+// Upgrade stops the running code of the chaincode upgraded by the transaction and
+// deploys its new code, which is then launched by Launch. The state of the chaincode
+// is left untouched.
+func (chaincodeSupport *ChaincodeSupport) Upgrade(context context.Context, t *pb.Transaction) (*pb.ChaincodeDeploymentSpec, error) {
+	cds := &pb.ChaincodeDeploymentSpec{}
+	err := proto.Unmarshal(t.Payload, cds)
+	if err != nil {
+		return nil, err
+	}
+	chaincode := cds.ChaincodeSpec.ChaincodeID.Name
+	if chaincode == "" {
+		return nil, fmt.Errorf("chaincode name not set")
+	}
+	if !chaincodeSupport.userRunsCC && cds.ChaincodeSpec.ChaincodeID.Version == "" {
+		return nil, fmt.Errorf("upgrade of chaincode %s without version", chaincode)
+	}
+
+	chaincodeSupport.runningChaincodes.RLock()
+	var running *pb.ChaincodeDeploymentSpec
+	chrte, launched := chaincodeSupport.chaincodeHasBeenLaunched(chaincode)
+	if launched {
+		running = chrte.cds
+	}
+	chaincodeSupport.runningChaincodes.RUnlock()
+
+	if !launched {
+		ledger, ledgerErr := ledger.GetLedger()
+		if ledgerErr != nil {
+			return nil, fmt.Errorf("Failed to get handle to ledger (%s)", ledgerErr)
+		}
+		depUUID, ledgerErr := ledger.GetChaincodeDeployment(chaincode, false)
+		if ledgerErr != nil {
+			return nil, fmt.Errorf("Could not get deployment of %s - %s", chaincode, ledgerErr)
+		}
+		if depTx, _ := ledger.GetTransactionByUUID(depUUID); depTx == nil {
+			return nil, fmt.Errorf("upgrade attempted but chaincode %s is not deployed", chaincode)
+		}
+	} else if running == nil {
+		return nil, fmt.Errorf("premature upgrade - chaincode (%s) is being launched", chaincode)
+	} else if running.ExecEnv == pb.ChaincodeDeploymentSpec_SYSTEM {
+		return nil, fmt.Errorf("system chaincode %s cannot be upgraded", chaincode)
+	} else if !chaincodeSupport.userRunsCC {
+		chaincodeLogger.Debug("stopping chaincode %s for upgrade to %s", chaincode, cds.ChaincodeSpec.ChaincodeID.Version)
+		if err = chaincodeSupport.Stop(context, running); err != nil {
+			return nil, err
+		}
+	}
+
+	return chaincodeSupport.Deploy(context, t)
+}
+
 // HandleChaincodeStream implements ccintf.HandleChaincodeStream for all vms to call with appropriate stream
 func (chaincodeSupport *ChaincodeSupport) HandleChaincodeStream(ctxt context.Context, stream ccintf.ChaincodeStream) error {
 	return HandleChaincodeStream(chaincodeSupport, ctxt, stream)
@@ -572,6 +826,22 @@ func createQueryMessage(uuid string, cMsg *pb.ChaincodeInput) (*pb.ChaincodeMess
 	return &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_QUERY, Payload: payload, Uuid: uuid}, nil
 }
 
+//stopRunaway stops the container of a chaincode which did not complete a transaction in
+//time, so that it stops using resources and is restarted by the next transaction. The
+//chaincodes run by the user in dev mode and the system chaincodes are left running.
+func (chaincodeSupport *ChaincodeSupport) stopRunaway(ctxt context.Context, chaincode string, chrte *chaincodeRTEnv) {
+	chaincodeSupport.runningChaincodes.RLock()
+	cds := chrte.cds
+	chaincodeSupport.runningChaincodes.RUnlock()
+	if cds == nil || chaincodeSupport.userRunsCC || cds.ExecEnv == pb.ChaincodeDeploymentSpec_SYSTEM {
+		return
+	}
+	chaincodeLogger.Warning("Stopping chaincode %s which did not complete a transaction in time", chaincode)
+	if err := chaincodeSupport.Stop(ctxt, cds); err != nil {
+		chaincodeLogger.Error(fmt.Sprintf("Error stopping chaincode %s: %s", chaincode, err))
+	}
+}
+
 // Execute executes a transaction and waits for it to complete until a timeout value.
 func (chaincodeSupport *ChaincodeSupport) Execute(ctxt context.Context, chaincode string, msg *pb.ChaincodeMessage, timeout time.Duration, tx *pb.Transaction) (*pb.ChaincodeMessage, error) {
 	chaincodeSupport.runningChaincodes.Lock()
@@ -584,6 +854,10 @@ func (chaincodeSupport *ChaincodeSupport) Execute(ctxt context.Context, chaincod
 	}
 	chaincodeSupport.runningChaincodes.Unlock()
 
+	if msg.Type == pb.ChaincodeMessage_TRANSACTION {
+		defer chaincodeSupport.txLocks.lock(chaincode, msg.Uuid)()
+	}
+
 	var notfy chan *pb.ChaincodeMessage
 	var err error
 	if notfy, err = chrte.handler.sendExecuteMessage(msg, tx); err != nil {
@@ -601,5 +875,9 @@ func (chaincodeSupport *ChaincodeSupport) Execute(ctxt context.Context, chaincod
 	//our responsibility to delete transaction context if sendExecuteMessage succeeded
 	chrte.handler.deleteTxContext(msg.Uuid)
 
+	if err != nil {
+		chaincodeSupport.stopRunaway(ctxt, chaincode, chrte)
+	}
+
 	return ccresp, err
 }
@@ -0,0 +1,203 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devmode
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/op/go-logging"
+)
+
+var logger = logging.MustGetLogger("devmode")
+
+// stopTimeout is how long a chaincode process is given to exit once interrupted
+// before it is killed
+const stopTimeout = 5 * time.Second
+
+// Reloader runs a Go chaincode in dev mode, where the user rather than the peer
+// runs the chaincodes. It builds the chaincode and launches it under its name,
+// then rebuilds and relaunches it whenever its source changes. The relaunched
+// process registers with the peer under the same name, so it takes over the
+// handler of the chaincode and its state without a new deploy transaction.
+type Reloader struct {
+	name        string
+	path        string
+	srcDir      string
+	peerAddress string
+	interval    time.Duration
+	binary      string
+
+	cmd    *exec.Cmd
+	exited chan error
+}
+
+// NewReloader returns a reloader of the chaincode at path, a Go package under
+// GOPATH, which runs it under name against the peer at peerAddress and checks
+// its source for changes every interval. Only the files under the directory of
+// the package are watched.
+func NewReloader(name, path, peerAddress string, interval time.Duration) (*Reloader, error) {
+	if name == "" || path == "" {
+		return nil, fmt.Errorf("chaincode name and path are required")
+	}
+	if interval <= 0 {
+		return nil, fmt.Errorf("invalid interval %s", interval)
+	}
+	gopath := os.Getenv("GOPATH")
+	if gopath == "" {
+		return nil, fmt.Errorf("GOPATH not defined")
+	}
+	// Only take the first element of GOPATH
+	srcDir := filepath.Join(filepath.SplitList(gopath)[0], "src", path)
+	if fi, err := os.Stat(srcDir); err != nil || !fi.IsDir() {
+		return nil, fmt.Errorf("Path to chaincode does not exist: %s", path)
+	}
+	binDir, err := ioutil.TempDir("", "devmode")
+	if err != nil {
+		return nil, err
+	}
+	return &Reloader{name: name, path: path, srcDir: srcDir, peerAddress: peerAddress, interval: interval, binary: filepath.Join(binDir, name)}, nil
+}
+
+// Run builds and launches the chaincode, and relaunches it on each change of
+// its source until stop is closed. The chaincode is left running when a build
+// fails, and is relaunched by the next change if it exits.
+func (r *Reloader) Run(stop <-chan struct{}) error {
+	defer os.RemoveAll(filepath.Dir(r.binary))
+
+	fingerprint, err := sourceFingerprint(r.srcDir)
+	if err != nil {
+		return err
+	}
+	if err = r.build(); err != nil {
+		return err
+	}
+	if err = r.start(); err != nil {
+		return err
+	}
+	defer r.stopProcess()
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return nil
+		case err := <-r.exited:
+			logger.Warning("Chaincode %s exited (%v), waiting for a change of its source", r.name, err)
+			r.cmd, r.exited = nil, nil
+		case <-ticker.C:
+			latest, err := sourceFingerprint(r.srcDir)
+			if err != nil {
+				logger.Warning("Error reading the source of chaincode %s: %s", r.name, err)
+				continue
+			}
+			if latest == fingerprint {
+				continue
+			}
+			fingerprint = latest
+
+			logger.Info("Source of chaincode %s changed, rebuilding", r.name)
+			if err = r.build(); err != nil {
+				logger.Error("Error rebuilding chaincode %s, the previous build keeps running: %s", r.name, err)
+				continue
+			}
+			r.stopProcess()
+			if err = r.start(); err != nil {
+				logger.Error("Error relaunching chaincode %s: %s", r.name, err)
+			}
+		}
+	}
+}
+
+// build builds the chaincode next to the running binary, which it replaces if
+// the build succeeds
+func (r *Reloader) build() error {
+	next := r.binary + ".next"
+	cmd := exec.Command("go", "build", "-o", next, r.path)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("go build %s failed: %s\n%s", r.path, err, out.String())
+	}
+	return os.Rename(next, r.binary)
+}
+
+func (r *Reloader) start() error {
+	cmd := exec.Command(r.binary, "-peer.address="+r.peerAddress)
+	cmd.Env = append(os.Environ(), "CORE_CHAINCODE_ID_NAME="+r.name)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("Error launching chaincode %s: %s", r.name, err)
+	}
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+	r.cmd, r.exited = cmd, exited
+	logger.Info("Chaincode %s launched (pid %d)", r.name, cmd.Process.Pid)
+	return nil
+}
+
+// stopProcess interrupts the running chaincode, killing it if it does not exit
+// within stopTimeout
+func (r *Reloader) stopProcess() {
+	if r.cmd == nil {
+		return
+	}
+	r.cmd.Process.Signal(os.Interrupt)
+	select {
+	case <-r.exited:
+	case <-time.After(stopTimeout):
+		r.cmd.Process.Kill()
+		<-r.exited
+	}
+	r.cmd, r.exited = nil, nil
+}
+
+// sourceFingerprint returns a hash of the names, sizes and modification times
+// of the files under dir, which changes whenever one of them does
+func sourceFingerprint(dir string) (string, error) {
+	h := sha256.New()
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if path != dir && strings.HasPrefix(info.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		fmt.Fprintf(h, "%s:%d:%d\n", path, info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
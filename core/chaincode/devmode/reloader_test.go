@@ -0,0 +1,73 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package devmode
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSourceFingerprint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "devmode")
+	if err != nil {
+		t.Fatalf("Error creating temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	source := filepath.Join(dir, "chaincode.go")
+	ioutil.WriteFile(source, []byte("package main"), 0644)
+	fingerprint, err := sourceFingerprint(dir)
+	if err != nil {
+		t.Fatalf("Error computing the fingerprint: %s", err)
+	}
+	if again, _ := sourceFingerprint(dir); again != fingerprint {
+		t.Fatalf("Expected the same fingerprint for the same source")
+	}
+
+	//hidden directories such as .git are not part of the source
+	os.MkdirAll(filepath.Join(dir, ".git"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, ".git", "index"), []byte("index"), 0644)
+	if again, _ := sourceFingerprint(dir); again != fingerprint {
+		t.Fatalf("Expected the fingerprint to ignore hidden directories")
+	}
+
+	later := time.Now().Add(time.Minute)
+	ioutil.WriteFile(source, []byte("package main\n"), 0644)
+	os.Chtimes(source, later, later)
+	changed, _ := sourceFingerprint(dir)
+	if changed == fingerprint {
+		t.Fatalf("Expected a different fingerprint once the source changed")
+	}
+
+	os.MkdirAll(filepath.Join(dir, "util"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "util", "util.go"), []byte("package util"), 0644)
+	if added, _ := sourceFingerprint(dir); added == changed {
+		t.Fatalf("Expected a different fingerprint once a file was added")
+	}
+}
+
+func TestNewReloaderChecksPath(t *testing.T) {
+	if _, err := NewReloader("mycc", "github.com/hyperledger/fabric/no/such/chaincode", "0.0.0.0:7051", time.Second); err == nil {
+		t.Fatalf("Expected a missing chaincode path to be refused")
+	}
+	if _, err := NewReloader("", "github.com/hyperledger/fabric/examples/chaincode/go/map", "0.0.0.0:7051", time.Second); err == nil {
+		t.Fatalf("Expected a missing chaincode name to be refused")
+	}
+}
@@ -0,0 +1,151 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chaincode
+
+import (
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/hyperledger/fabric/core/ledger"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// Endorsed transactions
+//
+// Besides being ordered then executed by each validating peer, an invoke can be executed first:
+// the client has it simulated by one or more validating peers against their committed state (see
+// SimulateTransaction), and submits the results, their endorsements, in a CHAINCODE_ENDORSED
+// transaction. When the transaction is reached in its batch, the endorsements are checked to
+// agree and the changes they carry are applied, without running the chaincode, if the values the
+// invoke read are still the ones of the state at that point. Otherwise the transaction fails and
+// the client has to simulate the invoke again. Since the outcome is the one computed by the
+// endorsers, a chaincode which does not execute deterministically can not make the validating
+// peers diverge.
+
+// executeEndorsed applies the changes endorsed by the CHAINCODE_ENDORSED transaction t, and
+// returns the response and the event of the chaincode they agree on
+func executeEndorsed(chain *ChaincodeSupport, lgr *ledger.Ledger, t *pb.Transaction) ([]byte, *pb.ChaincodeEvent, error) {
+	if chain.minEndorsers == 0 {
+		return nil, nil, fmt.Errorf("Endorsed transactions are not enabled")
+	}
+	endorsed := &pb.EndorsedTransaction{}
+	if err := proto.Unmarshal(t.Payload, endorsed); err != nil {
+		return nil, nil, fmt.Errorf("Failed to unmarshal endorsed transaction(%s)", err)
+	}
+	if endorsed.ChaincodeID == nil || endorsed.ChaincodeID.Name == "" {
+		return nil, nil, fmt.Errorf("Endorsed transaction %s does not name its chaincode", t.Uuid)
+	}
+	result, err := chain.checkEndorsements(endorsed.ChaincodeID.Name, endorsed.Endorsements)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = checkStateEndorsers(lgr, endorsed.ChaincodeID.Name, endorsed.Endorsements); err != nil {
+		return nil, nil, err
+	}
+
+	markTxBegin(lgr, t)
+	if err = lgr.ApplyReadWriteSet(result.Reads, result.RangeReads, result.Writes); err == nil {
+		err = validateTx(lgr, t)
+	}
+	if err != nil {
+		markTxFinish(lgr, t, false)
+		return nil, nil, fmt.Errorf("Failed to apply endorsed transaction %s(%s)", t.Uuid, err)
+	}
+	markTxFinish(lgr, t, true)
+
+	var event *pb.ChaincodeEvent
+	if result.ChaincodeEvent != nil {
+		event = &pb.ChaincodeEvent{ChaincodeID: endorsed.ChaincodeID.Name, TxID: t.Uuid, EventName: result.ChaincodeEvent.EventName, Payload: result.ChaincodeEvent.Payload}
+	}
+	return result.Response.Msg, event, nil
+}
+
+// checkEndorsements checks that the endorsements are successful simulations of an invoke of
+// chaincode, which agree on its outcome, made by enough distinct validating peers. Their
+// signatures are verified when security is enabled. The first endorsement is returned
+func (chaincodeSupport *ChaincodeSupport) checkEndorsements(chaincode string, endorsements []*pb.SimulationResult) (*pb.SimulationResult, error) {
+	if len(endorsements) == 0 {
+		return nil, fmt.Errorf("No endorsement")
+	}
+	endorsers := make(map[string]bool)
+	for _, endorsement := range endorsements {
+		if endorsement.PeerID == nil || endorsement.PeerID.Name == "" {
+			return nil, fmt.Errorf("Endorsement of simulation %s does not name its endorser", endorsement.Uuid)
+		}
+		if endorsement.Response == nil || endorsement.Response.Status != pb.Response_SUCCESS {
+			return nil, fmt.Errorf("Simulation %s endorsed by %s failed", endorsement.Uuid, endorsement.PeerID.Name)
+		}
+		if err := checkEndorsedChaincode(chaincode, endorsement); err != nil {
+			return nil, err
+		}
+		if !proto.Equal(endorsedOutcome(endorsement), endorsedOutcome(endorsements[0])) {
+			return nil, fmt.Errorf("Endorsements of %s and %s do not agree", endorsement.PeerID.Name, endorsements[0].PeerID.Name)
+		}
+		if secHelper := chaincodeSupport.getSecHelper(); nil != secHelper {
+			unsigned := *endorsement
+			unsigned.Signature = nil
+			raw, err := proto.Marshal(&unsigned)
+			if err != nil {
+				return nil, err
+			}
+			if err = secHelper.Verify(endorsement.PkiID, endorsement.Signature, raw); err != nil {
+				return nil, fmt.Errorf("Invalid endorsement signature of %s(%s)", endorsement.PeerID.Name, err)
+			}
+		}
+		endorsers[endorsement.PeerID.Name] = true
+	}
+	if len(endorsers) < chaincodeSupport.minEndorsers {
+		return nil, fmt.Errorf("Endorsed by %d validating peers, %d required", len(endorsers), chaincodeSupport.minEndorsers)
+	}
+	return endorsements[0], nil
+}
+
+// checkEndorsedChaincode checks that the state read and changed by the endorsed simulation, and
+// the event it set, belong to chaincode
+func checkEndorsedChaincode(chaincode string, endorsement *pb.SimulationResult) error {
+	for _, read := range endorsement.Reads {
+		if read.ChaincodeID != chaincode {
+			return fmt.Errorf("Simulation %s read the state of %s, not of %s", endorsement.Uuid, read.ChaincodeID, chaincode)
+		}
+	}
+	for _, read := range endorsement.RangeReads {
+		if read.ChaincodeID != chaincode {
+			return fmt.Errorf("Simulation %s read the state of %s, not of %s", endorsement.Uuid, read.ChaincodeID, chaincode)
+		}
+	}
+	for _, write := range endorsement.Writes {
+		if write.ChaincodeID != chaincode {
+			return fmt.Errorf("Simulation %s changed the state of %s, not of %s", endorsement.Uuid, write.ChaincodeID, chaincode)
+		}
+	}
+	if event := endorsement.ChaincodeEvent; event != nil && event.ChaincodeID != chaincode {
+		return fmt.Errorf("Simulation %s set an event of %s, not of %s", endorsement.Uuid, event.ChaincodeID, chaincode)
+	}
+	return nil
+}
+
+// endorsedOutcome returns what the endorsements of a simulation have to agree on: the
+// response, the read-write set and the event of the chaincode, which is stamped with
+// the uuid of the simulation that each endorser made
+func endorsedOutcome(endorsement *pb.SimulationResult) *pb.SimulationResult {
+	outcome := &pb.SimulationResult{Response: endorsement.Response, Reads: endorsement.Reads, RangeReads: endorsement.RangeReads, Writes: endorsement.Writes}
+	if event := endorsement.ChaincodeEvent; event != nil {
+		outcome.ChaincodeEvent = &pb.ChaincodeEvent{ChaincodeID: event.ChaincodeID, EventName: event.EventName, Payload: event.Payload}
+	}
+	return outcome
+}
@@ -17,7 +17,6 @@ limitations under the License.
 package chaincode
 
 import (
-	"errors"
 	"fmt"
 	"time"
 
@@ -25,17 +24,28 @@ import (
 	"golang.org/x/net/context"
 
 	"github.com/hyperledger/fabric/core/ledger"
+	"github.com/hyperledger/fabric/core/tracing"
 	pb "github.com/hyperledger/fabric/protos"
 )
 
-//Execute - execute transaction or a query
-func Execute(ctxt context.Context, chain *ChaincodeSupport, t *pb.Transaction) ([]byte, error) {
+//Execute - execute transaction or a query. For an invoke, the event set by
+//the chaincode (if any) is returned along with the result
+func Execute(ctxt context.Context, chain *ChaincodeSupport, t *pb.Transaction) ([]byte, *pb.ChaincodeEvent, error) {
+	span := tracing.StartTxSpan(t.Uuid, "execute")
+	span.SetTag("tx.type", t.Type.String())
+	result, ccevent, err := execute(ctxt, chain, t)
+	span.SetError(err)
+	span.Finish()
+	return result, ccevent, err
+}
+
+func execute(ctxt context.Context, chain *ChaincodeSupport, t *pb.Transaction) ([]byte, *pb.ChaincodeEvent, error) {
 	var err error
 
 	// get a handle to ledger to mark the begin/finish of a tx
 	ledger, ledgerErr := ledger.GetLedger()
 	if ledgerErr != nil {
-		return nil, fmt.Errorf("Failed to get handle to ledger (%s)", ledgerErr)
+		return nil, nil, fmt.Errorf("Failed to get handle to ledger (%s)", ledgerErr)
 	}
 
 	if secHelper := chain.getSecHelper(); nil != secHelper {
@@ -43,56 +53,109 @@ func Execute(ctxt context.Context, chain *ChaincodeSupport, t *pb.Transaction) (
 		t, err = secHelper.TransactionPreExecution(t)
 		// Note that t is now decrypted and is a deep clone of the original input t
 		if nil != err {
-			return nil, err
+			return nil, nil, err
 		}
 	}
 
 	if t.Type == pb.Transaction_CHAINCODE_DEPLOY {
-		_, err := chain.Deploy(ctxt, t)
+		cds, err := chain.Deploy(ctxt, t)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Failed to deploy chaincode spec(%s)", err)
+		}
+		stateKeys, err := getStateKeys(cds)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		//launch and wait for ready, unless the state is encrypted: the chaincode is then
+		//initialized by an endorsed transaction
+		markTxBegin(ledger, t)
+		if stateKeys == nil {
+			_, _, err = chain.Launch(ctxt, t)
+		}
+		if err != nil {
+			markTxFinish(ledger, t, false)
+			return nil, nil, fmt.Errorf("%s", err)
+		}
+		if err = recordInstantiation(ledger, t); err != nil {
+			markTxFinish(ledger, t, false)
+			return nil, nil, err
+		}
+		if err = validateTx(ledger, t); err != nil {
+			markTxFinish(ledger, t, false)
+			return nil, nil, err
+		}
+		markTxFinish(ledger, t, true)
+	} else if t.Type == pb.Transaction_CHAINCODE_UPGRADE {
+		cds, err := chain.Upgrade(ctxt, t)
 		if err != nil {
-			return nil, fmt.Errorf("Failed to deploy chaincode spec(%s)", err)
+			return nil, nil, fmt.Errorf("Failed to upgrade chaincode spec(%s)", err)
+		}
+		if err = checkStateNotEncrypted(ledger, cds.ChaincodeSpec.ChaincodeID.Name); err != nil {
+			return nil, nil, err
 		}
 
-		//launch and wait for ready
+		//launch the new code, which migrates the state within the tx, and record it
 		markTxBegin(ledger, t)
 		_, _, err = chain.Launch(ctxt, t)
 		if err != nil {
 			markTxFinish(ledger, t, false)
-			return nil, fmt.Errorf("%s", err)
+			return nil, nil, fmt.Errorf("%s", err)
+		}
+		if err = recordInstantiation(ledger, t); err != nil {
+			markTxFinish(ledger, t, false)
+			return nil, nil, err
+		}
+		if err = validateTx(ledger, t); err != nil {
+			markTxFinish(ledger, t, false)
+			return nil, nil, err
 		}
 		markTxFinish(ledger, t, true)
+	} else if t.Type == pb.Transaction_CHAINCODE_ENDORSED {
+		//the chaincode already ran on the endorsers, only their changes are applied
+		return executeEndorsed(chain, ledger, t)
+	} else if t.Type == pb.Transaction_CONFIG {
+		return nil, nil, executeConfig(chain, ledger, t)
 	} else if t.Type == pb.Transaction_CHAINCODE_INVOKE || t.Type == pb.Transaction_CHAINCODE_QUERY {
+		//transient data is not covered by the signature, check it against the signed hash
+		if err = t.VerifyTransient(); err != nil {
+			return nil, nil, err
+		}
+		if t.Type == pb.Transaction_CHAINCODE_INVOKE {
+			name, err := getInvokedChaincode(t)
+			if err != nil {
+				return nil, nil, err
+			}
+			if err = checkStateNotEncrypted(ledger, name); err != nil {
+				return nil, nil, err
+			}
+		}
+
 		//will launch if necessary (and wait for ready)
 		cID, cMsg, err := chain.Launch(ctxt, t)
 		if err != nil {
-			return nil, fmt.Errorf("Failed to launch chaincode spec(%s)", err)
+			return nil, nil, fmt.Errorf("Failed to launch chaincode spec(%s)", err)
 		}
 
 		//this should work because it worked above...
 		chaincode := cID.Name
 
 		if err != nil {
-			return nil, fmt.Errorf("Failed to stablish stream to container %s", chaincode)
+			return nil, nil, fmt.Errorf("Failed to stablish stream to container %s", chaincode)
 		}
 
-		// TODO: Need to comment next line and uncomment call to getTimeout, when transaction blocks are being created
-		timeout := time.Duration(30000) * time.Millisecond
-		//timeout, err := getTimeout(cID)
-
-		if err != nil {
-			return nil, fmt.Errorf("Failed to retrieve chaincode spec(%s)", err)
-		}
+		timeout := chain.GetExecuteTimeout(chaincode)
 
 		var ccMsg *pb.ChaincodeMessage
 		if t.Type == pb.Transaction_CHAINCODE_INVOKE {
 			ccMsg, err = createTransactionMessage(t.Uuid, cMsg)
 			if err != nil {
-				return nil, fmt.Errorf("Failed to transaction message(%s)", err)
+				return nil, nil, fmt.Errorf("Failed to transaction message(%s)", err)
 			}
 		} else {
 			ccMsg, err = createQueryMessage(t.Uuid, cMsg)
 			if err != nil {
-				return nil, fmt.Errorf("Failed to query message(%s)", err)
+				return nil, nil, fmt.Errorf("Failed to query message(%s)", err)
 			}
 		}
 
@@ -101,52 +164,131 @@ func Execute(ctxt context.Context, chain *ChaincodeSupport, t *pb.Transaction) (
 		if err != nil {
 			// Rollback transaction
 			markTxFinish(ledger, t, false)
-			return nil, fmt.Errorf("Failed to execute transaction or query(%s)", err)
+			return nil, nil, fmt.Errorf("Failed to execute transaction or query(%s)", err)
 		} else if resp == nil {
 			// Rollback transaction
 			markTxFinish(ledger, t, false)
-			return nil, fmt.Errorf("Failed to receive a response for (%s)", t.Uuid)
+			return nil, nil, fmt.Errorf("Failed to receive a response for (%s)", t.Uuid)
 		} else {
 			if resp.Type == pb.ChaincodeMessage_COMPLETED || resp.Type == pb.ChaincodeMessage_QUERY_COMPLETED {
+				if err = validateTx(ledger, t); err != nil {
+					markTxFinish(ledger, t, false)
+					return nil, nil, err
+				}
 				// Success
 				markTxFinish(ledger, t, true)
-				return resp.Payload, nil
+				return resp.Payload, chaincodeEvent(cID, t, resp), nil
 			} else if resp.Type == pb.ChaincodeMessage_ERROR || resp.Type == pb.ChaincodeMessage_QUERY_ERROR {
 				// Rollback transaction
 				markTxFinish(ledger, t, false)
-				return nil, fmt.Errorf("Transaction or query returned with failure: %s", string(resp.Payload))
+				return nil, nil, fmt.Errorf("Transaction or query returned with failure: %s", string(resp.Payload))
 			}
 			markTxFinish(ledger, t, false)
-			return resp.Payload, fmt.Errorf("receive a response for (%s) but in invalid state(%d)", t.Uuid, resp.Type)
+			return resp.Payload, nil, fmt.Errorf("receive a response for (%s) but in invalid state(%d)", t.Uuid, resp.Type)
 		}
 
 	} else {
 		err = fmt.Errorf("Invalid transaction type %s", t.Type.String())
 	}
-	return nil, err
+	return nil, nil, err
 }
 
-//ExecuteTransactions - will execute transactions on the array one by one
+//ExecuteTransactions - will execute transactions on the array one by one, or
+//in parallel when enabled, with the same outcome (see executeTransactionsInParallel)
 //will return an array of errors one for each transaction. If the execution
-//succeeded, array element will be nil. returns []byte of state hash or
-//error
-func ExecuteTransactions(ctxt context.Context, cname ChainName, xacts []*pb.Transaction) (stateHash []byte, txerrs []error, err error) {
+//succeeded, array element will be nil. A transaction whose uuid was already used,
+//on the blockchain or earlier in the batch, is not executed and fails with a
+//ledger error of type ErrorTypeDuplicateTransaction, as does an expired transaction
+//(see pb.TransactionExpiry) with an expiry error. Chaincode events are returned
+//in an array of the same length, nil where a transaction set none. A transaction
+//whose state changes the ledger replayed from its write-ahead log on startup is not
+//executed again, and succeeds without an event (see ledger.IsReplayedTx). returns
+//[]byte of state hash or error
+func ExecuteTransactions(ctxt context.Context, cname ChainName, xacts []*pb.Transaction) (stateHash []byte, ccevents []*pb.ChaincodeEvent, txerrs []error, err error) {
 	var chain = GetChain(cname)
 	if chain == nil {
 		// TODO: We should never get here, but otherwise a good reminder to better handle
 		panic(fmt.Sprintf("[ExecuteTransactions]Chain %s not found\n", cname))
 	}
 	txerrs = make([]error, len(xacts))
-	for i, t := range xacts {
-		_, txerrs[i] = Execute(ctxt, chain, t)
-	}
+	ccevents = make([]*pb.ChaincodeEvent, len(xacts))
 
+	// the transactions replayed from the write-ahead log of the ledger were executed before the
+	// peer restarted, their state changes are already in the batch
+	executed := make([]int, 0, len(xacts))
 	var lgr *ledger.Ledger
 	lgr, err = ledger.GetLedger()
+	if err == nil {
+		blockNumber, batchTime := lgr.GetBlockchainSize(), getBatchTime(xacts)
+		for i, t := range xacts {
+			if t.Type != pb.Transaction_CHAINCODE_QUERY {
+				if txerrs[i] = lgr.AddBatchTxUUID(t.Uuid); txerrs[i] == nil {
+					if lgr.IsReplayedTx(t.Uuid) {
+						chaincodeLogger.Debug("Transaction [%s] was replayed from the write-ahead log, not executing it again", t.Uuid)
+						continue
+					}
+					txerrs[i] = t.CheckExpiry(blockNumber, batchTime)
+				}
+			}
+			executed = append(executed, i)
+		}
+	} else {
+		for i := range xacts {
+			executed = append(executed, i)
+		}
+	}
+	execXacts := make([]*pb.Transaction, len(executed))
+	execEvents := make([]*pb.ChaincodeEvent, len(executed))
+	execErrs := make([]error, len(executed))
+	for j, i := range executed {
+		execXacts[j], execErrs[j] = xacts[i], txerrs[i]
+	}
+	if err == nil && chain.simulationWorkers > 0 {
+		executeTransactionsInParallel(ctxt, chain, lgr, execXacts, execEvents, execErrs)
+	} else {
+		for j, t := range execXacts {
+			if execErrs[j] == nil {
+				_, execEvents[j], execErrs[j] = Execute(ctxt, chain, t)
+			}
+		}
+	}
+	for j, i := range executed {
+		ccevents[i], txerrs[i] = execEvents[j], execErrs[j]
+	}
+
 	if err == nil {
 		stateHash, err = lgr.GetTempStateHash()
 	}
-	return stateHash, txerrs, err
+	return stateHash, ccevents, txerrs, err
+}
+
+// getBatchTime returns the time of a batch, against which the expiry of its transactions is
+// checked: the latest timestamp of its transactions, which is the same on all the peers. The
+// validating peers reject the transactions stamped in the future when they are submitted (see
+// pb.Transaction.CheckTimestamp), so that one cannot expire the others of its batch
+func getBatchTime(xacts []*pb.Transaction) time.Time {
+	var batchTime time.Time
+	for _, t := range xacts {
+		if ts := t.GetTimestamp(); ts != nil {
+			if txTime := time.Unix(ts.Seconds, int64(ts.Nanos)).UTC(); txTime.After(batchTime) {
+				batchTime = txTime
+			}
+		}
+	}
+	return batchTime
+}
+
+// chaincodeEvent returns the event the chaincode set while completing
+// transaction t, stamped with the chaincode and transaction it came from.
+// Queries can not emit events.
+func chaincodeEvent(cID *pb.ChaincodeID, t *pb.Transaction, resp *pb.ChaincodeMessage) *pb.ChaincodeEvent {
+	if t.Type != pb.Transaction_CHAINCODE_INVOKE || resp.ChaincodeEvent == nil {
+		return nil
+	}
+	event := resp.ChaincodeEvent
+	event.ChaincodeID = cID.Name
+	event.TxID = t.Uuid
+	return event
 }
 
 // GetSecureContext returns the security context from the context object or error
@@ -163,26 +305,30 @@ func ExecuteTransactions(ctxt context.Context, cname ChainName, xacts []*pb.Tran
 // 	return nil, err
 // }
 
-var errFailedToGetChainCodeSpecForTransaction = errors.New("Failed to get ChainCodeSpec from Transaction")
-
-func getTimeout(cID *pb.ChaincodeID) (time.Duration, error) {
-	ledger, err := ledger.GetLedger()
-	if err == nil {
-		chaincodeID := cID.Name
-		txUUID, err := ledger.GetState(chaincodeID, "github.com_openblockchain_obc-peer_chaincode_id", true)
-		if err == nil {
-			tx, err := ledger.GetTransactionByUUID(string(txUUID))
-			if err == nil {
-				chaincodeDeploymentSpec := &pb.ChaincodeDeploymentSpec{}
-				proto.Unmarshal(tx.Payload, chaincodeDeploymentSpec)
-				chaincodeSpec := chaincodeDeploymentSpec.GetChaincodeSpec()
-				timeout := time.Duration(time.Duration(chaincodeSpec.Timeout) * time.Millisecond)
-				return timeout, nil
+//recordInstantiation records in the ledger the chaincode instantiated by the deploy or
+//upgrade transaction t, which runs its code from then on
+func recordInstantiation(ledger *ledger.Ledger, t *pb.Transaction) error {
+	cds := &pb.ChaincodeDeploymentSpec{}
+	if err := proto.Unmarshal(t.Payload, cds); err != nil {
+		return err
+	}
+	cID := cds.ChaincodeSpec.ChaincodeID
+	instantiation := &pb.ChaincodeInstantiation{Name: cID.Name, Version: 1, CodeHash: getCodeHash(cID), EndorsementPolicy: cds.EndorsementPolicy, TxUUID: t.Uuid, StateKeys: cds.StateKeys}
+	if t.Type == pb.Transaction_CHAINCODE_UPGRADE {
+		previous, err := ledger.GetChaincodeInstantiation(cID.Name, false)
+		if err != nil {
+			return err
+		}
+		//chaincodes deployed before the instantiations were recorded are at version 1
+		instantiation.Version = 2
+		if previous != nil {
+			instantiation.Version = previous.Version + 1
+			if instantiation.EndorsementPolicy == "" {
+				instantiation.EndorsementPolicy = previous.EndorsementPolicy
 			}
 		}
 	}
-
-	return -1, errFailedToGetChainCodeSpecForTransaction
+	return ledger.SetChaincodeInstantiation(instantiation)
 }
 
 func markTxBegin(ledger *ledger.Ledger, t *pb.Transaction) {
@@ -198,3 +344,10 @@ func markTxFinish(ledger *ledger.Ledger, t *pb.Transaction, successful bool) {
 	}
 	ledger.TxFinished(t.Uuid, successful)
 }
+
+func validateTx(ledger *ledger.Ledger, t *pb.Transaction) error {
+	if t.Type == pb.Transaction_CHAINCODE_QUERY {
+		return nil
+	}
+	return ledger.ValidateTx(t.Uuid)
+}
@@ -223,7 +223,7 @@ func deploy(ctx context.Context, spec *pb.ChaincodeSpec) ([]byte, error) {
 		return nil, fmt.Errorf("Failed to get handle to ledger: %s ", err)
 	}
 	ledger.BeginTxBatch("1")
-	b, err := Execute(ctx, GetChain(DefaultChain), transaction)
+	b, _, err := Execute(ctx, GetChain(DefaultChain), transaction)
 	if err != nil {
 		return nil, fmt.Errorf("Error deploying chaincode: %s", err)
 	}
@@ -243,7 +243,7 @@ func deploy2(ctx context.Context, chaincodeDeploymentSpec *pb.ChaincodeDeploymen
 
 	ledger, err := ledger.GetLedger()
 	ledger.BeginTxBatch("1")
-	b, err := Execute(ctx, GetChain(DefaultChain), transaction)
+	b, _, err := Execute(ctx, GetChain(DefaultChain), transaction)
 	if err != nil {
 		return nil, fmt.Errorf("Error deploying chaincode: %s", err)
 	}
@@ -273,11 +273,11 @@ func invoke(ctx context.Context, spec *pb.ChaincodeSpec, typ pb.Transaction_Type
 	var retval []byte
 	var execErr error
 	if typ == pb.Transaction_CHAINCODE_QUERY {
-		retval, execErr = Execute(ctx, GetChain(DefaultChain), transaction)
+		retval, _, execErr = Execute(ctx, GetChain(DefaultChain), transaction)
 	} else {
 		ledger, _ := ledger.GetLedger()
 		ledger.BeginTxBatch("1")
-		retval, execErr = Execute(ctx, GetChain(DefaultChain), transaction)
+		retval, _, execErr = Execute(ctx, GetChain(DefaultChain), transaction)
 		if err != nil {
 			return uuid, nil, fmt.Errorf("Error invoking chaincode: %s ", err)
 		}
@@ -1312,6 +1312,34 @@ func TestChaincodeQueryChaincodeWithSec(t *testing.T) {
 	finitPeer(peerLis)
 }
 
+func TestCheckEndorsements(t *testing.T) {
+	chain := &ChaincodeSupport{minEndorsers: 2}
+	endorsement := func(peer string, value string) *pb.SimulationResult {
+		return &pb.SimulationResult{Uuid: util.GenerateUUID(), PeerID: &pb.PeerID{Name: peer},
+			Response: &pb.Response{Status: pb.Response_SUCCESS},
+			Writes:   []*pb.StateWrite{{ChaincodeID: "example02", Key: "a", Value: []byte(value)}},
+			ChaincodeEvent: &pb.ChaincodeEvent{ChaincodeID: "example02", TxID: peer, EventName: "moved"}}
+	}
+
+	if _, err := chain.checkEndorsements("example02", []*pb.SimulationResult{endorsement("vp0", "90"), endorsement("vp1", "90")}); err != nil {
+		t.Fatalf("Error checking agreeing endorsements: %s", err)
+	}
+	if _, err := chain.checkEndorsements("example02", []*pb.SimulationResult{endorsement("vp0", "90"), endorsement("vp0", "90")}); err == nil {
+		t.Fatalf("Expected an error for endorsements of a single validating peer")
+	}
+	if _, err := chain.checkEndorsements("example02", []*pb.SimulationResult{endorsement("vp0", "90"), endorsement("vp1", "80")}); err == nil {
+		t.Fatalf("Expected an error for endorsements which do not agree")
+	}
+	if _, err := chain.checkEndorsements("example01", []*pb.SimulationResult{endorsement("vp0", "90"), endorsement("vp1", "90")}); err == nil {
+		t.Fatalf("Expected an error for endorsements changing the state of another chaincode")
+	}
+	failed := endorsement("vp1", "90")
+	failed.Response.Status = pb.Response_FAILURE
+	if _, err := chain.checkEndorsements("example02", []*pb.SimulationResult{endorsement("vp0", "90"), failed}); err == nil {
+		t.Fatalf("Expected an error for a failed simulation")
+	}
+}
+
 func TestMain(m *testing.M) {
 	SetupTestConfig()
 	os.Exit(m.Run())
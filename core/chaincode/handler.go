@@ -20,7 +20,6 @@ import (
 	"fmt"
 	"io"
 	"sync"
-	"time"
 
 	"github.com/golang/protobuf/proto"
 	ccintf "github.com/hyperledger/fabric/core/container/ccintf"
@@ -94,6 +93,12 @@ type Handler struct {
 
 	// used to do Send after making sure the state transition is complete
 	nextState chan *nextStateInfo
+
+	// encryptor of the state of the chaincode, if encrypted (see getStateEncryptor)
+	stateEncryptorLock   sync.Mutex
+	stateEncryptorLoaded bool
+	stateEncryptor       crypto.StateEncryptor
+	stateEncryptorErr    error
 }
 
 func shortuuid(uuid string) string {
@@ -357,9 +362,15 @@ func newChaincodeSupportHandler(chaincodeSupport *ChaincodeSupport, peerChatStre
 			{Name: pb.ChaincodeMessage_TRANSACTION.String(), Src: []string{readystate}, Dst: transactionstate},
 			{Name: pb.ChaincodeMessage_PUT_STATE.String(), Src: []string{transactionstate}, Dst: busyxactstate},
 			{Name: pb.ChaincodeMessage_DEL_STATE.String(), Src: []string{transactionstate}, Dst: busyxactstate},
+			{Name: pb.ChaincodeMessage_PUT_PRIVATE_STATE.String(), Src: []string{transactionstate}, Dst: busyxactstate},
+			{Name: pb.ChaincodeMessage_DEL_PRIVATE_STATE.String(), Src: []string{transactionstate}, Dst: busyxactstate},
+			{Name: pb.ChaincodeMessage_MARK_WRITE_ONCE.String(), Src: []string{transactionstate}, Dst: busyxactstate},
 			{Name: pb.ChaincodeMessage_INVOKE_CHAINCODE.String(), Src: []string{transactionstate}, Dst: busyxactstate},
 			{Name: pb.ChaincodeMessage_PUT_STATE.String(), Src: []string{initstate}, Dst: busyinitstate},
 			{Name: pb.ChaincodeMessage_DEL_STATE.String(), Src: []string{initstate}, Dst: busyinitstate},
+			{Name: pb.ChaincodeMessage_PUT_PRIVATE_STATE.String(), Src: []string{initstate}, Dst: busyinitstate},
+			{Name: pb.ChaincodeMessage_DEL_PRIVATE_STATE.String(), Src: []string{initstate}, Dst: busyinitstate},
+			{Name: pb.ChaincodeMessage_MARK_WRITE_ONCE.String(), Src: []string{initstate}, Dst: busyinitstate},
 			{Name: pb.ChaincodeMessage_INVOKE_CHAINCODE.String(), Src: []string{initstate}, Dst: busyinitstate},
 			{Name: pb.ChaincodeMessage_COMPLETED.String(), Src: []string{initstate, readystate, transactionstate}, Dst: readystate},
 			{Name: pb.ChaincodeMessage_GET_STATE.String(), Src: []string{readystate}, Dst: readystate},
@@ -367,6 +378,11 @@ func newChaincodeSupportHandler(chaincodeSupport *ChaincodeSupport, peerChatStre
 			{Name: pb.ChaincodeMessage_GET_STATE.String(), Src: []string{busyinitstate}, Dst: busyinitstate},
 			{Name: pb.ChaincodeMessage_GET_STATE.String(), Src: []string{transactionstate}, Dst: transactionstate},
 			{Name: pb.ChaincodeMessage_GET_STATE.String(), Src: []string{busyxactstate}, Dst: busyxactstate},
+			{Name: pb.ChaincodeMessage_GET_PRIVATE_STATE.String(), Src: []string{readystate}, Dst: readystate},
+			{Name: pb.ChaincodeMessage_GET_PRIVATE_STATE.String(), Src: []string{initstate}, Dst: initstate},
+			{Name: pb.ChaincodeMessage_GET_PRIVATE_STATE.String(), Src: []string{busyinitstate}, Dst: busyinitstate},
+			{Name: pb.ChaincodeMessage_GET_PRIVATE_STATE.String(), Src: []string{transactionstate}, Dst: transactionstate},
+			{Name: pb.ChaincodeMessage_GET_PRIVATE_STATE.String(), Src: []string{busyxactstate}, Dst: busyxactstate},
 			{Name: pb.ChaincodeMessage_RANGE_QUERY_STATE.String(), Src: []string{readystate}, Dst: readystate},
 			{Name: pb.ChaincodeMessage_RANGE_QUERY_STATE.String(), Src: []string{initstate}, Dst: initstate},
 			{Name: pb.ChaincodeMessage_RANGE_QUERY_STATE.String(), Src: []string{busyinitstate}, Dst: busyinitstate},
@@ -382,6 +398,16 @@ func newChaincodeSupportHandler(chaincodeSupport *ChaincodeSupport, peerChatStre
 			{Name: pb.ChaincodeMessage_RANGE_QUERY_STATE_CLOSE.String(), Src: []string{busyinitstate}, Dst: busyinitstate},
 			{Name: pb.ChaincodeMessage_RANGE_QUERY_STATE_CLOSE.String(), Src: []string{transactionstate}, Dst: transactionstate},
 			{Name: pb.ChaincodeMessage_RANGE_QUERY_STATE_CLOSE.String(), Src: []string{busyxactstate}, Dst: busyxactstate},
+			{Name: pb.ChaincodeMessage_GET_QUERY_RESULT.String(), Src: []string{readystate}, Dst: readystate},
+			{Name: pb.ChaincodeMessage_GET_QUERY_RESULT.String(), Src: []string{initstate}, Dst: initstate},
+			{Name: pb.ChaincodeMessage_GET_QUERY_RESULT.String(), Src: []string{busyinitstate}, Dst: busyinitstate},
+			{Name: pb.ChaincodeMessage_GET_QUERY_RESULT.String(), Src: []string{transactionstate}, Dst: transactionstate},
+			{Name: pb.ChaincodeMessage_GET_QUERY_RESULT.String(), Src: []string{busyxactstate}, Dst: busyxactstate},
+			{Name: pb.ChaincodeMessage_GET_HISTORY_FOR_KEY.String(), Src: []string{readystate}, Dst: readystate},
+			{Name: pb.ChaincodeMessage_GET_HISTORY_FOR_KEY.String(), Src: []string{initstate}, Dst: initstate},
+			{Name: pb.ChaincodeMessage_GET_HISTORY_FOR_KEY.String(), Src: []string{busyinitstate}, Dst: busyinitstate},
+			{Name: pb.ChaincodeMessage_GET_HISTORY_FOR_KEY.String(), Src: []string{transactionstate}, Dst: transactionstate},
+			{Name: pb.ChaincodeMessage_GET_HISTORY_FOR_KEY.String(), Src: []string{busyxactstate}, Dst: busyxactstate},
 			{Name: pb.ChaincodeMessage_ERROR.String(), Src: []string{initstate}, Dst: endstate},
 			{Name: pb.ChaincodeMessage_ERROR.String(), Src: []string{transactionstate}, Dst: readystate},
 			{Name: pb.ChaincodeMessage_ERROR.String(), Src: []string{busyinitstate}, Dst: initstate},
@@ -397,8 +423,13 @@ func newChaincodeSupportHandler(chaincodeSupport *ChaincodeSupport, peerChatStre
 			"after_" + pb.ChaincodeMessage_RANGE_QUERY_STATE.String():       func(e *fsm.Event) { v.afterRangeQueryState(e, v.FSM.Current()) },
 			"after_" + pb.ChaincodeMessage_RANGE_QUERY_STATE_NEXT.String():  func(e *fsm.Event) { v.afterRangeQueryStateNext(e, v.FSM.Current()) },
 			"after_" + pb.ChaincodeMessage_RANGE_QUERY_STATE_CLOSE.String(): func(e *fsm.Event) { v.afterRangeQueryStateClose(e, v.FSM.Current()) },
+			"after_" + pb.ChaincodeMessage_GET_QUERY_RESULT.String():        func(e *fsm.Event) { v.afterGetQueryResult(e, v.FSM.Current()) },
+			"after_" + pb.ChaincodeMessage_GET_HISTORY_FOR_KEY.String():     func(e *fsm.Event) { v.afterGetHistoryForKey(e, v.FSM.Current()) },
 			"after_" + pb.ChaincodeMessage_PUT_STATE.String():               func(e *fsm.Event) { v.afterPutState(e, v.FSM.Current()) },
 			"after_" + pb.ChaincodeMessage_DEL_STATE.String():               func(e *fsm.Event) { v.afterDelState(e, v.FSM.Current()) },
+			"after_" + pb.ChaincodeMessage_GET_PRIVATE_STATE.String():       func(e *fsm.Event) { v.afterGetState(e, v.FSM.Current()) },
+			"after_" + pb.ChaincodeMessage_PUT_PRIVATE_STATE.String():       func(e *fsm.Event) { v.afterPutState(e, v.FSM.Current()) },
+			"after_" + pb.ChaincodeMessage_DEL_PRIVATE_STATE.String():       func(e *fsm.Event) { v.afterDelState(e, v.FSM.Current()) },
 			"after_" + pb.ChaincodeMessage_INVOKE_CHAINCODE.String():        func(e *fsm.Event) { v.afterInvokeChaincode(e, v.FSM.Current()) },
 			"enter_" + establishedstate:                                     func(e *fsm.Event) { v.enterEstablishedState(e, v.FSM.Current()) },
 			"enter_" + initstate:                                            func(e *fsm.Event) { v.enterInitState(e, v.FSM.Current()) },
@@ -540,14 +571,14 @@ func (handler *Handler) beforeInitState(e *fsm.Event, state string) {
 	handler.notifyDuringStartup(true)
 }
 
-// afterGetState handles a GET_STATE request from the chaincode.
+// afterGetState handles a GET_STATE or GET_PRIVATE_STATE request from the chaincode.
 func (handler *Handler) afterGetState(e *fsm.Event, state string) {
 	msg, ok := e.Args[0].(*pb.ChaincodeMessage)
 	if !ok {
 		e.Cancel(fmt.Errorf("Received unexpected message type"))
 		return
 	}
-	chaincodeLogger.Debug("[%s]Received %s, invoking get state from ledger", shortuuid(msg.Uuid), pb.ChaincodeMessage_GET_STATE)
+	chaincodeLogger.Debug("[%s]Received %s, invoking get state from ledger", shortuuid(msg.Uuid), msg.Type)
 
 	// Query ledger for state
 	handler.handleGetState(msg)
@@ -590,15 +621,26 @@ func (handler *Handler) handleGetState(msg *pb.ChaincodeMessage) {
 		chaincodeID := handler.ChaincodeID.Name
 
 		readCommittedState := !handler.getIsTransaction(msg.Uuid)
-		res, err := ledgerObj.GetState(chaincodeID, key, readCommittedState)
+		sim := ledgerObj.GetTxSimulator(msg.Uuid)
+		var res []byte
+		var err error
+		if sim != nil && msg.Type == pb.ChaincodeMessage_GET_PRIVATE_STATE {
+			err = notSimulated(sim, msg.Type)
+		} else if sim != nil {
+			res, err = sim.GetState(chaincodeID, key)
+		} else if msg.Type == pb.ChaincodeMessage_GET_PRIVATE_STATE {
+			res, err = ledgerObj.GetPrivateState(chaincodeID, key, readCommittedState)
+		} else {
+			res, err = ledgerObj.GetState(chaincodeID, key, readCommittedState)
+		}
 		if err != nil {
 			// Send error msg back to chaincode. GetState will not trigger event
 			payload := []byte(err.Error())
 			chaincodeLogger.Error(fmt.Sprintf("[%s]Failed to get chaincode state(%s). Sending %s", shortuuid(msg.Uuid), err, pb.ChaincodeMessage_ERROR))
 			serialSendMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: payload, Uuid: msg.Uuid}
 		} else {
-			// Decrypt the data if the confidential is enabled
-			if res, err = handler.decrypt(msg.Uuid, res); err == nil {
+			// Decrypt the data if the confidential is enabled or the state encrypted
+			if res, err = handler.decryptState(msg.Uuid, res); err == nil {
 				// Send response msg back to chaincode. GetState will not trigger event
 				chaincodeLogger.Debug("[%s]Got state. Sending %s", shortuuid(msg.Uuid), pb.ChaincodeMessage_RESPONSE)
 				serialSendMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_RESPONSE, Payload: res, Uuid: msg.Uuid}
@@ -661,8 +703,6 @@ func (handler *Handler) handleRangeQueryState(msg *pb.ChaincodeMessage) {
 			return
 		}
 
-		hasNext := true
-
 		ledger, ledgerErr := ledger.GetLedger()
 		if ledgerErr != nil {
 			// Send error msg back to chaincode. GetState will not trigger event
@@ -675,7 +715,13 @@ func (handler *Handler) handleRangeQueryState(msg *pb.ChaincodeMessage) {
 		chaincodeID := handler.ChaincodeID.Name
 
 		readCommittedState := !handler.getIsTransaction(msg.Uuid)
-		rangeIter, err := ledger.GetStateRangeScanIterator(chaincodeID, rangeQueryState.StartKey, rangeQueryState.EndKey, readCommittedState)
+		var rangeIter statemgmt.RangeScanIterator
+		var err error
+		if sim := ledger.GetTxSimulator(msg.Uuid); sim != nil {
+			rangeIter, err = sim.GetStateRangeScanIterator(chaincodeID, rangeQueryState.StartKey, rangeQueryState.EndKey)
+		} else {
+			rangeIter, err = ledger.GetStateRangeScanIterator(chaincodeID, rangeQueryState.StartKey, rangeQueryState.EndKey, readCommittedState)
+		}
 		if err != nil {
 			// Send error msg back to chaincode. GetState will not trigger event
 			payload := []byte(err.Error())
@@ -684,56 +730,63 @@ func (handler *Handler) handleRangeQueryState(msg *pb.ChaincodeMessage) {
 			return
 		}
 
+		if rangeQueryState.Ordered {
+			rangeIter = statemgmt.NewSortedRangeScanIterator(rangeIter)
+		}
+
 		iterID := util.GenerateUUID()
 		txContext := handler.getTxContext(msg.Uuid)
 		handler.putRangeQueryIterator(txContext, iterID, rangeIter)
 
-		hasNext = rangeIter.Next()
-
-		var keysAndValues []*pb.RangeQueryStateKeyValue
-		var i = uint32(0)
-		for ; hasNext && i < maxRangeQueryStateLimit; i++ {
-			key, value := rangeIter.GetKeyValue()
-			// Decrypt the data if the confidential is enabled
-			decryptedValue, err := handler.decrypt(msg.Uuid, value)
-			if err != nil {
-				payload := []byte(unmarshalErr.Error())
-				chaincodeLogger.Debug("Failed decrypt value. Sending %s", pb.ChaincodeMessage_ERROR)
-				serialSendMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: payload, Uuid: msg.Uuid}
-
-				rangeIter.Close()
-				handler.deleteRangeQueryIterator(txContext, iterID)
-
-				return
-			}
-			keyAndValue := pb.RangeQueryStateKeyValue{Key: key, Value: decryptedValue}
-			keysAndValues = append(keysAndValues, &keyAndValue)
-
-			hasNext = rangeIter.Next()
-		}
-
-		if !hasNext {
-			rangeIter.Close()
-			handler.deleteRangeQueryIterator(txContext, iterID)
-		}
+		serialSendMsg = handler.getRangeQueryStatePage(msg, txContext, iterID, rangeIter.Next())
+	}()
+}
 
-		payload := &pb.RangeQueryStateResponse{KeysAndValues: keysAndValues, HasMore: hasNext, ID: iterID}
-		payloadBytes, err := proto.Marshal(payload)
+// getRangeQueryStatePage returns the message holding the next page of key/values of a range query
+// iterator, which is positioned on its next key/value if hasNext. The iterator is closed and removed
+// from the transaction context once exhausted, or on error
+func (handler *Handler) getRangeQueryStatePage(msg *pb.ChaincodeMessage, txContext *transactionContext, iterID string, hasNext bool) *pb.ChaincodeMessage {
+	rangeIter := handler.getRangeQueryIterator(txContext, iterID)
+
+	var keysAndValues []*pb.RangeQueryStateKeyValue
+	var i = uint32(0)
+	for ; hasNext && i < maxRangeQueryStateLimit; i++ {
+		key, value := rangeIter.GetKeyValue()
+		// Decrypt the data if the confidential is enabled or the state encrypted
+		decryptedValue, err := handler.decryptState(msg.Uuid, value)
 		if err != nil {
 			rangeIter.Close()
 			handler.deleteRangeQueryIterator(txContext, iterID)
 
-			// Send error msg back to chaincode. GetState will not trigger event
 			payload := []byte(err.Error())
-			chaincodeLogger.Debug("Failed marshall resopnse. Sending %s", pb.ChaincodeMessage_ERROR)
-			serialSendMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: payload, Uuid: msg.Uuid}
-			return
+			chaincodeLogger.Debug("Failed decrypt value. Sending %s", pb.ChaincodeMessage_ERROR)
+			return &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: payload, Uuid: msg.Uuid}
 		}
+		keyAndValue := pb.RangeQueryStateKeyValue{Key: key, Value: decryptedValue}
+		keysAndValues = append(keysAndValues, &keyAndValue)
 
-		chaincodeLogger.Debug("Got keys and values. Sending %s", pb.ChaincodeMessage_RESPONSE)
-		serialSendMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_RESPONSE, Payload: payloadBytes, Uuid: msg.Uuid}
+		hasNext = rangeIter.Next()
+	}
 
-	}()
+	if !hasNext {
+		rangeIter.Close()
+		handler.deleteRangeQueryIterator(txContext, iterID)
+	}
+
+	payload := &pb.RangeQueryStateResponse{KeysAndValues: keysAndValues, HasMore: hasNext, ID: iterID}
+	payloadBytes, err := proto.Marshal(payload)
+	if err != nil {
+		rangeIter.Close()
+		handler.deleteRangeQueryIterator(txContext, iterID)
+
+		// Send error msg back to chaincode. GetState will not trigger event
+		payload := []byte(err.Error())
+		chaincodeLogger.Debug("Failed marshall resopnse. Sending %s", pb.ChaincodeMessage_ERROR)
+		return &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: payload, Uuid: msg.Uuid}
+	}
+
+	chaincodeLogger.Debug("Got keys and values. Sending %s", pb.ChaincodeMessage_RESPONSE)
+	return &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_RESPONSE, Payload: payloadBytes, Uuid: msg.Uuid}
 }
 
 // afterRangeQueryState handles a RANGE_QUERY_STATE_NEXT request from the chaincode.
@@ -791,50 +844,173 @@ func (handler *Handler) handleRangeQueryStateNext(msg *pb.ChaincodeMessage) {
 			return
 		}
 
-		var keysAndValues []*pb.RangeQueryStateKeyValue
-		var i = uint32(0)
-		hasNext := true
-		for ; hasNext && i < maxRangeQueryStateLimit; i++ {
-			key, value := rangeIter.GetKeyValue()
-			// Decrypt the data if the confidential is enabled
-			decryptedValue, err := handler.decrypt(msg.Uuid, value)
-			if err != nil {
-				payload := []byte(unmarshalErr.Error())
-				chaincodeLogger.Debug("Failed decrypt value. Sending %s", pb.ChaincodeMessage_ERROR)
-				serialSendMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: payload, Uuid: msg.Uuid}
+		serialSendMsg = handler.getRangeQueryStatePage(msg, txContext, rangeQueryStateNext.ID, true)
+	}()
+}
+
+// afterGetQueryResult handles a GET_QUERY_RESULT request from the chaincode.
+func (handler *Handler) afterGetQueryResult(e *fsm.Event, state string) {
+	msg, ok := e.Args[0].(*pb.ChaincodeMessage)
+	if !ok {
+		e.Cancel(fmt.Errorf("Received unexpected message type"))
+		return
+	}
+	chaincodeLogger.Debug("Received %s, invoking query of the ledger", pb.ChaincodeMessage_GET_QUERY_RESULT)
 
-				rangeIter.Close()
-				handler.deleteRangeQueryIterator(txContext, rangeQueryStateNext.ID)
+	// Query ledger for state
+	handler.handleGetQueryResult(msg)
+	chaincodeLogger.Debug("Exiting GET_QUERY_RESULT")
+}
 
-				return
+// Handles a rich query of the ledger. The results are paged like the ones of a range query
+func (handler *Handler) handleGetQueryResult(msg *pb.ChaincodeMessage) {
+	// The defer followed by triggering a go routine dance is needed to ensure that the previous state transition
+	// is completed before the next one is triggered. The previous state transition is deemed complete only when
+	// the afterGetQueryResult function is exited.
+	go func() {
+		// Check if this is the unique state request from this chaincode uuid
+		uniqueReq := handler.createUUIDEntry(msg.Uuid)
+		if !uniqueReq {
+			// Drop this request
+			chaincodeLogger.Debug("Another state request pending for this Uuid. Cannot process.")
+			return
+		}
+
+		var serialSendMsg *pb.ChaincodeMessage
+
+		defer func() {
+			handler.deleteUUIDEntry(msg.Uuid)
+			chaincodeLogger.Debug("[%s]handleGetQueryResult serial send %s", shortuuid(serialSendMsg.Uuid), serialSendMsg.Type)
+			handler.serialSend(serialSendMsg)
+		}()
+
+		getQueryResult := &pb.GetQueryResult{}
+		unmarshalErr := proto.Unmarshal(msg.Payload, getQueryResult)
+		if unmarshalErr != nil {
+			payload := []byte(unmarshalErr.Error())
+			chaincodeLogger.Debug("Failed to unmarshall query request. Sending %s", pb.ChaincodeMessage_ERROR)
+			serialSendMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: payload, Uuid: msg.Uuid}
+			return
+		}
+
+		// the values of an encrypted state can not be matched by a query
+		if enc, err := handler.getStateEncryptor(); err != nil || enc != nil {
+			if err == nil {
+				err = fmt.Errorf("The state of chaincode %s is encrypted, it can not be queried", handler.ChaincodeID.Name)
 			}
-			keyAndValue := pb.RangeQueryStateKeyValue{Key: key, Value: decryptedValue}
-			keysAndValues = append(keysAndValues, &keyAndValue)
+			chaincodeLogger.Debug("Failed to query the ledger. Sending %s", pb.ChaincodeMessage_ERROR)
+			serialSendMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: []byte(err.Error()), Uuid: msg.Uuid}
+			return
+		}
+
+		ledger, ledgerErr := ledger.GetLedger()
+		if ledgerErr != nil {
+			payload := []byte(ledgerErr.Error())
+			chaincodeLogger.Debug("Failed to get ledger. Sending %s", pb.ChaincodeMessage_ERROR)
+			serialSendMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: payload, Uuid: msg.Uuid}
+			return
+		}
 
-			hasNext = rangeIter.Next()
+		queryIter, err := ledger.GetStateQueryIterator(handler.ChaincodeID.Name, getQueryResult.Query)
+		if err != nil {
+			payload := []byte(err.Error())
+			chaincodeLogger.Debug("Failed to query the ledger. Sending %s", pb.ChaincodeMessage_ERROR)
+			serialSendMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: payload, Uuid: msg.Uuid}
+			return
 		}
 
-		if !hasNext {
-			rangeIter.Close()
-			handler.deleteRangeQueryIterator(txContext, rangeQueryStateNext.ID)
+		iterID := util.GenerateUUID()
+		txContext := handler.getTxContext(msg.Uuid)
+		handler.putRangeQueryIterator(txContext, iterID, queryIter)
+
+		serialSendMsg = handler.getRangeQueryStatePage(msg, txContext, iterID, queryIter.Next())
+	}()
+}
+
+// afterGetHistoryForKey handles a GET_HISTORY_FOR_KEY request from the chaincode.
+func (handler *Handler) afterGetHistoryForKey(e *fsm.Event, state string) {
+	msg, ok := e.Args[0].(*pb.ChaincodeMessage)
+	if !ok {
+		e.Cancel(fmt.Errorf("Received unexpected message type"))
+		return
+	}
+	chaincodeLogger.Debug("Received %s, invoking get history from ledger", pb.ChaincodeMessage_GET_HISTORY_FOR_KEY)
+
+	// Query ledger for history
+	handler.handleGetHistoryForKey(msg)
+	chaincodeLogger.Debug("Exiting GET_HISTORY_FOR_KEY")
+}
+
+// Handles query to ledger for the history of a key
+func (handler *Handler) handleGetHistoryForKey(msg *pb.ChaincodeMessage) {
+	// The defer followed by triggering a go routine dance is needed to ensure that the previous state transition
+	// is completed before the next one is triggered. The previous state transition is deemed complete only when
+	// the afterGetHistoryForKey function is exited.
+	go func() {
+		// Check if this is the unique state request from this chaincode uuid
+		uniqueReq := handler.createUUIDEntry(msg.Uuid)
+		if !uniqueReq {
+			// Drop this request
+			chaincodeLogger.Debug("Another state request pending for this Uuid. Cannot process.")
+			return
 		}
 
-		payload := &pb.RangeQueryStateResponse{KeysAndValues: keysAndValues, HasMore: hasNext, ID: rangeQueryStateNext.ID}
-		payloadBytes, err := proto.Marshal(payload)
+		var serialSendMsg *pb.ChaincodeMessage
+
+		defer func() {
+			handler.deleteUUIDEntry(msg.Uuid)
+			chaincodeLogger.Debug("[%s]handleGetHistoryForKey serial send %s", shortuuid(serialSendMsg.Uuid), serialSendMsg.Type)
+			handler.serialSend(serialSendMsg)
+		}()
+
+		getHistoryForKey := &pb.GetHistoryForKey{}
+		unmarshalErr := proto.Unmarshal(msg.Payload, getHistoryForKey)
+		if unmarshalErr != nil {
+			payload := []byte(unmarshalErr.Error())
+			chaincodeLogger.Debug("Failed to unmarshall history request. Sending %s", pb.ChaincodeMessage_ERROR)
+			serialSendMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: payload, Uuid: msg.Uuid}
+			return
+		}
+
+		ledger, ledgerErr := ledger.GetLedger()
+		if ledgerErr != nil {
+			payload := []byte(ledgerErr.Error())
+			chaincodeLogger.Debug("Failed to get ledger. Sending %s", pb.ChaincodeMessage_ERROR)
+			serialSendMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: payload, Uuid: msg.Uuid}
+			return
+		}
+
+		modifications, err := ledger.GetHistoryForKey(handler.ChaincodeID.Name, getHistoryForKey.Key)
 		if err != nil {
-			rangeIter.Close()
-			handler.deleteRangeQueryIterator(txContext, rangeQueryStateNext.ID)
+			payload := []byte(err.Error())
+			chaincodeLogger.Debug("Failed to get the history of the key. Sending %s", pb.ChaincodeMessage_ERROR)
+			serialSendMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: payload, Uuid: msg.Uuid}
+			return
+		}
 
-			// Send error msg back to chaincode. GetState will not trigger event
+		for _, modification := range modifications {
+			if modification.IsDelete {
+				continue
+			}
+			// Decrypt the data if the confidential is enabled or the state encrypted
+			if modification.Value, err = handler.decryptState(msg.Uuid, modification.Value); err != nil {
+				payload := []byte(err.Error())
+				chaincodeLogger.Debug("Failed decrypt value. Sending %s", pb.ChaincodeMessage_ERROR)
+				serialSendMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: payload, Uuid: msg.Uuid}
+				return
+			}
+		}
+
+		payloadBytes, err := proto.Marshal(&pb.GetHistoryForKeyResponse{Modifications: modifications})
+		if err != nil {
 			payload := []byte(err.Error())
-			chaincodeLogger.Debug("Failed marshall resopnse. Sending %s", pb.ChaincodeMessage_ERROR)
+			chaincodeLogger.Debug("Failed marshall response. Sending %s", pb.ChaincodeMessage_ERROR)
 			serialSendMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: payload, Uuid: msg.Uuid}
 			return
 		}
 
-		chaincodeLogger.Debug("Got keys and values. Sending %s", pb.ChaincodeMessage_RESPONSE)
+		chaincodeLogger.Debug("Got history. Sending %s", pb.ChaincodeMessage_RESPONSE)
 		serialSendMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_RESPONSE, Payload: payloadBytes, Uuid: msg.Uuid}
-
 	}()
 }
 
@@ -907,26 +1083,26 @@ func (handler *Handler) handleRangeQueryStateClose(msg *pb.ChaincodeMessage) {
 	}()
 }
 
-// afterPutState handles a PUT_STATE request from the chaincode.
+// afterPutState handles a PUT_STATE or PUT_PRIVATE_STATE request from the chaincode.
 func (handler *Handler) afterPutState(e *fsm.Event, state string) {
-	_, ok := e.Args[0].(*pb.ChaincodeMessage)
+	msg, ok := e.Args[0].(*pb.ChaincodeMessage)
 	if !ok {
 		e.Cancel(fmt.Errorf("Received unexpected message type"))
 		return
 	}
-	chaincodeLogger.Debug("Received %s in state %s, invoking put state to ledger", pb.ChaincodeMessage_PUT_STATE, state)
+	chaincodeLogger.Debug("Received %s in state %s, invoking put state to ledger", msg.Type, state)
 
 	// Put state into ledger handled within enterBusyState
 }
 
-// afterDelState handles a DEL_STATE request from the chaincode.
+// afterDelState handles a DEL_STATE or DEL_PRIVATE_STATE request from the chaincode.
 func (handler *Handler) afterDelState(e *fsm.Event, state string) {
-	_, ok := e.Args[0].(*pb.ChaincodeMessage)
+	msg, ok := e.Args[0].(*pb.ChaincodeMessage)
 	if !ok {
 		e.Cancel(fmt.Errorf("Received unexpected message type"))
 		return
 	}
-	chaincodeLogger.Debug("Received %s, invoking delete state from ledger", pb.ChaincodeMessage_DEL_STATE)
+	chaincodeLogger.Debug("Received %s, invoking delete state from ledger", msg.Type)
 
 	// Delete state from ledger handled within enterBusyState
 }
@@ -983,10 +1159,11 @@ func (handler *Handler) enterBusyState(e *fsm.Event, state string) {
 		}
 
 		chaincodeID := handler.ChaincodeID.Name
+		sim := ledgerObj.GetTxSimulator(msg.Uuid)
 		var err error
 		var res []byte
 
-		if msg.Type.String() == pb.ChaincodeMessage_PUT_STATE.String() {
+		if msg.Type.String() == pb.ChaincodeMessage_PUT_STATE.String() || msg.Type.String() == pb.ChaincodeMessage_PUT_PRIVATE_STATE.String() {
 			putStateInfo := &pb.PutStateInfo{}
 			unmarshalErr := proto.Unmarshal(msg.Payload, putStateInfo)
 			if unmarshalErr != nil {
@@ -997,15 +1174,50 @@ func (handler *Handler) enterBusyState(e *fsm.Event, state string) {
 			}
 
 			var pVal []byte
-			// Encrypt the data if the confidential is enabled
-			if pVal, err = handler.encrypt(msg.Uuid, putStateInfo.Value); err == nil {
+			// Encrypt the data if the confidential is enabled or the state encrypted
+			if pVal, err = handler.encryptState(msg.Uuid, putStateInfo.Value); err == nil {
 				// Invoke ledger to put state
-				err = ledgerObj.SetState(chaincodeID, putStateInfo.Key, pVal)
+				if sim != nil && msg.Type == pb.ChaincodeMessage_PUT_PRIVATE_STATE {
+					err = notSimulated(sim, msg.Type)
+				} else if sim != nil {
+					err = sim.SetState(chaincodeID, putStateInfo.Key, pVal)
+				} else if msg.Type == pb.ChaincodeMessage_PUT_PRIVATE_STATE {
+					err = ledgerObj.SetPrivateState(chaincodeID, putStateInfo.Key, pVal)
+				} else {
+					err = ledgerObj.SetState(chaincodeID, putStateInfo.Key, pVal)
+				}
 			}
 		} else if msg.Type.String() == pb.ChaincodeMessage_DEL_STATE.String() {
 			// Invoke ledger to delete state
 			key := string(msg.Payload)
-			err = ledgerObj.DeleteState(chaincodeID, key)
+			if sim != nil {
+				err = sim.DeleteState(chaincodeID, key)
+			} else {
+				err = ledgerObj.DeleteState(chaincodeID, key)
+			}
+		} else if sim != nil && (msg.Type == pb.ChaincodeMessage_DEL_PRIVATE_STATE || msg.Type == pb.ChaincodeMessage_INVOKE_CHAINCODE ||
+			msg.Type == pb.ChaincodeMessage_MARK_WRITE_ONCE) {
+			// the private state, the chaincodes called and the write-once marks are not captured by a simulation
+			err = notSimulated(sim, msg.Type)
+		} else if msg.Type.String() == pb.ChaincodeMessage_DEL_PRIVATE_STATE.String() {
+			// Invoke ledger to delete private state
+			key := string(msg.Payload)
+			err = ledgerObj.DeletePrivateState(chaincodeID, key)
+		} else if msg.Type.String() == pb.ChaincodeMessage_MARK_WRITE_ONCE.String() {
+			markWriteOnce := &pb.MarkWriteOnce{}
+			unmarshalErr := proto.Unmarshal(msg.Payload, markWriteOnce)
+			if unmarshalErr != nil {
+				payload := []byte(unmarshalErr.Error())
+				chaincodeLogger.Debug("[%s]Unable to decipher payload. Sending %s", shortuuid(msg.Uuid), pb.ChaincodeMessage_ERROR)
+				triggerNextStateMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: payload, Uuid: msg.Uuid}
+				return
+			}
+			// Invoke ledger to mark the key, or the keys with the prefix, as write-once
+			if markWriteOnce.Prefix {
+				err = ledgerObj.MarkWriteOncePrefix(chaincodeID, markWriteOnce.Key)
+			} else {
+				err = ledgerObj.MarkWriteOnce(chaincodeID, markWriteOnce.Key)
+			}
 		} else if msg.Type.String() == pb.ChaincodeMessage_INVOKE_CHAINCODE.String() {
 			//check and prohibit C-call-C for CONFIDENTIAL txs
 			if triggerNextStateMsg = handler.canCallChaincode(msg.Uuid); triggerNextStateMsg != nil {
@@ -1036,8 +1248,7 @@ func (handler *Handler) enterBusyState(e *fsm.Event, state string) {
 				return
 			}
 
-			// TODO: Need to handle timeout correctly
-			timeout := time.Duration(30000) * time.Millisecond
+			timeout := handler.chaincodeSupport.GetExecuteTimeout(newChaincodeID)
 
 			ccMsg, _ := createTransactionMessage(transaction.Uuid, chaincodeInput)
 
@@ -1069,6 +1280,13 @@ func (handler *Handler) enterBusyState(e *fsm.Event, state string) {
 	}()
 }
 
+// notSimulated marks the simulation of a transaction as unsupported because the chaincode made
+// a request that a simulation does not capture. The transaction is executed again, serially
+func notSimulated(sim *ledger.TxSimulator, msgType pb.ChaincodeMessage_Type) error {
+	sim.SetUnsupported(msgType.String())
+	return fmt.Errorf("%s is not supported in a simulated transaction", msgType)
+}
+
 func (handler *Handler) enterEstablishedState(e *fsm.Event, state string) {
 	handler.notifyDuringStartup(true)
 }
@@ -1202,6 +1420,7 @@ func (handler *Handler) setChaincodeSecurityContext(tx *pb.Transaction, msg *pb.
 			}
 
 			msg.SecurityContext.Payload = ctorMsgRaw
+			msg.SecurityContext.Transient = tx.Transient
 		}
 		msg.SecurityContext.TxTimestamp = tx.Timestamp
 	}
@@ -1304,8 +1523,7 @@ func (handler *Handler) handleQueryChaincode(msg *pb.ChaincodeMessage) {
 			return
 		}
 
-		// TODO: Need to handle timeout correctly
-		timeout := time.Duration(30000) * time.Millisecond
+		timeout := handler.chaincodeSupport.GetExecuteTimeout(newChaincodeID)
 
 		ccMsg, _ := createQueryMessage(transaction.Uuid, chaincodeInput)
 
@@ -1365,7 +1583,9 @@ func (handler *Handler) HandleMessage(msg *pb.ChaincodeMessage) error {
 	}
 	if handler.FSM.Cannot(msg.Type.String()) {
 		// Check if this is a request from validator in query context
-		if msg.Type.String() == pb.ChaincodeMessage_PUT_STATE.String() || msg.Type.String() == pb.ChaincodeMessage_DEL_STATE.String() || msg.Type.String() == pb.ChaincodeMessage_INVOKE_CHAINCODE.String() {
+		if msg.Type.String() == pb.ChaincodeMessage_PUT_STATE.String() || msg.Type.String() == pb.ChaincodeMessage_DEL_STATE.String() ||
+			msg.Type.String() == pb.ChaincodeMessage_PUT_PRIVATE_STATE.String() || msg.Type.String() == pb.ChaincodeMessage_DEL_PRIVATE_STATE.String() ||
+			msg.Type.String() == pb.ChaincodeMessage_MARK_WRITE_ONCE.String() || msg.Type.String() == pb.ChaincodeMessage_INVOKE_CHAINCODE.String() {
 			// Check if this UUID is a transaction
 			if !handler.getIsTransaction(msg.Uuid) {
 				payload := []byte(fmt.Sprintf("[%s]Cannot handle %s in query context", msg.Uuid, msg.Type.String()))
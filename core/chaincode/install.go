@@ -0,0 +1,96 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chaincode
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/golang/protobuf/proto"
+	pb "github.com/hyperledger/fabric/protos"
+	"github.com/spf13/viper"
+)
+
+//getInstallPath returns the directory in which the chaincode packages are installed on the peer
+func getInstallPath() (string, error) {
+	path := filepath.Join(viper.GetString("peer.fileSystemPath"), "chaincodes")
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return "", fmt.Errorf("Error creating the chaincode install directory [%s]: %s", path, err)
+	}
+	return path, nil
+}
+
+// InstallChaincode stores the deployment spec, with its code package, on the peer under the
+// name of the chaincode (the hash of its code) so that a deploy or upgrade transaction that
+// does not carry the code package can instantiate it. Installing is local to the peer: it
+// does not go through consensus and does not change the ledger.
+func InstallChaincode(cds *pb.ChaincodeDeploymentSpec) error {
+	name := cds.ChaincodeSpec.ChaincodeID.Name
+	if name == "" || len(cds.CodePackage) == 0 {
+		return fmt.Errorf("chaincode name and code package required for install")
+	}
+	path, err := getInstallPath()
+	if err != nil {
+		return err
+	}
+	data, err := proto.Marshal(cds)
+	if err != nil {
+		return err
+	}
+	tmpFile, err := ioutil.TempFile(path, ".tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmpFile.Write(data)
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpFile.Name(), filepath.Join(path, name))
+	}
+	if err != nil {
+		os.Remove(tmpFile.Name())
+		return fmt.Errorf("Error installing chaincode %s: %s", name, err)
+	}
+	chaincodeLogger.Info("Installed chaincode %s", name)
+	return nil
+}
+
+// GetInstalledChaincode returns the deployment spec of the chaincode installed on the peer
+// under the given name, nil if there is none
+func GetInstalledChaincode(name string) (*pb.ChaincodeDeploymentSpec, error) {
+	if name == "" || filepath.Base(name) != name {
+		return nil, fmt.Errorf("invalid chaincode name [%s]", name)
+	}
+	path, err := getInstallPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(filepath.Join(path, name))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	cds := &pb.ChaincodeDeploymentSpec{}
+	if err = proto.Unmarshal(data, cds); err != nil {
+		return nil, fmt.Errorf("Error reading installed chaincode %s: %s", name, err)
+	}
+	return cds, nil
+}
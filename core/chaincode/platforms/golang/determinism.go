@@ -0,0 +1,224 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package golang
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// Values of chaincode.golang.determinismCheck
+const (
+	DeterminismCheckOff     = "off"
+	DeterminismCheckWarn    = "warn"
+	DeterminismCheckEnforce = "enforce"
+)
+
+// DeterminismIssue is a construct of the chaincode which may not behave the
+// same on every validating peer, hence make the peers disagree on the state hash
+type DeterminismIssue struct {
+	Position  token.Position
+	Construct string
+}
+
+func (issue *DeterminismIssue) String() string {
+	return fmt.Sprintf("%s: %s", issue.Position, issue.Construct)
+}
+
+// math/rand functions which do not use the global, randomly seeded, source
+var deterministicRandFuncs = map[string]bool{"New": true, "NewSource": true, "NewZipf": true}
+
+// CheckDeterminism parses the Go files of the chaincode under dir, including
+// its sub-packages but not the vendored packages nor the tests, and returns
+// the nondeterministic constructs found: calls to time.Now, time.Since,
+// crypto/rand and the global source of math/rand, goroutines and iterations
+// over maps. The check is syntactic, hence a map is only recognized as such
+// when it is declared with its type or created by make or a literal.
+func CheckDeterminism(dir string) ([]*DeterminismIssue, error) {
+	var issues []*DeterminismIssue
+	fset := token.NewFileSet()
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if path != dir && (info.Name() == "vendor" || strings.HasPrefix(info.Name(), ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+			return nil
+		}
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		fileIssues, err := checkFileDeterminism(fset, path, src)
+		if err != nil {
+			return err
+		}
+		issues = append(issues, fileIssues...)
+		return nil
+	})
+	return issues, err
+}
+
+func checkFileDeterminism(fset *token.FileSet, filename string, src []byte) ([]*DeterminismIssue, error) {
+	file, err := parser.ParseFile(fset, filename, src, 0)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing %s: %s", filename, err)
+	}
+
+	// names under which the packages of interest are imported
+	imports := make(map[string]string)
+	for _, spec := range file.Imports {
+		path, _ := strconv.Unquote(spec.Path.Value)
+		name := path[strings.LastIndex(path, "/")+1:]
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		imports[name] = path
+	}
+
+	maps := collectMapNames(file)
+
+	var issues []*DeterminismIssue
+	report := func(node ast.Node, construct string) {
+		issues = append(issues, &DeterminismIssue{fset.Position(node.Pos()), construct})
+	}
+	ast.Inspect(file, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.GoStmt:
+			report(node, "goroutine")
+		case *ast.RangeStmt:
+			if isMapExpr(node.X, maps) {
+				report(node, "iteration over a map, whose order is random")
+			}
+		case *ast.CallExpr:
+			selector, ok := node.Fun.(*ast.SelectorExpr)
+			if !ok {
+				return true
+			}
+			pkg, ok := selector.X.(*ast.Ident)
+			if !ok || pkg.Obj != nil {
+				// not a package name
+				return true
+			}
+			switch imports[pkg.Name] {
+			case "time":
+				if selector.Sel.Name == "Now" || selector.Sel.Name == "Since" {
+					report(node, fmt.Sprintf("call to time.%s, use the transaction timestamp instead", selector.Sel.Name))
+				}
+			case "math/rand":
+				if !deterministicRandFuncs[selector.Sel.Name] {
+					report(node, fmt.Sprintf("call to math/rand.%s, use the transaction's GetTxRand instead", selector.Sel.Name))
+				}
+			case "crypto/rand":
+				report(node, fmt.Sprintf("call to crypto/rand.%s", selector.Sel.Name))
+			}
+		}
+		return true
+	})
+	return issues, nil
+}
+
+// collectMapNames returns the names of the variables, parameters and struct
+// fields of the file which are declared with a map type or assigned a new map
+func collectMapNames(file *ast.File) map[string]bool {
+	maps := make(map[string]bool)
+	ast.Inspect(file, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.Field:
+			if _, ok := node.Type.(*ast.MapType); ok {
+				for _, name := range node.Names {
+					maps[name.Name] = true
+				}
+			}
+		case *ast.ValueSpec:
+			_, isMapType := node.Type.(*ast.MapType)
+			for i, name := range node.Names {
+				if isMapType || (i < len(node.Values) && isMapExpr(node.Values[i], nil)) {
+					maps[name.Name] = true
+				}
+			}
+		case *ast.AssignStmt:
+			if len(node.Lhs) != len(node.Rhs) {
+				return true
+			}
+			for i, lhs := range node.Lhs {
+				if ident, ok := lhs.(*ast.Ident); ok && isMapExpr(node.Rhs[i], nil) {
+					maps[ident.Name] = true
+				}
+			}
+		}
+		return true
+	})
+	return maps
+}
+
+func isMapExpr(expr ast.Expr, maps map[string]bool) bool {
+	switch expr := expr.(type) {
+	case *ast.Ident:
+		return maps[expr.Name]
+	case *ast.SelectorExpr:
+		return maps[expr.Sel.Name]
+	case *ast.ParenExpr:
+		return isMapExpr(expr.X, maps)
+	case *ast.CompositeLit:
+		_, ok := expr.Type.(*ast.MapType)
+		return ok
+	case *ast.CallExpr:
+		if fun, ok := expr.Fun.(*ast.Ident); ok && fun.Name == "make" && len(expr.Args) > 0 {
+			_, ok := expr.Args[0].(*ast.MapType)
+			return ok
+		}
+	}
+	return false
+}
+
+// checkChaincodeDeterminism runs CheckDeterminism on the chaincode under dir
+// as configured by chaincode.golang.determinismCheck
+func checkChaincodeDeterminism(dir string) error {
+	mode := viper.GetString("chaincode.golang.determinismCheck")
+	if mode == "" || mode == DeterminismCheckOff {
+		return nil
+	}
+	issues, err := CheckDeterminism(dir)
+	if err != nil {
+		return err
+	}
+	if len(issues) == 0 {
+		return nil
+	}
+	for _, issue := range issues {
+		logger.Warning("Nondeterministic chaincode construct at %s", issue)
+	}
+	if mode == DeterminismCheckEnforce {
+		return fmt.Errorf("Chaincode %s has %d nondeterministic constructs, the first one at %s", dir, len(issues), issues[0])
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package golang
+
+import (
+	"go/token"
+	"strings"
+	"testing"
+)
+
+const nondeterministicChaincode = `package main
+
+import (
+	"math/rand"
+	t "time"
+)
+
+type state struct {
+	balances map[string]int
+}
+
+func invoke(s *state, keys []string) {
+	now := t.Now()
+	_ = now
+	counts := make(map[string]int)
+	for k := range counts {
+		_ = k
+	}
+	for _, k := range keys {
+		_ = k
+	}
+	for k := range s.balances {
+		_ = k
+	}
+	go invoke(s, nil)
+	_ = rand.Intn(10)
+	_ = rand.New(rand.NewSource(1))
+}
+`
+
+func TestCheckDeterminism(t *testing.T) {
+	issues, err := checkFileDeterminism(token.NewFileSet(), "chaincode.go", []byte(nondeterministicChaincode))
+	if err != nil {
+		t.Fatalf("Error checking chaincode: %s", err)
+	}
+	expected := []struct {
+		line      int
+		construct string
+	}{
+		{13, "call to time.Now"},
+		{16, "iteration over a map"},
+		{22, "iteration over a map"},
+		{25, "goroutine"},
+		{26, "call to math/rand.Intn"},
+	}
+	if len(issues) != len(expected) {
+		t.Fatalf("Expected %d issues, found %v", len(expected), issues)
+	}
+	for i, issue := range issues {
+		if issue.Position.Line != expected[i].line || !strings.HasPrefix(issue.Construct, expected[i].construct) {
+			t.Fatalf("Expected issue %q at line %d, found %s", expected[i].construct, expected[i].line, issue)
+		}
+	}
+}
@@ -254,6 +254,10 @@ func generateHashcode(spec *pb.ChaincodeSpec, tw *tar.Writer) (string, error) {
 		return "", fmt.Errorf("code does not exist %s", err)
 	}
 
+	if err = checkChaincodeDeterminism(tmppath); err != nil {
+		return "", err
+	}
+
 	hash := util.GenerateHashFromSignature(actualcodepath, ctor.Function, ctor.Args)
 
 	hash, err = hashFilesInDir(filepath.Join(codegopath, "src"), actualcodepath, hash, tw)
@@ -0,0 +1,58 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package java
+
+import (
+	"archive/tar"
+	"fmt"
+
+	"github.com/spf13/viper"
+
+	cutil "github.com/hyperledger/fabric/core/container/util"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// WritePackage writes the Java chaincode package. The shim is installed in the
+// local Maven repository of the image, against which the Gradle project of the
+// chaincode builds build/libs/chaincode.jar, a jar with its dependencies
+func (javaPlatform *Platform) WritePackage(spec *pb.ChaincodeSpec, tw *tar.Writer) error {
+
+	var err error
+	spec.ChaincodeID.Name, err = generateHashcode(spec, tw)
+	if err != nil {
+		return err
+	}
+
+	var buf []string
+
+	//let the jar's name be chaincode ID's name
+	buf = append(buf, viper.GetString("chaincode.java.Dockerfile"))
+	buf = append(buf, "COPY shim /root/shim")
+	buf = append(buf, "RUN cd /root/shim && gradle -q install")
+	buf = append(buf, "COPY chaincode /root/chaincode")
+	buf = append(buf, fmt.Sprintf("RUN cd /root/chaincode && gradle -q build && cp build/libs/chaincode.jar $GOPATH/bin/%s.jar && rm -rf /root/chaincode", spec.ChaincodeID.Name))
+
+	if err = cutil.WriteDockerfileToPackage(buf, tw); err != nil {
+		return err
+	}
+
+	if err = cutil.WriteShimToPackage("java", excludedDirs, []string{"protos/chaincode.proto"}, tw); err != nil {
+		return fmt.Errorf("Error writing the Java shim to the package: %s", err)
+	}
+
+	return nil
+}
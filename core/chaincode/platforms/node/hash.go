@@ -0,0 +1,53 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package node
+
+import (
+	"archive/tar"
+	"encoding/hex"
+	"fmt"
+
+	cutil "github.com/hyperledger/fabric/core/container/util"
+	"github.com/hyperledger/fabric/core/util"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+//excludedDirs are the dependencies installed locally for the chaincode, which
+//are installed again when the image is built
+var excludedDirs = []string{"node_modules"}
+
+//generateHashcode gets the hashcode of the npm package under the path of
+//the chaincode and writes it to the package
+func generateHashcode(spec *pb.ChaincodeSpec, tw *tar.Writer) (string, error) {
+	if spec == nil || spec.ChaincodeID == nil || spec.ChaincodeID.Path == "" {
+		return "", fmt.Errorf("Cannot generate hashcode from empty chaincode path")
+	}
+
+	ctor := spec.CtorMsg
+	if ctor == nil || ctor.Function == "" {
+		return "", fmt.Errorf("Cannot generate hashcode from empty ctor")
+	}
+
+	path := spec.ChaincodeID.Path
+	hash := util.GenerateHashFromSignature(path, ctor.Function, ctor.Args)
+	hash, err := cutil.WriteHashedFolderToPackage(path, "chaincode", excludedDirs, hash, tw)
+	if err != nil {
+		return "", fmt.Errorf("Could not get hashcode for %s - %s", path, err)
+	}
+
+	return hex.EncodeToString(hash[:]), nil
+}
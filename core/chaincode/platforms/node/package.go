@@ -0,0 +1,56 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package node
+
+import (
+	"archive/tar"
+	"fmt"
+
+	"github.com/spf13/viper"
+
+	cutil "github.com/hyperledger/fabric/core/container/util"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// WritePackage writes the Node.js chaincode package. The npm package of the
+// chaincode is installed, with the shim as its fabric-shim dependency, in a
+// directory named by the chaincode ID's name which the peer runs with node
+func (nodePlatform *Platform) WritePackage(spec *pb.ChaincodeSpec, tw *tar.Writer) error {
+
+	var err error
+	spec.ChaincodeID.Name, err = generateHashcode(spec, tw)
+	if err != nil {
+		return err
+	}
+
+	var buf []string
+
+	buf = append(buf, viper.GetString("chaincode.node.Dockerfile"))
+	buf = append(buf, "COPY shim /root/shim")
+	buf = append(buf, fmt.Sprintf("COPY chaincode $GOPATH/bin/%s", spec.ChaincodeID.Name))
+	buf = append(buf, fmt.Sprintf("RUN cd $GOPATH/bin/%s && npm install --production /root/shim && npm install --production", spec.ChaincodeID.Name))
+
+	if err = cutil.WriteDockerfileToPackage(buf, tw); err != nil {
+		return err
+	}
+
+	if err = cutil.WriteShimToPackage("node", excludedDirs, []string{"protos/chaincode.proto", "sdk/node/lib/protos/google/protobuf/timestamp.proto"}, tw); err != nil {
+		return fmt.Errorf("Error writing the Node.js shim to the package: %s", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,43 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package node
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// Platform for chaincodes written in JavaScript for Node.js
+type Platform struct {
+}
+
+// ValidateSpec validates Node.js chaincodes. The path of the chaincode is a
+// local directory holding the npm package of the chaincode
+func (nodePlatform *Platform) ValidateSpec(spec *pb.ChaincodeSpec) error {
+	path := spec.ChaincodeID.Path
+	fi, err := os.Stat(path)
+	if err != nil || !fi.IsDir() {
+		return fmt.Errorf("Path to chaincode is not a directory: %s", path)
+	}
+	if _, err = os.Stat(filepath.Join(path, "package.json")); err != nil {
+		return fmt.Errorf("Path to chaincode has no package.json: %s", path)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package node
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+func writeChaincodeDir(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "nodecc")
+	if err != nil {
+		t.Fatalf("Error creating temp dir: %s", err)
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err = ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Error writing %s: %s", name, err)
+		}
+	}
+	return dir
+}
+
+func TestValidateSpec(t *testing.T) {
+	dir := writeChaincodeDir(t, map[string]string{"map.js": "//map"})
+	defer os.RemoveAll(dir)
+
+	platform := &Platform{}
+	spec := &pb.ChaincodeSpec{Type: pb.ChaincodeSpec_NODE, ChaincodeID: &pb.ChaincodeID{Path: dir}}
+	if err := platform.ValidateSpec(spec); err == nil {
+		t.Fatalf("Expected a chaincode without package.json to be refused")
+	}
+
+	ioutil.WriteFile(filepath.Join(dir, "package.json"), []byte(`{"name": "map"}`), 0644)
+	if err := platform.ValidateSpec(spec); err != nil {
+		t.Fatalf("Error validating chaincode spec: %s", err)
+	}
+
+	spec.ChaincodeID.Path = filepath.Join(dir, "missing")
+	if err := platform.ValidateSpec(spec); err == nil {
+		t.Fatalf("Expected a missing chaincode path to be refused")
+	}
+}
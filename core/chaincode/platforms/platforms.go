@@ -22,6 +22,8 @@ import (
 
 	"github.com/hyperledger/fabric/core/chaincode/platforms/car"
 	"github.com/hyperledger/fabric/core/chaincode/platforms/golang"
+	"github.com/hyperledger/fabric/core/chaincode/platforms/java"
+	"github.com/hyperledger/fabric/core/chaincode/platforms/node"
 	pb "github.com/hyperledger/fabric/protos"
 )
 
@@ -40,6 +42,10 @@ func Find(chaincodeType pb.ChaincodeSpec_Type) (Platform, error) {
 		return &golang.Platform{}, nil
 	case pb.ChaincodeSpec_CAR:
 		return &car.Platform{}, nil
+	case pb.ChaincodeSpec_JAVA:
+		return &java.Platform{}, nil
+	case pb.ChaincodeSpec_NODE:
+		return &node.Platform{}, nil
 	default:
 		return nil, fmt.Errorf("Unknown chaincodeType: %s", chaincodeType)
 	}
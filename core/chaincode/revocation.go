@@ -0,0 +1,85 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chaincode
+
+import (
+	"crypto/x509/pkix"
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/hyperledger/fabric/core/crypto/utils"
+	"github.com/hyperledger/fabric/core/ledger"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// executeConfig applies the configuration update of the CONFIG transaction t: it records the
+// certificate revocation list it carries as the one of its CA. When security is enabled the list
+// has to be signed by the ECA or the TCA, which is what authorizes the update. A list can't be
+// replaced by an older one, which would reinstate the certificates revoked since
+func executeConfig(chain *ChaincodeSupport, lgr *ledger.Ledger, t *pb.Transaction) error {
+	update := &pb.ConfigUpdate{}
+	if err := proto.Unmarshal(t.Payload, update); err != nil {
+		return fmt.Errorf("Failed to unmarshal config update(%s)", err)
+	}
+	if len(update.RevocationList) == 0 {
+		return fmt.Errorf("Config transaction %s updates nothing", t.Uuid)
+	}
+	crl, err := utils.DERToCRL(update.RevocationList)
+	if err != nil {
+		return fmt.Errorf("Invalid revocation list in config transaction %s(%s)", t.Uuid, err)
+	}
+	issuer := utils.CRLIssuer(crl)
+	if secHelper := chain.getSecHelper(); nil != secHelper {
+		if err = secHelper.VerifyRevocationList(update.RevocationList); err != nil {
+			return fmt.Errorf("Revocation list of %s rejected(%s)", issuer, err)
+		}
+	}
+
+	markTxBegin(lgr, t)
+	err = checkMoreRecent(lgr, issuer, crl)
+	if err == nil {
+		err = lgr.SetRevocationList(issuer, update.RevocationList)
+	}
+	if err == nil {
+		err = validateTx(lgr, t)
+	}
+	if err != nil {
+		markTxFinish(lgr, t, false)
+		return fmt.Errorf("Failed to apply config transaction %s(%s)", t.Uuid, err)
+	}
+	markTxFinish(lgr, t, true)
+	chaincodeLogger.Info("Revocation list of %s issued %s recorded, %d certificates revoked", issuer, crl.TBSCertList.ThisUpdate, len(crl.TBSCertList.RevokedCertificates))
+	return nil
+}
+
+// checkMoreRecent checks that the revocation list of the issuer is more recent than the one
+// recorded, if any
+func checkMoreRecent(lgr *ledger.Ledger, issuer string, crl *pkix.CertificateList) error {
+	recorded, err := lgr.GetRevocationList(issuer, false)
+	if err != nil || recorded == nil {
+		return err
+	}
+	previous, err := utils.DERToCRL(recorded)
+	if err != nil {
+		return err
+	}
+	if !crl.TBSCertList.ThisUpdate.After(previous.TBSCertList.ThisUpdate) {
+		return fmt.Errorf("The revocation list of %s is not more recent than the recorded one, issued %s", issuer, previous.TBSCertList.ThisUpdate)
+	}
+	return nil
+}
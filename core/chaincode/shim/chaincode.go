@@ -20,11 +20,14 @@ package shim
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"encoding/asn1"
+	"encoding/binary"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"math/rand"
 	"os"
 	"strconv"
 	"strings"
@@ -64,11 +67,21 @@ type Chaincode interface {
 	Query(stub *ChaincodeStub, function string, args []string) ([]byte, error)
 }
 
+// Migrator can be implemented by a chaincode that needs to transform the state
+// left by its previous code when it is upgraded.
+type Migrator interface {
+	// Migrate is called during the Upgrade transaction after the container of
+	// the new code has been established, in place of Init. The changes it makes
+	// to the state are committed atomically with the upgrade
+	Migrate(stub *ChaincodeStub) ([]byte, error)
+}
+
 // ChaincodeStub is an object passed to chaincode for shim side handling of
 // APIs.
 type ChaincodeStub struct {
 	UUID            string
 	securityContext *pb.ChaincodeSecurityContext
+	chaincodeEvent  *pb.ChaincodeEvent
 }
 
 // Peer address derived from command line or env var
@@ -266,17 +279,50 @@ func (stub *ChaincodeStub) QueryChaincode(chaincodeName string, function string,
 
 // GetState returns the byte array value specified by the `key`.
 func (stub *ChaincodeStub) GetState(key string) ([]byte, error) {
-	return handler.handleGetState(key, stub.UUID)
+	return handler.handleGetState(pb.ChaincodeMessage_GET_STATE, key, stub.UUID)
 }
 
 // PutState writes the specified `value` and `key` into the ledger.
 func (stub *ChaincodeStub) PutState(key string, value []byte) error {
-	return handler.handlePutState(key, value, stub.UUID)
+	return handler.handlePutState(pb.ChaincodeMessage_PUT_STATE, key, value, stub.UUID)
 }
 
 // DelState removes the specified `key` and its value from the ledger.
 func (stub *ChaincodeStub) DelState(key string) error {
-	return handler.handleDelState(key, stub.UUID)
+	return handler.handleDelState(pb.ChaincodeMessage_DEL_STATE, key, stub.UUID)
+}
+
+// GetPrivateState returns the value of the `key` in the private state of the
+// chaincode. The private state is kept by the peer outside the world state: it
+// is neither hashed into the blocks nor replicated by state transfer.
+func (stub *ChaincodeStub) GetPrivateState(key string) ([]byte, error) {
+	return handler.handleGetState(pb.ChaincodeMessage_GET_PRIVATE_STATE, key, stub.UUID)
+}
+
+// PutPrivateState writes the specified `value` and `key` into the private
+// state of the chaincode.
+func (stub *ChaincodeStub) PutPrivateState(key string, value []byte) error {
+	return handler.handlePutState(pb.ChaincodeMessage_PUT_PRIVATE_STATE, key, value, stub.UUID)
+}
+
+// DelPrivateState removes the specified `key` and its value from the private
+// state of the chaincode.
+func (stub *ChaincodeStub) DelPrivateState(key string) error {
+	return handler.handleDelState(pb.ChaincodeMessage_DEL_PRIVATE_STATE, key, stub.UUID)
+}
+
+// MarkWriteOnce marks the `key` as write-once: once the key has a value, the
+// transactions that update or delete it are rejected at commit. The mark takes
+// effect at the end of the transaction, hence, the transaction can still set
+// the value of the key. It requires the ledger feature keyMetadata to be active.
+func (stub *ChaincodeStub) MarkWriteOnce(key string) error {
+	return handler.handleMarkWriteOnce(key, false, stub.UUID)
+}
+
+// MarkWriteOncePrefix marks all the keys starting with the `prefix` as
+// write-once. See MarkWriteOnce for details.
+func (stub *ChaincodeStub) MarkWriteOncePrefix(prefix string) error {
+	return handler.handleMarkWriteOnce(prefix, true, stub.UUID)
 }
 
 func (stub *ChaincodeStub) parseHeader(header string) (map[string]int, error) {
@@ -382,7 +428,32 @@ type StateRangeQueryIterator struct {
 // between the startKey and endKey, inclusive. The order in which keys are
 // returned by the iterator is random.
 func (stub *ChaincodeStub) RangeQueryState(startKey, endKey string) (*StateRangeQueryIterator, error) {
-	response, err := handler.handleRangeQueryState(startKey, endKey, stub.UUID)
+	response, err := handler.handleRangeQueryState(startKey, endKey, false, stub.UUID)
+	if err != nil {
+		return nil, err
+	}
+	return &StateRangeQueryIterator{handler, stub.UUID, response, 0}, nil
+}
+
+// GetStateByRange function can be invoked by a chaincode to query of a range
+// of keys in the state. Unlike RangeQueryState, the iterator returned returns
+// the keys between the startKey and endKey, inclusive, in lexical order.
+func (stub *ChaincodeStub) GetStateByRange(startKey, endKey string) (*StateRangeQueryIterator, error) {
+	response, err := handler.handleRangeQueryState(startKey, endKey, true, stub.UUID)
+	if err != nil {
+		return nil, err
+	}
+	return &StateRangeQueryIterator{handler, stub.UUID, response, 0}, nil
+}
+
+// GetQueryResult function can be invoked by a chaincode to run a rich query
+// of its state, in the query language of the state database of the peer. The
+// query is run against the committed state only, the changes made by the
+// current transaction are not taken into account. An error is returned if the
+// state database does not support rich queries. The results are returned by an
+// iterator like the one of a range query.
+func (stub *ChaincodeStub) GetQueryResult(query string) (*StateRangeQueryIterator, error) {
+	response, err := handler.handleGetQueryResult(query, stub.UUID)
 	if err != nil {
 		return nil, err
 	}
@@ -429,6 +500,49 @@ func (iter *StateRangeQueryIterator) Close() error {
 	return err
 }
 
+// HistoryQueryIterator allows a chaincode to iterate over the values taken by
+// a key.
+type HistoryQueryIterator struct {
+	modifications []*pb.KeyModification
+	currentLoc    int
+}
+
+// GetHistoryForKey function can be invoked by a chaincode to get the values
+// taken by a key, oldest first, in the committed blocks whose state delta is
+// still retained by the peer (see ledger.state.deltaHistorySize). There is
+// one modification per block which changed the key: its value at the end of
+// the block, along with the last transaction of the block which changed it.
+func (stub *ChaincodeStub) GetHistoryForKey(key string) (*HistoryQueryIterator, error) {
+	response, err := handler.handleGetHistoryForKey(key, stub.UUID)
+	if err != nil {
+		return nil, err
+	}
+	return &HistoryQueryIterator{response.Modifications, 0}, nil
+}
+
+// HasNext returns true if the history query iterator contains additional
+// modifications of the key.
+func (iter *HistoryQueryIterator) HasNext() bool {
+	return iter.currentLoc < len(iter.modifications)
+}
+
+// Next returns the next modification of the key: the UUID of the transaction,
+// the number of the block, the value, and whether the key was deleted.
+func (iter *HistoryQueryIterator) Next() (*pb.KeyModification, error) {
+	if !iter.HasNext() {
+		return nil, errors.New("No such modification")
+	}
+	modification := iter.modifications[iter.currentLoc]
+	iter.currentLoc++
+	return modification, nil
+}
+
+// Close closes the history query iterator. The history is returned at once by
+// the peer, so there are no resources to free.
+func (iter *HistoryQueryIterator) Close() error {
+	return nil
+}
+
 // TABLE FUNCTIONALITY
 // TODO More comments here with documentation
 
@@ -695,6 +809,16 @@ func (stub *ChaincodeStub) GetPayload() ([]byte, error) {
 	return stub.securityContext.Payload, nil
 }
 
+// GetTransient returns the transient data passed along with the invocation,
+// which is not part of the transaction stored on the ledger
+func (stub *ChaincodeStub) GetTransient() (map[string][]byte, error) {
+	transient := make(map[string][]byte)
+	for _, field := range stub.securityContext.Transient {
+		transient[field.Key] = field.Value
+	}
+	return transient, nil
+}
+
 // GetTxTimestamp returns transaction created timestamp, which is currently
 // taken from the peer receiving the transaction. Note that this timestamp
 // may not be the same with the other peers' time.
@@ -702,6 +826,26 @@ func (stub *ChaincodeStub) GetTxTimestamp() (*gp.Timestamp, error) {
 	return stub.securityContext.TxTimestamp, nil
 }
 
+// GetTxRand returns a source of pseudo-random numbers seeded from the
+// transaction UUID, which yields the same numbers on every validating peer,
+// unlike the global source of math/rand.
+func (stub *ChaincodeStub) GetTxRand() *rand.Rand {
+	seed := sha256.Sum256([]byte(stub.UUID))
+	return rand.New(rand.NewSource(int64(binary.BigEndian.Uint64(seed[:8]))))
+}
+
+// SetEvent sets the named event the chaincode emits for the current
+// transaction. The event is returned to the validator with the transaction
+// result and included in the block event once the transaction is committed.
+// Only one event can be set per transaction; a later call replaces it.
+func (stub *ChaincodeStub) SetEvent(name string, payload []byte) error {
+	if name == "" {
+		return errors.New("Event name can not be empty.")
+	}
+	stub.chaincodeEvent = &pb.ChaincodeEvent{EventName: name, Payload: payload}
+	return nil
+}
+
 func (stub *ChaincodeStub) getTable(tableName string) (*Table, error) {
 
 	tableName, err := getTableNameKey(tableName)
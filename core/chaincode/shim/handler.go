@@ -223,7 +223,16 @@ func (handler *Handler) handleInit(msg *pb.ChaincodeMessage) {
 		// Create the ChaincodeStub which the chaincode can use to callback
 		stub := new(ChaincodeStub)
 		stub.init(msg.Uuid, msg.SecurityContext)
-		res, err := handler.cc.Init(stub, input.Function, input.Args)
+		var res []byte
+		var err error
+		if input.Function == pb.ChaincodeMigrateFunction {
+			//the state of a chaincode which does not need to migrate it is kept as is
+			if migrator, ok := handler.cc.(Migrator); ok {
+				res, err = migrator.Migrate(stub)
+			}
+		} else {
+			res, err = handler.cc.Init(stub, input.Function, input.Args)
+		}
 
 		// delete isTransaction entry
 		handler.deleteIsTransaction(msg.Uuid)
@@ -237,7 +246,7 @@ func (handler *Handler) handleInit(msg *pb.ChaincodeMessage) {
 		}
 
 		// Send COMPLETED message to chaincode support and change state
-		nextStateMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_COMPLETED, Payload: res, Uuid: msg.Uuid}
+		nextStateMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_COMPLETED, Payload: res, Uuid: msg.Uuid, ChaincodeEvent: stub.chaincodeEvent}
 		chaincodeLogger.Debug("[%s]Init succeeded. Sending %s", shortuuid(msg.Uuid), pb.ChaincodeMessage_COMPLETED)
 	}()
 }
@@ -305,7 +314,7 @@ func (handler *Handler) handleTransaction(msg *pb.ChaincodeMessage) {
 
 		// Send COMPLETED message to chaincode support and change state
 		chaincodeLogger.Debug("[%s]Transaction completed. Sending %s", shortuuid(msg.Uuid), pb.ChaincodeMessage_COMPLETED)
-		nextStateMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_COMPLETED, Payload: res, Uuid: msg.Uuid}
+		nextStateMsg = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_COMPLETED, Payload: res, Uuid: msg.Uuid, ChaincodeEvent: stub.chaincodeEvent}
 	}()
 }
 
@@ -432,7 +441,8 @@ func (handler *Handler) afterError(e *fsm.Event) {
 
 // TODO: Implement method to get and put entire state map and not one key at a time?
 // handleGetState communicates with the validator to fetch the requested state information from the ledger.
-func (handler *Handler) handleGetState(key string, uuid string) ([]byte, error) {
+// msgType is GET_STATE for the world state or GET_PRIVATE_STATE for the private state.
+func (handler *Handler) handleGetState(msgType pb.ChaincodeMessage_Type, key string, uuid string) ([]byte, error) {
 	// Create the channel on which to communicate the response from validating peer
 	respChan, uniqueReqErr := handler.createChannel(uuid)
 	if uniqueReqErr != nil {
@@ -444,10 +454,10 @@ func (handler *Handler) handleGetState(key string, uuid string) ([]byte, error)
 
 	// Send GET_STATE message to validator chaincode support
 	payload := []byte(key)
-	msg := &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_GET_STATE, Payload: payload, Uuid: uuid}
-	chaincodeLogger.Debug("[%s]Sending %s", shortuuid(msg.Uuid), pb.ChaincodeMessage_GET_STATE)
+	msg := &pb.ChaincodeMessage{Type: msgType, Payload: payload, Uuid: uuid}
+	chaincodeLogger.Debug("[%s]Sending %s", shortuuid(msg.Uuid), msgType)
 	if err := handler.serialSend(msg); err != nil {
-		chaincodeLogger.Error(fmt.Sprintf("[%s]error sending GET_STATE %s", shortuuid(uuid), err))
+		chaincodeLogger.Error(fmt.Sprintf("[%s]error sending %s %s", shortuuid(uuid), msgType, err))
 		return nil, errors.New("could not send msg")
 	}
 
@@ -475,7 +485,8 @@ func (handler *Handler) handleGetState(key string, uuid string) ([]byte, error)
 }
 
 // handlePutState communicates with the validator to put state information into the ledger.
-func (handler *Handler) handlePutState(key string, value []byte, uuid string) error {
+// msgType is PUT_STATE for the world state or PUT_PRIVATE_STATE for the private state.
+func (handler *Handler) handlePutState(msgType pb.ChaincodeMessage_Type, key string, value []byte, uuid string) error {
 	// Check if this is a transaction
 	chaincodeLogger.Debug("[%s]Inside putstate, isTransaction = %t", shortuuid(uuid), handler.isTransaction[uuid])
 	if !handler.isTransaction[uuid] {
@@ -498,10 +509,10 @@ func (handler *Handler) handlePutState(key string, value []byte, uuid string) er
 	defer handler.deleteChannel(uuid)
 
 	// Send PUT_STATE message to validator chaincode support
-	msg := &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_PUT_STATE, Payload: payloadBytes, Uuid: uuid}
-	chaincodeLogger.Debug("[%s]Sending %s", shortuuid(msg.Uuid), pb.ChaincodeMessage_PUT_STATE)
+	msg := &pb.ChaincodeMessage{Type: msgType, Payload: payloadBytes, Uuid: uuid}
+	chaincodeLogger.Debug("[%s]Sending %s", shortuuid(msg.Uuid), msgType)
 	if err = handler.serialSend(msg); err != nil {
-		chaincodeLogger.Error(fmt.Sprintf("[%s]error sending PUT_STATE %s", msg.Uuid, err))
+		chaincodeLogger.Error(fmt.Sprintf("[%s]error sending %s %s", msg.Uuid, msgType, err))
 		return errors.New("could not send msg")
 	}
 
@@ -530,7 +541,8 @@ func (handler *Handler) handlePutState(key string, value []byte, uuid string) er
 }
 
 // handleDelState communicates with the validator to delete a key from the state in the ledger.
-func (handler *Handler) handleDelState(key string, uuid string) error {
+// msgType is DEL_STATE for the world state or DEL_PRIVATE_STATE for the private state.
+func (handler *Handler) handleDelState(msgType pb.ChaincodeMessage_Type, key string, uuid string) error {
 	// Check if this is a transaction
 	if !handler.isTransaction[uuid] {
 		return errors.New("Cannot del state in query context")
@@ -547,10 +559,10 @@ func (handler *Handler) handleDelState(key string, uuid string) error {
 
 	// Send DEL_STATE message to validator chaincode support
 	payload := []byte(key)
-	msg := &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_DEL_STATE, Payload: payload, Uuid: uuid}
-	chaincodeLogger.Debug("[%s]Sending %s", shortuuid(msg.Uuid), pb.ChaincodeMessage_DEL_STATE)
+	msg := &pb.ChaincodeMessage{Type: msgType, Payload: payload, Uuid: uuid}
+	chaincodeLogger.Debug("[%s]Sending %s", shortuuid(msg.Uuid), msgType)
 	if err := handler.serialSend(msg); err != nil {
-		chaincodeLogger.Error(fmt.Sprintf("[%s]error sending DEL_STATE %s", shortuuid(msg.Uuid), pb.ChaincodeMessage_DEL_STATE))
+		chaincodeLogger.Error(fmt.Sprintf("[%s]error sending %s %s", shortuuid(msg.Uuid), msgType, err))
 		return errors.New("could not send msg")
 	}
 
@@ -577,7 +589,71 @@ func (handler *Handler) handleDelState(key string, uuid string) error {
 	return errors.New("Incorrect chaincode message received")
 }
 
-func (handler *Handler) handleRangeQueryState(startKey, endKey string, uuid string) (*pb.RangeQueryStateResponse, error) {
+// handleMarkWriteOnce communicates with the validator to mark a key, or the keys starting with a
+// prefix, as write-once.
+func (handler *Handler) handleMarkWriteOnce(key string, prefix bool, uuid string) error {
+	// Check if this is a transaction
+	if !handler.isTransaction[uuid] {
+		return errors.New("Cannot mark write-once keys in query context")
+	}
+
+	payloadBytes, err := proto.Marshal(&pb.MarkWriteOnce{Key: key, Prefix: prefix})
+	if err != nil {
+		return errors.New("Failed to process mark write-once request")
+	}
+
+	// Create the channel on which to communicate the response from validating peer
+	respChan, uniqueReqErr := handler.createChannel(uuid)
+	if uniqueReqErr != nil {
+		chaincodeLogger.Error(fmt.Sprintf("[%s]Another state request pending for this Uuid. Cannot process create createChannel.", shortuuid(uuid)))
+		return uniqueReqErr
+	}
+
+	defer handler.deleteChannel(uuid)
+
+	// Send MARK_WRITE_ONCE message to validator chaincode support
+	msg := &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_MARK_WRITE_ONCE, Payload: payloadBytes, Uuid: uuid}
+	chaincodeLogger.Debug("[%s]Sending %s", shortuuid(msg.Uuid), pb.ChaincodeMessage_MARK_WRITE_ONCE)
+	if err := handler.serialSend(msg); err != nil {
+		chaincodeLogger.Error(fmt.Sprintf("[%s]error sending %s %s", shortuuid(msg.Uuid), pb.ChaincodeMessage_MARK_WRITE_ONCE, err))
+		return errors.New("could not send msg")
+	}
+
+	// Wait on responseChannel for response
+	responseMsg, ok := handler.receiveChannel(respChan)
+	if !ok {
+		chaincodeLogger.Error(fmt.Sprintf("[%s]Received unexpected message type", shortuuid(msg.Uuid)))
+		return errors.New("Received unexpected message type")
+	}
+
+	if responseMsg.Type.String() == pb.ChaincodeMessage_RESPONSE.String() {
+		// Success response
+		chaincodeLogger.Debug("[%s]Received %s. Successfully marked write-once", msg.Uuid, pb.ChaincodeMessage_RESPONSE)
+		return nil
+	}
+	if responseMsg.Type.String() == pb.ChaincodeMessage_ERROR.String() {
+		// Error response
+		chaincodeLogger.Error(fmt.Sprintf("[%s]Received %s. Payload: %s", msg.Uuid, pb.ChaincodeMessage_ERROR, responseMsg.Payload))
+		return errors.New(string(responseMsg.Payload[:]))
+	}
+
+	// Incorrect chaincode message received
+	chaincodeLogger.Error(fmt.Sprintf("[%s]Incorrect chaincode message %s received. Expecting %s or %s", shortuuid(responseMsg.Uuid), responseMsg.Type, pb.ChaincodeMessage_RESPONSE, pb.ChaincodeMessage_ERROR))
+	return errors.New("Incorrect chaincode message received")
+}
+
+func (handler *Handler) handleRangeQueryState(startKey, endKey string, ordered bool, uuid string) (*pb.RangeQueryStateResponse, error) {
+	payload := &pb.RangeQueryState{StartKey: startKey, EndKey: endKey, Ordered: ordered}
+	return handler.handleStateQuery(pb.ChaincodeMessage_RANGE_QUERY_STATE, payload, uuid)
+}
+
+func (handler *Handler) handleGetQueryResult(query string, uuid string) (*pb.RangeQueryStateResponse, error) {
+	payload := &pb.GetQueryResult{Query: query}
+	return handler.handleStateQuery(pb.ChaincodeMessage_GET_QUERY_RESULT, payload, uuid)
+}
+
+// handleStateQuery sends a query of the state whose results are paged, returning the first page
+func (handler *Handler) handleStateQuery(msgType pb.ChaincodeMessage_Type, payload proto.Message, uuid string) (*pb.RangeQueryStateResponse, error) {
 	// Create the channel on which to communicate the response from validating peer
 	respChan, uniqueReqErr := handler.createChannel(uuid)
 	if uniqueReqErr != nil {
@@ -587,16 +663,15 @@ func (handler *Handler) handleRangeQueryState(startKey, endKey string, uuid stri
 
 	defer handler.deleteChannel(uuid)
 
-	// Send RANGE_QUERY_STATE message to validator chaincode support
-	payload := &pb.RangeQueryState{StartKey: startKey, EndKey: endKey}
+	// Send the query message to validator chaincode support
 	payloadBytes, err := proto.Marshal(payload)
 	if err != nil {
-		return nil, errors.New("Failed to process range query state request")
+		return nil, fmt.Errorf("Failed to process %s request", msgType)
 	}
-	msg := &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_RANGE_QUERY_STATE, Payload: payloadBytes, Uuid: uuid}
-	chaincodeLogger.Debug("[%s]Sending %s", shortuuid(msg.Uuid), pb.ChaincodeMessage_RANGE_QUERY_STATE)
+	msg := &pb.ChaincodeMessage{Type: msgType, Payload: payloadBytes, Uuid: uuid}
+	chaincodeLogger.Debug("[%s]Sending %s", shortuuid(msg.Uuid), msgType)
 	if err = handler.serialSend(msg); err != nil {
-		chaincodeLogger.Error(fmt.Sprintf("[%s]error sending %s", shortuuid(msg.Uuid), pb.ChaincodeMessage_RANGE_QUERY_STATE))
+		chaincodeLogger.Error(fmt.Sprintf("[%s]error sending %s", shortuuid(msg.Uuid), msgType))
 		return nil, errors.New("could not send msg")
 	}
 
@@ -631,6 +706,59 @@ func (handler *Handler) handleRangeQueryState(startKey, endKey string, uuid stri
 	return nil, errors.New("Incorrect chaincode message received")
 }
 
+func (handler *Handler) handleGetHistoryForKey(key string, uuid string) (*pb.GetHistoryForKeyResponse, error) {
+	// Create the channel on which to communicate the response from validating peer
+	respChan, uniqueReqErr := handler.createChannel(uuid)
+	if uniqueReqErr != nil {
+		chaincodeLogger.Debug("[%s]Another state request pending for this Uuid. Cannot process.", shortuuid(uuid))
+		return nil, uniqueReqErr
+	}
+
+	defer handler.deleteChannel(uuid)
+
+	// Send GET_HISTORY_FOR_KEY message to validator chaincode support
+	payloadBytes, err := proto.Marshal(&pb.GetHistoryForKey{Key: key})
+	if err != nil {
+		return nil, errors.New("Failed to process get history for key request")
+	}
+	msg := &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_GET_HISTORY_FOR_KEY, Payload: payloadBytes, Uuid: uuid}
+	chaincodeLogger.Debug("[%s]Sending %s", shortuuid(msg.Uuid), pb.ChaincodeMessage_GET_HISTORY_FOR_KEY)
+	if err = handler.serialSend(msg); err != nil {
+		chaincodeLogger.Error(fmt.Sprintf("[%s]error sending %s", shortuuid(msg.Uuid), pb.ChaincodeMessage_GET_HISTORY_FOR_KEY))
+		return nil, errors.New("could not send msg")
+	}
+
+	// Wait on responseChannel for response
+	responseMsg, ok := handler.receiveChannel(respChan)
+	if !ok {
+		chaincodeLogger.Error(fmt.Sprintf("[%s]Received unexpected message type", uuid))
+		return nil, errors.New("Received unexpected message type")
+	}
+
+	if responseMsg.Type.String() == pb.ChaincodeMessage_RESPONSE.String() {
+		// Success response
+		chaincodeLogger.Debug("[%s]Received %s. Successfully got history", shortuuid(responseMsg.Uuid), pb.ChaincodeMessage_RESPONSE)
+
+		historyResponse := &pb.GetHistoryForKeyResponse{}
+		unmarshalErr := proto.Unmarshal(responseMsg.Payload, historyResponse)
+		if unmarshalErr != nil {
+			chaincodeLogger.Error(fmt.Sprintf("[%s]unmarshall error", shortuuid(responseMsg.Uuid)))
+			return nil, errors.New("Error unmarshalling GetHistoryForKeyResponse.")
+		}
+
+		return historyResponse, nil
+	}
+	if responseMsg.Type.String() == pb.ChaincodeMessage_ERROR.String() {
+		// Error response
+		chaincodeLogger.Error(fmt.Sprintf("[%s]Received %s", shortuuid(responseMsg.Uuid), pb.ChaincodeMessage_ERROR))
+		return nil, errors.New(string(responseMsg.Payload[:]))
+	}
+
+	// Incorrect chaincode message received
+	chaincodeLogger.Error(fmt.Sprintf("Incorrect chaincode message %s recieved. Expecting %s or %s", responseMsg.Type, pb.ChaincodeMessage_RESPONSE, pb.ChaincodeMessage_ERROR))
+	return nil, errors.New("Incorrect chaincode message received")
+}
+
 func (handler *Handler) handleRangeQueryStateNext(id, uuid string) (*pb.RangeQueryStateResponse, error) {
 	// Create the channel on which to communicate the response from validating peer
 	respChan, uniqueReqErr := handler.createChannel(uuid)
@@ -0,0 +1,128 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package org.hyperledger.fabric.shim;
+
+import java.io.File;
+import java.util.logging.Logger;
+
+import io.grpc.ManagedChannel;
+import io.grpc.netty.GrpcSslContexts;
+import io.grpc.netty.NegotiationType;
+import io.grpc.netty.NettyChannelBuilder;
+import io.grpc.stub.StreamObserver;
+import io.netty.handler.ssl.SslContextBuilder;
+import protos.Chaincode.ChaincodeMessage;
+import protos.ChaincodeSupportGrpc;
+
+/**
+ * ChaincodeBase is the base class of the chaincodes written in Java. It mirrors
+ * the Chaincode interface of the Go shim: a chaincode implements init, invoke
+ * and query, and its main method calls start to connect to the peer which
+ * launched it.
+ *
+ * The peer passes its address with the -peer.address argument and the name of
+ * the chaincode in the CORE_CHAINCODE_ID_NAME environment variable. When the
+ * chaincode is run by the user in development mode, CORE_PEER_ADDRESS can be
+ * set instead of the argument.
+ */
+public abstract class ChaincodeBase {
+
+    private static final Logger logger = Logger.getLogger(ChaincodeBase.class.getName());
+
+    /**
+     * init is called by the deploy transaction of the chaincode.
+     */
+    public abstract byte[] init(ChaincodeStub stub, String function, String[] args) throws Exception;
+
+    /**
+     * invoke is called by the invoke transactions of the chaincode.
+     */
+    public abstract byte[] invoke(ChaincodeStub stub, String function, String[] args) throws Exception;
+
+    /**
+     * query is called by the queries of the chaincode, which can not change
+     * the state.
+     */
+    public abstract byte[] query(ChaincodeStub stub, String function, String[] args) throws Exception;
+
+    /**
+     * migrate is called by the upgrade transaction which replaces the code of
+     * the chaincode with this one. It keeps the state as is unless overridden.
+     */
+    public byte[] migrate(ChaincodeStub stub) throws Exception {
+        return null;
+    }
+
+    /**
+     * start connects the chaincode to the peer and serves its invocations
+     * until the peer closes the stream.
+     */
+    public void start(String[] args) {
+        String peerAddress = System.getenv("CORE_PEER_ADDRESS");
+        for (String arg : args) {
+            if (arg.startsWith("-peer.address=")) {
+                peerAddress = arg.substring("-peer.address=".length());
+            }
+        }
+        String name = System.getenv("CORE_CHAINCODE_ID_NAME");
+        if (peerAddress == null || peerAddress.isEmpty() || name == null || name.isEmpty()) {
+            logger.severe("peer address and chaincode name must be set to start the chaincode");
+            System.exit(1);
+        }
+
+        try {
+            ManagedChannel channel = newPeerChannel(peerAddress);
+            Handler handler = new Handler(this);
+            StreamObserver<ChaincodeMessage> requests = ChaincodeSupportGrpc.newStub(channel).register(handler);
+            handler.register(requests, name);
+            handler.awaitClose();
+            channel.shutdown();
+        } catch (Exception e) {
+            logger.severe(String.format("Error chatting with peer at address=%s: %s", peerAddress, e));
+            System.exit(1);
+        }
+    }
+
+    // newPeerChannel connects to the peer, over TLS if CORE_PEER_TLS_ENABLED
+    // is true, like the Go shim configured through core.yaml. The certificate
+    // and key of CORE_PEER_TLS_CLIENTCERT_FILE and CORE_PEER_TLS_CLIENTKEY_FILE
+    // are presented to a peer requiring client authentication; netty reads the
+    // key in PKCS#8 PEM only.
+    private static ManagedChannel newPeerChannel(String peerAddress) throws Exception {
+        String[] hostPort = peerAddress.split(":");
+        NettyChannelBuilder builder = NettyChannelBuilder.forAddress(hostPort[0], Integer.parseInt(hostPort[1]));
+        if (!"true".equalsIgnoreCase(System.getenv("CORE_PEER_TLS_ENABLED"))) {
+            return builder.negotiationType(NegotiationType.PLAINTEXT).build();
+        }
+        SslContextBuilder sslContext = GrpcSslContexts.forClient();
+        String rootCert = System.getenv("CORE_PEER_TLS_ROOTCERT_FILE");
+        if (rootCert != null && !rootCert.isEmpty()) {
+            sslContext.trustManager(new File(rootCert));
+        }
+        String clientCert = System.getenv("CORE_PEER_TLS_CLIENTCERT_FILE");
+        String clientKey = System.getenv("CORE_PEER_TLS_CLIENTKEY_FILE");
+        if (clientCert != null && !clientCert.isEmpty() && clientKey != null && !clientKey.isEmpty()) {
+            sslContext.keyManager(new File(clientCert), new File(clientKey));
+        }
+        builder.sslContext(sslContext.build());
+        String hostOverride = System.getenv("CORE_PEER_TLS_SERVERHOSTOVERRIDE");
+        if (hostOverride != null && !hostOverride.isEmpty()) {
+            builder.overrideAuthority(hostOverride);
+        }
+        return builder.negotiationType(NegotiationType.TLS).build();
+    }
+}
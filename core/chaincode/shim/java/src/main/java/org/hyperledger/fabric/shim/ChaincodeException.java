@@ -0,0 +1,34 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package org.hyperledger.fabric.shim;
+
+/**
+ * ChaincodeException is thrown by the stub when the peer fails or refuses a
+ * request of the chaincode.
+ */
+public class ChaincodeException extends Exception {
+
+    private static final long serialVersionUID = 1L;
+
+    public ChaincodeException(String message) {
+        super(message);
+    }
+
+    public ChaincodeException(String message, Throwable cause) {
+        super(message, cause);
+    }
+}
@@ -0,0 +1,111 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package org.hyperledger.fabric.shim;
+
+import java.nio.charset.StandardCharsets;
+
+import com.google.protobuf.ByteString;
+import protos.Chaincode.ChaincodeEvent;
+import protos.Chaincode.ChaincodeMessage;
+import protos.Chaincode.PutStateInfo;
+
+/**
+ * ChaincodeStub is the chaincode's view of the transaction or query it
+ * executes, through which it reads and writes its state.
+ */
+public class ChaincodeStub {
+
+    private final String uuid;
+    private final Handler handler;
+    private final boolean isTransaction;
+    private ChaincodeEvent event;
+
+    ChaincodeStub(String uuid, Handler handler, boolean isTransaction) {
+        this.uuid = uuid;
+        this.handler = handler;
+        this.isTransaction = isTransaction;
+    }
+
+    /**
+     * getUuid returns the UUID of the transaction or query.
+     */
+    public String getUuid() {
+        return uuid;
+    }
+
+    /**
+     * getState returns the value of the key in the state, an empty array if
+     * the key is not set.
+     */
+    public byte[] getState(String key) throws ChaincodeException {
+        return handler.request(ChaincodeMessage.Type.GET_STATE, ByteString.copyFromUtf8(key), uuid).toByteArray();
+    }
+
+    /**
+     * getStringState returns the value of the key in the state as a string.
+     */
+    public String getStringState(String key) throws ChaincodeException {
+        return new String(getState(key), StandardCharsets.UTF_8);
+    }
+
+    /**
+     * putState sets the value of the key in the state. Only transactions can
+     * change the state.
+     */
+    public void putState(String key, byte[] value) throws ChaincodeException {
+        checkTransaction("put state");
+        PutStateInfo info = PutStateInfo.newBuilder().setKey(key).setValue(ByteString.copyFrom(value)).build();
+        handler.request(ChaincodeMessage.Type.PUT_STATE, info.toByteString(), uuid);
+    }
+
+    /**
+     * putStringState sets the value of the key in the state to a string.
+     */
+    public void putStringState(String key, String value) throws ChaincodeException {
+        putState(key, value.getBytes(StandardCharsets.UTF_8));
+    }
+
+    /**
+     * delState deletes the key from the state. Only transactions can change
+     * the state.
+     */
+    public void delState(String key) throws ChaincodeException {
+        checkTransaction("del state");
+        handler.request(ChaincodeMessage.Type.DEL_STATE, ByteString.copyFromUtf8(key), uuid);
+    }
+
+    /**
+     * setEvent sets the event returned to the peer with the completed
+     * transaction, replacing any event set before.
+     */
+    public void setEvent(String name, byte[] payload) throws ChaincodeException {
+        if (name == null || name.isEmpty()) {
+            throw new ChaincodeException("Event name can not be empty");
+        }
+        event = ChaincodeEvent.newBuilder().setEventName(name).setPayload(ByteString.copyFrom(payload)).build();
+    }
+
+    ChaincodeEvent getEvent() {
+        return event;
+    }
+
+    private void checkTransaction(String operation) throws ChaincodeException {
+        if (!isTransaction) {
+            throw new ChaincodeException("Cannot " + operation + " in query context");
+        }
+    }
+}
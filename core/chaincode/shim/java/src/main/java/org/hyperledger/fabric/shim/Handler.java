@@ -0,0 +1,190 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package org.hyperledger.fabric.shim;
+
+import java.nio.charset.StandardCharsets;
+import java.util.Map;
+import java.util.concurrent.CompletableFuture;
+import java.util.concurrent.ConcurrentHashMap;
+import java.util.concurrent.CountDownLatch;
+import java.util.concurrent.ExecutionException;
+import java.util.concurrent.ExecutorService;
+import java.util.concurrent.Executors;
+import java.util.logging.Logger;
+
+import com.google.protobuf.ByteString;
+import com.google.protobuf.InvalidProtocolBufferException;
+import io.grpc.stub.StreamObserver;
+import protos.Chaincode.ChaincodeID;
+import protos.Chaincode.ChaincodeInput;
+import protos.Chaincode.ChaincodeMessage;
+
+/**
+ * Handler speaks the shim protocol with the peer over the Register stream, as
+ * the handler of the Go shim does. Each transaction and query runs on its own
+ * thread; the requests it makes to the peer are matched with their responses
+ * by UUID.
+ */
+class Handler implements StreamObserver<ChaincodeMessage> {
+
+    private static final Logger logger = Logger.getLogger(Handler.class.getName());
+
+    // function of the INIT message sent by an upgrade transaction, see
+    // protos.ChaincodeMigrateFunction
+    private static final String MIGRATE_FUNCTION = "__migrate";
+
+    private final ChaincodeBase chaincode;
+    private final ExecutorService executor = Executors.newCachedThreadPool();
+    private final Map<String, CompletableFuture<ChaincodeMessage>> pending = new ConcurrentHashMap<>();
+    private final CountDownLatch closed = new CountDownLatch(1);
+    private StreamObserver<ChaincodeMessage> peer;
+
+    Handler(ChaincodeBase chaincode) {
+        this.chaincode = chaincode;
+    }
+
+    void register(StreamObserver<ChaincodeMessage> peer, String name) {
+        this.peer = peer;
+        logger.fine("Registering.. sending REGISTER");
+        ChaincodeID id = ChaincodeID.newBuilder().setName(name).build();
+        send(ChaincodeMessage.newBuilder().setType(ChaincodeMessage.Type.REGISTER).setPayload(id.toByteString()).build());
+    }
+
+    void awaitClose() throws InterruptedException {
+        closed.await();
+        executor.shutdown();
+    }
+
+    // send serializes the messages sent on the stream, which is not thread safe
+    private synchronized void send(ChaincodeMessage msg) {
+        peer.onNext(msg);
+    }
+
+    @Override
+    public void onNext(ChaincodeMessage msg) {
+        logger.fine(String.format("[%s]Received %s", msg.getUuid(), msg.getType()));
+        switch (msg.getType()) {
+        case REGISTERED:
+            logger.fine("Received REGISTERED, ready for invocations");
+            break;
+        case READY:
+            break;
+        case INIT:
+        case TRANSACTION:
+        case QUERY:
+            executor.submit(() -> execute(msg));
+            break;
+        case RESPONSE:
+        case ERROR:
+            CompletableFuture<ChaincodeMessage> response = pending.remove(msg.getUuid());
+            if (response != null) {
+                response.complete(msg);
+            } else {
+                logger.warning(String.format("[%s]Received %s with no pending request", msg.getUuid(), msg.getType()));
+            }
+            break;
+        default:
+            logger.warning(String.format("[%s]Received unexpected message %s", msg.getUuid(), msg.getType()));
+        }
+    }
+
+    @Override
+    public void onError(Throwable t) {
+        logger.severe("Stream with peer failed: " + t);
+        close();
+    }
+
+    @Override
+    public void onCompleted() {
+        logger.fine("Stream with peer closed");
+        close();
+    }
+
+    private void close() {
+        for (CompletableFuture<ChaincodeMessage> response : pending.values()) {
+            response.completeExceptionally(new ChaincodeException("stream with peer closed"));
+        }
+        closed.countDown();
+    }
+
+    // execute runs the INIT, TRANSACTION or QUERY message on the chaincode and
+    // sends the result back to the peer
+    private void execute(ChaincodeMessage msg) {
+        boolean isQuery = msg.getType() == ChaincodeMessage.Type.QUERY;
+        ChaincodeMessage.Type completed = isQuery ? ChaincodeMessage.Type.QUERY_COMPLETED : ChaincodeMessage.Type.COMPLETED;
+        ChaincodeMessage.Type failed = isQuery ? ChaincodeMessage.Type.QUERY_ERROR : ChaincodeMessage.Type.ERROR;
+
+        ChaincodeMessage.Builder result = ChaincodeMessage.newBuilder().setUuid(msg.getUuid());
+        try {
+            ChaincodeInput input = ChaincodeInput.parseFrom(msg.getPayload());
+            String function = input.getFunction();
+            String[] args = input.getArgsList().toArray(new String[0]);
+            ChaincodeStub stub = new ChaincodeStub(msg.getUuid(), this, !isQuery);
+
+            byte[] res;
+            switch (msg.getType()) {
+            case INIT:
+                res = MIGRATE_FUNCTION.equals(function) ? chaincode.migrate(stub) : chaincode.init(stub, function, args);
+                break;
+            case TRANSACTION:
+                res = chaincode.invoke(stub, function, args);
+                break;
+            default:
+                res = chaincode.query(stub, function, args);
+            }
+
+            result.setType(completed);
+            if (res != null) {
+                result.setPayload(ByteString.copyFrom(res));
+            }
+            if (!isQuery && stub.getEvent() != null) {
+                result.setChaincodeEvent(stub.getEvent());
+            }
+        } catch (InvalidProtocolBufferException e) {
+            logger.fine(String.format("[%s]Incorrect payload format. Sending %s", msg.getUuid(), failed));
+            result.setType(failed).setPayload(ByteString.copyFromUtf8(e.toString()));
+        } catch (Exception e) {
+            logger.fine(String.format("[%s]%s failed. Sending %s", msg.getUuid(), msg.getType(), failed));
+            String message = e.getMessage() != null ? e.getMessage() : e.toString();
+            result.setType(failed).setPayload(ByteString.copyFromUtf8(message));
+        }
+        send(result.build());
+    }
+
+    // request sends a state request of the transaction or query to the peer
+    // and waits for its response. A transaction makes one request at a time.
+    ByteString request(ChaincodeMessage.Type type, ByteString payload, String uuid) throws ChaincodeException {
+        CompletableFuture<ChaincodeMessage> response = new CompletableFuture<>();
+        if (pending.putIfAbsent(uuid, response) != null) {
+            throw new ChaincodeException("Another state request pending for this Uuid. Cannot process.");
+        }
+        logger.fine(String.format("[%s]Sending %s", uuid, type));
+        send(ChaincodeMessage.newBuilder().setType(type).setPayload(payload).setUuid(uuid).build());
+
+        ChaincodeMessage msg;
+        try {
+            msg = response.get();
+        } catch (InterruptedException | ExecutionException e) {
+            pending.remove(uuid);
+            throw new ChaincodeException(String.format("%s failed", type), e);
+        }
+        if (msg.getType() == ChaincodeMessage.Type.ERROR) {
+            throw new ChaincodeException(msg.getPayload().toString(StandardCharsets.UTF_8));
+        }
+        return msg.getPayload();
+    }
+}
@@ -0,0 +1,93 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+
+// Node.js shim of the chaincodes. A chaincode is an object with init, invoke
+// and query functions, mirroring the Chaincode interface of the Go shim, which
+// the chaincode passes to start:
+//
+//     var shim = require('fabric-shim');
+//     shim.start({
+//         init: function(stub, fn, args) { ... },
+//         invoke: function(stub, fn, args) { ... },
+//         query: function(stub, fn, args) { ... }
+//     });
+//
+// The functions return the result of the invocation, as a Buffer or a string,
+// or a promise of it. A chaincode can also have a migrate(stub) function called
+// by the upgrade transaction which replaces its code with this one.
+//
+// The peer passes its address with the -peer.address argument and the name of
+// the chaincode in the CORE_CHAINCODE_ID_NAME environment variable. When the
+// chaincode is run by the user in development mode, CORE_PEER_ADDRESS can be
+// set instead of the argument.
+
+'use strict';
+
+var fs = require('fs');
+var path = require('path');
+var grpc = require('grpc');
+
+var Handler = require('./handler.js');
+
+var _proto = grpc.load({root: path.join(__dirname, '..', 'protos'), file: 'chaincode.proto'}).protos;
+
+function getPeerAddress() {
+    var peerAddress = process.env.CORE_PEER_ADDRESS;
+    process.argv.forEach(function(arg) {
+        if (arg.indexOf('-peer.address=') === 0) {
+            peerAddress = arg.substring('-peer.address='.length);
+        }
+    });
+    return peerAddress;
+}
+
+// readFile returns the content of the file named by the environment variable
+// env, null if it is not set
+function readFile(env) {
+    return process.env[env] ? fs.readFileSync(process.env[env]) : null;
+}
+
+// newPeerClient connects to the peer, over TLS if CORE_PEER_TLS_ENABLED is
+// true, like the Go shim configured through core.yaml. The certificate and key
+// of CORE_PEER_TLS_CLIENTCERT_FILE and CORE_PEER_TLS_CLIENTKEY_FILE are
+// presented to a peer requiring client authentication.
+function newPeerClient(peerAddress) {
+    if (process.env.CORE_PEER_TLS_ENABLED !== 'true') {
+        return new _proto.ChaincodeSupport(peerAddress, grpc.credentials.createInsecure());
+    }
+    var credentials = grpc.credentials.createSsl(readFile('CORE_PEER_TLS_ROOTCERT_FILE'),
+        readFile('CORE_PEER_TLS_CLIENTKEY_FILE'), readFile('CORE_PEER_TLS_CLIENTCERT_FILE'));
+    var options = {};
+    if (process.env.CORE_PEER_TLS_SERVERHOSTOVERRIDE) {
+        options['grpc.ssl_target_name_override'] = process.env.CORE_PEER_TLS_SERVERHOSTOVERRIDE;
+    }
+    return new _proto.ChaincodeSupport(peerAddress, credentials, options);
+}
+
+// start connects the chaincode to the peer and serves its invocations until
+// the peer closes the stream
+function start(chaincode) {
+    var peerAddress = getPeerAddress();
+    var name = process.env.CORE_CHAINCODE_ID_NAME;
+    if (!peerAddress || !name) {
+        throw new Error('peer address and chaincode name must be set to start the chaincode');
+    }
+    var handler = new Handler(chaincode, _proto);
+    handler.register(newPeerClient(peerAddress).register(), name);
+}
+
+module.exports.start = start;
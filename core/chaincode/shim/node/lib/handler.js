@@ -0,0 +1,152 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+
+// Handler speaks the shim protocol with the peer over the Register stream, as
+// the handler of the Go shim does. The requests a transaction or query makes
+// to the peer are matched with their responses by UUID.
+
+'use strict';
+
+var ChaincodeStub = require('./stub.js');
+
+// function of the INIT message sent by an upgrade transaction, see
+// protos.ChaincodeMigrateFunction
+var MIGRATE_FUNCTION = '__migrate';
+
+function Handler(chaincode, proto) {
+    this.chaincode = chaincode;
+    this.proto = proto;
+    this.Type = proto.ChaincodeMessage.Type;
+    this.pending = {};
+}
+
+Handler.prototype.register = function(stream, name) {
+    var self = this;
+    self.stream = stream;
+    stream.on('data', function(msg) {
+        self.handleMessage(msg);
+    });
+    stream.on('error', function(err) {
+        console.error('Stream with peer failed: ' + err);
+        self.close(err);
+    });
+    stream.on('end', function() {
+        self.close(new Error('stream with peer closed'));
+    });
+
+    var id = new self.proto.ChaincodeID({name: name});
+    self.send({type: self.Type.REGISTER, payload: id.toBuffer()});
+};
+
+Handler.prototype.send = function(msg) {
+    this.stream.write(msg);
+};
+
+Handler.prototype.close = function(err) {
+    var pending = this.pending;
+    this.pending = {};
+    Object.keys(pending).forEach(function(uuid) {
+        pending[uuid].reject(err);
+    });
+};
+
+Handler.prototype.handleMessage = function(msg) {
+    var Type = this.Type;
+    switch (msg.type) {
+    case Type.REGISTERED:
+    case Type.READY:
+        break;
+    case Type.INIT:
+    case Type.TRANSACTION:
+    case Type.QUERY:
+        this.execute(msg);
+        break;
+    case Type.RESPONSE:
+    case Type.ERROR:
+        var request = this.pending[msg.uuid];
+        if (!request) {
+            console.warn('[' + msg.uuid + ']Received response with no pending request');
+            break;
+        }
+        delete this.pending[msg.uuid];
+        if (msg.type === Type.ERROR) {
+            request.reject(new Error(msg.payload.toString()));
+        } else {
+            request.resolve(msg.payload);
+        }
+        break;
+    default:
+        console.warn('[' + msg.uuid + ']Received unexpected message ' + msg.type);
+    }
+};
+
+// execute runs the INIT, TRANSACTION or QUERY message on the chaincode and
+// sends the result back to the peer
+Handler.prototype.execute = function(msg) {
+    var self = this;
+    var Type = self.Type;
+    var isQuery = msg.type === Type.QUERY;
+    var stub = new ChaincodeStub(msg.uuid, self, !isQuery);
+
+    new Promise(function(resolve) {
+        var input = self.proto.ChaincodeInput.decode(msg.payload);
+        var args = input.args || [];
+        switch (msg.type) {
+        case Type.INIT:
+            if (input.function === MIGRATE_FUNCTION) {
+                resolve(self.chaincode.migrate ? self.chaincode.migrate(stub) : null);
+            } else {
+                resolve(self.chaincode.init(stub, input.function, args));
+            }
+            break;
+        case Type.TRANSACTION:
+            resolve(self.chaincode.invoke(stub, input.function, args));
+            break;
+        default:
+            resolve(self.chaincode.query(stub, input.function, args));
+        }
+    }).then(function(res) {
+        var result = {type: isQuery ? Type.QUERY_COMPLETED : Type.COMPLETED, uuid: msg.uuid};
+        if (res !== null && res !== undefined) {
+            result.payload = Buffer.isBuffer(res) ? res : new Buffer(String(res));
+        }
+        if (!isQuery && stub.event) {
+            result.chaincodeEvent = stub.event;
+        }
+        self.send(result);
+    }, function(err) {
+        var message = err && err.message ? err.message : String(err);
+        self.send({type: isQuery ? Type.QUERY_ERROR : Type.ERROR, payload: new Buffer(message), uuid: msg.uuid});
+    });
+};
+
+// request sends a state request of the transaction or query to the peer and
+// returns a promise of the payload of its response. A transaction makes one
+// request at a time.
+Handler.prototype.request = function(type, payload, uuid) {
+    var self = this;
+    return new Promise(function(resolve, reject) {
+        if (self.pending[uuid]) {
+            reject(new Error('Another state request pending for this Uuid. Cannot process.'));
+            return;
+        }
+        self.pending[uuid] = {resolve: resolve, reject: reject};
+        self.send({type: type, payload: payload, uuid: uuid});
+    });
+};
+
+module.exports = Handler;
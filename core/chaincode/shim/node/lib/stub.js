@@ -0,0 +1,81 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+
+// ChaincodeStub is the chaincode's view of the transaction or query it
+// executes, through which it reads and writes its state. Its functions return
+// promises.
+
+'use strict';
+
+function ChaincodeStub(uuid, handler, isTransaction) {
+    this.uuid = uuid;
+    this.handler = handler;
+    this.isTransaction = isTransaction;
+    this.event = null;
+}
+
+function toBuffer(value) {
+    return Buffer.isBuffer(value) ? value : new Buffer(String(value));
+}
+
+ChaincodeStub.prototype.checkTransaction = function(operation) {
+    if (!this.isTransaction) {
+        return Promise.reject(new Error('Cannot ' + operation + ' in query context'));
+    }
+    return null;
+};
+
+// getState returns a promise of the value of the key in the state as a
+// Buffer, empty if the key is not set
+ChaincodeStub.prototype.getState = function(key) {
+    var Type = this.handler.Type;
+    return this.handler.request(Type.GET_STATE, new Buffer(key), this.uuid);
+};
+
+// putState sets the value, a Buffer or a string, of the key in the state. Only
+// transactions can change the state.
+ChaincodeStub.prototype.putState = function(key, value) {
+    var rejected = this.checkTransaction('put state');
+    if (rejected) {
+        return rejected;
+    }
+    var Type = this.handler.Type;
+    var info = new this.handler.proto.PutStateInfo({key: key, value: toBuffer(value)});
+    return this.handler.request(Type.PUT_STATE, info.toBuffer(), this.uuid);
+};
+
+// delState deletes the key from the state. Only transactions can change the
+// state.
+ChaincodeStub.prototype.delState = function(key) {
+    var rejected = this.checkTransaction('del state');
+    if (rejected) {
+        return rejected;
+    }
+    var Type = this.handler.Type;
+    return this.handler.request(Type.DEL_STATE, new Buffer(key), this.uuid);
+};
+
+// setEvent sets the event returned to the peer with the completed
+// transaction, replacing any event set before
+ChaincodeStub.prototype.setEvent = function(name, payload) {
+    if (!name) {
+        throw new Error('Event name can not be empty');
+    }
+    this.event = {eventName: name, payload: toBuffer(payload)};
+};
+
+module.exports = ChaincodeStub;
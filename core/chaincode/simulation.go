@@ -0,0 +1,237 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chaincode
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
+
+	"github.com/hyperledger/fabric/core/ledger"
+	"github.com/hyperledger/fabric/core/tracing"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// Parallel execution
+//
+// The transactions of a batch are executed in runs of consecutive invoke transactions. The
+// transactions of a run are first simulated concurrently against the state of the batch as it is
+// before the run (see 'ledger.TxSimulator'). The transactions invoking different chaincodes are
+// simulated in parallel, while the ones invoking the same chaincode are simulated one after the
+// other, since a chaincode executes one transaction at a time. Then, in the order of the batch, the
+// changes of each transaction are applied if the values it read are still current. Otherwise the
+// transaction conflicts with an earlier one of the run, and it is executed again, serially, like
+// the transactions whose simulation failed or used what a simulation does not capture (private
+// state, calls to other chaincodes). The outcome is the one of executing the batch serially.
+// Deploy and upgrade transactions, which change the code of the chaincodes, are always executed
+// serially.
+
+// simulation is the outcome of the simulation of a transaction
+type simulation struct {
+	sim     *ledger.TxSimulator
+	payload []byte
+	ccevent *pb.ChaincodeEvent
+	err     error
+}
+
+// executeTransactionsInParallel executes the transactions which are not already rejected in txerrs
+func executeTransactionsInParallel(ctxt context.Context, chain *ChaincodeSupport, lgr *ledger.Ledger, xacts []*pb.Transaction, ccevents []*pb.ChaincodeEvent, txerrs []error) {
+	for start := 0; start < len(xacts); {
+		end := start
+		for end < len(xacts) && xacts[end].Type == pb.Transaction_CHAINCODE_INVOKE && txerrs[end] == nil {
+			end++
+		}
+		if end-start < 2 {
+			if end == start {
+				end++
+			}
+			for i := start; i < end; i++ {
+				if txerrs[i] == nil {
+					_, ccevents[i], txerrs[i] = Execute(ctxt, chain, xacts[i])
+				}
+			}
+		} else {
+			executeRun(ctxt, chain, lgr, xacts[start:end], ccevents[start:end], txerrs[start:end])
+		}
+		start = end
+	}
+}
+
+// executeRun executes a run of invoke transactions by simulating them concurrently
+// and applying their changes in order, executing again the ones that conflict
+func executeRun(ctxt context.Context, chain *ChaincodeSupport, lgr *ledger.Ledger, xacts []*pb.Transaction, ccevents []*pb.ChaincodeEvent, txerrs []error) {
+	simulations := simulateTransactions(ctxt, chain, lgr, xacts)
+	reexecuted := 0
+	for i, t := range xacts {
+		if s := simulations[i]; s != nil && s.sim != nil && s.err == nil {
+			stale, err := s.sim.IsStale()
+			if err == nil && !stale {
+				if txerrs[i] = lgr.ApplyTxSimulation(s.sim); txerrs[i] == nil {
+					ccevents[i] = s.ccevent
+				}
+				continue
+			}
+		}
+		reexecuted++
+		_, ccevents[i], txerrs[i] = Execute(ctxt, chain, t)
+	}
+	chaincodeLogger.Debug("Executed %d transactions in parallel, %d of which executed again", len(xacts), reexecuted)
+}
+
+// simulateTransactions simulates the transactions, the ones invoking the same chaincode one
+// after the other. The simulation of a transaction is nil if the transaction is not simulated
+func simulateTransactions(ctxt context.Context, chain *ChaincodeSupport, lgr *ledger.Ledger, xacts []*pb.Transaction) []*simulation {
+	simulations := make([]*simulation, len(xacts))
+	decrypted := make([]*pb.Transaction, len(xacts))
+	var groups [][]int
+	byChaincode := make(map[string]int)
+	seen := make(map[string]bool)
+	for i, t := range xacts {
+		if seen[t.Uuid] {
+			// executed serially, after the first transaction with the same uuid
+			continue
+		}
+		seen[t.Uuid] = true
+		decrypted[i] = t
+		if secHelper := chain.getSecHelper(); nil != secHelper {
+			var err error
+			// Note that the decrypted transaction is a deep clone of the original one
+			if decrypted[i], err = secHelper.TransactionPreExecution(t); err != nil {
+				simulations[i] = &simulation{err: err}
+				continue
+			}
+		}
+		name, err := getInvokedChaincode(decrypted[i])
+		if err == nil {
+			err = checkStateNotEncrypted(lgr, name)
+		}
+		if err != nil {
+			simulations[i] = &simulation{err: err}
+			continue
+		}
+		g, ok := byChaincode[name]
+		if !ok {
+			g = len(groups)
+			byChaincode[name] = g
+			groups = append(groups, nil)
+		}
+		groups[g] = append(groups[g], i)
+	}
+
+	work := make(chan []int)
+	var wg sync.WaitGroup
+	for w := 0; w < chain.simulationWorkers && w < len(groups); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for group := range work {
+				for _, i := range group {
+					simulations[i] = simulateTransaction(ctxt, chain, lgr.NewTxSimulator(decrypted[i].Uuid), decrypted[i])
+				}
+			}
+		}()
+	}
+	for _, group := range groups {
+		work <- group
+	}
+	close(work)
+	wg.Wait()
+	return simulations
+}
+
+// simulateTransaction simulates the invoke transaction t, which has been decrypted, with sim
+func simulateTransaction(ctxt context.Context, chain *ChaincodeSupport, sim *ledger.TxSimulator, t *pb.Transaction) *simulation {
+	s := &simulation{sim: sim}
+	defer sim.Done()
+	span := tracing.StartTxSpan(t.Uuid, "simulate")
+	defer func() {
+		span.SetError(s.err)
+		span.Finish()
+	}()
+
+	//transient data is not covered by the signature, check it against the signed hash
+	if s.err = t.VerifyTransient(); s.err != nil {
+		return s
+	}
+	cID, cMsg, err := chain.Launch(ctxt, t)
+	if err != nil {
+		s.err = fmt.Errorf("Failed to launch chaincode spec(%s)", err)
+		return s
+	}
+	ccMsg, err := createTransactionMessage(t.Uuid, cMsg)
+	if err != nil {
+		s.err = fmt.Errorf("Failed to transaction message(%s)", err)
+		return s
+	}
+	resp, err := chain.Execute(ctxt, cID.Name, ccMsg, chain.GetExecuteTimeout(cID.Name), t)
+	if err != nil {
+		s.err = fmt.Errorf("Failed to execute transaction or query(%s)", err)
+	} else if resp == nil {
+		s.err = fmt.Errorf("Failed to receive a response for (%s)", t.Uuid)
+	} else if resp.Type == pb.ChaincodeMessage_COMPLETED {
+		s.payload = resp.Payload
+		s.ccevent = chaincodeEvent(cID, t, resp)
+	} else {
+		s.err = fmt.Errorf("Transaction or query returned with failure: %s", string(resp.Payload))
+	}
+	return s
+}
+
+// SimulateTransaction simulates the invoke transaction t against the committed state, as a dry
+// run: the transaction is not submitted, and the changes it would make are returned along with
+// the keys it read and the response and event of the chaincode. The simulation waits for the
+// chaincode to complete the transaction it may be executing
+func SimulateTransaction(ctxt context.Context, chain *ChaincodeSupport, t *pb.Transaction) (*pb.SimulationResult, error) {
+	if t.Type != pb.Transaction_CHAINCODE_INVOKE {
+		return nil, fmt.Errorf("Only invoke transactions can be simulated, got %s", t.Type)
+	}
+	lgr, err := ledger.GetLedger()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get handle to ledger (%s)", err)
+	}
+	if secHelper := chain.getSecHelper(); nil != secHelper {
+		// Note that t is now decrypted and is a deep clone of the original input t
+		if t, err = secHelper.TransactionPreExecution(t); err != nil {
+			return nil, err
+		}
+	}
+
+	result := &pb.SimulationResult{Uuid: t.Uuid, BlockHeight: lgr.GetBlockchainSize()}
+	s := simulateTransaction(ctxt, chain, lgr.NewCommittedTxSimulator(t.Uuid), t)
+	if s.err != nil {
+		result.Response = &pb.Response{Status: pb.Response_FAILURE, Msg: []byte(s.err.Error())}
+	} else {
+		result.Response = &pb.Response{Status: pb.Response_SUCCESS, Msg: s.payload}
+		result.ChaincodeEvent = s.ccevent
+	}
+	result.Reads, result.RangeReads, result.Writes = s.sim.GetReadWriteSet()
+	return result, nil
+}
+
+// getInvokedChaincode returns the name of the chaincode invoked by the transaction
+func getInvokedChaincode(t *pb.Transaction) (string, error) {
+	ci := &pb.ChaincodeInvocationSpec{}
+	if err := proto.Unmarshal(t.Payload, ci); err != nil {
+		return "", err
+	}
+	if ci.ChaincodeSpec == nil || ci.ChaincodeSpec.ChaincodeID == nil {
+		return "", fmt.Errorf("Chaincode not given in transaction %s", t.Uuid)
+	}
+	return ci.ChaincodeSpec.ChaincodeID.Name, nil
+}
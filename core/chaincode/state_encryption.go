@@ -0,0 +1,152 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chaincode
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/crypto"
+	"github.com/hyperledger/fabric/core/crypto/utils"
+	"github.com/hyperledger/fabric/core/ledger"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// Encrypted chaincode state
+//
+// A chaincode can be instantiated with the enrollment IDs of the validating peers authorized to
+// read its state (ChaincodeDeploymentSpec.StatePeers). The peer deploying it generates a key for
+// the chaincode and wraps it for each of them under the enrollment certificate the ECA issued
+// them, and the wrapped keys are recorded with the instantiation. The values the chaincode puts
+// in its state are encrypted with the key before they reach the ledger, and decrypted when it
+// gets them back, so that the other validating peers only ever store ciphertext. The keys of the
+// state are not encrypted.
+//
+// As the other validating peers can not execute the chaincode, its state is only changed by
+// endorsed transactions (see endorsement.go) simulated by authorized peers: the deploy does not
+// run its Init, and its invoke and upgrade transactions are rejected. Queries are answered by the
+// authorized peers only.
+
+// getStateKeys returns the wrapped keys of the state of the chaincode deployed by cds, nil if
+// its state is not encrypted
+func getStateKeys(cds *pb.ChaincodeDeploymentSpec) (*pb.ChaincodeStateKeys, error) {
+	if len(cds.StatePeers) > 0 && len(cds.StateKeys.GetKeys()) == 0 {
+		return nil, fmt.Errorf("The state key of chaincode %s is not wrapped for its state peers", cds.ChaincodeSpec.ChaincodeID.Name)
+	}
+	if len(cds.StateKeys.GetKeys()) == 0 {
+		return nil, nil
+	}
+	return cds.StateKeys, nil
+}
+
+// checkStateNotEncrypted returns an error if the state of the chaincode is encrypted, in which
+// case only endorsed transactions may change it
+func checkStateNotEncrypted(lgr *ledger.Ledger, chaincode string) error {
+	instantiation, err := lgr.GetChaincodeInstantiation(chaincode, false)
+	if err != nil {
+		return err
+	}
+	if instantiation.GetStateKeys() != nil {
+		return fmt.Errorf("The state of chaincode %s is encrypted, it is only changed by endorsed transactions", chaincode)
+	}
+	return nil
+}
+
+// checkStateEndorsers checks that the endorsers of a transaction of the chaincode are
+// authorized to read its state, if encrypted
+func checkStateEndorsers(lgr *ledger.Ledger, chaincode string, endorsements []*pb.SimulationResult) error {
+	instantiation, err := lgr.GetChaincodeInstantiation(chaincode, false)
+	if err != nil {
+		return err
+	}
+	keys := instantiation.GetStateKeys()
+	if keys == nil {
+		return nil
+	}
+	for _, endorsement := range endorsements {
+		authorized := false
+		for _, k := range keys.Keys {
+			if len(endorsement.PkiID) > 0 && bytes.Equal(k.PkiID, endorsement.PkiID) {
+				authorized = true
+				break
+			}
+		}
+		if !authorized {
+			return fmt.Errorf("Endorser %s is not authorized to read the state of chaincode %s", endorsement.PeerID.Name, chaincode)
+		}
+	}
+	return nil
+}
+
+// getStateEncryptor returns the encryptor of the state of the chaincode of the handler, nil if
+// its state is not encrypted
+func (handler *Handler) getStateEncryptor() (crypto.StateEncryptor, error) {
+	handler.stateEncryptorLock.Lock()
+	defer handler.stateEncryptorLock.Unlock()
+	if handler.stateEncryptorLoaded {
+		return handler.stateEncryptor, handler.stateEncryptorErr
+	}
+
+	lgr, err := ledger.GetLedger()
+	if err != nil {
+		return nil, err
+	}
+	chaincode := handler.ChaincodeID.Name
+	instantiation, err := lgr.GetChaincodeInstantiation(chaincode, false)
+	if err != nil {
+		return nil, err
+	}
+	if keys := instantiation.GetStateKeys(); keys != nil {
+		if secHelper := handler.chaincodeSupport.getSecHelper(); secHelper == nil {
+			handler.stateEncryptorErr = fmt.Errorf("The state of chaincode %s is encrypted, security is not enabled", chaincode)
+		} else if handler.stateEncryptor, err = secHelper.GetChaincodeStateEncryptor(keys); err == utils.ErrStateKeyNotFound {
+			handler.stateEncryptorErr = fmt.Errorf("This peer is not authorized to read the state of chaincode %s", chaincode)
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	handler.stateEncryptorLoaded = true
+	return handler.stateEncryptor, handler.stateEncryptorErr
+}
+
+// encryptState encrypts a value the chaincode puts in its state: with the key of the
+// transaction if confidential, then with the key of the chaincode if its state is encrypted
+func (handler *Handler) encryptState(uuid string, value []byte) ([]byte, error) {
+	value, err := handler.encrypt(uuid, value)
+	if err != nil {
+		return nil, err
+	}
+	enc, err := handler.getStateEncryptor()
+	if err != nil || enc == nil {
+		return value, err
+	}
+	return enc.Encrypt(value)
+}
+
+// decryptState decrypts a value of the state of the chaincode, nil if the key is not set
+func (handler *Handler) decryptState(uuid string, value []byte) ([]byte, error) {
+	enc, err := handler.getStateEncryptor()
+	if err != nil {
+		return nil, err
+	}
+	if enc != nil && value != nil {
+		if value, err = enc.Decrypt(value); err != nil {
+			return nil, fmt.Errorf("Failed to decrypt the state of chaincode %s(%s)", handler.ChaincodeID.Name, err)
+		}
+	}
+	return handler.decrypt(uuid, value)
+}
@@ -0,0 +1,110 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package comm
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// codec is the protobuf codec of the gRPC servers and clients of the peer. It bounds the
+// size of the messages sent and received, failing with an error naming the limit rather
+// than an opaque transport error, and optionally compresses the messages with gzip.
+// A compressed message is told from a protobuf one by the gzip magic number, which is not a
+// valid start of a protobuf message (wire type 7): the peer accepts both whatever its own
+// setting
+type codec struct {
+	maxSendMsgSize       int // 0 for unlimited
+	maxRecvMsgSize       int // 0 for unlimited
+	compress             bool
+	compressionThreshold int // the messages smaller than this are sent uncompressed
+}
+
+var gzipMagic = []byte{0x1f, 0x8b}
+
+func newCodec(config grpcConfig) *codec {
+	return &codec{
+		maxSendMsgSize:       config.maxSendMsgSize,
+		maxRecvMsgSize:       config.maxRecvMsgSize,
+		compress:             config.compression,
+		compressionThreshold: config.compressionThreshold,
+	}
+}
+
+func (c *codec) Marshal(v interface{}) ([]byte, error) {
+	data, err := proto.Marshal(v.(proto.Message))
+	if err != nil {
+		return nil, err
+	}
+	if c.maxSendMsgSize > 0 && len(data) > c.maxSendMsgSize {
+		return nil, grpc.Errorf(codes.ResourceExhausted, "grpc: message of %d bytes exceeds the maximum size sent of %d bytes (peer.grpc.maxSendMsgSize)", len(data), c.maxSendMsgSize)
+	}
+	if !c.compress || len(data) < c.compressionThreshold {
+		return data, nil
+	}
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	// incompressible data is sent as is
+	if buf.Len() >= len(data) {
+		return data, nil
+	}
+	return buf.Bytes(), nil
+}
+
+func (c *codec) Unmarshal(data []byte, v interface{}) error {
+	if c.maxRecvMsgSize > 0 && len(data) > c.maxRecvMsgSize {
+		return c.errTooLarge(len(data))
+	}
+	if bytes.HasPrefix(data, gzipMagic) {
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return grpc.Errorf(codes.Internal, "grpc: error decompressing message: %s", err)
+		}
+		// the limit applies to the decompressed message, which is read no further
+		var lr io.Reader = r
+		if c.maxRecvMsgSize > 0 {
+			lr = io.LimitReader(r, int64(c.maxRecvMsgSize)+1)
+		}
+		if data, err = ioutil.ReadAll(lr); err != nil {
+			return grpc.Errorf(codes.Internal, "grpc: error decompressing message: %s", err)
+		}
+		if c.maxRecvMsgSize > 0 && len(data) > c.maxRecvMsgSize {
+			return c.errTooLarge(len(data))
+		}
+	}
+	return proto.Unmarshal(data, v.(proto.Message))
+}
+
+func (c *codec) errTooLarge(size int) error {
+	return grpc.Errorf(codes.ResourceExhausted, "grpc: message of at least %d bytes exceeds the maximum size received of %d bytes (peer.grpc.maxRecvMsgSize)", size, c.maxRecvMsgSize)
+}
+
+func (c *codec) String() string {
+	return "proto"
+}
@@ -0,0 +1,82 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package comm
+
+import (
+	"bytes"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+func TestCodec(t *testing.T) {
+	msg := &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_PUT_STATE, Uuid: "uuid", Payload: bytes.Repeat([]byte("state"), 1000)}
+	plain := &codec{}
+	compressing := &codec{compress: true, compressionThreshold: 1024}
+
+	plainData, err := plain.Marshal(msg)
+	if err != nil {
+		t.Fatalf("Error marshalling: %s", err)
+	}
+	compressedData, err := compressing.Marshal(msg)
+	if err != nil {
+		t.Fatalf("Error marshalling: %s", err)
+	}
+	if len(compressedData) >= len(plainData) {
+		t.Fatalf("Expected the message to be compressed, got %d bytes for %d", len(compressedData), len(plainData))
+	}
+	// both codecs read both encodings
+	for _, c := range []*codec{plain, compressing} {
+		for _, data := range [][]byte{plainData, compressedData} {
+			got := &pb.ChaincodeMessage{}
+			if err := c.Unmarshal(data, got); err != nil {
+				t.Fatalf("Error unmarshalling: %s", err)
+			}
+			if got.Uuid != msg.Uuid || !bytes.Equal(got.Payload, msg.Payload) {
+				t.Fatalf("Expected %v, got %v", msg, got)
+			}
+		}
+	}
+
+	// the small messages are sent uncompressed
+	small := &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_GET_STATE, Uuid: "uuid"}
+	smallData, _ := compressing.Marshal(small)
+	if bytes.HasPrefix(smallData, gzipMagic) {
+		t.Fatal("Expected the message below the threshold not to be compressed")
+	}
+
+	limited := &codec{maxSendMsgSize: 1024, maxRecvMsgSize: 1024}
+	if _, err := limited.Marshal(msg); grpc.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("Expected sending the message beyond the limit to fail, got %v", err)
+	}
+	if err := limited.Unmarshal(plainData, &pb.ChaincodeMessage{}); grpc.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("Expected receiving the message beyond the limit to fail, got %v", err)
+	}
+	// the limit applies to the decompressed message
+	if len(compressedData) > limited.maxRecvMsgSize {
+		t.Fatalf("Expected the compressed message to fit the limit, got %d bytes", len(compressedData))
+	}
+	if err := limited.Unmarshal(compressedData, &pb.ChaincodeMessage{}); grpc.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("Expected receiving the decompressed message beyond the limit to fail, got %v", err)
+	}
+	if err := limited.Unmarshal(smallData, &pb.ChaincodeMessage{}); err != nil {
+		t.Fatalf("Error unmarshalling the message within the limit: %s", err)
+	}
+}
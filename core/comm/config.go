@@ -17,6 +17,8 @@ limitations under the License.
 package comm
 
 import (
+	"time"
+
 	"github.com/spf13/viper"
 )
 
@@ -25,6 +27,16 @@ var configurationCached = false
 
 // Cached values of commonly used configuration constants.
 var tlsEnabled bool
+var grpcConf grpcConfig
+
+// grpcConfig is the configuration of the gRPC servers and clients of the peer, peer.grpc
+type grpcConfig struct {
+	maxSendMsgSize       int
+	maxRecvMsgSize       int
+	compression          bool
+	compressionThreshold int
+	keepalive            time.Duration
+}
 
 // CacheConfiguration computes and caches commonly-used constants and
 // computed constants as package variables. Routines which were previously
@@ -32,6 +44,14 @@ func CacheConfiguration() (err error) {
 
 	tlsEnabled = viper.GetBool("peer.tls.enabled")
 
+	grpcConf = grpcConfig{
+		maxSendMsgSize:       viper.GetInt("peer.grpc.maxSendMsgSize"),
+		maxRecvMsgSize:       viper.GetInt("peer.grpc.maxRecvMsgSize"),
+		compression:          viper.GetBool("peer.grpc.compression.enabled"),
+		compressionThreshold: viper.GetInt("peer.grpc.compression.threshold"),
+		keepalive:            viper.GetDuration("peer.grpc.keepalive"),
+	}
+
 	configurationCached = true
 
 	return
@@ -51,3 +71,11 @@ func TLSEnabled() bool {
 	}
 	return tlsEnabled
 }
+
+// getGRPCConfig returns the cached peer.grpc configuration
+func getGRPCConfig() grpcConfig {
+	if !configurationCached {
+		cacheConfiguration()
+	}
+	return grpcConf
+}
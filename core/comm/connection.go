@@ -1,6 +1,7 @@
 package comm
 
 import (
+	"net"
 	"time"
 
 	"google.golang.org/grpc"
@@ -16,6 +17,7 @@ const defaultTimeout = time.Second * 3
 var commLogger = logging.MustGetLogger("comm")
 
 // NewClientConnectionWithAddress Returns a new grpc.ClientConn to the given address.
+// The connection enforces the message size limits, compression and keepalive of peer.grpc
 func NewClientConnectionWithAddress(peerAddress string, block bool, tslEnabled bool, creds credentials.TransportAuthenticator) (*grpc.ClientConn, error) {
 	var opts []grpc.DialOption
 	if tslEnabled {
@@ -24,6 +26,12 @@ func NewClientConnectionWithAddress(peerAddress string, block bool, tslEnabled b
 		opts = append(opts, grpc.WithInsecure())
 	}
 	opts = append(opts, grpc.WithTimeout(defaultTimeout))
+	opts = append(opts, grpc.WithCodec(newCodec(getGRPCConfig())))
+	if period := getGRPCConfig().keepalive; period > 0 {
+		opts = append(opts, grpc.WithDialer(func(addr string, timeout time.Duration) (net.Conn, error) {
+			return (&net.Dialer{Timeout: timeout, KeepAlive: period}).Dial("tcp", addr)
+		}))
+	}
 	if block {
 		opts = append(opts, grpc.WithBlock())
 	}
@@ -34,21 +42,16 @@ func NewClientConnectionWithAddress(peerAddress string, block bool, tslEnabled b
 	return conn, err
 }
 
-// InitTLSForPeer returns TLS credentials for peer
+// InitTLSForPeer returns TLS credentials for peer, which verify the server against
+// peer.tls.rootcert.file and present the client certificate of the peer if any
 func InitTLSForPeer() credentials.TransportAuthenticator {
 	var sn string
 	if viper.GetString("peer.tls.serverhostoverride") != "" {
 		sn = viper.GetString("peer.tls.serverhostoverride")
 	}
-	var creds credentials.TransportAuthenticator
-	if viper.GetString("peer.tls.cert.file") != "" {
-		var err error
-		creds, err = credentials.NewClientTLSFromFile(viper.GetString("peer.tls.cert.file"), sn)
-		if err != nil {
-			grpclog.Fatalf("Failed to create TLS credentials %v", err)
-		}
-	} else {
-		creds = credentials.NewClientTLSFromCert(nil, sn)
+	store, err := getCertStore()
+	if err != nil {
+		grpclog.Fatalf("Failed to create TLS credentials %v", err)
 	}
-	return creds
+	return store.clientCredentials(sn)
 }
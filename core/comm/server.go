@@ -0,0 +1,68 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package comm
+
+import (
+	"net"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// ServerOptions returns the options of the gRPC servers of the peer: the codec enforcing
+// the message size limits and compression of peer.grpc, and the TLS credentials of the
+// peer when TLS is enabled
+func ServerOptions() ([]grpc.ServerOption, error) {
+	opts := []grpc.ServerOption{grpc.CustomCodec(newCodec(getGRPCConfig()))}
+	if TLSEnabled() {
+		creds, err := GetServerCredentials()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, grpc.Creds(creds))
+	}
+	return opts, nil
+}
+
+// Listen announces on the TCP address of a gRPC server of the peer. The accepted
+// connections are kept alive every peer.grpc.keepalive if set, so that the dead clients
+// are detected and their streams released
+func Listen(address string) (net.Listener, error) {
+	lis, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	if period := getGRPCConfig().keepalive; period > 0 {
+		return keepAliveListener{lis.(*net.TCPListener), period}, nil
+	}
+	return lis, nil
+}
+
+type keepAliveListener struct {
+	*net.TCPListener
+	period time.Duration
+}
+
+func (l keepAliveListener) Accept() (net.Conn, error) {
+	conn, err := l.AcceptTCP()
+	if err != nil {
+		return nil, err
+	}
+	conn.SetKeepAlive(true)
+	conn.SetKeepAlivePeriod(l.period)
+	return conn, nil
+}
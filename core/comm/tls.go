@@ -0,0 +1,257 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package comm
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+	"google.golang.org/grpc/credentials"
+)
+
+// tlsFiles are the files the TLS certificates and keys of the peer are read from
+type tlsFiles struct {
+	serverCert      string
+	serverKey       string
+	clientCert      string
+	clientKey       string
+	rootCerts       []string // verify the servers the peer connects to
+	clientRootCerts []string // verify the clients of the servers of the peer
+}
+
+func tlsFilesFromConfig() tlsFiles {
+	files := tlsFiles{
+		serverCert: viper.GetString("peer.tls.cert.file"),
+		serverKey:  viper.GetString("peer.tls.key.file"),
+		clientCert: viper.GetString("peer.tls.clientCert.file"),
+		clientKey:  viper.GetString("peer.tls.clientKey.file"),
+	}
+	if files.clientCert == "" && files.clientKey == "" {
+		files.clientCert, files.clientKey = files.serverCert, files.serverKey
+	}
+	// a self-signed server certificate is its own root
+	if rootCert := viper.GetString("peer.tls.rootcert.file"); rootCert != "" {
+		files.rootCerts = []string{rootCert}
+	} else if files.serverCert != "" {
+		files.rootCerts = []string{files.serverCert}
+	}
+	if files.clientRootCerts = viper.GetStringSlice("peer.tls.clientRootCAs.files"); len(files.clientRootCerts) == 0 {
+		files.clientRootCerts = files.rootCerts
+	}
+	return files
+}
+
+func (files tlsFiles) all() []string {
+	all := append([]string{files.serverCert, files.serverKey, files.clientCert, files.clientKey}, files.rootCerts...)
+	return append(all, files.clientRootCerts...)
+}
+
+// certStore holds the TLS certificates of the peer. It reloads them when their files
+// change, so that they can be renewed without restarting the peer: the connections
+// established from then on use the new certificates
+type certStore struct {
+	sync.RWMutex
+	files              tlsFiles
+	clientAuthRequired bool
+	serverCert         *tls.Certificate // nil if no server certificate is configured
+	clientCert         *tls.Certificate // nil if no client certificate is configured
+	rootCAs            *x509.CertPool   // nil for the roots of the host
+	clientRootCAs      *x509.CertPool
+	modTimes           map[string]time.Time
+}
+
+var (
+	theCertStore    *certStore
+	theCertStoreErr error
+	certStoreOnce   sync.Once
+)
+
+// getCertStore returns the certificate store of the peer, loaded from the configuration
+// on the first call. The files are checked for changes every peer.tls.reloadPeriod if set
+func getCertStore() (*certStore, error) {
+	certStoreOnce.Do(func() {
+		theCertStore, theCertStoreErr = newCertStore(tlsFilesFromConfig(), viper.GetBool("peer.tls.clientAuthRequired"))
+		if period := viper.GetDuration("peer.tls.reloadPeriod"); theCertStoreErr == nil && period > 0 {
+			go theCertStore.watch(period)
+		}
+	})
+	return theCertStore, theCertStoreErr
+}
+
+func newCertStore(files tlsFiles, clientAuthRequired bool) (*certStore, error) {
+	if clientAuthRequired && len(files.clientRootCerts) == 0 {
+		return nil, errors.New("TLS client authentication requires peer.tls.clientRootCAs.files")
+	}
+	s := &certStore{files: files, clientAuthRequired: clientAuthRequired}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// load reads the certificates from their files. They are replaced only if all of them could
+// be read
+func (s *certStore) load() error {
+	modTimes := make(map[string]time.Time)
+	for _, file := range s.files.all() {
+		if file == "" {
+			continue
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			return fmt.Errorf("Error reading TLS file %s: %s", file, err)
+		}
+		modTimes[file] = info.ModTime()
+	}
+	serverCert, err := loadKeyPair(s.files.serverCert, s.files.serverKey)
+	if err != nil {
+		return err
+	}
+	clientCert, err := loadKeyPair(s.files.clientCert, s.files.clientKey)
+	if err != nil {
+		return err
+	}
+	rootCAs, err := loadCertPool(s.files.rootCerts)
+	if err != nil {
+		return err
+	}
+	clientRootCAs, err := loadCertPool(s.files.clientRootCerts)
+	if err != nil {
+		return err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	s.serverCert, s.clientCert = serverCert, clientCert
+	s.rootCAs, s.clientRootCAs = rootCAs, clientRootCAs
+	s.modTimes = modTimes
+	return nil
+}
+
+func loadKeyPair(certFile, keyFile string) (*tls.Certificate, error) {
+	if certFile == "" || keyFile == "" {
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("Error loading TLS certificate %s: %s", certFile, err)
+	}
+	return &cert, nil
+}
+
+func loadCertPool(files []string) (*x509.CertPool, error) {
+	if len(files) == 0 {
+		return nil, nil
+	}
+	pool := x509.NewCertPool()
+	for _, file := range files {
+		pem, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading TLS root certificates %s: %s", file, err)
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("No TLS root certificate found in %s", file)
+		}
+	}
+	return pool, nil
+}
+
+// reloadIfChanged reloads the certificates if one of their files has been modified, returns
+// whether they were reloaded
+func (s *certStore) reloadIfChanged() (bool, error) {
+	s.RLock()
+	changed := false
+	for file, modTime := range s.modTimes {
+		if info, err := os.Stat(file); err == nil && !info.ModTime().Equal(modTime) {
+			changed = true
+			break
+		}
+	}
+	s.RUnlock()
+	if !changed {
+		return false, nil
+	}
+	if err := s.load(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *certStore) watch(period time.Duration) {
+	for range time.Tick(period) {
+		if reloaded, err := s.reloadIfChanged(); err != nil {
+			commLogger.Error("Error reloading the TLS certificates, keeping the current ones: %s", err)
+		} else if reloaded {
+			commLogger.Info("TLS certificates reloaded")
+		}
+	}
+}
+
+// getConfigForClient returns the TLS configuration of a connection accepted by a server
+func (s *certStore) getConfigForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	s.RLock()
+	defer s.RUnlock()
+	config := &tls.Config{Certificates: []tls.Certificate{*s.serverCert}, NextProtos: []string{"h2"}}
+	if s.clientAuthRequired {
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+		config.ClientCAs = s.clientRootCAs
+	}
+	return config, nil
+}
+
+// getClientCertificate returns the certificate the peer presents to the servers it connects to
+func (s *certStore) getClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	s.RLock()
+	defer s.RUnlock()
+	if s.clientCert == nil {
+		return &tls.Certificate{}, nil
+	}
+	return s.clientCert, nil
+}
+
+func (s *certStore) serverCredentials() (credentials.TransportAuthenticator, error) {
+	s.RLock()
+	defer s.RUnlock()
+	if s.serverCert == nil {
+		return nil, errors.New("TLS requires peer.tls.cert.file and peer.tls.key.file")
+	}
+	return credentials.NewTLS(&tls.Config{GetConfigForClient: s.getConfigForClient}), nil
+}
+
+func (s *certStore) clientCredentials(serverName string) credentials.TransportAuthenticator {
+	s.RLock()
+	defer s.RUnlock()
+	return credentials.NewTLS(&tls.Config{ServerName: serverName, RootCAs: s.rootCAs, GetClientCertificate: s.getClientCertificate})
+}
+
+// GetServerCredentials returns the TLS credentials of the gRPC servers of the peer. The
+// servers require the clients (other peers, chaincodes, applications) to present a
+// certificate issued by one of peer.tls.clientRootCAs when peer.tls.clientAuthRequired is set
+func GetServerCredentials() (credentials.TransportAuthenticator, error) {
+	store, err := getCertStore()
+	if err != nil {
+		return nil, err
+	}
+	return store.serverCredentials()
+}
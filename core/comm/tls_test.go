@@ -0,0 +1,191 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package comm
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/credentials"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// newTestCert issues a certificate with the given serial number, self-signed if parent is nil
+func newTestCert(t *testing.T, serial int64, parent *testCert) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Error generating key: %s", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	signer := &testCert{cert: template, key: key}
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+	} else {
+		signer = parent
+	}
+	raw, err := x509.CreateCertificate(rand.Reader, template, signer.cert, &key.PublicKey, signer.key)
+	if err != nil {
+		t.Fatalf("Error creating certificate: %s", err)
+	}
+	cert, _ := x509.ParseCertificate(raw)
+	return &testCert{cert: cert, key: key}
+}
+
+// write writes the certificate and its key in PEM in dir, returns the paths of the files
+func (c *testCert) write(t *testing.T, dir string, name string) (string, string) {
+	certFile, keyFile := filepath.Join(dir, name+".pem"), filepath.Join(dir, name+".key")
+	keyDER, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatalf("Error marshalling key: %s", err)
+	}
+	if err := ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.cert.Raw}), 0600); err != nil {
+		t.Fatalf("Error writing certificate: %s", err)
+	}
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatalf("Error writing key: %s", err)
+	}
+	return certFile, keyFile
+}
+
+// handshake performs a TLS handshake between the credentials over a loopback connection,
+// returns the serial number of the certificate of the server
+func handshake(server, client credentials.TransportAuthenticator) (int64, error) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer lis.Close()
+	serverErr := make(chan error, 1)
+	go func() {
+		rawConn, err := lis.Accept()
+		if err != nil {
+			serverErr <- err
+			return
+		}
+		defer rawConn.Close()
+		conn, _, err := server.ServerHandshake(rawConn)
+		if err == nil {
+			// the client certificate is verified once the client has sent data in TLS 1.3
+			_, err = conn.Read(make([]byte, 1))
+		}
+		serverErr <- err
+	}()
+	rawConn, err := net.Dial("tcp", lis.Addr().String())
+	if err != nil {
+		return 0, err
+	}
+	defer rawConn.Close()
+	conn, _, err := client.ClientHandshake("localhost:30303", rawConn, time.Second)
+	if err != nil {
+		return 0, err
+	}
+	if _, err = conn.Write([]byte{0}); err != nil {
+		return 0, err
+	}
+	if err = <-serverErr; err != nil {
+		return 0, err
+	}
+	return conn.(*tls.Conn).ConnectionState().PeerCertificates[0].SerialNumber.Int64(), nil
+}
+
+func TestCertStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tls")
+	if err != nil {
+		t.Fatalf("Error creating temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	ca := newTestCert(t, 1, nil)
+	caFile, _ := ca.write(t, dir, "ca")
+	serverCertFile, serverKeyFile := newTestCert(t, 2, ca).write(t, dir, "server")
+	clientCertFile, clientKeyFile := newTestCert(t, 3, ca).write(t, dir, "client")
+
+	serverStore, err := newCertStore(tlsFiles{serverCert: serverCertFile, serverKey: serverKeyFile, clientRootCerts: []string{caFile}}, true)
+	if err != nil {
+		t.Fatalf("Error creating server cert store: %s", err)
+	}
+	serverCreds, err := serverStore.serverCredentials()
+	if err != nil {
+		t.Fatalf("Error getting server credentials: %s", err)
+	}
+	clientStore, err := newCertStore(tlsFiles{clientCert: clientCertFile, clientKey: clientKeyFile, rootCerts: []string{caFile}}, false)
+	if err != nil {
+		t.Fatalf("Error creating client cert store: %s", err)
+	}
+	if serial, err := handshake(serverCreds, clientStore.clientCredentials("")); err != nil || serial != 2 {
+		t.Fatalf("Expected the handshake with the server certificate 2 to succeed, got %d, %v", serial, err)
+	}
+
+	// the server requires a client certificate
+	anonymousStore, _ := newCertStore(tlsFiles{rootCerts: []string{caFile}}, false)
+	if _, err := handshake(serverCreds, anonymousStore.clientCredentials("")); err == nil {
+		t.Fatal("Expected the handshake without a client certificate to fail")
+	}
+
+	// the renewed server certificate is used once reloaded
+	if reloaded, err := serverStore.reloadIfChanged(); err != nil || reloaded {
+		t.Fatalf("Expected the unchanged certificates not to be reloaded, got %t, %v", reloaded, err)
+	}
+	newTestCert(t, 4, ca).write(t, dir, "server")
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(serverCertFile, later, later)
+	if reloaded, err := serverStore.reloadIfChanged(); err != nil || !reloaded {
+		t.Fatalf("Expected the renewed certificate to be reloaded, got %t, %v", reloaded, err)
+	}
+	if serial, err := handshake(serverCreds, clientStore.clientCredentials("")); err != nil || serial != 4 {
+		t.Fatalf("Expected the handshake with the server certificate 4 to succeed, got %d, %v", serial, err)
+	}
+
+	// a broken certificate is not loaded
+	ioutil.WriteFile(serverCertFile, []byte("broken"), 0600)
+	later = later.Add(time.Minute)
+	os.Chtimes(serverCertFile, later, later)
+	if _, err := serverStore.reloadIfChanged(); err == nil {
+		t.Fatal("Expected the broken certificate not to be reloaded")
+	}
+	if serial, err := handshake(serverCreds, clientStore.clientCredentials("")); err != nil || serial != 4 {
+		t.Fatalf("Expected the server certificate 4 to be kept, got %d, %v", serial, err)
+	}
+
+	if _, err := newCertStore(tlsFiles{serverCert: serverCertFile, serverKey: serverKeyFile}, true); err == nil {
+		t.Fatal("Expected client authentication without client root certificates to be rejected")
+	}
+}
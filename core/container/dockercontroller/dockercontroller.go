@@ -25,7 +25,9 @@ import (
 	"github.com/fsouza/go-dockerclient"
 	"github.com/hyperledger/fabric/core/container/ccintf"
 	cutil "github.com/hyperledger/fabric/core/container/util"
+	pb "github.com/hyperledger/fabric/protos"
 	"github.com/op/go-logging"
+	"github.com/spf13/viper"
 	"golang.org/x/net/context"
 )
 
@@ -36,9 +38,9 @@ type DockerVM struct {
 	id string
 }
 
-func (vm *DockerVM) createContainer(ctxt context.Context, client *docker.Client, imageID string, containerID string, args []string, env []string, attachstdin bool, attachstdout bool) error {
+func (vm *DockerVM) createContainer(ctxt context.Context, client *docker.Client, imageID string, containerID string, args []string, env []string, attachstdin bool, attachstdout bool, hostConfig *docker.HostConfig) error {
 	config := docker.Config{Cmd: args, Image: imageID, Env: env, AttachStdin: attachstdin, AttachStdout: attachstdout}
-	copts := docker.CreateContainerOptions{Name: containerID, Config: &config}
+	copts := docker.CreateContainerOptions{Name: containerID, Config: &config, HostConfig: hostConfig}
 	dockerLogger.Debug("Create container: %s", containerID)
 	_, err := client.CreateContainer(copts)
 	if err != nil {
@@ -90,13 +92,19 @@ func (vm *DockerVM) Start(ctxt context.Context, ccid ccintf.CCID, args []string,
 	dockerLogger.Debug("Cleanup container %s", containerID)
 	vm.stopInternal(ctxt, client, containerID, 0, false, false)
 
+	//the resource limits are set both when creating and starting the container, as the
+	//docker daemons before API 1.24 replace the host config of the container with the
+	//one given when starting it
+	hostConfig := &docker.HostConfig{NetworkMode: "host"}
+	hostConfig.Memory, hostConfig.CPUShares = getResourceLimits(ccid.ChaincodeSpec)
+
 	dockerLogger.Debug("Start container %s", containerID)
-	err = vm.createContainer(ctxt, client, imageID, containerID, args, env, attachstdin, attachstdout)
+	err = vm.createContainer(ctxt, client, imageID, containerID, args, env, attachstdin, attachstdout, hostConfig)
 	if err != nil {
 		dockerLogger.Error(fmt.Sprintf("start-could not recreate container %s", err))
 		return err
 	}
-	err = client.StartContainer(containerID, &docker.HostConfig{NetworkMode: "host"})
+	err = client.StartContainer(containerID, hostConfig)
 	if err != nil {
 		dockerLogger.Error(fmt.Sprintf("start-could not start container %s", err))
 		return err
@@ -106,6 +114,20 @@ func (vm *DockerVM) Start(ctxt context.Context, ccid ccintf.CCID, args []string,
 	return nil
 }
 
+//getResourceLimits returns the memory limit and the CPU shares of the container of
+//the chaincode: the ones of its spec if set, vm.docker.resources otherwise
+func getResourceLimits(spec *pb.ChaincodeSpec) (memory int64, cpuShares int64) {
+	memory = int64(viper.GetInt("vm.docker.resources.memory"))
+	cpuShares = int64(viper.GetInt("vm.docker.resources.cpuShares"))
+	if spec != nil && spec.MemoryLimit > 0 {
+		memory = spec.MemoryLimit
+	}
+	if spec != nil && spec.CpuShares > 0 {
+		cpuShares = spec.CpuShares
+	}
+	return
+}
+
 //Stop stops a running chaincode
 func (vm *DockerVM) Stop(ctxt context.Context, ccid ccintf.CCID, timeout uint, dontkill bool, dontremove bool) error {
 	id, _ := vm.GetVMName(ccid)
@@ -149,12 +171,17 @@ func (vm *DockerVM) stopInternal(ctxt context.Context, client *docker.Client, id
 
 //GetVMName generates the docker image from peer information given the hashcode. This is needed to
 //keep image name's unique in a single host, multi-peer environment (such as a development environment)
+//The version of an upgraded chaincode is appended so that each of its codes gets its own image
 func (vm *DockerVM) GetVMName(ccid ccintf.CCID) (string, error) {
+	name := ccid.ChaincodeSpec.ChaincodeID.Name
+	if version := ccid.ChaincodeSpec.ChaincodeID.Version; version != "" {
+		name = fmt.Sprintf("%s-%s", name, version)
+	}
 	if ccid.NetworkID != "" {
-		return fmt.Sprintf("%s-%s-%s", ccid.NetworkID, ccid.PeerID, ccid.ChaincodeSpec.ChaincodeID.Name), nil
+		return fmt.Sprintf("%s-%s-%s", ccid.NetworkID, ccid.PeerID, name), nil
 	} else if ccid.PeerID != "" {
-		return fmt.Sprintf("%s-%s", ccid.PeerID, ccid.ChaincodeSpec.ChaincodeID.Name), nil
+		return fmt.Sprintf("%s-%s", ccid.PeerID, name), nil
 	} else {
-		return ccid.ChaincodeSpec.ChaincodeID.Name, nil
+		return name, nil
 	}
 }
@@ -19,13 +19,16 @@ package util
 import (
 	"archive/tar"
 	"bufio"
+	"bytes"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/hyperledger/fabric/core/util"
 	"github.com/op/go-logging"
 )
 
@@ -90,6 +93,124 @@ func WriteGopathSrc(tw *tar.Writer, excludeDir string) error {
 	return nil
 }
 
+//WriteFolderToPackage tars up the files under localpath, which are written under
+//packagepath. Directories named in excludeDirs (such as build outputs) are skipped
+func WriteFolderToPackage(localpath string, packagepath string, excludeDirs []string, tw *tar.Writer) error {
+	excludeDirs = append([]string{".git"}, excludeDirs...)
+	walkFn := func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsDir() {
+			for _, exclude := range excludeDirs {
+				if info.Name() == exclude && path != localpath {
+					return filepath.SkipDir
+				}
+			}
+			return nil
+		}
+		relPath, err := filepath.Rel(localpath, path)
+		if err != nil {
+			return err
+		}
+		if err = WriteFileToPackage(path, filepath.ToSlash(filepath.Join(packagepath, relPath)), tw); err != nil {
+			return fmt.Errorf("Error writing file to package: %s", err)
+		}
+		return nil
+	}
+
+	if err := filepath.Walk(localpath, walkFn); err != nil {
+		vmLogger.Info("Error walking %s: %s", localpath, err)
+		return err
+	}
+	return nil
+}
+
+//WriteHashedFolderToPackage writes the files under localpath under packagepath,
+//as WriteFolderToPackage does, and computes h=hash(file bytes,h) for each of them
+//starting from hash. Files are traversed recursively in lexical order, so that
+//the same code always gets the same hash
+func WriteHashedFolderToPackage(localpath string, packagepath string, excludeDirs []string, hash []byte, tw *tar.Writer) ([]byte, error) {
+	excludeDirs = append([]string{".git"}, excludeDirs...)
+	walkFn := func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsDir() {
+			for _, exclude := range excludeDirs {
+				if info.Name() == exclude && path != localpath {
+					return filepath.SkipDir
+				}
+			}
+			return nil
+		}
+		relPath, err := filepath.Rel(localpath, path)
+		if err != nil {
+			return err
+		}
+		buf, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		newSlice := make([]byte, len(hash)+len(buf))
+		copy(newSlice, buf)
+		copy(newSlice[len(buf):], hash)
+		hash = util.ComputeCryptoHash(newSlice)
+
+		if err = WriteStreamToPackage(bytes.NewReader(buf), path, filepath.ToSlash(filepath.Join(packagepath, relPath)), tw); err != nil {
+			return fmt.Errorf("Error writing file to package: %s", err)
+		}
+		return nil
+	}
+
+	if err := filepath.Walk(localpath, walkFn); err != nil {
+		vmLogger.Info("Error walking %s: %s", localpath, err)
+		return hash, err
+	}
+	return hash, nil
+}
+
+//WriteShimToPackage writes the shim of the chaincodes written in language, under
+//core/chaincode/shim/ in the fabric sources, under shim/ in the package. The protos,
+//whose paths are relative to the fabric sources, are written under shim/protos/ by
+//their path under their protos directory
+func WriteShimToPackage(language string, excludeDirs []string, protos []string, tw *tar.Writer) error {
+	gopath := os.Getenv("GOPATH")
+	if gopath == "" {
+		return fmt.Errorf("GOPATH not defined")
+	}
+	// Only take the first element of GOPATH
+	gopath = filepath.SplitList(gopath)[0]
+	fabricRoot := filepath.Join(gopath, "src", "github.com", "hyperledger", "fabric")
+
+	err := WriteFolderToPackage(filepath.Join(fabricRoot, "core", "chaincode", "shim", language), "shim", append([]string{"protos"}, excludeDirs...), tw)
+	if err != nil {
+		return err
+	}
+	for _, proto := range protos {
+		packagepath := "shim/protos/" + proto[strings.LastIndex(proto, "protos/")+len("protos/"):]
+		if err = WriteFileToPackage(filepath.Join(fabricRoot, filepath.FromSlash(proto)), packagepath, tw); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//WriteDockerfileToPackage writes the Dockerfile made of lines to the tarball
+func WriteDockerfileToPackage(lines []string, tw *tar.Writer) error {
+	dockerFileContents := strings.Join(lines, "\n")
+	dockerFileSize := int64(len([]byte(dockerFileContents)))
+
+	//Make headers identical by using zero time
+	var zeroTime time.Time
+	if err := tw.WriteHeader(&tar.Header{Name: "Dockerfile", Size: dockerFileSize, ModTime: zeroTime, AccessTime: zeroTime, ChangeTime: zeroTime}); err != nil {
+		return err
+	}
+	_, err := tw.Write([]byte(dockerFileContents))
+	return err
+}
+
 //WriteFileToPackage writes a file to the tarball
 func WriteFileToPackage(localpath string, packagepath string, tw *tar.Writer) error {
 	fd, err := os.Open(localpath)
@@ -0,0 +1,98 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestFolder(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "writer")
+	if err != nil {
+		t.Fatalf("Error creating temp dir: %s", err)
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err = ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Error writing %s: %s", name, err)
+		}
+	}
+	return dir
+}
+
+func hashTestFolder(t *testing.T, dir string, tw *tar.Writer) []byte {
+	hash, err := WriteHashedFolderToPackage(dir, "chaincode", []string{"build"}, []byte("seed"), tw)
+	if err != nil {
+		t.Fatalf("Error writing the folder: %s", err)
+	}
+	return hash
+}
+
+func TestWriteHashedFolderToPackage(t *testing.T) {
+	dir := writeTestFolder(t, map[string]string{
+		"main.code":       "//main",
+		"lib/map.code":    "//map",
+		"build/out.bin":   "binary",
+		"lib/build/x.bin": "binary",
+		".git/HEAD":       "ref",
+	})
+	defer os.RemoveAll(dir)
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	hash := hashTestFolder(t, dir, tw)
+	tw.Close()
+
+	var names []string
+	tr := tar.NewReader(&buf)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Error reading package: %s", err)
+		}
+		names = append(names, header.Name)
+	}
+	if len(names) != 2 || names[0] != "chaincode/lib/map.code" || names[1] != "chaincode/main.code" {
+		t.Fatalf("Expected the files without the excluded dirs in the package, got %v", names)
+	}
+
+	//the excluded dirs do not change the hash, the content of the files does
+	os.RemoveAll(filepath.Join(dir, "build"))
+	if again := hashTestFolder(t, dir, tar.NewWriter(ioutil.Discard)); !bytes.Equal(again, hash) {
+		t.Fatalf("Expected the same hash without the excluded dirs, got %x and %x", hash, again)
+	}
+	ioutil.WriteFile(filepath.Join(dir, "lib/map.code"), []byte("//map v2"), 0644)
+	changed := hashTestFolder(t, dir, tar.NewWriter(ioutil.Discard))
+	if bytes.Equal(changed, hash) {
+		t.Fatalf("Expected a different hash for different content")
+	}
+	//files of the same size hash differently
+	ioutil.WriteFile(filepath.Join(dir, "lib/map.code"), []byte("//map v3"), 0644)
+	if other := hashTestFolder(t, dir, tar.NewWriter(ioutil.Discard)); bytes.Equal(other, changed) {
+		t.Fatalf("Expected a different hash for different content of the same size")
+	}
+}
@@ -0,0 +1,131 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package bccsp abstracts the providers of the cryptographic operations on the private
+// keys of the peer, so that the keys can be held by a hardware security module or a
+// PKCS#11 token rather than in files on disk. The provider of each key type is selected
+// by security.bccsp.<key type>
+package bccsp
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/op/go-logging"
+	"github.com/spf13/viper"
+)
+
+var logger = logging.MustGetLogger("bccsp")
+
+// The key types a provider is selected for
+const (
+	// EnrollmentKey is the signing key of the enrollment certificate
+	EnrollmentKey = "enrollment"
+	// ChainKey is the key of the validators decrypting the confidential chaincodes
+	ChainKey = "chain"
+)
+
+// The names of the providers
+const (
+	// Software holds the keys in memory, loaded from the keystore files
+	Software = "SW"
+	// PKCS11 holds the keys in a PKCS#11 token, available in the builds with the pkcs11 tag
+	PKCS11 = "PKCS11"
+)
+
+// ErrKeyNotFound is returned by GetKey for a label the provider holds no key of
+var ErrKeyNotFound = errors.New("Key not found")
+
+// Key is a private key held by a provider
+type Key interface {
+	// Label identifies the key within its provider
+	Label() string
+
+	// PublicKey returns the public key
+	PublicKey() *ecdsa.PublicKey
+}
+
+// BCCSP is a provider of the operations on private ECDSA keys
+type BCCSP interface {
+	// Name returns the name of the provider
+	Name() string
+
+	// Ephemeral tells whether the keys are only held for the lifetime of the process, in
+	// which case the keystore files are still needed to restore them
+	Ephemeral() bool
+
+	// GetKey returns the key of the label, ErrKeyNotFound if there is none
+	GetKey(label string) (Key, error)
+
+	// ImportKey imports the private key under the label, replacing any previous one
+	ImportKey(label string, priv *ecdsa.PrivateKey) (Key, error)
+
+	// Sign signs the digest, returning the ASN.1 encoded signature
+	Sign(k Key, digest []byte) ([]byte, error)
+
+	// ECDH returns the x coordinate of the product of the private key and the point (x, y)
+	ECDH(k Key, x, y *big.Int) ([]byte, error)
+}
+
+// Factory creates a provider, from its configuration under security.bccsp.<lower case name>
+type Factory func() (BCCSP, error)
+
+var (
+	registryLock sync.Mutex
+	factories    = map[string]Factory{}
+	providers    = map[string]BCCSP{}
+)
+
+// RegisterProvider registers the factory of the provider of the name
+func RegisterProvider(name string, factory Factory) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	factories[strings.ToUpper(name)] = factory
+}
+
+// GetProvider returns the provider selected for the key type by security.bccsp.<key type>,
+// the software one by default. The providers are created once and shared by the key types
+func GetProvider(keyType string) (BCCSP, error) {
+	name := strings.ToUpper(viper.GetString("security.bccsp." + keyType))
+	if name == "" {
+		name = Software
+	}
+	return getProvider(name)
+}
+
+func getProvider(name string) (BCCSP, error) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	if csp, ok := providers[name]; ok {
+		return csp, nil
+	}
+	factory, ok := factories[name]
+	if !ok {
+		// the PKCS11 provider is only compiled in the builds with the pkcs11 tag
+		return nil, fmt.Errorf("Unknown crypto provider [%s]", name)
+	}
+	csp, err := factory()
+	if err != nil {
+		return nil, fmt.Errorf("Failed initializing crypto provider [%s]: %s", name, err)
+	}
+	logger.Info("Crypto provider [%s] initialized", name)
+	providers[name] = csp
+	return csp, nil
+}
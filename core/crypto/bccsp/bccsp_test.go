@@ -0,0 +1,103 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bccsp
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/asn1"
+	"testing"
+
+	"github.com/spf13/viper"
+
+	"github.com/hyperledger/fabric/core/crypto/primitives"
+)
+
+func TestSoftwareProvider(t *testing.T) {
+	csp := NewSoftwareProvider()
+	if _, err := csp.GetKey("peer.enrollment"); err != ErrKeyNotFound {
+		t.Fatalf("Expected ErrKeyNotFound, got %v", err)
+	}
+
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = csp.ImportKey("peer.enrollment", priv); err != nil {
+		t.Fatal(err)
+	}
+	k, err := csp.GetKey("peer.enrollment")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k.Label() != "peer.enrollment" || k.PublicKey().X.Cmp(priv.X) != 0 {
+		t.Fatal("Imported key not returned")
+	}
+
+	digest := sha256.Sum256([]byte("message"))
+	raw, err := csp.Sign(k, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := new(primitives.ECDSASignature)
+	if _, err = asn1.Unmarshal(raw, sig); err != nil {
+		t.Fatal(err)
+	}
+	if !ecdsa.Verify(k.PublicKey(), digest[:], sig.R, sig.S) {
+		t.Fatal("Invalid signature")
+	}
+
+	ephemeral, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret, err := csp.ECDH(k, ephemeral.X, ephemeral.Y)
+	if err != nil {
+		t.Fatal(err)
+	}
+	x, _ := elliptic.P256().ScalarMult(priv.X, priv.Y, ephemeral.D.Bytes())
+	if !bytes.Equal(secret, x.Bytes()) {
+		t.Fatal("Shared secrets differ")
+	}
+	if _, err = csp.ECDH(k, ephemeral.X, ephemeral.X); err == nil {
+		t.Fatal("Point not on the curve accepted")
+	}
+}
+
+func TestGetProvider(t *testing.T) {
+	defer viper.Reset()
+
+	csp, err := GetProvider(EnrollmentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if csp.Name() != Software {
+		t.Fatalf("Expected the software provider by default, got %s", csp.Name())
+	}
+	viper.Set("security.bccsp.chain", "sw")
+	if other, err := GetProvider(ChainKey); err != nil || other != csp {
+		t.Fatalf("Expected the same software provider, got %v", err)
+	}
+
+	viper.Set("security.bccsp.chain", "unknown")
+	if _, err = GetProvider(ChainKey); err == nil {
+		t.Fatal("Unknown provider accepted")
+	}
+}
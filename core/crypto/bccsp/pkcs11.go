@@ -0,0 +1,329 @@
+//go:build pkcs11
+// +build pkcs11
+
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bccsp
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/miekg/pkcs11"
+	"github.com/spf13/viper"
+
+	"github.com/hyperledger/fabric/core/crypto/primitives"
+)
+
+func init() {
+	RegisterProvider(PKCS11, func() (BCCSP, error) {
+		return NewPKCS11Provider(
+			viper.GetString("security.bccsp.pkcs11.library"),
+			viper.GetString("security.bccsp.pkcs11.label"),
+			viper.GetString("security.bccsp.pkcs11.pin"),
+		)
+	})
+}
+
+var curveOIDs = map[string]asn1.ObjectIdentifier{
+	"P-224": {1, 3, 132, 0, 33},
+	"P-256": {1, 2, 840, 10045, 3, 1, 7},
+	"P-384": {1, 3, 132, 0, 34},
+	"P-521": {1, 3, 132, 0, 35},
+}
+
+type pkcs11Key struct {
+	label string
+	pub   *ecdsa.PublicKey
+	priv  pkcs11.ObjectHandle
+}
+
+func (k *pkcs11Key) Label() string {
+	return k.label
+}
+
+func (k *pkcs11Key) PublicKey() *ecdsa.PublicKey {
+	return k.pub
+}
+
+// pkcs11Provider holds the keys in a token, as non extractable private key objects. The
+// operations are serialized on a single session logged in as the user
+type pkcs11Provider struct {
+	sync.Mutex
+	ctx     *pkcs11.Ctx
+	session pkcs11.SessionHandle
+}
+
+// NewPKCS11Provider returns a provider holding the keys in the token of the label, through
+// the PKCS#11 library at the path
+func NewPKCS11Provider(library, label, pin string) (BCCSP, error) {
+	if library == "" {
+		return nil, errors.New("No PKCS#11 library configured in security.bccsp.pkcs11.library")
+	}
+	ctx := pkcs11.New(library)
+	if ctx == nil {
+		return nil, fmt.Errorf("Failed loading PKCS#11 library [%s]", library)
+	}
+	if err := ctx.Initialize(); err != nil {
+		return nil, fmt.Errorf("Failed initializing PKCS#11 library [%s]: %s", library, err)
+	}
+	slots, err := ctx.GetSlotList(true)
+	if err != nil {
+		return nil, fmt.Errorf("Failed listing PKCS#11 slots: %s", err)
+	}
+	for _, slot := range slots {
+		info, err := ctx.GetTokenInfo(slot)
+		if err != nil || info.Label != label {
+			continue
+		}
+		session, err := ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION|pkcs11.CKF_RW_SESSION)
+		if err != nil {
+			return nil, fmt.Errorf("Failed opening session on PKCS#11 token [%s]: %s", label, err)
+		}
+		if err = ctx.Login(session, pkcs11.CKU_USER, pin); err != nil && err != pkcs11.Error(pkcs11.CKR_USER_ALREADY_LOGGED_IN) {
+			ctx.CloseSession(session)
+			return nil, fmt.Errorf("Failed logging in PKCS#11 token [%s]: %s", label, err)
+		}
+		return &pkcs11Provider{ctx: ctx, session: session}, nil
+	}
+	return nil, fmt.Errorf("PKCS#11 token [%s] not found", label)
+}
+
+func (csp *pkcs11Provider) Name() string {
+	return PKCS11
+}
+
+func (csp *pkcs11Provider) Ephemeral() bool {
+	return false
+}
+
+func (csp *pkcs11Provider) findObjects(class uint, label string) ([]pkcs11.ObjectHandle, error) {
+	template := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, class),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+	}
+	if err := csp.ctx.FindObjectsInit(csp.session, template); err != nil {
+		return nil, err
+	}
+	defer csp.ctx.FindObjectsFinal(csp.session)
+	objects, _, err := csp.ctx.FindObjects(csp.session, 16)
+	return objects, err
+}
+
+func (csp *pkcs11Provider) GetKey(label string) (Key, error) {
+	csp.Lock()
+	defer csp.Unlock()
+
+	privs, err := csp.findObjects(pkcs11.CKO_PRIVATE_KEY, label)
+	if err != nil {
+		return nil, err
+	}
+	pubs, err := csp.findObjects(pkcs11.CKO_PUBLIC_KEY, label)
+	if err != nil {
+		return nil, err
+	}
+	if len(privs) == 0 || len(pubs) == 0 {
+		return nil, ErrKeyNotFound
+	}
+	attrs, err := csp.ctx.GetAttributeValue(csp.session, pubs[0], []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, nil),
+		pkcs11.NewAttribute(pkcs11.CKA_EC_POINT, nil),
+	})
+	if err != nil {
+		return nil, err
+	}
+	pub, err := decodePublicKey(attrs[0].Value, attrs[1].Value)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid public key [%s]: %s", label, err)
+	}
+	return &pkcs11Key{label, pub, privs[0]}, nil
+}
+
+func (csp *pkcs11Provider) ImportKey(label string, priv *ecdsa.PrivateKey) (Key, error) {
+	if priv == nil {
+		return nil, errors.New("Invalid private key. Nil.")
+	}
+	oid, ok := curveOIDs[priv.Curve.Params().Name]
+	if !ok {
+		return nil, fmt.Errorf("Unsupported curve [%s]", priv.Curve.Params().Name)
+	}
+	params, err := asn1.Marshal(oid)
+	if err != nil {
+		return nil, err
+	}
+	point, err := asn1.Marshal(elliptic.Marshal(priv.Curve, priv.X, priv.Y))
+	if err != nil {
+		return nil, err
+	}
+	size := (priv.Curve.Params().BitSize + 7) / 8
+	d := priv.D.Bytes()
+	value := append(make([]byte, size-len(d)), d...)
+
+	csp.Lock()
+	defer csp.Unlock()
+
+	// the previous key of the label is replaced
+	for _, class := range []uint{pkcs11.CKO_PRIVATE_KEY, pkcs11.CKO_PUBLIC_KEY} {
+		objects, err := csp.findObjects(class, label)
+		if err != nil {
+			return nil, err
+		}
+		for _, object := range objects {
+			if err := csp.ctx.DestroyObject(csp.session, object); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	handle, err := csp.ctx.CreateObject(csp.session, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PRIVATE_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_EC),
+		pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_PRIVATE, true),
+		pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, true),
+		pkcs11.NewAttribute(pkcs11.CKA_EXTRACTABLE, false),
+		pkcs11.NewAttribute(pkcs11.CKA_SIGN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_DERIVE, true),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+		pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, params),
+		pkcs11.NewAttribute(pkcs11.CKA_VALUE, value),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Failed importing private key [%s]: %s", label, err)
+	}
+	_, err = csp.ctx.CreateObject(csp.session, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PUBLIC_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_EC),
+		pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_VERIFY, true),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+		pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, params),
+		pkcs11.NewAttribute(pkcs11.CKA_EC_POINT, point),
+	})
+	if err != nil {
+		csp.ctx.DestroyObject(csp.session, handle)
+		return nil, fmt.Errorf("Failed importing public key [%s]: %s", label, err)
+	}
+
+	pub := priv.PublicKey
+	return &pkcs11Key{label, &pub, handle}, nil
+}
+
+func (csp *pkcs11Provider) Sign(k Key, digest []byte) ([]byte, error) {
+	key, ok := k.(*pkcs11Key)
+	if !ok {
+		return nil, errors.New("Invalid key. Not a PKCS#11 key.")
+	}
+
+	csp.Lock()
+	defer csp.Unlock()
+
+	if err := csp.ctx.SignInit(csp.session, []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_ECDSA, nil)}, key.priv); err != nil {
+		return nil, err
+	}
+	raw, err := csp.ctx.Sign(csp.session, digest)
+	if err != nil {
+		return nil, err
+	}
+	// the signature is the concatenation of r and s
+	size := (key.pub.Curve.Params().BitSize + 7) / 8
+	if len(raw) != 2*size {
+		return nil, fmt.Errorf("Invalid signature length [%d]", len(raw))
+	}
+	r := new(big.Int).SetBytes(raw[:size])
+	s := new(big.Int).SetBytes(raw[size:])
+	return asn1.Marshal(primitives.ECDSASignature{R: r, S: s})
+}
+
+func (csp *pkcs11Provider) ECDH(k Key, x, y *big.Int) ([]byte, error) {
+	key, ok := k.(*pkcs11Key)
+	if !ok {
+		return nil, errors.New("Invalid key. Not a PKCS#11 key.")
+	}
+	if !key.pub.Curve.IsOnCurve(x, y) {
+		return nil, errors.New("Invalid point on curve")
+	}
+	size := (key.pub.Curve.Params().BitSize + 7) / 8
+
+	csp.Lock()
+	defer csp.Unlock()
+
+	params := pkcs11.NewECDH1DeriveParams(pkcs11.CKD_NULL, nil, elliptic.Marshal(key.pub.Curve, x, y))
+	secret, err := csp.ctx.DeriveKey(csp.session, []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_ECDH1_DERIVE, params)}, key.priv, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_SECRET_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_GENERIC_SECRET),
+		pkcs11.NewAttribute(pkcs11.CKA_VALUE_LEN, size),
+		pkcs11.NewAttribute(pkcs11.CKA_TOKEN, false),
+		pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, false),
+		pkcs11.NewAttribute(pkcs11.CKA_EXTRACTABLE, true),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer csp.ctx.DestroyObject(csp.session, secret)
+
+	attrs, err := csp.ctx.GetAttributeValue(csp.session, secret, []*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_VALUE, nil)})
+	if err != nil {
+		return nil, err
+	}
+	return attrs[0].Value, nil
+}
+
+// decodePublicKey decodes the CKA_EC_PARAMS and CKA_EC_POINT attributes of a public key
+func decodePublicKey(params, point []byte) (*ecdsa.PublicKey, error) {
+	var oid asn1.ObjectIdentifier
+	if _, err := asn1.Unmarshal(params, &oid); err != nil {
+		return nil, err
+	}
+	var curve elliptic.Curve
+	for name, curveOID := range curveOIDs {
+		if curveOID.Equal(oid) {
+			curve = namedCurve(name)
+		}
+	}
+	if curve == nil {
+		return nil, fmt.Errorf("Unsupported curve [%s]", oid)
+	}
+	var raw []byte
+	if _, err := asn1.Unmarshal(point, &raw); err != nil {
+		return nil, err
+	}
+	x, y := elliptic.Unmarshal(curve, raw)
+	if x == nil {
+		return nil, errors.New("Invalid point")
+	}
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+func namedCurve(name string) elliptic.Curve {
+	switch name {
+	case "P-224":
+		return elliptic.P224()
+	case "P-256":
+		return elliptic.P256()
+	case "P-384":
+		return elliptic.P384()
+	case "P-521":
+		return elliptic.P521()
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bccsp
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+	"sync"
+
+	"github.com/hyperledger/fabric/core/crypto/primitives"
+)
+
+func init() {
+	RegisterProvider(Software, func() (BCCSP, error) { return NewSoftwareProvider(), nil })
+}
+
+type swKey struct {
+	label string
+	priv  *ecdsa.PrivateKey
+}
+
+func (k *swKey) Label() string {
+	return k.label
+}
+
+func (k *swKey) PublicKey() *ecdsa.PublicKey {
+	return &k.priv.PublicKey
+}
+
+// PrivateKey returns the private key of a software key, nil for the keys of the other
+// providers
+func PrivateKey(k Key) *ecdsa.PrivateKey {
+	if sk, ok := k.(*swKey); ok {
+		return sk.priv
+	}
+	return nil
+}
+
+type swProvider struct {
+	sync.RWMutex
+	keys map[string]*swKey
+}
+
+// NewSoftwareProvider returns a provider holding the keys in memory
+func NewSoftwareProvider() BCCSP {
+	return &swProvider{keys: make(map[string]*swKey)}
+}
+
+func (csp *swProvider) Name() string {
+	return Software
+}
+
+func (csp *swProvider) Ephemeral() bool {
+	return true
+}
+
+func (csp *swProvider) GetKey(label string) (Key, error) {
+	csp.RLock()
+	defer csp.RUnlock()
+	if k, ok := csp.keys[label]; ok {
+		return k, nil
+	}
+	return nil, ErrKeyNotFound
+}
+
+func (csp *swProvider) ImportKey(label string, priv *ecdsa.PrivateKey) (Key, error) {
+	if priv == nil {
+		return nil, errors.New("Invalid private key. Nil.")
+	}
+	k := &swKey{label, priv}
+	csp.Lock()
+	defer csp.Unlock()
+	csp.keys[label] = k
+	return k, nil
+}
+
+func (csp *swProvider) Sign(k Key, digest []byte) ([]byte, error) {
+	priv := PrivateKey(k)
+	if priv == nil {
+		return nil, errors.New("Invalid key. Not a software key.")
+	}
+	r, s, err := ecdsa.Sign(rand.Reader, priv, digest)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(primitives.ECDSASignature{R: r, S: s})
+}
+
+func (csp *swProvider) ECDH(k Key, x, y *big.Int) ([]byte, error) {
+	priv := PrivateKey(k)
+	if priv == nil {
+		return nil, errors.New("Invalid key. Not a software key.")
+	}
+	if !priv.Curve.IsOnCurve(x, y) {
+		return nil, errors.New("Invalid point on curve")
+	}
+	z, _ := priv.Curve.ScalarMult(x, y, priv.D.Bytes())
+	return z.Bytes(), nil
+}
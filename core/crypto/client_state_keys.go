@@ -0,0 +1,88 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crypto
+
+import (
+	"errors"
+
+	"golang.org/x/net/context"
+
+	"github.com/hyperledger/fabric/core/crypto/primitives"
+	"github.com/hyperledger/fabric/core/crypto/utils"
+	membersrvc "github.com/hyperledger/fabric/membersrvc/protos"
+	obc "github.com/hyperledger/fabric/protos"
+)
+
+// NewChaincodeStateKeys generates a key to encrypt the state of a chaincode and wraps it
+// for each of the peers, under the enrollment certificate the ECA issued them.
+func (client *clientImpl) NewChaincodeStateKeys(peers []string) (*obc.ChaincodeStateKeys, error) {
+	// Verify that the client is initialized
+	if !client.isInitialized {
+		return nil, utils.ErrNotInitialized
+	}
+	if len(peers) == 0 {
+		return nil, errors.New("No peer authorized to read the state.")
+	}
+
+	key, err := primitives.GenAESKey()
+	if err != nil {
+		client.error("Failed generating state key [%s].", err.Error())
+		return nil, err
+	}
+
+	keys := &obc.ChaincodeStateKeys{}
+	wrapped := make(map[string]bool)
+	for _, peer := range peers {
+		if wrapped[peer] {
+			continue
+		}
+		wrapped[peer] = true
+
+		pair, err := client.callECAReadCertificate(context.Background(), &membersrvc.ECertReadReq{Id: &membersrvc.Identity{Id: peer}})
+		if err != nil {
+			client.error("Failed reading enrollment certificate of [%s] from the ECA [%s].", peer, err.Error())
+			return nil, err
+		}
+		cert, err := primitives.DERToX509Certificate(pair.Sign)
+		if err != nil {
+			client.error("Failed parsing enrollment certificate of [%s] [%s].", peer, err.Error())
+			return nil, err
+		}
+		if _, err = primitives.CheckCertAgainRoot(cert, client.ecaCertPool); err != nil {
+			client.error("Failed verifying enrollment certificate of [%s] [%s].", peer, err.Error())
+			return nil, err
+		}
+
+		pk, err := client.eciesSPI.NewPublicKey(nil, cert.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		cipher, err := client.eciesSPI.NewAsymmetricCipherFromPublicKey(pk)
+		if err != nil {
+			return nil, err
+		}
+		ct, err := cipher.Process(key)
+		if err != nil {
+			client.error("Failed wrapping state key for [%s] [%s].", peer, err.Error())
+			return nil, err
+		}
+
+		keys.Keys = append(keys.Keys, &obc.ChaincodeStateKeys_WrappedKey{Peer: peer, PkiID: primitives.Hash(pair.Sign), Key: ct})
+	}
+
+	return keys, nil
+}
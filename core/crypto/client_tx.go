@@ -17,7 +17,6 @@ limitations under the License.
 package crypto
 
 import (
-	"github.com/golang/protobuf/proto"
 	"github.com/hyperledger/fabric/core/crypto/primitives"
 	"github.com/hyperledger/fabric/core/crypto/utils"
 	obc "github.com/hyperledger/fabric/protos"
@@ -174,8 +173,8 @@ func (client *clientImpl) newChaincodeDeployUsingTCert(chaincodeDeploymentSpec *
 	tx.Cert = tCert.GetCertificate().Raw
 
 	// Sign the transaction and append the signature
-	// 1. Marshall tx to bytes
-	rawTx, err := proto.Marshal(tx)
+	// 1. Marshall tx to bytes, without the transient data
+	rawTx, err := tx.SignedBytes()
 	if err != nil {
 		client.error("Failed marshaling tx [%s].", err.Error())
 		return nil, err
@@ -211,8 +210,8 @@ func (client *clientImpl) newChaincodeExecuteUsingTCert(chaincodeInvocation *obc
 	tx.Cert = tCert.GetCertificate().Raw
 
 	// Sign the transaction and append the signature
-	// 1. Marshall tx to bytes
-	rawTx, err := proto.Marshal(tx)
+	// 1. Marshall tx to bytes, without the transient data
+	rawTx, err := tx.SignedBytes()
 	if err != nil {
 		client.error("Failed marshaling tx [%s].", err.Error())
 		return nil, err
@@ -248,8 +247,8 @@ func (client *clientImpl) newChaincodeQueryUsingTCert(chaincodeInvocation *obc.C
 	tx.Cert = tCert.GetCertificate().Raw
 
 	// Sign the transaction and append the signature
-	// 1. Marshall tx to bytes
-	rawTx, err := proto.Marshal(tx)
+	// 1. Marshall tx to bytes, without the transient data
+	rawTx, err := tx.SignedBytes()
 	if err != nil {
 		client.error("Failed marshaling tx [%s].", err.Error())
 		return nil, err
@@ -285,8 +284,8 @@ func (client *clientImpl) newChaincodeDeployUsingECert(chaincodeDeploymentSpec *
 	tx.Cert = client.enrollCert.Raw
 
 	// Sign the transaction and append the signature
-	// 1. Marshall tx to bytes
-	rawTx, err := proto.Marshal(tx)
+	// 1. Marshall tx to bytes, without the transient data
+	rawTx, err := tx.SignedBytes()
 	if err != nil {
 		client.error("Failed marshaling tx [%s].", err.Error())
 		return nil, err
@@ -322,8 +321,8 @@ func (client *clientImpl) newChaincodeExecuteUsingECert(chaincodeInvocation *obc
 	tx.Cert = client.enrollCert.Raw
 
 	// Sign the transaction and append the signature
-	// 1. Marshall tx to bytes
-	rawTx, err := proto.Marshal(tx)
+	// 1. Marshall tx to bytes, without the transient data
+	rawTx, err := tx.SignedBytes()
 	if err != nil {
 		client.error("Failed marshaling tx [%s].", err.Error())
 		return nil, err
@@ -359,8 +358,8 @@ func (client *clientImpl) newChaincodeQueryUsingECert(chaincodeInvocation *obc.C
 	tx.Cert = client.enrollCert.Raw
 
 	// Sign the transaction and append the signature
-	// 1. Marshall tx to bytes
-	rawTx, err := proto.Marshal(tx)
+	// 1. Marshall tx to bytes, without the transient data
+	rawTx, err := tx.SignedBytes()
 	if err != nil {
 		client.error("Failed marshaling tx [%s].", err.Error())
 		return nil, err
@@ -403,15 +402,12 @@ func (client *clientImpl) checkTransaction(tx *obc.Transaction) error {
 		}
 		// TODO: verify cert
 
-		// 3. Marshall tx without signature
-		signature := tx.Signature
-		tx.Signature = nil
-		rawTx, err := proto.Marshal(tx)
+		// 3. Marshall tx without signature nor transient data
+		rawTx, err := tx.SignedBytes()
 		if err != nil {
 			client.error("Failed marshaling tx [%s].", err.Error())
 			return err
 		}
-		tx.Signature = signature
 
 		// 2. Verify signature
 		ver, err := client.verify(cert.PublicKey, rawTx, tx.Signature)
@@ -74,6 +74,10 @@ type Client interface {
 
 	// GetNextTCert gets next available (not yet used) transaction certificate.
 	GetNextTCert() (tCert, error)
+
+	// NewChaincodeStateKeys generates a key to encrypt the state of a chaincode
+	// and wraps it for each of the peers, identified by their enrollment id.
+	NewChaincodeStateKeys(peers []string) (*obc.ChaincodeStateKeys, error)
 }
 
 // Peer is an entity able to verify transactions
@@ -107,12 +111,25 @@ type Peer interface {
 	// If vkID is nil, then the signature is verified against this validator's verification key.
 	Verify(vkID, signature, message []byte) error
 
+	// VerifyValidator checks, as Verify, that signature is a valid signature of message under
+	// vkID's verification key, and also that vkID's enrollment certificate has been issued
+	// to a validator.
+	VerifyValidator(vkID, signature, message []byte) error
+
 	// GetStateEncryptor returns a StateEncryptor linked to pair defined by
 	// the deploy transaction and the execute transaction. Notice that,
 	// executeTx can also correspond to a deploy transaction.
 	GetStateEncryptor(deployTx, executeTx *obc.Transaction) (StateEncryptor, error)
 
 	GetTransactionBinding(tx *obc.Transaction) ([]byte, error)
+
+	// VerifyRevocationList verifies that the DER encoded certificate
+	// revocation list is signed by the ECA or the TCA.
+	VerifyRevocationList(crl []byte) error
+
+	// GetChaincodeStateEncryptor returns a StateEncryptor of the state of
+	// the chaincode whose key is wrapped in keys for this peer.
+	GetChaincodeStateEncryptor(keys *obc.ChaincodeStateKeys) (StateEncryptor, error)
 }
 
 // StateEncryptor is used to encrypt chaincode's state
@@ -596,6 +596,42 @@ func TestPeerStateEncryptor(t *testing.T) {
 	}
 }
 
+func TestPeerChaincodeStateEncryptor(t *testing.T) {
+	keys, err := deployer.NewChaincodeStateKeys([]string{validator.GetEnrollmentID()})
+	if err != nil {
+		t.Fatalf("Failed wrapping state key [%s].", err)
+	}
+
+	se, err := validator.GetChaincodeStateEncryptor(keys)
+	if err != nil {
+		t.Fatalf("Failed getting chaincode state encryptor [%s].", err)
+	}
+	msg := []byte("Hello World!!!")
+	ct, err := se.Encrypt(msg)
+	if err != nil {
+		t.Fatalf("Failed encrypting state [%s].", err)
+	}
+	ct2, err := se.Encrypt(msg)
+	if err != nil {
+		t.Fatalf("Failed encrypting state [%s].", err)
+	}
+	if !reflect.DeepEqual(ct, ct2) {
+		t.Fatalf("Equal values must have equal ciphertexts")
+	}
+	pt, err := se.Decrypt(ct)
+	if err != nil {
+		t.Fatalf("Failed decrypting state [%s].", err)
+	}
+	if !reflect.DeepEqual(msg, pt) {
+		t.Fatalf("Decrypted state does not match [%s]!=[%s]", msg, pt)
+	}
+
+	// The key is not wrapped for the other peer
+	if _, err = peer.GetChaincodeStateEncryptor(keys); err != utils.ErrStateKeyNotFound {
+		t.Fatalf("Error must be ErrStateKeyNotFound [%s].", err)
+	}
+}
+
 func TestPeerSignVerify(t *testing.T) {
 	msg := []byte("Hello World!!!")
 	signature, err := peer.Sign(msg)
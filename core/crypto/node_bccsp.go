@@ -0,0 +1,99 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"os"
+
+	"github.com/hyperledger/fabric/core/crypto/bccsp"
+	"github.com/hyperledger/fabric/core/crypto/primitives"
+)
+
+// providerKey is a private key held by a crypto provider. It is an ecies.KeyAgreement, so
+// that the chain key decrypts without leaving the provider
+type providerKey struct {
+	csp bccsp.BCCSP
+	key bccsp.Key
+}
+
+func (k *providerKey) Public() *ecdsa.PublicKey {
+	return k.key.PublicKey()
+}
+
+func (k *providerKey) SharedSecret(x, y *big.Int) ([]byte, error) {
+	return k.csp.ECDH(k.key, x, y)
+}
+
+func (k *providerKey) sign(msg []byte) ([]byte, error) {
+	return k.csp.Sign(k.key, primitives.Hash(msg))
+}
+
+// loadProviderKey loads the private key of the key type into the provider selected for it.
+// A key found in the keystore, just retrieved from the ECA, replaces the one of the provider
+// and is then removed from disk unless the provider only holds the keys in memory
+func (node *nodeImpl) loadProviderKey(keyType string, alias string) (*providerKey, error) {
+	var csp bccsp.BCCSP
+	if node.eType == NodeClient {
+		// the clients derive their TCert keys from the enrollment key, which must be at hand
+		csp = bccsp.NewSoftwareProvider()
+	} else {
+		var err error
+		if csp, err = bccsp.GetProvider(keyType); err != nil {
+			node.error("Failed getting crypto provider of the %s key [%s].", keyType, err.Error())
+			return nil, err
+		}
+	}
+	label := node.conf.prefix + "." + node.conf.name + "." + keyType
+
+	if !node.ks.isAliasSet(alias) {
+		if csp.Ephemeral() {
+			return nil, errors.New("Private key [" + alias + "] not found in the keystore")
+		}
+		key, err := csp.GetKey(label)
+		if err != nil {
+			node.error("Failed getting %s key [%s] from crypto provider [%s]: [%s].", keyType, label, csp.Name(), err.Error())
+			return nil, err
+		}
+		return &providerKey{csp, key}, nil
+	}
+
+	priv, err := node.ks.loadPrivateKey(alias)
+	if err != nil {
+		return nil, err
+	}
+	ecdsaPriv, ok := priv.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("Invalid private key [" + alias + "]. Not an ECDSA key.")
+	}
+	key, err := csp.ImportKey(label, ecdsaPriv)
+	if err != nil {
+		node.error("Failed importing %s key [%s] into crypto provider [%s]: [%s].", keyType, label, csp.Name(), err.Error())
+		return nil, err
+	}
+	if !csp.Ephemeral() {
+		node.debug("The %s key is held by crypto provider [%s], removing it from the keystore...", keyType, csp.Name())
+		if err := os.Remove(node.conf.getPathForAlias(alias)); err != nil {
+			node.error("Failed removing private key [%s] from the keystore: [%s].", alias, err.Error())
+			return nil, err
+		}
+	}
+
+	return &providerKey{csp, key}, nil
+}
@@ -30,6 +30,7 @@ import (
 	"io/ioutil"
 
 	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/crypto/bccsp"
 	"github.com/hyperledger/fabric/core/crypto/primitives"
 	"github.com/hyperledger/fabric/core/crypto/primitives/ecies"
 	"github.com/hyperledger/fabric/core/crypto/utils"
@@ -152,14 +153,15 @@ func (node *nodeImpl) retrieveEnrollmentData(enrollID, enrollPWD string) error {
 func (node *nodeImpl) loadEnrollmentKey() error {
 	node.debug("Loading enrollment key...")
 
-	enrollPrivKey, err := node.ks.loadPrivateKey(node.conf.getEnrollmentKeyFilename())
+	enrollKey, err := node.loadProviderKey(bccsp.EnrollmentKey, node.conf.getEnrollmentKeyFilename())
 	if err != nil {
 		node.error("Failed loading enrollment private key [%s].", err.Error())
 
 		return err
 	}
 
-	node.enrollPrivKey = enrollPrivKey.(*ecdsa.PrivateKey)
+	node.enrollKey = enrollKey
+	node.enrollPrivKey = bccsp.PrivateKey(enrollKey.key)
 
 	return nil
 }
@@ -177,11 +179,10 @@ func (node *nodeImpl) loadEnrollmentCertificate() error {
 
 	// TODO: move this to retrieve
 	pk := node.enrollCert.PublicKey.(*ecdsa.PublicKey)
-	err = primitives.VerifySignCapability(node.enrollPrivKey, pk)
-	if err != nil {
-		node.error("Failed checking enrollment certificate against enrollment key [%s].", err.Error())
+	if pub := node.enrollKey.Public(); pub.X.Cmp(pk.X) != 0 || pub.Y.Cmp(pk.Y) != 0 {
+		node.error("Failed checking enrollment certificate against enrollment key [%s].", node.enrollKey.key.Label())
 
-		return err
+		return errors.New("Enrollment key does not match the enrollment certificate.")
 	}
 
 	// Set node ID
@@ -227,12 +228,16 @@ func (node *nodeImpl) loadEnrollmentChainKey() error {
 	// Code for confidentiality 1.1
 	if node.eType == NodeValidator {
 		// enrollChainKey is a secret key
-		enrollChainKey, err := node.ks.loadPrivateKey(node.conf.getEnrollmentChainKeyFilename())
+		enrollChainKey, err := node.loadProviderKey(bccsp.ChainKey, node.conf.getEnrollmentChainKeyFilename())
 		if err != nil {
 			node.error("Failed loading enrollment chain key: [%s]", err)
 			return err
 		}
-		node.enrollChainKey = enrollChainKey
+		if priv := bccsp.PrivateKey(enrollChainKey.key); priv != nil {
+			node.enrollChainKey = priv
+		} else {
+			node.enrollChainKey = enrollChainKey
+		}
 	} else {
 		// enrollChainKey is a public key
 		enrollChainKey, err := node.ks.loadPublicKey(node.conf.getEnrollmentChainKeyFilename())
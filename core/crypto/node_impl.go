@@ -49,10 +49,12 @@ type nodeImpl struct {
 	// Enrollment Certificate and private key
 	enrollID       string
 	enrollCert     *x509.Certificate
-	enrollPrivKey  *ecdsa.PrivateKey
+	enrollPrivKey  *ecdsa.PrivateKey // nil unless held by the software crypto provider
+	enrollKey      *providerKey
 	enrollCertHash []byte
 
-	// Enrollment Chain
+	// Enrollment Chain: the validators' private key is an *ecdsa.PrivateKey, or the
+	// *providerKey of a provider other than the software one
 	enrollChainKey interface{}
 
 	// TLS
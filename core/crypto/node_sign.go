@@ -26,7 +26,7 @@ func (node *nodeImpl) sign(signKey interface{}, msg []byte) ([]byte, error) {
 }
 
 func (node *nodeImpl) signWithEnrollmentKey(msg []byte) ([]byte, error) {
-	return primitives.ECDSASign(node.enrollPrivKey, msg)
+	return node.enrollKey.sign(msg)
 }
 
 func (node *nodeImpl) ecdsaSignWithEnrollmentKey(msg []byte) (*big.Int, *big.Int, error) {
@@ -128,7 +128,7 @@ func CloseAllPeers() (bool, []error) {
 // Private Methods
 
 func newPeer() *peerImpl {
-	return &peerImpl{&nodeImpl{}, sync.RWMutex{}, nil, false}
+	return &peerImpl{&nodeImpl{}, sync.RWMutex{}, nil, sync.Mutex{}, nil, false}
 }
 
 func closePeerInternal(peer Peer, force bool) error {
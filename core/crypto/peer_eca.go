@@ -84,27 +84,37 @@ func (peer *peerImpl) getEnrollmentCertByHashFromECA(id []byte) ([]byte, []byte,
 	}
 
 	// Check role
-	roleRaw, err := utils.GetCriticalExtension(x509Cert, ECertSubjectRole)
+	role, err := peer.getEnrollmentCertRole(x509Cert)
 	if err != nil {
-		peer.error("Failed parsing ECertSubjectRole in enrollment certificate for signing: [%s]", err)
-
 		return nil, nil, err
 	}
 
-	role, err := strconv.ParseInt(string(roleRaw), 10, len(roleRaw)*8)
+	if role != membersrvc.Role_VALIDATOR && role != membersrvc.Role_PEER {
+		peer.error("Invalid ECertSubjectRole in enrollment certificate for signing. Not a validator or peer: [%s]", role)
+
+		return nil, nil, fmt.Errorf("Invalid ECertSubjectRole [%s] in enrollment certificate for signing", role)
+	}
+
+	return response.Sign, response.Enc, nil
+}
+
+// getEnrollmentCertRole returns the role of the subject of an enrollment certificate
+func (peer *peerImpl) getEnrollmentCertRole(cert *x509.Certificate) (membersrvc.Role, error) {
+	roleRaw, err := utils.GetCriticalExtension(cert, ECertSubjectRole)
 	if err != nil {
 		peer.error("Failed parsing ECertSubjectRole in enrollment certificate for signing: [%s]", err)
 
-		return nil, nil, err
+		return 0, err
 	}
 
-	if membersrvc.Role(role) != membersrvc.Role_VALIDATOR && membersrvc.Role(role) != membersrvc.Role_PEER {
-		peer.error("Invalid ECertSubjectRole in enrollment certificate for signing. Not a validator or peer: [%s]", err)
+	role, err := strconv.ParseInt(string(roleRaw), 10, len(roleRaw)*8)
+	if err != nil {
+		peer.error("Failed parsing ECertSubjectRole in enrollment certificate for signing: [%s]", err)
 
-		return nil, nil, err
+		return 0, err
 	}
 
-	return response.Sign, response.Enc, nil
+	return membersrvc.Role(role), nil
 }
 
 func (peer *peerImpl) getNodeEnrollmentCertificate(sid string) *x509.Certificate {
@@ -20,9 +20,9 @@ import (
 	"crypto/ecdsa"
 	"crypto/x509"
 	"fmt"
-	"github.com/golang/protobuf/proto"
 	"github.com/hyperledger/fabric/core/crypto/primitives"
 	"github.com/hyperledger/fabric/core/crypto/utils"
+	membersrvc "github.com/hyperledger/fabric/membersrvc/protos"
 	obc "github.com/hyperledger/fabric/protos"
 	"sync"
 )
@@ -33,6 +33,9 @@ type peerImpl struct {
 	nodeEnrollmentCertificatesMutex sync.RWMutex
 	nodeEnrollmentCertificates      map[string]*x509.Certificate
 
+	revocationListsMutex sync.Mutex
+	revocationLists      map[string]*revocationList
+
 	isInitialized bool
 }
 
@@ -69,16 +72,17 @@ func (peer *peerImpl) TransactionPreValidation(tx *obc.Transaction) (*obc.Transa
 		}
 
 		// TODO: verify cert
+		if err := peer.verifyRevocation(cert); err != nil {
+			peer.error("TransactionPreValidation: certificate of [%s] revoked.", tx.Uuid)
+			return tx, err
+		}
 
-		// 3. Marshall tx without signature
-		signature := tx.Signature
-		tx.Signature = nil
-		rawTx, err := proto.Marshal(tx)
+		// 3. Marshall tx without signature nor transient data
+		rawTx, err := tx.SignedBytes()
 		if err != nil {
 			peer.error("TransactionPreExecution: failed marshaling tx [%s] [%s].", err.Error())
 			return tx, err
 		}
-		tx.Signature = signature
 
 		// 2. Verify signature
 		ok, err := peer.verify(cert.PublicKey, rawTx, tx.Signature)
@@ -138,6 +142,10 @@ func (peer *peerImpl) Verify(vkID, signature, message []byte) error {
 		return err
 	}
 
+	if err = peer.verifyRevocation(cert); err != nil {
+		return err
+	}
+
 	vk := cert.PublicKey.(*ecdsa.PublicKey)
 
 	ok, err := peer.verify(vk, message, signature)
@@ -156,6 +164,32 @@ func (peer *peerImpl) Verify(vkID, signature, message []byte) error {
 	return nil
 }
 
+// VerifyValidator checks, as Verify, that signature is a valid signature of message under vkID's
+// verification key, and that the enrollment certificate of vkID has been issued to a validator.
+func (peer *peerImpl) VerifyValidator(vkID, signature, message []byte) error {
+	if err := peer.Verify(vkID, signature, message); err != nil {
+		return err
+	}
+
+	cert, err := peer.getEnrollmentCert(vkID)
+	if err != nil {
+		return err
+	}
+
+	role, err := peer.getEnrollmentCertRole(cert)
+	if err != nil {
+		return err
+	}
+
+	if role != membersrvc.Role_VALIDATOR {
+		peer.error("Failed [% x] is not a validator", vkID)
+
+		return fmt.Errorf("Peer [% x] is not a validator", vkID)
+	}
+
+	return nil
+}
+
 func (peer *peerImpl) GetStateEncryptor(deployTx, invokeTx *obc.Transaction) (StateEncryptor, error) {
 	return nil, utils.ErrNotImplemented
 }
@@ -211,6 +245,7 @@ func (peer *peerImpl) init(eType NodeType, id string, pwd []byte) error {
 
 	// EnrollCerts
 	peer.nodeEnrollmentCertificates = make(map[string]*x509.Certificate)
+	peer.revocationLists = make(map[string]*revocationList)
 
 	return nil
 }
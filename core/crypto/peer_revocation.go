@@ -0,0 +1,139 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crypto
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+
+	"github.com/spf13/viper"
+
+	"github.com/hyperledger/fabric/core/crypto/utils"
+	"github.com/hyperledger/fabric/core/ledger"
+)
+
+// The ECA and the TCA revoke enrollment and transaction certificates by issuing certificate
+// revocation lists: the RevokeCertificate calls of their admin services record the revocations
+// and PublishCRL signs the list, written to eca.crl or tca.crl in the CA directory, which CONFIG
+// transactions ('peer network revoke') record on the ledger. A transaction signed with a
+// certificate listed by the recorded list of its issuer is rejected when it is submitted and when
+// it is executed. The lists of the committed state are used so that all the validating peers
+// reject the same transactions of a block, a list taking effect at the block following the one
+// of its transaction.
+
+func revocationVerificationEnabled() bool {
+	// If the verification of the revocation is enabled in the configuration file return the configured value
+	if viper.IsSet("security.revocation.verification") {
+		return viper.GetBool("security.revocation.verification")
+	}
+
+	// Revocation verification is enabled by default if no configuration was specified.
+	return true
+}
+
+// revocationList is a certificate revocation list recorded on the ledger, with the serial
+// numbers of the certificates it revokes
+type revocationList struct {
+	raw     []byte
+	revoked map[string]bool
+}
+
+// verifyRevocation returns utils.ErrRevokedCertificate if the certificate is revoked by the
+// revocation list of its issuer recorded on the ledger
+func (peer *peerImpl) verifyRevocation(cert *x509.Certificate) error {
+	if !revocationVerificationEnabled() {
+		return nil
+	}
+
+	issuer := cert.Issuer.String()
+	list, err := peer.getRevocationList(issuer)
+	if err != nil {
+		peer.error("verifyRevocation: failed getting the revocation list of [%s]: [%s]", issuer, err)
+		return err
+	}
+	if list != nil && list.revoked[cert.SerialNumber.String()] {
+		peer.warning("Certificate [%s] of [%s] is revoked", cert.SerialNumber, issuer)
+		return utils.ErrRevokedCertificate
+	}
+
+	return nil
+}
+
+// getRevocationList returns the revocation list of the issuer in the committed state, nil if
+// none. The list is parsed again only when a new one is recorded
+func (peer *peerImpl) getRevocationList(issuer string) (*revocationList, error) {
+	ledger, err := ledger.GetLedger()
+	if err != nil {
+		return nil, err
+	}
+	raw, err := ledger.GetRevocationList(issuer, true)
+	if err != nil || raw == nil {
+		return nil, err
+	}
+
+	peer.revocationListsMutex.Lock()
+	defer peer.revocationListsMutex.Unlock()
+
+	if list, ok := peer.revocationLists[issuer]; ok && bytes.Equal(list.raw, raw) {
+		return list, nil
+	}
+	crl, err := utils.DERToCRL(raw)
+	if err != nil {
+		return nil, err
+	}
+	list := &revocationList{raw: raw, revoked: utils.RevokedSerialNumbers(crl)}
+	peer.revocationLists[issuer] = list
+
+	return list, nil
+}
+
+// VerifyRevocationList verifies that the DER encoded certificate revocation list is signed
+// by the ECA or the TCA
+func (peer *peerImpl) VerifyRevocationList(der []byte) error {
+	if !peer.isInitialized {
+		return utils.ErrNotInitialized
+	}
+
+	crl, err := utils.DERToCRL(der)
+	if err != nil {
+		peer.error("VerifyRevocationList: failed parsing revocation list [%s].", err)
+		return err
+	}
+	issuer := utils.CRLIssuer(crl)
+
+	for _, alias := range []string{peer.conf.getECACertsChainFilename(), peer.conf.getTCACertsChainFilename()} {
+		raw, err := peer.ks.loadCert(alias)
+		if err != nil {
+			return err
+		}
+		for block, rest := pem.Decode(raw); block != nil; block, rest = pem.Decode(rest) {
+			caCert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil || caCert.Subject.String() != issuer {
+				continue
+			}
+			if err = caCert.CheckCRLSignature(crl); err != nil {
+				peer.error("VerifyRevocationList: invalid signature of revocation list of [%s]: [%s].", issuer, err)
+				return err
+			}
+			return nil
+		}
+	}
+
+	return fmt.Errorf("Revocation list issued by [%s], which is neither the ECA nor the TCA", issuer)
+}
@@ -0,0 +1,102 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package crypto
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+
+	"github.com/hyperledger/fabric/core/crypto/primitives"
+	"github.com/hyperledger/fabric/core/crypto/utils"
+	obc "github.com/hyperledger/fabric/protos"
+)
+
+// GetChaincodeStateEncryptor returns the encryptor of the state of the chaincode whose key is
+// wrapped in keys, which it unwraps with the enrollment key of the peer.
+// utils.ErrStateKeyNotFound is returned if the key is not wrapped for the peer.
+func (peer *peerImpl) GetChaincodeStateEncryptor(keys *obc.ChaincodeStateKeys) (StateEncryptor, error) {
+	if !peer.isInitialized {
+		return nil, utils.ErrNotInitialized
+	}
+
+	var wrapped []byte
+	for _, k := range keys.GetKeys() {
+		if k.Peer == peer.enrollID && bytes.Equal(k.PkiID, peer.id) {
+			wrapped = k.Key
+			break
+		}
+	}
+	if wrapped == nil {
+		return nil, utils.ErrStateKeyNotFound
+	}
+
+	sk, err := peer.eciesSPI.NewPrivateKey(nil, peer.enrollKey)
+	if err != nil {
+		return nil, err
+	}
+	cipher, err := peer.eciesSPI.NewAsymmetricCipherFromPrivateKey(sk)
+	if err != nil {
+		return nil, err
+	}
+	key, err := cipher.Process(wrapped)
+	if err != nil {
+		peer.error("Failed unwrapping state key [%s].", err.Error())
+		return nil, err
+	}
+
+	se := &chaincodeStateEncryptor{}
+	if err = se.init(key); err != nil {
+		return nil, err
+	}
+	return se, nil
+}
+
+// chaincodeStateEncryptor encrypts the state values of a chaincode with AES-GCM. The nonce
+// of a value is derived from the value, so that the peers authorized to read the state,
+// which execute the transactions of the chaincode separately, agree on its ciphertext.
+// As a consequence equal values have equal ciphertexts.
+type chaincodeStateEncryptor struct {
+	nonceKey []byte
+	gcm      cipher.AEAD
+}
+
+func (se *chaincodeStateEncryptor) init(key []byte) error {
+	c, err := aes.NewCipher(primitives.HMACTruncated(key, []byte{1}, primitives.AESKeyLength))
+	if err != nil {
+		return err
+	}
+	if se.gcm, err = cipher.NewGCM(c); err != nil {
+		return err
+	}
+	se.nonceKey = primitives.HMAC(key, []byte{2})
+	return nil
+}
+
+func (se *chaincodeStateEncryptor) Encrypt(msg []byte) ([]byte, error) {
+	nonce := primitives.HMACTruncated(se.nonceKey, msg, se.gcm.NonceSize())
+	return se.gcm.Seal(nonce, nonce, msg, nil), nil
+}
+
+func (se *chaincodeStateEncryptor) Decrypt(ct []byte) ([]byte, error) {
+	nonceSize := se.gcm.NonceSize()
+	if len(ct) < nonceSize {
+		return nil, errors.New("Invalid ciphertext. Too short.")
+	}
+	return se.gcm.Open(nil, ct[:nonceSize], ct[nonceSize:], nil)
+}
@@ -25,6 +25,7 @@ import (
 	"crypto/rand"
 	"errors"
 	"io"
+	"math/big"
 
 	"crypto/subtle"
 	"fmt"
@@ -129,8 +130,8 @@ func eciesEncrypt(rand io.Reader, pub *ecdsa.PublicKey, s1, s2 []byte, plain []b
 	return ciphertext, nil
 }
 
-func eciesDecrypt(priv *ecdsa.PrivateKey, s1, s2 []byte, ciphertext []byte) ([]byte, error) {
-	params := priv.Curve
+func eciesDecrypt(sk *secretKeyImpl, s1, s2 []byte, ciphertext []byte) ([]byte, error) {
+	priv := sk.priv
 
 	var (
 		rLen   int
@@ -174,8 +175,17 @@ func eciesDecrypt(priv *ecdsa.PrivateKey, s1, s2 []byte, ciphertext []byte) ([]b
 
 	// Derive a shared secret field element z from the ephemeral secret key k
 	// and convert z to an octet string Z
-	z, _ := params.ScalarMult(Rx, Ry, priv.D.Bytes())
-	Z := z.Bytes()
+	var Z []byte
+	if sk.agreement != nil {
+		secret, err := sk.agreement.SharedSecret(Rx, Ry)
+		if err != nil {
+			return nil, err
+		}
+		Z = new(big.Int).SetBytes(secret).Bytes()
+	} else {
+		z, _ := priv.Curve.ScalarMult(Rx, Ry, priv.D.Bytes())
+		Z = z.Bytes()
+	}
 	//fmt.Printf("Z %s\n", utils.EncodeBase64(Z))
 
 	// generate keying data K of length ecnKeyLen + macKeyLen octects from Z
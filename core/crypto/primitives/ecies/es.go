@@ -62,5 +62,5 @@ func (es *encryptionSchemeImpl) Process(msg []byte) ([]byte, error) {
 	}
 
 	// Decrypt
-	return eciesDecrypt(es.priv.priv, nil, nil, msg)
+	return eciesDecrypt(es.priv, nil, nil, msg)
 }
@@ -62,5 +62,5 @@ func (kg *keyGeneratorImpl) GenerateKey() (primitives.PrivateKey, error) {
 		return nil, err
 	}
 
-	return &secretKeyImpl{privKey, nil, kg.params.params, kg.params.rand}, nil
+	return &secretKeyImpl{privKey, nil, kg.params.params, kg.params.rand, nil}, nil
 }
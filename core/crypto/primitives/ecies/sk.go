@@ -21,6 +21,7 @@ import (
 	"crypto/rand"
 	"crypto/x509"
 	"io"
+	"math/big"
 
 	"github.com/hyperledger/fabric/core/crypto/primitives"
 )
@@ -30,6 +31,21 @@ type secretKeyImpl struct {
 	pub    primitives.PublicKey
 	params *Params
 	rand   io.Reader
+
+	// agreement derives the shared secrets when the private key is held outside of the
+	// process, in which case priv only holds the public key
+	agreement KeyAgreement
+}
+
+// KeyAgreement is a private key held outside of the process, such as in an HSM, which
+// derives the shared secrets of the ECIES decryption
+type KeyAgreement interface {
+	// Public returns the public key
+	Public() *ecdsa.PublicKey
+
+	// SharedSecret returns the x coordinate of the product of the private key and the
+	// point (x, y)
+	SharedSecret(x, y *big.Int) ([]byte, error)
 }
 
 func (sk *secretKeyImpl) IsPublic() bool {
@@ -69,5 +85,5 @@ func (sks *secretKeySerializerImpl) FromBytes(bytes []byte) (interface{}, error)
 	}
 
 	// TODO: add params here
-	return &secretKeyImpl{key, nil, nil, rand.Reader}, nil
+	return &secretKeyImpl{key, nil, nil, rand.Reader, nil}, nil
 }
@@ -56,7 +56,7 @@ func newPublicKeyFromECDSA(pk *ecdsa.PublicKey) (primitives.PublicKey, error) {
 }
 
 func newPrivateKeyFromECDSA(sk *ecdsa.PrivateKey) (primitives.PrivateKey, error) {
-	return &secretKeyImpl{sk, nil, nil, rand.Reader}, nil
+	return &secretKeyImpl{sk, nil, nil, rand.Reader, nil}, nil
 }
 
 func serializePrivateKey(priv primitives.PrivateKey) ([]byte, error) {
@@ -165,6 +165,8 @@ func (spi *spiImpl) NewPrivateKey(r io.Reader, params interface{}) (primitives.P
 	switch t := params.(type) {
 	case *ecdsa.PrivateKey:
 		return newPrivateKeyFromECDSA(t)
+	case KeyAgreement:
+		return &secretKeyImpl{&ecdsa.PrivateKey{PublicKey: *t.Public()}, nil, nil, rand.Reader, t}, nil
 	case elliptic.Curve:
 		if r == nil {
 			r = rand.Reader
@@ -23,12 +23,15 @@ import (
 	"crypto/x509"
 	"fmt"
 	"github.com/hyperledger/fabric/core/crypto/primitives"
+	"math/big"
+	"os"
 	"reflect"
 	"testing"
 )
 
 func TestMain(m *testing.M) {
 	primitives.InitSecurityLevel("SHA3", 256)
+	os.Exit(m.Run())
 }
 
 func TestSPI(t *testing.T) {
@@ -169,6 +172,59 @@ func TestES(t *testing.T) {
 	}
 }
 
+// softKeyAgreement is a KeyAgreement on a private key held in memory
+type softKeyAgreement struct {
+	priv *ecdsa.PrivateKey
+}
+
+func (k *softKeyAgreement) Public() *ecdsa.PublicKey {
+	return &k.priv.PublicKey
+}
+
+func (k *softKeyAgreement) SharedSecret(x, y *big.Int) ([]byte, error) {
+	z, _ := k.priv.Curve.ScalarMult(x, y, k.priv.D.Bytes())
+	return z.Bytes(), nil
+}
+
+func TestKeyAgreement(t *testing.T) {
+	spi := NewSPI()
+
+	ecdsaKey, err := ecdsa.GenerateKey(primitives.GetDefaultCurve(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := spi.NewPublicKey(rand.Reader, &ecdsaKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privKey, err := spi.NewPrivateKey(rand.Reader, &softKeyAgreement{ecdsaKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cipher, err := spi.NewAsymmetricCipherFromPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext := []byte("Hello World!!!")
+	ciphertext, err := cipher.Process(plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cipher, err = spi.NewAsymmetricCipherFromPrivateKey(privKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext2, err := cipher.Process(ciphertext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(plaintext, plaintext2) {
+		t.Fatalf("Decryption failed [%s]!=[%s]", string(plaintext), string(plaintext2))
+	}
+}
+
 func generateKey() primitives.PrivateKey {
 	kg, _ := newKeyGenerator()
 	kgparams, _ := newKeyGeneratorParameter(rand.Reader, primitives.GetDefaultCurve())
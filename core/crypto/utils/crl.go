@@ -0,0 +1,53 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+)
+
+// DERToCRL converts der to a certificate revocation list
+func DERToCRL(der []byte) (*pkix.CertificateList, error) {
+	crl, err := x509.ParseDERCRL(der)
+	if err != nil {
+		return nil, err
+	}
+	if len(crl.TBSCertList.Issuer) == 0 {
+		return nil, errors.New("Certificate revocation list without issuer")
+	}
+	return crl, nil
+}
+
+// CRLIssuer returns the name of the CA which issued the certificate revocation list, in the
+// form of the Issuer.String() of the certificates it revokes
+func CRLIssuer(crl *pkix.CertificateList) string {
+	var issuer pkix.Name
+	issuer.FillFromRDNSequence(&crl.TBSCertList.Issuer)
+	return issuer.String()
+}
+
+// RevokedSerialNumbers returns the set of the serial numbers of the certificates revoked by the
+// certificate revocation list, in decimal
+func RevokedSerialNumbers(crl *pkix.CertificateList) map[string]bool {
+	revoked := make(map[string]bool, len(crl.TBSCertList.RevokedCertificates))
+	for _, entry := range crl.TBSCertList.RevokedCertificates {
+		revoked[entry.SerialNumber.String()] = true
+	}
+	return revoked
+}
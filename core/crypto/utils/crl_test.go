@@ -0,0 +1,79 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestRevocationList(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "tca", Organization: []string{"Hyperledger"}},
+		NotBefore:             now,
+		NotAfter:              now.Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := DERToX509Certificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	revoked := []pkix.RevokedCertificate{{SerialNumber: big.NewInt(7), RevocationTime: now}, {SerialNumber: big.NewInt(1234567890123), RevocationTime: now}}
+	raw, err := ca.CreateCRL(rand.Reader, key, revoked, now, now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	crl, err := DERToCRL(raw)
+	if err != nil {
+		t.Fatalf("Failed parsing revocation list: %s", err)
+	}
+	if err = ca.CheckCRLSignature(crl); err != nil {
+		t.Fatalf("Invalid signature of revocation list: %s", err)
+	}
+
+	// the issuer is named as in the certificates the CA issues
+	if issuer := CRLIssuer(crl); issuer != ca.Subject.String() {
+		t.Fatalf("Expected issuer [%s], got [%s]", ca.Subject.String(), issuer)
+	}
+	serials := RevokedSerialNumbers(crl)
+	if len(serials) != 2 || !serials["7"] || !serials["1234567890123"] || serials["1"] {
+		t.Fatalf("Unexpected revoked serial numbers %v", serials)
+	}
+
+	if _, err = DERToCRL(der); err == nil {
+		t.Fatal("Expected error parsing a certificate as a revocation list")
+	}
+}
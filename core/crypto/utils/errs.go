@@ -84,6 +84,12 @@ var (
 
 	// ErrInvalidProtocolVersion Invalid protocol version
 	ErrInvalidProtocolVersion = errors.New("Invalid protocol version")
+
+	// ErrRevokedCertificate Certificate revoked
+	ErrRevokedCertificate = errors.New("Certificate revoked.")
+
+	// ErrStateKeyNotFound No state key wrapped for the peer
+	ErrStateKeyNotFound = errors.New("No state key wrapped for this peer.")
 )
 
 // ErrToString converts and error to a string. If the error is nil, it returns the string "<clean>"
@@ -67,6 +67,18 @@ func (validator *validatorImpl) TransactionPreExecution(tx *obc.Transaction) (*o
 		}
 	}
 
+	if tx.Cert != nil {
+		cert, err := utils.DERToX509Certificate(tx.Cert)
+		if err != nil {
+			validator.error("TransactionPreExecution: failed unmarshalling cert %s:", err)
+			return tx, err
+		}
+		if err = validator.verifyRevocation(cert); err != nil {
+			validator.error("TransactionPreExecution: error verifying certificate revocation %s:", err)
+			return tx, err
+		}
+	}
+
 	switch tx.ConfidentialityLevel {
 	case obc.ConfidentialityLevel_PUBLIC:
 		// Nothing to do here!
@@ -116,6 +128,10 @@ func (validator *validatorImpl) Verify(vkID, signature, message []byte) error {
 		return err
 	}
 
+	if err = validator.verifyRevocation(cert); err != nil {
+		return err
+	}
+
 	vk := cert.PublicKey.(*ecdsa.PublicKey)
 
 	ok, err := validator.verify(vk, message, signature)
@@ -179,9 +195,7 @@ func (validator *validatorImpl) init(name string, pwd []byte) error {
 
 func (validator *validatorImpl) initCryptoEngine() (err error) {
 	// Init chain publicKey
-	validator.chainPrivateKey, err = validator.eciesSPI.NewPrivateKey(
-		nil, validator.enrollChainKey.(*ecdsa.PrivateKey),
-	)
+	validator.chainPrivateKey, err = validator.eciesSPI.NewPrivateKey(nil, validator.enrollChainKey)
 	if err != nil {
 		return
 	}
@@ -0,0 +1,269 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package db
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/spf13/viper"
+	"github.com/tecbot/gorocksdb"
+)
+
+// BackupInfo describes a backup taken by CreateBackup
+type BackupInfo struct {
+	ID        int64
+	Timestamp int64
+	Size      int64
+	NumFiles  int32
+}
+
+// CreateBackup takes a backup of the DB returned by GetDBHandle and of the DBs of the other
+// chains into the directory dir, which is created if missing. dir is relative to the
+// directory configured by 'peer.db.backupDir', outside of which no backup can be taken. The
+// DB of each chain is backed up into dir/chains/<chainID>. The backup of a DB is consistent
+// across all its column families and can be taken while the peer is running. Backups are
+// incremental, hence, backups taken in the same directory share the files that did not
+// change. The returned info describes the backup of the DB returned by GetDBHandle.
+func CreateBackup(dir string) (*BackupInfo, error) {
+	dir, err := resolveBackupDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	info, err := createBackup(GetDBHandle(), dir)
+	if err != nil {
+		return nil, err
+	}
+	chainDBsLock.Lock()
+	defer chainDBsLock.Unlock()
+	chainIDs, err := getChainIDsOnDisk()
+	if err != nil {
+		return nil, err
+	}
+	for _, chainID := range chainIDs {
+		chainDB, ok := chainDBs[chainID]
+		if !ok {
+			// the DB of a chain whose ledger is not open is opened for the time of the backup,
+			// holding chainDBsLock keeps OpenChainDB from opening it meanwhile
+			if chainDB, err = openDBAtPath(getChainDBPath(chainID), false); err != nil {
+				return nil, err
+			}
+			defer chainDB.close()
+		}
+		if _, err := createBackup(chainDB, getChainBackupDir(dir, chainID)); err != nil {
+			return nil, err
+		}
+	}
+	return info, nil
+}
+
+// GetLatestBackupInfo returns the latest backup in the directory dir or nil if the directory
+// does not contain any backup
+func GetLatestBackupInfo(dir string) (*BackupInfo, error) {
+	backupEngine, err := openBackupEngine(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer backupEngine.Close()
+	return getLatestBackupInfo(backupEngine), nil
+}
+
+// RestoreFromBackup replaces the DB with the latest backup in the directory dir, along with
+// the DBs of the chains backed up in dir/chains. The DBs of the chains created after the
+// backup are left as they are. The DBs must not be in use by any process, i.e., the peer
+// has to be stopped.
+func RestoreFromBackup(dir string) error {
+	if exists, err := dirExists(dir); err != nil || !exists {
+		return fmt.Errorf("Backup dir [%s] does not exist", dir)
+	}
+	chainIDs, err := getChainIDsInBackup(dir)
+	if err != nil {
+		return err
+	}
+	dbPaths := map[string]string{dir: getDBPath()}
+	for _, chainID := range chainIDs {
+		dbPaths[getChainBackupDir(dir, chainID)] = getChainDBPath(chainID)
+	}
+	// all the DBs are checked before any is restored, so that a DB in use does not leave a
+	// partial restore behind
+	chainDBsLock.Lock()
+	defer chainDBsLock.Unlock()
+	if isOpen || len(chainDBs) != 0 {
+		return fmt.Errorf("The DB must be closed before restoring it from a backup")
+	}
+	for _, dbPath := range dbPaths {
+		if err := checkDBNotInUse(dbPath); err != nil {
+			return err
+		}
+	}
+	for backupDir, dbPath := range dbPaths {
+		if err := restoreFromBackup(backupDir, dbPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func createBackup(openchainDB *OpenchainDB, dir string) (*BackupInfo, error) {
+	backupEngine, err := openBackupEngine(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer backupEngine.Close()
+	dbLogger.Info("Creating backup of the DB at [%s] in [%s]", openchainDB.dbPath, dir)
+	if err := backupEngine.CreateNewBackup(openchainDB.DB); err != nil {
+		return nil, fmt.Errorf("Error creating backup in [%s]: %s", dir, err)
+	}
+	info := getLatestBackupInfo(backupEngine)
+	if info == nil {
+		return nil, fmt.Errorf("Backup not found in [%s] after its creation", dir)
+	}
+	return info, nil
+}
+
+func restoreFromBackup(dir string, dbPath string) error {
+	backupEngine, err := openBackupEngine(dir)
+	if err != nil {
+		return err
+	}
+	defer backupEngine.Close()
+	if getLatestBackupInfo(backupEngine) == nil {
+		return fmt.Errorf("No backup found in [%s]", dir)
+	}
+	if err := os.MkdirAll(dbPath, 0755); err != nil {
+		return fmt.Errorf("Error making directory path [%s]: %s", dbPath, err)
+	}
+	ro := gorocksdb.NewRestoreOptions()
+	defer ro.Destroy()
+	dbLogger.Info("Restoring the DB at [%s] from the latest backup in [%s]", dbPath, dir)
+	if err := backupEngine.RestoreDBFromLatestBackup(dbPath, dbPath, ro); err != nil {
+		return fmt.Errorf("Error restoring the DB from [%s]: %s", dir, err)
+	}
+	return nil
+}
+
+// resolveBackupDir returns the path of dir, which is relative to 'peer.db.backupDir', and
+// fails if the path is outside of 'peer.db.backupDir'. Absolute paths are accepted as long
+// as they are inside of 'peer.db.backupDir'.
+func resolveBackupDir(dir string) (string, error) {
+	rootDir := viper.GetString("peer.db.backupDir")
+	if rootDir == "" {
+		return "", fmt.Errorf("Backups are disabled. Please check that property 'peer.db.backupDir' is set")
+	}
+	rootDir = filepath.Clean(rootDir)
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(rootDir, dir)
+	}
+	dir = filepath.Clean(dir)
+	rel, err := filepath.Rel(rootDir, dir)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("Backup dir [%s] is outside of the backup dir [%s] of the peer", dir, rootDir)
+	}
+	return dir, nil
+}
+
+func getChainBackupDir(dir string, chainID string) string {
+	return filepath.Join(dir, "chains", chainID)
+}
+
+// getChainIDsOnDisk returns the IDs of the chains that have a DB under 'peer.fileSystemPath'/chains
+func getChainIDsOnDisk() ([]string, error) {
+	return listChainIDs(getFileSystemPath()+"chains", func(chainDir string) string { return filepath.Join(chainDir, "db") })
+}
+
+// getChainIDsInBackup returns the IDs of the chains that have a backup under dir/chains
+func getChainIDsInBackup(dir string) ([]string, error) {
+	return listChainIDs(filepath.Join(dir, "chains"), func(chainDir string) string { return chainDir })
+}
+
+// listChainIDs returns the names of the sub-directories of chainsDir whose dbDir is not empty
+func listChainIDs(chainsDir string, dbDir func(chainDir string) string) ([]string, error) {
+	files, err := ioutil.ReadDir(chainsDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error listing the chains in [%s]: %s", chainsDir, err)
+	}
+	var chainIDs []string
+	for _, file := range files {
+		if !file.IsDir() {
+			continue
+		}
+		missing, err := dirMissingOrEmpty(dbDir(filepath.Join(chainsDir, file.Name())))
+		if err != nil {
+			return nil, err
+		}
+		if !missing {
+			chainIDs = append(chainIDs, file.Name())
+		}
+	}
+	return chainIDs, nil
+}
+
+// checkDBNotInUse fails if another process holds the lock that RocksDB takes on the LOCK file
+// of the DB at dbPath while the DB is open. The lock is tested, not taken, as closing the file
+// would release the locks of this process on it.
+func checkDBNotInUse(dbPath string) error {
+	lockFile, err := os.OpenFile(filepath.Join(dbPath, "LOCK"), os.O_RDWR, 0)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("Error opening the LOCK file of the DB at [%s]: %s", dbPath, err)
+	}
+	defer lockFile.Close()
+	lock := syscall.Flock_t{Type: syscall.F_WRLCK}
+	if err := syscall.FcntlFlock(lockFile.Fd(), syscall.F_GETLK, &lock); err != nil {
+		return fmt.Errorf("Error testing the lock of the DB at [%s]: %s", dbPath, err)
+	}
+	if lock.Type != syscall.F_UNLCK {
+		return fmt.Errorf("The DB at [%s] is in use by process [%d]. It must be closed before restoring it from a backup", dbPath, lock.Pid)
+	}
+	return nil
+}
+
+func openBackupEngine(dir string) (*gorocksdb.BackupEngine, error) {
+	opts := gorocksdb.NewDefaultOptions()
+	defer opts.Destroy()
+	opts.SetCreateIfMissing(true)
+	backupEngine, err := gorocksdb.OpenBackupEngine(opts, dir)
+	if err != nil {
+		return nil, fmt.Errorf("Error opening backup dir [%s]: %s", dir, err)
+	}
+	return backupEngine, nil
+}
+
+func getLatestBackupInfo(backupEngine *gorocksdb.BackupEngine) *BackupInfo {
+	engineInfo := backupEngine.GetInfo()
+	defer engineInfo.Destroy()
+	count := engineInfo.GetCount()
+	if count == 0 {
+		return nil
+	}
+	latest := count - 1
+	return &BackupInfo{
+		ID:        engineInfo.GetBackupId(latest),
+		Timestamp: engineInfo.GetTimestamp(latest),
+		Size:      engineInfo.GetSize(latest),
+		NumFiles:  engineInfo.GetNumFiles(latest),
+	}
+}
@@ -0,0 +1,169 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package db
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+
+	"github.com/hyperledger/fabric/core/errors"
+	"github.com/spf13/viper"
+)
+
+// checksummedColumnFamilies hold the data nodes of the state and the state deltas, whose
+// corruption otherwise surfaces only as a mismatch of the state hash
+var checksummedColumnFamilies = map[string]bool{
+	stateCF:      true,
+	stateDeltaCF: true,
+}
+
+// checksumsKey is the key in persistCF recording that the values of the checksummed column
+// families carry a checksum. Checksums are enabled ('peer.db.checksums') only when the DB
+// is created, as the values already stored do not have one.
+var checksumsKey = []byte("db.checksums")
+
+const checksumSize = crc32.Size
+
+var castagnoliTable = crc32.MakeTable(crc32.Castagnoli)
+
+// CorruptRecordError is returned for a value whose checksum does not match or that cannot be
+// decrypted
+type CorruptRecordError struct {
+	CFName string
+	Key    []byte
+	Reason string
+}
+
+func (e *CorruptRecordError) Error() string {
+	return fmt.Sprintf("Corrupt record with key [%x] in column family [%s]: %s", e.Key, e.CFName, e.Reason)
+}
+
+// Code returns errors.DataCorrupted
+func (e *CorruptRecordError) Code() errors.Code {
+	return errors.DataCorrupted
+}
+
+// checksum covers the key as well so that a value stored under another key is detected
+func checksum(key []byte, value []byte) uint32 {
+	crc := crc32.Update(0, castagnoliTable, key)
+	return crc32.Update(crc, castagnoliTable, value)
+}
+
+func appendChecksum(key []byte, value []byte) []byte {
+	stored := make([]byte, len(value)+checksumSize)
+	copy(stored, value)
+	binary.BigEndian.PutUint32(stored[len(value):], checksum(key, value))
+	return stored
+}
+
+func verifyChecksum(cfName string, key []byte, stored []byte) ([]byte, error) {
+	if len(stored) < checksumSize {
+		return nil, &CorruptRecordError{cfName, key, "value shorter than the checksum"}
+	}
+	value := stored[:len(stored)-checksumSize]
+	if binary.BigEndian.Uint32(stored[len(value):]) != checksum(key, value) {
+		return nil, &CorruptRecordError{cfName, key, "checksum mismatch"}
+	}
+	return value, nil
+}
+
+// encodeValue returns the value to be stored for the key in the column family: the value
+// followed by its checksum, if enabled, and then encrypted, if enabled
+func (openchainDB *OpenchainDB) encodeValue(cfName string, key []byte, value []byte) []byte {
+	if openchainDB.checksums && checksummedColumnFamilies[cfName] {
+		value = appendChecksum(key, value)
+	}
+	return openchainDB.encryptValue(cfName, key, value)
+}
+
+// decodeValue reverts encodeValue. A value that cannot be decoded yields a CorruptRecordError.
+func (openchainDB *OpenchainDB) decodeValue(cfName string, key []byte, stored []byte) ([]byte, error) {
+	value, err := openchainDB.decryptValue(cfName, key, stored)
+	if err != nil {
+		return nil, err
+	}
+	if value == nil || !openchainDB.checksums || !checksummedColumnFamilies[cfName] {
+		return value, nil
+	}
+	return verifyChecksum(cfName, key, value)
+}
+
+// checkChecksums enables the checksums if they have been enabled when the DB was created
+func (openchainDB *OpenchainDB) checkChecksums() error {
+	enabled, err := openchainDB.Get(openchainDB.PersistCF, checksumsKey)
+	if err != nil {
+		return err
+	}
+	if enabled != nil {
+		openchainDB.checksums = true
+		return nil
+	}
+	if !viper.GetBool("peer.db.checksums") {
+		return nil
+	}
+	for cfName := range checksummedColumnFamilies {
+		itr := openchainDB.GetIterator(openchainDB.getCFHandler(cfName))
+		itr.SeekToFirst()
+		hasData := itr.Valid()
+		itr.Close()
+		if hasData {
+			dbLogger.Warning("Checksums not enabled: column family [%s] of the DB already holds values without checksum", cfName)
+			return nil
+		}
+	}
+	dbLogger.Info("Enabling checksums of the DB")
+	if err := openchainDB.Put(openchainDB.PersistCF, checksumsKey, []byte{1}); err != nil {
+		return err
+	}
+	openchainDB.checksums = true
+	return nil
+}
+
+// ScrubReport reports the records scanned by Scrub
+type ScrubReport struct {
+	// NumRecords is the number of records scanned per column family
+	NumRecords map[string]uint64
+	Corrupt    []*CorruptRecordError
+	// ChecksumsEnabled is false for the DBs created before checksums were enabled, for which
+	// only the decryption of the encrypted values, if any, can detect corruption
+	ChecksumsEnabled bool
+}
+
+// Scrub reads all the records of all the column families and verifies their checksums and
+// their encryption. The records of the column families without checksum are only read, which
+// makes rocksdb verify the checksums of the blocks of the table files.
+func (openchainDB *OpenchainDB) Scrub() (*ScrubReport, error) {
+	report := &ScrubReport{NumRecords: make(map[string]uint64), ChecksumsEnabled: openchainDB.checksums}
+	for _, cfName := range columnfamilies {
+		itr := openchainDB.GetIterator(openchainDB.getCFHandler(cfName))
+		for itr.SeekToFirst(); itr.Valid(); itr.Next() {
+			report.NumRecords[cfName]++
+			key := makeCopy(itr.Key().Data())
+			if _, err := openchainDB.decodeValue(cfName, key, makeCopy(itr.Value().Data())); err != nil {
+				report.Corrupt = append(report.Corrupt, err.(*CorruptRecordError))
+			}
+		}
+		err := itr.Err()
+		itr.Close()
+		if err != nil {
+			return report, fmt.Errorf("Error scanning column family [%s]: %s", cfName, err)
+		}
+		dbLogger.Info("Scrubbed [%d] records of column family [%s]", report.NumRecords[cfName], cfName)
+	}
+	return report, nil
+}
@@ -0,0 +1,114 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package db
+
+import (
+	"sync"
+	"time"
+
+	"github.com/hyperledger/fabric/core/db/kvstore"
+	"github.com/spf13/viper"
+)
+
+// CompactionStats reports the outcome of the compaction of a column family
+type CompactionStats struct {
+	Name string `json:"name"`
+	// BytesBefore and BytesAfter are the size of the table files before and after the compaction
+	BytesBefore uint64        `json:"bytesBefore"`
+	BytesAfter  uint64        `json:"bytesAfter"`
+	Duration    time.Duration `json:"duration"`
+}
+
+// DefaultCompactionColumnFamilies are the column families compacted when none is specified.
+// These are the column families in which keys get deleted, hence, where tombstones accumulate.
+var DefaultCompactionColumnFamilies = []string{stateCF, stateDeltaCF}
+
+// CompactColumnFamilies compacts the given column families (DefaultCompactionColumnFamilies if
+// none is given) one after the other and reports the size of each of them before and after
+func (openchainDB *OpenchainDB) CompactColumnFamilies(cfNames ...string) ([]*CompactionStats, error) {
+	if len(cfNames) == 0 {
+		cfNames = DefaultCompactionColumnFamilies
+	}
+	var allStats []*CompactionStats
+	for _, cfName := range cfNames {
+		cfHandler := openchainDB.getCFHandler(cfName)
+		if cfHandler == nil {
+			return allStats, kvstore.UnknownColumnFamily(cfName)
+		}
+		stats := &CompactionStats{Name: cfName}
+		stats.BytesBefore = openchainDB.getUint64PropertyCF("rocksdb.total-sst-files-size", cfHandler)
+		start := time.Now()
+		if err := openchainDB.CompactColumnFamily(cfName); err != nil {
+			return allStats, err
+		}
+		stats.Duration = time.Since(start)
+		stats.BytesAfter = openchainDB.getUint64PropertyCF("rocksdb.total-sst-files-size", cfHandler)
+		dbLogger.Info("Compacted column family [%s] in %s: [%d] bytes before, [%d] bytes after",
+			cfName, stats.Duration, stats.BytesBefore, stats.BytesAfter)
+		allStats = append(allStats, stats)
+	}
+	return allStats, nil
+}
+
+// CompactionScheduler compacts column families of a DB periodically
+type CompactionScheduler struct {
+	openchainDB *OpenchainDB
+	interval    time.Duration
+	cfNames     []string
+	stop        chan struct{}
+	stopOnce    sync.Once
+}
+
+// NewCompactionSchedulerFromConfig returns a scheduler for the DB returned by GetDBHandle
+// as per 'peer.db.compaction', or nil if the periodic compaction is disabled
+func NewCompactionSchedulerFromConfig() *CompactionScheduler {
+	interval := viper.GetDuration("peer.db.compaction.interval")
+	if interval <= 0 {
+		return nil
+	}
+	return NewCompactionScheduler(GetDBHandle(), interval, viper.GetStringSlice("peer.db.compaction.columnFamilies")...)
+}
+
+// NewCompactionScheduler returns a scheduler compacting the given column families
+// (DefaultCompactionColumnFamilies if none is given) every interval, once started
+func NewCompactionScheduler(openchainDB *OpenchainDB, interval time.Duration, cfNames ...string) *CompactionScheduler {
+	return &CompactionScheduler{openchainDB: openchainDB, interval: interval, cfNames: cfNames, stop: make(chan struct{})}
+}
+
+// Start runs the compactions in the background until Stop is invoked
+func (scheduler *CompactionScheduler) Start() {
+	dbLogger.Info("Compacting column families %v every %s", scheduler.cfNames, scheduler.interval)
+	go func() {
+		ticker := time.NewTicker(scheduler.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := scheduler.openchainDB.CompactColumnFamilies(scheduler.cfNames...); err != nil {
+					dbLogger.Error("Error during the periodic compaction: %s", err)
+				}
+			case <-scheduler.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the compactions. A compaction in progress is not interrupted.
+func (scheduler *CompactionScheduler) Stop() {
+	scheduler.stopOnce.Do(func() { close(scheduler.stop) })
+}
@@ -22,7 +22,11 @@ import (
 	"os"
 	"path"
 	"strings"
+	"sync"
+	"sync/atomic"
 
+	"github.com/hyperledger/fabric/core/db/kvstore"
+	"github.com/hyperledger/fabric/core/errors"
 	"github.com/op/go-logging"
 	"github.com/spf13/viper"
 	"github.com/tecbot/gorocksdb"
@@ -30,33 +34,57 @@ import (
 
 var dbLogger = logging.MustGetLogger("db")
 
-const blockchainCF = "blockchainCF"
-const stateCF = "stateCF"
-const stateDeltaCF = "stateDeltaCF"
-const indexesCF = "indexesCF"
-const persistCF = "persistCF"
+const (
+	blockchainCF       = kvstore.BlockchainCFName
+	stateCF            = kvstore.StateCFName
+	stateDeltaCF       = kvstore.StateDeltaCFName
+	indexesCF          = kvstore.IndexesCFName
+	persistCF          = kvstore.PersistCFName
+	stagingCF          = kvstore.StagingCFName
+	walCF              = kvstore.WalCFName
+	secondaryIndexesCF = kvstore.SecondaryIndexesCFName
+	privateCF          = kvstore.PrivateCFName
+	consensusCF        = kvstore.ConsensusCFName
+	documentsCF        = kvstore.DocumentsCFName
+)
 
-var columnfamilies = []string{
-	blockchainCF, // blocks of the block chain
-	stateCF,      // world state
-	stateDeltaCF, // open transaction state
-	indexesCF,    // tx uuid -> blockno
-	persistCF,    // persistent per-peer state (consensus)
-}
+var columnfamilies = kvstore.ColumnFamilies()
 
 // OpenchainDB encapsulates rocksdb's structures
 type OpenchainDB struct {
-	DB           *gorocksdb.DB
-	BlockchainCF *gorocksdb.ColumnFamilyHandle
-	StateCF      *gorocksdb.ColumnFamilyHandle
-	StateDeltaCF *gorocksdb.ColumnFamilyHandle
-	IndexesCF    *gorocksdb.ColumnFamilyHandle
-	PersistCF    *gorocksdb.ColumnFamilyHandle
+	DB                 *gorocksdb.DB
+	BlockchainCF       *gorocksdb.ColumnFamilyHandle
+	StateCF            *gorocksdb.ColumnFamilyHandle
+	StateDeltaCF       *gorocksdb.ColumnFamilyHandle
+	IndexesCF          *gorocksdb.ColumnFamilyHandle
+	PersistCF          *gorocksdb.ColumnFamilyHandle
+	StagingCF          *gorocksdb.ColumnFamilyHandle
+	WalCF              *gorocksdb.ColumnFamilyHandle
+	SecondaryIndexesCF *gorocksdb.ColumnFamilyHandle
+	PrivateCF          *gorocksdb.ColumnFamilyHandle
+	ConsensusCF        *gorocksdb.ColumnFamilyHandle
+	DocumentsCF        *gorocksdb.ColumnFamilyHandle
+	// independent is true for the handles opened by OpenDB, which do not affect the handle
+	// returned by GetDBHandle
+	independent bool
+	// cipher encrypts the values of the encrypted column families, nil if encryption is disabled
+	cipher *valueCipher
+	// checksums is true if the values of the checksummed column families carry a checksum
+	checksums bool
+	dbPath    string
+	// quota is nil if no disk quota is configured
+	quota *diskQuota
+	// generation is incremented each time column families are recreated, see KVStore.Generation
+	generation uint64
 }
 
 var openchainDB *OpenchainDB
 var isOpen bool
 
+// chainDBs are the DBs opened by OpenChainDB and not closed yet, by chain ID
+var chainDBs = make(map[string]*OpenchainDB)
+var chainDBsLock sync.Mutex
+
 // CreateDB creates a rocks db database
 func CreateDB() error {
 	dbPath := getDBPath()
@@ -155,6 +183,12 @@ func (openchainDB *OpenchainDB) GetStateDeltaCFIterator() *gorocksdb.Iterator {
 	return openchainDB.GetIterator(openchainDB.StateDeltaCF)
 }
 
+// GetStateDeltaCFSnapshotIterator get iterator for column family - stateDeltaCF based
+// on a snapshot. Remember to call iterator.Close() when you are done.
+func (openchainDB *OpenchainDB) GetStateDeltaCFSnapshotIterator(snapshot *gorocksdb.Snapshot) *gorocksdb.Iterator {
+	return openchainDB.getSnapshotIterator(snapshot, openchainDB.StateDeltaCF)
+}
+
 // GetSnapshot returns a point-in-time view of the DB. You MUST call snapshot.Release()
 // when you are done with the snapshot.
 func (openchainDB *OpenchainDB) GetSnapshot() *gorocksdb.Snapshot {
@@ -162,6 +196,15 @@ func (openchainDB *OpenchainDB) GetSnapshot() *gorocksdb.Snapshot {
 }
 
 func getDBPath() string {
+	return getFileSystemPath() + "db"
+}
+
+// getChainDBPath returns the path of the DB of a chain other than the default one
+func getChainDBPath(chainID string) string {
+	return getFileSystemPath() + "chains/" + chainID + "/db"
+}
+
+func getFileSystemPath() string {
 	dbPath := viper.GetString("peer.fileSystemPath")
 	if dbPath == "" {
 		panic("DB path not specified in configuration file. Please check that property 'peer.fileSystemPath' is set")
@@ -169,7 +212,7 @@ func getDBPath() string {
 	if !strings.HasSuffix(dbPath, "/") {
 		dbPath = dbPath + "/"
 	}
-	return dbPath + "db"
+	return dbPath
 }
 
 func createDBIfDBPathEmpty() error {
@@ -188,23 +231,83 @@ func createDBIfDBPathEmpty() error {
 	return nil
 }
 
+// OpenDB opens the DB at dbPath, creating it if the path is missing or empty. Unlike the
+// handle returned by GetDBHandle, the returned handle is not shared. Hence, several DBs can
+// be opened in the same process (e.g. one per ledger). The caller has to close the handle
+// with CloseDB.
+func OpenDB(dbPath string) (*OpenchainDB, error) {
+	missing, err := dirMissingOrEmpty(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	if missing {
+		dbLogger.Debug("Creating DB at [%s]", dbPath)
+		if err := os.MkdirAll(dbPath, 0755); err != nil {
+			return nil, fmt.Errorf("Error making directory path [%s]: %s", dbPath, err)
+		}
+	}
+	openchainDB, err := openDBAtPath(dbPath, true)
+	if err != nil {
+		return nil, err
+	}
+	openchainDB.independent = true
+	return openchainDB, nil
+}
+
+// OpenChainDB opens the DB of the chain with the given ID, which is kept under
+// 'peer.fileSystemPath'/chains, apart from the DB returned by GetDBHandle. The caller
+// has to close the handle with CloseDB.
+func OpenChainDB(chainID string) (*OpenchainDB, error) {
+	chainDBsLock.Lock()
+	defer chainDBsLock.Unlock()
+	if _, ok := chainDBs[chainID]; ok {
+		return nil, fmt.Errorf("The DB of chain [%s] is already open", chainID)
+	}
+	chainDB, err := OpenDB(getChainDBPath(chainID))
+	if err != nil {
+		return nil, err
+	}
+	chainDBs[chainID] = chainDB
+	return chainDB, nil
+}
+
 func openDB() (*OpenchainDB, error) {
 	if isOpen {
 		return openchainDB, nil
 	}
+	openchainDB, err := openDBAtPath(getDBPath(), false)
+	if err != nil {
+		return nil, err
+	}
+	isOpen = true
+	return openchainDB, nil
+}
 
-	dbPath := getDBPath()
+func openDBAtPath(dbPath string, createIfMissing bool) (*OpenchainDB, error) {
 	opts := gorocksdb.NewDefaultOptions()
 	defer opts.Destroy()
 
-	opts.SetCreateIfMissing(false)
+	opts.SetCreateIfMissing(createIfMissing)
 	opts.SetCreateIfMissingColumnFamilies(true)
+	if maxOpenFiles := viper.GetInt("peer.db.maxOpenFiles"); maxOpenFiles != 0 {
+		opts.SetMaxOpenFiles(maxOpenFiles)
+	}
 
 	cfNames := []string{"default"}
 	cfNames = append(cfNames, columnfamilies...)
-	var cfOpts []*gorocksdb.Options
-	for range cfNames {
-		cfOpts = append(cfOpts, opts)
+	cfOpts := []*gorocksdb.Options{opts}
+	for _, cfName := range columnfamilies {
+		cfOpt, err := newCFOptions(cfName)
+		if err != nil {
+			return nil, err
+		}
+		defer cfOpt.Destroy()
+		cfOpts = append(cfOpts, cfOpt)
+	}
+
+	valueCipher, err := newValueCipherFromConfig()
+	if err != nil {
+		return nil, err
 	}
 
 	db, cfHandlers, err := gorocksdb.OpenDbColumnFamilies(opts, dbPath, cfNames, cfOpts)
@@ -213,48 +316,78 @@ func openDB() (*OpenchainDB, error) {
 		fmt.Println("Error opening DB", err)
 		return nil, err
 	}
-	isOpen = true
 	// XXX should we close cfHandlers[0]?
-	return &OpenchainDB{db, cfHandlers[1], cfHandlers[2], cfHandlers[3], cfHandlers[4], cfHandlers[5]}, nil
+	openchainDB := &OpenchainDB{db, cfHandlers[1], cfHandlers[2], cfHandlers[3], cfHandlers[4], cfHandlers[5], cfHandlers[6], cfHandlers[7], cfHandlers[8], cfHandlers[9], cfHandlers[10], cfHandlers[11], false, valueCipher, false, dbPath, newDiskQuotaFromConfig(), 0}
+	if err := openchainDB.checkEncryption(); err != nil {
+		openchainDB.CloseDB()
+		return nil, err
+	}
+	if err := openchainDB.checkChecksums(); err != nil {
+		openchainDB.CloseDB()
+		return nil, err
+	}
+	if err := openchainDB.migrateConsensusState(); err != nil {
+		openchainDB.CloseDB()
+		return nil, err
+	}
+	return openchainDB, nil
 }
 
 // CloseDB releases all column family handles and closes rocksdb
 func (openchainDB *OpenchainDB) CloseDB() {
+	if openchainDB.independent {
+		chainDBsLock.Lock()
+		defer chainDBsLock.Unlock()
+		for chainID, chainDB := range chainDBs {
+			if chainDB == openchainDB {
+				delete(chainDBs, chainID)
+			}
+		}
+	}
+	openchainDB.close()
+	if !openchainDB.independent {
+		isOpen = false
+	}
+}
+
+func (openchainDB *OpenchainDB) close() {
 	openchainDB.BlockchainCF.Destroy()
 	openchainDB.StateCF.Destroy()
 	openchainDB.StateDeltaCF.Destroy()
 	openchainDB.IndexesCF.Destroy()
 	openchainDB.PersistCF.Destroy()
+	openchainDB.StagingCF.Destroy()
+	openchainDB.WalCF.Destroy()
+	openchainDB.SecondaryIndexesCF.Destroy()
+	openchainDB.PrivateCF.Destroy()
+	openchainDB.ConsensusCF.Destroy()
+	openchainDB.DocumentsCF.Destroy()
 	openchainDB.DB.Close()
-	isOpen = false
 }
 
 // DeleteState delets ALL state keys/values from the DB. This is generally
 // only used during state synchronization when creating a new state from
-// a snapshot.
+// a snapshot. The JSON documents of the state are deleted along with it.
 func (openchainDB *OpenchainDB) DeleteState() error {
-	err := openchainDB.DB.DropColumnFamily(openchainDB.StateCF)
-	if err != nil {
-		dbLogger.Error("Error dropping state CF", err)
-		return err
-	}
-	err = openchainDB.DB.DropColumnFamily(openchainDB.StateDeltaCF)
+	return openchainDB.KVStore().Clear(stateCF, stateDeltaCF, documentsCF)
+}
+
+// recreateColumnFamily drops the column family and creates it again, empty
+func (openchainDB *OpenchainDB) recreateColumnFamily(cfName string, cfHandler **gorocksdb.ColumnFamilyHandle) error {
+	opts, err := newCFOptions(cfName)
 	if err != nil {
-		dbLogger.Error("Error dropping state delta CF", err)
 		return err
 	}
-	opts := gorocksdb.NewDefaultOptions()
 	defer opts.Destroy()
-	openchainDB.StateCF, err = openchainDB.DB.CreateColumnFamily(opts, stateCF)
-	if err != nil {
-		dbLogger.Error("Error creating state CF", err)
+	if err := openchainDB.DB.DropColumnFamily(*cfHandler); err != nil {
+		dbLogger.Error("Error dropping %s: %s", cfName, err)
 		return err
 	}
-	openchainDB.StateDeltaCF, err = openchainDB.DB.CreateColumnFamily(opts, stateDeltaCF)
-	if err != nil {
-		dbLogger.Error("Error creating state delta CF", err)
+	if *cfHandler, err = openchainDB.DB.CreateColumnFamily(opts, cfName); err != nil {
+		dbLogger.Error("Error creating %s: %s", cfName, err)
 		return err
 	}
+	atomic.AddUint64(&openchainDB.generation, 1)
 	return nil
 }
 
@@ -265,24 +398,24 @@ func (openchainDB *OpenchainDB) Get(cfHandler *gorocksdb.ColumnFamilyHandle, key
 	slice, err := openchainDB.DB.GetCF(opt, cfHandler, key)
 	if err != nil {
 		fmt.Println("Error while trying to retrieve key:", key)
-		return nil, err
+		return nil, errors.Errorf(errors.DBUnavailable, "Error while retrieving key [%x]: %s", key, err)
 	}
 	defer slice.Free()
 	if slice.Data() == nil {
 		return nil, nil
 	}
 	data := makeCopy(slice.Data())
-	return data, nil
+	return openchainDB.decodeValue(openchainDB.getCFName(cfHandler), key, data)
 }
 
 // Put saves the key/value in the given column family
 func (openchainDB *OpenchainDB) Put(cfHandler *gorocksdb.ColumnFamilyHandle, key []byte, value []byte) error {
 	opt := gorocksdb.NewDefaultWriteOptions()
 	defer opt.Destroy()
-	err := openchainDB.DB.PutCF(opt, cfHandler, key, value)
+	err := openchainDB.DB.PutCF(opt, cfHandler, key, openchainDB.encodeValue(openchainDB.getCFName(cfHandler), key, value))
 	if err != nil {
 		fmt.Println("Error while trying to write key:", key)
-		return err
+		return errors.Errorf(errors.DBUnavailable, "Error while writing key [%x]: %s", key, err)
 	}
 	return nil
 }
@@ -294,7 +427,7 @@ func (openchainDB *OpenchainDB) Delete(cfHandler *gorocksdb.ColumnFamilyHandle,
 	err := openchainDB.DB.DeleteCF(opt, cfHandler, key)
 	if err != nil {
 		fmt.Println("Error while trying to delete key:", key)
-		return err
+		return errors.Errorf(errors.DBUnavailable, "Error while deleting key [%x]: %s", key, err)
 	}
 	return nil
 }
@@ -306,14 +439,16 @@ func (openchainDB *OpenchainDB) getFromSnapshot(snapshot *gorocksdb.Snapshot, cf
 	slice, err := openchainDB.DB.GetCF(opt, cfHandler, key)
 	if err != nil {
 		fmt.Println("Error while trying to retrieve key:", key)
-		return nil, err
+		return nil, errors.Errorf(errors.DBUnavailable, "Error while retrieving key [%x]: %s", key, err)
 	}
 	defer slice.Free()
 	data := append([]byte(nil), slice.Data()...)
-	return data, nil
+	return openchainDB.decodeValue(openchainDB.getCFName(cfHandler), key, data)
 }
 
-// GetIterator returns an iterator for the given column family
+// GetIterator returns an iterator for the given column family. The iterator returns the values
+// as stored, i.e., encrypted if the column family is encrypted (see 'peer.db.encryption').
+// Iterators of KVStore return the decrypted values.
 func (openchainDB *OpenchainDB) GetIterator(cfHandler *gorocksdb.ColumnFamilyHandle) *gorocksdb.Iterator {
 	opt := gorocksdb.NewDefaultReadOptions()
 	opt.SetFillCache(true)
@@ -378,3 +513,31 @@ func makeCopy(src []byte) []byte {
 	copy(dest, src)
 	return dest
 }
+
+// legacyConsensusKeyPrefix prefixes the keys under which the consensus plugin kept its state in
+// the persistCF, before it had the consensusCF
+const legacyConsensusKeyPrefix = "consensus."
+
+// migrateConsensusState moves the state the consensus plugin kept in the persistCF to the
+// consensusCF
+func (openchainDB *OpenchainDB) migrateConsensusState() error {
+	prefix := []byte(legacyConsensusKeyPrefix)
+	writeBatch := gorocksdb.NewWriteBatch()
+	defer writeBatch.Destroy()
+	moved := 0
+	itr := openchainDB.GetIterator(openchainDB.PersistCF)
+	for itr.Seek(prefix); itr.ValidForPrefix(prefix); itr.Next() {
+		key := makeCopy(itr.Key().Data())
+		writeBatch.PutCF(openchainDB.ConsensusCF, key[len(prefix):], makeCopy(itr.Value().Data()))
+		writeBatch.DeleteCF(openchainDB.PersistCF, key)
+		moved++
+	}
+	itr.Close()
+	if moved == 0 {
+		return nil
+	}
+	dbLogger.Info("Moving %d keys of the consensus state to the %s column family", moved, consensusCF)
+	opt := gorocksdb.NewDefaultWriteOptions()
+	defer opt.Destroy()
+	return openchainDB.DB.Write(opt, writeBatch)
+}
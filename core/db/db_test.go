@@ -17,11 +17,19 @@ limitations under the License.
 package db
 
 import (
+	"bufio"
 	"bytes"
+	"fmt"
 	"io/ioutil"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
 	"testing"
+	"time"
 
+	"github.com/hyperledger/fabric/core/db/kvstore"
 	"github.com/spf13/viper"
 	"github.com/tecbot/gorocksdb"
 )
@@ -54,6 +62,238 @@ func TestWriteAndRead(t *testing.T) {
 	performBasicReadWrite(t)
 }
 
+func TestSpaceReport(t *testing.T) {
+	createTestDB()
+	defer deleteTestDB()
+	performBasicReadWrite(t)
+	openchainDB := GetDBHandle()
+
+	report, err := openchainDB.GetSpaceReport()
+	if err != nil {
+		t.Fatalf("Error while computing the space report: %s", err)
+	}
+	if len(report.ColumnFamilies) != len(columnfamilies) {
+		t.Fatalf("Expected [%d] column families in the space report, found [%d]", len(columnfamilies), len(report.ColumnFamilies))
+	}
+	for i, cfSpace := range report.ColumnFamilies {
+		if cfSpace.Name != columnfamilies[i] {
+			t.Fatalf("Expected column family [%s], found [%s]", columnfamilies[i], cfSpace.Name)
+		}
+		if cfSpace.LastCompaction != nil {
+			t.Fatalf("Column family [%s] reported as compacted", cfSpace.Name)
+		}
+		if cfSpace.DeadBytes > cfSpace.TotalBytes {
+			t.Fatalf("Dead bytes [%d] exceed total bytes [%d] for column family [%s]", cfSpace.DeadBytes, cfSpace.TotalBytes, cfSpace.Name)
+		}
+	}
+	if report.DiskBytes == 0 {
+		t.Fatalf("Expected the DB directory to be non-empty")
+	}
+
+	if err := openchainDB.CompactColumnFamily(stateCF); err != nil {
+		t.Fatalf("Error while compacting: %s", err)
+	}
+	if err := openchainDB.CompactColumnFamily("unknownCF"); err == nil {
+		t.Fatalf("Expected an error while compacting an unknown column family")
+	}
+	report, err = openchainDB.GetSpaceReport()
+	if err != nil {
+		t.Fatalf("Error while computing the space report: %s", err)
+	}
+	for _, cfSpace := range report.ColumnFamilies {
+		if compacted := cfSpace.LastCompaction != nil; compacted != (cfSpace.Name == stateCF) {
+			t.Fatalf("Unexpected last compaction [%v] for column family [%s]", cfSpace.LastCompaction, cfSpace.Name)
+		}
+	}
+}
+
+func TestCompactColumnFamilies(t *testing.T) {
+	createTestDB()
+	defer deleteTestDB()
+	openchainDB := GetDBHandle()
+
+	allStats, err := openchainDB.CompactColumnFamilies()
+	if err != nil {
+		t.Fatalf("Error while compacting: %s", err)
+	}
+	if len(allStats) != len(DefaultCompactionColumnFamilies) {
+		t.Fatalf("Expected stats for %v, found %d", DefaultCompactionColumnFamilies, len(allStats))
+	}
+	for i, stats := range allStats {
+		if stats.Name != DefaultCompactionColumnFamilies[i] {
+			t.Fatalf("Expected column family [%s], found [%s]", DefaultCompactionColumnFamilies[i], stats.Name)
+		}
+	}
+	if _, err := openchainDB.CompactColumnFamilies(stateCF, "unknownCF"); err == nil {
+		t.Fatalf("Expected an error while compacting an unknown column family")
+	}
+
+	scheduler := NewCompactionScheduler(openchainDB, 10*time.Millisecond, indexesCF)
+	scheduler.Start()
+	defer scheduler.Stop()
+	for i := 0; i < 100; i++ {
+		if lastCompaction, _ := openchainDB.getLastCompaction(indexesCF); lastCompaction != nil {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("Column family [%s] not compacted by the scheduler", indexesCF)
+}
+
+func TestEncryption(t *testing.T) {
+	deleteTestDBPath()
+	createTestDBPath()
+	defer deleteTestDBPath()
+	os.Setenv("TEST_DB_ENCRYPTION_KEY", "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f")
+	defer os.Unsetenv("TEST_DB_ENCRYPTION_KEY")
+	viper.Set("peer.db.encryption.keyEnv", "TEST_DB_ENCRYPTION_KEY")
+	viper.Set("peer.db.encryption.enabled", true)
+	defer func() {
+		viper.Set("peer.db.encryption.keyEnv", nil)
+		viper.Set("peer.db.encryption.enabled", nil)
+	}()
+
+	openchainDB := GetDBHandle()
+	store := openchainDB.KVStore()
+	writeBatch := store.NewWriteBatch()
+	writeBatch.Put(kvstore.StateCFName, []byte("key1"), []byte("value1"))
+	writeBatch.Put(kvstore.IndexesCFName, []byte("key2"), []byte("value2"))
+	if err := store.Write(writeBatch, false); err != nil {
+		t.Fatalf("Error writing to db: %s", err)
+	}
+	writeBatch.Destroy()
+
+	itr := openchainDB.GetStateCFIterator()
+	itr.SeekToFirst()
+	if !itr.Valid() || bytes.Equal(itr.Value().Data(), []byte("value1")) {
+		t.Fatalf("Expected the value to be stored encrypted")
+	}
+	itr.Close()
+	if value, _ := openchainDB.GetFromIndexesCF([]byte("key2")); !bytes.Equal(value, []byte("value2")) {
+		t.Fatalf("Expected the value in indexesCF to be stored in plaintext, found [%x]", value)
+	}
+	if value, _ := openchainDB.GetFromStateCF([]byte("key1")); !bytes.Equal(value, []byte("value1")) {
+		t.Fatalf("Expected the decrypted value, found [%x]", value)
+	}
+	kvItr := store.NewIterator(kvstore.StateCFName)
+	kvItr.SeekToFirst()
+	if !kvItr.Valid() || !bytes.Equal(kvItr.Value(), []byte("value1")) || kvItr.Err() != nil {
+		t.Fatalf("Expected the iterator to return the decrypted value")
+	}
+	kvItr.Close()
+	external := openchainDB.EncryptExternalValue("archive", []byte("key3"), []byte("value3"))
+	if bytes.Equal(external, []byte("value3")) {
+		t.Fatalf("Expected the external value to be encrypted")
+	}
+	if value, err := openchainDB.DecryptExternalValue("archive", []byte("key3"), external); err != nil || !bytes.Equal(value, []byte("value3")) {
+		t.Fatalf("Expected the decrypted external value, found [%x] %v", value, err)
+	}
+	if _, err := openchainDB.DecryptExternalValue("archive", []byte("key4"), external); err == nil {
+		t.Fatalf("Expected an error decrypting an external value stored under another key")
+	}
+	openchainDB.CloseDB()
+
+	os.Setenv("TEST_DB_ENCRYPTION_KEY", "ff0102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f")
+	if _, err := openDB(); err == nil {
+		t.Fatalf("Expected an error while opening the DB with another key")
+	}
+	viper.Set("peer.db.encryption.enabled", false)
+	if _, err := openDB(); err == nil {
+		t.Fatalf("Expected an error while opening an encrypted DB with encryption disabled")
+	}
+
+	deleteTestDBPath()
+	createTestDBPath()
+	performBasicReadWrite(t)
+	GetDBHandle().CloseDB()
+	viper.Set("peer.db.encryption.enabled", true)
+	if _, err := openDB(); err == nil {
+		t.Fatalf("Expected an error while enabling encryption on a DB with unencrypted data")
+	}
+}
+
+func TestChecksumsAndScrub(t *testing.T) {
+	deleteTestDBPath()
+	createTestDBPath()
+	defer deleteTestDB()
+	viper.Set("peer.db.checksums", true)
+	defer viper.Set("peer.db.checksums", nil)
+
+	openchainDB := GetDBHandle()
+	if !openchainDB.checksums {
+		t.Fatalf("Expected checksums to be enabled for a new DB")
+	}
+	store := openchainDB.KVStore()
+	writeBatch := store.NewWriteBatch()
+	writeBatch.Put(kvstore.StateCFName, []byte("key1"), []byte("value1"))
+	writeBatch.Put(kvstore.StateDeltaCFName, []byte("key2"), []byte("value2"))
+	if err := store.Write(writeBatch, false); err != nil {
+		t.Fatalf("Error writing to db: %s", err)
+	}
+	writeBatch.Destroy()
+	if value, err := store.Get(kvstore.StateCFName, []byte("key1")); err != nil || !bytes.Equal(value, []byte("value1")) {
+		t.Fatalf("Expected [value1], found [%s], err = %v", value, err)
+	}
+	report, err := openchainDB.Scrub()
+	if err != nil {
+		t.Fatalf("Error scrubbing: %s", err)
+	}
+	if len(report.Corrupt) != 0 || report.NumRecords[stateCF] != 1 || report.NumRecords[stateDeltaCF] != 1 {
+		t.Fatalf("Unexpected scrub report %+v", report)
+	}
+
+	// flip a bit of the stored value, bypassing the checksum
+	itr := openchainDB.GetStateCFIterator()
+	itr.SeekToFirst()
+	corrupted := makeCopy(itr.Value().Data())
+	itr.Close()
+	corrupted[0] ^= 0x01
+	writeOpts := gorocksdb.NewDefaultWriteOptions()
+	defer writeOpts.Destroy()
+	openchainDB.DB.PutCF(writeOpts, openchainDB.StateCF, []byte("key1"), corrupted)
+	if _, err := store.Get(kvstore.StateCFName, []byte("key1")); err == nil {
+		t.Fatalf("Expected a checksum mismatch")
+	}
+	report, err = openchainDB.Scrub()
+	if err != nil {
+		t.Fatalf("Error scrubbing: %s", err)
+	}
+	if len(report.Corrupt) != 1 || report.Corrupt[0].CFName != stateCF || !bytes.Equal(report.Corrupt[0].Key, []byte("key1")) {
+		t.Fatalf("Expected key1 of stateCF to be reported as corrupt, found %v", report.Corrupt)
+	}
+}
+
+func TestConsensusStateMigration(t *testing.T) {
+	deleteTestDBPath()
+	createTestDBPath()
+	defer deleteTestDBPath()
+	if err := CreateDB(); err != nil {
+		t.Fatalf("Error creating DB: %s", err)
+	}
+	openchainDB, err := openDB()
+	if err != nil {
+		t.Fatalf("Error opening DB: %s", err)
+	}
+	openchainDB.Put(openchainDB.PersistCF, []byte(legacyConsensusKeyPrefix+"view"), []byte("value"))
+	openchainDB.Put(openchainDB.PersistCF, []byte("other"), []byte("other"))
+	openchainDB.CloseDB()
+
+	openchainDB, err = openDB()
+	if err != nil {
+		t.Fatalf("Error re-opening DB: %s", err)
+	}
+	defer openchainDB.CloseDB()
+	if value, err := openchainDB.Get(openchainDB.ConsensusCF, []byte("view")); err != nil || !bytes.Equal(value, []byte("value")) {
+		t.Fatalf("Expected [value] in the consensusCF, found [%s], err = %v", value, err)
+	}
+	if value, _ := openchainDB.Get(openchainDB.PersistCF, []byte(legacyConsensusKeyPrefix+"view")); value != nil {
+		t.Fatalf("Expected the consensus state to be removed from the persistCF, found [%s]", value)
+	}
+	if value, _ := openchainDB.Get(openchainDB.PersistCF, []byte("other")); !bytes.Equal(value, []byte("other")) {
+		t.Fatalf("Expected the other keys of the persistCF to remain, found [%s]", value)
+	}
+}
+
 func TestOpenDB_DirDoesNotExist(t *testing.T) {
 	deleteTestDBPath()
 	defer deleteTestDB()
@@ -94,6 +334,201 @@ func TestDBColumnUpgrade(t *testing.T) {
 	db.CloseDB()
 }
 
+func TestCFTuning(t *testing.T) {
+	viper.Set("peer.db.default.writeBufferSize", 32)
+	viper.Set("peer.db.default.compression", "snappy")
+	viper.Set("peer.db.columnFamilies.stateCF.compression", "ZLib")
+	viper.Set("peer.db.columnFamilies.stateCF.bloomFilterBitsPerKey", 10)
+	defer func() {
+		viper.Set("peer.db.default.writeBufferSize", nil)
+		viper.Set("peer.db.default.compression", nil)
+		viper.Set("peer.db.columnFamilies.stateCF.compression", nil)
+		viper.Set("peer.db.columnFamilies.stateCF.bloomFilterBitsPerKey", nil)
+	}()
+	if tuning := getCFTuning(blockchainCF); tuning != (cfTuning{writeBufferSizeMBs: 32, compression: "snappy"}) {
+		t.Fatalf("Unexpected options for blockchainCF: %+v", tuning)
+	}
+	if tuning := getCFTuning(stateCF); tuning != (cfTuning{writeBufferSizeMBs: 32, compression: "zlib", bloomFilterBitsPerKey: 10}) {
+		t.Fatalf("Unexpected options for stateCF: %+v", tuning)
+	}
+
+	deleteTestDBPath()
+	createTestDBPath()
+	openchainDB := GetDBHandle()
+	openchainDB.CloseDB()
+
+	viper.Set("peer.db.columnFamilies.stateCF.compression", "bogus")
+	if _, err := openDB(); err == nil {
+		t.Fatalf("Expected an error for an unknown compression type")
+	}
+}
+
+func TestBackupRestore(t *testing.T) {
+	deleteTestDBPath()
+	createTestDBPath()
+	defer deleteTestDBPath()
+	backupRootDir, err := ioutil.TempDir("", "fabric-db-backup-test")
+	if err != nil {
+		t.Fatalf("Error creating backup dir: %s", err)
+	}
+	defer os.RemoveAll(backupRootDir)
+	viper.Set("peer.db.backupDir", backupRootDir)
+	defer viper.Set("peer.db.backupDir", nil)
+	backupDir := filepath.Join(backupRootDir, "backup")
+
+	performBasicReadWrite(t)
+	chainDB, err := OpenChainDB("chain1")
+	if err != nil {
+		t.Fatalf("Error opening the DB of the chain: %s", err)
+	}
+	if err := chainDB.Put(chainDB.BlockchainCF, []byte("chainKey"), []byte("chainValue")); err != nil {
+		t.Fatalf("Error writing key: %s", err)
+	}
+	info, err := CreateBackup("backup")
+	if err != nil {
+		t.Fatalf("Error creating backup: %s", err)
+	}
+	latest, err := GetLatestBackupInfo(backupDir)
+	if err != nil {
+		t.Fatalf("Error reading backup info: %s", err)
+	}
+	if latest == nil || *latest != *info {
+		t.Fatalf("Expected latest backup %+v, found %+v", info, latest)
+	}
+	if latest, err := GetLatestBackupInfo(filepath.Join(backupDir, "chains", "chain1")); err != nil || latest == nil {
+		t.Fatalf("Expected a backup of the DB of the chain, found %+v (%v)", latest, err)
+	}
+
+	openchainDB := GetDBHandle()
+	if err := openchainDB.Delete(openchainDB.BlockchainCF, []byte("dummyKey")); err != nil {
+		t.Fatalf("Error deleting key: %s", err)
+	}
+	if err := chainDB.Delete(chainDB.BlockchainCF, []byte("chainKey")); err != nil {
+		t.Fatalf("Error deleting key: %s", err)
+	}
+	openchainDB.CloseDB()
+	if err := RestoreFromBackup(backupDir); err == nil {
+		t.Fatalf("Expected an error while restoring with the DB of a chain open")
+	}
+	chainDB.CloseDB()
+	openchainDB = GetDBHandle()
+	if err := RestoreFromBackup(backupDir); err == nil {
+		t.Fatalf("Expected an error while restoring an open DB")
+	}
+	openchainDB.CloseDB()
+
+	if err := RestoreFromBackup(backupDir); err != nil {
+		t.Fatalf("Error restoring backup: %s", err)
+	}
+	value, err := GetDBHandle().GetFromBlockchainCF([]byte("dummyKey"))
+	if err != nil {
+		t.Fatalf("read error = [%s]", err)
+	}
+	if !bytes.Equal(value, []byte("dummyValue")) {
+		t.Fatalf("Expected the restored DB to contain the key written before the backup")
+	}
+	GetDBHandle().CloseDB()
+	chainDB, err = OpenChainDB("chain1")
+	if err != nil {
+		t.Fatalf("Error opening the DB of the chain: %s", err)
+	}
+	defer chainDB.CloseDB()
+	value, err = chainDB.Get(chainDB.BlockchainCF, []byte("chainKey"))
+	if err != nil {
+		t.Fatalf("read error = [%s]", err)
+	}
+	if !bytes.Equal(value, []byte("chainValue")) {
+		t.Fatalf("Expected the restored DB of the chain to contain the key written before the backup")
+	}
+}
+
+func TestBackupDirOutsideOfBackupRootDir(t *testing.T) {
+	if _, err := CreateBackup("backup"); err == nil {
+		t.Fatalf("Expected an error while 'peer.db.backupDir' is not set")
+	}
+	viper.Set("peer.db.backupDir", "/var/backups/peer")
+	defer viper.Set("peer.db.backupDir", nil)
+	for dir, expected := range map[string]string{
+		"backup":                     "/var/backups/peer/backup",
+		"daily/../backup":            "/var/backups/peer/backup",
+		"/var/backups/peer/backup":   "/var/backups/peer/backup",
+		"/var/backups/peer":          "/var/backups/peer",
+		"../backup":                  "",
+		"/var/backups/peer/../other": "",
+		"/var/backups/peer2":         "",
+		"/tmp":                       "",
+	} {
+		resolved, err := resolveBackupDir(dir)
+		if expected == "" && err == nil {
+			t.Fatalf("Expected an error for backup dir [%s], found [%s]", dir, resolved)
+		}
+		if expected != "" && (err != nil || resolved != expected) {
+			t.Fatalf("Expected backup dir [%s] to resolve to [%s], found [%s] (%v)", dir, expected, resolved, err)
+		}
+	}
+}
+
+func TestRestoreFromBackupDBInUse(t *testing.T) {
+	deleteTestDBPath()
+	createTestDBPath()
+	defer deleteTestDBPath()
+	backupRootDir, err := ioutil.TempDir("", "fabric-db-backup-test")
+	if err != nil {
+		t.Fatalf("Error creating backup dir: %s", err)
+	}
+	defer os.RemoveAll(backupRootDir)
+	viper.Set("peer.db.backupDir", backupRootDir)
+	defer viper.Set("peer.db.backupDir", nil)
+	performBasicReadWrite(t)
+	if _, err := CreateBackup("backup"); err != nil {
+		t.Fatalf("Error creating backup: %s", err)
+	}
+	GetDBHandle().CloseDB()
+
+	// another process holds the lock of the DB, as a running peer would
+	cmd := exec.Command(os.Args[0], "-test.run=TestHelperHoldDBLock")
+	cmd.Env = append(os.Environ(), "FABRIC_TEST_LOCKED_DB_PATH="+getDBPath())
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		t.Fatalf("Error creating pipe: %s", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatalf("Error creating pipe: %s", err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("Error starting the helper process: %s", err)
+	}
+	defer cmd.Wait()
+	defer stdin.Close()
+	if line, err := bufio.NewReader(stdout).ReadString('\n'); err != nil || line != "locked\n" {
+		t.Fatalf("Expected the helper process to lock the DB, read [%s] (%v)", line, err)
+	}
+	if err := RestoreFromBackup(filepath.Join(backupRootDir, "backup")); err == nil || !strings.Contains(err.Error(), "in use by process") {
+		t.Fatalf("Expected an error while restoring a DB in use by another process, found %v", err)
+	}
+}
+
+// TestHelperHoldDBLock is not a test by itself. Run by TestRestoreFromBackupDBInUse, it takes the
+// lock of the DB at FABRIC_TEST_LOCKED_DB_PATH the way RocksDB does and holds it until its stdin is closed
+func TestHelperHoldDBLock(t *testing.T) {
+	dbPath := os.Getenv("FABRIC_TEST_LOCKED_DB_PATH")
+	if dbPath == "" {
+		return
+	}
+	lockFile, err := os.OpenFile(filepath.Join(dbPath, "LOCK"), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatalf("Error opening the LOCK file: %s", err)
+	}
+	defer lockFile.Close()
+	lock := syscall.Flock_t{Type: syscall.F_WRLCK}
+	if err := syscall.FcntlFlock(lockFile.Fd(), syscall.F_SETLK, &lock); err != nil {
+		t.Fatalf("Error locking the LOCK file: %s", err)
+	}
+	fmt.Println("locked")
+	ioutil.ReadAll(os.Stdin)
+}
+
 // db helper functions
 func createTestDBPath() {
 	dbPath := viper.GetString("peer.fileSystemPath")
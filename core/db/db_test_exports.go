@@ -20,6 +20,7 @@ import (
 	"os"
 	"testing"
 
+	"github.com/hyperledger/fabric/core/db/kvstore"
 	"github.com/spf13/viper"
 	"github.com/tecbot/gorocksdb"
 )
@@ -65,10 +66,8 @@ func (testDB *TestDBWrapper) removeDBPath() {
 }
 
 // WriteToDB tests can use this method for persisting a given batch to db
-func (testDB *TestDBWrapper) WriteToDB(t testing.TB, writeBatch *gorocksdb.WriteBatch) {
-	opt := gorocksdb.NewDefaultWriteOptions()
-	defer opt.Destroy()
-	err := GetDBHandle().DB.Write(opt, writeBatch)
+func (testDB *TestDBWrapper) WriteToDB(t testing.TB, writeBatch kvstore.WriteBatch) {
+	err := GetDBHandle().KVStore().Write(writeBatch, false)
 	if err != nil {
 		t.Fatalf("Error while writing to db. Error:%s", err)
 	}
@@ -0,0 +1,201 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package db
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// encryptedColumnFamilies hold chaincode data, hence, their values are encrypted when
+// 'peer.db.encryption.enabled' is true. The keys are not encrypted, as the ledger relies on
+// their order.
+var encryptedColumnFamilies = map[string]bool{
+	blockchainCF: true,
+	stateCF:      true,
+	stateDeltaCF: true,
+	stagingCF:    true,
+	walCF:        true,
+	documentsCF:  true,
+}
+
+// encryptionCheckKey is the key in persistCF of a value encrypted with the key of the DB,
+// which tells whether the DB is encrypted and whether the configured key is the right one
+var encryptionCheckKey = []byte("db.encryptionCheck")
+var encryptionCheckValue = []byte("openchain")
+
+// valueCipher encrypts the values of the encrypted column families with AES-GCM. The column
+// family and the key are authenticated along with the value so that a value cannot be moved
+// to another key unnoticed.
+type valueCipher struct {
+	aead cipher.AEAD
+}
+
+func newValueCipherFromConfig() (*valueCipher, error) {
+	if !viper.GetBool("peer.db.encryption.enabled") {
+		return nil, nil
+	}
+	encodedKey, err := readEncryptionKey()
+	if err != nil {
+		return nil, err
+	}
+	key, err := hex.DecodeString(strings.TrimSpace(encodedKey))
+	if err != nil {
+		return nil, fmt.Errorf("The DB encryption key is not hex encoded: %s", err)
+	}
+	return newValueCipher(key)
+}
+
+// readEncryptionKey reads the key from 'peer.db.encryption.keyFile' or, if no file is
+// configured, from the environment variable named by 'peer.db.encryption.keyEnv'
+func readEncryptionKey() (string, error) {
+	if keyFile := viper.GetString("peer.db.encryption.keyFile"); keyFile != "" {
+		keyBytes, err := ioutil.ReadFile(keyFile)
+		if err != nil {
+			return "", fmt.Errorf("Error reading the DB encryption key: %s", err)
+		}
+		return string(keyBytes), nil
+	}
+	keyEnv := viper.GetString("peer.db.encryption.keyEnv")
+	if keyEnv == "" {
+		return "", fmt.Errorf("DB encryption is enabled but neither 'peer.db.encryption.keyFile' nor 'peer.db.encryption.keyEnv' is set")
+	}
+	key := os.Getenv(keyEnv)
+	if key == "" {
+		return "", fmt.Errorf("DB encryption is enabled but the environment variable [%s] is not set", keyEnv)
+	}
+	return key, nil
+}
+
+func newValueCipher(key []byte) (*valueCipher, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid DB encryption key: %s", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &valueCipher{aead}, nil
+}
+
+// encrypt returns the nonce followed by the sealed value
+func (c *valueCipher) encrypt(cfName string, key []byte, value []byte) []byte {
+	nonce := make([]byte, c.aead.NonceSize(), c.aead.NonceSize()+len(value)+c.aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		panic(fmt.Errorf("Error generating a nonce: %s", err))
+	}
+	return c.aead.Seal(nonce, nonce, value, additionalData(cfName, key))
+}
+
+func (c *valueCipher) decrypt(cfName string, key []byte, encrypted []byte) ([]byte, error) {
+	nonceSize := c.aead.NonceSize()
+	if len(encrypted) < nonceSize {
+		return nil, &CorruptRecordError{cfName, key, "encrypted value shorter than the nonce"}
+	}
+	value, err := c.aead.Open(nil, encrypted[:nonceSize], encrypted[nonceSize:], additionalData(cfName, key))
+	if err != nil {
+		return nil, &CorruptRecordError{cfName, key, fmt.Sprintf("decryption failed: %s", err)}
+	}
+	if value == nil {
+		value = []byte{}
+	}
+	return value, nil
+}
+
+func additionalData(cfName string, key []byte) []byte {
+	data := make([]byte, 0, len(cfName)+1+len(key))
+	data = append(data, cfName...)
+	data = append(data, 0)
+	return append(data, key...)
+}
+
+// encryptValue returns the value to be stored for the key in the column family
+func (openchainDB *OpenchainDB) encryptValue(cfName string, key []byte, value []byte) []byte {
+	if openchainDB.cipher == nil || !encryptedColumnFamilies[cfName] {
+		return value
+	}
+	return openchainDB.cipher.encrypt(cfName, key, value)
+}
+
+// decryptValue returns the value of the key in the column family from the stored value
+func (openchainDB *OpenchainDB) decryptValue(cfName string, key []byte, stored []byte) ([]byte, error) {
+	if stored == nil || openchainDB.cipher == nil || !encryptedColumnFamilies[cfName] {
+		return stored, nil
+	}
+	return openchainDB.cipher.decrypt(cfName, key, stored)
+}
+
+// EncryptExternalValue returns the value, e.g. an archived block, to be stored under the name
+// and the key outside of the DB, encrypted with the key of the DB if encryption is enabled. The
+// name and the key are authenticated along with the value, as for the column families.
+func (openchainDB *OpenchainDB) EncryptExternalValue(name string, key []byte, value []byte) []byte {
+	if openchainDB.cipher == nil {
+		return value
+	}
+	return openchainDB.cipher.encrypt(name, key, value)
+}
+
+// DecryptExternalValue returns the value from the one stored outside of the DB by EncryptExternalValue
+func (openchainDB *OpenchainDB) DecryptExternalValue(name string, key []byte, stored []byte) ([]byte, error) {
+	if openchainDB.cipher == nil {
+		return stored, nil
+	}
+	return openchainDB.cipher.decrypt(name, key, stored)
+}
+
+// checkEncryption verifies that the DB is encrypted with the configured key, or not encrypted
+// if encryption is disabled. Encryption can only be enabled on a DB with no data in the
+// encrypted column families, as the values already stored would not be readable.
+func (openchainDB *OpenchainDB) checkEncryption() error {
+	check, err := openchainDB.Get(openchainDB.PersistCF, encryptionCheckKey)
+	if err != nil {
+		return err
+	}
+	if openchainDB.cipher == nil {
+		if check != nil {
+			return fmt.Errorf("The DB is encrypted. Set 'peer.db.encryption.enabled' and the encryption key")
+		}
+		return nil
+	}
+	if check != nil {
+		if _, err := openchainDB.cipher.decrypt(persistCF, encryptionCheckKey, check); err != nil {
+			return fmt.Errorf("The configured key is not the encryption key of the DB")
+		}
+		return nil
+	}
+	for cfName := range encryptedColumnFamilies {
+		itr := openchainDB.GetIterator(openchainDB.getCFHandler(cfName))
+		itr.SeekToFirst()
+		hasData := itr.Valid()
+		itr.Close()
+		if hasData {
+			return fmt.Errorf("Encryption cannot be enabled on a DB that already holds unencrypted data in column family [%s]", cfName)
+		}
+	}
+	dbLogger.Info("Enabling encryption of the DB")
+	return openchainDB.Put(openchainDB.PersistCF, encryptionCheckKey, openchainDB.cipher.encrypt(persistCF, encryptionCheckKey, encryptionCheckValue))
+}
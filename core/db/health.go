@@ -0,0 +1,53 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package db
+
+import (
+	"strconv"
+
+	"github.com/hyperledger/fabric/core/errors"
+)
+
+// healthCheckKey is read from persistCF to check that the DB serves the reads. It need not exist
+var healthCheckKey = []byte("healthCheck")
+
+// CheckHealth returns an error if the DB is closed, fails to serve a read, has hit a
+// background error (e.g. a failed flush or compaction, after which rocksdb turns read
+// only) or has stopped the writes until its compactions catch up
+func (openchainDB *OpenchainDB) CheckHealth() error {
+	if openchainDB == nil || openchainDB.DB == nil || (!openchainDB.independent && !isOpen) {
+		return errors.ErrDBUnavailable
+	}
+	if _, err := openchainDB.Get(openchainDB.PersistCF, healthCheckKey); err != nil {
+		return err
+	}
+	if backgroundErrors := openchainDB.getUint64Property("rocksdb.background-errors"); backgroundErrors > 0 {
+		return errors.Errorf(errors.DBUnavailable, "The DB has hit %d background errors", backgroundErrors)
+	}
+	if openchainDB.getUint64Property("rocksdb.is-write-stopped") > 0 {
+		return errors.Errorf(errors.DBUnavailable, "The DB has stopped the writes until its compactions catch up")
+	}
+	return nil
+}
+
+func (openchainDB *OpenchainDB) getUint64Property(propName string) uint64 {
+	value, err := strconv.ParseUint(openchainDB.DB.GetProperty(propName), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return value
+}
@@ -0,0 +1,158 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kvstore defines the interface that the ledger expects from a storage engine, free of
+// any cgo dependency so that the ledger packages can be built and tested without RocksDB. The
+// store of the ledger DB is backed by RocksDB (see db.OpenchainDB.KVStore); this package provides
+// a store held in memory and a store backed by goleveldb.
+package kvstore
+
+import (
+	"github.com/hyperledger/fabric/core/errors"
+)
+
+// Names of the column families of the ledger DB
+const (
+	BlockchainCFName       = "blockchainCF"
+	StateCFName            = "stateCF"
+	StateDeltaCFName       = "stateDeltaCF"
+	IndexesCFName          = "indexesCF"
+	PersistCFName          = "persistCF"
+	StagingCFName          = "stagingCF"
+	WalCFName              = "walCF"
+	SecondaryIndexesCFName = "secondaryIndexesCF"
+	PrivateCFName          = "privateCF"
+	ConsensusCFName        = "consensusCF"
+	DocumentsCFName        = "documentsCF"
+)
+
+// ColumnFamilies returns the names of the column families of the ledger DB
+func ColumnFamilies() []string {
+	return []string{
+		BlockchainCFName,       // blocks of the block chain
+		StateCFName,            // world state
+		StateDeltaCFName,       // open transaction state
+		IndexesCFName,          // tx uuid -> blockno
+		PersistCFName,          // persistent per-peer state (consensus)
+		StagingCFName,          // state changes of the tx-batch in progress, flushed from memory
+		WalCFName,              // write-ahead log of the tx state deltas of the tx-batch in progress
+		SecondaryIndexesCFName, // chaincode id -> blocks, block timestamp -> blocks
+		PrivateCFName,          // private state of the chaincodes, kept out of the hashed world state
+		ConsensusCFName,        // protocol state of the consensus plugin
+		DocumentsCFName,        // JSON documents of the world state, queried by the jsondb state implementation
+	}
+}
+
+// KVStore is the interface that the ledger expects from a storage engine: an ordered key-value
+// store partitioned into column families, with atomic write-batches, point-in-time snapshots and
+// iterators. The column families are identified by name (see the constants above). The values
+// returned are copies that the caller is free to retain.
+//
+// NewMemoryKVStore returns a store held in memory, e.g., for unit tests, and NewLevelDBKVStore a
+// store backed by goleveldb. Other engines, such as BoltDB, can be plugged in by implementing
+// this interface.
+type KVStore interface {
+	// Get returns the value of the key in the column family, nil if the key does not exist
+	Get(cfName string, key []byte) ([]byte, error)
+	// NewIterator returns an iterator over the keys of the column family in increasing order.
+	// Iterator.Close() must be called once you are done
+	NewIterator(cfName string) Iterator
+	// NewWriteBatch returns an empty write-batch to be written with Write.
+	// WriteBatch.Destroy() must be called once you are done
+	NewWriteBatch() WriteBatch
+	// Write applies the changes of the write-batch atomically. If sync is true, the write
+	// is made durable before returning
+	Write(writeBatch WriteBatch, sync bool) error
+	// NewSnapshot returns a point-in-time view of the store. Snapshot.Release() must be
+	// called once you are done
+	NewSnapshot() Snapshot
+	// Clear deletes all the keys of the column families, e.g., to drop the state before
+	// it is rebuilt from a snapshot
+	Clear(cfNames ...string) error
+	// Generation returns a number that changes each time column families are cleared. The
+	// caches of the content of a column family compare it to detect that they are stale
+	Generation() uint64
+}
+
+// Snapshot is a point-in-time view of a KVStore
+type Snapshot interface {
+	Get(cfName string, key []byte) ([]byte, error)
+	NewIterator(cfName string) Iterator
+	Release()
+}
+
+// Reader is the read access shared by KVStore and Snapshot, for the code that reads
+// either the current content of the store or a snapshot of it
+type Reader interface {
+	Get(cfName string, key []byte) ([]byte, error)
+	NewIterator(cfName string) Iterator
+}
+
+// WriteBatch accumulates changes to be applied atomically by KVStore.Write
+type WriteBatch interface {
+	Put(cfName string, key []byte, value []byte)
+	Delete(cfName string, key []byte)
+	Destroy()
+}
+
+// Iterator iterates over the keys of a column family in increasing order
+type Iterator interface {
+	Seek(key []byte)
+	SeekToFirst()
+	Valid() bool
+	Next()
+	Key() []byte
+	Value() []byte
+	// Err returns the error, if any, that made the iterator invalid
+	Err() error
+	Close()
+}
+
+func isColumnFamily(cfName string) bool {
+	for _, name := range ColumnFamilies() {
+		if name == cfName {
+			return true
+		}
+	}
+	return false
+}
+
+// UnknownColumnFamily returns the error of an operation on a column family that does not exist
+func UnknownColumnFamily(cfName string) error {
+	return errors.Errorf(errors.InvalidArgument, "Unknown column family [%s]", cfName)
+}
+
+func makeCopy(src []byte) []byte {
+	dest := make([]byte, len(src))
+	copy(dest, src)
+	return dest
+}
+
+// Put writes a single key-value to the column family of the store
+func Put(store KVStore, cfName string, key []byte, value []byte) error {
+	writeBatch := store.NewWriteBatch()
+	defer writeBatch.Destroy()
+	writeBatch.Put(cfName, key, value)
+	return store.Write(writeBatch, false)
+}
+
+// Delete deletes a single key from the column family of the store
+func Delete(store KVStore, cfName string, key []byte) error {
+	writeBatch := store.NewWriteBatch()
+	defer writeBatch.Destroy()
+	writeBatch.Delete(cfName, key)
+	return store.Write(writeBatch, false)
+}
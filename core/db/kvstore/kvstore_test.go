@@ -0,0 +1,66 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kvstore
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestKVStoreMemory(t *testing.T) {
+	VerifyKVStore(t, NewMemoryKVStore())
+}
+
+func TestKVStoreLevelDB(t *testing.T) {
+	dbPath, err := ioutil.TempDir("", "kvstore")
+	if err != nil {
+		t.Fatalf("Error while creating a temp dir: %s", err)
+	}
+	defer os.RemoveAll(dbPath)
+	store, err := NewLevelDBKVStore(dbPath)
+	if err != nil {
+		t.Fatalf("Error while opening goleveldb: %s", err)
+	}
+	VerifyKVStore(t, store)
+
+	// the data is persisted, and the column families do not overlap
+	writeBatch := store.NewWriteBatch()
+	writeBatch.Put(StateCFName, []byte("key"), []byte("value"))
+	writeBatch.Put(StateDeltaCFName, []byte(""), []byte("deltaValue"))
+	if err := store.Write(writeBatch, true); err != nil {
+		t.Fatalf("Error while writing: %s", err)
+	}
+	writeBatch.Destroy()
+	if err := store.Close(); err != nil {
+		t.Fatalf("Error while closing goleveldb: %s", err)
+	}
+	store, err = NewLevelDBKVStore(dbPath)
+	if err != nil {
+		t.Fatalf("Error while opening goleveldb again: %s", err)
+	}
+	defer store.Close()
+	assertKVStoreValue(t, store, StateCFName, "key", []byte("value"))
+	assertIteratorKeys(t, store.NewIterator(StateCFName), nil, []string{"key"})
+	assertIteratorKeys(t, store.NewIterator(StateDeltaCFName), nil, []string{""})
+
+	writeBatch = store.NewWriteBatch()
+	writeBatch.Put("unknownCF", []byte("key"), []byte("value"))
+	if err := store.Write(writeBatch, false); err == nil {
+		t.Fatalf("Expected an error for an unknown column family")
+	}
+}
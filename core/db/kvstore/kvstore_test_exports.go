@@ -0,0 +1,105 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kvstore
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// VerifyKVStore checks the behaviour of an empty store against the contract of the KVStore
+// interface. Can be used by the implementations of the interface for testing
+func VerifyKVStore(t testing.TB, store KVStore) {
+	writeBatch := store.NewWriteBatch()
+	writeBatch.Put(StateCFName, []byte("key1"), []byte("value1"))
+	writeBatch.Put(StateCFName, []byte("key3"), []byte("value3"))
+	writeBatch.Put(StateCFName, []byte("key2"), []byte("value2"))
+	writeBatch.Put(StateCFName, []byte("key4"), []byte{})
+	writeBatch.Put(IndexesCFName, []byte("key1"), []byte("otherValue1"))
+	if err := store.Write(writeBatch, true); err != nil {
+		t.Fatalf("Error while writing: %s", err)
+	}
+	writeBatch.Destroy()
+	assertKVStoreValue(t, store, StateCFName, "key1", []byte("value1"))
+	assertKVStoreValue(t, store, IndexesCFName, "key1", []byte("otherValue1"))
+	assertKVStoreValue(t, store, StateCFName, "key4", []byte{})
+	assertKVStoreValue(t, store, StateCFName, "missingKey", nil)
+	if _, err := store.Get("unknownCF", []byte("key1")); err == nil {
+		t.Fatalf("Expected an error for an unknown column family")
+	}
+
+	snapshot := store.NewSnapshot()
+	defer snapshot.Release()
+	writeBatch = store.NewWriteBatch()
+	writeBatch.Delete(StateCFName, []byte("key1"))
+	writeBatch.Put(StateCFName, []byte("key2"), []byte("newValue2"))
+	if err := store.Write(writeBatch, false); err != nil {
+		t.Fatalf("Error while writing: %s", err)
+	}
+	writeBatch.Destroy()
+	assertKVStoreValue(t, store, StateCFName, "key1", nil)
+	assertKVStoreValue(t, store, StateCFName, "key2", []byte("newValue2"))
+	// the snapshot is not affected by the later writes
+	if value, err := snapshot.Get(StateCFName, []byte("key2")); err != nil || !bytes.Equal(value, []byte("value2")) {
+		t.Fatalf("Expected [value2] in the snapshot, found [%s], err=[%v]", value, err)
+	}
+	assertIteratorKeys(t, snapshot.NewIterator(StateCFName), nil, []string{"key1", "key2", "key3", "key4"})
+
+	assertIteratorKeys(t, store.NewIterator(StateCFName), nil, []string{"key2", "key3", "key4"})
+	assertIteratorKeys(t, store.NewIterator(StateCFName), []byte("key21"), []string{"key3", "key4"})
+	assertIteratorKeys(t, store.NewIterator(WalCFName), nil, nil)
+
+	generation := store.Generation()
+	if err := store.Clear(StateCFName); err != nil {
+		t.Fatalf("Error while clearing: %s", err)
+	}
+	if store.Generation() == generation {
+		t.Fatalf("Expected the generation to change when clearing a column family")
+	}
+	assertIteratorKeys(t, store.NewIterator(StateCFName), nil, nil)
+	assertKVStoreValue(t, store, IndexesCFName, "key1", []byte("otherValue1"))
+	if err := store.Clear("unknownCF"); err == nil {
+		t.Fatalf("Expected an error for an unknown column family")
+	}
+}
+
+func assertKVStoreValue(t testing.TB, store KVStore, cfName string, key string, expected []byte) {
+	value, err := store.Get(cfName, []byte(key))
+	if err != nil {
+		t.Fatalf("Error while reading key [%s]: %s", key, err)
+	}
+	if (value == nil) != (expected == nil) || !bytes.Equal(value, expected) {
+		t.Fatalf("Expected value [%v] for key [%s], found [%v]", expected, key, value)
+	}
+}
+
+func assertIteratorKeys(t testing.TB, itr Iterator, seekKey []byte, expected []string) {
+	defer itr.Close()
+	if seekKey == nil {
+		itr.SeekToFirst()
+	} else {
+		itr.Seek(seekKey)
+	}
+	var keys []string
+	for ; itr.Valid(); itr.Next() {
+		keys = append(keys, string(itr.Key()))
+	}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Fatalf("Expected keys %v, found %v", expected, keys)
+	}
+}
@@ -0,0 +1,213 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kvstore
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/hyperledger/fabric/core/errors"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/iterator"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// LevelDBKVStore is a KVStore backed by goleveldb, a pure Go implementation of LevelDB.
+// LevelDB has no column families, hence the keys of a column family are stored under the
+// name of the column family followed by a zero byte
+type LevelDBKVStore struct {
+	db         *leveldb.DB
+	generation uint64
+}
+
+// NewLevelDBKVStore opens the goleveldb database at the given path, creating it if missing
+func NewLevelDBKVStore(dbPath string) (*LevelDBKVStore, error) {
+	db, err := leveldb.OpenFile(dbPath, nil)
+	if err != nil {
+		return nil, errors.Errorf(errors.DBUnavailable, "Error while opening the goleveldb database at [%s]: %s", dbPath, err)
+	}
+	return &LevelDBKVStore{db: db}, nil
+}
+
+// Close closes the database. The store must not be used afterwards
+func (store *LevelDBKVStore) Close() error {
+	return store.db.Close()
+}
+
+// Get implements method in interface `KVStore`
+func (store *LevelDBKVStore) Get(cfName string, key []byte) ([]byte, error) {
+	return levelDBGet(store.db, cfName, key)
+}
+
+// NewIterator implements method in interface `KVStore`
+func (store *LevelDBKVStore) NewIterator(cfName string) Iterator {
+	return newLevelDBIterator(store.db, cfName)
+}
+
+// NewWriteBatch implements method in interface `KVStore`
+func (store *LevelDBKVStore) NewWriteBatch() WriteBatch {
+	return &levelDBWriteBatch{batch: new(leveldb.Batch)}
+}
+
+// Write implements method in interface `KVStore`
+func (store *LevelDBKVStore) Write(writeBatch WriteBatch, sync bool) error {
+	levelDBBatch, ok := writeBatch.(*levelDBWriteBatch)
+	if !ok {
+		return fmt.Errorf("Write-batch of type [%T] cannot be written to goleveldb", writeBatch)
+	}
+	if levelDBBatch.unknownCFName != "" {
+		return UnknownColumnFamily(levelDBBatch.unknownCFName)
+	}
+	if err := store.db.Write(levelDBBatch.batch, &opt.WriteOptions{Sync: sync}); err != nil {
+		return errors.Errorf(errors.DBUnavailable, "Error while writing the write-batch: %s", err)
+	}
+	return nil
+}
+
+// NewSnapshot implements method in interface `KVStore`
+func (store *LevelDBKVStore) NewSnapshot() Snapshot {
+	snapshot, err := store.db.GetSnapshot()
+	if err != nil {
+		panic(fmt.Errorf("Error while taking a snapshot of goleveldb: %s", err))
+	}
+	return &levelDBSnapshot{snapshot}
+}
+
+// Clear implements method in interface `KVStore`. The keys are deleted in a single write-batch
+func (store *LevelDBKVStore) Clear(cfNames ...string) error {
+	batch := new(leveldb.Batch)
+	for _, cfName := range cfNames {
+		if !isColumnFamily(cfName) {
+			return UnknownColumnFamily(cfName)
+		}
+		itr := store.db.NewIterator(util.BytesPrefix(cfPrefix(cfName)), nil)
+		for itr.Next() {
+			batch.Delete(makeCopy(itr.Key()))
+		}
+		itr.Release()
+		if err := itr.Error(); err != nil {
+			return errors.Errorf(errors.DBUnavailable, "Error while iterating over [%s]: %s", cfName, err)
+		}
+	}
+	if err := store.db.Write(batch, &opt.WriteOptions{Sync: true}); err != nil {
+		return errors.Errorf(errors.DBUnavailable, "Error while clearing the column families: %s", err)
+	}
+	atomic.AddUint64(&store.generation, 1)
+	return nil
+}
+
+// Generation implements method in interface `KVStore`
+func (store *LevelDBKVStore) Generation() uint64 {
+	return atomic.LoadUint64(&store.generation)
+}
+
+func cfPrefix(cfName string) []byte {
+	return append([]byte(cfName), 0)
+}
+
+func cfKey(cfName string, key []byte) []byte {
+	return append(cfPrefix(cfName), key...)
+}
+
+// levelDBReader is implemented by both leveldb.DB and leveldb.Snapshot
+type levelDBReader interface {
+	Get(key []byte, ro *opt.ReadOptions) ([]byte, error)
+	NewIterator(slice *util.Range, ro *opt.ReadOptions) iterator.Iterator
+}
+
+func levelDBGet(reader levelDBReader, cfName string, key []byte) ([]byte, error) {
+	if !isColumnFamily(cfName) {
+		return nil, UnknownColumnFamily(cfName)
+	}
+	value, err := reader.Get(cfKey(cfName, key), nil)
+	if err == leveldb.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Errorf(errors.DBUnavailable, "Error while retrieving key [%x]: %s", key, err)
+	}
+	return value, nil
+}
+
+type levelDBSnapshot struct {
+	snapshot *leveldb.Snapshot
+}
+
+func (snapshot *levelDBSnapshot) Get(cfName string, key []byte) ([]byte, error) {
+	return levelDBGet(snapshot.snapshot, cfName, key)
+}
+
+func (snapshot *levelDBSnapshot) NewIterator(cfName string) Iterator {
+	return newLevelDBIterator(snapshot.snapshot, cfName)
+}
+
+func (snapshot *levelDBSnapshot) Release() {
+	snapshot.snapshot.Release()
+}
+
+type levelDBWriteBatch struct {
+	batch *leveldb.Batch
+	// unknownCFName is the name of an unknown column family the batch was given, if any, reported by Write
+	unknownCFName string
+}
+
+func (batch *levelDBWriteBatch) Put(cfName string, key []byte, value []byte) {
+	batch.checkColumnFamily(cfName)
+	batch.batch.Put(cfKey(cfName, key), value)
+}
+
+func (batch *levelDBWriteBatch) Delete(cfName string, key []byte) {
+	batch.checkColumnFamily(cfName)
+	batch.batch.Delete(cfKey(cfName, key))
+}
+
+func (batch *levelDBWriteBatch) Destroy() {
+	batch.batch.Reset()
+}
+
+func (batch *levelDBWriteBatch) checkColumnFamily(cfName string) {
+	if batch.unknownCFName == "" && !isColumnFamily(cfName) {
+		batch.unknownCFName = cfName
+	}
+}
+
+// levelDBIterator strips the prefix of the column family from the keys. Like RocksDB iterators,
+// the iterator sees the column family as of its creation
+type levelDBIterator struct {
+	itr    iterator.Iterator
+	cfName string
+	prefix []byte
+	valid  bool
+}
+
+func newLevelDBIterator(reader levelDBReader, cfName string) *levelDBIterator {
+	if !isColumnFamily(cfName) {
+		panic(UnknownColumnFamily(cfName))
+	}
+	prefix := cfPrefix(cfName)
+	return &levelDBIterator{itr: reader.NewIterator(util.BytesPrefix(prefix), nil), cfName: cfName, prefix: prefix}
+}
+
+func (itr *levelDBIterator) Seek(key []byte) { itr.valid = itr.itr.Seek(cfKey(itr.cfName, key)) }
+func (itr *levelDBIterator) SeekToFirst()    { itr.valid = itr.itr.First() }
+func (itr *levelDBIterator) Valid() bool     { return itr.valid }
+func (itr *levelDBIterator) Next()           { itr.valid = itr.itr.Next() }
+func (itr *levelDBIterator) Key() []byte     { return makeCopy(itr.itr.Key()[len(itr.prefix):]) }
+func (itr *levelDBIterator) Value() []byte   { return makeCopy(itr.itr.Value()) }
+func (itr *levelDBIterator) Err() error      { return itr.itr.Error() }
+func (itr *levelDBIterator) Close()          { itr.itr.Release() }
@@ -0,0 +1,207 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kvstore
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// NewMemoryKVStore returns an empty KVStore, with the column families of the ledger DB,
+// held in memory. The changes are lost when the store is garbage collected.
+func NewMemoryKVStore() KVStore {
+	store := &memoryKVStore{cfs: make(map[string]map[string][]byte)}
+	for _, cfName := range ColumnFamilies() {
+		store.cfs[cfName] = make(map[string][]byte)
+	}
+	return store
+}
+
+type memoryKVStore struct {
+	lock       sync.RWMutex
+	cfs        map[string]map[string][]byte
+	generation uint64
+}
+
+func (store *memoryKVStore) Get(cfName string, key []byte) ([]byte, error) {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+	cf, ok := store.cfs[cfName]
+	if !ok {
+		return nil, UnknownColumnFamily(cfName)
+	}
+	value, ok := cf[string(key)]
+	if !ok {
+		return nil, nil
+	}
+	return makeCopy(value), nil
+}
+
+// NewIterator - like RocksDB iterators, the iterator sees the column family as of its creation
+func (store *memoryKVStore) NewIterator(cfName string) Iterator {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+	return newMemoryIterator(store.mustGetCF(cfName))
+}
+
+func (store *memoryKVStore) NewWriteBatch() WriteBatch {
+	return &memoryWriteBatch{}
+}
+
+func (store *memoryKVStore) Write(writeBatch WriteBatch, sync bool) error {
+	memoryBatch, ok := writeBatch.(*memoryWriteBatch)
+	if !ok {
+		return fmt.Errorf("Write-batch of type [%T] cannot be written to a memory store", writeBatch)
+	}
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	for _, op := range memoryBatch.ops {
+		if !isColumnFamily(op.cfName) {
+			return UnknownColumnFamily(op.cfName)
+		}
+	}
+	for _, op := range memoryBatch.ops {
+		if op.value == nil {
+			delete(store.cfs[op.cfName], op.key)
+		} else {
+			store.cfs[op.cfName][op.key] = op.value
+		}
+	}
+	return nil
+}
+
+// NewSnapshot copies the maps of the column families. The values are never modified in place,
+// hence they are shared with the snapshot
+func (store *memoryKVStore) NewSnapshot() Snapshot {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+	snapshot := &memoryKVStore{cfs: make(map[string]map[string][]byte)}
+	for cfName, cf := range store.cfs {
+		snapshotCF := make(map[string][]byte, len(cf))
+		for k, v := range cf {
+			snapshotCF[k] = v
+		}
+		snapshot.cfs[cfName] = snapshotCF
+	}
+	return snapshot
+}
+
+func (store *memoryKVStore) Clear(cfNames ...string) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	for _, cfName := range cfNames {
+		if _, ok := store.cfs[cfName]; !ok {
+			return UnknownColumnFamily(cfName)
+		}
+	}
+	for _, cfName := range cfNames {
+		store.cfs[cfName] = make(map[string][]byte)
+	}
+	store.generation++
+	return nil
+}
+
+func (store *memoryKVStore) Generation() uint64 {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+	return store.generation
+}
+
+// Release - a snapshot of a memory store is a memory store itself
+func (store *memoryKVStore) Release() {
+}
+
+func (store *memoryKVStore) mustGetCF(cfName string) map[string][]byte {
+	cf, ok := store.cfs[cfName]
+	if !ok {
+		panic(UnknownColumnFamily(cfName))
+	}
+	return cf
+}
+
+type memoryWriteBatchOp struct {
+	cfName string
+	key    string
+	// value is nil for a delete
+	value []byte
+}
+
+type memoryWriteBatch struct {
+	ops []memoryWriteBatchOp
+}
+
+func (batch *memoryWriteBatch) Put(cfName string, key []byte, value []byte) {
+	batch.ops = append(batch.ops, memoryWriteBatchOp{cfName, string(key), append([]byte{}, value...)})
+}
+
+func (batch *memoryWriteBatch) Delete(cfName string, key []byte) {
+	batch.ops = append(batch.ops, memoryWriteBatchOp{cfName, string(key), nil})
+}
+
+func (batch *memoryWriteBatch) Destroy() {
+	batch.ops = nil
+}
+
+type memoryIterator struct {
+	keys   []string
+	values [][]byte
+	index  int
+}
+
+func newMemoryIterator(cf map[string][]byte) *memoryIterator {
+	itr := &memoryIterator{index: len(cf)}
+	for k := range cf {
+		itr.keys = append(itr.keys, k)
+	}
+	sort.Strings(itr.keys)
+	for _, k := range itr.keys {
+		itr.values = append(itr.values, cf[k])
+	}
+	return itr
+}
+
+func (itr *memoryIterator) Seek(key []byte) {
+	itr.index = sort.SearchStrings(itr.keys, string(key))
+}
+
+func (itr *memoryIterator) SeekToFirst() {
+	itr.index = 0
+}
+
+func (itr *memoryIterator) Valid() bool {
+	return itr.index < len(itr.keys)
+}
+
+func (itr *memoryIterator) Next() {
+	itr.index++
+}
+
+func (itr *memoryIterator) Key() []byte {
+	return []byte(itr.keys[itr.index])
+}
+
+func (itr *memoryIterator) Value() []byte {
+	return makeCopy(itr.values[itr.index])
+}
+
+func (itr *memoryIterator) Err() error {
+	return nil
+}
+
+func (itr *memoryIterator) Close() {
+}
@@ -0,0 +1,176 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package db
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/hyperledger/fabric/core/db/kvstore"
+	"github.com/hyperledger/fabric/core/errors"
+	"github.com/tecbot/gorocksdb"
+)
+
+// KVStore returns the ledger DB as a kvstore.KVStore
+func (openchainDB *OpenchainDB) KVStore() kvstore.KVStore {
+	return &rocksDBKVStore{openchainDB}
+}
+
+// WrapWriteBatch returns a WriteBatch that adds the changes to the given RocksDB write-batch
+func (openchainDB *OpenchainDB) WrapWriteBatch(writeBatch *gorocksdb.WriteBatch) kvstore.WriteBatch {
+	return &rocksDBWriteBatch{openchainDB, writeBatch, false}
+}
+
+type rocksDBKVStore struct {
+	openchainDB *OpenchainDB
+}
+
+func (store *rocksDBKVStore) Get(cfName string, key []byte) ([]byte, error) {
+	cfHandler := store.openchainDB.getCFHandler(cfName)
+	if cfHandler == nil {
+		return nil, kvstore.UnknownColumnFamily(cfName)
+	}
+	return store.openchainDB.Get(cfHandler, key)
+}
+
+func (store *rocksDBKVStore) NewIterator(cfName string) kvstore.Iterator {
+	return &rocksDBIterator{store.openchainDB, cfName, store.openchainDB.GetIterator(store.openchainDB.mustGetCFHandler(cfName)), nil}
+}
+
+func (store *rocksDBKVStore) NewWriteBatch() kvstore.WriteBatch {
+	return &rocksDBWriteBatch{store.openchainDB, gorocksdb.NewWriteBatch(), true}
+}
+
+func (store *rocksDBKVStore) Write(writeBatch kvstore.WriteBatch, sync bool) error {
+	rocksDBBatch, ok := writeBatch.(*rocksDBWriteBatch)
+	if !ok {
+		return fmt.Errorf("Write-batch of type [%T] cannot be written to RocksDB", writeBatch)
+	}
+	opt := gorocksdb.NewDefaultWriteOptions()
+	defer opt.Destroy()
+	opt.SetSync(sync)
+	if err := store.openchainDB.DB.Write(opt, rocksDBBatch.writeBatch); err != nil {
+		return errors.Errorf(errors.DBUnavailable, "Error while writing the write-batch: %s", err)
+	}
+	return nil
+}
+
+func (store *rocksDBKVStore) NewSnapshot() kvstore.Snapshot {
+	return &rocksDBSnapshot{store.openchainDB, store.openchainDB.GetSnapshot()}
+}
+
+// Clear drops the column families and creates them again, which is faster than deleting their keys
+func (store *rocksDBKVStore) Clear(cfNames ...string) error {
+	for _, cfName := range cfNames {
+		if store.openchainDB.getCFHandler(cfName) == nil {
+			return kvstore.UnknownColumnFamily(cfName)
+		}
+	}
+	for _, cfName := range cfNames {
+		if err := store.openchainDB.recreateColumnFamily(cfName, store.openchainDB.getCFHandlerRef(cfName)); err != nil {
+			return errors.Errorf(errors.DBUnavailable, "Error while recreating column family [%s]: %s", cfName, err)
+		}
+	}
+	return nil
+}
+
+func (store *rocksDBKVStore) Generation() uint64 {
+	return atomic.LoadUint64(&store.openchainDB.generation)
+}
+
+type rocksDBWriteBatch struct {
+	openchainDB *OpenchainDB
+	writeBatch  *gorocksdb.WriteBatch
+	owned       bool
+}
+
+func (batch *rocksDBWriteBatch) Put(cfName string, key []byte, value []byte) {
+	batch.writeBatch.PutCF(batch.openchainDB.mustGetCFHandler(cfName), key, batch.openchainDB.encodeValue(cfName, key, value))
+}
+
+func (batch *rocksDBWriteBatch) Delete(cfName string, key []byte) {
+	batch.writeBatch.DeleteCF(batch.openchainDB.mustGetCFHandler(cfName), key)
+}
+
+// Destroy releases the write-batch, unless it wraps a write-batch of the caller (see WrapWriteBatch)
+func (batch *rocksDBWriteBatch) Destroy() {
+	if batch.owned {
+		batch.writeBatch.Destroy()
+	}
+}
+
+type rocksDBSnapshot struct {
+	openchainDB *OpenchainDB
+	snapshot    *gorocksdb.Snapshot
+}
+
+func (snapshot *rocksDBSnapshot) Get(cfName string, key []byte) ([]byte, error) {
+	cfHandler := snapshot.openchainDB.getCFHandler(cfName)
+	if cfHandler == nil {
+		return nil, kvstore.UnknownColumnFamily(cfName)
+	}
+	return snapshot.openchainDB.getFromSnapshot(snapshot.snapshot, cfHandler, key)
+}
+
+func (snapshot *rocksDBSnapshot) NewIterator(cfName string) kvstore.Iterator {
+	return &rocksDBIterator{snapshot.openchainDB, cfName,
+		snapshot.openchainDB.getSnapshotIterator(snapshot.snapshot, snapshot.openchainDB.mustGetCFHandler(cfName)), nil}
+}
+
+func (snapshot *rocksDBSnapshot) Release() {
+	snapshot.snapshot.Release()
+}
+
+type rocksDBIterator struct {
+	openchainDB *OpenchainDB
+	cfName      string
+	itr         *gorocksdb.Iterator
+	// decryptErr is the error, if any, of the decryption of a value
+	decryptErr error
+}
+
+func (itr *rocksDBIterator) Seek(key []byte) { itr.itr.Seek(key) }
+func (itr *rocksDBIterator) SeekToFirst()    { itr.itr.SeekToFirst() }
+func (itr *rocksDBIterator) Valid() bool     { return itr.decryptErr == nil && itr.itr.Valid() }
+func (itr *rocksDBIterator) Next()           { itr.itr.Next() }
+func (itr *rocksDBIterator) Key() []byte     { return makeCopy(itr.itr.Key().Data()) }
+func (itr *rocksDBIterator) Close()          { itr.itr.Close() }
+
+// Value returns nil and invalidates the iterator if the value cannot be decrypted
+func (itr *rocksDBIterator) Value() []byte {
+	value, err := itr.openchainDB.decodeValue(itr.cfName, itr.Key(), makeCopy(itr.itr.Value().Data()))
+	if err != nil {
+		itr.decryptErr = err
+		return nil
+	}
+	return value
+}
+
+func (itr *rocksDBIterator) Err() error {
+	if itr.decryptErr != nil {
+		return itr.decryptErr
+	}
+	return itr.itr.Err()
+}
+
+func (openchainDB *OpenchainDB) mustGetCFHandler(cfName string) *gorocksdb.ColumnFamilyHandle {
+	cfHandler := openchainDB.getCFHandler(cfName)
+	if cfHandler == nil {
+		panic(kvstore.UnknownColumnFamily(cfName))
+	}
+	return cfHandler
+}
@@ -0,0 +1,60 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package db
+
+import (
+	"github.com/hyperledger/fabric/core/metrics"
+)
+
+// rocksDBMetrics are the rocksdb properties of the column families reported as metrics
+var rocksDBMetrics = []struct {
+	name     string
+	help     string
+	property string
+}{
+	{"db_estimated_keys", "Estimated number of keys of the column family.", "rocksdb.estimate-num-keys"},
+	{"db_sst_files_bytes", "Size of the table files of the column family.", "rocksdb.total-sst-files-size"},
+	{"db_live_data_bytes", "Estimated size of the live data of the column family.", "rocksdb.estimate-live-data-size"},
+	{"db_memtables_bytes", "Size of the memtables of the column family.", "rocksdb.cur-size-all-mem-tables"},
+	{"db_pending_compaction_bytes", "Estimated bytes to rewrite to compact the column family.", "rocksdb.estimate-pending-compaction-bytes"},
+}
+
+func init() {
+	for _, m := range rocksDBMetrics {
+		property := m.property
+		metrics.NewGaugeFunc(m.name, m.help, []string{"column_family"}, func() []metrics.Sample {
+			return getPropertySamples(property)
+		})
+	}
+}
+
+// getPropertySamples reads the property of each column family of the DB of the peer, read
+// at the time the metrics are served as rocksdb maintains them anyway
+func getPropertySamples(property string) []metrics.Sample {
+	if !isOpen {
+		return nil
+	}
+	var samples []metrics.Sample
+	for _, cfName := range columnfamilies {
+		cfHandler := openchainDB.getCFHandler(cfName)
+		if cfHandler == nil {
+			continue
+		}
+		samples = append(samples, metrics.Sample{LabelValues: []string{cfName}, Value: float64(openchainDB.getUint64PropertyCF(property, cfHandler))})
+	}
+	return samples
+}
@@ -0,0 +1,65 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package db
+
+import "github.com/hyperledger/fabric/core/db/kvstore"
+
+// DebugProperties are the rocksdb properties dumped by default when diagnosing a DB that
+// stalls the commits: the statistics of the column families, the flushes and compactions
+// in progress and whether the writes are stopped or delayed
+var DebugProperties = []string{
+	"rocksdb.cfstats",
+	"rocksdb.levelstats",
+	"rocksdb.num-immutable-mem-table",
+	"rocksdb.mem-table-flush-pending",
+	"rocksdb.compaction-pending",
+	"rocksdb.background-errors",
+	"rocksdb.estimate-pending-compaction-bytes",
+	"rocksdb.num-running-flushes",
+	"rocksdb.num-running-compactions",
+	"rocksdb.is-write-stopped",
+	"rocksdb.actual-delayed-write-rate",
+}
+
+// Property is the value of a rocksdb property of a column family
+type Property struct {
+	ColumnFamily string `json:"columnFamily"`
+	Name         string `json:"name"`
+	Value        string `json:"value"`
+}
+
+// GetProperties returns the values of the rocksdb properties for each column family, or
+// for the given column families only. The properties not supported by the rocksdb version
+// in use are left out
+func (openchainDB *OpenchainDB) GetProperties(names []string, cfNames ...string) ([]Property, error) {
+	if len(cfNames) == 0 {
+		cfNames = columnfamilies
+	}
+	var properties []Property
+	for _, cfName := range cfNames {
+		cfHandler := openchainDB.getCFHandler(cfName)
+		if cfHandler == nil {
+			return nil, kvstore.UnknownColumnFamily(cfName)
+		}
+		for _, name := range names {
+			if value := openchainDB.DB.GetPropertyCF(name, cfHandler); value != "" {
+				properties = append(properties, Property{cfName, name, value})
+			}
+		}
+	}
+	return properties, nil
+}
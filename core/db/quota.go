@@ -0,0 +1,99 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package db
+
+import (
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// QuotaStatus tells how the disk space used by a DB compares with its quotas
+type QuotaStatus string
+
+const (
+	// QuotaOK is the status of a DB below its soft quota
+	QuotaOK = QuotaStatus("ok")
+	// QuotaSoftExceeded is the status of a DB above its soft quota and below its hard quota
+	QuotaSoftExceeded = QuotaStatus("softQuotaExceeded")
+	// QuotaHardExceeded is the status of a DB above its hard quota
+	QuotaHardExceeded = QuotaStatus("hardQuotaExceeded")
+)
+
+const defaultQuotaCheckInterval = 10 * time.Second
+
+// DiskUsage reports the disk space used by a DB along with its quotas ('peer.db.quota')
+type DiskUsage struct {
+	UsedBytes uint64 `json:"usedBytes"`
+	// SoftQuotaBytes and HardQuotaBytes are 0 if not set
+	SoftQuotaBytes uint64      `json:"softQuotaBytes"`
+	HardQuotaBytes uint64      `json:"hardQuotaBytes"`
+	Status         QuotaStatus `json:"status"`
+}
+
+// diskQuota measures the disk space of a DB at most once every checkInterval, as walking the
+// DB directory on every commit would be wasteful
+type diskQuota struct {
+	softBytes     uint64
+	hardBytes     uint64
+	checkInterval time.Duration
+	lock          sync.Mutex
+	lastCheck     time.Time
+	usage         *DiskUsage
+}
+
+func newDiskQuotaFromConfig() *diskQuota {
+	softBytes := uint64(viper.GetInt("peer.db.quota.soft")) * 1024 * 1024
+	hardBytes := uint64(viper.GetInt("peer.db.quota.hard")) * 1024 * 1024
+	if softBytes == 0 && hardBytes == 0 {
+		return nil
+	}
+	checkInterval := viper.GetDuration("peer.db.quota.checkInterval")
+	if checkInterval <= 0 {
+		checkInterval = defaultQuotaCheckInterval
+	}
+	return &diskQuota{softBytes: softBytes, hardBytes: hardBytes, checkInterval: checkInterval}
+}
+
+// CheckDiskQuota returns the disk space used by the DB and its status with respect to the
+// quotas, or nil if no quota is configured. The returned usage may be up to
+// 'peer.db.quota.checkInterval' old.
+func (openchainDB *OpenchainDB) CheckDiskQuota() (*DiskUsage, error) {
+	quota := openchainDB.quota
+	if quota == nil {
+		return nil, nil
+	}
+	quota.lock.Lock()
+	defer quota.lock.Unlock()
+	if quota.usage != nil && time.Since(quota.lastCheck) < quota.checkInterval {
+		return quota.usage, nil
+	}
+	usedBytes, err := dirSize(openchainDB.dbPath)
+	if err != nil {
+		return nil, err
+	}
+	usage := &DiskUsage{UsedBytes: usedBytes, SoftQuotaBytes: quota.softBytes, HardQuotaBytes: quota.hardBytes, Status: QuotaOK}
+	if quota.hardBytes > 0 && usedBytes >= quota.hardBytes {
+		usage.Status = QuotaHardExceeded
+	} else if quota.softBytes > 0 && usedBytes >= quota.softBytes {
+		usage.Status = QuotaSoftExceeded
+	}
+	quota.usage = usage
+	quota.lastCheck = time.Now()
+	return usage, nil
+}
@@ -0,0 +1,222 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package db
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/fabric/core/db/kvstore"
+	"github.com/tecbot/gorocksdb"
+)
+
+// lastCompactionKeyPrefix is the prefix of the keys in persistCF recording the time of the
+// last compaction of each column family triggered through CompactColumnFamily
+const lastCompactionKeyPrefix = "db.lastCompaction."
+
+// ColumnFamilySpace reports the space used by a column family, as estimated by rocksdb
+type ColumnFamilySpace struct {
+	Name string `json:"name"`
+	// NumKeys is the estimated number of keys
+	NumKeys uint64 `json:"numKeys"`
+	// TotalBytes is the size of the table (sst) files of the column family
+	TotalBytes uint64 `json:"totalBytes"`
+	// LiveBytes is the estimated size of the live data, i.e., of the latest values of the keys
+	LiveBytes uint64 `json:"liveBytes"`
+	// DeadBytes is the size of the overwritten or deleted data still held by the table files,
+	// which compactions reclaim
+	DeadBytes uint64 `json:"deadBytes"`
+	// MemTableBytes is the size of the data not yet flushed to table files
+	MemTableBytes uint64 `json:"memTableBytes"`
+	// PendingCompactionBytes is the estimated number of bytes that compactions have to
+	// rewrite for bringing the levels of the column family down to their target size
+	PendingCompactionBytes uint64 `json:"pendingCompactionBytes"`
+	// CompactionPending is true if rocksdb has a background compaction to run
+	CompactionPending bool `json:"compactionPending"`
+	// LastCompaction is the time of the last compaction of the whole column family triggered
+	// through CompactColumnFamily, nil if none. The background compactions run by rocksdb
+	// are not recorded
+	LastCompaction *time.Time `json:"lastCompaction,omitempty"`
+}
+
+// SpaceReport accounts for the disk space used by the DB
+type SpaceReport struct {
+	ColumnFamilies []*ColumnFamilySpace `json:"columnFamilies"`
+	// TotalBytes and LiveBytes are the sums over the column families
+	TotalBytes uint64 `json:"totalBytes"`
+	LiveBytes  uint64 `json:"liveBytes"`
+	// ReclaimableBytes estimates the space that compacting all the column families would free
+	ReclaimableBytes uint64 `json:"reclaimableBytes"`
+	// DiskBytes is the size of all the files in the DB directory, including the write-ahead
+	// log and the table files not yet deleted
+	DiskBytes uint64 `json:"diskBytes"`
+	// RunningCompactions is the number of compactions currently running
+	RunningCompactions uint64 `json:"runningCompactions"`
+}
+
+// GetSpaceReport reports, per column family, the live and dead bytes, the time of the last
+// compaction and the estimated space reclaimable by compactions. The figures are estimates
+// maintained by rocksdb and are cheap to obtain; the data is not scanned.
+func (openchainDB *OpenchainDB) GetSpaceReport() (*SpaceReport, error) {
+	report := &SpaceReport{}
+	for _, cfName := range columnfamilies {
+		cfSpace, err := openchainDB.getColumnFamilySpace(cfName)
+		if err != nil {
+			return nil, err
+		}
+		report.ColumnFamilies = append(report.ColumnFamilies, cfSpace)
+		report.TotalBytes += cfSpace.TotalBytes
+		report.LiveBytes += cfSpace.LiveBytes
+		report.ReclaimableBytes += cfSpace.DeadBytes
+	}
+	diskBytes, err := dirSize(openchainDB.dbPath)
+	if err != nil {
+		return nil, err
+	}
+	report.DiskBytes = diskBytes
+	report.RunningCompactions, _ = strconv.ParseUint(openchainDB.DB.GetProperty("rocksdb.num-running-compactions"), 10, 64)
+	return report, nil
+}
+
+func (openchainDB *OpenchainDB) getColumnFamilySpace(cfName string) (*ColumnFamilySpace, error) {
+	cfHandler := openchainDB.getCFHandler(cfName)
+	if cfHandler == nil {
+		return nil, kvstore.UnknownColumnFamily(cfName)
+	}
+	cfSpace := &ColumnFamilySpace{
+		Name:                   cfName,
+		NumKeys:                openchainDB.getUint64PropertyCF("rocksdb.estimate-num-keys", cfHandler),
+		TotalBytes:             openchainDB.getUint64PropertyCF("rocksdb.total-sst-files-size", cfHandler),
+		LiveBytes:              openchainDB.getUint64PropertyCF("rocksdb.estimate-live-data-size", cfHandler),
+		MemTableBytes:          openchainDB.getUint64PropertyCF("rocksdb.cur-size-all-mem-tables", cfHandler),
+		PendingCompactionBytes: openchainDB.getUint64PropertyCF("rocksdb.estimate-pending-compaction-bytes", cfHandler),
+		CompactionPending:      openchainDB.getUint64PropertyCF("rocksdb.compaction-pending", cfHandler) > 0,
+	}
+	if cfSpace.TotalBytes > cfSpace.LiveBytes {
+		cfSpace.DeadBytes = cfSpace.TotalBytes - cfSpace.LiveBytes
+	}
+	lastCompaction, err := openchainDB.getLastCompaction(cfName)
+	if err != nil {
+		return nil, err
+	}
+	cfSpace.LastCompaction = lastCompaction
+	return cfSpace, nil
+}
+
+// CompactColumnFamily compacts the whole key range of the column family, which drops the
+// overwritten and deleted data, and records the time of the compaction for GetSpaceReport
+func (openchainDB *OpenchainDB) CompactColumnFamily(cfName string) error {
+	cfHandler := openchainDB.getCFHandler(cfName)
+	if cfHandler == nil {
+		return kvstore.UnknownColumnFamily(cfName)
+	}
+	dbLogger.Info("Compacting column family [%s]", cfName)
+	openchainDB.DB.CompactRangeCF(cfHandler, gorocksdb.Range{Start: nil, Limit: nil})
+	timeBytes, err := time.Now().MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return openchainDB.Put(openchainDB.PersistCF, []byte(lastCompactionKeyPrefix+cfName), timeBytes)
+}
+
+func (openchainDB *OpenchainDB) getLastCompaction(cfName string) (*time.Time, error) {
+	timeBytes, err := openchainDB.Get(openchainDB.PersistCF, []byte(lastCompactionKeyPrefix+cfName))
+	if err != nil || timeBytes == nil {
+		return nil, err
+	}
+	lastCompaction := &time.Time{}
+	if err := lastCompaction.UnmarshalBinary(timeBytes); err != nil {
+		return nil, fmt.Errorf("Error while reading the time of the last compaction of column family [%s]: %s", cfName, err)
+	}
+	return lastCompaction, nil
+}
+
+func (openchainDB *OpenchainDB) getCFHandler(cfName string) *gorocksdb.ColumnFamilyHandle {
+	cfHandlerRef := openchainDB.getCFHandlerRef(cfName)
+	if cfHandlerRef == nil {
+		return nil
+	}
+	return *cfHandlerRef
+}
+
+// getCFHandlerRef returns the field of the handle of the column family, nil for an unknown column family
+func (openchainDB *OpenchainDB) getCFHandlerRef(cfName string) **gorocksdb.ColumnFamilyHandle {
+	switch cfName {
+	case blockchainCF:
+		return &openchainDB.BlockchainCF
+	case stateCF:
+		return &openchainDB.StateCF
+	case stateDeltaCF:
+		return &openchainDB.StateDeltaCF
+	case indexesCF:
+		return &openchainDB.IndexesCF
+	case persistCF:
+		return &openchainDB.PersistCF
+	case stagingCF:
+		return &openchainDB.StagingCF
+	case walCF:
+		return &openchainDB.WalCF
+	case secondaryIndexesCF:
+		return &openchainDB.SecondaryIndexesCF
+	case privateCF:
+		return &openchainDB.PrivateCF
+	case consensusCF:
+		return &openchainDB.ConsensusCF
+	case documentsCF:
+		return &openchainDB.DocumentsCF
+	}
+	return nil
+}
+
+func (openchainDB *OpenchainDB) getCFName(cfHandler *gorocksdb.ColumnFamilyHandle) string {
+	for _, cfName := range columnfamilies {
+		if openchainDB.getCFHandler(cfName) == cfHandler {
+			return cfName
+		}
+	}
+	return ""
+}
+
+// getUint64PropertyCF returns 0 for the properties not supported by the rocksdb version in use
+func (openchainDB *OpenchainDB) getUint64PropertyCF(propName string, cfHandler *gorocksdb.ColumnFamilyHandle) uint64 {
+	value, err := strconv.ParseUint(openchainDB.DB.GetPropertyCF(propName, cfHandler), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return value
+}
+
+func dirSize(dirPath string) (uint64, error) {
+	size := uint64(0)
+	err := filepath.Walk(dirPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// files are deleted by rocksdb while walking
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !info.IsDir() {
+			size += uint64(info.Size())
+		}
+		return nil
+	})
+	return size, err
+}
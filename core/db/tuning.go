@@ -0,0 +1,96 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package db
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/viper"
+	"github.com/tecbot/gorocksdb"
+)
+
+// cfTuning holds the RocksDB options of a column family (see 'peer.db' in core.yaml).
+// A zero value keeps the RocksDB default.
+type cfTuning struct {
+	blockCacheSizeMBs     int
+	writeBufferSizeMBs    int
+	compression           string
+	bloomFilterBitsPerKey int
+}
+
+var compressionTypes = map[string]gorocksdb.CompressionType{
+	"none":   gorocksdb.NoCompression,
+	"snappy": gorocksdb.SnappyCompression,
+	"zlib":   gorocksdb.ZLibCompression,
+	"bz2":    gorocksdb.Bz2Compression,
+}
+
+// getCFTuning returns the options configured under 'peer.db.default' overridden by the
+// ones configured under 'peer.db.columnFamilies.<cfName>'
+func getCFTuning(cfName string) cfTuning {
+	tuning := cfTuning{}
+	for _, prefix := range []string{"peer.db.default.", "peer.db.columnFamilies." + cfName + "."} {
+		if viper.IsSet(prefix + "blockCacheSize") {
+			tuning.blockCacheSizeMBs = viper.GetInt(prefix + "blockCacheSize")
+		}
+		if viper.IsSet(prefix + "writeBufferSize") {
+			tuning.writeBufferSizeMBs = viper.GetInt(prefix + "writeBufferSize")
+		}
+		if viper.IsSet(prefix + "compression") {
+			tuning.compression = strings.ToLower(strings.TrimSpace(viper.GetString(prefix + "compression")))
+		}
+		if viper.IsSet(prefix + "bloomFilterBitsPerKey") {
+			tuning.bloomFilterBitsPerKey = viper.GetInt(prefix + "bloomFilterBitsPerKey")
+		}
+	}
+	return tuning
+}
+
+// newCFOptions returns the options for opening the column family. The caller has to
+// destroy the options once the DB is open.
+func newCFOptions(cfName string) (*gorocksdb.Options, error) {
+	tuning := getCFTuning(cfName)
+	opts := gorocksdb.NewDefaultOptions()
+	if tuning.writeBufferSizeMBs > 0 {
+		opts.SetWriteBufferSize(tuning.writeBufferSizeMBs * 1024 * 1024)
+	}
+	if tuning.compression != "" {
+		compressionType, ok := compressionTypes[tuning.compression]
+		if !ok {
+			opts.Destroy()
+			return nil, fmt.Errorf("Unknown compression type [%s] configured for column family [%s]", tuning.compression, cfName)
+		}
+		opts.SetCompression(compressionType)
+	}
+	if tuning.blockCacheSizeMBs > 0 || tuning.bloomFilterBitsPerKey > 0 {
+		tableOpts := gorocksdb.NewDefaultBlockBasedTableOptions()
+		defer tableOpts.Destroy()
+		if tuning.blockCacheSizeMBs > 0 {
+			// the table factory keeps a reference to the cache, the handle is not needed afterwards
+			cache := gorocksdb.NewLRUCache(tuning.blockCacheSizeMBs * 1024 * 1024)
+			defer cache.Destroy()
+			tableOpts.SetBlockCache(cache)
+		}
+		if tuning.bloomFilterBitsPerKey > 0 {
+			tableOpts.SetFilterPolicy(gorocksdb.NewBloomFilter(tuning.bloomFilterBitsPerKey))
+		}
+		opts.SetBlockBasedTableFactory(tableOpts)
+	}
+	dbLogger.Debug("Options of column family [%s]: %+v", cfName, tuning)
+	return opts, nil
+}
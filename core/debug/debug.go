@@ -0,0 +1,125 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package debug collects what is needed to diagnose a peer whose commits do not progress:
+// the pprof profiles, including the goroutine dump, the statistics of the transaction-batch
+// in progress and the rocksdb properties. They are served by the Admin service and, over
+// HTTP, by Handler, both restricted to the clients with the admin role.
+package debug
+
+import (
+	"io"
+	"runtime/pprof"
+	"runtime/trace"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/fabric/core/db"
+	"github.com/hyperledger/fabric/core/errors"
+	"github.com/hyperledger/fabric/core/ledger"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt/state"
+	"github.com/op/go-logging"
+)
+
+var logger = logging.MustGetLogger("debug")
+
+// DefaultProfileDuration is the duration of the cpu profiles and of the traces when none
+// is given
+const DefaultProfileDuration = 30 * time.Second
+
+// cpuProfileLock serializes the cpu profiles and the traces, of which only one may run
+var cpuProfileLock sync.Mutex
+
+// WriteProfile writes the pprof profile of the given name, such as goroutine, heap,
+// threadcreate or block, in the given format (see pprof.Profile.WriteTo). The cpu
+// profile and the execution trace are recorded for the given duration
+func WriteProfile(w io.Writer, name string, duration time.Duration, debug int) error {
+	if duration <= 0 {
+		duration = DefaultProfileDuration
+	}
+	switch name {
+	case "cpu":
+		cpuProfileLock.Lock()
+		defer cpuProfileLock.Unlock()
+		if err := pprof.StartCPUProfile(w); err != nil {
+			return err
+		}
+		logger.Info("Recording a cpu profile for %s", duration)
+		time.Sleep(duration)
+		pprof.StopCPUProfile()
+		return nil
+	case "trace":
+		cpuProfileLock.Lock()
+		defer cpuProfileLock.Unlock()
+		if err := trace.Start(w); err != nil {
+			return err
+		}
+		logger.Info("Recording an execution trace for %s", duration)
+		time.Sleep(duration)
+		trace.Stop()
+		return nil
+	}
+	profile := pprof.Lookup(name)
+	if profile == nil {
+		return errors.Errorf(errors.NotFound, "Unknown profile [%s]", name)
+	}
+	return profile.WriteTo(w, debug)
+}
+
+// LedgerStats are the statistics of the ledger of a chain while it runs
+type LedgerStats struct {
+	BlockchainSize uint64 `json:"blockchainSize"`
+	// LastBlockCommitted is the time the last block was added, zero if none since the
+	// peer started
+	LastBlockCommitted time.Time `json:"lastBlockCommitted"`
+	// State describes the transaction-batch in progress
+	State state.RuntimeStats `json:"state"`
+}
+
+// GetLedgerStats returns the statistics of the ledger of the chain, the default chain if
+// chainID is empty
+func GetLedgerStats(chainID string) (*LedgerStats, error) {
+	chainLedger, err := getLedger(chainID)
+	if err != nil {
+		return nil, err
+	}
+	return &LedgerStats{
+		BlockchainSize:     chainLedger.GetBlockchainSize(),
+		LastBlockCommitted: chainLedger.LastBlockCommitted(),
+		State:              chainLedger.GetStateRuntimeStats(),
+	}, nil
+}
+
+// GetDBProperties returns the values of the rocksdb properties, db.DebugProperties if none
+// is given, of the column families of the DB of the chain, all of them if none is given
+func GetDBProperties(chainID string, names []string, cfNames []string) ([]db.Property, error) {
+	chainLedger, err := getLedger(chainID)
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		names = db.DebugProperties
+	}
+	return chainLedger.GetDBProperties(names, cfNames...)
+}
+
+func getLedger(chainID string) (*ledger.Ledger, error) {
+	chainLedger, err := ledger.GetLedgerByChainID(chainID)
+	if err != nil {
+		return nil, errors.Errorf(errors.CodeOf(err), "Error getting the ledger of chain [%s]: %s", chainID, err)
+	}
+	return chainLedger, nil
+}
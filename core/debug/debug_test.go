@@ -0,0 +1,70 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package debug
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric/core/acl"
+	"github.com/hyperledger/fabric/core/errors"
+)
+
+func TestWriteProfile(t *testing.T) {
+	var buffer bytes.Buffer
+	if err := WriteProfile(&buffer, "goroutine", 0, 2); err != nil {
+		t.Fatalf("Error writing the goroutine dump: %s", err)
+	}
+	if !strings.Contains(buffer.String(), "TestWriteProfile") {
+		t.Fatalf("Expected the goroutine dump to hold the stack of the test, got\n%s", buffer.String())
+	}
+	buffer.Reset()
+	if err := WriteProfile(&buffer, "cpu", 10*time.Millisecond, 0); err != nil || buffer.Len() == 0 {
+		t.Fatalf("Expected a cpu profile, got %d bytes and error %v", buffer.Len(), err)
+	}
+	if err := WriteProfile(&buffer, "unknown", 0, 0); !errors.Is(err, errors.NotFound) {
+		t.Fatalf("Expected an unknown profile to be not found, got %v", err)
+	}
+}
+
+func TestHandlerRequiresAdmin(t *testing.T) {
+	policy := acl.NewPolicy()
+	policy.Enabled = true
+	acl.SetPolicy(policy)
+	defer acl.SetPolicy(acl.NewPolicy())
+
+	req, err := http.NewRequest("GET", "/debug/pprof/goroutine?debug=1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder := httptest.NewRecorder()
+	Handler().ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusForbidden {
+		t.Fatalf("Expected the anonymous client to be denied, got %d", recorder.Code)
+	}
+
+	acl.SetPolicy(acl.NewPolicy())
+	recorder = httptest.NewRecorder()
+	Handler().ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), "goroutine profile") {
+		t.Fatalf("Expected the goroutine profile, got %d\n%s", recorder.Code, recorder.Body.String())
+	}
+}
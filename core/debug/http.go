@@ -0,0 +1,73 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package debug
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/pprof"
+
+	"github.com/hyperledger/fabric/core/acl"
+	"github.com/hyperledger/fabric/core/errors"
+)
+
+// Handler returns the handler of the debug endpoints, which require the admin role when
+// the access control is enabled:
+//
+//	/debug/pprof/          the pprof profiles, as served by net/http/pprof
+//	/debug/ledger?chainID= the statistics of the ledger, see LedgerStats
+//	/debug/db?chainID=&property=&columnFamily= the rocksdb properties, see GetDBProperties
+func Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc("/debug/ledger", func(rw http.ResponseWriter, req *http.Request) {
+		stats, err := GetLedgerStats(req.FormValue("chainID"))
+		writeJSON(rw, stats, err)
+	})
+	mux.HandleFunc("/debug/db", func(rw http.ResponseWriter, req *http.Request) {
+		req.ParseForm()
+		properties, err := GetDBProperties(req.FormValue("chainID"), req.Form["property"], req.Form["columnFamily"])
+		writeJSON(rw, properties, err)
+	})
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if err := acl.CheckHTTP(req, acl.AdminAPI); err != nil {
+			http.Error(rw, err.Error(), http.StatusForbidden)
+			return
+		}
+		mux.ServeHTTP(rw, req)
+	})
+}
+
+func writeJSON(rw http.ResponseWriter, value interface{}, err error) {
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch errors.CodeOf(err) {
+		case errors.NotFound, errors.InvalidArgument:
+			status = http.StatusBadRequest
+		}
+		http.Error(rw, err.Error(), status)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(value); err != nil {
+		logger.Error("Error encoding the response: %s", err)
+	}
+}
@@ -24,6 +24,7 @@ import (
 	"github.com/spf13/viper"
 	"golang.org/x/net/context"
 
+	"github.com/hyperledger/fabric/core/acl"
 	"github.com/hyperledger/fabric/core/chaincode"
 	"github.com/hyperledger/fabric/core/chaincode/platforms"
 	"github.com/hyperledger/fabric/core/container"
@@ -107,6 +108,12 @@ func (*Devops) getChaincodeBytes(context context.Context, spec *pb.ChaincodeSpec
 
 // Deploy deploys the supplied chaincode image to the validators through a transaction
 func (d *Devops) Deploy(ctx context.Context, spec *pb.ChaincodeSpec) (*pb.ChaincodeDeploymentSpec, error) {
+	release, err := authorize(ctx, acl.DeployAPI, submitRequest)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
 	// get the deployment spec
 	chaincodeDeploymentSpec, err := d.getChaincodeBytes(ctx, spec)
 
@@ -115,13 +122,95 @@ func (d *Devops) Deploy(ctx context.Context, spec *pb.ChaincodeSpec) (*pb.Chainc
 		return nil, err
 	}
 
-	// Now create the Transactions message and send to Peer.
+	return d.sendDeployTransaction(chaincodeDeploymentSpec)
+}
+
+// Install builds the supplied chaincode package and installs it on this peer only, without
+// deploying it. The name of the returned spec is the one under which the chaincode can then
+// be instantiated.
+func (d *Devops) Install(ctx context.Context, spec *pb.ChaincodeSpec) (*pb.ChaincodeDeploymentSpec, error) {
+	if viper.GetString("chaincode.mode") == chaincode.DevModeUserRunsChaincode {
+		return nil, fmt.Errorf("chaincodes are not installed in development mode")
+	}
+	if err := acl.Check(ctx, acl.DeployAPI); err != nil {
+		return nil, err
+	}
+	chaincodeDeploymentSpec, err := d.getChaincodeBytes(ctx, spec)
+	if err != nil {
+		devopsLogger.Error(fmt.Sprintf("Error installing chaincode spec: %v\n\n error: %s", spec, err))
+		return nil, err
+	}
+	if err = chaincode.InstallChaincode(chaincodeDeploymentSpec); err != nil {
+		return nil, err
+	}
+	return &pb.ChaincodeDeploymentSpec{ChaincodeSpec: chaincodeDeploymentSpec.ChaincodeSpec, ExecEnv: chaincodeDeploymentSpec.ExecEnv}, nil
+}
+
+// Instantiate deploys, through a transaction, the chaincode installed under the name of the
+// supplied spec. The transaction does not carry the code package: each validating peer uses
+// the package installed on it. The endorsement policy of the spec is recorded on the ledger
+// with the instantiation.
+func (d *Devops) Instantiate(ctx context.Context, cds *pb.ChaincodeDeploymentSpec) (*pb.ChaincodeDeploymentSpec, error) {
+	spec := cds.ChaincodeSpec
+	if spec == nil || spec.ChaincodeID == nil || spec.ChaincodeID.Name == "" {
+		return nil, fmt.Errorf("name of the installed chaincode not given for instantiate")
+	}
+	release, err := authorize(ctx, acl.DeployAPI, submitRequest)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	installed, err := chaincode.GetInstalledChaincode(spec.ChaincodeID.Name)
+	if err != nil {
+		return nil, err
+	}
+	if installed == nil {
+		return nil, fmt.Errorf("chaincode %s is not installed on this peer", spec.ChaincodeID.Name)
+	}
+	spec.Type = installed.ChaincodeSpec.Type
+	spec.ChaincodeID.Path = installed.ChaincodeSpec.ChaincodeID.Path
+	chaincodeDeploymentSpec := &pb.ChaincodeDeploymentSpec{ChaincodeSpec: spec, ExecEnv: installed.ExecEnv, EndorsementPolicy: cds.EndorsementPolicy, StatePeers: cds.StatePeers}
+
+	return d.sendDeployTransaction(chaincodeDeploymentSpec)
+}
+
+// sendDeployTransaction creates the deploy transaction of the chaincode, named after it, and
+// sends it to the validators
+func (d *Devops) sendDeployTransaction(chaincodeDeploymentSpec *pb.ChaincodeDeploymentSpec) (*pb.ChaincodeDeploymentSpec, error) {
+	tx, err := d.newDeployTransaction(chaincodeDeploymentSpec)
+	if err != nil {
+		return nil, err
+	}
+
+	if devopsLogger.IsEnabledFor(logging.DEBUG) {
+		devopsLogger.Debug("Sending deploy transaction (%s) to validator", tx.Uuid)
+	}
+	resp := d.coord.ExecuteTransaction(tx)
+	if resp.Status == pb.Response_FAILURE {
+		err = fmt.Errorf(string(resp.Msg))
+	}
+
+	return chaincodeDeploymentSpec, err
+}
+
+// newDeployTransaction creates the deploy transaction of the chaincode, whose uuid is the
+// name of the chaincode
+func (d *Devops) newDeployTransaction(chaincodeDeploymentSpec *pb.ChaincodeDeploymentSpec) (*pb.Transaction, error) {
+	spec := chaincodeDeploymentSpec.ChaincodeSpec
+
+	// Now create the Transactions message.
 
 	transID := chaincodeDeploymentSpec.ChaincodeSpec.ChaincodeID.Name
 
+	var err error
+
 	var tx *pb.Transaction
 	var sec crypto.Client
 
+	if len(chaincodeDeploymentSpec.StatePeers) > 0 && !peer.SecurityEnabled() {
+		return nil, fmt.Errorf("the state of a chaincode can only be encrypted with security enabled")
+	}
+
 	if peer.SecurityEnabled() {
 		if devopsLogger.IsEnabledFor(logging.DEBUG) {
 			devopsLogger.Debug("Initializing secure devops using context %s", spec.SecureContext)
@@ -136,6 +225,14 @@ func (d *Devops) Deploy(ctx context.Context, spec *pb.ChaincodeSpec) (*pb.Chainc
 			return nil, err
 		}
 
+		if len(chaincodeDeploymentSpec.StatePeers) > 0 {
+			// wrap the key of the state for the peers authorized to read it
+			chaincodeDeploymentSpec.StateKeys, err = sec.NewChaincodeStateKeys(chaincodeDeploymentSpec.StatePeers)
+			if err != nil {
+				return nil, fmt.Errorf("Error wrapping the state key of the chaincode: %s", err)
+			}
+		}
+
 		if devopsLogger.IsEnabledFor(logging.DEBUG) {
 			devopsLogger.Debug("Creating secure transaction %s", transID)
 		}
@@ -152,13 +249,69 @@ func (d *Devops) Deploy(ctx context.Context, spec *pb.ChaincodeSpec) (*pb.Chainc
 			return nil, fmt.Errorf("Error deploying chaincode: %s ", err)
 		}
 	}
+	return tx, nil
+}
+
+// Upgrade replaces the code of the chaincode deployed under spec's name with
+// the supplied package, or the one installed under spec's version if no path
+// is given, through a transaction. The chaincode keeps its state and its new
+// code is given the chance to migrate it.
+func (d *Devops) Upgrade(ctx context.Context, spec *pb.ChaincodeSpec) (*pb.ChaincodeDeploymentSpec, error) {
+	if spec.ChaincodeID == nil || spec.ChaincodeID.Name == "" {
+		return nil, fmt.Errorf("name of the chaincode to upgrade not given")
+	}
+	if peer.SecurityEnabled() {
+		return nil, fmt.Errorf("chaincode upgrade is not supported with security enabled")
+	}
+	release, err := authorize(ctx, acl.DeployAPI, submitRequest)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	name := spec.ChaincodeID.Name
+
+	var chaincodeDeploymentSpec *pb.ChaincodeDeploymentSpec
+	if spec.ChaincodeID.Path == "" && spec.ChaincodeID.Version != "" {
+		// upgrade to the chaincode installed under the version, without sending its code package
+		installed, err := chaincode.GetInstalledChaincode(spec.ChaincodeID.Version)
+		if err != nil {
+			return nil, err
+		}
+		if installed == nil {
+			return nil, fmt.Errorf("chaincode %s is not installed on this peer", spec.ChaincodeID.Version)
+		}
+		spec.Type = installed.ChaincodeSpec.Type
+		spec.ChaincodeID.Path = installed.ChaincodeSpec.ChaincodeID.Path
+		chaincodeDeploymentSpec = &pb.ChaincodeDeploymentSpec{ChaincodeSpec: spec, ExecEnv: installed.ExecEnv}
+	} else {
+		// get the deployment spec, which names the chaincode after its new code
+		chaincodeDeploymentSpec, err = d.getChaincodeBytes(ctx, spec)
+		if err != nil {
+			devopsLogger.Error(fmt.Sprintf("Error upgrading chaincode spec: %v\n\n error: %s", spec, err))
+			return nil, err
+		}
+	}
+	cID := chaincodeDeploymentSpec.ChaincodeSpec.ChaincodeID
+	if cID.Name != name {
+		cID.Version = cID.Name
+		cID.Name = name
+	}
 
+	uuid := util.GenerateUUID()
 	if devopsLogger.IsEnabledFor(logging.DEBUG) {
-		devopsLogger.Debug("Sending deploy transaction (%s) to validator", tx.Uuid)
+		devopsLogger.Debug("Creating upgrade transaction (%s) of %s to %s", uuid, cID.Name, cID.Version)
+	}
+	tx, err := pb.NewChaincodeUpgradeTransaction(chaincodeDeploymentSpec, uuid)
+	if err != nil {
+		return nil, fmt.Errorf("Error upgrading chaincode: %s ", err)
+	}
+
+	if devopsLogger.IsEnabledFor(logging.DEBUG) {
+		devopsLogger.Debug("Sending upgrade transaction (%s) to validator", tx.Uuid)
 	}
 	resp := d.coord.ExecuteTransaction(tx)
 	if resp.Status == pb.Response_FAILURE {
-		err = fmt.Errorf(string(resp.Msg))
+		err = fmt.Errorf("%s", resp.Msg)
 	}
 
 	return chaincodeDeploymentSpec, err
@@ -169,11 +322,19 @@ func (d *Devops) invokeOrQuery(ctx context.Context, chaincodeInvocationSpec *pb.
 	if chaincodeInvocationSpec.ChaincodeSpec.ChaincodeID.Name == "" {
 		return nil, fmt.Errorf("name not given for invoke/query")
 	}
+	api, class := acl.QueryAPI, queryRequest
+	if invoke {
+		api, class = acl.InvokeAPI, submitRequest
+	}
+	release, err := authorize(ctx, api, class)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
 
 	// Now create the Transactions message and send to Peer.
 	uuid := util.GenerateUUID()
 	var transaction *pb.Transaction
-	var err error
 	var sec crypto.Client
 	if peer.SecurityEnabled() {
 		if devopsLogger.IsEnabledFor(logging.DEBUG) {
@@ -251,6 +412,177 @@ func (d *Devops) Query(ctx context.Context, chaincodeInvocationSpec *pb.Chaincod
 	return d.invokeOrQuery(ctx, chaincodeInvocationSpec, false)
 }
 
+// ReadOnlyQuery performs the supplied query on the specified chaincode against
+// the committed state of a validator. No transaction is opened and the query does
+// not go through consensus, the result is signed by the validator that served it.
+func (d *Devops) ReadOnlyQuery(ctx context.Context, chaincodeInvocationSpec *pb.ChaincodeInvocationSpec) (*pb.QueryResponse, error) {
+	if chaincodeInvocationSpec.ChaincodeSpec.ChaincodeID.Name == "" {
+		return nil, fmt.Errorf("name not given for query")
+	}
+	release, err := authorize(ctx, acl.QueryAPI, queryRequest)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	uuid := util.GenerateUUID()
+	var sec crypto.Client
+	if peer.SecurityEnabled() {
+		sec, err = crypto.InitClient(chaincodeInvocationSpec.ChaincodeSpec.SecureContext, nil)
+		defer crypto.CloseClient(sec)
+		// remove the security context since we are no longer need it down stream
+		chaincodeInvocationSpec.ChaincodeSpec.SecureContext = ""
+		if nil != err {
+			return nil, err
+		}
+	}
+	transaction, err := d.createExecTx(chaincodeInvocationSpec, uuid, false, sec)
+	if err != nil {
+		return nil, err
+	}
+	if devopsLogger.IsEnabledFor(logging.DEBUG) {
+		devopsLogger.Debug("Sending read-only query (%s) to validator", transaction.Uuid)
+	}
+	resp := d.coord.ExecuteQuery(transaction)
+	if resp.GetResponse() == nil {
+		return nil, fmt.Errorf("no response to query %s", transaction.Uuid)
+	}
+	if resp.Response.Status == pb.Response_FAILURE {
+		return resp, fmt.Errorf("%s", resp.Response.Msg)
+	}
+	if nil != sec && viper.GetBool("security.privacy") {
+		// note the signature of the validator covers the encrypted result
+		if resp.Response.Msg, err = sec.DecryptQueryResult(transaction, resp.Response.Msg); nil != err {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+// SimulateTransaction simulates the supplied invocation of the specified chaincode against the
+// committed state of a validator, as a dry run. The transaction is not submitted, the changes it
+// would make are returned along with the keys it read, and the response and event of the chaincode
+func (d *Devops) SimulateTransaction(ctx context.Context, chaincodeInvocationSpec *pb.ChaincodeInvocationSpec) (*pb.SimulationResult, error) {
+	if chaincodeInvocationSpec.ChaincodeSpec.ChaincodeID.Name == "" {
+		return nil, fmt.Errorf("name not given for simulation")
+	}
+	release, err := authorize(ctx, acl.QueryAPI, queryRequest)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	uuid := util.GenerateUUID()
+	var sec crypto.Client
+	if peer.SecurityEnabled() {
+		sec, err = crypto.InitClient(chaincodeInvocationSpec.ChaincodeSpec.SecureContext, nil)
+		defer crypto.CloseClient(sec)
+		// remove the security context since we are no longer need it down stream
+		chaincodeInvocationSpec.ChaincodeSpec.SecureContext = ""
+		if nil != err {
+			return nil, err
+		}
+	}
+	transaction, err := d.createExecTx(chaincodeInvocationSpec, uuid, true, sec)
+	if err != nil {
+		return nil, err
+	}
+	if devopsLogger.IsEnabledFor(logging.DEBUG) {
+		devopsLogger.Debug("Sending simulation of transaction (%s) to validator", transaction.Uuid)
+	}
+	return d.coord.ExecuteSimulation(transaction)
+}
+
+// SubmitEndorsedTransaction submits for ordering the changes of an invocation simulated and
+// endorsed by validating peers (see SimulateTransaction). The validating peers apply them if the
+// state the invocation read is unchanged when the transaction is executed
+func (d *Devops) SubmitEndorsedTransaction(ctx context.Context, endorsed *pb.EndorsedTransaction) (*pb.Response, error) {
+	if endorsed.ChaincodeID == nil || endorsed.ChaincodeID.Name == "" {
+		return nil, fmt.Errorf("name not given for endorsed transaction")
+	}
+	release, err := authorize(ctx, acl.InvokeAPI, submitRequest)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	transaction, err := pb.NewEndorsedTransaction(endorsed, util.GenerateUUID())
+	if err != nil {
+		return nil, err
+	}
+	if peer.SecurityEnabled() {
+		if err = signTransaction(endorsed.SecureContext, transaction); err != nil {
+			return nil, err
+		}
+	}
+	if devopsLogger.IsEnabledFor(logging.DEBUG) {
+		devopsLogger.Debug("Sending endorsed transaction (%s) to validator", transaction.Uuid)
+	}
+	resp := d.coord.ExecuteTransaction(transaction)
+	if resp.Status == pb.Response_FAILURE {
+		return resp, fmt.Errorf("%s", resp.Msg)
+	}
+	return resp, nil
+}
+
+// UpdateConfig submits for ordering a CONFIG transaction updating the configuration of the
+// network recorded by the ledger, such as the certificate revocation list of a CA. It is
+// authorized as the Admin service is
+func (d *Devops) UpdateConfig(ctx context.Context, update *pb.ConfigUpdate) (*pb.Response, error) {
+	if len(update.RevocationList) == 0 {
+		return nil, fmt.Errorf("Empty config update")
+	}
+	release, err := authorize(ctx, acl.AdminAPI, submitRequest)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	transaction, err := pb.NewConfigTransaction(update, util.GenerateUUID())
+	if err != nil {
+		return nil, err
+	}
+	if peer.SecurityEnabled() {
+		if err = signTransaction(update.SecureContext, transaction); err != nil {
+			return nil, err
+		}
+	}
+	devopsLogger.Info("Sending config transaction (%s) to validator", transaction.Uuid)
+	resp := d.coord.ExecuteTransaction(transaction)
+	if resp.Status == pb.Response_FAILURE {
+		return resp, fmt.Errorf("%s", resp.Msg)
+	}
+	return resp, nil
+}
+
+// authorize checks that the client may call the API, then admits its request
+func authorize(ctx context.Context, api string, class requestClass) (func(), error) {
+	if err := acl.Check(ctx, api); err != nil {
+		return nil, err
+	}
+	return admit(ctx, class)
+}
+
+// signTransaction signs the transaction with the next transaction certificate of the user
+func signTransaction(secureContext string, transaction *pb.Transaction) error {
+	sec, err := crypto.InitClient(secureContext, nil)
+	defer crypto.CloseClient(sec)
+	if nil != err {
+		return err
+	}
+	handler, err := sec.GetTCertificateHandlerNext()
+	if err != nil {
+		return err
+	}
+	transaction.Cert = handler.GetCertificate()
+	raw, err := transaction.SignedBytes()
+	if err != nil {
+		return err
+	}
+	transaction.Signature, err = handler.Sign(raw)
+	return err
+}
+
 // CheckSpec to see if chaincode resides within current package capture for language.
 func CheckSpec(spec *pb.ChaincodeSpec) error {
 	// Don't allow nil value
@@ -0,0 +1,125 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"golang.org/x/net/context"
+
+	"github.com/hyperledger/fabric/core/acl"
+	fabricerrors "github.com/hyperledger/fabric/core/errors"
+	"github.com/hyperledger/fabric/core/ledger"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// readLedger authorizes the query of the ledger and calls read with the ledger of the peer.
+// The errors are returned with the gRPC status code mapped from their code, e.g. NotFound
+// for a block beyond the height of the blockchain
+func readLedger(ctx context.Context, query *pb.LedgerQuery, read func(*ledger.Ledger) error) error {
+	release, err := authorize(ctx, acl.QueryAPI, queryRequest)
+	if err != nil {
+		return err
+	}
+	defer release()
+	peerLedger, err := ledger.GetLedger()
+	if err != nil {
+		return fabricerrors.ToGRPC(err)
+	}
+	return fabricerrors.ToGRPC(read(peerLedger))
+}
+
+// GetBlockchainInfo returns the height and the hashes of the last blocks of the blockchain
+func (d *Devops) GetBlockchainInfo(ctx context.Context, query *pb.LedgerQuery) (info *pb.BlockchainInfo, err error) {
+	err = readLedger(ctx, query, func(peerLedger *ledger.Ledger) error {
+		info, err = peerLedger.GetBlockchainInfo()
+		return err
+	})
+	return info, err
+}
+
+// GetBlockByNumber returns the block of the given number, the genesis block being block zero
+func (d *Devops) GetBlockByNumber(ctx context.Context, query *pb.LedgerQuery) (block *pb.Block, err error) {
+	err = readLedger(ctx, query, func(peerLedger *ledger.Ledger) error {
+		if block, err = peerLedger.GetBlockByNumber(query.BlockNumber); err == ledger.ErrOutOfBounds {
+			return fabricerrors.Errorf(fabricerrors.NotFound, "Block %d not found, the height of the blockchain is %d", query.BlockNumber, peerLedger.GetBlockchainSize())
+		}
+		return err
+	})
+	return block, err
+}
+
+// GetTransactionByUUID returns the committed transaction of the given uuid
+func (d *Devops) GetTransactionByUUID(ctx context.Context, query *pb.LedgerQuery) (tx *pb.Transaction, err error) {
+	err = readLedger(ctx, query, func(peerLedger *ledger.Ledger) error {
+		if tx, err = peerLedger.GetTransactionByUUID(query.Uuid); err == ledger.ErrResourceNotFound {
+			return fabricerrors.Errorf(fabricerrors.NotFound, "Transaction [%s] not found", query.Uuid)
+		}
+		return err
+	})
+	return tx, err
+}
+
+// GetState returns the committed value of the key of the chaincode, as stored in the world
+// state, i.e. encrypted if the chaincode is confidential
+func (d *Devops) GetState(ctx context.Context, query *pb.LedgerQuery) (value *pb.StateValue, err error) {
+	err = readLedger(ctx, query, func(peerLedger *ledger.Ledger) error {
+		stateValue, err := peerLedger.GetState(query.ChaincodeID, query.Key, true)
+		if err != nil {
+			return err
+		}
+		if stateValue == nil {
+			return fabricerrors.Errorf(fabricerrors.KeyNotFound, "Key [%s] of chaincode [%s] not found", query.Key, query.ChaincodeID)
+		}
+		value = &pb.StateValue{Value: stateValue}
+		return nil
+	})
+	return value, err
+}
+
+// GetStateDelta returns the changes made to the world state by the block of the given number,
+// sorted by chaincode and key. The state deltas of the blocks older than the last
+// 'ledger.state.deltaHistorySize' ones, or received by state transfer, are not retained
+func (d *Devops) GetStateDelta(ctx context.Context, query *pb.LedgerQuery) (delta *pb.BlockStateDelta, err error) {
+	err = readLedger(ctx, query, func(peerLedger *ledger.Ledger) error {
+		stateDelta, err := peerLedger.GetStateDelta(query.BlockNumber)
+		if err == ledger.ErrOutOfBounds {
+			return fabricerrors.Errorf(fabricerrors.NotFound, "Block %d not found, the height of the blockchain is %d", query.BlockNumber, peerLedger.GetBlockchainSize())
+		}
+		if err != nil {
+			return err
+		}
+		if stateDelta == nil {
+			return fabricerrors.Errorf(fabricerrors.StateDeltaMissing, "The state delta of block %d is not retained", query.BlockNumber)
+		}
+		delta = ledger.ToBlockStateDelta(query.BlockNumber, stateDelta, "", "")
+		return nil
+	})
+	return delta, err
+}
+
+// GetStateHashDetail returns the crypto-hash of the committed world state along with the
+// crypto-hashes of the children of the root of the state tree
+func (d *Devops) GetStateHashDetail(ctx context.Context, query *pb.LedgerQuery) (detail *pb.StateHashDetail, err error) {
+	err = readLedger(ctx, query, func(peerLedger *ledger.Ledger) error {
+		stateHashDetail, err := peerLedger.GetRootStateHashDetail()
+		if err != nil {
+			return err
+		}
+		detail = ledger.ToStateHashDetail(stateHashDetail)
+		return nil
+	})
+	return detail, err
+}
@@ -0,0 +1,305 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/op/go-logging"
+	"github.com/spf13/viper"
+	"golang.org/x/net/context"
+
+	"github.com/hyperledger/fabric/core/acl"
+	"github.com/hyperledger/fabric/core/crypto"
+	fabricerrors "github.com/hyperledger/fabric/core/errors"
+	"github.com/hyperledger/fabric/core/ledger"
+	"github.com/hyperledger/fabric/core/peer"
+	"github.com/hyperledger/fabric/core/util"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// defaultStatusRetention is used when peer.devops.statusRetention is not set
+const defaultStatusRetention = 10 * time.Minute
+
+// NewDevopsV2Server creates and returns the server of the DevopsV2 service, which builds
+// the transactions the same way as the Devops server.
+func NewDevopsV2Server(devops *Devops) *DevopsV2 {
+	retention := viper.GetDuration("peer.devops.statusRetention")
+	if retention <= 0 {
+		retention = defaultStatusRetention
+	}
+	return &DevopsV2{devops: devops, tracker: newTxTracker(retention)}
+}
+
+// DevopsV2 implementation of the DevopsV2 services. Its Deploy and Invoke send the
+// transaction to the validator in the background and return at once, the client
+// following the transaction with GetTransactionStatus or WatchTransactionStatus.
+type DevopsV2 struct {
+	devops  *Devops
+	tracker *txTracker
+}
+
+// Deploy submits the deploy transaction of the supplied chaincode image
+func (d *DevopsV2) Deploy(ctx context.Context, spec *pb.ChaincodeSpec) (*pb.SubmitResponse, error) {
+	release, err := authorize(ctx, acl.DeployAPI, submitRequest)
+	if err != nil {
+		return nil, err
+	}
+	chaincodeDeploymentSpec, err := d.devops.getChaincodeBytes(ctx, spec)
+	if err != nil {
+		release()
+		devopsLogger.Error(fmt.Sprintf("Error deploying chaincode spec: %v\n\n error: %s", spec, err))
+		return nil, err
+	}
+	tx, err := d.devops.newDeployTransaction(chaincodeDeploymentSpec)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	d.submit(tx, release)
+	return &pb.SubmitResponse{Uuid: tx.Uuid, ChaincodeID: chaincodeDeploymentSpec.ChaincodeSpec.ChaincodeID}, nil
+}
+
+// Invoke submits the invoke transaction of the supplied invocation
+func (d *DevopsV2) Invoke(ctx context.Context, chaincodeInvocationSpec *pb.ChaincodeInvocationSpec) (*pb.SubmitResponse, error) {
+	spec := chaincodeInvocationSpec.ChaincodeSpec
+	if spec == nil || spec.ChaincodeID == nil || spec.ChaincodeID.Name == "" {
+		return nil, fmt.Errorf("name not given for invoke")
+	}
+	release, err := authorize(ctx, acl.InvokeAPI, submitRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	uuid := util.GenerateUUID()
+	var sec crypto.Client
+	if peer.SecurityEnabled() {
+		if devopsLogger.IsEnabledFor(logging.DEBUG) {
+			devopsLogger.Debug("Initializing secure devops using context %s", spec.SecureContext)
+		}
+		sec, err = crypto.InitClient(spec.SecureContext, nil)
+		defer crypto.CloseClient(sec)
+		// remove the security context since we are no longer need it down stream
+		spec.SecureContext = ""
+		if nil != err {
+			release()
+			return nil, err
+		}
+	}
+	transaction, err := d.devops.createExecTx(chaincodeInvocationSpec, uuid, true, sec)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	d.submit(transaction, release)
+	return &pb.SubmitResponse{Uuid: transaction.Uuid}, nil
+}
+
+// submit tracks the transaction as pending and sends it to the validator in the
+// background, then calls release
+func (d *DevopsV2) submit(tx *pb.Transaction, release func()) {
+	d.tracker.submitted(tx.Uuid)
+	go func() {
+		defer release()
+		if devopsLogger.IsEnabledFor(logging.DEBUG) {
+			devopsLogger.Debug("Sending transaction (%s) to validator", tx.Uuid)
+		}
+		resp := d.devops.coord.ExecuteTransaction(tx)
+		if resp.Status == pb.Response_FAILURE {
+			devopsLogger.Warning("Transaction (%s) rejected: %s", tx.Uuid, resp.Msg)
+			d.tracker.rejected(tx.Uuid, string(resp.Msg))
+		}
+	}()
+}
+
+// GetTransactionStatus returns the status of the transaction of the query's uuid
+func (d *DevopsV2) GetTransactionStatus(ctx context.Context, query *pb.LedgerQuery) (*pb.TransactionStatus, error) {
+	if query.Uuid == "" {
+		return nil, fabricerrors.ToGRPC(fabricerrors.Errorf(fabricerrors.InvalidArgument, "uuid of the transaction not given"))
+	}
+	release, err := authorize(ctx, acl.QueryAPI, queryRequest)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	status, _, err := d.transactionStatus(query.Uuid)
+	return status, fabricerrors.ToGRPC(err)
+}
+
+// WatchTransactionStatus streams the status of the transaction of the query's uuid, then
+// each of its changes, until the transaction is rejected or committed or the client
+// cancels the call. The status of a transaction unknown to the peer is streamed once it is
+// committed, e.g. if it was submitted through another peer.
+func (d *DevopsV2) WatchTransactionStatus(query *pb.LedgerQuery, stream pb.DevopsV2_WatchTransactionStatusServer) error {
+	if query.Uuid == "" {
+		return fabricerrors.ToGRPC(fabricerrors.Errorf(fabricerrors.InvalidArgument, "uuid of the transaction not given"))
+	}
+	// the watch is admitted as one query, it is not counted as in flight while waiting
+	release, err := authorize(stream.Context(), acl.QueryAPI, queryRequest)
+	if err != nil {
+		return err
+	}
+	release()
+	peerLedger, err := ledger.GetLedger()
+	if err != nil {
+		return fabricerrors.ToGRPC(err)
+	}
+
+	var last *pb.TransactionStatus
+	for {
+		committed := peerLedger.NextBlockCommitted()
+		status, rejected, err := d.transactionStatus(query.Uuid)
+		if err != nil {
+			return fabricerrors.ToGRPC(err)
+		}
+		if last == nil || status.Status != last.Status {
+			if err := stream.Send(status); err != nil {
+				return err
+			}
+			last = status
+		}
+		if isFinalTxStatus(status.Status) {
+			return nil
+		}
+		select {
+		case <-committed:
+		case <-rejected:
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		}
+	}
+}
+
+// transactionStatus returns the status of the transaction, read from the ledger once it is
+// committed. For a pending transaction it also returns a channel closed if the transaction
+// is rejected
+func (d *DevopsV2) transactionStatus(uuid string) (*pb.TransactionStatus, <-chan struct{}, error) {
+	peerLedger, err := ledger.GetLedger()
+	if err != nil {
+		return nil, nil, err
+	}
+	receipt, err := peerLedger.GetTransactionResult(uuid)
+	if err == ledger.ErrResourceNotFound {
+		status, rejected := d.tracker.status(uuid)
+		return status, rejected, nil
+	}
+	if err != nil {
+		return nil, nil, fabricerrors.Errorf(fabricerrors.CodeOf(err), "Error retrieving the result of transaction [%s]: %s", uuid, err)
+	}
+	d.tracker.committed(uuid)
+	return toTransactionStatus(receipt), nil, nil
+}
+
+// toTransactionStatus returns the status of a committed transaction
+func toTransactionStatus(receipt *ledger.TransactionReceipt) *pb.TransactionStatus {
+	status := &pb.TransactionStatus{
+		Uuid:        receipt.TxUUID,
+		Status:      pb.TransactionStatus_COMMITTED,
+		BlockNumber: receipt.BlockNumber,
+		TxIndex:     receipt.TxIndex,
+		ErrorCode:   receipt.ErrorCode,
+		Error:       receipt.Error,
+	}
+	switch receipt.Status {
+	case ledger.TxStatusSucceeded:
+		status.Status = pb.TransactionStatus_SUCCEEDED
+	case ledger.TxStatusFailed:
+		status.Status = pb.TransactionStatus_FAILED
+	}
+	return status
+}
+
+// isFinalTxStatus returns whether the status of a transaction can no longer change
+func isFinalTxStatus(status pb.TransactionStatus_StatusCode) bool {
+	return status != pb.TransactionStatus_UNKNOWN && status != pb.TransactionStatus_PENDING
+}
+
+// txTracker keeps the status of the transactions submitted through the peer until they are
+// committed, or for retention at most: a transaction pending for longer, e.g. lost in a
+// view change, becomes unknown again
+type txTracker struct {
+	sync.Mutex
+	retention time.Duration
+	txs       map[string]*trackedTx
+	lastPrune time.Time
+	now       func() time.Time
+}
+
+type trackedTx struct {
+	submitted time.Time
+	status    pb.TransactionStatus_StatusCode
+	err       string
+	// rejected is closed when the transaction is rejected
+	rejected chan struct{}
+}
+
+func newTxTracker(retention time.Duration) *txTracker {
+	return &txTracker{retention: retention, txs: make(map[string]*trackedTx), now: time.Now}
+}
+
+// submitted tracks the transaction as pending, and drops the expired transactions at
+// most once per retention
+func (t *txTracker) submitted(uuid string) {
+	t.Lock()
+	defer t.Unlock()
+	now := t.now()
+	if now.Sub(t.lastPrune) >= t.retention {
+		for id, tx := range t.txs {
+			if now.Sub(tx.submitted) >= t.retention {
+				delete(t.txs, id)
+			}
+		}
+		t.lastPrune = now
+	}
+	t.txs[uuid] = &trackedTx{submitted: now, status: pb.TransactionStatus_PENDING, rejected: make(chan struct{})}
+}
+
+// rejected records that the validator did not accept the transaction
+func (t *txTracker) rejected(uuid string, msg string) {
+	t.Lock()
+	defer t.Unlock()
+	if tx, ok := t.txs[uuid]; ok && tx.status == pb.TransactionStatus_PENDING {
+		tx.status = pb.TransactionStatus_REJECTED
+		tx.err = msg
+		close(tx.rejected)
+	}
+}
+
+// committed stops tracking the transaction, whose status is now read from the ledger
+func (t *txTracker) committed(uuid string) {
+	t.Lock()
+	defer t.Unlock()
+	delete(t.txs, uuid)
+}
+
+// status returns the status of an uncommitted transaction and, if it is pending, the
+// channel closed when it is rejected
+func (t *txTracker) status(uuid string) (*pb.TransactionStatus, <-chan struct{}) {
+	t.Lock()
+	defer t.Unlock()
+	tx, ok := t.txs[uuid]
+	if !ok || t.now().Sub(tx.submitted) >= t.retention {
+		return &pb.TransactionStatus{Uuid: uuid, Status: pb.TransactionStatus_UNKNOWN}, nil
+	}
+	status := &pb.TransactionStatus{Uuid: uuid, Status: tx.status, Error: tx.err}
+	if tx.status == pb.TransactionStatus_PENDING {
+		return status, tx.rejected
+	}
+	return status, nil
+}
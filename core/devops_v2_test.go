@@ -0,0 +1,81 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric/core/ledger"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+func TestTxTracker(t *testing.T) {
+	now := time.Unix(1000, 0)
+	tracker := newTxTracker(time.Minute)
+	tracker.now = func() time.Time { return now }
+
+	tracker.submitted("tx1")
+	tracker.submitted("tx2")
+	status, rejected := tracker.status("tx1")
+	if status.Status != pb.TransactionStatus_PENDING || rejected == nil {
+		t.Fatalf("Expected tx1 to be pending, got %s", status)
+	}
+
+	tracker.rejected("tx1", "no validator")
+	select {
+	case <-rejected:
+	default:
+		t.Fatal("Expected the channel of tx1 to be closed when it is rejected")
+	}
+	status, rejected = tracker.status("tx1")
+	if status.Status != pb.TransactionStatus_REJECTED || status.Error != "no validator" || rejected != nil {
+		t.Fatalf("Expected tx1 to be rejected, got %s", status)
+	}
+
+	tracker.committed("tx2")
+	if status, _ = tracker.status("tx2"); status.Status != pb.TransactionStatus_UNKNOWN {
+		t.Fatalf("Expected tx2 to be no longer tracked once committed, got %s", status)
+	}
+
+	now = now.Add(time.Minute)
+	if status, _ = tracker.status("tx1"); status.Status != pb.TransactionStatus_UNKNOWN {
+		t.Fatalf("Expected tx1 to be unknown after the retention, got %s", status)
+	}
+	tracker.submitted("tx3")
+	if _, ok := tracker.txs["tx1"]; ok {
+		t.Fatal("Expected tx1 to be dropped once expired")
+	}
+	if status, _ = tracker.status("tx3"); status.Status != pb.TransactionStatus_PENDING {
+		t.Fatalf("Expected tx3 to be pending, got %s", status)
+	}
+}
+
+func TestToTransactionStatus(t *testing.T) {
+	receipt := &ledger.TransactionReceipt{TxUUID: "tx1", BlockNumber: 3, TxIndex: 1, Status: ledger.TxStatusFailed, ErrorCode: 1, Error: "failed"}
+	status := toTransactionStatus(receipt)
+	if status.Status != pb.TransactionStatus_FAILED || status.BlockNumber != 3 || status.TxIndex != 1 || status.ErrorCode != 1 || status.Error != "failed" {
+		t.Fatalf("Unexpected status of a failed transaction: %s", status)
+	}
+	receipt.Status = ledger.TxStatusUnknown
+	if status = toTransactionStatus(receipt); status.Status != pb.TransactionStatus_COMMITTED {
+		t.Fatalf("Expected a transaction of unknown outcome to be committed, got %s", status)
+	}
+	if !isFinalTxStatus(pb.TransactionStatus_COMMITTED) || isFinalTxStatus(pb.TransactionStatus_PENDING) {
+		t.Fatal("Expected only the committed and rejected transactions to be final")
+	}
+}
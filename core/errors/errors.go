@@ -0,0 +1,158 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package errors defines the codes by which the errors of the state, ledger and db packages
+// are told apart, and maps them to gRPC status codes so that the clients of the peer can
+// branch on the code of an error instead of its message
+package errors
+
+import (
+	"fmt"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+// Code is the type of an error. The codes shared with the ledger have the strings of the
+// ledger.ErrorType values
+type Code string
+
+const (
+	// Unknown is the code of the errors that carry none
+	Unknown = Code("Unknown")
+	// InvalidArgument is the code of an invalid input
+	InvalidArgument = Code("InvalidArgument")
+	// NotFound is the code of a resource, such as a block or a transaction, that is not found
+	NotFound = Code("ResourceNotFound")
+	// KeyNotFound is the code of a key that has no value in the state
+	KeyNotFound = Code("KeyNotFound")
+	// OutOfBounds is the code of a request beyond the blockchain
+	OutOfBounds = Code("OutOfBounds")
+	// TxInProgress is the code of a call made while another transaction or
+	// transaction-batch is in progress
+	TxInProgress = Code("TxInProgress")
+	// StateDeltaMissing is the code of a state delta that is no longer retained
+	StateDeltaMissing = Code("StateDeltaMissing")
+	// DBUnavailable is the code of a failed read or write of the DB
+	DBUnavailable = Code("DBUnavailable")
+	// DataCorrupted is the code of data of the DB that cannot be decoded or fails its checksum
+	DataCorrupted = Code("DataCorrupted")
+	// NotSupported is the code of an operation that the configured implementation does not support
+	NotSupported = Code("NotSupported")
+	// NotReady is the code of a resource that is still being built
+	NotReady = Code("NotReady")
+	// DiskQuotaExceeded is the code of a write refused because the DB exceeds its hard disk quota
+	DiskQuotaExceeded = Code("DiskQuotaExceeded")
+	// ValidationRuleViolated is the code of a transaction that violates a ledger validation rule
+	ValidationRuleViolated = Code("ValidationRuleViolated")
+	// StaleRead is the code of a simulated transaction whose read state has changed
+	StaleRead = Code("StaleRead")
+	// DuplicateTransaction is the code of a transaction whose uuid was already used
+	DuplicateTransaction = Code("DuplicateTransaction")
+)
+
+// Error is an error with a code
+type Error struct {
+	code Code
+	msg  string
+}
+
+// New returns an error with the code and the message
+func New(code Code, msg string) *Error {
+	return &Error{code, msg}
+}
+
+// Errorf returns an error with the code and the message formatted as by fmt.Sprintf
+func Errorf(code Code, format string, args ...interface{}) *Error {
+	return &Error{code, fmt.Sprintf(format, args...)}
+}
+
+func (e *Error) Error() string {
+	return e.msg
+}
+
+// Code returns the code of the error
+func (e *Error) Code() Code {
+	return e.code
+}
+
+var (
+	// ErrKeyNotFound is returned if a key has no value in the state
+	ErrKeyNotFound = New(KeyNotFound, "key not found")
+	// ErrTxInProgress is returned if a transaction is already in progress
+	ErrTxInProgress = New(TxInProgress, "a transaction is already in progress")
+	// ErrStateDeltaMissing is returned if a state delta is no longer retained
+	ErrStateDeltaMissing = New(StateDeltaMissing, "state delta not retained")
+	// ErrDBUnavailable is returned if the DB cannot be read or written
+	ErrDBUnavailable = New(DBUnavailable, "db unavailable")
+)
+
+// coder is implemented by the errors with a code, including the ones of the ledger
+type coder interface {
+	Code() Code
+}
+
+// CodeOf returns the code of err, Unknown if it carries none
+func CodeOf(err error) Code {
+	if coded, ok := err.(coder); ok {
+		return coded.Code()
+	}
+	return Unknown
+}
+
+// Is returns whether err has the code
+func Is(err error, code Code) bool {
+	return err != nil && CodeOf(err) == code
+}
+
+var grpcCodes = map[Code]codes.Code{
+	InvalidArgument:        codes.InvalidArgument,
+	NotFound:               codes.NotFound,
+	KeyNotFound:            codes.NotFound,
+	StateDeltaMissing:      codes.NotFound,
+	OutOfBounds:            codes.OutOfRange,
+	TxInProgress:           codes.FailedPrecondition,
+	ValidationRuleViolated: codes.FailedPrecondition,
+	DBUnavailable:          codes.Unavailable,
+	NotReady:               codes.Unavailable,
+	NotSupported:           codes.Unimplemented,
+	DiskQuotaExceeded:      codes.ResourceExhausted,
+	StaleRead:              codes.Aborted,
+	DuplicateTransaction:   codes.AlreadyExists,
+	DataCorrupted:          codes.DataLoss,
+}
+
+// GRPCCode returns the gRPC status code of the errors with the code
+func GRPCCode(code Code) codes.Code {
+	if grpcCode, ok := grpcCodes[code]; ok {
+		return grpcCode
+	}
+	return codes.Unknown
+}
+
+// ToGRPC returns err as the error of a gRPC call, with the status code mapped from its code.
+// The errors without a code, including the ones already carrying a gRPC status code, are
+// returned as they are
+func ToGRPC(err error) error {
+	if err == nil {
+		return nil
+	}
+	coded, ok := err.(coder)
+	if !ok {
+		return err
+	}
+	return grpc.Errorf(GRPCCode(coded.Code()), "%s", err.Error())
+}
@@ -0,0 +1,67 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package errors
+
+import (
+	"fmt"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+func TestCodeOf(t *testing.T) {
+	err := Errorf(StateDeltaMissing, "The state delta of block [%d] is not retained", 3)
+	if err.Error() != "The state delta of block [3] is not retained" {
+		t.Fatalf("Unexpected message [%s]", err)
+	}
+	if CodeOf(err) != StateDeltaMissing || !Is(err, StateDeltaMissing) {
+		t.Fatalf("Expected code [%s], got [%s]", StateDeltaMissing, CodeOf(err))
+	}
+	if CodeOf(fmt.Errorf("no code")) != Unknown {
+		t.Fatal("Expected an error without a code to be of code Unknown")
+	}
+	if Is(nil, Unknown) {
+		t.Fatal("Expected no code for a nil error")
+	}
+}
+
+func TestToGRPC(t *testing.T) {
+	if ToGRPC(nil) != nil {
+		t.Fatal("Expected nil for a nil error")
+	}
+	for code, expected := range map[Code]codes.Code{
+		KeyNotFound:          codes.NotFound,
+		TxInProgress:         codes.FailedPrecondition,
+		DBUnavailable:        codes.Unavailable,
+		OutOfBounds:          codes.OutOfRange,
+		DuplicateTransaction: codes.AlreadyExists,
+		Unknown:              codes.Unknown,
+	} {
+		err := ToGRPC(New(code, "message"))
+		if grpc.Code(err) != expected {
+			t.Fatalf("Expected code [%s] to map to gRPC code [%s], got [%s]", code, expected, grpc.Code(err))
+		}
+		if grpc.ErrorDesc(err) != "message" {
+			t.Fatalf("Unexpected description [%s]", grpc.ErrorDesc(err))
+		}
+	}
+	plain := fmt.Errorf("no code")
+	if ToGRPC(plain) != plain {
+		t.Fatal("Expected an error without a code to be returned as it is")
+	}
+}
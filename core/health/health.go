@@ -0,0 +1,189 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package health serves the state of the peer to the Kubernetes probes and the load
+// balancers. The liveness checks (/healthz) fail when restarting the peer is the remedy,
+// e.g. a DB that stopped taking writes or a commit that does not progress. The readiness
+// checks (/readyz) also fail while the peer cannot serve transactions, e.g. when it is not
+// connected to enough validating peers.
+package health
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/op/go-logging"
+)
+
+var logger = logging.MustGetLogger("health")
+
+// Check returns an error describing why the peer is not healthy, nil if it is
+type Check func() error
+
+// CheckTimeout is the time after which a check that has not returned fails
+var CheckTimeout = 5 * time.Second
+
+// Registry holds the liveness and the readiness checks
+type Registry struct {
+	sync.RWMutex
+	liveness  map[string]Check
+	readiness map[string]Check
+}
+
+// NewRegistry returns an empty registry
+func NewRegistry() *Registry {
+	return &Registry{liveness: make(map[string]Check), readiness: make(map[string]Check)}
+}
+
+// DefaultRegistry holds the checks of the peer
+var DefaultRegistry = NewRegistry()
+
+// RegisterLiveness adds a check that fails when the peer has to be restarted. The
+// liveness checks are also readiness checks
+func (r *Registry) RegisterLiveness(name string, check Check) {
+	r.Lock()
+	defer r.Unlock()
+	r.liveness[name] = check
+}
+
+// RegisterReadiness adds a check that fails while the peer cannot serve transactions
+func (r *Registry) RegisterReadiness(name string, check Check) {
+	r.Lock()
+	defer r.Unlock()
+	r.readiness[name] = check
+}
+
+// RegisterLiveness adds a liveness check to DefaultRegistry
+func RegisterLiveness(name string, check Check) {
+	DefaultRegistry.RegisterLiveness(name, check)
+}
+
+// RegisterReadiness adds a readiness check to DefaultRegistry
+func RegisterReadiness(name string, check Check) {
+	DefaultRegistry.RegisterReadiness(name, check)
+}
+
+// Status is the result of the checks, "OK" for the checks that passed
+type Status struct {
+	Healthy bool              `json:"healthy"`
+	Checks  map[string]string `json:"checks"`
+}
+
+// Liveness runs the liveness checks
+func (r *Registry) Liveness() *Status {
+	return run(r.checks(false))
+}
+
+// Readiness runs the liveness and the readiness checks
+func (r *Registry) Readiness() *Status {
+	return run(r.checks(true))
+}
+
+func (r *Registry) checks(readiness bool) map[string]Check {
+	r.RLock()
+	defer r.RUnlock()
+	checks := make(map[string]Check)
+	for name, check := range r.liveness {
+		checks[name] = check
+	}
+	if readiness {
+		for name, check := range r.readiness {
+			checks[name] = check
+		}
+	}
+	return checks
+}
+
+// run runs the checks concurrently, as a check that hangs is the symptom of an unhealthy
+// peer rather than a reason for the probe to time out
+func run(checks map[string]Check) *Status {
+	type result struct {
+		name string
+		err  error
+	}
+	results := make(chan result, len(checks))
+	for name, check := range checks {
+		go func(name string, check Check) {
+			results <- result{name, check()}
+		}(name, check)
+	}
+	status := &Status{Healthy: true, Checks: make(map[string]string)}
+	timeout := time.After(CheckTimeout)
+	for len(status.Checks) < len(checks) {
+		select {
+		case res := <-results:
+			status.Checks[res.name] = "OK"
+			if res.err != nil {
+				status.Checks[res.name] = res.err.Error()
+			}
+		case <-timeout:
+			for name := range checks {
+				if _, done := status.Checks[name]; !done {
+					status.Checks[name] = fmt.Sprintf("No result after %s", CheckTimeout)
+				}
+			}
+		}
+	}
+	var failed []string
+	for name, message := range status.Checks {
+		if message != "OK" {
+			failed = append(failed, name)
+		}
+	}
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		status.Healthy = false
+		logger.Warning("Health checks %v failed: %v", failed, status.Checks)
+	}
+	return status
+}
+
+// LivenessHandler serves the result of the liveness checks, with the status 200 if they
+// pass and 503 otherwise
+func (r *Registry) LivenessHandler() http.Handler {
+	return statusHandler(r.Liveness)
+}
+
+// ReadinessHandler serves the result of the liveness and readiness checks, with the
+// status 200 if they pass and 503 otherwise
+func (r *Registry) ReadinessHandler() http.Handler {
+	return statusHandler(r.Readiness)
+}
+
+func statusHandler(run func() *Status) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		status := run()
+		rw.Header().Set("Content-Type", "application/json")
+		if status.Healthy {
+			rw.WriteHeader(http.StatusOK)
+		} else {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+		}
+		if err := json.NewEncoder(rw).Encode(status); err != nil {
+			logger.Error("Error encoding the health status: %s", err)
+		}
+	})
+}
+
+// Register adds the /healthz and /readyz endpoints of DefaultRegistry to the mux
+func Register(mux *http.ServeMux) {
+	mux.Handle("/healthz", DefaultRegistry.LivenessHandler())
+	mux.Handle("/readyz", DefaultRegistry.ReadinessHandler())
+}
@@ -0,0 +1,78 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package health
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLivenessAndReadiness(t *testing.T) {
+	registry := NewRegistry()
+	registry.RegisterLiveness("db", func() error { return nil })
+	registry.RegisterReadiness("consensus", func() error { return errors.New("Connected to 0 validating peers") })
+
+	if status := registry.Liveness(); !status.Healthy || len(status.Checks) != 1 || status.Checks["db"] != "OK" {
+		t.Fatalf("Expected the liveness checks to pass, got %v", status)
+	}
+	status := registry.Readiness()
+	if status.Healthy || status.Checks["db"] != "OK" || status.Checks["consensus"] != "Connected to 0 validating peers" {
+		t.Fatalf("Expected the readiness checks to fail on consensus, got %v", status)
+	}
+}
+
+func TestCheckTimeout(t *testing.T) {
+	defer func(timeout time.Duration) { CheckTimeout = timeout }(CheckTimeout)
+	CheckTimeout = 10 * time.Millisecond
+	registry := NewRegistry()
+	registry.RegisterLiveness("commit", func() error {
+		time.Sleep(time.Second)
+		return nil
+	})
+	if status := registry.Liveness(); status.Healthy {
+		t.Fatalf("Expected a check that hangs to fail, got %v", status)
+	}
+}
+
+func TestHandlers(t *testing.T) {
+	registry := NewRegistry()
+	registry.RegisterLiveness("db", func() error { return nil })
+	registry.RegisterReadiness("eventhub", func() error { return errors.New("stalled") })
+	req, err := http.NewRequest("GET", "/readyz", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+	registry.LivenessHandler().ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", recorder.Code)
+	}
+	recorder = httptest.NewRecorder()
+	registry.ReadinessHandler().ServeHTTP(recorder, req)
+	var status Status
+	if err := json.Unmarshal(recorder.Body.Bytes(), &status); err != nil {
+		t.Fatalf("Error decoding the status: %s", err)
+	}
+	if recorder.Code != http.StatusServiceUnavailable || status.Healthy || status.Checks["eventhub"] != "stalled" {
+		t.Fatalf("Expected status 503 with the failed check, got %d %v", recorder.Code, status)
+	}
+}
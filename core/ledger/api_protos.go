@@ -0,0 +1,60 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ledger
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+	"github.com/hyperledger/fabric/protos"
+)
+
+// ToBlockStateDelta returns the changes the state delta of the block made to the keys of
+// the chaincode starting with keyPrefix, sorted by chaincode and key. All the chaincodes
+// are included if chaincodeID is empty
+func ToBlockStateDelta(blockNumber uint64, delta *statemgmt.StateDelta, chaincodeID, keyPrefix string) *protos.BlockStateDelta {
+	blockStateDelta := &protos.BlockStateDelta{BlockNumber: blockNumber}
+	for _, updatedChaincodeID := range delta.GetUpdatedChaincodeIds(true) {
+		if chaincodeID != "" && updatedChaincodeID != chaincodeID {
+			continue
+		}
+		updates := delta.GetUpdates(updatedChaincodeID)
+		keys := make([]string, 0, len(updates))
+		for key := range updates {
+			if strings.HasPrefix(key, keyPrefix) {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			updatedValue := updates[key]
+			blockStateDelta.KeyValues = append(blockStateDelta.KeyValues, &protos.StateKeyValue{ChaincodeID: updatedChaincodeID, Key: key, Value: updatedValue.GetValue(), Deleted: updatedValue.IsDelete()})
+		}
+	}
+	return blockStateDelta
+}
+
+// ToStateHashDetail returns the crypto-hash of the state along with the crypto-hashes of
+// the children of the root of the state tree, as served to the clients
+func ToStateHashDetail(detail *statemgmt.StateHashDetail) *protos.StateHashDetail {
+	stateHashDetail := &protos.StateHashDetail{RootHash: detail.RootHash}
+	for _, child := range detail.Children {
+		stateHashDetail.Children = append(stateHashDetail.Children, &protos.StateHashChild{Index: uint32(child.Index), Hash: child.Hash})
+	}
+	return stateHashDetail
+}
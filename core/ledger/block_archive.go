@@ -0,0 +1,326 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ledger
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/hyperledger/fabric/core/db/kvstore"
+	"github.com/hyperledger/fabric/protos"
+	"github.com/spf13/viper"
+	"github.com/tecbot/gorocksdb"
+)
+
+// BlockArchive is a cold storage for the bodies of old blocks. Once a block is
+// archived only its header (everything but the transactions and their results)
+// and its hash are kept in the blockchain column family; the complete block is
+// fetched back from the archive by GetBlockByNumber. When the DB is encrypted
+// (see 'peer.db.encryption'), the blocks are given to the archive encrypted with
+// the key of the DB.
+type BlockArchive interface {
+	// Put stores the serialized block. Storing a block twice must overwrite the first copy.
+	Put(blockNumber uint64, blockBytes []byte) error
+	// Get returns the serialized block stored by Put
+	Get(blockNumber uint64) ([]byte, error)
+}
+
+// BlockArchiveFactory creates the archive of the ledger of the given chain. It
+// is expected to read its own settings from 'ledger.blockchain.archive.<type>'.
+type BlockArchiveFactory func(chainID string) (BlockArchive, error)
+
+var blockArchivesLock sync.RWMutex
+var blockArchives = map[string]BlockArchiveFactory{
+	"filesystem": newFileSystemBlockArchiveFromConfig,
+}
+
+// RegisterBlockArchive makes an archive type available to the
+// 'ledger.blockchain.archive.type' setting. Archives which depend on libraries
+// that are not vendored by default (e.g. an S3 compatible object store) can be
+// plugged in this way.
+func RegisterBlockArchive(archiveType string, factory BlockArchiveFactory) {
+	blockArchivesLock.Lock()
+	defer blockArchivesLock.Unlock()
+	blockArchives[archiveType] = factory
+}
+
+// newBlockArchiveFromConfig returns nil if archival is not enabled
+func newBlockArchiveFromConfig(chainID string) (BlockArchive, error) {
+	if !viper.GetBool("ledger.blockchain.archive.enabled") {
+		return nil, nil
+	}
+	archiveType := viper.GetString("ledger.blockchain.archive.type")
+	if archiveType == "" {
+		archiveType = "filesystem"
+	}
+	blockArchivesLock.RLock()
+	factory, ok := blockArchives[archiveType]
+	blockArchivesLock.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("Unknown block archive type [%s]", archiveType)
+	}
+	return factory(chainID)
+}
+
+// fileSystemBlockArchive keeps one file per block under a directory of its own
+// for each chain. Blocks are grouped in sub-directories of 10000 blocks so that
+// no directory grows too large.
+type fileSystemBlockArchive struct {
+	path string
+}
+
+func newFileSystemBlockArchiveFromConfig(chainID string) (BlockArchive, error) {
+	path := viper.GetString("ledger.blockchain.archive.filesystem.path")
+	if path == "" {
+		return nil, fmt.Errorf("ledger.blockchain.archive.filesystem.path is not set")
+	}
+	if chainID != DefaultChainID {
+		path = filepath.Join(path, "chains", chainID)
+	}
+	return newFileSystemBlockArchive(path)
+}
+
+func newFileSystemBlockArchive(path string) (*fileSystemBlockArchive, error) {
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return nil, fmt.Errorf("Error creating the block archive directory [%s]: %s", path, err)
+	}
+	return &fileSystemBlockArchive{path}, nil
+}
+
+func (archive *fileSystemBlockArchive) blockPath(blockNumber uint64) string {
+	return filepath.Join(archive.path, fmt.Sprintf("%d", blockNumber/10000), fmt.Sprintf("%d.block", blockNumber))
+}
+
+// Put writes the block to a temporary file which is renamed once synced, so
+// that a crash never leaves a partially written block behind
+func (archive *fileSystemBlockArchive) Put(blockNumber uint64, blockBytes []byte) error {
+	path := archive.blockPath(blockNumber)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmpFile, err := ioutil.TempFile(filepath.Dir(path), ".tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmpFile.Write(blockBytes)
+	if err == nil {
+		err = tmpFile.Sync()
+	}
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpFile.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmpFile.Name())
+	}
+	return err
+}
+
+func (archive *fileSystemBlockArchive) Get(blockNumber uint64) ([]byte, error) {
+	return ioutil.ReadFile(archive.blockPath(blockNumber))
+}
+
+var lastArchivedBlockKey = []byte("archivedBlockCount")
+
+// archivedBlockName is the name under which the archived blocks are encrypted
+const archivedBlockName = "blockArchive"
+
+var archivedBlockHashKeyPrefix = []byte("archivedBlockHash_")
+
+func archivedBlockHashKey(blockNumber uint64) []byte {
+	return append(append([]byte{}, archivedBlockHashKeyPrefix...), encodeBlockNumberDBKey(blockNumber)...)
+}
+
+// fetchArchivedBlock returns the complete block if the given block, read from
+// the DB, has been archived. The block read back from the archive has to match
+// the hash recorded at archival time.
+func (blockchain *blockchain) fetchArchivedBlock(blockNumber uint64, block *protos.Block) (*protos.Block, error) {
+	blockHash, err := blockchain.openchainDB.GetFromBlockchainCF(archivedBlockHashKey(blockNumber))
+	if err != nil || blockHash == nil {
+		return block, err
+	}
+	if blockchain.archive == nil {
+		return nil, fmt.Errorf("Block [%d] has been archived but the block archive is not enabled", blockNumber)
+	}
+	storedBytes, err := blockchain.archive.Get(blockNumber)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading block [%d] from the block archive: %s", blockNumber, err)
+	}
+	blockBytes, err := blockchain.openchainDB.DecryptExternalValue(archivedBlockName, encodeBlockNumberDBKey(blockNumber), storedBytes)
+	if err != nil {
+		return nil, fmt.Errorf("Error decrypting block [%d] read from the block archive: %s", blockNumber, err)
+	}
+	archivedBlock, err := protos.UnmarshallBlock(blockBytes)
+	if err != nil {
+		return nil, fmt.Errorf("Error unmarshalling block [%d] read from the block archive: %s", blockNumber, err)
+	}
+	archivedBlockHash, err := archivedBlock.GetHash()
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(blockHash, archivedBlockHash) {
+		return nil, fmt.Errorf("The hash of block [%d] read from the block archive does not match the one recorded in the DB", blockNumber)
+	}
+	return archivedBlock, nil
+}
+
+// blockArchiver moves the blocks older than the last keepBlocks blocks to the
+// archive. It runs in the background and is woken up after each commit.
+type blockArchiver struct {
+	blockchain *blockchain
+	keepBlocks uint64
+	committed  chan uint64
+	stopped    chan struct{}
+	done       chan struct{}
+}
+
+func newBlockArchiverFromConfig(blockchain *blockchain) *blockArchiver {
+	keepBlocks := viper.GetInt("ledger.blockchain.archive.keepBlocks")
+	if keepBlocks < 1 {
+		// The last block is needed to chain the next one
+		keepBlocks = 1
+	}
+	return &blockArchiver{blockchain, uint64(keepBlocks), make(chan uint64, 1), make(chan struct{}), make(chan struct{})}
+}
+
+func (archiver *blockArchiver) start() {
+	go archiver.run()
+	archiver.blockCommitted(archiver.blockchain.getSize())
+}
+
+func (archiver *blockArchiver) stop() {
+	close(archiver.stopped)
+	<-archiver.done
+}
+
+// blockCommitted notifies the archiver of the new size of the blockchain. A
+// notification that has not yet been picked up is replaced by the new one.
+func (archiver *blockArchiver) blockCommitted(size uint64) {
+	select {
+	case <-archiver.committed:
+	default:
+	}
+	archiver.committed <- size
+}
+
+func (archiver *blockArchiver) run() {
+	defer close(archiver.done)
+	for {
+		select {
+		case size := <-archiver.committed:
+			if err := archiver.archiveBlocks(size); err != nil {
+				ledgerLogger.Error("Error archiving blocks: %s", err)
+			}
+		case <-archiver.stopped:
+			return
+		}
+	}
+}
+
+func (archiver *blockArchiver) archiveBlocks(size uint64) error {
+	if size <= archiver.keepBlocks {
+		return nil
+	}
+	next, err := archiver.nextBlockToArchive()
+	if err != nil {
+		return err
+	}
+	for blockNumber := next; blockNumber < size-archiver.keepBlocks; blockNumber++ {
+		select {
+		case <-archiver.stopped:
+			return nil
+		default:
+		}
+		if err := archiver.archiveBlock(blockNumber); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (archiver *blockArchiver) nextBlockToArchive() (uint64, error) {
+	value, err := archiver.blockchain.openchainDB.GetFromBlockchainCF(lastArchivedBlockKey)
+	if err != nil || value == nil {
+		return 0, err
+	}
+	return decodeToUint64(value), nil
+}
+
+// archiveBlock stores the complete block in the archive and then replaces it
+// in the DB with its header. The header, the hash of the complete block and the
+// archival progress are written atomically, hence a block is archived again
+// after a crash instead of being lost.
+func (archiver *blockArchiver) archiveBlock(blockNumber uint64) error {
+	openchainDB := archiver.blockchain.openchainDB
+	blockBytes, err := openchainDB.GetFromBlockchainCF(encodeBlockNumberDBKey(blockNumber))
+	if err != nil {
+		return err
+	}
+	if blockBytes == nil {
+		// Blocks can be missing while the state is being transferred from another peer
+		return fmt.Errorf("Block [%d] is not available yet", blockNumber)
+	}
+	block, err := protos.UnmarshallBlock(blockBytes)
+	if err != nil {
+		return err
+	}
+	blockHash, err := block.GetHash()
+	if err != nil {
+		return err
+	}
+	storedBytes := openchainDB.EncryptExternalValue(archivedBlockName, encodeBlockNumberDBKey(blockNumber), blockBytes)
+	if err := archiver.blockchain.archive.Put(blockNumber, storedBytes); err != nil {
+		return fmt.Errorf("Error writing block [%d] to the block archive: %s", blockNumber, err)
+	}
+
+	header := &protos.Block{
+		Version:           block.Version,
+		Timestamp:         block.Timestamp,
+		StateHash:         block.StateHash,
+		PreviousBlockHash: block.PreviousBlockHash,
+		ConsensusMetadata: block.ConsensusMetadata,
+	}
+	if block.NonHashData != nil {
+		header.NonHashData = &protos.NonHashData{
+			LocalLedgerCommitTimestamp: block.NonHashData.LocalLedgerCommitTimestamp,
+			TxEffectsRoot:              block.NonHashData.TxEffectsRoot,
+		}
+	}
+	headerBytes, err := header.Bytes()
+	if err != nil {
+		return err
+	}
+	writeBatch := gorocksdb.NewWriteBatch()
+	defer writeBatch.Destroy()
+	blockchainBatch := openchainDB.WrapWriteBatch(writeBatch)
+	blockchainBatch.Put(kvstore.BlockchainCFName, encodeBlockNumberDBKey(blockNumber), headerBytes)
+	blockchainBatch.Put(kvstore.BlockchainCFName, archivedBlockHashKey(blockNumber), blockHash)
+	blockchainBatch.Put(kvstore.BlockchainCFName, lastArchivedBlockKey, encodeUint64(blockNumber+1))
+	opt := gorocksdb.NewDefaultWriteOptions()
+	defer opt.Destroy()
+	if err := openchainDB.DB.Write(opt, writeBatch); err != nil {
+		return err
+	}
+	ledgerLogger.Debug("Archived block [%d]", blockNumber)
+	return nil
+}
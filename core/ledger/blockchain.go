@@ -22,6 +22,7 @@ import (
 	"strconv"
 
 	"github.com/hyperledger/fabric/core/db"
+	"github.com/hyperledger/fabric/core/db/kvstore"
 	"github.com/hyperledger/fabric/core/util"
 	"github.com/hyperledger/fabric/protos"
 	"github.com/tecbot/gorocksdb"
@@ -35,6 +36,12 @@ type blockchain struct {
 	previousBlockHash  []byte
 	indexer            blockchainIndexer
 	lastProcessedBlock *lastProcessedBlock
+	openchainDB        *db.OpenchainDB
+	// archive is nil unless block archival is enabled
+	archive  BlockArchive
+	archiver *blockArchiver
+	// indexBackfiller builds the secondary indexes of the blocks persisted before they were introduced
+	indexBackfiller *secondaryIndexesBackfiller
 }
 
 type lastProcessedBlock struct {
@@ -45,19 +52,19 @@ type lastProcessedBlock struct {
 
 var indexBlockDataSynchronously = true
 
-func newBlockchain() (*blockchain, error) {
-	size, err := fetchBlockchainSizeFromDB()
+func newBlockchain(openchainDB *db.OpenchainDB) (*blockchain, error) {
+	size, err := fetchBlockchainSizeFromDB(openchainDB)
 	if err != nil {
 		return nil, err
 	}
-	blockchain := &blockchain{0, nil, nil, nil}
+	blockchain := &blockchain{0, nil, nil, nil, openchainDB, nil, nil, nil}
 	blockchain.size = size
 	if size > 0 {
-		previousBlock, err := fetchBlockFromDB(size - 1)
+		previousBlock, err := fetchBlockFromDB(openchainDB, size-1)
 		if err != nil {
 			return nil, err
 		}
-		previousBlockHash, err := previousBlock.GetHash()
+		previousBlockHash, err := getPersistedBlockHash(openchainDB, size-1, previousBlock)
 		if err != nil {
 			return nil, err
 		}
@@ -73,7 +80,7 @@ func newBlockchain() (*blockchain, error) {
 
 func (blockchain *blockchain) startIndexer() (err error) {
 	if indexBlockDataSynchronously {
-		blockchain.indexer = newBlockchainIndexerSync()
+		blockchain.indexer = newBlockchainIndexerSync(blockchain.openchainDB)
 	} else {
 		blockchain.indexer = newBlockchainIndexerAsync()
 	}
@@ -96,7 +103,12 @@ func (blockchain *blockchain) getSize() uint64 {
 
 // getBlock get block at arbitrary height in block chain
 func (blockchain *blockchain) getBlock(blockNumber uint64) (*protos.Block, error) {
-	return fetchBlockFromDB(blockNumber)
+	block, err := fetchBlockFromDB(blockchain.openchainDB, blockNumber)
+	if err != nil || block == nil || len(block.Transactions) > 0 {
+		return block, err
+	}
+	// Only the header of an archived block is kept in the DB
+	return blockchain.fetchArchivedBlock(blockNumber, block)
 }
 
 // getBlockByHash get block by block hash
@@ -204,8 +216,10 @@ func (blockchain *blockchain) addPersistenceChangesForNewBlock(ctx context.Conte
 	if blockBytesErr != nil {
 		return 0, blockBytesErr
 	}
-	writeBatch.PutCF(db.GetDBHandle().BlockchainCF, encodeBlockNumberDBKey(blockNumber), blockBytes)
-	writeBatch.PutCF(db.GetDBHandle().BlockchainCF, blockCountKey, encodeUint64(blockNumber+1))
+	blockchainBatch := blockchain.openchainDB.WrapWriteBatch(writeBatch)
+	blockchainBatch.Put(kvstore.BlockchainCFName, encodeBlockNumberDBKey(blockNumber), blockBytes)
+	blockchainBatch.Put(kvstore.BlockchainCFName, blockCountKey, encodeUint64(blockNumber+1))
+	addSecondaryIndexesForPersistence(block, blockNumber, blockchainBatch)
 	if blockchain.indexer.isSynchronous() {
 		blockchain.indexer.createIndexesSync(block, blockNumber, blockHash, writeBatch)
 	}
@@ -221,6 +235,9 @@ func (blockchain *blockchain) blockPersistenceStatus(success bool) {
 			blockchain.indexer.createIndexesAsync(blockchain.lastProcessedBlock.block,
 				blockchain.lastProcessedBlock.blockNumber, blockchain.lastProcessedBlock.blockHash)
 		}
+		if blockchain.archiver != nil {
+			blockchain.archiver.blockCommitted(blockchain.size)
+		}
 	}
 	blockchain.lastProcessedBlock = nil
 }
@@ -232,7 +249,9 @@ func (blockchain *blockchain) persistRawBlock(block *protos.Block, blockNumber u
 	}
 	writeBatch := gorocksdb.NewWriteBatch()
 	defer writeBatch.Destroy()
-	writeBatch.PutCF(db.GetDBHandle().BlockchainCF, encodeBlockNumberDBKey(blockNumber), blockBytes)
+	blockchainBatch := blockchain.openchainDB.WrapWriteBatch(writeBatch)
+	blockchainBatch.Put(kvstore.BlockchainCFName, encodeBlockNumberDBKey(blockNumber), blockBytes)
+	addSecondaryIndexesForPersistence(block, blockNumber, blockchainBatch)
 
 	blockHash, err := block.GetHash()
 	if err != nil {
@@ -243,7 +262,7 @@ func (blockchain *blockchain) persistRawBlock(block *protos.Block, blockNumber u
 	// really blockchain height, not size.
 	if blockchain.getSize() < blockNumber+1 {
 		sizeBytes := encodeUint64(blockNumber + 1)
-		writeBatch.PutCF(db.GetDBHandle().BlockchainCF, blockCountKey, sizeBytes)
+		blockchainBatch.Put(kvstore.BlockchainCFName, blockCountKey, sizeBytes)
 		blockchain.size = blockNumber + 1
 		blockchain.previousBlockHash = blockHash
 	}
@@ -254,10 +273,13 @@ func (blockchain *blockchain) persistRawBlock(block *protos.Block, blockNumber u
 
 	opt := gorocksdb.NewDefaultWriteOptions()
 	defer opt.Destroy()
-	err = db.GetDBHandle().DB.Write(opt, writeBatch)
+	err = blockchain.openchainDB.DB.Write(opt, writeBatch)
 	if err != nil {
 		return err
 	}
+	if blockchain.archiver != nil {
+		blockchain.archiver.blockCommitted(blockchain.size)
+	}
 	return nil
 }
 
@@ -288,8 +310,8 @@ func (blockchain *blockchain) persistRawBlock(block *protos.Block, blockNumber u
 // 	return nil
 // }
 
-func fetchBlockFromDB(blockNumber uint64) (*protos.Block, error) {
-	blockBytes, err := db.GetDBHandle().GetFromBlockchainCF(encodeBlockNumberDBKey(blockNumber))
+func fetchBlockFromDB(openchainDB *db.OpenchainDB, blockNumber uint64) (*protos.Block, error) {
+	blockBytes, err := openchainDB.GetFromBlockchainCF(encodeBlockNumberDBKey(blockNumber))
 	if err != nil {
 		return nil, err
 	}
@@ -299,16 +321,16 @@ func fetchBlockFromDB(blockNumber uint64) (*protos.Block, error) {
 	return protos.UnmarshallBlock(blockBytes)
 }
 
-func fetchTransactionFromDB(blockNum uint64, txIndex uint64) (*protos.Transaction, error) {
-	block, err := fetchBlockFromDB(blockNum)
+func fetchTransactionFromDB(openchainDB *db.OpenchainDB, blockNum uint64, txIndex uint64) (*protos.Transaction, error) {
+	block, err := fetchBlockFromDB(openchainDB, blockNum)
 	if err != nil {
 		return nil, err
 	}
 	return block.GetTransactions()[txIndex], nil
 }
 
-func fetchBlockchainSizeFromDB() (uint64, error) {
-	bytes, err := db.GetDBHandle().GetFromBlockchainCF(blockCountKey)
+func fetchBlockchainSizeFromDB(openchainDB *db.OpenchainDB) (uint64, error) {
+	bytes, err := openchainDB.GetFromBlockchainCF(blockCountKey)
 	if err != nil {
 		return 0, err
 	}
@@ -318,8 +340,8 @@ func fetchBlockchainSizeFromDB() (uint64, error) {
 	return decodeToUint64(bytes), nil
 }
 
-func fetchBlockchainSizeFromSnapshot(snapshot *gorocksdb.Snapshot) (uint64, error) {
-	blockNumberBytes, err := db.GetDBHandle().GetFromBlockchainCFSnapshot(snapshot, blockCountKey)
+func fetchBlockchainSizeFromSnapshot(snapshot kvstore.Snapshot) (uint64, error) {
+	blockNumberBytes, err := snapshot.Get(kvstore.BlockchainCFName, blockCountKey)
 	if err != nil {
 		return 0, err
 	}
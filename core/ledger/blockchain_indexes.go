@@ -31,6 +31,9 @@ var prefixBlockHashKey = byte(1)
 var prefixTxUUIDKey = byte(2)
 var prefixAddressBlockNumCompositeKey = byte(3)
 
+// prefix byte(4) is used in the indexesCF by the write index of the state (see statemgmt/state/write_index.go)
+// prefix byte(5) is used in the indexesCF for the tx effects of the blocks (see tx_effects.go)
+
 type blockchainIndexer interface {
 	isSynchronous() bool
 	start(blockchain *blockchain) error
@@ -43,10 +46,11 @@ type blockchainIndexer interface {
 
 // Implementation for sync indexer
 type blockchainIndexerSync struct {
+	openchainDB *db.OpenchainDB
 }
 
-func newBlockchainIndexerSync() *blockchainIndexerSync {
-	return &blockchainIndexerSync{}
+func newBlockchainIndexerSync(openchainDB *db.OpenchainDB) *blockchainIndexerSync {
+	return &blockchainIndexerSync{openchainDB}
 }
 
 func (indexer *blockchainIndexerSync) isSynchronous() bool {
@@ -59,7 +63,7 @@ func (indexer *blockchainIndexerSync) start(blockchain *blockchain) error {
 
 func (indexer *blockchainIndexerSync) createIndexesSync(
 	block *protos.Block, blockNumber uint64, blockHash []byte, writeBatch *gorocksdb.WriteBatch) error {
-	return addIndexDataForPersistence(block, blockNumber, blockHash, writeBatch)
+	return addIndexDataForPersistence(indexer.openchainDB, block, blockNumber, blockHash, writeBatch)
 }
 
 func (indexer *blockchainIndexerSync) createIndexesAsync(block *protos.Block, blockNumber uint64, blockHash []byte) error {
@@ -67,11 +71,11 @@ func (indexer *blockchainIndexerSync) createIndexesAsync(block *protos.Block, bl
 }
 
 func (indexer *blockchainIndexerSync) fetchBlockNumberByBlockHash(blockHash []byte) (uint64, error) {
-	return fetchBlockNumberByBlockHashFromDB(blockHash)
+	return fetchBlockNumberByBlockHashFromDB(indexer.openchainDB, blockHash)
 }
 
 func (indexer *blockchainIndexerSync) fetchTransactionIndexByUUID(txUUID string) (uint64, uint64, error) {
-	return fetchTransactionIndexByUUIDFromDB(txUUID)
+	return fetchTransactionIndexByUUIDFromDB(indexer.openchainDB, txUUID)
 }
 
 func (indexer *blockchainIndexerSync) stop() {
@@ -79,8 +83,7 @@ func (indexer *blockchainIndexerSync) stop() {
 }
 
 // Functions for persisting and retrieving index data
-func addIndexDataForPersistence(block *protos.Block, blockNumber uint64, blockHash []byte, writeBatch *gorocksdb.WriteBatch) error {
-	openchainDB := db.GetDBHandle()
+func addIndexDataForPersistence(openchainDB *db.OpenchainDB, block *protos.Block, blockNumber uint64, blockHash []byte, writeBatch *gorocksdb.WriteBatch) error {
 	cf := openchainDB.IndexesCF
 
 	// add blockhash -> blockNumber
@@ -91,15 +94,26 @@ func addIndexDataForPersistence(block *protos.Block, blockNumber uint64, blockHa
 	addressToChaincodeIDsMap := make(map[string][]*protos.ChaincodeID)
 
 	transactions := block.GetTransactions()
+	indexedUUIDs := make(map[string]bool)
 	for txIndex, tx := range transactions {
-		// add TxUUID -> (blockNumber,indexWithinBlock)
-		writeBatch.PutCF(cf, encodeTxUUIDKey(tx.Uuid), encodeBlockNumTxIndex(blockNumber, uint64(txIndex)))
+		// add TxUUID -> (blockNumber,indexWithinBlock), unless an earlier transaction has the uuid:
+		// a duplicate is rejected, the uuid keeps identifying the transaction which was executed
+		if !indexedUUIDs[tx.Uuid] {
+			indexedUUIDs[tx.Uuid] = true
+			indexed, err := openchainDB.GetFromIndexesCF(encodeTxUUIDKey(tx.Uuid))
+			if err != nil {
+				return err
+			}
+			if indexed == nil {
+				writeBatch.PutCF(cf, encodeTxUUIDKey(tx.Uuid), encodeBlockNumTxIndex(blockNumber, uint64(txIndex)))
+			}
+		}
 
 		txExecutingAddress := getTxExecutingAddress(tx)
 		addressToTxIndexesMap[txExecutingAddress] = append(addressToTxIndexesMap[txExecutingAddress], uint64(txIndex))
 
 		switch tx.Type {
-		case protos.Transaction_CHAINCODE_DEPLOY, protos.Transaction_CHAINCODE_INVOKE:
+		case protos.Transaction_CHAINCODE_DEPLOY, protos.Transaction_CHAINCODE_UPGRADE, protos.Transaction_CHAINCODE_INVOKE:
 			authroizedAddresses, chaincodeID := getAuthorisedAddresses(tx)
 			for _, authroizedAddress := range authroizedAddresses {
 				addressToChaincodeIDsMap[authroizedAddress] = append(addressToChaincodeIDsMap[authroizedAddress], chaincodeID)
@@ -112,8 +126,28 @@ func addIndexDataForPersistence(block *protos.Block, blockNumber uint64, blockHa
 	return nil
 }
 
-func fetchBlockNumberByBlockHashFromDB(blockHash []byte) (uint64, error) {
-	blockNumberBytes, err := db.GetDBHandle().GetFromIndexesCF(encodeBlockHashKey(blockHash))
+// removeIndexDataForPersistence adds to the write-batch the removal of the index data added by addIndexDataForPersistence
+func removeIndexDataForPersistence(openchainDB *db.OpenchainDB, block *protos.Block, blockNumber uint64, blockHash []byte, writeBatch *gorocksdb.WriteBatch) {
+	cf := openchainDB.IndexesCF
+	writeBatch.DeleteCF(cf, encodeBlockHashKey(blockHash))
+	addresses := make(map[string]bool)
+	for _, tx := range block.GetTransactions() {
+		addresses[getTxExecutingAddress(tx)] = true
+		// the duplicate of a transaction of an earlier block leaves its index
+		if indexed, err := openchainDB.GetFromIndexesCF(encodeTxUUIDKey(tx.Uuid)); err == nil && indexed != nil {
+			if indexedBlockNumber, _, err := decodeBlockNumTxIndex(indexed); err == nil && indexedBlockNumber != blockNumber {
+				continue
+			}
+		}
+		writeBatch.DeleteCF(cf, encodeTxUUIDKey(tx.Uuid))
+	}
+	for address := range addresses {
+		writeBatch.DeleteCF(cf, encodeAddressBlockNumCompositeKey(address, blockNumber))
+	}
+}
+
+func fetchBlockNumberByBlockHashFromDB(openchainDB *db.OpenchainDB, blockHash []byte) (uint64, error) {
+	blockNumberBytes, err := openchainDB.GetFromIndexesCF(encodeBlockHashKey(blockHash))
 	if err != nil {
 		return 0, err
 	}
@@ -121,8 +155,8 @@ func fetchBlockNumberByBlockHashFromDB(blockHash []byte) (uint64, error) {
 	return blockNumber, nil
 }
 
-func fetchTransactionIndexByUUIDFromDB(txUUID string) (uint64, uint64, error) {
-	blockNumTxIndexBytes, err := db.GetDBHandle().GetFromIndexesCF(encodeTxUUIDKey(txUUID))
+func fetchTransactionIndexByUUIDFromDB(openchainDB *db.OpenchainDB, txUUID string) (uint64, uint64, error) {
+	blockNumTxIndexBytes, err := openchainDB.GetFromIndexesCF(encodeTxUUIDKey(txUUID))
 	if err != nil {
 		return 0, 0, err
 	}
@@ -111,10 +111,10 @@ func (indexer *blockchainIndexerAsync) createIndexesAsync(block *protos.Block, b
 
 // createIndexes adds entries into db for creating indexes on various attributes
 func (indexer *blockchainIndexerAsync) createIndexesInternal(block *protos.Block, blockNumber uint64, blockHash []byte) error {
-	openchainDB := db.GetDBHandle()
+	openchainDB := indexer.blockchain.openchainDB
 	writeBatch := gorocksdb.NewWriteBatch()
 	defer writeBatch.Destroy()
-	addIndexDataForPersistence(block, blockNumber, blockHash, writeBatch)
+	addIndexDataForPersistence(openchainDB, block, blockNumber, blockHash, writeBatch)
 	writeBatch.PutCF(openchainDB.IndexesCF, lastIndexedBlockKey, encodeBlockNumber(blockNumber))
 	opt := gorocksdb.NewDefaultWriteOptions()
 	defer opt.Destroy()
@@ -132,7 +132,7 @@ func (indexer *blockchainIndexerAsync) fetchBlockNumberByBlockHash(blockHash []b
 		return 0, err
 	}
 	indexer.indexerState.waitForLastCommittedBlock()
-	return fetchBlockNumberByBlockHashFromDB(blockHash)
+	return fetchBlockNumberByBlockHashFromDB(indexer.blockchain.openchainDB, blockHash)
 }
 
 func (indexer *blockchainIndexerAsync) fetchTransactionIndexByUUID(txUUID string) (uint64, uint64, error) {
@@ -141,7 +141,7 @@ func (indexer *blockchainIndexerAsync) fetchTransactionIndexByUUID(txUUID string
 		return 0, 0, err
 	}
 	indexer.indexerState.waitForLastCommittedBlock()
-	return fetchTransactionIndexByUUIDFromDB(txUUID)
+	return fetchTransactionIndexByUUIDFromDB(indexer.blockchain.openchainDB, txUUID)
 }
 
 func (indexer *blockchainIndexerAsync) indexPendingBlocks() error {
@@ -200,7 +200,7 @@ type blockchainIndexerState struct {
 
 func newBlockchainIndexerState(indexer *blockchainIndexerAsync) (*blockchainIndexerState, error) {
 	var lock sync.RWMutex
-	zerothBlockIndexed, lastIndexedBlockNum, err := fetchLastIndexedBlockNumFromDB()
+	zerothBlockIndexed, lastIndexedBlockNum, err := fetchLastIndexedBlockNumFromDB(indexer.blockchain.openchainDB)
 	if err != nil {
 		return nil, err
 	}
@@ -277,8 +277,8 @@ func (indexerState *blockchainIndexerState) checkError() error {
 	return indexerState.err
 }
 
-func fetchLastIndexedBlockNumFromDB() (zerothBlockIndexed bool, lastIndexedBlockNum uint64, err error) {
-	lastIndexedBlockNumberBytes, err := db.GetDBHandle().GetFromIndexesCF(lastIndexedBlockKey)
+func fetchLastIndexedBlockNumFromDB(openchainDB *db.OpenchainDB) (zerothBlockIndexed bool, lastIndexedBlockNum uint64, err error) {
+	lastIndexedBlockNumberBytes, err := openchainDB.GetFromIndexesCF(lastIndexedBlockKey)
 	if err != nil {
 		return
 	}
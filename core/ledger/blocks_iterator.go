@@ -0,0 +1,82 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ledger
+
+import (
+	"github.com/hyperledger/fabric/core/errors"
+	"github.com/hyperledger/fabric/protos"
+)
+
+// BlocksIterator iterates over a range of blocks of the blockchain. The blocks are
+// read one at a time as the iteration proceeds, so that a large range of blocks can
+// be consumed at the pace of the consumer.
+type BlocksIterator struct {
+	blockchain  *blockchain
+	next        uint64
+	end         uint64
+	descending  bool
+	done        bool
+	blockNumber uint64
+	block       *protos.Block
+	err         error
+}
+
+// GetBlocks returns an iterator over the blocks from start to end, both included.
+// The blocks are iterated in descending order if start is greater than end.
+func (ledger *Ledger) GetBlocks(start uint64, end uint64) (*BlocksIterator, error) {
+	size := ledger.GetBlockchainSize()
+	if start >= size || end >= size {
+		return nil, ErrOutOfBounds
+	}
+	return &BlocksIterator{blockchain: ledger.blockchain, next: start, end: end, descending: start > end}, nil
+}
+
+// Next moves the iterator to the next block. It returns false once all the blocks
+// have been iterated or if a block cannot be read, see Err
+func (itr *BlocksIterator) Next() bool {
+	if itr.done {
+		return false
+	}
+	itr.blockNumber = itr.next
+	itr.block, itr.err = itr.blockchain.getBlock(itr.blockNumber)
+	if itr.err == nil && itr.block == nil {
+		itr.err = errors.Errorf(errors.NotFound, "Block [%d] is not present in the blockchain", itr.blockNumber)
+	}
+	if itr.err != nil {
+		itr.done = true
+		return false
+	}
+	switch {
+	case itr.blockNumber == itr.end:
+		itr.done = true
+	case itr.descending:
+		itr.next--
+	default:
+		itr.next++
+	}
+	return true
+}
+
+// GetBlock returns the current block and its number
+func (itr *BlocksIterator) GetBlock() (uint64, *protos.Block) {
+	return itr.blockNumber, itr.block
+}
+
+// Err returns the error, if any, which stopped the iteration
+func (itr *BlocksIterator) Err() error {
+	return itr.err
+}
@@ -0,0 +1,75 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ledger
+
+import (
+	"github.com/hyperledger/fabric/protos"
+)
+
+// ChaincodeEventEntry is an event set by a chaincode, along with the number of the block holding
+// the transaction that set it
+type ChaincodeEventEntry struct {
+	BlockNumber uint64                 `json:"blockNumber"`
+	Event       *protos.ChaincodeEvent `json:"event"`
+}
+
+// GetChaincodeEvents returns the events set by the chaincode with the given name in the blocks
+// fromBlock to toBlock (both included), in the order they were committed. If eventName is not
+// empty, only the events with this name are returned. The events are read from the transaction
+// results persisted with the blocks; the blocks are looked up through the secondary indexes,
+// or scanned while these are being built.
+func (ledger *Ledger) GetChaincodeEvents(chaincodeID string, eventName string, fromBlock uint64, toBlock uint64) ([]*ChaincodeEventEntry, error) {
+	size := ledger.GetBlockchainSize()
+	if size == 0 || fromBlock >= size {
+		return nil, nil
+	}
+	if toBlock >= size {
+		toBlock = size - 1
+	}
+	if toBlock < fromBlock {
+		return nil, nil
+	}
+
+	blockNumbers, err := ledger.GetBlockNumbersByChaincodeID(chaincodeID)
+	if err == ErrSecondaryIndexesNotReady {
+		blockNumbers = nil
+		for n := fromBlock; n <= toBlock; n++ {
+			blockNumbers = append(blockNumbers, n)
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	var entries []*ChaincodeEventEntry
+	for _, n := range blockNumbers {
+		if n < fromBlock || n > toBlock {
+			continue
+		}
+		block, err := ledger.GetBlockByNumber(n)
+		if err != nil {
+			return nil, err
+		}
+		for _, result := range block.GetNonHashData().GetTransactionResults() {
+			event := result.GetChaincodeEvent()
+			if event == nil || event.ChaincodeID != chaincodeID || (eventName != "" && event.EventName != eventName) {
+				continue
+			}
+			entries = append(entries, &ChaincodeEventEntry{BlockNumber: n, Event: event})
+		}
+	}
+	return entries, nil
+}
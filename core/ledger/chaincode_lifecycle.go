@@ -0,0 +1,76 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ledger
+
+import (
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+	"github.com/hyperledger/fabric/protos"
+)
+
+// LifecycleChaincodeID is the system namespace in which the instantiations of the chaincodes
+// are recorded. A chaincode is deployed by the transaction whose uuid is its name. Its deploy
+// and each of its upgrades record, under the name of the chaincode, the version, the hash of
+// the code, the endorsement policy and the uuid of the transaction that carries the code the
+// chaincode runs from then on.
+const LifecycleChaincodeID = statemgmt.SystemNamespacePrefix + "_lifecycle"
+
+// SetChaincodeInstantiation records the instantiation of a chaincode. Similar to ActivateFeature,
+// this has to be invoked in the context of a transaction so that the chaincode is instantiated,
+// or upgraded, on all the peers at the same point.
+func (ledger *Ledger) SetChaincodeInstantiation(instantiation *protos.ChaincodeInstantiation) error {
+	if err := checkNotSystemNamespace(instantiation.Name); err != nil {
+		return err
+	}
+	if instantiation.TxUUID == "" {
+		return newLedgerError(ErrorTypeInvalidArgument, fmt.Sprintf("Empty deployment transaction for chaincode [%s]", instantiation.Name))
+	}
+	value, err := proto.Marshal(instantiation)
+	if err != nil {
+		return err
+	}
+	return ledger.setSystemState(LifecycleChaincodeID, instantiation.Name, value)
+}
+
+// GetChaincodeInstantiation returns the instantiation record of the chaincode, nil if it has
+// not been instantiated
+func (ledger *Ledger) GetChaincodeInstantiation(chaincodeID string, committed bool) (*protos.ChaincodeInstantiation, error) {
+	value, err := ledger.state.Get(LifecycleChaincodeID, chaincodeID, committed)
+	if err != nil || value == nil {
+		return nil, err
+	}
+	instantiation := &protos.ChaincodeInstantiation{}
+	if err = proto.Unmarshal(value, instantiation); err != nil {
+		return nil, err
+	}
+	return instantiation, nil
+}
+
+// GetChaincodeDeployment returns the uuid of the transaction that carries the code the
+// chaincode runs: its latest upgrade, or its deploy transaction if it has never been upgraded
+func (ledger *Ledger) GetChaincodeDeployment(chaincodeID string, committed bool) (string, error) {
+	instantiation, err := ledger.GetChaincodeInstantiation(chaincodeID, committed)
+	if err != nil {
+		return "", err
+	}
+	if instantiation == nil {
+		return chaincodeID, nil
+	}
+	return instantiation.TxUUID, nil
+}
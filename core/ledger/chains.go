@@ -0,0 +1,148 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ledger
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hyperledger/fabric/core/db"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt/state"
+	"github.com/spf13/viper"
+)
+
+// DefaultChainID identifies the chain of the ledger returned by GetLedger
+const DefaultChainID = ""
+
+var chainIDPattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// chainStateConfigKeys are the settings under 'ledger.state' that can be set for a chain other
+// than DefaultChainID under 'ledger.chains.<chainID>.state'. The state data structure and the
+// hash algorithm are shared by all the chains of the peer
+var chainStateConfigKeys = map[string]bool{
+	"ledger.state.deltaHistorySize":     true,
+	"ledger.state.stagingFlushInterval": true,
+	"ledger.state.wal.enabled":          true,
+}
+
+var chainLedgers = make(map[string]*Ledger)
+var chainLedgersLock sync.Mutex
+
+// GetLedgerByChainID gives a reference to the ledger of the chain with the given ID. The
+// ledger is opened on the first call. The ledger of each chain has a DB of its own (see
+// db.OpenChainDB) and, hence, the blocks, the state and the state deltas of a chain are
+// isolated from the ones of the other chains. The ledger of DefaultChainID is the one
+// returned by GetLedger.
+func GetLedgerByChainID(chainID string) (*Ledger, error) {
+	if chainID == DefaultChainID {
+		return GetLedger()
+	}
+	if !chainIDPattern.MatchString(chainID) {
+		return nil, newLedgerError(ErrorTypeInvalidArgument, fmt.Sprintf("Invalid chain ID [%s]", chainID))
+	}
+	chainLedgersLock.Lock()
+	defer chainLedgersLock.Unlock()
+	if chainLedger, ok := chainLedgers[chainID]; ok {
+		return chainLedger, nil
+	}
+	openchainDB, err := db.OpenChainDB(chainID)
+	if err != nil {
+		return nil, fmt.Errorf("Error while opening the DB of chain [%s]: %s", chainID, err)
+	}
+	chainLedger, err := newLedger(chainID, openchainDB)
+	if err != nil {
+		openchainDB.CloseDB()
+		return nil, err
+	}
+	ledgerLogger.Info("Opened the ledger of chain [%s]", chainID)
+	chainLedgers[chainID] = chainLedger
+	return chainLedger, nil
+}
+
+// CloseLedgerByChainID closes the ledger of the chain with the given ID. The ledger must not
+// be used afterwards, a new one is opened by the next call to GetLedgerByChainID. The ledger
+// of DefaultChainID cannot be closed.
+func CloseLedgerByChainID(chainID string) error {
+	if chainID == DefaultChainID {
+		return newLedgerError(ErrorTypeInvalidArgument, "The ledger of the default chain cannot be closed")
+	}
+	chainLedgersLock.Lock()
+	defer chainLedgersLock.Unlock()
+	chainLedger, ok := chainLedgers[chainID]
+	if !ok {
+		return newLedgerError(ErrorTypeResourceNotFound, fmt.Sprintf("The ledger of chain [%s] is not open", chainID))
+	}
+	delete(chainLedgers, chainID)
+	chainLedger.blockchain.indexer.stop()
+	chainLedger.blockchain.indexBackfiller.stop()
+	if chainLedger.blockchain.archiver != nil {
+		chainLedger.blockchain.archiver.stop()
+	}
+	if chainLedger.checkpointer != nil {
+		chainLedger.checkpointer.stop()
+	}
+	chainLedger.openchainDB.CloseDB()
+	ledgerLogger.Info("Closed the ledger of chain [%s]", chainID)
+	return nil
+}
+
+// GetOpenChainIDs returns the sorted IDs of the chains whose ledger is open, not including DefaultChainID
+func GetOpenChainIDs() []string {
+	chainLedgersLock.Lock()
+	defer chainLedgersLock.Unlock()
+	chainIDs := make([]string, 0, len(chainLedgers))
+	for chainID := range chainLedgers {
+		chainIDs = append(chainIDs, chainID)
+	}
+	sort.Strings(chainIDs)
+	return chainIDs
+}
+
+// getOpenChainLedgers returns the ledgers of the chains that are open, not including the
+// ledger of DefaultChainID
+func getOpenChainLedgers() []*Ledger {
+	chainLedgersLock.Lock()
+	defer chainLedgersLock.Unlock()
+	ledgers := make([]*Ledger, 0, len(chainLedgers))
+	for _, chainLedger := range chainLedgers {
+		ledgers = append(ledgers, chainLedger)
+	}
+	return ledgers
+}
+
+// loadChainStateConfig reads the configuration of the state of the chain with the given ID. The
+// settings of the chain under 'ledger.chains.<chainID>.state' override the ones under 'ledger.state'
+func loadChainStateConfig(chainID string) (*state.Config, error) {
+	return state.LoadConfig(func(key string) string {
+		if chainID == DefaultChainID || !chainStateConfigKeys[key] {
+			return key
+		}
+		chainKey := "ledger.chains." + chainID + strings.TrimPrefix(key, "ledger")
+		if !viper.IsSet(chainKey) {
+			return key
+		}
+		return chainKey
+	})
+}
+
+// GetChainID returns the ID of the chain of the ledger
+func (ledger *Ledger) GetChainID() string {
+	return ledger.chainID
+}
@@ -0,0 +1,99 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ledger
+
+import (
+	"os"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/ledger/testutil"
+	"github.com/hyperledger/fabric/protos"
+	"github.com/spf13/viper"
+)
+
+func TestGetLedgerByChainID(t *testing.T) {
+	defaultLedger := InitTestLedger(t)
+	chainsPath := viper.GetString("peer.fileSystemPath") + "/chains"
+	os.RemoveAll(chainsPath)
+	defer os.RemoveAll(chainsPath)
+
+	ledger, err := GetLedgerByChainID(DefaultChainID)
+	testutil.AssertNoError(t, err, "Error while getting the ledger of the default chain")
+	testutil.AssertSame(t, ledger, defaultLedger)
+	_, err = GetLedgerByChainID("../chain1")
+	testutil.AssertError(t, err, "Expected an error for an invalid chain ID")
+
+	chainLedger, err := GetLedgerByChainID("chain1")
+	testutil.AssertNoError(t, err, "Error while opening the ledger of chain1")
+	testutil.AssertEquals(t, chainLedger.GetChainID(), "chain1")
+	sameLedger, _ := GetLedgerByChainID("chain1")
+	testutil.AssertSame(t, sameLedger, chainLedger)
+	testutil.AssertEquals(t, GetOpenChainIDs(), []string{"chain1"})
+
+	chainLedger.BeginTxBatch(1)
+	chainLedger.TxBegin("txUuid")
+	chainLedger.SetState("chaincode1", "key1", []byte("value1"))
+	chainLedger.TxFinished("txUuid", true)
+	transaction, _ := buildTestTx(t)
+	testutil.AssertNoError(t, chainLedger.CommitTxBatch(1, []*protos.Transaction{transaction}, nil, []byte("proof")), "Error while committing")
+
+	// the blocks and the state of the chain are not visible in the default chain
+	testutil.AssertEquals(t, chainLedger.GetBlockchainSize(), uint64(1))
+	testutil.AssertEquals(t, defaultLedger.GetBlockchainSize(), uint64(0))
+	value, err := defaultLedger.GetState("chaincode1", "key1", true)
+	testutil.AssertNoError(t, err, "Error while getting state")
+	testutil.AssertNil(t, value)
+	delta, err := chainLedger.GetStateDelta(0)
+	testutil.AssertNoError(t, err, "Error while getting the state delta")
+	testutil.AssertEquals(t, delta.Get("chaincode1", "key1").GetValue(), []byte("value1"))
+
+	// the chain survives a reopen
+	testutil.AssertNoError(t, CloseLedgerByChainID("chain1"), "Error while closing the ledger of chain1")
+	testutil.AssertEquals(t, len(GetOpenChainIDs()), 0)
+	testutil.AssertError(t, CloseLedgerByChainID("chain1"), "Expected an error for a ledger that is not open")
+	chainLedger, err = GetLedgerByChainID("chain1")
+	testutil.AssertNoError(t, err, "Error while reopening the ledger of chain1")
+	defer CloseLedgerByChainID("chain1")
+	testutil.AssertEquals(t, chainLedger.GetBlockchainSize(), uint64(1))
+	value, err = chainLedger.GetState("chaincode1", "key1", true)
+	testutil.AssertNoError(t, err, "Error while getting state")
+	testutil.AssertEquals(t, value, []byte("value1"))
+}
+
+func TestLoadChainStateConfig(t *testing.T) {
+	viper.Set("ledger.chains.chain1.state.deltaHistorySize", 2)
+	viper.Set("ledger.chains.chain1.state.dataStructure.name", "raw")
+	defer viper.Set("ledger.chains.chain1.state.deltaHistorySize", nil)
+	defer viper.Set("ledger.chains.chain1.state.dataStructure.name", nil)
+	defaultSize := viper.GetInt("ledger.state.deltaHistorySize")
+
+	config, err := loadChainStateConfig("chain1")
+	testutil.AssertNoError(t, err, "Error while loading the state configuration of chain1")
+	testutil.AssertEquals(t, config.DeltaHistorySize, 2)
+	// the data structure is shared by all the chains
+	testutil.AssertNotEquals(t, config.ImplName, "raw")
+	config, err = loadChainStateConfig("chain2")
+	testutil.AssertNoError(t, err, "Error while loading the state configuration of chain2")
+	testutil.AssertEquals(t, config.DeltaHistorySize, defaultSize)
+	config, err = loadChainStateConfig(DefaultChainID)
+	testutil.AssertNoError(t, err, "Error while loading the state configuration of the default chain")
+	testutil.AssertEquals(t, config.DeltaHistorySize, defaultSize)
+
+	viper.Set("ledger.chains.chain1.state.deltaHistorySize", -1)
+	_, err = loadChainStateConfig("chain1")
+	testutil.AssertError(t, err, "Expected an error for a negative delta history size")
+}
@@ -0,0 +1,513 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ledger
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/errors"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt/state"
+	"github.com/spf13/viper"
+)
+
+// Checkpoints
+//
+// Every 'ledger.checkpoint.interval' blocks, the complete state as of the block just committed
+// is written, in the background, to a file of its own along with the hash of the block and the
+// state hash. Only the latest 'ledger.checkpoint.retention' checkpoints are kept. A checkpoint
+// lets the state be rebuilt without replaying the state deltas of all the preceding blocks (see
+// RestoreStateFromCheckpoint), can be served to a peer bootstrapping through state transfer (see
+// OpenCheckpoint) and marks the point before which the history is no longer needed for
+// rebuilding the state.
+//
+// A checkpoint file is gzip compressed and holds
+//   uvarint(version) uvarint(blockNumber) bytes(blockHash) bytes(stateHash)
+//   { bytes(compositeKey) bytes(value) }* bytes(empty) uvarint(numKeys)
+// where bytes(b) is uvarint(len(b)) followed by b.
+
+const checkpointVersion = 1
+
+const checkpointFilePrefix = "checkpoint_"
+
+// CheckpointInfo describes a checkpoint
+type CheckpointInfo struct {
+	BlockNumber uint64
+	BlockHash   []byte
+	StateHash   []byte
+	// Size is the size of the checkpoint file
+	Size int64
+}
+
+type checkpointRequest struct {
+	blockNumber uint64
+	blockHash   []byte
+	stateHash   []byte
+	snapshot    *state.StateSnapshot
+}
+
+// checkpointer writes the checkpoints of a ledger in the background. A checkpoint that becomes
+// due while the previous one is still being written waits for it and replaces any other
+// checkpoint waiting.
+type checkpointer struct {
+	path      string
+	interval  uint64
+	retention int
+	requests  chan *checkpointRequest
+	done      chan struct{}
+}
+
+// newCheckpointerFromConfig returns nil if checkpoints are not enabled
+func newCheckpointerFromConfig(chainID string) (*checkpointer, error) {
+	interval := viper.GetInt("ledger.checkpoint.interval")
+	if interval <= 0 {
+		return nil, nil
+	}
+	retention := viper.GetInt("ledger.checkpoint.retention")
+	if retention < 1 {
+		retention = 1
+	}
+	path, err := getCheckpointPath(chainID)
+	if err != nil {
+		return nil, err
+	}
+	return &checkpointer{path, uint64(interval), retention, make(chan *checkpointRequest, 1), make(chan struct{})}, nil
+}
+
+func getCheckpointPath(chainID string) (string, error) {
+	path := viper.GetString("ledger.checkpoint.path")
+	if path == "" {
+		path = filepath.Join(viper.GetString("peer.fileSystemPath"), "checkpoints")
+	}
+	if chainID != DefaultChainID {
+		path = filepath.Join(path, "chains", chainID)
+	}
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return "", fmt.Errorf("Error creating the checkpoint directory [%s]: %s", path, err)
+	}
+	return path, nil
+}
+
+func (checkpointer *checkpointer) start() {
+	go func() {
+		defer close(checkpointer.done)
+		for request := range checkpointer.requests {
+			if err := checkpointer.writeCheckpoint(request); err != nil {
+				ledgerLogger.Error("Error writing the checkpoint of block [%d]: %s", request.blockNumber, err)
+			}
+		}
+	}()
+}
+
+// stop waits for the checkpoint being written, if any
+func (checkpointer *checkpointer) stop() {
+	close(checkpointer.requests)
+	<-checkpointer.done
+}
+
+// blockCommitted takes a snapshot of the state if a checkpoint is due at the given block.
+// This has to be invoked before the next block is committed.
+func (checkpointer *checkpointer) blockCommitted(ledger *Ledger, blockNumber uint64, blockHash []byte, stateHash []byte) {
+	if (blockNumber+1)%checkpointer.interval != 0 {
+		return
+	}
+	snapshot, err := ledger.state.GetSnapshot(blockNumber, ledger.openchainDB.KVStore().NewSnapshot())
+	if err != nil {
+		ledgerLogger.Error("Error taking the snapshot for the checkpoint of block [%d]: %s", blockNumber, err)
+		return
+	}
+	select {
+	case pending := <-checkpointer.requests:
+		pending.snapshot.Release()
+		ledgerLogger.Warning("Skipping the checkpoint of block [%d] in favour of block [%d], the previous checkpoint is still being written",
+			pending.blockNumber, blockNumber)
+	default:
+	}
+	checkpointer.requests <- &checkpointRequest{blockNumber, blockHash, stateHash, snapshot}
+}
+
+func (checkpointer *checkpointer) writeCheckpoint(request *checkpointRequest) error {
+	defer request.snapshot.Release()
+	tmpFile, err := ioutil.TempFile(checkpointer.path, ".tmp")
+	if err != nil {
+		return err
+	}
+	numKeys, err := writeCheckpointTo(tmpFile, request)
+	if err == nil {
+		err = tmpFile.Sync()
+	}
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpFile.Name(), checkpointFilePath(checkpointer.path, request.blockNumber))
+	}
+	if err != nil {
+		os.Remove(tmpFile.Name())
+		return err
+	}
+	ledgerLogger.Info("Checkpoint of block [%d] written with [%d] keys", request.blockNumber, numKeys)
+	return checkpointer.removeExpiredCheckpoints()
+}
+
+func writeCheckpointTo(file io.Writer, request *checkpointRequest) (uint64, error) {
+	gzipWriter := gzip.NewWriter(file)
+	writer := bufio.NewWriter(gzipWriter)
+	buf := make([]byte, binary.MaxVarintLen64)
+	writeUvarint := func(n uint64) {
+		writer.Write(buf[:binary.PutUvarint(buf, n)])
+	}
+	writeBytes := func(b []byte) {
+		writeUvarint(uint64(len(b)))
+		writer.Write(b)
+	}
+	writeUvarint(checkpointVersion)
+	writeUvarint(request.blockNumber)
+	writeBytes(request.blockHash)
+	writeBytes(request.stateHash)
+	numKeys := uint64(0)
+	for request.snapshot.Next() {
+		key, value := request.snapshot.GetRawKeyValue()
+		writeBytes(key)
+		writeBytes(value)
+		numKeys++
+	}
+	writeBytes(nil)
+	writeUvarint(numKeys)
+	// bufio.Writer retains the first error
+	if err := writer.Flush(); err != nil {
+		return 0, err
+	}
+	return numKeys, gzipWriter.Close()
+}
+
+func (checkpointer *checkpointer) removeExpiredCheckpoints() error {
+	blockNumbers, err := listCheckpoints(checkpointer.path)
+	if err != nil {
+		return err
+	}
+	for len(blockNumbers) > checkpointer.retention {
+		if err := os.Remove(checkpointFilePath(checkpointer.path, blockNumbers[0])); err != nil {
+			return err
+		}
+		ledgerLogger.Debug("Removed the checkpoint of block [%d]", blockNumbers[0])
+		blockNumbers = blockNumbers[1:]
+	}
+	return nil
+}
+
+func checkpointFilePath(path string, blockNumber uint64) string {
+	return filepath.Join(path, fmt.Sprintf("%s%020d", checkpointFilePrefix, blockNumber))
+}
+
+// listCheckpoints returns the block numbers of the checkpoints in ascending order
+func listCheckpoints(path string) ([]uint64, error) {
+	files, err := ioutil.ReadDir(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var blockNumbers []uint64
+	for _, file := range files {
+		if !strings.HasPrefix(file.Name(), checkpointFilePrefix) {
+			continue
+		}
+		blockNumber, err := strconv.ParseUint(strings.TrimPrefix(file.Name(), checkpointFilePrefix), 10, 64)
+		if err != nil {
+			continue
+		}
+		blockNumbers = append(blockNumbers, blockNumber)
+	}
+	sort.Sort(uint64Slice(blockNumbers))
+	return blockNumbers, nil
+}
+
+type uint64Slice []uint64
+
+func (s uint64Slice) Len() int           { return len(s) }
+func (s uint64Slice) Less(i, j int) bool { return s[i] < s[j] }
+func (s uint64Slice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// CheckpointReader reads the key-values of a checkpoint, in the same way as a StateSnapshot
+type CheckpointReader struct {
+	info    *CheckpointInfo
+	file    *os.File
+	gzip    *gzip.Reader
+	reader  *bufio.Reader
+	key     []byte
+	value   []byte
+	numKeys uint64
+	err     error
+}
+
+// OpenCheckpoint opens the checkpoint of the given block. You must call Release once you are
+// done with the reader.
+func (ledger *Ledger) OpenCheckpoint(blockNumber uint64) (*CheckpointReader, error) {
+	path, err := getCheckpointPath(ledger.chainID)
+	if err != nil {
+		return nil, err
+	}
+	return openCheckpoint(checkpointFilePath(path, blockNumber))
+}
+
+func openCheckpoint(filePath string) (*CheckpointReader, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrResourceNotFound
+		}
+		return nil, err
+	}
+	fileInfo, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	gzipReader, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("Error reading checkpoint [%s]: %s", filePath, err)
+	}
+	reader := &CheckpointReader{info: &CheckpointInfo{Size: fileInfo.Size()}, file: file, gzip: gzipReader, reader: bufio.NewReader(gzipReader)}
+	var version uint64
+	if version, reader.err = binary.ReadUvarint(reader.reader); reader.err == nil && version != checkpointVersion {
+		reader.err = fmt.Errorf("Unsupported checkpoint version [%d]", version)
+	}
+	reader.info.BlockNumber = reader.readUvarint()
+	reader.info.BlockHash = reader.readBytes()
+	reader.info.StateHash = reader.readBytes()
+	if reader.err != nil {
+		reader.Release()
+		return nil, fmt.Errorf("Error reading checkpoint [%s]: %s", filePath, reader.err)
+	}
+	return reader, nil
+}
+
+func (reader *CheckpointReader) readUvarint() uint64 {
+	if reader.err != nil {
+		return 0
+	}
+	var n uint64
+	n, reader.err = binary.ReadUvarint(reader.reader)
+	return n
+}
+
+func (reader *CheckpointReader) readBytes() []byte {
+	length := reader.readUvarint()
+	if reader.err != nil {
+		return nil
+	}
+	b := make([]byte, length)
+	_, reader.err = io.ReadFull(reader.reader, b)
+	return b
+}
+
+// GetInfo returns the block number and the hashes recorded in the checkpoint
+func (reader *CheckpointReader) GetInfo() *CheckpointInfo {
+	return reader.info
+}
+
+// Next moves to the next key-value. It returns false at the end of the checkpoint or on an
+// error, which is then returned by Err.
+func (reader *CheckpointReader) Next() bool {
+	if reader.err != nil {
+		return false
+	}
+	key := reader.readBytes()
+	if reader.err != nil {
+		return false
+	}
+	if len(key) == 0 {
+		if numKeys := reader.readUvarint(); reader.err == nil && numKeys != reader.numKeys {
+			reader.err = fmt.Errorf("The checkpoint is truncated, [%d] keys read instead of [%d]", reader.numKeys, numKeys)
+		}
+		if reader.err == io.EOF {
+			reader.err = io.ErrUnexpectedEOF
+		}
+		return false
+	}
+	reader.key = key
+	reader.value = reader.readBytes()
+	if reader.err != nil {
+		return false
+	}
+	reader.numKeys++
+	return true
+}
+
+// GetRawKeyValue returns the composite key and the value at the current position
+func (reader *CheckpointReader) GetRawKeyValue() ([]byte, []byte) {
+	return reader.key, reader.value
+}
+
+// Err returns the error encountered by Next, if any
+func (reader *CheckpointReader) Err() error {
+	if reader.err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return reader.err
+}
+
+// Release closes the checkpoint file
+func (reader *CheckpointReader) Release() {
+	reader.gzip.Close()
+	reader.file.Close()
+}
+
+// GetCheckpoints returns the checkpoints of the ledger in ascending order of block number
+func (ledger *Ledger) GetCheckpoints() ([]*CheckpointInfo, error) {
+	path, err := getCheckpointPath(ledger.chainID)
+	if err != nil {
+		return nil, err
+	}
+	blockNumbers, err := listCheckpoints(path)
+	if err != nil {
+		return nil, err
+	}
+	var checkpoints []*CheckpointInfo
+	for _, blockNumber := range blockNumbers {
+		reader, err := openCheckpoint(checkpointFilePath(path, blockNumber))
+		if err != nil {
+			return nil, err
+		}
+		checkpoints = append(checkpoints, reader.GetInfo())
+		reader.Release()
+	}
+	return checkpoints, nil
+}
+
+// RestoreStateFromCheckpoint rebuilds the state from the checkpoint of the given block and
+// then rolls it forward to the last block with the retained state deltas. The resulting state
+// hash has to match the one of the last block, otherwise the state is left unchanged. This
+// must not be invoked while a transaction-batch is in progress.
+func (ledger *Ledger) RestoreStateFromCheckpoint(blockNumber uint64) error {
+	lastBlockNumber := ledger.GetBlockchainSize() - 1
+	stateDelta, err := ledger.getStateDeltaFromCheckpoint(blockNumber, lastBlockNumber)
+	if err != nil {
+		return err
+	}
+	lastBlock, err := ledger.GetBlockByNumber(lastBlockNumber)
+	if err != nil {
+		return err
+	}
+	id := fmt.Sprintf("checkpoint-%d", blockNumber)
+	matched, err := ledger.commitStateDeltaIfMatches(id, stateDelta, lastBlock.StateHash)
+	if err != nil {
+		return err
+	}
+	if !matched {
+		return fmt.Errorf("The state rebuilt from the checkpoint of block [%d] does not match the state hash of block [%d]", blockNumber, lastBlockNumber)
+	}
+	ledgerLogger.Info("State restored from the checkpoint of block [%d] and rolled forward to block [%d]", blockNumber, lastBlockNumber)
+	return nil
+}
+
+// getStateDeltaFromCheckpoint returns a delta that turns the current state into the state of
+// block toBlockNumber, rebuilt from the checkpoint of block blockNumber and rolled forward with
+// the retained state deltas
+func (ledger *Ledger) getStateDeltaFromCheckpoint(blockNumber uint64, toBlockNumber uint64) (*statemgmt.StateDelta, error) {
+	reader, err := ledger.OpenCheckpoint(blockNumber)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Release()
+	info := reader.GetInfo()
+	block, err := ledger.GetBlockByNumber(info.BlockNumber)
+	if err != nil {
+		return nil, err
+	}
+	blockHash, err := block.GetHash()
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(blockHash, info.BlockHash) {
+		return nil, fmt.Errorf("The checkpoint of block [%d] does not belong to this blockchain", info.BlockNumber)
+	}
+
+	stateDelta, err := ledger.readCheckpointAsStateDelta(reader)
+	if err != nil {
+		return nil, err
+	}
+	for i := info.BlockNumber + 1; i <= toBlockNumber; i++ {
+		delta, err := ledger.GetStateDelta(i)
+		if err != nil {
+			return nil, err
+		}
+		if delta == nil {
+			return nil, errors.Errorf(errors.StateDeltaMissing, "The state delta of block [%d] is not retained, the state cannot be rolled forward from the checkpoint", i)
+		}
+		stateDelta.ApplyChanges(delta)
+	}
+	return stateDelta, nil
+}
+
+// commitStateDeltaIfMatches applies the delta to the state and commits it if the resulting
+// state hash is the expected one, otherwise the state is left unchanged and false is returned
+func (ledger *Ledger) commitStateDeltaIfMatches(id interface{}, stateDelta *statemgmt.StateDelta, expectedStateHash []byte) (bool, error) {
+	if err := ledger.ApplyStateDelta(id, stateDelta); err != nil {
+		return false, err
+	}
+	stateHash, err := ledger.GetTempStateHash()
+	if err != nil {
+		ledger.RollbackStateDelta(id)
+		return false, err
+	}
+	if !bytes.Equal(stateHash, expectedStateHash) {
+		ledger.RollbackStateDelta(id)
+		return false, nil
+	}
+	return true, ledger.CommitStateDelta(id)
+}
+
+// readCheckpointAsStateDelta returns a delta that turns the current state into the state of the checkpoint
+func (ledger *Ledger) readCheckpointAsStateDelta(reader *CheckpointReader) (*statemgmt.StateDelta, error) {
+	stateDelta := statemgmt.NewStateDelta()
+	for reader.Next() {
+		compositeKey, value := reader.GetRawKeyValue()
+		chaincodeID, key := statemgmt.DecodeCompositeKey(compositeKey)
+		stateDelta.Set(chaincodeID, key, value, nil)
+	}
+	if err := reader.Err(); err != nil {
+		return nil, fmt.Errorf("Error reading the checkpoint: %s", err)
+	}
+	snapshot, err := ledger.state.GetSnapshot(0, ledger.openchainDB.KVStore().NewSnapshot())
+	if err != nil {
+		return nil, err
+	}
+	defer snapshot.Release()
+	for snapshot.Next() {
+		compositeKey, _ := snapshot.GetRawKeyValue()
+		chaincodeID, key := statemgmt.DecodeCompositeKey(compositeKey)
+		if stateDelta.Get(chaincodeID, key) == nil {
+			stateDelta.Delete(chaincodeID, key, nil)
+		}
+	}
+	return stateDelta, nil
+}
@@ -0,0 +1,69 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ledger
+
+import (
+	"sync"
+	"time"
+)
+
+// commitNotifier wakes up all the goroutines waiting for the next block to be
+// added to the chain
+type commitNotifier struct {
+	sync.Mutex
+	next chan struct{}
+	// last is the time the last block was added, zero if none since the peer started
+	last time.Time
+}
+
+func newCommitNotifier() *commitNotifier {
+	return &commitNotifier{next: make(chan struct{})}
+}
+
+func (notifier *commitNotifier) nextCommit() <-chan struct{} {
+	notifier.Lock()
+	defer notifier.Unlock()
+	return notifier.next
+}
+
+func (notifier *commitNotifier) blockCommitted() {
+	notifier.Lock()
+	defer notifier.Unlock()
+	close(notifier.next)
+	notifier.next = make(chan struct{})
+	notifier.last = time.Now()
+}
+
+func (notifier *commitNotifier) lastCommit() time.Time {
+	notifier.Lock()
+	defer notifier.Unlock()
+	return notifier.last
+}
+
+// NextBlockCommitted returns a channel which is closed once the next block is
+// added to the chain, either committed or put by state transfer. To not miss a
+// block, the channel must be obtained before reading the size of the blockchain.
+func (ledger *Ledger) NextBlockCommitted() <-chan struct{} {
+	return ledger.commits.nextCommit()
+}
+
+// LastBlockCommitted returns the time the last block was added to the chain, either
+// committed or put by state transfer. The zero time if none was added since the peer
+// started
+func (ledger *Ledger) LastBlockCommitted() time.Time {
+	return ledger.commits.lastCommit()
+}
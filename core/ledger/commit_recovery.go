@@ -0,0 +1,167 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ledger
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/db"
+	"github.com/hyperledger/fabric/core/db/kvstore"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt/state"
+	"github.com/hyperledger/fabric/protos"
+	"github.com/tecbot/gorocksdb"
+)
+
+// Commit marker
+//
+// The block, its indexes, its transaction effects, the state changes, the state delta and the
+// commit marker are written by CommitTxBatch in a single write-batch, hence either all of these
+// or none are persisted. The commit marker records the number and the hash of the last block
+// whose state the persisted state is known to be. Blocks can however be persisted without
+// their state changes (see PutRawBlock), so, on startup, a ledger whose persisted state hash,
+// i.e. without the changes replayed from the WAL, does not match the one of the last block is
+// checked against the commit marker: if the state is the one of the marked block, the blocks
+// following it are the remains of a partially applied commit and are removed, along with their
+// indexes. Blocks received by an interrupted state transfer are removed as well and will be
+// received again. If the state matches neither block it is left as it is, e.g. for a state
+// transfer to complete it.
+
+var commitMarkerKey = []byte("ledger.commitMarker")
+
+func encodeCommitMarker(blockNumber uint64, blockHash []byte) []byte {
+	return append(encodeUint64(blockNumber), blockHash...)
+}
+
+func decodeCommitMarker(marker []byte) (uint64, []byte) {
+	return decodeToUint64(marker[:8]), marker[8:]
+}
+
+func addCommitMarkerForPersistence(blockNumber uint64, blockHash []byte, writeBatch kvstore.WriteBatch) {
+	writeBatch.Put(kvstore.PersistCFName, commitMarkerKey, encodeCommitMarker(blockNumber, blockHash))
+}
+
+// recoverPartialCommit makes the blockchain consistent with the state, see above. This has to
+// be invoked before the blockchain is loaded.
+func recoverPartialCommit(openchainDB *db.OpenchainDB, state *state.State) error {
+	size, err := fetchBlockchainSizeFromDB(openchainDB)
+	if err != nil || size == 0 {
+		return err
+	}
+	lastBlockNumber := size - 1
+	lastBlock, err := fetchBlockFromDB(openchainDB, lastBlockNumber)
+	if err != nil {
+		return err
+	}
+	if lastBlock == nil {
+		// blocks are being received from another peer
+		return nil
+	}
+	stateHash, err := state.GetCommittedHash()
+	if err != nil {
+		return err
+	}
+	marker, err := openchainDB.Get(openchainDB.PersistCF, commitMarkerKey)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(stateHash, lastBlock.StateHash) {
+		if marker == nil {
+			// DB created before the commit marker has been introduced, or state received from another peer
+			blockHash, err := getPersistedBlockHash(openchainDB, lastBlockNumber, lastBlock)
+			if err != nil {
+				return err
+			}
+			return openchainDB.Put(openchainDB.PersistCF, commitMarkerKey, encodeCommitMarker(lastBlockNumber, blockHash))
+		}
+		return nil
+	}
+	if marker == nil {
+		ledgerLogger.Warning("The state hash [%x] does not match the one of the last block [%d] and no commit has been marked", stateHash, lastBlockNumber)
+		return nil
+	}
+	markedBlockNumber, markedBlockHash := decodeCommitMarker(marker)
+	if markedBlockNumber >= lastBlockNumber {
+		ledgerLogger.Warning("The state hash [%x] does not match the one of the marked block [%d]", stateHash, markedBlockNumber)
+		return nil
+	}
+	markedBlock, err := fetchBlockFromDB(openchainDB, markedBlockNumber)
+	if err != nil {
+		return err
+	}
+	if markedBlock == nil || !bytes.Equal(stateHash, markedBlock.StateHash) {
+		ledgerLogger.Warning("The state hash [%x] matches neither the last block [%d] nor the marked block [%d]", stateHash, lastBlockNumber, markedBlockNumber)
+		return nil
+	}
+	blockHash, err := getPersistedBlockHash(openchainDB, markedBlockNumber, markedBlock)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(blockHash, markedBlockHash) {
+		return fmt.Errorf("The hash of block [%d] does not match the one recorded by the commit marker", markedBlockNumber)
+	}
+	ledgerLogger.Warning("Blocks [%d] to [%d] have been persisted without their state changes, removing them", markedBlockNumber+1, lastBlockNumber)
+	return truncateBlockchain(openchainDB, markedBlockNumber+1, size)
+}
+
+// getPersistedBlockHash returns the hash of the block, which, if the block has been
+// archived, is the one recorded at archival time
+func getPersistedBlockHash(openchainDB *db.OpenchainDB, blockNumber uint64, block *protos.Block) ([]byte, error) {
+	archivedBlockHash, err := openchainDB.GetFromBlockchainCF(archivedBlockHashKey(blockNumber))
+	if err != nil || archivedBlockHash != nil {
+		return archivedBlockHash, err
+	}
+	return block.GetHash()
+}
+
+// truncateBlockchain removes the blocks from newSize to size-1 along with the data derived
+// from them, in a single write-batch. The values are written through the KVStore write-batch,
+// which encrypts and checksums them as configured
+func truncateBlockchain(openchainDB *db.OpenchainDB, newSize uint64, size uint64) error {
+	writeBatch := gorocksdb.NewWriteBatch()
+	defer writeBatch.Destroy()
+	kvBatch := openchainDB.WrapWriteBatch(writeBatch)
+	for blockNumber := newSize; blockNumber < size; blockNumber++ {
+		block, err := fetchBlockFromDB(openchainDB, blockNumber)
+		if err != nil {
+			return err
+		}
+		if block != nil {
+			blockHash, err := block.GetHash()
+			if err != nil {
+				return err
+			}
+			removeIndexDataForPersistence(openchainDB, block, blockNumber, blockHash, writeBatch)
+			removeSecondaryIndexesForPersistence(block, blockNumber, kvBatch)
+		}
+		writeBatch.DeleteCF(openchainDB.BlockchainCF, encodeBlockNumberDBKey(blockNumber))
+		writeBatch.DeleteCF(openchainDB.IndexesCF, encodeTxEffectsKey(blockNumber))
+		// state deltas are keyed by block number in the same way as the blocks
+		writeBatch.DeleteCF(openchainDB.StateDeltaCF, encodeUint64(blockNumber))
+	}
+	kvBatch.Put(kvstore.BlockchainCFName, blockCountKey, encodeUint64(newSize))
+	_, lastIndexedBlockNumber, err := fetchLastIndexedBlockNumFromDB(openchainDB)
+	if err != nil {
+		return err
+	}
+	if lastIndexedBlockNumber >= newSize {
+		kvBatch.Put(kvstore.IndexesCFName, lastIndexedBlockKey, encodeBlockNumber(newSize-1))
+	}
+	opt := gorocksdb.NewDefaultWriteOptions()
+	defer opt.Destroy()
+	return openchainDB.DB.Write(opt, writeBatch)
+}
@@ -0,0 +1,49 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ledger
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/protos"
+)
+
+// The addresses of the peers the peer reconnects to after a restart are kept in the
+// persistCF, under discoveryListKey
+var discoveryListKey = []byte("ledger.discoveryList")
+
+// GetDiscoveryList returns the list of the peer addresses recorded by PutDiscoveryList,
+// nil if none was recorded
+func (ledger *Ledger) GetDiscoveryList() (*protos.DiscoveryList, error) {
+	value, err := ledger.openchainDB.Get(ledger.openchainDB.PersistCF, discoveryListKey)
+	if err != nil || value == nil {
+		return nil, err
+	}
+	list := &protos.DiscoveryList{}
+	if err := proto.Unmarshal(value, list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// PutDiscoveryList records the list of the peer addresses the peer reconnects to and bans
+func (ledger *Ledger) PutDiscoveryList(list *protos.DiscoveryList) error {
+	value, err := proto.Marshal(list)
+	if err != nil {
+		return err
+	}
+	return ledger.openchainDB.Put(ledger.openchainDB.PersistCF, discoveryListKey, value)
+}
@@ -0,0 +1,71 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ledger
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/db"
+	"github.com/hyperledger/fabric/events/producer"
+	"github.com/spf13/viper"
+)
+
+// checkDiskQuota is invoked before committing a block. A change of the status of the disk
+// quota of the DB (see 'peer.db.quota') is logged and sent as a 'diskQuota' event. Above the
+// hard quota, the block is refused with an error of type ErrorTypeDiskQuotaExceeded if
+// 'peer.db.quota.refuseCommits' is true, so that the peer stops growing the DB before the
+// disk is full instead of failing in the middle of a write.
+func (ledger *Ledger) checkDiskQuota() error {
+	usage, err := ledger.openchainDB.CheckDiskQuota()
+	if err != nil {
+		ledgerLogger.Warning("Error while checking the disk quota: %s", err)
+		return nil
+	}
+	if usage == nil {
+		return nil
+	}
+	if usage.Status != ledger.quotaStatus {
+		ledger.quotaStatusChanged(usage)
+	}
+	if usage.Status == db.QuotaHardExceeded && viper.GetBool("peer.db.quota.refuseCommits") {
+		return newLedgerError(ErrorTypeDiskQuotaExceeded,
+			fmt.Sprintf("The DB uses [%d] bytes, above its hard quota of [%d] bytes", usage.UsedBytes, usage.HardQuotaBytes))
+	}
+	return nil
+}
+
+func (ledger *Ledger) quotaStatusChanged(usage *db.DiskUsage) {
+	ledger.quotaStatus = usage.Status
+	switch usage.Status {
+	case db.QuotaOK:
+		ledgerLogger.Info("The DB uses [%d] bytes, back below its disk quotas", usage.UsedBytes)
+	case db.QuotaSoftExceeded:
+		ledgerLogger.Warning("The DB uses [%d] bytes, above its soft quota of [%d] bytes", usage.UsedBytes, usage.SoftQuotaBytes)
+	case db.QuotaHardExceeded:
+		ledgerLogger.Error("The DB uses [%d] bytes, above its hard quota of [%d] bytes", usage.UsedBytes, usage.HardQuotaBytes)
+	}
+	if ledger.chainID != DefaultChainID {
+		return
+	}
+	payload, err := json.Marshal(usage)
+	if err != nil {
+		ledgerLogger.Error("Error marshalling the disk usage for the disk quota event: %s", err)
+		return
+	}
+	producer.Send(producer.CreateGenericEvent(producer.DiskQuotaType, payload))
+}
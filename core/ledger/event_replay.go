@@ -0,0 +1,86 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ledger
+
+import (
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/protos"
+)
+
+// The ledger is the source of the blocks the event hub replays to the consumers (see
+// producer.ReplaySource). The position reached by each consumer is checkpointed in the
+// persistCF, under the consumer ID prefixed by eventConsumerCheckpointKeyPrefix.
+var eventConsumerCheckpointKeyPrefix = []byte("ledger.eventConsumerCheckpoint.")
+
+// GetEventBlock returns the committed block with the given number as it is sent in block
+// events, i.e. without the payload of the deploy transactions
+func (ledger *Ledger) GetEventBlock(blockNumber uint64) (*protos.Block, error) {
+	block, err := ledger.GetBlockByNumber(blockNumber)
+	if err != nil {
+		return nil, err
+	}
+	if block == nil {
+		return nil, ErrResourceNotFound
+	}
+	removeDeployPayloads(block)
+	return block, nil
+}
+
+// GetEventConsumerCheckpoint returns the number of the next block the event hub has to send to
+// the consumer, if the consumer has been checkpointed
+func (ledger *Ledger) GetEventConsumerCheckpoint(consumerID string) (uint64, bool, error) {
+	value, err := ledger.openchainDB.Get(ledger.openchainDB.PersistCF, eventConsumerCheckpointKey(consumerID))
+	if err != nil || value == nil {
+		return 0, false, err
+	}
+	return decodeToUint64(value), true, nil
+}
+
+// PutEventConsumerCheckpoint records the number of the next block the event hub has to send
+// to the consumer
+func (ledger *Ledger) PutEventConsumerCheckpoint(consumerID string, nextBlock uint64) error {
+	return ledger.openchainDB.Put(ledger.openchainDB.PersistCF, eventConsumerCheckpointKey(consumerID), encodeUint64(nextBlock))
+}
+
+func eventConsumerCheckpointKey(consumerID string) []byte {
+	return append(append([]byte{}, eventConsumerCheckpointKeyPrefix...), consumerID...)
+}
+
+// removeDeployPayloads removes the payload from the deploy and upgrade transactions of the block. This is
+// done to make block events more lightweight as the payload for these types of transactions can
+// be very large.
+func removeDeployPayloads(block *protos.Block) {
+	for _, transaction := range block.GetTransactions() {
+		if transaction.Type == protos.Transaction_CHAINCODE_DEPLOY || transaction.Type == protos.Transaction_CHAINCODE_UPGRADE {
+			deploymentSpec := &protos.ChaincodeDeploymentSpec{}
+			err := proto.Unmarshal(transaction.Payload, deploymentSpec)
+			if err != nil {
+				ledgerLogger.Error(fmt.Sprintf("Error unmarshalling deployment transaction for block event: %s", err))
+				continue
+			}
+			deploymentSpec.CodePackage = nil
+			deploymentSpecBytes, err := proto.Marshal(deploymentSpec)
+			if err != nil {
+				ledgerLogger.Error(fmt.Sprintf("Error marshalling deployment transaction for block event: %s", err))
+				continue
+			}
+			transaction.Payload = deploymentSpecBytes
+		}
+	}
+}
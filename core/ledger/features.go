@@ -0,0 +1,144 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ledger
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+)
+
+// LedgerFeature identifies a change in ledger behaviour that all the peers of
+// a network have to switch on at the same block, otherwise their state hashes
+// would diverge.
+type LedgerFeature string
+
+// The hash algorithm is not a feature because it cannot change once the ledger is
+// created (see RecordHashAlgorithm).
+const (
+	// FeatureKeyMetadata allows metadata to be associated with state keys
+	FeatureKeyMetadata = LedgerFeature("keyMetadata")
+)
+
+// FeaturesChaincodeID is the system namespace in which the activation block of
+// each ledger feature is recorded. Because the records are part of the world
+// state they are covered by the state hash, which makes every peer agree on the
+// block at which a feature becomes active.
+const FeaturesChaincodeID = statemgmt.SystemNamespacePrefix + "_features"
+
+// ConfigChaincodeID is the system namespace in which the genesis block records the
+// ledger configuration that has to be the same on all the peers of a network
+const ConfigChaincodeID = statemgmt.SystemNamespacePrefix + "_config"
+
+const hashAlgorithmKey = "hashAlgorithm"
+
+var knownFeatures = map[LedgerFeature]bool{
+	FeatureKeyMetadata: true,
+}
+
+// ActivateFeature records that the feature becomes active at activationBlock.
+// This has to be invoked in the context of a transaction (see TxBegin), which is
+// only done while creating the genesis block from the features configured under
+// 'ledger.blockchain.genesisBlock.features'. The activation
+// block cannot be lower than the block currently being built and a feature that
+// is already active cannot be rescheduled.
+func (ledger *Ledger) ActivateFeature(feature LedgerFeature, activationBlock uint64) error {
+	if !knownFeatures[feature] {
+		return newLedgerError(ErrorTypeInvalidArgument, fmt.Sprintf("Unknown ledger feature [%s]", feature))
+	}
+	nextBlockNumber := ledger.GetBlockchainSize()
+	if activationBlock < nextBlockNumber {
+		return newLedgerError(ErrorTypeInvalidArgument,
+			fmt.Sprintf("Activation block [%d] for ledger feature [%s] is in the past. Next block is [%d]", activationBlock, feature, nextBlockNumber))
+	}
+	active, err := ledger.IsFeatureActive(feature, nextBlockNumber)
+	if err != nil {
+		return err
+	}
+	if active {
+		return newLedgerError(ErrorTypeInvalidArgument, fmt.Sprintf("Ledger feature [%s] is already active", feature))
+	}
+	ledgerLogger.Info("Scheduling activation of ledger feature [%s] at block [%d]", feature, activationBlock)
+	return ledger.setSystemState(FeaturesChaincodeID, string(feature), encodeUint64(activationBlock))
+}
+
+// GetFeatureActivationBlock returns the block at which the feature becomes active
+// as per the committed state. The boolean return value is false if the activation
+// of the feature has not been recorded.
+func (ledger *Ledger) GetFeatureActivationBlock(feature LedgerFeature) (uint64, bool, error) {
+	value, err := ledger.state.Get(FeaturesChaincodeID, string(feature), true)
+	if err != nil {
+		return 0, false, err
+	}
+	if value == nil {
+		return 0, false, nil
+	}
+	return decodeToUint64(value), true, nil
+}
+
+// IsFeatureActive returns true if the feature is active for the block with the given number
+func (ledger *Ledger) IsFeatureActive(feature LedgerFeature, blockNumber uint64) (bool, error) {
+	activationBlock, ok, err := ledger.GetFeatureActivationBlock(feature)
+	if err != nil || !ok {
+		return false, err
+	}
+	return blockNumber >= activationBlock, nil
+}
+
+// GetFeatureActivations returns the activation block of all the features recorded in the committed state
+func (ledger *Ledger) GetFeatureActivations() (map[LedgerFeature]uint64, error) {
+	itr, err := ledger.state.GetRangeScanIterator(FeaturesChaincodeID, "", "", true)
+	if err != nil {
+		return nil, err
+	}
+	defer itr.Close()
+	activations := make(map[LedgerFeature]uint64)
+	for itr.Next() {
+		k, v := itr.GetKeyValue()
+		activations[LedgerFeature(k)] = decodeToUint64(v)
+	}
+	return activations, nil
+}
+
+// RecordHashAlgorithm records the hash algorithm used for state hashing (see the
+// 'ledger.state.hashAlgorithm' configuration). This is invoked, in the context of
+// a transaction, while creating the genesis block so that a peer configured with
+// a different algorithm refuses to start instead of computing diverging state hashes.
+func (ledger *Ledger) RecordHashAlgorithm() error {
+	return ledger.setSystemState(ConfigChaincodeID, hashAlgorithmKey, []byte(statemgmt.GetActiveHashProvider().Name()))
+}
+
+// checkHashAlgorithm verifies that the hash algorithm in use is the one recorded in
+// the genesis block. Ledgers created before the algorithm was recorded are expected
+// to use the default algorithm.
+func (ledger *Ledger) checkHashAlgorithm() error {
+	configured := statemgmt.GetActiveHashProvider().Name()
+	recorded, err := ledger.state.Get(ConfigChaincodeID, hashAlgorithmKey, true)
+	if err != nil {
+		return err
+	}
+	if recorded == nil {
+		if ledger.GetBlockchainSize() == 0 || configured == statemgmt.DefaultHashAlgorithm {
+			return nil
+		}
+		recorded = []byte(statemgmt.DefaultHashAlgorithm)
+	}
+	if string(recorded) != configured {
+		return fmt.Errorf("The ledger uses the hash algorithm [%s] but [%s] is configured", recorded, configured)
+	}
+	return nil
+}
@@ -19,6 +19,8 @@ package genesis
 import (
 	"sync"
 
+	"github.com/spf13/cast"
+
 	"github.com/spf13/viper"
 )
 
@@ -52,6 +54,16 @@ func getGenesis() map[string]interface{} {
 	return genesis
 }
 
+// getGenesisFeatures returns the ledger features to be activated in the genesis
+// block mapped to their activation block number
+func getGenesisFeatures() map[string]uint64 {
+	features := make(map[string]uint64)
+	for name, block := range cast.ToStringMap(getGenesis()["features"]) {
+		features[name] = uint64(cast.ToInt(block))
+	}
+	return features
+}
+
 func getMode() string {
 	initConfigs()
 	return mode
@@ -60,6 +60,14 @@ func MakeGenesis() error {
 			}
 		}()
 
+		if !genesisBlockExists {
+			if err := recordGenesisLedgerConfig(ledger); err != nil {
+				genesisLogger.Error("Error recording ledger configuration in the genesis block.", err)
+				makeGenesisError = err
+				return
+			}
+		}
+
 		//We are disabling the validity period deployment for now, we shouldn't even allow it if it's enabled in the configuration
 		allowDeployValidityPeriod := false
 
@@ -169,6 +177,29 @@ func MakeGenesis() error {
 	return makeGenesisError
 }
 
+// recordGenesisLedgerConfig records the hash algorithm used for the state and the
+// activation block of the ledger features configured under
+// ledger.blockchain.genesisBlock.features so that all the peers bootstrapped from
+// the same configuration agree on them from block 0
+func recordGenesisLedgerConfig(l *ledger.Ledger) error {
+	features := getGenesisFeatures()
+	genesisLogger.Debug("Genesis ledger features are %v", features)
+	txUUID := "genesis-ledger-config"
+	l.TxBegin(txUUID)
+	if err := l.RecordHashAlgorithm(); err != nil {
+		l.TxFinished(txUUID, false)
+		return err
+	}
+	for feature, activationBlock := range features {
+		if err := l.ActivateFeature(ledger.LedgerFeature(feature), activationBlock); err != nil {
+			l.TxFinished(txUUID, false)
+			return err
+		}
+	}
+	l.TxFinished(txUUID, true)
+	return nil
+}
+
 //BuildLocal builds a given chaincode code
 func BuildLocal(context context.Context, spec *protos.ChaincodeSpec) (*protos.ChaincodeDeploymentSpec, error) {
 	genesisLogger.Debug("Received build request for chaincode spec: %v", spec)
@@ -227,7 +258,7 @@ func DeployLocal(ctx context.Context, spec *protos.ChaincodeSpec, gbexists bool)
 	//chaincode.NewChaincodeSupport(chaincode.DefaultChain, peer.GetPeerEndpoint, false, 120000)
 	// The secHelper is set during creat ChaincodeSupport, so we don't need this step
 	//ctx = context.WithValue(ctx, "security", secCxt)
-	result, err := chaincode.Execute(ctx, chaincode.GetChain(chaincode.DefaultChain), transaction)
+	result, _, err := chaincode.Execute(ctx, chaincode.GetChain(chaincode.DefaultChain), transaction)
 	return transaction, result, err
 }
 
@@ -0,0 +1,48 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ledger
+
+import (
+	"fmt"
+	"time"
+)
+
+// CheckHealth returns an error if the DB of the ledger cannot serve the reads and writes
+// (see db.CheckHealth)
+func (ledger *Ledger) CheckHealth() error {
+	return ledger.openchainDB.CheckHealth()
+}
+
+// CheckCommitProgress returns an error if the transaction-batch in progress began changing
+// the state more than maxAge ago and is still not committed, which a peer that merely has
+// no transactions to process never reports
+func (ledger *Ledger) CheckCommitProgress(maxAge time.Duration) error {
+	stats := ledger.state.GetRuntimeStats()
+	if stats.BatchStarted.IsZero() || time.Since(stats.BatchStarted) <= maxAge {
+		return nil
+	}
+	lastCommit := "none since the peer started"
+	if last := ledger.LastBlockCommitted(); !last.IsZero() {
+		lastCommit = fmt.Sprintf("%s ago", time.Since(last))
+	}
+	if stats.TxInProgress != "" {
+		return fmt.Errorf("The transaction-batch started %s ago is not committed, transaction [%s] in progress for %s, last commit %s",
+			time.Since(stats.BatchStarted), stats.TxInProgress, time.Since(stats.TxInProgressSince), lastCommit)
+	}
+	return fmt.Errorf("The transaction-batch started %s ago with %d transactions is not committed, last commit %s",
+		time.Since(stats.BatchStarted), stats.BatchTxs, lastCommit)
+}
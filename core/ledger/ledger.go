@@ -21,9 +21,10 @@ import (
 	"fmt"
 	"reflect"
 	"sync"
+	"time"
 
-	"github.com/golang/protobuf/proto"
 	"github.com/hyperledger/fabric/core/db"
+	"github.com/hyperledger/fabric/core/errors"
 	"github.com/hyperledger/fabric/core/ledger/statemgmt"
 	"github.com/hyperledger/fabric/core/ledger/statemgmt/state"
 	"github.com/hyperledger/fabric/events/producer"
@@ -46,6 +47,16 @@ const (
 	ErrorTypeOutOfBounds = ErrorType("OutOfBounds")
 	//ErrorTypeResourceNotFound used to indicate if a resource is not found
 	ErrorTypeResourceNotFound = ErrorType("ResourceNotFound")
+	//ErrorTypeValidationRuleViolated used to indicate that a transaction violates a ledger validation rule
+	ErrorTypeValidationRuleViolated = ErrorType("ValidationRuleViolated")
+	//ErrorTypeDiskQuotaExceeded used to indicate that a block is refused because the DB exceeds its hard disk quota
+	ErrorTypeDiskQuotaExceeded = ErrorType("DiskQuotaExceeded")
+	//ErrorTypeNotReady used to indicate that a resource is still being built
+	ErrorTypeNotReady = ErrorType("NotReady")
+	//ErrorTypeStaleRead used to indicate that the state read by a simulated transaction has changed
+	ErrorTypeStaleRead = ErrorType("StaleRead")
+	//ErrorTypeDuplicateTransaction used to indicate that the uuid of a transaction was already used
+	ErrorTypeDuplicateTransaction = ErrorType("DuplicateTransaction")
 )
 
 //Error can be used for throwing an error from ledger code.
@@ -63,6 +74,11 @@ func (ledgerError *Error) Type() ErrorType {
 	return ledgerError.errType
 }
 
+//Code returns the code of the error, which has the string of its type
+func (ledgerError *Error) Code() errors.Code {
+	return errors.Code(ledgerError.errType)
+}
+
 func newLedgerError(errType ErrorType, msg string) *Error {
 	return &Error{errType, msg}
 }
@@ -77,9 +93,23 @@ var (
 
 // Ledger - the struct for openchain ledger
 type Ledger struct {
-	blockchain *blockchain
-	state      *state.State
-	currentID  interface{}
+	blockchain  *blockchain
+	state       *state.State
+	currentID   interface{}
+	openchainDB *db.OpenchainDB
+	chainID     string
+	// quotaStatus is the status of the disk quota of the DB as of the last commit
+	quotaStatus db.QuotaStatus
+	// checkpointer is nil unless checkpoints are enabled
+	checkpointer *checkpointer
+	commits      *commitNotifier
+	private      *privateState
+	simulators   *txSimulators
+	// batchTxUUIDs are the uuids of the transactions of the batch in progress
+	batchTxUUIDs map[string]bool
+	// replayedTxUUIDs are the uuids of the transactions of the batch in progress whose state
+	// changes were replayed from the write-ahead log on startup (see IsReplayedTx)
+	replayedTxUUIDs map[string]bool
 }
 
 var ledger *Ledger
@@ -89,19 +119,55 @@ var once sync.Once
 // GetLedger - gives a reference to a 'singleton' ledger
 func GetLedger() (*Ledger, error) {
 	once.Do(func() {
-		ledger, ledgerError = newLedger()
+		ledger, ledgerError = newLedger(DefaultChainID, db.GetDBHandle())
 	})
 	return ledger, ledgerError
 }
 
-func newLedger() (*Ledger, error) {
-	blockchain, err := newBlockchain()
+func newLedger(chainID string, openchainDB *db.OpenchainDB) (*Ledger, error) {
+	stateConfig, err := loadChainStateConfig(chainID)
+	if err != nil {
+		return nil, err
+	}
+	state := state.NewState(openchainDB.KVStore(), stateConfig)
+	if err := recoverPartialCommit(openchainDB, state); err != nil {
+		return nil, err
+	}
+	blockchain, err := newBlockchain(openchainDB)
+	if err != nil {
+		return nil, err
+	}
+	blockchain.archive, err = newBlockArchiveFromConfig(chainID)
 	if err != nil {
 		return nil, err
 	}
+	if err := initSecondaryIndexes(openchainDB, blockchain.size); err != nil {
+		return nil, err
+	}
 
-	state := state.NewState()
-	return &Ledger{blockchain, state, nil}, nil
+	ledger := &Ledger{blockchain, state, nil, openchainDB, chainID, db.QuotaOK, nil, newCommitNotifier(), newPrivateState(), newTxSimulators(), make(map[string]bool), make(map[string]bool)}
+	for _, txUUID := range state.GetTxUUIDs() {
+		ledger.replayedTxUUIDs[txUUID] = true
+	}
+	if err := ledger.checkHashAlgorithm(); err != nil {
+		return nil, err
+	}
+	if err := initValidationRules(); err != nil {
+		return nil, err
+	}
+	if blockchain.archive != nil {
+		blockchain.archiver = newBlockArchiverFromConfig(blockchain)
+		blockchain.archiver.start()
+	}
+	blockchain.indexBackfiller = newSecondaryIndexesBackfiller(blockchain)
+	blockchain.indexBackfiller.start()
+	if ledger.checkpointer, err = newCheckpointerFromConfig(chainID); err != nil {
+		return nil, err
+	}
+	if ledger.checkpointer != nil {
+		ledger.checkpointer.start()
+	}
+	return ledger, nil
 }
 
 /////////////////// Transaction-batch related methods ///////////////////////////////
@@ -132,7 +198,7 @@ func (ledger *Ledger) GetTXBatchPreviewBlockInfo(id interface{},
 	if err != nil {
 		return nil, err
 	}
-	block := ledger.blockchain.buildBlock(protos.NewBlock(transactions, metadata), stateHash)
+	block := ledger.blockchain.buildBlock(protos.NewBlock(withoutTransient(transactions), metadata), stateHash)
 	info := ledger.blockchain.getBlockchainInfoForBlock(ledger.blockchain.getSize()+1, block)
 	return info, nil
 }
@@ -145,6 +211,13 @@ func (ledger *Ledger) CommitTxBatch(id interface{}, transactions []*protos.Trans
 	if err != nil {
 		return err
 	}
+	start := time.Now()
+
+	if err := ledger.checkDiskQuota(); err != nil {
+		ledger.resetForNextTxGroup(false)
+		ledger.blockchain.blockPersistenceStatus(false)
+		return err
+	}
 
 	stateHash, err := ledger.state.GetHash()
 	if err != nil {
@@ -152,21 +225,26 @@ func (ledger *Ledger) CommitTxBatch(id interface{}, transactions []*protos.Trans
 		ledger.blockchain.blockPersistenceStatus(false)
 		return err
 	}
+	hashed := time.Now()
 
 	writeBatch := gorocksdb.NewWriteBatch()
 	defer writeBatch.Destroy()
-	block := protos.NewBlock(transactions, metadata)
-	block.NonHashData = &protos.NonHashData{TransactionResults: transactionResults}
+	txEffects := ledger.collectTxEffects(transactions)
+	block := protos.NewBlock(withoutTransient(transactions), metadata)
+	block.NonHashData = &protos.NonHashData{TransactionResults: transactionResults, TxEffectsRoot: ComputeTxEffectsRoot(txEffects)}
 	newBlockNumber, err := ledger.blockchain.addPersistenceChangesForNewBlock(context.TODO(), block, stateHash, writeBatch)
 	if err != nil {
 		ledger.resetForNextTxGroup(false)
 		ledger.blockchain.blockPersistenceStatus(false)
 		return err
 	}
-	ledger.state.AddChangesForPersistence(newBlockNumber, writeBatch)
+	addTxEffectsForPersistence(ledger.openchainDB, newBlockNumber, txEffects, writeBatch)
+	ledger.state.AddChangesForPersistence(newBlockNumber, ledger.openchainDB.WrapWriteBatch(writeBatch))
+	ledger.private.addChangesForPersistence(ledger.openchainDB.WrapWriteBatch(writeBatch))
+	addCommitMarkerForPersistence(newBlockNumber, ledger.blockchain.lastProcessedBlock.blockHash, ledger.openchainDB.WrapWriteBatch(writeBatch))
 	opt := gorocksdb.NewDefaultWriteOptions()
 	defer opt.Destroy()
-	dbErr := db.GetDBHandle().DB.Write(opt, writeBatch)
+	dbErr := ledger.openchainDB.DB.Write(opt, writeBatch)
 	if dbErr != nil {
 		ledger.resetForNextTxGroup(false)
 		ledger.blockchain.blockPersistenceStatus(false)
@@ -175,8 +253,17 @@ func (ledger *Ledger) CommitTxBatch(id interface{}, transactions []*protos.Trans
 
 	ledger.resetForNextTxGroup(true)
 	ledger.blockchain.blockPersistenceStatus(true)
-
-	sendProducerBlockEvent(block)
+	if ledger.checkpointer != nil {
+		ledger.checkpointer.blockCommitted(ledger, newBlockNumber, ledger.blockchain.previousBlockHash, stateHash)
+	}
+	ledger.commits.blockCommitted()
+	blockCommitLatency.ObserveSince(start)
+	committedBlocks.Inc()
+	committedTransactions.Add(float64(len(block.Transactions)))
+	traceCommit(newBlockNumber, block.Transactions, start, hashed, time.Now())
+
+	ledger.sendProducerBlockEvent(newBlockNumber, block)
+	ledger.sendProducerBlockCommitEvent(newBlockNumber, stateHash, txEffects)
 	return nil
 }
 
@@ -195,12 +282,14 @@ func (ledger *Ledger) RollbackTxBatch(id interface{}) error {
 // TxBegin - Marks the begin of a new transaction in the ongoing batch
 func (ledger *Ledger) TxBegin(txUUID string) {
 	ledger.state.TxBegin(txUUID)
+	ledger.private.txBegin(txUUID)
 }
 
 // TxFinished - Marks the finish of the on-going transaction.
 // If txSuccessful is false, the state changes made by the transaction are discarded
 func (ledger *Ledger) TxFinished(txUUID string, txSuccessful bool) {
 	ledger.state.TxFinish(txUUID, txSuccessful)
+	ledger.private.txFinish(txUUID, txSuccessful)
 }
 
 /////////////////// world-state related methods /////////////////////////////////////
@@ -220,6 +309,21 @@ func (ledger *Ledger) GetTempStateHashWithTxDeltaStateHashes() ([]byte, map[stri
 	return stateHash, ledger.state.GetTxStateDeltaHash(), err
 }
 
+// GetTempTxStateDeltaAggregateHash returns a hash over the state delta hashes (as returned by method
+// GetTempStateHashWithTxDeltaStateHashes) of the successful txs of the current transaction-batch, taken
+// in the order of execution. Unlike the map, this can be compared across peers in a single step
+func (ledger *Ledger) GetTempTxStateDeltaAggregateHash() []byte {
+	return ledger.state.GetTxStateDeltaAggregateHash()
+}
+
+// GetTempTxUUIDs returns the uuids of the successful txs of the current transaction-batch, in the order
+// of execution. When the write-ahead log is enabled ('ledger.state.wal.enabled'), after a restart these
+// include the txs of the batch in progress before the peer stopped, which must not be executed again
+// (see IsReplayedTx)
+func (ledger *Ledger) GetTempTxUUIDs() []string {
+	return ledger.state.GetTxUUIDs()
+}
+
 // GetState get state for chaincodeID and key. If committed is false, this first looks in memory
 // and if missing, pulls from db.  If committed is true, this pulls from the db only.
 func (ledger *Ledger) GetState(chaincodeID string, key string, committed bool) ([]byte, error) {
@@ -235,22 +339,55 @@ func (ledger *Ledger) GetStateRangeScanIterator(chaincodeID string, startKey str
 	return ledger.state.GetRangeScanIterator(chaincodeID, startKey, endKey, committed)
 }
 
+// GetStateQueryIterator returns an iterator to get the keys (and values) of a chaincodeID matching a
+// rich query, in the syntax of the state implementation. The query is run against the committed state
+// only, and fails with statemgmt.ErrQueryNotSupported if the state implementation does not support it
+func (ledger *Ledger) GetStateQueryIterator(chaincodeID string, query string) (statemgmt.RangeScanIterator, error) {
+	return ledger.state.GetQueryResultIterator(chaincodeID, query)
+}
+
 // SetState sets state to given value for chaincodeID and key. Does not immideatly writes to DB
 func (ledger *Ledger) SetState(chaincodeID string, key string, value []byte) error {
 	if key == "" || value == nil {
 		return newLedgerError(ErrorTypeInvalidArgument,
 			fmt.Sprintf("An empty string key or a nil value is not supported. Method invoked with key='%s', value='%#v'", key, value))
 	}
+	if err := checkNotSystemNamespace(chaincodeID); err != nil {
+		return err
+	}
 	return ledger.state.Set(chaincodeID, key, value)
 }
 
 // DeleteState tracks the deletion of state for chaincodeID and key. Does not immideatly writes to DB
 func (ledger *Ledger) DeleteState(chaincodeID string, key string) error {
+	if err := checkNotSystemNamespace(chaincodeID); err != nil {
+		return err
+	}
 	return ledger.state.Delete(chaincodeID, key)
 }
 
+// setSystemState sets the value of a ledger-internal record. Unlike SetState,
+// this is allowed to write into the system namespaces
+func (ledger *Ledger) setSystemState(chaincodeID string, key string, value []byte) error {
+	if !statemgmt.IsSystemNamespace(chaincodeID) {
+		return newLedgerError(ErrorTypeInvalidArgument, fmt.Sprintf("[%s] is not a system namespace", chaincodeID))
+	}
+	return ledger.state.Set(chaincodeID, key, value)
+}
+
+func checkNotSystemNamespace(chaincodeID string) error {
+	if statemgmt.IsSystemNamespace(chaincodeID) {
+		return newLedgerError(ErrorTypeInvalidArgument,
+			fmt.Sprintf("Namespaces starting with [%s] are reserved for the ledger. Write to [%s] not allowed", statemgmt.SystemNamespacePrefix, chaincodeID))
+	}
+	return nil
+}
+
 // CopyState copies all the key-values from sourceChaincodeID to destChaincodeID
 func (ledger *Ledger) CopyState(sourceChaincodeID string, destChaincodeID string) error {
+	if err := checkNotSystemNamespace(destChaincodeID); err != nil {
+		return err
+	}
 	return ledger.state.CopyState(sourceChaincodeID, destChaincodeID)
 }
 
@@ -263,14 +400,59 @@ func (ledger *Ledger) GetStateMultipleKeys(chaincodeID string, keys []string, co
 // SetStateMultipleKeys sets the values for the multiple keys.
 // This method is mainly to amortize the cost of grpc communication between chaincode shim peer
 func (ledger *Ledger) SetStateMultipleKeys(chaincodeID string, kvs map[string][]byte) error {
+	if err := checkNotSystemNamespace(chaincodeID); err != nil {
+		return err
+	}
 	return ledger.state.SetMultipleKeys(chaincodeID, kvs)
 }
 
+// GetRootStateHashDetail returns the crypto-hash of the committed world state along with
+// the crypto-hashes of the children of the root of the state tree. Comparing the details
+// reported by two peers tells which subtree of the state diverged.
+func (ledger *Ledger) GetRootStateHashDetail() (*statemgmt.StateHashDetail, error) {
+	return ledger.state.GetRootStateHashDetail()
+}
+
+// PruneState removes the nodes of the state data structure that are no longer reachable
+// from the current root (see 'statemgmt.Pruner'). This must not be invoked while a
+// transaction-batch is in progress.
+func (ledger *Ledger) PruneState() (*statemgmt.PruneStats, error) {
+	if ledger.currentID != nil {
+		return nil, newLedgerError(ErrorTypeInvalidArgument, "Cannot prune the state while a transaction-batch is in progress")
+	}
+	return ledger.state.PruneOrphanedNodes()
+}
+
+// GetStateStats returns statistics about the committed world state such as the
+// number of keys and bytes per chaincode and the size of the retained state deltas
+func (ledger *Ledger) GetStateStats() (*statemgmt.StateStats, error) {
+	return ledger.state.GetStateStats()
+}
+
+// GetStateRuntimeStats returns the statistics of the transaction-batch in progress, such
+// as the transaction in progress and the number of keys changed in memory. Unlike the
+// other methods of the ledger, it may be called while transactions are executed
+func (ledger *Ledger) GetStateRuntimeStats() state.RuntimeStats {
+	return ledger.state.GetRuntimeStats()
+}
+
+// GetDBProperties returns the values of the rocksdb properties for each column family of
+// the DB, such as db.DebugProperties
+func (ledger *Ledger) GetDBProperties(names []string, cfNames ...string) ([]db.Property, error) {
+	return ledger.openchainDB.GetProperties(names, cfNames...)
+}
+
+// GetDBSpaceReport returns the disk space used by each column family of the DB along
+// with the space that compactions are estimated to reclaim
+func (ledger *Ledger) GetDBSpaceReport() (*db.SpaceReport, error) {
+	return ledger.openchainDB.GetSpaceReport()
+}
+
 // GetStateSnapshot returns a point-in-time view of the global state for the current block. This
 // should be used when transferring the state from one peer to another peer. You must call
 // stateSnapshot.Release() once you are done with the snapsnot to free up resources.
 func (ledger *Ledger) GetStateSnapshot() (*state.StateSnapshot, error) {
-	dbSnapshot := db.GetDBHandle().GetSnapshot()
+	dbSnapshot := ledger.openchainDB.KVStore().NewSnapshot()
 	blockHeight, err := fetchBlockchainSizeFromSnapshot(dbSnapshot)
 	if err != nil {
 		dbSnapshot.Release()
@@ -278,11 +460,34 @@ func (ledger *Ledger) GetStateSnapshot() (*state.StateSnapshot, error) {
 	}
 	if 0 == blockHeight {
 		dbSnapshot.Release()
-		return nil, fmt.Errorf("Blockchain has no blocks, cannot determine block number")
+		return nil, errors.New(errors.NotFound, "Blockchain has no blocks, cannot determine block number")
 	}
 	return ledger.state.GetSnapshot(blockHeight-1, dbSnapshot)
 }
 
+// GetStateSubtreeSnapshot returns a point-in-time view of the key-values held by the subtrees
+// of the given children of the root of the state tree (see GetRootStateHashDetail), which lets
+// two peers compare only the subtrees whose crypto-hashes differ. You must call
+// stateSnapshot.Release() once you are done with the snapshot to free up resources.
+func (ledger *Ledger) GetStateSubtreeSnapshot(children []int) (*state.StateSnapshot, error) {
+	dbSnapshot := ledger.openchainDB.KVStore().NewSnapshot()
+	blockHeight, err := fetchBlockchainSizeFromSnapshot(dbSnapshot)
+	if err != nil {
+		dbSnapshot.Release()
+		return nil, err
+	}
+	if 0 == blockHeight {
+		dbSnapshot.Release()
+		return nil, errors.New(errors.NotFound, "Blockchain has no blocks, cannot determine block number")
+	}
+	snapshot, err := ledger.state.GetSubtreeSnapshot(blockHeight-1, dbSnapshot, children)
+	if err != nil {
+		dbSnapshot.Release()
+		return nil, err
+	}
+	return snapshot, nil
+}
+
 // GetStateDelta will return the state delta for the specified block if
 // available.  If not available because it has been discarded, returns nil,nil.
 func (ledger *Ledger) GetStateDelta(blockNumber uint64) (*statemgmt.StateDelta, error) {
@@ -292,6 +497,16 @@ func (ledger *Ledger) GetStateDelta(blockNumber uint64) (*statemgmt.StateDelta,
 	return ledger.state.FetchStateDeltaFromDB(blockNumber)
 }
 
+// PutStateDelta stores the state delta of the block, to be returned by GetStateDelta, without
+// applying it to the state. This should only be used when rebuilding a ledger from a dump
+// taken with GetStateSnapshot and GetStateDelta
+func (ledger *Ledger) PutStateDelta(blockNumber uint64, delta *statemgmt.StateDelta) error {
+	if blockNumber >= ledger.GetBlockchainSize() {
+		return ErrOutOfBounds
+	}
+	return ledger.state.PutStateDelta(blockNumber, delta)
+}
+
 // ApplyStateDelta applies a state delta to the current state. This is an
 // in memory change only. You must call ledger.CommitStateDelta to persist
 // the change to the DB.
@@ -380,11 +595,15 @@ func (ledger *Ledger) GetTransactionByUUID(txUUID string) (*protos.Transaction,
 // PutRawBlock puts a raw block on the chain. This function should only be
 // used for synchronization between peers.
 func (ledger *Ledger) PutRawBlock(block *protos.Block, blockNumber uint64) error {
+	if err := ledger.checkDiskQuota(); err != nil {
+		return err
+	}
 	err := ledger.blockchain.persistRawBlock(block, blockNumber)
 	if err != nil {
 		return err
 	}
-	sendProducerBlockEvent(block)
+	ledger.commits.blockCommitted()
+	ledger.sendProducerBlockEvent(blockNumber, block)
 	return nil
 }
 
@@ -437,14 +656,14 @@ func (ledger *Ledger) VerifyChain(highBlock, lowBlock uint64) (uint64, error) {
 
 func (ledger *Ledger) checkValidIDBegin() error {
 	if ledger.currentID != nil {
-		return fmt.Errorf("Another TxGroup [%s] already in-progress", ledger.currentID)
+		return errors.Errorf(errors.TxInProgress, "Another TxGroup [%s] already in-progress", ledger.currentID)
 	}
 	return nil
 }
 
 func (ledger *Ledger) checkValidIDCommitORRollback(id interface{}) error {
 	if !reflect.DeepEqual(ledger.currentID, id) {
-		return fmt.Errorf("Another TxGroup [%s] already in-progress", ledger.currentID)
+		return errors.Errorf(errors.TxInProgress, "Another TxGroup [%s] already in-progress", ledger.currentID)
 	}
 	return nil
 }
@@ -453,31 +672,29 @@ func (ledger *Ledger) resetForNextTxGroup(txCommited bool) {
 	ledgerLogger.Debug("resetting ledger state for next transaction batch")
 	ledger.currentID = nil
 	ledger.state.ClearInMemoryChanges(txCommited)
+	ledger.private.clearInMemoryChanges()
+	ledger.batchTxUUIDs = make(map[string]bool)
+	ledger.replayedTxUUIDs = make(map[string]bool)
 }
 
-func sendProducerBlockEvent(block *protos.Block) {
-
-	// Remove payload from deploy transactions. This is done to make block
-	// events more lightweight as the payload for these types of transactions
-	// can be very large.
-	blockTransactions := block.GetTransactions()
-	for _, transaction := range blockTransactions {
-		if transaction.Type == protos.Transaction_CHAINCODE_DEPLOY {
-			deploymentSpec := &protos.ChaincodeDeploymentSpec{}
-			err := proto.Unmarshal(transaction.Payload, deploymentSpec)
-			if err != nil {
-				ledgerLogger.Error(fmt.Sprintf("Error unmarshalling deployment transaction for block event: %s", err))
-				continue
-			}
-			deploymentSpec.CodePackage = nil
-			deploymentSpecBytes, err := proto.Marshal(deploymentSpec)
-			if err != nil {
-				ledgerLogger.Error(fmt.Sprintf("Error marshalling deployment transaction for block event: %s", err))
-				continue
-			}
-			transaction.Payload = deploymentSpecBytes
-		}
+func (ledger *Ledger) sendProducerBlockEvent(blockNumber uint64, block *protos.Block) {
+	// the events do not identify the chain yet, hence, only the blocks of the default chain are sent
+	if ledger.chainID != DefaultChainID {
+		return
 	}
+	removeDeployPayloads(block)
+	producer.SendBlockEvent(blockNumber, producer.CreateBlockEvent(block))
+}
 
-	producer.Send(producer.CreateBlockEvent(block))
+// sendProducerBlockCommitEvent sends the crypto-hashes of the changes made by the successful txs of
+// the committed block, so that listeners can tell the txs which changed the state from the no-ops
+func (ledger *Ledger) sendProducerBlockCommitEvent(blockNumber uint64, stateHash []byte, txEffects []*TxEffects) {
+	if ledger.chainID != DefaultChainID {
+		return
+	}
+	blockCommit := &protos.BlockCommit{BlockNumber: blockNumber, StateHash: stateHash}
+	for _, effects := range txEffects {
+		blockCommit.TxStateDeltaHashes = append(blockCommit.TxStateDeltaHashes, &protos.TxStateDeltaHash{TxUuid: effects.TxUUID, StateDeltaHash: effects.StateDeltaHash})
+	}
+	producer.Send(producer.CreateBlockCommitEvent(blockCommit))
 }
@@ -18,12 +18,21 @@ package ledger
 
 import (
 	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
 	"strconv"
 	"testing"
+	"time"
 
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/db"
+	"github.com/hyperledger/fabric/core/db/kvstore"
 	"github.com/hyperledger/fabric/core/ledger/statemgmt"
 	"github.com/hyperledger/fabric/core/ledger/testutil"
+	"github.com/hyperledger/fabric/core/util"
 	"github.com/hyperledger/fabric/protos"
+	"github.com/spf13/viper"
 )
 
 func TestLedgerCommit(t *testing.T) {
@@ -746,6 +755,15 @@ func TestTransactionResult(t *testing.T) {
 
 }
 
+func TestGetStateQueryIterator(t *testing.T) {
+	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
+	ledger := ledgerTestWrapper.ledger
+
+	// the bucket tree does not support rich queries
+	_, err := ledger.GetStateQueryIterator("chaincodeID1", "{}")
+	testutil.AssertSame(t, err, statemgmt.ErrQueryNotSupported)
+}
+
 func TestRangeScanIterator(t *testing.T) {
 	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
 	ledger := ledgerTestWrapper.ledger
@@ -956,3 +974,1358 @@ func TestLedgerInvalidInput(t *testing.T) {
 	value, _ := l.GetState("chaincodeID1", "key1", true)
 	testutil.AssertEquals(t, value, []byte("value1"))
 }
+
+func TestLedgerFeatureActivation(t *testing.T) {
+	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
+	ledger := ledgerTestWrapper.ledger
+
+	ledger.BeginTxBatch(0)
+	ledger.TxBegin("txUuid")
+	testutil.AssertNoError(t, ledger.ActivateFeature(FeatureKeyMetadata, 2), "Error activating feature")
+	testutil.AssertError(t, ledger.ActivateFeature(LedgerFeature("unknownFeature"), 2), "Expected error for unknown feature")
+	testutil.AssertError(t, ledger.SetState(FeaturesChaincodeID, string(FeatureKeyMetadata), encodeUint64(0)), "Expected error for write to features namespace")
+	testutil.AssertError(t, ledger.DeleteState(FeaturesChaincodeID, string(FeatureKeyMetadata)), "Expected error for delete in features namespace")
+	ledger.TxFinished("txUuid", true)
+	transaction, _ := buildTestTx(t)
+	ledger.CommitTxBatch(0, []*protos.Transaction{transaction}, nil, []byte("proof"))
+
+	active, err := ledger.IsFeatureActive(FeatureKeyMetadata, 1)
+	testutil.AssertNoError(t, err, "Error checking feature")
+	testutil.AssertEquals(t, active, false)
+	active, _ = ledger.IsFeatureActive(FeatureKeyMetadata, 2)
+	testutil.AssertEquals(t, active, true)
+	active, _ = ledger.IsFeatureActive(LedgerFeature("unknownFeature"), 2)
+	testutil.AssertEquals(t, active, false)
+
+	activations, err := ledger.GetFeatureActivations()
+	testutil.AssertNoError(t, err, "Error getting feature activations")
+	testutil.AssertEquals(t, activations, map[LedgerFeature]uint64{FeatureKeyMetadata: 2})
+
+	ledger.BeginTxBatch(1)
+	ledger.TxBegin("txUuid")
+	testutil.AssertError(t, ledger.ActivateFeature(FeatureKeyMetadata, 0), "Expected error for activation block in the past")
+	ledger.TxFinished("txUuid", false)
+	ledger.RollbackTxBatch(1)
+}
+
+func TestLedgerSystemNamespaceWrites(t *testing.T) {
+	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
+	ledger := ledgerTestWrapper.ledger
+
+	ledger.BeginTxBatch(0)
+	ledger.TxBegin("txUuid")
+	testutil.AssertNoError(t, ledger.SetState("chaincode1", "key1", []byte("value1")), "Error setting state")
+	testutil.AssertError(t, ledger.SetState("_sys_savepoint", "key1", []byte("value1")), "Expected error for write to system namespace")
+	testutil.AssertError(t, ledger.SetStateMultipleKeys("_sys", map[string][]byte{"key1": []byte("value1")}), "Expected error for write to system namespace")
+	testutil.AssertError(t, ledger.DeleteState("_sys_savepoint", "key1"), "Expected error for delete in system namespace")
+	testutil.AssertError(t, ledger.CopyState("chaincode1", "_sys_copy"), "Expected error for copy into system namespace")
+	ledger.TxFinished("txUuid", true)
+	hashWithoutSystemRecords := ledgerTestWrapper.GetTempStateHash()
+
+	// System records contribute to the state hash like chaincode records
+	ledger.TxBegin("txUuid2")
+	testutil.AssertNoError(t, ledger.ActivateFeature(FeatureKeyMetadata, 5), "Error activating feature")
+	ledger.TxFinished("txUuid2", true)
+	testutil.AssertNotEquals(t, ledgerTestWrapper.GetTempStateHash(), hashWithoutSystemRecords)
+	ledger.RollbackTxBatch(0)
+}
+
+func TestLedgerHashAlgorithmRecordedInState(t *testing.T) {
+	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
+	ledger := ledgerTestWrapper.ledger
+
+	ledger.BeginTxBatch(0)
+	ledger.TxBegin("txUuid")
+	testutil.AssertNoError(t, ledger.RecordHashAlgorithm(), "Error recording hash algorithm")
+	ledger.TxFinished("txUuid", true)
+	transaction, _ := buildTestTx(t)
+	ledger.CommitTxBatch(0, []*protos.Transaction{transaction}, nil, []byte("proof"))
+	testutil.AssertEquals(t, ledgerTestWrapper.GetState(ConfigChaincodeID, "hashAlgorithm", true), []byte(statemgmt.DefaultHashAlgorithm))
+	testutil.AssertNoError(t, ledger.checkHashAlgorithm(), "Unexpected hash algorithm mismatch")
+
+	defaultProvider := statemgmt.GetActiveHashProvider()
+	defer statemgmt.SetHashProvider(defaultProvider)
+	sha2, _ := statemgmt.GetHashProvider("SHA2_256")
+	statemgmt.SetHashProvider(sha2)
+	testutil.AssertError(t, ledger.checkHashAlgorithm(), "Expected an error for a hash algorithm mismatch")
+}
+
+func TestLedgerKeyProvenance(t *testing.T) {
+	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
+	ledger := ledgerTestWrapper.ledger
+
+	commitTx := func(blockNumber uint64, chaincodeName string, work func()) string {
+		uuid := util.GenerateUUID()
+		tx, err := protos.NewTransaction(protos.ChaincodeID{Name: chaincodeName}, uuid, "anyfunction", []string{})
+		testutil.AssertNoError(t, err, "Error building transaction")
+		ledger.BeginTxBatch(blockNumber)
+		ledger.TxBegin(uuid)
+		work()
+		ledger.TxFinished(uuid, true)
+		ledger.CommitTxBatch(blockNumber, []*protos.Transaction{tx}, nil, []byte("proof"))
+		return uuid
+	}
+	uuid0 := commitTx(0, "chaincode1", func() { ledger.SetState("chaincode1", "key1", []byte("value1")) })
+	commitTx(1, "chaincode1", func() { ledger.SetState("chaincode1", "key2", []byte("value2")) })
+	uuid2 := commitTx(2, "chaincode2", func() { ledger.SetState("chaincode1", "key1", []byte("value1_2")) })
+	uuid3 := commitTx(3, "chaincode1", func() { ledger.DeleteState("chaincode1", "key1") })
+
+	// every transaction of a block which writes the key is part of the chain
+	uuid4, uuid5 := util.GenerateUUID(), util.GenerateUUID()
+	tx4, _ := protos.NewTransaction(protos.ChaincodeID{Name: "chaincode1"}, uuid4, "anyfunction", []string{})
+	tx5, _ := protos.NewTransaction(protos.ChaincodeID{Name: "chaincode2"}, uuid5, "anyfunction", []string{})
+	ledger.BeginTxBatch(4)
+	ledger.TxBegin(uuid4)
+	ledger.SetState("chaincode1", "key1", []byte("value1_4"))
+	ledger.TxFinished(uuid4, true)
+	ledger.TxBegin(uuid5)
+	ledger.SetState("chaincode1", "key1", []byte("value1_5"))
+	ledger.TxFinished(uuid5, true)
+	ledger.CommitTxBatch(4, []*protos.Transaction{tx4, tx5}, nil, []byte("proof"))
+
+	entries, err := ledger.GetKeyProvenance("chaincode1", "key1")
+	testutil.AssertNoError(t, err, "Error getting key provenance")
+	testutil.AssertEquals(t, entries, []*ProvenanceEntry{
+		{0, uuid0, "chaincode1", statemgmt.ComputeCryptoHash([]byte("value1"))},
+		{2, uuid2, "chaincode2", statemgmt.ComputeCryptoHash([]byte("value1_2"))},
+		{3, uuid3, "chaincode1", nil},
+		{4, uuid4, "chaincode1", statemgmt.ComputeCryptoHash([]byte("value1_4"))},
+		{4, uuid5, "chaincode2", statemgmt.ComputeCryptoHash([]byte("value1_5"))},
+	})
+
+	entries, err = ledger.GetKeyProvenance("chaincode1", "key3")
+	testutil.AssertNoError(t, err, "Error getting key provenance")
+	testutil.AssertEquals(t, len(entries), 0)
+}
+
+func TestLedgerGetHistoryForKey(t *testing.T) {
+	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
+	ledger := ledgerTestWrapper.ledger
+
+	commitTx := func(blockNumber uint64, work func()) string {
+		uuid := util.GenerateUUID()
+		tx, err := protos.NewTransaction(protos.ChaincodeID{Name: "chaincode1"}, uuid, "anyfunction", []string{})
+		testutil.AssertNoError(t, err, "Error building transaction")
+		ledger.BeginTxBatch(blockNumber)
+		ledger.TxBegin(uuid)
+		work()
+		ledger.TxFinished(uuid, true)
+		ledger.CommitTxBatch(blockNumber, []*protos.Transaction{tx}, nil, []byte("proof"))
+		return uuid
+	}
+	uuid0 := commitTx(0, func() { ledger.SetState("chaincode1", "key1", []byte("value1")) })
+	commitTx(1, func() { ledger.SetState("chaincode1", "key2", []byte("value2")) })
+	uuid2 := commitTx(2, func() { ledger.SetState("chaincode1", "key1", []byte("value1_2")) })
+	uuid3 := commitTx(3, func() { ledger.DeleteState("chaincode1", "key1") })
+
+	history, err := ledger.GetHistoryForKey("chaincode1", "key1")
+	testutil.AssertNoError(t, err, "Error getting key history")
+	testutil.AssertEquals(t, history, []*protos.KeyModification{
+		{TxUUID: uuid0, BlockNumber: 0, Value: []byte("value1")},
+		{TxUUID: uuid2, BlockNumber: 2, Value: []byte("value1_2")},
+		{TxUUID: uuid3, BlockNumber: 3, IsDelete: true},
+	})
+
+	history, err = ledger.GetHistoryForKey("chaincode1", "key3")
+	testutil.AssertNoError(t, err, "Error getting key history")
+	testutil.AssertEquals(t, len(history), 0)
+}
+
+func TestLedgerPrivateState(t *testing.T) {
+	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
+	ledger := ledgerTestWrapper.ledger
+
+	ledger.BeginTxBatch(0)
+	ledger.TxBegin("txUuid1")
+	testutil.AssertNoError(t, ledger.SetPrivateState("chaincode1", "price", []byte("100")), "Error setting private state")
+	testutil.AssertNoError(t, ledger.SetState("chaincode1", "item", []byte("sold")), "Error setting state")
+	testutil.AssertEquals(t, ledgerTestWrapper.GetPrivateState("chaincode1", "price", false), []byte("100"))
+	testutil.AssertNil(t, ledgerTestWrapper.GetPrivateState("chaincode1", "price", true))
+	ledger.TxFinished("txUuid1", true)
+
+	ledger.TxBegin("txUuid2")
+	testutil.AssertNoError(t, ledger.SetPrivateState("chaincode1", "discount", []byte("10")), "Error setting private state")
+	ledger.TxFinished("txUuid2", false)
+
+	// the private state is not hashed into the world state
+	stateHash, err := ledger.GetTempStateHash()
+	testutil.AssertNoError(t, err, "Error getting state hash")
+	ledger.TxBegin("txUuid3")
+	testutil.AssertNoError(t, ledger.SetPrivateState("chaincode1", "cost", []byte("80")), "Error setting private state")
+	ledger.TxFinished("txUuid3", true)
+	stateHashAfter, err := ledger.GetTempStateHash()
+	testutil.AssertNoError(t, err, "Error getting state hash")
+	testutil.AssertEquals(t, stateHashAfter, stateHash)
+
+	transient := []*protos.TransientField{{Key: "price", Value: []byte("100")}}
+	tx, err := protos.NewChaincodeExecute(&protos.ChaincodeInvocationSpec{
+		ChaincodeSpec: &protos.ChaincodeSpec{ChaincodeID: &protos.ChaincodeID{Name: "chaincode1"}, CtorMsg: &protos.ChaincodeInput{Function: "sell"}},
+		Transient:     transient}, "txUuid1", protos.Transaction_CHAINCODE_INVOKE)
+	testutil.AssertNoError(t, err, "Error building transaction")
+	testutil.AssertEquals(t, tx.Transient, transient)
+	testutil.AssertNoError(t, ledger.CommitTxBatch(0, []*protos.Transaction{tx}, nil, []byte("proof")), "Error committing batch")
+
+	testutil.AssertEquals(t, ledgerTestWrapper.GetPrivateState("chaincode1", "price", true), []byte("100"))
+	testutil.AssertEquals(t, ledgerTestWrapper.GetPrivateState("chaincode1", "cost", true), []byte("80"))
+	testutil.AssertNil(t, ledgerTestWrapper.GetPrivateState("chaincode1", "discount", true))
+	testutil.AssertNil(t, ledgerTestWrapper.GetState("chaincode1", "price", true))
+
+	// the transient data is passed along with the transaction but not written in the block
+	testutil.AssertEquals(t, len(tx.Transient), 1)
+	block := ledgerTestWrapper.GetBlockByNumber(0)
+	testutil.AssertNil(t, block.Transactions[0].Transient)
+	testutil.AssertEquals(t, block.Transactions[0].TransientHash, protos.ComputeTransientHash(transient))
+
+	ledger.BeginTxBatch(1)
+	ledger.TxBegin("txUuid4")
+	testutil.AssertNoError(t, ledger.DeletePrivateState("chaincode1", "price"), "Error deleting private state")
+	testutil.AssertNil(t, ledgerTestWrapper.GetPrivateState("chaincode1", "price", false))
+	ledger.TxFinished("txUuid4", true)
+	ledger.RollbackTxBatch(1)
+	testutil.AssertEquals(t, ledgerTestWrapper.GetPrivateState("chaincode1", "price", false), []byte("100"))
+
+	// writes outside a transaction are rejected
+	testutil.AssertError(t, ledger.SetPrivateState("chaincode1", "price", []byte("90")), "Expected error setting private state outside a transaction")
+}
+
+func TestLedgerChaincodeInstantiation(t *testing.T) {
+	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
+	ledger := ledgerTestWrapper.ledger
+
+	// a chaincode that has never been upgraded runs the code of its deploy transaction
+	txUUID, err := ledger.GetChaincodeDeployment("chaincode1", true)
+	testutil.AssertNoError(t, err, "Error getting chaincode deployment")
+	testutil.AssertEquals(t, txUUID, "chaincode1")
+	instantiation, err := ledger.GetChaincodeInstantiation("chaincode1", true)
+	testutil.AssertNoError(t, err, "Error getting chaincode instantiation")
+	testutil.AssertNil(t, instantiation)
+
+	upgrade := &protos.ChaincodeInstantiation{Name: "chaincode1", Version: 2, CodeHash: "hash2", EndorsementPolicy: "policy1", TxUUID: "txUuid1"}
+	ledger.BeginTxBatch(0)
+	ledger.TxBegin("txUuid1")
+	testutil.AssertNoError(t, ledger.SetChaincodeInstantiation(upgrade), "Error setting chaincode instantiation")
+	ledger.SetState("chaincode1", "key1", []byte("value1"))
+	ledger.TxFinished("txUuid1", true)
+	txUUID, _ = ledger.GetChaincodeDeployment("chaincode1", false)
+	testutil.AssertEquals(t, txUUID, "txUuid1")
+	txUUID, _ = ledger.GetChaincodeDeployment("chaincode1", true)
+	testutil.AssertEquals(t, txUUID, "chaincode1")
+	tx, _ := buildTestTx(t)
+	ledger.CommitTxBatch(0, []*protos.Transaction{tx}, nil, []byte("proof"))
+
+	instantiation, _ = ledger.GetChaincodeInstantiation("chaincode1", true)
+	testutil.AssertEquals(t, instantiation, upgrade)
+	txUUID, _ = ledger.GetChaincodeDeployment("chaincode1", true)
+	testutil.AssertEquals(t, txUUID, "txUuid1")
+
+	// a failed upgrade leaves the chaincode on its previous code
+	ledger.BeginTxBatch(1)
+	ledger.TxBegin("txUuid2")
+	testutil.AssertNoError(t, ledger.SetChaincodeInstantiation(&protos.ChaincodeInstantiation{Name: "chaincode1", Version: 3, TxUUID: "txUuid2"}),
+		"Error setting chaincode instantiation")
+	ledger.TxFinished("txUuid2", false)
+	txUUID, _ = ledger.GetChaincodeDeployment("chaincode1", false)
+	testutil.AssertEquals(t, txUUID, "txUuid1")
+	ledger.RollbackTxBatch(1)
+
+	testutil.AssertError(t, ledger.SetChaincodeInstantiation(&protos.ChaincodeInstantiation{Name: LifecycleChaincodeID, TxUUID: "txUuid3"}),
+		"Expected error instantiating a system namespace")
+	testutil.AssertError(t, ledger.SetChaincodeInstantiation(&protos.ChaincodeInstantiation{Name: "chaincode1"}),
+		"Expected error for an empty deployment transaction")
+}
+
+func TestLedgerValidationRules(t *testing.T) {
+	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
+	ledger := ledgerTestWrapper.ledger
+
+	// a rule that does not allow decreasing the value of 'chaincode1/counter'
+	testutil.AssertNoError(t, RegisterValidationRule(NewValidationRule("testMonotonicCounter", func(ctx *TxValidationContext) error {
+		if ctx.WriteSet.Get("chaincode1", "counter") == nil {
+			return nil
+		}
+		previous, _ := ctx.GetPreviousState("chaincode1", "counter")
+		current, _ := ctx.GetState("chaincode1", "counter")
+		if bytes.Compare(current, previous) < 0 {
+			return fmt.Errorf("counter decreased from [%s] to [%s]", previous, current)
+		}
+		return nil
+	})), "Error registering validation rule")
+	testutil.AssertError(t, RegisterValidationRule(NewValidationRule("testMonotonicCounter", nil)), "Expected error on duplicate registration")
+	testutil.AssertError(t, enableValidationRules([]string{"testUnknownRule"}), "Expected error for an unregistered rule")
+	testutil.AssertNoError(t, enableValidationRules([]string{"testMonotonicCounter"}), "Error enabling validation rules")
+	defer enableValidationRules(nil)
+
+	ledger.BeginTxBatch(1)
+	ledger.TxBegin("txUuid1")
+	ledger.SetState("chaincode1", "counter", []byte("5"))
+	testutil.AssertNoError(t, ledger.ValidateTx("txUuid1"), "Unexpected rule violation")
+	ledger.TxFinished("txUuid1", true)
+
+	ledger.TxBegin("txUuid2")
+	ledger.SetState("chaincode1", "counter", []byte("3"))
+	err := ledger.ValidateTx("txUuid2")
+	testutil.AssertError(t, err, "Expected rule violation")
+	testutil.AssertEquals(t, err.(*Error).Type(), ErrorTypeValidationRuleViolated)
+	ledger.TxFinished("txUuid2", false)
+
+	ledger.TxBegin("txUuid3")
+	ledger.SetState("chaincode2", "counter", []byte("1"))
+	testutil.AssertNoError(t, ledger.ValidateTx("txUuid3"), "Unexpected rule violation")
+	ledger.TxFinished("txUuid3", true)
+	ledger.CommitTxBatch(1, []*protos.Transaction{}, nil, []byte("proof"))
+
+	testutil.AssertEquals(t, ledgerTestWrapper.GetState("chaincode1", "counter", true), []byte("5"))
+	testutil.AssertEquals(t, ledgerTestWrapper.GetState("chaincode2", "counter", true), []byte("1"))
+}
+
+func TestLedgerWriteOnceKeys(t *testing.T) {
+	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
+	ledger := ledgerTestWrapper.ledger
+
+	commitTx := func(blockNumber uint64, work func() error) error {
+		ledger.BeginTxBatch(blockNumber)
+		ledger.TxBegin("txUuid")
+		err := work()
+		if err == nil {
+			err = ledger.ValidateTx("txUuid")
+		}
+		ledger.TxFinished("txUuid", err == nil)
+		ledger.CommitTxBatch(blockNumber, []*protos.Transaction{}, nil, []byte("proof"))
+		return err
+	}
+
+	// marking keys requires the feature to be active
+	testutil.AssertError(t, commitTx(0, func() error { return ledger.MarkWriteOnce("chaincode1", "cert1") }), "Expected error for inactive feature")
+	testutil.AssertNoError(t, commitTx(1, func() error { return ledger.ActivateFeature(FeatureKeyMetadata, 2) }), "Error activating feature")
+	testutil.AssertError(t, commitTx(2, func() error { return ledger.MarkWriteOnce(FeaturesChaincodeID, "key") }), "Expected error for system namespace")
+
+	testutil.AssertNoError(t, commitTx(3, func() error {
+		ledger.SetState("chaincode1", "cert1", []byte("value1"))
+		ledger.MarkWriteOncePrefix("chaincode1", "doc/")
+		ledger.MarkWriteOncePrefix("chaincode1", "hash/")
+		return ledger.MarkWriteOnce("chaincode1", "cert1")
+	}), "Error marking keys as write-once")
+
+	isWriteOnce := func(key string) bool {
+		writeOnce, err := ledger.IsWriteOnce("chaincode1", key, true)
+		testutil.AssertNoError(t, err, "Error checking write-once key")
+		return writeOnce
+	}
+	testutil.AssertEquals(t, isWriteOnce("cert1"), true)
+	testutil.AssertEquals(t, isWriteOnce("doc/1"), true)
+	testutil.AssertEquals(t, isWriteOnce("hash/1"), true)
+	testutil.AssertEquals(t, isWriteOnce("cert2"), false)
+
+	// the first write of a write-once key is allowed, subsequent writes are not
+	testutil.AssertNoError(t, commitTx(4, func() error { return ledger.SetState("chaincode1", "doc/1", []byte("value1")) }), "Error writing new key")
+	err := commitTx(5, func() error { return ledger.SetState("chaincode1", "doc/1", []byte("value2")) })
+	testutil.AssertError(t, err, "Expected error on update of write-once key")
+	testutil.AssertEquals(t, err.(*Error).Type(), ErrorTypeValidationRuleViolated)
+	testutil.AssertError(t, commitTx(6, func() error { return ledger.DeleteState("chaincode1", "cert1") }), "Expected error on delete of write-once key")
+	testutil.AssertNoError(t, commitTx(7, func() error { return ledger.SetState("chaincode2", "doc/1", []byte("value2")) }), "Error writing to another chaincode")
+
+	testutil.AssertEquals(t, ledgerTestWrapper.GetState("chaincode1", "doc/1", true), []byte("value1"))
+	testutil.AssertEquals(t, ledgerTestWrapper.GetState("chaincode1", "cert1", true), []byte("value1"))
+}
+
+func TestLedgerRevocationLists(t *testing.T) {
+	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
+	ledger := ledgerTestWrapper.ledger
+
+	lists, err := ledger.GetRevocationLists()
+	testutil.AssertNoError(t, err, "Error getting revocation lists")
+	testutil.AssertEquals(t, len(lists), 0)
+
+	ledger.BeginTxBatch(0)
+	ledger.TxBegin("txUuid1")
+	testutil.AssertError(t, ledger.SetRevocationList("", []byte("crl0")), "Expected error for empty issuer")
+	testutil.AssertNoError(t, ledger.SetRevocationList("eca", []byte("crl1")), "Error setting revocation list")
+	testutil.AssertNoError(t, ledger.SetRevocationList("tca", []byte("crl2")), "Error setting revocation list")
+	ledger.TxFinished("txUuid1", true)
+	crl, _ := ledger.GetRevocationList("eca", false)
+	testutil.AssertEquals(t, crl, []byte("crl1"))
+	crl, _ = ledger.GetRevocationList("eca", true)
+	testutil.AssertNil(t, crl)
+	tx, _ := buildTestTx(t)
+	ledger.CommitTxBatch(0, []*protos.Transaction{tx}, nil, []byte("proof"))
+
+	lists, err = ledger.GetRevocationLists()
+	testutil.AssertNoError(t, err, "Error getting revocation lists")
+	testutil.AssertEquals(t, lists, map[string][]byte{"eca": []byte("crl1"), "tca": []byte("crl2")})
+
+	// the lists are part of the state and can't be written as the state of a chaincode
+	testutil.AssertError(t, ledger.SetState(RevocationChaincodeID, "eca", []byte("crl3")), "Expected error for system namespace")
+}
+
+func TestLedgerDuplicateTxUUIDs(t *testing.T) {
+	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
+	ledger := ledgerTestWrapper.ledger
+
+	tx1, uuid1 := buildTestTx(t)
+	ledger.BeginTxBatch(0)
+	testutil.AssertNoError(t, ledger.AddBatchTxUUID(uuid1), "Error adding tx uuid")
+	err := ledger.AddBatchTxUUID(uuid1)
+	testutil.AssertEquals(t, IsDuplicateTransaction(err), true)
+	testutil.AssertNoError(t, ledger.CheckTxUUID(uuid1), "Uncommitted tx must not be a duplicate")
+	// the duplicate is part of the block, but the uuid identifies the first transaction
+	tx1Dup, _ := buildTestTx(t)
+	tx1Dup.Uuid = uuid1
+	tx1Dup.Payload = []byte("replayed")
+	ledger.CommitTxBatch(0, []*protos.Transaction{tx1, tx1Dup}, nil, []byte("proof"))
+
+	err = ledger.CheckTxUUID(uuid1)
+	testutil.AssertEquals(t, IsDuplicateTransaction(err), true)
+	committed, _ := ledger.GetTransactionByUUID(uuid1)
+	testutil.AssertEquals(t, committed, tx1)
+
+	// a rolled back batch frees its uuids
+	_, uuid2 := buildTestTx(t)
+	ledger.BeginTxBatch(1)
+	testutil.AssertNoError(t, ledger.AddBatchTxUUID(uuid2), "Error adding tx uuid")
+	err = ledger.AddBatchTxUUID(uuid1)
+	testutil.AssertEquals(t, IsDuplicateTransaction(err), true)
+	ledger.RollbackTxBatch(1)
+	ledger.BeginTxBatch(2)
+	testutil.AssertNoError(t, ledger.AddBatchTxUUID(uuid2), "Error adding tx uuid")
+	ledger.RollbackTxBatch(2)
+}
+
+func TestLedgerReconcileNamespace(t *testing.T) {
+	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
+	ledger := ledgerTestWrapper.ledger
+
+	commitBlock := func(blockNumber uint64, work func()) {
+		ledger.BeginTxBatch(blockNumber)
+		ledger.TxBegin("txUuid")
+		work()
+		ledger.TxFinished("txUuid", true)
+		ledger.CommitTxBatch(blockNumber, []*protos.Transaction{}, nil, []byte("proof"))
+	}
+	commitBlock(0, func() {
+		ledger.SetState("chaincode1", "key1", []byte("value1"))
+		ledger.SetState("chaincode1", "key2", []byte("value2"))
+		ledger.SetState("chaincode2", "key1", []byte("value1"))
+	})
+	commitBlock(1, func() {
+		ledger.SetState("chaincode1", "key1", []byte("value1_1"))
+		ledger.DeleteState("chaincode1", "key2")
+		ledger.SetState("chaincode1", "key3", []byte("value3"))
+	})
+
+	dataset, err := ParseReconciliationDatasetCSV(bytes.NewBufferString("key1,value1\nkey2,value2\n"))
+	testutil.AssertNoError(t, err, "Error parsing CSV dataset")
+
+	report, err := ledger.ReconcileNamespace("chaincode1", 0, dataset)
+	testutil.AssertNoError(t, err, "Error reconciling namespace")
+	testutil.AssertEquals(t, report.Reconciled(), true)
+	testutil.AssertEquals(t, report.KeysInLedger, 2)
+
+	report, err = ledger.ReconcileNamespace("chaincode1", 1, dataset)
+	testutil.AssertNoError(t, err, "Error reconciling namespace")
+	testutil.AssertEquals(t, report.Reconciled(), false)
+	testutil.AssertEquals(t, report.Mismatches, []*ReconciliationMismatch{
+		{"key1", MismatchValue, []byte("value1"), []byte("value1_1")},
+		{"key2", MismatchMissing, []byte("value2"), nil},
+		{"key3", MismatchUnexpected, nil, []byte("value3")},
+	})
+
+	dataset, err = ParseReconciliationDatasetJSON(bytes.NewBufferString(`{"key1":"value1_1","key3":"value3"}`))
+	testutil.AssertNoError(t, err, "Error parsing JSON dataset")
+	report, err = ledger.ReconcileNamespace("chaincode1", 1, dataset)
+	testutil.AssertNoError(t, err, "Error reconciling namespace")
+	testutil.AssertEquals(t, report.Reconciled(), true)
+
+	_, err = ledger.ReconcileNamespace("chaincode1", 2, dataset)
+	testutil.AssertEquals(t, err, ErrOutOfBounds)
+	_, err = ParseReconciliationDatasetCSV(bytes.NewBufferString("key1,value1\nkey1,value2\n"))
+	testutil.AssertError(t, err, "Expected error for duplicate keys")
+}
+
+func TestLedgerTxEffects(t *testing.T) {
+	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
+	ledger := ledgerTestWrapper.ledger
+
+	ledger.BeginTxBatch(0)
+	var transactions []*protos.Transaction
+	var uuids []string
+	for i := 0; i < 5; i++ {
+		tx, uuid := buildTestTx(t)
+		transactions = append(transactions, tx)
+		uuids = append(uuids, uuid)
+		ledger.TxBegin(uuid)
+		if i != 3 {
+			ledger.SetState("chaincode1", fmt.Sprintf("key%d", i), []byte("value"))
+		}
+		// the tx at index 2 fails and hence has no effects
+		ledger.TxFinished(uuid, i != 2)
+	}
+	_, txDeltaHashes, err := ledger.GetTempStateHashWithTxDeltaStateHashes()
+	testutil.AssertNoError(t, err, "Error getting tx delta hashes")
+	ledger.CommitTxBatch(0, transactions, nil, []byte("proof"))
+
+	effects, err := ledger.GetTxEffects(0)
+	testutil.AssertNoError(t, err, "Error getting tx effects")
+	testutil.AssertEquals(t, effects, []*TxEffects{
+		{uuids[0], txDeltaHashes[uuids[0]]},
+		{uuids[1], txDeltaHashes[uuids[1]]},
+		{uuids[3], nil},
+		{uuids[4], txDeltaHashes[uuids[4]]},
+	})
+	root, err := ledger.GetTxEffectsRoot(0)
+	testutil.AssertNoError(t, err, "Error getting tx effects root")
+	testutil.AssertEquals(t, root, ComputeTxEffectsRoot(effects))
+	block, err := ledger.GetBlockByNumber(0)
+	testutil.AssertNoError(t, err, "Error getting block")
+	testutil.AssertEquals(t, block.NonHashData.TxEffectsRoot, root)
+
+	for _, uuid := range []string{uuids[0], uuids[1], uuids[3], uuids[4]} {
+		proof, err := ledger.GetTxEffectsProof(uuid)
+		testutil.AssertNoError(t, err, "Error getting tx effects proof")
+		testutil.AssertNoError(t, VerifyTxEffectsProof(root, proof), "Error verifying tx effects proof")
+		proof.Effects.StateDeltaHash = []byte("forged")
+		testutil.AssertError(t, VerifyTxEffectsProof(root, proof), "Expected error for forged effects")
+	}
+	_, err = ledger.GetTxEffectsProof(uuids[2])
+	testutil.AssertError(t, err, "Expected error for failed transaction")
+
+	// the recorded effects no longer match the root in the block
+	inconsistency, err := ledger.VerifyLedger(0, 0, false)
+	testutil.AssertNoError(t, err, "Error while verifying the ledger")
+	testutil.AssertNil(t, inconsistency)
+	effects[0].StateDeltaHash = []byte("forged")
+	testutil.AssertNoError(t, ledger.openchainDB.Put(ledger.openchainDB.IndexesCF, encodeTxEffectsKey(0), encodeTxEffects(effects)), "Error writing tx effects")
+	inconsistency, err = ledger.VerifyLedger(0, 0, false)
+	testutil.AssertNoError(t, err, "Error while verifying the ledger")
+	testutil.AssertEquals(t, inconsistency.BlockNumber, uint64(0))
+
+	_, err = ledger.GetTxEffects(1)
+	testutil.AssertEquals(t, err, ErrOutOfBounds)
+
+	// proofs verify for trees of all shapes
+	for numLeaves := 1; numLeaves <= 9; numLeaves++ {
+		var leaves []*TxEffects
+		for i := 0; i < numLeaves; i++ {
+			leaves = append(leaves, &TxEffects{fmt.Sprintf("tx%d", i), []byte{byte(i)}})
+		}
+		root := ComputeTxEffectsRoot(leaves)
+		for i := range leaves {
+			proof := &TxEffectsProof{0, leaves[i], uint64(i), uint64(numLeaves), computeTxEffectsSiblings(leaves, i)}
+			testutil.AssertNoError(t, VerifyTxEffectsProof(root, proof), "Error verifying tx effects proof")
+			proof.LeafIndex = uint64((i + 1) % numLeaves)
+			if numLeaves > 1 {
+				testutil.AssertError(t, VerifyTxEffectsProof(root, proof), "Expected error for wrong leaf index")
+			}
+		}
+	}
+}
+
+func TestDiskQuota(t *testing.T) {
+	InitTestLedger(t)
+	chainsPath := viper.GetString("peer.fileSystemPath") + "/chains"
+	os.RemoveAll(chainsPath)
+	defer os.RemoveAll(chainsPath)
+	viper.Set("peer.db.quota.hard", 1)
+	viper.Set("peer.db.quota.refuseCommits", true)
+	viper.Set("peer.db.quota.checkInterval", "1ns")
+	defer func() {
+		viper.Set("peer.db.quota.hard", nil)
+		viper.Set("peer.db.quota.refuseCommits", nil)
+		viper.Set("peer.db.quota.checkInterval", nil)
+	}()
+	ledger, err := GetLedgerByChainID("quotaChain")
+	testutil.AssertNoError(t, err, "Error while opening the ledger of quotaChain")
+	defer CloseLedgerByChainID("quotaChain")
+
+	commit := func(batchID int) error {
+		ledger.BeginTxBatch(batchID)
+		ledger.TxBegin("txUuid")
+		ledger.SetState("chaincode1", "key1", []byte("value1"))
+		ledger.TxFinished("txUuid", true)
+		transaction, _ := buildTestTx(t)
+		return ledger.CommitTxBatch(batchID, []*protos.Transaction{transaction}, nil, []byte("proof"))
+	}
+	testutil.AssertNoError(t, commit(1), "Error while committing below the quota")
+
+	fillerFile := chainsPath + "/quotaChain/db/filler"
+	testutil.AssertNoError(t, ioutil.WriteFile(fillerFile, make([]byte, 1024*1024), 0644), "Error writing filler file")
+	err = commit(2)
+	testutil.AssertError(t, err, "Expected the commit to be refused above the hard quota")
+	testutil.AssertEquals(t, err.(*Error).Type(), ErrorTypeDiskQuotaExceeded)
+	testutil.AssertEquals(t, ledger.GetBlockchainSize(), uint64(1))
+
+	os.Remove(fillerFile)
+	testutil.AssertNoError(t, commit(3), "Error while committing back below the quota")
+	testutil.AssertEquals(t, ledger.GetBlockchainSize(), uint64(2))
+}
+
+func TestBlockArchive(t *testing.T) {
+	InitTestLedger(t)
+	chainsPath := viper.GetString("peer.fileSystemPath") + "/chains"
+	archivePath := viper.GetString("peer.fileSystemPath") + "/archive"
+	os.RemoveAll(chainsPath)
+	os.RemoveAll(archivePath)
+	defer os.RemoveAll(chainsPath)
+	defer os.RemoveAll(archivePath)
+	viper.Set("ledger.blockchain.archive.enabled", true)
+	viper.Set("ledger.blockchain.archive.keepBlocks", 2)
+	viper.Set("ledger.blockchain.archive.filesystem.path", archivePath)
+	defer func() {
+		viper.Set("ledger.blockchain.archive.enabled", nil)
+		viper.Set("ledger.blockchain.archive.keepBlocks", nil)
+		viper.Set("ledger.blockchain.archive.filesystem.path", nil)
+	}()
+	ledger, err := GetLedgerByChainID("archiveChain")
+	testutil.AssertNoError(t, err, "Error while opening the ledger of archiveChain")
+
+	var blocks []*protos.Block
+	for i := 0; i < 5; i++ {
+		ledger.BeginTxBatch(i)
+		ledger.TxBegin("txUuid")
+		ledger.SetState("chaincode1", "key1", []byte(fmt.Sprintf("value%d", i)))
+		ledger.TxFinished("txUuid", true)
+		transaction, _ := buildTestTx(t)
+		testutil.AssertNoError(t, ledger.CommitTxBatch(i, []*protos.Transaction{transaction}, nil, []byte("proof")), "Error while committing")
+		block, err := ledger.GetBlockByNumber(uint64(i))
+		testutil.AssertNoError(t, err, "Error while getting block")
+		blocks = append(blocks, block)
+	}
+
+	for i := 0; ; i++ {
+		next, err := ledger.blockchain.archiver.nextBlockToArchive()
+		testutil.AssertNoError(t, err, "Error while reading the archival progress")
+		if next == 3 {
+			break
+		}
+		if i == 100 {
+			t.Fatalf("Blocks not archived, next block to archive is [%d]", next)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	for i, block := range blocks {
+		header, err := fetchBlockFromDB(ledger.openchainDB, uint64(i))
+		testutil.AssertNoError(t, err, "Error while fetching block from the DB")
+		testutil.AssertEquals(t, len(header.Transactions) == 0, i < 3)
+		archivedBlock, err := ledger.GetBlockByNumber(uint64(i))
+		testutil.AssertNoError(t, err, "Error while getting archived block")
+		testutil.AssertEquals(t, archivedBlock, block)
+	}
+	testutil.AssertNoError(t, CloseLedgerByChainID("archiveChain"), "Error while closing the ledger")
+
+	viper.Set("ledger.blockchain.archive.enabled", false)
+	ledger, err = GetLedgerByChainID("archiveChain")
+	testutil.AssertNoError(t, err, "Error while reopening the ledger of archiveChain")
+	defer CloseLedgerByChainID("archiveChain")
+	_, err = ledger.GetBlockByNumber(0)
+	testutil.AssertError(t, err, "Expected an error getting an archived block without the block archive")
+	_, err = ledger.GetBlockByNumber(4)
+	testutil.AssertNoError(t, err, "Error while getting a block that is not archived")
+}
+
+func TestBlockArchiveEncryption(t *testing.T) {
+	InitTestLedger(t)
+	chainsPath := viper.GetString("peer.fileSystemPath") + "/chains"
+	archivePath := viper.GetString("peer.fileSystemPath") + "/archive"
+	os.RemoveAll(chainsPath)
+	os.RemoveAll(archivePath)
+	defer os.RemoveAll(chainsPath)
+	defer os.RemoveAll(archivePath)
+	os.Setenv("TEST_DB_ENCRYPTION_KEY", "000102030405060708090a0b0c0d0e0f")
+	defer os.Unsetenv("TEST_DB_ENCRYPTION_KEY")
+	viper.Set("peer.db.encryption.keyEnv", "TEST_DB_ENCRYPTION_KEY")
+	viper.Set("peer.db.encryption.enabled", true)
+	viper.Set("ledger.blockchain.archive.enabled", true)
+	viper.Set("ledger.blockchain.archive.keepBlocks", 1)
+	viper.Set("ledger.blockchain.archive.filesystem.path", archivePath)
+	defer func() {
+		viper.Set("peer.db.encryption.keyEnv", nil)
+		viper.Set("peer.db.encryption.enabled", nil)
+		viper.Set("ledger.blockchain.archive.enabled", nil)
+		viper.Set("ledger.blockchain.archive.keepBlocks", nil)
+		viper.Set("ledger.blockchain.archive.filesystem.path", nil)
+	}()
+	ledger, err := GetLedgerByChainID("encryptedArchiveChain")
+	testutil.AssertNoError(t, err, "Error while opening the ledger of encryptedArchiveChain")
+	defer CloseLedgerByChainID("encryptedArchiveChain")
+
+	var blocks []*protos.Block
+	for i := 0; i < 2; i++ {
+		ledger.BeginTxBatch(i)
+		ledger.TxBegin("txUuid")
+		ledger.SetState("chaincode1", "key1", []byte(fmt.Sprintf("value%d", i)))
+		ledger.TxFinished("txUuid", true)
+		transaction, _ := buildTestTx(t)
+		testutil.AssertNoError(t, ledger.CommitTxBatch(i, []*protos.Transaction{transaction}, nil, []byte("proof")), "Error while committing")
+		block, err := ledger.GetBlockByNumber(uint64(i))
+		testutil.AssertNoError(t, err, "Error while getting block")
+		blocks = append(blocks, block)
+	}
+	for i := 0; ; i++ {
+		next, err := ledger.blockchain.archiver.nextBlockToArchive()
+		testutil.AssertNoError(t, err, "Error while reading the archival progress")
+		if next == 1 {
+			break
+		}
+		if i == 100 {
+			t.Fatalf("Block not archived, next block to archive is [%d]", next)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	storedBytes, err := ledger.blockchain.archive.Get(0)
+	testutil.AssertNoError(t, err, "Error while reading the archived block")
+	blockBytes, err := blocks[0].Bytes()
+	testutil.AssertNoError(t, err, "Error while marshaling block")
+	if bytes.Contains(storedBytes, []byte(blocks[0].Transactions[0].Uuid)) || bytes.Equal(storedBytes, blockBytes) {
+		t.Fatalf("Expected the archived block to be encrypted")
+	}
+	archivedBlock, err := ledger.GetBlockByNumber(0)
+	testutil.AssertNoError(t, err, "Error while getting archived block")
+	testutil.AssertEquals(t, archivedBlock, blocks[0])
+}
+
+func TestCheckpoints(t *testing.T) {
+	InitTestLedger(t)
+	chainsPath := viper.GetString("peer.fileSystemPath") + "/chains"
+	checkpointsPath := viper.GetString("peer.fileSystemPath") + "/checkpoints"
+	os.RemoveAll(chainsPath)
+	os.RemoveAll(checkpointsPath)
+	defer os.RemoveAll(chainsPath)
+	defer os.RemoveAll(checkpointsPath)
+	viper.Set("ledger.checkpoint.interval", 2)
+	viper.Set("ledger.checkpoint.retention", 1)
+	defer func() {
+		viper.Set("ledger.checkpoint.interval", nil)
+		viper.Set("ledger.checkpoint.retention", nil)
+	}()
+	ledger, err := GetLedgerByChainID("checkpointChain")
+	testutil.AssertNoError(t, err, "Error while opening the ledger of checkpointChain")
+	for i := 0; i < 5; i++ {
+		ledger.BeginTxBatch(i)
+		ledger.TxBegin("txUuid")
+		ledger.SetState("chaincode1", fmt.Sprintf("key%d", i), []byte(fmt.Sprintf("value%d", i)))
+		if i == 4 {
+			ledger.DeleteState("chaincode1", "key0")
+		}
+		ledger.TxFinished("txUuid", true)
+		transaction, _ := buildTestTx(t)
+		testutil.AssertNoError(t, ledger.CommitTxBatch(i, []*protos.Transaction{transaction}, nil, []byte("proof")), "Error while committing")
+	}
+	// closing the ledger waits for the checkpoints being written
+	testutil.AssertNoError(t, CloseLedgerByChainID("checkpointChain"), "Error while closing the ledger")
+	ledger, err = GetLedgerByChainID("checkpointChain")
+	testutil.AssertNoError(t, err, "Error while reopening the ledger of checkpointChain")
+	defer CloseLedgerByChainID("checkpointChain")
+
+	checkpoints, err := ledger.GetCheckpoints()
+	testutil.AssertNoError(t, err, "Error while listing the checkpoints")
+	testutil.AssertEquals(t, len(checkpoints), 1)
+	testutil.AssertEquals(t, checkpoints[0].BlockNumber, uint64(3))
+	block3, _ := ledger.GetBlockByNumber(3)
+	testutil.AssertEquals(t, checkpoints[0].StateHash, block3.StateHash)
+	_, err = ledger.OpenCheckpoint(1)
+	testutil.AssertEquals(t, err, ErrResourceNotFound)
+
+	expectedStateHash, _ := ledger.GetTempStateHash()
+	delta := statemgmt.NewStateDelta()
+	delta.Set("chaincode1", "key1", []byte("corrupted"), nil)
+	delta.Set("chaincode1", "stale", []byte("stale"), nil)
+	testutil.AssertNoError(t, ledger.ApplyStateDelta(2, delta), "Error while applying state delta")
+	testutil.AssertNoError(t, ledger.CommitStateDelta(2), "Error while committing state delta")
+
+	testutil.AssertNoError(t, ledger.RestoreStateFromCheckpoint(3), "Error while restoring the state from the checkpoint")
+	stateHash, _ := ledger.GetTempStateHash()
+	testutil.AssertEquals(t, stateHash, expectedStateHash)
+	value, _ := ledger.GetState("chaincode1", "key1", true)
+	testutil.AssertEquals(t, value, []byte("value1"))
+	value, _ = ledger.GetState("chaincode1", "stale", true)
+	testutil.AssertNil(t, value)
+	value, _ = ledger.GetState("chaincode1", "key0", true)
+	testutil.AssertNil(t, value)
+}
+
+func TestRewindState(t *testing.T) {
+	InitTestLedger(t)
+	chainsPath := viper.GetString("peer.fileSystemPath") + "/chains"
+	checkpointsPath := viper.GetString("peer.fileSystemPath") + "/checkpoints"
+	os.RemoveAll(chainsPath)
+	os.RemoveAll(checkpointsPath)
+	defer os.RemoveAll(chainsPath)
+	defer os.RemoveAll(checkpointsPath)
+	viper.Set("ledger.checkpoint.interval", 3)
+	viper.Set("ledger.checkpoint.retention", 2)
+	defer func() {
+		viper.Set("ledger.checkpoint.interval", nil)
+		viper.Set("ledger.checkpoint.retention", nil)
+	}()
+	ledger, err := GetLedgerByChainID("rewindChain")
+	testutil.AssertNoError(t, err, "Error while opening the ledger of rewindChain")
+	for i := 0; i < 7; i++ {
+		ledger.BeginTxBatch(i)
+		ledger.TxBegin("txUuid")
+		ledger.SetState("chaincode1", fmt.Sprintf("key%d", i), []byte(fmt.Sprintf("value%d", i)))
+		ledger.SetState("chaincode1", "counter", []byte(fmt.Sprintf("%d", i)))
+		if i == 4 {
+			ledger.DeleteState("chaincode1", "key0")
+		}
+		ledger.TxFinished("txUuid", true)
+		transaction, _ := buildTestTx(t)
+		testutil.AssertNoError(t, ledger.CommitTxBatch(i, []*protos.Transaction{transaction}, nil, []byte("proof")), "Error while committing")
+		if i == 2 || i == 6 {
+			// closing the ledger waits for the checkpoint being written, which is otherwise skipped if still pending at the next one
+			testutil.AssertNoError(t, CloseLedgerByChainID("rewindChain"), "Error while closing the ledger")
+			ledger, err = GetLedgerByChainID("rewindChain")
+			testutil.AssertNoError(t, err, "Error while reopening the ledger of rewindChain")
+		}
+	}
+	defer CloseLedgerByChainID("rewindChain")
+
+	// with the inverted state deltas
+	testutil.AssertNoError(t, ledger.RewindState(6, 4), "Error while rewinding the state to block 4")
+	block4, _ := ledger.GetBlockByNumber(4)
+	stateHash, _ := ledger.GetTempStateHash()
+	testutil.AssertEquals(t, stateHash, block4.StateHash)
+	value, _ := ledger.GetState("chaincode1", "counter", true)
+	testutil.AssertEquals(t, value, []byte("4"))
+	value, _ = ledger.GetState("chaincode1", "key5", true)
+	testutil.AssertNil(t, value)
+	delta, _ := ledger.GetStateDelta(5)
+	testutil.AssertNil(t, delta)
+
+	// from the checkpoint of block 2, once the state deltas are no longer retained
+	testutil.AssertNoError(t, ledger.state.RemoveStateDeltas(4, 4), "Error while removing a state delta")
+	testutil.AssertNoError(t, ledger.RewindState(4, 3), "Error while rewinding the state to block 3")
+	block3, _ := ledger.GetBlockByNumber(3)
+	stateHash, _ = ledger.GetTempStateHash()
+	testutil.AssertEquals(t, stateHash, block3.StateHash)
+	value, _ = ledger.GetState("chaincode1", "key0", true)
+	testutil.AssertEquals(t, value, []byte("value0"))
+	value, _ = ledger.GetState("chaincode1", "key4", true)
+	testutil.AssertNil(t, value)
+
+	testutil.AssertError(t, ledger.RewindState(3, 3), "Expected an error rewinding the state to its own block")
+}
+
+func TestRecoverPartialCommit(t *testing.T) {
+	InitTestLedger(t)
+	chainsPath := viper.GetString("peer.fileSystemPath") + "/chains"
+	os.RemoveAll(chainsPath)
+	defer os.RemoveAll(chainsPath)
+	ledger, err := GetLedgerByChainID("recoveryChain")
+	testutil.AssertNoError(t, err, "Error while opening the ledger of recoveryChain")
+
+	for i := 0; i < 2; i++ {
+		ledger.BeginTxBatch(i)
+		ledger.TxBegin("txUuid")
+		ledger.SetState("chaincode1", "key1", []byte(fmt.Sprintf("value%d", i)))
+		ledger.TxFinished("txUuid", true)
+		transaction, _ := buildTestTx(t)
+		testutil.AssertNoError(t, ledger.CommitTxBatch(i, []*protos.Transaction{transaction}, nil, []byte("proof")), "Error while committing")
+	}
+	lastBlock, err := ledger.GetBlockByNumber(1)
+	testutil.AssertNoError(t, err, "Error while getting block")
+	previousBlockHash, err := lastBlock.GetHash()
+	testutil.AssertNoError(t, err, "Error while hashing block")
+
+	// Block 2 is persisted but the state is still the one of block 1
+	transaction, uuid := buildTestTx(t)
+	block := protos.NewBlock([]*protos.Transaction{transaction}, nil)
+	block.PreviousBlockHash = previousBlockHash
+	block.StateHash = []byte("stateHashOfBlock2")
+	testutil.AssertNoError(t, ledger.PutRawBlock(block, 2), "Error while putting raw block")
+	testutil.AssertEquals(t, ledger.GetBlockchainSize(), uint64(3))
+	testutil.AssertNoError(t, CloseLedgerByChainID("recoveryChain"), "Error while closing the ledger")
+
+	ledger, err = GetLedgerByChainID("recoveryChain")
+	testutil.AssertNoError(t, err, "Error while reopening the ledger of recoveryChain")
+	defer CloseLedgerByChainID("recoveryChain")
+	testutil.AssertEquals(t, ledger.GetBlockchainSize(), uint64(2))
+	_, err = ledger.GetBlockByNumber(2)
+	testutil.AssertEquals(t, err, ErrOutOfBounds)
+	_, err = ledger.GetTransactionByUUID(uuid)
+	testutil.AssertEquals(t, err, ErrResourceNotFound)
+	value, err := ledger.GetState("chaincode1", "key1", true)
+	testutil.AssertNoError(t, err, "Error while getting state")
+	testutil.AssertEquals(t, value, []byte("value1"))
+
+	// The blockchain can be extended from the recovered block
+	ledger.BeginTxBatch(2)
+	ledger.TxBegin("txUuid")
+	ledger.SetState("chaincode1", "key1", []byte("value2"))
+	ledger.TxFinished("txUuid", true)
+	testutil.AssertNoError(t, ledger.CommitTxBatch(2, []*protos.Transaction{transaction}, nil, []byte("proof")), "Error while committing")
+	_, err = ledger.VerifyChain(2, 0)
+	testutil.AssertNoError(t, err, "Error while verifying the chain")
+	newBlock, err := ledger.GetBlockByNumber(2)
+	testutil.AssertNoError(t, err, "Error while getting block")
+	testutil.AssertEquals(t, newBlock.PreviousBlockHash, previousBlockHash)
+}
+
+func TestVerifyLedger(t *testing.T) {
+	ledger := InitTestLedger(t)
+	for i := 0; i < 4; i++ {
+		ledger.BeginTxBatch(i)
+		ledger.TxBegin("txUuid")
+		ledger.SetState("chaincode1", "key1", []byte(fmt.Sprintf("value%d", i)))
+		ledger.SetState("chaincode2", fmt.Sprintf("key%d", i), []byte("value"))
+		ledger.TxFinished("txUuid", true)
+		transaction, _ := buildTestTx(t)
+		testutil.AssertNoError(t, ledger.CommitTxBatch(i, []*protos.Transaction{transaction}, nil, []byte("proof")), "Error while committing")
+	}
+	stateHash, err := ledger.GetTempStateHash()
+	testutil.AssertNoError(t, err, "Error while getting state hash")
+
+	inconsistency, err := ledger.VerifyLedger(0, 3, true)
+	testutil.AssertNoError(t, err, "Error while verifying the ledger")
+	testutil.AssertNil(t, inconsistency)
+	_, err = ledger.VerifyLedger(0, 4, false)
+	testutil.AssertEquals(t, err, ErrOutOfBounds)
+
+	// The delta of block 2 no longer rolls the state back to the one of block 1
+	delta, err := ledger.GetStateDelta(2)
+	testutil.AssertNoError(t, err, "Error while getting state delta")
+	delta.Set("chaincode3", "key1", []byte("value"), []byte("bogus"))
+	testutil.AssertNoError(t, ledger.openchainDB.Put(ledger.openchainDB.StateDeltaCF, encodeUint64(2), delta.Marshal()), "Error while writing state delta")
+	inconsistency, err = ledger.VerifyLedger(0, 3, false)
+	testutil.AssertNoError(t, err, "Error while verifying the ledger")
+	testutil.AssertNil(t, inconsistency)
+	inconsistency, err = ledger.VerifyLedger(2, 3, true)
+	testutil.AssertNoError(t, err, "Error while verifying the ledger")
+	testutil.AssertNil(t, inconsistency)
+	inconsistency, err = ledger.VerifyLedger(0, 3, true)
+	testutil.AssertNoError(t, err, "Error while verifying the ledger")
+	testutil.AssertEquals(t, inconsistency.BlockNumber, uint64(1))
+
+	// Block 1 no longer matches the previous block hash of block 2
+	block, err := ledger.GetBlockByNumber(1)
+	testutil.AssertNoError(t, err, "Error while getting block")
+	block.StateHash = []byte("bogus")
+	blockBytes, err := block.Bytes()
+	testutil.AssertNoError(t, err, "Error while marshaling block")
+	testutil.AssertNoError(t, ledger.openchainDB.Put(ledger.openchainDB.BlockchainCF, encodeBlockNumberDBKey(1), blockBytes), "Error while writing block")
+	inconsistency, err = ledger.VerifyLedger(0, 3, false)
+	testutil.AssertNoError(t, err, "Error while verifying the ledger")
+	testutil.AssertEquals(t, inconsistency.BlockNumber, uint64(2))
+	inconsistency, err = ledger.VerifyLedger(0, 1, false)
+	testutil.AssertNoError(t, err, "Error while verifying the ledger")
+	testutil.AssertNil(t, inconsistency)
+
+	// The state is left unchanged
+	newStateHash, err := ledger.GetTempStateHash()
+	testutil.AssertNoError(t, err, "Error while getting state hash")
+	testutil.AssertEquals(t, newStateHash, stateHash)
+}
+
+func TestGetBlocks(t *testing.T) {
+	ledger := InitTestLedger(t)
+	for i := 0; i < 5; i++ {
+		ledger.BeginTxBatch(i)
+		ledger.TxBegin("txUuid")
+		ledger.SetState("chaincode1", "key1", []byte(fmt.Sprintf("value%d", i)))
+		ledger.TxFinished("txUuid", true)
+		transaction, _ := buildTestTx(t)
+		testutil.AssertNoError(t, ledger.CommitTxBatch(i, []*protos.Transaction{transaction}, nil, []byte("proof")), "Error while committing")
+	}
+	checkBlocks := func(start, end uint64, expected []uint64) {
+		itr, err := ledger.GetBlocks(start, end)
+		testutil.AssertNoError(t, err, "Error while getting blocks")
+		var blockNumbers []uint64
+		for itr.Next() {
+			blockNumber, block := itr.GetBlock()
+			expectedBlock, err := ledger.GetBlockByNumber(blockNumber)
+			testutil.AssertNoError(t, err, "Error while getting block")
+			testutil.AssertEquals(t, block, expectedBlock)
+			blockNumbers = append(blockNumbers, blockNumber)
+		}
+		testutil.AssertNoError(t, itr.Err(), "Error while iterating blocks")
+		testutil.AssertEquals(t, blockNumbers, expected)
+	}
+	checkBlocks(1, 3, []uint64{1, 2, 3})
+	checkBlocks(4, 0, []uint64{4, 3, 2, 1, 0})
+	checkBlocks(2, 2, []uint64{2})
+	_, err := ledger.GetBlocks(0, 5)
+	testutil.AssertEquals(t, err, ErrOutOfBounds)
+}
+
+func TestSecondaryIndexes(t *testing.T) {
+	ledger := InitTestLedger(t)
+	chaincodes := []string{"cc1", "cc2", "cc1", "cc3"}
+	startTime := time.Unix(time.Now().Unix(), 0)
+	for i, chaincode := range chaincodes {
+		ledger.BeginTxBatch(i)
+		tx1, err := protos.NewTransaction(protos.ChaincodeID{Name: chaincode}, util.GenerateUUID(), "invoke", nil)
+		testutil.AssertNoError(t, err, "Error while building transaction")
+		tx2, err := protos.NewTransaction(protos.ChaincodeID{Name: "cc2"}, util.GenerateUUID(), "invoke", nil)
+		testutil.AssertNoError(t, err, "Error while building transaction")
+		testutil.AssertNoError(t, ledger.CommitTxBatch(i, []*protos.Transaction{tx1, tx2}, nil, []byte("proof")), "Error while committing")
+	}
+	endTime := time.Now().Add(time.Second)
+	checkIndexes := func() {
+		blockNumbers, err := ledger.GetBlockNumbersByChaincodeID("cc1")
+		testutil.AssertNoError(t, err, "Error while querying the chaincode index")
+		testutil.AssertEquals(t, blockNumbers, []uint64{0, 2})
+		blockNumbers, err = ledger.GetBlockNumbersByChaincodeID("cc2")
+		testutil.AssertNoError(t, err, "Error while querying the chaincode index")
+		testutil.AssertEquals(t, blockNumbers, []uint64{0, 1, 2, 3})
+		blockNumbers, err = ledger.GetBlockNumbersByChaincodeID("cc")
+		testutil.AssertNoError(t, err, "Error while querying the chaincode index")
+		testutil.AssertEquals(t, len(blockNumbers), 0)
+		blockNumbers, err = ledger.GetBlockNumbersByTimeRange(startTime, endTime)
+		testutil.AssertNoError(t, err, "Error while querying the timestamp index")
+		testutil.AssertEquals(t, len(blockNumbers), 4)
+		blockNumbers, err = ledger.GetBlockNumbersByTimeRange(endTime, endTime.Add(time.Hour))
+		testutil.AssertNoError(t, err, "Error while querying the timestamp index")
+		testutil.AssertEquals(t, len(blockNumbers), 0)
+	}
+	checkIndexes()
+
+	// Blocks persisted before the secondary indexes have been introduced are indexed in the background
+	writeBatch := ledger.openchainDB.KVStore().NewWriteBatch()
+	itr := ledger.openchainDB.KVStore().NewIterator(kvstore.SecondaryIndexesCFName)
+	for itr.SeekToFirst(); itr.Valid(); itr.Next() {
+		writeBatch.Delete(kvstore.SecondaryIndexesCFName, itr.Key())
+	}
+	itr.Close()
+	testutil.AssertNoError(t, ledger.openchainDB.KVStore().Write(writeBatch, false), "Error while deleting the secondary indexes")
+	writeBatch.Destroy()
+	testutil.AssertNoError(t, initSecondaryIndexes(ledger.openchainDB, ledger.GetBlockchainSize()), "Error while initializing the secondary indexes")
+	_, err := ledger.GetBlockNumbersByChaincodeID("cc1")
+	testutil.AssertEquals(t, err, ErrSecondaryIndexesNotReady)
+	backfiller := newSecondaryIndexesBackfiller(ledger.blockchain)
+	backfiller.start()
+	<-backfiller.done
+	checkIndexes()
+}
+
+func TestGetTransactionResult(t *testing.T) {
+	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
+	ledger := ledgerTestWrapper.ledger
+
+	ledger.BeginTxBatch(0)
+	var transactions []*protos.Transaction
+	var txResults []*protos.TransactionResult
+	var uuids []string
+	for i := 0; i < 3; i++ {
+		tx, uuid := buildTestTx(t)
+		transactions = append(transactions, tx)
+		uuids = append(uuids, uuid)
+		ledger.TxBegin(uuid)
+		ledger.SetState("chaincode1", fmt.Sprintf("key%d", i), []byte("value"))
+		// the tx at index 1 fails and hence has no effects
+		ledger.TxFinished(uuid, i != 1)
+		if i == 1 {
+			txResults = append(txResults, &protos.TransactionResult{Uuid: uuid, ErrorCode: 1, Error: "chaincode error"})
+		} else {
+			txResults = append(txResults, &protos.TransactionResult{Uuid: uuid, Result: []byte("result")})
+		}
+	}
+	_, txDeltaHashes, err := ledger.GetTempStateHashWithTxDeltaStateHashes()
+	testutil.AssertNoError(t, err, "Error getting tx delta hashes")
+	ledger.CommitTxBatch(0, transactions, txResults, []byte("proof"))
+
+	receipt, err := ledger.GetTransactionResult(uuids[0])
+	testutil.AssertNoError(t, err, "Error getting transaction result")
+	testutil.AssertEquals(t, receipt, &TransactionReceipt{TxUUID: uuids[0], BlockNumber: 0, TxIndex: 0,
+		Status: TxStatusSucceeded, Result: []byte("result"), StateDeltaHash: txDeltaHashes[uuids[0]]})
+	receipt, err = ledger.GetTransactionResult(uuids[1])
+	testutil.AssertNoError(t, err, "Error getting transaction result")
+	testutil.AssertEquals(t, receipt, &TransactionReceipt{TxUUID: uuids[1], BlockNumber: 0, TxIndex: 1,
+		Status: TxStatusFailed, ErrorCode: 1, Error: "chaincode error"})
+	_, err = ledger.GetTransactionResult("unknown")
+	testutil.AssertEquals(t, err, ErrResourceNotFound)
+
+	// the receipts survive a restart
+	ledger, err = newLedger(DefaultChainID, db.GetDBHandle())
+	testutil.AssertNoError(t, err, "Error while reopening the ledger")
+	receipt, err = ledger.GetTransactionResult(uuids[2])
+	testutil.AssertNoError(t, err, "Error getting transaction result after restart")
+	testutil.AssertEquals(t, receipt.TxIndex, uint64(2))
+	testutil.AssertEquals(t, receipt.Status, TxStatusSucceeded)
+	testutil.AssertEquals(t, receipt.StateDeltaHash, txDeltaHashes[uuids[2]])
+}
+
+func TestGetChaincodeEvents(t *testing.T) {
+	ledger := InitTestLedger(t)
+	chaincodes := []string{"cc1", "cc2", "cc1", "cc1"}
+	eventNames := []string{"created", "created", "updated", "created"}
+	for i, chaincode := range chaincodes {
+		ledger.BeginTxBatch(i)
+		tx, err := protos.NewTransaction(protos.ChaincodeID{Name: chaincode}, util.GenerateUUID(), "invoke", nil)
+		testutil.AssertNoError(t, err, "Error while building transaction")
+		event := &protos.ChaincodeEvent{ChaincodeID: chaincode, TxID: tx.Uuid, EventName: eventNames[i], Payload: []byte{byte(i)}}
+		txResults := []*protos.TransactionResult{&protos.TransactionResult{Uuid: tx.Uuid, ChaincodeEvent: event}}
+		testutil.AssertNoError(t, ledger.CommitTxBatch(i, []*protos.Transaction{tx}, txResults, []byte("proof")), "Error while committing")
+	}
+	checkEvents := func(chaincodeID string, eventName string, fromBlock uint64, toBlock uint64, expectedBlocks []uint64) {
+		entries, err := ledger.GetChaincodeEvents(chaincodeID, eventName, fromBlock, toBlock)
+		testutil.AssertNoError(t, err, "Error while querying the chaincode events")
+		testutil.AssertEquals(t, len(entries), len(expectedBlocks))
+		for i, entry := range entries {
+			testutil.AssertEquals(t, entry.BlockNumber, expectedBlocks[i])
+			testutil.AssertEquals(t, entry.Event.ChaincodeID, chaincodeID)
+			testutil.AssertEquals(t, entry.Event.Payload, []byte{byte(expectedBlocks[i])})
+		}
+	}
+	checkEvents("cc1", "", 0, 10, []uint64{0, 2, 3})
+	checkEvents("cc1", "created", 0, 3, []uint64{0, 3})
+	checkEvents("cc1", "", 1, 2, []uint64{2})
+	checkEvents("cc2", "updated", 0, 3, nil)
+	checkEvents("cc1", "", 4, 10, nil)
+
+	// the blocks are scanned while the secondary indexes are being built
+	err := ledger.openchainDB.Put(ledger.openchainDB.SecondaryIndexesCF, lowestIndexedBlockKey, encodeUint64(ledger.GetBlockchainSize()))
+	testutil.AssertNoError(t, err, "Error while resetting the secondary indexes")
+	_, err = ledger.GetBlockNumbersByChaincodeID("cc1")
+	testutil.AssertEquals(t, err, ErrSecondaryIndexesNotReady)
+	checkEvents("cc1", "created", 0, 3, []uint64{0, 3})
+}
+
+func TestEventReplaySource(t *testing.T) {
+	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
+	ledger := ledgerTestWrapper.ledger
+
+	_, found, err := ledger.GetEventConsumerCheckpoint("consumer1")
+	testutil.AssertNoError(t, err, "Error getting event consumer checkpoint")
+	testutil.AssertEquals(t, found, false)
+	testutil.AssertNoError(t, ledger.PutEventConsumerCheckpoint("consumer1", 5), "Error putting event consumer checkpoint")
+	testutil.AssertNoError(t, ledger.PutEventConsumerCheckpoint("consumer2", 2), "Error putting event consumer checkpoint")
+
+	// the checkpoints survive a restart
+	ledger, err = newLedger(DefaultChainID, db.GetDBHandle())
+	testutil.AssertNoError(t, err, "Error while reopening the ledger")
+	nextBlock, found, err := ledger.GetEventConsumerCheckpoint("consumer1")
+	testutil.AssertNoError(t, err, "Error getting event consumer checkpoint")
+	testutil.AssertEquals(t, found, true)
+	testutil.AssertEquals(t, nextBlock, uint64(5))
+
+	// the blocks are replayed without the payload of the deploy transactions
+	ledger.BeginTxBatch(0)
+	tx, err := protos.NewChaincodeDeployTransaction(&protos.ChaincodeDeploymentSpec{ChaincodeSpec: &protos.ChaincodeSpec{ChaincodeID: &protos.ChaincodeID{Name: "cc"}}, CodePackage: []byte("code")}, util.GenerateUUID())
+	testutil.AssertNoError(t, err, "Error while building transaction")
+	testutil.AssertNoError(t, ledger.CommitTxBatch(0, []*protos.Transaction{tx}, nil, []byte("proof")), "Error while committing")
+	block, err := ledger.GetEventBlock(0)
+	testutil.AssertNoError(t, err, "Error getting event block")
+	deploymentSpec := &protos.ChaincodeDeploymentSpec{}
+	testutil.AssertNoError(t, proto.Unmarshal(block.Transactions[0].Payload, deploymentSpec), "Error unmarshalling deployment spec")
+	testutil.AssertNil(t, deploymentSpec.CodePackage)
+}
+
+func TestDiscoveryList(t *testing.T) {
+	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
+	ledger := ledgerTestWrapper.ledger
+
+	list, err := ledger.GetDiscoveryList()
+	testutil.AssertNoError(t, err, "Error getting discovery list")
+	testutil.AssertNil(t, list)
+	list = &protos.DiscoveryList{Addresses: []string{"peer1:30303", "peer2:30303"}, Banned: []string{"peer3:30303"}}
+	testutil.AssertNoError(t, ledger.PutDiscoveryList(list), "Error putting discovery list")
+
+	// the list survives a restart
+	ledger, err = newLedger(DefaultChainID, db.GetDBHandle())
+	testutil.AssertNoError(t, err, "Error while reopening the ledger")
+	persistedList, err := ledger.GetDiscoveryList()
+	testutil.AssertNoError(t, err, "Error getting discovery list")
+	testutil.AssertEquals(t, persistedList, list)
+}
+
+func TestStateTransferCursor(t *testing.T) {
+	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
+	ledger := ledgerTestWrapper.ledger
+	cursor, err := ledger.GetStateTransferCursor()
+	testutil.AssertNoError(t, err, "Error getting state transfer cursor")
+	testutil.AssertNil(t, cursor)
+
+	expected := &StateTransferCursor{BlockNumber: 10, PendingBuckets: []int{3, 300, 70000}}
+	testutil.AssertNoError(t, ledger.SetStateTransferCursor(expected), "Error setting state transfer cursor")
+	// the cursor survives a restart
+	ledger, err = newLedger(DefaultChainID, db.GetDBHandle())
+	testutil.AssertNoError(t, err, "Error while reopening the ledger")
+	cursor, err = ledger.GetStateTransferCursor()
+	testutil.AssertNoError(t, err, "Error getting state transfer cursor")
+	testutil.AssertEquals(t, cursor, expected)
+
+	testutil.AssertNoError(t, ledger.SetStateTransferCursor(nil), "Error clearing state transfer cursor")
+	cursor, err = ledger.GetStateTransferCursor()
+	testutil.AssertNoError(t, err, "Error getting state transfer cursor")
+	testutil.AssertNil(t, cursor)
+}
+
+func TestGetStateBucketHashes(t *testing.T) {
+	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
+	ledger := ledgerTestWrapper.ledger
+	ledger.BeginTxBatch(0)
+	ledger.TxBegin("txUuid")
+	ledger.SetState("chaincode1", "key1", []byte("value1"))
+	ledger.TxFinished("txUuid", true)
+	tx, _ := buildTestTx(t)
+	ledger.CommitTxBatch(0, []*protos.Transaction{tx}, nil, []byte("proof"))
+
+	blockNumber, hashes, err := ledger.GetStateBucketHashes(0, []int{1})
+	testutil.AssertNoError(t, err, "Error getting bucket hashes")
+	testutil.AssertEquals(t, blockNumber, uint64(0))
+	stateHash, _ := ledger.GetTempStateHash()
+	testutil.AssertEquals(t, hashes[0], stateHash)
+
+	shape, err := ledger.GetStateBucketTreeShape()
+	testutil.AssertNoError(t, err, "Error getting bucket tree shape")
+	_, _, err = ledger.GetStateBucketHashes(shape.LowestLevel(), []int{shape.NumBuckets + 1})
+	testutil.AssertError(t, err, "Expected an error for an invalid bucket number")
+
+	snapshot, err := ledger.GetStateBucketsSnapshot([]int{1})
+	testutil.AssertNoError(t, err, "Error getting buckets snapshot")
+	defer snapshot.Release()
+	testutil.AssertEquals(t, snapshot.GetBlockNumber(), uint64(0))
+}
+
+func TestLedgerTxSimulation(t *testing.T) {
+	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
+	ledger := ledgerTestWrapper.ledger
+	ledger.BeginTxBatch(1)
+	ledger.TxBegin("txUuid0")
+	ledger.SetState("chaincode1", "key1", []byte("value1"))
+	ledger.SetState("chaincode1", "key2", []byte("value2"))
+	ledger.TxFinished("txUuid0", true)
+
+	// two txs simulated concurrently against the same state
+	sim1 := ledger.NewTxSimulator("txUuid1")
+	sim2 := ledger.NewTxSimulator("txUuid2")
+	testutil.AssertSame(t, ledger.GetTxSimulator("txUuid1"), sim1)
+
+	value, _ := sim1.GetState("chaincode1", "key1")
+	testutil.AssertEquals(t, value, []byte("value1"))
+	sim1.SetState("chaincode1", "key1", []byte("value1_1"))
+	value, _ = sim1.GetState("chaincode1", "key1")
+	testutil.AssertEquals(t, value, []byte("value1_1"))
+	sim1.Done()
+	testutil.AssertNil(t, ledger.GetTxSimulator("txUuid1"))
+
+	itr, _ := sim2.GetStateRangeScanIterator("chaincode1", "key1", "key9")
+	var keys []string
+	for itr.Next() {
+		key, _ := itr.GetKeyValue()
+		keys = append(keys, key)
+	}
+	testutil.AssertEquals(t, keys, []string{"key1", "key2"})
+	sim2.DeleteState("chaincode1", "key2")
+	sim2.Done()
+
+	// nothing is visible to the batch until the simulation is applied
+	testutil.AssertEquals(t, ledgerTestWrapper.GetState("chaincode1", "key1", false), []byte("value1"))
+
+	stale, _ := sim1.IsStale()
+	testutil.AssertEquals(t, stale, false)
+	testutil.AssertNoError(t, ledger.ApplyTxSimulation(sim1), "Error applying the simulation")
+	testutil.AssertEquals(t, ledgerTestWrapper.GetState("chaincode1", "key1", false), []byte("value1_1"))
+
+	// sim2 read key1, which sim1 changed
+	stale, _ = sim2.IsStale()
+	testutil.AssertEquals(t, stale, true)
+	testutil.AssertEquals(t, ledger.GetTempTxUUIDs(), []string{"txUuid0", "txUuid1"})
+}
+
+func TestLedgerTxSimulationUnsupported(t *testing.T) {
+	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
+	ledger := ledgerTestWrapper.ledger
+	ledger.BeginTxBatch(1)
+	sim := ledger.NewTxSimulator("txUuid1")
+	testutil.AssertError(t, sim.SetState(statemgmt.SystemNamespacePrefix+"x", "key1", []byte("value1")), "Expected an error writing to a system namespace")
+	sim.SetUnsupported("private state")
+	sim.Done()
+	stale, _ := sim.IsStale()
+	testutil.AssertEquals(t, stale, true)
+}
+
+func TestLedgerCommittedTxSimulation(t *testing.T) {
+	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
+	ledger := ledgerTestWrapper.ledger
+	ledger.BeginTxBatch(1)
+	ledger.TxBegin("txUuid0")
+	ledger.SetState("chaincode1", "key1", []byte("value1"))
+	ledger.TxFinished("txUuid0", true)
+	transaction, _ := buildTestTx(t)
+	ledger.CommitTxBatch(1, []*protos.Transaction{transaction}, nil, []byte("proof"))
+
+	ledger.BeginTxBatch(2)
+	ledger.TxBegin("txUuid1")
+	ledger.SetState("chaincode1", "key1", []byte("value1_1"))
+
+	// the changes of the batch in progress are not seen
+	sim := ledger.NewCommittedTxSimulator("txUuid2")
+	value, _ := sim.GetState("chaincode1", "key1")
+	testutil.AssertEquals(t, value, []byte("value1"))
+	sim.GetStateRangeScanIterator("chaincode1", "", "")
+	sim.SetState("chaincode1", "key2", []byte("value2"))
+	sim.DeleteState("chaincode1", "key1")
+	sim.Done()
+	ledger.TxFinished("txUuid1", true)
+
+	reads, rangeReads, writes := sim.GetReadWriteSet()
+	testutil.AssertEquals(t, reads, []*protos.StateRead{{ChaincodeID: "chaincode1", Key: "key1", ValueHash: util.ComputeCryptoHash([]byte("value1"))}})
+	testutil.AssertEquals(t, rangeReads, []*protos.StateRangeRead{{ChaincodeID: "chaincode1", Keys: []string{"key1"},
+		ValueHash: util.ComputeCryptoHash(util.ComputeCryptoHash([]byte("value1")))}})
+	testutil.AssertEquals(t, writes, []*protos.StateWrite{{ChaincodeID: "chaincode1", Key: "key1", IsDelete: true},
+		{ChaincodeID: "chaincode1", Key: "key2", Value: []byte("value2")}})
+	testutil.AssertError(t, ledger.ApplyTxSimulation(sim), "Expected an error applying a simulation against the committed state")
+}
+
+func TestLedgerApplyReadWriteSet(t *testing.T) {
+	ledgerTestWrapper := createFreshDBAndTestLedgerWrapper(t)
+	ledger := ledgerTestWrapper.ledger
+	ledger.BeginTxBatch(1)
+	ledger.TxBegin("txUuid0")
+	ledger.SetState("chaincode1", "key1", []byte("value1"))
+	ledger.TxFinished("txUuid0", true)
+	transaction, _ := buildTestTx(t)
+	ledger.CommitTxBatch(1, []*protos.Transaction{transaction}, nil, []byte("proof"))
+
+	sim := ledger.NewCommittedTxSimulator("txUuid1")
+	value, _ := sim.GetState("chaincode1", "key1")
+	sim.GetStateRangeScanIterator("chaincode1", "", "")
+	sim.SetState("chaincode1", "key1", append(value, []byte("_1")...))
+	sim.Done()
+	reads, rangeReads, writes := sim.GetReadWriteSet()
+
+	// applied as long as the state read is unchanged
+	ledger.BeginTxBatch(2)
+	ledger.TxBegin("txUuid2")
+	testutil.AssertNoError(t, ledger.ApplyReadWriteSet(reads, rangeReads, writes), "Error applying read-write set")
+	ledger.TxFinished("txUuid2", true)
+	testutil.AssertEquals(t, ledgerTestWrapper.GetState("chaincode1", "key1", false), []byte("value1_1"))
+
+	// a second application conflicts with the first one
+	ledger.TxBegin("txUuid3")
+	err := ledger.ApplyReadWriteSet(reads, rangeReads, writes)
+	ledger.TxFinished("txUuid3", false)
+	testutil.AssertError(t, err, "Expected an error applying a stale read-write set")
+	testutil.AssertEquals(t, err.(*Error).Type(), ErrorTypeStaleRead)
+
+	// a key added to a range read conflicts too
+	ledger.TxBegin("txUuid4")
+	ledger.SetState("chaincode1", "key2", []byte("value2"))
+	ledger.TxFinished("txUuid4", true)
+	ledger.TxBegin("txUuid5")
+	err = ledger.ApplyReadWriteSet(nil, rangeReads, nil)
+	ledger.TxFinished("txUuid5", false)
+	testutil.AssertError(t, err, "Expected an error applying a stale range read")
+
+	// writes into system namespaces are refused
+	ledger.TxBegin("txUuid6")
+	err = ledger.ApplyReadWriteSet(nil, nil, []*protos.StateWrite{{ChaincodeID: LifecycleChaincodeID, Key: "key1", Value: []byte("value1")}})
+	ledger.TxFinished("txUuid6", false)
+	testutil.AssertError(t, err, "Expected an error writing into a system namespace")
+}
+
+func TestToBlockStateDelta(t *testing.T) {
+	delta := statemgmt.NewStateDelta()
+	delta.Set("chaincode2", "key1", []byte("value1"), nil)
+	delta.Set("chaincode1", "key2", []byte("value2"), nil)
+	delta.Delete("chaincode1", "key1", []byte("value1"))
+
+	blockStateDelta := ToBlockStateDelta(3, delta, "", "")
+	testutil.AssertEquals(t, blockStateDelta.BlockNumber, uint64(3))
+	testutil.AssertEquals(t, blockStateDelta.KeyValues, []*protos.StateKeyValue{
+		{ChaincodeID: "chaincode1", Key: "key1", Deleted: true},
+		{ChaincodeID: "chaincode1", Key: "key2", Value: []byte("value2")},
+		{ChaincodeID: "chaincode2", Key: "key1", Value: []byte("value1")},
+	})
+	testutil.AssertEquals(t, len(ToBlockStateDelta(3, delta, "chaincode1", "key2").KeyValues), 1)
+}
@@ -0,0 +1,30 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ledger
+
+import (
+	"github.com/hyperledger/fabric/core/metrics"
+)
+
+var (
+	blockCommitLatency = metrics.NewHistogram("ledger_block_commit_duration_seconds",
+		"Time taken by CommitTxBatch to hash the state and persist a block.", metrics.DefBuckets)
+	committedBlocks = metrics.NewCounter("ledger_committed_blocks_total",
+		"Number of blocks committed.")
+	committedTransactions = metrics.NewCounter("ledger_committed_transactions_total",
+		"Number of transactions committed, whose rate is the transaction throughput.")
+)
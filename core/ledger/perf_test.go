@@ -163,7 +163,7 @@ func BenchmarkLedgerRandomTransactions(b *testing.B) {
 	b.Logf(`Running test with params: keyPrefix=%s, kvSize=%d, batchSize=%d, maxKeySuffix=%d, numBatches=%d, numReadsFromLedger=%d, numWritesToLedger=%d`,
 		*keyPrefix, *kvSize, *batchSize, *maxKeySuffix, *numBatches, *numReadsFromLedger, *numWritesToLedger)
 
-	ledger, err := newLedger()
+	ledger, err := newLedger(DefaultChainID, db.GetDBHandle())
 	testutil.AssertNoError(b, err, "Error while constructing ledger")
 
 	chaincode := "chaincodeId"
@@ -204,12 +204,16 @@ func populateDB(tb testing.TB, kvSize int, totalKeys int, keyPrefix string) {
 	dbWrapper := db.NewTestDBWrapper()
 	dbWrapper.CreateFreshDB(tb)
 	batch := gorocksdb.NewWriteBatch()
+	opt := gorocksdb.NewDefaultWriteOptions()
+	defer opt.Destroy()
 	for i := 0; i < totalKeys; i++ {
 		key := []byte(keyPrefix + strconv.Itoa(i))
 		value := testutil.ConstructRandomBytes(tb, kvSize-len(key))
 		batch.Put(key, value)
 		if i%1000 == 0 {
-			dbWrapper.WriteToDB(tb, batch)
+			if err := db.GetDBHandle().DB.Write(opt, batch); err != nil {
+				tb.Fatalf("Error while writing to db. Error:%s", err)
+			}
 			batch = gorocksdb.NewWriteBatch()
 		}
 	}
@@ -20,6 +20,7 @@ import (
 	"os"
 	"testing"
 
+	"github.com/hyperledger/fabric/core/db"
 	"github.com/hyperledger/fabric/core/ledger/statemgmt"
 	"github.com/hyperledger/fabric/core/ledger/testutil"
 	"github.com/hyperledger/fabric/core/util"
@@ -42,7 +43,7 @@ type blockchainTestWrapper struct {
 }
 
 func newTestBlockchainWrapper(t *testing.T) *blockchainTestWrapper {
-	blockchain, err := newBlockchain()
+	blockchain, err := newBlockchain(db.GetDBHandle())
 	testutil.AssertNoError(t, err, "Error while getting handle to chain")
 	return &blockchainTestWrapper{t, blockchain}
 }
@@ -52,13 +53,13 @@ func (testWrapper *blockchainTestWrapper) addNewBlock(block *protos.Block, state
 	defer writeBatch.Destroy()
 	newBlockNumber, err := testWrapper.blockchain.addPersistenceChangesForNewBlock(context.TODO(), block, stateHash, writeBatch)
 	testutil.AssertNoError(testWrapper.t, err, "Error while adding a new block")
-	testDBWrapper.WriteToDB(testWrapper.t, writeBatch)
+	testDBWrapper.WriteToDB(testWrapper.t, db.GetDBHandle().WrapWriteBatch(writeBatch))
 	testWrapper.blockchain.blockPersistenceStatus(true)
 	return newBlockNumber
 }
 
 func (testWrapper *blockchainTestWrapper) fetchBlockchainSizeFromDB() uint64 {
-	size, err := fetchBlockchainSizeFromDB()
+	size, err := fetchBlockchainSizeFromDB(db.GetDBHandle())
 	testutil.AssertNoError(testWrapper.t, err, "Error while fetching blockchain size from db")
 	return size
 }
@@ -165,7 +166,7 @@ type ledgerTestWrapper struct {
 
 func createFreshDBAndTestLedgerWrapper(tb testing.TB) *ledgerTestWrapper {
 	testDBWrapper.CreateFreshDB(tb)
-	ledger, err := newLedger()
+	ledger, err := newLedger(DefaultChainID, db.GetDBHandle())
 	testutil.AssertNoError(tb, err, "Error while constructing ledger")
 	return &ledgerTestWrapper{ledger, tb}
 }
@@ -176,6 +177,12 @@ func (ledgerTestWrapper *ledgerTestWrapper) GetState(chaincodeID string, key str
 	return value
 }
 
+func (ledgerTestWrapper *ledgerTestWrapper) GetPrivateState(chaincodeID string, key string, committed bool) []byte {
+	value, err := ledgerTestWrapper.ledger.GetPrivateState(chaincodeID, key, committed)
+	testutil.AssertNoError(ledgerTestWrapper.tb, err, "error while getting private state from ledger")
+	return value
+}
+
 func (ledgerTestWrapper *ledgerTestWrapper) GetBlockByNumber(blockNumber uint64) *protos.Block {
 	block, err := ledgerTestWrapper.ledger.GetBlockByNumber(blockNumber)
 	testutil.AssertNoError(ledgerTestWrapper.tb, err, "error while getting block from ledger")
@@ -0,0 +1,141 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ledger
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/db/kvstore"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+	"github.com/hyperledger/fabric/protos"
+)
+
+// Private state
+//
+// The private state of a chaincode is kept in the privateCF, keyed by the composite key of the
+// chaincode and the key. It is not part of the world state: it is not hashed into the blocks,
+// not recorded in the state deltas and not transferred to the peers that catch up through state
+// transfer. The changes made by a transaction are kept in memory until the transaction finishes
+// and are persisted in the batch that commits the block, hence, they are discarded along with
+// the changes to the world state when the transaction fails or the batch is rolled back.
+
+// withoutTransient returns the transactions without their transient data, which is passed to
+// the chaincode but never written into the blocks
+func withoutTransient(transactions []*protos.Transaction) []*protos.Transaction {
+	var stripped []*protos.Transaction
+	for i, tx := range transactions {
+		if len(tx.Transient) == 0 {
+			continue
+		}
+		if stripped == nil {
+			stripped = append([]*protos.Transaction(nil), transactions...)
+		}
+		stripped[i] = tx.WithoutTransient()
+	}
+	if stripped == nil {
+		return transactions
+	}
+	return stripped
+}
+
+type privateState struct {
+	currentTxUUID string
+	txDelta       *statemgmt.StateDelta
+	batchDelta    *statemgmt.StateDelta
+}
+
+func newPrivateState() *privateState {
+	return &privateState{"", statemgmt.NewStateDelta(), statemgmt.NewStateDelta()}
+}
+
+func (privateState *privateState) txBegin(txUUID string) {
+	privateState.currentTxUUID = txUUID
+}
+
+func (privateState *privateState) txFinish(txUUID string, txSuccessful bool) {
+	if txSuccessful && !privateState.txDelta.IsEmpty() {
+		privateState.batchDelta.ApplyChanges(privateState.txDelta)
+	}
+	privateState.txDelta = statemgmt.NewStateDelta()
+	privateState.currentTxUUID = ""
+}
+
+func (privateState *privateState) clearInMemoryChanges() {
+	privateState.currentTxUUID = ""
+	privateState.txDelta = statemgmt.NewStateDelta()
+	privateState.batchDelta = statemgmt.NewStateDelta()
+}
+
+func (privateState *privateState) addChangesForPersistence(writeBatch kvstore.WriteBatch) {
+	for _, chaincodeID := range privateState.batchDelta.GetUpdatedChaincodeIds(false) {
+		for key, updatedValue := range privateState.batchDelta.GetUpdates(chaincodeID) {
+			compositeKey := statemgmt.ConstructCompositeKey(chaincodeID, key)
+			if updatedValue.IsDelete() {
+				writeBatch.Delete(kvstore.PrivateCFName, compositeKey)
+			} else {
+				writeBatch.Put(kvstore.PrivateCFName, compositeKey, updatedValue.GetValue())
+			}
+		}
+	}
+}
+
+// GetPrivateState get the private state of the chaincode for the given key. If committed is
+// false, this first looks in memory for the changes made by the ongoing transaction and
+// transaction-batch and, if missing, reads it from the db
+func (ledger *Ledger) GetPrivateState(chaincodeID string, key string, committed bool) ([]byte, error) {
+	if !committed {
+		for _, delta := range []*statemgmt.StateDelta{ledger.private.txDelta, ledger.private.batchDelta} {
+			if updatedValue := delta.Get(chaincodeID, key); updatedValue != nil {
+				return statemgmt.Copy(updatedValue.GetValue()), nil
+			}
+		}
+	}
+	return ledger.openchainDB.KVStore().Get(kvstore.PrivateCFName, statemgmt.ConstructCompositeKey(chaincodeID, key))
+}
+
+// SetPrivateState sets the private state of the chaincode for the given key. The change is
+// only visible to the ongoing transaction until the transaction finishes successfully
+func (ledger *Ledger) SetPrivateState(chaincodeID string, key string, value []byte) error {
+	if key == "" || value == nil {
+		return newLedgerError(ErrorTypeInvalidArgument,
+			fmt.Sprintf("An empty string key or a nil value is not supported. Method invoked with key='%s', value='%#v'", key, value))
+	}
+	if err := ledger.checkPrivateStateWrite(chaincodeID); err != nil {
+		return err
+	}
+	ledger.private.txDelta.Set(chaincodeID, key, value, nil)
+	return nil
+}
+
+// DeletePrivateState deletes the private state of the chaincode for the given key
+func (ledger *Ledger) DeletePrivateState(chaincodeID string, key string) error {
+	if err := ledger.checkPrivateStateWrite(chaincodeID); err != nil {
+		return err
+	}
+	ledger.private.txDelta.Delete(chaincodeID, key, nil)
+	return nil
+}
+
+func (ledger *Ledger) checkPrivateStateWrite(chaincodeID string) error {
+	if err := checkNotSystemNamespace(chaincodeID); err != nil {
+		return err
+	}
+	if ledger.private.currentTxUUID == "" {
+		return newLedgerError(ErrorTypeInvalidArgument, "private state can only be written within a transaction")
+	}
+	return nil
+}
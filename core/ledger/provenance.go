@@ -0,0 +1,88 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ledger
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/protos"
+)
+
+// ProvenanceEntry describes a change made to a key by a transaction
+type ProvenanceEntry struct {
+	BlockNumber uint64 `json:"blockNumber"`
+	TxUUID      string `json:"txUUID"`
+	// WriterChaincodeID is the name of the chaincode invoked by the transaction
+	WriterChaincodeID string `json:"writerChaincodeID"`
+	// ValueHash is the crypto-hash of the value written, nil if the key was deleted
+	ValueHash []byte `json:"valueHash"`
+}
+
+// GetKeyProvenance returns the chain of changes made to the key, oldest first, by the
+// transactions of the committed blocks for which the state delta is retained (see
+// 'ledger.state.deltaHistorySize'), one entry for each transaction that wrote the key.
+// Each entry is assembled from the write index maintained by the state and the
+// transaction stored in the blockchain.
+func (ledger *Ledger) GetKeyProvenance(chaincodeID string, key string) ([]*ProvenanceEntry, error) {
+	writes, err := ledger.state.GetKeyWrites(chaincodeID, key)
+	if err != nil {
+		return nil, err
+	}
+	entries := []*ProvenanceEntry{}
+	for _, write := range writes {
+		for _, txWrite := range write.TxWrites {
+			entry := &ProvenanceEntry{BlockNumber: write.BlockNumber, TxUUID: txWrite.TxUUID, ValueHash: txWrite.ValueHash}
+			tx, err := ledger.GetTransactionByUUID(txWrite.TxUUID)
+			if err != nil && err != ErrResourceNotFound {
+				return nil, err
+			}
+			if tx != nil {
+				entry.WriterChaincodeID = getTxChaincodeName(tx)
+			}
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// GetHistoryForKey returns the values taken by the key, oldest first, at the end of the
+// committed blocks for which the state delta is retained, along with the last transaction
+// of each block which changed the key
+func (ledger *Ledger) GetHistoryForKey(chaincodeID string, key string) ([]*protos.KeyModification, error) {
+	writes, err := ledger.state.GetKeyWrites(chaincodeID, key)
+	if err != nil {
+		return nil, err
+	}
+	modifications := make([]*protos.KeyModification, 0, len(writes))
+	for _, write := range writes {
+		modifications = append(modifications, &protos.KeyModification{
+			TxUUID:      write.TxUUID,
+			BlockNumber: write.BlockNumber,
+			Value:       write.Value,
+			IsDelete:    write.Value == nil,
+		})
+	}
+	return modifications, nil
+}
+
+func getTxChaincodeName(tx *protos.Transaction) string {
+	chaincodeID := &protos.ChaincodeID{}
+	if err := proto.Unmarshal(tx.ChaincodeID, chaincodeID); err != nil {
+		ledgerLogger.Warning("Error unmarshalling chaincodeID of transaction [%s]: %s", tx.Uuid, err)
+		return ""
+	}
+	return chaincodeID.Name
+}
@@ -0,0 +1,173 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ledger
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// MismatchType describes how a key of a namespace differs from the external dataset
+type MismatchType string
+
+const (
+	// MismatchMissing is reported for a key present in the dataset but not in the namespace
+	MismatchMissing = MismatchType("Missing")
+	// MismatchUnexpected is reported for a key present in the namespace but not in the dataset
+	MismatchUnexpected = MismatchType("Unexpected")
+	// MismatchValue is reported for a key that has different values in the namespace and in the dataset
+	MismatchValue = MismatchType("Value")
+)
+
+// ReconciliationMismatch is a key for which the namespace does not agree with the dataset
+type ReconciliationMismatch struct {
+	Key      string       `json:"key"`
+	Type     MismatchType `json:"type"`
+	Expected []byte       `json:"expected,omitempty"`
+	Actual   []byte       `json:"actual,omitempty"`
+}
+
+// ReconciliationReport is the outcome of comparing a namespace with an external dataset
+type ReconciliationReport struct {
+	ChaincodeID  string                    `json:"chaincodeID"`
+	BlockNumber  uint64                    `json:"blockNumber"`
+	KeysInLedger int                       `json:"keysInLedger"`
+	KeysExpected int                       `json:"keysExpected"`
+	Mismatches   []*ReconciliationMismatch `json:"mismatches"`
+}
+
+// Reconciled returns true if the namespace agrees with the dataset
+func (report *ReconciliationReport) Reconciled() bool {
+	return len(report.Mismatches) == 0
+}
+
+// ReconcileNamespace compares the key-values of the namespace chaincodeID, as these were
+// after the block blockNumber was committed, with the expected key-values and reports the
+// mismatches sorted by key. The state at a past block is derived from the current state by
+// rolling back the state deltas of the following blocks and hence, blockNumber has to be
+// within the state delta history (see 'ledger.state.deltaHistorySize').
+func (ledger *Ledger) ReconcileNamespace(chaincodeID string, blockNumber uint64, expected map[string][]byte) (*ReconciliationReport, error) {
+	actual, err := ledger.getNamespaceAtBlock(chaincodeID, blockNumber)
+	if err != nil {
+		return nil, err
+	}
+	report := &ReconciliationReport{ChaincodeID: chaincodeID, BlockNumber: blockNumber,
+		KeysInLedger: len(actual), KeysExpected: len(expected)}
+	keys := make(map[string]bool)
+	for k := range actual {
+		keys[k] = true
+	}
+	for k := range expected {
+		keys[k] = true
+	}
+	sortedKeys := make([]string, 0, len(keys))
+	for k := range keys {
+		sortedKeys = append(sortedKeys, k)
+	}
+	sort.Strings(sortedKeys)
+	for _, k := range sortedKeys {
+		actualValue, inLedger := actual[k]
+		expectedValue, inDataset := expected[k]
+		switch {
+		case !inLedger:
+			report.Mismatches = append(report.Mismatches, &ReconciliationMismatch{k, MismatchMissing, expectedValue, nil})
+		case !inDataset:
+			report.Mismatches = append(report.Mismatches, &ReconciliationMismatch{k, MismatchUnexpected, nil, actualValue})
+		case !bytes.Equal(actualValue, expectedValue):
+			report.Mismatches = append(report.Mismatches, &ReconciliationMismatch{k, MismatchValue, expectedValue, actualValue})
+		}
+	}
+	return report, nil
+}
+
+// getNamespaceAtBlock returns the key-values of the namespace after the block blockNumber was committed.
+// The current key-values of the namespace and the state deltas are read from the same DB snapshot so
+// that the blocks committed meanwhile are not rolled back on top of a state that does not include them
+func (ledger *Ledger) getNamespaceAtBlock(chaincodeID string, blockNumber uint64) (map[string][]byte, error) {
+	dbSnapshot := ledger.openchainDB.KVStore().NewSnapshot()
+	defer dbSnapshot.Release()
+	blockHeight, err := fetchBlockchainSizeFromSnapshot(dbSnapshot)
+	if err != nil {
+		return nil, err
+	}
+	if blockNumber >= blockHeight {
+		return nil, ErrOutOfBounds
+	}
+	itr, err := ledger.state.GetRangeScanIteratorFromSnapshot(dbSnapshot, chaincodeID, "", "")
+	if err != nil {
+		return nil, err
+	}
+	kvs := make(map[string][]byte)
+	for itr.Next() {
+		key, value := itr.GetKeyValue()
+		kvs[key] = value
+	}
+	itr.Close()
+	for n := blockHeight - 1; n > blockNumber; n-- {
+		delta, err := ledger.state.FetchStateDeltaFromSnapshot(dbSnapshot, n)
+		if err != nil {
+			return nil, err
+		}
+		if delta == nil {
+			return nil, newLedgerError(ErrorTypeResourceNotFound,
+				fmt.Sprintf("State delta for block [%d] is not available. Block [%d] is beyond the state delta history", n, blockNumber))
+		}
+		for key, updatedValue := range delta.GetUpdates(chaincodeID) {
+			if updatedValue.GetPreviousValue() == nil {
+				delete(kvs, key)
+			} else {
+				kvs[key] = updatedValue.GetPreviousValue()
+			}
+		}
+	}
+	return kvs, nil
+}
+
+// ParseReconciliationDatasetCSV reads a dataset of expected key-values from CSV records of two fields - key and value
+func ParseReconciliationDatasetCSV(reader io.Reader) (map[string][]byte, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = 2
+	records, err := csvReader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing CSV dataset: %s", err)
+	}
+	dataset := make(map[string][]byte)
+	for _, record := range records {
+		if _, ok := dataset[record[0]]; ok {
+			return nil, fmt.Errorf("Duplicate key [%s] in CSV dataset", record[0])
+		}
+		dataset[record[0]] = []byte(record[1])
+	}
+	return dataset, nil
+}
+
+// ParseReconciliationDatasetJSON reads a dataset of expected key-values from a JSON object with string values
+func ParseReconciliationDatasetJSON(reader io.Reader) (map[string][]byte, error) {
+	var kvs map[string]string
+	if err := json.NewDecoder(reader).Decode(&kvs); err != nil {
+		return nil, fmt.Errorf("Error parsing JSON dataset: %s", err)
+	}
+	dataset := make(map[string][]byte)
+	for k, v := range kvs {
+		dataset[k] = []byte(v)
+	}
+	return dataset, nil
+}
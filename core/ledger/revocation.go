@@ -0,0 +1,57 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ledger
+
+import (
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+)
+
+// RevocationChaincodeID is the system namespace in which the certificate revocation lists are
+// recorded, each under the name of the CA which issued it. Being part of the world state, the
+// lists are the same on all the peers at a given block, so that they reject the same transactions.
+const RevocationChaincodeID = statemgmt.SystemNamespacePrefix + "_revocation"
+
+// SetRevocationList records the DER encoded certificate revocation list of the CA, replacing
+// the one recorded before. Similar to ActivateFeature, this has to be invoked in the context of
+// a transaction so that the list is updated on all the peers at the same point.
+func (ledger *Ledger) SetRevocationList(issuer string, crl []byte) error {
+	if issuer == "" {
+		return newLedgerError(ErrorTypeInvalidArgument, "Empty issuer of certificate revocation list")
+	}
+	return ledger.setSystemState(RevocationChaincodeID, issuer, crl)
+}
+
+// GetRevocationList returns the certificate revocation list recorded for the CA, nil if none
+func (ledger *Ledger) GetRevocationList(issuer string, committed bool) ([]byte, error) {
+	return ledger.state.Get(RevocationChaincodeID, issuer, committed)
+}
+
+// GetRevocationLists returns the certificate revocation lists recorded in the committed state,
+// by the name of the CA which issued them
+func (ledger *Ledger) GetRevocationLists() (map[string][]byte, error) {
+	itr, err := ledger.state.GetRangeScanIterator(RevocationChaincodeID, "", "", true)
+	if err != nil {
+		return nil, err
+	}
+	defer itr.Close()
+	lists := make(map[string][]byte)
+	for itr.Next() {
+		k, v := itr.GetKeyValue()
+		lists[k] = v
+	}
+	return lists, nil
+}
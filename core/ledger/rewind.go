@@ -0,0 +1,118 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ledger
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/errors"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+)
+
+// Rewinding the state
+//
+// A peer whose state went astray from the one of the network, e.g. because a transaction did
+// not execute in the same way, commits blocks whose state hashes differ from the ones of the
+// other peers from some block on. RewindState brings the state back to the last block the peer
+// has in common with the network, from which the state deltas of the blocks of the network can
+// be played forward, instead of the whole state being transferred. The state is rolled back by
+// applying the retained state deltas of the blocks that follow inverted, or, if these are no
+// longer retained, rebuilt from the latest checkpoint at or before the block and rolled forward.
+
+// RewindState rolls the state, which is the one of block stateBlockNumber, back to the state of
+// block blockNumber. The blocks that follow may already have been replaced by the ones of
+// another peer, but the state deltas retained for them have to be the ones the state has been
+// built with. The resulting state hash has to match the one of block blockNumber, otherwise the
+// state is left unchanged. The state deltas of the blocks rolled back are removed. This must
+// not be invoked while a transaction-batch is in progress.
+func (ledger *Ledger) RewindState(stateBlockNumber uint64, blockNumber uint64) error {
+	if blockNumber >= stateBlockNumber {
+		return fmt.Errorf("Cannot rewind the state of block [%d] to block [%d]", stateBlockNumber, blockNumber)
+	}
+	block, err := ledger.GetBlockByNumber(blockNumber)
+	if err != nil {
+		return err
+	}
+	stateDelta, err := ledger.getRewindStateDelta(stateBlockNumber, blockNumber)
+	if err != nil {
+		return err
+	}
+	if stateDelta == nil {
+		ledgerLogger.Info("The state deltas of blocks [%d] to [%d] are not all retained, rebuilding the state of block [%d] from a checkpoint",
+			blockNumber+1, stateBlockNumber, blockNumber)
+		if stateDelta, err = ledger.getRewindStateDeltaFromCheckpoint(blockNumber); err != nil {
+			return err
+		}
+	}
+	id := fmt.Sprintf("rewind-%d", blockNumber)
+	matched, err := ledger.commitStateDeltaIfMatches(id, stateDelta, block.StateHash)
+	if err != nil {
+		return err
+	}
+	if !matched {
+		return fmt.Errorf("The state rewound to block [%d] does not match the state hash of the block", blockNumber)
+	}
+	if err := ledger.state.RemoveStateDeltas(blockNumber+1, stateBlockNumber); err != nil {
+		return err
+	}
+	ledgerLogger.Info("State rewound from block [%d] to block [%d]", stateBlockNumber, blockNumber)
+	return nil
+}
+
+// getRewindStateDelta returns a delta that turns the state of block stateBlockNumber into the
+// state of block blockNumber, by inverting the state deltas of the blocks in between. nil is
+// returned if any of these state deltas is not retained.
+func (ledger *Ledger) getRewindStateDelta(stateBlockNumber uint64, blockNumber uint64) (*statemgmt.StateDelta, error) {
+	forward := statemgmt.NewStateDelta()
+	for i := blockNumber + 1; i <= stateBlockNumber; i++ {
+		delta, err := ledger.state.FetchStateDeltaFromDB(i)
+		if err != nil {
+			return nil, err
+		}
+		if delta == nil {
+			return nil, nil
+		}
+		forward.ApplyChanges(delta)
+	}
+	// the state implementations do not all support StateDelta.RollBackwards, invert explicitly
+	inverted := statemgmt.NewStateDelta()
+	for _, chaincodeID := range forward.GetUpdatedChaincodeIds(false) {
+		for key, updatedValue := range forward.GetUpdates(chaincodeID) {
+			if updatedValue.GetPreviousValue() == nil {
+				inverted.Delete(chaincodeID, key, updatedValue.GetValue())
+			} else {
+				inverted.Set(chaincodeID, key, updatedValue.GetPreviousValue(), updatedValue.GetValue())
+			}
+		}
+	}
+	return inverted, nil
+}
+
+// getRewindStateDeltaFromCheckpoint returns a delta that turns the current state into the state
+// of block blockNumber, rebuilt from the latest checkpoint at or before the block
+func (ledger *Ledger) getRewindStateDeltaFromCheckpoint(blockNumber uint64) (*statemgmt.StateDelta, error) {
+	checkpoints, err := ledger.GetCheckpoints()
+	if err != nil {
+		return nil, err
+	}
+	for i := len(checkpoints) - 1; i >= 0; i-- {
+		if checkpoints[i].BlockNumber <= blockNumber {
+			return ledger.getStateDeltaFromCheckpoint(checkpoints[i].BlockNumber, blockNumber)
+		}
+	}
+	return nil, errors.Errorf(errors.StateDeltaMissing, "The state of block [%d] can be rebuilt from neither the retained state deltas nor a checkpoint", blockNumber)
+}
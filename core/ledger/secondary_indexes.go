@@ -0,0 +1,239 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ledger
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/db"
+	"github.com/hyperledger/fabric/core/db/kvstore"
+	"github.com/hyperledger/fabric/core/errors"
+	"github.com/hyperledger/fabric/protos"
+	google_protobuf "google/protobuf"
+)
+
+// Secondary indexes
+//
+// The secondaryIndexesCF maps the name of a chaincode to the blocks holding its transactions
+// (along with the indexes of these transactions within the block) and the timestamp of a block
+// (see getBlockTimestamp) to its number. Unlike the indexes of the indexesCF (block hash -> block number, tx uuid ->
+// (block number, tx index)) they are always written in the batch that persists the block.
+//
+// The blocks persisted before the secondary indexes have been introduced are indexed in the
+// background, from the most recent block down to the genesis block, and lowestIndexedBlockKey
+// records the progress. The queries return an error until this backfill has completed.
+
+var lowestIndexedBlockKey = []byte{0}
+var prefixChaincodeBlockKey = byte(1)
+var prefixTimestampBlockKey = byte(2)
+
+const secondaryIndexesBackfillBatchSize = 100
+
+// ErrSecondaryIndexesNotReady is returned by the queries on the secondary indexes while the
+// blocks persisted before the indexes have been introduced are being indexed
+var ErrSecondaryIndexesNotReady = newLedgerError(ErrorTypeNotReady, "ledger: secondary indexes are being built")
+
+func addSecondaryIndexesForPersistence(block *protos.Block, blockNumber uint64, writeBatch kvstore.WriteBatch) {
+	for chaincodeID, txIndexes := range getTxIndexesByChaincodeID(block) {
+		writeBatch.Put(kvstore.SecondaryIndexesCFName, encodeChaincodeBlockKey(chaincodeID, blockNumber), encodeListTxIndexes(txIndexes))
+	}
+	if timestamp := getBlockTimestamp(block); timestamp != nil {
+		writeBatch.Put(kvstore.SecondaryIndexesCFName, encodeTimestampBlockKey(timestamp.Seconds, blockNumber), []byte{})
+	}
+}
+
+func removeSecondaryIndexesForPersistence(block *protos.Block, blockNumber uint64, writeBatch kvstore.WriteBatch) {
+	for chaincodeID := range getTxIndexesByChaincodeID(block) {
+		writeBatch.Delete(kvstore.SecondaryIndexesCFName, encodeChaincodeBlockKey(chaincodeID, blockNumber))
+	}
+	if timestamp := getBlockTimestamp(block); timestamp != nil {
+		writeBatch.Delete(kvstore.SecondaryIndexesCFName, encodeTimestampBlockKey(timestamp.Seconds, blockNumber))
+	}
+}
+
+// getBlockTimestamp returns the timestamp of the block if the consensus set one, the time at
+// which the block has been committed otherwise
+func getBlockTimestamp(block *protos.Block) *google_protobuf.Timestamp {
+	if block.Timestamp != nil {
+		return block.Timestamp
+	}
+	if block.NonHashData != nil {
+		return block.NonHashData.LocalLedgerCommitTimestamp
+	}
+	return nil
+}
+
+// getTxIndexesByChaincodeID returns the indexes of the transactions of the block by the name of
+// the chaincode they target. Transactions whose chaincode ID cannot be read, e.g. because it is
+// encrypted, are not indexed.
+func getTxIndexesByChaincodeID(block *protos.Block) map[string][]uint64 {
+	txIndexes := make(map[string][]uint64)
+	for txIndex, tx := range block.GetTransactions() {
+		chaincodeID := &protos.ChaincodeID{}
+		if err := proto.Unmarshal(tx.ChaincodeID, chaincodeID); err != nil || chaincodeID.Name == "" {
+			continue
+		}
+		txIndexes[chaincodeID.Name] = append(txIndexes[chaincodeID.Name], uint64(txIndex))
+	}
+	return txIndexes
+}
+
+// initSecondaryIndexes records, for a DB created before the secondary indexes have been
+// introduced, that the blocks below the current size have to be backfilled
+func initSecondaryIndexes(openchainDB *db.OpenchainDB, size uint64) error {
+	value, err := openchainDB.Get(openchainDB.SecondaryIndexesCF, lowestIndexedBlockKey)
+	if err != nil || value != nil {
+		return err
+	}
+	return openchainDB.Put(openchainDB.SecondaryIndexesCF, lowestIndexedBlockKey, encodeUint64(size))
+}
+
+func fetchLowestIndexedBlockFromDB(openchainDB *db.OpenchainDB) (uint64, error) {
+	value, err := openchainDB.Get(openchainDB.SecondaryIndexesCF, lowestIndexedBlockKey)
+	if err != nil || value == nil {
+		return 0, err
+	}
+	return decodeToUint64(value), nil
+}
+
+// GetBlockNumbersByChaincodeID returns, in increasing order, the numbers of the blocks holding
+// transactions for the chaincode with the given name
+func (ledger *Ledger) GetBlockNumbersByChaincodeID(chaincodeID string) ([]uint64, error) {
+	prefix := encodeChaincodeBlockKeyPrefix(chaincodeID)
+	return ledger.scanSecondaryIndexes(prefix, prefix, nil)
+}
+
+// GetBlockNumbersByTimeRange returns the numbers of the blocks whose timestamp is within
+// [start, end), in increasing order of timestamp. Timestamps are indexed with a precision of
+// one second.
+func (ledger *Ledger) GetBlockNumbersByTimeRange(start time.Time, end time.Time) ([]uint64, error) {
+	if start.Unix() < 0 {
+		start = time.Unix(0, 0)
+	}
+	if !end.After(start) {
+		return nil, nil
+	}
+	return ledger.scanSecondaryIndexes([]byte{prefixTimestampBlockKey},
+		encodeTimestampBlockKey(start.Unix(), 0), encodeTimestampBlockKey(end.Unix(), 0))
+}
+
+// scanSecondaryIndexes returns the block numbers ending the keys which start with prefix, from
+// the key startKey included to the key endKey excluded (or to the end of the prefix if nil)
+func (ledger *Ledger) scanSecondaryIndexes(prefix []byte, startKey []byte, endKey []byte) ([]uint64, error) {
+	lowestIndexedBlock, err := fetchLowestIndexedBlockFromDB(ledger.openchainDB)
+	if err != nil {
+		return nil, err
+	}
+	if lowestIndexedBlock > 0 {
+		return nil, ErrSecondaryIndexesNotReady
+	}
+	itr := ledger.openchainDB.KVStore().NewIterator(kvstore.SecondaryIndexesCFName)
+	defer itr.Close()
+	var blockNumbers []uint64
+	for itr.Seek(startKey); itr.Valid(); itr.Next() {
+		key := itr.Key()
+		if !bytes.HasPrefix(key, prefix) || (endKey != nil && bytes.Compare(key, endKey) >= 0) {
+			break
+		}
+		blockNumbers = append(blockNumbers, decodeToUint64(key[len(key)-8:]))
+	}
+	return blockNumbers, itr.Err()
+}
+
+// secondaryIndexesBackfiller indexes, in the background, the blocks persisted before the
+// secondary indexes have been introduced
+type secondaryIndexesBackfiller struct {
+	blockchain *blockchain
+	stopped    chan struct{}
+	done       chan struct{}
+}
+
+func newSecondaryIndexesBackfiller(blockchain *blockchain) *secondaryIndexesBackfiller {
+	return &secondaryIndexesBackfiller{blockchain, make(chan struct{}), make(chan struct{})}
+}
+
+func (backfiller *secondaryIndexesBackfiller) start() {
+	go backfiller.run()
+}
+
+func (backfiller *secondaryIndexesBackfiller) stop() {
+	close(backfiller.stopped)
+	<-backfiller.done
+}
+
+func (backfiller *secondaryIndexesBackfiller) run() {
+	defer close(backfiller.done)
+	openchainDB := backfiller.blockchain.openchainDB
+	lowestIndexedBlock, err := fetchLowestIndexedBlockFromDB(openchainDB)
+	if err != nil {
+		ledgerLogger.Error("Error reading the progress of the secondary indexes backfill: %s", err)
+		return
+	}
+	if lowestIndexedBlock == 0 {
+		return
+	}
+	ledgerLogger.Info("Building the secondary indexes of blocks [0] to [%d]", lowestIndexedBlock-1)
+	for lowestIndexedBlock > 0 {
+		select {
+		case <-backfiller.stopped:
+			return
+		default:
+		}
+		if lowestIndexedBlock, err = backfiller.indexBlocks(lowestIndexedBlock); err != nil {
+			ledgerLogger.Error("Error building the secondary indexes: %s", err)
+			return
+		}
+	}
+	ledgerLogger.Info("Secondary indexes built")
+}
+
+// indexBlocks indexes a batch of blocks below lowestIndexedBlock and returns the new lowest indexed block
+func (backfiller *secondaryIndexesBackfiller) indexBlocks(lowestIndexedBlock uint64) (uint64, error) {
+	openchainDB := backfiller.blockchain.openchainDB
+	writeBatch := openchainDB.KVStore().NewWriteBatch()
+	defer writeBatch.Destroy()
+	for i := 0; i < secondaryIndexesBackfillBatchSize && lowestIndexedBlock > 0; i++ {
+		block, err := backfiller.blockchain.getBlock(lowestIndexedBlock - 1)
+		if err != nil {
+			return 0, err
+		}
+		if block == nil {
+			return 0, errors.Errorf(errors.NotFound, "Block [%d] is not present in the blockchain", lowestIndexedBlock-1)
+		}
+		lowestIndexedBlock--
+		addSecondaryIndexesForPersistence(block, lowestIndexedBlock, writeBatch)
+	}
+	writeBatch.Put(kvstore.SecondaryIndexesCFName, lowestIndexedBlockKey, encodeUint64(lowestIndexedBlock))
+	return lowestIndexedBlock, openchainDB.KVStore().Write(writeBatch, false)
+}
+
+func encodeChaincodeBlockKeyPrefix(chaincodeID string) []byte {
+	b := proto.NewBuffer([]byte{prefixChaincodeBlockKey})
+	b.EncodeRawBytes([]byte(chaincodeID))
+	return b.Bytes()
+}
+
+func encodeChaincodeBlockKey(chaincodeID string, blockNumber uint64) []byte {
+	return append(encodeChaincodeBlockKeyPrefix(chaincodeID), encodeUint64(blockNumber)...)
+}
+
+func encodeTimestampBlockKey(seconds int64, blockNumber uint64) []byte {
+	key := append([]byte{prefixTimestampBlockKey}, encodeUint64(uint64(seconds))...)
+	return append(key, encodeUint64(blockNumber)...)
+}
@@ -0,0 +1,127 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ledger
+
+import (
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt/state"
+)
+
+// GetStateBucketTreeShape returns the shape of the tree of buckets in which the state is
+// organized. Two peers can compare the crypto-hashes of their buckets only if the shapes match.
+func (ledger *Ledger) GetStateBucketTreeShape() (*statemgmt.BucketTreeShape, error) {
+	return ledger.state.GetBucketTreeShape()
+}
+
+// GetStateBucketHashes returns the crypto-hashes of the buckets with the given numbers at the
+// given level of the tree of buckets, along with the number of the block whose state they
+// belong to. The crypto-hash of an empty bucket is nil.
+func (ledger *Ledger) GetStateBucketHashes(level int, bucketNumbers []int) (uint64, [][]byte, error) {
+	dbSnapshot := ledger.openchainDB.KVStore().NewSnapshot()
+	defer dbSnapshot.Release()
+	blockHeight, err := fetchBlockchainSizeFromSnapshot(dbSnapshot)
+	if err != nil {
+		return 0, nil, err
+	}
+	var blockNumber uint64
+	if blockHeight > 0 {
+		blockNumber = blockHeight - 1
+	}
+	hashes, err := ledger.state.GetBucketHashes(dbSnapshot, level, bucketNumbers)
+	if err != nil {
+		return 0, nil, err
+	}
+	return blockNumber, hashes, nil
+}
+
+// GetStateBucketsSnapshot returns a point-in-time view of the key-values held by the buckets
+// with the given numbers at the lowest level of the tree of buckets, along with the number of
+// the block whose state they belong to (see GetStateBucketHashes). You must call
+// stateSnapshot.Release() once you are done with the snapshot to free up resources.
+func (ledger *Ledger) GetStateBucketsSnapshot(bucketNumbers []int) (*state.StateSnapshot, error) {
+	dbSnapshot := ledger.openchainDB.KVStore().NewSnapshot()
+	blockHeight, err := fetchBlockchainSizeFromSnapshot(dbSnapshot)
+	if err != nil {
+		dbSnapshot.Release()
+		return nil, err
+	}
+	var blockNumber uint64
+	if blockHeight > 0 {
+		blockNumber = blockHeight - 1
+	}
+	snapshot, err := ledger.state.GetBucketsSnapshot(blockNumber, dbSnapshot, bucketNumbers)
+	if err != nil {
+		dbSnapshot.Release()
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// StateTransferCursor records the progress of a state transfer by buckets so that it can be
+// resumed, instead of restarted, after the peer is interrupted
+type StateTransferCursor struct {
+	// BlockNumber is the block whose state the remote peer reported for the pending buckets
+	BlockNumber uint64
+	// PendingBuckets holds the numbers of the buckets at the lowest level still to be received
+	PendingBuckets []int
+}
+
+var stateTransferCursorKey = []byte("ledger.stateTransferCursor")
+
+// GetStateTransferCursor returns the persisted progress of the state transfer, nil if none is in progress
+func (ledger *Ledger) GetStateTransferCursor() (*StateTransferCursor, error) {
+	cursorBytes, err := ledger.openchainDB.Get(ledger.openchainDB.PersistCF, stateTransferCursorKey)
+	if err != nil || cursorBytes == nil {
+		return nil, err
+	}
+	buffer := proto.NewBuffer(cursorBytes)
+	blockNumber, err := buffer.DecodeVarint()
+	if err != nil {
+		return nil, fmt.Errorf("Error decoding the state transfer cursor: %s", err)
+	}
+	numBuckets, err := buffer.DecodeVarint()
+	if err != nil {
+		return nil, fmt.Errorf("Error decoding the state transfer cursor: %s", err)
+	}
+	cursor := &StateTransferCursor{BlockNumber: blockNumber}
+	for i := uint64(0); i < numBuckets; i++ {
+		bucketNumber, err := buffer.DecodeVarint()
+		if err != nil {
+			return nil, fmt.Errorf("Error decoding the state transfer cursor: %s", err)
+		}
+		cursor.PendingBuckets = append(cursor.PendingBuckets, int(bucketNumber))
+	}
+	return cursor, nil
+}
+
+// SetStateTransferCursor persists the progress of the state transfer. A nil cursor records
+// that no state transfer is in progress.
+func (ledger *Ledger) SetStateTransferCursor(cursor *StateTransferCursor) error {
+	if cursor == nil {
+		return ledger.openchainDB.Delete(ledger.openchainDB.PersistCF, stateTransferCursorKey)
+	}
+	buffer := proto.NewBuffer([]byte{})
+	buffer.EncodeVarint(cursor.BlockNumber)
+	buffer.EncodeVarint(uint64(len(cursor.PendingBuckets)))
+	for _, bucketNumber := range cursor.PendingBuckets {
+		buffer.EncodeVarint(uint64(bucketNumber))
+	}
+	return ledger.openchainDB.Put(ledger.openchainDB.PersistCF, stateTransferCursorKey, buffer.Bytes())
+}
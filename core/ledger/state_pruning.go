@@ -0,0 +1,79 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ledger
+
+import (
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// StatePruningScheduler prunes the state of the open ledgers periodically (see PruneState)
+type StatePruningScheduler struct {
+	interval time.Duration
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewStatePruningSchedulerFromConfig returns a scheduler as per 'ledger.state.pruning',
+// or nil if the periodic pruning is disabled
+func NewStatePruningSchedulerFromConfig() *StatePruningScheduler {
+	interval := viper.GetDuration("ledger.state.pruning.interval")
+	if interval <= 0 {
+		return nil
+	}
+	return &StatePruningScheduler{interval: interval, stop: make(chan struct{})}
+}
+
+// Start runs the pruning passes in the background until Stop is invoked
+func (scheduler *StatePruningScheduler) Start() {
+	ledgerLogger.Info("Pruning the state every %s", scheduler.interval)
+	go func() {
+		ticker := time.NewTicker(scheduler.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				scheduler.pruneAll()
+			case <-scheduler.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the pruning passes. A pass in progress is not interrupted.
+func (scheduler *StatePruningScheduler) Stop() {
+	scheduler.stopOnce.Do(func() { close(scheduler.stop) })
+}
+
+func (scheduler *StatePruningScheduler) pruneAll() {
+	defaultLedger, err := GetLedger()
+	if err != nil {
+		ledgerLogger.Error("Error getting the ledger for pruning: %s", err)
+		return
+	}
+	for _, ledger := range append([]*Ledger{defaultLedger}, getOpenChainLedgers()...) {
+		if !ledger.state.IsPruningSupported() {
+			continue
+		}
+		if _, err := ledger.PruneState(); err != nil {
+			ledgerLogger.Error("Error during the periodic pruning of the state of chain [%s]: %s", ledger.chainID, err)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statemgmt
+
+import (
+	"github.com/hyperledger/fabric/core/db/kvstore"
+)
+
+// BucketTreeShape describes how a state implementation groups the keys in a tree of buckets.
+// The crypto-hashes of the buckets of two peers can be compared only if their trees have the
+// same shape.
+type BucketTreeShape struct {
+	// NumBuckets is the number of buckets at the lowest level of the tree
+	NumBuckets int
+	// MaxGroupingAtEachLevel is the maximum number of children of a bucket
+	MaxGroupingAtEachLevel int
+	// BucketHashFunction is the name of the function that assigns the keys to the buckets
+	BucketHashFunction string
+}
+
+// LowestLevel returns the level of the buckets that hold the keys, the root being at level 0
+func (shape *BucketTreeShape) LowestLevel() int {
+	level := 0
+	for numBuckets := shape.NumBuckets; numBuckets > 1; level++ {
+		numBuckets = (numBuckets + shape.MaxGroupingAtEachLevel - 1) / shape.MaxGroupingAtEachLevel
+	}
+	return level
+}
+
+// NumBucketsAt returns the number of buckets at the given level
+func (shape *BucketTreeShape) NumBucketsAt(level int) int {
+	numBuckets := shape.NumBuckets
+	for l := shape.LowestLevel(); l > level; l-- {
+		numBuckets = (numBuckets + shape.MaxGroupingAtEachLevel - 1) / shape.MaxGroupingAtEachLevel
+	}
+	return numBuckets
+}
+
+// ChildBuckets returns the numbers of the children, at level+1, of the bucket with the given
+// number at the given level. Bucket numbers start from 1 at each level.
+func (shape *BucketTreeShape) ChildBuckets(level int, bucketNumber int) []int {
+	first := (bucketNumber-1)*shape.MaxGroupingAtEachLevel + 1
+	last := bucketNumber * shape.MaxGroupingAtEachLevel
+	if numBuckets := shape.NumBucketsAt(level + 1); last > numBuckets {
+		last = numBuckets
+	}
+	var children []int
+	for child := first; child <= last; child++ {
+		children = append(children, child)
+	}
+	return children
+}
+
+// BucketHashProvider can optionally be implemented by a HashableState that organizes the keys
+// in a tree of buckets. Two peers with trees of the same shape can find the buckets in which
+// their states differ by comparing the crypto-hashes of the buckets level by level, from the
+// root down, and then transfer only the key-values of these buckets.
+type BucketHashProvider interface {
+	// GetBucketTreeShape returns the shape of the tree of buckets
+	GetBucketTreeShape() *BucketTreeShape
+	// GetBucketHashes returns the crypto-hashes of the buckets with the given numbers at the
+	// given level, in the same order. The crypto-hash of an empty bucket is nil.
+	GetBucketHashes(snapshot kvstore.Snapshot, level int, bucketNumbers []int) ([][]byte, error)
+	// GetBucketsSnapshotIterator returns an iterator over the key-values of the buckets with
+	// the given numbers at the lowest level
+	GetBucketsSnapshotIterator(snapshot kvstore.Snapshot, bucketNumbers []int) (StateSnapshotIterator, error)
+}
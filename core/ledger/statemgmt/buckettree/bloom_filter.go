@@ -0,0 +1,209 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package buckettree
+
+import (
+	"fmt"
+	"hash/crc32"
+	"hash/fnv"
+	"math"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/db/kvstore"
+)
+
+// A bloom filter over the keys of each lowest-level bucket lets Get return for a key that is not
+// present without reading the DB. The filter of a bucket is rebuilt, from the data nodes that are
+// read anyway for computing the crypto-hash of the bucket, whenever the bucket changes. The filters
+// are stored in the stateCF, after the data nodes, so that these are dropped along with the state
+// (e.g., by state transfer); these are not part of the state hash. A bucket without a filter (e.g.,
+// one not changed since the filters were enabled) is looked up in the DB. When the filters are
+// disabled, the filters of the changed buckets are removed so that these never go stale.
+//
+// key:   bloomFilterKeyPrefix encodeBucketNumber(bucketNumber)
+// value: varint(numHashes) rawBytes(bits) fixed32(crc32(bits))
+const bloomFilterKeyPrefix = byte(0xff)
+
+const minBloomFilterBits = 64
+
+type bloomFilter struct {
+	numHashes uint64
+	bits      []byte
+}
+
+func newBloomFilter(numKeys int, bitsPerKey int) *bloomFilter {
+	numBits := numKeys * bitsPerKey
+	if numBits < minBloomFilterBits {
+		numBits = minBloomFilterBits
+	}
+	// the number of hash functions that minimizes the false positive rate is bitsPerKey * ln(2)
+	numHashes := uint64(math.Floor(float64(bitsPerKey)*math.Ln2 + 0.5))
+	if numHashes < 1 {
+		numHashes = 1
+	} else if numHashes > 30 {
+		numHashes = 30
+	}
+	return &bloomFilter{numHashes, make([]byte, (numBits+7)/8)}
+}
+
+// bitIndexes uses double hashing, i.e., the i-th index is h1 + i*h2
+func (filter *bloomFilter) bitIndexes(key []byte) []uint64 {
+	hash := fnv.New64a()
+	hash.Write(key)
+	sum := hash.Sum64()
+	h1, h2 := sum&0xffffffff, (sum>>32)|1
+	numBits := uint64(len(filter.bits) * 8)
+	indexes := make([]uint64, filter.numHashes)
+	for i := range indexes {
+		indexes[i] = (h1 + uint64(i)*h2) % numBits
+	}
+	return indexes
+}
+
+func (filter *bloomFilter) add(key []byte) {
+	for _, index := range filter.bitIndexes(key) {
+		filter.bits[index/8] |= 1 << (index % 8)
+	}
+}
+
+func (filter *bloomFilter) mayContain(key []byte) bool {
+	for _, index := range filter.bitIndexes(key) {
+		if filter.bits[index/8]&(1<<(index%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (filter *bloomFilter) marshal() []byte {
+	buffer := proto.NewBuffer([]byte{})
+	buffer.EncodeVarint(filter.numHashes)
+	buffer.EncodeRawBytes(filter.bits)
+	buffer.EncodeFixed32(uint64(crc32.ChecksumIEEE(filter.bits)))
+	return buffer.Bytes()
+}
+
+func unmarshalBloomFilter(serializedBytes []byte) (*bloomFilter, error) {
+	buffer := proto.NewBuffer(serializedBytes)
+	numHashes, err := buffer.DecodeVarint()
+	if err != nil {
+		return nil, err
+	}
+	bits, err := buffer.DecodeRawBytes(true)
+	if err != nil {
+		return nil, err
+	}
+	if len(bits) == 0 || numHashes == 0 {
+		return nil, fmt.Errorf("Invalid bloom filter")
+	}
+	checksum, err := buffer.DecodeFixed32()
+	if err != nil {
+		return nil, err
+	}
+	if uint32(checksum) != crc32.ChecksumIEEE(bits) {
+		return nil, fmt.Errorf("Checksum mismatch")
+	}
+	return &bloomFilter{numHashes, bits}, nil
+}
+
+// buildBloomFilter builds the filter for a bucket from the data nodes of the bucket after the changes
+func buildBloomFilter(updatedNodes dataNodes, existingNodes dataNodes, bitsPerKey int) *bloomFilter {
+	nodes := mergeDataNodes(updatedNodes, existingNodes)
+	filter := newBloomFilter(len(nodes), bitsPerKey)
+	for _, node := range nodes {
+		filter.add(node.dataKey.compositeKey)
+	}
+	return filter
+}
+
+func encodeBloomFilterKey(bucketNumber int) []byte {
+	return append([]byte{bloomFilterKeyPrefix}, encodeBucketNumber(bucketNumber)...)
+}
+
+// isDataNodeKey returns true for the keys in the stateCF that are neither bucket nodes nor bloom filters
+func isDataNodeKey(key []byte) bool {
+	return len(key) > 0 && key[0] != byte(0) && key[0] != bloomFilterKeyPrefix
+}
+
+// bloomFilterCache keeps the filters of the lowest-level buckets, loaded on demand. A nil filter
+// records that the bucket has no filter in the DB
+type bloomFilterCache struct {
+	store      kvstore.KVStore
+	lock       sync.RWMutex
+	c          map[int]*bloomFilter
+	generation uint64
+	readRepair *readRepair
+}
+
+func newBloomFilterCache(store kvstore.KVStore, readRepair *readRepair) *bloomFilterCache {
+	return &bloomFilterCache{store: store, c: make(map[int]*bloomFilter), generation: store.Generation(), readRepair: readRepair}
+}
+
+// get returns the filter of the bucket, nil if the bucket has no filter
+func (cache *bloomFilterCache) get(bucketNumber int) (*bloomFilter, error) {
+	store := cache.store
+	cache.lock.RLock()
+	filter, ok := cache.c[bucketNumber]
+	valid := cache.generation == store.Generation()
+	cache.lock.RUnlock()
+	if ok && valid {
+		return filter, nil
+	}
+	filterBytes, err := store.Get(kvstore.StateCFName, encodeBloomFilterKey(bucketNumber))
+	if err != nil {
+		return nil, err
+	}
+	if filterBytes != nil {
+		if filter, err = unmarshalBloomFilter(filterBytes); err != nil {
+			// the filter is dropped and the lookups in the bucket go to the DB until it is rebuilt
+			if err := kvstore.Delete(store, kvstore.StateCFName, encodeBloomFilterKey(bucketNumber)); err != nil {
+				return nil, err
+			}
+			cache.readRepair.recordInconsistency(structureBloomFilter, newBucketKeyAtLowestLevel(bucketNumber),
+				fmt.Sprintf("persisted filter is corrupt: %s", err))
+			filter = nil
+		}
+	}
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	cache.checkStateCFWithoutLock(store.Generation())
+	// the filter may have been updated by a commit meanwhile, in which case the one read may be stale
+	if cachedFilter, ok := cache.c[bucketNumber]; ok {
+		return cachedFilter, nil
+	}
+	cache.c[bucketNumber] = filter
+	return filter, nil
+}
+
+// update records the filters persisted for the changed buckets
+func (cache *bloomFilterCache) update(filters map[int]*bloomFilter) {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	cache.checkStateCFWithoutLock(cache.store.Generation())
+	for bucketNumber, filter := range filters {
+		cache.c[bucketNumber] = filter
+	}
+}
+
+// checkStateCFWithoutLock drops the cached filters if the state column family has been cleared
+func (cache *bloomFilterCache) checkStateCFWithoutLock(generation uint64) {
+	if cache.generation != generation {
+		cache.c = make(map[int]*bloomFilter)
+		cache.generation = generation
+	}
+}
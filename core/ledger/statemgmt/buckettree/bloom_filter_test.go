@@ -0,0 +1,112 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package buckettree
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/db"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+	"github.com/hyperledger/fabric/core/ledger/testutil"
+)
+
+func TestBloomFilter(t *testing.T) {
+	filter := newBloomFilter(1000, 10)
+	for i := 0; i < 1000; i++ {
+		filter.add([]byte(fmt.Sprintf("key%d", i)))
+	}
+	filter, err := unmarshalBloomFilter(filter.marshal())
+	testutil.AssertNoError(t, err, "Error while unmarshalling bloom filter")
+	for i := 0; i < 1000; i++ {
+		testutil.AssertEquals(t, filter.mayContain([]byte(fmt.Sprintf("key%d", i))), true)
+	}
+	falsePositives := 0
+	for i := 0; i < 10000; i++ {
+		if filter.mayContain([]byte(fmt.Sprintf("absentKey%d", i))) {
+			falsePositives++
+		}
+	}
+	if falsePositives > 300 {
+		t.Fatalf("Too many false positives [%d] out of 10000 lookups", falsePositives)
+	}
+}
+
+func TestStateImplBloomFilters(t *testing.T) {
+	testDBWrapper.CreateFreshDB(t)
+	configs := map[string]interface{}{ConfigNumBuckets: 5, ConfigMaxGroupingAtEachLevel: 2, ConfigBloomFilterBitsPerKey: 10}
+	stateImplTestWrapper := newStateImplTestWrapperWithCustomConfigMap(t, configs)
+	stateDelta := statemgmt.NewStateDelta()
+	for i := 0; i < 20; i++ {
+		stateDelta.Set("chaincodeID1", fmt.Sprintf("key%d", i), []byte(fmt.Sprintf("value%d", i)), nil)
+	}
+	rootHash := stateImplTestWrapper.prepareWorkingSetAndComputeCryptoHash(stateDelta)
+	stateImplTestWrapper.persistChangesAndResetInMemoryChanges()
+	for bucketNumber := 1; bucketNumber <= 5; bucketNumber++ {
+		filterBytes, _ := db.GetDBHandle().GetFromStateCF(encodeBloomFilterKey(bucketNumber))
+		testutil.AssertNotNil(t, filterBytes)
+	}
+
+	// the filters are neither part of the state hash nor of the iterators
+	testDBWrapper.CreateFreshDB(t)
+	testutil.AssertEquals(t, newStateImplTestWrapperWithCustomConfig(t, 5, 2).prepareWorkingSetAndComputeCryptoHash(stateDelta), rootHash)
+	testDBWrapper.CreateFreshDB(t)
+	stateImplTestWrapper = newStateImplTestWrapperWithCustomConfigMap(t, configs)
+	stateImplTestWrapper.prepareWorkingSetAndComputeCryptoHash(stateDelta)
+	stateImplTestWrapper.persistChangesAndResetInMemoryChanges()
+	snapshot := db.GetDBHandle().KVStore().NewSnapshot()
+	defer snapshot.Release()
+	snapshotItr, _ := stateImplTestWrapper.stateImpl.GetStateSnapshotIterator(snapshot)
+	numKeys := 0
+	for snapshotItr.Next() {
+		numKeys++
+	}
+	snapshotItr.Close()
+	testutil.AssertEquals(t, numKeys, 20)
+
+	for i := 0; i < 20; i++ {
+		testutil.AssertEquals(t, stateImplTestWrapper.get("chaincodeID1", fmt.Sprintf("key%d", i)), []byte(fmt.Sprintf("value%d", i)))
+	}
+	testutil.AssertNil(t, stateImplTestWrapper.get("chaincodeID1", "absentKey"))
+
+	// deleted keys are removed from the filters
+	stateDelta = statemgmt.NewStateDelta()
+	stateDelta.Delete("chaincodeID1", "key1", nil)
+	stateImplTestWrapper.prepareWorkingSetAndComputeCryptoHash(stateDelta)
+	stateImplTestWrapper.persistChangesAndResetInMemoryChanges()
+	key1 := newDataKey("chaincodeID1", "key1")
+	filter, err := stateImplTestWrapper.stateImpl.bloomFilters.get(key1.bucketKey.bucketNumber)
+	testutil.AssertNoError(t, err, "Error while getting bloom filter")
+	testutil.AssertEquals(t, filter.mayContain(key1.compositeKey), false)
+	testutil.AssertNil(t, stateImplTestWrapper.get("chaincodeID1", "key1"))
+
+	// the filters of the buckets changed while the filters are disabled are removed
+	stateImplTestWrapper = newStateImplTestWrapperWithCustomConfig(t, 5, 2)
+	stateDelta = statemgmt.NewStateDelta()
+	stateDelta.Set("chaincodeID1", "key1", []byte("newValue"), nil)
+	stateImplTestWrapper.prepareWorkingSetAndComputeCryptoHash(stateDelta)
+	stateImplTestWrapper.persistChangesAndResetInMemoryChanges()
+	stateImplTestWrapper = newStateImplTestWrapperWithCustomConfigMap(t, configs)
+	testutil.AssertEquals(t, stateImplTestWrapper.get("chaincodeID1", "key1"), []byte("newValue"))
+}
+
+func newStateImplTestWrapperWithCustomConfigMap(t *testing.T, configs map[string]interface{}) *stateImplTestWrapper {
+	stateImpl := NewStateImpl(db.GetDBHandle().KVStore())
+	err := stateImpl.Initialize(configs)
+	testutil.AssertNoError(t, err, "Error while constrcuting stateImpl")
+	return &stateImplTestWrapper{configs, stateImpl, t}
+}
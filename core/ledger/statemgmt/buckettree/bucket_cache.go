@@ -21,9 +21,8 @@ import (
 	"time"
 	"unsafe"
 
-	"github.com/hyperledger/fabric/core/db"
+	"github.com/hyperledger/fabric/core/db/kvstore"
 	"github.com/hyperledger/fabric/core/ledger/perfstat"
-	"github.com/hyperledger/fabric/core/ledger/statemgmt"
 )
 
 var defaultBucketCacheMaxSize = 100 // MBs
@@ -34,6 +33,7 @@ var defaultBucketCacheMaxSize = 100 // MBs
 // be controlled - by keeping seletive buckets in the cache (most likely first few levels of the bucket tree - because,
 // higher the level of the bucket, more are the chances that the bucket would be required for recomputation of hash)
 type bucketCache struct {
+	store     kvstore.KVStore
 	isEnabled bool
 	c         map[bucketKey]*bucketNode
 	lock      sync.RWMutex
@@ -41,36 +41,33 @@ type bucketCache struct {
 	maxSize   uint64
 }
 
-func newBucketCache(maxSizeMBs int) *bucketCache {
+func newBucketCache(store kvstore.KVStore, maxSizeMBs int) *bucketCache {
 	isEnabled := true
 	if maxSizeMBs <= 0 {
 		isEnabled = false
 	} else {
 		logger.Info("Constructing bucket-cache with max bucket cache size = [%d] MBs", maxSizeMBs)
 	}
-	return &bucketCache{c: make(map[bucketKey]*bucketNode), maxSize: uint64(maxSizeMBs * 1024 * 1024), isEnabled: isEnabled}
+	return &bucketCache{store: store, c: make(map[bucketKey]*bucketNode), maxSize: uint64(maxSizeMBs * 1024 * 1024), isEnabled: isEnabled}
 }
 
 func (cache *bucketCache) loadAllBucketNodesFromDB() {
 	if !cache.isEnabled {
 		return
 	}
-	openchainDB := db.GetDBHandle()
-	itr := openchainDB.GetStateCFIterator()
+	itr := cache.store.NewIterator(kvstore.StateCFName)
 	defer itr.Close()
 	itr.Seek([]byte{byte(0)})
 	count := 0
 	cache.lock.Lock()
 	defer cache.lock.Unlock()
 	for ; itr.Valid(); itr.Next() {
-		key := itr.Key().Data()
+		key := itr.Key()
 		if key[0] != byte(0) {
-			itr.Key().Free()
-			itr.Value().Free()
 			break
 		}
-		bKey := decodeBucketKey(statemgmt.Copy(itr.Key().Data()))
-		nodeBytes := statemgmt.Copy(itr.Value().Data())
+		bKey := decodeBucketKey(itr.Key())
+		nodeBytes := itr.Value()
 		bucketNode := unmarshalBucketNode(&bKey, nodeBytes)
 		size := bKey.size() + bucketNode.size()
 		cache.size += size
@@ -79,8 +76,6 @@ func (cache *bucketCache) loadAllBucketNodesFromDB() {
 			break
 		}
 		cache.c[bKey] = bucketNode
-		itr.Key().Free()
-		itr.Value().Free()
 		count++
 	}
 	logger.Info("Loaded buckets data in cache. Total buckets in DB = [%d]. Total cache size:=%d", count, cache.size)
@@ -116,17 +111,30 @@ func (cache *bucketCache) putWithoutLock(key bucketKey, node *bucketNode) {
 func (cache *bucketCache) get(key bucketKey) (*bucketNode, error) {
 	defer perfstat.UpdateTimeStat("timeSpent", time.Now())
 	if !cache.isEnabled {
-		return fetchBucketNodeFromDB(&key)
+		return fetchBucketNodeFromDB(cache.store, &key)
 	}
 	cache.lock.RLock()
 	defer cache.lock.RUnlock()
 	bucketNode := cache.c[key]
 	if bucketNode == nil {
-		return fetchBucketNodeFromDB(&key)
+		return fetchBucketNodeFromDB(cache.store, &key)
 	}
 	return bucketNode, nil
 }
 
+// replace puts the node in place of the cached one, removing the cached one if the node is nil
+func (cache *bucketCache) replace(key bucketKey, node *bucketNode) {
+	if !cache.isEnabled {
+		return
+	}
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	cache.removeWithoutLock(key)
+	if node != nil {
+		cache.putWithoutLock(key, node)
+	}
+}
+
 func (cache *bucketCache) removeWithoutLock(key bucketKey) {
 	if !cache.isEnabled {
 		return
@@ -18,7 +18,7 @@ package buckettree
 
 import (
 	"github.com/golang/protobuf/proto"
-	openchainUtil "github.com/hyperledger/fabric/core/util"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
 )
 
 type bucketHashCalculator struct {
@@ -53,7 +53,7 @@ func (c *bucketHashCalculator) computeCryptoHash() []byte {
 	if c.hashingData == nil {
 		return nil
 	}
-	return openchainUtil.ComputeCryptoHash(c.hashingData)
+	return statemgmt.ComputeCryptoHash(c.hashingData)
 }
 
 func (c *bucketHashCalculator) appendCurrentChaincodeData() {
@@ -0,0 +1,101 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package buckettree
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/hyperledger/fabric/core/db/kvstore"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+)
+
+// GetBucketTreeShape - see interface 'statemgmt.BucketHashProvider' for details
+func (stateImpl *StateImpl) GetBucketTreeShape() *statemgmt.BucketTreeShape {
+	return &statemgmt.BucketTreeShape{
+		NumBuckets:             conf.getNumBucketsAtLowestLevel(),
+		MaxGroupingAtEachLevel: conf.getMaxGroupingAtEachLevel(),
+		BucketHashFunction:     conf.hashFuncName,
+	}
+}
+
+// GetBucketHashes - see interface 'statemgmt.BucketHashProvider' for details.
+// The crypto-hash of a bucket below the root is read from its parent bucket node.
+func (stateImpl *StateImpl) GetBucketHashes(snapshot kvstore.Snapshot, level int, bucketNumbers []int) ([][]byte, error) {
+	if level < 0 || level > conf.getLowestLevel() {
+		return nil, fmt.Errorf("Invalid level [%d]. Level can be between 0 and [%d]", level, conf.getLowestLevel())
+	}
+	parents := make(map[int]*bucketNode)
+	hashes := make([][]byte, len(bucketNumbers))
+	for i, bucketNumber := range bucketNumbers {
+		if bucketNumber < 1 || bucketNumber > conf.getNumBuckets(level) {
+			return nil, fmt.Errorf("Invalid bucket number [%d]. Bucket number at level [%d] can be between 1 and [%d]", bucketNumber, level, conf.getNumBuckets(level))
+		}
+		bucketKey := newBucketKey(level, bucketNumber)
+		if level == 0 {
+			rootBucketNode, err := fetchBucketNodeFromSnapshot(snapshot, bucketKey)
+			if err != nil {
+				return nil, err
+			}
+			if rootBucketNode != nil {
+				hashes[i] = rootBucketNode.computeCryptoHash()
+			}
+			continue
+		}
+		parentKey := bucketKey.getParentKey()
+		parent, ok := parents[parentKey.bucketNumber]
+		if !ok {
+			var err error
+			if parent, err = fetchBucketNodeFromSnapshot(snapshot, parentKey); err != nil {
+				return nil, err
+			}
+			parents[parentKey.bucketNumber] = parent
+		}
+		if parent != nil {
+			hashes[i] = parent.childrenCryptoHash[parentKey.getChildIndex(bucketKey)]
+		}
+	}
+	return hashes, nil
+}
+
+// GetBucketsSnapshotIterator - see interface 'statemgmt.BucketHashProvider' for details
+func (stateImpl *StateImpl) GetBucketsSnapshotIterator(snapshot kvstore.Snapshot, bucketNumbers []int) (statemgmt.StateSnapshotIterator, error) {
+	sortedBucketNumbers := append([]int(nil), bucketNumbers...)
+	sort.Ints(sortedBucketNumbers)
+	var ranges [][2]int
+	for i, bucketNumber := range sortedBucketNumbers {
+		if bucketNumber < 1 || bucketNumber > conf.getNumBucketsAtLowestLevel() {
+			return nil, fmt.Errorf("Invalid bucket number [%d]. Bucket number at the lowest level can be between 1 and [%d]", bucketNumber, conf.getNumBucketsAtLowestLevel())
+		}
+		if i > 0 && bucketNumber == sortedBucketNumbers[i-1] {
+			continue
+		}
+		ranges = append(ranges, [2]int{bucketNumber, bucketNumber})
+	}
+	return newSubtreeSnapshotIterator(snapshot, ranges), nil
+}
+
+func fetchBucketNodeFromSnapshot(snapshot kvstore.Snapshot, bucketKey *bucketKey) (*bucketNode, error) {
+	nodeBytes, err := snapshot.Get(kvstore.StateCFName, bucketKey.getEncodedBytes())
+	if err != nil {
+		return nil, err
+	}
+	if nodeBytes == nil {
+		return nil, nil
+	}
+	return unmarshalBucketNode(bucketKey, nodeBytes), nil
+}
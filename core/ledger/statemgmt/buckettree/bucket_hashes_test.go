@@ -0,0 +1,90 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package buckettree
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric/core/db"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+	"github.com/hyperledger/fabric/core/ledger/testutil"
+)
+
+func TestBucketTreeShape(t *testing.T) {
+	// number of buckets at each level 26,9,3,1
+	_, stateImplTestWrapper, _ := createFreshDBAndInitTestStateImplWithCustomHasher(t, 26, 3)
+	shape := stateImplTestWrapper.stateImpl.GetBucketTreeShape()
+	testutil.AssertEquals(t, shape.LowestLevel(), 3)
+	testutil.AssertEquals(t, shape.NumBucketsAt(0), 1)
+	testutil.AssertEquals(t, shape.NumBucketsAt(1), 3)
+	testutil.AssertEquals(t, shape.NumBucketsAt(2), 9)
+	testutil.AssertEquals(t, shape.NumBucketsAt(3), 26)
+	testutil.AssertEquals(t, shape.ChildBuckets(0, 1), []int{1, 2, 3})
+	testutil.AssertEquals(t, shape.ChildBuckets(2, 8), []int{22, 23, 24})
+	testutil.AssertEquals(t, shape.ChildBuckets(2, 9), []int{25, 26})
+}
+
+func TestGetBucketHashes(t *testing.T) {
+	testHasher, stateImplTestWrapper, stateDelta := createFreshDBAndInitTestStateImplWithCustomHasher(t, 26, 3)
+	testHasher.populate("chaincodeID1", "key1", 0)
+	testHasher.populate("chaincodeID2", "key2", 1)
+	testHasher.populate("chaincodeID3", "key3", 25)
+	stateDelta.Set("chaincodeID1", "key1", []byte("value1"), nil)
+	stateDelta.Set("chaincodeID2", "key2", []byte("value2"), nil)
+	stateDelta.Set("chaincodeID3", "key3", []byte("value3"), nil)
+	stateImplTestWrapper.prepareWorkingSet(stateDelta)
+	rootHash := stateImplTestWrapper.computeCryptoHash()
+	stateImplTestWrapper.persistChangesAndResetInMemoryChanges()
+	dbSnapshot := db.GetDBHandle().KVStore().NewSnapshot()
+	defer dbSnapshot.Release()
+	stateImpl := stateImplTestWrapper.stateImpl
+
+	hashes, err := stateImpl.GetBucketHashes(dbSnapshot, 0, []int{1})
+	testutil.AssertNoError(t, err, "Error while getting the root hash")
+	testutil.AssertEquals(t, hashes[0], rootHash)
+
+	// The first bucket at level 1 holds the lowest buckets 1 to 9, the third one the buckets 19 to 26
+	hashes, err = stateImpl.GetBucketHashes(dbSnapshot, 1, []int{1, 2, 3})
+	testutil.AssertNoError(t, err, "Error while getting bucket hashes")
+	testutil.AssertNotNil(t, hashes[0])
+	testutil.AssertNil(t, hashes[1])
+	testutil.AssertNotNil(t, hashes[2])
+
+	hashes, err = stateImpl.GetBucketHashes(dbSnapshot, 3, []int{1, 2, 3, 26})
+	testutil.AssertNoError(t, err, "Error while getting bucket hashes")
+	testutil.AssertNotNil(t, hashes[0])
+	testutil.AssertNotNil(t, hashes[1])
+	testutil.AssertNil(t, hashes[2])
+	testutil.AssertNotNil(t, hashes[3])
+	testutil.AssertNotEquals(t, hashes[0], hashes[1])
+
+	_, err = stateImpl.GetBucketHashes(dbSnapshot, 4, []int{1})
+	testutil.AssertError(t, err, "Expected an error for an invalid level")
+	_, err = stateImpl.GetBucketHashes(dbSnapshot, 1, []int{4})
+	testutil.AssertError(t, err, "Expected an error for an invalid bucket number")
+
+	itr, err := stateImpl.GetBucketsSnapshotIterator(dbSnapshot, []int{26, 1, 1})
+	testutil.AssertNoError(t, err, "Error while getting buckets snapshot iterator")
+	defer itr.Close()
+	var keys []string
+	for itr.Next() {
+		key, _ := itr.GetRawKeyValue()
+		chaincodeID, k := statemgmt.DecodeCompositeKey(key)
+		keys = append(keys, chaincodeID+"/"+k)
+	}
+	testutil.AssertEquals(t, keys, []string{"chaincodeID1/key1", "chaincodeID3/key3"})
+}
@@ -20,7 +20,7 @@ import (
 	"fmt"
 
 	"github.com/golang/protobuf/proto"
-	openchainUtil "github.com/hyperledger/fabric/core/util"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
 )
 
 type bucketNode struct {
@@ -96,7 +96,7 @@ func (bucketNode *bucketNode) computeCryptoHash() []byte {
 		return cryptoHashContent
 	}
 	logger.Debug("Computing crypto-hash for bucket [%s] by merging [%d] children", bucketNode.bucketKey, numChildren)
-	return openchainUtil.ComputeCryptoHash(cryptoHashContent)
+	return statemgmt.ComputeCryptoHash(cryptoHashContent)
 }
 
 func (bucketNode *bucketNode) String() string {
@@ -18,7 +18,9 @@ package buckettree
 
 import (
 	"fmt"
+	"hash/crc32"
 	"hash/fnv"
+	"sort"
 )
 
 // ConfigNumBuckets - config name 'numBuckets' as it appears in yaml file
@@ -27,6 +29,21 @@ const ConfigNumBuckets = "numBuckets"
 // ConfigMaxGroupingAtEachLevel - config name 'maxGroupingAtEachLevel' as it appears in yaml file
 const ConfigMaxGroupingAtEachLevel = "maxGroupingAtEachLevel"
 
+// ConfigHashWorkers - config name 'hashWorkers' as it appears in yaml file
+const ConfigHashWorkers = "hashWorkers"
+
+// ConfigDataNodeCacheSize - config name 'dataNodeCacheSize' as it appears in yaml file
+const ConfigDataNodeCacheSize = "dataNodeCacheSize"
+
+// ConfigBloomFilterBitsPerKey - config name 'bloomFilterBitsPerKey' as it appears in yaml file
+const ConfigBloomFilterBitsPerKey = "bloomFilterBitsPerKey"
+
+// ConfigReadRepairCheckInterval - config name 'readRepairCheckInterval' as it appears in yaml file
+const ConfigReadRepairCheckInterval = "readRepairCheckInterval"
+
+// ConfigBucketHashFunction - config name 'bucketHashFunction' as it appears in yaml file
+const ConfigBucketHashFunction = "bucketHashFunction"
+
 // ConfigHashFunction - config name 'hashFunction'. This is not exposed in yaml file. This configuration is used for testing with custom hash-function
 const ConfigHashFunction = "hashFunction"
 
@@ -37,6 +54,18 @@ const DefaultNumBuckets = 10009
 // Grouping is started from left. The last group may have less buckets
 const DefaultMaxGroupingAtEachLevel = 10
 
+// DefaultBucketHashFunction - name of the function used for assigning the keys to the buckets
+const DefaultBucketHashFunction = "fnv32a"
+
+// customHashFunctionName is the name recorded for a hash-function passed with config 'hashFunction'
+const customHashFunctionName = "custom"
+
+var bucketHashFunctions = map[string]hashFunc{
+	"fnv32a": fnvHash,
+	"fnv32":  fnv32Hash,
+	"crc32":  crc32.ChecksumIEEE,
+}
+
 var conf *config
 
 type config struct {
@@ -44,9 +73,10 @@ type config struct {
 	lowestLevel            int
 	levelToNumBucketsMap   map[int]int
 	hashFunc               hashFunc
+	hashFuncName           string
 }
 
-func initConfig(configs map[string]interface{}) {
+func initConfig(configs map[string]interface{}) error {
 	logger.Info("configs passed during initialization = %#v", configs)
 
 	numBuckets, ok := configs[ConfigNumBuckets].(int)
@@ -59,16 +89,45 @@ func initConfig(configs map[string]interface{}) {
 		maxGroupingAtEachLevel = DefaultMaxGroupingAtEachLevel
 	}
 
+	if numBuckets <= 0 {
+		return fmt.Errorf("Invalid bucket tree configuration. '%s' must be greater than zero. Current value is %d", ConfigNumBuckets, numBuckets)
+	}
+	if maxGroupingAtEachLevel < 2 {
+		return fmt.Errorf("Invalid bucket tree configuration. '%s' must be greater than one. Current value is %d",
+			ConfigMaxGroupingAtEachLevel, maxGroupingAtEachLevel)
+	}
+
+	hashFunctionName := DefaultBucketHashFunction
 	hashFunction, ok := configs[ConfigHashFunction].(hashFunc)
-	if !ok {
-		hashFunction = fnvHash
+	if ok {
+		hashFunctionName = customHashFunctionName
+	} else {
+		if name, ok := configs[ConfigBucketHashFunction].(string); ok && name != "" {
+			hashFunctionName = name
+		}
+		hashFunction, ok = bucketHashFunctions[hashFunctionName]
+		if !ok {
+			return fmt.Errorf("Invalid bucket tree configuration. Unknown '%s' [%s]. Supported functions are %s",
+				ConfigBucketHashFunction, hashFunctionName, supportedBucketHashFunctions())
+		}
 	}
 	conf = newConfig(numBuckets, maxGroupingAtEachLevel, hashFunction)
+	conf.hashFuncName = hashFunctionName
 	logger.Info("Initializing bucket tree state implemetation with configurations %+v", conf)
+	return nil
+}
+
+func supportedBucketHashFunctions() []string {
+	var names []string
+	for name := range bucketHashFunctions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
 }
 
 func newConfig(numBuckets int, maxGroupingAtEachLevel int, hashFunc hashFunc) *config {
-	conf := &config{maxGroupingAtEachLevel, -1, make(map[int]int), hashFunc}
+	conf := &config{maxGroupingAtEachLevel, -1, make(map[int]int), hashFunc, ""}
 	currentLevel := 0
 	numBucketAtCurrentLevel := numBuckets
 	levelInfoMap := make(map[int]int)
@@ -130,3 +189,9 @@ func fnvHash(data []byte) uint32 {
 	fnvHash.Write(data)
 	return fnvHash.Sum32()
 }
+
+func fnv32Hash(data []byte) uint32 {
+	fnvHash := fnv.New32()
+	fnvHash.Write(data)
+	return fnvHash.Sum32()
+}
@@ -17,8 +17,10 @@ limitations under the License.
 package buckettree
 
 import (
+	"strings"
 	"testing"
 
+	"github.com/hyperledger/fabric/core/db"
 	"github.com/hyperledger/fabric/core/ledger/testutil"
 	"github.com/spf13/viper"
 )
@@ -27,7 +29,7 @@ func TestConfigInit(t *testing.T) {
 	configs := viper.GetStringMap("ledger.state.dataStructure.configs")
 	t.Logf("Configs loaded from yaml = %#v", configs)
 	testDBWrapper.CreateFreshDB(t)
-	stateImpl := NewStateImpl()
+	stateImpl := NewStateImpl(db.GetDBHandle().KVStore())
 	stateImpl.Initialize(configs)
 	testutil.AssertEquals(t, conf.getNumBucketsAtLowestLevel(), configs[ConfigNumBuckets])
 	testutil.AssertEquals(t, conf.getMaxGroupingAtEachLevel(), configs[ConfigMaxGroupingAtEachLevel])
@@ -59,3 +61,29 @@ func TestConfig(t *testing.T) {
 	testutil.AssertEquals(t, testConf.computeParentBucketNumber(24), 8)
 	testutil.AssertEquals(t, testConf.computeParentBucketNumber(25), 9)
 }
+
+func TestConfigInvalid(t *testing.T) {
+	testutil.AssertError(t, initConfig(map[string]interface{}{ConfigNumBuckets: 0}), "Expected error for zero buckets")
+	testutil.AssertError(t, initConfig(map[string]interface{}{ConfigMaxGroupingAtEachLevel: 1}), "Expected error for grouping of one")
+	testutil.AssertError(t, initConfig(map[string]interface{}{ConfigBucketHashFunction: "md5"}), "Expected error for unknown hash function")
+	testutil.AssertNoError(t, initConfig(map[string]interface{}{ConfigBucketHashFunction: "crc32"}), "Error for a supported hash function")
+	testutil.AssertEquals(t, conf.hashFuncName, "crc32")
+}
+
+func TestConfigMismatchWithPersistedTree(t *testing.T) {
+	testDBWrapper.CreateFreshDB(t)
+	configs := map[string]interface{}{ConfigNumBuckets: 100, ConfigMaxGroupingAtEachLevel: 5}
+	testutil.AssertNoError(t, NewStateImpl(db.GetDBHandle().KVStore()).Initialize(configs), "Error while initializing stateImpl")
+	// the persisted metadata matches the configurations
+	testutil.AssertNoError(t, NewStateImpl(db.GetDBHandle().KVStore()).Initialize(configs), "Error while re-initializing stateImpl")
+
+	for _, changedConfigs := range []map[string]interface{}{
+		{ConfigNumBuckets: 101, ConfigMaxGroupingAtEachLevel: 5},
+		{ConfigNumBuckets: 100, ConfigMaxGroupingAtEachLevel: 6},
+		{ConfigNumBuckets: 100, ConfigMaxGroupingAtEachLevel: 5, ConfigBucketHashFunction: "crc32"},
+	} {
+		err := NewStateImpl(db.GetDBHandle().KVStore()).Initialize(changedConfigs)
+		testutil.AssertError(t, err, "Expected error for configurations that do not match the persisted tree")
+		testutil.AssertEquals(t, strings.Contains(err.Error(), "cannot be changed"), true)
+	}
+}
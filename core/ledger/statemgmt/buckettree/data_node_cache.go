@@ -0,0 +1,224 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package buckettree
+
+import (
+	"bytes"
+	"container/list"
+	"sort"
+	"sync"
+	"unsafe"
+
+	"github.com/hyperledger/fabric/core/db/kvstore"
+)
+
+var defaultDataNodeCacheMaxSize = 0 // MBs
+
+// dataNodeCache keeps the data nodes of the recently used lowest-level buckets so that
+// computing the crypto-hash of a bucket that is updated in consecutive blocks does not
+// iterate the DB every time. Unlike the bucket nodes, the data nodes hold the values and
+// cannot be expected to fit in memory. Hence, the cache is bounded by the size (in bytes)
+// of the data nodes held and the least recently used buckets are evicted. The cache is
+// updated with the changes persisted in the DB (see ClearWorkingSet) and dropped when the
+// state column family is cleared (i.e., when the state is replaced by state transfer).
+type dataNodeCache struct {
+	store      kvstore.KVStore
+	isEnabled  bool
+	lock       sync.Mutex
+	c          map[bucketKey]*list.Element
+	lru        *list.List
+	size       uint64
+	maxSize    uint64
+	generation uint64
+}
+
+type dataNodeCacheEntry struct {
+	key   bucketKey
+	nodes dataNodes
+	size  uint64
+}
+
+func newDataNodeCache(store kvstore.KVStore, maxSizeMBs int) *dataNodeCache {
+	isEnabled := true
+	if maxSizeMBs <= 0 {
+		isEnabled = false
+	} else {
+		logger.Info("Constructing data-node-cache with max size = [%d] MBs", maxSizeMBs)
+	}
+	return &dataNodeCache{
+		store:      store,
+		isEnabled:  isEnabled,
+		c:          make(map[bucketKey]*list.Element),
+		lru:        list.New(),
+		maxSize:    uint64(maxSizeMBs * 1024 * 1024),
+		generation: store.Generation(),
+	}
+}
+
+// get returns the data nodes of the lowest-level bucket, fetching them from DB if not cached
+func (cache *dataNodeCache) get(key *bucketKey) (dataNodes, error) {
+	if !cache.isEnabled {
+		return fetchDataNodesFromDBFor(cache.store, key)
+	}
+	cache.lock.Lock()
+	cache.checkStateCFWithoutLock()
+	if element, ok := cache.c[*key]; ok {
+		cache.lru.MoveToFront(element)
+		cache.lock.Unlock()
+		return element.Value.(*dataNodeCacheEntry).nodes, nil
+	}
+	generation := cache.generation
+	cache.lock.Unlock()
+
+	nodes, err := fetchDataNodesFromDBFor(cache.store, key)
+	if err != nil {
+		return nil, err
+	}
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	// another goroutine may have cached the bucket (or the state may have been replaced) meanwhile
+	if _, ok := cache.c[*key]; !ok && generation == cache.generation {
+		cache.putWithoutLock(*key, nodes)
+	}
+	return nodes, nil
+}
+
+// getDataNode returns the data node for the key and true if the bucket of the key is cached
+func (cache *dataNodeCache) getDataNode(key *dataKey) (*dataNode, bool) {
+	if !cache.isEnabled {
+		return nil, false
+	}
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	cache.checkStateCFWithoutLock()
+	element, ok := cache.c[*key.bucketKey]
+	if !ok {
+		return nil, false
+	}
+	nodes := element.Value.(*dataNodeCacheEntry).nodes
+	i := sort.Search(len(nodes), func(i int) bool {
+		return bytes.Compare(nodes[i].dataKey.compositeKey, key.compositeKey) >= 0
+	})
+	if i < len(nodes) && bytes.Equal(nodes[i].dataKey.compositeKey, key.compositeKey) {
+		return nodes[i], true
+	}
+	return nil, true
+}
+
+// update applies the persisted changes to the cached buckets
+func (cache *dataNodeCache) update(delta *dataNodesDelta) {
+	if !cache.isEnabled || delta == nil {
+		return
+	}
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	cache.checkStateCFWithoutLock()
+	for _, key := range delta.getAffectedBuckets() {
+		element, ok := cache.c[*key]
+		if !ok {
+			continue
+		}
+		existingNodes := element.Value.(*dataNodeCacheEntry).nodes
+		cache.removeWithoutLock(element)
+		cache.putWithoutLock(*key, mergeDataNodes(delta.getSortedDataNodesFor(key), existingNodes))
+	}
+}
+
+// remove drops the bucket from the cache
+func (cache *dataNodeCache) remove(key *bucketKey) {
+	if !cache.isEnabled {
+		return
+	}
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	if element, ok := cache.c[*key]; ok {
+		cache.removeWithoutLock(element)
+	}
+}
+
+func (cache *dataNodeCache) putWithoutLock(key bucketKey, nodes dataNodes) {
+	entry := &dataNodeCacheEntry{key, nodes, key.size() + nodes.size()}
+	if entry.size > cache.maxSize {
+		return
+	}
+	cache.c[key] = cache.lru.PushFront(entry)
+	cache.size += entry.size
+	for cache.size > cache.maxSize {
+		cache.removeWithoutLock(cache.lru.Back())
+	}
+}
+
+func (cache *dataNodeCache) removeWithoutLock(element *list.Element) {
+	entry := cache.lru.Remove(element).(*dataNodeCacheEntry)
+	delete(cache.c, entry.key)
+	cache.size -= entry.size
+}
+
+// checkStateCFWithoutLock drops the cached buckets if the state column family has been cleared
+func (cache *dataNodeCache) checkStateCFWithoutLock() {
+	generation := cache.store.Generation()
+	if cache.generation == generation {
+		return
+	}
+	logger.Info("State column family cleared. Dropping [%d] buckets from data-node-cache", len(cache.c))
+	cache.c = make(map[bucketKey]*list.Element)
+	cache.lru.Init()
+	cache.size = 0
+	cache.generation = generation
+}
+
+// mergeDataNodes merges the sorted updated nodes into the sorted existing nodes, leaving out the deleted nodes
+func mergeDataNodes(updatedNodes dataNodes, existingNodes dataNodes) dataNodes {
+	merged := make(dataNodes, 0, len(updatedNodes)+len(existingNodes))
+	i, j := 0, 0
+	for i < len(updatedNodes) || j < len(existingNodes) {
+		var nextNode *dataNode
+		switch {
+		case j == len(existingNodes):
+			nextNode = updatedNodes[i]
+			i++
+		case i == len(updatedNodes):
+			nextNode = existingNodes[j]
+			j++
+		default:
+			switch bytes.Compare(updatedNodes[i].dataKey.compositeKey, existingNodes[j].dataKey.compositeKey) {
+			case -1:
+				nextNode = updatedNodes[i]
+				i++
+			case 0:
+				nextNode = updatedNodes[i]
+				i++
+				j++
+			case 1:
+				nextNode = existingNodes[j]
+				j++
+			}
+		}
+		if !nextNode.isDelete() {
+			merged = append(merged, nextNode)
+		}
+	}
+	return merged
+}
+
+func (nodes dataNodes) size() uint64 {
+	size := uint64(0)
+	for _, node := range nodes {
+		size += uint64(unsafe.Sizeof(*node)+unsafe.Sizeof(*node.dataKey)) + uint64(len(node.dataKey.compositeKey)+len(node.value))
+	}
+	return size
+}
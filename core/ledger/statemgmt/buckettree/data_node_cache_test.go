@@ -0,0 +1,102 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package buckettree
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/db"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+	"github.com/hyperledger/fabric/core/ledger/testutil"
+)
+
+func TestDataNodeCache(t *testing.T) {
+	hashesWithoutCache := testGetRootHashesForDataNodeCache(t, 0)
+	hashesWithCache := testGetRootHashesForDataNodeCache(t, 1)
+	testutil.AssertEquals(t, hashesWithCache, hashesWithoutCache)
+}
+
+func testGetRootHashesForDataNodeCache(t *testing.T, cacheSizeMBs int) [][]byte {
+	testDBWrapper.CreateFreshDB(t)
+	stateImplTestWrapper := newStateImplTestWrapperWithCustomConfig(t, 5, 2)
+	stateImpl := stateImplTestWrapper.stateImpl
+	stateImpl.dataNodeCache = newDataNodeCache(db.GetDBHandle().KVStore(), cacheSizeMBs)
+	var rootHashes [][]byte
+	for block := 0; block < 5; block++ {
+		stateDelta := statemgmt.NewStateDelta()
+		for i := 0; i < 10; i++ {
+			key := fmt.Sprintf("key%d", i)
+			if (i+block)%4 == 0 {
+				stateDelta.Delete("chaincodeID1", key, nil)
+			} else {
+				stateDelta.Set("chaincodeID1", key, []byte(fmt.Sprintf("value%d_%d", i, block)), nil)
+			}
+		}
+		rootHashes = append(rootHashes, stateImplTestWrapper.prepareWorkingSetAndComputeCryptoHash(stateDelta))
+		stateImplTestWrapper.persistChangesAndResetInMemoryChanges()
+		for i := 0; i < 10; i++ {
+			key := fmt.Sprintf("key%d", i)
+			expectedValue, err := fetchDataNodeFromDB(db.GetDBHandle().KVStore(), newDataKey("chaincodeID1", key))
+			testutil.AssertNoError(t, err, "Error while fetching data node")
+			value := stateImplTestWrapper.get("chaincodeID1", key)
+			if expectedValue == nil {
+				testutil.AssertNil(t, value)
+			} else {
+				testutil.AssertEquals(t, value, expectedValue.value)
+			}
+		}
+	}
+	if cacheSizeMBs > 0 {
+		testutil.AssertNotEquals(t, len(stateImpl.dataNodeCache.c), 0)
+	}
+	return rootHashes
+}
+
+func TestDataNodeCacheEviction(t *testing.T) {
+	testDBWrapper.CreateFreshDB(t)
+	stateImplTestWrapper := newStateImplTestWrapperWithCustomConfig(t, 100, 10)
+	stateDelta := statemgmt.NewStateDelta()
+	var bucketKeys []*bucketKey
+	for i := 0; i < 20; i++ {
+		key := fmt.Sprintf("key%d", i)
+		stateDelta.Set("chaincodeID1", key, make([]byte, 1000), nil)
+		bucketKeys = append(bucketKeys, newDataKey("chaincodeID1", key).getBucketKey())
+	}
+	stateImplTestWrapper.prepareWorkingSetAndComputeCryptoHash(stateDelta)
+	stateImplTestWrapper.persistChangesAndResetInMemoryChanges()
+
+	cache := newDataNodeCache(db.GetDBHandle().KVStore(), 1)
+	cache.maxSize = 5000
+	for _, bucketKey := range bucketKeys {
+		_, err := cache.get(bucketKey)
+		testutil.AssertNoError(t, err, "Error while getting data nodes")
+		if cache.size > cache.maxSize {
+			t.Fatalf("Cache size [%d] exceeds max size [%d]", cache.size, cache.maxSize)
+		}
+	}
+	// the most recently used bucket is retained
+	_, ok := cache.c[*bucketKeys[len(bucketKeys)-1]]
+	testutil.AssertEquals(t, ok, true)
+	testutil.AssertEquals(t, cache.lru.Len(), len(cache.c))
+
+	// the cache is dropped when the state column family is recreated
+	testutil.AssertNoError(t, db.GetDBHandle().DeleteState(), "Error while deleting the state")
+	_, ok = cache.getDataNode(newDataKey("chaincodeID1", "key1"))
+	testutil.AssertEquals(t, ok, false)
+	testutil.AssertEquals(t, cache.size, uint64(0))
+}
@@ -17,13 +17,11 @@ limitations under the License.
 package buckettree
 
 import (
-	"github.com/hyperledger/fabric/core/db"
-	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+	"github.com/hyperledger/fabric/core/db/kvstore"
 )
 
-func fetchDataNodeFromDB(dataKey *dataKey) (*dataNode, error) {
-	openchainDB := db.GetDBHandle()
-	nodeBytes, err := openchainDB.GetFromStateCF(dataKey.getEncodedBytes())
+func fetchDataNodeFromDB(store kvstore.KVStore, dataKey *dataKey) (*dataNode, error) {
+	nodeBytes, err := store.Get(kvstore.StateCFName, dataKey.getEncodedBytes())
 	if err != nil {
 		return nil, err
 	}
@@ -39,9 +37,8 @@ func fetchDataNodeFromDB(dataKey *dataKey) (*dataNode, error) {
 	return unmarshalDataNode(dataKey, nodeBytes), nil
 }
 
-func fetchBucketNodeFromDB(bucketKey *bucketKey) (*bucketNode, error) {
-	openchainDB := db.GetDBHandle()
-	nodeBytes, err := openchainDB.GetFromStateCF(bucketKey.getEncodedBytes())
+func fetchBucketNodeFromDB(store kvstore.KVStore, bucketKey *bucketKey) (*bucketNode, error) {
+	nodeBytes, err := store.Get(kvstore.StateCFName, bucketKey.getEncodedBytes())
 	if err != nil {
 		return nil, err
 	}
@@ -53,10 +50,9 @@ func fetchBucketNodeFromDB(bucketKey *bucketKey) (*bucketNode, error) {
 
 type rawKey []byte
 
-func fetchDataNodesFromDBFor(bucketKey *bucketKey) (dataNodes, error) {
+func fetchDataNodesFromDBFor(store kvstore.KVStore, bucketKey *bucketKey) (dataNodes, error) {
 	logger.Debug("Fetching from DB data nodes for bucket [%s]", bucketKey)
-	openchainDB := db.GetDBHandle()
-	itr := openchainDB.GetStateCFIterator()
+	itr := store.NewIterator(kvstore.StateCFName)
 	defer itr.Close()
 	minimumDataKeyBytes := minimumPossibleDataKeyBytesFor(bucketKey)
 
@@ -64,12 +60,10 @@ func fetchDataNodesFromDBFor(bucketKey *bucketKey) (dataNodes, error) {
 
 	itr.Seek(minimumDataKeyBytes)
 
-	for ; itr.Valid(); itr.Next() {
+	for ; itr.Valid() && isDataNodeKey(itr.Key()); itr.Next() {
 
-		// making a copy of key-value bytes because, underlying key bytes are reused by itr.
-		// no need to free slices as iterator frees memory when closed.
-		keyBytes := statemgmt.Copy(itr.Key().Data())
-		valueBytes := statemgmt.Copy(itr.Value().Data())
+		keyBytes := itr.Key()
+		valueBytes := itr.Value()
 
 		dataKey := newDataKeyFromEncodedBytes(keyBytes)
 		logger.Debug("Retrieved data key [%s] from DB for bucket [%s]", dataKey, bucketKey)
@@ -0,0 +1,101 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package buckettree
+
+import (
+	"sync"
+)
+
+// computeBucketsCryptoHash computes the crypto-hash of the given lowest-level buckets, the
+// hash at index i being the one of bucketKeys[i]. Fetching the existing data nodes from
+// the DB and hashing them dominate the cost of ComputeCryptoHash when a block touches many
+// buckets, so the buckets are distributed over 'hashWorkers' goroutines. The data nodes
+// delta is only read here; the bucket tree delta is updated by the caller afterwards.
+// The bloom filters of the buckets are rebuilt along, if enabled.
+func (stateImpl *StateImpl) computeBucketsCryptoHash(bucketKeys []*bucketKey) ([][]byte, []*bloomFilter, error) {
+	cryptoHashes := make([][]byte, len(bucketKeys))
+	bloomFilters := make([]*bloomFilter, len(bucketKeys))
+	numWorkers := stateImpl.numHashWorkers
+	if numWorkers > len(bucketKeys) {
+		numWorkers = len(bucketKeys)
+	}
+	if numWorkers <= 1 {
+		for i, bucketKey := range bucketKeys {
+			cryptoHash, bloomFilter, err := stateImpl.computeBucketCryptoHash(bucketKey)
+			if err != nil {
+				return nil, nil, err
+			}
+			cryptoHashes[i] = cryptoHash
+			bloomFilters[i] = bloomFilter
+		}
+		return cryptoHashes, bloomFilters, nil
+	}
+
+	logger.Debug("Computing crypto-hash for [%d] buckets using [%d] workers", len(bucketKeys), numWorkers)
+	indexes := make(chan int, len(bucketKeys))
+	for i := range bucketKeys {
+		indexes <- i
+	}
+	close(indexes)
+
+	var wg sync.WaitGroup
+	var errLock sync.Mutex
+	var firstErr error
+	for w := 0; w < numWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				cryptoHash, bloomFilter, err := stateImpl.computeBucketCryptoHash(bucketKeys[i])
+				if err != nil {
+					errLock.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					errLock.Unlock()
+					return
+				}
+				cryptoHashes[i] = cryptoHash
+				bloomFilters[i] = bloomFilter
+			}
+		}()
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, nil, firstErr
+	}
+	return cryptoHashes, bloomFilters, nil
+}
+
+func (stateImpl *StateImpl) computeBucketCryptoHash(bucketKey *bucketKey) ([]byte, *bloomFilter, error) {
+	updatedDataNodes := stateImpl.dataNodesDelta.getSortedDataNodesFor(bucketKey)
+	generation, check := stateImpl.readRepair.startCheck()
+	existingDataNodes, err := stateImpl.dataNodeCache.get(bucketKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	if check && stateImpl.dataNodeCache.isEnabled {
+		if existingDataNodes, err = stateImpl.verifyCachedDataNodes(bucketKey, existingDataNodes, generation); err != nil {
+			return nil, nil, err
+		}
+	}
+	var bloomFilter *bloomFilter
+	if stateImpl.bloomFilterBitsPerKey > 0 {
+		bloomFilter = buildBloomFilter(updatedDataNodes, existingDataNodes, stateImpl.bloomFilterBitsPerKey)
+	}
+	return computeDataNodesCryptoHash(bucketKey, updatedDataNodes, existingDataNodes), bloomFilter, nil
+}
@@ -23,9 +23,9 @@ import (
 
 	"github.com/golang/protobuf/proto"
 	"github.com/hyperledger/fabric/core/db"
+	"github.com/hyperledger/fabric/core/db/kvstore"
 	"github.com/hyperledger/fabric/core/ledger/statemgmt"
 	"github.com/hyperledger/fabric/core/ledger/testutil"
-	"github.com/tecbot/gorocksdb"
 )
 
 var testDBWrapper = db.NewTestDBWrapper()
@@ -70,7 +70,7 @@ type stateImplTestWrapper struct {
 
 func newStateImplTestWrapper(t testing.TB) *stateImplTestWrapper {
 	var configMap map[string]interface{}
-	stateImpl := NewStateImpl()
+	stateImpl := NewStateImpl(db.GetDBHandle().KVStore())
 	err := stateImpl.Initialize(configMap)
 	testutil.AssertNoError(t, err, "Error while constrcuting stateImpl")
 	return &stateImplTestWrapper{configMap, stateImpl, t}
@@ -78,7 +78,7 @@ func newStateImplTestWrapper(t testing.TB) *stateImplTestWrapper {
 
 func newStateImplTestWrapperWithCustomConfig(t testing.TB, numBuckets int, maxGroupingAtEachLevel int) *stateImplTestWrapper {
 	configMap := map[string]interface{}{ConfigNumBuckets: numBuckets, ConfigMaxGroupingAtEachLevel: maxGroupingAtEachLevel}
-	stateImpl := NewStateImpl()
+	stateImpl := NewStateImpl(db.GetDBHandle().KVStore())
 	err := stateImpl.Initialize(configMap)
 	testutil.AssertNoError(t, err, "Error while constrcuting stateImpl")
 	return &stateImplTestWrapper{configMap, stateImpl, t}
@@ -93,7 +93,7 @@ func createFreshDBAndInitTestStateImplWithCustomHasher(t testing.TB, numBuckets
 	}
 
 	testDBWrapper.CreateFreshDB(t)
-	stateImpl := NewStateImpl()
+	stateImpl := NewStateImpl(db.GetDBHandle().KVStore())
 	stateImpl.Initialize(configMap)
 	stateImplTestWrapper := &stateImplTestWrapper{configMap, stateImpl, t}
 	stateDelta := statemgmt.NewStateDelta()
@@ -101,7 +101,7 @@ func createFreshDBAndInitTestStateImplWithCustomHasher(t testing.TB, numBuckets
 }
 
 func (testWrapper *stateImplTestWrapper) constructNewStateImpl() {
-	stateImpl := NewStateImpl()
+	stateImpl := NewStateImpl(db.GetDBHandle().KVStore())
 	err := stateImpl.Initialize(testWrapper.configMap)
 	testutil.AssertNoError(testWrapper.t, err, "Error while constructing new state tree")
 	testWrapper.stateImpl = stateImpl
@@ -130,13 +130,13 @@ func (testWrapper *stateImplTestWrapper) prepareWorkingSetAndComputeCryptoHash(s
 	return testWrapper.computeCryptoHash()
 }
 
-func (testWrapper *stateImplTestWrapper) addChangesForPersistence(writeBatch *gorocksdb.WriteBatch) {
+func (testWrapper *stateImplTestWrapper) addChangesForPersistence(writeBatch kvstore.WriteBatch) {
 	err := testWrapper.stateImpl.AddChangesForPersistence(writeBatch)
 	testutil.AssertNoError(testWrapper.t, err, "Error while adding changes to db write-batch")
 }
 
 func (testWrapper *stateImplTestWrapper) persistChangesAndResetInMemoryChanges() {
-	writeBatch := gorocksdb.NewWriteBatch()
+	writeBatch := db.GetDBHandle().KVStore().NewWriteBatch()
 	defer writeBatch.Destroy()
 	testWrapper.addChangesForPersistence(writeBatch)
 	testDBWrapper.WriteToDB(testWrapper.t, writeBatch)
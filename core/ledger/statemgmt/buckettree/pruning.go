@@ -0,0 +1,165 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package buckettree
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/hyperledger/fabric/core/db/kvstore"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+)
+
+// PruneOrphanedNodes - see interface 'statemgmt.Pruner' for details
+//
+// A bucket node is reachable if it lies within the configured tree and its parent is reachable
+// and holds a crypto-hash for it. A data node is reachable if its key hashes to the bucket it is
+// stored in and that bucket is reachable. The filter of a bucket that is not reachable is removed
+// as well. The candidates are collected from a snapshot and removed in a single write, which is
+// abandoned if a block has been committed in the meantime. The write holds the persist lock, so
+// that a block cannot be committed between the check and the write: the commit of the next block
+// waits for the pruning to complete.
+func (stateImpl *StateImpl) PruneOrphanedNodes() (*statemgmt.PruneStats, error) {
+	generation := atomic.LoadUint64(&stateImpl.readRepair.persistGeneration)
+	if generation%2 == 1 {
+		return nil, fmt.Errorf("Cannot prune the state while a block is being committed")
+	}
+	snapshot := stateImpl.store.NewSnapshot()
+	defer snapshot.Release()
+
+	bucketNodes := make(map[bucketKey][]byte)
+	var orphanedKeys [][]byte
+	stats := &statemgmt.PruneStats{}
+	addOrphan := func(key []byte, value []byte, counter *uint64) {
+		orphanedKeys = append(orphanedKeys, key)
+		*counter++
+		stats.BytesReclaimed += uint64(len(key) + len(value))
+	}
+
+	itr := snapshot.NewIterator(kvstore.StateCFName)
+	defer itr.Close()
+	for itr.Seek([]byte{byte(0)}); itr.Valid() && itr.Key()[0] == byte(0); itr.Next() {
+		key := itr.Key()
+		bKey := decodeBucketKey(key)
+		if bKey.level >= conf.getLowestLevel() || bKey.bucketNumber < 1 || bKey.bucketNumber > conf.getNumBuckets(bKey.level) {
+			addOrphan(key, itr.Value(), &stats.IntermediateNodesRemoved)
+			continue
+		}
+		bucketNodes[bKey] = itr.Value()
+	}
+	if err := itr.Err(); err != nil {
+		return nil, err
+	}
+
+	// the bucket nodes are visited level by level so that the parent of a node is settled first
+	reachable := make(map[bucketKey]bool)
+	for level := 0; level < conf.getLowestLevel(); level++ {
+		for bKey, value := range bucketNodes {
+			if bKey.level != level {
+				continue
+			}
+			if stateImpl.isReferencedByParent(&bKey, bucketNodes, reachable) {
+				reachable[bKey] = true
+			} else {
+				addOrphan(bKey.getEncodedBytes(), value, &stats.IntermediateNodesRemoved)
+			}
+		}
+	}
+
+	// lowest-level buckets from which a data node or the filter is removed
+	prunedBuckets := make(map[int]bool)
+	for itr.Seek([]byte{0x01}); itr.Valid() && isDataNodeKey(itr.Key()); itr.Next() {
+		key := itr.Key()
+		bucketNumber, numBytesRead := decodeBucketNumber(key)
+		if bucketNumber < 1 || bucketNumber > conf.getNumBucketsAtLowestLevel() ||
+			int(conf.computeBucketHash(key[numBytesRead:]))%conf.getNumBucketsAtLowestLevel()+1 != bucketNumber ||
+			!stateImpl.isReferencedByParent(&bucketKey{conf.getLowestLevel(), bucketNumber}, bucketNodes, reachable) {
+			addOrphan(key, itr.Value(), &stats.DataNodesRemoved)
+			prunedBuckets[bucketNumber] = true
+		}
+	}
+	if err := itr.Err(); err != nil {
+		return nil, err
+	}
+
+	for itr.Seek([]byte{bloomFilterKeyPrefix}); itr.Valid(); itr.Next() {
+		key := itr.Key()
+		bucketNumber, _ := decodeBucketNumber(key[1:])
+		if bucketNumber < 1 || bucketNumber > conf.getNumBucketsAtLowestLevel() ||
+			!stateImpl.isReferencedByParent(&bucketKey{conf.getLowestLevel(), bucketNumber}, bucketNodes, reachable) {
+			addOrphan(key, itr.Value(), &stats.OtherNodesRemoved)
+			prunedBuckets[bucketNumber] = true
+		}
+	}
+	if err := itr.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(orphanedKeys) == 0 {
+		logger.Info("No orphaned state nodes found")
+		return stats, nil
+	}
+	writeBatch := stateImpl.store.NewWriteBatch()
+	defer writeBatch.Destroy()
+	for _, key := range orphanedKeys {
+		writeBatch.Delete(kvstore.StateCFName, key)
+	}
+	stateImpl.readRepair.persistLock.Lock()
+	defer stateImpl.readRepair.persistLock.Unlock()
+	if !stateImpl.readRepair.isValid(generation) {
+		return nil, fmt.Errorf("A block has been committed while pruning the state, no node has been removed")
+	}
+	if err := stateImpl.store.Write(writeBatch, false); err != nil {
+		return nil, err
+	}
+	stateImpl.evictPrunedNodes(orphanedKeys, prunedBuckets)
+	logger.Info("Pruned the state: [%d] data nodes, [%d] bucket nodes and [%d] filters removed, [%d] bytes reclaimed",
+		stats.DataNodesRemoved, stats.IntermediateNodesRemoved, stats.OtherNodesRemoved, stats.BytesReclaimed)
+	return stats, nil
+}
+
+// isReferencedByParent returns true if the parent of the bucket is reachable and holds a
+// crypto-hash for the bucket. The root bucket has no parent and is always reachable.
+func (stateImpl *StateImpl) isReferencedByParent(bKey *bucketKey, bucketNodes map[bucketKey][]byte, reachable map[bucketKey]bool) bool {
+	if bKey.level == 0 {
+		return true
+	}
+	parentKey := bucketKey{bKey.level - 1, conf.computeParentBucketNumber(bKey.bucketNumber)}
+	if !reachable[parentKey] {
+		return false
+	}
+	parentNode := unmarshalBucketNode(&parentKey, bucketNodes[parentKey])
+	return len(parentNode.childrenCryptoHash[parentKey.getChildIndex(bKey)]) != 0
+}
+
+// evictPrunedNodes drops the removed nodes from the caches, which may have loaded them
+func (stateImpl *StateImpl) evictPrunedNodes(orphanedKeys [][]byte, prunedBuckets map[int]bool) {
+	for _, key := range orphanedKeys {
+		if key[0] == byte(0) {
+			stateImpl.bucketCache.replace(decodeBucketKey(key), nil)
+		}
+	}
+	for bucketNumber := range prunedBuckets {
+		if bucketNumber < 1 || bucketNumber > conf.getNumBucketsAtLowestLevel() {
+			continue
+		}
+		stateImpl.dataNodeCache.remove(newBucketKeyAtLowestLevel(bucketNumber))
+		if err := stateImpl.bloomFilters.remove(bucketNumber); err != nil {
+			logger.Warning("Error dropping the filter of pruned bucket [%d]: %s", bucketNumber, err)
+		}
+	}
+}
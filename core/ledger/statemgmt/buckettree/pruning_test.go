@@ -0,0 +1,81 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package buckettree
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/db"
+	"github.com/hyperledger/fabric/core/db/kvstore"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+	"github.com/hyperledger/fabric/core/ledger/testutil"
+)
+
+func TestPruneOrphanedNodes(t *testing.T) {
+	testDBWrapper.CreateFreshDB(t)
+	stateImplTestWrapper := newStateImplTestWrapperWithCustomConfig(t, 100, 5)
+	stateDelta := statemgmt.NewStateDelta()
+	for i := 0; i < 5; i++ {
+		stateDelta.Set("chaincodeID", fmt.Sprintf("key%d", i), []byte(fmt.Sprintf("value%d", i)), nil)
+	}
+	rootHash := stateImplTestWrapper.prepareWorkingSetAndComputeCryptoHash(stateDelta)
+	stateImplTestWrapper.persistChangesAndResetInMemoryChanges()
+
+	stats, err := stateImplTestWrapper.stateImpl.PruneOrphanedNodes()
+	testutil.AssertNoError(t, err, "Error while pruning")
+	testutil.AssertEquals(t, *stats, statemgmt.PruneStats{})
+
+	// a key whose parent bucket is not part of the tree
+	var orphanedKey *dataKey
+	for i := 0; orphanedKey == nil; i++ {
+		dataKey := newDataKey("chaincodeID", fmt.Sprintf("orphan%d", i))
+		parentNode, _ := fetchBucketNodeFromDB(db.GetDBHandle().KVStore(), dataKey.bucketKey.getParentKey())
+		if parentNode == nil {
+			orphanedKey = dataKey
+		}
+	}
+	unreachableBucket := orphanedKey.bucketKey.getParentKey()
+	misplacedKey := newDataKey("chaincodeID", "key0")
+	misplacedKey.bucketKey = newBucketKeyAtLowestLevel(misplacedKey.bucketKey.bucketNumber%100 + 1)
+	outOfTreeBucket := &bucketKey{conf.getLowestLevel() + 2, 1}
+
+	writeBatch := db.GetDBHandle().KVStore().NewWriteBatch()
+	defer writeBatch.Destroy()
+	writeBatch.Put(kvstore.StateCFName, orphanedKey.getEncodedBytes(), []byte("orphan"))
+	writeBatch.Put(kvstore.StateCFName, misplacedKey.getEncodedBytes(), []byte("misplaced"))
+	writeBatch.Put(kvstore.StateCFName, unreachableBucket.getEncodedBytes(), newBucketNode(unreachableBucket).marshal())
+	writeBatch.Put(kvstore.StateCFName, outOfTreeBucket.getEncodedBytes(), []byte("outOfTree"))
+	writeBatch.Put(kvstore.StateCFName, encodeBloomFilterKey(101), []byte("filter"))
+	testDBWrapper.WriteToDB(t, writeBatch)
+
+	stats, err = stateImplTestWrapper.stateImpl.PruneOrphanedNodes()
+	testutil.AssertNoError(t, err, "Error while pruning")
+	testutil.AssertEquals(t, stats.DataNodesRemoved, uint64(2))
+	testutil.AssertEquals(t, stats.IntermediateNodesRemoved, uint64(2))
+	testutil.AssertEquals(t, stats.OtherNodesRemoved, uint64(1))
+
+	for _, key := range [][]byte{orphanedKey.getEncodedBytes(), misplacedKey.getEncodedBytes(),
+		unreachableBucket.getEncodedBytes(), outOfTreeBucket.getEncodedBytes(), encodeBloomFilterKey(101)} {
+		testutil.AssertNil(t, testDBWrapper.GetFromStateCF(t, key))
+	}
+	stateImplTestWrapper.constructNewStateImpl()
+	testutil.AssertEquals(t, stateImplTestWrapper.computeCryptoHash(), rootHash)
+	for i := 0; i < 5; i++ {
+		testutil.AssertEquals(t, stateImplTestWrapper.get("chaincodeID", fmt.Sprintf("key%d", i)), []byte(fmt.Sprintf("value%d", i)))
+	}
+}
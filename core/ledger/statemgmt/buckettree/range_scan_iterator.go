@@ -17,14 +17,13 @@ limitations under the License.
 package buckettree
 
 import (
-	"github.com/hyperledger/fabric/core/db"
+	"github.com/hyperledger/fabric/core/db/kvstore"
 	"github.com/hyperledger/fabric/core/ledger/statemgmt"
-	"github.com/tecbot/gorocksdb"
 )
 
 // RangeScanIterator implements the interface 'statemgmt.RangeScanIterator'
 type RangeScanIterator struct {
-	dbItr               *gorocksdb.Iterator
+	dbItr               kvstore.Iterator
 	chaincodeID         string
 	startKey            string
 	endKey              string
@@ -34,8 +33,8 @@ type RangeScanIterator struct {
 	done                bool
 }
 
-func newRangeScanIterator(chaincodeID string, startKey string, endKey string) (*RangeScanIterator, error) {
-	dbItr := db.GetDBHandle().GetStateCFIterator()
+func newRangeScanIterator(store kvstore.Reader, chaincodeID string, startKey string, endKey string) (*RangeScanIterator, error) {
+	dbItr := store.NewIterator(kvstore.StateCFName)
 	itr := &RangeScanIterator{
 		dbItr:       dbItr,
 		chaincodeID: chaincodeID,
@@ -52,12 +51,10 @@ func (itr *RangeScanIterator) Next() bool {
 		return false
 	}
 
-	for itr.dbItr.Valid() {
+	for itr.dbItr.Valid() && isDataNodeKey(itr.dbItr.Key()) {
 
-		// making a copy of key-value bytes because, underlying key bytes are reused by itr.
-		// no need to free slices as iterator frees memory when closed.
-		keyBytes := statemgmt.Copy(itr.dbItr.Key().Data())
-		valueBytes := statemgmt.Copy(itr.dbItr.Value().Data())
+		keyBytes := itr.dbItr.Key()
+		valueBytes := itr.dbItr.Value()
 
 		dataNode := unmarshalDataNodeFromBytes(keyBytes, valueBytes)
 		dataKey := dataNode.dataKey
@@ -0,0 +1,220 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package buckettree
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/hyperledger/fabric/core/db/kvstore"
+)
+
+// Read-repair
+//
+// The bucket cache, the data-node cache and the bloom filters are derived from the nodes
+// persisted in the state column family, which remain the authority. One in every
+// 'readRepairCheckInterval' reads served by a derived structure is verified against the DB.
+// On a disagreement, an inconsistency event is logged, the derived structure is repaired by
+// dropping the offending entry (which is then reloaded from, or rebuilt along with, the DB)
+// and a counter per structure is incremented. The counters are reported with the state stats.
+
+const (
+	structureBucketCache   = "bucketCache"
+	structureDataNodeCache = "dataNodeCache"
+	structureBloomFilter   = "bloomFilter"
+)
+
+type readRepair struct {
+	numReads uint64
+	// persistGeneration is odd while the changes of a block are being written to the DB.
+	// The derived structures are updated only after the write (see ClearWorkingSet), so a
+	// check that overlaps a write may observe a disagreement that is not an inconsistency.
+	persistGeneration uint64
+	// persistLock is held while persistGeneration is odd, hence from the moment the changes
+	// of a block are added to the write-batch until the caches are updated. Pruning holds
+	// it for its write, so that no commit starts in between (see PruneOrphanedNodes)
+	persistLock   sync.Mutex
+	checkInterval uint64
+	lock          sync.Mutex
+	counters      map[string]uint64
+}
+
+func newReadRepair(checkInterval int) *readRepair {
+	if checkInterval < 0 {
+		checkInterval = 0
+	}
+	return &readRepair{checkInterval: uint64(checkInterval), counters: make(map[string]uint64)}
+}
+
+// startCheck returns true if the current read is to be verified against the DB, along
+// with the generation to be passed to isValid once the DB has been read
+func (repair *readRepair) startCheck() (uint64, bool) {
+	if repair.checkInterval == 0 || atomic.AddUint64(&repair.numReads, 1)%repair.checkInterval != 0 {
+		return 0, false
+	}
+	generation := atomic.LoadUint64(&repair.persistGeneration)
+	return generation, generation%2 == 0
+}
+
+// isValid returns false if the changes of a block may have been written to the DB since startCheck
+func (repair *readRepair) isValid(generation uint64) bool {
+	return atomic.LoadUint64(&repair.persistGeneration) == generation
+}
+
+func (repair *readRepair) beginPersist() {
+	if atomic.LoadUint64(&repair.persistGeneration)%2 == 0 {
+		repair.persistLock.Lock()
+		atomic.AddUint64(&repair.persistGeneration, 1)
+	}
+}
+
+func (repair *readRepair) endPersist() {
+	if atomic.LoadUint64(&repair.persistGeneration)%2 == 1 {
+		atomic.AddUint64(&repair.persistGeneration, 1)
+		repair.persistLock.Unlock()
+	}
+}
+
+func (repair *readRepair) recordInconsistency(structure string, bucketKey *bucketKey, detail string) {
+	repair.lock.Lock()
+	repair.counters[structure]++
+	count := repair.counters[structure]
+	repair.lock.Unlock()
+	logger.Warning("event=[stateInconsistency] structure=[%s] bucket=[%s] detail=[%s] repairs=[%d]", structure, bucketKey, detail, count)
+}
+
+func (repair *readRepair) getCounters() map[string]uint64 {
+	repair.lock.Lock()
+	defer repair.lock.Unlock()
+	counters := make(map[string]uint64, len(repair.counters))
+	for structure, count := range repair.counters {
+		counters[structure] = count
+	}
+	return counters
+}
+
+// GetReadRepairCounts - see interface 'statemgmt.ReadRepairStatsProvider' for details
+func (stateImpl *StateImpl) GetReadRepairCounts() map[string]uint64 {
+	return stateImpl.readRepair.getCounters()
+}
+
+// verifyCachedDataNode compares the data node served by the data-node cache with the one in
+// the DB and returns the value from the DB
+func (stateImpl *StateImpl) verifyCachedDataNode(dataKey *dataKey, cachedNode *dataNode, generation uint64) ([]byte, error) {
+	dbNode, err := fetchDataNodeFromDB(stateImpl.store, dataKey)
+	if err != nil {
+		return nil, err
+	}
+	if !dataNodesEqual(cachedNode, dbNode) && stateImpl.readRepair.isValid(generation) {
+		stateImpl.dataNodeCache.remove(dataKey.bucketKey)
+		stateImpl.readRepair.recordInconsistency(structureDataNodeCache, dataKey.bucketKey,
+			fmt.Sprintf("cached value of key [%s] differs from the DB", dataKey))
+	}
+	if dbNode == nil {
+		return nil, nil
+	}
+	return dbNode.value, nil
+}
+
+// verifyCachedDataNodes compares the data nodes of a bucket served by the data-node cache
+// with the ones in the DB and returns the nodes from the DB
+func (stateImpl *StateImpl) verifyCachedDataNodes(bucketKey *bucketKey, cachedNodes dataNodes, generation uint64) (dataNodes, error) {
+	dbNodes, err := fetchDataNodesFromDBFor(stateImpl.store, bucketKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(cachedNodes) != len(dbNodes) {
+		if stateImpl.readRepair.isValid(generation) {
+			stateImpl.dataNodeCache.remove(bucketKey)
+			stateImpl.readRepair.recordInconsistency(structureDataNodeCache, bucketKey,
+				fmt.Sprintf("[%d] keys cached while the DB has [%d]", len(cachedNodes), len(dbNodes)))
+		}
+		return dbNodes, nil
+	}
+	for i := range dbNodes {
+		if !bytes.Equal(cachedNodes[i].dataKey.compositeKey, dbNodes[i].dataKey.compositeKey) || !dataNodesEqual(cachedNodes[i], dbNodes[i]) {
+			if stateImpl.readRepair.isValid(generation) {
+				stateImpl.dataNodeCache.remove(bucketKey)
+				stateImpl.readRepair.recordInconsistency(structureDataNodeCache, bucketKey,
+					fmt.Sprintf("cached key-value [%s] differs from the DB", cachedNodes[i].dataKey))
+			}
+			break
+		}
+	}
+	return dbNodes, nil
+}
+
+// verifyBloomFilter checks, against the DB, that a key excluded by the bloom filter of its
+// bucket is indeed absent, and returns the value from the DB
+func (stateImpl *StateImpl) verifyBloomFilter(dataKey *dataKey, generation uint64) ([]byte, error) {
+	dbNode, err := fetchDataNodeFromDB(stateImpl.store, dataKey)
+	if err != nil {
+		return nil, err
+	}
+	if dbNode == nil {
+		return nil, nil
+	}
+	if stateImpl.readRepair.isValid(generation) {
+		// without a filter, the lookups in the bucket go to the DB until the filter is rebuilt
+		// along with the next change to the bucket
+		if err := stateImpl.bloomFilters.remove(dataKey.bucketKey.bucketNumber); err != nil {
+			return nil, err
+		}
+		stateImpl.readRepair.recordInconsistency(structureBloomFilter, dataKey.bucketKey,
+			fmt.Sprintf("key [%s] present in the DB is excluded by the filter", dataKey))
+	}
+	return dbNode.value, nil
+}
+
+// verifyCachedBucketNode compares the bucket node served by the bucket cache with the one in
+// the DB and returns the node from the DB
+func (stateImpl *StateImpl) verifyCachedBucketNode(bucketKey *bucketKey, cachedNode *bucketNode) (*bucketNode, error) {
+	dbNode, err := fetchBucketNodeFromDB(stateImpl.store, bucketKey)
+	if err != nil {
+		return nil, err
+	}
+	if cachedNode == nil && dbNode == nil {
+		return nil, nil
+	}
+	if cachedNode == nil || dbNode == nil || !bytes.Equal(cachedNode.marshal(), dbNode.marshal()) {
+		stateImpl.bucketCache.replace(*bucketKey, dbNode)
+		stateImpl.readRepair.recordInconsistency(structureBucketCache, bucketKey, "cached children crypto-hashes differ from the DB")
+	}
+	return dbNode, nil
+}
+
+func dataNodesEqual(node1 *dataNode, node2 *dataNode) bool {
+	if node1 == nil || node2 == nil {
+		return node1 == node2
+	}
+	return bytes.Equal(node1.value, node2.value)
+}
+
+// remove drops the filter of the bucket, from the DB as well as from the cache
+func (cache *bloomFilterCache) remove(bucketNumber int) error {
+	store := cache.store
+	if err := kvstore.Delete(store, kvstore.StateCFName, encodeBloomFilterKey(bucketNumber)); err != nil {
+		return err
+	}
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	cache.checkStateCFWithoutLock(store.Generation())
+	cache.c[bucketNumber] = nil
+	return nil
+}
@@ -0,0 +1,157 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package buckettree
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric/core/db"
+	"github.com/hyperledger/fabric/core/db/kvstore"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+	"github.com/hyperledger/fabric/core/ledger/testutil"
+)
+
+func TestReadRepairDataNodeCache(t *testing.T) {
+	stateImplTestWrapper := createFreshDBAndInitReadRepairTestStateImpl(t)
+	stateImpl := stateImplTestWrapper.stateImpl
+	dataKey := newDataKey("chaincodeID1", "key1")
+	_, err := stateImpl.dataNodeCache.get(dataKey.bucketKey)
+	testutil.AssertNoError(t, err, "Error while loading bucket in data-node cache")
+
+	// the value changed behind the cache is served from the DB and the bucket is dropped from the cache
+	store := db.GetDBHandle().KVStore()
+	kvstore.Put(store, kvstore.StateCFName, dataKey.getEncodedBytes(), []byte("valueInDB"))
+	testutil.AssertEquals(t, stateImplTestWrapper.get("chaincodeID1", "key1"), []byte("valueInDB"))
+	testutil.AssertEquals(t, stateImpl.GetReadRepairCounts()[structureDataNodeCache], uint64(1))
+	_, cached := stateImpl.dataNodeCache.getDataNode(dataKey)
+	testutil.AssertEquals(t, cached, false)
+	testutil.AssertEquals(t, stateImplTestWrapper.get("chaincodeID1", "key1"), []byte("valueInDB"))
+	testutil.AssertEquals(t, stateImpl.GetReadRepairCounts()[structureDataNodeCache], uint64(1))
+}
+
+func TestReadRepairBloomFilter(t *testing.T) {
+	stateImplTestWrapper := createFreshDBAndInitReadRepairTestStateImpl(t)
+	stateImpl := stateImplTestWrapper.stateImpl
+	stateImpl.dataNodeCache = newDataNodeCache(db.GetDBHandle().KVStore(), 0)
+
+	// a key added behind the filter is served from the DB and the filter is dropped
+	dataKey := newDataKey("chaincodeID1", "keyNotInFilter")
+	filter, err := stateImpl.bloomFilters.get(dataKey.bucketKey.bucketNumber)
+	testutil.AssertNoError(t, err, "Error while getting bloom filter")
+	if filter == nil || filter.mayContain(dataKey.compositeKey) {
+		t.Skip("The test key is not excluded by the filter")
+	}
+	store := db.GetDBHandle().KVStore()
+	kvstore.Put(store, kvstore.StateCFName, dataKey.getEncodedBytes(), []byte("valueInDB"))
+	testutil.AssertEquals(t, stateImplTestWrapper.get("chaincodeID1", "keyNotInFilter"), []byte("valueInDB"))
+	testutil.AssertEquals(t, stateImpl.GetReadRepairCounts()[structureBloomFilter], uint64(1))
+	filterBytes, _ := store.Get(kvstore.StateCFName, encodeBloomFilterKey(dataKey.bucketKey.bucketNumber))
+	testutil.AssertNil(t, filterBytes)
+
+	// a corrupt filter is dropped instead of failing the lookups
+	bucketNumber := newDataKey("chaincodeID1", "key2").bucketKey.bucketNumber
+	kvstore.Put(store, kvstore.StateCFName, encodeBloomFilterKey(bucketNumber), []byte("corrupt"))
+	stateImpl.bloomFilters = newBloomFilterCache(db.GetDBHandle().KVStore(), stateImpl.readRepair)
+	testutil.AssertEquals(t, stateImplTestWrapper.get("chaincodeID1", "key2"), []byte("value2"))
+	testutil.AssertEquals(t, stateImpl.GetReadRepairCounts()[structureBloomFilter], uint64(2))
+	filterBytes, _ = store.Get(kvstore.StateCFName, encodeBloomFilterKey(bucketNumber))
+	testutil.AssertNil(t, filterBytes)
+}
+
+func TestReadRepairBucketCache(t *testing.T) {
+	stateImplTestWrapper := createFreshDBAndInitReadRepairTestStateImpl(t)
+	stateImpl := stateImplTestWrapper.stateImpl
+	rootKey := constructRootBucketKey()
+	stateImpl.bucketCache.lock.Lock()
+	stateImpl.bucketCache.c[*rootKey].childrenCryptoHash[0] = []byte("staleHash")
+	stateImpl.bucketCache.lock.Unlock()
+
+	stateDelta := statemgmt.NewStateDelta()
+	stateDelta.Set("chaincodeID1", "key0", []byte("newValue"), nil)
+	rootHash := stateImplTestWrapper.prepareWorkingSetAndComputeCryptoHash(stateDelta)
+	testutil.AssertEquals(t, stateImpl.GetReadRepairCounts()[structureBucketCache] > 0, true)
+	stateImplTestWrapper.persistChangesAndResetInMemoryChanges()
+
+	// the state hash is the one computed without the cache
+	stateImplTestWrapper.configMap[ConfigReadRepairCheckInterval] = 0
+	stateImplTestWrapper.configMap["bucketCacheSize"] = 0
+	stateImplTestWrapper.constructNewStateImpl()
+	stateDelta = statemgmt.NewStateDelta()
+	stateDelta.Set("chaincodeID1", "key0", []byte("newValue"), nil)
+	testutil.AssertEquals(t, stateImplTestWrapper.prepareWorkingSetAndComputeCryptoHash(stateDelta), rootHash)
+}
+
+func TestReadRepairDisabledDuringPersist(t *testing.T) {
+	repair := newReadRepair(1)
+	generation, check := repair.startCheck()
+	testutil.AssertEquals(t, check, true)
+	repair.beginPersist()
+	testutil.AssertEquals(t, repair.isValid(generation), false)
+	_, check = repair.startCheck()
+	testutil.AssertEquals(t, check, false)
+	repair.endPersist()
+	generation, check = repair.startCheck()
+	testutil.AssertEquals(t, check, true)
+	testutil.AssertEquals(t, repair.isValid(generation), true)
+
+	repair = newReadRepair(0)
+	_, check = repair.startCheck()
+	testutil.AssertEquals(t, check, false)
+}
+
+func createFreshDBAndInitReadRepairTestStateImpl(t *testing.T) *stateImplTestWrapper {
+	testDBWrapper.CreateFreshDB(t)
+	stateImplTestWrapper := newStateImplTestWrapperWithCustomConfigMap(t, map[string]interface{}{
+		ConfigNumBuckets:              5,
+		ConfigMaxGroupingAtEachLevel:  2,
+		ConfigDataNodeCacheSize:       1,
+		ConfigBloomFilterBitsPerKey:   10,
+		ConfigReadRepairCheckInterval: 1,
+	})
+	stateDelta := statemgmt.NewStateDelta()
+	for i := 0; i < 10; i++ {
+		stateDelta.Set("chaincodeID1", fmt.Sprintf("key%d", i), []byte(fmt.Sprintf("value%d", i)), nil)
+	}
+	stateImplTestWrapper.prepareWorkingSetAndComputeCryptoHash(stateDelta)
+	stateImplTestWrapper.persistChangesAndResetInMemoryChanges()
+	return stateImplTestWrapper
+}
+
+func TestPersistWaitsForPruning(t *testing.T) {
+	repair := newReadRepair(0)
+	// as held by PruneOrphanedNodes for its write
+	repair.persistLock.Lock()
+	persisting := make(chan struct{})
+	go func() {
+		repair.beginPersist()
+		close(persisting)
+	}()
+	select {
+	case <-persisting:
+		t.Fatalf("Expected the commit to wait for the pruning")
+	case <-time.After(50 * time.Millisecond):
+	}
+	repair.persistLock.Unlock()
+	<-persisting
+	_, check := repair.startCheck()
+	testutil.AssertEquals(t, check, false)
+	repair.endPersist()
+	testutil.AssertEquals(t, atomic.LoadUint64(&repair.persistGeneration), uint64(2))
+}
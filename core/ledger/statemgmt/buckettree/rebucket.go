@@ -0,0 +1,154 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package buckettree
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/hyperledger/fabric/core/db/kvstore"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+)
+
+// rebucketChunkSize is the number of data nodes Rebucket reads in memory at once
+var rebucketChunkSize = 100000
+
+// Rebucket rebuilds the bucket tree persisted in store with the configurations oldConfigs so that it
+// conforms to the configurations newConfigs (see ConfigNumBuckets, ConfigMaxGroupingAtEachLevel
+// and ConfigBucketHashFunction) and returns the new root hash. The data nodes are read in chunks
+// of the new lowest-level buckets, each chunk holding about rebucketChunkSize data nodes, and the
+// removal of the existing tree along with the new tree are written in a single write-batch, so
+// that the DB holds either tree if the rebucketing is interrupted.
+//
+// This is an offline operation - the peer should not be running and the DB should be backed up
+// before. Because the state hash depends on the configurations, the state hash of the blocks
+// committed afterwards is computed with the new tree and hence, all the peers of a network have
+// to be rebucketed with the same configurations.
+func Rebucket(store kvstore.KVStore, oldConfigs map[string]interface{}, newConfigs map[string]interface{}) ([]byte, error) {
+	if err := initConfig(oldConfigs); err != nil {
+		return nil, err
+	}
+	if err := checkTreeMetadata(store); err != nil {
+		return nil, err
+	}
+	writeBatch := store.NewWriteBatch()
+	defer writeBatch.Destroy()
+	// the deletes precede the puts of the new tree, which hence prevail for the same keys
+	numKeys, err := addTreeDeletes(store, writeBatch)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := initConfig(newConfigs); err != nil {
+		return nil, err
+	}
+	bloomFilterBitsPerKey, _ := newConfigs[ConfigBloomFilterBitsPerKey].(int)
+	logger.Info("Rebucketing [%d] keys from configurations [%s]", numKeys, newTreeMetadataFromConfig())
+	numBuckets := conf.getNumBucketsAtLowestLevel()
+	numChunks := numKeys/rebucketChunkSize + 1
+	bucketsPerChunk := (numBuckets + numChunks - 1) / numChunks
+	bucketTreeDelta := newBucketTreeDelta()
+	for firstBucket := 1; firstBucket <= numBuckets; firstBucket += bucketsPerChunk {
+		dataNodesDelta, err := readDataNodesInBuckets(store, firstBucket, firstBucket+bucketsPerChunk)
+		if err != nil {
+			return nil, err
+		}
+		for _, bucketKey := range dataNodesDelta.getAffectedBuckets() {
+			dataNodes := dataNodesDelta.getSortedDataNodesFor(bucketKey)
+			for _, dataNode := range dataNodes {
+				writeBatch.Put(kvstore.StateCFName, dataNode.dataKey.getEncodedBytes(), dataNode.value)
+			}
+			if bloomFilterBitsPerKey > 0 {
+				writeBatch.Put(kvstore.StateCFName, encodeBloomFilterKey(bucketKey.bucketNumber), buildBloomFilter(dataNodes, nil, bloomFilterBitsPerKey).marshal())
+			}
+			parentBucket := bucketTreeDelta.getOrCreateBucketNode(bucketKey.getParentKey())
+			parentBucket.setChildCryptoHash(bucketKey, computeDataNodesCryptoHash(bucketKey, dataNodes, nil))
+		}
+	}
+	rootHash := addBucketNodes(bucketTreeDelta, writeBatch)
+	writeBatch.Put(kvstore.PersistCFName, treeMetadataKey, newTreeMetadataFromConfig().marshal())
+	if err := store.Write(writeBatch, false); err != nil {
+		return nil, err
+	}
+	logger.Info("Rebucketed [%d] keys to configurations [%s]. New root hash = [%x]", numKeys, newTreeMetadataFromConfig(), rootHash)
+	return rootHash, nil
+}
+
+// addTreeDeletes adds to the write-batch the removal of all the bucket nodes, the data nodes and
+// the bloom filters of the tree, and returns the number of the data nodes
+func addTreeDeletes(store kvstore.KVStore, writeBatch kvstore.WriteBatch) (int, error) {
+	itr := store.NewIterator(kvstore.StateCFName)
+	defer itr.Close()
+	numKeys := 0
+	for itr.SeekToFirst(); itr.Valid(); itr.Next() {
+		if isDataNodeKey(itr.Key()) {
+			numKeys++
+		}
+		writeBatch.Delete(kvstore.StateCFName, itr.Key())
+	}
+	if err := itr.Err(); err != nil {
+		return 0, fmt.Errorf("Error while reading the tree: %s", err)
+	}
+	return numKeys, nil
+}
+
+// readDataNodesInBuckets returns the data nodes of the tree that belong, with the current
+// configurations, to the lowest-level buckets from firstBucket to lastBucket-1
+func readDataNodesInBuckets(store kvstore.KVStore, firstBucket int, lastBucket int) (*dataNodesDelta, error) {
+	itr := store.NewIterator(kvstore.StateCFName)
+	defer itr.Close()
+	dataNodesDelta := &dataNodesDelta{make(map[bucketKey]dataNodes)}
+	// data nodes follow the bucket nodes that are stored with the prefix 0x00
+	for itr.Seek([]byte{0x01}); itr.Valid() && isDataNodeKey(itr.Key()); itr.Next() {
+		// the key is encoded with the bucket number of the existing tree
+		_, bucketNumberLength := decodeBucketNumber(itr.Key())
+		chaincodeID, key := statemgmt.DecodeCompositeKey(itr.Key()[bucketNumberLength:])
+		dataKey := newDataKey(chaincodeID, key)
+		if bucketNumber := dataKey.bucketKey.bucketNumber; bucketNumber < firstBucket || bucketNumber >= lastBucket {
+			continue
+		}
+		bucketKey := *dataKey.getBucketKey()
+		dataNodesDelta.byBucket[bucketKey] = append(dataNodesDelta.byBucket[bucketKey], newDataNode(dataKey, itr.Value()))
+	}
+	if err := itr.Err(); err != nil {
+		return nil, fmt.Errorf("Error while reading data nodes: %s", err)
+	}
+	for _, dataNodes := range dataNodesDelta.byBucket {
+		sort.Sort(dataNodes)
+	}
+	return dataNodesDelta, nil
+}
+
+// addBucketNodes computes the bucket nodes above the lowest level from the crypto-hashes of the
+// lowest-level buckets, set in their parents, adds them to the write-batch and returns the root hash
+func addBucketNodes(bucketTreeDelta *bucketTreeDelta, writeBatch kvstore.WriteBatch) []byte {
+	for level := conf.getLowestLevel() - 1; level > 0; level-- {
+		for _, bucketNode := range bucketTreeDelta.getBucketNodesAt(level) {
+			parentBucket := bucketTreeDelta.getOrCreateBucketNode(bucketNode.bucketKey.getParentKey())
+			parentBucket.setChildCryptoHash(bucketNode.bucketKey, bucketNode.computeCryptoHash())
+		}
+	}
+	rootHash := bucketTreeDelta.getOrCreateBucketNode(constructRootBucketKey()).computeCryptoHash()
+	for level := conf.getLowestLevel() - 1; level >= 0; level-- {
+		for _, bucketNode := range bucketTreeDelta.getBucketNodesAt(level) {
+			if !bucketNode.markedForDeletion {
+				writeBatch.Put(kvstore.StateCFName, bucketNode.bucketKey.getEncodedBytes(), bucketNode.marshal())
+			}
+		}
+	}
+	return rootHash
+}
@@ -0,0 +1,61 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package buckettree
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/db"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+	"github.com/hyperledger/fabric/core/ledger/testutil"
+)
+
+func TestRebucket(t *testing.T) {
+	stateDelta := statemgmt.NewStateDelta()
+	for i := 0; i < 50; i++ {
+		stateDelta.Set(fmt.Sprintf("chaincodeID%d", i%3), fmt.Sprintf("key%d", i), []byte(fmt.Sprintf("value%d", i)), nil)
+	}
+
+	// expected root hash, computed on a tree built directly with the new configurations
+	testDBWrapper.CreateFreshDB(t)
+	stateImplTestWrapper := newStateImplTestWrapperWithCustomConfig(t, 26, 3)
+	expectedHash := stateImplTestWrapper.prepareWorkingSetAndComputeCryptoHash(stateDelta)
+
+	testDBWrapper.CreateFreshDB(t)
+	stateImplTestWrapper = newStateImplTestWrapperWithCustomConfig(t, 100, 5)
+	stateImplTestWrapper.prepareWorkingSetAndComputeCryptoHash(stateDelta)
+	stateImplTestWrapper.persistChangesAndResetInMemoryChanges()
+
+	newConfigs := map[string]interface{}{ConfigNumBuckets: 26, ConfigMaxGroupingAtEachLevel: 3}
+	_, err := Rebucket(db.GetDBHandle().KVStore(), newConfigs, newConfigs)
+	testutil.AssertError(t, err, "Expected error for old configurations that do not match the persisted tree")
+
+	// several chunks of buckets
+	defer func(chunkSize int) { rebucketChunkSize = chunkSize }(rebucketChunkSize)
+	rebucketChunkSize = 7
+	rootHash, err := Rebucket(db.GetDBHandle().KVStore(), stateImplTestWrapper.configMap, newConfigs)
+	testutil.AssertNoError(t, err, "Error while rebucketing")
+	testutil.AssertEquals(t, rootHash, expectedHash)
+
+	// the tree can only be opened with the new configurations now
+	testutil.AssertError(t, NewStateImpl(db.GetDBHandle().KVStore()).Initialize(stateImplTestWrapper.configMap), "Expected error for old configurations")
+	stateImplTestWrapper = newStateImplTestWrapperWithCustomConfig(t, 26, 3)
+	testutil.AssertEquals(t, stateImplTestWrapper.computeCryptoHash(), expectedHash)
+	testutil.AssertEquals(t, stateImplTestWrapper.get("chaincodeID1", "key1"), []byte("value1"))
+	testutil.AssertEquals(t, stateImplTestWrapper.get("chaincodeID2", "key47"), []byte("value47"))
+}
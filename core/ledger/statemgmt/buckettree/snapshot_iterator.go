@@ -17,36 +17,36 @@ limitations under the License.
 package buckettree
 
 import (
-	"github.com/hyperledger/fabric/core/db"
-	"github.com/hyperledger/fabric/core/ledger/statemgmt"
-	"github.com/tecbot/gorocksdb"
+	"github.com/hyperledger/fabric/core/db/kvstore"
 )
 
 // StateSnapshotIterator implements the interface 'statemgmt.StateSnapshotIterator'
 type StateSnapshotIterator struct {
-	dbItr *gorocksdb.Iterator
+	dbItr kvstore.Iterator
+	// positioned is set once dbItr has been positioned on the first data node
+	positioned bool
 }
 
-func newStateSnapshotIterator(snapshot *gorocksdb.Snapshot) (*StateSnapshotIterator, error) {
-	dbItr := db.GetDBHandle().GetStateCFSnapshotIterator(snapshot)
-	dbItr.Seek([]byte{0x01})
-	dbItr.Prev()
-	return &StateSnapshotIterator{dbItr}, nil
+func newStateSnapshotIterator(snapshot kvstore.Snapshot) (*StateSnapshotIterator, error) {
+	return &StateSnapshotIterator{snapshot.NewIterator(kvstore.StateCFName), false}, nil
 }
 
 // Next - see interface 'statemgmt.StateSnapshotIterator' for details
 func (snapshotItr *StateSnapshotIterator) Next() bool {
-	snapshotItr.dbItr.Next()
-	return snapshotItr.dbItr.Valid()
+	if snapshotItr.positioned {
+		snapshotItr.dbItr.Next()
+	} else {
+		// the data nodes are stored after the bucket nodes, which have the prefix 0x00
+		snapshotItr.dbItr.Seek([]byte{0x01})
+		snapshotItr.positioned = true
+	}
+	return snapshotItr.dbItr.Valid() && isDataNodeKey(snapshotItr.dbItr.Key())
 }
 
 // GetRawKeyValue - see interface 'statemgmt.StateSnapshotIterator' for details
 func (snapshotItr *StateSnapshotIterator) GetRawKeyValue() ([]byte, []byte) {
-
-	// making a copy of key-value bytes because, underlying key bytes are reused by itr.
-	// no need to free slices as iterator frees memory when closed.
-	keyBytes := statemgmt.Copy(snapshotItr.dbItr.Key().Data())
-	valueBytes := statemgmt.Copy(snapshotItr.dbItr.Value().Data())
+	keyBytes := snapshotItr.dbItr.Key()
+	valueBytes := snapshotItr.dbItr.Value()
 	dataNode := unmarshalDataNodeFromBytes(keyBytes, valueBytes)
 	return dataNode.getCompositeKey(), dataNode.getValue()
 }
@@ -55,3 +55,54 @@ func (snapshotItr *StateSnapshotIterator) GetRawKeyValue() ([]byte, []byte) {
 func (snapshotItr *StateSnapshotIterator) Close() {
 	snapshotItr.dbItr.Close()
 }
+
+// subtreeSnapshotIterator iterates over the data nodes of ranges of buckets at the lowest level,
+// i.e., over the key-values of some subtrees of the bucket tree
+type subtreeSnapshotIterator struct {
+	dbItr kvstore.Iterator
+	// ranges holds the pairs of first and last bucket numbers still to be iterated over
+	ranges [][2]int
+	// positioned is set once dbItr has been positioned in the first range
+	positioned bool
+}
+
+func newSubtreeSnapshotIterator(snapshot kvstore.Snapshot, ranges [][2]int) *subtreeSnapshotIterator {
+	return &subtreeSnapshotIterator{snapshot.NewIterator(kvstore.StateCFName), ranges, false}
+}
+
+// Next - see interface 'statemgmt.StateSnapshotIterator' for details
+func (itr *subtreeSnapshotIterator) Next() bool {
+	if itr.positioned {
+		itr.dbItr.Next()
+	}
+	for len(itr.ranges) > 0 {
+		if !itr.positioned {
+			itr.dbItr.Seek(encodeBucketNumber(itr.ranges[0][0]))
+			itr.positioned = true
+		}
+		if itr.dbItr.Valid() {
+			keyBytes := itr.dbItr.Key()
+			if isDataNodeKey(keyBytes) {
+				if bucketNumber, _ := decodeBucketNumber(keyBytes); bucketNumber <= itr.ranges[0][1] {
+					return true
+				}
+			}
+		}
+		itr.ranges = itr.ranges[1:]
+		itr.positioned = false
+	}
+	return false
+}
+
+// GetRawKeyValue - see interface 'statemgmt.StateSnapshotIterator' for details
+func (itr *subtreeSnapshotIterator) GetRawKeyValue() ([]byte, []byte) {
+	keyBytes := itr.dbItr.Key()
+	valueBytes := itr.dbItr.Value()
+	dataNode := unmarshalDataNodeFromBytes(keyBytes, valueBytes)
+	return dataNode.getCompositeKey(), dataNode.getValue()
+}
+
+// Close - see interface 'statemgmt.StateSnapshotIterator' for details
+func (itr *subtreeSnapshotIterator) Close() {
+	itr.dbItr.Close()
+}
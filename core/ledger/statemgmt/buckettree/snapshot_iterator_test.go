@@ -17,9 +17,11 @@ limitations under the License.
 package buckettree
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/hyperledger/fabric/core/db"
+	"github.com/hyperledger/fabric/core/db/kvstore"
 	"github.com/hyperledger/fabric/core/ledger/statemgmt"
 	"github.com/hyperledger/fabric/core/ledger/testutil"
 )
@@ -42,7 +44,7 @@ func TestStateSnapshotIterator(t *testing.T) {
 	testutil.AssertEquals(t, stateImplTestWrapper.get("chaincodeID5", "key5"), []byte("value5"))
 
 	// take db snapeshot
-	dbSnapshot := db.GetDBHandle().GetSnapshot()
+	dbSnapshot := db.GetDBHandle().KVStore().NewSnapshot()
 
 	// delete keys
 	stateDelta.Delete("chaincodeID1", "key1", nil)
@@ -66,3 +68,71 @@ func TestStateSnapshotIterator(t *testing.T) {
 	}
 	testutil.AssertEquals(t, numKeys, 6)
 }
+
+func TestSubtreeSnapshotIterator(t *testing.T) {
+	// number of buckets at each level 26,9,3,1
+	testHasher, stateImplTestWrapper, stateDelta := createFreshDBAndInitTestStateImplWithCustomHasher(t, 26, 3)
+	testHasher.populate("chaincodeID1", "key1", 0)
+	testHasher.populate("chaincodeID2", "key2", 8)
+	testHasher.populate("chaincodeID3", "key3", 9)
+	testHasher.populate("chaincodeID4", "key4", 17)
+	testHasher.populate("chaincodeID5", "key5", 25)
+	for i := 1; i <= 5; i++ {
+		stateDelta.Set(fmt.Sprintf("chaincodeID%d", i), fmt.Sprintf("key%d", i), []byte(fmt.Sprintf("value%d", i)), nil)
+	}
+	stateImplTestWrapper.prepareWorkingSet(stateDelta)
+	stateImplTestWrapper.persistChangesAndResetInMemoryChanges()
+	dbSnapshot := db.GetDBHandle().KVStore().NewSnapshot()
+	defer dbSnapshot.Release()
+
+	getKeys := func(children ...int) []string {
+		itr, err := stateImplTestWrapper.stateImpl.GetSubtreeSnapshotIterator(dbSnapshot, children)
+		testutil.AssertNoError(t, err, "Error while getting subtree snapshot iterator")
+		defer itr.Close()
+		var keys []string
+		for itr.Next() {
+			key, _ := itr.GetRawKeyValue()
+			chaincodeID, k := statemgmt.DecodeCompositeKey(key)
+			keys = append(keys, chaincodeID+"/"+k)
+		}
+		return keys
+	}
+	testutil.AssertEquals(t, getKeys(0), []string{"chaincodeID1/key1", "chaincodeID2/key2"})
+	testutil.AssertEquals(t, getKeys(1), []string{"chaincodeID3/key3", "chaincodeID4/key4"})
+	testutil.AssertEquals(t, getKeys(2), []string{"chaincodeID5/key5"})
+	testutil.AssertEquals(t, getKeys(2, 0), []string{"chaincodeID1/key1", "chaincodeID2/key2", "chaincodeID5/key5"})
+	testutil.AssertEquals(t, len(getKeys(0, 1, 2)), 5)
+
+	_, err := stateImplTestWrapper.stateImpl.GetSubtreeSnapshotIterator(dbSnapshot, []int{3})
+	testutil.AssertError(t, err, "Expected an error for an invalid child index")
+}
+
+func TestStateSnapshotIteratorMemoryStore(t *testing.T) {
+	testDBWrapper.CreateFreshDB(t)
+	stateImplTestWrapper := newStateImplTestWrapper(t)
+	stateDelta := statemgmt.NewStateDelta()
+	stateDelta.Set("chaincodeID1", "key1", []byte("value1"), nil)
+	stateDelta.Set("chaincodeID2", "key2", []byte("value2"), nil)
+	stateImplTestWrapper.prepareWorkingSet(stateDelta)
+
+	// the changes can be persisted to, and read back from, a store other than RocksDB
+	store := kvstore.NewMemoryKVStore()
+	writeBatch := store.NewWriteBatch()
+	defer writeBatch.Destroy()
+	testutil.AssertNoError(t, stateImplTestWrapper.stateImpl.AddChangesForPersistence(writeBatch), "Error while adding changes for persistence")
+	testutil.AssertNoError(t, store.Write(writeBatch, false), "Error while writing to the memory store")
+	snapshot := store.NewSnapshot()
+	defer snapshot.Release()
+	itr, err := stateImplTestWrapper.stateImpl.GetStateSnapshotIterator(snapshot)
+	testutil.AssertNoError(t, err, "Error while getting state snapshot iterator")
+	defer itr.Close()
+	keyValues := make(map[string]string)
+	for itr.Next() {
+		key, value := itr.GetRawKeyValue()
+		keyValues[string(key)] = string(value)
+	}
+	testutil.AssertEquals(t, keyValues, map[string]string{
+		string(statemgmt.ConstructCompositeKey("chaincodeID1", "key1")): "value1",
+		string(statemgmt.ConstructCompositeKey("chaincodeID2", "key2")): "value2",
+	})
+}
@@ -0,0 +1,76 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package buckettree
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/hyperledger/fabric/core/db/kvstore"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+)
+
+// GetRootStateHashDetail - see interface 'statemgmt.StateHashDetailProvider' for details.
+// The children are the buckets at the first level of the tree, the index of a child
+// being the position of the bucket in the root bucket.
+func (stateImpl *StateImpl) GetRootStateHashDetail() (*statemgmt.StateHashDetail, error) {
+	rootBucketNode, err := fetchBucketNodeFromDB(stateImpl.store, constructRootBucketKey())
+	if err != nil {
+		return nil, err
+	}
+	detail := &statemgmt.StateHashDetail{}
+	if rootBucketNode == nil {
+		return detail, nil
+	}
+	detail.RootHash = rootBucketNode.computeCryptoHash()
+	for i, childCryptoHash := range rootBucketNode.childrenCryptoHash {
+		if childCryptoHash != nil {
+			detail.Children = append(detail.Children, &statemgmt.StateHashChild{Index: i, Hash: childCryptoHash})
+		}
+	}
+	return detail, nil
+}
+
+// GetSubtreeSnapshotIterator - see interface 'statemgmt.SubtreeSnapshotProvider' for details.
+// The key-values of a bucket at the first level are those of the range of buckets at the lowest
+// level that descend from it.
+func (stateImpl *StateImpl) GetSubtreeSnapshotIterator(snapshot kvstore.Snapshot, children []int) (statemgmt.StateSnapshotIterator, error) {
+	if conf.getLowestLevel() == 0 {
+		return nil, fmt.Errorf("The bucket tree has a single level")
+	}
+	sortedChildren := append([]int(nil), children...)
+	sort.Ints(sortedChildren)
+	var ranges [][2]int
+	for i, child := range sortedChildren {
+		if child < 0 || child >= conf.getNumBuckets(1) {
+			return nil, fmt.Errorf("Invalid child index [%d]. The root bucket has [%d] children", child, conf.getNumBuckets(1))
+		}
+		if i > 0 && child == sortedChildren[i-1] {
+			continue
+		}
+		first, last := child+1, child+1
+		for level := 1; level < conf.getLowestLevel(); level++ {
+			first = (first-1)*conf.getMaxGroupingAtEachLevel() + 1
+			last = last * conf.getMaxGroupingAtEachLevel()
+			if numBuckets := conf.getNumBuckets(level + 1); last > numBuckets {
+				last = numBuckets
+			}
+		}
+		ranges = append(ranges, [2]int{first, last})
+	}
+	return newSubtreeSnapshotIterator(snapshot, ranges), nil
+}
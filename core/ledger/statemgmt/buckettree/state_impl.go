@@ -18,34 +18,46 @@ package buckettree
 
 import (
 	"bytes"
+	"runtime"
 
-	"github.com/hyperledger/fabric/core/db"
+	"github.com/hyperledger/fabric/core/db/kvstore"
 	"github.com/hyperledger/fabric/core/ledger/statemgmt"
 	"github.com/op/go-logging"
-	"github.com/tecbot/gorocksdb"
 )
 
 var logger = logging.MustGetLogger("buckettree")
 
 // StateImpl - implements the interface - 'statemgmt.HashableState'
 type StateImpl struct {
+	store                  kvstore.KVStore
 	dataNodesDelta         *dataNodesDelta
 	bucketTreeDelta        *bucketTreeDelta
 	persistedStateHash     []byte
 	lastComputedCryptoHash []byte
 	recomputeCryptoHash    bool
 	bucketCache            *bucketCache
+	dataNodeCache          *dataNodeCache
+	numHashWorkers         int
+	bloomFilterBitsPerKey  int
+	bloomFilters           *bloomFilterCache
+	updatedBloomFilters    map[int]*bloomFilter
+	readRepair             *readRepair
 }
 
-// NewStateImpl constructs a new StateImpl
-func NewStateImpl() *StateImpl {
-	return &StateImpl{}
+// NewStateImpl constructs a new StateImpl that persists the tree in the given DB
+func NewStateImpl(store kvstore.KVStore) *StateImpl {
+	return &StateImpl{store: store}
 }
 
 // Initialize - method implementation for interface 'statemgmt.HashableState'
 func (stateImpl *StateImpl) Initialize(configs map[string]interface{}) error {
-	initConfig(configs)
-	rootBucketNode, err := fetchBucketNodeFromDB(constructRootBucketKey())
+	if err := initConfig(configs); err != nil {
+		return err
+	}
+	if err := checkTreeMetadata(stateImpl.store); err != nil {
+		return err
+	}
+	rootBucketNode, err := fetchBucketNodeFromDB(stateImpl.store, constructRootBucketKey())
 	if err != nil {
 		return err
 	}
@@ -58,15 +70,62 @@ func (stateImpl *StateImpl) Initialize(configs map[string]interface{}) error {
 	if !ok {
 		bucketCacheMaxSize = defaultBucketCacheMaxSize
 	}
-	stateImpl.bucketCache = newBucketCache(bucketCacheMaxSize)
+	readRepairCheckInterval, ok := configs[ConfigReadRepairCheckInterval].(int)
+	if !ok {
+		readRepairCheckInterval = 0
+	}
+	stateImpl.readRepair = newReadRepair(readRepairCheckInterval)
+
+	stateImpl.bucketCache = newBucketCache(stateImpl.store, bucketCacheMaxSize)
 	stateImpl.bucketCache.loadAllBucketNodesFromDB()
+
+	dataNodeCacheMaxSize, ok := configs[ConfigDataNodeCacheSize].(int)
+	if !ok {
+		dataNodeCacheMaxSize = defaultDataNodeCacheMaxSize
+	}
+	stateImpl.dataNodeCache = newDataNodeCache(stateImpl.store, dataNodeCacheMaxSize)
+
+	numHashWorkers, ok := configs[ConfigHashWorkers].(int)
+	if !ok || numHashWorkers <= 0 {
+		numHashWorkers = runtime.NumCPU()
+	}
+	stateImpl.numHashWorkers = numHashWorkers
+
+	bloomFilterBitsPerKey, ok := configs[ConfigBloomFilterBitsPerKey].(int)
+	if !ok || bloomFilterBitsPerKey < 0 {
+		bloomFilterBitsPerKey = 0
+	}
+	stateImpl.bloomFilterBitsPerKey = bloomFilterBitsPerKey
+	stateImpl.bloomFilters = newBloomFilterCache(stateImpl.store, stateImpl.readRepair)
 	return nil
 }
 
 // Get - method implementation for interface 'statemgmt.HashableState'
 func (stateImpl *StateImpl) Get(chaincodeID string, key string) ([]byte, error) {
 	dataKey := newDataKey(chaincodeID, key)
-	dataNode, err := fetchDataNodeFromDB(dataKey)
+	generation, check := stateImpl.readRepair.startCheck()
+	if dataNode, ok := stateImpl.dataNodeCache.getDataNode(dataKey); ok {
+		if check {
+			return stateImpl.verifyCachedDataNode(dataKey, dataNode, generation)
+		}
+		if dataNode == nil {
+			return nil, nil
+		}
+		return dataNode.value, nil
+	}
+	if stateImpl.bloomFilterBitsPerKey > 0 {
+		filter, err := stateImpl.bloomFilters.get(dataKey.bucketKey.bucketNumber)
+		if err != nil {
+			return nil, err
+		}
+		if filter != nil && !filter.mayContain(dataKey.compositeKey) {
+			if check {
+				return stateImpl.verifyBloomFilter(dataKey, generation)
+			}
+			return nil, nil
+		}
+	}
+	dataNode, err := fetchDataNodeFromDB(stateImpl.store, dataKey)
 	if err != nil {
 		return nil, err
 	}
@@ -95,11 +154,17 @@ func (stateImpl *StateImpl) ClearWorkingSet(changesPersisted bool) {
 	if changesPersisted {
 		stateImpl.persistedStateHash = stateImpl.lastComputedCryptoHash
 		stateImpl.updateBucketCache()
+		stateImpl.dataNodeCache.update(stateImpl.dataNodesDelta)
+		if stateImpl.updatedBloomFilters != nil {
+			stateImpl.bloomFilters.update(stateImpl.updatedBloomFilters)
+		}
 	} else {
 		stateImpl.lastComputedCryptoHash = stateImpl.persistedStateHash
 	}
+	stateImpl.readRepair.endPersist()
 	stateImpl.dataNodesDelta = nil
 	stateImpl.bucketTreeDelta = nil
+	stateImpl.updatedBloomFilters = nil
 	stateImpl.recomputeCryptoHash = false
 }
 
@@ -126,13 +191,14 @@ func (stateImpl *StateImpl) ComputeCryptoHash() ([]byte, error) {
 
 func (stateImpl *StateImpl) processDataNodeDelta() error {
 	afftectedBuckets := stateImpl.dataNodesDelta.getAffectedBuckets()
-	for _, bucketKey := range afftectedBuckets {
-		updatedDataNodes := stateImpl.dataNodesDelta.getSortedDataNodesFor(bucketKey)
-		existingDataNodes, err := fetchDataNodesFromDBFor(bucketKey)
-		if err != nil {
-			return err
-		}
-		cryptoHashForBucket := computeDataNodesCryptoHash(bucketKey, updatedDataNodes, existingDataNodes)
+	cryptoHashes, bloomFilters, err := stateImpl.computeBucketsCryptoHash(afftectedBuckets)
+	if err != nil {
+		return err
+	}
+	stateImpl.updatedBloomFilters = make(map[int]*bloomFilter)
+	for i, bucketKey := range afftectedBuckets {
+		stateImpl.updatedBloomFilters[bucketKey.bucketNumber] = bloomFilters[i]
+		cryptoHashForBucket := cryptoHashes[i]
 		logger.Debug("Crypto-hash for lowest-level bucket [%s] is [%x]", bucketKey, cryptoHashForBucket)
 		parentBucket := stateImpl.bucketTreeDelta.getOrCreateBucketNode(bucketKey.getParentKey())
 		parentBucket.setChildCryptoHash(bucketKey, cryptoHashForBucket)
@@ -152,6 +218,11 @@ func (stateImpl *StateImpl) processBucketTreeDelta() error {
 			if err != nil {
 				return err
 			}
+			if _, check := stateImpl.readRepair.startCheck(); check && stateImpl.bucketCache.isEnabled {
+				if dbBucketNode, err = stateImpl.verifyCachedBucketNode(bucketNode.bucketKey, dbBucketNode); err != nil {
+					return err
+				}
+			}
 			if dbBucketNode != nil {
 				bucketNode.mergeBucketNode(dbBucketNode)
 				logger.Debug("After merge... bucketNode in tree-delta [%s]", bucketNode)
@@ -216,7 +287,7 @@ func computeDataNodesCryptoHash(bucketKey *bucketKey, updatedNodes dataNodes, ex
 }
 
 // AddChangesForPersistence - method implementation for interface 'statemgmt.HashableState'
-func (stateImpl *StateImpl) AddChangesForPersistence(writeBatch *gorocksdb.WriteBatch) error {
+func (stateImpl *StateImpl) AddChangesForPersistence(writeBatch kvstore.WriteBatch) error {
 
 	if stateImpl.dataNodesDelta == nil {
 		return nil
@@ -230,36 +301,46 @@ func (stateImpl *StateImpl) AddChangesForPersistence(writeBatch *gorocksdb.Write
 	}
 	stateImpl.addDataNodeChangesForPersistence(writeBatch)
 	stateImpl.addBucketNodeChangesForPersistence(writeBatch)
+	stateImpl.addBloomFilterChangesForPersistence(writeBatch)
+	stateImpl.readRepair.beginPersist()
 	return nil
 }
 
-func (stateImpl *StateImpl) addDataNodeChangesForPersistence(writeBatch *gorocksdb.WriteBatch) {
-	openchainDB := db.GetDBHandle()
+func (stateImpl *StateImpl) addBloomFilterChangesForPersistence(writeBatch kvstore.WriteBatch) {
+	for bucketNumber, filter := range stateImpl.updatedBloomFilters {
+		if filter == nil {
+			writeBatch.Delete(kvstore.StateCFName, encodeBloomFilterKey(bucketNumber))
+		} else {
+			writeBatch.Put(kvstore.StateCFName, encodeBloomFilterKey(bucketNumber), filter.marshal())
+		}
+	}
+}
+
+func (stateImpl *StateImpl) addDataNodeChangesForPersistence(writeBatch kvstore.WriteBatch) {
 	affectedBuckets := stateImpl.dataNodesDelta.getAffectedBuckets()
 	for _, affectedBucket := range affectedBuckets {
 		dataNodes := stateImpl.dataNodesDelta.getSortedDataNodesFor(affectedBucket)
 		for _, dataNode := range dataNodes {
 			if dataNode.isDelete() {
 				logger.Debug("Deleting data node key = %#v", dataNode.dataKey)
-				writeBatch.DeleteCF(openchainDB.StateCF, dataNode.dataKey.getEncodedBytes())
+				writeBatch.Delete(kvstore.StateCFName, dataNode.dataKey.getEncodedBytes())
 			} else {
 				logger.Debug("Adding data node with value = %#v", dataNode.value)
-				writeBatch.PutCF(openchainDB.StateCF, dataNode.dataKey.getEncodedBytes(), dataNode.value)
+				writeBatch.Put(kvstore.StateCFName, dataNode.dataKey.getEncodedBytes(), dataNode.value)
 			}
 		}
 	}
 }
 
-func (stateImpl *StateImpl) addBucketNodeChangesForPersistence(writeBatch *gorocksdb.WriteBatch) {
-	openchainDB := db.GetDBHandle()
+func (stateImpl *StateImpl) addBucketNodeChangesForPersistence(writeBatch kvstore.WriteBatch) {
 	secondLastLevel := conf.getLowestLevel() - 1
 	for level := secondLastLevel; level >= 0; level-- {
 		bucketNodes := stateImpl.bucketTreeDelta.getBucketNodesAt(level)
 		for _, bucketNode := range bucketNodes {
 			if bucketNode.markedForDeletion {
-				writeBatch.DeleteCF(openchainDB.StateCF, bucketNode.bucketKey.getEncodedBytes())
+				writeBatch.Delete(kvstore.StateCFName, bucketNode.bucketKey.getEncodedBytes())
 			} else {
-				writeBatch.PutCF(openchainDB.StateCF, bucketNode.bucketKey.getEncodedBytes(), bucketNode.marshal())
+				writeBatch.Put(kvstore.StateCFName, bucketNode.bucketKey.getEncodedBytes(), bucketNode.marshal())
 			}
 		}
 	}
@@ -292,11 +373,16 @@ func (stateImpl *StateImpl) PerfHintKeyChanged(chaincodeID string, key string) {
 }
 
 // GetStateSnapshotIterator - method implementation for interface 'statemgmt.HashableState'
-func (stateImpl *StateImpl) GetStateSnapshotIterator(snapshot *gorocksdb.Snapshot) (statemgmt.StateSnapshotIterator, error) {
+func (stateImpl *StateImpl) GetStateSnapshotIterator(snapshot kvstore.Snapshot) (statemgmt.StateSnapshotIterator, error) {
 	return newStateSnapshotIterator(snapshot)
 }
 
 // GetRangeScanIterator - method implementation for interface 'statemgmt.HashableState'
 func (stateImpl *StateImpl) GetRangeScanIterator(chaincodeID string, startKey string, endKey string) (statemgmt.RangeScanIterator, error) {
-	return newRangeScanIterator(chaincodeID, startKey, endKey)
+	return newRangeScanIterator(stateImpl.store, chaincodeID, startKey, endKey)
+}
+
+// GetRangeScanIteratorFromSnapshot - method implementation for interface 'statemgmt.SnapshotRangeScanProvider'
+func (stateImpl *StateImpl) GetRangeScanIteratorFromSnapshot(snapshot kvstore.Snapshot, chaincodeID string, startKey string, endKey string) (statemgmt.RangeScanIterator, error) {
+	return newRangeScanIterator(snapshot, chaincodeID, startKey, endKey)
 }
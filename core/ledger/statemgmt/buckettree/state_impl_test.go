@@ -17,10 +17,13 @@ limitations under the License.
 package buckettree
 
 import (
+	"fmt"
 	"testing"
 
+	"github.com/hyperledger/fabric/core/db"
 	"github.com/hyperledger/fabric/core/ledger/statemgmt"
 	"github.com/hyperledger/fabric/core/ledger/testutil"
+	"github.com/hyperledger/fabric/core/ledger/verify"
 )
 
 func TestStateImpl_ComputeHash_AllInMemory_NoContents(t *testing.T) {
@@ -345,27 +348,27 @@ func TestStateImpl_DB_Changes(t *testing.T) {
 	testutil.AssertEquals(t, stateImplTestWrapper.get("chaincodeID2", "key1"), []byte("value3"))
 
 	// fetch datanode from DB
-	dataNodeFromDB, _ := fetchDataNodeFromDB(newDataKey("chaincodeID2", "key1"))
+	dataNodeFromDB, _ := fetchDataNodeFromDB(db.GetDBHandle().KVStore(), newDataKey("chaincodeID2", "key1"))
 	testutil.AssertEquals(t, dataNodeFromDB, newDataNode(newDataKey("chaincodeID2", "key1"), []byte("value3")))
 
 	//fetch non-existing data node from DB
-	dataNodeFromDB, _ = fetchDataNodeFromDB(newDataKey("chaincodeID10", "key10"))
+	dataNodeFromDB, _ = fetchDataNodeFromDB(db.GetDBHandle().KVStore(), newDataKey("chaincodeID10", "key10"))
 	t.Logf("isNIL...[%t]", dataNodeFromDB == nil)
 	testutil.AssertNil(t, dataNodeFromDB)
 
 	// fetch all data nodes from db that belong to bucket 1 at lowest level
-	dataNodesFromDB, _ := fetchDataNodesFromDBFor(newBucketKeyAtLowestLevel(1))
+	dataNodesFromDB, _ := fetchDataNodesFromDBFor(db.GetDBHandle().KVStore(), newBucketKeyAtLowestLevel(1))
 	testutil.AssertContainsAll(t, dataNodesFromDB,
 		dataNodes{newDataNode(newDataKey("chaincodeID1", "key1"), []byte("value1")),
 			newDataNode(newDataKey("chaincodeID1", "key2"), []byte("value2"))})
 
 	// fetch all data nodes from db that belong to bucket 2 at lowest level
-	dataNodesFromDB, _ = fetchDataNodesFromDBFor(newBucketKeyAtLowestLevel(2))
+	dataNodesFromDB, _ = fetchDataNodesFromDBFor(db.GetDBHandle().KVStore(), newBucketKeyAtLowestLevel(2))
 	testutil.AssertContainsAll(t, dataNodesFromDB,
 		dataNodes{newDataNode(newDataKey("chaincodeID2", "key1"), []byte("value3"))})
 
 	// fetch first bucket at second level
-	bucketNodeFromDB, _ := fetchBucketNodeFromDB(newBucketKey(2, 1))
+	bucketNodeFromDB, _ := fetchBucketNodeFromDB(db.GetDBHandle().KVStore(), newBucketKey(2, 1))
 	testutil.AssertEquals(t, bucketNodeFromDB.bucketKey, newBucketKey(2, 1))
 	//check childrenCryptoHash entries in the bucket node from DB
 	testutil.AssertEquals(t, bucketNodeFromDB.childrenCryptoHash[0],
@@ -377,7 +380,7 @@ func TestStateImpl_DB_Changes(t *testing.T) {
 	testutil.AssertNil(t, bucketNodeFromDB.childrenCryptoHash[2])
 
 	// third bucket at second level should be nil
-	bucketNodeFromDB, _ = fetchBucketNodeFromDB(newBucketKey(2, 3))
+	bucketNodeFromDB, _ = fetchBucketNodeFromDB(db.GetDBHandle().KVStore(), newBucketKey(2, 3))
 	testutil.AssertNil(t, bucketNodeFromDB)
 }
 
@@ -398,3 +401,74 @@ func TestStateImpl_DB_EmptyArrayValues(t *testing.T) {
 		t.Fatalf("Expected a nil. found = %#v", nilVal)
 	}
 }
+
+func TestStateImpl_ComputeHash_ParallelWorkers(t *testing.T) {
+	stateDelta := statemgmt.NewStateDelta()
+	for i := 0; i < 100; i++ {
+		stateDelta.Set(fmt.Sprintf("chaincodeID%d", i%7), fmt.Sprintf("key%d", i), []byte(fmt.Sprintf("value%d", i)), nil)
+	}
+	var hashes [][]byte
+	for _, numWorkers := range []int{1, 4, 16} {
+		testDBWrapper.CreateFreshDB(t)
+		configMap := map[string]interface{}{ConfigNumBuckets: 100, ConfigMaxGroupingAtEachLevel: 5, ConfigHashWorkers: numWorkers}
+		stateImpl := NewStateImpl(db.GetDBHandle().KVStore())
+		testutil.AssertNoError(t, stateImpl.Initialize(configMap), "Error while constructing stateImpl")
+		testutil.AssertEquals(t, stateImpl.numHashWorkers, numWorkers)
+		stateImplTestWrapper := &stateImplTestWrapper{configMap, stateImpl, t}
+		hashes = append(hashes, stateImplTestWrapper.prepareWorkingSetAndComputeCryptoHash(stateDelta))
+		stateImplTestWrapper.persistChangesAndResetInMemoryChanges()
+
+		// update some of the keys already present in the DB
+		updateDelta := statemgmt.NewStateDelta()
+		updateDelta.Set("chaincodeID1", "key1", []byte("newValue1"), nil)
+		updateDelta.Delete("chaincodeID2", "key2", nil)
+		hashes = append(hashes, stateImplTestWrapper.prepareWorkingSetAndComputeCryptoHash(updateDelta))
+	}
+	for i := 2; i < len(hashes); i++ {
+		testutil.AssertEquals(t, hashes[i], hashes[i%2])
+	}
+}
+
+func TestStateImplHashMatchesVerify(t *testing.T) {
+	for _, conf := range []*verify.BucketTreeConfig{
+		{NumBuckets: 5, MaxGroupingAtEachLevel: 2},
+		{NumBuckets: 26, MaxGroupingAtEachLevel: 3, BucketHashFunction: "crc32"},
+		{NumBuckets: DefaultNumBuckets, MaxGroupingAtEachLevel: DefaultMaxGroupingAtEachLevel, BucketHashFunction: "fnv32"},
+	} {
+		testDBWrapper.CreateFreshDB(t)
+		configs := map[string]interface{}{ConfigNumBuckets: conf.NumBuckets, ConfigMaxGroupingAtEachLevel: conf.MaxGroupingAtEachLevel}
+		if conf.BucketHashFunction != "" {
+			configs[ConfigBucketHashFunction] = conf.BucketHashFunction
+		}
+		stateImplTestWrapper := newStateImplTestWrapperWithCustomConfigMap(t, configs)
+		state := make(map[string]*verify.KeyValue)
+		stateDelta := statemgmt.NewStateDelta()
+		for i := 0; i < 50; i++ {
+			chaincodeID := fmt.Sprintf("chaincodeID%d", i%3)
+			key := fmt.Sprintf("key%d", i)
+			stateDelta.Set(chaincodeID, key, []byte(fmt.Sprintf("value%d", i)), nil)
+			state[chaincodeID+key] = &verify.KeyValue{ChaincodeID: chaincodeID, Key: key, Value: []byte(fmt.Sprintf("value%d", i))}
+		}
+		stateImplTestWrapper.prepareWorkingSetAndComputeCryptoHash(stateDelta)
+		stateImplTestWrapper.persistChangesAndResetInMemoryChanges()
+
+		stateDelta = statemgmt.NewStateDelta()
+		for i := 0; i < 50; i += 4 {
+			chaincodeID := fmt.Sprintf("chaincodeID%d", i%3)
+			key := fmt.Sprintf("key%d", i)
+			stateDelta.Delete(chaincodeID, key, nil)
+			delete(state, chaincodeID+key)
+		}
+		stateDelta.Set("chaincodeID1", "key1", []byte("newValue"), nil)
+		state["chaincodeID1key1"].Value = []byte("newValue")
+		hash := stateImplTestWrapper.prepareWorkingSetAndComputeCryptoHash(stateDelta)
+
+		var keyValues []*verify.KeyValue
+		for _, kv := range state {
+			keyValues = append(keyValues, kv)
+		}
+		expectedHash, err := verify.ComputeBucketTreeStateHash(statemgmt.ComputeCryptoHash, conf, keyValues)
+		testutil.AssertNoError(t, err, "Error computing the state hash")
+		testutil.AssertEquals(t, hash, expectedHash)
+	}
+}
@@ -0,0 +1,114 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package buckettree
+
+import (
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/db/kvstore"
+)
+
+// treeMetadataKey is the key, in the persistCF, under which the configuration that
+// determines the shape of the tree is stored. Unlike the stateCF, the persistCF is not
+// dropped along with the state (e.g., during state transfer).
+var treeMetadataKey = []byte("buckettree.metadata")
+
+const treeMetadataVersion = 1
+
+// treeMetadata captures the configurations that cannot be changed once the tree has been
+// built because these decide the bucket (and hence the hash) of every key
+type treeMetadata struct {
+	numBuckets             int
+	maxGroupingAtEachLevel int
+	hashFuncName           string
+}
+
+func newTreeMetadataFromConfig() *treeMetadata {
+	return &treeMetadata{conf.getNumBucketsAtLowestLevel(), conf.getMaxGroupingAtEachLevel(), conf.hashFuncName}
+}
+
+func (metadata *treeMetadata) marshal() []byte {
+	buffer := proto.NewBuffer([]byte{})
+	buffer.EncodeVarint(treeMetadataVersion)
+	buffer.EncodeVarint(uint64(metadata.numBuckets))
+	buffer.EncodeVarint(uint64(metadata.maxGroupingAtEachLevel))
+	buffer.EncodeStringBytes(metadata.hashFuncName)
+	return buffer.Bytes()
+}
+
+func unmarshalTreeMetadata(metadataBytes []byte) (*treeMetadata, error) {
+	buffer := proto.NewBuffer(metadataBytes)
+	version, err := buffer.DecodeVarint()
+	if err != nil {
+		return nil, err
+	}
+	if version != treeMetadataVersion {
+		return nil, fmt.Errorf("Unsupported version [%d] of bucket tree metadata", version)
+	}
+	numBuckets, err := buffer.DecodeVarint()
+	if err != nil {
+		return nil, err
+	}
+	maxGroupingAtEachLevel, err := buffer.DecodeVarint()
+	if err != nil {
+		return nil, err
+	}
+	hashFuncName, err := buffer.DecodeStringBytes()
+	if err != nil {
+		return nil, err
+	}
+	return &treeMetadata{int(numBuckets), int(maxGroupingAtEachLevel), hashFuncName}, nil
+}
+
+func (metadata *treeMetadata) String() string {
+	return fmt.Sprintf("%s=%d, %s=%d, %s=%s", ConfigNumBuckets, metadata.numBuckets,
+		ConfigMaxGroupingAtEachLevel, metadata.maxGroupingAtEachLevel, ConfigBucketHashFunction, metadata.hashFuncName)
+}
+
+// checkTreeMetadata compares the current configuration with the one persisted when the
+// tree was built and returns an error on mismatch. If no metadata is persisted yet, the
+// current configuration is persisted. For a tree built before the metadata was introduced,
+// the current configuration is assumed to be the one that has been used for building the tree.
+// This has to be invoked before reading any bucket node because the encoding of the bucket
+// nodes depends on the configuration
+func checkTreeMetadata(store kvstore.KVStore) error {
+	configured := newTreeMetadataFromConfig()
+	metadataBytes, err := store.Get(kvstore.PersistCFName, treeMetadataKey)
+	if err != nil {
+		return err
+	}
+	if metadataBytes == nil {
+		rootBucketNodeBytes, err := store.Get(kvstore.StateCFName, constructRootBucketKey().getEncodedBytes())
+		if err != nil {
+			return err
+		}
+		if rootBucketNodeBytes != nil {
+			logger.Warning("No metadata found for the existing bucket tree. Assuming the tree was built with configurations [%s]", configured)
+		}
+		return kvstore.Put(store, kvstore.PersistCFName, treeMetadataKey, configured.marshal())
+	}
+	persisted, err := unmarshalTreeMetadata(metadataBytes)
+	if err != nil {
+		return fmt.Errorf("Error reading bucket tree metadata: %s", err)
+	}
+	if *persisted != *configured {
+		return fmt.Errorf("Bucket tree configurations [%s] do not match the configurations [%s] the existing tree has been built with. "+
+			"These configurations cannot be changed once the DB has been created", configured, persisted)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package buckettree
+
+import (
+	"github.com/hyperledger/fabric/core/db/kvstore"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+)
+
+// GetTreeStats - see interface 'statemgmt.TreeStatsProvider' for details
+func (stateImpl *StateImpl) GetTreeStats(snapshot kvstore.Snapshot) (*statemgmt.TreeStats, error) {
+	itr := snapshot.NewIterator(kvstore.StateCFName)
+	defer itr.Close()
+	keysInBucket := make(map[int]int)
+	// bucket nodes are stored with the prefix 0x00 followed by the data nodes (see newStateSnapshotIterator)
+	for itr.Seek([]byte{0x01}); itr.Valid() && isDataNodeKey(itr.Key()); itr.Next() {
+		bucketNumber, _ := decodeBucketNumber(itr.Key())
+		keysInBucket[bucketNumber]++
+	}
+	stats := &statemgmt.TreeStats{
+		Depth:           conf.getLowestLevel() + 1,
+		NumBuckets:      conf.getNumBucketsAtLowestLevel(),
+		OccupiedBuckets: len(keysInBucket)}
+	for _, numKeys := range keysInBucket {
+		if numKeys > stats.MaxKeysInBucket {
+			stats.MaxKeysInBucket = numKeys
+		}
+	}
+	return stats, nil
+}
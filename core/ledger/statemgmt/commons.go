@@ -18,7 +18,9 @@ package statemgmt
 
 import (
 	"bytes"
+	"strings"
 
+	"github.com/hyperledger/fabric/core/ledger/verify"
 	"github.com/op/go-logging"
 )
 
@@ -26,11 +28,32 @@ var logger = logging.MustGetLogger("statemgmt")
 
 var stateKeyDelimiter = []byte{0x00}
 
+// SystemNamespacePrefix is the prefix of the chaincodeIDs reserved for the records
+// that the ledger keeps for itself in the world state (e.g., feature flags,
+// savepoints, migration status). Chaincodes cannot write into these namespaces.
+//
+// System namespace hashing, version 1 (SystemNamespaceHashVersion): the records
+// of the system namespaces are part of the StateDelta of a block and are hashed
+// by the configured HashableState in the same way as the records of chaincodes.
+// This makes every peer agree on them through the state hash. A change to this
+// rule has to be introduced as a new version that is activated network-wide at
+// a given block, like any other ledger feature.
+const SystemNamespacePrefix = "_sys"
+
+// SystemNamespaceHashVersion is the version of the rule, described above, that
+// defines how the system namespaces contribute to the state hash
+const SystemNamespaceHashVersion = 1
+
+// IsSystemNamespace returns true if the chaincodeID is reserved for ledger-internal records
+func IsSystemNamespace(chaincodeID string) bool {
+	return strings.HasPrefix(chaincodeID, SystemNamespacePrefix)
+}
+
 // ConstructCompositeKey returns a []byte that uniquely represents a given chaincodeID and key.
 // This assumes that chaincodeID does not contain a 0x00 byte, but the key may
 // TODO:enforce this restriction on chaincodeID or use length prefixing here instead of delimiter
 func ConstructCompositeKey(chaincodeID string, key string) []byte {
-	return bytes.Join([][]byte{[]byte(chaincodeID), []byte(key)}, stateKeyDelimiter)
+	return verify.ConstructCompositeKey(chaincodeID, key)
 }
 
 // DecodeCompositeKey decodes the compositeKey constructed by ConstructCompositeKey method
@@ -0,0 +1,109 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statemgmt
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/hyperledger/fabric/core/ledger/verify"
+)
+
+// HashProvider computes the crypto-hashes used by the state management, i.e., the
+// hashes of the nodes of the bucket tree/trie, of the tx state deltas, and, as a
+// result, the state hash recorded in the blocks. All the peers of a network must
+// use the same HashProvider, which is why the genesis block records its name.
+type HashProvider interface {
+	// Name returns the name under which the provider is configured in core.yaml
+	Name() string
+	// ComputeHash returns the crypto-hash of data
+	ComputeHash(data []byte) []byte
+}
+
+// DefaultHashAlgorithm is the hash algorithm used when none is configured. This is
+// the algorithm used by the ledgers created before the algorithm became configurable.
+const DefaultHashAlgorithm = verify.DefaultHashAlgorithm
+
+var hashProvidersLock sync.RWMutex
+var hashProviders = make(map[string]HashProvider)
+var hashProvider HashProvider
+
+func init() {
+	for _, name := range verify.HashAlgorithms() {
+		compute, _ := verify.GetHashFunc(name)
+		RegisterHashProvider(hashFunc{name, compute})
+	}
+	hashProvider = hashProviders[DefaultHashAlgorithm]
+}
+
+// RegisterHashProvider makes a HashProvider available under its name
+func RegisterHashProvider(provider HashProvider) {
+	hashProvidersLock.Lock()
+	defer hashProvidersLock.Unlock()
+	hashProviders[provider.Name()] = provider
+}
+
+// GetHashProvider returns the HashProvider registered with the given name
+func GetHashProvider(name string) (HashProvider, error) {
+	hashProvidersLock.RLock()
+	defer hashProvidersLock.RUnlock()
+	provider, ok := hashProviders[name]
+	if !ok {
+		var names []string
+		for n := range hashProviders {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("Hash algorithm [%s] is not supported. Supported algorithms are %v", name, names)
+	}
+	return provider, nil
+}
+
+// SetHashProvider sets the HashProvider used by ComputeCryptoHash. This is expected to be
+// invoked once, while initializing the state and before any hash is computed.
+func SetHashProvider(provider HashProvider) {
+	hashProvidersLock.Lock()
+	defer hashProvidersLock.Unlock()
+	logger.Info("Using hash algorithm [%s] for state hashing", provider.Name())
+	hashProvider = provider
+}
+
+// GetActiveHashProvider returns the HashProvider used by ComputeCryptoHash
+func GetActiveHashProvider() HashProvider {
+	hashProvidersLock.RLock()
+	defer hashProvidersLock.RUnlock()
+	return hashProvider
+}
+
+// ComputeCryptoHash computes the crypto-hash of data with the active HashProvider
+func ComputeCryptoHash(data []byte) []byte {
+	return GetActiveHashProvider().ComputeHash(data)
+}
+
+type hashFunc struct {
+	name    string
+	compute verify.HashFunc
+}
+
+func (h hashFunc) Name() string {
+	return h.name
+}
+
+func (h hashFunc) ComputeHash(data []byte) []byte {
+	return h.compute(data)
+}
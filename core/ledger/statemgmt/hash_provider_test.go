@@ -0,0 +1,55 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statemgmt
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/ledger/testutil"
+	"github.com/hyperledger/fabric/core/util"
+)
+
+func TestHashProviders(t *testing.T) {
+	expectedHashes := map[string]string{
+		"SHA2_256":    "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad",
+		"SHA3_256":    "3a985da74fe225b2045c172d6bd390bd855f086e3e9d525b46bfe24511431532",
+		"BLAKE2B_256": "bddd813c634239723171ef3fee98579b94964e3bb1cb3e427262c8c068d52319",
+	}
+	for name, expectedHash := range expectedHashes {
+		provider, err := GetHashProvider(name)
+		testutil.AssertNoError(t, err, "Error getting hash provider")
+		testutil.AssertEquals(t, provider.Name(), name)
+		testutil.AssertEquals(t, hex.EncodeToString(provider.ComputeHash([]byte("abc"))), expectedHash)
+	}
+	_, err := GetHashProvider("MD5")
+	testutil.AssertError(t, err, "Expected an error for an unsupported algorithm")
+}
+
+func TestComputeCryptoHashUsesActiveProvider(t *testing.T) {
+	testutil.AssertEquals(t, GetActiveHashProvider().Name(), DefaultHashAlgorithm)
+	testutil.AssertEquals(t, ComputeCryptoHash([]byte("abc")), util.ComputeCryptoHash([]byte("abc")))
+
+	sha2, _ := GetHashProvider("SHA2_256")
+	SetHashProvider(sha2)
+	defer SetHashProvider(hashProviders[DefaultHashAlgorithm])
+	testutil.AssertEquals(t, ComputeCryptoHash([]byte("abc")), sha2.ComputeHash([]byte("abc")))
+
+	stateDelta := NewStateDelta()
+	stateDelta.Set("chaincode1", "key1", []byte("value1"), nil)
+	testutil.AssertEquals(t, stateDelta.ComputeCryptoHash(), sha2.ComputeHash([]byte("chaincode1key1value1")))
+}
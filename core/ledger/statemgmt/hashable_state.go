@@ -17,7 +17,8 @@ limitations under the License.
 package statemgmt
 
 import (
-	"github.com/tecbot/gorocksdb"
+	"github.com/hyperledger/fabric/core/db/kvstore"
+	"github.com/hyperledger/fabric/core/errors"
 )
 
 // HashableState - Interface that is be implemented by state management
@@ -42,7 +43,7 @@ type HashableState interface {
 	// to persist for committing the  stateDelta (passed in PrepareWorkingSet method) to DB.
 	// In addition to the information in the StateDelta, the implementation may also want to
 	// persist intermediate results for faster crypto-hash computation
-	AddChangesForPersistence(writeBatch *gorocksdb.WriteBatch) error
+	AddChangesForPersistence(writeBatch kvstore.WriteBatch) error
 
 	// ClearWorkingSet state implementation may clear any data structures that it may have constructed
 	// for computing cryptoHash and persisting the changes for the stateDelta (passed in PrepareWorkingSet method)
@@ -52,7 +53,7 @@ type HashableState interface {
 	// All the key-value of global state. A particular implementation may need to remove additional information
 	// that the implementation keeps for faster crypto-hash computation. For instance, filter a few of the
 	// key-values or remove some data from particular key-values.
-	GetStateSnapshotIterator(snapshot *gorocksdb.Snapshot) (StateSnapshotIterator, error)
+	GetStateSnapshotIterator(snapshot kvstore.Snapshot) (StateSnapshotIterator, error)
 
 	// GetRangeScanIterator - state implementation to provide an iterator that is supposed to give
 	// All the key-values for a given chaincodeID such that a return key should be lexically greater than or
@@ -68,6 +69,15 @@ type HashableState interface {
 	PerfHintKeyChanged(chaincodeID string, key string)
 }
 
+// SnapshotRangeScanProvider - is to be implemented by the state implementations that can give
+// the range scan of GetRangeScanIterator as of a DB snapshot, besides the interface 'HashableState'
+type SnapshotRangeScanProvider interface {
+
+	// GetRangeScanIteratorFromSnapshot returns an iterator over the key-values of the chaincodeID
+	// in the range of keys as held by the snapshot
+	GetRangeScanIteratorFromSnapshot(snapshot kvstore.Snapshot, chaincodeID string, startKey string, endKey string) (RangeScanIterator, error)
+}
+
 // StateSnapshotIterator An interface that is to be implemented by the return value of
 // GetStateSnapshotIterator method in the implementation of HashableState interface
 type StateSnapshotIterator interface {
@@ -95,3 +105,15 @@ type RangeScanIterator interface {
 	// Close releases resources occupied by the iterator
 	Close()
 }
+
+// ErrQueryNotSupported is returned for a rich query when the state implementation is not a QueryableState
+var ErrQueryNotSupported = errors.New(errors.NotSupported, "Rich queries are not supported by the state implementation")
+
+// QueryableState - is to be implemented by the state implementations that support rich queries
+// of the values of a chaincode (e.g., a document store), besides the interface 'HashableState'
+type QueryableState interface {
+
+	// GetQueryResultIterator returns an iterator over the key-values of the chaincode matching the query.
+	// The syntax of the query is defined by the state implementation
+	GetQueryResultIterator(chaincodeID string, query string) (RangeScanIterator, error)
+}
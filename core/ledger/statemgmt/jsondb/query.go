@@ -0,0 +1,277 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jsondb
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+
+	"github.com/hyperledger/fabric/core/db/kvstore"
+	"github.com/hyperledger/fabric/core/errors"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+)
+
+// query is a rich query of the documents of a chaincode. It is either a selector or a
+// JSON object with the fields
+//   - selector: the selector of the documents,
+//   - sort: an array of the paths of the fields the results are sorted by, in ascending
+//     order, or of objects {"<path>": "asc"} or {"<path>": "desc"}; the documents missing
+//     a field come first. The results are in the order of the keys otherwise,
+//   - skip: the number of results skipped,
+//   - limit: the maximum number of results, all of them if 0.
+type query struct {
+	selector selector
+	sort     []sortField
+	skip     int
+	limit    int
+}
+
+type sortField struct {
+	path       []string
+	descending bool
+}
+
+func invalidQuery(format string, args ...interface{}) error {
+	return errors.Errorf(errors.InvalidArgument, "Invalid query: "+format, args...)
+}
+
+// parseQuery parses a query or a selector
+func parseQuery(queryString string) (*query, error) {
+	var value interface{}
+	if err := json.Unmarshal([]byte(queryString), &value); err != nil {
+		return nil, invalidQuery("%s", err)
+	}
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, invalidQuery("a query must be a JSON object")
+	}
+	selectorValue, ok := object["selector"]
+	if !ok {
+		s, err := parseSelector(object)
+		if err != nil {
+			return nil, err
+		}
+		return &query{selector: s}, nil
+	}
+	q := &query{}
+	var err error
+	if q.selector, err = parseSelector(selectorValue); err != nil {
+		return nil, err
+	}
+	for name, arg := range object {
+		switch name {
+		case "selector":
+		case "sort":
+			q.sort, err = parseSort(arg)
+		case "skip":
+			q.skip, err = parseCount(name, arg)
+		case "limit":
+			q.limit, err = parseCount(name, arg)
+		default:
+			err = invalidQuery("unknown field %s", name)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return q, nil
+}
+
+func parseCount(name string, arg interface{}) (int, error) {
+	count, ok := arg.(float64)
+	if !ok || count < 0 || count != float64(int(count)) {
+		return 0, invalidQuery("%s must be a non negative integer", name)
+	}
+	return int(count), nil
+}
+
+func parseSort(arg interface{}) ([]sortField, error) {
+	array, ok := arg.([]interface{})
+	if !ok {
+		return nil, invalidQuery("sort must be an array")
+	}
+	fields := make([]sortField, len(array))
+	for i, value := range array {
+		switch v := value.(type) {
+		case string:
+			fields[i] = sortField{path: parseFieldPath(v)}
+		case map[string]interface{}:
+			if len(v) != 1 {
+				return nil, invalidQuery("a sort field must be an object with a single field")
+			}
+			for path, direction := range v {
+				if direction != "asc" && direction != "desc" {
+					return nil, invalidQuery("the direction of sort field %s must be asc or desc", path)
+				}
+				fields[i] = sortField{parseFieldPath(path), direction == "desc"}
+			}
+		default:
+			return nil, invalidQuery("a sort field must be a path or an object")
+		}
+	}
+	return fields, nil
+}
+
+// less tells whether the document a comes before b in the order of the sort fields
+func (q *query) less(a, b interface{}) bool {
+	for _, field := range q.sort {
+		valueA, presentA := lookupField(a, field.path)
+		valueB, presentB := lookupField(b, field.path)
+		var cmp int
+		switch {
+		case presentA && presentB:
+			cmp = collate(valueA, valueB)
+		case presentA:
+			cmp = 1
+		case presentB:
+			cmp = -1
+		}
+		if field.descending {
+			cmp = -cmp
+		}
+		if cmp != 0 {
+			return cmp < 0
+		}
+	}
+	return false
+}
+
+// queryResultIterator iterates over the documents of a chaincode matching a query without
+// sort fields, in the order of the keys
+type queryResultIterator struct {
+	dbItr    kvstore.Iterator
+	prefix   []byte
+	query    *query
+	skipped  int
+	returned int
+	key      string
+	value    []byte
+	started  bool
+}
+
+func newQueryResultIterator(store kvstore.KVStore, chaincodeID string, q *query) *queryResultIterator {
+	prefix := encodeDocumentKey(chaincodeID, "")
+	return &queryResultIterator{dbItr: store.NewIterator(kvstore.DocumentsCFName), prefix: prefix, query: q}
+}
+
+// Next - see interface 'statemgmt.RangeScanIterator' for details
+func (itr *queryResultIterator) Next() bool {
+	if itr.query.limit > 0 && itr.returned >= itr.query.limit {
+		return false
+	}
+	if itr.started {
+		itr.dbItr.Next()
+	} else {
+		itr.dbItr.Seek(itr.prefix)
+		itr.started = true
+	}
+	for ; itr.dbItr.Valid() && bytes.HasPrefix(itr.dbItr.Key(), itr.prefix); itr.dbItr.Next() {
+		doc, ok := decodeDocument(itr.dbItr.Value())
+		if !ok || !itr.query.selector.matches(doc) {
+			continue
+		}
+		if itr.skipped < itr.query.skip {
+			itr.skipped++
+			continue
+		}
+		_, itr.key = decodeDocumentKey(itr.dbItr.Key())
+		itr.value = itr.dbItr.Value()
+		itr.returned++
+		return true
+	}
+	return false
+}
+
+// GetKeyValue - see interface 'statemgmt.RangeScanIterator' for details
+func (itr *queryResultIterator) GetKeyValue() (string, []byte) {
+	return itr.key, itr.value
+}
+
+// Close - see interface 'statemgmt.RangeScanIterator' for details
+func (itr *queryResultIterator) Close() {
+	itr.dbItr.Close()
+}
+
+// sortedQueryResults returns the documents of the chaincode matching a query with sort
+// fields, which are all read before the first one is returned
+func sortedQueryResults(store kvstore.KVStore, chaincodeID string, q *query) (statemgmt.RangeScanIterator, error) {
+	unsorted := &query{selector: q.selector}
+	itr := newQueryResultIterator(store, chaincodeID, unsorted)
+	defer itr.Close()
+	var results []*queryResult
+	for itr.Next() {
+		key, value := itr.GetKeyValue()
+		doc, _ := decodeDocument(value)
+		results = append(results, &queryResult{key, value, doc})
+	}
+	if err := itr.dbItr.Err(); err != nil {
+		return nil, err
+	}
+	sort.Stable(&queryResultSorter{results, q})
+	if q.skip >= len(results) {
+		results = nil
+	} else {
+		results = results[q.skip:]
+	}
+	if q.limit > 0 && len(results) > q.limit {
+		results = results[:q.limit]
+	}
+	return &queryResultSliceIterator{results: results, next: 0}, nil
+}
+
+type queryResult struct {
+	key   string
+	value []byte
+	doc   interface{}
+}
+
+type queryResultSorter struct {
+	results []*queryResult
+	query   *query
+}
+
+func (s *queryResultSorter) Len() int { return len(s.results) }
+func (s *queryResultSorter) Less(i, j int) bool {
+	return s.query.less(s.results[i].doc, s.results[j].doc)
+}
+func (s *queryResultSorter) Swap(i, j int) { s.results[i], s.results[j] = s.results[j], s.results[i] }
+
+// queryResultSliceIterator iterates over the results of a sorted query
+type queryResultSliceIterator struct {
+	results []*queryResult
+	next    int
+}
+
+// Next - see interface 'statemgmt.RangeScanIterator' for details
+func (itr *queryResultSliceIterator) Next() bool {
+	if itr.next >= len(itr.results) {
+		return false
+	}
+	itr.next++
+	return true
+}
+
+// GetKeyValue - see interface 'statemgmt.RangeScanIterator' for details
+func (itr *queryResultSliceIterator) GetKeyValue() (string, []byte) {
+	result := itr.results[itr.next-1]
+	return result.key, result.value
+}
+
+// Close - see interface 'statemgmt.RangeScanIterator' for details
+func (itr *queryResultSliceIterator) Close() {
+}
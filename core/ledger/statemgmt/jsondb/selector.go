@@ -0,0 +1,512 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jsondb
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/errors"
+)
+
+// A selector is a subset of the selectors of the Mango queries of CouchDB. It is a JSON
+// object whose fields, all of which have to match a document, are
+// - a path to a field of the document, the names of the nested fields being separated
+//   by dots, along with either a value the field has to be equal to or an object of
+//   conditions on the field: $eq, $ne, $gt, $gte, $lt, $lte, $in, $nin, $exists, $type,
+//   $regex, $size, $all and $elemMatch,
+// - a combination of selectors: $and, $or and $nor take an array of selectors, $not a
+//   selector.
+// The values are compared in the order of the views of CouchDB: null, false, true,
+// numbers, strings (in byte order), arrays and objects. All the conditions but $exists
+// require the field to be present.
+
+// selector matches documents, decoded by encoding/json
+type selector interface {
+	matches(doc interface{}) bool
+}
+
+// condition matches the value of a field, present is false if the field is missing
+type condition interface {
+	matches(value interface{}, present bool) bool
+}
+
+func invalidSelector(format string, args ...interface{}) error {
+	return errors.Errorf(errors.InvalidArgument, "Invalid selector: "+format, args...)
+}
+
+// parseSelector parses a selector decoded by encoding/json
+func parseSelector(value interface{}) (selector, error) {
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, invalidSelector("a selector must be an object, got %v", value)
+	}
+	var selectors andSelector
+	for _, name := range sortedNames(object) {
+		arg := object[name]
+		var s selector
+		var err error
+		switch name {
+		case "$and", "$or", "$nor":
+			var list []selector
+			if list, err = parseSelectorList(name, arg); err == nil {
+				switch name {
+				case "$and":
+					s = andSelector(list)
+				case "$or":
+					s = orSelector(list)
+				default:
+					s = notSelector{orSelector(list)}
+				}
+			}
+		case "$not":
+			var negated selector
+			if negated, err = parseSelector(arg); err == nil {
+				s = notSelector{negated}
+			}
+		default:
+			if strings.HasPrefix(name, "$") {
+				return nil, invalidSelector("unknown operator %s", name)
+			}
+			var cond condition
+			if cond, err = parseConditions(arg); err == nil {
+				s = &fieldSelector{parseFieldPath(name), cond}
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		selectors = append(selectors, s)
+	}
+	if len(selectors) == 1 {
+		return selectors[0], nil
+	}
+	return selectors, nil
+}
+
+func parseSelectorList(operator string, arg interface{}) ([]selector, error) {
+	array, ok := arg.([]interface{})
+	if !ok || len(array) == 0 {
+		return nil, invalidSelector("%s takes a non empty array of selectors", operator)
+	}
+	list := make([]selector, len(array))
+	for i, value := range array {
+		s, err := parseSelector(value)
+		if err != nil {
+			return nil, err
+		}
+		list[i] = s
+	}
+	return list, nil
+}
+
+// isConditions tells whether the value is an object of conditions, i.e., a non empty
+// object whose fields are all operators
+func isConditions(value interface{}) bool {
+	object, ok := value.(map[string]interface{})
+	if !ok || len(object) == 0 {
+		return false
+	}
+	for name := range object {
+		if !strings.HasPrefix(name, "$") {
+			return false
+		}
+	}
+	return true
+}
+
+// parseConditions parses the conditions on a field, a value being a condition of equality
+func parseConditions(value interface{}) (condition, error) {
+	if !isConditions(value) {
+		return &compareCondition{"$eq", value}, nil
+	}
+	object := value.(map[string]interface{})
+	var conditions andCondition
+	for _, operator := range sortedNames(object) {
+		cond, err := parseCondition(operator, object[operator])
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, cond)
+	}
+	if len(conditions) == 1 {
+		return conditions[0], nil
+	}
+	return conditions, nil
+}
+
+func parseCondition(operator string, arg interface{}) (condition, error) {
+	switch operator {
+	case "$eq", "$ne", "$gt", "$gte", "$lt", "$lte":
+		return &compareCondition{operator, arg}, nil
+	case "$in", "$nin", "$all":
+		values, ok := arg.([]interface{})
+		if !ok {
+			return nil, invalidSelector("%s takes an array", operator)
+		}
+		return &listCondition{operator, values}, nil
+	case "$exists":
+		exists, ok := arg.(bool)
+		if !ok {
+			return nil, invalidSelector("$exists takes a boolean")
+		}
+		return existsCondition(exists), nil
+	case "$type":
+		typeName, ok := arg.(string)
+		if !ok || !isTypeName(typeName) {
+			return nil, invalidSelector("$type takes one of null, boolean, number, string, array and object")
+		}
+		return typeCondition(typeName), nil
+	case "$regex":
+		pattern, ok := arg.(string)
+		if !ok {
+			return nil, invalidSelector("$regex takes a string")
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, invalidSelector("invalid $regex %s: %s", pattern, err)
+		}
+		return &regexCondition{re}, nil
+	case "$size":
+		size, ok := arg.(float64)
+		if !ok || size < 0 || size != float64(int(size)) {
+			return nil, invalidSelector("$size takes a non negative integer")
+		}
+		return sizeCondition(int(size)), nil
+	case "$elemMatch":
+		if isConditions(arg) {
+			cond, err := parseConditions(arg)
+			if err != nil {
+				return nil, err
+			}
+			return &elemMatchCondition{cond: cond}, nil
+		}
+		s, err := parseSelector(arg)
+		if err != nil {
+			return nil, err
+		}
+		return &elemMatchCondition{selector: s}, nil
+	}
+	return nil, invalidSelector("unknown operator %s", operator)
+}
+
+// parseFieldPath splits the path to a field at the dots not escaped by a backslash
+func parseFieldPath(path string) []string {
+	var names []string
+	var name []byte
+	for i := 0; i < len(path); i++ {
+		switch {
+		case path[i] == '\\' && i+1 < len(path):
+			i++
+			name = append(name, path[i])
+		case path[i] == '.':
+			names = append(names, string(name))
+			name = nil
+		default:
+			name = append(name, path[i])
+		}
+	}
+	return append(names, string(name))
+}
+
+// sortedNames returns the names of the fields of the object in order, for the selectors
+// to be evaluated in a predictable order
+func sortedNames(object map[string]interface{}) []string {
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+type andSelector []selector
+
+func (s andSelector) matches(doc interface{}) bool {
+	for _, selector := range s {
+		if !selector.matches(doc) {
+			return false
+		}
+	}
+	return true
+}
+
+type orSelector []selector
+
+func (s orSelector) matches(doc interface{}) bool {
+	for _, selector := range s {
+		if selector.matches(doc) {
+			return true
+		}
+	}
+	return false
+}
+
+type notSelector struct {
+	selector selector
+}
+
+func (s notSelector) matches(doc interface{}) bool {
+	return !s.selector.matches(doc)
+}
+
+// fieldSelector matches the documents whose field at the path meets the condition
+type fieldSelector struct {
+	path []string
+	cond condition
+}
+
+func (s *fieldSelector) matches(doc interface{}) bool {
+	value, present := lookupField(doc, s.path)
+	return s.cond.matches(value, present)
+}
+
+// lookupField returns the value of the field at the path of the document
+func lookupField(doc interface{}, path []string) (interface{}, bool) {
+	value := doc
+	for _, name := range path {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = object[name]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+type andCondition []condition
+
+func (c andCondition) matches(value interface{}, present bool) bool {
+	for _, cond := range c {
+		if !cond.matches(value, present) {
+			return false
+		}
+	}
+	return true
+}
+
+// compareCondition compares the value of the field with the argument
+type compareCondition struct {
+	operator string
+	arg      interface{}
+}
+
+func (c *compareCondition) matches(value interface{}, present bool) bool {
+	if !present {
+		return false
+	}
+	cmp := collate(value, c.arg)
+	switch c.operator {
+	case "$eq":
+		return cmp == 0
+	case "$ne":
+		return cmp != 0
+	case "$gt":
+		return cmp > 0
+	case "$gte":
+		return cmp >= 0
+	case "$lt":
+		return cmp < 0
+	}
+	return cmp <= 0
+}
+
+// listCondition matches the fields equal to one ($in) or none ($nin) of the values, or
+// the arrays holding all of them ($all)
+type listCondition struct {
+	operator string
+	values   []interface{}
+}
+
+func contains(values []interface{}, value interface{}) bool {
+	for _, v := range values {
+		if collate(v, value) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *listCondition) matches(value interface{}, present bool) bool {
+	if !present {
+		return false
+	}
+	switch c.operator {
+	case "$in":
+		return contains(c.values, value)
+	case "$nin":
+		return !contains(c.values, value)
+	}
+	array, ok := value.([]interface{})
+	if !ok {
+		return false
+	}
+	for _, v := range c.values {
+		if !contains(array, v) {
+			return false
+		}
+	}
+	return true
+}
+
+type existsCondition bool
+
+func (c existsCondition) matches(value interface{}, present bool) bool {
+	return present == bool(c)
+}
+
+type typeCondition string
+
+func (c typeCondition) matches(value interface{}, present bool) bool {
+	return present && typeName(value) == string(c)
+}
+
+func isTypeName(name string) bool {
+	switch name {
+	case "null", "boolean", "number", "string", "array", "object":
+		return true
+	}
+	return false
+}
+
+func typeName(value interface{}) string {
+	switch value.(type) {
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return "null"
+}
+
+type regexCondition struct {
+	re *regexp.Regexp
+}
+
+func (c *regexCondition) matches(value interface{}, present bool) bool {
+	s, ok := value.(string)
+	return present && ok && c.re.MatchString(s)
+}
+
+type sizeCondition int
+
+func (c sizeCondition) matches(value interface{}, present bool) bool {
+	array, ok := value.([]interface{})
+	return present && ok && len(array) == int(c)
+}
+
+// elemMatchCondition matches the arrays holding an element matching either the
+// conditions or the selector
+type elemMatchCondition struct {
+	cond     condition
+	selector selector
+}
+
+func (c *elemMatchCondition) matches(value interface{}, present bool) bool {
+	array, ok := value.([]interface{})
+	if !present || !ok {
+		return false
+	}
+	for _, element := range array {
+		if c.cond != nil && c.cond.matches(element, true) || c.selector != nil && c.selector.matches(element) {
+			return true
+		}
+	}
+	return false
+}
+
+// typeRank returns the rank of the type of the value in the order of the views of CouchDB
+func typeRank(value interface{}) int {
+	switch v := value.(type) {
+	case bool:
+		if v {
+			return 2
+		}
+		return 1
+	case float64:
+		return 3
+	case string:
+		return 4
+	case []interface{}:
+		return 5
+	case map[string]interface{}:
+		return 6
+	}
+	return 0
+}
+
+// collate compares two values in the order of the views of CouchDB, returning -1, 0 or
+// 1. The objects are compared field by field, in the order of the names of their fields.
+func collate(a, b interface{}) int {
+	rankA, rankB := typeRank(a), typeRank(b)
+	if rankA != rankB {
+		if rankA < rankB {
+			return -1
+		}
+		return 1
+	}
+	switch a := a.(type) {
+	case float64:
+		b := b.(float64)
+		if a < b {
+			return -1
+		} else if a > b {
+			return 1
+		}
+	case string:
+		return strings.Compare(a, b.(string))
+	case []interface{}:
+		b := b.([]interface{})
+		for i := 0; i < len(a) && i < len(b); i++ {
+			if cmp := collate(a[i], b[i]); cmp != 0 {
+				return cmp
+			}
+		}
+		return compareInts(len(a), len(b))
+	case map[string]interface{}:
+		b := b.(map[string]interface{})
+		namesA, namesB := sortedNames(a), sortedNames(b)
+		for i := 0; i < len(namesA) && i < len(namesB); i++ {
+			if cmp := strings.Compare(namesA[i], namesB[i]); cmp != 0 {
+				return cmp
+			}
+			if cmp := collate(a[namesA[i]], b[namesB[i]]); cmp != 0 {
+				return cmp
+			}
+		}
+		return compareInts(len(namesA), len(namesB))
+	}
+	return 0
+}
+
+func compareInts(a, b int) int {
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
+	}
+	return 0
+}
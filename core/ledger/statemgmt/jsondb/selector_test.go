@@ -0,0 +1,163 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jsondb
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/errors"
+	"github.com/hyperledger/fabric/core/ledger/testutil"
+)
+
+const testDocument = `{
+	"owner": {"name": "alice", "age": 30},
+	"size": 5,
+	"color": "red",
+	"tags": ["new", "small"],
+	"parts": [{"name": "wheel", "count": 4}, {"name": "seat", "count": 1}],
+	"a.b": true,
+	"retired": null
+}`
+
+func matchesTestDocument(t *testing.T, selectorJSON string) bool {
+	var value interface{}
+	testutil.AssertNoError(t, json.Unmarshal([]byte(selectorJSON), &value), "Error decoding the selector")
+	s, err := parseSelector(value)
+	testutil.AssertNoError(t, err, "Error parsing selector "+selectorJSON)
+	doc, ok := decodeDocument([]byte(testDocument))
+	testutil.AssertEquals(t, ok, true)
+	return s.matches(doc)
+}
+
+func TestSelectorMatches(t *testing.T) {
+	matching := []string{
+		`{}`,
+		`{"color": "red"}`,
+		`{"owner.name": "alice", "size": 5}`,
+		`{"owner": {"name": "alice", "age": 30}}`,
+		`{"size": {"$gt": 4, "$lte": 5}}`,
+		`{"size": {"$ne": 6}}`,
+		`{"size": {"$lt": "a"}}`,
+		`{"color": {"$in": ["blue", "red"]}}`,
+		`{"color": {"$nin": ["blue"]}}`,
+		`{"tags": {"$all": ["small"]}}`,
+		`{"tags": {"$size": 2}}`,
+		`{"tags": {"$elemMatch": {"$eq": "new"}}}`,
+		`{"parts": {"$elemMatch": {"name": "wheel", "count": {"$gte": 4}}}}`,
+		`{"color": {"$regex": "^r"}}`,
+		`{"retired": {"$exists": true, "$type": "null"}}`,
+		`{"missing": {"$exists": false}}`,
+		`{"a\\.b": true}`,
+		`{"$or": [{"color": "blue"}, {"size": 5}]}`,
+		`{"$and": [{"color": "red"}, {"owner.age": {"$gte": 18}}]}`,
+		`{"$not": {"color": "blue"}}`,
+		`{"$nor": [{"color": "blue"}, {"size": 6}]}`,
+	}
+	for _, selectorJSON := range matching {
+		if !matchesTestDocument(t, selectorJSON) {
+			t.Fatalf("Expected selector %s to match the document", selectorJSON)
+		}
+	}
+	notMatching := []string{
+		`{"color": "blue"}`,
+		`{"owner": {"name": "alice"}}`,
+		`{"owner.name.first": "alice"}`,
+		`{"size": {"$gt": 5}}`,
+		`{"size": "5"}`,
+		`{"missing": {"$ne": 1}}`,
+		`{"color": {"$nin": ["red"]}}`,
+		`{"tags": {"$all": ["small", "big"]}}`,
+		`{"parts": {"$elemMatch": {"name": "wheel", "count": 1}}}`,
+		`{"size": {"$regex": "5"}}`,
+		`{"$or": [{"color": "blue"}, {"size": 6}]}`,
+		`{"$not": {"color": "red"}}`,
+	}
+	for _, selectorJSON := range notMatching {
+		if matchesTestDocument(t, selectorJSON) {
+			t.Fatalf("Expected selector %s not to match the document", selectorJSON)
+		}
+	}
+}
+
+func TestSelectorInvalid(t *testing.T) {
+	invalid := []string{
+		`[]`,
+		`{"$unknown": 1}`,
+		`{"size": {"$unknown": 1}}`,
+		`{"$or": []}`,
+		`{"$and": {"size": 1}}`,
+		`{"size": {"$in": 1}}`,
+		`{"size": {"$exists": 1}}`,
+		`{"size": {"$type": "integer"}}`,
+		`{"color": {"$regex": "("}}`,
+		`{"tags": {"$size": 1.5}}`,
+	}
+	for _, selectorJSON := range invalid {
+		var value interface{}
+		testutil.AssertNoError(t, json.Unmarshal([]byte(selectorJSON), &value), "Error decoding the selector")
+		_, err := parseSelector(value)
+		testutil.AssertError(t, err, "Expected selector "+selectorJSON+" to be invalid")
+		testutil.AssertEquals(t, errors.CodeOf(err), errors.InvalidArgument)
+	}
+}
+
+func TestCollate(t *testing.T) {
+	ordered := []string{`null`, `false`, `true`, `-1`, `2.5`, `10`, `""`, `"B"`, `"a"`, `"ab"`, `[]`, `[1]`, `[1, 2]`, `[2]`, `{}`, `{"a": 1}`, `{"a": 2}`, `{"b": 1}`}
+	values := make([]interface{}, len(ordered))
+	for i, valueJSON := range ordered {
+		testutil.AssertNoError(t, json.Unmarshal([]byte(valueJSON), &values[i]), "Error decoding "+valueJSON)
+	}
+	for i := range values {
+		for j := range values {
+			testutil.AssertEquals(t, collate(values[i], values[j]), compareInts(i, j))
+		}
+	}
+}
+
+func TestParseQuery(t *testing.T) {
+	q, err := parseQuery(`{"selector": {"size": {"$gt": 1}}, "sort": ["owner.name", {"size": "desc"}], "skip": 1, "limit": 2}`)
+	testutil.AssertNoError(t, err, "Error parsing the query")
+	testutil.AssertEquals(t, q.sort, []sortField{{[]string{"owner", "name"}, false}, {[]string{"size"}, true}})
+	testutil.AssertEquals(t, q.skip, 1)
+	testutil.AssertEquals(t, q.limit, 2)
+
+	q, err = parseQuery(`{"color": "red"}`)
+	testutil.AssertNoError(t, err, "Error parsing a selector")
+	testutil.AssertEquals(t, len(q.sort), 0)
+	testutil.AssertEquals(t, q.limit, 0)
+
+	for _, invalid := range []string{`not json`, `"red"`, `{"selector": {}, "fields": ["a"]}`, `{"selector": {}, "limit": -1}`,
+		`{"selector": {}, "sort": [{"size": "up"}]}`, `{"selector": {}, "sort": "size"}`} {
+		_, err := parseQuery(invalid)
+		testutil.AssertError(t, err, "Expected query "+invalid+" to be invalid")
+	}
+}
+
+func TestQuerySort(t *testing.T) {
+	q, err := parseQuery(`{"selector": {}, "sort": [{"size": "desc"}, "color"]}`)
+	testutil.AssertNoError(t, err, "Error parsing the query")
+	docs := make([]interface{}, 4)
+	for i, docJSON := range []string{`{"size": 1, "color": "b"}`, `{"size": 2}`, `{"size": 1, "color": "a"}`, `{"color": "c"}`} {
+		docs[i], _ = decodeDocument([]byte(docJSON))
+	}
+	// the documents missing a field come first, i.e. last in descending order
+	testutil.AssertEquals(t, q.less(docs[1], docs[0]), true)
+	testutil.AssertEquals(t, q.less(docs[2], docs[0]), true)
+	testutil.AssertEquals(t, q.less(docs[0], docs[2]), false)
+	testutil.AssertEquals(t, q.less(docs[0], docs[3]), true)
+}
@@ -0,0 +1,195 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jsondb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/db/kvstore"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt/buckettree"
+	"github.com/op/go-logging"
+)
+
+var logger = logging.MustGetLogger("jsondb")
+
+// The documents are held by the documentsCF, keyed by the composite key of their key.
+// The metadata key records that the documents are those of the state, so that they are
+// built from the state when a DB created with another data structure is opened.
+//
+// key:   prefixDocumentKey compositeKey
+// value: the JSON document
+var prefixDocumentKey = byte(1)
+var documentsMetadataKey = []byte{0}
+
+// rebuildBatchSize is the number of documents written by a write-batch while building the
+// documents from the state
+const rebuildBatchSize = 1000
+
+// StateImpl keeps the state in a bucket tree, which computes the crypto-hash of the state and
+// serves the reads, and stores the values that are JSON objects as documents in the documentsCF
+// as well, so that the values of a chaincode can be queried (see GetQueryResultIterator). The
+// documents are written in the write-batch of the changes of the bucket tree.
+type StateImpl struct {
+	*buckettree.StateImpl
+	store      kvstore.KVStore
+	stateDelta *statemgmt.StateDelta
+}
+
+// NewStateImpl constructs a new StateImpl that persists the bucket tree and the documents
+// in the given DB
+func NewStateImpl(store kvstore.KVStore) *StateImpl {
+	return &StateImpl{StateImpl: buckettree.NewStateImpl(store), store: store}
+}
+
+// Initialize - method implementation for interface 'statemgmt.HashableState'. The configs
+// are the ones of the bucket tree
+func (impl *StateImpl) Initialize(configs map[string]interface{}) error {
+	if err := impl.StateImpl.Initialize(configs); err != nil {
+		return err
+	}
+	store := impl.store
+	metadata, err := store.Get(kvstore.DocumentsCFName, documentsMetadataKey)
+	if err != nil {
+		return fmt.Errorf("Error while reading the metadata of the documents: %s", err)
+	}
+	if metadata == nil {
+		return impl.rebuildDocuments()
+	}
+	return nil
+}
+
+// rebuildDocuments writes the documents of the values of the state
+func (impl *StateImpl) rebuildDocuments() error {
+	store := impl.store
+	snapshot := store.NewSnapshot()
+	defer snapshot.Release()
+	itr, err := impl.StateImpl.GetStateSnapshotIterator(snapshot)
+	if err != nil {
+		return err
+	}
+	defer itr.Close()
+	logger.Info("Building the JSON documents of the state")
+	writeBatch := store.NewWriteBatch()
+	defer func() { writeBatch.Destroy() }()
+	numKeys, numDocuments := 0, 0
+	for itr.Next() {
+		compositeKey, value := itr.GetRawKeyValue()
+		numKeys++
+		if isDocument(value) {
+			writeBatch.Put(kvstore.DocumentsCFName, append([]byte{prefixDocumentKey}, compositeKey...), value)
+			numDocuments++
+		}
+		if numKeys%rebuildBatchSize == 0 {
+			if err := store.Write(writeBatch, false); err != nil {
+				return fmt.Errorf("Error while writing the documents of the state: %s", err)
+			}
+			writeBatch.Destroy()
+			writeBatch = store.NewWriteBatch()
+		}
+	}
+	writeBatch.Put(kvstore.DocumentsCFName, documentsMetadataKey, []byte{})
+	if err := store.Write(writeBatch, true); err != nil {
+		return fmt.Errorf("Error while writing the documents of the state: %s", err)
+	}
+	logger.Info("Built %d JSON documents out of the %d keys of the state", numDocuments, numKeys)
+	return nil
+}
+
+// PrepareWorkingSet - method implementation for interface 'statemgmt.HashableState'
+func (impl *StateImpl) PrepareWorkingSet(stateDelta *statemgmt.StateDelta) error {
+	impl.stateDelta = stateDelta
+	return impl.StateImpl.PrepareWorkingSet(stateDelta)
+}
+
+// ClearWorkingSet - method implementation for interface 'statemgmt.HashableState'
+func (impl *StateImpl) ClearWorkingSet(changesPersisted bool) {
+	impl.stateDelta = nil
+	impl.StateImpl.ClearWorkingSet(changesPersisted)
+}
+
+// AddChangesForPersistence - method implementation for interface 'statemgmt.HashableState'.
+// A key whose new value is not a JSON object loses its document, if any.
+func (impl *StateImpl) AddChangesForPersistence(writeBatch kvstore.WriteBatch) error {
+	if err := impl.StateImpl.AddChangesForPersistence(writeBatch); err != nil {
+		return err
+	}
+	delta := impl.stateDelta
+	if delta == nil {
+		return nil
+	}
+	for _, chaincodeID := range delta.GetUpdatedChaincodeIds(false) {
+		for key, value := range delta.GetUpdates(chaincodeID) {
+			documentKey := encodeDocumentKey(chaincodeID, key)
+			if value.IsDelete() || !isDocument(value.GetValue()) {
+				writeBatch.Delete(kvstore.DocumentsCFName, documentKey)
+			} else {
+				writeBatch.Put(kvstore.DocumentsCFName, documentKey, value.GetValue())
+			}
+		}
+	}
+	// the metadata is written again in case the documents were deleted along with the
+	// state (see state.DeleteState)
+	writeBatch.Put(kvstore.DocumentsCFName, documentsMetadataKey, []byte{})
+	return nil
+}
+
+// GetQueryResultIterator - method implementation for interface 'statemgmt.QueryableState'.
+// The query is either a selector, a subset of the selectors of the Mango queries of CouchDB
+// (see selector.go), or a query object (see query.go). The query is run against the
+// committed documents of the chaincode, the values that are not JSON objects are never
+// returned.
+func (impl *StateImpl) GetQueryResultIterator(chaincodeID string, queryString string) (statemgmt.RangeScanIterator, error) {
+	q, err := parseQuery(queryString)
+	if err != nil {
+		return nil, err
+	}
+	store := impl.store
+	if len(q.sort) > 0 {
+		return sortedQueryResults(store, chaincodeID, q)
+	}
+	return newQueryResultIterator(store, chaincodeID, q), nil
+}
+
+// isDocument tells whether the value is a JSON object
+func isDocument(value []byte) bool {
+	_, ok := decodeDocument(value)
+	return ok
+}
+
+// decodeDocument decodes the value if it is a JSON object
+func decodeDocument(value []byte) (interface{}, bool) {
+	trimmed := bytes.TrimSpace(value)
+	if len(trimmed) == 0 || trimmed[0] != '{' {
+		return nil, false
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(trimmed, &doc); err != nil {
+		return nil, false
+	}
+	return doc, true
+}
+
+func encodeDocumentKey(chaincodeID string, key string) []byte {
+	return append([]byte{prefixDocumentKey}, statemgmt.ConstructCompositeKey(chaincodeID, key)...)
+}
+
+func decodeDocumentKey(documentKey []byte) (string, string) {
+	return statemgmt.DecodeCompositeKey(documentKey[1:])
+}
@@ -0,0 +1,136 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jsondb
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric/core/db"
+	"github.com/hyperledger/fabric/core/errors"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt/buckettree"
+	"github.com/hyperledger/fabric/core/ledger/testutil"
+)
+
+var testConfigs = map[string]interface{}{buckettree.ConfigNumBuckets: 100, buckettree.ConfigMaxGroupingAtEachLevel: 5}
+
+func newTestStateImpl(t *testing.T) *StateImpl {
+	impl := NewStateImpl(db.GetDBHandle().KVStore())
+	testutil.AssertNoError(t, impl.Initialize(testConfigs), "Error while initializing stateImpl")
+	return impl
+}
+
+func applyAndPersist(t *testing.T, impl statemgmt.HashableState, stateDelta *statemgmt.StateDelta) []byte {
+	impl.PrepareWorkingSet(stateDelta)
+	cryptoHash, err := impl.ComputeCryptoHash()
+	testutil.AssertNoError(t, err, "Error while computing crypto hash")
+	writeBatch := db.GetDBHandle().KVStore().NewWriteBatch()
+	defer writeBatch.Destroy()
+	testutil.AssertNoError(t, impl.AddChangesForPersistence(writeBatch), "Error while adding changes to db write-batch")
+	testDBWrapper.WriteToDB(t, writeBatch)
+	impl.ClearWorkingSet(true)
+	return cryptoHash
+}
+
+func queryKeys(t *testing.T, impl *StateImpl, chaincodeID string, queryString string) []string {
+	itr, err := impl.GetQueryResultIterator(chaincodeID, queryString)
+	testutil.AssertNoError(t, err, "Error while querying "+queryString)
+	defer itr.Close()
+	keys := []string{}
+	for itr.Next() {
+		key, value := itr.GetKeyValue()
+		stateValue, err := impl.Get(chaincodeID, key)
+		testutil.AssertNoError(t, err, "Error while reading the state")
+		testutil.AssertEquals(t, value, stateValue)
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func TestStateImplQuery(t *testing.T) {
+	testDBWrapper.CreateFreshDB(t)
+	impl := newTestStateImpl(t)
+	delta := statemgmt.NewStateDelta()
+	delta.Set("chaincodeID1", "key1", []byte(`{"owner": "alice", "size": 3}`), nil)
+	delta.Set("chaincodeID1", "key2", []byte(`{"owner": "bob", "size": 1}`), nil)
+	delta.Set("chaincodeID1", "key3", []byte(`{"owner": "alice", "size": 2}`), nil)
+	delta.Set("chaincodeID1", "key4", []byte(`["alice"]`), nil)
+	delta.Set("chaincodeID1", "key5", []byte("alice"), nil)
+	delta.Set("chaincodeID2", "key1", []byte(`{"owner": "alice", "size": 1}`), nil)
+	applyAndPersist(t, impl, delta)
+
+	testutil.AssertEquals(t, queryKeys(t, impl, "chaincodeID1", `{}`), []string{"key1", "key2", "key3"})
+	testutil.AssertEquals(t, queryKeys(t, impl, "chaincodeID1", `{"owner": "alice"}`), []string{"key1", "key3"})
+	testutil.AssertEquals(t, queryKeys(t, impl, "chaincodeID2", `{"owner": "alice"}`), []string{"key1"})
+	testutil.AssertEquals(t, queryKeys(t, impl, "chaincodeID3", `{}`), []string{})
+	testutil.AssertEquals(t, queryKeys(t, impl, "chaincodeID1", `{"selector": {}, "skip": 1, "limit": 1}`), []string{"key2"})
+	testutil.AssertEquals(t, queryKeys(t, impl, "chaincodeID1", `{"selector": {}, "sort": ["size"]}`), []string{"key2", "key3", "key1"})
+	testutil.AssertEquals(t, queryKeys(t, impl, "chaincodeID1",
+		`{"selector": {"owner": "alice"}, "sort": [{"size": "desc"}], "skip": 1}`), []string{"key3"})
+
+	// the documents follow the updates and deletes of the values
+	delta = statemgmt.NewStateDelta()
+	delta.Set("chaincodeID1", "key1", []byte("not a document"), nil)
+	delta.Delete("chaincodeID1", "key2", nil)
+	delta.Set("chaincodeID1", "key5", []byte(`{"owner": "alice"}`), nil)
+	applyAndPersist(t, impl, delta)
+	testutil.AssertEquals(t, queryKeys(t, impl, "chaincodeID1", `{}`), []string{"key3", "key5"})
+
+	_, err := impl.GetQueryResultIterator("chaincodeID1", `{"owner": {"$unknown": 1}}`)
+	testutil.AssertError(t, err, "Expected an invalid query error")
+	testutil.AssertEquals(t, errors.CodeOf(err), errors.InvalidArgument)
+}
+
+func TestStateImplHashMatchesBucketTree(t *testing.T) {
+	delta := statemgmt.NewStateDelta()
+	delta.Set("chaincodeID1", "key1", []byte(`{"owner": "alice"}`), nil)
+	delta.Set("chaincodeID1", "key2", []byte("value2"), nil)
+	delta.Set("chaincodeID2", "key1", []byte(`{"owner": "bob"}`), nil)
+
+	testDBWrapper.CreateFreshDB(t)
+	bucketTree := buckettree.NewStateImpl(db.GetDBHandle().KVStore())
+	testutil.AssertNoError(t, bucketTree.Initialize(testConfigs), "Error while initializing the bucket tree")
+	expectedHash := applyAndPersist(t, bucketTree, delta)
+
+	testDBWrapper.CreateFreshDB(t)
+	impl := newTestStateImpl(t)
+	testutil.AssertEquals(t, applyAndPersist(t, impl, delta), expectedHash)
+}
+
+func TestStateImplRebuildsDocuments(t *testing.T) {
+	testDBWrapper.CreateFreshDB(t)
+	// a state persisted by the bucket tree alone
+	bucketTree := buckettree.NewStateImpl(db.GetDBHandle().KVStore())
+	testutil.AssertNoError(t, bucketTree.Initialize(testConfigs), "Error while initializing the bucket tree")
+	delta := statemgmt.NewStateDelta()
+	delta.Set("chaincodeID1", "key1", []byte(`{"owner": "alice"}`), nil)
+	delta.Set("chaincodeID1", "key2", []byte("value2"), nil)
+	delta.Set("chaincodeID1", "key3", []byte(`{"owner": "bob"}`), nil)
+	applyAndPersist(t, bucketTree, delta)
+
+	impl := newTestStateImpl(t)
+	testutil.AssertEquals(t, queryKeys(t, impl, "chaincodeID1", `{}`), []string{"key1", "key3"})
+	testutil.AssertEquals(t, queryKeys(t, impl, "chaincodeID1", `{"owner": "bob"}`), []string{"key3"})
+
+	// the documents are deleted along with the state, and built again on the next initialization
+	testutil.AssertNoError(t, db.GetDBHandle().DeleteState(), "Error while deleting the state")
+	bucketTree = buckettree.NewStateImpl(db.GetDBHandle().KVStore())
+	testutil.AssertNoError(t, bucketTree.Initialize(testConfigs), "Error while initializing the bucket tree")
+	applyAndPersist(t, bucketTree, delta)
+	impl = newTestStateImpl(t)
+	testutil.AssertEquals(t, queryKeys(t, impl, "chaincodeID1", `{}`), []string{"key1", "key3"})
+}
@@ -0,0 +1,37 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statemgmt
+
+// PruneStats reports the nodes removed by a pruning pass
+type PruneStats struct {
+	// DataNodesRemoved is the number of key-values removed
+	DataNodesRemoved uint64 `json:"dataNodesRemoved"`
+	// IntermediateNodesRemoved is the number of nodes of the hash tree removed
+	IntermediateNodesRemoved uint64 `json:"intermediateNodesRemoved"`
+	// OtherNodesRemoved is the number of other records (e.g., filters) removed
+	OtherNodesRemoved uint64 `json:"otherNodesRemoved"`
+	// BytesReclaimed is the sum of the sizes of the keys and values removed
+	BytesReclaimed uint64 `json:"bytesReclaimed"`
+}
+
+// Pruner can optionally be implemented by a HashableState for removing the
+// persisted nodes that are no longer reachable from the current root (e.g.,
+// left over from a crash, a state transfer or a change of configuration).
+// Pruning must not overlap with the commit of a block.
+type Pruner interface {
+	PruneOrphanedNodes() (*PruneStats, error)
+}
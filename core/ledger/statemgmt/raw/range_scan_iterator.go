@@ -0,0 +1,67 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package raw
+
+import (
+	"github.com/hyperledger/fabric/core/db/kvstore"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+)
+
+// RangeScanIterator implements the interface 'statemgmt.RangeScanIterator'.
+// The keys are stored as composite keys, hence, the keys of a chaincode are
+// contiguous in the db and appear in the sorted order
+type RangeScanIterator struct {
+	dbItr        kvstore.Iterator
+	chaincodeID  string
+	endKey       string
+	currentKey   string
+	currentValue []byte
+	done         bool
+}
+
+func newRangeScanIterator(store kvstore.Reader, chaincodeID string, startKey string, endKey string) (*RangeScanIterator, error) {
+	dbItr := store.NewIterator(kvstore.StateCFName)
+	dbItr.Seek(statemgmt.ConstructCompositeKey(chaincodeID, startKey))
+	return &RangeScanIterator{dbItr: dbItr, chaincodeID: chaincodeID, endKey: endKey}, nil
+}
+
+// Next - see interface 'statemgmt.RangeScanIterator' for details
+func (itr *RangeScanIterator) Next() bool {
+	if itr.done || !itr.dbItr.Valid() {
+		itr.done = true
+		return false
+	}
+	chaincodeID, key := statemgmt.DecodeCompositeKey(itr.dbItr.Key())
+	if chaincodeID != itr.chaincodeID || (itr.endKey != "" && key > itr.endKey) {
+		itr.done = true
+		return false
+	}
+	itr.currentKey = key
+	itr.currentValue = itr.dbItr.Value()
+	itr.dbItr.Next()
+	return true
+}
+
+// GetKeyValue - see interface 'statemgmt.RangeScanIterator' for details
+func (itr *RangeScanIterator) GetKeyValue() (string, []byte) {
+	return itr.currentKey, itr.currentValue
+}
+
+// Close - see interface 'statemgmt.RangeScanIterator' for details
+func (itr *RangeScanIterator) Close() {
+	itr.dbItr.Close()
+}
@@ -17,82 +17,142 @@ limitations under the License.
 package raw
 
 import (
-	"github.com/hyperledger/fabric/core/db"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/db/kvstore"
 	"github.com/hyperledger/fabric/core/ledger/statemgmt"
-	"github.com/tecbot/gorocksdb"
+	"github.com/hyperledger/fabric/core/ledger/verify"
 )
 
-// StateImpl implements raw state management. This implementation does not support computation of crypto-hash of the state.
-// It simply stores the compositeKey and value in the db
+// StateImpl implements raw state management, meant for development networks where commit speed matters
+// more than provable state hashes. It simply stores the compositeKey and value in the db and does not
+// maintain any merkle structure. The crypto-hash of the state is a chain of the hashes of the state deltas
+// applied, i.e., hash(previousStateHash + hash(stateDelta)), which is cheap to compute and lets the peers that
+// applied the same deltas agree on the state. However, the hash is not a function of the state content. Hence,
+// it cannot be used for verifying the state received by state transfer or for proving the value of a key.
 type StateImpl struct {
-	stateDelta *statemgmt.StateDelta
+	store                  kvstore.KVStore
+	stateDelta             *statemgmt.StateDelta
+	persistedStateHash     []byte
+	lastComputedCryptoHash []byte
+	recomputeCryptoHash    bool
 }
 
-// NewRawState constructs new instance of raw state
-func NewRawState() *StateImpl {
-	return &StateImpl{}
+// stateHashKey is the key in the persistCF under which the state hash is maintained
+var stateHashKey = []byte("raw.stateHash")
+
+// NewRawState constructs new instance of raw state that persists the key-values in the given DB
+func NewRawState(store kvstore.KVStore) *StateImpl {
+	return &StateImpl{store: store}
 }
 
 // Initialize - method implementation for interface 'statemgmt.HashableState'
 func (impl *StateImpl) Initialize(configs map[string]interface{}) error {
+	store := impl.store
+	stateHash, err := store.Get(kvstore.PersistCFName, stateHashKey)
+	if err != nil {
+		return fmt.Errorf("Error while loading the state hash: %s", err)
+	}
+	impl.persistedStateHash = stateHash
+	impl.lastComputedCryptoHash = stateHash
 	return nil
 }
 
 // Get - method implementation for interface 'statemgmt.HashableState'
 func (impl *StateImpl) Get(chaincodeID string, key string) ([]byte, error) {
 	compositeKey := statemgmt.ConstructCompositeKey(chaincodeID, key)
-	openchainDB := db.GetDBHandle()
-	return openchainDB.GetFromStateCF(compositeKey)
+	store := impl.store
+	return store.Get(kvstore.StateCFName, compositeKey)
 }
 
 // PrepareWorkingSet - method implementation for interface 'statemgmt.HashableState'
 func (impl *StateImpl) PrepareWorkingSet(stateDelta *statemgmt.StateDelta) error {
 	impl.stateDelta = stateDelta
+	impl.recomputeCryptoHash = true
 	return nil
 }
 
 // ClearWorkingSet - method implementation for interface 'statemgmt.HashableState'
 func (impl *StateImpl) ClearWorkingSet(changesPersisted bool) {
 	impl.stateDelta = nil
+	impl.recomputeCryptoHash = false
+	if changesPersisted {
+		impl.persistedStateHash = impl.lastComputedCryptoHash
+	} else {
+		impl.lastComputedCryptoHash = impl.persistedStateHash
+	}
 }
 
 // ComputeCryptoHash - method implementation for interface 'statemgmt.HashableState'
 func (impl *StateImpl) ComputeCryptoHash() ([]byte, error) {
-	return nil, nil
+	if !impl.recomputeCryptoHash {
+		return impl.lastComputedCryptoHash, nil
+	}
+	impl.lastComputedCryptoHash = impl.persistedStateHash
+	if impl.stateDelta != nil && !impl.stateDelta.IsEmpty() {
+		impl.lastComputedCryptoHash = verify.ComputeChainedStateHash(statemgmt.ComputeCryptoHash,
+			impl.persistedStateHash, impl.stateDelta.ComputeCryptoHash())
+	}
+	impl.recomputeCryptoHash = false
+	return impl.lastComputedCryptoHash, nil
 }
 
 // AddChangesForPersistence - method implementation for interface 'statemgmt.HashableState'
-func (impl *StateImpl) AddChangesForPersistence(writeBatch *gorocksdb.WriteBatch) error {
+func (impl *StateImpl) AddChangesForPersistence(writeBatch kvstore.WriteBatch) error {
 	delta := impl.stateDelta
 	if delta == nil {
 		return nil
 	}
-	openchainDB := db.GetDBHandle()
+	if impl.recomputeCryptoHash {
+		if _, err := impl.ComputeCryptoHash(); err != nil {
+			return err
+		}
+	}
+	if impl.lastComputedCryptoHash != nil {
+		writeBatch.Put(kvstore.PersistCFName, stateHashKey, impl.lastComputedCryptoHash)
+	}
 	updatedChaincodeIds := delta.GetUpdatedChaincodeIds(false)
 	for _, updatedChaincodeID := range updatedChaincodeIds {
 		updates := delta.GetUpdates(updatedChaincodeID)
 		for updatedKey, value := range updates {
 			compositeKey := statemgmt.ConstructCompositeKey(updatedChaincodeID, updatedKey)
 			if value.IsDelete() {
-				writeBatch.DeleteCF(openchainDB.StateCF, compositeKey)
+				writeBatch.Delete(kvstore.StateCFName, compositeKey)
 			} else {
-				writeBatch.PutCF(openchainDB.StateCF, compositeKey, value.GetValue())
+				writeBatch.Put(kvstore.StateCFName, compositeKey, value.GetValue())
 			}
 		}
 	}
 	return nil
 }
 
+// GetRootStateHashDetail - see interface 'statemgmt.StateHashDetailProvider' for details.
+// The hash of the raw state is a chain over the state deltas rather than a tree and,
+// hence, there are no children to report.
+func (impl *StateImpl) GetRootStateHashDetail() (*statemgmt.StateHashDetail, error) {
+	store := impl.store
+	stateHash, err := store.Get(kvstore.PersistCFName, stateHashKey)
+	if err != nil {
+		return nil, err
+	}
+	return &statemgmt.StateHashDetail{RootHash: stateHash}, nil
+}
+
 // PerfHintKeyChanged - method implementation for interface 'statemgmt.HashableState'
 func (impl *StateImpl) PerfHintKeyChanged(chaincodeID string, key string) {
 }
 
 // GetStateSnapshotIterator - method implementation for interface 'statemgmt.HashableState'
-func (impl *StateImpl) GetStateSnapshotIterator(snapshot *gorocksdb.Snapshot) (statemgmt.StateSnapshotIterator, error) {
-	panic("Not a full-fledged state implementation. Implemented only for measuring best-case performance benchmark")
+func (impl *StateImpl) GetStateSnapshotIterator(snapshot kvstore.Snapshot) (statemgmt.StateSnapshotIterator, error) {
+	return newStateSnapshotIterator(snapshot)
 }
 
 // GetRangeScanIterator - method implementation for interface 'statemgmt.HashableState'
 func (impl *StateImpl) GetRangeScanIterator(chaincodeID string, startKey string, endKey string) (statemgmt.RangeScanIterator, error) {
-	panic("Not a full-fledged state implementation. Implemented only for measuring best-case performance benchmark")
+	return newRangeScanIterator(impl.store, chaincodeID, startKey, endKey)
+}
+
+// GetRangeScanIteratorFromSnapshot - method implementation for interface 'statemgmt.SnapshotRangeScanProvider'
+func (impl *StateImpl) GetRangeScanIteratorFromSnapshot(snapshot kvstore.Snapshot, chaincodeID string, startKey string, endKey string) (statemgmt.RangeScanIterator, error) {
+	return newRangeScanIterator(snapshot, chaincodeID, startKey, endKey)
 }
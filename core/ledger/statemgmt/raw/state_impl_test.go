@@ -0,0 +1,114 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package raw
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric/core/db"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+	"github.com/hyperledger/fabric/core/ledger/testutil"
+)
+
+func applyAndPersist(t *testing.T, impl *StateImpl, stateDelta *statemgmt.StateDelta) []byte {
+	impl.PrepareWorkingSet(stateDelta)
+	cryptoHash, err := impl.ComputeCryptoHash()
+	testutil.AssertNoError(t, err, "Error while computing crypto hash")
+	writeBatch := db.GetDBHandle().KVStore().NewWriteBatch()
+	defer writeBatch.Destroy()
+	testutil.AssertNoError(t, impl.AddChangesForPersistence(writeBatch), "Error while adding changes to db write-batch")
+	testDBWrapper.WriteToDB(t, writeBatch)
+	impl.ClearWorkingSet(true)
+	return cryptoHash
+}
+
+func TestRawStateHashChain(t *testing.T) {
+	testDBWrapper.CreateFreshDB(t)
+	impl := NewRawState(db.GetDBHandle().KVStore())
+	testutil.AssertNoError(t, impl.Initialize(nil), "Error while initializing raw state")
+	hash, _ := impl.ComputeCryptoHash()
+	testutil.AssertNil(t, hash)
+
+	delta1 := statemgmt.NewStateDelta()
+	delta1.Set("chaincodeID1", "key1", []byte("value1"), nil)
+	hash1 := applyAndPersist(t, impl, delta1)
+	testutil.AssertEquals(t, hash1, statemgmt.ComputeCryptoHash(delta1.ComputeCryptoHash()))
+
+	// an empty delta leaves the hash unchanged
+	testutil.AssertEquals(t, applyAndPersist(t, impl, statemgmt.NewStateDelta()), hash1)
+
+	delta2 := statemgmt.NewStateDelta()
+	delta2.Delete("chaincodeID1", "key1", nil)
+	hash2 := applyAndPersist(t, impl, delta2)
+	testutil.AssertEquals(t, hash2, statemgmt.ComputeCryptoHash(append(append([]byte{}, hash1...), delta2.ComputeCryptoHash()...)))
+
+	// the hash survives a restart and a rolled back working set
+	impl = NewRawState(db.GetDBHandle().KVStore())
+	testutil.AssertNoError(t, impl.Initialize(nil), "Error while initializing raw state")
+	impl.PrepareWorkingSet(delta1)
+	impl.ComputeCryptoHash()
+	impl.ClearWorkingSet(false)
+	hash, _ = impl.ComputeCryptoHash()
+	testutil.AssertEquals(t, hash, hash2)
+}
+
+func TestRawStateIterators(t *testing.T) {
+	testDBWrapper.CreateFreshDB(t)
+	impl := NewRawState(db.GetDBHandle().KVStore())
+	impl.Initialize(nil)
+	delta := statemgmt.NewStateDelta()
+	delta.Set("chaincodeID1", "key1", []byte("value1"), nil)
+	delta.Set("chaincodeID2", "key1", []byte("value1"), nil)
+	delta.Set("chaincodeID2", "key2", []byte("value2"), nil)
+	delta.Set("chaincodeID2", "key3", []byte("value3"), nil)
+	delta.Set("chaincodeID2", "key4", []byte("value4"), nil)
+	delta.Set("chaincodeID3", "key1", []byte("value1"), nil)
+	applyAndPersist(t, impl, delta)
+
+	itr, err := impl.GetRangeScanIterator("chaincodeID2", "key2", "key3")
+	testutil.AssertNoError(t, err, "Error while creating range scan iterator")
+	expected := map[string][]byte{"key2": []byte("value2"), "key3": []byte("value3")}
+	found := map[string][]byte{}
+	for itr.Next() {
+		k, v := itr.GetKeyValue()
+		found[k] = v
+	}
+	itr.Close()
+	testutil.AssertEquals(t, found, expected)
+
+	itr, _ = impl.GetRangeScanIterator("chaincodeID2", "", "")
+	count := 0
+	for itr.Next() {
+		count++
+	}
+	itr.Close()
+	testutil.AssertEquals(t, count, 4)
+
+	snapshot := db.GetDBHandle().KVStore().NewSnapshot()
+	defer snapshot.Release()
+	snapshotItr, err := impl.GetStateSnapshotIterator(snapshot)
+	testutil.AssertNoError(t, err, "Error while creating snapshot iterator")
+	defer snapshotItr.Close()
+	count = 0
+	for snapshotItr.Next() {
+		k, v := snapshotItr.GetRawKeyValue()
+		chaincodeID, key := statemgmt.DecodeCompositeKey(k)
+		testutil.AssertEquals(t, delta.Get(chaincodeID, key).GetValue(), v)
+		count++
+	}
+	testutil.AssertEquals(t, count, 6)
+}
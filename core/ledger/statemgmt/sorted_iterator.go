@@ -0,0 +1,61 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statemgmt
+
+import (
+	"sort"
+)
+
+// SortedRangeScanIterator - An iterator returning the key-values of another iterator in the lexical
+// order of the keys. The key-values are all read, and the other iterator closed, on creation
+type SortedRangeScanIterator struct {
+	keys            []string
+	values          map[string][]byte
+	currentKeyIndex int
+}
+
+// NewSortedRangeScanIterator - return an iterator over the key-values of the given iterator sorted by key
+func NewSortedRangeScanIterator(itr RangeScanIterator) *SortedRangeScanIterator {
+	defer itr.Close()
+	sortedItr := &SortedRangeScanIterator{values: make(map[string][]byte), currentKeyIndex: -1}
+	for itr.Next() {
+		key, value := itr.GetKeyValue()
+		sortedItr.keys = append(sortedItr.keys, key)
+		sortedItr.values[key] = value
+	}
+	sort.Strings(sortedItr.keys)
+	return sortedItr
+}
+
+// Next - see interface 'RangeScanIterator' for details
+func (itr *SortedRangeScanIterator) Next() bool {
+	if itr.currentKeyIndex+1 < len(itr.keys) {
+		itr.currentKeyIndex++
+		return true
+	}
+	return false
+}
+
+// GetKeyValue - see interface 'RangeScanIterator' for details
+func (itr *SortedRangeScanIterator) GetKeyValue() (string, []byte) {
+	key := itr.keys[itr.currentKeyIndex]
+	return key, itr.values[key]
+}
+
+// Close - see interface 'RangeScanIterator' for details
+func (itr *SortedRangeScanIterator) Close() {
+}
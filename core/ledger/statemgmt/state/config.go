@@ -20,35 +20,74 @@ import (
 	"fmt"
 	"sync"
 
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
 	"github.com/spf13/viper"
 )
 
-var loadConfigOnce sync.Once
+var loadHashProviderOnce sync.Once
 
-var stateImplName string
-var stateImplConfigs map[string]interface{}
-var deltaHistorySize int
+var hashProvider statemgmt.HashProvider
 
-func initConfig() {
-	loadConfigOnce.Do(func() { loadConfig() })
+// Config is the configuration of a State. Each State has a configuration of its own, hence,
+// the states of different chains may, e.g., retain a different number of state deltas
+type Config struct {
+	// ImplName is the name of the state data structure
+	ImplName string
+	// ImplConfigs are passed to the Initialize method of the state data structure
+	ImplConfigs map[string]interface{}
+	// DeltaHistorySize is the number of state deltas retained
+	DeltaHistorySize int
+	// StagingFlushInterval is the number of txs after which the state changes are flushed to the
+	// stagingCF, 0 keeps them in memory until the commit
+	StagingFlushInterval int
+	// WALEnabled enables the WAL of the state changes of the tx-batch in progress
+	WALEnabled bool
 }
 
-func loadConfig() {
-	logger.Info("Loading configurations...")
-	stateImplName = viper.GetString("ledger.state.dataStructure.name")
-	stateImplConfigs = viper.GetStringMap("ledger.state.dataStructure.configs")
-	deltaHistorySize = viper.GetInt("ledger.state.deltaHistorySize")
+// LoadConfig reads the configuration of a State from the keys under 'ledger.state'. If configKey
+// is not nil, each setting is read from the key it returns for the key under 'ledger.state'
+func LoadConfig(configKey func(key string) string) (*Config, error) {
+	if configKey == nil {
+		configKey = func(key string) string { return key }
+	}
+	config := &Config{
+		ImplName:             viper.GetString(configKey("ledger.state.dataStructure.name")),
+		ImplConfigs:          viper.GetStringMap(configKey("ledger.state.dataStructure.configs")),
+		DeltaHistorySize:     viper.GetInt(configKey("ledger.state.deltaHistorySize")),
+		StagingFlushInterval: viper.GetInt(configKey("ledger.state.stagingFlushInterval")),
+		WALEnabled:           viper.GetBool(configKey("ledger.state.wal.enabled")),
+	}
 	logger.Info("Configurations loaded. stateImplName=[%s], stateImplConfigs=%s, deltaHistorySize=[%d]",
-		stateImplName, stateImplConfigs, deltaHistorySize)
+		config.ImplName, config.ImplConfigs, config.DeltaHistorySize)
+
+	if len(config.ImplName) == 0 {
+		config.ImplName = detaultStateImpl
+		config.ImplConfigs = nil
+	} else if config.ImplName != "buckettree" && config.ImplName != "trie" && config.ImplName != "patricia" && config.ImplName != "raw" && config.ImplName != "jsondb" {
+		return nil, fmt.Errorf("State data structure '%s' is not valid.", config.ImplName)
+	}
 
-	if len(stateImplName) == 0 {
-		stateImplName = detaultStateImpl
-		stateImplConfigs = nil
-	} else if stateImplName != "buckettree" && stateImplName != "trie" && stateImplName != "raw" {
-		panic(fmt.Errorf("Error during initialization of state implementation. State data structure '%s' is not valid.", stateImplName))
+	if config.DeltaHistorySize < 0 {
+		return nil, fmt.Errorf("Delta history size must be greater than or equal to 0. Current value is %d.", config.DeltaHistorySize)
 	}
 
-	if deltaHistorySize < 0 {
-		panic(fmt.Errorf("Delta history size must be greater than or equal to 0. Current value is %d.", deltaHistorySize))
+	if config.StagingFlushInterval < 0 {
+		return nil, fmt.Errorf("Staging flush interval must be greater than or equal to 0. Current value is %d.", config.StagingFlushInterval)
 	}
+	return config, nil
+}
+
+// initHashProvider loads the hash algorithm, which is shared by all the states of the process
+func initHashProvider() {
+	loadHashProviderOnce.Do(func() {
+		hashAlgorithm := viper.GetString("ledger.state.hashAlgorithm")
+		if len(hashAlgorithm) == 0 {
+			hashAlgorithm = statemgmt.DefaultHashAlgorithm
+		}
+		var err error
+		hashProvider, err = statemgmt.GetHashProvider(hashAlgorithm)
+		if err != nil {
+			panic(fmt.Errorf("Error during initialization of state implementation: %s", err))
+		}
+	})
 }
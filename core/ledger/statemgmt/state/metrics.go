@@ -0,0 +1,35 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	"github.com/hyperledger/fabric/core/metrics"
+)
+
+// the reads and writes mostly hit the in-memory deltas and the caches, hence the buckets
+// from 10 microseconds on
+var stateAccessBuckets = metrics.ExponentialBuckets(0.00001, 4, 10)
+
+var (
+	stateGetLatency = metrics.NewHistogram("state_get_duration_seconds",
+		"Time taken by a Get of the state.", stateAccessBuckets)
+	stateSetLatency = metrics.NewHistogram("state_set_duration_seconds",
+		"Time taken by a Set or a Delete of the state, including the read of the previous value.", stateAccessBuckets)
+	stateHashLatency = metrics.NewHistogramVec("state_hash_duration_seconds",
+		"Time taken by the state implementation to apply the changes of a block and compute the state hash.",
+		metrics.DefBuckets, "implementation")
+)
@@ -23,7 +23,6 @@ import (
 	"github.com/hyperledger/fabric/core/db"
 	"github.com/hyperledger/fabric/core/ledger/statemgmt"
 	"github.com/hyperledger/fabric/core/ledger/testutil"
-	"github.com/tecbot/gorocksdb"
 )
 
 var testDBWrapper = db.NewTestDBWrapper()
@@ -45,7 +44,13 @@ type stateTestWrapper struct {
 }
 
 func newStateTestWrapper(t *testing.T) *stateTestWrapper {
-	return &stateTestWrapper{t, NewState()}
+	return &stateTestWrapper{t, NewState(db.GetDBHandle().KVStore(), newTestConfig(t))}
+}
+
+func newTestConfig(t *testing.T) *Config {
+	config, err := LoadConfig(nil)
+	testutil.AssertNoError(t, err, "Error while loading the state configuration")
+	return config
 }
 
 func (testWrapper *stateTestWrapper) get(chaincodeID string, key string, committed bool) []byte {
@@ -55,14 +60,14 @@ func (testWrapper *stateTestWrapper) get(chaincodeID string, key string, committ
 }
 
 func (testWrapper *stateTestWrapper) getSnapshot() *StateSnapshot {
-	dbSnapshot := db.GetDBHandle().GetSnapshot()
+	dbSnapshot := db.GetDBHandle().KVStore().NewSnapshot()
 	stateSnapshot, err := testWrapper.state.GetSnapshot(0, dbSnapshot)
 	testutil.AssertNoError(testWrapper.t, err, "Error during creation of state snapshot")
 	return stateSnapshot
 }
 
 func (testWrapper *stateTestWrapper) persistAndClearInMemoryChanges(blockNumber uint64) {
-	writeBatch := gorocksdb.NewWriteBatch()
+	writeBatch := db.GetDBHandle().KVStore().NewWriteBatch()
 	defer writeBatch.Destroy()
 	testWrapper.state.AddChangesForPersistence(blockNumber, writeBatch)
 	testDBWrapper.WriteToDB(testWrapper.t, writeBatch)
@@ -0,0 +1,40 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	"github.com/hyperledger/fabric/core/errors"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+)
+
+// PruneOrphanedNodes removes the persisted nodes of the state implementation that are
+// no longer reachable from the current root, if the implementation supports it (see
+// 'statemgmt.Pruner'). The state deltas are pruned as blocks are committed, as per
+// 'ledger.state.deltaHistorySize', and are not affected.
+func (state *State) PruneOrphanedNodes() (*statemgmt.PruneStats, error) {
+	pruner, ok := state.stateImpl.(statemgmt.Pruner)
+	if !ok {
+		return nil, errors.Errorf(errors.NotSupported, "Pruning is not supported by the state implementation [%T]", state.stateImpl)
+	}
+	return pruner.PruneOrphanedNodes()
+}
+
+// IsPruningSupported returns true if the state implementation supports PruneOrphanedNodes
+func (state *State) IsPruningSupported() bool {
+	_, ok := state.stateImpl.(statemgmt.Pruner)
+	return ok
+}
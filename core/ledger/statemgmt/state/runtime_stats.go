@@ -0,0 +1,96 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	"time"
+
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+)
+
+// RuntimeStats describes the tx-batch in progress, for diagnosing a commit that does not
+// progress. Unlike the other methods of State, GetRuntimeStats may be called concurrently
+// with the execution of the txs
+type RuntimeStats struct {
+	// TxInProgress is the uuid of the tx between TxBegin and TxFinish, "" if none
+	TxInProgress string `json:"txInProgress,omitempty"`
+	// TxInProgressSince is the time TxBegin was called for TxInProgress
+	TxInProgressSince time.Time `json:"txInProgressSince,omitempty"`
+	// BatchStarted is the time the tx-batch in progress began changing the state, zero if
+	// there are no changes to commit
+	BatchStarted time.Time `json:"batchStarted,omitempty"`
+	// BatchTxs is the number of successful txs of the tx-batch in progress
+	BatchTxs int `json:"batchTxs"`
+	// PendingKeys is the number of keys changed by the tx-batch and held in memory
+	PendingKeys int `json:"pendingKeys"`
+	// StagedChanges is whether changes of the tx-batch were flushed to the stagingCF
+	StagedChanges bool `json:"stagedChanges"`
+	// WALInUse is whether the txs of the tx-batch are recorded in the WAL
+	WALInUse bool `json:"walInUse"`
+}
+
+// GetRuntimeStats returns the statistics of the tx-batch in progress
+func (state *State) GetRuntimeStats() RuntimeStats {
+	state.runtimeStatsLock.RLock()
+	defer state.runtimeStatsLock.RUnlock()
+	return state.runtimeStats
+}
+
+// updateRuntimeStats publishes the statistics of the tx-batch in progress, after a change
+func (state *State) updateRuntimeStats() {
+	state.runtimeStatsLock.Lock()
+	defer state.runtimeStatsLock.Unlock()
+	stats := &state.runtimeStats
+	if stats.TxInProgress != state.currentTxUUID {
+		stats.TxInProgress = state.currentTxUUID
+		stats.TxInProgressSince = time.Time{}
+		if state.currentTxUUID != "" {
+			stats.TxInProgressSince = time.Now()
+		}
+	}
+	if state.currentTxUUID == "" && len(state.txUUIDs) == 0 && state.pendingKeys == 0 && !state.hasStagedChanges {
+		stats.BatchStarted = time.Time{}
+	} else if stats.BatchStarted.IsZero() {
+		stats.BatchStarted = time.Now()
+	}
+	stats.BatchTxs = len(state.txUUIDs)
+	stats.PendingKeys = state.pendingKeys
+	stats.StagedChanges = state.hasStagedChanges
+	stats.WALInUse = state.walInUse
+}
+
+// countNewKeys returns the number of keys changed by the delta that the changes of the
+// tx-batch do not hold yet
+func (state *State) countNewKeys(delta *statemgmt.StateDelta) int {
+	count := 0
+	for _, chaincodeID := range delta.GetUpdatedChaincodeIds(false) {
+		for key := range delta.GetUpdates(chaincodeID) {
+			if !state.stateDelta.IsUpdatedValueSet(chaincodeID, key) {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+func countKeys(delta *statemgmt.StateDelta) int {
+	count := 0
+	for _, chaincodeID := range delta.GetUpdatedChaincodeIds(false) {
+		count += len(delta.GetUpdates(chaincodeID))
+	}
+	return count
+}
@@ -0,0 +1,233 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/db/kvstore"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+)
+
+// For very large tx-batches, the changes accumulated in memory by the successful txs are flushed to
+// the stagingCF after every 'ledger.state.stagingFlushInterval' txs. This bounds the memory held
+// while the batch is executed. The reads made during the execution look up the stagingCF after the
+// in-memory changes. The staged changes are loaded back when the state hash is computed, because
+// the state implementations take the changes of the whole batch, and are removed from the
+// stagingCF in the same write-batch that commits the block, hence, atomically with the commit.
+//
+// key:   compositeKey
+// value: varint(flags) [rawBytes(value)] [rawBytes(previousValue)]
+const (
+	stagedValueIsSet         = 1
+	stagedPreviousValueIsSet = 2
+)
+
+// flushToStaging moves the changes of the finished txs from memory to the stagingCF. The previous
+// value of a key already staged is kept so that the staged changes read as a single delta
+func (state *State) flushToStaging() error {
+	state.numTxsSinceFlush = 0
+	if state.stateDelta.IsEmpty() {
+		return nil
+	}
+	writeBatch := state.store.NewWriteBatch()
+	defer writeBatch.Destroy()
+	for _, chaincodeID := range state.stateDelta.GetUpdatedChaincodeIds(false) {
+		for key, updatedValue := range state.stateDelta.GetUpdates(chaincodeID) {
+			previousValue := updatedValue.GetPreviousValue()
+			staged, err := state.getStaged(chaincodeID, key)
+			if err != nil {
+				return err
+			}
+			if staged != nil {
+				previousValue = staged.GetPreviousValue()
+			}
+			writeBatch.Put(kvstore.StagingCFName, statemgmt.ConstructCompositeKey(chaincodeID, key),
+				encodeStagedValue(updatedValue.GetValue(), previousValue))
+		}
+	}
+	if err := state.store.Write(writeBatch, false); err != nil {
+		return err
+	}
+	logger.Debug("Flushed state changes of the tx-batch to the stagingCF")
+	state.stateDelta = statemgmt.NewStateDelta()
+	state.pendingKeys = 0
+	state.hasStagedChanges = true
+	state.stagingCFInUse = true
+	state.updateRuntimeStats()
+	return nil
+}
+
+// getStaged returns the staged change to the key, nil if the key is not staged
+func (state *State) getStaged(chaincodeID string, key string) (*statemgmt.UpdatedValue, error) {
+	if !state.hasStagedChanges {
+		return nil, nil
+	}
+	stagedBytes, err := state.store.Get(kvstore.StagingCFName, statemgmt.ConstructCompositeKey(chaincodeID, key))
+	if err != nil || stagedBytes == nil {
+		return nil, err
+	}
+	delta := statemgmt.NewStateDelta()
+	if err := addStagedValue(delta, chaincodeID, key, stagedBytes); err != nil {
+		return nil, err
+	}
+	return delta.Get(chaincodeID, key), nil
+}
+
+// getBatchDeltaInRange returns the changes of the tx-batch to the keys of the chaincode in the range,
+// the in-memory changes taking precedence over the staged changes
+func (state *State) getBatchDeltaInRange(chaincodeID string, startKey string, endKey string) (*statemgmt.StateDelta, error) {
+	if !state.hasStagedChanges {
+		return state.stateDelta, nil
+	}
+	delta := statemgmt.NewStateDelta()
+	itr := state.store.NewIterator(kvstore.StagingCFName)
+	defer itr.Close()
+	for itr.Seek(statemgmt.ConstructCompositeKey(chaincodeID, startKey)); itr.Valid(); itr.Next() {
+		stagedChaincodeID, key := statemgmt.DecodeCompositeKey(itr.Key())
+		if stagedChaincodeID != chaincodeID || (endKey != "" && key > endKey) {
+			break
+		}
+		if err := addStagedValue(delta, chaincodeID, key, itr.Value()); err != nil {
+			return nil, err
+		}
+	}
+	for key, updatedValue := range state.stateDelta.GetUpdates(chaincodeID) {
+		if key < startKey || (endKey != "" && key > endKey) {
+			continue
+		}
+		if updatedValue.IsDelete() {
+			delta.Delete(chaincodeID, key, nil)
+		} else {
+			delta.Set(chaincodeID, key, updatedValue.GetValue(), nil)
+		}
+	}
+	return delta, nil
+}
+
+// loadStagedChanges brings the staged changes back in memory, underneath the in-memory changes.
+// The staged changes are merged one by one as the stagingCF is iterated, so that the changes of the
+// batch are held once in memory. The stagingCF is cleared when the batch is committed or rolled back
+func (state *State) loadStagedChanges() error {
+	if !state.hasStagedChanges {
+		return nil
+	}
+	itr := state.store.NewIterator(kvstore.StagingCFName)
+	defer itr.Close()
+	for itr.SeekToFirst(); itr.Valid(); itr.Next() {
+		chaincodeID, key := statemgmt.DecodeCompositeKey(itr.Key())
+		value, previousValue, err := decodeStagedValue(itr.Value())
+		if err != nil {
+			return err
+		}
+		if inMemory := state.stateDelta.Get(chaincodeID, key); inMemory != nil {
+			// the key changed again after it was staged, its value before the batch is the staged one
+			inMemory.PreviousValue = previousValue
+			continue
+		}
+		if value == nil {
+			state.stateDelta.Delete(chaincodeID, key, previousValue)
+		} else {
+			state.stateDelta.Set(chaincodeID, key, value, previousValue)
+		}
+		state.pendingKeys++
+	}
+	if err := itr.Err(); err != nil {
+		return err
+	}
+	state.hasStagedChanges = false
+	state.updateStateImpl = true
+	state.updateRuntimeStats()
+	return nil
+}
+
+// addStagingCFCleanupForPersistence removes the staged changes in the write-batch that commits the tx-batch
+func (state *State) addStagingCFCleanupForPersistence(writeBatch kvstore.WriteBatch) {
+	if state.stagingCFInUse {
+		state.addStagingCFDeletes(writeBatch)
+	}
+}
+
+func (state *State) addStagingCFDeletes(writeBatch kvstore.WriteBatch) {
+	itr := state.store.NewIterator(kvstore.StagingCFName)
+	defer itr.Close()
+	for itr.SeekToFirst(); itr.Valid(); itr.Next() {
+		writeBatch.Delete(kvstore.StagingCFName, itr.Key())
+	}
+}
+
+// clearStagingCF removes the staged changes of a tx-batch that is rolled back or that was
+// in progress when the peer stopped
+func (state *State) clearStagingCF() {
+	writeBatch := state.store.NewWriteBatch()
+	defer writeBatch.Destroy()
+	state.addStagingCFDeletes(writeBatch)
+	if err := state.store.Write(writeBatch, false); err != nil {
+		logger.Error("Error while clearing the stagingCF: %s", err)
+	}
+}
+
+func encodeStagedValue(value []byte, previousValue []byte) []byte {
+	flags := uint64(0)
+	if value != nil {
+		flags |= stagedValueIsSet
+	}
+	if previousValue != nil {
+		flags |= stagedPreviousValueIsSet
+	}
+	buffer := proto.NewBuffer([]byte{})
+	buffer.EncodeVarint(flags)
+	if value != nil {
+		buffer.EncodeRawBytes(value)
+	}
+	if previousValue != nil {
+		buffer.EncodeRawBytes(previousValue)
+	}
+	return buffer.Bytes()
+}
+
+func addStagedValue(delta *statemgmt.StateDelta, chaincodeID string, key string, stagedBytes []byte) error {
+	value, previousValue, err := decodeStagedValue(stagedBytes)
+	if err != nil {
+		return err
+	}
+	if value == nil {
+		delta.Delete(chaincodeID, key, previousValue)
+	} else {
+		delta.Set(chaincodeID, key, value, previousValue)
+	}
+	return nil
+}
+
+func decodeStagedValue(stagedBytes []byte) ([]byte, []byte, error) {
+	buffer := proto.NewBuffer(stagedBytes)
+	flags, err := buffer.DecodeVarint()
+	if err != nil {
+		return nil, nil, err
+	}
+	var value, previousValue []byte
+	if flags&stagedValueIsSet != 0 {
+		if value, err = buffer.DecodeRawBytes(false); err != nil {
+			return nil, nil, err
+		}
+	}
+	if flags&stagedPreviousValueIsSet != 0 {
+		if previousValue, err = buffer.DecodeRawBytes(false); err != nil {
+			return nil, nil, err
+		}
+	}
+	return value, previousValue, nil
+}
@@ -19,22 +19,24 @@ package state
 import (
 	"encoding/binary"
 	"fmt"
+	"sync"
+	"time"
 
-	"github.com/hyperledger/fabric/core/db"
+	"github.com/hyperledger/fabric/core/db/kvstore"
+	"github.com/hyperledger/fabric/core/errors"
 	"github.com/hyperledger/fabric/core/ledger/statemgmt"
 	"github.com/hyperledger/fabric/core/ledger/statemgmt/buckettree"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt/jsondb"
 	"github.com/hyperledger/fabric/core/ledger/statemgmt/raw"
 	"github.com/hyperledger/fabric/core/ledger/statemgmt/trie"
+	"github.com/hyperledger/fabric/core/ledger/verify"
 	"github.com/op/go-logging"
-	"github.com/tecbot/gorocksdb"
 )
 
 var logger = logging.MustGetLogger("state")
 
 const detaultStateImpl = "buckettree"
 
-var stateImpl statemgmt.HashableState
-
 // State structure for maintaining world state.
 // This encapsulates a particular implementation for managing the state persistence
 // This is not thread safe
@@ -46,57 +48,132 @@ type State struct {
 	txStateDeltaHash      map[string][]byte
 	updateStateImpl       bool
 	historyStateDeltaSize uint64
+	txWriters             map[string][]*TxKeyWrite
+	txUUIDs               []string
+	txStateDeltaHashLock  sync.RWMutex
+	numTxsSinceFlush      int
+	hasStagedChanges      bool
+	stagingCFInUse        bool
+	walSequence           uint64
+	walInUse              bool
+	walFailed             bool
+	store                 kvstore.KVStore
+	pendingKeys           int
+	runtimeStats          RuntimeStats
+	runtimeStatsLock      sync.RWMutex
+	config                *Config
 }
 
-// NewState constructs a new State. This Initializes encapsulated state implementation
-func NewState() *State {
-	initConfig()
-	logger.Info("Initializing state implementation [%s]", stateImplName)
-	switch stateImplName {
+// NewState constructs a new State persisted in the given store with the given configuration (see LoadConfig).
+// This Initializes encapsulated state implementation
+func NewState(store kvstore.KVStore, config *Config) *State {
+	initHashProvider()
+	logger.Info("Initializing state implementation [%s]", config.ImplName)
+	statemgmt.SetHashProvider(hashProvider)
+	var stateImpl statemgmt.HashableState
+	switch config.ImplName {
 	case "buckettree":
-		stateImpl = buckettree.NewStateImpl()
+		stateImpl = buckettree.NewStateImpl(store)
 	case "trie":
-		stateImpl = trie.NewStateTrie()
+		stateImpl = trie.NewStateTrie(store)
+	case "patricia":
+		stateImpl = trie.NewPatriciaTrie(store)
 	case "raw":
-		stateImpl = raw.NewRawState()
+		stateImpl = raw.NewRawState(store)
+	case "jsondb":
+		stateImpl = jsondb.NewStateImpl(store)
 	default:
 		panic("Should not reach here. Configs should have checked for the stateImplName being a valid names ")
 	}
-	err := stateImpl.Initialize(stateImplConfigs)
+	err := stateImpl.Initialize(config.ImplConfigs)
 	if err != nil {
 		panic(fmt.Errorf("Error during initialization of state implementation: %s", err))
 	}
-	return &State{stateImpl, statemgmt.NewStateDelta(), statemgmt.NewStateDelta(), "", make(map[string][]byte),
-		false, uint64(deltaHistorySize)}
+	state := &State{stateImpl, statemgmt.NewStateDelta(), statemgmt.NewStateDelta(), "", make(map[string][]byte),
+		false, uint64(config.DeltaHistorySize), make(map[string][]*TxKeyWrite), nil, sync.RWMutex{}, 0, false, false, 0, false, false,
+		store, 0, RuntimeStats{}, sync.RWMutex{}, config}
+	state.clearStagingCF()
+	if !config.WALEnabled {
+		state.clearWAL()
+		return state
+	}
+	numTxs, err := state.replayWAL()
+	if err != nil {
+		// the txs of the tx-batch have to be executed again
+		logger.Error("Discarding the state changes recovered from the WAL: %s", err)
+		state.ClearInMemoryChanges(false)
+		state.clearWAL()
+	} else if numTxs > 0 {
+		logger.Info("Recovered the state changes of [%d] txs of the tx-batch in progress from the WAL", numTxs)
+	}
+	state.updateRuntimeStats()
+	return state
 }
 
 // TxBegin marks begin of a new tx. If a tx is already in progress, this call panics
 func (state *State) TxBegin(txUUID string) {
 	logger.Debug("txBegin() for txUuid [%s]", txUUID)
 	if state.txInProgress() {
-		panic(fmt.Errorf("A tx [%s] is already in progress. Received call for begin of another tx [%s]", state.currentTxUUID, txUUID))
+		panic(errors.Errorf(errors.TxInProgress, "A tx [%s] is already in progress. Received call for begin of another tx [%s]", state.currentTxUUID, txUUID))
 	}
 	state.currentTxUUID = txUUID
+	state.updateRuntimeStats()
 }
 
 // TxFinish marks the completion of on-going tx. If txUUID is not same as of the on-going tx, this call panics
 func (state *State) TxFinish(txUUID string, txSuccessful bool) {
 	logger.Debug("txFinish() for txUuid [%s], txSuccessful=[%t]", txUUID, txSuccessful)
 	if state.currentTxUUID != txUUID {
-		panic(fmt.Errorf("Different Uuid in tx-begin [%s] and tx-finish [%s]", state.currentTxUUID, txUUID))
+		panic(errors.Errorf(errors.TxInProgress, "Different Uuid in tx-begin [%s] and tx-finish [%s]", state.currentTxUUID, txUUID))
 	}
 	if txSuccessful {
-		if !state.currentTxStateDelta.IsEmpty() {
-			logger.Debug("txFinish() for txUuid [%s] merging state changes", txUUID)
-			state.stateDelta.ApplyChanges(state.currentTxStateDelta)
-			state.txStateDeltaHash[txUUID] = state.currentTxStateDelta.ComputeCryptoHash()
-			state.updateStateImpl = true
-		} else {
-			state.txStateDeltaHash[txUUID] = nil
+		state.applyTxStateDelta(txUUID, state.currentTxStateDelta)
+		if state.config.WALEnabled && !state.walFailed {
+			if err := state.appendToWAL(txUUID, state.currentTxStateDelta); err != nil {
+				// an incomplete WAL must not be replayed, the txs of the tx-batch are executed again after a restart
+				logger.Error("Error while appending the state delta of tx [%s] to the WAL. Disabling the WAL for the tx-batch: %s", txUUID, err)
+				state.walFailed = true
+				state.clearWAL()
+			}
+		}
+		if state.config.StagingFlushInterval > 0 {
+			state.numTxsSinceFlush++
+			if state.numTxsSinceFlush >= state.config.StagingFlushInterval {
+				if err := state.flushToStaging(); err != nil {
+					// the changes remain in memory and the flush is attempted again after the next tx
+					logger.Error("Error while flushing state changes to the stagingCF: %s", err)
+				}
+			}
 		}
 	}
 	state.currentTxStateDelta = statemgmt.NewStateDelta()
 	state.currentTxUUID = ""
+	state.updateRuntimeStats()
+}
+
+// applyTxStateDelta merges the changes of a successful tx into the changes of the tx-batch
+func (state *State) applyTxStateDelta(txUUID string, txStateDelta *statemgmt.StateDelta) {
+	var txStateDeltaHash []byte
+	if !txStateDelta.IsEmpty() {
+		logger.Debug("txFinish() for txUuid [%s] merging state changes", txUUID)
+		state.pendingKeys += state.countNewKeys(txStateDelta)
+		state.stateDelta.ApplyChanges(txStateDelta)
+		txStateDeltaHash = txStateDelta.ComputeCryptoHash()
+		state.recordTxWriter(txUUID, txStateDelta)
+		state.updateStateImpl = true
+	}
+	state.txStateDeltaHashLock.Lock()
+	state.txStateDeltaHash[txUUID] = txStateDeltaHash
+	state.txUUIDs = append(state.txUUIDs, txUUID)
+	state.txStateDeltaHashLock.Unlock()
+}
+
+// GetTxUUIDs returns the uuids of the successful txs of the tx-batch in progress, in the order of
+// their completion. After a restart, these include the txs replayed from the WAL
+func (state *State) GetTxUUIDs() []string {
+	state.txStateDeltaHashLock.RLock()
+	defer state.txStateDeltaHashLock.RUnlock()
+	return append([]string(nil), state.txUUIDs...)
 }
 
 func (state *State) txInProgress() bool {
@@ -106,6 +183,7 @@ func (state *State) txInProgress() bool {
 // Get returns state for chaincodeID and key. If committed is false, this first looks in memory and if missing,
 // pulls from db. If committed is true, this pulls from the db only.
 func (state *State) Get(chaincodeID string, key string, committed bool) ([]byte, error) {
+	defer stateGetLatency.ObserveSince(time.Now())
 	if !committed {
 		valueHolder := state.currentTxStateDelta.Get(chaincodeID, key)
 		if valueHolder != nil {
@@ -115,6 +193,35 @@ func (state *State) Get(chaincodeID string, key string, committed bool) ([]byte,
 		if valueHolder != nil {
 			return valueHolder.GetValue(), nil
 		}
+		valueHolder, err := state.getStaged(chaincodeID, key)
+		if err != nil {
+			return nil, err
+		}
+		if valueHolder != nil {
+			return valueHolder.GetValue(), nil
+		}
+	}
+	return state.stateImpl.Get(chaincodeID, key)
+}
+
+// GetCurrentTxStateDelta returns the changes made so far by the on-going tx
+func (state *State) GetCurrentTxStateDelta() *statemgmt.StateDelta {
+	return state.currentTxStateDelta
+}
+
+// GetBeforeCurrentTx returns state for chaincodeID and key as it was before the on-going tx made any change to it,
+// i.e., including the changes made by the earlier txs of the batch
+func (state *State) GetBeforeCurrentTx(chaincodeID string, key string) ([]byte, error) {
+	valueHolder := state.stateDelta.Get(chaincodeID, key)
+	if valueHolder != nil {
+		return valueHolder.GetValue(), nil
+	}
+	valueHolder, err := state.getStaged(chaincodeID, key)
+	if err != nil {
+		return nil, err
+	}
+	if valueHolder != nil {
+		return valueHolder.GetValue(), nil
 	}
 	return state.stateImpl.Get(chaincodeID, key)
 }
@@ -130,15 +237,31 @@ func (state *State) GetRangeScanIterator(chaincodeID string, startKey string, en
 	if committed {
 		return stateImplItr, nil
 	}
+	batchDelta, err := state.getBatchDeltaInRange(chaincodeID, startKey, endKey)
+	if err != nil {
+		stateImplItr.Close()
+		return nil, err
+	}
 	return newCompositeRangeScanIterator(
 		statemgmt.NewStateDeltaRangeScanIterator(state.currentTxStateDelta, chaincodeID, startKey, endKey),
-		statemgmt.NewStateDeltaRangeScanIterator(state.stateDelta, chaincodeID, startKey, endKey),
+		statemgmt.NewStateDeltaRangeScanIterator(batchDelta, chaincodeID, startKey, endKey),
 		stateImplItr), nil
 }
 
+// GetQueryResultIterator returns an iterator to get the committed keys (and values) of a chaincodeID
+// matching a rich query, if the state implementation supports them
+func (state *State) GetQueryResultIterator(chaincodeID string, query string) (statemgmt.RangeScanIterator, error) {
+	queryableState, ok := state.stateImpl.(statemgmt.QueryableState)
+	if !ok {
+		return nil, statemgmt.ErrQueryNotSupported
+	}
+	return queryableState.GetQueryResultIterator(chaincodeID, query)
+}
+
 // Set sets state to given value for chaincodeID and key. Does not immideatly writes to DB
 func (state *State) Set(chaincodeID string, key string, value []byte) error {
 	logger.Debug("set() chaincodeID=[%s], key=[%s], value=[%#v]", chaincodeID, key, value)
+	defer stateSetLatency.ObserveSince(time.Now())
 	if !state.txInProgress() {
 		panic("State can be changed only in context of a tx.")
 	}
@@ -163,6 +286,7 @@ func (state *State) Set(chaincodeID string, key string, value []byte) error {
 // Delete tracks the deletion of state for chaincodeID and key. Does not immideatly writes to DB
 func (state *State) Delete(chaincodeID string, key string) error {
 	logger.Debug("delete() chaincodeID=[%s], key=[%s]", chaincodeID, key)
+	defer stateSetLatency.ObserveSince(time.Now())
 	if !state.txInProgress() {
 		panic("State can be changed only in context of a tx.")
 	}
@@ -229,6 +353,10 @@ func (state *State) SetMultipleKeys(chaincodeID string, kvs map[string][]byte) e
 // Recomputes only if stateDelta has changed after most recent call to this function
 func (state *State) GetHash() ([]byte, error) {
 	logger.Debug("Enter - GetHash()")
+	if err := state.loadStagedChanges(); err != nil {
+		return nil, err
+	}
+	start := time.Now()
 	if state.updateStateImpl {
 		logger.Debug("updating stateImpl with working-set")
 		state.stateImpl.PrepareWorkingSet(state.stateDelta)
@@ -238,20 +366,99 @@ func (state *State) GetHash() ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
+	stateHashLatency.With(state.config.ImplName).ObserveSince(start)
 	logger.Debug("Exit - GetHash()")
 	return hash, nil
 }
 
-// GetTxStateDeltaHash return the hash of the StateDelta
+// GetCommittedHash returns the crypto-hash of the persisted state. Unlike GetHash, the changes
+// of the on-going tx-batch, including the ones replayed from the WAL, are not taken into account
+func (state *State) GetCommittedHash() ([]byte, error) {
+	if !state.updateStateImpl {
+		// the working-set of the stateImpl may hold the changes of the on-going tx-batch
+		state.stateImpl.ClearWorkingSet(false)
+		state.updateStateImpl = true
+	}
+	return state.stateImpl.ComputeCryptoHash()
+}
+
+// GetRootStateHashDetail returns the crypto-hash of the committed state along with the
+// crypto-hashes of the children of the root of the tree maintained by the state
+// implementation. The changes of the on-going tx-batch are not taken into account.
+func (state *State) GetRootStateHashDetail() (*statemgmt.StateHashDetail, error) {
+	provider, ok := state.stateImpl.(statemgmt.StateHashDetailProvider)
+	if !ok {
+		return nil, errors.Errorf(errors.NotSupported, "The state implementation [%T] does not report the details of the state hash", state.stateImpl)
+	}
+	return provider.GetRootStateHashDetail()
+}
+
+// GetTxStateDeltaHash returns a copy of the map [txUuid of Tx --> cryptoHash(stateChangesMadeByTx)]
+// for the successful txs of the on-going batch. The returned map can be used safely by the caller
+// while the state moves on to the next batch
 func (state *State) GetTxStateDeltaHash() map[string][]byte {
-	return state.txStateDeltaHash
+	state.txStateDeltaHashLock.RLock()
+	defer state.txStateDeltaHashLock.RUnlock()
+	txStateDeltaHash := make(map[string][]byte, len(state.txStateDeltaHash))
+	for txUUID, hash := range state.txStateDeltaHash {
+		txStateDeltaHash[txUUID] = copyHash(hash)
+	}
+	return txStateDeltaHash
+}
+
+// GetTxStateDeltaHashFor returns the hash of the changes made by the tx. The boolean return value
+// is false if the tx is not a successful tx of the on-going batch
+func (state *State) GetTxStateDeltaHashFor(txUUID string) ([]byte, bool) {
+	state.txStateDeltaHashLock.RLock()
+	defer state.txStateDeltaHashLock.RUnlock()
+	hash, ok := state.txStateDeltaHash[txUUID]
+	return copyHash(hash), ok
+}
+
+// copyHash copies the hash keeping the nil hash of the txs that made no changes
+func copyHash(hash []byte) []byte {
+	if hash == nil {
+		return nil
+	}
+	return statemgmt.Copy(hash)
+}
+
+// GetTxStateDeltaAggregateHash returns a hash over the tx state delta hashes of the successful txs
+// of the on-going batch, taken in the order in which the txs finished. This is nil if no tx finished
+// successfully
+func (state *State) GetTxStateDeltaAggregateHash() []byte {
+	state.txStateDeltaHashLock.RLock()
+	defer state.txStateDeltaHashLock.RUnlock()
+	effects := make([]*verify.TxEffects, len(state.txUUIDs))
+	for i, txUUID := range state.txUUIDs {
+		effects[i] = &verify.TxEffects{TxUUID: txUUID, StateDeltaHash: state.txStateDeltaHash[txUUID]}
+	}
+	return verify.ComputeTxStateDeltaAggregateHash(statemgmt.ComputeCryptoHash, effects)
 }
 
 // ClearInMemoryChanges remove from memory all the changes to state
 func (state *State) ClearInMemoryChanges(changesPersisted bool) {
 	state.stateDelta = statemgmt.NewStateDelta()
+	state.pendingKeys = 0
+	state.txStateDeltaHashLock.Lock()
 	state.txStateDeltaHash = make(map[string][]byte)
+	state.txUUIDs = nil
+	state.txStateDeltaHashLock.Unlock()
+	state.txWriters = make(map[string][]*TxKeyWrite)
+	if state.stagingCFInUse && !changesPersisted {
+		state.clearStagingCF()
+	}
+	if state.walInUse && !changesPersisted {
+		state.clearWAL()
+	}
+	state.numTxsSinceFlush = 0
+	state.hasStagedChanges = false
+	state.stagingCFInUse = false
+	state.walSequence = 0
+	state.walInUse = false
+	state.walFailed = false
 	state.stateImpl.ClearWorkingSet(changesPersisted)
+	state.updateRuntimeStats()
 }
 
 // getStateDelta get changes in state after most recent call to method clearInMemoryChanges
@@ -261,13 +468,82 @@ func (state *State) getStateDelta() *statemgmt.StateDelta {
 
 // GetSnapshot returns a snapshot of the global state for the current block. stateSnapshot.Release()
 // must be called once you are done.
-func (state *State) GetSnapshot(blockNumber uint64, dbSnapshot *gorocksdb.Snapshot) (*StateSnapshot, error) {
-	return newStateSnapshot(blockNumber, dbSnapshot)
+func (state *State) GetSnapshot(blockNumber uint64, dbSnapshot kvstore.Snapshot) (*StateSnapshot, error) {
+	return newStateSnapshot(state.stateImpl, blockNumber, dbSnapshot)
+}
+
+// GetSubtreeSnapshot returns a snapshot of the key-values held by the subtrees of the given
+// children of the root of the state tree (see GetRootStateHashDetail). stateSnapshot.Release()
+// must be called once you are done.
+func (state *State) GetSubtreeSnapshot(blockNumber uint64, dbSnapshot kvstore.Snapshot, children []int) (*StateSnapshot, error) {
+	provider, ok := state.stateImpl.(statemgmt.SubtreeSnapshotProvider)
+	if !ok {
+		return nil, errors.Errorf(errors.NotSupported, "The state implementation [%T] does not give the key-values of subtrees", state.stateImpl)
+	}
+	itr, err := provider.GetSubtreeSnapshotIterator(dbSnapshot, children)
+	if err != nil {
+		return nil, err
+	}
+	return &StateSnapshot{blockNumber, itr, dbSnapshot}, nil
+}
+
+// GetBucketTreeShape returns the shape of the tree of buckets of the state implementation.
+// The crypto-hashes of the buckets of two peers are comparable only if the shapes are equal.
+func (state *State) GetBucketTreeShape() (*statemgmt.BucketTreeShape, error) {
+	provider, ok := state.stateImpl.(statemgmt.BucketHashProvider)
+	if !ok {
+		return nil, errors.Errorf(errors.NotSupported, "The state implementation [%T] does not organize the state in buckets", state.stateImpl)
+	}
+	return provider.GetBucketTreeShape(), nil
+}
+
+// GetBucketHashes returns the crypto-hashes of the buckets with the given numbers at the given
+// level of the tree of buckets, as of the given DB snapshot
+func (state *State) GetBucketHashes(dbSnapshot kvstore.Snapshot, level int, bucketNumbers []int) ([][]byte, error) {
+	provider, ok := state.stateImpl.(statemgmt.BucketHashProvider)
+	if !ok {
+		return nil, errors.Errorf(errors.NotSupported, "The state implementation [%T] does not organize the state in buckets", state.stateImpl)
+	}
+	return provider.GetBucketHashes(dbSnapshot, level, bucketNumbers)
+}
+
+// GetBucketsSnapshot returns a snapshot of the key-values held by the buckets with the given
+// numbers at the lowest level of the tree of buckets. stateSnapshot.Release() must be called
+// once you are done.
+func (state *State) GetBucketsSnapshot(blockNumber uint64, dbSnapshot kvstore.Snapshot, bucketNumbers []int) (*StateSnapshot, error) {
+	provider, ok := state.stateImpl.(statemgmt.BucketHashProvider)
+	if !ok {
+		return nil, errors.Errorf(errors.NotSupported, "The state implementation [%T] does not organize the state in buckets", state.stateImpl)
+	}
+	itr, err := provider.GetBucketsSnapshotIterator(dbSnapshot, bucketNumbers)
+	if err != nil {
+		return nil, err
+	}
+	return &StateSnapshot{blockNumber, itr, dbSnapshot}, nil
+}
+
+// GetRangeScanIteratorFromSnapshot returns an iterator over the key-values of the chaincodeID in
+// the range of keys (see GetRangeScanIterator) as held by the DB snapshot
+func (state *State) GetRangeScanIteratorFromSnapshot(dbSnapshot kvstore.Snapshot, chaincodeID string, startKey string, endKey string) (statemgmt.RangeScanIterator, error) {
+	provider, ok := state.stateImpl.(statemgmt.SnapshotRangeScanProvider)
+	if !ok {
+		return nil, errors.Errorf(errors.NotSupported, "The state implementation [%T] does not give range scans of DB snapshots", state.stateImpl)
+	}
+	return provider.GetRangeScanIteratorFromSnapshot(dbSnapshot, chaincodeID, startKey, endKey)
 }
 
 // FetchStateDeltaFromDB fetches the StateDelta corrsponding to given blockNumber
 func (state *State) FetchStateDeltaFromDB(blockNumber uint64) (*statemgmt.StateDelta, error) {
-	stateDeltaBytes, err := db.GetDBHandle().GetFromStateDeltaCF(encodeStateDeltaKey(blockNumber))
+	return fetchStateDelta(state.store, blockNumber)
+}
+
+// FetchStateDeltaFromSnapshot fetches the StateDelta corresponding to given blockNumber as held by the DB snapshot
+func (state *State) FetchStateDeltaFromSnapshot(dbSnapshot kvstore.Snapshot, blockNumber uint64) (*statemgmt.StateDelta, error) {
+	return fetchStateDelta(dbSnapshot, blockNumber)
+}
+
+func fetchStateDelta(reader kvstore.Reader, blockNumber uint64) (*statemgmt.StateDelta, error) {
+	stateDeltaBytes, err := reader.Get(kvstore.StateDeltaCFName, encodeStateDeltaKey(blockNumber))
 	if err != nil {
 		return nil, err
 	}
@@ -279,9 +555,34 @@ func (state *State) FetchStateDeltaFromDB(blockNumber uint64) (*statemgmt.StateD
 	return stateDelta, nil
 }
 
+// RemoveStateDeltas removes the StateDeltas corresponding to the blocks from fromBlockNumber to
+// toBlockNumber, along with their entries in the write index
+func (state *State) RemoveStateDeltas(fromBlockNumber uint64, toBlockNumber uint64) error {
+	writeBatch := state.store.NewWriteBatch()
+	defer writeBatch.Destroy()
+	for blockNumber := fromBlockNumber; blockNumber <= toBlockNumber; blockNumber++ {
+		state.deleteWriteIndexForBlock(blockNumber, writeBatch)
+		writeBatch.Delete(kvstore.StateDeltaCFName, encodeStateDeltaKey(blockNumber))
+	}
+	return state.store.Write(writeBatch, false)
+}
+
+// PutStateDelta persists the StateDelta of the given block without changing the state. Like the
+// deltas applied through state transfer, the delta is not added to the write index. This is to be
+// used only when rebuilding a db
+func (state *State) PutStateDelta(blockNumber uint64, stateDelta *statemgmt.StateDelta) error {
+	writeBatch := state.store.NewWriteBatch()
+	defer writeBatch.Destroy()
+	writeBatch.Put(kvstore.StateDeltaCFName, encodeStateDeltaKey(blockNumber), stateDelta.Marshal())
+	return state.store.Write(writeBatch, false)
+}
+
 // AddChangesForPersistence adds key-value pairs to writeBatch
-func (state *State) AddChangesForPersistence(blockNumber uint64, writeBatch *gorocksdb.WriteBatch) {
+func (state *State) AddChangesForPersistence(blockNumber uint64, writeBatch kvstore.WriteBatch) {
 	logger.Debug("state.addChangesForPersistence()...start")
+	if err := state.loadStagedChanges(); err != nil {
+		panic(fmt.Errorf("Error while loading the staged state changes: %s", err))
+	}
 	if state.updateStateImpl {
 		state.stateImpl.PrepareWorkingSet(state.stateDelta)
 		state.updateStateImpl = false
@@ -289,13 +590,16 @@ func (state *State) AddChangesForPersistence(blockNumber uint64, writeBatch *gor
 	state.stateImpl.AddChangesForPersistence(writeBatch)
 
 	serializedStateDelta := state.stateDelta.Marshal()
-	cf := db.GetDBHandle().StateDeltaCF
 	logger.Debug("Adding state-delta corresponding to block number[%d]", blockNumber)
-	writeBatch.PutCF(cf, encodeStateDeltaKey(blockNumber), serializedStateDelta)
+	writeBatch.Put(kvstore.StateDeltaCFName, encodeStateDeltaKey(blockNumber), serializedStateDelta)
+	state.addWriteIndexForPersistence(blockNumber, writeBatch)
+	state.addStagingCFCleanupForPersistence(writeBatch)
+	state.addWALCleanupForPersistence(writeBatch)
 	if blockNumber >= state.historyStateDeltaSize {
 		blockNumberToDelete := blockNumber - state.historyStateDeltaSize
 		logger.Debug("Deleting state-delta corresponding to block number[%d]", blockNumberToDelete)
-		writeBatch.DeleteCF(cf, encodeStateDeltaKey(blockNumberToDelete))
+		state.deleteWriteIndexForBlock(blockNumberToDelete, writeBatch)
+		writeBatch.Delete(kvstore.StateDeltaCFName, encodeStateDeltaKey(blockNumberToDelete))
 	} else {
 		logger.Debug("Not deleting previous state-delta. Block number [%d] is smaller than historyStateDeltaSize [%d]",
 			blockNumber, state.historyStateDeltaSize)
@@ -309,6 +613,8 @@ func (state *State) AddChangesForPersistence(blockNumber uint64, writeBatch *gor
 func (state *State) ApplyStateDelta(delta *statemgmt.StateDelta) {
 	state.stateDelta = delta
 	state.updateStateImpl = true
+	state.pendingKeys = countKeys(delta)
+	state.updateRuntimeStats()
 }
 
 // CommitStateDelta commits the changes from state.ApplyStateDelta to the
@@ -319,12 +625,10 @@ func (state *State) CommitStateDelta() error {
 		state.updateStateImpl = false
 	}
 
-	writeBatch := gorocksdb.NewWriteBatch()
+	writeBatch := state.store.NewWriteBatch()
 	defer writeBatch.Destroy()
 	state.stateImpl.AddChangesForPersistence(writeBatch)
-	opt := gorocksdb.NewDefaultWriteOptions()
-	defer opt.Destroy()
-	return db.GetDBHandle().DB.Write(opt, writeBatch)
+	return state.store.Write(writeBatch, false)
 }
 
 // DeleteState deletes ALL state keys/values from the DB. This is generally
@@ -332,7 +636,7 @@ func (state *State) CommitStateDelta() error {
 // a snapshot.
 func (state *State) DeleteState() error {
 	state.ClearInMemoryChanges(false)
-	err := db.GetDBHandle().DeleteState()
+	err := state.store.Clear(kvstore.StateCFName, kvstore.StateDeltaCFName, kvstore.DocumentsCFName)
 	if err != nil {
 		logger.Error("Error deleting state", err)
 	}
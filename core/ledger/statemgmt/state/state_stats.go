@@ -0,0 +1,63 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	"github.com/hyperledger/fabric/core/db/kvstore"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+)
+
+// GetStateStats scans the committed state and the retained state deltas and reports
+// the number of keys and bytes per chaincode, the size of the delta of each block,
+// and, if the state implementation supports it, statistics about the hash tree and
+// the read-repairs of the structures derived from the state.
+// This reads the entire state and is meant for operators rather than for the
+// transaction processing path.
+func (state *State) GetStateStats() (*statemgmt.StateStats, error) {
+	dbSnapshot := state.store.NewSnapshot()
+	defer dbSnapshot.Release()
+
+	stats := statemgmt.NewStateStats()
+	stateItr, err := state.stateImpl.GetStateSnapshotIterator(dbSnapshot)
+	if err != nil {
+		return nil, err
+	}
+	defer stateItr.Close()
+	for stateItr.Next() {
+		compositeKey, value := stateItr.GetRawKeyValue()
+		chaincodeID, key := statemgmt.DecodeCompositeKey(compositeKey)
+		stats.AddKeyValue(chaincodeID, key, value)
+	}
+
+	deltaItr := dbSnapshot.NewIterator(kvstore.StateDeltaCFName)
+	defer deltaItr.Close()
+	for deltaItr.SeekToFirst(); deltaItr.Valid(); deltaItr.Next() {
+		stats.DeltaSizes[decodeStateDeltaKey(deltaItr.Key())] = uint64(len(deltaItr.Value()))
+	}
+
+	if treeStatsProvider, ok := state.stateImpl.(statemgmt.TreeStatsProvider); ok {
+		stats.Tree, err = treeStatsProvider.GetTreeStats(dbSnapshot)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if readRepairStatsProvider, ok := state.stateImpl.(statemgmt.ReadRepairStatsProvider); ok {
+		stats.ReadRepairs = readRepairStatsProvider.GetReadRepairCounts()
+	}
+	logger.Debug("State stats: totalKeys=[%d], totalBytes=[%d]", stats.TotalKeys, stats.TotalBytes)
+	return stats, nil
+}
@@ -17,9 +17,17 @@ limitations under the License.
 package state
 
 import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
 	"testing"
 
+	"github.com/hyperledger/fabric/core/db"
+	"github.com/hyperledger/fabric/core/db/kvstore"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
 	"github.com/hyperledger/fabric/core/ledger/testutil"
+	"github.com/spf13/viper"
 )
 
 func TestStateChanges(t *testing.T) {
@@ -169,3 +177,387 @@ func TestStateDeltaSizeSetting(t *testing.T) {
 		t.Fatalf("Error reading historyStateDeltaSize. Expected 500, but got %d", state.historyStateDeltaSize)
 	}
 }
+
+func TestStateStats(t *testing.T) {
+	stateTestWrapper, state := createFreshDBAndConstructState(t)
+	state.TxBegin("txUuid")
+	state.Set("chaincode1", "key1", []byte("value1"))
+	state.Set("chaincode1", "key2", []byte("value2"))
+	state.Set("chaincode2", "key3", []byte("value03"))
+	state.TxFinish("txUuid", true)
+	stateTestWrapper.persistAndClearInMemoryChanges(0)
+
+	state.TxBegin("txUuid")
+	state.Delete("chaincode1", "key2")
+	state.TxFinish("txUuid", true)
+	stateTestWrapper.persistAndClearInMemoryChanges(1)
+
+	stats, err := state.GetStateStats()
+	testutil.AssertNoError(t, err, "Error getting state stats")
+	testutil.AssertEquals(t, stats.TotalKeys, uint64(2))
+	testutil.AssertEquals(t, stats.TotalBytes, uint64(21))
+	testutil.AssertEquals(t, stats.Chaincodes["chaincode1"], &statemgmt.ChaincodeStateStats{Keys: 1, Bytes: 10})
+	testutil.AssertEquals(t, stats.Chaincodes["chaincode2"], &statemgmt.ChaincodeStateStats{Keys: 1, Bytes: 11})
+	testutil.AssertEquals(t, len(stats.DeltaSizes), 2)
+	testutil.AssertEquals(t, stats.DeltaSizes[1], uint64(len(stateTestWrapper.fetchStateDeltaFromDB(1).Marshal())))
+	if state.config.ImplName == "buckettree" {
+		testutil.AssertNotNil(t, stats.Tree)
+		testutil.AssertEquals(t, stats.Tree.OccupiedBuckets <= 2, true)
+	}
+}
+
+func TestStateHashDetail(t *testing.T) {
+	stateTestWrapper, state := createFreshDBAndConstructState(t)
+	detail, err := state.GetRootStateHashDetail()
+	testutil.AssertNoError(t, err, "Error getting state hash detail")
+	testutil.AssertNil(t, detail.RootHash)
+	testutil.AssertEquals(t, len(detail.Children), 0)
+
+	state.TxBegin("txUuid")
+	for i := 0; i < 10; i++ {
+		state.Set("chaincode1", fmt.Sprintf("key%d", i), []byte(fmt.Sprintf("value%d", i)))
+	}
+	state.TxFinish("txUuid", true)
+	stateHash, err := state.GetHash()
+	testutil.AssertNoError(t, err, "Error computing state hash")
+	stateTestWrapper.persistAndClearInMemoryChanges(0)
+
+	// the changes of the on-going tx-batch are not reported
+	state.TxBegin("txUuid")
+	state.Set("chaincode1", "key0", []byte("newValue"))
+	state.TxFinish("txUuid", true)
+
+	detail, err = state.GetRootStateHashDetail()
+	testutil.AssertNoError(t, err, "Error getting state hash detail")
+	testutil.AssertEquals(t, detail.RootHash, stateHash)
+	if state.config.ImplName != "raw" {
+		testutil.AssertNotEquals(t, len(detail.Children), 0)
+		for _, child := range detail.Children {
+			testutil.AssertNotNil(t, child.Hash)
+		}
+	}
+}
+
+func TestStateKeyWrites(t *testing.T) {
+	stateTestWrapper, state := createFreshDBAndConstructState(t)
+	state.historyStateDeltaSize = 2
+
+	state.TxBegin("txUuid1")
+	state.Set("chaincode1", "key1", []byte("value1"))
+	state.TxFinish("txUuid1", true)
+	state.TxBegin("txUuid2")
+	state.Set("chaincode1", "key1", []byte("value2"))
+	state.TxFinish("txUuid2", true)
+	stateTestWrapper.persistAndClearInMemoryChanges(0)
+
+	state.TxBegin("txUuid3")
+	state.Delete("chaincode1", "key1")
+	state.TxFinish("txUuid3", true)
+	state.TxBegin("txUuid4")
+	state.Set("chaincode1", "key1", []byte("value4"))
+	state.TxFinish("txUuid4", false)
+	stateTestWrapper.persistAndClearInMemoryChanges(1)
+
+	writes, err := state.GetKeyWrites("chaincode1", "key1")
+	testutil.AssertNoError(t, err, "Error getting key writes")
+	block1Writes := &KeyWrite{1, "txUuid3", nil, []*TxKeyWrite{{"txUuid3", nil}}}
+	testutil.AssertEquals(t, writes, []*KeyWrite{
+		{0, "txUuid2", []byte("value2"), []*TxKeyWrite{
+			{"txUuid1", statemgmt.ComputeCryptoHash([]byte("value1"))},
+			{"txUuid2", statemgmt.ComputeCryptoHash([]byte("value2"))},
+		}},
+		block1Writes,
+	})
+
+	// The entries of block 0 are removed along with its state delta
+	state.TxBegin("txUuid5")
+	state.Set("chaincode1", "key2", []byte("value5"))
+	state.TxFinish("txUuid5", true)
+	stateTestWrapper.persistAndClearInMemoryChanges(2)
+	writes, _ = state.GetKeyWrites("chaincode1", "key1")
+	testutil.AssertEquals(t, writes, []*KeyWrite{block1Writes})
+	indexEntry, _ := db.GetDBHandle().GetFromIndexesCF(encodeWriteIndexKey(statemgmt.ConstructCompositeKey("chaincode1", "key1"), 0))
+	testutil.AssertNil(t, indexEntry)
+
+	// an entry holding only the last transaction of the block, as written before all the
+	// transactions were recorded
+	testutil.AssertNoError(t, db.GetDBHandle().Put(db.GetDBHandle().IndexesCF,
+		encodeWriteIndexKey(statemgmt.ConstructCompositeKey("chaincode1", "key2"), 2), []byte("txUuid5")), "Error writing the index entry")
+	writes, _ = state.GetKeyWrites("chaincode1", "key2")
+	testutil.AssertEquals(t, writes, []*KeyWrite{
+		{2, "txUuid5", []byte("value5"), []*TxKeyWrite{{"txUuid5", statemgmt.ComputeCryptoHash([]byte("value5"))}}},
+	})
+}
+
+func TestStateTxStateDeltaHashes(t *testing.T) {
+	_, state := createFreshDBAndConstructState(t)
+	testutil.AssertNil(t, state.GetTxStateDeltaAggregateHash())
+	state.TxBegin("txUuid1")
+	state.Set("chaincode1", "key1", []byte("value1"))
+	state.TxFinish("txUuid1", true)
+	state.TxBegin("txUuid2")
+	state.TxFinish("txUuid2", true)
+
+	// changes to the returned map do not affect the state
+	txDeltaHashes := state.GetTxStateDeltaHash()
+	txDeltaHashes["txUuid1"][0]++
+	delete(txDeltaHashes, "txUuid2")
+	hash, ok := state.GetTxStateDeltaHashFor("txUuid1")
+	testutil.AssertEquals(t, ok, true)
+	testutil.AssertEquals(t, hash, testutil.ComputeCryptoHash([]byte("chaincode1key1value1")))
+	hash, ok = state.GetTxStateDeltaHashFor("txUuid2")
+	testutil.AssertEquals(t, ok, true)
+	testutil.AssertNil(t, hash)
+	_, ok = state.GetTxStateDeltaHashFor("txUuid3")
+	testutil.AssertEquals(t, ok, false)
+
+	// the aggregate hash depends on the order of the txs
+	aggregateHash := state.GetTxStateDeltaAggregateHash()
+	testutil.AssertNotNil(t, aggregateHash)
+	state.ClearInMemoryChanges(false)
+	testutil.AssertEquals(t, len(txDeltaHashes), 1)
+	testutil.AssertNil(t, state.GetTxStateDeltaAggregateHash())
+	state.TxBegin("txUuid2")
+	state.TxFinish("txUuid2", true)
+	state.TxBegin("txUuid1")
+	state.Set("chaincode1", "key1", []byte("value1"))
+	state.TxFinish("txUuid1", true)
+	testutil.AssertNotEquals(t, state.GetTxStateDeltaAggregateHash(), aggregateHash)
+}
+
+func TestStateRuntimeStats(t *testing.T) {
+	_, state := createFreshDBAndConstructState(t)
+	state.TxBegin("txUuid1")
+	stats := state.GetRuntimeStats()
+	testutil.AssertEquals(t, stats.TxInProgress, "txUuid1")
+	testutil.AssertEquals(t, stats.TxInProgressSince.IsZero(), false)
+	batchStarted := stats.BatchStarted
+	testutil.AssertEquals(t, batchStarted.IsZero(), false)
+	state.Set("chaincode1", "key1", []byte("value1"))
+	state.Set("chaincode1", "key2", []byte("value2"))
+	state.TxFinish("txUuid1", true)
+
+	state.TxBegin("txUuid2")
+	state.Set("chaincode1", "key2", []byte("value2_new"))
+	state.Set("chaincode2", "key1", []byte("value1"))
+	state.TxFinish("txUuid2", true)
+	stats = state.GetRuntimeStats()
+	testutil.AssertEquals(t, stats.TxInProgress, "")
+	testutil.AssertEquals(t, stats.BatchTxs, 2)
+	testutil.AssertEquals(t, stats.PendingKeys, 3)
+	testutil.AssertEquals(t, stats.BatchStarted, batchStarted)
+
+	state.ClearInMemoryChanges(false)
+	testutil.AssertEquals(t, state.GetRuntimeStats(), RuntimeStats{})
+}
+
+func TestStateStagingFlush(t *testing.T) {
+	executeBatch := func(flushInterval int) ([]byte, *statemgmt.StateDelta) {
+		stateTestWrapper, state := createFreshDBAndConstructState(t)
+		state.TxBegin("txUuid0")
+		state.Set("chaincode1", "key1", []byte("value0"))
+		state.Set("chaincode1", "key2", []byte("value0"))
+		state.TxFinish("txUuid0", true)
+		stateTestWrapper.persistAndClearInMemoryChanges(0)
+
+		state.config.StagingFlushInterval = flushInterval
+		for i := 1; i <= 5; i++ {
+			txUUID := fmt.Sprintf("txUuid%d", i)
+			state.TxBegin(txUUID)
+			state.Set("chaincode1", "key1", []byte(fmt.Sprintf("value%d", i)))
+			state.Set("chaincode1", fmt.Sprintf("key%d", i+2), []byte("value"))
+			if i == 3 {
+				state.Delete("chaincode1", "key2")
+				state.Delete("chaincode1", "key4")
+			}
+			state.TxFinish(txUUID, true)
+			// the changes of the finished txs are visible whether staged or in memory
+			testutil.AssertEquals(t, stateTestWrapper.get("chaincode1", "key1", false), []byte(fmt.Sprintf("value%d", i)))
+			testutil.AssertEquals(t, stateTestWrapper.get("chaincode1", "key1", true), []byte("value0"))
+		}
+		testutil.AssertNil(t, stateTestWrapper.get("chaincode1", "key2", false))
+		testutil.AssertNil(t, stateTestWrapper.get("chaincode1", "key4", false))
+		testutil.AssertEquals(t, stateTestWrapper.get("chaincode1", "key7", false), []byte("value"))
+		itr, err := state.GetRangeScanIterator("chaincode1", "key1", "key5", false)
+		testutil.AssertNoError(t, err, "Error while creating range scan iterator")
+		var keys []string
+		for itr.Next() {
+			k, _ := itr.GetKeyValue()
+			keys = append(keys, k)
+		}
+		itr.Close()
+		sort.Strings(keys)
+		testutil.AssertEquals(t, keys, []string{"key1", "key3", "key5"})
+		if flushInterval > 0 {
+			testutil.AssertEquals(t, state.stagingCFInUse, true)
+		}
+
+		stateHash, err := state.GetHash()
+		testutil.AssertNoError(t, err, "Error while computing state hash")
+		stateTestWrapper.persistAndClearInMemoryChanges(1)
+		itr2 := db.GetDBHandle().GetIterator(db.GetDBHandle().StagingCF)
+		defer itr2.Close()
+		itr2.SeekToFirst()
+		testutil.AssertEquals(t, itr2.Valid(), false)
+		return stateHash, stateTestWrapper.fetchStateDeltaFromDB(1)
+	}
+	expectedHash, expectedDelta := executeBatch(0)
+	stagedHash, stagedDelta := executeBatch(2)
+	testutil.AssertEquals(t, stagedHash, expectedHash)
+	testutil.AssertEquals(t, stagedDelta, expectedDelta)
+	testutil.AssertEquals(t, stagedDelta.Get("chaincode1", "key1").GetPreviousValue(), []byte("value0"))
+
+	// the staged changes of a rolled back batch are discarded
+	stateTestWrapper, state := createFreshDBAndConstructState(t)
+	state.config.StagingFlushInterval = 1
+	state.TxBegin("txUuid1")
+	state.Set("chaincode1", "key1", []byte("value1"))
+	state.TxFinish("txUuid1", true)
+	testutil.AssertEquals(t, stateTestWrapper.get("chaincode1", "key1", false), []byte("value1"))
+	state.ClearInMemoryChanges(false)
+	testutil.AssertNil(t, stateTestWrapper.get("chaincode1", "key1", false))
+}
+
+func TestStateWAL(t *testing.T) {
+	viper.Set("ledger.state.wal.enabled", true)
+	defer viper.Set("ledger.state.wal.enabled", false)
+	stateTestWrapper, state := createFreshDBAndConstructState(t)
+	committedHash, err := state.GetCommittedHash()
+	testutil.AssertNoError(t, err, "Error while computing committed state hash")
+	state.TxBegin("txUuid1")
+	state.Set("chaincode1", "key1", []byte("value1"))
+	state.Set("chaincode1", "key2", []byte("value2"))
+	state.TxFinish("txUuid1", true)
+	state.TxBegin("txUuid2")
+	state.Set("chaincode1", "key1", []byte("newValue1"))
+	state.TxFinish("txUuid2", false)
+	state.TxBegin("txUuid3")
+	state.TxFinish("txUuid3", true)
+	state.TxBegin("txUuid4")
+	state.Delete("chaincode1", "key2")
+	state.TxFinish("txUuid4", true)
+	expectedHash, err := state.GetHash()
+	testutil.AssertNoError(t, err, "Error while computing state hash")
+	expectedTxHashes := state.GetTxStateDeltaHash()
+
+	// a restart recovers the changes of the tx-batch in progress
+	stateTestWrapper = newStateTestWrapper(t)
+	state = stateTestWrapper.state
+	testutil.AssertEquals(t, state.GetTxUUIDs(), []string{"txUuid1", "txUuid3", "txUuid4"})
+	testutil.AssertEquals(t, state.GetTxStateDeltaHash(), expectedTxHashes)
+	testutil.AssertEquals(t, stateTestWrapper.get("chaincode1", "key1", false), []byte("value1"))
+	testutil.AssertNil(t, stateTestWrapper.get("chaincode1", "key2", false))
+	stateHash, err := state.GetHash()
+	testutil.AssertNoError(t, err, "Error while computing state hash")
+	testutil.AssertEquals(t, stateHash, expectedHash)
+	// the replayed changes are not part of the committed state
+	stateHash, err = state.GetCommittedHash()
+	testutil.AssertNoError(t, err, "Error while computing committed state hash")
+	testutil.AssertEquals(t, stateHash, committedHash)
+	stateHash, err = state.GetHash()
+	testutil.AssertNoError(t, err, "Error while computing state hash")
+	testutil.AssertEquals(t, stateHash, expectedHash)
+
+	// the txs after the restart are appended to the recovered ones
+	state.TxBegin("txUuid5")
+	state.Set("chaincode1", "key3", []byte("value3"))
+	state.TxFinish("txUuid5", true)
+	stateTestWrapper = newStateTestWrapper(t)
+	state = stateTestWrapper.state
+	testutil.AssertEquals(t, state.GetTxUUIDs(), []string{"txUuid1", "txUuid3", "txUuid4", "txUuid5"})
+
+	// the WAL is removed with the commit of the block
+	stateTestWrapper.persistAndClearInMemoryChanges(0)
+	testutil.AssertEquals(t, stateTestWrapper.get("chaincode1", "key3", true), []byte("value3"))
+	stateTestWrapper = newStateTestWrapper(t)
+	testutil.AssertEquals(t, len(stateTestWrapper.state.GetTxUUIDs()), 0)
+
+	// and with the rollback of the tx-batch
+	state = stateTestWrapper.state
+	state.TxBegin("txUuid6")
+	state.Set("chaincode1", "key4", []byte("value4"))
+	state.TxFinish("txUuid6", true)
+	state.ClearInMemoryChanges(false)
+	stateTestWrapper = newStateTestWrapper(t)
+	testutil.AssertEquals(t, len(stateTestWrapper.state.GetTxUUIDs()), 0)
+	testutil.AssertNil(t, stateTestWrapper.get("chaincode1", "key4", false))
+}
+
+func TestStatesInSeparateDBs(t *testing.T) {
+	stateTestWrapper, state := createFreshDBAndConstructState(t)
+	otherDBPath := viper.GetString("peer.fileSystemPath") + "/otherdb"
+	os.RemoveAll(otherDBPath)
+	defer os.RemoveAll(otherDBPath)
+	otherDB, err := db.OpenDB(otherDBPath)
+	testutil.AssertNoError(t, err, "Error while opening the other DB")
+	otherState := NewState(otherDB.KVStore(), newTestConfig(t))
+
+	state.TxBegin("txUuid1")
+	state.Set("chaincode1", "key1", []byte("value1"))
+	state.TxFinish("txUuid1", true)
+	stateTestWrapper.persistAndClearInMemoryChanges(0)
+
+	otherState.TxBegin("txUuid1")
+	otherState.Set("chaincode1", "key1", []byte("otherValue1"))
+	otherState.TxFinish("txUuid1", true)
+	writeBatch := otherDB.KVStore().NewWriteBatch()
+	defer writeBatch.Destroy()
+	otherState.AddChangesForPersistence(0, writeBatch)
+	testutil.AssertNoError(t, otherDB.KVStore().Write(writeBatch, false), "Error while writing to the other DB")
+	otherState.ClearInMemoryChanges(true)
+
+	testutil.AssertEquals(t, stateTestWrapper.get("chaincode1", "key1", true), []byte("value1"))
+	otherValue, err := otherState.Get("chaincode1", "key1", true)
+	testutil.AssertNoError(t, err, "Error while getting state")
+	testutil.AssertEquals(t, otherValue, []byte("otherValue1"))
+
+	hash, _ := state.GetHash()
+	otherHash, _ := otherState.GetHash()
+	testutil.AssertNotEquals(t, hash, otherHash)
+
+	// the shared handle is not affected by closing the other DB
+	otherDB.CloseDB()
+	testutil.AssertEquals(t, stateTestWrapper.get("chaincode1", "key1", true), []byte("value1"))
+}
+
+func TestStateOnLevelDB(t *testing.T) {
+	setKeys := func(state *State) {
+		state.TxBegin("txUuid")
+		state.Set("chaincode1", "key1", []byte("value1"))
+		state.Set("chaincode2", "key2", []byte("value2"))
+		state.TxFinish("txUuid", true)
+	}
+	persist := func(state *State, store kvstore.KVStore) {
+		writeBatch := store.NewWriteBatch()
+		defer writeBatch.Destroy()
+		state.AddChangesForPersistence(0, writeBatch)
+		testutil.AssertNoError(t, store.Write(writeBatch, true), "Error while writing the state")
+		state.ClearInMemoryChanges(true)
+	}
+	_, rocksDBState := createFreshDBAndConstructState(t)
+	setKeys(rocksDBState)
+	persist(rocksDBState, db.GetDBHandle().KVStore())
+	expectedHash, err := rocksDBState.GetHash()
+	testutil.AssertNoError(t, err, "Error while computing the hash")
+
+	dbPath, err := ioutil.TempDir("", "stateleveldb")
+	testutil.AssertNoError(t, err, "Error while creating a temp dir")
+	defer os.RemoveAll(dbPath)
+	store, err := kvstore.NewLevelDBKVStore(dbPath)
+	testutil.AssertNoError(t, err, "Error while opening goleveldb")
+	defer store.Close()
+	state := NewState(store, newTestConfig(t))
+	setKeys(state)
+	persist(state, store)
+	value, err := state.Get("chaincode1", "key1", true)
+	testutil.AssertNoError(t, err, "Error while reading the state")
+	testutil.AssertEquals(t, value, []byte("value1"))
+	hash, err := state.GetHash()
+	testutil.AssertNoError(t, err, "Error while computing the hash")
+	testutil.AssertEquals(t, hash, expectedHash)
+
+	testutil.AssertNoError(t, state.DeleteState(), "Error while deleting the state")
+	value, err = state.Get("chaincode1", "key1", true)
+	testutil.AssertNoError(t, err, "Error while reading the state")
+	testutil.AssertNil(t, value)
+}
@@ -0,0 +1,114 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/db/kvstore"
+	"github.com/hyperledger/fabric/core/errors"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+)
+
+// When 'ledger.state.wal.enabled' is set, the state delta of each successful tx is appended to the
+// walCF, with a synchronous write, when the tx finishes. The changes of the tx-batch in progress are
+// otherwise held only in memory (or in the stagingCF, which is cleared on restart) and are lost if
+// the peer stops before the block is committed. On restart, the deltas in the walCF are replayed,
+// in order, so that the txs of the batch need not be executed again (see GetTxUUIDs). The entries are
+// removed in the same write-batch that commits the block, hence, atomically with the commit, and
+// when the tx-batch is rolled back.
+//
+// key:   uint64(sequence number of the tx in the tx-batch)
+// value: rawBytes(txUUID) rawBytes(marshalled tx state delta)
+
+// appendToWAL records the state delta of a successful tx
+func (state *State) appendToWAL(txUUID string, txStateDelta *statemgmt.StateDelta) error {
+	buffer := proto.NewBuffer([]byte{})
+	buffer.EncodeRawBytes([]byte(txUUID))
+	buffer.EncodeRawBytes(txStateDelta.Marshal())
+	writeBatch := state.store.NewWriteBatch()
+	defer writeBatch.Destroy()
+	writeBatch.Put(kvstore.WalCFName, encodeUint64(state.walSequence), buffer.Bytes())
+	if err := state.store.Write(writeBatch, true); err != nil {
+		return err
+	}
+	state.walSequence++
+	state.walInUse = true
+	return nil
+}
+
+// replayWAL applies the tx state deltas recorded in the walCF by a previous run of the peer and
+// returns the number of the txs replayed
+func (state *State) replayWAL() (int, error) {
+	itr := state.store.NewIterator(kvstore.WalCFName)
+	defer itr.Close()
+	numTxs := 0
+	for itr.SeekToFirst(); itr.Valid(); itr.Next() {
+		sequence := decodeToUint64(itr.Key())
+		txUUID, txStateDelta, err := decodeWALEntry(itr.Value())
+		if err != nil {
+			return numTxs, errors.Errorf(errors.DataCorrupted, "Error while decoding the WAL entry [%d]: %s", sequence, err)
+		}
+		logger.Debug("Replaying the state delta of tx [%s] from the WAL", txUUID)
+		state.applyTxStateDelta(txUUID, txStateDelta)
+		state.walSequence = sequence + 1
+		state.walInUse = true
+		numTxs++
+	}
+	return numTxs, nil
+}
+
+func decodeWALEntry(entry []byte) (string, *statemgmt.StateDelta, error) {
+	buffer := proto.NewBuffer(entry)
+	txUUID, err := buffer.DecodeRawBytes(false)
+	if err != nil {
+		return "", nil, err
+	}
+	deltaBytes, err := buffer.DecodeRawBytes(false)
+	if err != nil {
+		return "", nil, err
+	}
+	txStateDelta := statemgmt.NewStateDelta()
+	if err := txStateDelta.Unmarshal(deltaBytes); err != nil {
+		return "", nil, err
+	}
+	return string(txUUID), txStateDelta, nil
+}
+
+// addWALCleanupForPersistence removes the WAL entries in the write-batch that commits the tx-batch
+func (state *State) addWALCleanupForPersistence(writeBatch kvstore.WriteBatch) {
+	if state.walInUse {
+		state.addWALDeletes(writeBatch)
+	}
+}
+
+func (state *State) addWALDeletes(writeBatch kvstore.WriteBatch) {
+	itr := state.store.NewIterator(kvstore.WalCFName)
+	defer itr.Close()
+	for itr.SeekToFirst(); itr.Valid(); itr.Next() {
+		writeBatch.Delete(kvstore.WalCFName, itr.Key())
+	}
+}
+
+// clearWAL removes the WAL entries of a tx-batch that is rolled back
+func (state *State) clearWAL() {
+	writeBatch := state.store.NewWriteBatch()
+	defer writeBatch.Destroy()
+	state.addWALDeletes(writeBatch)
+	if err := state.store.Write(writeBatch, false); err != nil {
+		logger.Error("Error while clearing the walCF: %s", err)
+	}
+}
@@ -0,0 +1,179 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package state
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/db/kvstore"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+)
+
+// The write index records, for every key changed by a block, the transactions of the
+// block that wrote the key, in commit order, along with the crypto-hash of the value
+// each one wrote. The entries are kept in the indexesCF as long as the state delta of
+// the block is retained (see 'ledger.state.deltaHistorySize'). Deltas applied through
+// state transfer (ApplyStateDelta) carry no transaction information and are not indexed.
+//
+// key:   prefixWriteIndexKey varint(len(compositeKey)) compositeKey bigEndian(blockNumber)
+// value: writeIndexVersion varint(numTxs) [varint(len(txUUID)) txUUID varint(len(valueHash)) valueHash]...
+//
+// The entries written before the transactions were all recorded hold the txUUID of the
+// last transaction only, which never starts with writeIndexVersion.
+var prefixWriteIndexKey = byte(4)
+
+const writeIndexVersion = byte(0)
+
+// KeyWrite describes the changes made to a key by the transactions of a block
+type KeyWrite struct {
+	BlockNumber uint64
+	// TxUUID is the last transaction of the block that wrote the key
+	TxUUID string
+	// Value is the value of the key at the end of the block, nil if the key was deleted
+	Value []byte
+	// TxWrites are the changes made to the key by the transactions of the block, in commit order
+	TxWrites []*TxKeyWrite
+}
+
+// TxKeyWrite describes a change made to a key by a transaction
+type TxKeyWrite struct {
+	TxUUID string
+	// ValueHash is the crypto-hash of the value written by the transaction, nil if the key was deleted
+	ValueHash []byte
+}
+
+func (state *State) recordTxWriter(txUUID string, txStateDelta *statemgmt.StateDelta) {
+	for _, chaincodeID := range txStateDelta.GetUpdatedChaincodeIds(false) {
+		for key, updatedValue := range txStateDelta.GetUpdates(chaincodeID) {
+			compositeKey := string(statemgmt.ConstructCompositeKey(chaincodeID, key))
+			state.txWriters[compositeKey] = append(state.txWriters[compositeKey], newTxKeyWrite(txUUID, updatedValue.GetValue()))
+		}
+	}
+}
+
+func newTxKeyWrite(txUUID string, value []byte) *TxKeyWrite {
+	txWrite := &TxKeyWrite{TxUUID: txUUID}
+	if value != nil {
+		txWrite.ValueHash = statemgmt.ComputeCryptoHash(value)
+	}
+	return txWrite
+}
+
+func (state *State) addWriteIndexForPersistence(blockNumber uint64, writeBatch kvstore.WriteBatch) {
+	for compositeKey, txWrites := range state.txWriters {
+		writeBatch.Put(kvstore.IndexesCFName, encodeWriteIndexKey([]byte(compositeKey), blockNumber), encodeTxKeyWrites(txWrites))
+	}
+}
+
+func (state *State) deleteWriteIndexForBlock(blockNumber uint64, writeBatch kvstore.WriteBatch) {
+	stateDelta, err := state.FetchStateDeltaFromDB(blockNumber)
+	if err != nil || stateDelta == nil {
+		logger.Debug("No state-delta to remove from the write index for block number [%d]", blockNumber)
+		return
+	}
+	for _, chaincodeID := range stateDelta.GetUpdatedChaincodeIds(false) {
+		for key := range stateDelta.GetUpdates(chaincodeID) {
+			writeBatch.Delete(kvstore.IndexesCFName, encodeWriteIndexKey(statemgmt.ConstructCompositeKey(chaincodeID, key), blockNumber))
+		}
+	}
+}
+
+// GetKeyWrites returns, in the increasing order of block numbers, the changes made to
+// the key by the committed blocks for which the state delta is still retained
+func (state *State) GetKeyWrites(chaincodeID string, key string) ([]*KeyWrite, error) {
+	prefix := encodeWriteIndexPrefix(statemgmt.ConstructCompositeKey(chaincodeID, key))
+	itr := state.store.NewIterator(kvstore.IndexesCFName)
+	defer itr.Close()
+	var writes []*KeyWrite
+	for itr.Seek(prefix); itr.Valid(); itr.Next() {
+		indexKey := itr.Key()
+		if !bytes.HasPrefix(indexKey, prefix) {
+			break
+		}
+		blockNumber := decodeToUint64(indexKey[len(prefix):])
+		stateDelta, err := state.FetchStateDeltaFromDB(blockNumber)
+		if err != nil {
+			return nil, err
+		}
+		if stateDelta == nil {
+			continue
+		}
+		updatedValue := stateDelta.Get(chaincodeID, key)
+		if updatedValue == nil {
+			continue
+		}
+		txWrites, err := decodeTxKeyWrites(itr.Value(), updatedValue.GetValue())
+		if err != nil {
+			return nil, fmt.Errorf("Error decoding the write index entry of block [%d]: %s", blockNumber, err)
+		}
+		writes = append(writes, &KeyWrite{blockNumber, txWrites[len(txWrites)-1].TxUUID, updatedValue.GetValue(), txWrites})
+	}
+	return writes, nil
+}
+
+func encodeTxKeyWrites(txWrites []*TxKeyWrite) []byte {
+	b := proto.NewBuffer([]byte{writeIndexVersion})
+	b.EncodeVarint(uint64(len(txWrites)))
+	for _, txWrite := range txWrites {
+		b.EncodeStringBytes(txWrite.TxUUID)
+		b.EncodeRawBytes(txWrite.ValueHash)
+	}
+	return b.Bytes()
+}
+
+// decodeTxKeyWrites decodes the value of a write index entry. The value of the key at the
+// end of the block stands for the value written by the transaction of an older entry.
+func decodeTxKeyWrites(encoded []byte, blockValue []byte) ([]*TxKeyWrite, error) {
+	if len(encoded) == 0 || encoded[0] != writeIndexVersion {
+		return []*TxKeyWrite{newTxKeyWrite(string(encoded), blockValue)}, nil
+	}
+	b := proto.NewBuffer(encoded[1:])
+	numTxs, err := b.DecodeVarint()
+	if err != nil {
+		return nil, err
+	}
+	if numTxs == 0 {
+		return nil, fmt.Errorf("no transaction recorded")
+	}
+	txWrites := make([]*TxKeyWrite, numTxs)
+	for i := range txWrites {
+		txWrite := &TxKeyWrite{}
+		if txWrite.TxUUID, err = b.DecodeStringBytes(); err != nil {
+			return nil, err
+		}
+		if txWrite.ValueHash, err = b.DecodeRawBytes(true); err != nil {
+			return nil, err
+		}
+		if len(txWrite.ValueHash) == 0 {
+			txWrite.ValueHash = nil
+		}
+		txWrites[i] = txWrite
+	}
+	return txWrites, nil
+}
+
+func encodeWriteIndexPrefix(compositeKey []byte) []byte {
+	b := proto.NewBuffer([]byte{prefixWriteIndexKey})
+	b.EncodeRawBytes(compositeKey)
+	return b.Bytes()
+}
+
+func encodeWriteIndexKey(compositeKey []byte, blockNumber uint64) []byte {
+	return append(encodeWriteIndexPrefix(compositeKey), encodeUint64(blockNumber)...)
+}
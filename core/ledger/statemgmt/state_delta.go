@@ -17,12 +17,12 @@ limitations under the License.
 package statemgmt
 
 import (
-	"bytes"
 	"fmt"
 	"sort"
 
 	"github.com/golang/protobuf/proto"
-	"github.com/hyperledger/fabric/core/util"
+	"github.com/hyperledger/fabric/core/ledger/verify"
+	pb "github.com/hyperledger/fabric/protos"
 )
 
 // StateDelta holds the changes to existing state. This struct is used for holding the uncommitted changes during execution of a tx-batch
@@ -76,11 +76,14 @@ func (stateDelta *StateDelta) IsUpdatedValueSet(chaincodeID, key string) bool {
 	return false
 }
 
-// ApplyChanges merges another delta - if a key is present in both, the value of the existing key is overwritten
+// ApplyChanges merges another delta - if a key is present in both, the value of the existing key is overwritten.
+// The changes are applied in lexicographical order of chaincodeID and key
 func (stateDelta *StateDelta) ApplyChanges(anotherStateDelta *StateDelta) {
-	for chaincodeID, chaincodeStateDelta := range anotherStateDelta.ChaincodeStateDeltas {
+	for _, chaincodeID := range anotherStateDelta.GetUpdatedChaincodeIds(true) {
+		chaincodeStateDelta := anotherStateDelta.ChaincodeStateDeltas[chaincodeID]
 		existingChaincodeStateDelta, existingChaincode := stateDelta.ChaincodeStateDeltas[chaincodeID]
-		for key, valueHolder := range chaincodeStateDelta.UpdatedKVs {
+		for _, key := range chaincodeStateDelta.getSortedKeys() {
+			valueHolder := chaincodeStateDelta.UpdatedKVs[key]
 			var previousValue []byte
 			if existingChaincode {
 				existingUpdateValue, existingUpdate := existingChaincodeStateDelta.UpdatedKVs[key]
@@ -149,23 +152,13 @@ func (stateDelta *StateDelta) ComputeCryptoHash() []byte {
 	if stateDelta.IsEmpty() {
 		return nil
 	}
-	var buffer bytes.Buffer
-	sortedChaincodeIds := stateDelta.GetUpdatedChaincodeIds(true)
-	for _, chaincodeID := range sortedChaincodeIds {
-		buffer.WriteString(chaincodeID)
-		chaincodeStateDelta := stateDelta.ChaincodeStateDeltas[chaincodeID]
-		sortedKeys := chaincodeStateDelta.getSortedKeys()
-		for _, key := range sortedKeys {
-			buffer.WriteString(key)
-			updatedValue := chaincodeStateDelta.get(key)
-			if !updatedValue.IsDelete() {
-				buffer.Write(updatedValue.Value)
-			}
+	var changes []*verify.KeyValue
+	for chaincodeID, chaincodeStateDelta := range stateDelta.ChaincodeStateDeltas {
+		for key, updatedValue := range chaincodeStateDelta.UpdatedKVs {
+			changes = append(changes, &verify.KeyValue{ChaincodeID: chaincodeID, Key: key, Value: updatedValue.Value})
 		}
 	}
-	hashingContent := buffer.Bytes()
-	logger.Debug("computing hash on %#v", hashingContent)
-	return util.ComputeCryptoHash(hashingContent)
+	return verify.ComputeStateDeltaHash(ComputeCryptoHash, changes)
 }
 
 //ChaincodeStateDelta maintains state for a chaincode
@@ -241,65 +234,117 @@ func (updatedValue *UpdatedValue) GetPreviousValue() []byte {
 }
 
 // marshalling / Unmarshalling code
-// We need to revisit the following when we define proto messages
-// for state related structures for transporting. May be we can
-// completely get rid of custom marshalling / Unmarshalling of a state delta
+//
+// A StateDelta is serialized as a single stateDeltaFormatMarker byte followed
+// by a protos.StateDeltaMessage so that other implementations and external
+// tools can parse persisted deltas and state transfer payloads. Chaincodes and
+// keys are added to the message in lexicographical order and, therefore, two
+// StateDeltas holding the same changes always marshal to the same bytes
+// regardless of the order in which the changes were made.
+//
+// Deltas persisted by earlier versions use the legacy (version 1) encoding
+//
+//	varint(number of chaincodes)
+//	for each chaincodeID:
+//		varint(len(chaincodeID)) chaincodeID
+//		varint(number of keys)
+//		for each key:
+//			varint(len(key)) key
+//			value marker: varint(0) for nil, or varint(1) varint(len(value)) value
+//			previous value marker: same as value
+//
+// which starts with the 0x00 byte only for an empty delta, in which case it is
+// exactly one byte long. Unmarshal relies on this to accept both encodings.
+
+const (
+	stateDeltaFormatMarker  = byte(0)
+	stateDeltaFormatVersion = uint32(2)
+)
 
-// Marshal serializes the StateDelta
+// Marshal serializes the StateDelta in the canonical form described above
 func (stateDelta *StateDelta) Marshal() (b []byte) {
-	buffer := proto.NewBuffer([]byte{})
-	err := buffer.EncodeVarint(uint64(len(stateDelta.ChaincodeStateDeltas)))
+	msgBytes, err := proto.Marshal(stateDelta.ToMessage())
 	if err != nil {
-		// in protobuf code the error return is always nil
-		panic(fmt.Errorf("This error should not occure: %s", err))
-	}
-	for chaincodeID, chaincodeStateDelta := range stateDelta.ChaincodeStateDeltas {
-		buffer.EncodeStringBytes(chaincodeID)
-		chaincodeStateDelta.marshal(buffer)
+		panic(fmt.Errorf("This error should not occur: %s", err))
 	}
-	b = buffer.Bytes()
+	b = append([]byte{stateDeltaFormatMarker}, msgBytes...)
 	return
 }
 
-func (chaincodeStateDelta *ChaincodeStateDelta) marshal(buffer *proto.Buffer) {
-	err := buffer.EncodeVarint(uint64(len(chaincodeStateDelta.UpdatedKVs)))
-	if err != nil {
-		panic(fmt.Errorf("This error should not occur: %s", err))
+// ToMessage returns the StateDeltaMessage holding the changes of the delta
+func (stateDelta *StateDelta) ToMessage() *pb.StateDeltaMessage {
+	msg := &pb.StateDeltaMessage{Version: stateDeltaFormatVersion}
+	for _, chaincodeID := range stateDelta.GetUpdatedChaincodeIds(true) {
+		chaincodeStateDelta := stateDelta.ChaincodeStateDeltas[chaincodeID]
+		msg.ChaincodeStateDeltas = append(msg.ChaincodeStateDeltas, chaincodeStateDelta.toMessage(chaincodeID))
 	}
-	for key, valueHolder := range chaincodeStateDelta.UpdatedKVs {
-		err = buffer.EncodeStringBytes(key)
-		if err != nil {
-			panic(fmt.Errorf("This error should not occur: %s", err))
-		}
-		chaincodeStateDelta.marshalValueWithMarker(buffer, valueHolder.Value)
-		chaincodeStateDelta.marshalValueWithMarker(buffer, valueHolder.PreviousValue)
+	return msg
+}
+
+func (chaincodeStateDelta *ChaincodeStateDelta) toMessage(chaincodeID string) *pb.ChaincodeStateDeltaMessage {
+	msg := &pb.ChaincodeStateDeltaMessage{ChaincodeID: chaincodeID}
+	for _, key := range chaincodeStateDelta.getSortedKeys() {
+		valueHolder := chaincodeStateDelta.UpdatedKVs[key]
+		msg.UpdatedKVs = append(msg.UpdatedKVs, &pb.UpdatedValueMessage{
+			Key:           key,
+			Value:         toStateValue(valueHolder.Value),
+			PreviousValue: toStateValue(valueHolder.PreviousValue)})
 	}
-	return
+	return msg
 }
 
-func (chaincodeStateDelta *ChaincodeStateDelta) marshalValueWithMarker(buffer *proto.Buffer, value []byte) {
+func toStateValue(value []byte) *pb.StateValue {
 	if value == nil {
-		// Just add a marker that the value is nil
-		err := buffer.EncodeVarint(uint64(0))
-		if err != nil {
-			panic(fmt.Errorf("This error should not occur: %s", err))
-		}
-		return
+		return nil
 	}
-	err := buffer.EncodeVarint(uint64(1))
-	if err != nil {
-		panic(fmt.Errorf("This error should not occur: %s", err))
+	return &pb.StateValue{Value: value}
+}
+
+func fromStateValue(stateValue *pb.StateValue) []byte {
+	if stateValue == nil {
+		return nil
 	}
-	// If the value happen to be an empty byte array, it would appear as a nil during
-	// deserialization - see method 'unmarshalValueWithMarker'
-	err = buffer.EncodeRawBytes(value)
-	if err != nil {
-		panic(fmt.Errorf("This error should not occur: %s", err))
+	// protobuff makes an empty []byte into a nil. So, assigning an empty byte array explicitly
+	if stateValue.Value == nil {
+		return []byte{}
 	}
+	return stateValue.Value
 }
 
-// Unmarshal deserializes StateDelta
+// Unmarshal deserializes StateDelta from either the current or the legacy encoding
 func (stateDelta *StateDelta) Unmarshal(bytes []byte) error {
+	if len(bytes) > 1 && bytes[0] == stateDeltaFormatMarker {
+		return stateDelta.unmarshalMessage(bytes[1:])
+	}
+	return stateDelta.unmarshalLegacy(bytes)
+}
+
+func (stateDelta *StateDelta) unmarshalMessage(bytes []byte) error {
+	msg := &pb.StateDeltaMessage{}
+	if err := proto.Unmarshal(bytes, msg); err != nil {
+		return fmt.Errorf("Error unmarshaling state delta: %s", err)
+	}
+	return stateDelta.FromMessage(msg)
+}
+
+// FromMessage replaces the changes of the delta with the ones held by the StateDeltaMessage
+func (stateDelta *StateDelta) FromMessage(msg *pb.StateDeltaMessage) error {
+	if msg.Version != stateDeltaFormatVersion {
+		return fmt.Errorf("Unsupported state delta format version [%d]", msg.Version)
+	}
+	stateDelta.ChaincodeStateDeltas = make(map[string]*ChaincodeStateDelta, len(msg.ChaincodeStateDeltas))
+	for _, chaincodeMsg := range msg.ChaincodeStateDeltas {
+		chaincodeStateDelta := newChaincodeStateDelta(chaincodeMsg.ChaincodeID)
+		for _, kv := range chaincodeMsg.UpdatedKVs {
+			chaincodeStateDelta.UpdatedKVs[kv.Key] = &UpdatedValue{fromStateValue(kv.Value), fromStateValue(kv.PreviousValue)}
+		}
+		stateDelta.ChaincodeStateDeltas[chaincodeMsg.ChaincodeID] = chaincodeStateDelta
+	}
+	return nil
+}
+
+// unmarshalLegacy deserializes a StateDelta persisted in the legacy encoding
+func (stateDelta *StateDelta) unmarshalLegacy(bytes []byte) error {
 	buffer := proto.NewBuffer(bytes)
 	size, err := buffer.DecodeVarint()
 	if err != nil {
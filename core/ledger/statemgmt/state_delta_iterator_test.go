@@ -63,3 +63,23 @@ func TestStateDeltaIteratorTest(t *testing.T) {
 			"key6": []byte("value6"),
 		})
 }
+
+func TestSortedRangeScanIterator(t *testing.T) {
+	delta := NewStateDelta()
+	for _, key := range []string{"key5", "key1", "key4", "key2", "key3"} {
+		delta.Set("chaincodeID1", key, []byte("value"+key[3:]), nil)
+	}
+	itr := NewSortedRangeScanIterator(NewStateDeltaRangeScanIterator(delta, "chaincodeID1", "key2", "key4"))
+	for _, expected := range []string{"key2", "key3", "key4"} {
+		if !itr.Next() {
+			t.Fatalf("Expected key %s, the iterator is exhausted", expected)
+		}
+		key, value := itr.GetKeyValue()
+		if key != expected || string(value) != "value"+expected[3:] {
+			t.Fatalf("Expected key %s, got %s=%s", expected, key, value)
+		}
+	}
+	if itr.Next() {
+		t.Fatalf("Expected the iterator to be exhausted")
+	}
+}
@@ -19,7 +19,9 @@ package statemgmt
 import (
 	"testing"
 
+	"github.com/golang/protobuf/proto"
 	"github.com/hyperledger/fabric/core/ledger/testutil"
+	pb "github.com/hyperledger/fabric/protos"
 )
 
 func TestStateDeltaMarshalling(t *testing.T) {
@@ -76,3 +78,90 @@ func TestStateDeltaEmptyArrayValue(t *testing.T) {
 	v = stateDelta1.Get("chaincode4", "")
 	testutil.AssertEquals(t, v.GetValue(), []byte("value4"))
 }
+
+func TestStateDeltaMarshallingIsCanonical(t *testing.T) {
+	stateDelta1 := NewStateDelta()
+	stateDelta1.Set("chaincode1", "key1", []byte("value1"), nil)
+	stateDelta1.Set("chaincode1", "key2", []byte("value2"), []byte("previous2"))
+	stateDelta1.Set("chaincode2", "key1", []byte("value1"), nil)
+	stateDelta1.Delete("chaincode3", "key3", []byte("previous3"))
+
+	stateDelta2 := NewStateDelta()
+	stateDelta2.Delete("chaincode3", "key3", []byte("previous3"))
+	stateDelta2.Set("chaincode2", "key1", []byte("value1"), nil)
+	stateDelta2.Set("chaincode1", "key2", []byte("value2"), []byte("previous2"))
+	stateDelta2.Set("chaincode1", "key1", []byte("value1"), nil)
+
+	marshalled := stateDelta1.Marshal()
+	for i := 0; i < 10; i++ {
+		testutil.AssertEquals(t, stateDelta1.Marshal(), marshalled)
+		testutil.AssertEquals(t, stateDelta2.Marshal(), marshalled)
+	}
+
+	// Applying the changes in sorted order yields the same canonical bytes
+	stateDelta3 := NewStateDelta()
+	stateDelta3.ApplyChanges(stateDelta2)
+	testutil.AssertEquals(t, stateDelta3.Marshal(), marshalled)
+}
+
+func TestStateDeltaUnmarshalLegacyFormat(t *testing.T) {
+	stateDelta := NewStateDelta()
+	stateDelta.Set("chaincode1", "key1", []byte("value1"), nil)
+	stateDelta.Set("chaincode2", "key2", []byte{}, []byte("previous2"))
+	stateDelta.Delete("chaincode3", "key3", []byte("previous3"))
+
+	stateDelta1 := NewStateDelta()
+	testutil.AssertNoError(t, stateDelta1.Unmarshal(marshalLegacy(stateDelta)), "Error unmarshalling legacy format")
+	testutil.AssertEquals(t, stateDelta1, stateDelta)
+
+	emptyStateDelta := NewStateDelta()
+	testutil.AssertNoError(t, emptyStateDelta.Unmarshal(marshalLegacy(NewStateDelta())), "Error unmarshalling empty legacy delta")
+	testutil.AssertEquals(t, emptyStateDelta.IsEmpty(), true)
+	testutil.AssertNoError(t, emptyStateDelta.Unmarshal(NewStateDelta().Marshal()), "Error unmarshalling empty delta")
+	testutil.AssertEquals(t, emptyStateDelta.IsEmpty(), true)
+}
+
+func TestStateDeltaMarshalledAsProtobuf(t *testing.T) {
+	stateDelta := NewStateDelta()
+	stateDelta.Set("chaincode1", "key1", []byte("value1"), []byte{})
+	stateDelta.Delete("chaincode2", "key2", []byte("previous2"))
+
+	b := stateDelta.Marshal()
+	testutil.AssertEquals(t, b[0], stateDeltaFormatMarker)
+	msg := &pb.StateDeltaMessage{}
+	testutil.AssertNoError(t, proto.Unmarshal(b[1:], msg), "Error unmarshalling protobuf message")
+	testutil.AssertEquals(t, msg.Version, stateDeltaFormatVersion)
+	testutil.AssertEquals(t, len(msg.ChaincodeStateDeltas), 2)
+	kv := msg.ChaincodeStateDeltas[1].UpdatedKVs[0]
+	testutil.AssertEquals(t, msg.ChaincodeStateDeltas[1].ChaincodeID, "chaincode2")
+	testutil.AssertNil(t, kv.Value)
+	testutil.AssertEquals(t, kv.PreviousValue.Value, []byte("previous2"))
+
+	msg.Version = stateDeltaFormatVersion + 1
+	unsupported, _ := proto.Marshal(msg)
+	testutil.AssertError(t, NewStateDelta().Unmarshal(append([]byte{stateDeltaFormatMarker}, unsupported...)),
+		"Expected an error for an unsupported format version")
+}
+
+// marshalLegacy serializes the delta in the encoding used before the protobuf one
+func marshalLegacy(stateDelta *StateDelta) []byte {
+	buffer := proto.NewBuffer([]byte{})
+	buffer.EncodeVarint(uint64(len(stateDelta.ChaincodeStateDeltas)))
+	for _, chaincodeID := range stateDelta.GetUpdatedChaincodeIds(false) {
+		buffer.EncodeStringBytes(chaincodeID)
+		chaincodeStateDelta := stateDelta.ChaincodeStateDeltas[chaincodeID]
+		buffer.EncodeVarint(uint64(len(chaincodeStateDelta.UpdatedKVs)))
+		for key, valueHolder := range chaincodeStateDelta.UpdatedKVs {
+			buffer.EncodeStringBytes(key)
+			for _, value := range [][]byte{valueHolder.Value, valueHolder.PreviousValue} {
+				if value == nil {
+					buffer.EncodeVarint(0)
+				} else {
+					buffer.EncodeVarint(1)
+					buffer.EncodeRawBytes(value)
+				}
+			}
+		}
+	}
+	return buffer.Bytes()
+}
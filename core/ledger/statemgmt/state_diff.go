@@ -0,0 +1,111 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statemgmt
+
+import (
+	"bytes"
+	"sort"
+)
+
+// KeyDifference is a key whose value differs between two snapshots of the state.
+// ValueA (resp. ValueB) is nil if the key is not present in the first (resp. second) snapshot
+type KeyDifference struct {
+	ChaincodeID string
+	Key         string
+	ValueA      []byte
+	ValueB      []byte
+}
+
+// DiffSnapshots consumes the two snapshot iterators and returns the keys whose values differ,
+// in the order of the composite keys. The iterators are advanced in lock-step and only the keys
+// not yet seen on the other side are held in memory. Hence, when both snapshots come from the
+// same state implementation with the same configuration, and so return the keys in the same
+// order, the memory used is bounded by the differences. The iterators are not closed.
+func DiffSnapshots(a, b StateSnapshotIterator) []KeyDifference {
+	pendingA := make(map[string][]byte)
+	pendingB := make(map[string][]byte)
+	var differences []KeyDifference
+	addDifference := func(compositeKey string, valueA []byte, valueB []byte) {
+		chaincodeID, key := DecodeCompositeKey([]byte(compositeKey))
+		differences = append(differences, KeyDifference{chaincodeID, key, valueA, valueB})
+	}
+	compare := func(compositeKey string, valueA []byte, valueB []byte) {
+		if !bytes.Equal(valueA, valueB) {
+			addDifference(compositeKey, valueA, valueB)
+		}
+	}
+	hasA, hasB := true, true
+	for hasA || hasB {
+		if hasA = hasA && a.Next(); hasA {
+			k, v := a.GetRawKeyValue()
+			if valueB, ok := pendingB[string(k)]; ok {
+				delete(pendingB, string(k))
+				compare(string(k), v, valueB)
+			} else {
+				pendingA[string(k)] = v
+			}
+		}
+		if hasB = hasB && b.Next(); hasB {
+			k, v := b.GetRawKeyValue()
+			if valueA, ok := pendingA[string(k)]; ok {
+				delete(pendingA, string(k))
+				compare(string(k), valueA, v)
+			} else {
+				pendingB[string(k)] = v
+			}
+		}
+	}
+	for k, v := range pendingA {
+		addDifference(k, v, nil)
+	}
+	for k, v := range pendingB {
+		addDifference(k, nil, v)
+	}
+	sort.Sort(keyDifferenceSorter(differences))
+	return differences
+}
+
+type keyDifferenceSorter []KeyDifference
+
+func (s keyDifferenceSorter) Len() int      { return len(s) }
+func (s keyDifferenceSorter) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s keyDifferenceSorter) Less(i, j int) bool {
+	return bytes.Compare(ConstructCompositeKey(s[i].ChaincodeID, s[i].Key), ConstructCompositeKey(s[j].ChaincodeID, s[j].Key)) < 0
+}
+
+// DiffStateHashDetails returns the indexes of the children of the root of the state tree whose
+// crypto-hashes differ between the two details, in increasing order. A child present in only one
+// of the details is included.
+func DiffStateHashDetails(a, b *StateHashDetail) []int {
+	hashes := make(map[int][]byte)
+	for _, child := range a.Children {
+		hashes[child.Index] = child.Hash
+	}
+	var differences []int
+	for _, child := range b.Children {
+		hashA, ok := hashes[child.Index]
+		if !ok || !bytes.Equal(hashA, child.Hash) {
+			differences = append(differences, child.Index)
+		}
+		delete(hashes, child.Index)
+	}
+	for index := range hashes {
+		differences = append(differences, index)
+	}
+	sort.Ints(differences)
+	return differences
+}
@@ -0,0 +1,93 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statemgmt
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric/core/ledger/testutil"
+)
+
+type sliceSnapshotIterator struct {
+	keyValues [][2][]byte
+	index     int
+}
+
+func newSliceSnapshotIterator(keyValues ...[]string) *sliceSnapshotIterator {
+	itr := &sliceSnapshotIterator{index: -1}
+	for _, kv := range keyValues {
+		var value []byte
+		if kv[2] != "" {
+			value = []byte(kv[2])
+		}
+		itr.keyValues = append(itr.keyValues, [2][]byte{ConstructCompositeKey(kv[0], kv[1]), value})
+	}
+	return itr
+}
+
+func (itr *sliceSnapshotIterator) Next() bool {
+	itr.index++
+	return itr.index < len(itr.keyValues)
+}
+
+func (itr *sliceSnapshotIterator) GetRawKeyValue() ([]byte, []byte) {
+	return itr.keyValues[itr.index][0], itr.keyValues[itr.index][1]
+}
+
+func (itr *sliceSnapshotIterator) Close() {
+}
+
+func TestDiffSnapshots(t *testing.T) {
+	a := newSliceSnapshotIterator(
+		[]string{"chaincodeID1", "key1", "value1"},
+		[]string{"chaincodeID1", "key2", "value2"},
+		[]string{"chaincodeID2", "key3", "value3"},
+		[]string{"chaincodeID2", "key4", ""},
+		[]string{"chaincodeID3", "key5", "value5"},
+	)
+	// same keys in a different order, one key changed, one missing and one extra
+	b := newSliceSnapshotIterator(
+		[]string{"chaincodeID3", "key5", "value5"},
+		[]string{"chaincodeID2", "key3", "newValue3"},
+		[]string{"chaincodeID2", "key4", ""},
+		[]string{"chaincodeID1", "key1", "value1"},
+		[]string{"chaincodeID4", "key6", "value6"},
+	)
+	differences := DiffSnapshots(a, b)
+	testutil.AssertEquals(t, differences, []KeyDifference{
+		{"chaincodeID1", "key2", []byte("value2"), nil},
+		{"chaincodeID2", "key3", []byte("value3"), []byte("newValue3")},
+		{"chaincodeID4", "key6", nil, []byte("value6")},
+	})
+
+	testutil.AssertEquals(t, len(DiffSnapshots(newSliceSnapshotIterator(), newSliceSnapshotIterator())), 0)
+}
+
+func TestDiffStateHashDetails(t *testing.T) {
+	a := &StateHashDetail{RootHash: []byte("rootA"), Children: []*StateHashChild{
+		{Index: 0, Hash: []byte("hash0")},
+		{Index: 1, Hash: []byte("hash1")},
+		{Index: 3, Hash: []byte("hash3")},
+	}}
+	b := &StateHashDetail{RootHash: []byte("rootB"), Children: []*StateHashChild{
+		{Index: 0, Hash: []byte("hash0")},
+		{Index: 1, Hash: []byte("newHash1")},
+		{Index: 2, Hash: []byte("hash2")},
+	}}
+	testutil.AssertEquals(t, DiffStateHashDetails(a, b), []int{1, 2, 3})
+	testutil.AssertNil(t, DiffStateHashDetails(a, a))
+}
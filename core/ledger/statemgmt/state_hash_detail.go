@@ -0,0 +1,55 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statemgmt
+
+import (
+	"github.com/hyperledger/fabric/core/db/kvstore"
+)
+
+// StateHashDetail holds the crypto-hash of the committed state along with the
+// crypto-hashes of the children of the root of the tree from which the state
+// implementation computes it. Comparing the details obtained from two peers
+// tells which subtrees of their states diverged.
+type StateHashDetail struct {
+	// RootHash is the crypto-hash of the committed state
+	RootHash []byte `json:"rootHash"`
+	// Children holds the non-empty children of the root, in the order of their index
+	Children []*StateHashChild `json:"children"`
+}
+
+// StateHashChild holds the crypto-hash of a child of the root of the state tree.
+// The meaning of the index depends on the state implementation. For instance, it
+// is the position of the bucket at the first level of the bucket tree.
+type StateHashChild struct {
+	Index int    `json:"index"`
+	Hash  []byte `json:"hash"`
+}
+
+// StateHashDetailProvider can optionally be implemented by a HashableState for
+// reporting the crypto-hashes at the top of its tree
+type StateHashDetailProvider interface {
+	GetRootStateHashDetail() (*StateHashDetail, error)
+}
+
+// SubtreeSnapshotProvider can optionally be implemented by a HashableState that reports the
+// details of its state hash. It gives the key-values held by the subtrees of some children of
+// the root, so that only the subtrees that diverged between two peers have to be compared.
+type SubtreeSnapshotProvider interface {
+	// GetSubtreeSnapshotIterator returns an iterator over the key-values of the subtrees of the
+	// children of the root with the given indexes (see StateHashChild)
+	GetSubtreeSnapshotIterator(snapshot kvstore.Snapshot, children []int) (StateSnapshotIterator, error)
+}
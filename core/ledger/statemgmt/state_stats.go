@@ -0,0 +1,91 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statemgmt
+
+import (
+	"github.com/hyperledger/fabric/core/db/kvstore"
+)
+
+// StateStats holds statistics about the world state
+type StateStats struct {
+	// TotalKeys is the number of keys present in the state
+	TotalKeys uint64 `json:"totalKeys"`
+	// TotalBytes is the sum of the sizes of all the keys and values present in the state
+	TotalBytes uint64 `json:"totalBytes"`
+	// Chaincodes holds the statistics per chaincodeID
+	Chaincodes map[string]*ChaincodeStateStats `json:"chaincodes"`
+	// DeltaSizes maps the number of each block for which a state delta is retained to
+	// the size of the serialized delta
+	DeltaSizes map[uint64]uint64 `json:"deltaSizes"`
+	// Tree holds the statistics reported by the state implementation, if any
+	Tree *TreeStats `json:"tree,omitempty"`
+	// ReadRepairs maps each structure derived from the state (e.g., a cache) to the number
+	// of inconsistencies with the state detected and repaired since the peer started, if
+	// reported by the state implementation
+	ReadRepairs map[string]uint64 `json:"readRepairs,omitempty"`
+}
+
+// ChaincodeStateStats holds statistics about the state of a chaincode
+type ChaincodeStateStats struct {
+	Keys  uint64 `json:"keys"`
+	Bytes uint64 `json:"bytes"`
+}
+
+// TreeStats holds statistics about the structure that a state implementation
+// maintains for computing the crypto-hash
+type TreeStats struct {
+	// Depth is the number of levels in the tree
+	Depth int `json:"depth"`
+	// NumBuckets is the number of buckets at the lowest level
+	NumBuckets int `json:"numBuckets"`
+	// OccupiedBuckets is the number of buckets at the lowest level holding at least one key
+	OccupiedBuckets int `json:"occupiedBuckets"`
+	// MaxKeysInBucket is the number of keys in the most occupied bucket
+	MaxKeysInBucket int `json:"maxKeysInBucket"`
+}
+
+// AddKeyValue accounts for a key-value present in the state
+func (stats *StateStats) AddKeyValue(chaincodeID string, key string, value []byte) {
+	size := uint64(len(key) + len(value))
+	stats.TotalKeys++
+	stats.TotalBytes += size
+	chaincodeStats, ok := stats.Chaincodes[chaincodeID]
+	if !ok {
+		chaincodeStats = &ChaincodeStateStats{}
+		stats.Chaincodes[chaincodeID] = chaincodeStats
+	}
+	chaincodeStats.Keys++
+	chaincodeStats.Bytes += size
+}
+
+// NewStateStats constructs an empty StateStats
+func NewStateStats() *StateStats {
+	return &StateStats{Chaincodes: make(map[string]*ChaincodeStateStats), DeltaSizes: make(map[uint64]uint64)}
+}
+
+// TreeStatsProvider can optionally be implemented by a HashableState for
+// reporting statistics about its internal structure
+type TreeStatsProvider interface {
+	GetTreeStats(snapshot kvstore.Snapshot) (*TreeStats, error)
+}
+
+// ReadRepairStatsProvider can optionally be implemented by a HashableState that verifies
+// the structures it derives from the persisted state (e.g., caches) and repairs these
+// when found inconsistent
+type ReadRepairStatsProvider interface {
+	GetReadRepairCounts() map[string]uint64
+}
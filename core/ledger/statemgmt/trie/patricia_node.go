@@ -0,0 +1,211 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package trie
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/db/kvstore"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+	"github.com/hyperledger/fabric/core/ledger/verify"
+)
+
+const patriciaTrieWidth = verify.PatriciaTrieWidth
+
+// A node of the patricia trie is identified by its path, i.e., the nibbles of the composite
+// key (see statemgmt.ConstructCompositeKey) from the root down to the node. The chains of
+// nodes having a single child and no value are compressed into the edges so that the
+// depth of a key is the number of branches on its way rather than the length of the key.
+type patriciaNode struct {
+	path     []byte
+	value    []byte
+	children [patriciaTrieWidth]*patriciaEdge
+
+	// hash of the node, valid unless the node is dirty
+	hash []byte
+	// dirty is set for the nodes changed in the working set, which have to be rehashed and persisted
+	dirty bool
+}
+
+// patriciaEdge points from a node to a child. The suffix is the part of the path of the
+// child beyond the path of the parent and starts with the index of the child in the parent
+type patriciaEdge struct {
+	suffix []byte
+	hash   []byte
+	// the child, loaded from the DB on demand
+	node *patriciaNode
+}
+
+func newPatriciaNode(path []byte) *patriciaNode {
+	return &patriciaNode{path: path, dirty: true}
+}
+
+func (node *patriciaNode) isEmpty() bool {
+	return node.value == nil && node.numChildren() == 0
+}
+
+func (node *patriciaNode) numChildren() int {
+	numChildren := 0
+	for _, edge := range node.children {
+		if edge != nil {
+			numChildren++
+		}
+	}
+	return numChildren
+}
+
+func (node *patriciaNode) getOnlyChild() *patriciaEdge {
+	for _, edge := range node.children {
+		if edge != nil {
+			return edge
+		}
+	}
+	return nil
+}
+
+// computeCryptoHash computes the hash of the dirty nodes of the subtree rooted at this node
+func (node *patriciaNode) computeCryptoHash() []byte {
+	if !node.dirty {
+		return node.hash
+	}
+	for _, edge := range node.children {
+		if edge != nil && edge.node != nil && edge.node.dirty {
+			edge.hash = edge.node.computeCryptoHash()
+		}
+	}
+	node.hash = statemgmt.ComputeCryptoHash(node.marshal())
+	return node.hash
+}
+
+// marshal serializes the value of the node followed by the edges in the order of the child index.
+// The serialized bytes are both stored in the DB and hashed, which lets a client verify a path of
+// nodes from the root (see PatriciaTrie.GetProof and verify.DecodePatriciaNode)
+func (node *patriciaNode) marshal() []byte {
+	buffer := proto.NewBuffer([]byte{})
+	if node.value == nil {
+		buffer.EncodeVarint(0)
+	} else {
+		buffer.EncodeVarint(1)
+		buffer.EncodeRawBytes(node.value)
+	}
+	buffer.EncodeVarint(uint64(node.numChildren()))
+	for _, edge := range node.children {
+		if edge != nil {
+			buffer.EncodeRawBytes(edge.suffix)
+			buffer.EncodeRawBytes(edge.hash)
+		}
+	}
+	return buffer.Bytes()
+}
+
+func unmarshalPatriciaNode(path []byte, serializedBytes []byte) (*patriciaNode, error) {
+	decoded, err := verify.DecodePatriciaNode(serializedBytes)
+	if err != nil {
+		return nil, fmt.Errorf("Error decoding patricia trie node at path [%x]: %s", path, err)
+	}
+	node := &patriciaNode{path: path, value: decoded.Value, hash: statemgmt.ComputeCryptoHash(serializedBytes)}
+	for i, edge := range decoded.Children {
+		if edge != nil {
+			node.children[i] = &patriciaEdge{suffix: edge.Suffix, hash: edge.Hash}
+		}
+	}
+	return node, nil
+}
+
+func unmarshalPatriciaNodeValue(serializedBytes []byte) []byte {
+	node, err := unmarshalPatriciaNode(nil, serializedBytes)
+	if err != nil {
+		panic(fmt.Errorf("This error is not excpected: %s", err))
+	}
+	return node.value
+}
+
+func (node *patriciaNode) String() string {
+	return fmt.Sprintf("path=[%x], value=[%x], numChildren=[%d], dirty=[%t]", node.path, node.value, node.numChildren(), node.dirty)
+}
+
+// The nodes are stored in the DB with the packed nibbles of the path followed by a byte that
+// tells whether the path has an even (0x00) or an odd (0x01) number of nibbles. Because the
+// nodes that hold a value have an even path, their DB key is the composite key followed by 0x00
+// and hence these appear in the order of the composite key.
+const patriciaEvenPathMarker = byte(0x00)
+const patriciaOddPathMarker = byte(0x01)
+
+func encodePatriciaPath(path []byte) []byte {
+	encodedBytes := make([]byte, (len(path)+1)/2, (len(path)+1)/2+1)
+	for i, nibble := range path {
+		if i%2 == 0 {
+			encodedBytes[i/2] = nibble << 4
+		} else {
+			encodedBytes[i/2] |= nibble
+		}
+	}
+	if len(path)%2 == 0 {
+		return append(encodedBytes, patriciaEvenPathMarker)
+	}
+	return append(encodedBytes, patriciaOddPathMarker)
+}
+
+// decodePatriciaCompositeKey returns the composite key for the DB key of a node with an even path
+func decodePatriciaCompositeKey(encodedBytes []byte) ([]byte, bool) {
+	if len(encodedBytes) == 0 || encodedBytes[len(encodedBytes)-1] != patriciaEvenPathMarker {
+		return nil, false
+	}
+	return encodedBytes[:len(encodedBytes)-1], true
+}
+
+func toNibbles(b []byte) []byte {
+	nibbles := make([]byte, 2*len(b))
+	for i, v := range b {
+		nibbles[2*i] = v >> 4
+		nibbles[2*i+1] = v & 0x0f
+	}
+	return nibbles
+}
+
+func newPatriciaPath(chaincodeID string, key string) []byte {
+	return toNibbles(statemgmt.ConstructCompositeKey(chaincodeID, key))
+}
+
+func concatNibbles(a []byte, b []byte) []byte {
+	path := make([]byte, 0, len(a)+len(b))
+	path = append(path, a...)
+	return append(path, b...)
+}
+
+func commonPrefixLength(a []byte, b []byte) int {
+	i := 0
+	for ; i < len(a) && i < len(b) && a[i] == b[i]; i++ {
+	}
+	return i
+}
+
+func fetchPatriciaNodeFromDB(store kvstore.KVStore, path []byte) (*patriciaNode, error) {
+	nodeBytes, err := store.Get(kvstore.StateCFName, encodePatriciaPath(path))
+	if err != nil {
+		return nil, err
+	}
+	if nodeBytes == nil {
+		return nil, nil
+	}
+	return unmarshalPatriciaNode(path, nodeBytes)
+}
+
+func hasNibblesPrefix(path []byte, prefix []byte) bool {
+	return len(path) >= len(prefix) && bytes.Equal(path[:len(prefix)], prefix)
+}
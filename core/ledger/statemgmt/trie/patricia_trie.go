@@ -0,0 +1,384 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package trie
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/db/kvstore"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+	"github.com/hyperledger/fabric/core/ledger/verify"
+)
+
+// PatriciaTrie implements the interface 'statemgmt.HashableState' as a merkle patricia trie with
+// a branching factor of 16 (one nibble of the composite key per level). Unlike the StateTrie, the
+// chains of nodes with a single child are compressed, hence, the number of nodes rehashed for a
+// key is the number of branches on the path of the key. Unlike the bucket tree, a change to a key
+// does not require rehashing the other keys that are assigned to the same bucket. Every node holds
+// the hashes of its children, which gives proofs for a single key (see GetProof).
+type PatriciaTrie struct {
+	store                  kvstore.KVStore
+	root                   *patriciaNode
+	deletedPaths           map[string][]byte
+	persistedStateHash     []byte
+	lastComputedCryptoHash []byte
+	recomputeCryptoHash    bool
+}
+
+// NewPatriciaTrie constructs a new PatriciaTrie that persists the nodes in the given DB
+func NewPatriciaTrie(store kvstore.KVStore) *PatriciaTrie {
+	return &PatriciaTrie{store: store}
+}
+
+// Initialize - method implementation for interface 'statemgmt.HashableState'
+func (trie *PatriciaTrie) Initialize(configs map[string]interface{}) error {
+	root, err := fetchPatriciaNodeFromDB(trie.store, nil)
+	if err != nil {
+		return fmt.Errorf("Error in fetching root node from DB while initializing patricia trie: %s", err)
+	}
+	if root != nil && !root.isEmpty() {
+		trie.persistedStateHash = root.hash
+		trie.lastComputedCryptoHash = trie.persistedStateHash
+	}
+	return nil
+}
+
+// Get - method implementation for interface 'statemgmt.HashableState'
+func (trie *PatriciaTrie) Get(chaincodeID string, key string) ([]byte, error) {
+	node, err := fetchPatriciaNodeFromDB(trie.store, newPatriciaPath(chaincodeID, key))
+	if err != nil || node == nil {
+		return nil, err
+	}
+	return node.value, nil
+}
+
+// PrepareWorkingSet - method implementation for interface 'statemgmt.HashableState'
+func (trie *PatriciaTrie) PrepareWorkingSet(stateDelta *statemgmt.StateDelta) error {
+	root, err := fetchPatriciaNodeFromDB(trie.store, nil)
+	if err != nil {
+		return err
+	}
+	if root == nil {
+		root = newPatriciaNode(nil)
+	}
+	trie.root = root
+	trie.deletedPaths = make(map[string][]byte)
+	for _, chaincodeID := range stateDelta.GetUpdatedChaincodeIds(true) {
+		updates := stateDelta.GetUpdates(chaincodeID)
+		for key, updatedValue := range updates {
+			path := newPatriciaPath(chaincodeID, key)
+			if updatedValue.IsDelete() {
+				err = trie.delete(path)
+			} else {
+				err = trie.set(path, updatedValue.GetValue())
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	trie.recomputeCryptoHash = true
+	return nil
+}
+
+func (trie *PatriciaTrie) loadChild(parent *patriciaNode, edge *patriciaEdge) (*patriciaNode, error) {
+	if edge.node != nil {
+		return edge.node, nil
+	}
+	childPath := concatNibbles(parent.path, edge.suffix)
+	child, err := fetchPatriciaNodeFromDB(trie.store, childPath)
+	if err != nil {
+		return nil, err
+	}
+	if child == nil {
+		return nil, fmt.Errorf("Patricia trie node at path [%x] is missing from DB", childPath)
+	}
+	edge.node = child
+	return child, nil
+}
+
+func (trie *PatriciaTrie) newNode(path []byte) *patriciaNode {
+	delete(trie.deletedPaths, string(path))
+	return newPatriciaNode(path)
+}
+
+func (trie *PatriciaTrie) removeNode(node *patriciaNode) {
+	trie.deletedPaths[string(node.path)] = node.path
+}
+
+func (trie *PatriciaTrie) set(path []byte, value []byte) error {
+	node := trie.root
+	node.dirty = true
+	for {
+		rel := path[len(node.path):]
+		if len(rel) == 0 {
+			node.value = value
+			return nil
+		}
+		edge := node.children[rel[0]]
+		if edge == nil {
+			leaf := trie.newNode(path)
+			leaf.value = value
+			node.children[rel[0]] = &patriciaEdge{suffix: rel, node: leaf}
+			return nil
+		}
+		common := commonPrefixLength(edge.suffix, rel)
+		if common < len(edge.suffix) {
+			// split the edge by inserting a node at the point where the paths diverge
+			branch := trie.newNode(concatNibbles(node.path, rel[:common]))
+			branch.children[edge.suffix[common]] = &patriciaEdge{edge.suffix[common:], edge.hash, edge.node}
+			edge = &patriciaEdge{suffix: rel[:common], node: branch}
+			node.children[rel[0]] = edge
+		}
+		child, err := trie.loadChild(node, edge)
+		if err != nil {
+			return err
+		}
+		child.dirty = true
+		node = child
+	}
+}
+
+func (trie *PatriciaTrie) delete(path []byte) error {
+	stack := []*patriciaNode{trie.root}
+	node := trie.root
+	for len(path) > len(node.path) {
+		rel := path[len(node.path):]
+		edge := node.children[rel[0]]
+		if edge == nil || !hasNibblesPrefix(rel, edge.suffix) {
+			return nil
+		}
+		child, err := trie.loadChild(node, edge)
+		if err != nil {
+			return err
+		}
+		stack = append(stack, child)
+		node = child
+	}
+	if node.value == nil {
+		return nil
+	}
+	for _, n := range stack {
+		n.dirty = true
+	}
+	node.value = nil
+
+	// remove the nodes that are left without a value and children and
+	// compress the node that is left without a value and with a single child
+	for i := len(stack) - 1; i > 0; i-- {
+		node, parent := stack[i], stack[i-1]
+		if node.value != nil {
+			break
+		}
+		index := node.path[len(parent.path)]
+		switch node.numChildren() {
+		case 0:
+			parent.children[index] = nil
+			trie.removeNode(node)
+			continue
+		case 1:
+			edge, onlyChild := parent.children[index], node.getOnlyChild()
+			parent.children[index] = &patriciaEdge{concatNibbles(edge.suffix, onlyChild.suffix), onlyChild.hash, onlyChild.node}
+			trie.removeNode(node)
+		}
+		break
+	}
+	return nil
+}
+
+// ComputeCryptoHash - method implementation for interface 'statemgmt.HashableState'
+func (trie *PatriciaTrie) ComputeCryptoHash() ([]byte, error) {
+	if !trie.recomputeCryptoHash {
+		return trie.lastComputedCryptoHash, nil
+	}
+	if trie.root.isEmpty() {
+		trie.lastComputedCryptoHash = nil
+	} else {
+		trie.lastComputedCryptoHash = trie.root.computeCryptoHash()
+	}
+	trie.recomputeCryptoHash = false
+	return trie.lastComputedCryptoHash, nil
+}
+
+// AddChangesForPersistence - method implementation for interface 'statemgmt.HashableState'
+func (trie *PatriciaTrie) AddChangesForPersistence(writeBatch kvstore.WriteBatch) error {
+	if trie.root == nil {
+		return nil
+	}
+	if trie.recomputeCryptoHash {
+		if _, err := trie.ComputeCryptoHash(); err != nil {
+			return err
+		}
+	}
+	for _, path := range trie.deletedPaths {
+		writeBatch.Delete(kvstore.StateCFName, encodePatriciaPath(path))
+	}
+	if trie.root.isEmpty() {
+		writeBatch.Delete(kvstore.StateCFName, encodePatriciaPath(nil))
+		return nil
+	}
+	addDirtyNodesForPersistence(trie.root, writeBatch)
+	return nil
+}
+
+func addDirtyNodesForPersistence(node *patriciaNode, writeBatch kvstore.WriteBatch) {
+	if !node.dirty {
+		return
+	}
+	writeBatch.Put(kvstore.StateCFName, encodePatriciaPath(node.path), node.marshal())
+	for _, edge := range node.children {
+		if edge != nil && edge.node != nil {
+			addDirtyNodesForPersistence(edge.node, writeBatch)
+		}
+	}
+}
+
+// ClearWorkingSet - method implementation for interface 'statemgmt.HashableState'
+func (trie *PatriciaTrie) ClearWorkingSet(changesPersisted bool) {
+	trie.root = nil
+	trie.deletedPaths = nil
+	trie.recomputeCryptoHash = false
+	if changesPersisted {
+		trie.persistedStateHash = trie.lastComputedCryptoHash
+	} else {
+		trie.lastComputedCryptoHash = trie.persistedStateHash
+	}
+}
+
+// PerfHintKeyChanged - method implementation for interface 'statemgmt.HashableState'
+func (trie *PatriciaTrie) PerfHintKeyChanged(chaincodeID string, key string) {
+}
+
+// GetStateSnapshotIterator - method implementation for interface 'statemgmt.HashableState'
+func (trie *PatriciaTrie) GetStateSnapshotIterator(snapshot kvstore.Snapshot) (statemgmt.StateSnapshotIterator, error) {
+	dbItr := snapshot.NewIterator(kvstore.StateCFName)
+	dbItr.SeekToFirst()
+	return &patriciaIterator{dbItr: dbItr}, nil
+}
+
+// GetRangeScanIterator - method implementation for interface 'statemgmt.HashableState'
+func (trie *PatriciaTrie) GetRangeScanIterator(chaincodeID string, startKey string, endKey string) (statemgmt.RangeScanIterator, error) {
+	return newPatriciaRangeScanIterator(trie.store, chaincodeID, startKey, endKey), nil
+}
+
+// GetRangeScanIteratorFromSnapshot - method implementation for interface 'statemgmt.SnapshotRangeScanProvider'
+func (trie *PatriciaTrie) GetRangeScanIteratorFromSnapshot(snapshot kvstore.Snapshot, chaincodeID string, startKey string, endKey string) (statemgmt.RangeScanIterator, error) {
+	return newPatriciaRangeScanIterator(snapshot, chaincodeID, startKey, endKey), nil
+}
+
+func newPatriciaRangeScanIterator(store kvstore.Reader, chaincodeID string, startKey string, endKey string) *patriciaIterator {
+	dbItr := store.NewIterator(kvstore.StateCFName)
+	dbItr.Seek(encodePatriciaPath(newPatriciaPath(chaincodeID, startKey)))
+	return &patriciaIterator{dbItr: dbItr, rangeScan: true, chaincodeID: chaincodeID, endKey: endKey}
+}
+
+// patriciaIterator implements the interfaces 'statemgmt.StateSnapshotIterator' and 'statemgmt.RangeScanIterator'.
+// It skips the nodes that do not hold a value
+type patriciaIterator struct {
+	dbItr        kvstore.Iterator
+	rangeScan    bool
+	chaincodeID  string
+	endKey       string
+	currentKey   []byte
+	currentValue []byte
+	done         bool
+}
+
+func (itr *patriciaIterator) Next() bool {
+	if itr.done {
+		return false
+	}
+	for ; itr.dbItr.Valid(); itr.dbItr.Next() {
+		compositeKey, ok := decodePatriciaCompositeKey(itr.dbItr.Key())
+		if !ok {
+			continue
+		}
+		value := unmarshalPatriciaNodeValue(itr.dbItr.Value())
+		if value == nil {
+			continue
+		}
+		if itr.rangeScan {
+			chaincodeID, key := statemgmt.DecodeCompositeKey(compositeKey)
+			if chaincodeID != itr.chaincodeID || (itr.endKey != "" && key > itr.endKey) {
+				break
+			}
+		}
+		itr.currentKey = compositeKey
+		itr.currentValue = value
+		itr.dbItr.Next()
+		return true
+	}
+	itr.done = true
+	return false
+}
+
+// GetRawKeyValue - see interface 'statemgmt.StateSnapshotIterator' for details
+func (itr *patriciaIterator) GetRawKeyValue() ([]byte, []byte) {
+	return itr.currentKey, itr.currentValue
+}
+
+// GetKeyValue - see interface 'statemgmt.RangeScanIterator' for details
+func (itr *patriciaIterator) GetKeyValue() (string, []byte) {
+	_, key := statemgmt.DecodeCompositeKey(itr.currentKey)
+	return key, itr.currentValue
+}
+
+func (itr *patriciaIterator) Close() {
+	itr.dbItr.Close()
+}
+
+// GetProof returns the serialized nodes, as committed, on the path from the root down to the key.
+// If the key is not present, the proof ends with the node beyond which the path of the key does
+// not continue. The proof can be verified against the state hash with VerifyPatriciaProof.
+func (trie *PatriciaTrie) GetProof(chaincodeID string, key string) ([][]byte, error) {
+	store := trie.store
+	path := newPatriciaPath(chaincodeID, key)
+	var proof [][]byte
+	var nodePath []byte
+	for {
+		nodeBytes, err := store.Get(kvstore.StateCFName, encodePatriciaPath(nodePath))
+		if err != nil {
+			return nil, err
+		}
+		if nodeBytes == nil {
+			if nodePath == nil {
+				return proof, nil
+			}
+			return nil, fmt.Errorf("Patricia trie node at path [%x] is missing from DB", nodePath)
+		}
+		proof = append(proof, nodeBytes)
+		node, err := unmarshalPatriciaNode(nodePath, nodeBytes)
+		if err != nil {
+			return nil, err
+		}
+		rel := path[len(nodePath):]
+		if len(rel) == 0 {
+			return proof, nil
+		}
+		edge := node.children[rel[0]]
+		if edge == nil || !hasNibblesPrefix(rel, edge.suffix) {
+			return proof, nil
+		}
+		nodePath = concatNibbles(nodePath, edge.suffix)
+	}
+}
+
+// VerifyPatriciaProof verifies a proof returned by PatriciaTrie.GetProof against the state hash.
+// A nil value verifies that the key is not present in the state. Clients can verify the proofs
+// without depending on the peer with verify.VerifyPatriciaProof.
+func VerifyPatriciaProof(stateHash []byte, chaincodeID string, key string, value []byte, proof [][]byte) error {
+	return verify.VerifyPatriciaProof(statemgmt.ComputeCryptoHash, stateHash, chaincodeID, key, value, proof)
+}
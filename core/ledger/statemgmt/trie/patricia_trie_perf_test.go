@@ -0,0 +1,77 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package trie
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric/core/db"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt/buckettree"
+	"github.com/hyperledger/fabric/core/ledger/testutil"
+	"github.com/op/go-logging"
+)
+
+// The benchmarks below compare the cost of computing the state hash for a batch of sparse
+// updates over a populated state for the patricia trie and the bucket tree
+
+const benchmarkPopulatedKeys = 10000
+const benchmarkUpdatedKeys = 10
+
+func benchmarkSparseUpdates(b *testing.B, hashableState statemgmt.HashableState) {
+	b.StopTimer()
+	testutil.SetLogLevel(logging.ERROR, "statemgmt")
+	testutil.SetLogLevel(logging.ERROR, "buckettree")
+	testutil.SetLogLevel(logging.ERROR, "db")
+	persist := func() {
+		writeBatch := db.GetDBHandle().KVStore().NewWriteBatch()
+		defer writeBatch.Destroy()
+		if err := hashableState.AddChangesForPersistence(writeBatch); err != nil {
+			b.Fatalf("Error while adding changes to db write-batch: %s", err)
+		}
+		testDBWrapper.WriteToDB(b, writeBatch)
+		hashableState.ClearWorkingSet(true)
+	}
+	hashableState.PrepareWorkingSet(statemgmt.ConstructRandomStateDelta(b, "cID", 1, benchmarkPopulatedKeys, benchmarkPopulatedKeys, 100))
+	hashableState.ComputeCryptoHash()
+	persist()
+	for i := 0; i < b.N; i++ {
+		delta := statemgmt.ConstructRandomStateDelta(b, "cID", 1, benchmarkPopulatedKeys, benchmarkUpdatedKeys, 100)
+		b.StartTimer()
+		hashableState.PrepareWorkingSet(delta)
+		hashableState.ComputeCryptoHash()
+		b.StopTimer()
+		persist()
+	}
+}
+
+func BenchmarkPatriciaTrieSparseUpdates(b *testing.B) {
+	testDBWrapper.CreateFreshDB(b)
+	patriciaTrie := NewPatriciaTrie(db.GetDBHandle().KVStore())
+	patriciaTrie.Initialize(nil)
+	benchmarkSparseUpdates(b, patriciaTrie)
+}
+
+func BenchmarkBucketTreeSparseUpdates(b *testing.B) {
+	testDBWrapper.CreateFreshDB(b)
+	bucketTree := buckettree.NewStateImpl(db.GetDBHandle().KVStore())
+	err := bucketTree.Initialize(map[string]interface{}{buckettree.ConfigNumBuckets: 10009, buckettree.ConfigMaxGroupingAtEachLevel: 10})
+	if err != nil {
+		b.Fatalf("Error while initializing bucket tree: %s", err)
+	}
+	benchmarkSparseUpdates(b, bucketTree)
+}
@@ -0,0 +1,195 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package trie
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric/core/db"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+	"github.com/hyperledger/fabric/core/ledger/testutil"
+)
+
+type patriciaTrieTestWrapper struct {
+	patriciaTrie *PatriciaTrie
+	t            *testing.T
+}
+
+func newPatriciaTrieTestWrapper(t *testing.T) *patriciaTrieTestWrapper {
+	patriciaTrie := NewPatriciaTrie(db.GetDBHandle().KVStore())
+	err := patriciaTrie.Initialize(nil)
+	testutil.AssertNoError(t, err, "Error while initializing patricia trie")
+	return &patriciaTrieTestWrapper{patriciaTrie, t}
+}
+
+func (testWrapper *patriciaTrieTestWrapper) get(chaincodeID string, key string) []byte {
+	value, err := testWrapper.patriciaTrie.Get(chaincodeID, key)
+	testutil.AssertNoError(testWrapper.t, err, "Error while getting value")
+	return value
+}
+
+func (testWrapper *patriciaTrieTestWrapper) applyAndPersist(stateDelta *statemgmt.StateDelta) []byte {
+	err := testWrapper.patriciaTrie.PrepareWorkingSet(stateDelta)
+	testutil.AssertNoError(testWrapper.t, err, "Error while preparing working set")
+	cryptoHash, err := testWrapper.patriciaTrie.ComputeCryptoHash()
+	testutil.AssertNoError(testWrapper.t, err, "Error while computing crypto hash")
+	writeBatch := db.GetDBHandle().KVStore().NewWriteBatch()
+	defer writeBatch.Destroy()
+	err = testWrapper.patriciaTrie.AddChangesForPersistence(writeBatch)
+	testutil.AssertNoError(testWrapper.t, err, "Error while adding changes to db write-batch")
+	testDBWrapper.WriteToDB(testWrapper.t, writeBatch)
+	testWrapper.patriciaTrie.ClearWorkingSet(true)
+	return cryptoHash
+}
+
+var patriciaTestKeys = []string{"key1", "key10", "key100", "key2", "k", "abc", "key1000", "key11"}
+
+func TestPatriciaTrieHashIsIndependentOfUpdateOrder(t *testing.T) {
+	testDBWrapper.CreateFreshDB(t)
+	bulkDelta := statemgmt.NewStateDelta()
+	for _, key := range patriciaTestKeys {
+		bulkDelta.Set("chaincodeID1", key, []byte("value_"+key), nil)
+		bulkDelta.Set("chaincodeID2", key, []byte("value_"+key), nil)
+	}
+	bulkHash := newPatriciaTrieTestWrapper(t).applyAndPersist(bulkDelta)
+	testutil.AssertNotNil(t, bulkHash)
+
+	// apply the same keys one per batch, in the reverse order
+	testDBWrapper.CreateFreshDB(t)
+	testWrapper := newPatriciaTrieTestWrapper(t)
+	var incrementalHash []byte
+	for i := len(patriciaTestKeys) - 1; i >= 0; i-- {
+		key := patriciaTestKeys[i]
+		delta := statemgmt.NewStateDelta()
+		delta.Set("chaincodeID2", key, []byte("value_"+key), nil)
+		delta.Set("chaincodeID1", key, []byte("value_"+key), nil)
+		incrementalHash = testWrapper.applyAndPersist(delta)
+	}
+	testutil.AssertEquals(t, incrementalHash, bulkHash)
+
+	// a node reloaded after a restart yields the same hash
+	testutil.AssertEquals(t, newPatriciaTrieTestWrapper(t).patriciaTrie.lastComputedCryptoHash, bulkHash)
+
+	// adding and then removing keys restores the hash
+	delta := statemgmt.NewStateDelta()
+	delta.Set("chaincodeID1", "key1001", []byte("value"), nil)
+	delta.Set("chaincodeID1", "ke", []byte("value"), nil)
+	delta.Set("chaincodeID3", "key1", []byte("value"), nil)
+	testutil.AssertNotEquals(t, testWrapper.applyAndPersist(delta), bulkHash)
+	delta = statemgmt.NewStateDelta()
+	delta.Delete("chaincodeID1", "key1001", nil)
+	delta.Delete("chaincodeID1", "ke", nil)
+	delta.Delete("chaincodeID3", "key1", nil)
+	testutil.AssertEquals(t, testWrapper.applyAndPersist(delta), bulkHash)
+
+	// removing all the keys leaves an empty trie
+	delta = statemgmt.NewStateDelta()
+	for _, key := range patriciaTestKeys {
+		delta.Delete("chaincodeID1", key, nil)
+		delta.Delete("chaincodeID2", key, nil)
+	}
+	testutil.AssertNil(t, testWrapper.applyAndPersist(delta))
+	itr := db.GetDBHandle().GetStateCFIterator()
+	defer itr.Close()
+	itr.SeekToFirst()
+	testutil.AssertEquals(t, itr.Valid(), false)
+}
+
+func TestPatriciaTrieGetAndIterators(t *testing.T) {
+	testDBWrapper.CreateFreshDB(t)
+	testWrapper := newPatriciaTrieTestWrapper(t)
+	delta := statemgmt.NewStateDelta()
+	for _, key := range patriciaTestKeys {
+		delta.Set("chaincodeID1", key, []byte("value_"+key), nil)
+	}
+	delta.Set("chaincodeID2", "key1", []byte("value_key1"), nil)
+	testWrapper.applyAndPersist(delta)
+
+	testutil.AssertEquals(t, testWrapper.get("chaincodeID1", "key10"), []byte("value_key10"))
+	testutil.AssertNil(t, testWrapper.get("chaincodeID1", "key"))
+	testutil.AssertNil(t, testWrapper.get("chaincodeID3", "key1"))
+
+	// changes in the working set are not visible till persisted
+	delta = statemgmt.NewStateDelta()
+	delta.Set("chaincodeID1", "key10", []byte("newValue"), nil)
+	testWrapper.patriciaTrie.PrepareWorkingSet(delta)
+	testutil.AssertEquals(t, testWrapper.get("chaincodeID1", "key10"), []byte("value_key10"))
+	testWrapper.patriciaTrie.ClearWorkingSet(false)
+
+	rangeScanItr, err := testWrapper.patriciaTrie.GetRangeScanIterator("chaincodeID1", "key1", "key2")
+	testutil.AssertNoError(t, err, "Error while creating range scan iterator")
+	var keys []string
+	for rangeScanItr.Next() {
+		key, value := rangeScanItr.GetKeyValue()
+		testutil.AssertEquals(t, value, []byte("value_"+key))
+		keys = append(keys, key)
+	}
+	rangeScanItr.Close()
+	testutil.AssertEquals(t, keys, []string{"key1", "key10", "key100", "key1000", "key11", "key2"})
+
+	snapshot := db.GetDBHandle().KVStore().NewSnapshot()
+	defer snapshot.Release()
+	snapshotItr, err := testWrapper.patriciaTrie.GetStateSnapshotIterator(snapshot)
+	testutil.AssertNoError(t, err, "Error while creating snapshot iterator")
+	defer snapshotItr.Close()
+	numKeys := 0
+	for snapshotItr.Next() {
+		k, v := snapshotItr.GetRawKeyValue()
+		chaincodeID, key := statemgmt.DecodeCompositeKey(k)
+		testutil.AssertEquals(t, v, []byte("value_"+key))
+		testutil.AssertEquals(t, testWrapper.get(chaincodeID, key), v)
+		numKeys++
+	}
+	testutil.AssertEquals(t, numKeys, len(patriciaTestKeys)+1)
+}
+
+func TestPatriciaTrieProofs(t *testing.T) {
+	testDBWrapper.CreateFreshDB(t)
+	testWrapper := newPatriciaTrieTestWrapper(t)
+	proof, err := testWrapper.patriciaTrie.GetProof("chaincodeID1", "key1")
+	testutil.AssertNoError(t, err, "Error while getting proof")
+	testutil.AssertNoError(t, VerifyPatriciaProof(nil, "chaincodeID1", "key1", nil, proof), "Error while verifying proof")
+
+	delta := statemgmt.NewStateDelta()
+	for _, key := range patriciaTestKeys {
+		delta.Set("chaincodeID1", key, []byte("value_"+key), nil)
+	}
+	stateHash := testWrapper.applyAndPersist(delta)
+
+	for _, key := range patriciaTestKeys {
+		proof, err := testWrapper.patriciaTrie.GetProof("chaincodeID1", key)
+		testutil.AssertNoError(t, err, "Error while getting proof")
+		testutil.AssertNoError(t, VerifyPatriciaProof(stateHash, "chaincodeID1", key, []byte("value_"+key), proof), "Error while verifying proof")
+		testutil.AssertError(t, VerifyPatriciaProof(stateHash, "chaincodeID1", key, []byte("wrongValue"), proof), "Expected error for wrong value")
+		testutil.AssertError(t, VerifyPatriciaProof(stateHash, "chaincodeID1", key, nil, proof), "Expected error for absence of a present key")
+	}
+
+	for _, key := range []string{"key", "key3", "key1001", "x"} {
+		proof, err := testWrapper.patriciaTrie.GetProof("chaincodeID1", key)
+		testutil.AssertNoError(t, err, "Error while getting proof")
+		testutil.AssertNoError(t, VerifyPatriciaProof(stateHash, "chaincodeID1", key, nil, proof), "Error while verifying proof of absence")
+		testutil.AssertError(t, VerifyPatriciaProof(stateHash, "chaincodeID1", key, []byte("value"), proof), "Expected error for absent key")
+	}
+
+	// a proof does not verify against a different state hash or when tampered with
+	proof, _ = testWrapper.patriciaTrie.GetProof("chaincodeID1", "key100")
+	testutil.AssertError(t, VerifyPatriciaProof([]byte("otherHash"), "chaincodeID1", "key100", []byte("value_key100"), proof), "Expected error for wrong hash")
+	proof[len(proof)-1] = append([]byte{}, proof[len(proof)-1]...)
+	proof[len(proof)-1][len(proof[len(proof)-1])-1] ^= 0xff
+	testutil.AssertError(t, VerifyPatriciaProof(stateHash, "chaincodeID1", "key100", []byte("value_key100"), proof), "Expected error for tampered proof")
+	testutil.AssertError(t, VerifyPatriciaProof(stateHash, "chaincodeID1", "key100", []byte("value_key100"), proof[:1]), "Expected error for truncated proof")
+}
@@ -22,10 +22,10 @@ import (
 
 	"github.com/golang/protobuf/proto"
 	"github.com/hyperledger/fabric/core/db"
+	"github.com/hyperledger/fabric/core/db/kvstore"
 	"github.com/hyperledger/fabric/core/ledger/statemgmt"
 	"github.com/hyperledger/fabric/core/ledger/testutil"
 	"github.com/hyperledger/fabric/core/util"
-	"github.com/tecbot/gorocksdb"
 )
 
 var testDBWrapper = db.NewTestDBWrapper()
@@ -36,7 +36,7 @@ type stateTrieTestWrapper struct {
 }
 
 func newStateTrieTestWrapper(t *testing.T) *stateTrieTestWrapper {
-	return &stateTrieTestWrapper{NewStateTrie(), t}
+	return &stateTrieTestWrapper{NewStateTrie(db.GetDBHandle().KVStore()), t}
 }
 
 func (stateTrieTestWrapper *stateTrieTestWrapper) Get(chaincodeID string, key string) []byte {
@@ -54,13 +54,13 @@ func (stateTrieTestWrapper *stateTrieTestWrapper) PrepareWorkingSetAndComputeCry
 	return cryptoHash
 }
 
-func (stateTrieTestWrapper *stateTrieTestWrapper) AddChangesForPersistence(writeBatch *gorocksdb.WriteBatch) {
+func (stateTrieTestWrapper *stateTrieTestWrapper) AddChangesForPersistence(writeBatch kvstore.WriteBatch) {
 	err := stateTrieTestWrapper.stateTrie.AddChangesForPersistence(writeBatch)
 	testutil.AssertNoError(stateTrieTestWrapper.t, err, "Error while adding changes to db write-batch")
 }
 
 func (stateTrieTestWrapper *stateTrieTestWrapper) PersistChangesAndResetInMemoryChanges() {
-	writeBatch := gorocksdb.NewWriteBatch()
+	writeBatch := db.GetDBHandle().KVStore().NewWriteBatch()
 	defer writeBatch.Destroy()
 	stateTrieTestWrapper.AddChangesForPersistence(writeBatch)
 	testDBWrapper.WriteToDB(stateTrieTestWrapper.t, writeBatch)
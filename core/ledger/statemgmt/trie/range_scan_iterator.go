@@ -17,14 +17,13 @@ limitations under the License.
 package trie
 
 import (
-	"github.com/hyperledger/fabric/core/db"
+	"github.com/hyperledger/fabric/core/db/kvstore"
 	"github.com/hyperledger/fabric/core/ledger/statemgmt"
-	"github.com/tecbot/gorocksdb"
 )
 
 // RangeScanIterator implements the interface 'statemgmt.RangeScanIterator'
 type RangeScanIterator struct {
-	dbItr        *gorocksdb.Iterator
+	dbItr        kvstore.Iterator
 	chaincodeID  string
 	endKey       string
 	currentKey   string
@@ -32,8 +31,8 @@ type RangeScanIterator struct {
 	done         bool
 }
 
-func newRangeScanIterator(chaincodeID string, startKey string, endKey string) (*RangeScanIterator, error) {
-	dbItr := db.GetDBHandle().GetStateCFIterator()
+func newRangeScanIterator(store kvstore.Reader, chaincodeID string, startKey string, endKey string) (*RangeScanIterator, error) {
+	dbItr := store.NewIterator(kvstore.StateCFName)
 	encodedStartKey := newTrieKey(chaincodeID, startKey).getEncodedBytes()
 	dbItr.Seek(encodedStartKey)
 	return &RangeScanIterator{dbItr, chaincodeID, endKey, "", nil, false}, nil
@@ -46,10 +45,8 @@ func (itr *RangeScanIterator) Next() bool {
 	}
 	for ; itr.dbItr.Valid(); itr.dbItr.Next() {
 
-		// making a copy of key-value bytes because, underlying key bytes are reused by itr.
-		// no need to free slices as iterator frees memory when closed.
-		trieKeyBytes := statemgmt.Copy(itr.dbItr.Key().Data())
-		trieNodeBytes := statemgmt.Copy(itr.dbItr.Value().Data())
+		trieKeyBytes := itr.dbItr.Key()
+		trieNodeBytes := itr.dbItr.Value()
 		value := unmarshalTrieNodeValue(trieNodeBytes)
 		if value == nil {
 			continue
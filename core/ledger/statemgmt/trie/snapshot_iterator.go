@@ -17,20 +17,19 @@ limitations under the License.
 package trie
 
 import (
-	"github.com/hyperledger/fabric/core/db"
+	"github.com/hyperledger/fabric/core/db/kvstore"
 	"github.com/hyperledger/fabric/core/ledger/statemgmt"
-	"github.com/tecbot/gorocksdb"
 )
 
 // StateSnapshotIterator implements the interface 'statemgmt.StateSnapshotIterator'
 type StateSnapshotIterator struct {
-	dbItr        *gorocksdb.Iterator
+	dbItr        kvstore.Iterator
 	currentKey   []byte
 	currentValue []byte
 }
 
-func newStateSnapshotIterator(snapshot *gorocksdb.Snapshot) (*StateSnapshotIterator, error) {
-	dbItr := db.GetDBHandle().GetStateCFSnapshotIterator(snapshot)
+func newStateSnapshotIterator(snapshot kvstore.Snapshot) (*StateSnapshotIterator, error) {
+	dbItr := snapshot.NewIterator(kvstore.StateCFName)
 	dbItr.SeekToFirst()
 	// skip the root key, because, the value test in Next method is misleading for root key as the value field
 	dbItr.Next()
@@ -41,11 +40,8 @@ func newStateSnapshotIterator(snapshot *gorocksdb.Snapshot) (*StateSnapshotItera
 func (snapshotItr *StateSnapshotIterator) Next() bool {
 	var available bool
 	for ; snapshotItr.dbItr.Valid(); snapshotItr.dbItr.Next() {
-
-		// making a copy of key-value bytes because, underlying key bytes are reused by itr.
-		// no need to free slices as iterator frees memory when closed.
-		trieKeyBytes := statemgmt.Copy(snapshotItr.dbItr.Key().Data())
-		trieNodeBytes := statemgmt.Copy(snapshotItr.dbItr.Value().Data())
+		trieKeyBytes := snapshotItr.dbItr.Key()
+		trieNodeBytes := snapshotItr.dbItr.Value()
 		value := unmarshalTrieNodeValue(trieNodeBytes)
 		if value != nil {
 			snapshotItr.currentKey = trieKeyEncoderImpl.decodeTrieKeyBytes(statemgmt.Copy(trieKeyBytes))
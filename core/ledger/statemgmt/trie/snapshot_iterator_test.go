@@ -48,7 +48,7 @@ func TestStateSnapshotIterator(t *testing.T) {
 	testutil.AssertEquals(t, stateTrieTestWrapper.Get("chaincodeID6", "key6"), []byte("value6"))
 
 	// take db snapeshot
-	dbSnapshot := db.GetDBHandle().GetSnapshot()
+	dbSnapshot := db.GetDBHandle().KVStore().NewSnapshot()
 
 	stateDelta1 := statemgmt.NewStateDelta()
 	// delete a few keys
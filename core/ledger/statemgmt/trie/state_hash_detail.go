@@ -0,0 +1,61 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package trie
+
+import (
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+)
+
+// GetRootStateHashDetail - see interface 'statemgmt.StateHashDetailProvider' for details.
+// The children are the sub-tries of the root node, the index of a child being the first
+// element of the trie keys under it.
+func (stateTrie *StateTrie) GetRootStateHashDetail() (*statemgmt.StateHashDetail, error) {
+	rootNode, err := fetchTrieNodeFromDB(stateTrie.store, rootTrieKey)
+	if err != nil {
+		return nil, err
+	}
+	detail := &statemgmt.StateHashDetail{}
+	if rootNode == nil {
+		return detail, nil
+	}
+	detail.RootHash = rootNode.computeCryptoHash()
+	for _, index := range rootNode.getSortedChildrenIndex() {
+		detail.Children = append(detail.Children, &statemgmt.StateHashChild{Index: index, Hash: rootNode.childrenCryptoHashes[index]})
+	}
+	return detail, nil
+}
+
+// GetRootStateHashDetail - see interface 'statemgmt.StateHashDetailProvider' for details.
+// The children are the edges of the root node, the index of a child being the first nibble
+// of the composite keys under it.
+func (trie *PatriciaTrie) GetRootStateHashDetail() (*statemgmt.StateHashDetail, error) {
+	root, err := fetchPatriciaNodeFromDB(trie.store, nil)
+	if err != nil {
+		return nil, err
+	}
+	detail := &statemgmt.StateHashDetail{}
+	if root == nil || root.isEmpty() {
+		return detail, nil
+	}
+	detail.RootHash = root.hash
+	for index, edge := range root.children {
+		if edge != nil {
+			detail.Children = append(detail.Children, &statemgmt.StateHashChild{Index: index, Hash: edge.hash})
+		}
+	}
+	return detail, nil
+}
@@ -19,10 +19,9 @@ package trie
 import (
 	"fmt"
 
-	"github.com/hyperledger/fabric/core/db"
+	"github.com/hyperledger/fabric/core/db/kvstore"
 	"github.com/hyperledger/fabric/core/ledger/statemgmt"
 	"github.com/op/go-logging"
-	"github.com/tecbot/gorocksdb"
 )
 
 var stateTrieLogger = logging.MustGetLogger("stateTrie")
@@ -31,20 +30,21 @@ var logHashOfEveryNode = false
 // StateTrie defines the trie for the state, a merkle tree where keys
 // and values are stored for fast hash computation.
 type StateTrie struct {
+	store                  kvstore.KVStore
 	trieDelta              *trieDelta
 	persistedStateHash     []byte
 	lastComputedCryptoHash []byte
 	recomputeCryptoHash    bool
 }
 
-// NewStateTrie contructs a new empty StateTrie
-func NewStateTrie() *StateTrie {
-	return &StateTrie{}
+// NewStateTrie contructs a new empty StateTrie that persists the nodes in the given DB
+func NewStateTrie(store kvstore.KVStore) *StateTrie {
+	return &StateTrie{store: store}
 }
 
 // Initialize the state trie with the root key
 func (stateTrie *StateTrie) Initialize(configs map[string]interface{}) error {
-	rootNode, err := fetchTrieNodeFromDB(rootTrieKey)
+	rootNode, err := fetchTrieNodeFromDB(stateTrie.store, rootTrieKey)
 	if err != nil {
 		panic(fmt.Errorf("Error in fetching root node from DB while initializing state trie: %s", err))
 	}
@@ -57,7 +57,7 @@ func (stateTrie *StateTrie) Initialize(configs map[string]interface{}) error {
 
 // Get the value for a given chaincode ID and key
 func (stateTrie *StateTrie) Get(chaincodeID string, key string) ([]byte, error) {
-	trieNode, err := fetchTrieNodeFromDB(newTrieKey(chaincodeID, key))
+	trieNode, err := fetchTrieNodeFromDB(stateTrie.store, newTrieKey(chaincodeID, key))
 	if err != nil {
 		return nil, err
 	}
@@ -117,7 +117,7 @@ func (stateTrie *StateTrie) ComputeCryptoHash() ([]byte, error) {
 
 func (stateTrie *StateTrie) processChangedNode(changedNode *trieNode) error {
 	stateTrieLogger.Debug("Enter - processChangedNode() for node [%s]", changedNode)
-	dbNode, err := fetchTrieNodeFromDB(changedNode.trieKey)
+	dbNode, err := fetchTrieNodeFromDB(stateTrie.store, changedNode.trieKey)
 	if err != nil {
 		return err
 	}
@@ -141,7 +141,7 @@ func (stateTrie *StateTrie) processChangedNode(changedNode *trieNode) error {
 }
 
 // AddChangesForPersistence commits current changes to the database
-func (stateTrie *StateTrie) AddChangesForPersistence(writeBatch *gorocksdb.WriteBatch) error {
+func (stateTrie *StateTrie) AddChangesForPersistence(writeBatch kvstore.WriteBatch) error {
 	if stateTrie.recomputeCryptoHash {
 		_, err := stateTrie.ComputeCryptoHash()
 		if err != nil {
@@ -154,20 +154,19 @@ func (stateTrie *StateTrie) AddChangesForPersistence(writeBatch *gorocksdb.Write
 		return nil
 	}
 
-	openchainDB := db.GetDBHandle()
 	lowestLevel := stateTrie.trieDelta.getLowestLevel()
 	for level := lowestLevel; level >= 0; level-- {
 		changedNodes := stateTrie.trieDelta.deltaMap[level]
 		for _, changedNode := range changedNodes {
 			if changedNode.markedForDeletion {
-				writeBatch.DeleteCF(openchainDB.StateCF, changedNode.trieKey.getEncodedBytes())
+				writeBatch.Delete(kvstore.StateCFName, changedNode.trieKey.getEncodedBytes())
 				continue
 			}
 			serializedContent, err := changedNode.marshal()
 			if err != nil {
 				return err
 			}
-			writeBatch.PutCF(openchainDB.StateCF, changedNode.trieKey.getEncodedBytes(), serializedContent)
+			writeBatch.Put(kvstore.StateCFName, changedNode.trieKey.getEncodedBytes(), serializedContent)
 		}
 	}
 	stateTrieLogger.Debug("Added changes to DB")
@@ -180,11 +179,16 @@ func (stateTrie *StateTrie) PerfHintKeyChanged(chaincodeID string, key string) {
 }
 
 // GetStateSnapshotIterator - method implementation for interface 'statemgmt.HashableState'
-func (stateTrie *StateTrie) GetStateSnapshotIterator(snapshot *gorocksdb.Snapshot) (statemgmt.StateSnapshotIterator, error) {
+func (stateTrie *StateTrie) GetStateSnapshotIterator(snapshot kvstore.Snapshot) (statemgmt.StateSnapshotIterator, error) {
 	return newStateSnapshotIterator(snapshot)
 }
 
 // GetRangeScanIterator returns an iterator for performing a range scan between the start and end keys
 func (stateTrie *StateTrie) GetRangeScanIterator(chaincodeID string, startKey string, endKey string) (statemgmt.RangeScanIterator, error) {
-	return newRangeScanIterator(chaincodeID, startKey, endKey)
+	return newRangeScanIterator(stateTrie.store, chaincodeID, startKey, endKey)
+}
+
+// GetRangeScanIteratorFromSnapshot - method implementation for interface 'statemgmt.SnapshotRangeScanProvider'
+func (stateTrie *StateTrie) GetRangeScanIteratorFromSnapshot(snapshot kvstore.Snapshot, chaincodeID string, startKey string, endKey string) (statemgmt.RangeScanIterator, error) {
+	return newRangeScanIterator(snapshot, chaincodeID, startKey, endKey)
 }
@@ -16,12 +16,13 @@ limitations under the License.
 
 package trie
 
-import "github.com/hyperledger/fabric/core/db"
+import (
+	"github.com/hyperledger/fabric/core/db/kvstore"
+)
 
-func fetchTrieNodeFromDB(key *trieKey) (*trieNode, error) {
+func fetchTrieNodeFromDB(store kvstore.KVStore, key *trieKey) (*trieNode, error) {
 	stateTrieLogger.Debug("Enter fetchTrieNodeFromDB() for trieKey [%s]", key)
-	openchainDB := db.GetDBHandle()
-	trieNodeBytes, err := openchainDB.GetFromStateCF(key.getEncodedBytes())
+	trieNodeBytes, err := store.Get(kvstore.StateCFName, key.getEncodedBytes())
 	if err != nil {
 		stateTrieLogger.Error("Error in retrieving trie node from DB for triekey [%s]. Error:%s", key, err)
 		return nil, err
@@ -21,7 +21,7 @@ import (
 	"sort"
 
 	"github.com/golang/protobuf/proto"
-	"github.com/hyperledger/fabric/core/util"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
 )
 
 type trieNode struct {
@@ -120,7 +120,7 @@ func (trieNode *trieNode) computeCryptoHash() []byte {
 	}
 
 	stateTrieLogger.Debug("Recomputing hash for trieKey = [%s]", trieNode)
-	return util.ComputeCryptoHash(cryptoHashContent)
+	return statemgmt.ComputeCryptoHash(cryptoHashContent)
 }
 
 func (trieNode *trieNode) containsValue() bool {
@@ -0,0 +1,35 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ledger
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/fabric/core/tracing"
+	"github.com/hyperledger/fabric/protos"
+)
+
+// traceCommit records, in the trace of each transaction of the block, the spans of the
+// computation of the state hash and of the commit of the block
+func traceCommit(blockNumber uint64, transactions []*protos.Transaction, start, hashed, committed time.Time) {
+	tags := map[string]string{"block.number": strconv.FormatUint(blockNumber, 10)}
+	for _, tx := range transactions {
+		tracing.RecordTxSpan(tx.Uuid, "state_hash", start, hashed, tags)
+		tracing.RecordTxSpan(tx.Uuid, "commit", start, committed, tags)
+	}
+}
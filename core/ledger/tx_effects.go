@@ -0,0 +1,216 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ledger
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/db"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+	"github.com/hyperledger/fabric/core/ledger/verify"
+	"github.com/hyperledger/fabric/protos"
+	"github.com/tecbot/gorocksdb"
+)
+
+// The effects of the successful txs of a block, i.e., the hashes of the state changes made by each
+// tx (see GetTempStateHashWithTxDeltaStateHashes), are recorded in the indexesCF when the block is
+// committed. These are the leaves, in the order of the txs in the block, of a merkle tree whose root
+// lets a client verify that the effects of a tx are included in a block with a proof of log size
+// instead of fetching the state deltas (see package verify). The blocks added via PutRawBlock (i.e., by state transfer)
+// are not executed locally and hence do not have the effects recorded. The root is also recorded in the
+// NonHashData of the block, from which clients read it along with the block, and checked by VerifyLedger.
+//
+// key:   prefixTxEffectsKey bigEndian(blockNumber)
+// value: varint(numTxs) [stringBytes(txUUID) rawBytes(stateDeltaHash)]...
+var prefixTxEffectsKey = byte(5)
+
+// TxEffects is the hash of the state changes made by a successful tx. StateDeltaHash is nil if
+// the tx made no changes
+type TxEffects struct {
+	TxUUID         string `json:"txUUID"`
+	StateDeltaHash []byte `json:"stateDeltaHash"`
+}
+
+// TxEffectsProof proves that the effects of a tx are included in the tx effects root of a block.
+// Siblings are the hashes needed to compute the root from the leaf of the tx, bottom up.
+type TxEffectsProof struct {
+	BlockNumber uint64     `json:"blockNumber"`
+	Effects     *TxEffects `json:"effects"`
+	LeafIndex   uint64     `json:"leafIndex"`
+	NumLeaves   uint64     `json:"numLeaves"`
+	Siblings    [][]byte   `json:"siblings"`
+}
+
+// GetTxEffects returns the effects of the successful txs of the block, in the order of the txs in the block
+func (ledger *Ledger) GetTxEffects(blockNumber uint64) ([]*TxEffects, error) {
+	if blockNumber >= ledger.GetBlockchainSize() {
+		return nil, ErrOutOfBounds
+	}
+	effectsBytes, err := ledger.openchainDB.GetFromIndexesCF(encodeTxEffectsKey(blockNumber))
+	if err != nil {
+		return nil, err
+	}
+	if effectsBytes == nil {
+		return nil, ErrResourceNotFound
+	}
+	return decodeTxEffects(effectsBytes)
+}
+
+// GetTxEffectsRoot returns the merkle root over the effects of the successful txs of the
+// block. The root is nil if the block has no successful txs
+func (ledger *Ledger) GetTxEffectsRoot(blockNumber uint64) ([]byte, error) {
+	effects, err := ledger.GetTxEffects(blockNumber)
+	if err != nil {
+		return nil, err
+	}
+	return ComputeTxEffectsRoot(effects), nil
+}
+
+// GetTxEffectsProof returns the proof that the effects of the tx are included in the tx effects
+// root of the block that contains the tx. The proof can be checked with VerifyTxEffectsProof
+func (ledger *Ledger) GetTxEffectsProof(txUUID string) (*TxEffectsProof, error) {
+	blockNumber, _, err := ledger.blockchain.indexer.fetchTransactionIndexByUUID(txUUID)
+	if err != nil {
+		return nil, err
+	}
+	effects, err := ledger.GetTxEffects(blockNumber)
+	if err != nil {
+		return nil, err
+	}
+	for i, e := range effects {
+		if e.TxUUID == txUUID {
+			return &TxEffectsProof{
+				BlockNumber: blockNumber,
+				Effects:     e,
+				LeafIndex:   uint64(i),
+				NumLeaves:   uint64(len(effects)),
+				Siblings:    computeTxEffectsSiblings(effects, i),
+			}, nil
+		}
+	}
+	return nil, newLedgerError(ErrorTypeResourceNotFound,
+		fmt.Sprintf("Transaction [%s] did not change the state successfully in block [%d]", txUUID, blockNumber))
+}
+
+// ComputeTxEffectsRoot computes the merkle root over the tx effects. A level with an odd number of
+// nodes promotes the last node to the next level as is. The root is nil if there are no effects
+func ComputeTxEffectsRoot(effects []*TxEffects) []byte {
+	return verify.ComputeTxEffectsRoot(statemgmt.ComputeCryptoHash, toVerifiableTxEffects(effects))
+}
+
+// VerifyTxEffectsProof verifies that the effects in the proof are included in the tx effects root.
+// Clients can verify the proofs without depending on the peer with verify.VerifyTxEffectsProof.
+func VerifyTxEffectsProof(root []byte, proof *TxEffectsProof) error {
+	if proof == nil || proof.Effects == nil {
+		return fmt.Errorf("Malformed tx effects proof")
+	}
+	return verify.VerifyTxEffectsProof(statemgmt.ComputeCryptoHash, root, &verify.TxEffectsProof{
+		BlockNumber: proof.BlockNumber,
+		Effects:     &verify.TxEffects{TxUUID: proof.Effects.TxUUID, StateDeltaHash: proof.Effects.StateDeltaHash},
+		LeafIndex:   proof.LeafIndex,
+		NumLeaves:   proof.NumLeaves,
+		Siblings:    proof.Siblings,
+	})
+}
+
+func computeTxEffectsSiblings(effects []*TxEffects, index int) [][]byte {
+	return verify.ComputeTxEffectsSiblings(statemgmt.ComputeCryptoHash, toVerifiableTxEffects(effects), index)
+}
+
+func toVerifiableTxEffects(effects []*TxEffects) []*verify.TxEffects {
+	verifiable := make([]*verify.TxEffects, len(effects))
+	for i, e := range effects {
+		verifiable[i] = &verify.TxEffects{TxUUID: e.TxUUID, StateDeltaHash: e.StateDeltaHash}
+	}
+	return verifiable
+}
+
+// verifyTxEffectsRoot checks that the tx effects root in the NonHashData of the block is the merkle
+// root over the effects recorded when the block was committed. The blocks without recorded effects
+// (i.e., received by state transfer) or without a root (i.e., committed before the root was
+// recorded in the block) are not checked
+func (ledger *Ledger) verifyTxEffectsRoot(blockNumber uint64, block *protos.Block) (*LedgerInconsistency, error) {
+	if block.NonHashData == nil || block.NonHashData.TxEffectsRoot == nil {
+		return nil, nil
+	}
+	effects, err := ledger.GetTxEffects(blockNumber)
+	if err == ErrResourceNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if root := ComputeTxEffectsRoot(effects); !bytes.Equal(block.NonHashData.TxEffectsRoot, root) {
+		return &LedgerInconsistency{blockNumber,
+			fmt.Sprintf("Tx effects root [%x] does not match the root of the recorded tx effects [%x]", block.NonHashData.TxEffectsRoot, root)}, nil
+	}
+	return nil, nil
+}
+
+// collectTxEffects returns the effects of the successful txs of the on-going batch in the order of the txs
+func (ledger *Ledger) collectTxEffects(transactions []*protos.Transaction) []*TxEffects {
+	var effects []*TxEffects
+	for _, tx := range transactions {
+		if hash, ok := ledger.state.GetTxStateDeltaHashFor(tx.Uuid); ok {
+			effects = append(effects, &TxEffects{tx.Uuid, hash})
+		}
+	}
+	return effects
+}
+
+func addTxEffectsForPersistence(openchainDB *db.OpenchainDB, blockNumber uint64, effects []*TxEffects, writeBatch *gorocksdb.WriteBatch) {
+	writeBatch.PutCF(openchainDB.IndexesCF, encodeTxEffectsKey(blockNumber), encodeTxEffects(effects))
+}
+
+func encodeTxEffectsKey(blockNumber uint64) []byte {
+	return prependKeyPrefix(prefixTxEffectsKey, encodeUint64(blockNumber))
+}
+
+func encodeTxEffects(effects []*TxEffects) []byte {
+	buffer := proto.NewBuffer([]byte{})
+	buffer.EncodeVarint(uint64(len(effects)))
+	for _, e := range effects {
+		buffer.EncodeStringBytes(e.TxUUID)
+		buffer.EncodeRawBytes(e.StateDeltaHash)
+	}
+	return buffer.Bytes()
+}
+
+func decodeTxEffects(effectsBytes []byte) ([]*TxEffects, error) {
+	buffer := proto.NewBuffer(effectsBytes)
+	numTxs, err := buffer.DecodeVarint()
+	if err != nil {
+		return nil, err
+	}
+	effects := make([]*TxEffects, 0, numTxs)
+	for i := uint64(0); i < numTxs; i++ {
+		e := &TxEffects{}
+		if e.TxUUID, err = buffer.DecodeStringBytes(); err != nil {
+			return nil, err
+		}
+		if e.StateDeltaHash, err = buffer.DecodeRawBytes(true); err != nil {
+			return nil, err
+		}
+		if len(e.StateDeltaHash) == 0 {
+			e.StateDeltaHash = nil
+		}
+		effects = append(effects, e)
+	}
+	return effects, nil
+}
@@ -0,0 +1,94 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ledger
+
+// TxStatus is the outcome of the execution of a committed transaction
+type TxStatus string
+
+const (
+	// TxStatusSucceeded means that the transaction executed successfully and its state changes, if any, were committed
+	TxStatusSucceeded = TxStatus("SUCCEEDED")
+	// TxStatusFailed means that the transaction failed, e.g. because the chaincode returned an error or a
+	// validation rule rejected it, and that its state changes were discarded
+	TxStatusFailed = TxStatus("FAILED")
+	// TxStatusUnknown means that the outcome of the transaction was not recorded, e.g. for a block received
+	// by state transfer from a peer that did not record the results of the transactions
+	TxStatusUnknown = TxStatus("UNKNOWN")
+)
+
+// TransactionReceipt tells where a committed transaction is in the blockchain and what its outcome was.
+// StateDeltaHash is the hash of the state changes made by the transaction (see TxEffects), it is nil if the
+// transaction failed, made no changes or was not executed locally.
+type TransactionReceipt struct {
+	TxUUID         string   `json:"txUUID"`
+	BlockNumber    uint64   `json:"blockNumber"`
+	TxIndex        uint64   `json:"txIndex"`
+	Status         TxStatus `json:"status"`
+	Result         []byte   `json:"result,omitempty"`
+	ErrorCode      uint32   `json:"errorCode,omitempty"`
+	Error          string   `json:"error,omitempty"`
+	StateDeltaHash []byte   `json:"stateDeltaHash,omitempty"`
+}
+
+// GetTransactionResult returns the receipt of a committed transaction. The receipt is assembled from the
+// data persisted with the block: the tx uuid index, the results of the transactions recorded in the block
+// and the tx effects. ErrResourceNotFound is returned if the transaction has not been committed.
+func (ledger *Ledger) GetTransactionResult(txUUID string) (*TransactionReceipt, error) {
+	blockNumber, txIndex, err := ledger.blockchain.indexer.fetchTransactionIndexByUUID(txUUID)
+	if err != nil {
+		return nil, err
+	}
+	block, err := ledger.blockchain.getBlock(blockNumber)
+	if err != nil {
+		return nil, err
+	}
+	if block == nil {
+		return nil, ErrResourceNotFound
+	}
+	receipt := &TransactionReceipt{TxUUID: txUUID, BlockNumber: blockNumber, TxIndex: txIndex, Status: TxStatusUnknown}
+	for _, result := range block.GetNonHashData().GetTransactionResults() {
+		if result.Uuid == txUUID {
+			receipt.Result = result.Result
+			receipt.ErrorCode = result.ErrorCode
+			receipt.Error = result.Error
+			if result.ErrorCode == 0 {
+				receipt.Status = TxStatusSucceeded
+			} else {
+				receipt.Status = TxStatusFailed
+			}
+			break
+		}
+	}
+	effects, err := ledger.GetTxEffects(blockNumber)
+	if err == ErrResourceNotFound {
+		// block received by state transfer
+		return receipt, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	// the effects are recorded for the successful transactions only
+	receipt.Status = TxStatusFailed
+	for _, e := range effects {
+		if e.TxUUID == txUUID {
+			receipt.Status = TxStatusSucceeded
+			receipt.StateDeltaHash = e.StateDeltaHash
+			break
+		}
+	}
+	return receipt, nil
+}
@@ -0,0 +1,411 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ledger
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+	"github.com/hyperledger/fabric/core/util"
+	"github.com/hyperledger/fabric/protos"
+)
+
+// Transaction simulation
+//
+// A transaction can be simulated, instead of executed, against the state of the on-going
+// transaction-batch: the simulation does not open the transaction on the ledger, so many
+// transactions can be simulated concurrently as long as no transaction is in progress. The
+// simulator records the values read by the transaction along with the changes it makes, which
+// 'ApplyTxSimulation' applies later, in the order of the batch, as a transaction of its own.
+// The outcome is the one of executing the transaction at that point if the values it read are
+// still current; otherwise the simulation is stale and the transaction has to be executed again.
+// A transaction can also be simulated against the committed state only, to preview its effects
+// without submitting it; such a simulation can run at any time and is never applied here. Its
+// read-write set can be applied later on any peer by 'ApplyReadWriteSet', which checks the
+// hashes of the values read against the state at that point.
+
+type txSimulators struct {
+	sync.RWMutex
+	m map[string]*TxSimulator
+}
+
+func newTxSimulators() *txSimulators {
+	return &txSimulators{m: make(map[string]*TxSimulator)}
+}
+
+// rangeRead is a range scan made by a simulated transaction, along with the
+// key-values it returned before the changes of the transaction were applied
+type rangeRead struct {
+	chaincodeID string
+	startKey    string
+	endKey      string
+	keyValues   map[string][]byte
+}
+
+// TxSimulator records the reads and the changes of a simulated transaction
+type TxSimulator struct {
+	ledger     *Ledger
+	txUUID     string
+	committed  bool
+	lock       sync.Mutex
+	reads      map[string]map[string][]byte
+	rangeReads []*rangeRead
+	writeSet   *statemgmt.StateDelta
+	// unsupported is the reason why the simulation can not stand for the execution
+	// of the transaction, if the transaction did something a simulation does not capture
+	unsupported string
+}
+
+// NewTxSimulator starts the simulation of the transaction txUUID. The state accessed by the
+// transaction is taken from the simulator returned by 'GetTxSimulator' until 'Done' is invoked.
+// This must not be invoked while a transaction is in progress
+func (ledger *Ledger) NewTxSimulator(txUUID string) *TxSimulator {
+	sim := &TxSimulator{ledger: ledger, txUUID: txUUID, reads: make(map[string]map[string][]byte), writeSet: statemgmt.NewStateDelta()}
+	ledger.simulators.Lock()
+	ledger.simulators.m[txUUID] = sim
+	ledger.simulators.Unlock()
+	return sim
+}
+
+// NewCommittedTxSimulator starts the simulation of the transaction txUUID against the committed
+// state, ignoring the changes of the on-going transaction-batch. Unlike the simulations started
+// by 'NewTxSimulator', this can be invoked at any time, but the simulation must not be applied
+func (ledger *Ledger) NewCommittedTxSimulator(txUUID string) *TxSimulator {
+	sim := ledger.NewTxSimulator(txUUID)
+	sim.committed = true
+	return sim
+}
+
+// GetTxSimulator returns the simulator of the transaction txUUID, or nil if the
+// transaction is not being simulated
+func (ledger *Ledger) GetTxSimulator(txUUID string) *TxSimulator {
+	ledger.simulators.RLock()
+	defer ledger.simulators.RUnlock()
+	return ledger.simulators.m[txUUID]
+}
+
+// ApplyTxSimulation applies the changes recorded by the simulator to the on-going
+// transaction-batch, as the transaction of the simulation. The validation rules are
+// enforced on the changes like for any other transaction. The caller is expected to
+// have checked that the simulation is not stale (see 'IsStale')
+func (ledger *Ledger) ApplyTxSimulation(sim *TxSimulator) error {
+	if sim.committed {
+		return newLedgerError(ErrorTypeInvalidArgument, fmt.Sprintf("The simulation of tx [%s] against the committed state can not be applied", sim.txUUID))
+	}
+	ledger.TxBegin(sim.txUUID)
+	var err error
+	for _, chaincodeID := range sim.writeSet.GetUpdatedChaincodeIds(true) {
+		for key, updatedValue := range sim.writeSet.GetUpdates(chaincodeID) {
+			if updatedValue.IsDelete() {
+				err = ledger.state.Delete(chaincodeID, key)
+			} else {
+				err = ledger.state.Set(chaincodeID, key, updatedValue.GetValue())
+			}
+			if err != nil {
+				break
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = ledger.ValidateTx(sim.txUUID)
+	}
+	ledger.TxFinished(sim.txUUID, err == nil)
+	return err
+}
+
+// Done ends the simulation. The recorded reads and changes remain available
+func (sim *TxSimulator) Done() {
+	sim.ledger.simulators.Lock()
+	if sim.ledger.simulators.m[sim.txUUID] == sim {
+		delete(sim.ledger.simulators.m, sim.txUUID)
+	}
+	sim.ledger.simulators.Unlock()
+}
+
+// GetState returns the state for chaincodeID and key as seen by the simulated transaction
+func (sim *TxSimulator) GetState(chaincodeID string, key string) ([]byte, error) {
+	sim.lock.Lock()
+	defer sim.lock.Unlock()
+	if updatedValue := sim.writeSet.Get(chaincodeID, key); updatedValue != nil {
+		return statemgmt.Copy(updatedValue.GetValue()), nil
+	}
+	if value, ok := sim.reads[chaincodeID][key]; ok {
+		return statemgmt.Copy(value), nil
+	}
+	value, err := sim.ledger.state.Get(chaincodeID, key, sim.committed)
+	if err != nil {
+		return nil, err
+	}
+	if sim.reads[chaincodeID] == nil {
+		sim.reads[chaincodeID] = make(map[string][]byte)
+	}
+	sim.reads[chaincodeID][key] = value
+	return statemgmt.Copy(value), nil
+}
+
+// GetStateRangeScanIterator returns an iterator over the key-values of chaincodeID between
+// startKey and endKey as seen by the simulated transaction, in the order of the keys. The
+// key-values of the range are read at once
+func (sim *TxSimulator) GetStateRangeScanIterator(chaincodeID string, startKey string, endKey string) (statemgmt.RangeScanIterator, error) {
+	keyValues, err := sim.ledger.getStateInRange(chaincodeID, startKey, endKey, sim.committed)
+	if err != nil {
+		return nil, err
+	}
+	sim.lock.Lock()
+	defer sim.lock.Unlock()
+	sim.rangeReads = append(sim.rangeReads, &rangeRead{chaincodeID, startKey, endKey, keyValues})
+	merged := statemgmt.NewStateDelta()
+	for key, value := range keyValues {
+		merged.Set(chaincodeID, key, value, nil)
+	}
+	for key, updatedValue := range sim.writeSet.GetUpdates(chaincodeID) {
+		if updatedValue.IsDelete() {
+			merged.Delete(chaincodeID, key, nil)
+		} else {
+			merged.Set(chaincodeID, key, updatedValue.GetValue(), nil)
+		}
+	}
+	return statemgmt.NewSortedRangeScanIterator(statemgmt.NewStateDeltaRangeScanIterator(merged, chaincodeID, startKey, endKey)), nil
+}
+
+// SetState records the change of the state for chaincodeID and key by the simulated transaction
+func (sim *TxSimulator) SetState(chaincodeID string, key string, value []byte) error {
+	if key == "" || value == nil {
+		return newLedgerError(ErrorTypeInvalidArgument,
+			fmt.Sprintf("An empty string key or a nil value is not supported. Method invoked with key='%s', value='%#v'", key, value))
+	}
+	if err := checkNotSystemNamespace(chaincodeID); err != nil {
+		return err
+	}
+	sim.lock.Lock()
+	defer sim.lock.Unlock()
+	sim.writeSet.Set(chaincodeID, key, value, nil)
+	return nil
+}
+
+// DeleteState records the deletion of the state for chaincodeID and key by the simulated transaction
+func (sim *TxSimulator) DeleteState(chaincodeID string, key string) error {
+	if err := checkNotSystemNamespace(chaincodeID); err != nil {
+		return err
+	}
+	sim.lock.Lock()
+	defer sim.lock.Unlock()
+	sim.writeSet.Delete(chaincodeID, key, nil)
+	return nil
+}
+
+// SetUnsupported marks the simulation as not standing for the execution of the transaction,
+// because the transaction did something that is not captured by a simulation. The
+// simulation is stale from then on
+func (sim *TxSimulator) SetUnsupported(reason string) {
+	sim.lock.Lock()
+	defer sim.lock.Unlock()
+	if sim.unsupported == "" {
+		sim.unsupported = reason
+	}
+}
+
+// IsStale tells whether the simulated transaction has to be executed again, because some value
+// it read is no longer the one of the on-going transaction-batch, or because the simulation was
+// marked as unsupported. This must not be invoked while a transaction is in progress
+func (sim *TxSimulator) IsStale() (bool, error) {
+	sim.lock.Lock()
+	defer sim.lock.Unlock()
+	if sim.unsupported != "" {
+		ledgerLogger.Debug("Simulation of tx [%s] is not supported: %s", sim.txUUID, sim.unsupported)
+		return true, nil
+	}
+	for chaincodeID, keys := range sim.reads {
+		for key, value := range keys {
+			current, err := sim.ledger.state.Get(chaincodeID, key, sim.committed)
+			if err != nil {
+				return false, err
+			}
+			if !bytes.Equal(current, value) {
+				ledgerLogger.Debug("Simulation of tx [%s] is stale: key [%s] of [%s] changed", sim.txUUID, key, chaincodeID)
+				return true, nil
+			}
+		}
+	}
+	for _, read := range sim.rangeReads {
+		current, err := sim.ledger.getStateInRange(read.chaincodeID, read.startKey, read.endKey, sim.committed)
+		if err != nil {
+			return false, err
+		}
+		if !sameKeyValues(current, read.keyValues) {
+			ledgerLogger.Debug("Simulation of tx [%s] is stale: range [%s, %s] of [%s] changed", sim.txUUID, read.startKey, read.endKey, read.chaincodeID)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// GetReadWriteSet returns the keys read by the simulated transaction, its range queries and
+// its changes, sorted by chaincode and key
+func (sim *TxSimulator) GetReadWriteSet() ([]*protos.StateRead, []*protos.StateRangeRead, []*protos.StateWrite) {
+	sim.lock.Lock()
+	defer sim.lock.Unlock()
+	var reads []*protos.StateRead
+	for _, chaincodeID := range sortedKeys(sim.reads) {
+		var keys []string
+		for key := range sim.reads[chaincodeID] {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			reads = append(reads, &protos.StateRead{ChaincodeID: chaincodeID, Key: key, ValueHash: valueHash(sim.reads[chaincodeID][key])})
+		}
+	}
+	var rangeReads []*protos.StateRangeRead
+	for _, read := range sim.rangeReads {
+		rangeRead := &protos.StateRangeRead{ChaincodeID: read.chaincodeID, StartKey: read.startKey, EndKey: read.endKey}
+		for key := range read.keyValues {
+			rangeRead.Keys = append(rangeRead.Keys, key)
+		}
+		sort.Strings(rangeRead.Keys)
+		rangeRead.ValueHash = rangeValueHash(rangeRead.Keys, read.keyValues)
+		rangeReads = append(rangeReads, rangeRead)
+	}
+	var writes []*protos.StateWrite
+	for _, chaincodeID := range sim.writeSet.GetUpdatedChaincodeIds(true) {
+		updates := sim.writeSet.GetUpdates(chaincodeID)
+		var keys []string
+		for key := range updates {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			writes = append(writes, &protos.StateWrite{ChaincodeID: chaincodeID, Key: key, Value: updates[key].GetValue(), IsDelete: updates[key].IsDelete()})
+		}
+	}
+	return reads, rangeReads, writes
+}
+
+// ApplyReadWriteSet applies the changes of a transaction simulated on some peer to the state of
+// the transaction in progress, if the values it read are still the ones of that state. A
+// ledger error of type ErrorTypeStaleRead is returned otherwise, and nothing is changed.
+// Similar to SetState, this must be invoked within a transaction
+func (ledger *Ledger) ApplyReadWriteSet(reads []*protos.StateRead, rangeReads []*protos.StateRangeRead, writes []*protos.StateWrite) error {
+	for _, read := range reads {
+		current, err := ledger.state.Get(read.ChaincodeID, read.Key, false)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(valueHash(current), read.ValueHash) {
+			return newLedgerError(ErrorTypeStaleRead, fmt.Sprintf("Key [%s] of [%s] changed since it was read", read.Key, read.ChaincodeID))
+		}
+	}
+	for _, read := range rangeReads {
+		keyValues, err := ledger.getStateInRange(read.ChaincodeID, read.StartKey, read.EndKey, false)
+		if err != nil {
+			return err
+		}
+		var keys []string
+		for key := range keyValues {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		if !equalKeys(keys, read.Keys) || !bytes.Equal(rangeValueHash(keys, keyValues), read.ValueHash) {
+			return newLedgerError(ErrorTypeStaleRead, fmt.Sprintf("Range [%s, %s] of [%s] changed since it was read", read.StartKey, read.EndKey, read.ChaincodeID))
+		}
+	}
+	for _, write := range writes {
+		var err error
+		if write.IsDelete {
+			err = ledger.DeleteState(write.ChaincodeID, write.Key)
+		} else {
+			err = ledger.SetState(write.ChaincodeID, write.Key, write.Value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// valueHash returns the hash of a value read by a simulated transaction, nil if the key was not set
+func valueHash(value []byte) []byte {
+	if value == nil {
+		return nil
+	}
+	return util.ComputeCryptoHash(value)
+}
+
+// rangeValueHash returns the hash of the hashes of the values of keys, which are sorted
+func rangeValueHash(keys []string, keyValues map[string][]byte) []byte {
+	var hashes []byte
+	for _, key := range keys {
+		hashes = append(hashes, util.ComputeCryptoHash(keyValues[key])...)
+	}
+	return util.ComputeCryptoHash(hashes)
+}
+
+func equalKeys(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func sortedKeys(m map[string]map[string][]byte) []string {
+	var keys []string
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// getStateInRange returns the key-values of chaincodeID between startKey and endKey in the
+// committed state, or in the state of the on-going transaction-batch if committed is false
+func (ledger *Ledger) getStateInRange(chaincodeID string, startKey string, endKey string, committed bool) (map[string][]byte, error) {
+	itr, err := ledger.state.GetRangeScanIterator(chaincodeID, startKey, endKey, committed)
+	if err != nil {
+		return nil, err
+	}
+	defer itr.Close()
+	keyValues := make(map[string][]byte)
+	for itr.Next() {
+		key, value := itr.GetKeyValue()
+		keyValues[key] = statemgmt.Copy(value)
+	}
+	return keyValues, nil
+}
+
+func sameKeyValues(a, b map[string][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key, value := range a {
+		other, ok := b[key]
+		if !ok || !bytes.Equal(value, other) {
+			return false
+		}
+	}
+	return true
+}
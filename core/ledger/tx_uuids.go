@@ -0,0 +1,69 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ledger
+
+import (
+	"fmt"
+)
+
+// NewDuplicateTransactionError returns the error of type ErrorTypeDuplicateTransaction by which a
+// transaction whose uuid was already used is rejected
+func NewDuplicateTransactionError(txUUID string) *Error {
+	return newLedgerError(ErrorTypeDuplicateTransaction, fmt.Sprintf("Transaction [%s] was already submitted", txUUID))
+}
+
+// IsDuplicateTransaction returns whether err rejects a transaction whose uuid was already used
+func IsDuplicateTransaction(err error) bool {
+	ledgerErr, ok := err.(*Error)
+	return ok && ledgerErr.Type() == ErrorTypeDuplicateTransaction
+}
+
+// CheckTxUUID returns an error of type ErrorTypeDuplicateTransaction if a transaction of the
+// blockchain has the uuid
+func (ledger *Ledger) CheckTxUUID(txUUID string) error {
+	_, _, err := ledger.blockchain.indexer.fetchTransactionIndexByUUID(txUUID)
+	if err == ErrResourceNotFound {
+		return nil
+	}
+	if err == nil {
+		return NewDuplicateTransactionError(txUUID)
+	}
+	return err
+}
+
+// AddBatchTxUUID adds the uuid of a transaction to be executed in the batch in progress, unless a
+// transaction of the blockchain or of the batch already has it, in which case an error of type
+// ErrorTypeDuplicateTransaction is returned and the transaction must not be executed. As the
+// blockchain and the batch are the same on all the peers, they all reject the same transactions
+func (ledger *Ledger) AddBatchTxUUID(txUUID string) error {
+	if ledger.batchTxUUIDs[txUUID] {
+		return NewDuplicateTransactionError(txUUID)
+	}
+	if err := ledger.CheckTxUUID(txUUID); err != nil {
+		return err
+	}
+	ledger.batchTxUUIDs[txUUID] = true
+	return nil
+}
+
+// IsReplayedTx tells whether the transaction of the batch in progress was executed before the peer
+// restarted, its state changes having been replayed from the write-ahead log. Such a transaction
+// must not be executed again, else its changes would be applied twice. Its uuid is still added to
+// the batch with AddBatchTxUUID
+func (ledger *Ledger) IsReplayedTx(txUUID string) bool {
+	return ledger.replayedTxUUIDs[txUUID]
+}
@@ -0,0 +1,145 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ledger
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+	"github.com/spf13/viper"
+)
+
+// ValidationRule is a check evaluated against the changes made by each transaction
+// before these are merged into the block being built. A transaction violating one
+// of the enabled rules is rolled back and reported as failed. Because every peer
+// evaluates the same rules while executing the same transactions, a rule has to be
+// deterministic (i.e., depend only on the state and the changes of the transaction)
+// and all the peers of a network have to enable the same rules, in the same order.
+type ValidationRule interface {
+	// Name returns the name under which the rule is registered and enabled
+	Name() string
+	// Validate returns an error if the transaction violates the rule
+	Validate(ctx *TxValidationContext) error
+}
+
+// TxValidationContext gives a validation rule access to the transaction being validated
+type TxValidationContext struct {
+	// TxUUID is the uuid of the transaction being validated
+	TxUUID string
+	// WriteSet contains the changes made by the transaction. This should not be modified
+	WriteSet *statemgmt.StateDelta
+	ledger   *Ledger
+}
+
+// GetPreviousState returns the value of the key before the transaction was executed
+func (ctx *TxValidationContext) GetPreviousState(chaincodeID string, key string) ([]byte, error) {
+	return ctx.ledger.state.GetBeforeCurrentTx(chaincodeID, key)
+}
+
+// GetState returns the value of the key that results from the transaction
+func (ctx *TxValidationContext) GetState(chaincodeID string, key string) ([]byte, error) {
+	if updatedValue := ctx.WriteSet.Get(chaincodeID, key); updatedValue != nil {
+		return updatedValue.GetValue(), nil
+	}
+	return ctx.GetPreviousState(chaincodeID, key)
+}
+
+type validationRuleFunc struct {
+	name     string
+	validate func(ctx *TxValidationContext) error
+}
+
+func (rule *validationRuleFunc) Name() string {
+	return rule.name
+}
+
+func (rule *validationRuleFunc) Validate(ctx *TxValidationContext) error {
+	return rule.validate(ctx)
+}
+
+// NewValidationRule constructs a ValidationRule from a function
+func NewValidationRule(name string, validate func(ctx *TxValidationContext) error) ValidationRule {
+	return &validationRuleFunc{name, validate}
+}
+
+var validationRulesLock sync.RWMutex
+var registeredValidationRules = make(map[string]ValidationRule)
+var enabledValidationRules []ValidationRule
+
+// RegisterValidationRule makes a rule available for being enabled in the configuration
+// ('ledger.validation.rules'). Rules are compiled in the peer and are expected to be
+// registered from the init function of the package that implements them.
+func RegisterValidationRule(rule ValidationRule) error {
+	validationRulesLock.Lock()
+	defer validationRulesLock.Unlock()
+	if _, ok := registeredValidationRules[rule.Name()]; ok {
+		return fmt.Errorf("A validation rule with name [%s] is already registered", rule.Name())
+	}
+	registeredValidationRules[rule.Name()] = rule
+	return nil
+}
+
+func initValidationRules() error {
+	return enableValidationRules(viper.GetStringSlice("ledger.validation.rules"))
+}
+
+// enableValidationRules replaces the enabled rules with the registered rules with the given names
+func enableValidationRules(names []string) error {
+	validationRulesLock.Lock()
+	defer validationRulesLock.Unlock()
+	var rules []ValidationRule
+	for _, name := range names {
+		rule, ok := registeredValidationRules[name]
+		if !ok {
+			return fmt.Errorf("Validation rule [%s] is enabled in the configuration but not registered", name)
+		}
+		rules = append(rules, rule)
+	}
+	if len(rules) > 0 {
+		ledgerLogger.Info("Enabled validation rules: %s", names)
+	}
+	enabledValidationRules = rules
+	return nil
+}
+
+// ValidateTx evaluates the write-once keys (see MarkWriteOnce) and the enabled validation
+// rules against the changes made by the on-going transaction. This has to be invoked before
+// marking the transaction as finished (see TxFinished) and the transaction has to be finished
+// as unsuccessful if an error is returned.
+func (ledger *Ledger) ValidateTx(txUUID string) error {
+	validationRulesLock.RLock()
+	rules := enabledValidationRules
+	validationRulesLock.RUnlock()
+	writeSet := ledger.state.GetCurrentTxStateDelta()
+	if writeSet.IsEmpty() {
+		return nil
+	}
+	if err := ledger.checkWriteOnceKeys(writeSet); err != nil {
+		ledgerLogger.Debug("Transaction [%s] violates write-once keys: %s", txUUID, err)
+		return newLedgerError(ErrorTypeValidationRuleViolated, fmt.Sprintf("Transaction [%s] violates write-once keys: %s", txUUID, err))
+	}
+	ctx := &TxValidationContext{txUUID, writeSet, ledger}
+	for _, rule := range rules {
+		if err := rule.Validate(ctx); err != nil {
+			ledgerLogger.Debug("Transaction [%s] violates validation rule [%s]: %s", txUUID, rule.Name(), err)
+			return newLedgerError(ErrorTypeValidationRuleViolated,
+				fmt.Sprintf("Transaction [%s] violates validation rule [%s]: %s", txUUID, rule.Name(), err))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ledger
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+)
+
+// LedgerInconsistency describes the first inconsistency found by VerifyLedger
+type LedgerInconsistency struct {
+	BlockNumber uint64
+	Reason      string
+}
+
+func (inconsistency *LedgerInconsistency) Error() string {
+	return fmt.Sprintf("Block [%d]: %s", inconsistency.BlockNumber, inconsistency.Reason)
+}
+
+// VerifyLedger re-hashes the blocks from 'from' to 'to' and checks that each of them
+// links to the hash of its previous block and records the tx effects root of the effects
+// committed with it (see verifyTxEffectsRoot). If verifyState is true, the retained state
+// deltas are then replayed backwards from the current state to check the state hash of
+// each of these blocks. The state is left unchanged. The returned inconsistency is nil
+// if none has been found. This must not be invoked while a transaction-batch is in progress.
+func (ledger *Ledger) VerifyLedger(from uint64, to uint64, verifyState bool) (*LedgerInconsistency, error) {
+	if ledger.currentID != nil {
+		return nil, newLedgerError(ErrorTypeInvalidArgument, "Cannot verify the ledger while a transaction-batch is in progress")
+	}
+	size := ledger.GetBlockchainSize()
+	if from > to || to >= size {
+		return nil, ErrOutOfBounds
+	}
+	inconsistency, err := ledger.verifyHashChain(from, to)
+	if err != nil || inconsistency != nil || !verifyState {
+		return inconsistency, err
+	}
+	return ledger.verifyStateHashes(from, to)
+}
+
+func (ledger *Ledger) verifyHashChain(from uint64, to uint64) (*LedgerInconsistency, error) {
+	var previousBlockHash []byte
+	if from > 0 {
+		previousBlock, err := ledger.GetBlockByNumber(from - 1)
+		if err != nil {
+			return &LedgerInconsistency{from - 1, fmt.Sprintf("Cannot read the block: %s", err)}, nil
+		}
+		if previousBlockHash, err = previousBlock.GetHash(); err != nil {
+			return nil, err
+		}
+	}
+	for blockNumber := from; blockNumber <= to; blockNumber++ {
+		block, err := ledger.GetBlockByNumber(blockNumber)
+		if err != nil {
+			return &LedgerInconsistency{blockNumber, fmt.Sprintf("Cannot read the block: %s", err)}, nil
+		}
+		if blockNumber > 0 && !bytes.Equal(block.PreviousBlockHash, previousBlockHash) {
+			return &LedgerInconsistency{blockNumber,
+				fmt.Sprintf("Previous block hash [%x] does not match the hash of block [%d] [%x]", block.PreviousBlockHash, blockNumber-1, previousBlockHash)}, nil
+		}
+		if inconsistency, err := ledger.verifyTxEffectsRoot(blockNumber, block); err != nil || inconsistency != nil {
+			return inconsistency, err
+		}
+		if previousBlockHash, err = block.GetHash(); err != nil {
+			return nil, err
+		}
+		if blockNumber == ledger.GetBlockchainSize()-1 && !bytes.Equal(previousBlockHash, ledger.blockchain.previousBlockHash) {
+			return &LedgerInconsistency{blockNumber, "The hash of the last block does not match the one in use for the next block"}, nil
+		}
+	}
+	return nil, nil
+}
+
+// verifyStateHashes rolls the committed state backwards, block by block, by accumulating
+// the inverse of the retained state deltas and compares the resulting state hashes with
+// the ones recorded in the blocks
+func (ledger *Ledger) verifyStateHashes(from uint64, to uint64) (*LedgerInconsistency, error) {
+	rollback := statemgmt.NewStateDelta()
+	const id = "verifyLedger"
+	for blockNumber := ledger.GetBlockchainSize() - 1; ; blockNumber-- {
+		if blockNumber <= to {
+			block, err := ledger.GetBlockByNumber(blockNumber)
+			if err != nil {
+				return nil, err
+			}
+			if err := ledger.ApplyStateDelta(id, rollback); err != nil {
+				return nil, err
+			}
+			stateHash, err := ledger.GetTempStateHash()
+			ledger.RollbackStateDelta(id)
+			if err != nil {
+				return nil, err
+			}
+			if !bytes.Equal(stateHash, block.StateHash) {
+				return &LedgerInconsistency{blockNumber,
+					fmt.Sprintf("State hash [%x] does not match the replayed state hash [%x]", block.StateHash, stateHash)}, nil
+			}
+		}
+		if blockNumber == from {
+			return nil, nil
+		}
+		delta, err := ledger.GetStateDelta(blockNumber)
+		if err != nil {
+			return nil, err
+		}
+		if delta == nil {
+			return &LedgerInconsistency{blockNumber, "The state delta is not retained, the state cannot be replayed"}, nil
+		}
+		for _, chaincodeID := range delta.GetUpdatedChaincodeIds(true) {
+			for key, updatedValue := range delta.GetUpdates(chaincodeID) {
+				// the older value overrides the ones of the blocks already rolled back
+				if previousValue := updatedValue.GetPreviousValue(); previousValue == nil {
+					rollback.Delete(chaincodeID, key, updatedValue.GetValue())
+				} else {
+					rollback.Set(chaincodeID, key, previousValue, updatedValue.GetValue())
+				}
+			}
+		}
+	}
+}
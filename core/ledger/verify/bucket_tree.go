@@ -0,0 +1,140 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package verify
+
+import (
+	"bytes"
+	"fmt"
+	"hash/crc32"
+	"hash/fnv"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// BucketTreeConfig is the configuration of the bucket tree state of the peers
+// (see 'ledger.state.dataStructure.configs' in core.yaml)
+type BucketTreeConfig struct {
+	NumBuckets             int
+	MaxGroupingAtEachLevel int
+	// BucketHashFunction assigns the keys to the buckets. One of fnv32a (default), fnv32 and crc32
+	BucketHashFunction string
+}
+
+var bucketHashFunctions = map[string]func(data []byte) uint32{
+	"fnv32a": func(data []byte) uint32 {
+		h := fnv.New32a()
+		h.Write(data)
+		return h.Sum32()
+	},
+	"fnv32": func(data []byte) uint32 {
+		h := fnv.New32()
+		h.Write(data)
+		return h.Sum32()
+	},
+	"crc32": crc32.ChecksumIEEE,
+}
+
+// ComputeBucketTreeStateHash computes, from scratch, the state hash of a peer using the bucket
+// tree state with the given configuration and holding the given key-values. The key-values may
+// be given in any order but a key may appear only once. The state hash is nil for an empty state.
+func ComputeBucketTreeStateHash(hash HashFunc, conf *BucketTreeConfig, state []*KeyValue) ([]byte, error) {
+	if conf.NumBuckets <= 0 || conf.MaxGroupingAtEachLevel < 2 {
+		return nil, fmt.Errorf("Invalid bucket tree configuration: numBuckets=[%d], maxGroupingAtEachLevel=[%d]",
+			conf.NumBuckets, conf.MaxGroupingAtEachLevel)
+	}
+	bucketHashFunctionName := conf.BucketHashFunction
+	if bucketHashFunctionName == "" {
+		bucketHashFunctionName = "fnv32a"
+	}
+	bucketHashFunction, ok := bucketHashFunctions[bucketHashFunctionName]
+	if !ok {
+		return nil, fmt.Errorf("Unknown bucket hash function [%s]", bucketHashFunctionName)
+	}
+
+	// the key-values of each bucket at the lowest level in the order of the composite key
+	buckets := make(map[int][]*KeyValue)
+	for _, kv := range sortKeyValues(state) {
+		if kv.Value == nil {
+			continue
+		}
+		bucketNumber := int(bucketHashFunction(ConstructCompositeKey(kv.ChaincodeID, kv.Key)))%conf.NumBuckets + 1
+		buckets[bucketNumber] = append(buckets[bucketNumber], kv)
+	}
+	level := make(map[int][]byte)
+	for bucketNumber, keyValues := range buckets {
+		level[bucketNumber] = computeDataBucketHash(hash, keyValues)
+	}
+
+	// merge the buckets level by level up to the root
+	for numBuckets := conf.NumBuckets; numBuckets > 1; numBuckets = (numBuckets + conf.MaxGroupingAtEachLevel - 1) / conf.MaxGroupingAtEachLevel {
+		children := make(map[int][][]byte)
+		for bucketNumber := 1; bucketNumber <= numBuckets; bucketNumber++ {
+			if childHash := level[bucketNumber]; childHash != nil {
+				parentNumber := (bucketNumber + conf.MaxGroupingAtEachLevel - 1) / conf.MaxGroupingAtEachLevel
+				children[parentNumber] = append(children[parentNumber], childHash)
+			}
+		}
+		level = make(map[int][]byte)
+		for parentNumber, childrenHashes := range children {
+			level[parentNumber] = ComputeBucketNodeHash(hash, childrenHashes)
+		}
+	}
+	return level[1], nil
+}
+
+// ComputeBucketNodeHash computes the hash of a bucket of the bucket tree above the lowest level
+// from the hashes of its non-empty children, in the order of the children. A bucket with a single
+// non-empty child takes the hash of the child. This lets a client check the children hashes of
+// the root bucket, as returned by the /state/hash endpoint of the peer, against the state hash.
+func ComputeBucketNodeHash(hash HashFunc, childrenHashes [][]byte) []byte {
+	switch len(childrenHashes) {
+	case 0:
+		return nil
+	case 1:
+		return childrenHashes[0]
+	}
+	return hash(bytes.Join(childrenHashes, nil))
+}
+
+// computeDataBucketHash computes the hash of a bucket at the lowest level from its key-values
+// given in the order of the composite key. Each chaincode contributes its length prefixed ID and
+// number of keys followed by the length prefixed keys and values.
+func computeDataBucketHash(hash HashFunc, keyValues []*KeyValue) []byte {
+	var hashingData []byte
+	appendSize := func(size int) {
+		hashingData = append(hashingData, proto.EncodeVarint(uint64(size))...)
+	}
+	appendSizeAndData := func(b []byte) {
+		appendSize(len(b))
+		hashingData = append(hashingData, b...)
+	}
+	for i := 0; i < len(keyValues); {
+		chaincodeID := keyValues[i].ChaincodeID
+		j := i
+		for j < len(keyValues) && keyValues[j].ChaincodeID == chaincodeID {
+			j++
+		}
+		appendSizeAndData([]byte(chaincodeID))
+		appendSize(j - i)
+		for _, kv := range keyValues[i:j] {
+			appendSizeAndData([]byte(kv.Key))
+			appendSizeAndData(kv.Value)
+		}
+		i = j
+	}
+	return hash(hashingData)
+}
@@ -0,0 +1,62 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package verify
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestComputeBucketNodeHash(t *testing.T) {
+	hash, _ := GetHashFunc(DefaultHashAlgorithm)
+	if ComputeBucketNodeHash(hash, nil) != nil {
+		t.Fatalf("Expected a nil hash for a bucket without children")
+	}
+	if !bytes.Equal(ComputeBucketNodeHash(hash, [][]byte{[]byte("child")}), []byte("child")) {
+		t.Fatalf("Expected the hash of the single child to be propagated")
+	}
+	if !bytes.Equal(ComputeBucketNodeHash(hash, [][]byte{[]byte("child1"), []byte("child2")}), hash([]byte("child1child2"))) {
+		t.Fatalf("Unexpected hash for a bucket with two children")
+	}
+}
+
+func TestComputeBucketTreeStateHash(t *testing.T) {
+	hash, _ := GetHashFunc(DefaultHashAlgorithm)
+	conf := &BucketTreeConfig{NumBuckets: 10, MaxGroupingAtEachLevel: 3}
+	stateHash, err := ComputeBucketTreeStateHash(hash, conf, nil)
+	if err != nil || stateHash != nil {
+		t.Fatalf("Expected a nil hash for the empty state, got [%x], err=[%v]", stateHash, err)
+	}
+
+	// a single key ends up in a single bucket whose hash is propagated up to the root
+	stateHash, _ = ComputeBucketTreeStateHash(hash, conf, []*KeyValue{{"chaincodeID1", "key1", []byte("value1")}})
+	expected := hash([]byte("\x0cchaincodeID1\x01\x04key1\x06value1"))
+	if !bytes.Equal(stateHash, expected) {
+		t.Fatalf("Unexpected state hash for a single key")
+	}
+	// deleted keys do not contribute
+	stateHash, _ = ComputeBucketTreeStateHash(hash, conf, []*KeyValue{{"chaincodeID1", "key1", []byte("value1")}, {"chaincodeID1", "key2", nil}})
+	if !bytes.Equal(stateHash, expected) {
+		t.Fatalf("Unexpected state hash with a deleted key")
+	}
+
+	if _, err := ComputeBucketTreeStateHash(hash, &BucketTreeConfig{NumBuckets: 10, MaxGroupingAtEachLevel: 1}, nil); err == nil {
+		t.Fatalf("Expected an error for an invalid configuration")
+	}
+	if _, err := ComputeBucketTreeStateHash(hash, &BucketTreeConfig{NumBuckets: 10, MaxGroupingAtEachLevel: 3, BucketHashFunction: "md5"}, nil); err == nil {
+		t.Fatalf("Expected an error for an unknown bucket hash function")
+	}
+}
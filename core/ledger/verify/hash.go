@@ -0,0 +1,79 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package verify lets clients, such as SDKs and auditing tools, independently verify the
+// data served by the peers: the hashes of state deltas, the inclusion proofs of tx effects
+// and of state keys, and the state hash of a given set of key-values. The package depends
+// neither on RocksDB nor on the other packages of the peer so that it can be embedded on
+// its own. The peer computes these hashes with the same functions.
+//
+// All the functions take the HashFunc that the network uses for state hashing, which is
+// recorded in the genesis block (see 'ledger.state.hashAlgorithm' in core.yaml).
+package verify
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sort"
+
+	"github.com/dchest/blake2b"
+	"golang.org/x/crypto/sha3"
+)
+
+// HashFunc computes the crypto-hash of data
+type HashFunc func(data []byte) []byte
+
+// DefaultHashAlgorithm is the hash algorithm used by the ledgers for which none is configured
+const DefaultHashAlgorithm = "SHAKE256"
+
+var hashFuncs = map[string]HashFunc{
+	DefaultHashAlgorithm: func(data []byte) []byte {
+		hash := make([]byte, 64)
+		sha3.ShakeSum256(hash, data)
+		return hash
+	},
+	"SHA2_256": func(data []byte) []byte {
+		hash := sha256.Sum256(data)
+		return hash[:]
+	},
+	"SHA3_256": func(data []byte) []byte {
+		hash := sha3.Sum256(data)
+		return hash[:]
+	},
+	"BLAKE2B_256": func(data []byte) []byte {
+		hash := blake2b.Sum256(data)
+		return hash[:]
+	},
+}
+
+// GetHashFunc returns the hash function of the hash algorithm with the given name
+func GetHashFunc(name string) (HashFunc, error) {
+	hashFunc, ok := hashFuncs[name]
+	if !ok {
+		return nil, fmt.Errorf("Hash algorithm [%s] is not supported. Supported algorithms are %v", name, HashAlgorithms())
+	}
+	return hashFunc, nil
+}
+
+// HashAlgorithms returns the sorted names of the supported hash algorithms
+func HashAlgorithms() []string {
+	var names []string
+	for name := range hashFuncs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,51 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package verify
+
+import (
+	"go/build"
+	"strings"
+	"testing"
+)
+
+func TestGetHashFunc(t *testing.T) {
+	expectedSizes := map[string]int{"SHAKE256": 64, "SHA2_256": 32, "SHA3_256": 32, "BLAKE2B_256": 32}
+	for _, name := range HashAlgorithms() {
+		hash, err := GetHashFunc(name)
+		if err != nil {
+			t.Fatalf("Error getting hash algorithm [%s]: %s", name, err)
+		}
+		if size := len(hash([]byte("data"))); size != expectedSizes[name] {
+			t.Fatalf("Expected a hash of [%d] bytes for [%s], got [%d]", expectedSizes[name], name, size)
+		}
+	}
+	if _, err := GetHashFunc("MD5"); err == nil {
+		t.Fatalf("Expected an error for an unsupported hash algorithm")
+	}
+}
+
+// The package is embedded by clients and hence must not pull RocksDB or the packages of the peer
+func TestDependencies(t *testing.T) {
+	pkg, err := build.ImportDir(".", 0)
+	if err != nil {
+		t.Fatalf("Error reading the package: %s", err)
+	}
+	for _, imported := range pkg.Imports {
+		if strings.HasPrefix(imported, "github.com/hyperledger/fabric") || strings.Contains(imported, "rocksdb") {
+			t.Fatalf("Package verify must not import [%s]", imported)
+		}
+	}
+}
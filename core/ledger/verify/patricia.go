@@ -0,0 +1,146 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package verify
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// PatriciaTrieWidth is the number of children of a node of the patricia trie state, one per nibble
+const PatriciaTrieWidth = 16
+
+// PatriciaNode is a node of the patricia trie state as serialized in the DB and in the proofs.
+// The path of a node is made of the nibbles of the composite key (see ConstructCompositeKey)
+// from the root down to the node. The hash of a node is the hash of its serialized bytes.
+type PatriciaNode struct {
+	Value []byte
+	// Children holds the edges to the children, indexed by the first nibble of their suffix
+	Children [PatriciaTrieWidth]*PatriciaEdge
+}
+
+// PatriciaEdge points from a node to a child. The suffix is the part of the path of the
+// child beyond the path of the parent.
+type PatriciaEdge struct {
+	Suffix []byte
+	Hash   []byte
+}
+
+// DecodePatriciaNode decodes a serialized node, i.e., a varint flag telling whether the node
+// holds a value, the value if any, and the edges in the order of the child index
+func DecodePatriciaNode(serializedBytes []byte) (*PatriciaNode, error) {
+	node := &PatriciaNode{}
+	buffer := proto.NewBuffer(serializedBytes)
+	hasValue, err := buffer.DecodeVarint()
+	if err != nil {
+		return nil, err
+	}
+	if hasValue == 1 {
+		if node.Value, err = buffer.DecodeRawBytes(true); err != nil {
+			return nil, err
+		}
+	}
+	numChildren, err := buffer.DecodeVarint()
+	if err != nil {
+		return nil, err
+	}
+	for i := uint64(0); i < numChildren; i++ {
+		edge := &PatriciaEdge{}
+		if edge.Suffix, err = buffer.DecodeRawBytes(true); err != nil {
+			return nil, err
+		}
+		if edge.Hash, err = buffer.DecodeRawBytes(true); err != nil {
+			return nil, err
+		}
+		if len(edge.Suffix) == 0 || int(edge.Suffix[0]) >= PatriciaTrieWidth {
+			return nil, fmt.Errorf("Invalid edge in patricia trie node")
+		}
+		node.Children[edge.Suffix[0]] = edge
+	}
+	return node, nil
+}
+
+// VerifyPatriciaProof verifies a proof of the patricia trie state, i.e., the serialized nodes on
+// the path from the root down to the key, against the state hash. If the key is not present, the
+// proof ends with the node beyond which the path of the key does not continue. A nil value
+// verifies that the key is not present in the state.
+func VerifyPatriciaProof(hash HashFunc, stateHash []byte, chaincodeID string, key string, value []byte, proof [][]byte) error {
+	if len(proof) == 0 {
+		if stateHash != nil {
+			return fmt.Errorf("Empty proof for a non-empty state")
+		}
+		if value != nil {
+			return fmt.Errorf("Key [%s] is not present in the empty state", key)
+		}
+		return nil
+	}
+	path := toNibbles(ConstructCompositeKey(chaincodeID, key))
+	pathLen := 0
+	expectedHash := stateHash
+	var node *PatriciaNode
+	for i, nodeBytes := range proof {
+		var err error
+		if node, err = DecodePatriciaNode(nodeBytes); err != nil {
+			return fmt.Errorf("Invalid node at position [%d] in the proof: %s", i, err)
+		}
+		if !bytes.Equal(hash(nodeBytes), expectedHash) {
+			return fmt.Errorf("Hash mismatch for node at position [%d] in the proof", i)
+		}
+		rel := path[pathLen:]
+		if len(rel) == 0 {
+			if i != len(proof)-1 {
+				return fmt.Errorf("Proof continues beyond the node of the key")
+			}
+			break
+		}
+		edge := node.Children[rel[0]]
+		if edge == nil || !bytes.HasPrefix(rel, edge.Suffix) {
+			if i != len(proof)-1 {
+				return fmt.Errorf("Proof continues beyond the path of the key")
+			}
+			node = nil
+			break
+		}
+		if i == len(proof)-1 {
+			return fmt.Errorf("Proof ends before reaching the key")
+		}
+		pathLen += len(edge.Suffix)
+		expectedHash = edge.Hash
+	}
+	var provenValue []byte
+	if node != nil {
+		provenValue = node.Value
+	}
+	if provenValue == nil && value == nil {
+		return nil
+	}
+	if provenValue == nil || value == nil || !bytes.Equal(provenValue, value) {
+		return fmt.Errorf("Value of key [%s] does not match the proof", key)
+	}
+	return nil
+}
+
+func toNibbles(b []byte) []byte {
+	nibbles := make([]byte, 2*len(b))
+	for i, v := range b {
+		nibbles[2*i] = v >> 4
+		nibbles[2*i+1] = v & 0x0f
+	}
+	return nibbles
+}
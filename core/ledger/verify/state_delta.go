@@ -0,0 +1,104 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package verify
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/golang/protobuf/proto"
+)
+
+var compositeKeyDelimiter = []byte{0x00}
+
+// KeyValue is a key of a chaincode along with its value. In a state delta, a nil Value
+// stands for the deletion of the key.
+type KeyValue struct {
+	ChaincodeID string `json:"chaincodeID"`
+	Key         string `json:"key"`
+	Value       []byte `json:"value"`
+}
+
+// ConstructCompositeKey returns the key under which the state holds the key of a chaincode,
+// i.e., the chaincodeID and the key separated by a 0x00 byte
+func ConstructCompositeKey(chaincodeID string, key string) []byte {
+	return bytes.Join([][]byte{[]byte(chaincodeID), []byte(key)}, compositeKeyDelimiter)
+}
+
+// ComputeStateDeltaHash computes the hash of the changes made by a state delta, e.g., by a tx
+// (see TxEffects). The changes may be given in any order but a key may appear only once. The
+// hash is nil if there are no changes.
+func ComputeStateDeltaHash(hash HashFunc, changes []*KeyValue) []byte {
+	if len(changes) == 0 {
+		return nil
+	}
+	sorted := sortKeyValues(changes)
+	var buffer bytes.Buffer
+	for i, change := range sorted {
+		if i == 0 || change.ChaincodeID != sorted[i-1].ChaincodeID {
+			buffer.WriteString(change.ChaincodeID)
+		}
+		buffer.WriteString(change.Key)
+		buffer.Write(change.Value)
+	}
+	return hash(buffer.Bytes())
+}
+
+// ComputeChainedStateHash computes the state hash of the raw state implementation after
+// applying a state delta, i.e., the hash of the previous state hash followed by the hash
+// of the delta (see ComputeStateDeltaHash). The state hash of a ledger using the raw state
+// is obtained by chaining the deltas of the blocks, starting with a nil state hash.
+func ComputeChainedStateHash(hash HashFunc, previousStateHash []byte, stateDeltaHash []byte) []byte {
+	var hashingContent []byte
+	hashingContent = append(hashingContent, previousStateHash...)
+	hashingContent = append(hashingContent, stateDeltaHash...)
+	return hash(hashingContent)
+}
+
+// ComputeTxStateDeltaAggregateHash computes the hash over the state delta hashes of txs,
+// taken in the given order. The hash is nil if there are no txs.
+func ComputeTxStateDeltaAggregateHash(hash HashFunc, effects []*TxEffects) []byte {
+	if len(effects) == 0 {
+		return nil
+	}
+	buffer := proto.NewBuffer([]byte{})
+	for _, e := range effects {
+		buffer.EncodeStringBytes(e.TxUUID)
+		buffer.EncodeRawBytes(e.StateDeltaHash)
+	}
+	return hash(buffer.Bytes())
+}
+
+// sortKeyValues returns a copy of the key-values sorted by chaincodeID and key, which is also
+// the order of their composite keys
+func sortKeyValues(keyValues []*KeyValue) []*KeyValue {
+	sorted := make([]*KeyValue, len(keyValues))
+	copy(sorted, keyValues)
+	sort.Sort(keyValueSorter(sorted))
+	return sorted
+}
+
+type keyValueSorter []*KeyValue
+
+func (s keyValueSorter) Len() int      { return len(s) }
+func (s keyValueSorter) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s keyValueSorter) Less(i, j int) bool {
+	if s[i].ChaincodeID != s[j].ChaincodeID {
+		return s[i].ChaincodeID < s[j].ChaincodeID
+	}
+	return s[i].Key < s[j].Key
+}
@@ -0,0 +1,51 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package verify
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestComputeStateDeltaHash(t *testing.T) {
+	hash, _ := GetHashFunc(DefaultHashAlgorithm)
+	if ComputeStateDeltaHash(hash, nil) != nil {
+		t.Fatalf("Expected a nil hash for no changes")
+	}
+	changes := []*KeyValue{
+		{"chaincodeID2", "key1", []byte("value1")},
+		{"chaincodeID1", "key2", nil},
+		{"chaincodeID1", "key1", []byte("value2")},
+	}
+	expected := hash([]byte("chaincodeID1key1value2key2chaincodeID2key1value1"))
+	if !bytes.Equal(ComputeStateDeltaHash(hash, changes), expected) {
+		t.Fatalf("Unexpected state delta hash")
+	}
+	if changes[0].ChaincodeID != "chaincodeID2" {
+		t.Fatalf("The changes passed have been reordered")
+	}
+}
+
+func TestComputeChainedStateHash(t *testing.T) {
+	hash, _ := GetHashFunc(DefaultHashAlgorithm)
+	first := ComputeChainedStateHash(hash, nil, []byte("delta1"))
+	if !bytes.Equal(first, hash([]byte("delta1"))) {
+		t.Fatalf("Unexpected state hash for the first delta")
+	}
+	if !bytes.Equal(ComputeChainedStateHash(hash, first, []byte("delta2")), hash(append(first, []byte("delta2")...))) {
+		t.Fatalf("Unexpected state hash for the second delta")
+	}
+}
@@ -0,0 +1,142 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package verify
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// The effects of the successful txs of a block are the leaves, in the order of the txs in the
+// block, of a merkle tree. A level with an odd number of nodes promotes its last node to the
+// next level as is. The leaves and the inner nodes are hashed with different prefixes so that
+// an inner node cannot be presented as a leaf.
+const (
+	txEffectsLeafPrefix = byte(0)
+	txEffectsNodePrefix = byte(1)
+)
+
+// TxEffects is the hash of the state changes made by a successful tx (see ComputeStateDeltaHash).
+// StateDeltaHash is nil if the tx made no changes
+type TxEffects struct {
+	TxUUID         string `json:"txUUID"`
+	StateDeltaHash []byte `json:"stateDeltaHash"`
+}
+
+// TxEffectsProof proves that the effects of a tx are included in the tx effects root of a block.
+// Siblings are the hashes needed to compute the root from the leaf of the tx, bottom up.
+type TxEffectsProof struct {
+	BlockNumber uint64     `json:"blockNumber"`
+	Effects     *TxEffects `json:"effects"`
+	LeafIndex   uint64     `json:"leafIndex"`
+	NumLeaves   uint64     `json:"numLeaves"`
+	Siblings    [][]byte   `json:"siblings"`
+}
+
+// ComputeTxEffectsRoot computes the merkle root over the tx effects. The root is nil if there are no effects
+func ComputeTxEffectsRoot(hash HashFunc, effects []*TxEffects) []byte {
+	if len(effects) == 0 {
+		return nil
+	}
+	level := computeTxEffectsLeaves(hash, effects)
+	for len(level) > 1 {
+		level = computeNextTxEffectsLevel(hash, level)
+	}
+	return level[0]
+}
+
+// ComputeTxEffectsSiblings returns the siblings of the proof for the effects at the given index
+func ComputeTxEffectsSiblings(hash HashFunc, effects []*TxEffects, index int) [][]byte {
+	level := computeTxEffectsLeaves(hash, effects)
+	var siblings [][]byte
+	for len(level) > 1 {
+		if index%2 == 1 {
+			siblings = append(siblings, level[index-1])
+		} else if index+1 < len(level) {
+			siblings = append(siblings, level[index+1])
+		}
+		level = computeNextTxEffectsLevel(hash, level)
+		index /= 2
+	}
+	return siblings
+}
+
+// VerifyTxEffectsProof verifies that the effects in the proof are included in the tx effects root
+func VerifyTxEffectsProof(hash HashFunc, root []byte, proof *TxEffectsProof) error {
+	if proof == nil || proof.Effects == nil || proof.LeafIndex >= proof.NumLeaves {
+		return fmt.Errorf("Malformed tx effects proof")
+	}
+	nodeHash := computeTxEffectsLeafHash(hash, proof.Effects)
+	siblings := proof.Siblings
+	for index, numNodes := proof.LeafIndex, proof.NumLeaves; numNodes > 1; index, numNodes = index/2, (numNodes+1)/2 {
+		if index%2 == 0 && index+1 == numNodes {
+			// promoted to the next level as is
+			continue
+		}
+		if len(siblings) == 0 {
+			return fmt.Errorf("Tx effects proof has too few hashes")
+		}
+		if index%2 == 0 {
+			nodeHash = computeTxEffectsNodeHash(hash, nodeHash, siblings[0])
+		} else {
+			nodeHash = computeTxEffectsNodeHash(hash, siblings[0], nodeHash)
+		}
+		siblings = siblings[1:]
+	}
+	if len(siblings) != 0 {
+		return fmt.Errorf("Tx effects proof has too many hashes")
+	}
+	if !bytes.Equal(nodeHash, root) {
+		return fmt.Errorf("Tx effects of transaction [%s] are not included in the root [%x]", proof.Effects.TxUUID, root)
+	}
+	return nil
+}
+
+func computeTxEffectsLeaves(hash HashFunc, effects []*TxEffects) [][]byte {
+	leaves := make([][]byte, len(effects))
+	for i, e := range effects {
+		leaves[i] = computeTxEffectsLeafHash(hash, e)
+	}
+	return leaves
+}
+
+func computeNextTxEffectsLevel(hash HashFunc, level [][]byte) [][]byte {
+	next := make([][]byte, 0, (len(level)+1)/2)
+	for i := 0; i+1 < len(level); i += 2 {
+		next = append(next, computeTxEffectsNodeHash(hash, level[i], level[i+1]))
+	}
+	if len(level)%2 == 1 {
+		next = append(next, level[len(level)-1])
+	}
+	return next
+}
+
+func computeTxEffectsLeafHash(hash HashFunc, effects *TxEffects) []byte {
+	buffer := proto.NewBuffer([]byte{txEffectsLeafPrefix})
+	buffer.EncodeStringBytes(effects.TxUUID)
+	buffer.EncodeRawBytes(effects.StateDeltaHash)
+	return hash(buffer.Bytes())
+}
+
+func computeTxEffectsNodeHash(hash HashFunc, left []byte, right []byte) []byte {
+	buffer := proto.NewBuffer([]byte{txEffectsNodePrefix})
+	buffer.EncodeRawBytes(left)
+	buffer.EncodeRawBytes(right)
+	return hash(buffer.Bytes())
+}
@@ -0,0 +1,48 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package verify
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestTxEffectsProofs(t *testing.T) {
+	hash, _ := GetHashFunc(DefaultHashAlgorithm)
+	if ComputeTxEffectsRoot(hash, nil) != nil {
+		t.Fatalf("Expected a nil root for no effects")
+	}
+	for numLeaves := 1; numLeaves <= 9; numLeaves++ {
+		var effects []*TxEffects
+		for i := 0; i < numLeaves; i++ {
+			effects = append(effects, &TxEffects{fmt.Sprintf("tx%d", i), []byte{byte(i)}})
+		}
+		root := ComputeTxEffectsRoot(hash, effects)
+		for i := range effects {
+			proof := &TxEffectsProof{0, effects[i], uint64(i), uint64(numLeaves), ComputeTxEffectsSiblings(hash, effects, i)}
+			if err := VerifyTxEffectsProof(hash, root, proof); err != nil {
+				t.Fatalf("Error verifying the proof of leaf [%d] out of [%d]: %s", i, numLeaves, err)
+			}
+			proof.Effects = &TxEffects{effects[i].TxUUID, []byte("forged")}
+			if err := VerifyTxEffectsProof(hash, root, proof); err == nil {
+				t.Fatalf("Expected an error for forged effects of leaf [%d] out of [%d]", i, numLeaves)
+			}
+		}
+	}
+	if err := VerifyTxEffectsProof(hash, nil, &TxEffectsProof{LeafIndex: 1, NumLeaves: 1, Effects: &TxEffects{}}); err == nil {
+		t.Fatalf("Expected an error for a malformed proof")
+	}
+}
@@ -0,0 +1,182 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ledger
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+)
+
+// WriteOnceChaincodeID is the system namespace in which the write-once keys and key
+// prefixes are recorded. A write-once key can be set only if it has no value, i.e.,
+// once set, the key cannot be updated or deleted anymore.
+const WriteOnceChaincodeID = statemgmt.SystemNamespacePrefix + "_writeonce"
+
+// The keys are recorded as 'k<len(chaincodeID)>:<chaincodeID><key>' and the prefixes of
+// a chaincode are recorded all together under the key 'p<chaincodeID>'
+func writeOnceKeyRecord(chaincodeID string, key string) string {
+	return fmt.Sprintf("k%d:%s%s", len(chaincodeID), chaincodeID, key)
+}
+
+func writeOncePrefixesRecord(chaincodeID string) string {
+	return "p" + chaincodeID
+}
+
+// MarkWriteOnce marks the key of the chaincode as write-once. Similar to ActivateFeature,
+// this has to be invoked in the context of a transaction and requires the feature
+// FeatureKeyMetadata to be active.
+func (ledger *Ledger) MarkWriteOnce(chaincodeID string, key string) error {
+	if err := ledger.checkWriteOnceAllowed(chaincodeID); err != nil {
+		return err
+	}
+	return ledger.setSystemState(WriteOnceChaincodeID, writeOnceKeyRecord(chaincodeID, key), []byte{1})
+}
+
+// MarkWriteOncePrefix marks all the keys of the chaincode that start with the prefix as write-once.
+// See MarkWriteOnce for details.
+func (ledger *Ledger) MarkWriteOncePrefix(chaincodeID string, prefix string) error {
+	if err := ledger.checkWriteOnceAllowed(chaincodeID); err != nil {
+		return err
+	}
+	prefixes, err := getWriteOncePrefixes(ledger.uncommittedStateGetter(), chaincodeID)
+	if err != nil {
+		return err
+	}
+	for _, p := range prefixes {
+		if p == prefix {
+			return nil
+		}
+	}
+	prefixes = append(prefixes, prefix)
+	sort.Strings(prefixes)
+	buffer := proto.NewBuffer([]byte{})
+	buffer.EncodeVarint(uint64(len(prefixes)))
+	for _, p := range prefixes {
+		buffer.EncodeStringBytes(p)
+	}
+	return ledger.setSystemState(WriteOnceChaincodeID, writeOncePrefixesRecord(chaincodeID), buffer.Bytes())
+}
+
+// IsWriteOnce returns true if the key of the chaincode, or a prefix of the key, is marked as write-once
+func (ledger *Ledger) IsWriteOnce(chaincodeID string, key string, committed bool) (bool, error) {
+	get := func(chaincodeID string, key string) ([]byte, error) {
+		return ledger.state.Get(chaincodeID, key, committed)
+	}
+	prefixes, err := getWriteOncePrefixes(get, chaincodeID)
+	if err != nil {
+		return false, err
+	}
+	return isWriteOnce(get, chaincodeID, key, prefixes)
+}
+
+type stateGetter func(chaincodeID string, key string) ([]byte, error)
+
+func (ledger *Ledger) uncommittedStateGetter() stateGetter {
+	return func(chaincodeID string, key string) ([]byte, error) {
+		return ledger.state.Get(chaincodeID, key, false)
+	}
+}
+
+func isWriteOnce(get stateGetter, chaincodeID string, key string, prefixes []string) (bool, error) {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true, nil
+		}
+	}
+	mark, err := get(WriteOnceChaincodeID, writeOnceKeyRecord(chaincodeID, key))
+	if err != nil {
+		return false, err
+	}
+	return mark != nil, nil
+}
+
+func getWriteOncePrefixes(get stateGetter, chaincodeID string) ([]string, error) {
+	value, err := get(WriteOnceChaincodeID, writeOncePrefixesRecord(chaincodeID))
+	if err != nil || value == nil {
+		return nil, err
+	}
+	buffer := proto.NewBuffer(value)
+	numPrefixes, err := buffer.DecodeVarint()
+	if err != nil {
+		return nil, err
+	}
+	prefixes := make([]string, numPrefixes)
+	for i := range prefixes {
+		if prefixes[i], err = buffer.DecodeStringBytes(); err != nil {
+			return nil, err
+		}
+	}
+	return prefixes, nil
+}
+
+func (ledger *Ledger) checkWriteOnceAllowed(chaincodeID string) error {
+	if err := checkNotSystemNamespace(chaincodeID); err != nil {
+		return err
+	}
+	active, err := ledger.IsFeatureActive(FeatureKeyMetadata, ledger.GetBlockchainSize())
+	if err != nil {
+		return err
+	}
+	if !active {
+		return newLedgerError(ErrorTypeInvalidArgument, fmt.Sprintf("Ledger feature [%s] is not active", FeatureKeyMetadata))
+	}
+	return nil
+}
+
+// checkWriteOnceKeys returns an error if the write set updates or deletes a write-once key
+// that already has a value. The marks are evaluated as these were before the on-going tx so
+// that a tx can set the value of a key and mark it as write-once. The keys are evaluated in
+// sorted order so that all the peers report the same violation.
+func (ledger *Ledger) checkWriteOnceKeys(writeSet *statemgmt.StateDelta) error {
+	get := ledger.state.GetBeforeCurrentTx
+	for _, chaincodeID := range writeSet.GetUpdatedChaincodeIds(true) {
+		if statemgmt.IsSystemNamespace(chaincodeID) {
+			continue
+		}
+		prefixes, err := getWriteOncePrefixes(get, chaincodeID)
+		if err != nil {
+			return err
+		}
+		updates := writeSet.GetUpdates(chaincodeID)
+		var keys []string
+		for key := range updates {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			writeOnce, err := isWriteOnce(get, chaincodeID, key, prefixes)
+			if err != nil {
+				return err
+			}
+			if !writeOnce {
+				continue
+			}
+			previousValue, err := get(chaincodeID, key)
+			if err != nil {
+				return err
+			}
+			if previousValue != nil {
+				return fmt.Errorf("key [%s] of chaincode [%s] is write-once and already has a value", key, chaincodeID)
+			}
+		}
+	}
+	return nil
+}
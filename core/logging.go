@@ -17,8 +17,15 @@ limitations under the License.
 package core
 
 import (
+	"encoding/json"
+	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"runtime"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/op/go-logging"
 	"github.com/spf13/viper"
@@ -31,14 +38,43 @@ var loggingLogger = logging.MustGetLogger("logging")
 // case of configuration errors.
 var loggingDefaultLevel = logging.INFO
 
+// The formats of the log records, selected by 'logging.format'
+var loggingFormats = map[string]logging.Formatter{
+	"text": logging.MustStringFormatter(
+		"%{color}%{time:15:04:05.000} [%{module}] %{shortfunc} -> %{level:.4s} %{id:03x}%{color:reset} %{message}",
+	),
+	"json": &jsonFormatter{},
+}
+
+// The levels of the modules, shared by all the loggers of the peer
+var loggingLevels = &moduleLevels{levels: map[string]logging.Level{}}
+
 // LoggingInit is a 'hook' called at the beginning of command processing to
 // parse logging-related options specified either on the command-line or in
 // config files.  Command-line options take precedence over config file
 // options, and can also be passed as suitably-named environment variables. To
-// change module logging levels at runtime call `logging.SetLevel(level,
-// module)`.  To debug this routine include logging=debug as the first
-// term of the logging specification.
+// change module logging levels at runtime call `SetModuleLevel(module,
+// level)`, also exposed by the Admin service.  To debug this routine include
+// logging=debug as the first term of the logging specification.
 func LoggingInit(command string) {
+	if format := viper.GetString("logging.format"); format != "" {
+		if formatter, ok := loggingFormats[format]; ok {
+			loggingLevels.setBackend(logging.NewBackendFormatter(logging.NewLogBackend(os.Stderr, "", 0), formatter))
+		} else {
+			loggingLogger.Warning("Logging format '%s' not recognized, keeping text", format)
+		}
+	}
+	// The levels of 'logging.modules' apply to the modules and their submodules,
+	// e.g. consensus applies to consensus/obcpbft, unless the specification
+	// below overrides them
+	for module, name := range viper.GetStringMapString("logging.modules") {
+		if level, err := logging.LogLevel(name); err != nil {
+			loggingLogger.Warning("Invalid logging level '%s' of module '%s' ignored", name, module)
+		} else {
+			logging.SetLevel(level, module)
+			loggingLogger.Debug("Setting logging level for module '%s' to %s", module, level)
+		}
+	}
 	// Parse the logging specification in the form
 	//     [<module>[,<module>...]=]<level>[:[<module>[,<module>...]=]<level>...]
 	defaultLevel := loggingDefaultLevel
@@ -82,14 +118,123 @@ func LoggingInit(command string) {
 	loggingLogger.Debug("Setting default logging level to %s for command '%s'", defaultLevel, command)
 }
 
-// Initiate 'leveled' logging to stderr.
-func init() {
+// SetModuleLevel sets the logging level of the module and of its submodules
+// without a level of their own. The empty module sets the default level.
+func SetModuleLevel(module, name string) error {
+	level, err := logging.LogLevel(name)
+	if err != nil {
+		return fmt.Errorf("Invalid logging level '%s': %s", name, err)
+	}
+	logging.SetLevel(level, module)
+	loggingLogger.Info("Logging level for module '%s' set to %s", module, level)
+	return nil
+}
 
-	format := logging.MustStringFormatter(
-		"%{color}%{time:15:04:05.000} [%{module}] %{shortfunc} -> %{level:.4s} %{id:03x}%{color:reset} %{message}",
-	)
+// GetModuleLevels returns the default logging level and the levels of the
+// modules that have one of their own
+func GetModuleLevels() (string, map[string]string) {
+	return loggingLevels.GetLevel("").String(), loggingLevels.moduleLevels()
+}
 
-	backend := logging.NewLogBackend(os.Stderr, "", 0)
-	backendFormatter := logging.NewBackendFormatter(backend, format)
-	logging.SetBackend(backendFormatter).SetLevel(loggingDefaultLevel, "")
+// moduleLevels is the leveled backend of all the loggers. Unlike the one of
+// go-logging its levels can be changed while the peer logs, and the level of
+// a module applies to its submodules, the ones named '<module>/...'.
+type moduleLevels struct {
+	sync.RWMutex
+	levels  map[string]logging.Level
+	backend logging.Backend
+}
+
+func (l *moduleLevels) setBackend(backend logging.Backend) {
+	l.Lock()
+	defer l.Unlock()
+	l.backend = backend
+}
+
+// GetLevel returns the level of the module, or of its closest parent module
+// that has one, or the default level
+func (l *moduleLevels) GetLevel(module string) logging.Level {
+	l.RLock()
+	defer l.RUnlock()
+	for {
+		if level, ok := l.levels[module]; ok {
+			return level
+		}
+		i := strings.LastIndex(module, "/")
+		if i < 0 {
+			break
+		}
+		module = module[:i]
+	}
+	if level, ok := l.levels[""]; ok {
+		return level
+	}
+	return loggingDefaultLevel
+}
+
+func (l *moduleLevels) SetLevel(level logging.Level, module string) {
+	l.Lock()
+	defer l.Unlock()
+	l.levels[module] = level
+}
+
+func (l *moduleLevels) IsEnabledFor(level logging.Level, module string) bool {
+	return level <= l.GetLevel(module)
+}
+
+func (l *moduleLevels) Log(level logging.Level, calldepth int, rec *logging.Record) error {
+	if !l.IsEnabledFor(level, rec.Module) {
+		return nil
+	}
+	l.RLock()
+	backend := l.backend
+	l.RUnlock()
+	return backend.Log(level, calldepth+1, rec)
+}
+
+func (l *moduleLevels) moduleLevels() map[string]string {
+	l.RLock()
+	defer l.RUnlock()
+	levels := make(map[string]string, len(l.levels))
+	for module, level := range l.levels {
+		if module != "" {
+			levels[module] = level.String()
+		}
+	}
+	return levels
+}
+
+// jsonRecord is a log record in the JSON format, one object per line
+type jsonRecord struct {
+	Time    string `json:"time"`
+	Level   string `json:"level"`
+	Module  string `json:"module"`
+	Caller  string `json:"caller"`
+	ID      uint64 `json:"id"`
+	Message string `json:"message"`
+}
+
+// jsonFormatter formats the log records for the log collectors, such as
+// Logstash or Fluentd
+type jsonFormatter struct{}
+
+func (*jsonFormatter) Format(calldepth int, r *logging.Record, w io.Writer) error {
+	caller := "???"
+	if _, file, line, ok := runtime.Caller(calldepth + 1); ok {
+		caller = fmt.Sprintf("%s:%d", filepath.Base(file), line)
+	}
+	return json.NewEncoder(w).Encode(&jsonRecord{
+		Time:    r.Time.UTC().Format(time.RFC3339Nano),
+		Level:   r.Level.String(),
+		Module:  r.Module,
+		Caller:  caller,
+		ID:      r.Id,
+		Message: r.Message(),
+	})
+}
+
+// Initiate 'leveled' logging to stderr.
+func init() {
+	loggingLevels.setBackend(logging.NewBackendFormatter(logging.NewLogBackend(os.Stderr, "", 0), loggingFormats["text"]))
+	logging.SetBackend(loggingLevels).SetLevel(loggingDefaultLevel, "")
 }
@@ -0,0 +1,424 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package metrics holds the counters, gauges and histograms of the peer and serves them in
+// the Prometheus text exposition format. The metrics are created by the packages they
+// measure, as package variables, and registered in DefaultRegistry.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Collector is a metric of a registry
+type Collector interface {
+	// Name returns the name of the metric
+	Name() string
+	// write writes the samples of the metric in the text exposition format
+	write(w io.Writer)
+}
+
+// Registry holds metrics by name
+type Registry struct {
+	lock       sync.Mutex
+	collectors map[string]Collector
+}
+
+// DefaultRegistry holds the metrics created by the New* functions
+var DefaultRegistry = NewRegistry()
+
+// NewRegistry returns an empty registry
+func NewRegistry() *Registry {
+	return &Registry{collectors: make(map[string]Collector)}
+}
+
+// Register adds the metric to the registry, unless a metric with the same name is registered
+func (registry *Registry) Register(collector Collector) error {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+	if _, ok := registry.collectors[collector.Name()]; ok {
+		return fmt.Errorf("A metric named [%s] is already registered", collector.Name())
+	}
+	registry.collectors[collector.Name()] = collector
+	return nil
+}
+
+// MustRegister adds the metric to the registry and panics if a metric with the same name is
+// registered
+func (registry *Registry) MustRegister(collector Collector) {
+	if err := registry.Register(collector); err != nil {
+		panic(err)
+	}
+}
+
+// WriteText writes the samples of the metrics, sorted by name, in the text exposition format
+func (registry *Registry) WriteText(w io.Writer) error {
+	registry.lock.Lock()
+	names := make([]string, 0, len(registry.collectors))
+	for name := range registry.collectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	collectors := make([]Collector, len(names))
+	for i, name := range names {
+		collectors[i] = registry.collectors[name]
+	}
+	registry.lock.Unlock()
+
+	buffered := bufio.NewWriter(w)
+	for _, collector := range collectors {
+		collector.write(buffered)
+	}
+	return buffered.Flush()
+}
+
+// Handler returns the HTTP handler serving the metrics of the registry
+func (registry *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		registry.WriteText(rw)
+	})
+}
+
+// Handler returns the HTTP handler serving the metrics of DefaultRegistry
+func Handler() http.Handler {
+	return DefaultRegistry.Handler()
+}
+
+// Counter is a value that only goes up
+type Counter struct {
+	lock  sync.Mutex
+	value float64
+}
+
+// Inc adds 1 to the counter
+func (counter *Counter) Inc() {
+	counter.Add(1)
+}
+
+// Add adds v, which must not be negative, to the counter
+func (counter *Counter) Add(v float64) {
+	counter.lock.Lock()
+	defer counter.lock.Unlock()
+	counter.value += v
+}
+
+func (counter *Counter) write(w io.Writer, name string, labels []labelPair) {
+	counter.lock.Lock()
+	defer counter.lock.Unlock()
+	writeSample(w, name, labels, counter.value)
+}
+
+// Gauge is a value that goes up and down
+type Gauge struct {
+	lock  sync.Mutex
+	value float64
+}
+
+// Set sets the value of the gauge
+func (gauge *Gauge) Set(v float64) {
+	gauge.lock.Lock()
+	defer gauge.lock.Unlock()
+	gauge.value = v
+}
+
+// Add adds v, possibly negative, to the gauge
+func (gauge *Gauge) Add(v float64) {
+	gauge.lock.Lock()
+	defer gauge.lock.Unlock()
+	gauge.value += v
+}
+
+func (gauge *Gauge) write(w io.Writer, name string, labels []labelPair) {
+	gauge.lock.Lock()
+	defer gauge.lock.Unlock()
+	writeSample(w, name, labels, gauge.value)
+}
+
+// DefBuckets are the upper bounds, in seconds, of the buckets of the histograms of the
+// latencies of network or disk operations
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// ExponentialBuckets returns count upper bounds, the first one being start and each other
+// being factor times the previous one
+func ExponentialBuckets(start, factor float64, count int) []float64 {
+	buckets := make([]float64, count)
+	for i := range buckets {
+		buckets[i] = start
+		start *= factor
+	}
+	return buckets
+}
+
+// Histogram counts the observed values in buckets
+type Histogram struct {
+	lock        sync.Mutex
+	upperBounds []float64
+	counts      []uint64
+	sum         float64
+	count       uint64
+}
+
+func newHistogram(upperBounds []float64) *Histogram {
+	return &Histogram{upperBounds: upperBounds, counts: make([]uint64, len(upperBounds))}
+}
+
+// Observe adds the value to the histogram
+func (histogram *Histogram) Observe(v float64) {
+	histogram.lock.Lock()
+	defer histogram.lock.Unlock()
+	for i, upperBound := range histogram.upperBounds {
+		if v <= upperBound {
+			histogram.counts[i]++
+			break
+		}
+	}
+	histogram.sum += v
+	histogram.count++
+}
+
+// ObserveSince adds the seconds elapsed since start to the histogram. It is meant to be
+// deferred as in defer histogram.ObserveSince(time.Now())
+func (histogram *Histogram) ObserveSince(start time.Time) {
+	histogram.Observe(time.Since(start).Seconds())
+}
+
+func (histogram *Histogram) write(w io.Writer, name string, labels []labelPair) {
+	histogram.lock.Lock()
+	defer histogram.lock.Unlock()
+	// the buckets are cumulative in the exposition format
+	var cumulative uint64
+	for i, upperBound := range histogram.upperBounds {
+		cumulative += histogram.counts[i]
+		writeSample(w, name+"_bucket", append(labels, labelPair{"le", formatFloat(upperBound)}), float64(cumulative))
+	}
+	writeSample(w, name+"_bucket", append(labels, labelPair{"le", "+Inf"}), float64(histogram.count))
+	writeSample(w, name+"_sum", labels, histogram.sum)
+	writeSample(w, name+"_count", labels, float64(histogram.count))
+}
+
+// child is a metric of a family with given values of the labels
+type child interface {
+	write(w io.Writer, name string, labels []labelPair)
+}
+
+// family is a metric with a child per combination of the values of its labels
+type family struct {
+	name       string
+	help       string
+	metricType string
+	labelNames []string
+	newChild   func() child
+
+	lock        sync.Mutex
+	children    map[string]child
+	labelValues map[string][]string
+}
+
+func newFamily(name, help, metricType string, labelNames []string, newChild func() child) *family {
+	f := &family{name: name, help: help, metricType: metricType, labelNames: labelNames, newChild: newChild,
+		children: make(map[string]child), labelValues: make(map[string][]string)}
+	DefaultRegistry.MustRegister(f)
+	return f
+}
+
+func (f *family) Name() string {
+	return f.name
+}
+
+func (f *family) with(labelValues []string) child {
+	if len(labelValues) != len(f.labelNames) {
+		panic(fmt.Errorf("Metric [%s] has labels %v, got values %v", f.name, f.labelNames, labelValues))
+	}
+	key := strings.Join(labelValues, "\xff")
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	c, ok := f.children[key]
+	if !ok {
+		c = f.newChild()
+		f.children[key] = c
+		f.labelValues[key] = append([]string(nil), labelValues...)
+	}
+	return c
+}
+
+func (f *family) write(w io.Writer) {
+	f.lock.Lock()
+	keys := make([]string, 0, len(f.children))
+	for key := range f.children {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	children := make([]child, len(keys))
+	labels := make([][]labelPair, len(keys))
+	for i, key := range keys {
+		children[i] = f.children[key]
+		labels[i] = makeLabels(f.labelNames, f.labelValues[key])
+	}
+	f.lock.Unlock()
+
+	writeHeader(w, f.name, f.help, f.metricType)
+	for i, c := range children {
+		c.write(w, f.name, labels[i])
+	}
+}
+
+// CounterVec is a counter per combination of the values of its labels
+type CounterVec struct {
+	*family
+}
+
+// NewCounterVec returns a counter with the labels, registered in DefaultRegistry
+func NewCounterVec(name, help string, labelNames ...string) *CounterVec {
+	return &CounterVec{newFamily(name, help, "counter", labelNames, func() child { return &Counter{} })}
+}
+
+// With returns the counter of the values of the labels, in the order of their names
+func (v *CounterVec) With(labelValues ...string) *Counter {
+	return v.with(labelValues).(*Counter)
+}
+
+// NewCounter returns a counter without labels, registered in DefaultRegistry
+func NewCounter(name, help string) *Counter {
+	return NewCounterVec(name, help).With()
+}
+
+// GaugeVec is a gauge per combination of the values of its labels
+type GaugeVec struct {
+	*family
+}
+
+// NewGaugeVec returns a gauge with the labels, registered in DefaultRegistry
+func NewGaugeVec(name, help string, labelNames ...string) *GaugeVec {
+	return &GaugeVec{newFamily(name, help, "gauge", labelNames, func() child { return &Gauge{} })}
+}
+
+// With returns the gauge of the values of the labels, in the order of their names
+func (v *GaugeVec) With(labelValues ...string) *Gauge {
+	return v.with(labelValues).(*Gauge)
+}
+
+// NewGauge returns a gauge without labels, registered in DefaultRegistry
+func NewGauge(name, help string) *Gauge {
+	return NewGaugeVec(name, help).With()
+}
+
+// HistogramVec is a histogram per combination of the values of its labels
+type HistogramVec struct {
+	*family
+}
+
+// NewHistogramVec returns a histogram with the buckets, given by their increasing upper
+// bounds, and the labels, registered in DefaultRegistry
+func NewHistogramVec(name, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	return &HistogramVec{newFamily(name, help, "histogram", labelNames, func() child { return newHistogram(buckets) })}
+}
+
+// With returns the histogram of the values of the labels, in the order of their names
+func (v *HistogramVec) With(labelValues ...string) *Histogram {
+	return v.with(labelValues).(*Histogram)
+}
+
+// NewHistogram returns a histogram without labels, registered in DefaultRegistry
+func NewHistogram(name, help string, buckets []float64) *Histogram {
+	return NewHistogramVec(name, help, buckets).With()
+}
+
+// Sample is a value of a GaugeFunc with the values of its labels
+type Sample struct {
+	LabelValues []string
+	Value       float64
+}
+
+// GaugeFunc is a gauge whose values are read when the metrics are served, for the values
+// that are already maintained elsewhere, such as the properties of RocksDB
+type GaugeFunc struct {
+	name       string
+	help       string
+	labelNames []string
+	samples    func() []Sample
+}
+
+// NewGaugeFunc returns a gauge with the labels whose samples are returned by the function,
+// registered in DefaultRegistry
+func NewGaugeFunc(name, help string, labelNames []string, samples func() []Sample) *GaugeFunc {
+	gauge := &GaugeFunc{name, help, labelNames, samples}
+	DefaultRegistry.MustRegister(gauge)
+	return gauge
+}
+
+// Name returns the name of the gauge
+func (gauge *GaugeFunc) Name() string {
+	return gauge.name
+}
+
+func (gauge *GaugeFunc) write(w io.Writer) {
+	writeHeader(w, gauge.name, gauge.help, "gauge")
+	for _, sample := range gauge.samples() {
+		writeSample(w, gauge.name, makeLabels(gauge.labelNames, sample.LabelValues), sample.Value)
+	}
+}
+
+type labelPair struct {
+	name  string
+	value string
+}
+
+func makeLabels(names []string, values []string) []labelPair {
+	labels := make([]labelPair, len(names))
+	for i, name := range names {
+		labels[i] = labelPair{name, values[i]}
+	}
+	return labels
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func writeHeader(w io.Writer, name, help, metricType string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, metricType)
+}
+
+func writeSample(w io.Writer, name string, labels []labelPair, value float64) {
+	io.WriteString(w, name)
+	if len(labels) > 0 {
+		io.WriteString(w, "{")
+		for i, label := range labels {
+			if i > 0 {
+				io.WriteString(w, ",")
+			}
+			fmt.Fprintf(w, `%s="%s"`, label.name, labelValueEscaper.Replace(label.value))
+		}
+		io.WriteString(w, "}")
+	}
+	fmt.Fprintf(w, " %s\n", formatFloat(value))
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
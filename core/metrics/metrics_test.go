@@ -0,0 +1,88 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCounterVec(t *testing.T) {
+	requests := NewCounterVec("test_requests_total", "Requests.", "method", "code")
+	requests.With("Get", "OK").Inc()
+	requests.With("Get", "OK").Add(2)
+	requests.With("Put", "Unknown \"error\"").Inc()
+	var buffer bytes.Buffer
+	requests.write(&buffer)
+	expected := `# HELP test_requests_total Requests.
+# TYPE test_requests_total counter
+test_requests_total{method="Get",code="OK"} 3
+test_requests_total{method="Put",code="Unknown \"error\""} 1
+`
+	if buffer.String() != expected {
+		t.Fatalf("Expected\n%s\ngot\n%s", expected, buffer.String())
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	latency := NewHistogram("test_latency_seconds", "Latency.", []float64{0.1, 1})
+	latency.Observe(0.05)
+	latency.Observe(0.5)
+	latency.Observe(0.5)
+	latency.Observe(3)
+	var buffer bytes.Buffer
+	DefaultRegistry.collectors["test_latency_seconds"].write(&buffer)
+	expected := `# HELP test_latency_seconds Latency.
+# TYPE test_latency_seconds histogram
+test_latency_seconds_bucket{le="0.1"} 1
+test_latency_seconds_bucket{le="1"} 3
+test_latency_seconds_bucket{le="+Inf"} 4
+test_latency_seconds_sum 4.05
+test_latency_seconds_count 4
+`
+	if buffer.String() != expected {
+		t.Fatalf("Expected\n%s\ngot\n%s", expected, buffer.String())
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry()
+	gauge := &GaugeFunc{"test_backlog", "Backlog.", nil, func() []Sample { return []Sample{{nil, 7}} }}
+	registry.MustRegister(gauge)
+	if err := registry.Register(gauge); err == nil {
+		t.Fatal("Expected an error registering a metric twice")
+	}
+	req, err := http.NewRequest("GET", "/metrics", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder := httptest.NewRecorder()
+	registry.Handler().ServeHTTP(recorder, req)
+	if !strings.Contains(recorder.Body.String(), "\ntest_backlog 7\n") {
+		t.Fatalf("Unexpected metrics\n%s", recorder.Body.String())
+	}
+}
+
+func TestExponentialBuckets(t *testing.T) {
+	buckets := ExponentialBuckets(0.001, 10, 3)
+	if len(buckets) != 3 || buckets[0] != 0.001 || buckets[2] != 0.1 {
+		t.Fatalf("Unexpected buckets %v", buckets)
+	}
+}
@@ -32,6 +32,7 @@ package peer
 import (
 	"fmt"
 	"net"
+	"time"
 
 	"github.com/spf13/viper"
 
@@ -51,8 +52,15 @@ var peerEndpointError error
 
 // Cached values of commonly used configuration constants.
 var syncStateSnapshotChannelSize int
+var syncStateSnapshotChunkSize int
+var syncStateSnapshotResumeWindow int
+var syncStateSnapshotRetention time.Duration
+var syncStateSnapshotMaxBytesPerSecond int
 var syncStateDeltasChannelSize int
+var syncStateBucketsChannelSize int
 var syncBlocksChannelSize int
+var syncBlocksBatchSize int
+var syncStateCompressions []pb.SyncCompression
 var validatorEnabled bool
 
 // Note: There is some kind of circular import issue that prevents us from
@@ -115,8 +123,24 @@ func CacheConfiguration() (err error) {
 	validatorStreamAddress = getValidatorStreamAddress()
 
 	syncStateSnapshotChannelSize = viper.GetInt("peer.sync.state.snapshot.channelSize")
+	syncStateSnapshotChunkSize = viper.GetInt("peer.sync.state.snapshot.chunkSize")
+	if syncStateSnapshotChunkSize < 1 {
+		syncStateSnapshotChunkSize = 1
+	}
+	syncStateSnapshotResumeWindow = viper.GetInt("peer.sync.state.snapshot.resumeWindow")
+	syncStateSnapshotRetention = viper.GetDuration("peer.sync.state.snapshot.retention")
+	syncStateSnapshotMaxBytesPerSecond = viper.GetInt("peer.sync.state.snapshot.maxBytesPerSecond")
 	syncStateDeltasChannelSize = viper.GetInt("peer.sync.state.deltas.channelSize")
+	syncStateBucketsChannelSize = viper.GetInt("peer.sync.state.buckets.channelSize")
+	if syncStateBucketsChannelSize < 1 {
+		syncStateBucketsChannelSize = syncStateSnapshotChannelSize
+	}
 	syncBlocksChannelSize = viper.GetInt("peer.sync.blocks.channelSize")
+	syncBlocksBatchSize = viper.GetInt("peer.sync.blocks.batchSize")
+	if syncBlocksBatchSize < 1 {
+		syncBlocksBatchSize = 1
+	}
+	syncStateCompressions = parseSyncCompressions(viper.GetStringSlice("peer.sync.state.compression"))
 	validatorEnabled = viper.GetBool("peer.validator.enabled")
 
 	securityEnabled = viper.GetBool("security.enabled")
@@ -171,6 +195,38 @@ func SyncStateSnapshotChannelSize() int {
 	return syncStateSnapshotChannelSize
 }
 
+// SyncStateSnapshotChunkSize returns the peer.sync.state.snapshot.chunkSize property
+func SyncStateSnapshotChunkSize() int {
+	if !configurationCached {
+		cacheConfiguration()
+	}
+	return syncStateSnapshotChunkSize
+}
+
+// SyncStateSnapshotResumeWindow returns the peer.sync.state.snapshot.resumeWindow property
+func SyncStateSnapshotResumeWindow() int {
+	if !configurationCached {
+		cacheConfiguration()
+	}
+	return syncStateSnapshotResumeWindow
+}
+
+// SyncStateSnapshotRetention returns the peer.sync.state.snapshot.retention property
+func SyncStateSnapshotRetention() time.Duration {
+	if !configurationCached {
+		cacheConfiguration()
+	}
+	return syncStateSnapshotRetention
+}
+
+// SyncStateSnapshotMaxBytesPerSecond returns the peer.sync.state.snapshot.maxBytesPerSecond property
+func SyncStateSnapshotMaxBytesPerSecond() int {
+	if !configurationCached {
+		cacheConfiguration()
+	}
+	return syncStateSnapshotMaxBytesPerSecond
+}
+
 // SyncStateDeltasChannelSize returns the peer.sync.state.deltas.channelSize property
 func SyncStateDeltasChannelSize() int {
 	if !configurationCached {
@@ -179,6 +235,14 @@ func SyncStateDeltasChannelSize() int {
 	return syncStateDeltasChannelSize
 }
 
+// SyncStateBucketsChannelSize returns the peer.sync.state.buckets.channelSize property
+func SyncStateBucketsChannelSize() int {
+	if !configurationCached {
+		cacheConfiguration()
+	}
+	return syncStateBucketsChannelSize
+}
+
 // SyncBlocksChannelSize returns the peer.sync.blocks.channelSize property
 func SyncBlocksChannelSize() int {
 	if !configurationCached {
@@ -187,6 +251,23 @@ func SyncBlocksChannelSize() int {
 	return syncBlocksChannelSize
 }
 
+// SyncBlocksBatchSize returns the peer.sync.blocks.batchSize property
+func SyncBlocksBatchSize() int {
+	if !configurationCached {
+		cacheConfiguration()
+	}
+	return syncBlocksBatchSize
+}
+
+// SyncStateCompressions returns the codecs listed in the peer.sync.state.compression
+// property which are available on this peer, in order of preference
+func SyncStateCompressions() []pb.SyncCompression {
+	if !configurationCached {
+		cacheConfiguration()
+	}
+	return syncStateCompressions
+}
+
 // ValidatorEnabled returns the peer.validator.enabled property
 func ValidatorEnabled() bool {
 	if !configurationCached {
@@ -0,0 +1,281 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package peer
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// DiscoveryManager interface for managing at runtime the peers this peer connects to
+type DiscoveryManager interface {
+	GetDiscoveryList() *pb.DiscoveryList
+	AddPeer(address string) error
+	RemovePeer(address string) error
+	BanPeer(address string) error
+}
+
+// discoveryListStore records the discovery list across restarts, implemented by the ledger
+type discoveryListStore interface {
+	GetDiscoveryList() (*pb.DiscoveryList, error)
+	PutDiscoveryList(list *pb.DiscoveryList) error
+}
+
+// discoveryList keeps the addresses of the peers this peer reconnects to when it gets
+// disconnected from them, and of the ones it bans. The list is recorded in the store, if
+// any, on every change so that the peer reconnects to the same peers after a restart
+type discoveryList struct {
+	sync.Mutex
+	store            discoveryListStore
+	addresses        map[string]bool
+	banned           map[string]bool
+	bannedIdentities map[string]string // identity of a banned peer to its banned address
+	connecting       map[string]bool   // addresses a chatWithPeer loop runs for
+}
+
+func newDiscoveryListFromConfig(store discoveryListStore) (*discoveryList, error) {
+	if !viper.GetBool("peer.discovery.persist") {
+		store = nil
+	}
+	return newDiscoveryList(store)
+}
+
+func newDiscoveryList(store discoveryListStore) (*discoveryList, error) {
+	l := &discoveryList{
+		store:            store,
+		addresses:        make(map[string]bool),
+		banned:           make(map[string]bool),
+		bannedIdentities: make(map[string]string),
+		connecting:       make(map[string]bool),
+	}
+	if store == nil {
+		return l, nil
+	}
+	list, err := store.GetDiscoveryList()
+	if err != nil {
+		return nil, fmt.Errorf("Error loading the discovery list: %s", err)
+	}
+	if list == nil {
+		return l, nil
+	}
+	for _, address := range list.Addresses {
+		l.addresses[address] = true
+	}
+	for _, address := range list.Banned {
+		l.banned[address] = true
+	}
+	for _, banned := range list.BannedIdentities {
+		l.bannedIdentities[banned.Identity] = banned.Address
+	}
+	return l, nil
+}
+
+// add adds address to the list unless it is banned, returns whether it is in the list
+func (l *discoveryList) add(address string) (bool, error) {
+	l.Lock()
+	defer l.Unlock()
+	if l.banned[address] {
+		return false, nil
+	}
+	if l.addresses[address] {
+		return true, nil
+	}
+	l.addresses[address] = true
+	return true, l.persist()
+}
+
+func (l *discoveryList) remove(address string) error {
+	l.Lock()
+	defer l.Unlock()
+	delete(l.addresses, address)
+	return l.persist()
+}
+
+func (l *discoveryList) ban(address string) error {
+	l.Lock()
+	defer l.Unlock()
+	delete(l.addresses, address)
+	l.banned[address] = true
+	return l.persist()
+}
+
+func (l *discoveryList) unban(address string) error {
+	l.Lock()
+	defer l.Unlock()
+	if !l.banned[address] {
+		return nil
+	}
+	delete(l.banned, address)
+	for identity, bannedAddress := range l.bannedIdentities {
+		if bannedAddress == address {
+			delete(l.bannedIdentities, identity)
+		}
+	}
+	return l.persist()
+}
+
+// banIdentity bans the identity of the peer banned by its address, until the address is
+// unbanned
+func (l *discoveryList) banIdentity(identity, address string) error {
+	l.Lock()
+	defer l.Unlock()
+	if _, ok := l.bannedIdentities[identity]; ok {
+		return nil
+	}
+	l.bannedIdentities[identity] = address
+	return l.persist()
+}
+
+func (l *discoveryList) isBannedIdentity(identity string) bool {
+	l.Lock()
+	defer l.Unlock()
+	_, ok := l.bannedIdentities[identity]
+	return ok
+}
+
+func (l *discoveryList) contains(address string) bool {
+	l.Lock()
+	defer l.Unlock()
+	return l.addresses[address]
+}
+
+func (l *discoveryList) isBanned(address string) bool {
+	l.Lock()
+	defer l.Unlock()
+	return l.banned[address]
+}
+
+// startConnecting returns false if a chatWithPeer loop already runs for address
+func (l *discoveryList) startConnecting(address string) bool {
+	l.Lock()
+	defer l.Unlock()
+	if l.connecting[address] {
+		return false
+	}
+	l.connecting[address] = true
+	return true
+}
+
+func (l *discoveryList) stopConnecting(address string) {
+	l.Lock()
+	defer l.Unlock()
+	delete(l.connecting, address)
+}
+
+// list returns the addresses of the list and the banned ones, sorted
+func (l *discoveryList) list() *pb.DiscoveryList {
+	l.Lock()
+	defer l.Unlock()
+	return l.toMessage()
+}
+
+func (l *discoveryList) toMessage() *pb.DiscoveryList {
+	list := &pb.DiscoveryList{}
+	for address := range l.addresses {
+		list.Addresses = append(list.Addresses, address)
+	}
+	for address := range l.banned {
+		list.Banned = append(list.Banned, address)
+	}
+	identities := make([]string, 0, len(l.bannedIdentities))
+	for identity := range l.bannedIdentities {
+		identities = append(identities, identity)
+	}
+	sort.Strings(list.Addresses)
+	sort.Strings(list.Banned)
+	sort.Strings(identities)
+	for _, identity := range identities {
+		list.BannedIdentities = append(list.BannedIdentities, &pb.DiscoveryList_BannedIdentity{Identity: identity, Address: l.bannedIdentities[identity]})
+	}
+	return list
+}
+
+// persist records the list in the store, called with the lock held
+func (l *discoveryList) persist() error {
+	if l.store == nil {
+		return nil
+	}
+	if err := l.store.PutDiscoveryList(l.toMessage()); err != nil {
+		return fmt.Errorf("Error recording the discovery list: %s", err)
+	}
+	return nil
+}
+
+func validatePeerAddress(address string) error {
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		return fmt.Errorf("Invalid peer address %s: %s", address, err)
+	}
+	return nil
+}
+
+// reconnectBackoff computes the delays between the attempts to connect to a peer, which
+// grow exponentially up to a maximum. Each delay is randomized by a jitter so that the
+// peers which lost the same peer do not all attempt to reconnect to it at once
+type reconnectBackoff struct {
+	initial    time.Duration
+	max        time.Duration
+	multiplier float64
+	jitter     float64
+	delay      time.Duration
+}
+
+func newReconnectBackoffFromConfig() *reconnectBackoff {
+	return newReconnectBackoff(
+		viper.GetDuration("peer.discovery.reconnect.initial"),
+		viper.GetDuration("peer.discovery.reconnect.max"),
+		viper.GetFloat64("peer.discovery.reconnect.multiplier"),
+		viper.GetFloat64("peer.discovery.reconnect.jitter"))
+}
+
+func newReconnectBackoff(initial, max time.Duration, multiplier, jitter float64) *reconnectBackoff {
+	if initial <= 0 {
+		initial = time.Second
+	}
+	if max < initial {
+		max = initial
+	}
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	if jitter < 0 || jitter > 1 {
+		jitter = 0
+	}
+	return &reconnectBackoff{initial: initial, max: max, multiplier: multiplier, jitter: jitter}
+}
+
+// next returns the delay before the next attempt
+func (b *reconnectBackoff) next() time.Duration {
+	if b.delay == 0 {
+		b.delay = b.initial
+	} else if b.delay = time.Duration(float64(b.delay) * b.multiplier); b.delay > b.max {
+		b.delay = b.max
+	}
+	return time.Duration(float64(b.delay) * (1 + b.jitter*(2*rand.Float64()-1)))
+}
+
+// reset starts the delays over, once connected
+func (b *reconnectBackoff) reset() {
+	b.delay = 0
+}
@@ -0,0 +1,152 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package peer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/context"
+
+	"github.com/hyperledger/fabric/core/ledger/testutil"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+type discoveryListTestStore struct {
+	value []byte
+}
+
+func (s *discoveryListTestStore) GetDiscoveryList() (*pb.DiscoveryList, error) {
+	if s.value == nil {
+		return nil, nil
+	}
+	list := &pb.DiscoveryList{}
+	return list, proto.Unmarshal(s.value, list)
+}
+
+func (s *discoveryListTestStore) PutDiscoveryList(list *pb.DiscoveryList) (err error) {
+	s.value, err = proto.Marshal(list)
+	return err
+}
+
+func TestDiscoveryList(t *testing.T) {
+	store := &discoveryListTestStore{}
+	l, err := newDiscoveryList(store)
+	testutil.AssertNoError(t, err, "Error creating discovery list")
+	for _, address := range []string{"peer2:30303", "peer1:30303", "peer3:30303", "peer1:30303"} {
+		added, err := l.add(address)
+		testutil.AssertNoError(t, err, "Error adding peer address")
+		testutil.AssertEquals(t, added, true)
+	}
+	testutil.AssertNoError(t, l.remove("peer2:30303"), "Error removing peer address")
+	testutil.AssertNoError(t, l.ban("peer3:30303"), "Error banning peer address")
+	testutil.AssertEquals(t, l.contains("peer3:30303"), false)
+	testutil.AssertEquals(t, l.isBanned("peer3:30303"), true)
+
+	// a banned address is not added back when discovered again
+	added, err := l.add("peer3:30303")
+	testutil.AssertNoError(t, err, "Error adding peer address")
+	testutil.AssertEquals(t, added, false)
+	expected := &pb.DiscoveryList{Addresses: []string{"peer1:30303"}, Banned: []string{"peer3:30303"}}
+	testutil.AssertEquals(t, l.list(), expected)
+
+	// the list is loaded back after a restart
+	l, err = newDiscoveryList(store)
+	testutil.AssertNoError(t, err, "Error loading discovery list")
+	testutil.AssertEquals(t, l.list(), expected)
+	testutil.AssertNoError(t, l.unban("peer3:30303"), "Error unbanning peer address")
+	added, err = l.add("peer3:30303")
+	testutil.AssertNoError(t, err, "Error adding peer address")
+	testutil.AssertEquals(t, added, true)
+	testutil.AssertEquals(t, l.list(), &pb.DiscoveryList{Addresses: []string{"peer1:30303", "peer3:30303"}})
+
+	// a single chatWithPeer loop runs per address
+	testutil.AssertEquals(t, l.startConnecting("peer1:30303"), true)
+	testutil.AssertEquals(t, l.startConnecting("peer1:30303"), false)
+	l.stopConnecting("peer1:30303")
+	testutil.AssertEquals(t, l.startConnecting("peer1:30303"), true)
+}
+
+func TestReconnectBackoff(t *testing.T) {
+	b := newReconnectBackoff(time.Second, 10*time.Second, 2, 0.2)
+	for i, expected := range []time.Duration{1, 2, 4, 8, 10, 10} {
+		delay := b.next()
+		if delay < expected*800*time.Millisecond || delay > expected*1200*time.Millisecond {
+			t.Fatalf("Expected delay %d to be %ds +/- 20%%, got %s", i, expected, delay)
+		}
+	}
+	b.reset()
+	if delay := b.next(); delay < 800*time.Millisecond || delay > 1200*time.Millisecond {
+		t.Fatalf("Expected the delay to start over from 1s after a reset, got %s", delay)
+	}
+
+	// the defaults retry every second
+	b = newReconnectBackoff(0, 0, 0, 0)
+	for i := 0; i < 3; i++ {
+		testutil.AssertEquals(t, b.next(), time.Second)
+	}
+}
+
+func TestBannedPeerCannotReRegister(t *testing.T) {
+	wasEnabled := SecurityEnabled()
+	defer func() { securityEnabled = wasEnabled }()
+	newPeer := func() *PeerImpl {
+		l, err := newDiscoveryList(nil)
+		testutil.AssertNoError(t, err, "Error creating discovery list")
+		return &PeerImpl{handlerMap: &handlerMap{m: make(map[pb.PeerID]MessageHandler)}, discovery: l}
+	}
+	newHandler := func(name, address string, pkiID string) *Handler {
+		return &Handler{ToPeerEndpoint: &pb.PeerEndpoint{ID: &pb.PeerID{Name: name}, Address: address, PkiID: []byte(pkiID)}}
+	}
+
+	// with security, the PKI-ID proven by the HELLO of the peer is banned
+	securityEnabled = true
+	p := newPeer()
+	testutil.AssertNoError(t, p.RegisterHandler(newHandler("vp1", "peer1:30303", "vp1-pkiID")), "Error registering handler")
+	testutil.AssertNoError(t, p.BanPeer("peer1:30303"), "Error banning peer")
+	delete(p.handlerMap.m, pb.PeerID{Name: "vp1"})
+	testutil.AssertError(t, p.RegisterHandler(newHandler("vp1", "peer9:30303", "vp1-pkiID")), "Expected banned peer to be refused under a new address")
+	testutil.AssertError(t, p.RegisterHandler(newHandler("vp9", "peer9:30303", "vp1-pkiID")), "Expected banned peer to be refused under a new ID")
+	testutil.AssertNoError(t, p.RegisterHandler(newHandler("vp2", "peer2:30303", "vp2-pkiID")), "Error registering handler")
+
+	// a peer banned while disconnected is banned by its PKI-ID once it advertises the address
+	testutil.AssertNoError(t, p.BanPeer("peer3:30303"), "Error banning peer")
+	testutil.AssertError(t, p.RegisterHandler(newHandler("vp3", "peer3:30303", "vp3-pkiID")), "Expected banned address to be refused")
+	testutil.AssertError(t, p.RegisterHandler(newHandler("vp3", "peer8:30303", "vp3-pkiID")), "Expected banned peer to be refused under a new address")
+
+	// lifting the ban of the address lifts the one of the identity
+	testutil.AssertNoError(t, p.discovery.unban("peer3:30303"), "Error unbanning peer")
+	testutil.AssertNoError(t, p.RegisterHandler(newHandler("vp3", "peer8:30303", "vp3-pkiID")), "Error registering handler")
+
+	// without security, the host the peer connects from is banned
+	securityEnabled = false
+	p = newPeer()
+	p.handlerFactory = func(MessageHandlerCoordinator, ChatStream, bool, MessageHandler) (MessageHandler, error) {
+		t.Fatal("Handler created for a banned peer")
+		return nil, nil
+	}
+	testutil.AssertNoError(t, p.BanPeer("10.0.0.1:30303"), "Error banning peer")
+	testutil.AssertError(t, p.handleChat(context.Background(), nil, false, "10.0.0.1:52817"), "Expected banned host to be refused")
+	// a peer banned by an address which is not the one it connects from is banned by its host
+	// once it advertises the address
+	testutil.AssertNoError(t, p.BanPeer("peer5:30303"), "Error banning peer")
+	testutil.AssertEquals(t, p.isBanned(&pb.PeerEndpoint{Address: "peer5:30303"}, "10.0.0.2:52817", true), true)
+	testutil.AssertEquals(t, p.isBanned(&pb.PeerEndpoint{Address: "peer6:30303"}, "10.0.0.2:52818", true), true)
+	testutil.AssertError(t, p.handleChat(context.Background(), nil, false, "10.0.0.2:52819"), "Expected banned host to be refused")
+	testutil.AssertEquals(t, p.isBanned(&pb.PeerEndpoint{Address: "peer7:30303"}, "10.0.0.3:52817", true), false)
+}
@@ -0,0 +1,470 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package peer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/spf13/viper"
+
+	"github.com/hyperledger/fabric/core/crypto"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+	"github.com/hyperledger/fabric/core/util"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// gossipStack is the part of the peer the gossip of the blocks relies on
+type gossipStack interface {
+	BlockChainAccessor
+	BlockChainModifier
+	BlockChainUtil
+	GetStateDelta(blockNumber uint64) (*statemgmt.StateDelta, error)
+	GetPeers() (*pb.PeersMessage, error)
+	Unicast(*pb.Message, *pb.PeerID) error
+}
+
+// gossipAuthenticator signs the blocks a validating peer gossips, and verifies that a
+// gossiped block has been signed by a validating peer
+type gossipAuthenticator interface {
+	sign(message []byte) (originPkiID []byte, signature []byte, err error)
+	verify(originPkiID []byte, signature []byte, message []byte) error
+}
+
+// secHelperAuthenticator authenticates the gossiped blocks with the enrollment certificates
+// of the peers
+type secHelperAuthenticator struct {
+	secHelper crypto.Peer
+}
+
+func (a *secHelperAuthenticator) sign(message []byte) ([]byte, []byte, error) {
+	signature, err := a.secHelper.Sign(message)
+	return a.secHelper.GetID(), signature, err
+}
+
+func (a *secHelperAuthenticator) verify(originPkiID []byte, signature []byte, message []byte) error {
+	return a.secHelper.VerifyValidator(originPkiID, signature, message)
+}
+
+// gossipSignature is the origin and the signature of a committed gossiped block
+type gossipSignature struct {
+	originPkiID []byte
+	signature   []byte
+}
+
+// gossiper spreads the committed blocks epidemic-style among the non-validating peers. A
+// validating peer sends each block it commits, along with its state delta, to fanout of the
+// non-validating peers. A non-validating peer commits the block once it has been signed by a
+// validating peer, chains to its blockchain and the state delta matches the state hash of the
+// block, and relays the blocks it did not have yet to fanout of the other non-validating peers,
+// up to maxHops hops from the validating peer. Periodically, a non-validating peer sends the
+// digest of its blockchain to a random peer, which sends back the blocks it misses
+// (anti-entropy), so that the blocks lost on the way are eventually received. A non-validating
+// peer retains the signatures of its last retainedSignatures blocks, which it can send back.
+// Without security, the peers cannot be authenticated and the non-validating peers only
+// commit the blocks sent by the validating peers they are connected to.
+type gossiper struct {
+	stack              gossipStack
+	auth               gossipAuthenticator // nil unless security is enabled
+	relay              bool                // whether the gossiped blocks are committed and relayed, which validating peers do not
+	fanout             int
+	maxHops            uint32
+	maxBlocks          uint64 // the number of blocks sent in reply to a digest
+	maxPending         int
+	retainedSignatures uint64
+
+	sync.Mutex
+	pending    map[uint64]*pb.GossipBlock  // the blocks received ahead of the blockchain, by block number
+	signatures map[uint64]*gossipSignature // the signatures of the committed gossiped blocks, by block number
+}
+
+// newGossiperFromConfig returns nil if the gossip of the blocks is not enabled. auth is nil
+// unless security is enabled
+func newGossiperFromConfig(stack gossipStack, auth gossipAuthenticator) *gossiper {
+	if !viper.GetBool("peer.gossip.enabled") {
+		return nil
+	}
+	fanout := viper.GetInt("peer.gossip.fanout")
+	if fanout <= 0 {
+		panic(fmt.Errorf("peer.gossip.fanout must be greater than 0"))
+	}
+	maxBlocks := viper.GetInt("peer.gossip.antiEntropy.maxBlocks")
+	if maxBlocks <= 0 {
+		panic(fmt.Errorf("peer.gossip.antiEntropy.maxBlocks must be greater than 0"))
+	}
+	if auth == nil {
+		peerLogger.Warning("Security is not enabled, the gossiped blocks are only taken from the validating peers connected to")
+	}
+	return newGossiper(stack, auth, !ValidatorEnabled(), fanout, uint32(viper.GetInt("peer.gossip.maxHops")),
+		uint64(maxBlocks), viper.GetInt("peer.gossip.maxPending"), uint64(viper.GetInt("peer.gossip.antiEntropy.retainedSignatures")))
+}
+
+func newGossiper(stack gossipStack, auth gossipAuthenticator, relay bool, fanout int, maxHops uint32, maxBlocks uint64, maxPending int, retainedSignatures uint64) *gossiper {
+	return &gossiper{
+		stack:              stack,
+		auth:               auth,
+		relay:              relay,
+		fanout:             fanout,
+		maxHops:            maxHops,
+		maxBlocks:          maxBlocks,
+		maxPending:         maxPending,
+		retainedSignatures: retainedSignatures,
+		pending:            make(map[uint64]*pb.GossipBlock),
+		signatures:         make(map[uint64]*gossipSignature),
+	}
+}
+
+// start runs the anti-entropy of the blockchain with a random peer every period
+func (g *gossiper) start(period time.Duration) {
+	if period <= 0 {
+		return
+	}
+	go func() {
+		for range time.NewTicker(period).C {
+			g.antiEntropy()
+		}
+	}()
+}
+
+// gossipBlock sends a block the peer committed to fanout of the non-validating peers
+func (g *gossiper) gossipBlock(blockNumber uint64) error {
+	msg, err := g.newGossipBlockMessage(blockNumber, 0)
+	if err != nil {
+		return err
+	}
+	g.send(msg, g.pickPeers(pb.PeerEndpoint_NON_VALIDATOR, g.fanout, nil))
+	return nil
+}
+
+// handleMessage handles the GOSSIP_BLOCK and GOSSIP_DIGEST messages received from sender
+func (g *gossiper) handleMessage(msg *pb.Message, sender *pb.PeerID) error {
+	switch msg.Type {
+	case pb.Message_GOSSIP_BLOCK:
+		gossipBlock := &pb.GossipBlock{}
+		if err := proto.Unmarshal(msg.Payload, gossipBlock); err != nil {
+			return fmt.Errorf("Error unmarshalling GossipBlock: %s", err)
+		}
+		return g.blockReceived(gossipBlock, sender)
+	case pb.Message_GOSSIP_DIGEST:
+		digest := &pb.GossipDigest{}
+		if err := proto.Unmarshal(msg.Payload, digest); err != nil {
+			return fmt.Errorf("Error unmarshalling GossipDigest: %s", err)
+		}
+		return g.digestReceived(digest, sender)
+	}
+	return fmt.Errorf("Unexpected gossip message type %s", msg.Type)
+}
+
+func (g *gossiper) blockReceived(gossipBlock *pb.GossipBlock, sender *pb.PeerID) error {
+	if !g.relay {
+		return nil
+	}
+	if gossipBlock.GetBlockState().GetBlock() == nil {
+		return fmt.Errorf("Received a GossipBlock without a block from %s", sender)
+	}
+	if gossipBlock.BlockNumber < g.stack.GetBlockchainSize() {
+		// already committed
+		return nil
+	}
+	if err := g.authenticate(gossipBlock, sender); err != nil {
+		return err
+	}
+
+	var isNew, isAhead bool
+	g.Lock()
+	height := g.stack.GetBlockchainSize()
+	switch {
+	case gossipBlock.BlockNumber < height:
+		// already committed
+	case gossipBlock.BlockNumber > height:
+		isAhead = true
+		if _, ok := g.pending[gossipBlock.BlockNumber]; !ok && len(g.pending) < g.maxPending {
+			g.pending[gossipBlock.BlockNumber] = gossipBlock
+			isNew = true
+		}
+	default:
+		if err := g.commitBlock(gossipBlock); err != nil {
+			g.Unlock()
+			return err
+		}
+		isNew = true
+		g.commitPendingBlocks()
+	}
+	g.Unlock()
+
+	// without security, the other non-validating peers only take the blocks of the validating peers
+	if isNew && gossipBlock.Hops < g.maxHops && g.auth != nil {
+		relayed := &pb.GossipBlock{BlockNumber: gossipBlock.BlockNumber, BlockState: gossipBlock.BlockState, Hops: gossipBlock.Hops + 1,
+			OriginPkiID: gossipBlock.OriginPkiID, Signature: gossipBlock.Signature}
+		payload, err := proto.Marshal(relayed)
+		if err != nil {
+			return fmt.Errorf("Error marshalling GossipBlock: %s", err)
+		}
+		g.send(&pb.Message{Type: pb.Message_GOSSIP_BLOCK, Payload: payload}, g.pickPeers(pb.PeerEndpoint_NON_VALIDATOR, g.fanout, sender))
+	}
+	if isAhead {
+		// ask the sender for the blocks in between
+		return g.sendDigest(sender)
+	}
+	return nil
+}
+
+// commitBlock commits a gossiped block which is the next one of the blockchain
+func (g *gossiper) commitBlock(gossipBlock *pb.GossipBlock) error {
+	block := gossipBlock.BlockState.Block
+	if gossipBlock.BlockNumber > 0 {
+		previousBlock, err := g.stack.GetBlockByNumber(gossipBlock.BlockNumber - 1)
+		if err != nil {
+			return err
+		}
+		previousBlockHash, err := g.stack.HashBlock(previousBlock)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(block.PreviousBlockHash, previousBlockHash) {
+			return fmt.Errorf("Gossiped block %d does not chain to the blockchain", gossipBlock.BlockNumber)
+		}
+	}
+
+	delta := &statemgmt.StateDelta{}
+	if err := delta.Unmarshal(gossipBlock.BlockState.StateDelta); err != nil {
+		return fmt.Errorf("Error unmarshalling the state delta of gossiped block %d: %s", gossipBlock.BlockNumber, err)
+	}
+	if err := g.stack.ApplyStateDelta(gossipBlock, delta); err != nil {
+		return err
+	}
+	stateHash, err := g.stack.GetCurrentStateHash()
+	if err != nil || !bytes.Equal(stateHash, block.StateHash) {
+		if err := g.stack.RollbackStateDelta(gossipBlock); err != nil {
+			return err
+		}
+		return fmt.Errorf("The state delta of gossiped block %d does not match the state hash of the block", gossipBlock.BlockNumber)
+	}
+	if err := g.stack.CommitStateDelta(gossipBlock); err != nil {
+		return err
+	}
+	if err := g.stack.PutBlock(gossipBlock.BlockNumber, block); err != nil {
+		return err
+	}
+	g.signatures[gossipBlock.BlockNumber] = &gossipSignature{gossipBlock.OriginPkiID, gossipBlock.Signature}
+	if gossipBlock.BlockNumber >= g.retainedSignatures {
+		delete(g.signatures, gossipBlock.BlockNumber-g.retainedSignatures)
+	}
+	peerLogger.Debug("Committed gossiped block %d", gossipBlock.BlockNumber)
+	return nil
+}
+
+// authenticate returns an error unless the gossiped block originates from a validating peer,
+// i.e. is signed by a peer whose enrollment certificate has been issued to a validator or,
+// without security, has been sent by a validating peer
+func (g *gossiper) authenticate(gossipBlock *pb.GossipBlock, sender *pb.PeerID) error {
+	if g.auth == nil {
+		peersMessage, err := g.stack.GetPeers()
+		if err != nil {
+			return err
+		}
+		for _, endpoint := range peersMessage.Peers {
+			if endpoint.ID.Name == sender.Name && endpoint.Type == pb.PeerEndpoint_VALIDATOR {
+				return nil
+			}
+		}
+		return fmt.Errorf("Gossiped block %d was not sent by a validating peer", gossipBlock.BlockNumber)
+	}
+	message, err := g.signedBytes(gossipBlock.BlockNumber, gossipBlock.BlockState)
+	if err != nil {
+		return err
+	}
+	if err := g.auth.verify(gossipBlock.OriginPkiID, gossipBlock.Signature, message); err != nil {
+		return fmt.Errorf("Gossiped block %d is not signed by a validating peer: %s", gossipBlock.BlockNumber, err)
+	}
+	return nil
+}
+
+// signedBytes returns the bytes the validating peer a block originates from signs: the block
+// number, the hash of the block and the hash of the state delta
+func (g *gossiper) signedBytes(blockNumber uint64, blockState *pb.BlockState) ([]byte, error) {
+	blockHash, err := g.stack.HashBlock(blockState.Block)
+	if err != nil {
+		return nil, err
+	}
+	message := make([]byte, 8)
+	binary.BigEndian.PutUint64(message, blockNumber)
+	message = append(message, blockHash...)
+	return append(message, util.ComputeCryptoHash(blockState.StateDelta)...), nil
+}
+
+// commitPendingBlocks commits the pending blocks which follow the blockchain, and drops the
+// ones it already has
+func (g *gossiper) commitPendingBlocks() {
+	height := g.stack.GetBlockchainSize()
+	for blockNumber := range g.pending {
+		if blockNumber < height {
+			delete(g.pending, blockNumber)
+		}
+	}
+	for gossipBlock, ok := g.pending[height]; ok; gossipBlock, ok = g.pending[height] {
+		delete(g.pending, height)
+		if err := g.commitBlock(gossipBlock); err != nil {
+			peerLogger.Warning("Dropping pending gossiped block %d: %s", height, err)
+			return
+		}
+		height++
+	}
+}
+
+func (g *gossiper) digestReceived(digest *pb.GossipDigest, sender *pb.PeerID) error {
+	height := g.stack.GetBlockchainSize()
+	switch {
+	case digest.Height < height:
+		if g.relay && g.auth == nil {
+			// the blocks of a non-validating peer are only taken with the signature of their origin
+			return nil
+		}
+		// send the blocks the sender misses, which are not relayed further
+		for blockNumber := digest.Height; blockNumber < height && blockNumber < digest.Height+g.maxBlocks; blockNumber++ {
+			msg, err := g.newGossipBlockMessage(blockNumber, g.maxHops)
+			if err != nil {
+				return err
+			}
+			if err := g.stack.Unicast(msg, sender); err != nil {
+				return err
+			}
+		}
+	case digest.Height > height:
+		if g.relay {
+			// ask the sender for the blocks we miss
+			return g.sendDigest(sender)
+		}
+	default:
+		if headBlockHash, err := g.getHeadBlockHash(height); err == nil && !bytes.Equal(headBlockHash, digest.HeadBlockHash) {
+			peerLogger.Warning("The head block %d of the blockchain differs from the one of %s", height-1, sender)
+		}
+	}
+	return nil
+}
+
+// antiEntropy sends the digest of the blockchain to a random peer
+func (g *gossiper) antiEntropy() {
+	for _, peerID := range g.pickPeers(pb.PeerEndpoint_UNDEFINED, 1, nil) {
+		if err := g.sendDigest(peerID); err != nil {
+			peerLogger.Warning("Error sending the gossip digest to %s: %s", peerID, err)
+		}
+	}
+}
+
+func (g *gossiper) sendDigest(receiver *pb.PeerID) error {
+	height := g.stack.GetBlockchainSize()
+	headBlockHash, err := g.getHeadBlockHash(height)
+	if err != nil {
+		return err
+	}
+	payload, err := proto.Marshal(&pb.GossipDigest{Height: height, HeadBlockHash: headBlockHash})
+	if err != nil {
+		return fmt.Errorf("Error marshalling GossipDigest: %s", err)
+	}
+	return g.stack.Unicast(&pb.Message{Type: pb.Message_GOSSIP_DIGEST, Payload: payload}, receiver)
+}
+
+func (g *gossiper) getHeadBlockHash(height uint64) ([]byte, error) {
+	if height == 0 {
+		return nil, nil
+	}
+	block, err := g.stack.GetBlockByNumber(height - 1)
+	if err != nil {
+		return nil, err
+	}
+	return g.stack.HashBlock(block)
+}
+
+func (g *gossiper) newGossipBlockMessage(blockNumber uint64, hops uint32) (*pb.Message, error) {
+	block, err := g.stack.GetBlockByNumber(blockNumber)
+	if err != nil {
+		return nil, err
+	}
+	delta, err := g.stack.GetStateDelta(blockNumber)
+	if err != nil {
+		return nil, err
+	}
+	if delta == nil {
+		return nil, fmt.Errorf("The state delta of block %d is no longer retained", blockNumber)
+	}
+	gossipBlock := &pb.GossipBlock{BlockNumber: blockNumber, BlockState: &pb.BlockState{Block: block, StateDelta: delta.Marshal()}, Hops: hops}
+	if g.auth != nil {
+		if g.relay {
+			g.Lock()
+			signature, ok := g.signatures[blockNumber]
+			g.Unlock()
+			if !ok {
+				return nil, fmt.Errorf("The signature of block %d is no longer retained", blockNumber)
+			}
+			gossipBlock.OriginPkiID, gossipBlock.Signature = signature.originPkiID, signature.signature
+		} else {
+			message, err := g.signedBytes(blockNumber, gossipBlock.BlockState)
+			if err != nil {
+				return nil, err
+			}
+			if gossipBlock.OriginPkiID, gossipBlock.Signature, err = g.auth.sign(message); err != nil {
+				return nil, fmt.Errorf("Error signing block %d: %s", blockNumber, err)
+			}
+		}
+	}
+	payload, err := proto.Marshal(gossipBlock)
+	if err != nil {
+		return nil, fmt.Errorf("Error marshalling GossipBlock: %s", err)
+	}
+	return &pb.Message{Type: pb.Message_GOSSIP_BLOCK, Payload: payload}, nil
+}
+
+// pickPeers returns up to count of the connected peers of the given type, other than exclude, at random
+func (g *gossiper) pickPeers(typ pb.PeerEndpoint_Type, count int, exclude *pb.PeerID) []*pb.PeerID {
+	peersMessage, err := g.stack.GetPeers()
+	if err != nil {
+		peerLogger.Warning("Error getting the peers to gossip with: %s", err)
+		return nil
+	}
+	var candidates []*pb.PeerID
+	for _, endpoint := range peersMessage.Peers {
+		if typ != pb.PeerEndpoint_UNDEFINED && endpoint.Type != typ {
+			continue
+		}
+		if exclude != nil && endpoint.ID.Name == exclude.Name {
+			continue
+		}
+		candidates = append(candidates, endpoint.ID)
+	}
+	var peerIDs []*pb.PeerID
+	for _, i := range rand.Perm(len(candidates)) {
+		if len(peerIDs) == count {
+			break
+		}
+		peerIDs = append(peerIDs, candidates[i])
+	}
+	return peerIDs
+}
+
+func (g *gossiper) send(msg *pb.Message, peerIDs []*pb.PeerID) {
+	for _, peerID := range peerIDs {
+		if err := g.stack.Unicast(msg, peerID); err != nil {
+			peerLogger.Warning("Error gossiping %s to %s: %s", msg.Type, peerID, err)
+		}
+	}
+}
@@ -0,0 +1,393 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package peer
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+	"github.com/hyperledger/fabric/core/ledger/testutil"
+	"github.com/hyperledger/fabric/core/util"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+type gossipTestMessage struct {
+	msg      *pb.Message
+	sender   *pb.PeerID
+	receiver *pb.PeerID
+}
+
+// gossipTestNetwork queues the messages the peers unicast, until they are delivered
+type gossipTestNetwork struct {
+	peers map[string]*gossipTestPeer
+	queue []*gossipTestMessage
+}
+
+func newGossipTestNetwork() *gossipTestNetwork {
+	return &gossipTestNetwork{peers: make(map[string]*gossipTestPeer)}
+}
+
+func (n *gossipTestNetwork) addPeer(name string, typ pb.PeerEndpoint_Type, fanout int, maxHops uint32, maxBlocks uint64) *gossipTestPeer {
+	p := &gossipTestPeer{
+		endpoint:  &pb.PeerEndpoint{ID: &pb.PeerID{Name: name}, Type: typ},
+		network:   n,
+		neighbors: make(map[string]bool),
+		state:     make(map[string][]byte),
+		deltas:    make(map[uint64]*statemgmt.StateDelta),
+	}
+	p.gossiper = newGossiper(p, &gossipTestAuthenticator{n, name}, typ == pb.PeerEndpoint_NON_VALIDATOR, fanout, maxHops, maxBlocks, 10, 100)
+	n.peers[name] = p
+	return p
+}
+
+func (n *gossipTestNetwork) connect(name1, name2 string) {
+	n.peers[name1].neighbors[name2] = true
+	n.peers[name2].neighbors[name1] = true
+}
+
+// deliver delivers the queued messages, and the ones sent in reply, in order
+func (n *gossipTestNetwork) deliver(t *testing.T) {
+	for len(n.queue) > 0 {
+		m := n.queue[0]
+		n.queue = n.queue[1:]
+		if err := n.peers[m.receiver.Name].gossiper.handleMessage(m.msg, m.sender); err != nil {
+			t.Logf("%s failed to handle %s from %s: %s", m.receiver.Name, m.msg.Type, m.sender.Name, err)
+		}
+	}
+}
+
+// gossipTestAuthenticator signs with the name of the peer as PKI ID, the validating peers being
+// the peers of the network of type VALIDATOR
+type gossipTestAuthenticator struct {
+	network *gossipTestNetwork
+	name    string
+}
+
+func (a *gossipTestAuthenticator) sign(message []byte) ([]byte, []byte, error) {
+	return []byte(a.name), util.ComputeCryptoHash(append([]byte(a.name), message...)), nil
+}
+
+func (a *gossipTestAuthenticator) verify(originPkiID []byte, signature []byte, message []byte) error {
+	origin, ok := a.network.peers[string(originPkiID)]
+	if !ok || origin.endpoint.Type != pb.PeerEndpoint_VALIDATOR {
+		return fmt.Errorf("%s is not a validating peer", originPkiID)
+	}
+	if !bytes.Equal(signature, util.ComputeCryptoHash(append([]byte(string(originPkiID)), message...))) {
+		return fmt.Errorf("Invalid signature")
+	}
+	return nil
+}
+
+// gossipTestPeer keeps its blockchain and its state in memory
+type gossipTestPeer struct {
+	endpoint  *pb.PeerEndpoint
+	network   *gossipTestNetwork
+	neighbors map[string]bool
+	gossiper  *gossiper
+
+	blocks    []*pb.Block
+	deltas    map[uint64]*statemgmt.StateDelta
+	state     map[string][]byte
+	preDelta  map[string][]byte
+	lastDelta *statemgmt.StateDelta
+}
+
+// commit commits a block setting key to value and gossips it
+func (p *gossipTestPeer) commit(t *testing.T, key string, value string) {
+	blockNumber := p.commitBlock(t, key, value)
+	testutil.AssertNoError(t, p.gossiper.gossipBlock(blockNumber), "Error gossiping block")
+}
+
+// commitBlock commits a block setting key to value
+func (p *gossipTestPeer) commitBlock(t *testing.T, key string, value string) uint64 {
+	delta := statemgmt.NewStateDelta()
+	delta.Set("chaincode1", key, []byte(value), nil)
+	testutil.AssertNoError(t, p.ApplyStateDelta(key, delta), "Error applying state delta")
+	testutil.AssertNoError(t, p.CommitStateDelta(key), "Error committing state delta")
+	stateHash, _ := p.GetCurrentStateHash()
+	block := &pb.Block{StateHash: stateHash, ConsensusMetadata: []byte(key)}
+	if height := len(p.blocks); height > 0 {
+		block.PreviousBlockHash, _ = p.HashBlock(p.blocks[height-1])
+	}
+	blockNumber := uint64(len(p.blocks))
+	testutil.AssertNoError(t, p.PutBlock(blockNumber, block), "Error putting block")
+	return blockNumber
+}
+
+// newSignedGossipBlockMessage returns a GOSSIP_BLOCK message of the given block, signed by signer
+func newSignedGossipBlockMessage(t *testing.T, signer *gossipTestPeer, gossipBlock *pb.GossipBlock) *pb.Message {
+	message, err := signer.gossiper.signedBytes(gossipBlock.BlockNumber, gossipBlock.BlockState)
+	testutil.AssertNoError(t, err, "Error getting the signed bytes")
+	gossipBlock.OriginPkiID, gossipBlock.Signature, _ = signer.gossiper.auth.sign(message)
+	payload, err := proto.Marshal(gossipBlock)
+	testutil.AssertNoError(t, err, "Error marshalling GossipBlock")
+	return &pb.Message{Type: pb.Message_GOSSIP_BLOCK, Payload: payload}
+}
+
+func (p *gossipTestPeer) assertInSync(t *testing.T, other *gossipTestPeer) {
+	if p.GetBlockchainSize() != other.GetBlockchainSize() {
+		t.Fatalf("Expected %s to have %d blocks as %s, got %d", p.endpoint.ID.Name, other.GetBlockchainSize(), other.endpoint.ID.Name, p.GetBlockchainSize())
+	}
+	stateHash, _ := p.GetCurrentStateHash()
+	otherStateHash, _ := other.GetCurrentStateHash()
+	if !bytes.Equal(stateHash, otherStateHash) {
+		t.Fatalf("Expected %s to have the same state as %s", p.endpoint.ID.Name, other.endpoint.ID.Name)
+	}
+}
+
+func (p *gossipTestPeer) GetBlockByNumber(blockNumber uint64) (*pb.Block, error) {
+	if blockNumber >= uint64(len(p.blocks)) {
+		return nil, fmt.Errorf("Block %d not found", blockNumber)
+	}
+	return p.blocks[blockNumber], nil
+}
+
+func (p *gossipTestPeer) GetBlockchainSize() uint64 {
+	return uint64(len(p.blocks))
+}
+
+func (p *gossipTestPeer) GetCurrentStateHash() ([]byte, error) {
+	var keys []string
+	for key := range p.state {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var buffer bytes.Buffer
+	for _, key := range keys {
+		buffer.WriteString(key)
+		buffer.Write(p.state[key])
+	}
+	return util.ComputeCryptoHash(buffer.Bytes()), nil
+}
+
+func (p *gossipTestPeer) ApplyStateDelta(id interface{}, delta *statemgmt.StateDelta) error {
+	p.preDelta = make(map[string][]byte)
+	for key, value := range p.state {
+		p.preDelta[key] = value
+	}
+	for key, updatedValue := range delta.GetUpdates("chaincode1") {
+		if updatedValue.IsDelete() {
+			delete(p.state, key)
+		} else {
+			p.state[key] = updatedValue.GetValue()
+		}
+	}
+	p.lastDelta = delta
+	return nil
+}
+
+func (p *gossipTestPeer) RollbackStateDelta(id interface{}) error {
+	p.state = p.preDelta
+	return nil
+}
+
+func (p *gossipTestPeer) CommitStateDelta(id interface{}) error {
+	return nil
+}
+
+func (p *gossipTestPeer) EmptyState() error {
+	p.state = make(map[string][]byte)
+	return nil
+}
+
+func (p *gossipTestPeer) RewindState(stateBlockNumber, blockNumber uint64) error {
+	return fmt.Errorf("Not implemented")
+}
+
+func (p *gossipTestPeer) PutBlock(blockNumber uint64, block *pb.Block) error {
+	if blockNumber != uint64(len(p.blocks)) {
+		return fmt.Errorf("Expected block %d, got block %d", len(p.blocks), blockNumber)
+	}
+	p.blocks = append(p.blocks, block)
+	p.deltas[blockNumber] = p.lastDelta
+	return nil
+}
+
+func (p *gossipTestPeer) HashBlock(block *pb.Block) ([]byte, error) {
+	return block.GetHash()
+}
+
+func (p *gossipTestPeer) VerifyBlockchain(start, finish uint64) (uint64, error) {
+	return 0, nil
+}
+
+func (p *gossipTestPeer) GetStateDelta(blockNumber uint64) (*statemgmt.StateDelta, error) {
+	return p.deltas[blockNumber], nil
+}
+
+func (p *gossipTestPeer) GetPeers() (*pb.PeersMessage, error) {
+	peersMessage := &pb.PeersMessage{}
+	for name := range p.neighbors {
+		peersMessage.Peers = append(peersMessage.Peers, p.network.peers[name].endpoint)
+	}
+	return peersMessage, nil
+}
+
+func (p *gossipTestPeer) Unicast(msg *pb.Message, receiver *pb.PeerID) error {
+	if !p.neighbors[receiver.Name] {
+		return fmt.Errorf("%s is not connected to %s", p.endpoint.ID.Name, receiver.Name)
+	}
+	p.network.queue = append(p.network.queue, &gossipTestMessage{msg: msg, sender: p.endpoint.ID, receiver: receiver})
+	return nil
+}
+
+// newGossipTestLine returns a validating peer followed by the given number of non-validating
+// peers, each one connected to the previous one only
+func newGossipTestLine(nvps int, maxHops uint32, maxBlocks uint64) (*gossipTestNetwork, *gossipTestPeer, []*gossipTestPeer) {
+	network := newGossipTestNetwork()
+	vp := network.addPeer("vp", pb.PeerEndpoint_VALIDATOR, 1, maxHops, maxBlocks)
+	var peers []*gossipTestPeer
+	previous := "vp"
+	for i := 0; i < nvps; i++ {
+		name := fmt.Sprintf("nvp%d", i)
+		peers = append(peers, network.addPeer(name, pb.PeerEndpoint_NON_VALIDATOR, 1, maxHops, maxBlocks))
+		network.connect(previous, name)
+		previous = name
+	}
+	return network, vp, peers
+}
+
+func TestGossipRelaysBlocks(t *testing.T) {
+	network, vp, nvps := newGossipTestLine(3, 5, 20)
+	for i := 0; i < 3; i++ {
+		vp.commit(t, fmt.Sprintf("key%d", i), fmt.Sprintf("value%d", i))
+		network.deliver(t)
+	}
+	for _, nvp := range nvps {
+		nvp.assertInSync(t, vp)
+	}
+}
+
+func TestGossipAntiEntropy(t *testing.T) {
+	// the blocks do not go further than the second non-validating peer
+	network, vp, nvps := newGossipTestLine(3, 1, 2)
+	for i := 0; i < 3; i++ {
+		vp.commit(t, fmt.Sprintf("key%d", i), fmt.Sprintf("value%d", i))
+		network.deliver(t)
+	}
+	nvps[1].assertInSync(t, vp)
+	testutil.AssertEquals(t, nvps[2].GetBlockchainSize(), uint64(0))
+
+	// each digest is answered with up to 2 blocks
+	nvps[2].gossiper.antiEntropy()
+	network.deliver(t)
+	testutil.AssertEquals(t, nvps[2].GetBlockchainSize(), uint64(2))
+	nvps[2].gossiper.antiEntropy()
+	network.deliver(t)
+	nvps[2].assertInSync(t, vp)
+
+	// a peer ahead asks for the blocks it misses
+	vp.commit(t, "key3", "value3")
+	network.deliver(t)
+	testutil.AssertNoError(t, nvps[1].gossiper.sendDigest(nvps[2].endpoint.ID), "Error sending digest")
+	network.deliver(t)
+	nvps[2].assertInSync(t, vp)
+}
+
+func TestGossipBlockAhead(t *testing.T) {
+	network, vp, nvps := newGossipTestLine(1, 5, 20)
+	for i := 0; i < 3; i++ {
+		vp.commit(t, fmt.Sprintf("key%d", i), fmt.Sprintf("value%d", i))
+	}
+	// only the last block reaches the non-validating peer, which asks for the ones before
+	network.queue = network.queue[2:]
+	network.deliver(t)
+	nvps[0].assertInSync(t, vp)
+}
+
+func TestGossipRejectsBadBlocks(t *testing.T) {
+	network, vp, nvps := newGossipTestLine(1, 5, 20)
+	vp.commit(t, "key0", "value0")
+	network.deliver(t)
+	vp.commit(t, "key1", "value1")
+
+	// a state delta which does not match the state hash of the block
+	gossipBlock := &pb.GossipBlock{}
+	testutil.AssertNoError(t, proto.Unmarshal(network.queue[0].msg.Payload, gossipBlock), "Error unmarshalling GossipBlock")
+	delta := statemgmt.NewStateDelta()
+	delta.Set("chaincode1", "key1", []byte("forged"), nil)
+	gossipBlock.BlockState.StateDelta = delta.Marshal()
+	msg := newSignedGossipBlockMessage(t, vp, gossipBlock)
+	testutil.AssertError(t, nvps[0].gossiper.handleMessage(msg, vp.endpoint.ID), "Expected an error for a forged state delta")
+
+	// a block which does not chain to the blockchain
+	gossipBlock.BlockState.Block.PreviousBlockHash = []byte("forged")
+	msg = newSignedGossipBlockMessage(t, vp, gossipBlock)
+	testutil.AssertError(t, nvps[0].gossiper.handleMessage(msg, vp.endpoint.ID), "Expected an error for a block which does not chain")
+	testutil.AssertEquals(t, nvps[0].GetBlockchainSize(), uint64(1))
+
+	// the genuine block is still taken
+	network.deliver(t)
+	nvps[0].assertInSync(t, vp)
+}
+
+func TestGossipRejectsForgedBlocks(t *testing.T) {
+	network, vp, nvps := newGossipTestLine(2, 5, 20)
+	vp.commit(t, "key0", "value0")
+	network.deliver(t)
+	nvps[0].assertInSync(t, vp)
+
+	// a non-validating peer forks the blockchain with a block of its own, which chains to the
+	// blockchain and whose state delta matches its state hash
+	blockNumber := nvps[0].commitBlock(t, "key1", "forged")
+	gossipBlock := &pb.GossipBlock{BlockNumber: blockNumber, BlockState: &pb.BlockState{Block: nvps[0].blocks[blockNumber], StateDelta: nvps[0].deltas[blockNumber].Marshal()}}
+	msg := newSignedGossipBlockMessage(t, nvps[0], gossipBlock)
+	testutil.AssertError(t, nvps[1].gossiper.handleMessage(msg, nvps[0].endpoint.ID), "Expected an error for a block signed by a non-validating peer")
+
+	// or claims it originates from the validating peer
+	gossipBlock.OriginPkiID = []byte("vp")
+	payload, _ := proto.Marshal(gossipBlock)
+	msg = &pb.Message{Type: pb.Message_GOSSIP_BLOCK, Payload: payload}
+	testutil.AssertError(t, nvps[1].gossiper.handleMessage(msg, nvps[0].endpoint.ID), "Expected an error for a forged signature")
+	testutil.AssertEquals(t, nvps[1].GetBlockchainSize(), uint64(1))
+
+	// the genuine block is taken, along with its signature which the anti-entropy sends on
+	vp.commit(t, "key1", "value1")
+	testutil.AssertNoError(t, nvps[1].gossiper.handleMessage(network.queue[0].msg, nvps[0].endpoint.ID), "Error handling the genuine block")
+	network.queue = nil
+	nvps[1].assertInSync(t, vp)
+	other := network.addPeer("nvp2", pb.PeerEndpoint_NON_VALIDATOR, 1, 5, 20)
+	network.connect("nvp1", "nvp2")
+	other.gossiper.antiEntropy()
+	network.deliver(t)
+	other.assertInSync(t, vp)
+}
+
+func TestGossipWithoutSecurity(t *testing.T) {
+	network, vp, nvps := newGossipTestLine(2, 5, 20)
+	for _, p := range network.peers {
+		p.gossiper.auth = nil
+	}
+	vp.commit(t, "key0", "value0")
+	network.deliver(t)
+	nvps[0].assertInSync(t, vp)
+
+	// the blocks are neither relayed nor sent back by the non-validating peers
+	testutil.AssertEquals(t, nvps[1].GetBlockchainSize(), uint64(0))
+	nvps[1].gossiper.antiEntropy()
+	network.deliver(t)
+	testutil.AssertEquals(t, nvps[1].GetBlockchainSize(), uint64(0))
+	msg, err := nvps[0].gossiper.newGossipBlockMessage(0, 0)
+	testutil.AssertNoError(t, err, "Error creating GossipBlock message")
+	testutil.AssertError(t, nvps[1].gossiper.handleMessage(msg, nvps[0].endpoint.ID), "Expected an error for a block sent by a non-validating peer")
+	testutil.AssertEquals(t, nvps[1].GetBlockchainSize(), uint64(0))
+}
@@ -43,6 +43,10 @@ type Handler struct {
 	snapshotRequestHandler        *syncStateSnapshotRequestHandler
 	syncStateDeltasRequestHandler *syncStateDeltasHandler
 	syncBlocksRequestHandler      *syncBlocksRequestHandler
+	syncCompression               pb.SyncCompression // codec used for state sync payloads sent to this peer
+	remoteSyncStateVersion        uint32             // version of the state sync protocol supported by this peer
+	bucketHashesRequestHandler    *syncStateBucketHashesHandler
+	bucketsRequestHandler         *syncStateBucketsHandler
 }
 
 // NewPeerHandler returns a new Peer handler
@@ -59,6 +63,8 @@ func NewPeerHandler(coord MessageHandlerCoordinator, stream ChatStream, initiate
 	d.snapshotRequestHandler = newSyncStateSnapshotRequestHandler()
 	d.syncStateDeltasRequestHandler = newSyncStateDeltasHandler()
 	d.syncBlocksRequestHandler = newSyncBlocksRequestHandler()
+	d.bucketHashesRequestHandler = newSyncStateBucketHashesHandler()
+	d.bucketsRequestHandler = newSyncStateBucketsHandler()
 	d.FSM = fsm.NewFSM(
 		"created",
 		fsm.Events{
@@ -72,19 +78,31 @@ func NewPeerHandler(coord MessageHandlerCoordinator, stream ChatStream, initiate
 			{Name: pb.Message_SYNC_STATE_SNAPSHOT.String(), Src: []string{"established"}, Dst: "established"},
 			{Name: pb.Message_SYNC_STATE_GET_DELTAS.String(), Src: []string{"established"}, Dst: "established"},
 			{Name: pb.Message_SYNC_STATE_DELTAS.String(), Src: []string{"established"}, Dst: "established"},
+			{Name: pb.Message_SYNC_STATE_GET_BUCKET_HASHES.String(), Src: []string{"established"}, Dst: "established"},
+			{Name: pb.Message_SYNC_STATE_BUCKET_HASHES.String(), Src: []string{"established"}, Dst: "established"},
+			{Name: pb.Message_SYNC_STATE_GET_BUCKETS.String(), Src: []string{"established"}, Dst: "established"},
+			{Name: pb.Message_SYNC_STATE_BUCKETS.String(), Src: []string{"established"}, Dst: "established"},
+			{Name: pb.Message_GOSSIP_BLOCK.String(), Src: []string{"established"}, Dst: "established"},
+			{Name: pb.Message_GOSSIP_DIGEST.String(), Src: []string{"established"}, Dst: "established"},
 		},
 		fsm.Callbacks{
-			"enter_state":                                           func(e *fsm.Event) { d.enterState(e) },
-			"before_" + pb.Message_DISC_HELLO.String():              func(e *fsm.Event) { d.beforeHello(e) },
-			"before_" + pb.Message_DISC_GET_PEERS.String():          func(e *fsm.Event) { d.beforeGetPeers(e) },
-			"before_" + pb.Message_DISC_PEERS.String():              func(e *fsm.Event) { d.beforePeers(e) },
-			"before_" + pb.Message_SYNC_BLOCK_ADDED.String():        func(e *fsm.Event) { d.beforeBlockAdded(e) },
-			"before_" + pb.Message_SYNC_GET_BLOCKS.String():         func(e *fsm.Event) { d.beforeSyncGetBlocks(e) },
-			"before_" + pb.Message_SYNC_BLOCKS.String():             func(e *fsm.Event) { d.beforeSyncBlocks(e) },
-			"before_" + pb.Message_SYNC_STATE_GET_SNAPSHOT.String(): func(e *fsm.Event) { d.beforeSyncStateGetSnapshot(e) },
-			"before_" + pb.Message_SYNC_STATE_SNAPSHOT.String():     func(e *fsm.Event) { d.beforeSyncStateSnapshot(e) },
-			"before_" + pb.Message_SYNC_STATE_GET_DELTAS.String():   func(e *fsm.Event) { d.beforeSyncStateGetDeltas(e) },
-			"before_" + pb.Message_SYNC_STATE_DELTAS.String():       func(e *fsm.Event) { d.beforeSyncStateDeltas(e) },
+			"enter_state": func(e *fsm.Event) { d.enterState(e) },
+			"before_" + pb.Message_DISC_HELLO.String():                   func(e *fsm.Event) { d.beforeHello(e) },
+			"before_" + pb.Message_DISC_GET_PEERS.String():               func(e *fsm.Event) { d.beforeGetPeers(e) },
+			"before_" + pb.Message_DISC_PEERS.String():                   func(e *fsm.Event) { d.beforePeers(e) },
+			"before_" + pb.Message_SYNC_BLOCK_ADDED.String():             func(e *fsm.Event) { d.beforeBlockAdded(e) },
+			"before_" + pb.Message_SYNC_GET_BLOCKS.String():              func(e *fsm.Event) { d.beforeSyncGetBlocks(e) },
+			"before_" + pb.Message_SYNC_BLOCKS.String():                  func(e *fsm.Event) { d.beforeSyncBlocks(e) },
+			"before_" + pb.Message_SYNC_STATE_GET_SNAPSHOT.String():      func(e *fsm.Event) { d.beforeSyncStateGetSnapshot(e) },
+			"before_" + pb.Message_SYNC_STATE_SNAPSHOT.String():          func(e *fsm.Event) { d.beforeSyncStateSnapshot(e) },
+			"before_" + pb.Message_SYNC_STATE_GET_DELTAS.String():        func(e *fsm.Event) { d.beforeSyncStateGetDeltas(e) },
+			"before_" + pb.Message_SYNC_STATE_DELTAS.String():            func(e *fsm.Event) { d.beforeSyncStateDeltas(e) },
+			"before_" + pb.Message_SYNC_STATE_GET_BUCKET_HASHES.String(): func(e *fsm.Event) { d.beforeSyncStateGetBucketHashes(e) },
+			"before_" + pb.Message_SYNC_STATE_BUCKET_HASHES.String():     func(e *fsm.Event) { d.beforeSyncStateBucketHashes(e) },
+			"before_" + pb.Message_SYNC_STATE_GET_BUCKETS.String():       func(e *fsm.Event) { d.beforeSyncStateGetBuckets(e) },
+			"before_" + pb.Message_SYNC_STATE_BUCKETS.String():           func(e *fsm.Event) { d.beforeSyncStateBuckets(e) },
+			"before_" + pb.Message_GOSSIP_BLOCK.String():                 func(e *fsm.Event) { d.beforeGossip(e) },
+			"before_" + pb.Message_GOSSIP_DIGEST.String():                func(e *fsm.Event) { d.beforeGossip(e) },
 		},
 	)
 
@@ -155,6 +173,11 @@ func (d *Handler) beforeHello(e *fsm.Event) {
 	d.ToPeerEndpoint = helloMessage.PeerEndpoint
 	peerLogger.Debug("Received %s from endpoint=%s", e.Event, helloMessage)
 
+	// Pick the codec for the state sync payloads we send to this peer
+	d.syncCompression = negotiateSyncCompression(SyncStateCompressions(), helloMessage.SyncCompressions)
+	peerLogger.Debug("Negotiated sync compression %s with endpoint=%s", d.syncCompression, helloMessage.PeerEndpoint)
+	d.remoteSyncStateVersion = helloMessage.SyncStateVersion
+
 	// If security enabled, need to verify the signature on the hello message
 	if SecurityEnabled() {
 		if err := d.Coordinator.GetSecHelper().Verify(helloMessage.PeerEndpoint.PkiID, msg.Signature, msg.Payload); err != nil {
@@ -243,6 +266,19 @@ func (d *Handler) beforeBlockAdded(e *fsm.Event) {
 	_ = msg
 }
 
+func (d *Handler) beforeGossip(e *fsm.Event) {
+	peerLogger.Debug("Received message: %s", e.Event)
+	msg, ok := e.Args[0].(*pb.Message)
+	if !ok {
+		e.Cancel(fmt.Errorf("Received unexpected message type"))
+		return
+	}
+	// A peer gossiping a bad block is not a reason to close the stream
+	if err := d.Coordinator.HandleGossipMessage(msg, d.ToPeerEndpoint.ID); err != nil {
+		peerLogger.Warning("Error handling %s from %s: %s", e.Event, d.ToPeerEndpoint.ID, err)
+	}
+}
+
 func (d *Handler) when(stateToCheck string) bool {
 	return d.FSM.Is(stateToCheck)
 }
@@ -445,23 +481,35 @@ func (d *Handler) sendBlocks(syncBlockRange *pb.SyncBlockRange) {
 			blockNums = append(blockNums, i)
 		}
 	}
-	for _, currBlockNum := range blockNums {
-		// Get the Block from
-		block, err := d.Coordinator.GetBlockByNumber(currBlockNum)
-		if err != nil {
-			peerLogger.Error(fmt.Sprintf("Error sending blockNum %d: %s", currBlockNum, err))
-			break
+	// Blocks are sent in batches, the range of each SyncBlocks message keeping the order of the request.
+	// As SendMessage blocks while the stream is congested, blocks are only read as fast as they are sent.
+	batchSize := SyncBlocksBatchSize()
+	for len(blockNums) > 0 {
+		batch := blockNums
+		if len(batch) > batchSize {
+			batch = batch[:batchSize]
+		}
+		blockNums = blockNums[len(batch):]
+		blocks := make([]*pb.Block, 0, len(batch))
+		for _, currBlockNum := range batch {
+			// Get the Block from
+			block, err := d.Coordinator.GetBlockByNumber(currBlockNum)
+			if err != nil {
+				peerLogger.Error(fmt.Sprintf("Error sending blockNum %d: %s", currBlockNum, err))
+				return
+			}
+			blocks = append(blocks, block)
 		}
 		// Encode a SyncBlocks into the payload
-		syncBlocks := &pb.SyncBlocks{Range: &pb.SyncBlockRange{Start: currBlockNum, End: currBlockNum, CorrelationId: syncBlockRange.CorrelationId}, Blocks: []*pb.Block{block}}
+		syncBlocks := &pb.SyncBlocks{Range: &pb.SyncBlockRange{Start: batch[0], End: batch[len(batch)-1], CorrelationId: syncBlockRange.CorrelationId}, Blocks: blocks}
 		syncBlocksBytes, err := proto.Marshal(syncBlocks)
 		if err != nil {
-			peerLogger.Error(fmt.Sprintf("Error marshalling syncBlocks for BlockNum = %d: %s", currBlockNum, err))
-			break
+			peerLogger.Error(fmt.Sprintf("Error marshalling syncBlocks for BlockNums %d-%d: %s", batch[0], batch[len(batch)-1], err))
+			return
 		}
 		if err := d.SendMessage(&pb.Message{Type: pb.Message_SYNC_BLOCKS, Payload: syncBlocksBytes}); err != nil {
-			peerLogger.Error(fmt.Sprintf("Error sending blockNum %d: %s", currBlockNum, err))
-			break
+			peerLogger.Error(fmt.Sprintf("Error sending blockNums %d-%d: %s", batch[0], batch[len(batch)-1], err))
+			return
 		}
 	}
 }
@@ -476,6 +524,16 @@ func (d *Handler) sendBlocks(syncBlockRange *pb.SyncBlockRange) {
 // RequestStateSnapshot request the state snapshot deltas from the other PeerEndpoint, will provide them through the returned channel.
 // this will also stop writing any received syncStateSnapshot(s) to channels created from Prior calls to RequestStateSnapshot()
 func (d *Handler) RequestStateSnapshot() (<-chan *pb.SyncStateSnapshot, error) {
+	return d.requestStateSnapshot(0, 0)
+}
+
+// ResumeStateSnapshot requests the other PeerEndpoint to resume the stream of the state snapshot of the given block from the
+// chunk with the given sequence. If the snapshot is no longer available, the stream of a new snapshot is received from sequence 0.
+func (d *Handler) ResumeStateSnapshot(blockNumber, sequence uint64) (<-chan *pb.SyncStateSnapshot, error) {
+	return d.requestStateSnapshot(blockNumber, sequence)
+}
+
+func (d *Handler) requestStateSnapshot(blockNumber, startSequence uint64) (<-chan *pb.SyncStateSnapshot, error) {
 	d.snapshotRequestHandler.Lock()
 	defer d.snapshotRequestHandler.Unlock()
 	// Reset the handler
@@ -483,6 +541,8 @@ func (d *Handler) RequestStateSnapshot() (<-chan *pb.SyncStateSnapshot, error) {
 
 	// Create the syncStateSnapshotRequest
 	syncStateSnapshotRequest := d.snapshotRequestHandler.createRequest()
+	syncStateSnapshotRequest.BlockNumber = blockNumber
+	syncStateSnapshotRequest.StartSequence = startSequence
 	syncStateSnapshotRequestBytes, err := proto.Marshal(syncStateSnapshotRequest)
 	if err != nil {
 		return nil, fmt.Errorf("Error marshaling syncStateSnapshotRequest during GetStateSnapshot: %s", err)
@@ -510,6 +570,10 @@ func (d *Handler) beforeSyncStateGetSnapshot(e *fsm.Event) {
 		e.Cancel(fmt.Errorf("Error unmarshalling SyncStateSnapshotRequest in beforeSyncStateGetSnapshot: %s", err))
 		return
 	}
+	if err := checkSnapshotRequester(d.ToPeerEndpoint, d.registered, SecurityEnabled()); err != nil {
+		e.Cancel(fmt.Errorf("Refusing the state snapshot request: %s", err))
+		return
+	}
 
 	// Start a separate go FUNC to send the State snapshot
 	go d.sendStateSnapshot(syncStateSnapshotRequest)
@@ -530,6 +594,14 @@ func (d *Handler) beforeSyncStateSnapshot(e *fsm.Event) {
 		e.Cancel(fmt.Errorf("Error unmarshalling syncStateSnapshot in beforeSyncStateSnapshot: %s", err))
 		return
 	}
+	if len(syncStateSnapshot.Delta) > 0 {
+		syncStateSnapshot.Delta, err = decompressSyncPayload(syncStateSnapshot.Compression, syncStateSnapshot.Delta)
+		if err != nil {
+			e.Cancel(fmt.Errorf("Error decompressing syncStateSnapshot in beforeSyncStateSnapshot: %s", err))
+			return
+		}
+	}
+	syncStateSnapshot.Compression = pb.SyncCompression_NONE
 
 	// Send the message onto the channel, allow for the fact that channel may be closed on send attempt.
 	defer func() {
@@ -556,45 +628,58 @@ func (d *Handler) beforeSyncStateSnapshot(e *fsm.Event) {
 	}
 }
 
-// sendBlocks sends the blocks based upon the supplied SyncBlockRange over the stream.
+// sendStateSnapshot sends the state snapshot over the stream in chunks of key-values, throttled to the configured bandwidth.
+// If the stream stops before being completed, the snapshot is retained for the remote peer to resume it.
 func (d *Handler) sendStateSnapshot(syncStateSnapshotRequest *pb.SyncStateSnapshotRequest) {
 	peerLogger.Debug("Sending state snapshot with correlationId = %d", syncStateSnapshotRequest.CorrelationId)
 
-	snapshot, err := d.Coordinator.GetStateSnapshot()
+	var peerName string
+	if d.ToPeerEndpoint != nil && d.ToPeerEndpoint.ID != nil {
+		peerName = d.ToPeerEndpoint.ID.Name
+	}
+	stream, sequence, err := retainedSnapshotStreams.open(peerName, syncStateSnapshotRequest, d.Coordinator.GetStateSnapshot, SyncStateSnapshotResumeWindow())
 	if err != nil {
 		peerLogger.Error(fmt.Sprintf("Error getting snapshot: %s", err))
 		return
 	}
-	defer snapshot.Release()
+	completed := false
+	defer func() {
+		if completed {
+			stream.release()
+		} else {
+			retainedSnapshotStreams.retain(peerName, stream, SyncStateSnapshotRetention())
+		}
+	}()
 
-	// Iterate over the state deltas and send to requestor
-	currBlockNumber := snapshot.GetBlockNumber()
-	var sequence uint64
-	// Loop through and send the Deltas
-	for i := 0; snapshot.Next(); i++ {
-		delta := statemgmt.NewStateDelta()
-		k, v := snapshot.GetRawKeyValue()
-		cID, kID := statemgmt.DecodeCompositeKey(k)
-		delta.Set(cID, kID, v, nil)
-
-		deltaAsBytes := delta.Marshal()
+	// Iterate over the chunks of the snapshot and send them to requestor
+	currBlockNumber := stream.blockNumber()
+	for ; ; sequence++ {
+		deltaBytes := stream.chunk(sequence, SyncStateSnapshotChunkSize())
+		if deltaBytes == nil {
+			break
+		}
+		deltaAsBytes, err := compressSyncPayload(d.syncCompression, deltaBytes)
+		if err != nil {
+			peerLogger.Error(fmt.Sprintf("Error compressing syncStateSnapsot for BlockNum = %d: %s", currBlockNumber, err))
+			return
+		}
 		// Encode a SyncStateSnapsot into the payload
-		sequence = uint64(i)
-		syncStateSnapshot := &pb.SyncStateSnapshot{Delta: deltaAsBytes, Sequence: sequence, BlockNumber: currBlockNumber, Request: syncStateSnapshotRequest}
+		syncStateSnapshot := &pb.SyncStateSnapshot{Delta: deltaAsBytes, Sequence: sequence, BlockNumber: currBlockNumber, Request: syncStateSnapshotRequest, Compression: d.syncCompression}
 
 		syncStateSnapshotBytes, err := proto.Marshal(syncStateSnapshot)
 		if err != nil {
 			peerLogger.Error(fmt.Sprintf("Error marshalling syncStateSnapsot for BlockNum = %d: %s", currBlockNumber, err))
-			break
+			return
 		}
+		snapshotThrottle.wait(len(syncStateSnapshotBytes), SyncStateSnapshotMaxBytesPerSecond())
 		if err := d.SendMessage(&pb.Message{Type: pb.Message_SYNC_STATE_SNAPSHOT, Payload: syncStateSnapshotBytes}); err != nil {
-			peerLogger.Error(fmt.Sprintf("Error sending syncStateSnapsot for BlockNum = %d: %s", currBlockNumber, err))
-			break
+			peerLogger.Error(fmt.Sprintf("Error sending syncStateSnapsot for BlockNum = %d, sequence = %d: %s", currBlockNumber, sequence, err))
+			return
 		}
 	}
 
 	// Now send the terminating message
-	syncStateSnapshot := &pb.SyncStateSnapshot{Delta: []byte{}, Sequence: sequence + 1, BlockNumber: currBlockNumber, Request: syncStateSnapshotRequest}
+	syncStateSnapshot := &pb.SyncStateSnapshot{Delta: []byte{}, Sequence: sequence, BlockNumber: currBlockNumber, Request: syncStateSnapshotRequest}
 	syncStateSnapshotBytes, err := proto.Marshal(syncStateSnapshot)
 	if err != nil {
 		peerLogger.Error(fmt.Sprintf("Error marshalling terminating syncStateSnapsot message for correlationId = %d, BlockNum = %d: %s", syncStateSnapshotRequest.CorrelationId, currBlockNumber, err))
@@ -604,7 +689,7 @@ func (d *Handler) sendStateSnapshot(syncStateSnapshotRequest *pb.SyncStateSnapsh
 		peerLogger.Error(fmt.Sprintf("Error sending terminating syncStateSnapsot for correlationId = %d, BlockNum = %d: %s", syncStateSnapshotRequest.CorrelationId, currBlockNumber, err))
 		return
 	}
-
+	completed = true
 }
 
 // ----------------------------------------------------------------------------
@@ -686,8 +771,12 @@ func (d *Handler) sendStateDeltas(syncStateDeltasRequest *pb.SyncStateDeltasRequ
 			break
 		}
 		// Encode a SyncStateDeltas into the payload
-		stateDeltaBytes := stateDelta.Marshal()
-		syncStateDeltas := &pb.SyncStateDeltas{Range: &pb.SyncBlockRange{Start: currBlockNum, End: currBlockNum, CorrelationId: syncBlockRange.CorrelationId}, Deltas: [][]byte{stateDeltaBytes}}
+		stateDeltaBytes, err := compressSyncPayload(d.syncCompression, stateDelta.Marshal())
+		if err != nil {
+			peerLogger.Error(fmt.Sprintf("Error compressing stateDelta for BlockNum = %d: %s", currBlockNum, err))
+			break
+		}
+		syncStateDeltas := &pb.SyncStateDeltas{Range: &pb.SyncBlockRange{Start: currBlockNum, End: currBlockNum, CorrelationId: syncBlockRange.CorrelationId}, Deltas: [][]byte{stateDeltaBytes}, Compression: d.syncCompression}
 		syncStateDeltasBytes, err := proto.Marshal(syncStateDeltas)
 		if err != nil {
 			peerLogger.Error(fmt.Sprintf("Error marshalling syncStateDeltas for BlockNum = %d: %s", currBlockNum, err))
@@ -714,6 +803,14 @@ func (d *Handler) beforeSyncStateDeltas(e *fsm.Event) {
 		e.Cancel(fmt.Errorf("Error unmarshalling SyncStateDeltas in beforeSyncStateDeltas: %s", err))
 		return
 	}
+	for i, delta := range syncStateDeltas.Deltas {
+		syncStateDeltas.Deltas[i], err = decompressSyncPayload(syncStateDeltas.Compression, delta)
+		if err != nil {
+			e.Cancel(fmt.Errorf("Error decompressing SyncStateDeltas in beforeSyncStateDeltas: %s", err))
+			return
+		}
+	}
+	syncStateDeltas.Compression = pb.SyncCompression_NONE
 	peerLogger.Debug("Sending state delta onto channel for start = %d and end = %d", syncStateDeltas.Range.Start, syncStateDeltas.Range.End)
 
 	// Send the message onto the channel, allow for the fact that channel may be closed on send attempt.
@@ -740,3 +837,274 @@ func (d *Handler) beforeSyncStateDeltas(e *fsm.Event) {
 	}
 
 }
+
+// ----------------------------------------------------------------------------
+//
+//  State sync Buckets functionality
+//
+//
+// ----------------------------------------------------------------------------
+
+// SyncStateProtocolVersion is the version of the state sync protocol supported by this peer, advertised
+// in the HelloMessage. Version 2 adds the transfer of the state by buckets, peers which do not advertise
+// a version only support the transfer of the whole state snapshot.
+const SyncStateProtocolVersion = 2
+
+// RemoteSyncStateVersion returns the version of the state sync protocol supported by the other PeerEndpoint
+func (d *Handler) RemoteSyncStateVersion() uint32 {
+	return d.remoteSyncStateVersion
+}
+
+// RequestStateBucketHashes requests the crypto-hashes of the buckets with the given numbers at the given level
+// of the bucket tree of the other PeerEndpoint, will provide them through the returned channel.
+// this will also stop writing any received SyncStateBucketHashes to channels created from Prior calls to RequestStateBucketHashes()
+func (d *Handler) RequestStateBucketHashes(level int, bucketNumbers []int) (<-chan *pb.SyncStateBucketHashes, error) {
+	if d.remoteSyncStateVersion < SyncStateProtocolVersion {
+		return nil, fmt.Errorf("Peer %s does not support the state transfer by buckets, its state sync protocol version is %d", d.ToPeerEndpoint, d.remoteSyncStateVersion)
+	}
+	d.bucketHashesRequestHandler.Lock()
+	defer d.bucketHashesRequestHandler.Unlock()
+	// Reset the handler
+	d.bucketHashesRequestHandler.reset()
+
+	syncStateBucketHashesRequest := d.bucketHashesRequestHandler.createRequest(level, bucketNumbers)
+	syncStateBucketHashesRequestBytes, err := proto.Marshal(syncStateBucketHashesRequest)
+	if err != nil {
+		return nil, fmt.Errorf("Error marshaling syncStateBucketHashesRequest during RequestStateBucketHashes: %s", err)
+	}
+	peerLogger.Debug("Sending %s with correlationId = %d for %d buckets at level %d", pb.Message_SYNC_STATE_GET_BUCKET_HASHES, syncStateBucketHashesRequest.CorrelationId, len(bucketNumbers), level)
+	if err := d.SendMessage(&pb.Message{Type: pb.Message_SYNC_STATE_GET_BUCKET_HASHES, Payload: syncStateBucketHashesRequestBytes}); err != nil {
+		return nil, fmt.Errorf("Error sending %s during RequestStateBucketHashes: %s", pb.Message_SYNC_STATE_GET_BUCKET_HASHES, err)
+	}
+
+	return d.bucketHashesRequestHandler.channel, nil
+}
+
+// beforeSyncStateGetBucketHashes triggers the sending of the requested bucket hashes to remote Peer.
+func (d *Handler) beforeSyncStateGetBucketHashes(e *fsm.Event) {
+	peerLogger.Debug("Received message: %s", e.Event)
+	msg, ok := e.Args[0].(*pb.Message)
+	if !ok {
+		e.Cancel(fmt.Errorf("Received unexpected message type"))
+		return
+	}
+	syncStateBucketHashesRequest := &pb.SyncStateBucketHashesRequest{}
+	err := proto.Unmarshal(msg.Payload, syncStateBucketHashesRequest)
+	if err != nil {
+		e.Cancel(fmt.Errorf("Error unmarshalling SyncStateBucketHashesRequest in beforeSyncStateGetBucketHashes: %s", err))
+		return
+	}
+
+	go d.sendStateBucketHashes(syncStateBucketHashesRequest)
+}
+
+// sendStateBucketHashes sends the crypto-hashes of the requested buckets, along with the shape of the bucket tree.
+// The error of the reply is set if they cannot be computed, so that the requestor does not have to wait for a timeout.
+func (d *Handler) sendStateBucketHashes(syncStateBucketHashesRequest *pb.SyncStateBucketHashesRequest) {
+	syncStateBucketHashes := &pb.SyncStateBucketHashes{Request: syncStateBucketHashesRequest}
+	shape, err := d.Coordinator.GetStateBucketTreeShape()
+	if err == nil {
+		syncStateBucketHashes.Shape = &pb.SyncBucketTreeShape{NumBuckets: uint32(shape.NumBuckets), MaxGroupingAtEachLevel: uint32(shape.MaxGroupingAtEachLevel), BucketHashFunction: shape.BucketHashFunction}
+		syncStateBucketHashes.BlockNumber, syncStateBucketHashes.Hashes, err = d.Coordinator.GetStateBucketHashes(int(syncStateBucketHashesRequest.Level), toIntSlice(syncStateBucketHashesRequest.BucketNumbers))
+	}
+	if err != nil {
+		peerLogger.Error(fmt.Sprintf("Error getting bucket hashes for correlationId = %d: %s", syncStateBucketHashesRequest.CorrelationId, err))
+		syncStateBucketHashes.Error = err.Error()
+		syncStateBucketHashes.Hashes = nil
+	}
+	syncStateBucketHashesBytes, err := proto.Marshal(syncStateBucketHashes)
+	if err != nil {
+		peerLogger.Error(fmt.Sprintf("Error marshalling syncStateBucketHashes for correlationId = %d: %s", syncStateBucketHashesRequest.CorrelationId, err))
+		return
+	}
+	if err := d.SendMessage(&pb.Message{Type: pb.Message_SYNC_STATE_BUCKET_HASHES, Payload: syncStateBucketHashesBytes}); err != nil {
+		peerLogger.Error(fmt.Sprintf("Error sending syncStateBucketHashes for correlationId = %d: %s", syncStateBucketHashesRequest.CorrelationId, err))
+	}
+}
+
+// beforeSyncStateBucketHashes will write the bucket hashes to the respective channel.
+func (d *Handler) beforeSyncStateBucketHashes(e *fsm.Event) {
+	peerLogger.Debug("Received message: %s", e.Event)
+	msg, ok := e.Args[0].(*pb.Message)
+	if !ok {
+		e.Cancel(fmt.Errorf("Received unexpected message type"))
+		return
+	}
+	syncStateBucketHashes := &pb.SyncStateBucketHashes{}
+	err := proto.Unmarshal(msg.Payload, syncStateBucketHashes)
+	if err != nil {
+		e.Cancel(fmt.Errorf("Error unmarshalling SyncStateBucketHashes in beforeSyncStateBucketHashes: %s", err))
+		return
+	}
+	if syncStateBucketHashes.Request == nil {
+		e.Cancel(fmt.Errorf("Received SyncStateBucketHashes without request in beforeSyncStateBucketHashes"))
+		return
+	}
+
+	// Send the message onto the channel, allow for the fact that channel may be closed on send attempt.
+	defer func() {
+		if x := recover(); x != nil {
+			peerLogger.Error(fmt.Sprintf("Error sending syncStateBucketHashes to channel: %v", x))
+		}
+	}()
+	// Use non-blocking send, will WARN if missed message.
+	d.bucketHashesRequestHandler.Lock()
+	defer d.bucketHashesRequestHandler.Unlock()
+	if d.bucketHashesRequestHandler.shouldHandle(syncStateBucketHashes.Request.CorrelationId) {
+		select {
+		case d.bucketHashesRequestHandler.channel <- syncStateBucketHashes:
+		default:
+			peerLogger.Warning("Did NOT send SyncStateBucketHashes message to channel for correlationId = %d", syncStateBucketHashes.Request.CorrelationId)
+		}
+	} else {
+		//Ignore the message, does not match the current correlationId
+		peerLogger.Warning("Ignoring SyncStateBucketHashes message with correlationId = %d, as current correlationId = %d", syncStateBucketHashes.Request.CorrelationId, d.bucketHashesRequestHandler.correlationID)
+	}
+}
+
+// RequestStateBuckets requests the key-values of the buckets with the given numbers at the lowest level of
+// the bucket tree of the other PeerEndpoint, will provide them through the returned channel.
+// this will also stop writing any received SyncStateBuckets to channels created from Prior calls to RequestStateBuckets()
+func (d *Handler) RequestStateBuckets(bucketNumbers []int) (<-chan *pb.SyncStateBuckets, error) {
+	if d.remoteSyncStateVersion < SyncStateProtocolVersion {
+		return nil, fmt.Errorf("Peer %s does not support the state transfer by buckets, its state sync protocol version is %d", d.ToPeerEndpoint, d.remoteSyncStateVersion)
+	}
+	d.bucketsRequestHandler.Lock()
+	defer d.bucketsRequestHandler.Unlock()
+	// Reset the handler
+	d.bucketsRequestHandler.reset()
+
+	syncStateBucketsRequest := d.bucketsRequestHandler.createRequest(bucketNumbers)
+	syncStateBucketsRequestBytes, err := proto.Marshal(syncStateBucketsRequest)
+	if err != nil {
+		return nil, fmt.Errorf("Error marshaling syncStateBucketsRequest during RequestStateBuckets: %s", err)
+	}
+	peerLogger.Debug("Sending %s with correlationId = %d for %d buckets", pb.Message_SYNC_STATE_GET_BUCKETS, syncStateBucketsRequest.CorrelationId, len(bucketNumbers))
+	if err := d.SendMessage(&pb.Message{Type: pb.Message_SYNC_STATE_GET_BUCKETS, Payload: syncStateBucketsRequestBytes}); err != nil {
+		return nil, fmt.Errorf("Error sending %s during RequestStateBuckets: %s", pb.Message_SYNC_STATE_GET_BUCKETS, err)
+	}
+
+	return d.bucketsRequestHandler.channel, nil
+}
+
+// beforeSyncStateGetBuckets triggers the sending of the key-values of the requested buckets to remote Peer.
+func (d *Handler) beforeSyncStateGetBuckets(e *fsm.Event) {
+	peerLogger.Debug("Received message: %s", e.Event)
+	msg, ok := e.Args[0].(*pb.Message)
+	if !ok {
+		e.Cancel(fmt.Errorf("Received unexpected message type"))
+		return
+	}
+	syncStateBucketsRequest := &pb.SyncStateBucketsRequest{}
+	err := proto.Unmarshal(msg.Payload, syncStateBucketsRequest)
+	if err != nil {
+		e.Cancel(fmt.Errorf("Error unmarshalling SyncStateBucketsRequest in beforeSyncStateGetBuckets: %s", err))
+		return
+	}
+
+	go d.sendStateBuckets(syncStateBucketsRequest)
+}
+
+// sendStateBuckets streams the key-values of the requested buckets, like sendStateSnapshot does for the whole state.
+// The error of the terminating message is set if the key-values cannot be read.
+func (d *Handler) sendStateBuckets(syncStateBucketsRequest *pb.SyncStateBucketsRequest) {
+	peerLogger.Debug("Sending state buckets with correlationId = %d", syncStateBucketsRequest.CorrelationId)
+
+	var currBlockNumber uint64
+	var sequence uint64
+	var sendErr error
+	snapshot, err := d.Coordinator.GetStateBucketsSnapshot(toIntSlice(syncStateBucketsRequest.BucketNumbers))
+	if err == nil {
+		defer snapshot.Release()
+		currBlockNumber = snapshot.GetBlockNumber()
+		for ; snapshot.Next(); sequence++ {
+			delta := statemgmt.NewStateDelta()
+			k, v := snapshot.GetRawKeyValue()
+			cID, kID := statemgmt.DecodeCompositeKey(k)
+			delta.Set(cID, kID, v, nil)
+
+			deltaAsBytes, err := compressSyncPayload(d.syncCompression, delta.Marshal())
+			if err != nil {
+				sendErr = fmt.Errorf("Error compressing syncStateBuckets for BlockNum = %d: %s", currBlockNumber, err)
+				break
+			}
+			syncStateBuckets := &pb.SyncStateBuckets{Request: syncStateBucketsRequest, BlockNumber: currBlockNumber, Sequence: sequence, Delta: deltaAsBytes, Compression: d.syncCompression}
+			syncStateBucketsBytes, err := proto.Marshal(syncStateBuckets)
+			if err != nil {
+				sendErr = fmt.Errorf("Error marshalling syncStateBuckets for BlockNum = %d: %s", currBlockNumber, err)
+				break
+			}
+			if err := d.SendMessage(&pb.Message{Type: pb.Message_SYNC_STATE_BUCKETS, Payload: syncStateBucketsBytes}); err != nil {
+				peerLogger.Error(fmt.Sprintf("Error sending syncStateBuckets for BlockNum = %d: %s", currBlockNumber, err))
+				return
+			}
+		}
+	} else {
+		sendErr = fmt.Errorf("Error getting buckets snapshot: %s", err)
+	}
+
+	// Now send the terminating message
+	syncStateBuckets := &pb.SyncStateBuckets{Request: syncStateBucketsRequest, BlockNumber: currBlockNumber, Sequence: sequence, Delta: []byte{}}
+	if sendErr != nil {
+		peerLogger.Error(sendErr.Error())
+		syncStateBuckets.Error = sendErr.Error()
+	}
+	syncStateBucketsBytes, err := proto.Marshal(syncStateBuckets)
+	if err != nil {
+		peerLogger.Error(fmt.Sprintf("Error marshalling terminating syncStateBuckets message for correlationId = %d, BlockNum = %d: %s", syncStateBucketsRequest.CorrelationId, currBlockNumber, err))
+		return
+	}
+	if err := d.SendMessage(&pb.Message{Type: pb.Message_SYNC_STATE_BUCKETS, Payload: syncStateBucketsBytes}); err != nil {
+		peerLogger.Error(fmt.Sprintf("Error sending terminating syncStateBuckets for correlationId = %d, BlockNum = %d: %s", syncStateBucketsRequest.CorrelationId, currBlockNumber, err))
+	}
+}
+
+// beforeSyncStateBuckets will write the key-values of the buckets to the respective channel.
+func (d *Handler) beforeSyncStateBuckets(e *fsm.Event) {
+	peerLogger.Debug("Received message: %s", e.Event)
+	msg, ok := e.Args[0].(*pb.Message)
+	if !ok {
+		e.Cancel(fmt.Errorf("Received unexpected message type"))
+		return
+	}
+	syncStateBuckets := &pb.SyncStateBuckets{}
+	err := proto.Unmarshal(msg.Payload, syncStateBuckets)
+	if err != nil {
+		e.Cancel(fmt.Errorf("Error unmarshalling SyncStateBuckets in beforeSyncStateBuckets: %s", err))
+		return
+	}
+	if syncStateBuckets.Request == nil {
+		e.Cancel(fmt.Errorf("Received SyncStateBuckets without request in beforeSyncStateBuckets"))
+		return
+	}
+	if len(syncStateBuckets.Delta) > 0 {
+		syncStateBuckets.Delta, err = decompressSyncPayload(syncStateBuckets.Compression, syncStateBuckets.Delta)
+		if err != nil {
+			e.Cancel(fmt.Errorf("Error decompressing SyncStateBuckets in beforeSyncStateBuckets: %s", err))
+			return
+		}
+	}
+	syncStateBuckets.Compression = pb.SyncCompression_NONE
+
+	// Send the message onto the channel, allow for the fact that channel may be closed on send attempt.
+	defer func() {
+		if x := recover(); x != nil {
+			peerLogger.Error(fmt.Sprintf("Error sending syncStateBuckets to channel: %v", x))
+		}
+	}()
+	// Use non-blocking send, will WARN and close channel if missed message.
+	d.bucketsRequestHandler.Lock()
+	defer d.bucketsRequestHandler.Unlock()
+	if d.bucketsRequestHandler.shouldHandle(syncStateBuckets.Request.CorrelationId) {
+		select {
+		case d.bucketsRequestHandler.channel <- syncStateBuckets:
+		default:
+			// Was not able to write to the channel, in which case the stream is incomplete, and must be discarded, closing the channel
+			peerLogger.Warning("Did NOT send SyncStateBuckets message to channel for correlationId = %d, sequence = %d, closing channel as the message has been discarded", syncStateBuckets.Request.CorrelationId, syncStateBuckets.Sequence)
+			d.bucketsRequestHandler.reset()
+		}
+	} else {
+		//Ignore the message, does not match the current correlationId
+		peerLogger.Warning("Ignoring SyncStateBuckets message with correlationId = %d, sequence = %d, as current correlationId = %d", syncStateBuckets.Request.CorrelationId, syncStateBuckets.Sequence, d.bucketsRequestHandler.correlationID)
+	}
+}
@@ -119,3 +119,78 @@ func newSyncStateDeltasHandler() *syncStateDeltasHandler {
 	ssdh.reset()
 	return ssdh
 }
+
+//-----------------------------------------------------------------------------
+//
+// Sync State Bucket Hashes Handler
+//
+//-----------------------------------------------------------------------------
+
+type syncStateBucketHashesHandler struct {
+	syncHandler
+	channel chan *pb.SyncStateBucketHashes
+}
+
+func (sbhh *syncStateBucketHashesHandler) reset() {
+	if sbhh.channel != nil {
+		close(sbhh.channel)
+	}
+	// A single message is expected in reply to each request
+	sbhh.channel = make(chan *pb.SyncStateBucketHashes, 1)
+	sbhh.correlationID++
+}
+
+func (sbhh *syncStateBucketHashesHandler) createRequest(level int, bucketNumbers []int) *pb.SyncStateBucketHashesRequest {
+	return &pb.SyncStateBucketHashesRequest{CorrelationId: sbhh.correlationID, Level: uint32(level), BucketNumbers: toUint32Slice(bucketNumbers)}
+}
+
+func newSyncStateBucketHashesHandler() *syncStateBucketHashesHandler {
+	sbhh := &syncStateBucketHashesHandler{}
+	sbhh.reset()
+	return sbhh
+}
+
+//-----------------------------------------------------------------------------
+//
+// Sync State Buckets Handler
+//
+//-----------------------------------------------------------------------------
+
+type syncStateBucketsHandler struct {
+	syncHandler
+	channel chan *pb.SyncStateBuckets
+}
+
+func (sbh *syncStateBucketsHandler) reset() {
+	if sbh.channel != nil {
+		close(sbh.channel)
+	}
+	sbh.channel = make(chan *pb.SyncStateBuckets, SyncStateBucketsChannelSize())
+	sbh.correlationID++
+}
+
+func (sbh *syncStateBucketsHandler) createRequest(bucketNumbers []int) *pb.SyncStateBucketsRequest {
+	return &pb.SyncStateBucketsRequest{CorrelationId: sbh.correlationID, BucketNumbers: toUint32Slice(bucketNumbers)}
+}
+
+func newSyncStateBucketsHandler() *syncStateBucketsHandler {
+	sbh := &syncStateBucketsHandler{}
+	sbh.reset()
+	return sbh
+}
+
+func toUint32Slice(numbers []int) []uint32 {
+	converted := make([]uint32, len(numbers))
+	for i, n := range numbers {
+		converted[i] = uint32(n)
+	}
+	return converted
+}
+
+func toIntSlice(numbers []uint32) []int {
+	converted := make([]int, len(numbers))
+	for i, n := range numbers {
+		converted[i] = int(n)
+	}
+	return converted
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package peer
 
 import (
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
@@ -27,6 +28,7 @@ import (
 	"golang.org/x/net/context"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/transport"
 
 	"github.com/golang/protobuf/proto"
 	"github.com/op/go-logging"
@@ -58,6 +60,20 @@ type StateRetriever interface {
 	RequestStateDeltas(syncBlockRange *pb.SyncBlockRange) (<-chan *pb.SyncStateDeltas, error)
 }
 
+// BucketStateRetriever interface for retrieving the state by buckets, implemented by the
+// handlers of peers that support version 2 of the state sync protocol (see SyncStateProtocolVersion)
+type BucketStateRetriever interface {
+	RemoteSyncStateVersion() uint32
+	RequestStateBucketHashes(level int, bucketNumbers []int) (<-chan *pb.SyncStateBucketHashes, error)
+	RequestStateBuckets(bucketNumbers []int) (<-chan *pb.SyncStateBuckets, error)
+}
+
+// StateSnapshotResumer interface for resuming the stream of a state snapshot after a dropped
+// connection, from the chunk with the given sequence
+type StateSnapshotResumer interface {
+	ResumeStateSnapshot(blockNumber, sequence uint64) (<-chan *pb.SyncStateSnapshot, error)
+}
+
 // RemoteLedger interface for retrieving remote ledger data.
 type RemoteLedger interface {
 	BlocksRetriever
@@ -77,6 +93,7 @@ type BlockChainModifier interface {
 	RollbackStateDelta(id interface{}) error
 	CommitStateDelta(id interface{}) error
 	EmptyState() error
+	RewindState(stateBlockNumber, blockNumber uint64) error
 	PutBlock(blockNumber uint64, block *pb.Block) error
 }
 
@@ -92,6 +109,16 @@ type StateAccessor interface {
 	GetStateDelta(blockNumber uint64) (*statemgmt.StateDelta, error)
 }
 
+// BucketStateAccessor interface for retrieving the state organized in buckets and for
+// recording the progress of a state transfer by buckets
+type BucketStateAccessor interface {
+	GetStateBucketTreeShape() (*statemgmt.BucketTreeShape, error)
+	GetStateBucketHashes(level int, bucketNumbers []int) (uint64, [][]byte, error)
+	GetStateBucketsSnapshot(bucketNumbers []int) (*state.StateSnapshot, error)
+	GetStateTransferCursor() (*ledger.StateTransferCursor, error)
+	SetStateTransferCursor(cursor *ledger.StateTransferCursor) error
+}
+
 // MessageHandler standard interface for handling Openchain messages.
 type MessageHandler interface {
 	RemoteLedger
@@ -109,6 +136,7 @@ type MessageHandlerCoordinator interface {
 	BlockChainModifier
 	BlockChainUtil
 	StateAccessor
+	BucketStateAccessor
 	RegisterHandler(messageHandler MessageHandler) error
 	DeregisterHandler(messageHandler MessageHandler) error
 	Broadcast(*pb.Message, pb.PeerEndpoint_Type) []error
@@ -117,6 +145,15 @@ type MessageHandlerCoordinator interface {
 	GetRemoteLedger(receiver *pb.PeerID) (RemoteLedger, error)
 	PeersDiscovered(*pb.PeersMessage) error
 	ExecuteTransaction(transaction *pb.Transaction) *pb.Response
+	ExecuteQuery(transaction *pb.Transaction) *pb.QueryResponse
+	ExecuteSimulation(transaction *pb.Transaction) (*pb.SimulationResult, error)
+	Gossiper
+}
+
+// Gossiper interface for spreading the committed blocks among the non-validating peers
+type Gossiper interface {
+	GossipBlock(blockNumber uint64) error
+	HandleGossipMessage(msg *pb.Message, sender *pb.PeerID) error
 }
 
 // ChatStream interface supported by stream between Peers
@@ -186,11 +223,14 @@ type PeerImpl struct {
 	secHelper      crypto.Peer
 	engine         Engine
 	isValidator    bool
+	gossiper       *gossiper // nil unless the gossip of the blocks is enabled
+	discovery      *discoveryList
 }
 
 // TransactionProccesor responsible for processing of Transactions
 type TransactionProccesor interface {
 	ProcessTransactionMsg(*pb.Message, *pb.Transaction) *pb.Response
+	ProcessSimulation(*pb.Transaction) (*pb.SimulationResult, error)
 }
 
 // Engine Responsible for managing Peer network communications (Handlers) and processing of Transactions
@@ -224,7 +264,11 @@ func NewPeerWithHandler(secHelperFunc func() crypto.Peer, handlerFact HandlerFac
 		return nil, fmt.Errorf("Error constructing NewPeerWithHandler: %s", err)
 	}
 	peer.ledgerWrapper = &ledgerWrapper{ledger: ledgerPtr}
-	go peer.chatWithPeer(viper.GetString("peer.discovery.rootnode"))
+	if peer.discovery, err = newDiscoveryListFromConfig(ledgerPtr); err != nil {
+		return nil, err
+	}
+	peer.startGossip()
+	peer.startDiscovery()
 	return peer, nil
 }
 
@@ -249,6 +293,9 @@ func NewPeerWithEngine(secHelperFunc func() crypto.Peer, engFactory EngineFactor
 		return nil, fmt.Errorf("Error constructing NewPeerWithHandler: %s", err)
 	}
 	peer.ledgerWrapper = &ledgerWrapper{ledger: ledgerPtr}
+	if peer.discovery, err = newDiscoveryListFromConfig(ledgerPtr); err != nil {
+		return nil, err
+	}
 
 	peer.engine, err = engFactory(peer)
 	if err != nil {
@@ -259,13 +306,159 @@ func NewPeerWithEngine(secHelperFunc func() crypto.Peer, engFactory EngineFactor
 		return nil, errors.New("Cannot supply nil handler factory")
 	}
 
-	go peer.chatWithPeer(viper.GetString("peer.discovery.rootnode"))
+	peer.startGossip()
+	peer.startDiscovery()
 	return peer, nil
 }
 
+// startDiscovery connects to the root node and to the peers of the discovery list, which
+// holds the peers this peer was connected to before a restart
+func (p *PeerImpl) startDiscovery() {
+	addresses := p.discovery.list().Addresses
+	if rootNode := viper.GetString("peer.discovery.rootnode"); rootNode != "" || len(addresses) == 0 {
+		addresses = append(addresses, rootNode)
+	}
+	for _, address := range addresses {
+		go p.chatWithPeer(address)
+	}
+}
+
+// GetDiscoveryList returns the addresses of the peers this peer reconnects to and of the
+// ones it bans
+func (p *PeerImpl) GetDiscoveryList() *pb.DiscoveryList {
+	return p.discovery.list()
+}
+
+// AddPeer adds the address of a peer to the discovery list, lifting its ban if any, and
+// connects to it
+func (p *PeerImpl) AddPeer(address string) error {
+	if err := validatePeerAddress(address); err != nil {
+		return err
+	}
+	if err := p.discovery.unban(address); err != nil {
+		return err
+	}
+	if _, err := p.discovery.add(address); err != nil {
+		return err
+	}
+	go p.chatWithPeer(address)
+	return nil
+}
+
+// RemovePeer removes the address of a peer from the discovery list, this peer no longer
+// reconnects to it. The peer is added back if it is discovered again
+func (p *PeerImpl) RemovePeer(address string) error {
+	return p.discovery.remove(address)
+}
+
+// BanPeer removes the address of a peer from the discovery list and bans it, this peer
+// disconnects from it on the next message it receives from it and refuses its connections.
+// The ban extends to the identity of the peer (see getPeerIdentity) once it is known, the
+// host of the address right away when security is disabled, so that the peer can't come
+// back under another address
+func (p *PeerImpl) BanPeer(address string) error {
+	if err := validatePeerAddress(address); err != nil {
+		return err
+	}
+	if err := p.discovery.ban(address); err != nil {
+		return err
+	}
+	if identity := getPeerIdentity(nil, address); identity != "" {
+		if err := p.discovery.banIdentity(identity, address); err != nil {
+			return err
+		}
+	}
+	p.handlerMap.RLock()
+	defer p.handlerMap.RUnlock()
+	for _, handler := range p.handlerMap.m {
+		if peerEndpoint, err := handler.To(); err == nil && peerEndpoint.Address == address {
+			if identity := getPeerIdentity(&peerEndpoint, ""); identity != "" {
+				if err := p.discovery.banIdentity(identity, address); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// getPeerIdentity returns the identity a ban of a peer applies to besides the address it
+// advertises: the PKI-ID of its endpoint, which the signature of its HELLO proves, when
+// security is enabled, and the host of remoteAddress, the address of its connection,
+// otherwise. Empty if unknown
+func getPeerIdentity(peerEndpoint *pb.PeerEndpoint, remoteAddress string) string {
+	if SecurityEnabled() {
+		if peerEndpoint == nil || len(peerEndpoint.PkiID) == 0 {
+			return ""
+		}
+		return "pkiID:" + hex.EncodeToString(peerEndpoint.PkiID)
+	}
+	host, _, err := net.SplitHostPort(remoteAddress)
+	if err != nil || host == "" {
+		return ""
+	}
+	return "host:" + host
+}
+
+// isBanned returns true if the peer is banned, by the address its endpoint advertises or by
+// its identity. When the endpoint is verified, a peer banned by its address gets banned by
+// its identity too so that it can't come back under another address
+func (p *PeerImpl) isBanned(peerEndpoint *pb.PeerEndpoint, remoteAddress string, verified bool) bool {
+	identity := getPeerIdentity(peerEndpoint, remoteAddress)
+	if peerEndpoint != nil && p.discovery.isBanned(peerEndpoint.Address) {
+		if verified && identity != "" {
+			if err := p.discovery.banIdentity(identity, peerEndpoint.Address); err != nil {
+				peerLogger.Warning("Error banning the identity of peer address=%s: %s", peerEndpoint.Address, err)
+			}
+		}
+		return true
+	}
+	return identity != "" && p.discovery.isBannedIdentity(identity)
+}
+
+// startGossip sets up the gossip of the blocks, the non-validating peers running the
+// anti-entropy of their blockchain with the other peers
+func (p *PeerImpl) startGossip() {
+	var auth gossipAuthenticator
+	if SecurityEnabled() {
+		auth = &secHelperAuthenticator{p.secHelper}
+	}
+	if p.gossiper = newGossiperFromConfig(p, auth); p.gossiper != nil && p.gossiper.relay {
+		p.gossiper.start(viper.GetDuration("peer.gossip.antiEntropy.period"))
+	}
+}
+
+// GossipBlock sends a block this validating peer committed to some of the non-validating
+// peers, which relay it to the others
+func (p *PeerImpl) GossipBlock(blockNumber uint64) error {
+	if p.gossiper == nil {
+		return nil
+	}
+	return p.gossiper.gossipBlock(blockNumber)
+}
+
+// HandleGossipMessage handles a GOSSIP_BLOCK or GOSSIP_DIGEST message received from sender
+func (p *PeerImpl) HandleGossipMessage(msg *pb.Message, sender *pb.PeerID) error {
+	if p.gossiper == nil {
+		return nil
+	}
+	return p.gossiper.handleMessage(msg, sender)
+}
+
 // Chat implementation of the the Chat bidi streaming RPC function
 func (p *PeerImpl) Chat(stream pb.Peer_ChatServer) error {
-	return p.handleChat(stream.Context(), stream, false)
+	return p.handleChat(stream.Context(), stream, false, getRemoteAddress(stream.Context()))
+}
+
+// getRemoteAddress returns the address the client of the stream of ctx connects from, empty
+// if unknown
+func getRemoteAddress(ctx context.Context) string {
+	if stream, ok := transport.StreamFromContext(ctx); ok && stream.ServerTransport() != nil {
+		if addr := stream.ServerTransport().RemoteAddr(); addr != nil {
+			return addr.String()
+		}
+	}
+	return ""
 }
 
 // ProcessTransaction implementation of the ProcessTransaction RPC function
@@ -287,6 +480,45 @@ func (p *PeerImpl) ProcessTransaction(ctx context.Context, tx *pb.Transaction) (
 	return p.ExecuteTransaction(tx), err
 }
 
+// ProcessQuery implementation of the ProcessQuery RPC function. Only
+// CHAINCODE_QUERY transactions are accepted, they are executed against the
+// committed state without going through consensus.
+func (p *PeerImpl) ProcessQuery(ctx context.Context, tx *pb.Transaction) (*pb.QueryResponse, error) {
+	peerLogger.Debug("ProcessQuery processing query uuid = %s", tx.Uuid)
+	if tx.Type != pb.Transaction_CHAINCODE_QUERY {
+		return nil, fmt.Errorf("ProcessQuery only accepts queries, got %s", tx.Type)
+	}
+	if p.isValidator {
+		// Verify transaction signature if security is enabled
+		if secHelper := p.secHelper; nil != secHelper {
+			peerLogger.Debug("Verifying query signature %s", tx.Uuid)
+			var err error
+			if tx, err = secHelper.TransactionPreValidation(tx); err != nil {
+				peerLogger.Error("ProcessQuery failed to verify query %v", err)
+				return &pb.QueryResponse{Uuid: tx.Uuid, Response: &pb.Response{Status: pb.Response_FAILURE, Msg: []byte(err.Error())}}, nil
+			}
+		}
+	}
+	return p.ExecuteQuery(tx), nil
+}
+
+// SimulateTransaction implementation of the SimulateTransaction RPC function. The invoke
+// transaction is simulated against the committed state and never submitted.
+func (p *PeerImpl) SimulateTransaction(ctx context.Context, tx *pb.Transaction) (*pb.SimulationResult, error) {
+	peerLogger.Debug("SimulateTransaction processing transaction uuid = %s", tx.Uuid)
+	if p.isValidator {
+		// Verify transaction signature if security is enabled
+		if secHelper := p.secHelper; nil != secHelper {
+			var err error
+			if tx, err = secHelper.TransactionPreValidation(tx); err != nil {
+				peerLogger.Error("SimulateTransaction failed to verify transaction %v", err)
+				return nil, err
+			}
+		}
+	}
+	return p.ExecuteSimulation(tx)
+}
+
 // GetPeers returns the currently registered PeerEndpoints
 func (p *PeerImpl) GetPeers() (*pb.PeersMessage, error) {
 	p.handlerMap.RLock()
@@ -303,6 +535,25 @@ func (p *PeerImpl) GetPeers() (*pb.PeersMessage, error) {
 	return peersMessage, nil
 }
 
+// CheckValidatorConnections returns an error if the peer is connected to fewer than min
+// validating peers, below which it cannot take part in, or follow, the consensus
+func (p *PeerImpl) CheckValidatorConnections(min int) error {
+	peersMessage, err := p.GetPeers()
+	if err != nil {
+		return err
+	}
+	validators := 0
+	for _, peerEndpoint := range peersMessage.Peers {
+		if peerEndpoint.Type == pb.PeerEndpoint_VALIDATOR {
+			validators++
+		}
+	}
+	if validators < min {
+		return fmt.Errorf("Connected to %d validating peers, expected at least %d", validators, min)
+	}
+	return nil
+}
+
 // GetRemoteLedger returns the RemoteLedger interface for the remote Peer Endpoint
 func (p *PeerImpl) GetRemoteLedger(receiverHandle *pb.PeerID) (RemoteLedger, error) {
 	p.handlerMap.RLock()
@@ -326,6 +577,8 @@ func (p *PeerImpl) PeersDiscovered(peersMessage *pb.PeersMessage) error {
 		// Filter out THIS Peer's endpoint
 		if *getHandlerKeyFromPeerEndpoint(thisPeersEndpoint) == *getHandlerKeyFromPeerEndpoint(peerEndpoint) {
 			// NOOP
+		} else if p.isBanned(peerEndpoint, "", false) {
+			peerLogger.Debug("Ignoring banned peer address: %s", peerEndpoint.Address)
 		} else if _, ok := p.handlerMap.m[*getHandlerKeyFromPeerEndpoint(peerEndpoint)]; ok == false {
 			// Start chat with Peer
 			go p.chatWithPeer(peerEndpoint.Address)
@@ -352,6 +605,9 @@ func (p *PeerImpl) RegisterHandler(messageHandler MessageHandler) error {
 	if err != nil {
 		return fmt.Errorf("Error registering handler: %s", err)
 	}
+	if peerEndpoint, _ := messageHandler.To(); p.isBanned(&peerEndpoint, "", true) {
+		return fmt.Errorf("Error registering handler, peer address=%s is banned", peerEndpoint.Address)
+	}
 	p.handlerMap.Lock()
 	defer p.handlerMap.Unlock()
 	if _, ok := p.handlerMap.m[*key]; ok == true {
@@ -477,6 +733,22 @@ func (p *PeerImpl) SendTransactionsToPeer(peerAddress string, transaction *pb.Tr
 	return response
 }
 
+// sendQueryToPeer forwards a read-only query to the specified peer address.
+func (p *PeerImpl) sendQueryToPeer(peerAddress string, transaction *pb.Transaction) *pb.QueryResponse {
+	conn, err := NewPeerClientConnectionWithAddress(peerAddress)
+	if err != nil {
+		return &pb.QueryResponse{Uuid: transaction.Uuid, Response: &pb.Response{Status: pb.Response_FAILURE, Msg: []byte(fmt.Sprintf("Error creating client to peer address=%s:  %s", peerAddress, err))}}
+	}
+	defer conn.Close()
+	serverClient := pb.NewPeerClient(conn)
+	peerLogger.Debug("Sending query to Peer: %s", peerAddress)
+	response, err := serverClient.ProcessQuery(context.Background(), transaction)
+	if err != nil {
+		return &pb.QueryResponse{Uuid: transaction.Uuid, Response: &pb.Response{Status: pb.Response_FAILURE, Msg: []byte(fmt.Sprintf("Error calling ProcessQuery on remote peer at address=%s:  %s", peerAddress, err))}}
+	}
+	return response
+}
+
 // sendTransactionsToLocalEngine send the transaction to the local engine (This Peer is a validator)
 func (p *PeerImpl) sendTransactionsToLocalEngine(transaction *pb.Transaction) *pb.Response {
 
@@ -494,13 +766,32 @@ func (p *PeerImpl) sendTransactionsToLocalEngine(transaction *pb.Transaction) *p
 	return response
 }
 
+// chatWithPeer connects to the peer at peerAddress, adding it to the discovery list, and
+// reconnects to it whenever the chat ends until it is removed from the list. The attempts
+// are spaced by an exponential backoff
 func (p *PeerImpl) chatWithPeer(peerAddress string) error {
 	if len(peerAddress) == 0 {
 		peerLogger.Debug("Starting up the first peer")
 		return nil // nothing to do
 	}
+	if !p.discovery.startConnecting(peerAddress) {
+		peerLogger.Debug("Already chatting with peer address: %s", peerAddress)
+		return nil
+	}
+	defer p.discovery.stopConnecting(peerAddress)
+	if added, err := p.discovery.add(peerAddress); err != nil {
+		peerLogger.Warning("Error adding peer address=%s to the discovery list: %s", peerAddress, err)
+	} else if !added {
+		peerLogger.Debug("Not chatting with banned peer address: %s", peerAddress)
+		return nil
+	}
+	backoff := newReconnectBackoffFromConfig()
 	for {
-		time.Sleep(1 * time.Second)
+		time.Sleep(backoff.next())
+		if !p.discovery.contains(peerAddress) {
+			peerLogger.Info("Peer address=%s is no longer in the discovery list, stopped chatting with it", peerAddress)
+			return nil
+		}
 		peerLogger.Debug("Initiating Chat with peer address: %s", peerAddress)
 		conn, err := NewPeerClientConnectionWithAddress(peerAddress)
 		if err != nil {
@@ -514,24 +805,28 @@ func (p *PeerImpl) chatWithPeer(peerAddress string) error {
 		if err != nil {
 			e := fmt.Errorf("Error establishing chat with peer address=%s:  %s", peerAddress, err)
 			peerLogger.Error(fmt.Sprintf("%s", e.Error()))
+			conn.Close()
 			continue
 		}
 		peerLogger.Debug("Established Chat with peer address: %s", peerAddress)
-		err = p.handleChat(ctx, stream, true)
+		backoff.reset()
+		err = p.handleChat(ctx, stream, true, peerAddress)
 		stream.CloseSend()
+		conn.Close()
 		if err != nil {
-			e := fmt.Errorf("Ending chat with peer address=%s due to error:  %s", peerAddress, err)
-			peerLogger.Error(e.Error())
-			return e
+			peerLogger.Error("Chat with peer address=%s ended due to error:  %s", peerAddress, err)
 		}
-
 	}
 }
 
-// Chat implementation of the the Chat bidi streaming RPC function
-func (p *PeerImpl) handleChat(ctx context.Context, stream ChatStream, initiatedStream bool) error {
+// Chat implementation of the the Chat bidi streaming RPC function, remoteAddress is the
+// address of the connection to the peer
+func (p *PeerImpl) handleChat(ctx context.Context, stream ChatStream, initiatedStream bool, remoteAddress string) error {
 	deadline, ok := ctx.Deadline()
 	peerLogger.Debug("Current context deadline = %s, ok = %v", deadline, ok)
+	if p.isBanned(nil, remoteAddress, false) {
+		return fmt.Errorf("Peer connecting from address=%s is banned", remoteAddress)
+	}
 	handler, err := p.handlerFactory(p, stream, initiatedStream, nil)
 	if err != nil {
 		return fmt.Errorf("Error creating handler during handleChat initiation: %s", err)
@@ -553,6 +848,9 @@ func (p *PeerImpl) handleChat(ctx context.Context, stream ChatStream, initiatedS
 			peerLogger.Error(fmt.Sprintf("Error handling message: %s", err))
 			//return err
 		}
+		if peerEndpoint, err := handler.To(); err == nil && p.isBanned(&peerEndpoint, remoteAddress, true) {
+			return fmt.Errorf("Peer address=%s is banned, stopping handler", peerEndpoint.Address)
+		}
 	}
 }
 
@@ -567,6 +865,73 @@ func (p *PeerImpl) ExecuteTransaction(transaction *pb.Transaction) (response *pb
 	return response
 }
 
+//ExecuteQuery executes a read-only query against the committed state of the
+//validator, never opening a transaction nor entering consensus, so queries are
+//served concurrently with each other and with the transactions being ordered.
+//The response carries the height of the blockchain the query was executed at
+//and is signed by the validator when security is enabled
+func (p *PeerImpl) ExecuteQuery(transaction *pb.Transaction) *pb.QueryResponse {
+	if transaction.Type != pb.Transaction_CHAINCODE_QUERY {
+		return &pb.QueryResponse{Uuid: transaction.Uuid, Response: &pb.Response{Status: pb.Response_FAILURE, Msg: []byte(fmt.Sprintf("Not a query: %s", transaction.Type))}}
+	}
+	if !p.isValidator {
+		return p.sendQueryToPeer(getValidatorStreamAddress(), transaction)
+	}
+
+	queryResponse := &pb.QueryResponse{Uuid: transaction.Uuid, BlockHeight: p.GetBlockchainSize()}
+	queryResponse.Response = p.sendTransactionsToLocalEngine(transaction)
+	if ep, err := p.GetPeerEndpoint(); err == nil {
+		queryResponse.PeerID = ep.ID
+	}
+	if secHelper := p.GetSecHelper(); nil != secHelper {
+		raw, err := proto.Marshal(queryResponse)
+		if err == nil {
+			queryResponse.Signature, err = secHelper.Sign(raw)
+		}
+		if err != nil {
+			peerLogger.Error("Failed signing query response %s: %s", transaction.Uuid, err)
+			return &pb.QueryResponse{Uuid: transaction.Uuid, Response: &pb.Response{Status: pb.Response_FAILURE, Msg: []byte(fmt.Sprintf("Error signing query response: %s", err))}}
+		}
+	}
+	return queryResponse
+}
+
+//ExecuteSimulation simulates an invoke transaction against the committed state of the
+//validator, as a dry run: the transaction is neither ordered nor committed. The validator
+//endorses the result, which can be submitted in an endorsed transaction
+func (p *PeerImpl) ExecuteSimulation(transaction *pb.Transaction) (*pb.SimulationResult, error) {
+	if p.isValidator {
+		result, err := p.engine.ProcessSimulation(transaction)
+		if err != nil {
+			return nil, err
+		}
+		if ep, err := p.GetPeerEndpoint(); err == nil {
+			result.PeerID = ep.ID
+			result.PkiID = ep.PkiID
+		}
+		if secHelper := p.GetSecHelper(); nil != secHelper {
+			raw, err := proto.Marshal(result)
+			if err == nil {
+				result.Signature, err = secHelper.Sign(raw)
+			}
+			if err != nil {
+				peerLogger.Error("Failed signing simulation result %s: %s", transaction.Uuid, err)
+				return nil, fmt.Errorf("Error signing simulation result: %s", err)
+			}
+		}
+		return result, nil
+	}
+	peerAddress := getValidatorStreamAddress()
+	conn, err := NewPeerClientConnectionWithAddress(peerAddress)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating client to peer address=%s:  %s", peerAddress, err)
+	}
+	defer conn.Close()
+	serverClient := pb.NewPeerClient(conn)
+	peerLogger.Debug("Sending simulation to Peer: %s", peerAddress)
+	return serverClient.SimulateTransaction(context.Background(), transaction)
+}
+
 // GetPeerEndpoint returns the endpoint for this peer
 func (p *PeerImpl) GetPeerEndpoint() (*pb.PeerEndpoint, error) {
 	ep, err := GetPeerEndpoint()
@@ -589,7 +954,7 @@ func (p *PeerImpl) newHelloMessage() (*pb.HelloMessage, error) {
 	if err != nil {
 		return nil, fmt.Errorf("Error creating hello message, error getting block chain info: %s", err)
 	}
-	return &pb.HelloMessage{PeerEndpoint: endpoint, BlockchainInfo: blockChainInfo}, nil
+	return &pb.HelloMessage{PeerEndpoint: endpoint, BlockchainInfo: blockChainInfo, SyncCompressions: SyncStateCompressions(), SyncStateVersion: SyncStateProtocolVersion}, nil
 }
 
 // GetBlockByNumber return a block by block number
@@ -659,6 +1024,14 @@ func (p *PeerImpl) EmptyState() error {
 	return p.ledgerWrapper.ledger.DeleteALLStateKeysAndValues()
 }
 
+// RewindState rolls the state of block stateBlockNumber back to the state of
+// block blockNumber, for the blocks that follow to be played again
+func (p *PeerImpl) RewindState(stateBlockNumber, blockNumber uint64) error {
+	p.ledgerWrapper.Lock()
+	defer p.ledgerWrapper.Unlock()
+	return p.ledgerWrapper.ledger.RewindState(stateBlockNumber, blockNumber)
+}
+
 // GetStateSnapshot return the state snapshot
 func (p *PeerImpl) GetStateSnapshot() (*state.StateSnapshot, error) {
 	p.ledgerWrapper.RLock()
@@ -666,6 +1039,41 @@ func (p *PeerImpl) GetStateSnapshot() (*state.StateSnapshot, error) {
 	return p.ledgerWrapper.ledger.GetStateSnapshot()
 }
 
+// GetStateBucketTreeShape return the shape of the tree of buckets of the state
+func (p *PeerImpl) GetStateBucketTreeShape() (*statemgmt.BucketTreeShape, error) {
+	p.ledgerWrapper.RLock()
+	defer p.ledgerWrapper.RUnlock()
+	return p.ledgerWrapper.ledger.GetStateBucketTreeShape()
+}
+
+// GetStateBucketHashes return the crypto-hashes of the requested buckets
+func (p *PeerImpl) GetStateBucketHashes(level int, bucketNumbers []int) (uint64, [][]byte, error) {
+	p.ledgerWrapper.RLock()
+	defer p.ledgerWrapper.RUnlock()
+	return p.ledgerWrapper.ledger.GetStateBucketHashes(level, bucketNumbers)
+}
+
+// GetStateBucketsSnapshot return the snapshot of the key-values of the requested buckets
+func (p *PeerImpl) GetStateBucketsSnapshot(bucketNumbers []int) (*state.StateSnapshot, error) {
+	p.ledgerWrapper.RLock()
+	defer p.ledgerWrapper.RUnlock()
+	return p.ledgerWrapper.ledger.GetStateBucketsSnapshot(bucketNumbers)
+}
+
+// GetStateTransferCursor return the persisted progress of the state transfer
+func (p *PeerImpl) GetStateTransferCursor() (*ledger.StateTransferCursor, error) {
+	p.ledgerWrapper.RLock()
+	defer p.ledgerWrapper.RUnlock()
+	return p.ledgerWrapper.ledger.GetStateTransferCursor()
+}
+
+// SetStateTransferCursor persist the progress of the state transfer
+func (p *PeerImpl) SetStateTransferCursor(cursor *ledger.StateTransferCursor) error {
+	p.ledgerWrapper.Lock()
+	defer p.ledgerWrapper.Unlock()
+	return p.ledgerWrapper.ledger.SetStateTransferCursor(cursor)
+}
+
 // GetStateDelta return the state delta for the requested block number
 func (p *PeerImpl) GetStateDelta(blockNumber uint64) (*statemgmt.StateDelta, error) {
 	p.ledgerWrapper.RLock()
@@ -58,6 +58,17 @@ func TestMissingMessageHandlerUnicast(t *testing.T) {
 	}
 }
 
+func TestExecuteQueryRejectsTransactions(t *testing.T) {
+	peerImpl := PeerImpl{isValidator: true}
+	resp := peerImpl.ExecuteQuery(&pb.Transaction{Type: pb.Transaction_CHAINCODE_INVOKE, Uuid: "tx1"})
+	if resp.GetResponse().Status != pb.Response_FAILURE {
+		t.Error("Expected an invoke transaction to be rejected as a query")
+	}
+	if resp.Uuid != "tx1" {
+		t.Errorf("Expected the response to carry the uuid of the query, got %s", resp.Uuid)
+	}
+}
+
 func performChat(t testing.TB, conn *grpc.ClientConn) error {
 	serverClient := pb.NewPeerClient(conn)
 	stream, err := serverClient.Chat(context.Background())
@@ -0,0 +1,442 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statetransfer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/fabric/core/ledger"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+	"github.com/hyperledger/fabric/core/peer"
+	"github.com/hyperledger/fabric/protos"
+)
+
+// State transfer by buckets
+//
+// When both peers organize their state in trees of buckets of the same shape (see
+// statemgmt.BucketHashProvider) and the remote peer supports version 2 of the state sync
+// protocol, the state is not transferred as a whole. The lagging peer instead compares the
+// crypto-hashes of the buckets of both trees level by level, from the root down, following
+// only the buckets that differ, and then requests the key-values of the differing buckets at
+// the lowest level. The buckets still to be received are persisted as a transfer cursor
+// after each batch, so that an interrupted transfer resumes with them. As the remote state
+// keeps changing while the buckets are received, passes are repeated until the root hashes
+// match, which means the local state is the remote state as of the block of the root hash.
+
+// errBucketSyncUnsupported is returned when the state cannot be transferred by buckets, in
+// which case the whole state snapshot is transferred instead
+var errBucketSyncUnsupported = errors.New("state transfer by buckets is not supported")
+
+// maxBucketSyncPasses bounds the passes made to catch up with a remote state that keeps changing
+const maxBucketSyncPasses = 5
+
+// bucketHashesPerRequest bounds the number of bucket hashes requested at once
+const bucketHashesPerRequest = 1000
+
+// BucketSyncStack is the functionality, in addition to PartialStack, required to transfer
+// the state by buckets. It is implemented by peer.MessageHandlerCoordinator.
+type BucketSyncStack interface {
+	peer.BucketStateAccessor
+	ApplyStateDelta(id interface{}, delta *statemgmt.StateDelta) error
+	CommitStateDelta(id interface{}) error
+}
+
+// trySyncStateByBuckets transfers the state from the given peer by buckets, it returns
+// errBucketSyncUnsupported if either this peer or the remote peer cannot do so. The buckets
+// may also be fetched from other peers among candidatePeerIDs (see syncBuckets).
+func (sts *StateTransferState) trySyncStateByBuckets(peerID *protos.PeerID, candidatePeerIDs []*protos.PeerID) (uint64, error) {
+	if !sts.bucketSyncEnabled {
+		return 0, errBucketSyncUnsupported
+	}
+	stack, ok := sts.stack.(BucketSyncStack)
+	if !ok {
+		return 0, errBucketSyncUnsupported
+	}
+	remote, err := sts.getBucketStateRetriever(peerID)
+	if err != nil {
+		return 0, err
+	}
+	var helpers []peer.BucketStateRetriever
+	for _, candidatePeerID := range candidatePeerIDs {
+		if len(helpers)+1 >= sts.maxBucketSyncPeers {
+			break
+		}
+		if candidatePeerID.Name == peerID.Name || candidatePeerID.Name == sts.id.Name {
+			continue
+		}
+		helper, err := sts.getBucketStateRetriever(candidatePeerID)
+		if err != nil {
+			logger.Debug("%v will not fetch state buckets from %v: %s", sts.id, candidatePeerID, err)
+			continue
+		}
+		helpers = append(helpers, helper)
+	}
+	return sts.syncStateByBuckets(stack, remote, helpers...)
+}
+
+func (sts *StateTransferState) getBucketStateRetriever(peerID *protos.PeerID) (peer.BucketStateRetriever, error) {
+	remoteLedger, err := sts.stack.GetRemoteLedger(peerID)
+	if err != nil {
+		return nil, err
+	}
+	remote, ok := remoteLedger.(peer.BucketStateRetriever)
+	if !ok || remote.RemoteSyncStateVersion() < peer.SyncStateProtocolVersion {
+		return nil, errBucketSyncUnsupported
+	}
+	return remote, nil
+}
+
+// syncStateByBuckets transfers the state of the remote peer. The key-values of the buckets may also
+// be fetched from the helpers, which are assumed to be peers with the same state as the remote peer.
+func (sts *StateTransferState) syncStateByBuckets(stack BucketSyncStack, remote peer.BucketStateRetriever, helpers ...peer.BucketStateRetriever) (uint64, error) {
+	shape, err := stack.GetStateBucketTreeShape()
+	if err != nil {
+		logger.Debug("%v cannot transfer the state by buckets: %s", sts.id, err)
+		return 0, errBucketSyncUnsupported
+	}
+	remoteBlockNumber, remoteRootHash, remoteShape, err := sts.getRemoteBucketHashes(remote, 0, []int{1})
+	if err != nil {
+		return 0, err
+	}
+	if !sameBucketTreeShape(remoteShape, shape) {
+		logger.Warning("%v cannot transfer the state by buckets, the bucket tree of the remote peer is %v while the local one is %+v", sts.id, remoteShape, shape)
+		return 0, errBucketSyncUnsupported
+	}
+	var sameShapeHelpers []peer.BucketStateRetriever
+	for _, helper := range helpers {
+		if _, _, helperShape, err := sts.getRemoteBucketHashes(helper, 0, []int{1}); err == nil && sameBucketTreeShape(helperShape, shape) {
+			sameShapeHelpers = append(sameShapeHelpers, helper)
+		}
+	}
+
+	cursor, err := stack.GetStateTransferCursor()
+	if err != nil {
+		return 0, err
+	}
+	if cursor != nil {
+		var pending []int
+		for _, bucketNumber := range cursor.PendingBuckets {
+			if bucketNumber >= 1 && bucketNumber <= shape.NumBuckets {
+				pending = append(pending, bucketNumber)
+			}
+		}
+		logger.Debug("%v resuming the state transfer with %d pending buckets as of block %d", sts.id, len(pending), cursor.BlockNumber)
+		// The hashes of the pending buckets are unknown, they cannot be fetched from the helpers
+		if err := sts.syncBuckets(stack, remote, nil, pending, nil); err != nil {
+			return 0, err
+		}
+		if remoteBlockNumber, remoteRootHash, _, err = sts.getRemoteBucketHashes(remote, 0, []int{1}); err != nil {
+			return 0, err
+		}
+	}
+
+	for pass := 0; ; pass++ {
+		_, localRootHash, err := stack.GetStateBucketHashes(0, []int{1})
+		if err != nil {
+			return 0, err
+		}
+		if bytes.Equal(localRootHash[0], remoteRootHash[0]) {
+			logger.Debug("%v has the state of block %d after %d passes of state transfer by buckets", sts.id, remoteBlockNumber, pass)
+			return remoteBlockNumber, stack.SetStateTransferCursor(nil)
+		}
+		if pass == maxBucketSyncPasses {
+			return 0, fmt.Errorf("%v could not catch up with the state of the remote peer after %d passes of state transfer by buckets", sts.id, pass)
+		}
+		differing, expectedHashes, err := sts.getDifferingBuckets(stack, remote, shape)
+		if err != nil {
+			return 0, err
+		}
+		logger.Debug("%v found %d differing buckets in pass %d of state transfer by buckets", sts.id, len(differing), pass)
+		if err := sts.syncBuckets(stack, remote, sameShapeHelpers, differing, expectedHashes); err != nil {
+			return 0, err
+		}
+		if remoteBlockNumber, remoteRootHash, _, err = sts.getRemoteBucketHashes(remote, 0, []int{1}); err != nil {
+			return 0, err
+		}
+	}
+}
+
+func sameBucketTreeShape(remoteShape *protos.SyncBucketTreeShape, shape *statemgmt.BucketTreeShape) bool {
+	return remoteShape != nil && int(remoteShape.NumBuckets) == shape.NumBuckets &&
+		int(remoteShape.MaxGroupingAtEachLevel) == shape.MaxGroupingAtEachLevel && remoteShape.BucketHashFunction == shape.BucketHashFunction
+}
+
+// getDifferingBuckets walks down the bucket trees, from the root, and returns the numbers of the
+// buckets at the lowest level whose crypto-hashes differ between this peer and the remote peer,
+// along with the crypto-hashes of these buckets reported by the remote peer
+func (sts *StateTransferState) getDifferingBuckets(stack BucketSyncStack, remote peer.BucketStateRetriever, shape *statemgmt.BucketTreeShape) ([]int, map[int][]byte, error) {
+	differing := []int{1}
+	var expectedHashes map[int][]byte
+	for level := 1; level <= shape.LowestLevel() && len(differing) > 0; level++ {
+		var candidates []int
+		for _, bucketNumber := range differing {
+			candidates = append(candidates, shape.ChildBuckets(level-1, bucketNumber)...)
+		}
+		differing = nil
+		expectedHashes = make(map[int][]byte)
+		for start := 0; start < len(candidates); start += bucketHashesPerRequest {
+			end := start + bucketHashesPerRequest
+			if end > len(candidates) {
+				end = len(candidates)
+			}
+			chunk := candidates[start:end]
+			_, remoteHashes, _, err := sts.getRemoteBucketHashes(remote, level, chunk)
+			if err != nil {
+				return nil, nil, err
+			}
+			_, localHashes, err := stack.GetStateBucketHashes(level, chunk)
+			if err != nil {
+				return nil, nil, err
+			}
+			for i, bucketNumber := range chunk {
+				if !bytes.Equal(localHashes[i], remoteHashes[i]) {
+					differing = append(differing, bucketNumber)
+					expectedHashes[bucketNumber] = remoteHashes[i]
+				}
+			}
+		}
+	}
+	return differing, expectedHashes, nil
+}
+
+// bucketBatch is a batch of buckets at the lowest level along with their key-values once fetched
+type bucketBatch struct {
+	bucketNumbers []int
+	source        peer.BucketStateRetriever
+	delta         *statemgmt.StateDelta
+	blockNumber   uint64
+	err           error
+}
+
+// syncBuckets replaces the key-values of the given buckets at the lowest level with the ones of the
+// remote peer, in batches of bucketsPerRequest buckets, recording the pending buckets after each batch.
+// The batches are fetched concurrently from the remote peer and from the helpers, one batch per peer
+// at a time, but applied one after the other. As the helpers may not have the state the expected hashes
+// were obtained from, the buckets they send are checked against these hashes once applied and, if they
+// differ, are fetched again from the remote peer. The helpers are only used when expectedHashes is set.
+func (sts *StateTransferState) syncBuckets(stack BucketSyncStack, remote peer.BucketStateRetriever, helpers []peer.BucketStateRetriever, bucketNumbers []int, expectedHashes map[int][]byte) error {
+	var lowestLevel int
+	if expectedHashes == nil {
+		helpers = nil
+	} else if len(helpers) > 0 {
+		shape, err := stack.GetStateBucketTreeShape()
+		if err != nil {
+			return err
+		}
+		lowestLevel = shape.LowestLevel()
+	}
+	pending := make(map[int]bool)
+	for _, bucketNumber := range bucketNumbers {
+		pending[bucketNumber] = true
+	}
+	var retries []int
+	for len(bucketNumbers) > 0 || len(retries) > 0 {
+		// Assign a batch to each peer, the buckets to fetch again going to the remote peer
+		var batches []*bucketBatch
+		for i, source := range append([]peer.BucketStateRetriever{remote}, helpers...) {
+			var batch []int
+			if i == 0 && len(retries) > 0 {
+				batch, retries = splitBucketBatch(retries, sts.bucketsPerRequest)
+			} else {
+				batch, bucketNumbers = splitBucketBatch(bucketNumbers, sts.bucketsPerRequest)
+			}
+			if len(batch) > 0 {
+				batches = append(batches, &bucketBatch{bucketNumbers: batch, source: source})
+			}
+		}
+
+		var wg sync.WaitGroup
+		for _, batch := range batches {
+			wg.Add(1)
+			go func(batch *bucketBatch) {
+				defer wg.Done()
+				batch.delta, batch.blockNumber, batch.err = sts.fetchBucketBatch(batch.source, batch.bucketNumbers)
+			}(batch)
+		}
+		wg.Wait()
+
+		var blockNumber uint64
+		for _, batch := range batches {
+			if batch.err != nil {
+				if batch.source == remote {
+					return batch.err
+				}
+				logger.Warning("%v stops fetching state buckets from a peer: %s", sts.id, batch.err)
+				helpers = removeBucketStateRetriever(helpers, batch.source)
+				retries = append(retries, batch.bucketNumbers...)
+				continue
+			}
+			if err := sts.applyBucketBatch(stack, batch.bucketNumbers, batch.delta); err != nil {
+				return err
+			}
+			verified := batch.bucketNumbers
+			if batch.source != remote {
+				var err error
+				if verified, err = sts.verifyBucketBatch(stack, lowestLevel, batch.bucketNumbers, expectedHashes, &retries); err != nil {
+					return err
+				}
+				if len(verified) < len(batch.bucketNumbers) {
+					logger.Warning("%v received %d state buckets which do not match the expected hashes, fetching them again", sts.id, len(batch.bucketNumbers)-len(verified))
+				}
+			}
+			for _, bucketNumber := range verified {
+				delete(pending, bucketNumber)
+			}
+			blockNumber = batch.blockNumber
+		}
+		if err := stack.SetStateTransferCursor(&ledger.StateTransferCursor{BlockNumber: blockNumber, PendingBuckets: sortedBucketNumbers(pending)}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// verifyBucketBatch returns the numbers of the given buckets whose local crypto-hashes are the expected
+// ones, the other buckets are added to the retries
+func (sts *StateTransferState) verifyBucketBatch(stack BucketSyncStack, lowestLevel int, bucketNumbers []int, expectedHashes map[int][]byte, retries *[]int) ([]int, error) {
+	_, localHashes, err := stack.GetStateBucketHashes(lowestLevel, bucketNumbers)
+	if err != nil {
+		return nil, err
+	}
+	var verified []int
+	for i, bucketNumber := range bucketNumbers {
+		if bytes.Equal(localHashes[i], expectedHashes[bucketNumber]) {
+			verified = append(verified, bucketNumber)
+		} else {
+			*retries = append(*retries, bucketNumber)
+		}
+	}
+	return verified, nil
+}
+
+// fetchBucketBatch receives the key-values of the given buckets from the remote peer
+func (sts *StateTransferState) fetchBucketBatch(remote peer.BucketStateRetriever, bucketNumbers []int) (*statemgmt.StateDelta, uint64, error) {
+	bucketsChan, err := remote.RequestStateBuckets(bucketNumbers)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	delta := statemgmt.NewStateDelta()
+	timer := time.NewTimer(sts.StateBucketsRequestTimeout)
+	defer timer.Stop()
+	for {
+		select {
+		case piece, ok := <-bucketsChan:
+			if !ok {
+				return nil, 0, fmt.Errorf("%v had state buckets channel close prematurely", sts.id)
+			}
+			if piece.Error != "" {
+				return nil, 0, fmt.Errorf("%v could not receive state buckets: %s", sts.id, piece.Error)
+			}
+			if 0 == len(piece.Delta) {
+				return delta, piece.BlockNumber, nil
+			}
+			umDelta := &statemgmt.StateDelta{}
+			if err := umDelta.Unmarshal(piece.Delta); nil != err {
+				return nil, 0, fmt.Errorf("%v received a corrupt state bucket delta: %s", sts.id, err)
+			}
+			delta.ApplyChanges(umDelta)
+		case <-timer.C:
+			return nil, 0, fmt.Errorf("%v timed out receiving state buckets", sts.id)
+		}
+	}
+}
+
+// applyBucketBatch replaces the key-values of the given buckets with the received ones
+func (sts *StateTransferState) applyBucketBatch(stack BucketSyncStack, bucketNumbers []int, delta *statemgmt.StateDelta) error {
+	// The keys of the buckets that the remote peer does not hold anymore have to be deleted
+	snapshot, err := stack.GetStateBucketsSnapshot(bucketNumbers)
+	if err != nil {
+		return err
+	}
+	for snapshot.Next() {
+		k, _ := snapshot.GetRawKeyValue()
+		chaincodeID, key := statemgmt.DecodeCompositeKey(k)
+		if !delta.IsUpdatedValueSet(chaincodeID, key) {
+			delta.Delete(chaincodeID, key, nil)
+		}
+	}
+	snapshot.Release()
+
+	id := &bucketNumbers
+	if err := stack.ApplyStateDelta(id, delta); err != nil {
+		return err
+	}
+	if err := stack.CommitStateDelta(id); err != nil {
+		return fmt.Errorf("%v could not commit the state buckets: %s", sts.id, err)
+	}
+	return nil
+}
+
+func splitBucketBatch(bucketNumbers []int, size int) ([]int, []int) {
+	if len(bucketNumbers) > size {
+		return bucketNumbers[:size], bucketNumbers[size:]
+	}
+	return bucketNumbers, nil
+}
+
+func removeBucketStateRetriever(retrievers []peer.BucketStateRetriever, removed peer.BucketStateRetriever) []peer.BucketStateRetriever {
+	var kept []peer.BucketStateRetriever
+	for _, retriever := range retrievers {
+		if retriever != removed {
+			kept = append(kept, retriever)
+		}
+	}
+	return kept
+}
+
+func sortedBucketNumbers(bucketNumbers map[int]bool) []int {
+	sorted := make([]int, 0, len(bucketNumbers))
+	for bucketNumber := range bucketNumbers {
+		sorted = append(sorted, bucketNumber)
+	}
+	sort.Ints(sorted)
+	return sorted
+}
+
+func (sts *StateTransferState) getRemoteBucketHashes(remote peer.BucketStateRetriever, level int, bucketNumbers []int) (uint64, [][]byte, *protos.SyncBucketTreeShape, error) {
+	hashesChan, err := remote.RequestStateBucketHashes(level, bucketNumbers)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	select {
+	case reply, ok := <-hashesChan:
+		if !ok {
+			return 0, nil, nil, fmt.Errorf("%v had bucket hashes channel close prematurely", sts.id)
+		}
+		if reply.Error != "" {
+			return 0, nil, nil, fmt.Errorf("%v could not receive bucket hashes: %s", sts.id, reply.Error)
+		}
+		if len(reply.Hashes) != len(bucketNumbers) {
+			return 0, nil, nil, fmt.Errorf("%v received %d bucket hashes while %d were requested", sts.id, len(reply.Hashes), len(bucketNumbers))
+		}
+		// An empty hash stands for an empty bucket
+		for i, hash := range reply.Hashes {
+			if len(hash) == 0 {
+				reply.Hashes[i] = nil
+			}
+		}
+		return reply.BlockNumber, reply.Hashes, reply.Shape, nil
+	case <-time.After(sts.StateBucketsRequestTimeout):
+		return 0, nil, nil, fmt.Errorf("%v timed out receiving bucket hashes", sts.id)
+	}
+}
@@ -0,0 +1,206 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statetransfer
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric/core/ledger"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+	"github.com/hyperledger/fabric/core/ledger/testutil"
+	"github.com/hyperledger/fabric/core/peer"
+	"github.com/hyperledger/fabric/protos"
+	"github.com/spf13/viper"
+)
+
+// ledgerBucketRetriever serves the bucket hashes and the state buckets of a ledger, as the
+// handler of a remote peer would. Once it has served failAfter bucket requests, if positive,
+// it behaves as if the connection to the remote peer had been lost. The bucket requests served
+// are counted in requests.
+type ledgerBucketRetriever struct {
+	ledger    *ledger.Ledger
+	failAfter int
+	requests  int
+}
+
+func (r *ledgerBucketRetriever) RemoteSyncStateVersion() uint32 {
+	return peer.SyncStateProtocolVersion
+}
+
+func (r *ledgerBucketRetriever) RequestStateBucketHashes(level int, bucketNumbers []int) (<-chan *protos.SyncStateBucketHashes, error) {
+	shape, err := r.ledger.GetStateBucketTreeShape()
+	if err != nil {
+		return nil, err
+	}
+	blockNumber, hashes, err := r.ledger.GetStateBucketHashes(level, bucketNumbers)
+	if err != nil {
+		return nil, err
+	}
+	hashesChan := make(chan *protos.SyncStateBucketHashes, 1)
+	hashesChan <- &protos.SyncStateBucketHashes{
+		BlockNumber: blockNumber,
+		Shape:       &protos.SyncBucketTreeShape{NumBuckets: uint32(shape.NumBuckets), MaxGroupingAtEachLevel: uint32(shape.MaxGroupingAtEachLevel), BucketHashFunction: shape.BucketHashFunction},
+		Hashes:      hashes,
+	}
+	return hashesChan, nil
+}
+
+func (r *ledgerBucketRetriever) RequestStateBuckets(bucketNumbers []int) (<-chan *protos.SyncStateBuckets, error) {
+	if r.failAfter == 0 {
+		return nil, fmt.Errorf("Connection lost")
+	}
+	r.failAfter--
+	r.requests++
+	snapshot, err := r.ledger.GetStateBucketsSnapshot(bucketNumbers)
+	if err != nil {
+		return nil, err
+	}
+	defer snapshot.Release()
+	var pieces []*protos.SyncStateBuckets
+	for snapshot.Next() {
+		k, v := snapshot.GetRawKeyValue()
+		chaincodeID, key := statemgmt.DecodeCompositeKey(k)
+		delta := statemgmt.NewStateDelta()
+		delta.Set(chaincodeID, key, v, nil)
+		pieces = append(pieces, &protos.SyncStateBuckets{BlockNumber: snapshot.GetBlockNumber(), Delta: delta.Marshal()})
+	}
+	pieces = append(pieces, &protos.SyncStateBuckets{BlockNumber: snapshot.GetBlockNumber()})
+	bucketsChan := make(chan *protos.SyncStateBuckets, len(pieces))
+	for _, piece := range pieces {
+		bucketsChan <- piece
+	}
+	return bucketsChan, nil
+}
+
+func commitTestState(t *testing.T, l *ledger.Ledger, blockNumber int, state map[string]string) {
+	testutil.AssertNoError(t, l.BeginTxBatch(blockNumber), "Error beginning tx batch")
+	l.TxBegin("txUuid")
+	for key, value := range state {
+		if value == "" {
+			testutil.AssertNoError(t, l.DeleteState("chaincode1", key), "Error deleting state")
+		} else {
+			testutil.AssertNoError(t, l.SetState("chaincode1", key, []byte(value)), "Error setting state")
+		}
+	}
+	l.TxFinished("txUuid", true)
+	tx, err := protos.NewTransaction(protos.ChaincodeID{Path: "testUrl"}, "txUuid", "anyfunction", []string{"param1"})
+	testutil.AssertNoError(t, err, "Error creating transaction")
+	testutil.AssertNoError(t, l.CommitTxBatch(blockNumber, []*protos.Transaction{tx}, nil, []byte("proof")), "Error committing tx batch")
+}
+
+func TestSyncStateByBuckets(t *testing.T) {
+	localLedger := ledger.InitTestLedger(t)
+	chainsPath := viper.GetString("peer.fileSystemPath") + "/chains"
+	os.RemoveAll(chainsPath)
+	defer os.RemoveAll(chainsPath)
+	remoteLedger, err := ledger.GetLedgerByChainID("bucketsync")
+	testutil.AssertNoError(t, err, "Error opening remote ledger")
+
+	remoteState := make(map[string]string)
+	localState := make(map[string]string)
+	for i := 0; i < 20; i++ {
+		remoteState[fmt.Sprintf("key%d", i)] = fmt.Sprintf("value%d", i)
+		if i%4 == 0 {
+			localState[fmt.Sprintf("key%d", i)] = fmt.Sprintf("value%d", i)
+		} else if i%4 == 1 {
+			localState[fmt.Sprintf("key%d", i)] = "stale"
+		}
+	}
+	localState["localOnly"] = "value"
+	commitTestState(t, remoteLedger, 0, remoteState)
+	commitTestState(t, localLedger, 0, localState)
+
+	sts := &StateTransferState{
+		id:                         &protos.PeerID{Name: "vp0"},
+		bucketSyncEnabled:          true,
+		bucketsPerRequest:          2,
+		StateBucketsRequestTimeout: time.Second,
+	}
+
+	// The transfer is interrupted after two batches of buckets, the pending buckets are recorded
+	_, err = sts.syncStateByBuckets(localLedger, &ledgerBucketRetriever{ledger: remoteLedger, failAfter: 2})
+	testutil.AssertError(t, err, "Expected an error for an interrupted state transfer")
+	cursor, err := localLedger.GetStateTransferCursor()
+	testutil.AssertNoError(t, err, "Error getting state transfer cursor")
+	testutil.AssertNotNil(t, cursor)
+	testutil.AssertNotEquals(t, len(cursor.PendingBuckets), 0)
+
+	// The remote state moves on while the transfer is resumed
+	commitTestState(t, remoteLedger, 1, map[string]string{"key3": "", "key21": "value21"})
+	blockNumber, err := sts.syncStateByBuckets(localLedger, &ledgerBucketRetriever{ledger: remoteLedger, failAfter: -1})
+	testutil.AssertNoError(t, err, "Error transferring the state by buckets")
+	testutil.AssertEquals(t, blockNumber, uint64(1))
+
+	localHash, _ := localLedger.GetTempStateHash()
+	remoteHash, _ := remoteLedger.GetTempStateHash()
+	testutil.AssertEquals(t, localHash, remoteHash)
+	value, _ := localLedger.GetState("chaincode1", "localOnly", true)
+	testutil.AssertNil(t, value)
+	cursor, err = localLedger.GetStateTransferCursor()
+	testutil.AssertNoError(t, err, "Error getting state transfer cursor")
+	testutil.AssertNil(t, cursor)
+}
+
+func TestSyncStateByBucketsFromSeveralPeers(t *testing.T) {
+	localLedger := ledger.InitTestLedger(t)
+	chainsPath := viper.GetString("peer.fileSystemPath") + "/chains"
+	os.RemoveAll(chainsPath)
+	defer os.RemoveAll(chainsPath)
+	remoteLedger, err := ledger.GetLedgerByChainID("bucketsyncremote")
+	testutil.AssertNoError(t, err, "Error opening remote ledger")
+	staleLedger, err := ledger.GetLedgerByChainID("bucketsyncstale")
+	testutil.AssertNoError(t, err, "Error opening stale ledger")
+
+	remoteState := make(map[string]string)
+	staleState := make(map[string]string)
+	for i := 0; i < 40; i++ {
+		remoteState[fmt.Sprintf("key%d", i)] = fmt.Sprintf("value%d", i)
+		staleState[fmt.Sprintf("key%d", i)] = "stale"
+	}
+	commitTestState(t, remoteLedger, 0, remoteState)
+	commitTestState(t, staleLedger, 0, staleState)
+
+	sts := &StateTransferState{
+		id:                         &protos.PeerID{Name: "vp0"},
+		bucketSyncEnabled:          true,
+		bucketsPerRequest:          2,
+		StateBucketsRequestTimeout: time.Second,
+	}
+
+	// The buckets received from the stale helper do not match the hashes of the remote peer and
+	// are fetched again, the failing helper is no longer used
+	remote := &ledgerBucketRetriever{ledger: remoteLedger, failAfter: -1}
+	helper := &ledgerBucketRetriever{ledger: remoteLedger, failAfter: -1}
+	staleHelper := &ledgerBucketRetriever{ledger: staleLedger, failAfter: -1}
+	failingHelper := &ledgerBucketRetriever{ledger: remoteLedger, failAfter: 1}
+	blockNumber, err := sts.syncStateByBuckets(localLedger, remote, helper, staleHelper, failingHelper)
+	testutil.AssertNoError(t, err, "Error transferring the state by buckets")
+	testutil.AssertEquals(t, blockNumber, uint64(0))
+
+	localHash, _ := localLedger.GetTempStateHash()
+	remoteHash, _ := remoteLedger.GetTempStateHash()
+	testutil.AssertEquals(t, localHash, remoteHash)
+	if helper.requests == 0 || staleHelper.requests == 0 || failingHelper.requests != 1 {
+		t.Fatalf("Expected the buckets to be fetched from all the helpers, got %d, %d and %d requests", helper.requests, staleHelper.requests, failingHelper.requests)
+	}
+	cursor, err := localLedger.GetStateTransferCursor()
+	testutil.AssertNoError(t, err, "Error getting state transfer cursor")
+	testutil.AssertNil(t, cursor)
+}
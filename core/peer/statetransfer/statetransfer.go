@@ -124,6 +124,13 @@ type StateTransferState struct {
 	BlockRequestTimeout         time.Duration // How long to wait for a peer to respond to a block request
 	StateDeltaRequestTimeout    time.Duration // How long to wait for a peer to respond to a state delta request
 	StateSnapshotRequestTimeout time.Duration // How long to wait for a peer to respond to a state snapshot request
+	StateBucketsRequestTimeout  time.Duration // How long to wait for a peer to respond to a bucket hashes or state buckets request
+
+	bucketSyncEnabled  bool // Whether the state may be transferred by buckets instead of as a whole snapshot
+	bucketsPerRequest  int  // The maximum number of buckets whose key-values are requested at once
+	maxBucketSyncPeers int  // The maximum number of peers from which buckets are fetched concurrently
+
+	snapshotResume *snapshotResumePoint // Where to resume the state snapshot last received partially, if any
 
 	maxStateDeltas     int    // The maximum number of state deltas to attempt to retrieve before giving up and performing a full state snapshot retrieval
 	maxBlockRange      uint64 // The maximum number blocks to attempt to retrieve at once, to prevent from overflowing the peer's buffer
@@ -354,6 +361,22 @@ func threadlessNewStateTransferState(stack PartialStack) *StateTransferState {
 		panic(fmt.Errorf("Cannot parse statetransfer.timeout.fullstate timeout: %s", err))
 	}
 
+	sts.bucketSyncEnabled = viper.GetBool("statetransfer.bucketsync.enabled")
+	if sts.bucketSyncEnabled {
+		sts.bucketsPerRequest = viper.GetInt("statetransfer.bucketsync.bucketsperrequest")
+		if sts.bucketsPerRequest <= 0 {
+			panic(fmt.Errorf("statetransfer.bucketsync.bucketsperrequest must be greater than 0"))
+		}
+		sts.maxBucketSyncPeers = viper.GetInt("statetransfer.bucketsync.maxpeers")
+		if sts.maxBucketSyncPeers <= 0 {
+			sts.maxBucketSyncPeers = 1
+		}
+		sts.StateBucketsRequestTimeout, err = time.ParseDuration(viper.GetString("statetransfer.timeout.statebuckets"))
+		if err != nil {
+			panic(fmt.Errorf("Cannot parse statetransfer.timeout.statebuckets timeout: %s", err))
+		}
+	}
+
 	sts.maxStateDeltas = viper.GetInt("statetransfer.maxdeltas")
 	if sts.maxStateDeltas <= 0 {
 		panic(fmt.Errorf("sts.maxdeltas must be greater than 0"))
@@ -440,6 +463,26 @@ func (a blockRangeSlice) Less(i, j int) bool {
 // helper functions for state transfer
 // =============================================================================
 
+// Returns the IDs of the validating peers other than this one
+func (sts *StateTransferState) discoverPeerIDs() ([]*protos.PeerID, error) {
+	peersMsg, err := sts.stack.GetPeers()
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't retrieve list of peers: %v", err)
+	}
+	var peerIDs []*protos.PeerID
+	for _, endpoint := range peersMsg.GetPeers() {
+		if endpoint.Type == protos.PeerEndpoint_VALIDATOR {
+			if endpoint.ID.Name == sts.id.Name {
+				continue
+			}
+			peerIDs = append(peerIDs, endpoint.ID)
+		}
+	}
+
+	logger.Debug("%v discovered %d peerIDs", sts.id, len(peerIDs))
+	return peerIDs, nil
+}
+
 // Executes a func trying each peer included in peerIDs until successful
 // Attempts to execute over all peers if peerIDs is nil
 func (sts *StateTransferState) tryOverPeers(passedPeerIDs []*protos.PeerID, do func(peerID *protos.PeerID) error) (err error) {
@@ -449,21 +492,9 @@ func (sts *StateTransferState) tryOverPeers(passedPeerIDs []*protos.PeerID, do f
 	if nil == passedPeerIDs {
 		logger.Debug("%v tryOverPeers, no peerIDs given, discovering", sts.id)
 
-		peersMsg, err := sts.stack.GetPeers()
-		if err != nil {
-			return fmt.Errorf("Couldn't retrieve list of peers: %v", err)
-		}
-		peers := peersMsg.GetPeers()
-		for _, endpoint := range peers {
-			if endpoint.Type == protos.PeerEndpoint_VALIDATOR {
-				if endpoint.ID.Name == sts.id.Name {
-					continue
-				}
-				peerIDs = append(peerIDs, endpoint.ID)
-			}
+		if peerIDs, err = sts.discoverPeerIDs(); err != nil {
+			return err
 		}
-
-		logger.Debug("%v discovered %d peerIDs", sts.id, len(peerIDs))
 	}
 
 	logger.Debug("%v in tryOverPeers, using peerIDs: %v", sts.id, peerIDs)
@@ -893,6 +924,23 @@ func (sts *StateTransferState) attemptStateTransfer(currentStateBlockNumber *uin
 		return fmt.Errorf("%v believed its state for block %d to be valid, but it could not retrieve it : %s", sts.id, *currentStateBlockNumber, err)
 	}
 
+	if !bytes.Equal(stateHash, block.StateHash) && sts.stateValid && sts.RecoverDamage {
+		// Our state likely forked from the network's at an earlier block, rewind it rather than retrieving a new one
+		if blockNumber, err := sts.rewindForkedState(*currentStateBlockNumber, (*blockHReply).blockNumber, (*blockHReply).peerIDs); nil != err {
+			logger.Warning("%v could not rewind its state for block %d to a block in common with the network: %s", sts.id, *currentStateBlockNumber, err)
+		} else {
+			*currentStateBlockNumber = blockNumber
+			if stateHash, err = sts.stack.GetCurrentStateHash(); nil != err {
+				sts.stateValid = false
+				return fmt.Errorf("%v could not compute its current state hash: %x", sts.id, err)
+			}
+			if block, err = sts.stack.GetBlockByNumber(blockNumber); nil != err {
+				*blocksValid = false
+				return fmt.Errorf("%v rewound its state to block %d, but it could not retrieve it : %s", sts.id, blockNumber, err)
+			}
+		}
+	}
+
 	if !bytes.Equal(stateHash, block.StateHash) {
 		if sts.stateValid {
 			sts.stateValid = false
@@ -1014,6 +1062,91 @@ func (sts *StateTransferState) informListeners(blockNumber uint64, blockHash []b
 	}
 }
 
+// rewindForkedState looks for the last block before block stateBlockNumber that the blockchain
+// has in common with the network, replacing the blocks that follow it with the network's, and
+// rolls the state back to that block, for the state deltas of the network to be played forward
+// from there up to block targetBlockNumber. Returns the number of the block the state was
+// rewound to, or an error, in which case the state has to be retrieved anew.
+func (sts *StateTransferState) rewindForkedState(stateBlockNumber, targetBlockNumber uint64, peerIDs []*protos.PeerID) (uint64, error) {
+	block, err := sts.stack.GetBlockByNumber(stateBlockNumber)
+	if nil != err {
+		return 0, err
+	}
+	validBlockHash := block.PreviousBlockHash
+
+	for blockNumber := stateBlockNumber - 1; ; blockNumber-- {
+		if targetBlockNumber-blockNumber > uint64(sts.maxStateDeltas) {
+			return 0, fmt.Errorf("no block in common with the network within the %d state deltas which may be played forward", sts.maxStateDeltas)
+		}
+
+		localBlock, err := sts.stack.GetBlockByNumber(blockNumber)
+		if nil != err {
+			return 0, err
+		}
+		localBlockHash, err := sts.stack.HashBlock(localBlock)
+		if nil != err {
+			return 0, err
+		}
+
+		if bytes.Equal(localBlockHash, validBlockHash) {
+			logger.Info("%v has a state which forked from the network's after block %d, rewinding it from block %d", sts.id, blockNumber, stateBlockNumber)
+			if err := sts.stack.RewindState(stateBlockNumber, blockNumber); nil != err {
+				return 0, err
+			}
+			return blockNumber, nil
+		}
+
+		if 0 == blockNumber {
+			return 0, fmt.Errorf("the genesis block differs from the network's")
+		}
+
+		block, err = sts.syncBlock(blockNumber, validBlockHash, peerIDs)
+		if nil != err {
+			return 0, err
+		}
+		logger.Debug("%v has a forked block %d with StateHash (%x), the network's has StateHash (%x)", sts.id, blockNumber, localBlock.StateHash, block.StateHash)
+		if err := sts.stack.PutBlock(blockNumber, block); nil != err {
+			return 0, err
+		}
+		validBlockHash = block.PreviousBlockHash
+	}
+}
+
+// syncBlock retrieves the block with the given number and hash from the first of the peers to have it
+func (sts *StateTransferState) syncBlock(blockNumber uint64, blockHash []byte, peerIDs []*protos.PeerID) (*protos.Block, error) {
+	var block *protos.Block
+
+	err := sts.tryOverPeers(peerIDs, func(peerID *protos.PeerID) error {
+		blockChan, err := sts.GetRemoteBlocks(peerID, blockNumber, blockNumber)
+		if nil != err {
+			return err
+		}
+
+		select {
+		case syncBlockMessage, ok := <-blockChan:
+			if !ok || syncBlockMessage.Range.Start != blockNumber || 1 != len(syncBlockMessage.Blocks) {
+				return fmt.Errorf("%v did not receive block %d from %v", sts.id, blockNumber, peerID)
+			}
+
+			testHash, err := sts.stack.HashBlock(syncBlockMessage.Blocks[0])
+			if nil != err {
+				return fmt.Errorf("%v got a block %d which could not hash from %v: %s", sts.id, blockNumber, peerID, err)
+			}
+
+			if !bytes.Equal(testHash, blockHash) {
+				return fmt.Errorf("%v got block %d from %v with hash %x, was expecting hash %x", sts.id, blockNumber, peerID, testHash, blockHash)
+			}
+
+			block = syncBlockMessage.Blocks[0]
+			return nil
+		case <-time.After(sts.BlockRequestTimeout):
+			return fmt.Errorf("%v had block sync request to %v time out", sts.id, peerID)
+		}
+	})
+
+	return block, err
+}
+
 func (sts *StateTransferState) playStateUpToBlockNumber(fromBlockNumber, toBlockNumber uint64, peerIDs []*protos.PeerID) (uint64, error) {
 	logger.Debug("%v attempting to play state forward from %v to block %d", sts.id, peerIDs, toBlockNumber)
 	currentBlock := fromBlockNumber
@@ -1113,14 +1246,40 @@ func (sts *StateTransferState) syncStateSnapshot(minBlockNumber uint64, peerIDs
 
 	currentStateBlock := uint64(0)
 
+	// The buckets of the state may be fetched from several peers at once
+	candidatePeerIDs := peerIDs
+	if nil == candidatePeerIDs && sts.bucketSyncEnabled && sts.maxBucketSyncPeers > 1 {
+		candidatePeerIDs, _ = sts.discoverPeerIDs()
+	}
+
 	ok := sts.tryOverPeers(peerIDs, func(peerID *protos.PeerID) error {
 		logger.Debug("%v is initiating state recovery from %v", sts.id, peerID)
 
-		if err := sts.stack.EmptyState(); nil != err {
-			logger.Error("Could not empty the current state: %s", err)
+		if blockNumber, err := sts.trySyncStateByBuckets(peerID, candidatePeerIDs); err != errBucketSyncUnsupported {
+			sts.snapshotResume = nil
+			if err == nil {
+				currentStateBlock = blockNumber
+			}
+			return err
 		}
 
-		stateChan, err := sts.GetRemoteStateSnapshot(peerID)
+		// A snapshot received partially from the same peer is resumed, unless the peer has released it
+		resume := sts.snapshotResume
+		var stateChan <-chan *protos.SyncStateSnapshot
+		var err error
+		if resume != nil && resume.peerName == peerID.Name {
+			if stateChan, err = sts.resumeRemoteStateSnapshot(peerID, resume.blockNumber, resume.sequence); nil != err {
+				return err
+			}
+		}
+		if nil == stateChan {
+			resume = nil
+			sts.snapshotResume = nil
+			if err := sts.stack.EmptyState(); nil != err {
+				logger.Error("Could not empty the current state: %s", err)
+			}
+			stateChan, err = sts.GetRemoteStateSnapshot(peerID)
+		}
 
 		if err != nil {
 			return err
@@ -1128,6 +1287,10 @@ func (sts *StateTransferState) syncStateSnapshot(minBlockNumber uint64, peerIDs
 
 		timer := time.NewTimer(sts.StateSnapshotRequestTimeout)
 		counter := 0
+		var nextSequence uint64
+		if nil != resume {
+			nextSequence = resume.sequence
+		}
 
 		for {
 			select {
@@ -1135,7 +1298,18 @@ func (sts *StateTransferState) syncStateSnapshot(minBlockNumber uint64, peerIDs
 				if !ok {
 					return fmt.Errorf("%v had state snapshot channel close prematurely after %d deltas: %s", sts.id, counter, err)
 				}
+				if nil != resume {
+					if piece.BlockNumber != resume.blockNumber || piece.Sequence != resume.sequence {
+						logger.Debug("%v could not resume the state snapshot of block %d from %v, receiving a new one", sts.id, resume.blockNumber, peerID)
+						if err := sts.stack.EmptyState(); nil != err {
+							logger.Error("Could not empty the current state: %s", err)
+						}
+						nextSequence = 0
+					}
+					resume = nil
+				}
 				if 0 == len(piece.Delta) {
+					sts.snapshotResume = nil
 					stateHash, err := sts.stack.GetCurrentStateHash()
 					if nil != err {
 						sts.stateValid = false
@@ -1146,15 +1320,23 @@ func (sts *StateTransferState) syncStateSnapshot(minBlockNumber uint64, peerIDs
 					logger.Debug("%v received final piece of state snapshot from %v after %d deltas, now has hash %x", sts.id, peerID, counter, stateHash)
 					return nil
 				}
+				if piece.Sequence != nextSequence {
+					sts.snapshotResume = nil
+					return fmt.Errorf("%v received the state snapshot delta with sequence %d from %v while expecting sequence %d", sts.id, piece.Sequence, peerID, nextSequence)
+				}
 				umDelta := &statemgmt.StateDelta{}
 				if err := umDelta.Unmarshal(piece.Delta); nil != err {
+					sts.snapshotResume = nil
 					return fmt.Errorf("%v received a corrupt delta from %v after %d deltas : %s", sts.id, peerID, counter, err)
 				}
 				sts.stack.ApplyStateDelta(piece, umDelta)
 				currentStateBlock = piece.BlockNumber
 				if err := sts.stack.CommitStateDelta(piece); nil != err {
+					sts.snapshotResume = nil
 					return fmt.Errorf("%v could not commit state delta from %v after %d deltas: %s", sts.id, counter, peerID, err)
 				}
+				nextSequence++
+				sts.snapshotResume = &snapshotResumePoint{peerName: peerID.Name, blockNumber: piece.BlockNumber, sequence: nextSequence}
 				counter++
 			case <-timer.C:
 				return fmt.Errorf("%v timed out during state recovery from %v", sts.id, peerID)
@@ -1189,6 +1371,27 @@ func (sts *StateTransferState) GetRemoteStateSnapshot(replicaID *protos.PeerID)
 	return remoteLedger.RequestStateSnapshot()
 }
 
+// snapshotResumePoint is the chunk from which the stream of a state snapshot partially received from a peer can be resumed
+type snapshotResumePoint struct {
+	peerName    string
+	blockNumber uint64
+	sequence    uint64
+}
+
+// resumeRemoteStateSnapshot will return a channel to resume the stream of a state snapshot from the desired replicaID, or a nil
+// channel if the replicaID cannot resume it
+func (sts *StateTransferState) resumeRemoteStateSnapshot(replicaID *protos.PeerID, blockNumber, sequence uint64) (<-chan *protos.SyncStateSnapshot, error) {
+	remoteLedger, err := sts.stack.GetRemoteLedger(replicaID)
+	if nil != err {
+		return nil, err
+	}
+	resumer, ok := remoteLedger.(peer.StateSnapshotResumer)
+	if !ok {
+		return nil, nil
+	}
+	return resumer.ResumeStateSnapshot(blockNumber, sequence)
+}
+
 // GetRemoteStateDeltas will return a channel to stream a state snapshot deltas from the desired replicaID
 func (sts *StateTransferState) GetRemoteStateDeltas(replicaID *protos.PeerID, start, finish uint64) (<-chan *protos.SyncStateDeltas, error) {
 	remoteLedger, err := sts.stack.GetRemoteLedger(replicaID)
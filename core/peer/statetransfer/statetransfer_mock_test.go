@@ -138,6 +138,9 @@ type MockLedger struct {
 	deltaID       interface{}
 	preDeltaValue uint64
 
+	// the state deltas of the blocks the state was built with, for RewindState
+	stateDeltas map[uint64]uint64
+
 	t *testing.T
 }
 
@@ -145,6 +148,7 @@ func NewMockLedger(remoteLedgers LedgerDirectory, filter func(request mockReques
 	mock := &MockLedger{}
 	mock.mutex = &sync.Mutex{}
 	mock.blocks = make(map[uint64]*protos.Block)
+	mock.stateDeltas = make(map[uint64]uint64)
 	mock.state = 0
 	mock.blockHeight = 0
 	mock.t = t
@@ -541,6 +545,36 @@ func (mock *MockLedger) EmptyState() error {
 	return nil
 }
 
+func (mock *MockLedger) RewindState(stateBlockNumber, blockNumber uint64) error {
+	mock.mutex.Lock()
+	defer func() {
+		mock.mutex.Unlock()
+	}()
+
+	state := mock.state
+	for i := blockNumber + 1; i <= stateBlockNumber; i++ {
+		delta, ok := mock.stateDeltas[i]
+		if !ok {
+			return fmt.Errorf("The state delta of block %d is not retained", i)
+		}
+		state -= delta
+	}
+
+	block, ok := mock.blocks[blockNumber]
+	if !ok {
+		return fmt.Errorf("Block not found")
+	}
+	if !bytes.Equal(block.StateHash, []byte(fmt.Sprintf("%d", state))) {
+		return fmt.Errorf("The state rewound to block %d does not match the state hash of the block", blockNumber)
+	}
+
+	mock.state = state
+	for i := blockNumber + 1; i <= stateBlockNumber; i++ {
+		delete(mock.stateDeltas, i)
+	}
+	return nil
+}
+
 func (mock *MockLedger) GetCurrentStateHash() ([]byte, error) {
 	mock.mutex.Lock()
 	defer func() {
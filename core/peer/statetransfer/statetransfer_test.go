@@ -247,6 +247,56 @@ func TestCatchupWithoutDeltas(t *testing.T) {
 
 }
 
+func TestCatchupForkedState(t *testing.T) {
+	mrls := createRemoteLedgers(1, 3)
+
+	snapshotTransferred := false
+
+	ml := NewMockLedger(mrls, func(request mockRequest, peerID *protos.PeerID) mockResponse {
+		if request == SyncSnapshot {
+			snapshotTransferred = true
+		}
+
+		return Normal
+	}, t)
+
+	// Test from blockheight of 7, with the blocks after block 3 forked from the network's
+	for i := uint64(0); i <= 3; i++ {
+		ml.PutBlock(i, SimpleGetBlock(i))
+	}
+	ml.state = SimpleGetState(3)
+	for i := uint64(4); i <= 6; i++ {
+		forkedDelta := 10 * i
+		ml.state += forkedDelta
+		ml.stateDeltas[i] = forkedDelta
+		previousBlock, _ := ml.GetBlock(i - 1)
+		previousBlockHash, _ := ml.HashBlock(previousBlock)
+		ml.PutBlock(i, &protos.Block{
+			Transactions:      []*protos.Transaction{{Payload: SimpleEncodeUint64(forkedDelta)}},
+			ConsensusMetadata: SimpleGetConsensusMetadata(i),
+			StateHash:         []byte(fmt.Sprintf("%d", ml.state)),
+			PreviousBlockHash: previousBlockHash,
+		})
+	}
+
+	sts := newTestStateTransfer(ml, mrls)
+	defer sts.Stop()
+
+	if err := executeStateTransfer(sts, ml, 9, 10, mrls); nil != err {
+		t.Fatalf("Forked state case: %s", err)
+	}
+
+	if snapshotTransferred {
+		t.Fatalf("The forked state should have been rewound rather than retrieved as a whole")
+	}
+
+	for i := uint64(4); i <= 6; i++ {
+		if block, _ := ml.GetBlock(i); !bytes.Equal(SimpleHashBlock(block), SimpleGetBlockHash(i)) {
+			t.Fatalf("The forked block %d should have been replaced by the network's", i)
+		}
+	}
+}
+
 func TestCatchupSyncBlocksErrors(t *testing.T) {
 	for _, failureType := range AllFailures {
 		mrls := createRemoteLedgers(1, 3)
@@ -0,0 +1,175 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package peer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+
+	"github.com/golang/snappy"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// SyncCodec compresses and decompresses the state delta payloads exchanged
+// during state sync.
+type SyncCodec interface {
+	Compress(data []byte) ([]byte, error)
+	Decompress(data []byte) ([]byte, error)
+}
+
+var syncCodecsLock sync.RWMutex
+var syncCodecs = map[pb.SyncCompression]SyncCodec{
+	pb.SyncCompression_NONE:   noneSyncCodec{},
+	pb.SyncCompression_GZIP:   gzipSyncCodec{},
+	pb.SyncCompression_SNAPPY: snappySyncCodec{},
+}
+
+// RegisterSyncCodec makes a codec available for state sync. No zstd library
+// is vendored, hence, ZSTD is only available once a codec is registered this
+// way. Only registered codecs are advertised to other peers, and the codecs
+// have to be registered before the configuration of the peer is cached (see
+// CacheConfiguration), e.g. from an init function.
+func RegisterSyncCodec(compression pb.SyncCompression, codec SyncCodec) {
+	syncCodecsLock.Lock()
+	defer syncCodecsLock.Unlock()
+	syncCodecs[compression] = codec
+}
+
+func getSyncCodec(compression pb.SyncCompression) (SyncCodec, error) {
+	syncCodecsLock.RLock()
+	defer syncCodecsLock.RUnlock()
+	codec, ok := syncCodecs[compression]
+	if !ok {
+		return nil, fmt.Errorf("No codec registered for sync compression %s", compression)
+	}
+	return codec, nil
+}
+
+// parseSyncCompressions converts the configured codec names into the list
+// of codecs advertised in the HelloMessage. Unknown or unregistered codecs
+// are skipped with a warning. NONE is always appended as the last resort so
+// that peers can talk to each other even without a common codec.
+func parseSyncCompressions(names []string) []pb.SyncCompression {
+	var compressions []pb.SyncCompression
+	seen := make(map[pb.SyncCompression]bool)
+	for _, name := range names {
+		value, ok := pb.SyncCompression_value[strings.ToUpper(strings.TrimSpace(name))]
+		if !ok {
+			peerLogger.Warning("Ignoring unknown sync compression codec [%s]", name)
+			continue
+		}
+		compression := pb.SyncCompression(value)
+		if _, err := getSyncCodec(compression); err != nil {
+			peerLogger.Warning("Ignoring sync compression codec [%s]: %s", name, err)
+			continue
+		}
+		if !seen[compression] {
+			seen[compression] = true
+			compressions = append(compressions, compression)
+		}
+	}
+	if !seen[pb.SyncCompression_NONE] {
+		compressions = append(compressions, pb.SyncCompression_NONE)
+	}
+	return compressions
+}
+
+// negotiateSyncCompression returns the first of the local codecs, in order of
+// local preference, which the remote peer also supports
+func negotiateSyncCompression(local, remote []pb.SyncCompression) pb.SyncCompression {
+	for _, l := range local {
+		for _, r := range remote {
+			if l == r {
+				return l
+			}
+		}
+	}
+	return pb.SyncCompression_NONE
+}
+
+func compressSyncPayload(compression pb.SyncCompression, data []byte) ([]byte, error) {
+	codec, err := getSyncCodec(compression)
+	if err != nil {
+		return nil, err
+	}
+	return codec.Compress(data)
+}
+
+func decompressSyncPayload(compression pb.SyncCompression, data []byte) ([]byte, error) {
+	codec, err := getSyncCodec(compression)
+	if err != nil {
+		return nil, err
+	}
+	return codec.Decompress(data)
+}
+
+type noneSyncCodec struct{}
+
+func (noneSyncCodec) Compress(data []byte) ([]byte, error) {
+	return data, nil
+}
+
+func (noneSyncCodec) Decompress(data []byte) ([]byte, error) {
+	return data, nil
+}
+
+type gzipSyncCodec struct{}
+
+func (gzipSyncCodec) Compress(data []byte) ([]byte, error) {
+	var buffer bytes.Buffer
+	writer := gzip.NewWriter(&buffer)
+	if _, err := writer.Write(data); err != nil {
+		return nil, fmt.Errorf("Error compressing sync payload: %s", err)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("Error compressing sync payload: %s", err)
+	}
+	return buffer.Bytes(), nil
+}
+
+func (gzipSyncCodec) Decompress(data []byte) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("Error decompressing sync payload: %s", err)
+	}
+	defer reader.Close()
+	decompressed, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("Error decompressing sync payload: %s", err)
+	}
+	return decompressed, nil
+}
+
+// snappySyncCodec uses the block format of snappy, which compresses less than
+// gzip but is several times faster
+type snappySyncCodec struct{}
+
+func (snappySyncCodec) Compress(data []byte) ([]byte, error) {
+	return snappy.Encode(nil, data), nil
+}
+
+func (snappySyncCodec) Decompress(data []byte) ([]byte, error) {
+	decompressed, err := snappy.Decode(nil, data)
+	if err != nil {
+		return nil, fmt.Errorf("Error decompressing sync payload: %s", err)
+	}
+	return decompressed, nil
+}
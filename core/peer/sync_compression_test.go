@@ -0,0 +1,66 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package peer
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+func TestSyncCompressionRoundTrip(t *testing.T) {
+	payload := bytes.Repeat([]byte("chaincodeID key value "), 100)
+	for _, compression := range []pb.SyncCompression{pb.SyncCompression_NONE, pb.SyncCompression_GZIP, pb.SyncCompression_SNAPPY} {
+		compressed, err := compressSyncPayload(compression, payload)
+		if err != nil {
+			t.Fatalf("Error compressing with %s: %s", compression, err)
+		}
+		decompressed, err := decompressSyncPayload(compression, compressed)
+		if err != nil {
+			t.Fatalf("Error decompressing with %s: %s", compression, err)
+		}
+		if !bytes.Equal(payload, decompressed) {
+			t.Fatalf("Payload altered by %s round trip", compression)
+		}
+	}
+	if _, err := compressSyncPayload(pb.SyncCompression_ZSTD, payload); err == nil {
+		t.Fatalf("Expected an error for an unregistered codec")
+	}
+	if _, err := decompressSyncPayload(pb.SyncCompression_SNAPPY, payload); err == nil {
+		t.Fatalf("Expected an error for a corrupted snappy payload")
+	}
+}
+
+func TestSyncCompressionNegotiation(t *testing.T) {
+	// zstd is not advertised as no codec is registered for it
+	local := parseSyncCompressions([]string{"zstd", "snappy", "gzip", "bogus"})
+	if expected := []pb.SyncCompression{pb.SyncCompression_SNAPPY, pb.SyncCompression_GZIP, pb.SyncCompression_NONE}; !reflect.DeepEqual(local, expected) {
+		t.Fatalf("Expected %v, got %v", expected, local)
+	}
+	if c := negotiateSyncCompression(local, []pb.SyncCompression{pb.SyncCompression_NONE, pb.SyncCompression_GZIP}); c != pb.SyncCompression_GZIP {
+		t.Fatalf("Expected GZIP, got %s", c)
+	}
+	if c := negotiateSyncCompression(local, []pb.SyncCompression{pb.SyncCompression_GZIP, pb.SyncCompression_SNAPPY}); c != pb.SyncCompression_SNAPPY {
+		t.Fatalf("Expected SNAPPY, got %s", c)
+	}
+	// Peers which do not advertise any codec only understand uncompressed payloads
+	if c := negotiateSyncCompression(local, nil); c != pb.SyncCompression_NONE {
+		t.Fatalf("Expected NONE, got %s", c)
+	}
+}
@@ -0,0 +1,189 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package peer
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt/state"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// snapshotStream reads a state snapshot for a peer in chunks of key-values, numbered
+// from 0. The last chunks read are kept so that the peer can resume the stream from
+// any of them after a dropped connection.
+type snapshotStream struct {
+	snapshot     *state.StateSnapshot
+	nextSequence uint64
+	window       [][]byte // the chunks with sequences nextSequence-len(window) to nextSequence-1
+	windowSize   int
+	exhausted    bool
+	expiry       *time.Timer
+}
+
+func newSnapshotStream(snapshot *state.StateSnapshot, windowSize int) *snapshotStream {
+	return &snapshotStream{snapshot: snapshot, windowSize: windowSize}
+}
+
+func (ss *snapshotStream) blockNumber() uint64 {
+	return ss.snapshot.GetBlockNumber()
+}
+
+// canResumeFrom returns whether the chunk with the given sequence can still be sent
+func (ss *snapshotStream) canResumeFrom(sequence uint64) bool {
+	return sequence <= ss.nextSequence && ss.nextSequence-sequence <= uint64(len(ss.window))
+}
+
+// chunk returns the marshalled state delta of the chunk with the given sequence, which
+// cannot be older than the kept chunks, or nil once the snapshot is exhausted
+func (ss *snapshotStream) chunk(sequence uint64, chunkSize int) []byte {
+	if sequence < ss.nextSequence {
+		return ss.window[len(ss.window)-int(ss.nextSequence-sequence)]
+	}
+	if ss.exhausted {
+		return nil
+	}
+	delta := statemgmt.NewStateDelta()
+	size := 0
+	for size < chunkSize && ss.snapshot.Next() {
+		k, v := ss.snapshot.GetRawKeyValue()
+		chaincodeID, key := statemgmt.DecodeCompositeKey(k)
+		delta.Set(chaincodeID, key, v, nil)
+		size += len(k) + len(v)
+	}
+	if delta.IsEmpty() {
+		ss.exhausted = true
+		return nil
+	}
+	deltaBytes := delta.Marshal()
+	ss.window = append(ss.window, deltaBytes)
+	if len(ss.window) > ss.windowSize {
+		ss.window = ss.window[len(ss.window)-ss.windowSize:]
+	}
+	ss.nextSequence++
+	return deltaBytes
+}
+
+func (ss *snapshotStream) release() {
+	if ss.expiry != nil {
+		ss.expiry.Stop()
+	}
+	ss.snapshot.Release()
+}
+
+// checkSnapshotRequester returns an error unless the remote peer may be sent a state snapshot.
+// The peer has to be registered, i.e. identified by its hello message, whose signature is
+// verified against its PKI ID when security is enabled, and has to be a validating peer, as
+// only validating peers transfer the state.
+func checkSnapshotRequester(endpoint *pb.PeerEndpoint, registered bool, securityEnabled bool) error {
+	if !registered || endpoint == nil || endpoint.ID == nil {
+		return fmt.Errorf("The peer has not been identified by a hello message")
+	}
+	if securityEnabled && len(endpoint.PkiID) == 0 {
+		return fmt.Errorf("Peer %s has no PKI ID to authenticate it", endpoint.ID.Name)
+	}
+	if endpoint.Type != pb.PeerEndpoint_VALIDATOR {
+		return fmt.Errorf("Peer %s is not a validating peer", endpoint.ID.Name)
+	}
+	return nil
+}
+
+// snapshotStreams keeps, for each peer, the snapshot stream which stopped before being
+// completed, until the peer resumes it or the retention expires
+type snapshotStreams struct {
+	sync.Mutex
+	streams map[string]*snapshotStream
+}
+
+var retainedSnapshotStreams = &snapshotStreams{streams: make(map[string]*snapshotStream)}
+
+// open returns the stream of the snapshot requested by the peer, along with the sequence of the
+// first chunk to send. The retained stream of the peer is resumed if the request asks for it
+// and its chunks are still available, otherwise the stream of a new snapshot starts at 0.
+func (ss *snapshotStreams) open(peerName string, request *pb.SyncStateSnapshotRequest, newSnapshot func() (*state.StateSnapshot, error), windowSize int) (*snapshotStream, uint64, error) {
+	ss.Lock()
+	stream := ss.streams[peerName]
+	delete(ss.streams, peerName)
+	ss.Unlock()
+
+	if stream != nil {
+		if request.StartSequence > 0 && stream.blockNumber() == request.BlockNumber && stream.canResumeFrom(request.StartSequence) {
+			stream.expiry.Stop()
+			return stream, request.StartSequence, nil
+		}
+		stream.release()
+	}
+	if request.StartSequence > 0 {
+		peerLogger.Debug("Cannot resume the state snapshot of block %d from sequence %d for %s, sending a new snapshot", request.BlockNumber, request.StartSequence, peerName)
+	}
+	snapshot, err := newSnapshot()
+	if err != nil {
+		return nil, 0, err
+	}
+	return newSnapshotStream(snapshot, windowSize), 0, nil
+}
+
+// retain keeps the stream for the peer to resume it within the retention, replacing any
+// other stream retained for the peer
+func (ss *snapshotStreams) retain(peerName string, stream *snapshotStream, retention time.Duration) {
+	if peerName == "" || retention <= 0 || stream.windowSize <= 0 {
+		stream.release()
+		return
+	}
+	ss.Lock()
+	defer ss.Unlock()
+	if retained := ss.streams[peerName]; retained != nil {
+		retained.release()
+	}
+	ss.streams[peerName] = stream
+	stream.expiry = time.AfterFunc(retention, func() {
+		ss.Lock()
+		defer ss.Unlock()
+		if ss.streams[peerName] == stream {
+			delete(ss.streams, peerName)
+			stream.release()
+		}
+	})
+}
+
+// bandwidthThrottle spaces the messages sent so that, on average, no more than a given
+// number of bytes per second are sent over all the streams sharing it
+type bandwidthThrottle struct {
+	sync.Mutex
+	next time.Time
+}
+
+var snapshotThrottle = &bandwidthThrottle{}
+
+// wait blocks until the given number of bytes can be sent, bytesPerSecond <= 0 meaning unlimited
+func (bt *bandwidthThrottle) wait(size int, bytesPerSecond int) {
+	if bytesPerSecond <= 0 {
+		return
+	}
+	bt.Lock()
+	now := time.Now()
+	if bt.next.Before(now) {
+		bt.next = now
+	}
+	delay := bt.next.Sub(now)
+	bt.next = bt.next.Add(time.Duration(size) * time.Second / time.Duration(bytesPerSecond))
+	bt.Unlock()
+	time.Sleep(delay)
+}
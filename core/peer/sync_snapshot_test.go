@@ -0,0 +1,128 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package peer
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric/core/ledger"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+	"github.com/hyperledger/fabric/core/ledger/testutil"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+func TestSnapshotStreamResume(t *testing.T) {
+	l := ledger.InitTestLedger(t)
+	testutil.AssertNoError(t, l.BeginTxBatch(0), "Error beginning tx batch")
+	l.TxBegin("txUuid")
+	for i := 0; i < 10; i++ {
+		testutil.AssertNoError(t, l.SetState("chaincode1", fmt.Sprintf("key%d", i), []byte(fmt.Sprintf("value%d", i))), "Error setting state")
+	}
+	l.TxFinished("txUuid", true)
+	tx, err := pb.NewTransaction(pb.ChaincodeID{Path: "testUrl"}, "txUuid", "anyfunction", []string{"param1"})
+	testutil.AssertNoError(t, err, "Error creating transaction")
+	testutil.AssertNoError(t, l.CommitTxBatch(0, []*pb.Transaction{tx}, nil, []byte("proof")), "Error committing tx batch")
+
+	streams := &snapshotStreams{streams: make(map[string]*snapshotStream)}
+	stream, sequence, err := streams.open("vp1", &pb.SyncStateSnapshotRequest{}, l.GetStateSnapshot, 3)
+	testutil.AssertNoError(t, err, "Error opening snapshot stream")
+	testutil.AssertEquals(t, sequence, uint64(0))
+
+	// Chunks of two key-values
+	var chunks [][]byte
+	for chunk := stream.chunk(0, 30); chunk != nil; chunk = stream.chunk(uint64(len(chunks)), 30) {
+		chunks = append(chunks, chunk)
+		if len(chunks) == 4 {
+			break
+		}
+	}
+	testutil.AssertEquals(t, len(chunks), 4)
+
+	// The stream is resumed from a chunk which is still kept
+	streams.retain("vp1", stream, time.Minute)
+	resumed, sequence, err := streams.open("vp1", &pb.SyncStateSnapshotRequest{BlockNumber: 0, StartSequence: 2}, l.GetStateSnapshot, 3)
+	testutil.AssertNoError(t, err, "Error resuming snapshot stream")
+	if resumed != stream || sequence != 2 {
+		t.Fatalf("Expected the retained stream to be resumed from sequence 2, got sequence %d", sequence)
+	}
+	if !bytes.Equal(resumed.chunk(2, 30), chunks[2]) || !bytes.Equal(resumed.chunk(3, 30), chunks[3]) {
+		t.Fatalf("Expected the kept chunks to be sent again")
+	}
+	delta := statemgmt.NewStateDelta()
+	for _, chunk := range chunks[:2] {
+		chunkDelta := &statemgmt.StateDelta{}
+		testutil.AssertNoError(t, chunkDelta.Unmarshal(chunk), "Error unmarshalling chunk")
+		delta.ApplyChanges(chunkDelta)
+	}
+	for sequence = 2; ; sequence++ {
+		chunk := resumed.chunk(sequence, 30)
+		if chunk == nil {
+			break
+		}
+		chunkDelta := &statemgmt.StateDelta{}
+		testutil.AssertNoError(t, chunkDelta.Unmarshal(chunk), "Error unmarshalling chunk")
+		delta.ApplyChanges(chunkDelta)
+	}
+	testutil.AssertEquals(t, sequence, uint64(5))
+	testutil.AssertEquals(t, len(delta.GetUpdates("chaincode1")), 10)
+
+	// A chunk which is no longer kept cannot be sent again, a new snapshot is streamed
+	streams.retain("vp1", resumed, time.Minute)
+	restarted, sequence, err := streams.open("vp1", &pb.SyncStateSnapshotRequest{BlockNumber: 0, StartSequence: 1}, l.GetStateSnapshot, 3)
+	testutil.AssertNoError(t, err, "Error opening snapshot stream")
+	if restarted == resumed || sequence != 0 {
+		t.Fatalf("Expected a new stream from sequence 0, got sequence %d", sequence)
+	}
+
+	// The retained stream is released once the retention expires
+	streams.retain("vp1", restarted, 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	streams.Lock()
+	testutil.AssertEquals(t, len(streams.streams), 0)
+	streams.Unlock()
+}
+
+func TestCheckSnapshotRequester(t *testing.T) {
+	validator := &pb.PeerEndpoint{ID: &pb.PeerID{Name: "vp1"}, Type: pb.PeerEndpoint_VALIDATOR, PkiID: []byte("pki")}
+	testutil.AssertNoError(t, checkSnapshotRequester(validator, true, true), "Expected a registered validating peer to be allowed")
+	testutil.AssertError(t, checkSnapshotRequester(validator, false, true), "Expected an unregistered peer to be refused")
+	testutil.AssertError(t, checkSnapshotRequester(nil, true, false), "Expected an unidentified peer to be refused")
+	nonValidator := &pb.PeerEndpoint{ID: &pb.PeerID{Name: "nvp1"}, Type: pb.PeerEndpoint_NON_VALIDATOR, PkiID: []byte("pki")}
+	testutil.AssertError(t, checkSnapshotRequester(nonValidator, true, true), "Expected a non-validating peer to be refused")
+	anonymous := &pb.PeerEndpoint{ID: &pb.PeerID{Name: "vp2"}, Type: pb.PeerEndpoint_VALIDATOR}
+	testutil.AssertError(t, checkSnapshotRequester(anonymous, true, true), "Expected a peer without PKI ID to be refused with security enabled")
+	testutil.AssertNoError(t, checkSnapshotRequester(anonymous, true, false), "Expected a peer without PKI ID to be allowed with security disabled")
+}
+
+func TestBandwidthThrottle(t *testing.T) {
+	throttle := &bandwidthThrottle{}
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		throttle.wait(100, 1000)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Fatalf("Expected 300 bytes at 1000 bytes per second to take at least 200ms, took %s", elapsed)
+	}
+	start = time.Now()
+	throttle.wait(1000000, 0)
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Fatalf("Expected no throttling, took %s", elapsed)
+	}
+}
@@ -17,21 +17,28 @@ limitations under the License.
 package rest
 
 import (
-	"errors"
-	"fmt"
+	"sort"
+	"time"
 
 	"golang.org/x/net/context"
 
 	google_protobuf1 "google/protobuf"
 
 	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/db"
+	fabricerrors "github.com/hyperledger/fabric/core/errors"
 	"github.com/hyperledger/fabric/core/ledger"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt/state"
 	pb "github.com/hyperledger/fabric/protos"
 )
 
 var (
 	// ErrNotFound is returned if a requested resource does not exist
-	ErrNotFound = errors.New("openchain: resource not found")
+	ErrNotFound = fabricerrors.New(fabricerrors.NotFound, "openchain: resource not found")
+	// ErrNotReady is returned if a block listing is filtered while the ledger is
+	// still indexing the blocks persisted before the secondary indexes existed
+	ErrNotReady = fabricerrors.New(fabricerrors.NotReady, "openchain: block indexes are being built")
 )
 
 // PeerInfo defines API to peer info data
@@ -78,7 +85,7 @@ func NewOpenchainServerWithPeerInfo(peerServer PeerInfo) (*ServerOpenchain, erro
 func (s *ServerOpenchain) GetBlockchainInfo(ctx context.Context, e *google_protobuf1.Empty) (*pb.BlockchainInfo, error) {
 	blockchainInfo, err := s.ledger.GetBlockchainInfo()
 	if blockchainInfo.Height == 0 {
-		return nil, fmt.Errorf("No blocks in blockchain.")
+		return nil, fabricerrors.New(fabricerrors.NotFound, "No blocks in blockchain.")
 	}
 	return blockchainInfo, err
 }
@@ -92,32 +99,38 @@ func (s *ServerOpenchain) GetBlockByNumber(ctx context.Context, num *pb.BlockNum
 		case ledger.ErrOutOfBounds:
 			return nil, ErrNotFound
 		default:
-			return nil, fmt.Errorf("Error retrieving block from blockchain: %s", err)
+			return nil, fabricerrors.Errorf(fabricerrors.CodeOf(err), "Error retrieving block from blockchain: %s", err)
 		}
 	}
 
-	// Remove payload from deploy transactions. This is done to make rest api
-	// calls more lightweight as the payload for these types of transactions
-	// can be very large. If the payload is needed, the caller should fetch the
-	// individual transaction.
+	if err := removeDeployPayloads(block); err != nil {
+		return nil, err
+	}
+	return block, nil
+}
+
+// removeDeployPayloads removes the code package from deploy and upgrade transactions. This is
+// done to make rest api calls more lightweight as the payload for these types of
+// transactions can be very large. If the payload is needed, the caller should fetch
+// the individual transaction.
+func removeDeployPayloads(block *pb.Block) error {
 	blockTransactions := block.GetTransactions()
 	for _, transaction := range blockTransactions {
-		if transaction.Type == pb.Transaction_CHAINCODE_DEPLOY {
+		if transaction.Type == pb.Transaction_CHAINCODE_DEPLOY || transaction.Type == pb.Transaction_CHAINCODE_UPGRADE {
 			deploymentSpec := &pb.ChaincodeDeploymentSpec{}
 			err := proto.Unmarshal(transaction.Payload, deploymentSpec)
 			if err != nil {
-				return nil, err
+				return err
 			}
 			deploymentSpec.CodePackage = nil
 			deploymentSpecBytes, err := proto.Marshal(deploymentSpec)
 			if err != nil {
-				return nil, err
+				return err
 			}
 			transaction.Payload = deploymentSpecBytes
 		}
 	}
-
-	return block, nil
+	return nil
 }
 
 // GetBlockCount returns the current number of blocks in the blockchain data
@@ -134,7 +147,263 @@ func (s *ServerOpenchain) GetBlockCount(ctx context.Context, e *google_protobuf1
 		return count, nil
 	}
 
-	return nil, fmt.Errorf("No blocks in blockchain.")
+	return nil, fabricerrors.New(fabricerrors.NotFound, "No blocks in blockchain.")
+}
+
+// GetStateHashDetail returns the crypto-hash of the committed world state along
+// with the crypto-hashes of the children of the root of the state tree.
+func (s *ServerOpenchain) GetStateHashDetail(ctx context.Context, e *google_protobuf1.Empty) (*pb.StateHashDetail, error) {
+	detail, err := s.ledger.GetRootStateHashDetail()
+	if err != nil {
+		return nil, fabricerrors.Errorf(fabricerrors.CodeOf(err), "Error retrieving state hash detail: %s", err)
+	}
+	return ledger.ToStateHashDetail(detail), nil
+}
+
+// stateSnapshotChunkSize is the maximum number of key-values sent in a StateSnapshotChunk
+const stateSnapshotChunkSize = 1000
+
+// GetStateSnapshot streams the key-values of the committed world state, restricted to the
+// subtrees of the requested children of the root of the state tree, if any.
+func (s *ServerOpenchain) GetStateSnapshot(req *pb.StateSnapshotRequest, stream pb.Openchain_GetStateSnapshotServer) error {
+	var snapshot *state.StateSnapshot
+	var err error
+	if len(req.Children) == 0 {
+		snapshot, err = s.ledger.GetStateSnapshot()
+	} else {
+		children := make([]int, len(req.Children))
+		for i, child := range req.Children {
+			children[i] = int(child)
+		}
+		snapshot, err = s.ledger.GetStateSubtreeSnapshot(children)
+	}
+	if err != nil {
+		return fabricerrors.Errorf(fabricerrors.CodeOf(err), "Error retrieving state snapshot: %s", err)
+	}
+	defer snapshot.Release()
+	chunk := &pb.StateSnapshotChunk{BlockNumber: snapshot.GetBlockNumber()}
+	for snapshot.Next() {
+		k, v := snapshot.GetRawKeyValue()
+		chaincodeID, key := statemgmt.DecodeCompositeKey(k)
+		chunk.KeyValues = append(chunk.KeyValues, &pb.StateKeyValue{ChaincodeID: chaincodeID, Key: key, Value: v})
+		if len(chunk.KeyValues) == stateSnapshotChunkSize {
+			if err := stream.Send(chunk); err != nil {
+				return err
+			}
+			chunk = &pb.StateSnapshotChunk{BlockNumber: snapshot.GetBlockNumber()}
+		}
+	}
+	// the last chunk is sent even if empty so that the block number is always received
+	return stream.Send(chunk)
+}
+
+// GetBlocks streams the blocks of the given range. Blocks are only read once the
+// previous one has been handed over to gRPC, so a slow client throttles the reads
+// through the flow control of the stream.
+func (s *ServerOpenchain) GetBlocks(blockRange *pb.BlockRange, stream pb.Openchain_GetBlocksServer) error {
+	itr, err := s.ledger.GetBlocks(blockRange.Start, blockRange.End)
+	if err != nil {
+		if err == ledger.ErrOutOfBounds {
+			return ErrNotFound
+		}
+		return fabricerrors.Errorf(fabricerrors.CodeOf(err), "Error retrieving blocks: %s", err)
+	}
+	for itr.Next() {
+		_, block := itr.GetBlock()
+		if err := removeDeployPayloads(block); err != nil {
+			return err
+		}
+		if err := stream.Send(block); err != nil {
+			return err
+		}
+	}
+	if err := itr.Err(); err != nil {
+		return fabricerrors.Errorf(fabricerrors.CodeOf(err), "Error retrieving blocks: %s", err)
+	}
+	return nil
+}
+
+const (
+	// DefaultBlockPageSize is the number of blocks listed by ListBlocks when no limit is given
+	DefaultBlockPageSize = 10
+	// MaxBlockPageSize is the maximum number of blocks listed by ListBlocks
+	MaxBlockPageSize = 100
+)
+
+// BlockQuery selects the blocks listed by ListBlocks
+type BlockQuery struct {
+	// ChaincodeID, if not empty, restricts the listing to the blocks holding
+	// transactions for the chaincode with this name
+	ChaincodeID string
+	// StartTime and EndTime, if not nil, restrict the listing to the blocks
+	// whose timestamp is within [StartTime, EndTime)
+	StartTime *time.Time
+	EndTime   *time.Time
+	// Cursor is the number of the block from which the listing goes backwards.
+	// If nil the listing starts from the last block.
+	Cursor *uint64
+	// Limit is the maximum number of blocks listed, see DefaultBlockPageSize and MaxBlockPageSize
+	Limit int
+	// OmitPayloads strips the payloads of all the transactions
+	OmitPayloads bool
+}
+
+// NumberedBlock is a block along with its number in the blockchain
+type NumberedBlock struct {
+	Number uint64    `json:"number"`
+	Block  *pb.Block `json:"block"`
+}
+
+// BlockPage is a page of the blocks listed by ListBlocks, from the most recent
+// block to the oldest one. NextCursor, if not nil, is the cursor to pass for
+// the next page.
+type BlockPage struct {
+	Blocks     []*NumberedBlock `json:"blocks"`
+	NextCursor *uint64          `json:"nextCursor,omitempty"`
+}
+
+// ListBlocks returns a page of the blocks selected by the query, from the most
+// recent block to the oldest one. Filtering by chaincode or by time range
+// relies on the secondary indexes of the ledger.
+func (s *ServerOpenchain) ListBlocks(ctx context.Context, query *BlockQuery) (*BlockPage, error) {
+	limit := query.Limit
+	if limit <= 0 {
+		limit = DefaultBlockPageSize
+	} else if limit > MaxBlockPageSize {
+		limit = MaxBlockPageSize
+	}
+	page := &BlockPage{Blocks: []*NumberedBlock{}}
+	size := s.ledger.GetBlockchainSize()
+	if size == 0 {
+		return page, nil
+	}
+	cursor := size - 1
+	if query.Cursor != nil && *query.Cursor < cursor {
+		cursor = *query.Cursor
+	}
+
+	var blockNumbers []uint64
+	if query.ChaincodeID == "" && query.StartTime == nil && query.EndTime == nil {
+		for n := cursor; len(blockNumbers) <= limit; n-- {
+			blockNumbers = append(blockNumbers, n)
+			if n == 0 {
+				break
+			}
+		}
+	} else {
+		var err error
+		blockNumbers, err = s.getFilteredBlockNumbers(query, cursor, limit+1)
+		if err != nil {
+			if err == ledger.ErrSecondaryIndexesNotReady {
+				return nil, ErrNotReady
+			}
+			return nil, fabricerrors.Errorf(fabricerrors.CodeOf(err), "Error querying the block indexes: %s", err)
+		}
+	}
+	if len(blockNumbers) > limit {
+		page.NextCursor = &blockNumbers[limit]
+		blockNumbers = blockNumbers[:limit]
+	}
+
+	for _, n := range blockNumbers {
+		block, err := s.ledger.GetBlockByNumber(n)
+		if err != nil {
+			return nil, fabricerrors.Errorf(fabricerrors.CodeOf(err), "Error retrieving block from blockchain: %s", err)
+		}
+		if query.OmitPayloads {
+			for _, tx := range block.GetTransactions() {
+				tx.Payload = nil
+			}
+		} else if err := removeDeployPayloads(block); err != nil {
+			return nil, err
+		}
+		page.Blocks = append(page.Blocks, &NumberedBlock{n, block})
+	}
+	return page, nil
+}
+
+// getFilteredBlockNumbers returns, in decreasing order, at most max numbers of
+// blocks selected by the filters of the query and not higher than cursor
+func (s *ServerOpenchain) getFilteredBlockNumbers(query *BlockQuery, cursor uint64, max int) ([]uint64, error) {
+	var selected map[uint64]bool
+	if query.ChaincodeID != "" {
+		blockNumbers, err := s.ledger.GetBlockNumbersByChaincodeID(query.ChaincodeID)
+		if err != nil {
+			return nil, err
+		}
+		selected = make(map[uint64]bool)
+		for _, n := range blockNumbers {
+			selected[n] = true
+		}
+	}
+	if query.StartTime != nil || query.EndTime != nil {
+		start, end := time.Unix(0, 0), time.Now().Add(time.Hour)
+		if query.StartTime != nil {
+			start = *query.StartTime
+		}
+		if query.EndTime != nil {
+			end = *query.EndTime
+		}
+		blockNumbers, err := s.ledger.GetBlockNumbersByTimeRange(start, end)
+		if err != nil {
+			return nil, err
+		}
+		inRange := make(map[uint64]bool)
+		for _, n := range blockNumbers {
+			if selected == nil || selected[n] {
+				inRange[n] = true
+			}
+		}
+		selected = inRange
+	}
+
+	var result uint64Slice
+	for n := range selected {
+		if n <= cursor {
+			result = append(result, n)
+		}
+	}
+	sort.Sort(sort.Reverse(result))
+	if len(result) > max {
+		result = result[:max]
+	}
+	return result, nil
+}
+
+type uint64Slice []uint64
+
+func (s uint64Slice) Len() int           { return len(s) }
+func (s uint64Slice) Less(i, j int) bool { return s[i] < s[j] }
+func (s uint64Slice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// SubscribeStateDeltas streams the state delta of each block from the requested one on,
+// waiting for the next blocks to be committed once the end of the chain is reached. The
+// stream fails with an error of code StateDeltaMissing at a block whose state delta is not available, either
+// because it is older than the last ledger.state.deltaHistorySize blocks or because the
+// block was received by state transfer, in which case the subscriber has to read a state
+// snapshot before subscribing again.
+func (s *ServerOpenchain) SubscribeStateDeltas(req *pb.StateDeltasSubscription, stream pb.Openchain_SubscribeStateDeltasServer) error {
+	blockNumber := req.FromBlock
+	for {
+		committed := s.ledger.NextBlockCommitted()
+		for ; blockNumber < s.ledger.GetBlockchainSize(); blockNumber++ {
+			delta, err := s.ledger.GetStateDelta(blockNumber)
+			if err != nil {
+				return fabricerrors.Errorf(fabricerrors.CodeOf(err), "Error retrieving the state delta of block %d: %s", blockNumber, err)
+			}
+			if delta == nil {
+				return fabricerrors.Errorf(fabricerrors.StateDeltaMissing, "The state delta of block %d is not retained", blockNumber)
+			}
+			if err := stream.Send(ledger.ToBlockStateDelta(blockNumber, delta, req.ChaincodeID, req.KeyPrefix)); err != nil {
+				return err
+			}
+		}
+		select {
+		case <-committed:
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		}
+	}
 }
 
 // GetState returns the value for a particular chaincode ID and key
@@ -142,6 +411,110 @@ func (s *ServerOpenchain) GetState(ctx context.Context, chaincodeID, key string)
 	return s.ledger.GetState(chaincodeID, key, true)
 }
 
+const (
+	// DefaultStatePageSize is the number of key-values listed by ListState when no limit is given
+	DefaultStatePageSize = 100
+	// MaxStatePageSize is the maximum number of key-values listed by ListState
+	MaxStatePageSize = 1000
+)
+
+// StateQuery selects the key-values listed by ListState
+type StateQuery struct {
+	ChaincodeID string
+	// StartKey and EndKey restrict the listing to the keys within [StartKey, EndKey].
+	// If EndKey is empty the listing goes up to the last key of the chaincode
+	StartKey string
+	EndKey   string
+	// Cursor is the key from which the listing continues. If empty the listing starts
+	// from StartKey
+	Cursor string
+	// Limit is the maximum number of key-values listed, see DefaultStatePageSize and MaxStatePageSize
+	Limit int
+}
+
+// StateKeyValue is a key of the committed world state along with its value
+type StateKeyValue struct {
+	Key   string
+	Value []byte
+}
+
+// StatePage is a page of the key-values listed by ListState, in the lexical order of
+// the keys. NextCursor, if not empty, is the cursor to pass for the next page.
+type StatePage struct {
+	KeyValues  []*StateKeyValue
+	NextCursor string
+}
+
+// ListState returns a page of the committed key-values of the chaincode selected by
+// the query, read through the range scan iterator of the state
+func (s *ServerOpenchain) ListState(ctx context.Context, query *StateQuery) (*StatePage, error) {
+	limit := query.Limit
+	if limit <= 0 {
+		limit = DefaultStatePageSize
+	} else if limit > MaxStatePageSize {
+		limit = MaxStatePageSize
+	}
+	startKey := query.StartKey
+	if query.Cursor > startKey {
+		startKey = query.Cursor
+	}
+	itr, err := s.ledger.GetStateRangeScanIterator(query.ChaincodeID, startKey, query.EndKey, true)
+	if err != nil {
+		return nil, fabricerrors.Errorf(fabricerrors.CodeOf(err), "Error scanning the state: %s", err)
+	}
+	defer itr.Close()
+	page := &StatePage{KeyValues: []*StateKeyValue{}}
+	for itr.Next() {
+		key, value := itr.GetKeyValue()
+		if len(page.KeyValues) == limit {
+			page.NextCursor = key
+			break
+		}
+		page.KeyValues = append(page.KeyValues, &StateKeyValue{key, value})
+	}
+	return page, nil
+}
+
+// GetStateHistory returns the values taken by the key at the end of the committed blocks
+// for which the state delta is retained, oldest first
+func (s *ServerOpenchain) GetStateHistory(ctx context.Context, chaincodeID, key string) ([]*pb.KeyModification, error) {
+	modifications, err := s.ledger.GetHistoryForKey(chaincodeID, key)
+	if err != nil {
+		return nil, fabricerrors.Errorf(fabricerrors.CodeOf(err), "Error retrieving the history of the key: %s", err)
+	}
+	return modifications, nil
+}
+
+// GetKeyProvenance returns the changes made to the key by the transactions of the committed
+// blocks for which the state delta is retained, oldest first
+func (s *ServerOpenchain) GetKeyProvenance(ctx context.Context, chaincodeID, key string) ([]*ledger.ProvenanceEntry, error) {
+	entries, err := s.ledger.GetKeyProvenance(chaincodeID, key)
+	if err != nil {
+		return nil, fabricerrors.Errorf(fabricerrors.CodeOf(err), "Error retrieving the provenance of the key: %s", err)
+	}
+	return entries, nil
+}
+
+// ReconcileNamespace compares the key-values of the chaincode after the block blockNumber was
+// committed with the expected key-values, and reports the mismatches
+func (s *ServerOpenchain) ReconcileNamespace(ctx context.Context, chaincodeID string, blockNumber uint64, expected map[string][]byte) (*ledger.ReconciliationReport, error) {
+	report, err := s.ledger.ReconcileNamespace(chaincodeID, blockNumber, expected)
+	if err != nil {
+		return nil, fabricerrors.Errorf(fabricerrors.CodeOf(err), "Error reconciling the key-values of the chaincode: %s", err)
+	}
+	return report, nil
+}
+
+// GetStateStats returns statistics about the world state
+func (s *ServerOpenchain) GetStateStats(ctx context.Context) (*statemgmt.StateStats, error) {
+	return s.ledger.GetStateStats()
+}
+
+// GetDBSpaceReport returns the disk space used by the ledger per column family
+func (s *ServerOpenchain) GetDBSpaceReport(ctx context.Context) (*db.SpaceReport, error) {
+	return s.ledger.GetDBSpaceReport()
+}
+
 // GetTransactionByUUID returns a transaction matching the specified UUID
 func (s *ServerOpenchain) GetTransactionByUUID(ctx context.Context, txUUID string) (*pb.Transaction, error) {
 	transaction, err := s.ledger.GetTransactionByUUID(txUUID)
@@ -150,12 +523,40 @@ func (s *ServerOpenchain) GetTransactionByUUID(ctx context.Context, txUUID strin
 		case ledger.ErrResourceNotFound:
 			return nil, ErrNotFound
 		default:
-			return nil, fmt.Errorf("Error retrieving transaction from blockchain: %s", err)
+			return nil, fabricerrors.Errorf(fabricerrors.CodeOf(err), "Error retrieving transaction from blockchain: %s", err)
 		}
 	}
 	return transaction, nil
 }
 
+// GetTransactionResult returns the receipt of the committed transaction matching the specified UUID
+func (s *ServerOpenchain) GetTransactionResult(ctx context.Context, txUUID string) (*ledger.TransactionReceipt, error) {
+	receipt, err := s.ledger.GetTransactionResult(txUUID)
+	if err != nil {
+		switch err {
+		case ledger.ErrResourceNotFound:
+			return nil, ErrNotFound
+		default:
+			return nil, fabricerrors.Errorf(fabricerrors.CodeOf(err), "Error retrieving transaction result from blockchain: %s", err)
+		}
+	}
+	return receipt, nil
+}
+
+// GetEventsByChaincode returns the events set by the chaincode in the blocks
+// fromBlock to toBlock (both included), optionally restricted to the events
+// with the given name
+func (s *ServerOpenchain) GetEventsByChaincode(ctx context.Context, chaincodeID string, eventName string, fromBlock uint64, toBlock uint64) ([]*ledger.ChaincodeEventEntry, error) {
+	entries, err := s.ledger.GetChaincodeEvents(chaincodeID, eventName, fromBlock, toBlock)
+	if err != nil {
+		return nil, fabricerrors.Errorf(fabricerrors.CodeOf(err), "Error retrieving chaincode events from blockchain: %s", err)
+	}
+	if entries == nil {
+		entries = []*ledger.ChaincodeEventEntry{}
+	}
+	return entries, nil
+}
+
 // GetPeers returns a list of all peer nodes currently connected to the target peer.
 func (s *ServerOpenchain) GetPeers(ctx context.Context, e *google_protobuf1.Empty) (*pb.PeersMessage, error) {
 	return s.peerInfo.GetPeers()
@@ -18,17 +18,28 @@ package rest
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"google/protobuf"
 
+	"github.com/gocraft/web"
+	"github.com/hyperledger/fabric/core/acl"
 	"github.com/hyperledger/fabric/core/ledger"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
 	"github.com/hyperledger/fabric/core/util"
+	"github.com/hyperledger/fabric/events/producer"
 	"github.com/hyperledger/fabric/protos"
 	"github.com/spf13/viper"
 	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 )
 
 func TestMain(m *testing.M) {
@@ -233,6 +244,38 @@ func TestServerOpenchain_API_GetBlockCount(t *testing.T) {
 	}
 }
 
+func TestServerOpenchain_API_GetStateHashDetail(t *testing.T) {
+	// Must initialize the ledger singleton before initializing the
+	// OpenchainServer, as it needs that pointer.
+
+	// Construct a ledger with 3 blocks.
+	ledger := ledger.InitTestLedger(t)
+	buildTestLedger1(ledger, t)
+
+	// Initialize the OpenchainServer object.
+	server, err := NewOpenchainServerWithPeerInfo(new(peerInfo))
+	if err != nil {
+		t.Logf("Error creating OpenchainServer: %s", err)
+		t.Fail()
+	}
+
+	// The root hash must be the state hash of the last block
+	detail, err := server.GetStateHashDetail(context.Background(), &google_protobuf.Empty{})
+	if err != nil {
+		t.Fatalf("Error retrieving StateHashDetail: %s", err)
+	}
+	block, err := server.GetBlockByNumber(context.Background(), &protos.BlockNumber{Number: 2})
+	if err != nil {
+		t.Fatalf("Error retrieving block from blockchain: %s", err)
+	}
+	if !bytes.Equal(detail.RootHash, block.StateHash) {
+		t.Fatalf("Root hash [%x] differs from the state hash of the last block [%x]", detail.RootHash, block.StateHash)
+	}
+	if len(detail.Children) == 0 {
+		t.Fatalf("Expected the children of the root of the state tree")
+	}
+}
+
 func TestServerOpenchain_API_GetState(t *testing.T) {
 	ledger1 := ledger.InitTestLedger(t)
 	// Construct a blockchain with 3 blocks.
@@ -255,7 +298,270 @@ func TestServerOpenchain_API_GetState(t *testing.T) {
 
 }
 
+func TestServerOpenchain_API_ListBlocks(t *testing.T) {
+	ledger1 := ledger.InitTestLedger(t)
+	// Construct a blockchain with 5 blocks, the odd ones holding a transaction for "cc1"
+	var payloads [][]byte
+	for i := 0; i < 5; i++ {
+		ledger1.BeginTxBatch(i)
+		transaction, err := protos.NewTransaction(protos.ChaincodeID{Name: fmt.Sprintf("cc%d", i%2)}, generateUUID(t), "invoke", []string{"a"})
+		if err != nil {
+			t.Fatalf("Error creating NewTransaction: %s", err)
+		}
+		payloads = append(payloads, transaction.Payload)
+		if err := ledger1.CommitTxBatch(i, []*protos.Transaction{transaction}, nil, []byte("dummy-proof")); err != nil {
+			t.Fatalf("Error in commit: %s", err)
+		}
+	}
+
+	server, err := NewOpenchainServerWithPeerInfo(new(peerInfo))
+	if err != nil {
+		t.Fatalf("Error creating OpenchainServer: %s", err)
+	}
+
+	checkPage := func(query *BlockQuery, expectedNumbers []uint64, expectedNextCursor int64) *BlockPage {
+		page, err := server.ListBlocks(context.Background(), query)
+		if err != nil {
+			t.Fatalf("Error listing blocks: %s", err)
+		}
+		var numbers []uint64
+		for _, b := range page.Blocks {
+			numbers = append(numbers, b.Number)
+		}
+		if fmt.Sprint(numbers) != fmt.Sprint(expectedNumbers) {
+			t.Fatalf("Expected blocks %v, got %v", expectedNumbers, numbers)
+		}
+		if expectedNextCursor < 0 && page.NextCursor != nil || expectedNextCursor >= 0 && (page.NextCursor == nil || *page.NextCursor != uint64(expectedNextCursor)) {
+			t.Fatalf("Expected next cursor %d, got %v", expectedNextCursor, page.NextCursor)
+		}
+		return page
+	}
+
+	page := checkPage(&BlockQuery{Limit: 2}, []uint64{4, 3}, 2)
+	if !bytes.Equal(page.Blocks[0].Block.Transactions[0].Payload, payloads[4]) {
+		t.Fatalf("Expected the payload of the transaction")
+	}
+	page = checkPage(&BlockQuery{Limit: 2, Cursor: page.NextCursor, OmitPayloads: true}, []uint64{2, 1}, 0)
+	if page.Blocks[0].Block.Transactions[0].Payload != nil {
+		t.Fatalf("Expected the payload of the transaction to be omitted")
+	}
+	checkPage(&BlockQuery{Limit: 2, Cursor: page.NextCursor}, []uint64{0}, -1)
+
+	checkPage(&BlockQuery{ChaincodeID: "cc1"}, []uint64{3, 1}, -1)
+	cursor := uint64(2)
+	checkPage(&BlockQuery{ChaincodeID: "cc0", Cursor: &cursor, Limit: 1}, []uint64{2}, 0)
+
+	startTime := time.Now().Add(-time.Hour)
+	checkPage(&BlockQuery{ChaincodeID: "cc0", StartTime: &startTime}, []uint64{4, 2, 0}, -1)
+	checkPage(&BlockQuery{EndTime: &startTime}, nil, -1)
+}
+
+func TestServerOpenchain_API_ListStateAndHistory(t *testing.T) {
+	ledger1 := ledger.InitTestLedger(t)
+	// Construct a blockchain with 3 blocks, each changing the keys of "cc1"
+	for i := 0; i < 3; i++ {
+		ledger1.BeginTxBatch(i)
+		txUUID := generateUUID(t)
+		ledger1.TxBegin(txUUID)
+		ledger1.SetState("cc1", fmt.Sprintf("key%d", i), []byte(fmt.Sprintf("{\"n\":%d}", i)))
+		if i == 2 {
+			ledger1.DeleteState("cc1", "key0")
+		} else {
+			ledger1.SetState("cc1", "key0", []byte{0xff, byte(i)})
+		}
+		ledger1.TxFinished(txUUID, true)
+		transaction, err := protos.NewTransaction(protos.ChaincodeID{Name: "cc1"}, txUUID, "invoke", []string{"a"})
+		if err != nil {
+			t.Fatalf("Error creating NewTransaction: %s", err)
+		}
+		if err := ledger1.CommitTxBatch(i, []*protos.Transaction{transaction}, nil, []byte("dummy-proof")); err != nil {
+			t.Fatalf("Error in commit: %s", err)
+		}
+	}
+
+	server, err := NewOpenchainServerWithPeerInfo(new(peerInfo))
+	if err != nil {
+		t.Fatalf("Error creating OpenchainServer: %s", err)
+	}
+
+	checkPage := func(query *StateQuery, expectedKeys []string, expectedNextCursor string) *StatePage {
+		page, err := server.ListState(context.Background(), query)
+		if err != nil {
+			t.Fatalf("Error listing the state: %s", err)
+		}
+		var keys []string
+		for _, kv := range page.KeyValues {
+			keys = append(keys, kv.Key)
+		}
+		if fmt.Sprint(keys) != fmt.Sprint(expectedKeys) || page.NextCursor != expectedNextCursor {
+			t.Fatalf("Expected keys %v and next cursor [%s], got %v and [%s]", expectedKeys, expectedNextCursor, keys, page.NextCursor)
+		}
+		return page
+	}
+	page := checkPage(&StateQuery{ChaincodeID: "cc1", Limit: 1}, []string{"key1"}, "key2")
+	if !bytes.Equal(page.KeyValues[0].Value, []byte("{\"n\":1}")) {
+		t.Fatalf("Unexpected value %s", page.KeyValues[0].Value)
+	}
+	checkPage(&StateQuery{ChaincodeID: "cc1", Limit: 1, Cursor: page.NextCursor}, []string{"key2"}, "")
+	checkPage(&StateQuery{ChaincodeID: "cc1", EndKey: "key1"}, []string{"key1"}, "")
+	checkPage(&StateQuery{ChaincodeID: "cc2"}, nil, "")
+
+	history, err := server.GetStateHistory(context.Background(), "cc1", "key0")
+	if err != nil {
+		t.Fatalf("Error retrieving the history of the key: %s", err)
+	}
+	if len(history) != 3 || !bytes.Equal(history[1].Value, []byte{0xff, 1}) || !history[2].IsDelete || history[2].BlockNumber != 2 {
+		t.Fatalf("Unexpected history %v", history)
+	}
+
+	provenance, err := server.GetKeyProvenance(context.Background(), "cc1", "key0")
+	if err != nil {
+		t.Fatalf("Error retrieving the provenance of the key: %s", err)
+	}
+	if len(provenance) != 3 || provenance[1].TxUUID != history[1].TxUUID || provenance[1].WriterChaincodeID != "cc1" ||
+		!bytes.Equal(provenance[1].ValueHash, statemgmt.ComputeCryptoHash([]byte{0xff, 1})) || provenance[2].ValueHash != nil {
+		t.Fatalf("Unexpected provenance %v", provenance)
+	}
+
+	report, err := server.ReconcileNamespace(context.Background(), "cc1", 1, map[string][]byte{"key0": {0xff, 1}, "key1": []byte("{\"n\":2}")})
+	if err != nil {
+		t.Fatalf("Error reconciling the key-values of the chaincode: %s", err)
+	}
+	if report.KeysInLedger != 2 || len(report.Mismatches) != 1 || report.Mismatches[0].Key != "key1" || report.Mismatches[0].Type != ledger.MismatchValue {
+		t.Fatalf("Unexpected reconciliation report %v", report)
+	}
+	if _, err := server.ReconcileNamespace(context.Background(), "cc1", 3, nil); err == nil {
+		t.Fatalf("Expected an error for a block beyond the blockchain")
+	}
+}
+
+func TestEncodeStateValue(t *testing.T) {
+	for _, test := range []struct {
+		value       []byte
+		forceBase64 bool
+		expected    string
+	}{
+		{[]byte(`{"a": [1, 2]}`), false, `{"value":{"a":[1,2]},"encoding":"json"}`},
+		{[]byte("hello"), false, `{"value":"hello","encoding":"utf8"}`},
+		{[]byte{0xff, 0}, false, `{"value":"/wA=","encoding":"base64"}`},
+		{[]byte("hello"), true, `{"value":"aGVsbG8=","encoding":"base64"}`},
+	} {
+		encoded, err := json.Marshal(encodeStateValue(test.value, test.forceBase64))
+		if err != nil {
+			t.Fatalf("Error encoding the value %q: %s", test.value, err)
+		}
+		if string(encoded) != test.expected {
+			t.Fatalf("Expected %s, got %s", test.expected, encoded)
+		}
+	}
+}
+
+func TestStreamMessages(t *testing.T) {
+	event := &protos.ChaincodeEvent{ChaincodeID: "cc1", EventName: "moved"}
+	results := []*protos.TransactionResult{{Uuid: "tx1", ChaincodeEvent: event}, {Uuid: "tx2"}}
+	block := &protos.Block{NonHashData: &protos.NonHashData{TransactionResults: results}}
+	e := &producer.LocalEvent{Event: producer.CreateBlockEvent(block), BlockNumber: 0, Numbered: true}
+
+	messages := streamMessages(e, map[string]bool{streamMessageBlock: true, streamMessageChaincode: true})
+	if len(messages) != 2 || messages[0].Block != block || messages[1].ChaincodeEvent != event {
+		t.Fatalf("Expected the block followed by its chaincode event, got %v", messages)
+	}
+	encoded, _ := json.Marshal(messages[1])
+	if expected := `{"type":"chaincode","blockNumber":0,"chaincodeEvent":{"chaincodeID":"cc1","eventName":"moved"}}`; string(encoded) != expected {
+		t.Fatalf("Expected %s, got %s", expected, encoded)
+	}
+
+	e.Numbered = false
+	messages = streamMessages(e, map[string]bool{streamMessageChaincode: true})
+	if len(messages) != 1 || messages[0].BlockNumber != nil {
+		t.Fatalf("Expected the chaincode event only, without a block number, got %v", messages)
+	}
+}
+
 // buildTestLedger1 builds a simple ledger data structure that contains a blockchain with 3 blocks.
+type stateDeltasStream struct {
+	grpc.ServerStream
+	ctx    context.Context
+	deltas chan *protos.BlockStateDelta
+}
+
+func (stream *stateDeltasStream) Context() context.Context {
+	return stream.ctx
+}
+
+func (stream *stateDeltasStream) Send(delta *protos.BlockStateDelta) error {
+	stream.deltas <- delta
+	return nil
+}
+
+func TestServerOpenchain_API_SubscribeStateDeltas(t *testing.T) {
+	ledger1 := ledger.InitTestLedger(t)
+	commitState := func(blockNumber int, changes map[string]string) {
+		ledger1.BeginTxBatch(blockNumber)
+		ledger1.TxBegin("txUuid")
+		for compositeKey, value := range changes {
+			keys := strings.SplitN(compositeKey, "/", 2)
+			if value == "" {
+				ledger1.DeleteState(keys[0], keys[1])
+			} else {
+				ledger1.SetState(keys[0], keys[1], []byte(value))
+			}
+		}
+		ledger1.TxFinished("txUuid", true)
+		if err := ledger1.CommitTxBatch(blockNumber, []*protos.Transaction{}, nil, []byte("dummy-proof")); err != nil {
+			t.Fatalf("Error in commit: %s", err)
+		}
+	}
+	commitState(0, map[string]string{"cc1/a": "1", "cc2/a": "2"})
+	commitState(1, map[string]string{"cc2/b": "3"})
+
+	server, err := NewOpenchainServerWithPeerInfo(new(peerInfo))
+	if err != nil {
+		t.Fatalf("Error creating OpenchainServer: %s", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := &stateDeltasStream{ctx: ctx, deltas: make(chan *protos.BlockStateDelta, 10)}
+	done := make(chan error)
+	go func() {
+		done <- server.SubscribeStateDeltas(&protos.StateDeltasSubscription{ChaincodeID: "cc1"}, stream)
+	}()
+
+	checkDelta := func(expectedBlockNumber uint64, expected string) {
+		select {
+		case delta := <-stream.deltas:
+			var changes []string
+			for _, kv := range delta.KeyValues {
+				changes = append(changes, fmt.Sprintf("%s/%s=%s,%t", kv.ChaincodeID, kv.Key, kv.Value, kv.Deleted))
+			}
+			if delta.BlockNumber != expectedBlockNumber || strings.Join(changes, " ") != expected {
+				t.Fatalf("Expected the changes [%s] of block %d, got [%s] of block %d", expected, expectedBlockNumber, strings.Join(changes, " "), delta.BlockNumber)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for the state delta of block %d", expectedBlockNumber)
+		}
+	}
+	// The blocks already committed are streamed first, then the blocks as they are committed
+	checkDelta(0, "cc1/a=1,false")
+	checkDelta(1, "")
+	commitState(2, map[string]string{"cc1/a": "", "cc1/ab": "4", "cc2/a": "5"})
+	checkDelta(2, "cc1/a=,true cc1/ab=4,false")
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Fatalf("Expected the subscription to be canceled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Timed out waiting for the subscription to end")
+	}
+
+	// A subscription with a key prefix
+	stream = &stateDeltasStream{ctx: context.Background(), deltas: make(chan *protos.BlockStateDelta, 10)}
+	go server.SubscribeStateDeltas(&protos.StateDeltasSubscription{FromBlock: 2, KeyPrefix: "a"}, stream)
+	checkDelta(2, "cc1/a=,true cc1/ab=4,false cc2/a=5,false")
+}
+
 func buildTestLedger1(ledger1 *ledger.Ledger, t *testing.T) {
 	// -----------------------------<Block #0>---------------------
 	// Add the 0th (genesis block)
@@ -441,3 +747,119 @@ func buildTestLedger2(ledger *ledger.Ledger, t *testing.T) {
 func generateUUID(t *testing.T) string {
 	return util.GenerateUUID()
 }
+
+func TestRESTAccessControl(t *testing.T) {
+	p := acl.NewPolicy()
+	p.Enabled = true
+	acl.SetPolicy(p)
+	defer acl.SetPolicy(acl.NewPolicy())
+
+	// every handler but the one of the JSON RPC requests, checked by the devops service,
+	// denies the anonymous clients
+	for _, op := range restOperations {
+		if op.JSONRPC {
+			continue
+		}
+		router := web.New(ServerOpenchainREST{})
+		path := op.Path
+		switch op.Method {
+		case "GET":
+			router.Get(op.Path, op.Handler)
+		case "POST":
+			router.Post(op.Path, op.Handler)
+		case "DELETE":
+			router.Delete(op.Path, op.Handler)
+		}
+		for _, param := range []string{":Block", ":chaincodeID", ":key", ":UUID", ":enrollmentID"} {
+			path = strings.Replace(path, param, "x", -1)
+		}
+		req, _ := http.NewRequest(op.Method, path, strings.NewReader("{}"))
+		rw := httptest.NewRecorder()
+		router.ServeHTTP(rw, req)
+		if rw.Code != http.StatusForbidden {
+			t.Fatalf("Expected operation %s to be denied to the anonymous client, got %d", op.ID, rw.Code)
+		}
+	}
+}
+
+type stateSnapshotStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (stream *stateSnapshotStream) Context() context.Context {
+	return stream.ctx
+}
+
+func (stream *stateSnapshotStream) Send(chunk *protos.StateSnapshotChunk) error {
+	return nil
+}
+
+type blocksStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (stream *blocksStream) Context() context.Context {
+	return stream.ctx
+}
+
+func (stream *blocksStream) Send(block *protos.Block) error {
+	return nil
+}
+
+func TestGRPCAccessControl(t *testing.T) {
+	ledger.InitTestLedger(t)
+	server, err := NewOpenchainServerWithPeerInfo(new(peerInfo))
+	if err != nil {
+		t.Fatalf("Error creating OpenchainServer: %s", err)
+	}
+	grpcServer := NewOpenchainGRPCServer(server)
+	p := acl.NewPolicy()
+	p.Enabled = true
+	p.Members["reader"] = acl.Reader
+	acl.SetPolicy(p)
+	defer acl.SetPolicy(acl.NewPolicy())
+
+	calls := map[string]func(ctx context.Context) error{
+		"GetBlockchainInfo": func(ctx context.Context) error {
+			_, err := grpcServer.GetBlockchainInfo(ctx, &google_protobuf.Empty{})
+			return err
+		},
+		"GetBlockByNumber": func(ctx context.Context) error {
+			_, err := grpcServer.GetBlockByNumber(ctx, &protos.BlockNumber{Number: 0})
+			return err
+		},
+		"GetBlockCount": func(ctx context.Context) error {
+			_, err := grpcServer.GetBlockCount(ctx, &google_protobuf.Empty{})
+			return err
+		},
+		"GetPeers": func(ctx context.Context) error {
+			_, err := grpcServer.GetPeers(ctx, &google_protobuf.Empty{})
+			return err
+		},
+		"GetStateHashDetail": func(ctx context.Context) error {
+			_, err := grpcServer.GetStateHashDetail(ctx, &google_protobuf.Empty{})
+			return err
+		},
+		"GetStateSnapshot": func(ctx context.Context) error {
+			return grpcServer.GetStateSnapshot(&protos.StateSnapshotRequest{}, &stateSnapshotStream{ctx: ctx})
+		},
+		"GetBlocks": func(ctx context.Context) error {
+			return grpcServer.GetBlocks(&protos.BlockRange{}, &blocksStream{ctx: ctx})
+		},
+		"SubscribeStateDeltas": func(ctx context.Context) error {
+			return grpcServer.SubscribeStateDeltas(&protos.StateDeltasSubscription{}, &stateDeltasStream{ctx: ctx})
+		},
+	}
+	for method, call := range calls {
+		if err := call(context.Background()); grpc.Code(err) != codes.PermissionDenied {
+			t.Fatalf("Expected %s to be denied to the anonymous client, got %v", method, err)
+		}
+	}
+
+	// a reader may read the blockchain
+	if _, err := grpcServer.GetPeers(acl.NewContext(context.Background(), "reader"), &google_protobuf.Empty{}); err != nil {
+		t.Fatalf("Expected GetPeers to be allowed to a reader, got %s", err)
+	}
+}
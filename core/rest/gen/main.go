@@ -0,0 +1,307 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// gen generates the operations of the REST API (core/rest/rest_api_spec.go) from its
+// Swagger specification (core/rest/rest_api.json). Run it with `make rest-api`, or
+// from core/rest with `go run gen/main.go`, after editing the specification.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// swagger is the subset of a Swagger 2.0 specification the generator reads
+type swagger struct {
+	Consumes    []string                         `json:"consumes"`
+	Paths       map[string]map[string]*operation `json:"paths"`
+	Definitions map[string]*schema               `json:"definitions"`
+}
+
+type operation struct {
+	OperationID string       `json:"operationId"`
+	Handler     string       `json:"x-handler"`
+	JSONRPC     bool         `json:"x-jsonrpc"`
+	Consumes    []string     `json:"consumes"`
+	Parameters  []*parameter `json:"parameters"`
+}
+
+type parameter struct {
+	Name      string   `json:"name"`
+	In        string   `json:"in"`
+	Required  bool     `json:"required"`
+	Type      string   `json:"type"`
+	Format    string   `json:"format"`
+	Enum      []string `json:"enum"`
+	MinLength int      `json:"minLength"`
+	Schema    *schema  `json:"schema"`
+}
+
+type schema struct {
+	Ref        string             `json:"$ref"`
+	Type       string             `json:"type"`
+	Format     string             `json:"format"`
+	Enum       []string           `json:"enum"`
+	MinLength  int                `json:"minLength"`
+	ProtoEnum  bool               `json:"x-proto-enum"`
+	Required   []string           `json:"required"`
+	Properties map[string]*schema `json:"properties"`
+	Items      *schema            `json:"items"`
+}
+
+var pathParam = regexp.MustCompile(`\{(\w+)\}`)
+
+var methods = map[string]bool{"get": true, "post": true, "put": true, "delete": true, "patch": true}
+
+// generator writes the Go source of the operations
+type generator struct {
+	spec *swagger
+	buf  bytes.Buffer
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+// resolve returns the definition a schema refers to
+func (g *generator) resolve(s *schema, seen map[string]bool) (*schema, error) {
+	for s.Ref != "" {
+		name := strings.TrimPrefix(s.Ref, "#/definitions/")
+		if seen[name] {
+			return nil, fmt.Errorf("recursive definition %s", name)
+		}
+		definition, ok := g.spec.Definitions[name]
+		if !ok {
+			return nil, fmt.Errorf("undefined definition %s", s.Ref)
+		}
+		seen = copySeen(seen)
+		seen[name] = true
+		s = definition
+	}
+	return s, nil
+}
+
+func copySeen(seen map[string]bool) map[string]bool {
+	c := make(map[string]bool, len(seen)+1)
+	for k := range seen {
+		c[k] = true
+	}
+	return c
+}
+
+func (g *generator) writeStrings(field string, values []string) {
+	if len(values) == 0 {
+		return
+	}
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = fmt.Sprintf("%q", v)
+	}
+	g.printf("%s: []string{%s},\n", field, strings.Join(quoted, ", "))
+}
+
+// writeSchema writes a restSchema literal, with the definitions referred to inlined.
+// The type of the literal is elided in the composite literals of the same type
+func (g *generator) writeSchema(s *schema, seen map[string]bool, elided bool) error {
+	if s.Ref != "" {
+		name := strings.TrimPrefix(s.Ref, "#/definitions/")
+		resolved, err := g.resolve(s, seen)
+		if err != nil {
+			return err
+		}
+		seen = copySeen(seen)
+		seen[name] = true
+		s = resolved
+	}
+	if !elided {
+		g.printf("&restSchema")
+	}
+	g.printf("{\n")
+	if s.Type != "" {
+		g.printf("Type: %q,\n", s.Type)
+	}
+	if s.Format != "" {
+		g.printf("Format: %q,\n", s.Format)
+	}
+	g.writeStrings("Enum", s.Enum)
+	if s.MinLength > 0 {
+		g.printf("MinLength: %d,\n", s.MinLength)
+	}
+	if s.ProtoEnum {
+		g.printf("ProtoEnum: true,\n")
+	}
+	g.writeStrings("Required", s.Required)
+	if len(s.Properties) > 0 {
+		names := make([]string, 0, len(s.Properties))
+		for name := range s.Properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		g.printf("Properties: map[string]*restSchema{\n")
+		for _, name := range names {
+			g.printf("%q: ", name)
+			if err := g.writeSchema(s.Properties[name], seen, true); err != nil {
+				return fmt.Errorf("%s: %s", name, err)
+			}
+			g.printf(",\n")
+		}
+		g.printf("},\n")
+	}
+	if s.Items != nil {
+		g.printf("Items: ")
+		if err := g.writeSchema(s.Items, seen, false); err != nil {
+			return err
+		}
+		g.printf(",\n")
+	}
+	g.printf("}")
+	return nil
+}
+
+func (g *generator) writeParam(p *parameter, elided bool) error {
+	if !elided {
+		g.printf("&restParam")
+	}
+	g.printf("{Name: %q, In: %q", p.Name, p.In)
+	if p.Required {
+		g.printf(", Required: true")
+	}
+	g.printf(", Schema: ")
+	s := p.Schema
+	if s == nil {
+		s = &schema{Type: p.Type, Format: p.Format, Enum: p.Enum, MinLength: p.MinLength}
+	}
+	if err := g.writeSchema(s, nil, false); err != nil {
+		return err
+	}
+	g.printf("}")
+	return nil
+}
+
+func (g *generator) writeOperation(path, method string, op *operation) error {
+	if op.Handler == "" {
+		return fmt.Errorf("no x-handler")
+	}
+	g.printf("{\n")
+	g.printf("ID: %q,\n", op.OperationID)
+	g.printf("Method: %q,\n", strings.ToUpper(method))
+	g.printf("Path: %q,\n", pathParam.ReplaceAllString(path, ":$1"))
+	g.printf("Handler: (*ServerOpenchainREST).%s,\n", op.Handler)
+
+	var params []*parameter
+	var body *parameter
+	for _, p := range op.Parameters {
+		switch p.In {
+		case "body":
+			body = p
+		case "path", "query", "header":
+			params = append(params, p)
+		default:
+			return fmt.Errorf("unsupported parameter %s in %s", p.Name, p.In)
+		}
+	}
+	if len(params) > 0 {
+		g.printf("Params: []*restParam{\n")
+		for _, p := range params {
+			if err := g.writeParam(p, true); err != nil {
+				return fmt.Errorf("parameter %s: %s", p.Name, err)
+			}
+			g.printf(",\n")
+		}
+		g.printf("},\n")
+	}
+	if body != nil {
+		consumes := op.Consumes
+		if consumes == nil {
+			consumes = g.spec.Consumes
+		}
+		g.writeStrings("Consumes", consumes)
+		g.printf("Body: ")
+		if err := g.writeParam(body, false); err != nil {
+			return fmt.Errorf("body %s: %s", body.Name, err)
+		}
+		g.printf(",\n")
+	}
+	if op.JSONRPC {
+		g.printf("JSONRPC: true,\n")
+	}
+	g.printf("},\n")
+	return nil
+}
+
+// generate returns the Go source of the operations of the specification, sorted by
+// path and method
+func generate(specJSON []byte) ([]byte, error) {
+	g := &generator{spec: &swagger{}}
+	if err := json.Unmarshal(specJSON, g.spec); err != nil {
+		return nil, err
+	}
+
+	g.printf("// Code generated by gen/main.go from rest_api.json. DO NOT EDIT.\n\n")
+	g.printf("package rest\n\n")
+	g.printf("// restOperations are the operations of the REST API specification\n")
+	g.printf("var restOperations = []*restOperation{\n")
+	paths := make([]string, 0, len(g.spec.Paths))
+	for path := range g.spec.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		var pathMethods []string
+		for method := range g.spec.Paths[path] {
+			if methods[method] {
+				pathMethods = append(pathMethods, method)
+			}
+		}
+		sort.Strings(pathMethods)
+		for _, method := range pathMethods {
+			if err := g.writeOperation(path, method, g.spec.Paths[path][method]); err != nil {
+				return nil, fmt.Errorf("%s %s: %s", strings.ToUpper(method), path, err)
+			}
+		}
+	}
+	g.printf("}\n")
+	return format.Source(g.buf.Bytes())
+}
+
+func main() {
+	specFile := flag.String("spec", "rest_api.json", "Swagger specification of the REST API")
+	outFile := flag.String("out", "rest_api_spec.go", "Go file generated")
+	flag.Parse()
+
+	specJSON, err := ioutil.ReadFile(*specFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading the specification: %s\n", err)
+		os.Exit(1)
+	}
+	source, err := generate(specJSON)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error generating the operations: %s\n", err)
+		os.Exit(1)
+	}
+	if err := ioutil.WriteFile(*outFile, source, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing %s: %s\n", *outFile, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,89 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestGeneratedOperationsUpToDate(t *testing.T) {
+	specJSON, err := ioutil.ReadFile("../rest_api.json")
+	if err != nil {
+		t.Fatalf("Error reading the specification: %s", err)
+	}
+	expected, err := generate(specJSON)
+	if err != nil {
+		t.Fatalf("Error generating the operations: %s", err)
+	}
+	generated, err := ioutil.ReadFile("../rest_api_spec.go")
+	if err != nil {
+		t.Fatalf("Error reading the generated operations: %s", err)
+	}
+	if !bytes.Equal(generated, expected) {
+		t.Fatalf("rest_api_spec.go is out of date with rest_api.json, run `make rest-api`")
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	spec := `{
+		"consumes": ["application/json"],
+		"definitions": {
+			"Item": {"type": "object", "properties": {"kind": {"type": "string", "enum": ["A", "B"], "x-proto-enum": true}}, "required": ["kind"]}
+		},
+		"paths": {
+			"/items/{itemID}": {
+				"put": {
+					"operationId": "putItem",
+					"x-handler": "PutItem",
+					"parameters": [
+						{"name": "itemID", "in": "path", "type": "integer", "format": "uint64", "required": true},
+						{"name": "Item", "in": "body", "required": true, "schema": {"$ref": "#/definitions/Item"}}
+					]
+				}
+			}
+		}
+	}`
+	source, err := generate([]byte(spec))
+	if err != nil {
+		t.Fatalf("Error generating the operations: %s", err)
+	}
+	// ignore the alignment of the fields
+	normalized := strings.Join(strings.Fields(string(source)), " ")
+	for _, expected := range []string{
+		`Path: "/items/:itemID"`,
+		`Handler: (*ServerOpenchainREST).PutItem`,
+		`Consumes: []string{"application/json"}`,
+		`{Name: "itemID", In: "path", Required: true, Schema: &restSchema{`,
+		`Required: []string{"kind"}`,
+		`Enum: []string{"A", "B"}, ProtoEnum: true`,
+	} {
+		if !strings.Contains(normalized, expected) {
+			t.Fatalf("Expected %s in the generated source:\n%s", expected, source)
+		}
+	}
+
+	if _, err := generate([]byte(`{"paths": {"/items": {"get": {"operationId": "listItems"}}}}`)); err == nil {
+		t.Fatalf("Expected an operation without x-handler to fail")
+	}
+	if _, err := generate([]byte(`{"paths": {"/items": {"post": {"operationId": "postItem", "x-handler": "PostItem",
+		"parameters": [{"name": "Item", "in": "body", "schema": {"$ref": "#/definitions/Item"}}]}}}}`)); err == nil {
+		t.Fatalf("Expected an undefined definition to fail")
+	}
+}
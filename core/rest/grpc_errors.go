@@ -0,0 +1,118 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rest
+
+import (
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+
+	google_protobuf1 "google/protobuf"
+
+	"github.com/hyperledger/fabric/core/acl"
+	fabricerrors "github.com/hyperledger/fabric/core/errors"
+	"github.com/hyperledger/fabric/core/metrics"
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+// grpcRequests counts the calls of the Openchain gRPC service by method and status code
+var grpcRequests = metrics.NewCounterVec("grpc_server_handled_total",
+	"Number of calls of the Openchain gRPC service completed, by method and status code.", "service", "method", "code")
+
+// grpcOpenchain serves the Openchain service of the ServerOpenchain over gRPC, with the
+// code of its errors mapped to a gRPC status code (see fabricerrors.ToGRPC). The calls are
+// checked against the access control list: the subscription to the state deltas as calls
+// of acl.EventsAPI and the other methods as calls of acl.QueryAPI. The REST API calls the
+// ServerOpenchain directly, checking its own requests, and keeps comparing its errors to
+// ErrNotFound.
+type grpcOpenchain struct {
+	*ServerOpenchain
+}
+
+// done returns the error of the call of the method mapped to a gRPC status code, and
+// counts the call
+func (g *grpcOpenchain) done(method string, err error) error {
+	err = fabricerrors.ToGRPC(err)
+	grpcRequests.With("Openchain", method, grpc.Code(err).String()).Inc()
+	return err
+}
+
+// NewOpenchainGRPCServer returns the server of the Openchain service to register on the
+// gRPC server of the peer
+func NewOpenchainGRPCServer(s *ServerOpenchain) pb.OpenchainServer {
+	return &grpcOpenchain{s}
+}
+
+func (g *grpcOpenchain) GetBlockchainInfo(ctx context.Context, e *google_protobuf1.Empty) (*pb.BlockchainInfo, error) {
+	if err := acl.Check(ctx, acl.QueryAPI); err != nil {
+		return nil, g.done("GetBlockchainInfo", err)
+	}
+	blockchainInfo, err := g.ServerOpenchain.GetBlockchainInfo(ctx, e)
+	return blockchainInfo, g.done("GetBlockchainInfo", err)
+}
+
+func (g *grpcOpenchain) GetBlockByNumber(ctx context.Context, num *pb.BlockNumber) (*pb.Block, error) {
+	if err := acl.Check(ctx, acl.QueryAPI); err != nil {
+		return nil, g.done("GetBlockByNumber", err)
+	}
+	block, err := g.ServerOpenchain.GetBlockByNumber(ctx, num)
+	return block, g.done("GetBlockByNumber", err)
+}
+
+func (g *grpcOpenchain) GetBlockCount(ctx context.Context, e *google_protobuf1.Empty) (*pb.BlockCount, error) {
+	if err := acl.Check(ctx, acl.QueryAPI); err != nil {
+		return nil, g.done("GetBlockCount", err)
+	}
+	count, err := g.ServerOpenchain.GetBlockCount(ctx, e)
+	return count, g.done("GetBlockCount", err)
+}
+
+func (g *grpcOpenchain) GetPeers(ctx context.Context, e *google_protobuf1.Empty) (*pb.PeersMessage, error) {
+	if err := acl.Check(ctx, acl.QueryAPI); err != nil {
+		return nil, g.done("GetPeers", err)
+	}
+	peers, err := g.ServerOpenchain.GetPeers(ctx, e)
+	return peers, g.done("GetPeers", err)
+}
+
+func (g *grpcOpenchain) GetStateHashDetail(ctx context.Context, e *google_protobuf1.Empty) (*pb.StateHashDetail, error) {
+	if err := acl.Check(ctx, acl.QueryAPI); err != nil {
+		return nil, g.done("GetStateHashDetail", err)
+	}
+	detail, err := g.ServerOpenchain.GetStateHashDetail(ctx, e)
+	return detail, g.done("GetStateHashDetail", err)
+}
+
+func (g *grpcOpenchain) GetStateSnapshot(req *pb.StateSnapshotRequest, stream pb.Openchain_GetStateSnapshotServer) error {
+	if err := acl.Check(stream.Context(), acl.QueryAPI); err != nil {
+		return g.done("GetStateSnapshot", err)
+	}
+	return g.done("GetStateSnapshot", g.ServerOpenchain.GetStateSnapshot(req, stream))
+}
+
+func (g *grpcOpenchain) GetBlocks(blockRange *pb.BlockRange, stream pb.Openchain_GetBlocksServer) error {
+	if err := acl.Check(stream.Context(), acl.QueryAPI); err != nil {
+		return g.done("GetBlocks", err)
+	}
+	return g.done("GetBlocks", g.ServerOpenchain.GetBlocks(blockRange, stream))
+}
+
+func (g *grpcOpenchain) SubscribeStateDeltas(req *pb.StateDeltasSubscription, stream pb.Openchain_SubscribeStateDeltasServer) error {
+	if err := acl.Check(stream.Context(), acl.EventsAPI); err != nil {
+		return g.done("SubscribeStateDeltas", err)
+	}
+	return g.done("SubscribeStateDeltas", g.ServerOpenchain.SubscribeStateDeltas(req, stream))
+}
@@ -23,11 +23,14 @@ import (
 	"google/protobuf"
 	"io"
 	"io/ioutil"
+	"math"
+	"mime"
 	"net/http"
 	"net/url"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"golang.org/x/net/context"
 
@@ -37,10 +40,13 @@ import (
 	"github.com/spf13/viper"
 
 	core "github.com/hyperledger/fabric/core"
+	"github.com/hyperledger/fabric/core/acl"
 	"github.com/hyperledger/fabric/core/chaincode"
 	"github.com/hyperledger/fabric/core/comm"
 	"github.com/hyperledger/fabric/core/crypto"
 	"github.com/hyperledger/fabric/core/crypto/utils"
+	"github.com/hyperledger/fabric/core/ledger"
+	"github.com/hyperledger/fabric/events/producer"
 	pb "github.com/hyperledger/fabric/protos"
 )
 
@@ -147,6 +153,8 @@ var (
 	ChaincodeDeployError     = &rpcError{Code: -32001, Message: "Deployment failure", Data: "Chaincode deployment has failed."}
 	ChaincodeInvokeError     = &rpcError{Code: -32002, Message: "Invocation failure", Data: "Chaincode invocation has failed."}
 	ChaincodeQueryError      = &rpcError{Code: -32003, Message: "Query failure", Data: "Chaincode query has failed."}
+	RateLimitedError         = &rpcError{Code: -32004, Message: "Too many requests", Data: "The request exceeded the rate limits of the peer, retry later."}
+	AccessDeniedError        = &rpcError{Code: -32005, Message: "Access denied", Data: "The client may not make the request."}
 )
 
 // SetOpenchainServer is a middleware function that sets the pointer to the
@@ -185,6 +193,10 @@ func getRESTFilePath() string {
 // Register confirms the enrollmentID and secret password of the client with the
 // CA and stores the enrollment certificate and key in the Devops server.
 func (s *ServerOpenchainREST) Register(rw web.ResponseWriter, req *web.Request) {
+	if !checkAccess(rw, req, acl.InvokeAPI) {
+		return
+	}
+
 	restLogger.Info("REST client login...")
 
 	// Decode the incoming JSON payload
@@ -285,8 +297,12 @@ func (s *ServerOpenchainREST) Register(rw web.ResponseWriter, req *web.Request)
 // GetEnrollmentID checks whether a given user has already registered with the
 // Devops server.
 func (s *ServerOpenchainREST) GetEnrollmentID(rw web.ResponseWriter, req *web.Request) {
+	if !checkAccess(rw, req, acl.InvokeAPI) {
+		return
+	}
+
 	// Parse out the user enrollment ID
-	enrollmentID := req.PathParams["id"]
+	enrollmentID := req.PathParams["enrollmentID"]
 
 	// Retrieve the REST data storage path
 	// Returns /var/hyperledger/production/client/
@@ -311,8 +327,12 @@ func (s *ServerOpenchainREST) GetEnrollmentID(rw web.ResponseWriter, req *web.Re
 // longer be able to transact without logging in again. On the REST interface,
 // this method may be used as a means of logging out an active client.
 func (s *ServerOpenchainREST) DeleteEnrollmentID(rw web.ResponseWriter, req *web.Request) {
+	if !checkAccess(rw, req, acl.InvokeAPI) {
+		return
+	}
+
 	// Parse out the user enrollment ID
-	enrollmentID := req.PathParams["id"]
+	enrollmentID := req.PathParams["enrollmentID"]
 
 	// Retrieve the REST data storage path
 	// Returns /var/hyperledger/production/client/
@@ -365,8 +385,12 @@ func (s *ServerOpenchainREST) DeleteEnrollmentID(rw web.ResponseWriter, req *web
 
 // GetEnrollmentCert retrieves the enrollment certificate for a given user.
 func (s *ServerOpenchainREST) GetEnrollmentCert(rw web.ResponseWriter, req *web.Request) {
+	if !checkAccess(rw, req, acl.InvokeAPI) {
+		return
+	}
+
 	// Parse out the user enrollment ID
-	enrollmentID := req.PathParams["id"]
+	enrollmentID := req.PathParams["enrollmentID"]
 
 	restLogger.Debug("REST received enrollment certificate retrieval request for registrationID '%s'", enrollmentID)
 
@@ -450,8 +474,12 @@ func (s *ServerOpenchainREST) GetEnrollmentCert(rw web.ResponseWriter, req *web.
 
 // GetTransactionCert retrieves the transaction certificate(s) for a given user.
 func (s *ServerOpenchainREST) GetTransactionCert(rw web.ResponseWriter, req *web.Request) {
+	if !checkAccess(rw, req, acl.InvokeAPI) {
+		return
+	}
+
 	// Parse out the user enrollment ID
-	enrollmentID := req.PathParams["id"]
+	enrollmentID := req.PathParams["enrollmentID"]
 
 	restLogger.Debug("REST received transaction certificate retrieval request for registrationID '%s'", enrollmentID)
 
@@ -587,6 +615,10 @@ func (s *ServerOpenchainREST) GetTransactionCert(rw web.ResponseWriter, req *web
 // GetBlockchainInfo returns information about the blockchain ledger such as
 // height, current block hash, and previous block hash.
 func (s *ServerOpenchainREST) GetBlockchainInfo(rw web.ResponseWriter, req *web.Request) {
+	if !checkAccess(rw, req, acl.QueryAPI) {
+		return
+	}
+
 	info, err := s.server.GetBlockchainInfo(context.Background(), &google_protobuf.Empty{})
 
 	encoder := json.NewEncoder(rw)
@@ -606,8 +638,12 @@ func (s *ServerOpenchainREST) GetBlockchainInfo(rw web.ResponseWriter, req *web.
 // GetBlockByNumber returns the data contained within a specific block in the
 // blockchain. The genesis block is block zero.
 func (s *ServerOpenchainREST) GetBlockByNumber(rw web.ResponseWriter, req *web.Request) {
+	if !checkAccess(rw, req, acl.QueryAPI) {
+		return
+	}
+
 	// Parse out the Block id
-	blockNumber, err := strconv.ParseUint(req.PathParams["id"], 10, 64)
+	blockNumber, err := strconv.ParseUint(req.PathParams["Block"], 10, 64)
 
 	// Check for proper Block id syntax
 	if err != nil {
@@ -637,10 +673,101 @@ func (s *ServerOpenchainREST) GetBlockByNumber(rw web.ResponseWriter, req *web.R
 	}
 }
 
+// ListBlocks returns a page of blocks, from the most recent one to the oldest
+// one. The query parameters are
+//   limit:        maximum number of blocks returned (10 by default, at most 100)
+//   cursor:       number of the block from which the listing goes backwards, as
+//                 returned in the nextCursor field of the previous page
+//   chaincodeID:  only list the blocks holding transactions for this chaincode
+//   startTime:    only list the blocks with a timestamp (RFC 3339) not before this one
+//   endTime:      only list the blocks with a timestamp (RFC 3339) before this one
+//   omitPayloads: if true, strip the payloads of the transactions
+func (s *ServerOpenchainREST) ListBlocks(rw web.ResponseWriter, req *web.Request) {
+	if !checkAccess(rw, req, acl.QueryAPI) {
+		return
+	}
+
+	query, err := parseBlockQuery(req)
+	if err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(rw, "{\"Error\": \"%s\"}", err)
+		restLogger.Error(fmt.Sprintf("{\"Error\": \"%s\"}", err))
+		return
+	}
+
+	page, err := s.server.ListBlocks(context.Background(), query)
+
+	// Check for error
+	if err != nil {
+		// Failure
+		switch err {
+		case ErrNotReady:
+			rw.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			rw.WriteHeader(http.StatusInternalServerError)
+		}
+		fmt.Fprintf(rw, "{\"Error\": \"%s\"}", err)
+		restLogger.Error(fmt.Sprintf("{\"Error\": \"%s\"}", err))
+	} else {
+		// Success
+		rw.WriteHeader(http.StatusOK)
+		encoder := json.NewEncoder(rw)
+		encoder.Encode(page)
+	}
+}
+
+// parseBlockQuery parses the query parameters of a block listing request
+func parseBlockQuery(req *web.Request) (*BlockQuery, error) {
+	req.ParseForm()
+	queryParams := req.Form
+
+	query := &BlockQuery{ChaincodeID: queryParams.Get("chaincodeID")}
+	if v := queryParams.Get("limit"); v != "" {
+		limit, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return nil, errors.New("Limit query parameter must be a non-negative integer.")
+		}
+		query.Limit = int(limit)
+	}
+	if v := queryParams.Get("cursor"); v != "" {
+		cursor, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return nil, errors.New("Cursor query parameter must be a block number (uint64).")
+		}
+		query.Cursor = &cursor
+	}
+	if v := queryParams.Get("startTime"); v != "" {
+		startTime, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return nil, errors.New("StartTime query parameter must be an RFC 3339 timestamp.")
+		}
+		query.StartTime = &startTime
+	}
+	if v := queryParams.Get("endTime"); v != "" {
+		endTime, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return nil, errors.New("EndTime query parameter must be an RFC 3339 timestamp.")
+		}
+		query.EndTime = &endTime
+	}
+	if v := queryParams.Get("omitPayloads"); v != "" {
+		omitPayloads, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, errors.New("OmitPayloads query parameter must be a boolean.")
+		}
+		query.OmitPayloads = omitPayloads
+	}
+	return query, nil
+}
+
 // GetTransactionByUUID returns a transaction matching the specified UUID
 func (s *ServerOpenchainREST) GetTransactionByUUID(rw web.ResponseWriter, req *web.Request) {
+	if !checkAccess(rw, req, acl.QueryAPI) {
+		return
+	}
+
 	// Parse out the transaction UUID
-	txUUID := req.PathParams["uuid"]
+	txUUID := req.PathParams["UUID"]
 
 	// Retrieve the transaction matching the UUID
 	tx, err := s.server.GetTransactionByUUID(context.Background(), txUUID)
@@ -665,9 +792,476 @@ func (s *ServerOpenchainREST) GetTransactionByUUID(rw web.ResponseWriter, req *w
 	}
 }
 
+// GetTransactionResult returns the receipt of the committed transaction matching
+// the specified UUID: its position in the blockchain, whether it succeeded, the
+// error returned by the chaincode if it failed and the hash of its state changes.
+func (s *ServerOpenchainREST) GetTransactionResult(rw web.ResponseWriter, req *web.Request) {
+	if !checkAccess(rw, req, acl.QueryAPI) {
+		return
+	}
+
+	// Parse out the transaction UUID
+	txUUID := req.PathParams["UUID"]
+
+	// Retrieve the receipt of the transaction matching the UUID
+	receipt, err := s.server.GetTransactionResult(context.Background(), txUUID)
+
+	// Check for Error
+	if err != nil {
+		switch err {
+		case ErrNotFound:
+			rw.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(rw, "{\"Error\": \"Transaction %s is not found.\"}", txUUID)
+		default:
+			rw.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprintf(rw, "{\"Error\": \"Error retrieving result of transaction %s: %s.\"}", txUUID, err)
+			restLogger.Error(fmt.Sprintf("{\"Error\": \"Error retrieving result of transaction %s: %s.\"}", txUUID, err))
+		}
+	} else {
+		// Return the receipt
+		rw.WriteHeader(http.StatusOK)
+		encoder := json.NewEncoder(rw)
+		encoder.Encode(receipt)
+		restLogger.Info(fmt.Sprintf("Successfully retrieved result of transaction: %s", txUUID))
+	}
+}
+
+// GetEventsByChaincode returns the events set by a chaincode, in the order they
+// were committed. The query parameters are
+//   name:      only return the events with this name
+//   fromBlock: number of the first block searched (0 by default)
+//   toBlock:   number of the last block searched (the last block of the chain by default)
+func (s *ServerOpenchainREST) GetEventsByChaincode(rw web.ResponseWriter, req *web.Request) {
+	if !checkAccess(rw, req, acl.QueryAPI) {
+		return
+	}
+
+	chaincodeID := req.PathParams["chaincodeID"]
+
+	req.ParseForm()
+	queryParams := req.Form
+	fromBlock := uint64(0)
+	toBlock := uint64(math.MaxUint64)
+	var err error
+	if v := queryParams.Get("fromBlock"); v != "" {
+		if fromBlock, err = strconv.ParseUint(v, 10, 64); err != nil {
+			rw.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(rw, "{\"Error\": \"FromBlock query parameter must be a block number (uint64).\"}")
+			return
+		}
+	}
+	if v := queryParams.Get("toBlock"); v != "" {
+		if toBlock, err = strconv.ParseUint(v, 10, 64); err != nil {
+			rw.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(rw, "{\"Error\": \"ToBlock query parameter must be a block number (uint64).\"}")
+			return
+		}
+	}
+
+	entries, err := s.server.GetEventsByChaincode(context.Background(), chaincodeID, queryParams.Get("name"), fromBlock, toBlock)
+
+	// Check for error
+	if err != nil {
+		// Failure
+		rw.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(rw, "{\"Error\": \"%s\"}", err)
+		restLogger.Error(fmt.Sprintf("{\"Error\": \"%s\"}", err))
+	} else {
+		// Success
+		rw.WriteHeader(http.StatusOK)
+		encoder := json.NewEncoder(rw)
+		encoder.Encode(entries)
+	}
+}
+
+// StreamEvents upgrades the request to a WebSocket connection over which the blocks
+// committed from then on, and the chaincode events they hold, are sent as JSON text
+// messages. The query parameters are
+//   events:      comma separated types of the messages sent, block and chaincode (all by default)
+//   chaincodeID: only send the transactions of this chaincode, and their events
+//   eventName:   only send the transactions which set a chaincode event with this name
+//   txResult:    only send the transactions which succeeded or failed (any by default)
+// The blocks are restricted to the transactions matching the query. The connection is
+// closed if the client cannot keep up with the blocks committed.
+func (s *ServerOpenchainREST) StreamEvents(rw web.ResponseWriter, req *web.Request) {
+	if !checkAccess(rw, req, acl.EventsAPI) {
+		return
+	}
+
+	interest, types, err := parseStreamQuery(req)
+	if err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(rw, "{\"Error\": \"%s\"}", err)
+		return
+	}
+
+	sub, err := producer.Subscribe([]*pb.Interest{interest})
+
+	// Check for error
+	if err != nil {
+		// Failure
+		rw.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintf(rw, "{\"Error\": \"Cannot stream the events: %s\"}", err)
+		restLogger.Error(fmt.Sprintf("{\"Error\": \"Cannot stream the events: %s\"}", err))
+		return
+	}
+	conn, err := upgradeWebSocket(rw, req.Request)
+	if err != nil {
+		sub.Close()
+		rw.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(rw, "{\"Error\": \"%s\"}", err)
+		return
+	}
+
+	// Success
+	restLogger.Info(fmt.Sprintf("Streaming events to %s", req.RemoteAddr))
+	streamEvents(conn, sub, types)
+	restLogger.Info(fmt.Sprintf("Stopped streaming events to %s", req.RemoteAddr))
+}
+
+// streamEvents sends the events of the subscription over the connection until either
+// the client or the event hub disconnects, and then closes both.
+func streamEvents(conn *wsConn, sub *producer.Subscription, types map[string]bool) {
+	defer sub.Close()
+
+	clientDone := make(chan error, 1)
+	go func() {
+		clientDone <- conn.readLoop()
+	}()
+	pings := time.NewTicker(wsPingInterval)
+	defer pings.Stop()
+
+	for {
+		select {
+		case e := <-sub.Events():
+			for _, message := range streamMessages(e, types) {
+				b, err := json.Marshal(message)
+				if err != nil {
+					restLogger.Error(fmt.Sprintf("Error marshalling the %s message: %s", message.Type, err))
+					continue
+				}
+				if err := conn.WriteText(b); err != nil {
+					conn.Close(wsCloseInternalError, "")
+					return
+				}
+			}
+		case err := <-sub.Done():
+			conn.Close(wsClosePolicyViolation, err.Error())
+			return
+		case <-clientDone:
+			return
+		case <-pings.C:
+			if err := conn.Ping(); err != nil {
+				conn.Close(wsCloseInternalError, "")
+				return
+			}
+		}
+	}
+}
+
+// parseStreamQuery parses the query parameters of an event stream request into the
+// interest registered to the event hub and the types of the messages sent
+func parseStreamQuery(req *web.Request) (*pb.Interest, map[string]bool, error) {
+	req.ParseForm()
+	queryParams := req.Form
+
+	types := map[string]bool{streamMessageBlock: true, streamMessageChaincode: true}
+	if v := queryParams.Get("events"); v != "" {
+		types = make(map[string]bool)
+		for _, t := range strings.Split(v, ",") {
+			t = strings.TrimSpace(t)
+			if t != streamMessageBlock && t != streamMessageChaincode {
+				return nil, nil, fmt.Errorf("Events query parameter must list %s or %s.", streamMessageBlock, streamMessageChaincode)
+			}
+			types[t] = true
+		}
+	}
+
+	filter := &pb.EventFilter{ChaincodeID: queryParams.Get("chaincodeID"), EventName: queryParams.Get("eventName")}
+	if v := queryParams.Get("txResult"); v != "" {
+		txResult, ok := pb.EventFilter_TxResult_value[strings.ToUpper(v)]
+		if !ok {
+			return nil, nil, errors.New("TxResult query parameter must be any, succeeded or failed.")
+		}
+		filter.TxResult = pb.EventFilter_TxResult(txResult)
+	}
+	interest := &pb.Interest{EventType: producer.BlockType, ResponseType: pb.Interest_PROTOBUF}
+	if filter.ChaincodeID != "" || filter.EventName != "" || filter.TxResult != pb.EventFilter_ANY {
+		interest.Filter = filter
+	}
+	return interest, types, nil
+}
+
+// GetStateStats returns statistics about the world state such as the number of
+// keys and bytes held by each chaincode and the size of the retained state deltas.
+func (s *ServerOpenchainREST) GetStateStats(rw web.ResponseWriter, req *web.Request) {
+	if !checkAccess(rw, req, acl.QueryAPI) {
+		return
+	}
+
+	stats, err := s.server.GetStateStats(context.Background())
+
+	// Check for error
+	if err != nil {
+		// Failure
+		rw.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(rw, "{\"Error\": \"Error retrieving state stats: %s\"}", err)
+		restLogger.Error(fmt.Sprintf("{\"Error\": \"Error retrieving state stats: %s\"}", err))
+	} else {
+		// Success
+		rw.WriteHeader(http.StatusOK)
+		encoder := json.NewEncoder(rw)
+		encoder.Encode(stats)
+	}
+}
+
+// GetStateHashDetail returns the crypto-hash of the committed world state along
+// with the crypto-hashes of the children of the root of the state tree.
+func (s *ServerOpenchainREST) GetStateHashDetail(rw web.ResponseWriter, req *web.Request) {
+	if !checkAccess(rw, req, acl.QueryAPI) {
+		return
+	}
+
+	detail, err := s.server.GetStateHashDetail(context.Background(), &google_protobuf.Empty{})
+
+	// Check for error
+	if err != nil {
+		// Failure
+		rw.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(rw, "{\"Error\": \"%s\"}", err)
+		restLogger.Error(fmt.Sprintf("{\"Error\": \"%s\"}", err))
+	} else {
+		// Success
+		rw.WriteHeader(http.StatusOK)
+		encoder := json.NewEncoder(rw)
+		encoder.Encode(detail)
+	}
+}
+
+// GetStateValue returns the committed value of a key of the world state. The value is
+// returned as JSON if it is a JSON document, as a string if it is valid UTF-8, and base64
+// encoded otherwise, or always base64 encoded with the query parameter encoding=base64.
+func (s *ServerOpenchainREST) GetStateValue(rw web.ResponseWriter, req *web.Request) {
+	if !checkAccess(rw, req, acl.QueryAPI) {
+		return
+	}
+
+	chaincodeID := req.PathParams["chaincodeID"]
+	key := req.PathParams["key"]
+	req.ParseForm()
+	forceBase64 := req.Form.Get("encoding") == stateValueBase64
+
+	value, err := s.server.GetState(context.Background(), chaincodeID, key)
+
+	// Check for error
+	if err != nil {
+		// Failure
+		rw.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(rw, "{\"Error\": \"Error retrieving key %s of chaincode %s: %s\"}", key, chaincodeID, err)
+		restLogger.Error(fmt.Sprintf("{\"Error\": \"Error retrieving key %s of chaincode %s: %s\"}", key, chaincodeID, err))
+	} else if value == nil {
+		rw.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(rw, "{\"Error\": \"Key %s of chaincode %s is not found.\"}", key, chaincodeID)
+	} else {
+		// Success
+		rw.WriteHeader(http.StatusOK)
+		encoder := json.NewEncoder(rw)
+		encoder.Encode(&restStateKeyValue{key, encodeStateValue(value, forceBase64)})
+	}
+}
+
+// ListState returns a page of the committed key-values of a chaincode, in the lexical
+// order of the keys. The query parameters are
+//   startKey, endKey: only list the keys within [startKey, endKey]
+//   limit:            maximum number of key-values returned (see DefaultStatePageSize and MaxStatePageSize)
+//   cursor:           key from which the listing continues, the nextCursor of the previous page
+//   encoding:         base64 to always return the values base64 encoded
+func (s *ServerOpenchainREST) ListState(rw web.ResponseWriter, req *web.Request) {
+	if !checkAccess(rw, req, acl.QueryAPI) {
+		return
+	}
+
+	req.ParseForm()
+	queryParams := req.Form
+	query := &StateQuery{
+		ChaincodeID: req.PathParams["chaincodeID"],
+		StartKey:    queryParams.Get("startKey"),
+		EndKey:      queryParams.Get("endKey"),
+		Cursor:      queryParams.Get("cursor"),
+	}
+	if v := queryParams.Get("limit"); v != "" {
+		limit, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			rw.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(rw, "{\"Error\": \"Limit query parameter must be a non-negative integer.\"}")
+			return
+		}
+		query.Limit = int(limit)
+	}
+	forceBase64 := queryParams.Get("encoding") == stateValueBase64
+
+	page, err := s.server.ListState(context.Background(), query)
+
+	// Check for error
+	if err != nil {
+		// Failure
+		rw.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(rw, "{\"Error\": \"%s\"}", err)
+		restLogger.Error(fmt.Sprintf("{\"Error\": \"%s\"}", err))
+	} else {
+		// Success
+		restPage := &restStatePage{KeyValues: []*restStateKeyValue{}, NextCursor: page.NextCursor}
+		for _, kv := range page.KeyValues {
+			restPage.KeyValues = append(restPage.KeyValues, &restStateKeyValue{kv.Key, encodeStateValue(kv.Value, forceBase64)})
+		}
+		rw.WriteHeader(http.StatusOK)
+		encoder := json.NewEncoder(rw)
+		encoder.Encode(restPage)
+	}
+}
+
+// GetStateHistory returns the values taken by a key of the world state at the end of the
+// committed blocks for which the state delta is retained (see 'ledger.state.deltaHistorySize'),
+// oldest first, along with the last transaction of each block which changed the key.
+// The values are encoded as by GetStateValue.
+func (s *ServerOpenchainREST) GetStateHistory(rw web.ResponseWriter, req *web.Request) {
+	if !checkAccess(rw, req, acl.QueryAPI) {
+		return
+	}
+
+	chaincodeID := req.PathParams["chaincodeID"]
+	key := req.PathParams["key"]
+	req.ParseForm()
+	forceBase64 := req.Form.Get("encoding") == stateValueBase64
+
+	modifications, err := s.server.GetStateHistory(context.Background(), chaincodeID, key)
+
+	// Check for error
+	if err != nil {
+		// Failure
+		rw.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(rw, "{\"Error\": \"%s\"}", err)
+		restLogger.Error(fmt.Sprintf("{\"Error\": \"%s\"}", err))
+	} else {
+		// Success
+		history := []*restKeyModification{}
+		for _, modification := range modifications {
+			restModification := &restKeyModification{TxUUID: modification.TxUUID, BlockNumber: modification.BlockNumber, IsDelete: modification.IsDelete}
+			if !modification.IsDelete {
+				restModification.restStateValue = encodeStateValue(modification.Value, forceBase64)
+			}
+			history = append(history, restModification)
+		}
+		rw.WriteHeader(http.StatusOK)
+		encoder := json.NewEncoder(rw)
+		encoder.Encode(history)
+	}
+}
+
+// GetKeyProvenance returns the chain of changes made to a key of the world state by the
+// transactions of the committed blocks for which the state delta is retained (see
+// 'ledger.state.deltaHistorySize'), oldest first: the block, the transaction, the chaincode
+// it invoked and the hash of the value written, if not deleted, for each transaction.
+func (s *ServerOpenchainREST) GetKeyProvenance(rw web.ResponseWriter, req *web.Request) {
+	if !checkAccess(rw, req, acl.QueryAPI) {
+		return
+	}
+
+	chaincodeID := req.PathParams["chaincodeID"]
+	key := req.PathParams["key"]
+
+	entries, err := s.server.GetKeyProvenance(context.Background(), chaincodeID, key)
+
+	// Check for error
+	if err != nil {
+		// Failure
+		rw.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(rw, "{\"Error\": \"%s\"}", err)
+		restLogger.Error(fmt.Sprintf("{\"Error\": \"%s\"}", err))
+	} else {
+		// Success
+		rw.WriteHeader(http.StatusOK)
+		encoder := json.NewEncoder(rw)
+		encoder.Encode(entries)
+	}
+}
+
+// ReconcileNamespace compares the key-values of a chaincode, as these were after the block
+// given by the block query parameter was committed, with the expected key-values of the body
+// and returns the mismatches. The body is either a JSON object of string values or, with the
+// Content-Type text/csv, CSV records of a key and a value. The block has to be within the
+// state delta history (see 'ledger.state.deltaHistorySize').
+func (s *ServerOpenchainREST) ReconcileNamespace(rw web.ResponseWriter, req *web.Request) {
+	if !checkAccess(rw, req, acl.QueryAPI) {
+		return
+	}
+
+	chaincodeID := req.PathParams["chaincodeID"]
+	blockNumber, err := strconv.ParseUint(req.URL.Query().Get("block"), 10, 64)
+	if err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(rw, "{\"Error\": \"Block query parameter must be a non-negative integer.\"}")
+		return
+	}
+
+	var expected map[string][]byte
+	if mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type")); mediaType == "text/csv" {
+		expected, err = ledger.ParseReconciliationDatasetCSV(req.Body)
+	} else {
+		expected, err = ledger.ParseReconciliationDatasetJSON(req.Body)
+	}
+	if err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(rw, "{\"Error\": \"%s\"}", err)
+		return
+	}
+
+	report, err := s.server.ReconcileNamespace(context.Background(), chaincodeID, blockNumber, expected)
+
+	// Check for error
+	if err != nil {
+		// Failure
+		rw.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(rw, "{\"Error\": \"%s\"}", err)
+		restLogger.Error(fmt.Sprintf("{\"Error\": \"%s\"}", err))
+	} else {
+		// Success
+		rw.WriteHeader(http.StatusOK)
+		encoder := json.NewEncoder(rw)
+		encoder.Encode(report)
+	}
+}
+
+// GetDBSpaceReport returns the live and dead bytes held by each column family of the
+// ledger DB, the time of their last compaction and the estimated reclaimable space.
+func (s *ServerOpenchainREST) GetDBSpaceReport(rw web.ResponseWriter, req *web.Request) {
+	if !checkAccess(rw, req, acl.AdminAPI) {
+		return
+	}
+
+	report, err := s.server.GetDBSpaceReport(context.Background())
+
+	// Check for error
+	if err != nil {
+		// Failure
+		rw.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(rw, "{\"Error\": \"Error retrieving DB space report: %s\"}", err)
+		restLogger.Error(fmt.Sprintf("{\"Error\": \"Error retrieving DB space report: %s\"}", err))
+	} else {
+		// Success
+		rw.WriteHeader(http.StatusOK)
+		encoder := json.NewEncoder(rw)
+		encoder.Encode(report)
+	}
+}
+
 // Deploy first builds the chaincode package and subsequently deploys it to the
 // blockchain.
 func (s *ServerOpenchainREST) Deploy(rw web.ResponseWriter, req *web.Request) {
+	if !checkAccess(rw, req, acl.DeployAPI) {
+		return
+	}
+
 	restLogger.Info("REST deploying chaincode...")
 
 	// This endpoint has been deprecated. Add a warning header to all responses.
@@ -792,12 +1386,12 @@ func (s *ServerOpenchainREST) Deploy(rw web.ResponseWriter, req *web.Request) {
 	}
 
 	// Deploy the ChaincodeSpec
-	chaincodeDeploymentSpec, err := s.devops.Deploy(context.Background(), &spec)
+	chaincodeDeploymentSpec, err := s.devops.Deploy(clientContext(req), &spec)
 	if err != nil {
 		// Replace " characters with '
 		errVal := strings.Replace(err.Error(), "\"", "'", -1)
 
-		rw.WriteHeader(http.StatusBadRequest)
+		rw.WriteHeader(devopsErrorStatus(err))
 		fmt.Fprintf(rw, "{\"Error\": \"%s\"}", errVal)
 		restLogger.Error(fmt.Sprintf("{\"Error\": \"Deploying Chaincode -- %s\"}", errVal))
 
@@ -814,6 +1408,10 @@ func (s *ServerOpenchainREST) Deploy(rw web.ResponseWriter, req *web.Request) {
 
 // Invoke executes a specified function within a target Chaincode.
 func (s *ServerOpenchainREST) Invoke(rw web.ResponseWriter, req *web.Request) {
+	if !checkAccess(rw, req, acl.InvokeAPI) {
+		return
+	}
+
 	restLogger.Info("REST invoking chaincode...")
 
 	// This endpoint has been deprecated. Add a warning header to all responses.
@@ -931,12 +1529,12 @@ func (s *ServerOpenchainREST) Invoke(rw web.ResponseWriter, req *web.Request) {
 	}
 
 	// Invoke the chainCode
-	resp, err := s.devops.Invoke(context.Background(), &spec)
+	resp, err := s.devops.Invoke(clientContext(req), &spec)
 	if err != nil {
 		// Replace " characters with '
 		errVal := strings.Replace(err.Error(), "\"", "'", -1)
 
-		rw.WriteHeader(http.StatusBadRequest)
+		rw.WriteHeader(devopsErrorStatus(err))
 		fmt.Fprintf(rw, "{\"Error\": \"%s\"}", errVal)
 		restLogger.Error(fmt.Sprintf("{\"Error\": \"Invoking Chaincode -- %s\"}", errVal))
 
@@ -954,6 +1552,10 @@ func (s *ServerOpenchainREST) Invoke(rw web.ResponseWriter, req *web.Request) {
 
 // Query performs the requested query on the target Chaincode.
 func (s *ServerOpenchainREST) Query(rw web.ResponseWriter, req *web.Request) {
+	if !checkAccess(rw, req, acl.QueryAPI) {
+		return
+	}
+
 	restLogger.Info("REST querying chaincode...")
 
 	// This endpoint has been deprecated. Add a warning header to all responses.
@@ -1071,12 +1673,12 @@ func (s *ServerOpenchainREST) Query(rw web.ResponseWriter, req *web.Request) {
 	}
 
 	// Query the chainCode
-	resp, err := s.devops.Query(context.Background(), &spec)
+	resp, err := s.devops.Query(clientContext(req), &spec)
 	if err != nil {
 		// Replace " characters with '
 		errVal := strings.Replace(err.Error(), "\"", "'", -1)
 
-		rw.WriteHeader(http.StatusBadRequest)
+		rw.WriteHeader(devopsErrorStatus(err))
 		fmt.Fprintf(rw, "{\"Error\": \"%s\"}", errVal)
 		restLogger.Error(fmt.Sprintf("{\"Error\": \"Querying Chaincode -- %s\"}", errVal))
 
@@ -1274,7 +1876,7 @@ func (s *ServerOpenchainREST) ProcessChaincode(rw web.ResponseWriter, req *web.R
 		deploySpec := requestPayload.Params
 
 		// Process the chaincode deployment request and record the result
-		result = s.processChaincodeDeploy(deploySpec)
+		result = s.processChaincodeDeploy(clientContext(req), deploySpec)
 	} else {
 
 		//
@@ -1305,7 +1907,7 @@ func (s *ServerOpenchainREST) ProcessChaincode(rw web.ResponseWriter, req *web.R
 		}
 
 		// Process the chaincode invoke/query request and record the result
-		result = s.processChaincodeInvokeOrQuery(*(requestPayload.Method), invokequeryPayload)
+		result = s.processChaincodeInvokeOrQuery(clientContext(req), *(requestPayload.Method), invokequeryPayload)
 	}
 
 	//
@@ -1317,7 +1919,13 @@ func (s *ServerOpenchainREST) ProcessChaincode(rw web.ResponseWriter, req *web.R
 
 	// If the request is not a notification, produce a response.
 	if !notification {
-		rw.WriteHeader(http.StatusOK)
+		if result.Error != nil && result.Error.Code == RateLimitedError.Code {
+			rw.WriteHeader(http.StatusTooManyRequests)
+		} else if result.Error != nil && result.Error.Code == AccessDeniedError.Code {
+			rw.WriteHeader(http.StatusForbidden)
+		} else {
+			rw.WriteHeader(http.StatusOK)
+		}
 		fmt.Fprintf(rw, string(jsonResponse))
 	}
 
@@ -1332,7 +1940,7 @@ func (s *ServerOpenchainREST) ProcessChaincode(rw web.ResponseWriter, req *web.R
 }
 
 // processChaincodeDeploy triggers chaincode deploy and returns a result or an error
-func (s *ServerOpenchainREST) processChaincodeDeploy(spec *pb.ChaincodeSpec) rpcResult {
+func (s *ServerOpenchainREST) processChaincodeDeploy(ctx context.Context, spec *pb.ChaincodeSpec) rpcResult {
 	restLogger.Info("REST deploying chaincode...")
 
 	// Check that the ChaincodeID is not nil.
@@ -1449,7 +2057,7 @@ func (s *ServerOpenchainREST) processChaincodeDeploy(spec *pb.ChaincodeSpec) rpc
 	// Trigger the chaincode deployment through the devops service
 	//
 
-	chaincodeDeploymentSpec, err := s.devops.Deploy(context.Background(), spec)
+	chaincodeDeploymentSpec, err := s.devops.Deploy(ctx, spec)
 
 	//
 	// Deployment failed
@@ -1460,7 +2068,7 @@ func (s *ServerOpenchainREST) processChaincodeDeploy(spec *pb.ChaincodeSpec) rpc
 		errVal := strings.Replace(err.Error(), "\"", "'", -1)
 
 		// Format the error appropriately for further processing
-		error := formatRPCError(ChaincodeDeployError.Code, ChaincodeDeployError.Message, fmt.Sprintf("Error when deploying chaincode: %s", errVal))
+		error := formatDevopsError(err, ChaincodeDeployError, fmt.Sprintf("Error when deploying chaincode: %s", errVal))
 		restLogger.Error(fmt.Sprintf("Error when deploying chaincode: %s", errVal))
 
 		return error
@@ -1484,7 +2092,7 @@ func (s *ServerOpenchainREST) processChaincodeDeploy(spec *pb.ChaincodeSpec) rpc
 }
 
 // processChaincodeInvokeOrQuery triggers chaincode invoke or query and returns a result or an error
-func (s *ServerOpenchainREST) processChaincodeInvokeOrQuery(method string, spec *pb.ChaincodeInvocationSpec) rpcResult {
+func (s *ServerOpenchainREST) processChaincodeInvokeOrQuery(ctx context.Context, method string, spec *pb.ChaincodeInvocationSpec) rpcResult {
 	restLogger.Info(fmt.Sprintf("REST %s chaincode...", method))
 
 	// Check that the ChaincodeID is not nil.
@@ -1585,7 +2193,7 @@ func (s *ServerOpenchainREST) processChaincodeInvokeOrQuery(method string, spec
 		// Trigger the chaincode invoke through the devops service
 		//
 
-		resp, err := s.devops.Invoke(context.Background(), spec)
+		resp, err := s.devops.Invoke(ctx, spec)
 
 		//
 		// Invocation failed
@@ -1596,7 +2204,7 @@ func (s *ServerOpenchainREST) processChaincodeInvokeOrQuery(method string, spec
 			errVal := strings.Replace(err.Error(), "\"", "'", -1)
 
 			// Format the error appropriately for further processing
-			error := formatRPCError(ChaincodeInvokeError.Code, ChaincodeInvokeError.Message, fmt.Sprintf("Error when invoking chaincode: %s", errVal))
+			error := formatDevopsError(err, ChaincodeInvokeError, fmt.Sprintf("Error when invoking chaincode: %s", errVal))
 			restLogger.Error(fmt.Sprintf("Error when invoking chaincode: %s", errVal))
 
 			return error
@@ -1624,7 +2232,7 @@ func (s *ServerOpenchainREST) processChaincodeInvokeOrQuery(method string, spec
 		// Trigger the chaincode query through the devops service
 		//
 
-		resp, err := s.devops.Query(context.Background(), spec)
+		resp, err := s.devops.Query(ctx, spec)
 
 		//
 		// Query failed
@@ -1635,7 +2243,7 @@ func (s *ServerOpenchainREST) processChaincodeInvokeOrQuery(method string, spec
 			errVal := strings.Replace(err.Error(), "\"", "'", -1)
 
 			// Format the error appropriately for further processing
-			error := formatRPCError(ChaincodeQueryError.Code, ChaincodeQueryError.Message, fmt.Sprintf("Error when querying chaincode: %s", errVal))
+			error := formatDevopsError(err, ChaincodeQueryError, fmt.Sprintf("Error when querying chaincode: %s", errVal))
 			restLogger.Error(fmt.Sprintf("Error when querying chaincode: %s", errVal))
 
 			return error
@@ -1661,6 +2269,10 @@ func (s *ServerOpenchainREST) processChaincodeInvokeOrQuery(method string, spec
 
 // GetPeers returns a list of all peer nodes currently connected to the target peer, including itself
 func (s *ServerOpenchainREST) GetPeers(rw web.ResponseWriter, req *web.Request) {
+	if !checkAccess(rw, req, acl.QueryAPI) {
+		return
+	}
+
 	peers, err := s.server.GetPeers(context.Background(), &google_protobuf.Empty{})
 	currentPeer, err1 := s.server.GetPeerEndpoint(context.Background(), &google_protobuf.Empty{})
 
@@ -1719,27 +2331,8 @@ func StartOpenchainRESTServer(server *ServerOpenchain, devops *core.Devops) {
 	router.Middleware((*ServerOpenchainREST).SetOpenchainServer)
 	router.Middleware((*ServerOpenchainREST).SetResponseType)
 
-	// Add routes
-	router.Post("/registrar", (*ServerOpenchainREST).Register)
-	router.Get("/registrar/:id", (*ServerOpenchainREST).GetEnrollmentID)
-	router.Delete("/registrar/:id", (*ServerOpenchainREST).DeleteEnrollmentID)
-	router.Get("/registrar/:id/ecert", (*ServerOpenchainREST).GetEnrollmentCert)
-	router.Get("/registrar/:id/tcert", (*ServerOpenchainREST).GetTransactionCert)
-
-	router.Get("/chain", (*ServerOpenchainREST).GetBlockchainInfo)
-	router.Get("/chain/blocks/:id", (*ServerOpenchainREST).GetBlockByNumber)
-
-	// The /devops endpoint is now considered deprecated and superseded by the /chaincode endpoint
-	router.Post("/devops/deploy", (*ServerOpenchainREST).Deploy)
-	router.Post("/devops/invoke", (*ServerOpenchainREST).Invoke)
-	router.Post("/devops/query", (*ServerOpenchainREST).Query)
-
-	// The /chaincode endpoint which superceedes the /devops endpoint from above
-	router.Post("/chaincode", (*ServerOpenchainREST).ProcessChaincode)
-
-	router.Get("/transactions/:uuid", (*ServerOpenchainREST).GetTransactionByUUID)
-
-	router.Get("/network/peers", (*ServerOpenchainREST).GetPeers)
+	// Add the routes of the operations of the REST API specification
+	addRESTOperations(router, restOperations)
 
 	// Add not found page
 	router.NotFound((*ServerOpenchainREST).NotFound)
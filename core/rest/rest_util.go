@@ -16,7 +16,21 @@ limitations under the License.
 
 package rest
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"unicode/utf8"
+
+	"github.com/gocraft/web"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+
+	"github.com/hyperledger/fabric/core/acl"
+	"github.com/hyperledger/fabric/events/producer"
+	pb "github.com/hyperledger/fabric/protos"
+)
 
 // isJSON is a helper function to determine if a given string is proper JSON.
 func isJSON(s string) bool {
@@ -24,6 +38,58 @@ func isJSON(s string) bool {
 	return json.Unmarshal([]byte(s), &js) == nil
 }
 
+const (
+	stateValueJSON   = "json"
+	stateValueUTF8   = "utf8"
+	stateValueBase64 = "base64"
+)
+
+// restStateValue is a value of the world state as returned by the REST API, along with
+// the encoding of the value in the response
+type restStateValue struct {
+	Value    json.RawMessage `json:"value,omitempty"`
+	Encoding string          `json:"encoding,omitempty"`
+}
+
+// restStateKeyValue is a key of the world state along with its value
+type restStateKeyValue struct {
+	Key string `json:"key"`
+	restStateValue
+}
+
+// restStatePage is a page of the key-values of a chaincode
+type restStatePage struct {
+	KeyValues  []*restStateKeyValue `json:"keyValues"`
+	NextCursor string               `json:"nextCursor,omitempty"`
+}
+
+// restKeyModification is a change made to a key by a block, without a value if the key
+// was deleted
+type restKeyModification struct {
+	TxUUID      string `json:"txUUID"`
+	BlockNumber uint64 `json:"blockNumber"`
+	IsDelete    bool   `json:"isDelete"`
+	restStateValue
+}
+
+// encodeStateValue returns the value as is if it is a JSON document, as a string if it
+// is valid UTF-8, and base64 encoded otherwise or if forceBase64 is set
+func encodeStateValue(value []byte, forceBase64 bool) restStateValue {
+	var document interface{}
+	if !forceBase64 && json.Unmarshal(value, &document) == nil {
+		return restStateValue{json.RawMessage(value), stateValueJSON}
+	}
+	var encoded []byte
+	encoding := stateValueBase64
+	if !forceBase64 && utf8.Valid(value) {
+		encoded, _ = json.Marshal(string(value))
+		encoding = stateValueUTF8
+	} else {
+		encoded, _ = json.Marshal(value)
+	}
+	return restStateValue{encoded, encoding}
+}
+
 // formatRPCError formats the ERROR response to aid in JSON RPC 2.0 implementation
 func formatRPCError(code int64, msg string, data string) rpcResult {
 	err := &rpcError{Code: code, Message: msg, Data: data}
@@ -32,6 +98,54 @@ func formatRPCError(code int64, msg string, data string) rpcResult {
 	return error
 }
 
+// checkAccess writes a 403 response and returns false if the client of the request may
+// not call the API. The requests of ProcessChaincode are checked by the devops service,
+// as the API they call depends on their method (see clientContext)
+func checkAccess(rw web.ResponseWriter, req *web.Request, api string) bool {
+	if err := acl.CheckHTTP(req.Request, api); err != nil {
+		rw.WriteHeader(http.StatusForbidden)
+		fmt.Fprintf(rw, "{\"Error\": \"%s\"}", err)
+		return false
+	}
+	return true
+}
+
+// clientContext returns the context of the calls of the devops service serving the
+// request, which identifies the client of the request to the access control list
+func clientContext(req *web.Request) context.Context {
+	return acl.NewContext(context.Background(), acl.HTTPClientIdentity(req.Request))
+}
+
+// isRateLimited tells whether the devops service rejected a request for exceeding the
+// admission limits of the peer
+func isRateLimited(err error) bool {
+	return grpc.Code(err) == codes.ResourceExhausted
+}
+
+// devopsErrorStatus returns the HTTP status of a request the devops service failed
+func devopsErrorStatus(err error) int {
+	switch {
+	case isRateLimited(err):
+		return http.StatusTooManyRequests
+	case grpc.Code(err) == codes.PermissionDenied:
+		return http.StatusForbidden
+	}
+	return http.StatusBadRequest
+}
+
+// formatDevopsError formats the ERROR response of a request the devops service failed,
+// with the code of the rate limited requests if it was rejected for exceeding the limits,
+// or the code of the denied requests if the client may not make it
+func formatDevopsError(err error, failure *rpcError, data string) rpcResult {
+	switch {
+	case isRateLimited(err):
+		return formatRPCError(RateLimitedError.Code, RateLimitedError.Message, data)
+	case grpc.Code(err) == codes.PermissionDenied:
+		return formatRPCError(AccessDeniedError.Code, AccessDeniedError.Message, data)
+	}
+	return formatRPCError(failure.Code, failure.Message, data)
+}
+
 // formatRPCOK formats the OK response to aid in JSON RPC 2.0 implementation
 func formatRPCOK(msg string) rpcResult {
 	result := rpcResult{Status: "OK", Message: msg}
@@ -54,3 +168,44 @@ func formatRPCResponse(res rpcResult, id *rpcID) rpcResponse {
 
 	return response
 }
+
+const (
+	streamMessageBlock     = "block"
+	streamMessageChaincode = "chaincode"
+)
+
+// restStreamMessage is a message of the event stream: a committed block, or a chaincode
+// event set by a transaction of the block. BlockNumber is unknown for the blocks sent
+// without their number to the event hub
+type restStreamMessage struct {
+	Type           string             `json:"type"`
+	BlockNumber    *uint64            `json:"blockNumber,omitempty"`
+	Block          *pb.Block          `json:"block,omitempty"`
+	ChaincodeEvent *pb.ChaincodeEvent `json:"chaincodeEvent,omitempty"`
+}
+
+// streamMessages returns the messages of the stream for a block event, of the types
+// asked for: the block itself followed by the chaincode events it holds
+func streamMessages(e *producer.LocalEvent, types map[string]bool) []*restStreamMessage {
+	block := e.Event.GetBlock()
+	if block == nil {
+		return nil
+	}
+	var blockNumber *uint64
+	if e.Numbered {
+		n := e.BlockNumber
+		blockNumber = &n
+	}
+	var messages []*restStreamMessage
+	if types[streamMessageBlock] {
+		messages = append(messages, &restStreamMessage{Type: streamMessageBlock, BlockNumber: blockNumber, Block: block})
+	}
+	if types[streamMessageChaincode] {
+		for _, result := range block.GetNonHashData().GetTransactionResults() {
+			if event := result.GetChaincodeEvent(); event != nil {
+				messages = append(messages, &restStreamMessage{Type: streamMessageChaincode, BlockNumber: blockNumber, ChaincodeEvent: event})
+			}
+		}
+	}
+	return messages
+}
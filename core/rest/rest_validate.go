@@ -0,0 +1,391 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gocraft/web"
+
+	fabricerrors "github.com/hyperledger/fabric/core/errors"
+)
+
+// The operations of the REST API are defined by the Swagger specification in
+// rest_api.json, from which rest_api_spec.go is generated (see gen/main.go). Every
+// operation names the method of ServerOpenchainREST handling it with x-handler, and
+// its requests are checked against the parameters and the body schema of the
+// specification before they reach the handler.
+
+// restOperation is an operation of the REST API specification
+type restOperation struct {
+	ID     string
+	Method string
+	// Path is the route of the operation, with the path parameters prefixed by ':'
+	Path    string
+	Handler func(*ServerOpenchainREST, web.ResponseWriter, *web.Request)
+	Params  []*restParam
+	// Consumes are the media types accepted for the body
+	Consumes []string
+	Body     *restParam
+	// JSONRPC operations report their errors as JSON RPC 2.0 responses, and check
+	// the envelope of their body themselves
+	JSONRPC bool
+}
+
+// restParam is a parameter of an operation. The parameters in the path, the query
+// and the headers have a scalar schema
+type restParam struct {
+	Name     string
+	In       string
+	Required bool
+	Schema   *restSchema
+}
+
+// restSchema is the subset of the Swagger schemas used by the specification
+type restSchema struct {
+	Type      string
+	Format    string
+	Enum      []string
+	MinLength int
+	// ProtoEnum is set for the enums of protobuf messages, which may also be given
+	// by the number of the value, its index in Enum
+	ProtoEnum  bool
+	Required   []string
+	Properties map[string]*restSchema
+	Items      *restSchema
+}
+
+// restError is the body of the responses of the failed requests. Violations list the
+// parameters and fields of a request which does not conform to the specification.
+type restError struct {
+	Error      string            `json:"Error"`
+	Code       fabricerrors.Code `json:"code,omitempty"`
+	Violations []*restViolation  `json:"violations,omitempty"`
+}
+
+// restViolation is a parameter or a field of the body of a request which does not
+// conform to the specification. Name is the path of the field in the body, with its
+// components separated by dots.
+type restViolation struct {
+	In      string `json:"in"`
+	Name    string `json:"name"`
+	Message string `json:"message"`
+}
+
+// route returns the handler of the route of the operation, which validates the
+// requests before passing them on to the handler of the operation.
+func (op *restOperation) route() func(*ServerOpenchainREST, web.ResponseWriter, *web.Request) {
+	return func(s *ServerOpenchainREST, rw web.ResponseWriter, req *web.Request) {
+		if status, restErr := op.validate(req); restErr != nil {
+			op.writeError(rw, status, restErr)
+			return
+		}
+		op.Handler(s, rw, req)
+	}
+}
+
+// addRESTOperations adds the routes of the operations to the router.
+func addRESTOperations(router *web.Router, operations []*restOperation) {
+	for _, op := range operations {
+		switch op.Method {
+		case "GET":
+			router.Get(op.Path, op.route())
+		case "POST":
+			router.Post(op.Path, op.route())
+		case "PUT":
+			router.Put(op.Path, op.route())
+		case "DELETE":
+			router.Delete(op.Path, op.route())
+		case "PATCH":
+			router.Patch(op.Path, op.route())
+		default:
+			panic(fmt.Sprintf("Unsupported method %s of operation %s", op.Method, op.ID))
+		}
+	}
+}
+
+// writeError writes the response of a request which does not conform to the
+// specification.
+func (op *restOperation) writeError(rw web.ResponseWriter, status int, restErr *restError) {
+	restLogger.Error(fmt.Sprintf("Invalid %s request: %s", op.ID, restErr.Error))
+	rw.WriteHeader(status)
+	if op.JSONRPC {
+		response := formatRPCResponse(formatRPCError(InvalidRequest.Code, InvalidRequest.Message, restErr.Error), nil)
+		json.NewEncoder(rw).Encode(response)
+		return
+	}
+	json.NewEncoder(rw).Encode(restErr)
+}
+
+// validate checks the request against the specification of the operation. It returns
+// the status of the response and the error of a request which does not conform to it.
+func (op *restOperation) validate(req *web.Request) (int, *restError) {
+	var violations []*restViolation
+	violate := func(in, name, format string, args ...interface{}) {
+		violations = append(violations, &restViolation{In: in, Name: name, Message: fmt.Sprintf(format, args...)})
+	}
+
+	for _, param := range op.Params {
+		var values []string
+		switch param.In {
+		case "path":
+			values = []string{req.PathParams[param.Name]}
+		case "query":
+			values = req.URL.Query()[param.Name]
+		case "header":
+			values = req.Header[http.CanonicalHeaderKey(param.Name)]
+		}
+		if len(values) == 0 || (param.In == "path" && values[0] == "") {
+			if param.Required {
+				violate(param.In, param.Name, "is required")
+			}
+			continue
+		}
+		for _, value := range values {
+			if msg := param.Schema.checkString(value); msg != "" {
+				violate(param.In, param.Name, "%s", msg)
+			}
+		}
+	}
+	if len(violations) > 0 {
+		return http.StatusBadRequest, newValidationError(violations)
+	}
+
+	if op.Body == nil {
+		return 0, nil
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return http.StatusBadRequest, &restError{Error: fmt.Sprintf("Error reading the body: %s", err), Code: fabricerrors.InvalidArgument}
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if len(bytes.TrimSpace(body)) == 0 {
+		if op.Body.Required && !op.JSONRPC {
+			violate("body", op.Body.Name, "is required")
+			return http.StatusBadRequest, newValidationError(violations)
+		}
+		return 0, nil
+	}
+	if !op.consumes(req.Header.Get("Content-Type")) {
+		return http.StatusUnsupportedMediaType, &restError{
+			Error: fmt.Sprintf("Content-Type must be %s.", strings.Join(op.Consumes, " or ")),
+			Code:  fabricerrors.InvalidArgument,
+		}
+	}
+	if mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type")); op.JSONRPC || mediaType != "application/json" {
+		// only the JSON bodies are checked against the schema
+		return 0, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var document interface{}
+	if err := decoder.Decode(&document); err != nil {
+		violate("body", op.Body.Name, "is not valid JSON: %s", err)
+		return http.StatusBadRequest, newValidationError(violations)
+	}
+	op.Body.Schema.check(document, "", func(name, msg string) {
+		if name == "" {
+			name = op.Body.Name
+		}
+		violate("body", name, "%s", msg)
+	})
+	if len(violations) > 0 {
+		return http.StatusBadRequest, newValidationError(violations)
+	}
+	return 0, nil
+}
+
+// consumes returns whether the body of the content type is accepted
+func (op *restOperation) consumes(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, consumed := range op.Consumes {
+		if mediaType == consumed {
+			return true
+		}
+	}
+	return false
+}
+
+func newValidationError(violations []*restViolation) *restError {
+	messages := make([]string, len(violations))
+	for i, v := range violations {
+		messages[i] = fmt.Sprintf("%s parameter %s %s", v.In, v.Name, v.Message)
+	}
+	return &restError{
+		Error:      fmt.Sprintf("Request does not conform to the REST API specification: %s.", strings.Join(messages, "; ")),
+		Code:       fabricerrors.InvalidArgument,
+		Violations: violations,
+	}
+}
+
+// checkString checks the value of a parameter, returning the reason for which it
+// does not conform to the schema, or an empty string
+func (schema *restSchema) checkString(value string) string {
+	switch schema.Type {
+	case "integer":
+		if msg := checkInteger(value, schema.Format); msg != "" {
+			return msg
+		}
+	case "number":
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return "must be a number"
+		}
+	case "boolean":
+		if _, err := strconv.ParseBool(value); err != nil {
+			return "must be a boolean"
+		}
+	case "string":
+		if utf8.RuneCountInString(value) < schema.MinLength {
+			return fmt.Sprintf("must be at least %d characters long", schema.MinLength)
+		}
+		if schema.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339, value); err != nil {
+				return "must be an RFC 3339 timestamp"
+			}
+		}
+	}
+	if len(schema.Enum) > 0 && !schema.inEnum(value) {
+		return fmt.Sprintf("must be one of %s", strings.Join(schema.Enum, ", "))
+	}
+	return ""
+}
+
+func (schema *restSchema) inEnum(value string) bool {
+	for _, v := range schema.Enum {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// checkInteger checks the value of an integer of the format, one of int32, int64,
+// uint32 and uint64 (int64 by default)
+func checkInteger(value string, format string) string {
+	var err error
+	switch format {
+	case "int32":
+		_, err = strconv.ParseInt(value, 10, 32)
+	case "uint32":
+		_, err = strconv.ParseUint(value, 10, 32)
+	case "uint64":
+		_, err = strconv.ParseUint(value, 10, 64)
+	default:
+		format = "int64"
+		_, err = strconv.ParseInt(value, 10, 64)
+	}
+	if err != nil {
+		return fmt.Sprintf("must be an integer (%s)", format)
+	}
+	return ""
+}
+
+// check checks a JSON document decoded with json.Number numbers against the
+// schema, and reports the fields which do not conform to it by their path
+func (schema *restSchema) check(document interface{}, path string, report func(name, msg string)) {
+	if document == nil {
+		// null is the same as an absent field
+		return
+	}
+	switch schema.Type {
+	case "object":
+		object, ok := document.(map[string]interface{})
+		if !ok {
+			report(path, "must be an object")
+			return
+		}
+		for _, name := range schema.Required {
+			if v, present := object[name]; !present || v == nil {
+				report(joinFieldPath(path, name), "is required")
+			}
+		}
+		names := make([]string, 0, len(object))
+		for name := range object {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if property, ok := schema.Properties[name]; ok {
+				property.check(object[name], joinFieldPath(path, name), report)
+			}
+		}
+	case "array":
+		array, ok := document.([]interface{})
+		if !ok {
+			report(path, "must be an array")
+			return
+		}
+		if schema.Items != nil {
+			for i, item := range array {
+				schema.Items.check(item, joinFieldPath(path, strconv.Itoa(i)), report)
+			}
+		}
+	case "string":
+		if number, ok := document.(json.Number); ok && schema.ProtoEnum {
+			if i, err := number.Int64(); err != nil || i < 0 || i >= int64(len(schema.Enum)) {
+				report(path, fmt.Sprintf("must be one of %s, or their number", strings.Join(schema.Enum, ", ")))
+			}
+			return
+		}
+		s, ok := document.(string)
+		if !ok {
+			report(path, "must be a string")
+			return
+		}
+		if msg := schema.checkString(s); msg != "" {
+			report(path, msg)
+		}
+	case "integer":
+		number, ok := document.(json.Number)
+		if !ok {
+			report(path, "must be an integer")
+			return
+		}
+		if msg := checkInteger(number.String(), schema.Format); msg != "" {
+			report(path, msg)
+		}
+	case "number":
+		if _, ok := document.(json.Number); !ok {
+			report(path, "must be a number")
+		}
+	case "boolean":
+		if _, ok := document.(bool); !ok {
+			report(path, "must be a boolean")
+		}
+	}
+}
+
+func joinFieldPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
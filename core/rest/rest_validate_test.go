@@ -0,0 +1,147 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rest
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gocraft/web"
+)
+
+func findRESTOperation(t *testing.T, id string) *restOperation {
+	for _, op := range restOperations {
+		if op.ID == id {
+			return op
+		}
+	}
+	t.Fatalf("Operation %s not found", id)
+	return nil
+}
+
+func newValidationRequest(t *testing.T, method, url, contentType, body string, pathParams map[string]string) *web.Request {
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Error creating the request: %s", err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	return &web.Request{Request: req, PathParams: pathParams}
+}
+
+func TestValidateParams(t *testing.T) {
+	listBlocks := findRESTOperation(t, "listBlocks")
+	for _, test := range []struct {
+		query      string
+		violations []string
+	}{
+		{"limit=10&cursor=7&startTime=2016-08-01T00:00:00Z&omitPayloads=true", nil},
+		{"limit=-1", []string{"limit"}},
+		{"cursor=x&endTime=yesterday&omitPayloads=maybe", []string{"cursor", "endTime", "omitPayloads"}},
+	} {
+		req := newValidationRequest(t, "GET", "/chain/blocks?"+test.query, "", "", nil)
+		status, restErr := listBlocks.validate(req)
+		if len(test.violations) == 0 {
+			if restErr != nil {
+				t.Fatalf("Expected %s to be valid, got %s", test.query, restErr.Error)
+			}
+			continue
+		}
+		if status != http.StatusBadRequest || restErr == nil || len(restErr.Violations) != len(test.violations) {
+			t.Fatalf("Expected %d violations for %s, got %d %v", len(test.violations), test.query, status, restErr)
+		}
+		for i, name := range test.violations {
+			if v := restErr.Violations[i]; v.In != "query" || v.Name != name {
+				t.Fatalf("Expected a violation of query parameter %s, got %v", name, v)
+			}
+		}
+	}
+
+	getBlock := findRESTOperation(t, "getBlock")
+	if _, restErr := getBlock.validate(newValidationRequest(t, "GET", "/chain/blocks/x", "", "", map[string]string{"Block": "x"})); restErr == nil {
+		t.Fatalf("Expected a block number which is not an integer to be rejected")
+	}
+	getStateValue := findRESTOperation(t, "getStateValue")
+	if _, restErr := getStateValue.validate(newValidationRequest(t, "GET", "/state/cc/k?encoding=hex", "", "", map[string]string{"chaincodeID": "cc", "key": "k"})); restErr == nil {
+		t.Fatalf("Expected an encoding which is not in the enum to be rejected")
+	}
+}
+
+func TestValidateBody(t *testing.T) {
+	deploy := findRESTOperation(t, "chaincodeDeploy")
+	for _, test := range []struct {
+		contentType string
+		body        string
+		status      int
+		violations  []string
+	}{
+		{"application/json", `{"type": "GOLANG", "chaincodeID": {"path": "example"}, "ctorMsg": {"args": ["a", "100"]}}`, 0, nil},
+		{"application/json; charset=utf-8", `{"type": 1, "confidentialityLevel": "PUBLIC"}`, 0, nil},
+		{"text/plain", `{"type": "GOLANG"}`, http.StatusUnsupportedMediaType, nil},
+		{"application/json", ``, http.StatusBadRequest, []string{"ChaincodeSpec"}},
+		{"application/json", `{"type": "COBOL"`, http.StatusBadRequest, []string{"ChaincodeSpec"}},
+		{"application/json", `[]`, http.StatusBadRequest, []string{"ChaincodeSpec"}},
+		{"application/json", `{"type": 9, "chaincodeID": {"name": 1}, "ctorMsg": {"args": ["a", 100]}}`, http.StatusBadRequest,
+			[]string{"chaincodeID.name", "ctorMsg.args.1", "type"}},
+	} {
+		req := newValidationRequest(t, "POST", "/devops/deploy", test.contentType, test.body, nil)
+		status, restErr := deploy.validate(req)
+		if status != test.status || (restErr == nil) != (test.status == 0) {
+			t.Fatalf("Expected status %d for %s, got %d %v", test.status, test.body, status, restErr)
+		}
+		if restErr == nil {
+			// the handler reads the body validated
+			if body, _ := ioutil.ReadAll(req.Body); string(body) != test.body {
+				t.Fatalf("Expected the body to be passed on to the handler, got %s", body)
+			}
+			continue
+		}
+		if len(restErr.Violations) != len(test.violations) {
+			t.Fatalf("Expected %d violations for %s, got %v", len(test.violations), test.body, restErr.Error)
+		}
+		for i, name := range test.violations {
+			if v := restErr.Violations[i]; v.In != "body" || v.Name != name {
+				t.Fatalf("Expected a violation of body field %s, got %v", name, v)
+			}
+		}
+	}
+
+	register := findRESTOperation(t, "registerUser")
+	_, restErr := register.validate(newValidationRequest(t, "POST", "/registrar", "application/json", `{"enrollId": ""}`, nil))
+	if restErr == nil || len(restErr.Violations) != 2 {
+		t.Fatalf("Expected an empty enrollId and a missing enrollSecret to be rejected, got %v", restErr)
+	}
+
+	// the JSON RPC handler checks the envelope of its requests itself
+	chaincodeOp := findRESTOperation(t, "chaincodeOp")
+	if _, restErr := chaincodeOp.validate(newValidationRequest(t, "POST", "/chaincode", "application/json", `{"method": 1}`, nil)); restErr != nil {
+		t.Fatalf("Expected the body of a JSON RPC request not to be validated, got %s", restErr.Error)
+	}
+
+	// the bodies which are not JSON are left to the handler
+	reconcile := findRESTOperation(t, "reconcileNamespace")
+	params := map[string]string{"chaincodeID": "cc1"}
+	if _, restErr := reconcile.validate(newValidationRequest(t, "POST", "/state/cc1/reconcile?block=1", "text/csv", "key1,value1\n", params)); restErr != nil {
+		t.Fatalf("Expected a CSV dataset to be accepted, got %s", restErr.Error)
+	}
+	if _, restErr := reconcile.validate(newValidationRequest(t, "POST", "/state/cc1/reconcile?block=1", "application/json", `["key1"]`, params)); restErr == nil {
+		t.Fatalf("Expected a JSON dataset which is not an object to be rejected")
+	}
+}
@@ -0,0 +1,281 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rest
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The server side of the WebSocket protocol (RFC 6455), limited to what the
+// event stream needs: the server sends text messages, and the client only
+// sends control frames (ping, pong and close), its other messages are ignored.
+
+const (
+	wsOpContinuation = 0x0
+	wsOpText         = 0x1
+	wsOpBinary       = 0x2
+	wsOpClose        = 0x8
+	wsOpPing         = 0x9
+	wsOpPong         = 0xA
+
+	wsCloseNormal          = 1000
+	wsClosePolicyViolation = 1008
+	wsCloseInternalError   = 1011
+
+	// wsAcceptGUID is concatenated to the key of the handshake request to
+	// compute the accept key of the response
+	wsAcceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+	// wsMaxControlPayload is the maximum size of the payload of a control frame
+	wsMaxControlPayload = 125
+	// wsMaxClientPayload is the maximum size of the frames read from the client,
+	// which has nothing to send but control frames
+	wsMaxClientPayload = 4096
+
+	wsWriteTimeout = 10 * time.Second
+	// wsPingInterval is the interval of the pings keeping idle connections open
+	// through proxies
+	wsPingInterval = 30 * time.Second
+)
+
+var errWSClosed = errors.New("WebSocket connection closed")
+
+// wsConn is a WebSocket connection upgraded from an HTTP request
+type wsConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+
+	// writeLock serializes the frames written by the sender of the messages
+	// and by the reader answering the control frames
+	writeLock sync.Mutex
+	closeSent bool
+}
+
+// wsAcceptKey returns the value of the Sec-WebSocket-Accept header answering the
+// Sec-WebSocket-Key of a handshake request.
+func wsAcceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + wsAcceptGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// headerContainsToken returns whether the comma separated list of the header
+// contains the token, ignoring case.
+func headerContainsToken(header http.Header, name string, token string) bool {
+	for _, v := range header[http.CanonicalHeaderKey(name)] {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isWebSocketUpgrade returns whether the request asks to be upgraded to a
+// WebSocket connection
+func isWebSocketUpgrade(req *http.Request) bool {
+	return headerContainsToken(req.Header, "Connection", "upgrade") && headerContainsToken(req.Header, "Upgrade", "websocket")
+}
+
+// upgradeWebSocket completes the opening handshake of the WebSocket protocol and
+// takes over the connection of the request. The errors returned before the
+// connection is taken over are meant to be returned to the client with status
+// http.StatusBadRequest.
+func upgradeWebSocket(rw http.ResponseWriter, req *http.Request) (*wsConn, error) {
+	if req.Method != "GET" {
+		return nil, fmt.Errorf("WebSocket handshake must use the GET method")
+	}
+	if !isWebSocketUpgrade(req) {
+		return nil, fmt.Errorf("Request is not a WebSocket handshake")
+	}
+	if req.Header.Get("Sec-WebSocket-Version") != "13" {
+		rw.Header().Set("Sec-WebSocket-Version", "13")
+		return nil, fmt.Errorf("Unsupported WebSocket version, must be 13")
+	}
+	key := req.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		return nil, fmt.Errorf("Invalid Sec-WebSocket-Key")
+	}
+	hijacker, ok := rw.(http.Hijacker)
+	if !ok {
+		return nil, fmt.Errorf("Connection cannot be upgraded")
+	}
+	conn, buf, err := hijacker.Hijack()
+	if err != nil {
+		return nil, fmt.Errorf("Connection cannot be upgraded: %s", err)
+	}
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + wsAcceptKey(key) + "\r\n\r\n"
+	conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	if _, err := conn.Write([]byte(response)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &wsConn{conn: conn, reader: buf.Reader}, nil
+}
+
+// writeFrame writes an unfragmented frame. The frames of the server are not masked.
+func (c *wsConn) writeFrame(opcode byte, payload []byte) error {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	if c.closeSent {
+		return errWSClosed
+	}
+	if opcode == wsOpClose {
+		c.closeSent = true
+	}
+
+	header := make([]byte, 2, 10)
+	header[0] = 0x80 | opcode
+	switch length := len(payload); {
+	case length <= 125:
+		header[1] = byte(length)
+	case length <= 0xFFFF:
+		header[1] = 126
+		header = header[:4]
+		binary.BigEndian.PutUint16(header[2:], uint16(length))
+	default:
+		header[1] = 127
+		header = header[:10]
+		binary.BigEndian.PutUint64(header[2:], uint64(length))
+	}
+	c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	if _, err := c.conn.Write(header); err != nil {
+		return err
+	}
+	_, err := c.conn.Write(payload)
+	return err
+}
+
+// WriteText sends a text message.
+func (c *wsConn) WriteText(message []byte) error {
+	return c.writeFrame(wsOpText, message)
+}
+
+// Ping sends a ping, which the client answers with a pong.
+func (c *wsConn) Ping() error {
+	return c.writeFrame(wsOpPing, nil)
+}
+
+// Close sends a close frame with the status code and reason, unless one has
+// been sent already, and closes the connection.
+func (c *wsConn) Close(code int, reason string) error {
+	if len(reason) > wsMaxControlPayload-2 {
+		reason = reason[:wsMaxControlPayload-2]
+	}
+	payload := make([]byte, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	copy(payload[2:], reason)
+	c.writeFrame(wsOpClose, payload)
+	return c.conn.Close()
+}
+
+// readFrame reads a frame sent by the client, whose frames must be masked.
+func (c *wsConn) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	var header [2]byte
+	if _, err = io.ReadFull(c.reader, header[:]); err != nil {
+		return
+	}
+	fin = header[0]&0x80 != 0
+	opcode = header[0] & 0x0F
+	if header[1]&0x80 == 0 {
+		err = fmt.Errorf("Client frame is not masked")
+		return
+	}
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var extended [2]byte
+		if _, err = io.ReadFull(c.reader, extended[:]); err != nil {
+			return
+		}
+		length = uint64(binary.BigEndian.Uint16(extended[:]))
+	case 127:
+		var extended [8]byte
+		if _, err = io.ReadFull(c.reader, extended[:]); err != nil {
+			return
+		}
+		length = binary.BigEndian.Uint64(extended[:])
+	}
+	if opcode >= wsOpClose && (length > wsMaxControlPayload || !fin) {
+		err = fmt.Errorf("Invalid control frame")
+		return
+	}
+	if length > wsMaxClientPayload {
+		err = fmt.Errorf("Client frame of %d bytes is too large", length)
+		return
+	}
+	var mask [4]byte
+	if _, err = io.ReadFull(c.reader, mask[:]); err != nil {
+		return
+	}
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(c.reader, payload); err != nil {
+		return
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return
+}
+
+// readLoop reads the frames of the client until the connection is closed,
+// answering the pings and the close frame of the client. It returns nil when
+// the client closes the connection.
+func (c *wsConn) readLoop() error {
+	for {
+		_, opcode, payload, err := c.readFrame()
+		if err != nil {
+			c.Close(wsClosePolicyViolation, err.Error())
+			return err
+		}
+		switch opcode {
+		case wsOpPing:
+			if err := c.writeFrame(wsOpPong, payload); err != nil {
+				return err
+			}
+		case wsOpClose:
+			// echo the status code of the client
+			code := wsCloseNormal
+			if len(payload) >= 2 {
+				code = int(binary.BigEndian.Uint16(payload))
+			}
+			c.Close(code, "")
+			return nil
+		case wsOpPong, wsOpText, wsOpBinary, wsOpContinuation:
+			// nothing is expected from the client
+		default:
+			err := fmt.Errorf("Unknown opcode %d", opcode)
+			c.Close(wsClosePolicyViolation, err.Error())
+			return err
+		}
+	}
+}
@@ -0,0 +1,125 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rest
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWebSocketAcceptKey(t *testing.T) {
+	// example of RFC 6455
+	if accept := wsAcceptKey("dGhlIHNhbXBsZSBub25jZQ=="); accept != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("Unexpected accept key %s", accept)
+	}
+}
+
+// writeClientFrame writes a masked frame with a short payload, as a client does
+func writeClientFrame(t *testing.T, w io.Writer, opcode byte, payload []byte) {
+	mask := []byte{1, 2, 3, 4}
+	frame := []byte{0x80 | opcode, 0x80 | byte(len(payload))}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	if _, err := w.Write(frame); err != nil {
+		t.Fatalf("Error writing the frame: %s", err)
+	}
+}
+
+// readServerFrame reads an unmasked frame sent by the server
+func readServerFrame(t *testing.T, r io.Reader) (byte, []byte) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(r, header); err != nil {
+		t.Fatalf("Error reading the frame: %s", err)
+	}
+	length := int(header[1] & 0x7F)
+	if length == 126 {
+		extended := make([]byte, 2)
+		io.ReadFull(r, extended)
+		length = int(binary.BigEndian.Uint16(extended))
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		t.Fatalf("Error reading the frame: %s", err)
+	}
+	return header[0] & 0x0F, payload
+}
+
+func TestWebSocketConnection(t *testing.T) {
+	message := strings.Repeat("x", 300)
+	done := make(chan error, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		conn, err := upgradeWebSocket(rw, req)
+		if err != nil {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		conn.WriteText([]byte(message))
+		done <- conn.readLoop()
+	}))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Error sending the request: %s", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected a request which is not a handshake to be rejected, got status %d", resp.StatusCode)
+	}
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatalf("Error connecting: %s", err)
+	}
+	defer conn.Close()
+	handshake := "GET /events HTTP/1.1\r\nHost: localhost\r\nUpgrade: websocket\r\nConnection: keep-alive, Upgrade\r\n" +
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n"
+	if _, err := conn.Write([]byte(handshake)); err != nil {
+		t.Fatalf("Error sending the handshake: %s", err)
+	}
+	reader := bufio.NewReader(conn)
+	resp, err = http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatalf("Error reading the handshake response: %s", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("Unexpected handshake response %d %v", resp.StatusCode, resp.Header)
+	}
+
+	if opcode, payload := readServerFrame(t, reader); opcode != wsOpText || string(payload) != message {
+		t.Fatalf("Unexpected frame %d %q", opcode, payload)
+	}
+	writeClientFrame(t, conn, wsOpPing, []byte("ping"))
+	if opcode, payload := readServerFrame(t, reader); opcode != wsOpPong || string(payload) != "ping" {
+		t.Fatalf("Expected a pong, got %d %q", opcode, payload)
+	}
+	writeClientFrame(t, conn, wsOpClose, []byte{0x03, 0xE8})
+	if opcode, payload := readServerFrame(t, reader); opcode != wsOpClose || binary.BigEndian.Uint16(payload) != wsCloseNormal {
+		t.Fatalf("Expected the close frame to be echoed, got %d %q", opcode, payload)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("Expected the client to close the connection, got %s", err)
+	}
+}
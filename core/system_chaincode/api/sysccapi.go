@@ -18,25 +18,72 @@ package api
 
 import (
 	"fmt"
+	"sync"
 
 	"github.com/hyperledger/fabric/core/chaincode/shim"
 	inproc "github.com/hyperledger/fabric/core/container/inproccontroller"
+	pb "github.com/hyperledger/fabric/protos"
 	"github.com/op/go-logging"
+	"github.com/spf13/viper"
 )
 
 var sysccLogger = logging.MustGetLogger("sysccapi")
 
+// SystemChaincode is a chaincode compiled into the peer. It runs in-process, without
+// Docker, through the same shim interface as the user chaincodes, and its invocations
+// and queries are transactions and queries like any other. An enabled system chaincode
+// is launched on its first invocation or query, without a deploy transaction, so its
+// Init function is not called.
+type SystemChaincode struct {
+	// Name under which the chaincode is invoked and queried, and enabled
+	// through chaincode.system.<name>
+	Name string
+
+	// Path under which the chaincode is registered with the in-process controller
+	Path string
+
+	Chaincode shim.Chaincode
+}
+
+var sysccs = struct {
+	sync.RWMutex
+	byName map[string]*SystemChaincode
+}{byName: make(map[string]*SystemChaincode)}
+
 // RegisterSysCC registers the given system chaincode with the peer
-func RegisterSysCC(path string, o interface{}) error {
-	syscc := o.(shim.Chaincode)
-	if syscc == nil {
-		sysccLogger.Warning(fmt.Sprintf("invalid chaincode %v", o))
-		return fmt.Errorf(fmt.Sprintf("invalid chaincode %v", o))
+func RegisterSysCC(syscc *SystemChaincode) error {
+	if syscc == nil || syscc.Chaincode == nil || syscc.Name == "" || syscc.Path == "" {
+		sysccLogger.Warning(fmt.Sprintf("invalid system chaincode %v", syscc))
+		return fmt.Errorf("invalid system chaincode %v", syscc)
+	}
+	sysccs.Lock()
+	defer sysccs.Unlock()
+	if _, ok := sysccs.byName[syscc.Name]; ok {
+		return fmt.Errorf("system chaincode %s is registered", syscc.Name)
 	}
-	err := inproc.Register(path, syscc)
+	err := inproc.Register(syscc.Path, syscc.Chaincode)
 	if err != nil {
-		return fmt.Errorf(fmt.Sprintf("could not register (%s,%v): %s", path, syscc, err))
+		return fmt.Errorf("could not register (%s,%v): %s", syscc.Path, syscc.Chaincode, err)
 	}
-	sysccLogger.Debug("system chaincode %s registered", path)
-	return err
+	sysccs.byName[syscc.Name] = syscc
+	sysccLogger.Debug("system chaincode %s registered", syscc.Name)
+	return nil
+}
+
+// GetSysCC returns the system chaincode registered under the name if it is enabled in
+// the configuration, nil otherwise
+func GetSysCC(name string) *SystemChaincode {
+	sysccs.RLock()
+	syscc := sysccs.byName[name]
+	sysccs.RUnlock()
+	if syscc == nil || !viper.GetBool("chaincode.system."+name) {
+		return nil
+	}
+	return syscc
+}
+
+// DeploymentSpec returns the spec with which the system chaincode is launched
+func (syscc *SystemChaincode) DeploymentSpec() *pb.ChaincodeDeploymentSpec {
+	spec := &pb.ChaincodeSpec{Type: pb.ChaincodeSpec_GOLANG, ChaincodeID: &pb.ChaincodeID{Name: syscc.Name, Path: syscc.Path}}
+	return &pb.ChaincodeDeploymentSpec{ExecEnv: pb.ChaincodeDeploymentSpec_SYSTEM, ChaincodeSpec: spec}
 }
@@ -19,11 +19,41 @@ package system_chaincode
 import (
 	//import system chain codes here
 	"github.com/hyperledger/fabric/core/system_chaincode/api"
+	"github.com/hyperledger/fabric/core/system_chaincode/ledgerconfig"
+	"github.com/hyperledger/fabric/core/system_chaincode/ledgerquery"
+	"github.com/hyperledger/fabric/core/system_chaincode/lifecycle"
 	"github.com/hyperledger/fabric/core/system_chaincode/sample_syscc"
 )
 
-//RegisterSysCCs is the hook for system chaincodes where system chaincodes are registered with the fabric
-//note the chaincode must still be deployed and launched like a user chaincode will be
+//systemChaincodes are the chaincodes compiled into the peer, launched in-process on their
+//first invocation or query if enabled through chaincode.system
+var systemChaincodes = []*api.SystemChaincode{
+	{
+		Name:      "sample_syscc",
+		Path:      "github.com/hyperledger/fabric/core/system_chaincode/sample_syscc",
+		Chaincode: &sample_syscc.SampleSysCC{},
+	},
+	{
+		Name:      "lifecycle",
+		Path:      "github.com/hyperledger/fabric/core/system_chaincode/lifecycle",
+		Chaincode: &lifecycle.LifecycleSysCC{},
+	},
+	{
+		Name:      "ledgerconfig",
+		Path:      "github.com/hyperledger/fabric/core/system_chaincode/ledgerconfig",
+		Chaincode: &ledgerconfig.LedgerConfigSysCC{},
+	},
+	{
+		Name:      "ledgerquery",
+		Path:      "github.com/hyperledger/fabric/core/system_chaincode/ledgerquery",
+		Chaincode: &ledgerquery.LedgerQuerySysCC{},
+	},
+}
+
+//RegisterSysCCs is the hook for system chaincodes where system chaincodes are registered with the fabric.
+//A system chaincode can also be deployed like a user chaincode, e.g. in the genesis block
 func RegisterSysCCs() {
-	api.RegisterSysCC("github.com/hyperledger/fabric/core/system_chaincode/sample_syscc", &sample_syscc.SampleSysCC{})
+	for _, syscc := range systemChaincodes {
+		api.RegisterSysCC(syscc)
+	}
 }
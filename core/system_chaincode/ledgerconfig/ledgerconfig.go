@@ -0,0 +1,70 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ledgerconfig
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/core/ledger"
+)
+
+// LedgerConfigSysCC is the system chaincode through which the configuration that all the
+// peers of the network agree on through the ledger is queried
+type LedgerConfigSysCC struct {
+}
+
+// Init does nothing, the configuration is recorded by the genesis block
+func (t *LedgerConfigSysCC) Init(stub *shim.ChaincodeStub, function string, args []string) ([]byte, error) {
+	return nil, nil
+}
+
+// Invoke is not supported, the ledger configuration system chaincode cannot change the state
+func (t *LedgerConfigSysCC) Invoke(stub *shim.ChaincodeStub, function string, args []string) ([]byte, error) {
+	return nil, errors.New("The ledger configuration system chaincode only supports queries")
+}
+
+// Query returns, for the function "getfeatures", the JSON map of the ledger features that
+// are scheduled to their activation block and, for the function "isfeatureactive", whether
+// the feature given as argument is active at the next block
+func (t *LedgerConfigSysCC) Query(stub *shim.ChaincodeStub, function string, args []string) ([]byte, error) {
+	ledgerObj, err := ledger.GetLedger()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get handle to ledger: %s", err)
+	}
+
+	switch function {
+	case "getfeatures":
+		activations, err := ledgerObj.GetFeatureActivations()
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get ledger features: %s", err)
+		}
+		return json.Marshal(activations)
+	case "isfeatureactive":
+		if len(args) != 1 {
+			return nil, errors.New("Incorrect number of arguments. Expecting the name of the feature")
+		}
+		active, err := ledgerObj.IsFeatureActive(ledger.LedgerFeature(args[0]), ledgerObj.GetBlockchainSize())
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get ledger feature %s: %s", args[0], err)
+		}
+		return json.Marshal(active)
+	}
+	return nil, errors.New("Invalid query function name. Expecting \"getfeatures\" or \"isfeatureactive\"")
+}
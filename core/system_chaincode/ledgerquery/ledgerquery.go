@@ -0,0 +1,85 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ledgerquery
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/core/ledger"
+)
+
+// LedgerQuerySysCC is the system chaincode through which the blockchain is queried, so
+// that chaincodes and applications reach the blocks and transactions through the same
+// interface as the state
+type LedgerQuerySysCC struct {
+}
+
+// Init does nothing
+func (t *LedgerQuerySysCC) Init(stub *shim.ChaincodeStub, function string, args []string) ([]byte, error) {
+	return nil, nil
+}
+
+// Invoke is not supported, the ledger query system chaincode cannot change the state
+func (t *LedgerQuerySysCC) Invoke(stub *shim.ChaincodeStub, function string, args []string) ([]byte, error) {
+	return nil, errors.New("The ledger query system chaincode only supports queries")
+}
+
+// Query returns the JSON of the blockchain info for the function "getchaininfo", of the block
+// whose number is the argument for "getblockbynumber" and of the transaction whose uuid is
+// the argument for "gettransactionbyuuid"
+func (t *LedgerQuerySysCC) Query(stub *shim.ChaincodeStub, function string, args []string) ([]byte, error) {
+	ledgerObj, err := ledger.GetLedger()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get handle to ledger: %s", err)
+	}
+
+	switch function {
+	case "getchaininfo":
+		info, err := ledgerObj.GetBlockchainInfo()
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get blockchain info: %s", err)
+		}
+		return json.Marshal(info)
+	case "getblockbynumber":
+		if len(args) != 1 {
+			return nil, errors.New("Incorrect number of arguments. Expecting the number of the block")
+		}
+		blockNumber, err := strconv.ParseUint(args[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid block number %s: %s", args[0], err)
+		}
+		block, err := ledgerObj.GetBlockByNumber(blockNumber)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get block %d: %s", blockNumber, err)
+		}
+		return json.Marshal(block)
+	case "gettransactionbyuuid":
+		if len(args) != 1 {
+			return nil, errors.New("Incorrect number of arguments. Expecting the uuid of the transaction")
+		}
+		tx, err := ledgerObj.GetTransactionByUUID(args[0])
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get transaction %s: %s", args[0], err)
+		}
+		return json.Marshal(tx)
+	}
+	return nil, errors.New("Invalid query function name. Expecting \"getchaininfo\", \"getblockbynumber\" or \"gettransactionbyuuid\"")
+}
@@ -0,0 +1,66 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lifecycle
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/core/ledger"
+)
+
+// LifecycleSysCC is the system chaincode through which the instantiations of the chaincodes
+// recorded by the ledger are queried. Running in the peer, it reads them from the reserved
+// namespace of the ledger directly.
+type LifecycleSysCC struct {
+}
+
+// Init does nothing, the instantiations are recorded by the deploy and upgrade transactions
+func (t *LifecycleSysCC) Init(stub *shim.ChaincodeStub, function string, args []string) ([]byte, error) {
+	return nil, nil
+}
+
+// Invoke is not supported, the lifecycle system chaincode cannot change the state
+func (t *LifecycleSysCC) Invoke(stub *shim.ChaincodeStub, function string, args []string) ([]byte, error) {
+	return nil, errors.New("The lifecycle system chaincode only supports queries")
+}
+
+// Query returns, for the function "getinstantiation", the JSON of the committed instantiation
+// record of the chaincode whose name is the argument
+func (t *LifecycleSysCC) Query(stub *shim.ChaincodeStub, function string, args []string) ([]byte, error) {
+	if function != "getinstantiation" {
+		return nil, errors.New("Invalid query function name. Expecting \"getinstantiation\"")
+	}
+	if len(args) != 1 {
+		return nil, errors.New("Incorrect number of arguments. Expecting the name of the chaincode")
+	}
+
+	ledgerObj, err := ledger.GetLedger()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get handle to ledger: %s", err)
+	}
+	instantiation, err := ledgerObj.GetChaincodeInstantiation(args[0], true)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get instantiation of %s: %s", args[0], err)
+	}
+	if instantiation == nil {
+		return nil, fmt.Errorf("Chaincode %s is not instantiated", args[0])
+	}
+	return json.Marshal(instantiation)
+}
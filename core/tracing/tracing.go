@@ -0,0 +1,200 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package tracing records the spans of the stages a transaction goes through on a peer
+// (submission, ordering, execution, state hash, commit) and reports them to Zipkin, or to
+// Jaeger through its Zipkin collector. The trace of a transaction is identified by a hash
+// of its uuid, so the spans recorded by all the peers for a transaction join the same
+// trace without any context being carried along with the transaction, and all the peers
+// sample the same transactions.
+package tracing
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/op/go-logging"
+	"github.com/spf13/viper"
+)
+
+var logger = logging.MustGetLogger("tracing")
+
+// Span is a stage of the processing of a transaction. The methods of a nil Span, which is
+// returned for the transactions that are not traced, do nothing
+type Span struct {
+	traceID uint64
+	id      uint64
+	name    string
+	start   time.Time
+	end     time.Time
+	tags    map[string]string
+}
+
+// Reporter receives the finished spans
+type Reporter interface {
+	Report(span *Span)
+}
+
+var (
+	lock       sync.RWMutex
+	reporter   Reporter
+	sampleRate float64
+)
+
+// SetReporter enables tracing, with the spans of the given fraction of the transactions
+// reported to reporter. A nil reporter disables tracing
+func SetReporter(r Reporter, rate float64) {
+	lock.Lock()
+	defer lock.Unlock()
+	reporter, sampleRate = r, rate
+}
+
+// InitFromConfig enables tracing if 'peer.tracing.enabled' is set, with the spans reported
+// to the Zipkin collector at 'peer.tracing.zipkin.url' under the name of the peer
+func InitFromConfig() {
+	if !viper.GetBool("peer.tracing.enabled") {
+		return
+	}
+	url := viper.GetString("peer.tracing.zipkin.url")
+	batchSize := viper.GetInt("peer.tracing.zipkin.batchSize")
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	flushInterval := viper.GetDuration("peer.tracing.zipkin.flushInterval")
+	if flushInterval <= 0 {
+		flushInterval = time.Second
+	}
+	rate := 1.0
+	if viper.IsSet("peer.tracing.sampleRate") {
+		rate = viper.GetFloat64("peer.tracing.sampleRate")
+	}
+	logger.Info("Reporting the spans of %v of the transactions to [%s]", rate, url)
+	SetReporter(NewZipkinReporter(url, viper.GetString("peer.id"), batchSize, flushInterval), rate)
+}
+
+// traceID returns the id of the trace of the transaction, the same on all the peers
+func traceID(txUUID string) uint64 {
+	hash := sha256.Sum256([]byte(txUUID))
+	return binary.BigEndian.Uint64(hash[:8])
+}
+
+// sampled returns whether the trace is reported, the same on all the peers
+func sampled(traceID uint64, rate float64) bool {
+	return float64(traceID>>11)/float64(uint64(1)<<53) < rate
+}
+
+func newSpanID() uint64 {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return uint64(time.Now().UnixNano())
+	}
+	return binary.BigEndian.Uint64(b[:])
+}
+
+func getReporter(traceID uint64) Reporter {
+	lock.RLock()
+	defer lock.RUnlock()
+	if reporter == nil || !sampled(traceID, sampleRate) {
+		return nil
+	}
+	return reporter
+}
+
+// StartTxSpan starts a span of the trace of the transaction, or returns nil if tracing is
+// disabled or the transaction is not sampled
+func StartTxSpan(txUUID, name string) *Span {
+	return StartTxSpanAt(txUUID, name, time.Now())
+}
+
+// StartTxSpanAt starts, at the given time, a span of the trace of the transaction, or
+// returns nil if tracing is disabled or the transaction is not sampled
+func StartTxSpanAt(txUUID, name string, start time.Time) *Span {
+	id := traceID(txUUID)
+	if getReporter(id) == nil {
+		return nil
+	}
+	return &Span{traceID: id, id: newSpanID(), name: name, start: start, tags: map[string]string{"tx.uuid": txUUID}}
+}
+
+// RecordTxSpan reports a span of the trace of the transaction that has already finished
+func RecordTxSpan(txUUID, name string, start, end time.Time, tags map[string]string) {
+	span := StartTxSpanAt(txUUID, name, start)
+	if span == nil {
+		return
+	}
+	for key, value := range tags {
+		span.tags[key] = value
+	}
+	span.FinishAt(end)
+}
+
+// SetTag annotates the span
+func (span *Span) SetTag(key, value string) {
+	if span == nil {
+		return
+	}
+	span.tags[key] = value
+}
+
+// SetError annotates the span with the error, if any
+func (span *Span) SetError(err error) {
+	if span == nil || err == nil {
+		return
+	}
+	span.tags["error"] = err.Error()
+}
+
+// Finish ends the span now and reports it
+func (span *Span) Finish() {
+	span.FinishAt(time.Now())
+}
+
+// FinishAt ends the span at the given time and reports it
+func (span *Span) FinishAt(end time.Time) {
+	if span == nil {
+		return
+	}
+	r := getReporter(span.traceID)
+	if r == nil {
+		return
+	}
+	span.end = end
+	r.Report(span)
+}
+
+// Name returns the name of the span
+func (span *Span) Name() string {
+	return span.name
+}
+
+// Duration returns the duration of the finished span
+func (span *Span) Duration() time.Duration {
+	return span.end.Sub(span.start)
+}
+
+// Tags returns the annotations of the span
+func (span *Span) Tags() map[string]string {
+	return span.tags
+}
+
+// TraceID returns the hex encoded id of the trace of the span
+func (span *Span) TraceID() string {
+	return fmt.Sprintf("%016x", span.traceID)
+}
@@ -0,0 +1,120 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tracing
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type testReporter struct {
+	spans []*Span
+}
+
+func (r *testReporter) Report(span *Span) {
+	r.spans = append(r.spans, span)
+}
+
+func TestSpansOfATransactionShareTheTrace(t *testing.T) {
+	reporter := &testReporter{}
+	SetReporter(reporter, 1)
+	defer SetReporter(nil, 0)
+
+	span := StartTxSpan("tx1", "execute")
+	span.SetTag("chaincode", "mycc")
+	span.SetError(fmt.Errorf("failed"))
+	span.Finish()
+	start := time.Now()
+	RecordTxSpan("tx1", "commit", start, start.Add(time.Second), map[string]string{"block.number": "3"})
+
+	if len(reporter.spans) != 2 {
+		t.Fatalf("Expected 2 spans, got %d", len(reporter.spans))
+	}
+	execute, commit := reporter.spans[0], reporter.spans[1]
+	if execute.TraceID() != commit.TraceID() || execute.id == commit.id {
+		t.Fatal("Expected the spans of a transaction to be distinct spans of the same trace")
+	}
+	if execute.Tags()["tx.uuid"] != "tx1" || execute.Tags()["chaincode"] != "mycc" || execute.Tags()["error"] != "failed" {
+		t.Fatalf("Unexpected tags %v", execute.Tags())
+	}
+	if commit.Duration() != time.Second || commit.Tags()["block.number"] != "3" {
+		t.Fatalf("Unexpected commit span %v", commit)
+	}
+	if StartTxSpan("tx2", "execute").TraceID() == execute.TraceID() {
+		t.Fatal("Expected distinct transactions to have distinct traces")
+	}
+}
+
+func TestSampling(t *testing.T) {
+	reporter := &testReporter{}
+	SetReporter(reporter, 0.5)
+	defer SetReporter(nil, 0)
+	for i := 0; i < 1000; i++ {
+		RecordTxSpan(fmt.Sprintf("tx%d", i), "commit", time.Now(), time.Now(), nil)
+	}
+	if len(reporter.spans) < 400 || len(reporter.spans) > 600 {
+		t.Fatalf("Expected about half the transactions to be sampled, got %d", len(reporter.spans))
+	}
+	for _, span := range reporter.spans {
+		if !sampled(traceID(span.Tags()["tx.uuid"]), 0.5) {
+			t.Fatal("Expected the sampling of a transaction to depend on its uuid only")
+		}
+	}
+
+	SetReporter(nil, 0)
+	span := StartTxSpan("tx1", "execute")
+	if span != nil {
+		t.Fatal("Expected no span with tracing disabled")
+	}
+	// the methods of a nil span do nothing
+	span.SetTag("key", "value")
+	span.Finish()
+}
+
+func TestZipkinReporter(t *testing.T) {
+	received := make(chan []zipkinSpan, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		var spans []zipkinSpan
+		if err := json.NewDecoder(req.Body).Decode(&spans); err != nil {
+			t.Errorf("Error decoding the spans: %s", err)
+		}
+		rw.WriteHeader(http.StatusAccepted)
+		received <- spans
+	}))
+	defer server.Close()
+	SetReporter(NewZipkinReporter(server.URL, "vp0", 2, time.Hour), 1)
+	defer SetReporter(nil, 0)
+
+	start := time.Unix(100, 0)
+	RecordTxSpan("tx1", "state_hash", start, start.Add(time.Millisecond), nil)
+	RecordTxSpan("tx1", "commit", start, start.Add(2*time.Millisecond), nil)
+	select {
+	case spans := <-received:
+		if len(spans) != 2 || spans[0].Name != "state_hash" || spans[1].Duration != 2000 || spans[0].Timestamp != 100000000 {
+			t.Fatalf("Unexpected spans %v", spans)
+		}
+		if spans[0].TraceID != spans[1].TraceID || spans[0].LocalEndpoint.ServiceName != "vp0" {
+			t.Fatalf("Unexpected spans %v", spans)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the spans to be posted once the batch is full")
+	}
+}
@@ -0,0 +1,121 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// zipkinSpan is a span in the JSON format of the v2 API of Zipkin
+type zipkinSpan struct {
+	TraceID       string            `json:"traceId"`
+	ID            string            `json:"id"`
+	Name          string            `json:"name"`
+	Timestamp     int64             `json:"timestamp"`
+	Duration      int64             `json:"duration"`
+	LocalEndpoint zipkinEndpoint    `json:"localEndpoint"`
+	Tags          map[string]string `json:"tags,omitempty"`
+}
+
+type zipkinEndpoint struct {
+	ServiceName string `json:"serviceName"`
+}
+
+// ZipkinReporter posts the spans, by batches, to the v2 API of a Zipkin collector, such as
+// http://localhost:9411/api/v2/spans. The spans reported while the buffer is full are
+// dropped rather than slowing down the transactions
+type ZipkinReporter struct {
+	url           string
+	serviceName   string
+	batchSize     int
+	flushInterval time.Duration
+	spans         chan *Span
+	client        *http.Client
+}
+
+// NewZipkinReporter returns a reporter posting the spans to url, under the name of the
+// service, once batchSize spans are collected or flushInterval has elapsed
+func NewZipkinReporter(url, serviceName string, batchSize int, flushInterval time.Duration) *ZipkinReporter {
+	reporter := &ZipkinReporter{url: url, serviceName: serviceName, batchSize: batchSize, flushInterval: flushInterval,
+		spans: make(chan *Span, 10*batchSize), client: &http.Client{Timeout: 10 * time.Second}}
+	go reporter.run()
+	return reporter
+}
+
+// Report queues the span to be posted
+func (reporter *ZipkinReporter) Report(span *Span) {
+	select {
+	case reporter.spans <- span:
+	default:
+		logger.Debug("Dropping span [%s] of trace [%s], the buffer is full", span.name, span.TraceID())
+	}
+}
+
+func (reporter *ZipkinReporter) run() {
+	ticker := time.NewTicker(reporter.flushInterval)
+	defer ticker.Stop()
+	batch := make([]*Span, 0, reporter.batchSize)
+	for {
+		select {
+		case span := <-reporter.spans:
+			batch = append(batch, span)
+			if len(batch) < reporter.batchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		}
+		if err := reporter.post(batch); err != nil {
+			logger.Warning("Error reporting %d spans to [%s]: %s", len(batch), reporter.url, err)
+		}
+		batch = batch[:0]
+	}
+}
+
+func (reporter *ZipkinReporter) post(batch []*Span) error {
+	zipkinSpans := make([]zipkinSpan, len(batch))
+	for i, span := range batch {
+		zipkinSpans[i] = zipkinSpan{
+			TraceID:       span.TraceID(),
+			ID:            fmt.Sprintf("%016x", span.id),
+			Name:          span.name,
+			Timestamp:     span.start.UnixNano() / int64(time.Microsecond),
+			Duration:      int64(span.Duration() / time.Microsecond),
+			LocalEndpoint: zipkinEndpoint{reporter.serviceName},
+			Tags:          span.tags,
+		}
+	}
+	body, err := json.Marshal(zipkinSpans)
+	if err != nil {
+		return err
+	}
+	resp, err := reporter.client.Post(reporter.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("Unexpected status [%s]", resp.Status)
+	}
+	return nil
+}
@@ -27,3 +27,22 @@ type EventAdapter interface {
 	Recv(msg *ehpb.Event) (bool, error)
 	Disconnected(err error)
 }
+
+//ReplayAdapter is implemented by the adapters which want the events of the blocks
+//committed before they connect, from the block given by the Replay, to be sent
+//before the live events. With a consumer ID and resume set, a client reconnecting
+//continues after the last block it was sent
+type ReplayAdapter interface {
+	EventAdapter
+	GetReplay() (*ehpb.Replay, error)
+}
+
+//AckAdapter is implemented by the adapters which acknowledge the events. The
+//event hub sends at most GetMaxUnackedEvents events which have not been
+//acknowledged, an event being acknowledged once Recv returns. A consumer which
+//falls too far behind is disconnected instead of missing events; along with a
+//replay, blocks are checkpointed once acknowledged
+type AckAdapter interface {
+	EventAdapter
+	GetMaxUnackedEvents() uint32
+}
@@ -48,8 +48,8 @@ func newEventsClientConnectionWithAddress(peerAddress string) (*grpc.ClientConn,
 	return comm.NewClientConnectionWithAddress(peerAddress, true, false, nil)
 }
 
-func (ec *EventsClient) register(ies []*ehpb.Interest) error {
-	emsg := &ehpb.Event{Event: &ehpb.Event_Register{Register: &ehpb.Register{Events: ies}}}
+func (ec *EventsClient) register(ies []*ehpb.Interest, replay *ehpb.Replay, maxUnacked uint32) error {
+	emsg := &ehpb.Event{Event: &ehpb.Event_Register{Register: &ehpb.Register{Events: ies, Replay: replay, MaxUnackedEvents: maxUnacked}}}
 	var err error
 	if err = ec.stream.Send(emsg); err != nil {
 		fmt.Printf("error on Register send %s\n", err)
@@ -103,6 +103,12 @@ func (ec *EventsClient) processEvents() error {
 				return err
 			}
 		}
+		if in.Sequence != 0 {
+			ack := &ehpb.Event{Event: &ehpb.Event_Ack{Ack: &ehpb.Ack{Sequence: in.Sequence}}}
+			if err := ec.stream.Send(ack); err != nil {
+				return err
+			}
+		}
 	}
 }
 
@@ -122,13 +128,24 @@ func (ec *EventsClient) Start() error {
 		return fmt.Errorf("must supply interested events")
 	}
 
+	var replay *ehpb.Replay
+	if ra, ok := ec.adapter.(ReplayAdapter); ok {
+		if replay, err = ra.GetReplay(); err != nil {
+			return fmt.Errorf("error getting replay:%s", err)
+		}
+	}
+	var maxUnacked uint32
+	if aa, ok := ec.adapter.(AckAdapter); ok {
+		maxUnacked = aa.GetMaxUnackedEvents()
+	}
+
 	serverClient := ehpb.NewEventsClient(conn)
 	ec.stream, err = serverClient.Chat(context.Background())
 	if err != nil {
 		return fmt.Errorf("Could not create client conn to %s", ec.peerAddress)
 	}
 
-	if err = ec.register(ies); err != nil {
+	if err = ec.register(ies, replay, maxUnacked); err != nil {
 		return err
 	}
 
@@ -44,7 +44,7 @@ var adapter *Adapter
 var obcEHClient *consumer.EventsClient
 
 func (a *Adapter) GetInterestedEvents() ([]*ehpb.Interest, error) {
-	return []*ehpb.Interest{{"block", ehpb.Interest_PROTOBUF}}, nil
+	return []*ehpb.Interest{{EventType: "block", ResponseType: ehpb.Interest_PROTOBUF}, {EventType: "blockCommit", ResponseType: ehpb.Interest_PROTOBUF}}, nil
 	//return [] *ehpb.Interest{ &ehpb.InterestedEvent{"block", ehpb.Interest_JSON }}, nil
 }
 
@@ -52,6 +52,10 @@ func (a *Adapter) Recv(msg *ehpb.Event) (bool, error) {
 	//fmt.Printf("Adapter received %v\n", msg.Event)
 	switch x := msg.Event.(type) {
 	case *ehpb.Event_Block:
+	case *ehpb.Event_BlockCommit:
+		if x.BlockCommit.BlockNumber != 7 || len(x.BlockCommit.TxStateDeltaHashes) != 2 {
+			return false, fmt.Errorf("unexpected block commit %v", x.BlockCommit)
+		}
 	case *ehpb.Event_Generic:
 	case nil:
 		// The field is not set.
@@ -105,6 +109,365 @@ func TestReceiveMessage(t *testing.T) {
 	}
 }
 
+func TestReceiveBlockCommitMessage(t *testing.T) {
+	adapter.count = 1
+	emsg := producer.CreateBlockCommitEvent(&ehpb.BlockCommit{
+		BlockNumber: 7,
+		StateHash:   []byte("stateHash"),
+		TxStateDeltaHashes: []*ehpb.TxStateDeltaHash{
+			{TxUuid: "tx1", StateDeltaHash: []byte("stateDeltaHash")},
+			{TxUuid: "tx2"},
+		},
+	})
+	if err := producer.Send(emsg); err != nil {
+		t.Fatalf("Error sending message %s", err)
+	}
+
+	select {
+	case <-adapter.notfy:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out on message")
+	}
+}
+
+type replaySource struct {
+	sync.Mutex
+	blocks      []*ehpb.Block
+	checkpoints map[string]uint64
+}
+
+func (s *replaySource) addBlock() *ehpb.Block {
+	s.Lock()
+	defer s.Unlock()
+	block := &ehpb.Block{Transactions: []*ehpb.Transaction{}, ConsensusMetadata: []byte{byte(len(s.blocks))}}
+	s.blocks = append(s.blocks, block)
+	return block
+}
+
+func (s *replaySource) GetBlockchainSize() uint64 {
+	s.Lock()
+	defer s.Unlock()
+	return uint64(len(s.blocks))
+}
+
+func (s *replaySource) GetEventBlock(blockNumber uint64) (*ehpb.Block, error) {
+	s.Lock()
+	defer s.Unlock()
+	return s.blocks[blockNumber], nil
+}
+
+func (s *replaySource) GetEventConsumerCheckpoint(consumerID string) (uint64, bool, error) {
+	s.Lock()
+	defer s.Unlock()
+	nextBlock, ok := s.checkpoints[consumerID]
+	return nextBlock, ok, nil
+}
+
+func (s *replaySource) PutEventConsumerCheckpoint(consumerID string, nextBlock uint64) error {
+	s.Lock()
+	defer s.Unlock()
+	s.checkpoints[consumerID] = nextBlock
+	return nil
+}
+
+type replayAdapter struct {
+	blocks chan byte
+}
+
+func (a *replayAdapter) GetInterestedEvents() ([]*ehpb.Interest, error) {
+	return []*ehpb.Interest{{EventType: "block", ResponseType: ehpb.Interest_PROTOBUF}}, nil
+}
+
+func (a *replayAdapter) GetReplay() (*ehpb.Replay, error) {
+	return &ehpb.Replay{FromBlock: 1, ConsumerID: "replayer", Resume: true}, nil
+}
+
+func (a *replayAdapter) Recv(msg *ehpb.Event) (bool, error) {
+	if block := msg.GetBlock(); block != nil {
+		a.blocks <- block.ConsensusMetadata[0]
+	}
+	return true, nil
+}
+
+func (a *replayAdapter) Disconnected(err error) {}
+
+func expectBlocks(t *testing.T, blocks chan byte, expected ...byte) {
+	for _, n := range expected {
+		select {
+		case received := <-blocks:
+			if received != n {
+				t.Fatalf("Expected block %d, received block %d", n, received)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for block %d", n)
+		}
+	}
+}
+
+func TestReplayBlocks(t *testing.T) {
+	source := &replaySource{checkpoints: make(map[string]uint64)}
+	for i := 0; i < 3; i++ {
+		source.addBlock()
+	}
+	producer.SetReplaySource(source)
+	defer producer.SetReplaySource(nil)
+
+	replayer := &replayAdapter{blocks: make(chan byte, 10)}
+	client := consumer.NewEventsClient(peerAddress, replayer)
+	if err := client.Start(); err != nil {
+		t.Fatalf("could not start chat %s", err)
+	}
+	expectBlocks(t, replayer.blocks, 1, 2)
+
+	// the live events of the blocks already replayed are skipped
+	adapter.count = 2
+	block := source.addBlock()
+	if err := producer.SendBlockEvent(2, producer.CreateBlockEvent(source.blocks[2])); err != nil {
+		t.Fatalf("Error sending message %s", err)
+	}
+	if err := producer.SendBlockEvent(3, producer.CreateBlockEvent(block)); err != nil {
+		t.Fatalf("Error sending message %s", err)
+	}
+	expectBlocks(t, replayer.blocks, 3)
+	select {
+	case <-adapter.notfy:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out on message")
+	}
+	client.Stop()
+
+	// a consumer reconnecting resumes after the last block it was sent
+	source.addBlock()
+	source.addBlock()
+	client = consumer.NewEventsClient(peerAddress, replayer)
+	if err := client.Start(); err != nil {
+		t.Fatalf("could not start chat %s", err)
+	}
+	defer client.Stop()
+	expectBlocks(t, replayer.blocks, 4, 5)
+	select {
+	case n := <-replayer.blocks:
+		t.Fatalf("Unexpected block %d", n)
+	case <-time.After(500 * time.Millisecond):
+	}
+	if nextBlock, _, _ := source.GetEventConsumerCheckpoint("replayer"); nextBlock != 6 {
+		t.Fatalf("Expected the consumer to be checkpointed at block 6, got %d", nextBlock)
+	}
+}
+
+type filterAdapter struct {
+	blocks chan *ehpb.Block
+}
+
+func (a *filterAdapter) GetInterestedEvents() ([]*ehpb.Interest, error) {
+	filter := &ehpb.EventFilter{ChaincodeID: "cc1", TxResult: ehpb.EventFilter_SUCCEEDED}
+	return []*ehpb.Interest{{EventType: "block", ResponseType: ehpb.Interest_PROTOBUF, Filter: filter}}, nil
+}
+
+func (a *filterAdapter) Recv(msg *ehpb.Event) (bool, error) {
+	if block := msg.GetBlock(); block != nil {
+		a.blocks <- block
+	}
+	return true, nil
+}
+
+func (a *filterAdapter) Disconnected(err error) {}
+
+func createTestTx(t *testing.T, chaincode string) *ehpb.Transaction {
+	tx, err := ehpb.NewTransaction(ehpb.ChaincodeID{Name: chaincode}, fmt.Sprintf("%s-%d", chaincode, time.Now().UnixNano()), "invoke", nil)
+	if err != nil {
+		t.Fatalf("Error creating transaction: %s", err)
+	}
+	return tx
+}
+
+func waitForMainAdapter(t *testing.T) {
+	select {
+	case <-adapter.notfy:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out on message")
+	}
+}
+
+func TestFilterEvents(t *testing.T) {
+	filterer := &filterAdapter{blocks: make(chan *ehpb.Block, 10)}
+	client := consumer.NewEventsClient(peerAddress, filterer)
+	if err := client.Start(); err != nil {
+		t.Fatalf("could not start chat %s", err)
+	}
+	defer client.Stop()
+
+	adapter.count = 2
+	txs := []*ehpb.Transaction{createTestTx(t, "cc1"), createTestTx(t, "cc1"), createTestTx(t, "cc2")}
+	results := []*ehpb.TransactionResult{{Uuid: txs[0].Uuid}, {Uuid: txs[1].Uuid, ErrorCode: 1}, {Uuid: txs[2].Uuid}}
+	block := &ehpb.Block{Transactions: txs, NonHashData: &ehpb.NonHashData{TransactionResults: results}}
+	if err := producer.Send(producer.CreateBlockEvent(block)); err != nil {
+		t.Fatalf("Error sending message %s", err)
+	}
+	// no transaction of this block passes the filter
+	tx := createTestTx(t, "cc2")
+	block = &ehpb.Block{Transactions: []*ehpb.Transaction{tx}, NonHashData: &ehpb.NonHashData{TransactionResults: []*ehpb.TransactionResult{{Uuid: tx.Uuid}}}}
+	if err := producer.Send(producer.CreateBlockEvent(block)); err != nil {
+		t.Fatalf("Error sending message %s", err)
+	}
+	waitForMainAdapter(t)
+
+	select {
+	case received := <-filterer.blocks:
+		if len(received.Transactions) != 1 || received.Transactions[0].Uuid != txs[0].Uuid {
+			t.Fatalf("Expected the block to be restricted to transaction %s, got %v", txs[0].Uuid, received.Transactions)
+		}
+		if len(received.NonHashData.TransactionResults) != 1 || received.NonHashData.TransactionResults[0].Uuid != txs[0].Uuid {
+			t.Fatalf("Expected the results to be restricted to transaction %s, got %v", txs[0].Uuid, received.NonHashData.TransactionResults)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for the filtered block")
+	}
+	select {
+	case received := <-filterer.blocks:
+		t.Fatalf("Unexpected block %v", received)
+	case <-time.After(500 * time.Millisecond):
+	}
+}
+
+type ackAdapter struct {
+	replay    *ehpb.Replay
+	sequences chan uint64
+	release   chan struct{}
+	errors    chan error
+}
+
+func (a *ackAdapter) GetMaxUnackedEvents() uint32 {
+	return 1
+}
+
+func (a *ackAdapter) GetInterestedEvents() ([]*ehpb.Interest, error) {
+	return []*ehpb.Interest{{EventType: "block", ResponseType: ehpb.Interest_PROTOBUF}}, nil
+}
+
+func (a *ackAdapter) GetReplay() (*ehpb.Replay, error) {
+	return a.replay, nil
+}
+
+func (a *ackAdapter) Recv(msg *ehpb.Event) (bool, error) {
+	<-a.release
+	a.sequences <- msg.Sequence
+	return true, nil
+}
+
+func (a *ackAdapter) Disconnected(err error) {
+	a.errors <- err
+}
+
+func TestAckEvents(t *testing.T) {
+	source := &replaySource{checkpoints: make(map[string]uint64)}
+	for i := 0; i < 3; i++ {
+		source.addBlock()
+	}
+	producer.SetReplaySource(source)
+	defer producer.SetReplaySource(nil)
+
+	acker := &ackAdapter{replay: &ehpb.Replay{ConsumerID: "acker"}, sequences: make(chan uint64, 10), release: make(chan struct{}), errors: make(chan error, 1)}
+	close(acker.release)
+	client := consumer.NewEventsClient(peerAddress, acker)
+	if err := client.Start(); err != nil {
+		t.Fatalf("could not start chat %s", err)
+	}
+	defer client.Stop()
+	for i := uint64(1); i <= 3; i++ {
+		select {
+		case sequence := <-acker.sequences:
+			if sequence != i {
+				t.Fatalf("Expected event %d, received event %d", i, sequence)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for event %d", i)
+		}
+	}
+	// the blocks are checkpointed once acknowledged
+	for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+		if nextBlock, _, _ := source.GetEventConsumerCheckpoint("acker"); nextBlock == 3 {
+			break
+		}
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("timed out waiting for the checkpoint")
+		}
+	}
+}
+
+func TestDisconnectSlowConsumer(t *testing.T) {
+	producer.SetConsumerBufferSize(2)
+	defer producer.SetConsumerBufferSize(0)
+
+	slow := &ackAdapter{sequences: make(chan uint64, 10), release: make(chan struct{}), errors: make(chan error, 1)}
+	client := consumer.NewEventsClient(peerAddress, slow)
+	if err := client.Start(); err != nil {
+		t.Fatalf("could not start chat %s", err)
+	}
+	defer client.Stop()
+
+	// one event in flight, two queued, the fourth one overflows
+	adapter.count = 4
+	for i := 0; i < 4; i++ {
+		if err := producer.Send(createTestBlock()); err != nil {
+			t.Fatalf("Error sending message %s", err)
+		}
+	}
+	waitForMainAdapter(t)
+	close(slow.release)
+	select {
+	case err := <-slow.errors:
+		if err == nil {
+			t.Fatalf("Expected the slow consumer to be disconnected with an error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for the slow consumer to be disconnected")
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	producer.SetConsumerBufferSize(2)
+	defer producer.SetConsumerBufferSize(0)
+
+	filter := &ehpb.EventFilter{ChaincodeID: "cc1"}
+	sub, err := producer.Subscribe([]*ehpb.Interest{{EventType: "block", ResponseType: ehpb.Interest_PROTOBUF, Filter: filter}})
+	if err != nil {
+		t.Fatalf("could not subscribe %s", err)
+	}
+	defer sub.Close()
+
+	adapter.count = 4
+	tx := createTestTx(t, "cc1")
+	block := &ehpb.Block{Transactions: []*ehpb.Transaction{tx}, NonHashData: &ehpb.NonHashData{TransactionResults: []*ehpb.TransactionResult{{Uuid: tx.Uuid}}}}
+	if err := producer.Send(producer.CreateBlockEvent(block)); err != nil {
+		t.Fatalf("Error sending message %s", err)
+	}
+	select {
+	case e := <-sub.Events():
+		if received := e.Event.GetBlock(); received == nil || received.Transactions[0].Uuid != tx.Uuid {
+			t.Fatalf("Expected the block of transaction %s, got %v", tx.Uuid, e.Event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for the block")
+	}
+
+	// two events queued, the third one overflows
+	for i := 0; i < 3; i++ {
+		if err := producer.Send(producer.CreateBlockEvent(block)); err != nil {
+			t.Fatalf("Error sending message %s", err)
+		}
+	}
+	waitForMainAdapter(t)
+	select {
+	case err := <-sub.Done():
+		if err == nil {
+			t.Fatalf("Expected the subscription to be disconnected with an error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for the subscription to be disconnected")
+	}
+}
+
 func BenchmarkMessages(b *testing.B) {
 	numMessages := 10000
 
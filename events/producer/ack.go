@@ -0,0 +1,160 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package producer
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+//defaultConsumerBufferSize is the default number of events queued for a
+//consumer which acknowledges the events
+const defaultConsumerBufferSize = 1000
+
+var consumerBufferSize = defaultConsumerBufferSize
+
+//SetConsumerBufferSize sets the number of events queued for a consumer which
+//acknowledges the events, beyond which the consumer is disconnected
+func SetConsumerBufferSize(size int) {
+	if size <= 0 {
+		size = defaultConsumerBufferSize
+	}
+	consumerBufferSize = size
+}
+
+var errSlowConsumer = errors.New("too many events queued for the consumer")
+
+//delivery is an event queued for a consumer which acknowledges the events
+type delivery struct {
+	event       *pb.Event
+	sequence    uint64
+	blockNumber uint64
+	numbered    bool
+}
+
+//ackQueue delivers the events to a consumer which acknowledges them. At most
+//maxUnacked events are in flight and at most consumerBufferSize more are
+//queued: a consumer which falls further behind is disconnected rather than
+//missing events. When the consumer replays the blocks with a consumer ID, the
+//blocks are checkpointed once acknowledged, so that a reconnecting consumer
+//resumes from the first block it has not acknowledged
+type ackQueue struct {
+	sync.Mutex
+	cond       *sync.Cond
+	maxUnacked int
+	maxQueued  int
+	sequence   uint64
+	queued     []*delivery
+	unacked    []*delivery
+	closed     bool
+}
+
+func newAckQueue(maxUnacked int) *ackQueue {
+	q := &ackQueue{maxUnacked: maxUnacked, maxQueued: consumerBufferSize}
+	q.cond = sync.NewCond(q)
+	return q
+}
+
+//enqueue queues the event, it fails if the consumer is too far behind
+func (q *ackQueue) enqueue(e *pb.Event, blockNumber uint64, numbered bool) error {
+	q.Lock()
+	defer q.Unlock()
+	if q.closed {
+		return fmt.Errorf("the consumer is disconnected")
+	}
+	if len(q.queued) >= q.maxQueued {
+		return errSlowConsumer
+	}
+	q.queued = append(q.queued, &delivery{event: e, blockNumber: blockNumber, numbered: numbered})
+	q.cond.Signal()
+	return nil
+}
+
+//next waits for an event which can be sent and numbers it. It returns nil
+//once the queue is closed
+func (q *ackQueue) next() *delivery {
+	q.Lock()
+	defer q.Unlock()
+	for !q.closed && (len(q.queued) == 0 || len(q.unacked) >= q.maxUnacked) {
+		q.cond.Wait()
+	}
+	if q.closed {
+		return nil
+	}
+	d := q.queued[0]
+	q.queued = q.queued[1:]
+	q.sequence++
+	d.sequence = q.sequence
+	q.unacked = append(q.unacked, d)
+	return d
+}
+
+//ack acknowledges the events up to the one with the given sequence number. It
+//returns the number of the highest block acknowledged, if any
+func (q *ackQueue) ack(sequence uint64) (uint64, bool) {
+	q.Lock()
+	defer q.Unlock()
+	var blockNumber uint64
+	acked := false
+	i := 0
+	for ; i < len(q.unacked) && q.unacked[i].sequence <= sequence; i++ {
+		if q.unacked[i].numbered {
+			blockNumber = q.unacked[i].blockNumber
+			acked = true
+		}
+	}
+	q.unacked = q.unacked[i:]
+	q.cond.Signal()
+	return blockNumber, acked
+}
+
+func (q *ackQueue) close() {
+	q.Lock()
+	q.closed = true
+	q.queued = nil
+	q.cond.Broadcast()
+	q.Unlock()
+}
+
+//runAcks sends the queued events to the consumer as the window of unacknowledged
+//events allows
+func (d *handler) runAcks() {
+	for {
+		delivery := d.acks.next()
+		if delivery == nil {
+			return
+		}
+		if err := d.ChatStream.Send(&pb.Event{Event: delivery.event.Event, Sequence: delivery.sequence}); err != nil {
+			d.abort(fmt.Errorf("Error Sending message through ChatStream: %s", err))
+			return
+		}
+	}
+}
+
+//handleAck processes an acknowledgement from the consumer
+func (d *handler) handleAck(ack *pb.Ack) error {
+	if d.acks == nil {
+		return fmt.Errorf("acknowledgement from a consumer which did not register for acknowledgements")
+	}
+	if blockNumber, ok := d.acks.ack(ack.Sequence); ok && d.replay != nil {
+		d.replay.checkpoint(blockNumber + 1)
+	}
+	return nil
+}
@@ -22,5 +22,15 @@ import (
 
 //CreateBlockEvent creates a Event from a Block
 func CreateBlockEvent(te *ehpb.Block) *ehpb.Event {
-	return &ehpb.Event{&ehpb.Event_Block{Block: te}}
+	return &ehpb.Event{Event: &ehpb.Event_Block{Block: te}}
+}
+
+//CreateBlockCommitEvent creates a Event from a BlockCommit
+func CreateBlockCommitEvent(bc *ehpb.BlockCommit) *ehpb.Event {
+	return &ehpb.Event{Event: &ehpb.Event_BlockCommit{BlockCommit: bc}}
+}
+
+//CreateGenericEvent creates a generic Event of the given type
+func CreateGenericEvent(eventType string, payload []byte) *ehpb.Event {
+	return &ehpb.Event{Event: &ehpb.Event_Generic{Generic: &ehpb.Generic{EventType: eventType, Payload: payload}}}
 }
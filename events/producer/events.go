@@ -20,6 +20,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	pb "github.com/hyperledger/fabric/protos"
@@ -45,11 +46,16 @@ type handlerList struct {
 //per handlerMap if necessary.
 //
 type eventProcessor struct {
+	//deliveringSince is the time, in unix nanoseconds, the event being
+	//delivered was dequeued, 0 while waiting for events. It is accessed
+	//atomically, and first in the struct to be 64-bit aligned
+	deliveringSince int64
+
 	sync.RWMutex
 	eventConsumers map[string]*handlerList
 
 	//we could generalize this with mutiple channels each with its own size
-	eventChannel chan *pb.Event
+	eventChannel chan *producerEvent
 
 	//milliseconds timeout for producer to send an event.
 	//if < 0, if buffer full, unblocks immediately and not send
@@ -58,6 +64,14 @@ type eventProcessor struct {
 	timeout int
 }
 
+//producerEvent is an event sent by a producer along with, for the events of
+//committed blocks, the number of the block (see SendBlockEvent)
+type producerEvent struct {
+	event       *pb.Event
+	blockNumber uint64
+	numbered    bool
+}
+
 //global eventProcessor singleton created by initializeEvents. Openchain producers
 //send events simply over a reentrant static method
 var gEventProcessor *eventProcessor
@@ -66,7 +80,10 @@ func (ep *eventProcessor) start() {
 	producerLogger.Info("event processor started")
 	for {
 		//wait for event
-		e := <-ep.eventChannel
+		atomic.StoreInt64(&ep.deliveringSince, 0)
+		pe := <-ep.eventChannel
+		atomic.StoreInt64(&ep.deliveringSince, time.Now().UnixNano())
+		e := pe.event
 
 		var hl *handlerList
 		eType := getMessageType(e)
@@ -81,35 +98,66 @@ func (ep *eventProcessor) start() {
 		ep.Unlock()
 
 		for h := range hl.handlers {
-			if rType := h.responseType(eType); rType != pb.Interest_DONTSEND {
-				//if Message is already a generic message, producer must have already converted
-				if eType != "generic" {
-					switch rType {
-					case pb.Interest_JSON:
-						if b, err := json.Marshal(e.Event); err != nil {
-							producerLogger.Error(fmt.Sprintf("could not marshall JSON for eObject %v(%s)", e.Event, eType))
-						} else {
-							e.Event = &pb.Event_Generic{Generic: &pb.Generic{EventType: eType, Payload: b}}
-						}
-					case pb.Interest_PROTOBUF:
-					}
-				}
-				if e.Event != nil {
-					h.SendMessage(e)
-				}
+			if he := h.prepareEvent(e, eType); he != nil && he.Event != nil {
+				h.deliver(he, pe)
 			}
 		}
 		hl.Unlock()
 	}
 }
 
+//prepareEvent returns the event as the handler registered for it: restricted
+//by the filter of the handler, and converted to JSON if asked for. It returns
+//nil if the event is not to be sent to the handler
+func (h *handler) prepareEvent(e *pb.Event, eType string) *pb.Event {
+	rType := h.responseType(eType)
+	if rType == pb.Interest_DONTSEND {
+		return nil
+	}
+	if filter := h.filter(eType); filter != nil {
+		if e = filterEvent(e, filter); e == nil {
+			return nil
+		}
+	}
+	//if Message is already a generic message, producer must have already converted
+	if _, isGeneric := e.Event.(*pb.Event_Generic); !isGeneric {
+		switch rType {
+		case pb.Interest_JSON:
+			if b, err := json.Marshal(e.Event); err != nil {
+				producerLogger.Error(fmt.Sprintf("could not marshall JSON for eObject %v(%s)", e.Event, eType))
+			} else {
+				e = &pb.Event{Event: &pb.Event_Generic{Generic: &pb.Generic{EventType: eType, Payload: b}}}
+			}
+		case pb.Interest_PROTOBUF:
+		}
+	}
+	return e
+}
+
+//CheckHealth returns an error if the event hub is not started or has been
+//delivering the same event for more than maxStall, e.g. to a consumer that
+//stopped reading its events
+func CheckHealth(maxStall time.Duration) error {
+	if gEventProcessor == nil {
+		return fmt.Errorf("Event hub not started")
+	}
+	since := atomic.LoadInt64(&gEventProcessor.deliveringSince)
+	if since == 0 {
+		return nil
+	}
+	if stall := time.Since(time.Unix(0, since)); stall > maxStall {
+		return fmt.Errorf("Event hub delivering the same event for %s, %d events queued", stall, len(gEventProcessor.eventChannel))
+	}
+	return nil
+}
+
 //initialize and start
 func initializeEvents(bufferSize uint, tout int) {
 	if gEventProcessor != nil {
 		panic("should not be called twice")
 	}
 
-	gEventProcessor = &eventProcessor{eventConsumers: make(map[string]*handlerList), eventChannel: make(chan *pb.Event, bufferSize), timeout: tout}
+	gEventProcessor = &eventProcessor{eventConsumers: make(map[string]*handlerList), eventChannel: make(chan *producerEvent, bufferSize), timeout: tout}
 
 	addInternalEventTypes()
 
@@ -177,7 +225,18 @@ func deRegisterHandler(ie *pb.Interest, h *handler) error {
 
 //Send sends the event to interested consumers
 func Send(e *pb.Event) error {
-	if e.Event == nil {
+	return send(&producerEvent{event: e})
+}
+
+//SendBlockEvent sends the event of the committed block with the given number
+//to interested consumers. The number lets the consumers replaying the
+//blockchain switch to the live events without missing or repeating a block
+func SendBlockEvent(blockNumber uint64, e *pb.Event) error {
+	return send(&producerEvent{event: e, blockNumber: blockNumber, numbered: true})
+}
+
+func send(e *producerEvent) error {
+	if e.event.Event == nil {
 		producerLogger.Error("event not set")
 		return fmt.Errorf("event not set")
 	}
@@ -190,6 +249,7 @@ func Send(e *pb.Event) error {
 		select {
 		case gEventProcessor.eventChannel <- e:
 		default:
+			droppedEvents.Inc()
 			return fmt.Errorf("could not send the blocking event")
 		}
 	} else if gEventProcessor.timeout == 0 {
@@ -198,6 +258,7 @@ func Send(e *pb.Event) error {
 		select {
 		case gEventProcessor.eventChannel <- e:
 		case <-time.After(time.Duration(gEventProcessor.timeout) * time.Millisecond):
+			droppedEvents.Inc()
 			return fmt.Errorf("could not send the blocking event")
 		}
 	}
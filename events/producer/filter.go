@@ -0,0 +1,93 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package producer
+
+import (
+	"github.com/golang/protobuf/proto"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+//filter returns the filter the handler registered for the event type, if any
+func (d *handler) filter(eventType string) *pb.EventFilter {
+	if ie, _ := d.interestedEvents[eventType]; ie != nil {
+		return ie.Filter
+	}
+	return nil
+}
+
+//filterEvent restricts a block event to the transactions matching the filter.
+//It returns nil if no transaction matches. The other events are not filtered
+func filterEvent(e *pb.Event, filter *pb.EventFilter) *pb.Event {
+	block := e.GetBlock()
+	if block == nil {
+		return e
+	}
+	results := make(map[string]*pb.TransactionResult)
+	for _, result := range block.GetNonHashData().GetTransactionResults() {
+		results[result.Uuid] = result
+	}
+
+	var transactions []*pb.Transaction
+	var transactionResults []*pb.TransactionResult
+	for _, tx := range block.Transactions {
+		result := results[tx.Uuid]
+		if !matchesFilter(tx, result, filter) {
+			continue
+		}
+		transactions = append(transactions, tx)
+		if result != nil {
+			transactionResults = append(transactionResults, result)
+		}
+	}
+	if len(transactions) == 0 {
+		return nil
+	}
+
+	filtered := *block
+	filtered.Transactions = transactions
+	if block.NonHashData != nil {
+		nonHashData := *block.NonHashData
+		nonHashData.TransactionResults = transactionResults
+		filtered.NonHashData = &nonHashData
+	}
+	return CreateBlockEvent(&filtered)
+}
+
+//matchesFilter tells if the transaction, whose result may be unknown, matches
+//all the criteria of the filter
+func matchesFilter(tx *pb.Transaction, result *pb.TransactionResult, filter *pb.EventFilter) bool {
+	if filter.ChaincodeID != "" {
+		chaincodeID := &pb.ChaincodeID{}
+		if err := proto.Unmarshal(tx.ChaincodeID, chaincodeID); err != nil || chaincodeID.Name != filter.ChaincodeID {
+			return false
+		}
+	}
+	if filter.EventName != "" {
+		event := result.GetChaincodeEvent()
+		if event == nil || event.EventName != filter.EventName {
+			return false
+		}
+	}
+	switch filter.TxResult {
+	case pb.EventFilter_SUCCEEDED:
+		return result != nil && result.ErrorCode == 0
+	case pb.EventFilter_FAILED:
+		return result != nil && result.ErrorCode != 0
+	}
+	return true
+}
@@ -27,6 +27,15 @@ type handler struct {
 	doneChan         chan bool
 	registered       bool
 	interestedEvents map[string]*pb.Interest
+	//replay is set if the consumer asked for the committed blocks to be replayed
+	replay *blockReplay
+	//acks is set if the consumer acknowledges the events
+	acks *ackQueue
+	//abortChan receives the error for which the consumer has to be disconnected
+	abortChan chan error
+	//local is set for an in-process consumer, which receives the events
+	//through the subscription instead of the ChatStream
+	local *Subscription
 }
 
 func newEventHandler(stream pb.Events_ChatServer) (*handler, error) {
@@ -34,18 +43,33 @@ func newEventHandler(stream pb.Events_ChatServer) (*handler, error) {
 		ChatStream: stream,
 	}
 	d.doneChan = make(chan bool)
+	d.abortChan = make(chan error, 1)
 
 	return d, nil
 }
 
 // Stop stops this handler
 func (d *handler) Stop() error {
+	if d.replay != nil {
+		d.replay.stop()
+	}
+	if d.acks != nil {
+		d.acks.close()
+	}
 	d.deregister()
-	d.doneChan <- true
+	close(d.doneChan)
 	d.registered = false
 	return nil
 }
 
+//abort disconnects the consumer
+func (d *handler) abort(err error) {
+	select {
+	case d.abortChan <- err:
+	default:
+	}
+}
+
 func (d *handler) register(iEvents []*pb.Interest) error {
 	//TODO add the handler to the map for the interested events
 	//if successfully done, continue....
@@ -94,11 +118,31 @@ func (d *handler) responseType(eventType string) pb.Interest_ResponseType {
 // HandleMessage handles the Openchain messages for the Peer.
 func (d *handler) HandleMessage(msg *pb.Event) error {
 	producerLogger.Debug("Handling Event")
+	if ack := msg.GetAck(); ack != nil {
+		return d.handleAck(ack)
+	}
 	eventsObj := msg.GetRegister()
 	if eventsObj == nil {
 		return fmt.Errorf("Invalid object from consumer %v", msg.GetEvent())
 	}
 
+	startAcks := false
+	if eventsObj.MaxUnackedEvents > 0 && d.acks == nil {
+		d.acks = newAckQueue(int(eventsObj.MaxUnackedEvents))
+		startAcks = true
+	}
+
+	//the replay has to be set up before the handler receives live events
+	startReplay := false
+	if eventsObj.Replay != nil && d.replay == nil && interestedInBlocks(eventsObj.Events) {
+		replay, err := newBlockReplay(eventsObj.Replay, d.acks != nil)
+		if err != nil {
+			return fmt.Errorf("Could not replay blocks %s", err)
+		}
+		d.replay = replay
+		startReplay = true
+	}
+
 	if err := d.register(eventsObj.Events); err != nil {
 		return fmt.Errorf("Could not register events %s", err)
 	}
@@ -110,9 +154,46 @@ func (d *handler) HandleMessage(msg *pb.Event) error {
 
 	d.registered = true
 
+	if startAcks {
+		go d.runAcks()
+	}
+	if startReplay {
+		go d.runReplay()
+	}
+
 	return nil
 }
 
+func interestedInBlocks(iEvents []*pb.Interest) bool {
+	for _, ie := range iEvents {
+		if ie.EventType == BlockType && ie.ResponseType != pb.Interest_DONTSEND {
+			return true
+		}
+	}
+	return false
+}
+
+//send sends an event to the consumer, through the queue of the events to
+//acknowledge if the consumer acknowledges them, or to the subscription of an
+//in-process consumer. blockNumber is the number of the block of a numbered
+//block event
+func (d *handler) send(e *pb.Event, blockNumber uint64, numbered bool) error {
+	var err error
+	switch {
+	case d.local != nil:
+		err = d.local.deliver(e, blockNumber, numbered)
+	case d.acks == nil:
+		return d.SendMessage(e)
+	default:
+		err = d.acks.enqueue(e, blockNumber, numbered)
+	}
+	if err == errSlowConsumer {
+		producerLogger.Warning("disconnecting consumer: %s", err)
+		d.abort(err)
+	}
+	return err
+}
+
 // SendMessage sends a message to the remote PEER through the stream
 func (d *handler) SendMessage(msg *pb.Event) error {
 	err := d.ChatStream.Send(msg)
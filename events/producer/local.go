@@ -0,0 +1,88 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package producer
+
+import (
+	"fmt"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+//LocalEvent is an event delivered to an in-process consumer. BlockNumber is
+//the number of the block of the events of committed blocks (Numbered set)
+type LocalEvent struct {
+	Event       *pb.Event
+	BlockNumber uint64
+	Numbered    bool
+}
+
+//Subscription is an in-process consumer of the event hub, such as the
+//WebSocket endpoint of the REST API, which receives the events without going
+//through the gRPC Chat stream
+type Subscription struct {
+	handler *handler
+	events  chan *LocalEvent
+}
+
+//Subscribe registers an in-process consumer for the events of the interests.
+//The same as for a consumer acknowledging its events, the consumer is
+//disconnected (see Done) rather than slowing down the event hub when more
+//events than the consumer buffer size are queued for it. The subscription
+//must be closed once done with
+func Subscribe(interests []*pb.Interest) (*Subscription, error) {
+	if gEventProcessor == nil {
+		return nil, fmt.Errorf("the event hub is not running")
+	}
+	h, err := newEventHandler(nil)
+	if err != nil {
+		return nil, err
+	}
+	s := &Subscription{handler: h, events: make(chan *LocalEvent, consumerBufferSize)}
+	h.local = s
+	//there is no Register message, the handler receives the events as soon
+	//as it is registered
+	h.registered = true
+	if err := h.register(interests); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+//Events returns the channel the events are delivered on
+func (s *Subscription) Events() <-chan *LocalEvent {
+	return s.events
+}
+
+//Done returns a channel receiving the error for which the consumer is
+//disconnected, after which no event is delivered
+func (s *Subscription) Done() <-chan error {
+	return s.handler.abortChan
+}
+
+//Close deregisters the consumer
+func (s *Subscription) Close() {
+	s.handler.Stop()
+}
+
+func (s *Subscription) deliver(e *pb.Event, blockNumber uint64, numbered bool) error {
+	select {
+	case s.events <- &LocalEvent{Event: e, BlockNumber: blockNumber, Numbered: numbered}:
+		return nil
+	default:
+		return errSlowConsumer
+	}
+}
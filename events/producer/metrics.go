@@ -0,0 +1,36 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package producer
+
+import (
+	"github.com/hyperledger/fabric/core/metrics"
+)
+
+//droppedEvents counts the events not sent because the buffer of the event hub
+//stayed full for longer than the timeout
+var droppedEvents = metrics.NewCounter("events_dropped_total",
+	"Number of events not sent because the buffer of the event hub was full.")
+
+func init() {
+	metrics.NewGaugeFunc("events_backlog", "Number of events buffered by the event hub, not yet sent to the consumers.", nil,
+		func() []metrics.Sample {
+			if gEventProcessor == nil {
+				return nil
+			}
+			return []metrics.Sample{{Value: float64(len(gEventProcessor.eventChannel))}}
+		})
+}
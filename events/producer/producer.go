@@ -21,6 +21,7 @@ import (
 	"io"
 	"time"
 
+	"github.com/hyperledger/fabric/core/acl"
 	pb "github.com/hyperledger/fabric/protos"
 	"github.com/op/go-logging"
 )
@@ -48,26 +49,51 @@ func NewEventsServer(bufferSize uint, timeout int) *EventsServer {
 
 // Chat implementation of the the Chat bidi streaming RPC function
 func (p *EventsServer) Chat(stream pb.Events_ChatServer) error {
+	if err := acl.Check(stream.Context(), acl.EventsAPI); err != nil {
+		return err
+	}
 	handler, err := newEventHandler(stream)
 	if err != nil {
 		return fmt.Errorf("Error creating handler during handleChat initiation: %s", err)
 	}
 	defer handler.Stop()
-	for {
-		in, err := stream.Recv()
-		if err == io.EOF {
-			producerLogger.Debug("Received EOF, ending Chat")
-			return nil
+	received := make(chan *pb.Event)
+	recvErr := make(chan error, 1)
+	go func() {
+		for {
+			in, err := stream.Recv()
+			if err != nil {
+				recvErr <- err
+				return
+			}
+			select {
+			case received <- in:
+			case <-handler.doneChan:
+				return
+			}
 		}
-		if err != nil {
+	}()
+	for {
+		select {
+		case in := <-received:
+			err = handler.HandleMessage(in)
+			if err != nil {
+				producerLogger.Error(fmt.Sprintf("Error handling message: %s", err))
+				//return err
+			}
+		case err = <-recvErr:
+			if err == io.EOF {
+				producerLogger.Debug("Received EOF, ending Chat")
+				return nil
+			}
 			e := fmt.Errorf("Error during Chat, stopping handler: %s", err)
 			producerLogger.Error(e.Error())
 			return e
-		}
-		err = handler.HandleMessage(in)
-		if err != nil {
-			producerLogger.Error(fmt.Sprintf("Error handling message: %s", err))
-			//return err
+		case err = <-handler.abortChan:
+			// e.g. the consumer does not acknowledge the events fast enough
+			e := fmt.Errorf("Disconnecting consumer: %s", err)
+			producerLogger.Error(e.Error())
+			return e
 		}
 	}
 }
@@ -27,8 +27,10 @@ import (
 
 //----Event Types -----
 const (
-	RegisterType = "register"
-	BlockType    = "block"
+	RegisterType    = "register"
+	BlockType       = "block"
+	BlockCommitType = "blockCommit"
+	DiskQuotaType   = "diskQuota"
 )
 
 func getMessageType(e *pb.Event) string {
@@ -37,7 +39,13 @@ func getMessageType(e *pb.Event) string {
 		return "register"
 	case *pb.Event_Block:
 		return "block"
+	case *pb.Event_BlockCommit:
+		return BlockCommitType
 	case *pb.Event_Generic:
+		// generic events sent by the peer, such as DiskQuotaType, are dispatched per event type
+		if e.GetGeneric().EventType == DiskQuotaType {
+			return DiskQuotaType
+		}
 		return "generic"
 	default:
 		return ""
@@ -47,5 +55,7 @@ func getMessageType(e *pb.Event) string {
 //should be called at init time to register supported internal events
 func addInternalEventTypes() {
 	AddEventType(BlockType)
+	AddEventType(BlockCommitType)
 	AddEventType(RegisterType)
+	AddEventType(DiskQuotaType)
 }
@@ -0,0 +1,233 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package producer
+
+import (
+	"fmt"
+	"sync"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+//ReplaySource gives the event hub access to the committed blocks it replays
+//to the consumers and to the checkpoints of the consumers. It is implemented
+//by the ledger
+type ReplaySource interface {
+	//GetBlockchainSize returns the number of committed blocks
+	GetBlockchainSize() uint64
+	//GetEventBlock returns the committed block with the given number, as sent in block events
+	GetEventBlock(blockNumber uint64) (*pb.Block, error)
+	//GetEventConsumerCheckpoint returns the number of the next block to send to the consumer
+	GetEventConsumerCheckpoint(consumerID string) (nextBlock uint64, found bool, err error)
+	//PutEventConsumerCheckpoint records the number of the next block to send to the consumer
+	PutEventConsumerCheckpoint(consumerID string, nextBlock uint64) error
+}
+
+var replaySource struct {
+	sync.RWMutex
+	source ReplaySource
+}
+
+//SetReplaySource sets the source of the blocks replayed to the consumers which
+//register with a Replay. Without a source, such registrations are rejected
+func SetReplaySource(source ReplaySource) {
+	replaySource.Lock()
+	defer replaySource.Unlock()
+	replaySource.source = source
+}
+
+func getReplaySource() ReplaySource {
+	replaySource.RLock()
+	defer replaySource.RUnlock()
+	return replaySource.source
+}
+
+//queuedEvent is a live block event received while the blocks before it are
+//being replayed
+type queuedEvent struct {
+	event       *pb.Event
+	blockNumber uint64
+}
+
+//blockReplay tracks the blocks sent to a consumer which asked for a replay.
+//Its lock serializes the events sent to the consumer by the event processor
+//and by the replay. The live block events are queued while replaying and the
+//blocks already sent are skipped, so that the consumer receives each block
+//once and in order
+type blockReplay struct {
+	sync.Mutex
+	source     ReplaySource
+	consumerID string
+	nextBlock  uint64
+	replaying  bool
+	stopped    bool
+	pending    []*queuedEvent
+	//checkpointOnAck is set if the blocks are checkpointed once acknowledged
+	//by the consumer rather than once sent
+	checkpointOnAck bool
+}
+
+func newBlockReplay(replay *pb.Replay, checkpointOnAck bool) (*blockReplay, error) {
+	source := getReplaySource()
+	if source == nil {
+		return nil, fmt.Errorf("replay is not supported by this event hub")
+	}
+	r := &blockReplay{source: source, consumerID: replay.ConsumerID, nextBlock: replay.FromBlock, replaying: true, checkpointOnAck: checkpointOnAck}
+	if replay.Resume && replay.ConsumerID != "" {
+		nextBlock, found, err := source.GetEventConsumerCheckpoint(replay.ConsumerID)
+		if err != nil {
+			return nil, fmt.Errorf("could not read the checkpoint of %s: %s", replay.ConsumerID, err)
+		}
+		if found {
+			r.nextBlock = nextBlock
+		}
+	}
+	producerLogger.Debug("replaying blocks from %d to consumer '%s'", r.nextBlock, r.consumerID)
+	return r, nil
+}
+
+//sent records that the block was sent to the consumer. Must be called with the lock held
+func (r *blockReplay) sent(blockNumber uint64) {
+	r.nextBlock = blockNumber + 1
+	if !r.checkpointOnAck {
+		r.checkpoint(r.nextBlock)
+	}
+}
+
+//checkpoint records the number of the next block to send to the consumer
+func (r *blockReplay) checkpoint(nextBlock uint64) {
+	if r.consumerID == "" {
+		return
+	}
+	if err := r.source.PutEventConsumerCheckpoint(r.consumerID, nextBlock); err != nil {
+		producerLogger.Error(fmt.Sprintf("could not checkpoint block %d for consumer %s: %s", nextBlock-1, r.consumerID, err))
+	}
+}
+
+func (r *blockReplay) stop() {
+	r.Lock()
+	r.stopped = true
+	r.pending = nil
+	r.Unlock()
+}
+
+//deliver sends a live event to the consumer
+func (d *handler) deliver(e *pb.Event, pe *producerEvent) error {
+	r := d.replay
+	if r == nil {
+		return d.send(e, pe.blockNumber, pe.numbered)
+	}
+	r.Lock()
+	defer r.Unlock()
+	if !pe.numbered {
+		return d.send(e, 0, false)
+	}
+	switch {
+	case pe.blockNumber < r.nextBlock:
+		// already replayed
+		return nil
+	case r.replaying:
+		r.pending = append(r.pending, &queuedEvent{event: e, blockNumber: pe.blockNumber})
+		return nil
+	}
+	if err := d.send(e, pe.blockNumber, true); err != nil {
+		return err
+	}
+	r.sent(pe.blockNumber)
+	return nil
+}
+
+//runReplay sends the committed blocks from the requested one, then the live
+//block events queued meanwhile, after which the handler is fed by the event
+//processor only
+func (d *handler) runReplay() {
+	r := d.replay
+	for {
+		r.Lock()
+		if r.stopped {
+			r.Unlock()
+			return
+		}
+		for len(r.pending) > 0 && r.pending[0].blockNumber <= r.nextBlock {
+			q := r.pending[0]
+			r.pending = r.pending[1:]
+			if q.blockNumber == r.nextBlock && !d.sendReplayed(q.event, q.blockNumber) {
+				r.Unlock()
+				return
+			}
+		}
+		if r.nextBlock >= r.source.GetBlockchainSize() {
+			r.finish(d)
+			r.Unlock()
+			return
+		}
+		blockNumber := r.nextBlock
+		r.Unlock()
+
+		block, err := r.source.GetEventBlock(blockNumber)
+		r.Lock()
+		if err != nil {
+			producerLogger.Error(fmt.Sprintf("could not replay block %d, switching to live events: %s", blockNumber, err))
+			r.finish(d)
+			r.Unlock()
+			return
+		}
+		if r.stopped {
+			r.Unlock()
+			return
+		}
+		if e := d.createReplayedEvent(block); e == nil {
+			// filtered out
+			r.sent(blockNumber)
+		} else if !d.sendReplayed(e, blockNumber) {
+			r.Unlock()
+			return
+		}
+		r.Unlock()
+	}
+}
+
+//finish ends the replay, sending the queued live events. Must be called with the lock held
+func (r *blockReplay) finish(d *handler) {
+	for _, q := range r.pending {
+		if q.blockNumber >= r.nextBlock && !d.sendReplayed(q.event, q.blockNumber) {
+			break
+		}
+	}
+	r.pending = nil
+	r.replaying = false
+	producerLogger.Debug("replay to consumer '%s' caught up at block %d", r.consumerID, r.nextBlock)
+}
+
+//sendReplayed sends a block event during the replay. It returns false if the
+//consumer is gone. Must be called with the lock held
+func (d *handler) sendReplayed(e *pb.Event, blockNumber uint64) bool {
+	if err := d.send(e, blockNumber, true); err != nil {
+		producerLogger.Error(fmt.Sprintf("could not replay block %d: %s", blockNumber, err))
+		d.replay.stopped = true
+		return false
+	}
+	d.replay.sent(blockNumber)
+	return true
+}
+
+//createReplayedEvent creates the event of a replayed block, as the consumer
+//registered for it. It returns nil if the block does not pass the filter of
+//the consumer
+func (d *handler) createReplayedEvent(block *pb.Block) *pb.Event {
+	return d.prepareEvent(CreateBlockEvent(block), BlockType)
+}
@@ -0,0 +1,245 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/hyperledger/fabric/events/consumer"
+	pb "github.com/hyperledger/fabric/protos"
+	"github.com/op/go-logging"
+)
+
+var logger = logging.MustGetLogger("eventhub_webhook")
+
+const (
+	//BlockNotification is the type of the notifications of the committed blocks
+	BlockNotification = "block"
+	//ChaincodeNotification is the type of the notifications of the chaincode events
+	ChaincodeNotification = "chaincode"
+
+	//SignatureHeader holds the hex encoded HMAC-SHA256 of the body, keyed with
+	//the secret of the endpoint
+	SignatureHeader = "X-Fabric-Signature"
+	//EventHeader holds the type of the notification
+	EventHeader = "X-Fabric-Event"
+
+	//maxUnackedEvents is the number of events sent to the adapter before the
+	//previous ones are posted
+	maxUnackedEvents = 10
+)
+
+//Endpoint is an HTTP endpoint the events are posted to
+type Endpoint struct {
+	URL string
+	//Secret signs the notifications when set
+	Secret string
+	//Events are the types of the notifications posted to the endpoint, all of
+	//them when empty
+	Events []string
+}
+
+func (ep *Endpoint) wants(notificationType string) bool {
+	if len(ep.Events) == 0 {
+		return true
+	}
+	for _, t := range ep.Events {
+		if t == notificationType {
+			return true
+		}
+	}
+	return false
+}
+
+//Notification is the JSON body posted to the endpoints
+type Notification struct {
+	Type           string             `json:"type"`
+	Block          *pb.Block          `json:"block,omitempty"`
+	ChaincodeEvent *pb.ChaincodeEvent `json:"chaincodeEvent,omitempty"`
+}
+
+//Adapter is an event adapter posting the committed blocks and the chaincode
+//events they hold to HTTP endpoints. A failed post is retried with an
+//exponential backoff before the notification is given up
+type Adapter struct {
+	endpoints     []Endpoint
+	client        *http.Client
+	retries       int
+	retryInterval time.Duration
+	replay        *pb.Replay
+	disconnected  chan error
+}
+
+//NewAdapter returns an adapter posting to the given endpoints. A post is
+//retried at most retries times, the first time after retryInterval. When
+//consumerID is set, the adapter resumes after the last block it posted when
+//restarted
+func NewAdapter(endpoints []Endpoint, retries int, retryInterval time.Duration, timeout time.Duration, consumerID string) *Adapter {
+	a := &Adapter{
+		endpoints:     endpoints,
+		client:        &http.Client{Timeout: timeout},
+		retries:       retries,
+		retryInterval: retryInterval,
+		disconnected:  make(chan error, 1),
+	}
+	if consumerID != "" {
+		a.replay = &pb.Replay{ConsumerID: consumerID, Resume: true}
+	}
+	return a
+}
+
+//GetInterestedEvents implements consumer.EventAdapter
+func (a *Adapter) GetInterestedEvents() ([]*pb.Interest, error) {
+	return []*pb.Interest{{EventType: "block", ResponseType: pb.Interest_PROTOBUF}}, nil
+}
+
+//GetReplay implements consumer.ReplayAdapter
+func (a *Adapter) GetReplay() (*pb.Replay, error) {
+	return a.replay, nil
+}
+
+//GetMaxUnackedEvents implements consumer.AckAdapter, so that an endpoint
+//slower than the blocks are committed does not hold the other consumers back
+func (a *Adapter) GetMaxUnackedEvents() uint32 {
+	return maxUnackedEvents
+}
+
+//Recv implements consumer.EventAdapter by posting the block and its chaincode
+//events to the endpoints
+func (a *Adapter) Recv(msg *pb.Event) (bool, error) {
+	block := msg.GetBlock()
+	if block == nil {
+		return true, nil
+	}
+	notifications := []*Notification{{Type: BlockNotification, Block: block}}
+	if block.NonHashData != nil {
+		for _, result := range block.NonHashData.TransactionResults {
+			if event := result.GetChaincodeEvent(); event != nil {
+				notifications = append(notifications, &Notification{Type: ChaincodeNotification, ChaincodeEvent: event})
+			}
+		}
+	}
+	for _, n := range notifications {
+		body, err := json.Marshal(n)
+		if err != nil {
+			logger.Error("Error marshalling the %s notification: %s", n.Type, err)
+			continue
+		}
+		for i := range a.endpoints {
+			ep := &a.endpoints[i]
+			if !ep.wants(n.Type) {
+				continue
+			}
+			if err := a.post(ep, n.Type, body); err != nil {
+				logger.Error("Giving up the %s notification to %s: %s", n.Type, ep.URL, err)
+			}
+		}
+	}
+	return true, nil
+}
+
+//Disconnected implements consumer.EventAdapter
+func (a *Adapter) Disconnected(err error) {
+	select {
+	case a.disconnected <- err:
+	default:
+	}
+}
+
+//Start connects the adapter to the event hub at the given address, and
+//reconnects it when disconnected with an error, e.g. because the endpoints
+//could not keep up with the blocks
+func (a *Adapter) Start(eventsAddress string) error {
+	if err := consumer.NewEventsClient(eventsAddress, a).Start(); err != nil {
+		return err
+	}
+	go func() {
+		for err := range a.disconnected {
+			if err == nil {
+				return
+			}
+			logger.Warning("Webhooks disconnected from the event hub: %s", err)
+			for {
+				time.Sleep(a.retryInterval)
+				if err = consumer.NewEventsClient(eventsAddress, a).Start(); err == nil {
+					break
+				}
+				logger.Error("Error reconnecting the webhooks to the event hub: %s", err)
+			}
+		}
+	}()
+	return nil
+}
+
+func (a *Adapter) post(ep *Endpoint, notificationType string, body []byte) error {
+	interval := a.retryInterval
+	for attempt := 0; ; attempt++ {
+		retry, err := a.postOnce(ep, notificationType, body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= a.retries {
+			return err
+		}
+		logger.Debug("Retrying the %s notification to %s in %s: %s", notificationType, ep.URL, interval, err)
+		time.Sleep(interval)
+		interval *= 2
+	}
+}
+
+//postOnce posts the body to the endpoint, returning whether a failure may be
+//retried
+func (a *Adapter) postOnce(ep *Endpoint, notificationType string, body []byte) (bool, error) {
+	req, err := http.NewRequest("POST", ep.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, notificationType)
+	if ep.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign([]byte(ep.Secret), body))
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		return false, nil
+	}
+	// the other client errors would fail again
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusRequestTimeout
+	return retry, fmt.Errorf("endpoint returned status %s", resp.Status)
+}
+
+//Sign returns the value of the signature header of a notification: "sha256="
+//followed by the hex encoded HMAC-SHA256 of the body
+func Sign(secret []byte, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,131 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	pb "github.com/hyperledger/fabric/protos"
+)
+
+type endpoint struct {
+	sync.Mutex
+	failures      int
+	status        int
+	attempts      int
+	notifications []*Notification
+	signatures    []string
+}
+
+func (ep *endpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ep.Lock()
+	defer ep.Unlock()
+	ep.attempts++
+	if ep.failures > 0 {
+		ep.failures--
+		w.WriteHeader(ep.status)
+		return
+	}
+	body, _ := ioutil.ReadAll(r.Body)
+	n := &Notification{}
+	if err := json.Unmarshal(body, n); err != nil || n.Type != r.Header.Get(EventHeader) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if r.Header.Get(SignatureHeader) != "" && r.Header.Get(SignatureHeader) != Sign([]byte("secret"), body) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	ep.notifications = append(ep.notifications, n)
+	ep.signatures = append(ep.signatures, r.Header.Get(SignatureHeader))
+}
+
+func createBlockEvent() *pb.Event {
+	results := []*pb.TransactionResult{
+		{Uuid: "tx1", ChaincodeEvent: &pb.ChaincodeEvent{ChaincodeID: "cc", TxID: "tx1", EventName: "transfer", Payload: []byte("payload")}},
+		{Uuid: "tx2"},
+	}
+	block := &pb.Block{NonHashData: &pb.NonHashData{TransactionResults: results}}
+	return &pb.Event{Event: &pb.Event_Block{Block: block}}
+}
+
+func TestPostNotifications(t *testing.T) {
+	all := &endpoint{failures: 2, status: http.StatusServiceUnavailable}
+	allServer := httptest.NewServer(all)
+	defer allServer.Close()
+	chaincode := &endpoint{}
+	chaincodeServer := httptest.NewServer(chaincode)
+	defer chaincodeServer.Close()
+
+	endpoints := []Endpoint{
+		{URL: allServer.URL, Secret: "secret"},
+		{URL: chaincodeServer.URL, Events: []string{ChaincodeNotification}},
+	}
+	a := NewAdapter(endpoints, 3, time.Millisecond, time.Second, "")
+	if cont, err := a.Recv(createBlockEvent()); !cont || err != nil {
+		t.Fatalf("Expected the adapter to go on, got %t, %v", cont, err)
+	}
+
+	if all.attempts != 4 {
+		t.Fatalf("Expected the first notification to be retried twice, got %d attempts", all.attempts)
+	}
+	if len(all.notifications) != 2 || all.notifications[0].Type != BlockNotification || all.notifications[1].Type != ChaincodeNotification {
+		t.Fatalf("Expected a block and a chaincode notification, got %v", all.notifications)
+	}
+	if all.signatures[0] == "" || all.signatures[1] == "" {
+		t.Fatalf("Expected the notifications to be signed")
+	}
+	if len(all.notifications[0].Block.NonHashData.TransactionResults) != 2 {
+		t.Fatalf("Expected the block notification to hold the transaction results, got %v", all.notifications[0].Block)
+	}
+
+	if len(chaincode.notifications) != 1 || chaincode.notifications[0].ChaincodeEvent.EventName != "transfer" {
+		t.Fatalf("Expected only the chaincode notification, got %v", chaincode.notifications)
+	}
+	if chaincode.signatures[0] != "" {
+		t.Fatalf("Expected the notification not to be signed without a secret")
+	}
+}
+
+func TestGiveUpNotifications(t *testing.T) {
+	retried := &endpoint{failures: 10, status: http.StatusInternalServerError}
+	retriedServer := httptest.NewServer(retried)
+	defer retriedServer.Close()
+	rejected := &endpoint{failures: 10, status: http.StatusBadRequest}
+	rejectedServer := httptest.NewServer(rejected)
+	defer rejectedServer.Close()
+
+	endpoints := []Endpoint{{URL: retriedServer.URL}, {URL: rejectedServer.URL}}
+	a := NewAdapter(endpoints, 2, time.Millisecond, time.Second, "")
+	if cont, err := a.Recv(createBlockEvent()); !cont || err != nil {
+		t.Fatalf("Expected the adapter to go on, got %t, %v", cont, err)
+	}
+	// both notifications are tried three times
+	if retried.attempts != 6 {
+		t.Fatalf("Expected 6 attempts, got %d", retried.attempts)
+	}
+	// client errors are not retried
+	if rejected.attempts != 2 {
+		t.Fatalf("Expected 2 attempts, got %d", rejected.attempts)
+	}
+}
@@ -184,7 +184,7 @@ func deploy(admCert crypto.CertificateHandler) error {
 
 	ledger, err := ledger.GetLedger()
 	ledger.BeginTxBatch("1")
-	_, err = chaincode.Execute(ctx, chaincode.GetChain(chaincode.DefaultChain), transaction)
+	_, _, err = chaincode.Execute(ctx, chaincode.GetChain(chaincode.DefaultChain), transaction)
 	if err != nil {
 		return fmt.Errorf("Error deploying chaincode: %s", err)
 	}
@@ -243,7 +243,7 @@ func assignOwnership(admCert crypto.CertificateHandler, asset string, newOwnerCe
 
 	ledger, err := ledger.GetLedger()
 	ledger.BeginTxBatch("1")
-	_, err = chaincode.Execute(ctx, chaincode.GetChain(chaincode.DefaultChain), transaction)
+	_, _, err = chaincode.Execute(ctx, chaincode.GetChain(chaincode.DefaultChain), transaction)
 	if err != nil {
 		return fmt.Errorf("Error deploying chaincode: %s", err)
 	}
@@ -303,7 +303,7 @@ func transferOwnership(owner crypto.Client, ownerCert crypto.CertificateHandler,
 
 	ledger, err := ledger.GetLedger()
 	ledger.BeginTxBatch("1")
-	_, err = chaincode.Execute(ctx, chaincode.GetChain(chaincode.DefaultChain), transaction)
+	_, _, err = chaincode.Execute(ctx, chaincode.GetChain(chaincode.DefaultChain), transaction)
 	if err != nil {
 		return fmt.Errorf("Error deploying chaincode: %s", err)
 	}
@@ -337,7 +337,7 @@ func whoIsTheOwner(asset string) ([]byte, error) {
 
 	ledger, err := ledger.GetLedger()
 	ledger.BeginTxBatch("1")
-	result, err := chaincode.Execute(ctx, chaincode.GetChain(chaincode.DefaultChain), transaction)
+	result, _, err := chaincode.Execute(ctx, chaincode.GetChain(chaincode.DefaultChain), transaction)
 	if err != nil {
 		return nil, fmt.Errorf("Error deploying chaincode: %s", err)
 	}
@@ -34,6 +34,8 @@ import (
 // Query operations
 // get - requires one argument, a key, and returns a value
 // keys - requires no arguments, returns all keys
+// query - requires a rich query, returns the matching keys and values
+// history - requires a key, returns the values it took
 
 // SimpleChaincode example simple Chaincode implementation
 type SimpleChaincode struct {
@@ -44,9 +46,10 @@ func (t *SimpleChaincode) Init(stub *shim.ChaincodeStub, function string, args [
 	return nil, nil
 }
 
-// Invoke has two functions
+// Invoke has three functions
 // put - takes two arguements, a key and value, and stores them in the state
 // remove - takes one argument, a key, and removes if from the state
+// writeonce - takes one argument, a key, and marks it as write-once
 func (t *SimpleChaincode) Invoke(stub *shim.ChaincodeStub, function string, args []string) ([]byte, error) {
 
 	switch function {
@@ -76,14 +79,29 @@ func (t *SimpleChaincode) Invoke(stub *shim.ChaincodeStub, function string, args
 		}
 		return nil, nil
 
+	case "writeonce":
+		if len(args) < 1 {
+			return nil, errors.New("writeonce operation must include one argument, a key")
+		}
+		key := args[0]
+
+		err := stub.MarkWriteOnce(key)
+		if err != nil {
+			return nil, fmt.Errorf("writeonce operation failed. Error marking the key: %s", err)
+		}
+		return nil, nil
+
 	default:
 		return nil, errors.New("Unsupported operation")
 	}
 }
 
-// Query has two functions
+// Query has four functions
 // get - takes one argument, a key, and returns the value for the key
-// keys - returns all keys stored in this chaincode
+// keys - returns all keys stored in this chaincode, in lexical order
+// query - takes one argument, a query in the syntax of the state database,
+//         and returns the matching keys and values
+// history - takes one argument, a key, and returns the values it took
 func (t *SimpleChaincode) Query(stub *shim.ChaincodeStub, function string, args []string) ([]byte, error) {
 
 	switch function {
@@ -101,7 +119,7 @@ func (t *SimpleChaincode) Query(stub *shim.ChaincodeStub, function string, args
 
 	case "keys":
 
-		keysIter, err := stub.RangeQueryState("", "")
+		keysIter, err := stub.GetStateByRange("", "")
 		if err != nil {
 			return nil, fmt.Errorf("keys operation failed. Error accessing state: %s", err)
 		}
@@ -123,6 +141,64 @@ func (t *SimpleChaincode) Query(stub *shim.ChaincodeStub, function string, args
 
 		return jsonKeys, nil
 
+	case "query":
+		if len(args) < 1 {
+			return nil, errors.New("query operation must include one argument, a query")
+		}
+		resultsIter, err := stub.GetQueryResult(args[0])
+		if err != nil {
+			return nil, fmt.Errorf("query operation failed. Error accessing state: %s", err)
+		}
+		defer resultsIter.Close()
+
+		results := make(map[string]string)
+		for resultsIter.HasNext() {
+			key, value, iterErr := resultsIter.Next()
+			if iterErr != nil {
+				return nil, fmt.Errorf("query operation failed. Error accessing state: %s", iterErr)
+			}
+			results[key] = string(value)
+		}
+
+		jsonResults, err := json.Marshal(results)
+		if err != nil {
+			return nil, fmt.Errorf("query operation failed. Error marshaling JSON: %s", err)
+		}
+
+		return jsonResults, nil
+
+	case "history":
+		if len(args) < 1 {
+			return nil, errors.New("history operation must include one argument, a key")
+		}
+		historyIter, err := stub.GetHistoryForKey(args[0])
+		if err != nil {
+			return nil, fmt.Errorf("history operation failed. Error accessing state: %s", err)
+		}
+		defer historyIter.Close()
+
+		type keyModification struct {
+			TxUUID      string `json:"txUUID"`
+			BlockNumber uint64 `json:"blockNumber"`
+			Value       string `json:"value"`
+			IsDelete    bool   `json:"isDelete"`
+		}
+		var history []keyModification
+		for historyIter.HasNext() {
+			modification, iterErr := historyIter.Next()
+			if iterErr != nil {
+				return nil, fmt.Errorf("history operation failed. Error accessing state: %s", iterErr)
+			}
+			history = append(history, keyModification{modification.TxUUID, modification.BlockNumber, string(modification.Value), modification.IsDelete})
+		}
+
+		jsonHistory, err := json.Marshal(history)
+		if err != nil {
+			return nil, fmt.Errorf("history operation failed. Error marshaling JSON: %s", err)
+		}
+
+		return jsonHistory, nil
+
 	default:
 		return nil, errors.New("Unsupported operation")
 	}
@@ -0,0 +1,76 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package example;
+
+import org.hyperledger.fabric.shim.ChaincodeBase;
+import org.hyperledger.fabric.shim.ChaincodeStub;
+
+/**
+ * This chaincode implements a simple map that is stored in the state, like
+ * examples/chaincode/go/map.
+ *
+ * Invoke operations
+ * put - requires two arguments, a key and value
+ * remove - requires a key
+ *
+ * Query operations
+ * get - requires one argument, a key, and returns a value
+ */
+public class Map extends ChaincodeBase {
+
+    @Override
+    public byte[] init(ChaincodeStub stub, String function, String[] args) {
+        return null;
+    }
+
+    @Override
+    public byte[] invoke(ChaincodeStub stub, String function, String[] args) throws Exception {
+        switch (function) {
+        case "put":
+            if (args.length < 2) {
+                throw new IllegalArgumentException("put operation must include two arguments, a key and value");
+            }
+            stub.putStringState(args[0], args[1]);
+            return null;
+        case "remove":
+            if (args.length < 1) {
+                throw new IllegalArgumentException("remove operation must include one argument, a key");
+            }
+            stub.delState(args[0]);
+            return null;
+        default:
+            throw new IllegalArgumentException("Unsupported operation");
+        }
+    }
+
+    @Override
+    public byte[] query(ChaincodeStub stub, String function, String[] args) throws Exception {
+        switch (function) {
+        case "get":
+            if (args.length < 1) {
+                throw new IllegalArgumentException("get operation must include one argument, a key");
+            }
+            return stub.getState(args[0]);
+        default:
+            throw new IllegalArgumentException("Unsupported operation");
+        }
+    }
+
+    public static void main(String[] args) {
+        new Map().start(args);
+    }
+}
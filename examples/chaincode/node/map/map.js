@@ -0,0 +1,70 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+
+// This chaincode implements a simple map that is stored in the state, like
+// examples/chaincode/go/map. The fabric-shim module is installed with the
+// chaincode by the peer.
+//
+// Invoke operations
+// put - requires two arguments, a key and value
+// remove - requires a key
+//
+// Query operations
+// get - requires one argument, a key, and returns a value
+
+'use strict';
+
+var shim = require('fabric-shim');
+
+shim.start({
+    init: function(stub, fn, args) {
+        return null;
+    },
+
+    invoke: function(stub, fn, args) {
+        switch (fn) {
+        case 'put':
+            if (args.length < 2) {
+                throw new Error('put operation must include two arguments, a key and value');
+            }
+            return stub.putState(args[0], args[1]).then(function() {
+                return null;
+            });
+        case 'remove':
+            if (args.length < 1) {
+                throw new Error('remove operation must include one argument, a key');
+            }
+            return stub.delState(args[0]).then(function() {
+                return null;
+            });
+        default:
+            throw new Error('Unsupported operation');
+        }
+    },
+
+    query: function(stub, fn, args) {
+        switch (fn) {
+        case 'get':
+            if (args.length < 1) {
+                throw new Error('get operation must include one argument, a key');
+            }
+            return stub.getState(args[0]);
+        default:
+            throw new Error('Unsupported operation');
+        }
+    }
+});
@@ -213,6 +213,9 @@ func NewCA(name string) *CA {
 	if _, err := db.Exec("CREATE TABLE IF NOT EXISTS AffiliationGroups (row INTEGER PRIMARY KEY, name VARCHAR(64), parent INTEGER, FOREIGN KEY(parent) REFERENCES AffiliationGroups(row))"); err != nil {
 		Panic.Panicln(err)
 	}
+	if _, err := db.Exec("CREATE TABLE IF NOT EXISTS Revocations (row INTEGER PRIMARY KEY, serial VARCHAR(64) UNIQUE, timestamp INTEGER)"); err != nil {
+		Panic.Panicln(err)
+	}
 	ca.db = db
 
 	// read or create signing key pair
@@ -443,6 +446,90 @@ func (ca *CA) readCertificateByHash(hash []byte) ([]byte, error) {
 	return raw, err
 }
 
+// readCertificateOwner returns the id of the user the certificate was issued to
+//
+func (ca *CA) readCertificateOwner(raw []byte) (string, error) {
+	hash := primitives.NewHash()
+	hash.Write(raw)
+
+	var id string
+	err := ca.db.QueryRow("SELECT id FROM Certificates WHERE hash=?", hash.Sum(nil)).Scan(&id)
+
+	return id, err
+}
+
+// revokeCertificate records the revocation of a certificate issued by the CA, which the
+// revocation lists of the CA created from then on list
+//
+func (ca *CA) revokeCertificate(raw []byte) error {
+	cert, err := x509.ParseCertificate(raw)
+	if err != nil {
+		return err
+	}
+	Trace.Println("Revoking certificate " + cert.SerialNumber.String() + ".")
+
+	_, err = ca.db.Exec("INSERT OR IGNORE INTO Revocations (serial, timestamp) VALUES (?, ?)", cert.SerialNumber.String(), time.Now().Unix())
+
+	return err
+}
+
+// isRevoked returns true if the certificate has been revoked
+//
+func (ca *CA) isRevoked(cert *x509.Certificate) bool {
+	var count int
+	ca.db.QueryRow("SELECT COUNT(*) FROM Revocations WHERE serial=?", cert.SerialNumber.String()).Scan(&count)
+
+	return count > 0
+}
+
+// createCRL creates the certificate revocation list of the CA, signed with its key, which lists
+// all the certificates it revoked. The list is written in PEM to <name>.crl, from which it is
+// recorded on the ledger with 'peer network revoke'.
+//
+func (ca *CA) createCRL(name string) ([]byte, error) {
+	Trace.Println("Creating certificate revocation list.")
+
+	rows, err := ca.db.Query("SELECT serial, timestamp FROM Revocations ORDER BY row")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var revoked []pkix.RevokedCertificate
+	for rows.Next() {
+		var serial string
+		var timestamp int64
+		if err = rows.Scan(&serial, &timestamp); err != nil {
+			return nil, err
+		}
+		serialNumber, ok := new(big.Int).SetString(serial, 10)
+		if !ok {
+			return nil, errors.New("invalid serial number " + serial)
+		}
+		revoked = append(revoked, pkix.RevokedCertificate{SerialNumber: serialNumber, RevocationTime: time.Unix(timestamp, 0)})
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	raw, err := ca.cert.CreateCRL(rand.Reader, ca.priv, revoked, now, now.Add(time.Hour*24*90))
+	if err != nil {
+		return nil, err
+	}
+
+	cooked := pem.EncodeToMemory(
+		&pem.Block{
+			Type:  "X509 CRL",
+			Bytes: raw,
+		})
+	if err = ioutil.WriteFile(ca.path+"/"+name+".crl", cooked, 0644); err != nil {
+		return nil, err
+	}
+
+	return raw, nil
+}
+
 func (ca *CA) isValidAffiliation(affiliation string) (bool, error) {
 	Trace.Println("Validating affiliation: " + affiliation)
 
@@ -17,12 +17,22 @@ limitations under the License.
 package ca
 
 import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
 	"io/ioutil"
 	"os"
 	"testing"
+	"time"
 
+	"github.com/golang/protobuf/proto"
 	"github.com/hyperledger/fabric/core/crypto"
 	"github.com/hyperledger/fabric/core/crypto/primitives"
+	"github.com/hyperledger/fabric/core/crypto/utils"
+	"github.com/hyperledger/fabric/core/util"
+	pb "github.com/hyperledger/fabric/membersrvc/protos"
+	"golang.org/x/net/context"
 )
 
 const (
@@ -95,6 +105,175 @@ func TestNewCA(t *testing.T) {
 
 }
 
+func TestRevocation(t *testing.T) {
+	if err := crypto.Init(); err != nil {
+		t.Fatalf("Failed initializing the crypto layer [%s]", err)
+	}
+	LogInit(ioutil.Discard, ioutil.Discard, ioutil.Discard, os.Stderr, os.Stdout)
+
+	eca := NewECA()
+	tca := NewTCA(eca)
+	defer cleanupFiles(eca.path)
+	defer eca.Close()
+	defer tca.Close()
+	ecaa, tcaa := &ECAA{eca}, &TCAA{tca}
+
+	alice, aliceCert := registerTestUser(t, eca, "alice", pb.Role_CLIENT)
+	bob, bobCert := registerTestUser(t, eca, "bob", pb.Role_CLIENT)
+	auditor, _ := registerTestUser(t, eca, "auditor", pb.Role_AUDITOR)
+
+	// a user can only revoke its own certificates and can not publish the list
+	req := &pb.ECertRevokeReq{Id: &pb.Identity{Id: "bob"}, Cert: &pb.Cert{Cert: aliceCert}}
+	req.Sig = signTestRequest(t, bob, req)
+	if _, err := ecaa.RevokeCertificate(context.Background(), req); err == nil {
+		t.Fatal("bob revoked the certificate of alice")
+	}
+	crlReq := &pb.ECertCRLReq{Id: &pb.Identity{Id: "bob"}}
+	crlReq.Sig = signTestRequest(t, bob, crlReq)
+	if _, err := ecaa.PublishCRL(context.Background(), crlReq); err == nil {
+		t.Fatal("bob published the revocation list of the ECA")
+	}
+
+	req = &pb.ECertRevokeReq{Id: &pb.Identity{Id: "alice"}, Cert: &pb.Cert{Cert: aliceCert}}
+	req.Sig = signTestRequest(t, alice, req)
+	if _, err := ecaa.RevokeCertificate(context.Background(), req); err != nil {
+		t.Fatalf("alice failed revoking her certificate: %s", err)
+	}
+	// the revoked enrollment certificate can not sign requests any longer
+	req = &pb.ECertRevokeReq{Id: &pb.Identity{Id: "alice"}, Cert: &pb.Cert{Cert: aliceCert}}
+	req.Sig = signTestRequest(t, alice, req)
+	if _, err := ecaa.RevokeCertificate(context.Background(), req); err == nil {
+		t.Fatal("Request signed with a revoked certificate accepted")
+	}
+
+	crlReq = &pb.ECertCRLReq{Id: &pb.Identity{Id: "auditor"}}
+	crlReq.Sig = signTestRequest(t, auditor, crlReq)
+	if _, err := ecaa.PublishCRL(context.Background(), crlReq); err != nil {
+		t.Fatalf("Failed publishing the revocation list of the ECA: %s", err)
+	}
+	revoked := readTestCRL(t, eca.CA, "eca")
+	if !revoked[serialNumber(t, aliceCert)] || revoked[serialNumber(t, bobCert)] {
+		t.Fatalf("Expected the revocation list of the ECA to list the certificate of alice only, got %v", revoked)
+	}
+
+	// bob revokes his latest set of transaction certificates
+	var latest, previous [][]byte
+	for i := 0; i < 2; i++ {
+		previous = append(previous, createTestTCert(t, tca, "bob", 100))
+		latest = append(latest, createTestTCert(t, tca, "bob", 200))
+	}
+	setReq := &pb.TCertRevokeSetReq{Id: &pb.Identity{Id: "bob"}}
+	setReq.Sig = signTestRequest(t, bob, setReq)
+	if _, err := tcaa.RevokeCertificateSet(context.Background(), setReq); err != nil {
+		t.Fatalf("bob failed revoking his certificate set: %s", err)
+	}
+	tcrlReq := &pb.TCertCRLReq{Id: &pb.Identity{Id: "auditor"}}
+	tcrlReq.Sig = signTestRequest(t, auditor, tcrlReq)
+	if _, err := tcaa.PublishCRL(context.Background(), tcrlReq); err != nil {
+		t.Fatalf("Failed publishing the revocation list of the TCA: %s", err)
+	}
+	revoked = readTestCRL(t, tca.CA, "tca")
+	for i := range latest {
+		if !revoked[serialNumber(t, latest[i])] || revoked[serialNumber(t, previous[i])] {
+			t.Fatalf("Expected the revocation list of the TCA to list the latest set of bob only, got %v", revoked)
+		}
+	}
+
+	// the auditor revokes a certificate of the previous set
+	tReq := &pb.TCertRevokeReq{Id: &pb.Identity{Id: "auditor"}, Cert: &pb.Cert{Cert: previous[0]}}
+	tReq.Sig = signTestRequest(t, auditor, tReq)
+	if _, err := tcaa.RevokeCertificate(context.Background(), tReq); err != nil {
+		t.Fatalf("The auditor failed revoking a certificate of bob: %s", err)
+	}
+	tcrlReq.Sig = signTestRequest(t, auditor, tcrlReq)
+	if _, err := tcaa.PublishCRL(context.Background(), tcrlReq); err != nil {
+		t.Fatalf("Failed publishing the revocation list of the TCA: %s", err)
+	}
+	if revoked = readTestCRL(t, tca.CA, "tca"); !revoked[serialNumber(t, previous[0])] {
+		t.Fatalf("Expected the revocation list of the TCA to list the revoked certificate, got %v", revoked)
+	}
+}
+
+// registerTestUser registers the user with the ECA and issues its enrollment certificate
+func registerTestUser(t *testing.T, eca *ECA, id string, role pb.Role) (*ecdsa.PrivateKey, []byte) {
+	if _, err := eca.registerUserWithErollID(id, id, role); err != nil {
+		t.Fatalf("Failed registering %s: %s", id, err)
+	}
+	priv, err := ecdsa.GenerateKey(primitives.GetDefaultCurve(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec := NewDefaultPeriodCertificateSpec(id, util.GenerateIntUUID(), &priv.PublicKey, x509.KeyUsageDigitalSignature)
+	raw, err := eca.createCertificateFromSpec(spec, time.Now().UnixNano(), nil)
+	if err != nil {
+		t.Fatalf("Failed creating the enrollment certificate of %s: %s", id, err)
+	}
+	return priv, raw
+}
+
+// createTestTCert issues a transaction certificate of the set ts of the user
+func createTestTCert(t *testing.T, tca *TCA, id string, ts int64) []byte {
+	priv, err := ecdsa.GenerateKey(primitives.GetDefaultCurve(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec := NewDefaultPeriodCertificateSpec(id, util.GenerateIntUUID(), &priv.PublicKey, x509.KeyUsageDigitalSignature)
+	raw, err := tca.createCertificateFromSpec(spec, ts, nil)
+	if err != nil {
+		t.Fatalf("Failed creating a transaction certificate of %s: %s", id, err)
+	}
+	return raw
+}
+
+// signTestRequest signs the request, whose signature is not set, as the clients of the CAs do
+func signTestRequest(t *testing.T, priv *ecdsa.PrivateKey, req proto.Message) *pb.Signature {
+	raw, err := proto.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := primitives.NewHash()
+	hash.Write(raw)
+	r, s, err := ecdsa.Sign(rand.Reader, priv, hash.Sum(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	R, _ := r.MarshalText()
+	S, _ := s.MarshalText()
+	return &pb.Signature{Type: pb.CryptoType_ECDSA, R: R, S: S}
+}
+
+// readTestCRL reads the revocation list published by the CA as the peers do, verifying that it
+// is signed by the CA, and returns the serial numbers it revokes
+func readTestCRL(t *testing.T, ca *CA, name string) map[string]bool {
+	cooked, err := ioutil.ReadFile(ca.path + "/" + name + ".crl")
+	if err != nil {
+		t.Fatalf("Failed reading the revocation list: %s", err)
+	}
+	block, _ := pem.Decode(cooked)
+	if block == nil {
+		t.Fatal("Revocation list not in PEM")
+	}
+	crl, err := utils.DERToCRL(block.Bytes)
+	if err != nil {
+		t.Fatalf("Failed parsing the revocation list: %s", err)
+	}
+	if issuer := utils.CRLIssuer(crl); issuer != ca.cert.Subject.String() {
+		t.Fatalf("Revocation list issued by [%s], expected [%s]", issuer, ca.cert.Subject)
+	}
+	if err = ca.cert.CheckCRLSignature(crl); err != nil {
+		t.Fatalf("Invalid signature of the revocation list: %s", err)
+	}
+	return utils.RevokedSerialNumbers(crl)
+}
+
+func serialNumber(t *testing.T, raw []byte) string {
+	cert, err := x509.ParseCertificate(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert.SerialNumber.String()
+}
+
 //cleanup files between and after tests
 func cleanupFiles(path string) error {
 	return os.RemoveAll(path)
@@ -37,6 +37,7 @@ import (
 
 	"github.com/golang/protobuf/proto"
 	"github.com/hyperledger/fabric/core/crypto/primitives"
+	"github.com/hyperledger/fabric/core/util"
 	pb "github.com/hyperledger/fabric/membersrvc/protos"
 	"github.com/spf13/viper"
 	"golang.org/x/net/context"
@@ -288,14 +289,14 @@ func (ecap *ECAP) CreateCertificatePair(ctx context.Context, in *pb.ECertCreateR
 		// create new certificate pair
 		ts := time.Now().Add(-1 * time.Minute).UnixNano()
 
-		spec := NewDefaultCertificateSpecWithCommonName(id, enrollID, skey.(*ecdsa.PublicKey), x509.KeyUsageDigitalSignature, pkix.Extension{Id: ECertSubjectRole, Critical: true, Value: []byte(strconv.Itoa(ecap.eca.readRole(id)))})
+		spec := NewDefaultPeriodCertificateSpecWithCommonName(id, enrollID, util.GenerateIntUUID(), skey.(*ecdsa.PublicKey), x509.KeyUsageDigitalSignature, pkix.Extension{Id: ECertSubjectRole, Critical: true, Value: []byte(strconv.Itoa(ecap.eca.readRole(id)))})
 		sraw, err := ecap.eca.createCertificateFromSpec(spec, ts, nil)
 		if err != nil {
 			Error.Println(err)
 			return nil, err
 		}
 
-		spec = NewDefaultCertificateSpecWithCommonName(id, enrollID, ekey.(*ecdsa.PublicKey), x509.KeyUsageDataEncipherment, pkix.Extension{Id: ECertSubjectRole, Critical: true, Value: []byte(strconv.Itoa(ecap.eca.readRole(id)))})
+		spec = NewDefaultPeriodCertificateSpecWithCommonName(id, enrollID, util.GenerateIntUUID(), ekey.(*ecdsa.PublicKey), x509.KeyUsageDataEncipherment, pkix.Extension{Id: ECertSubjectRole, Critical: true, Value: []byte(strconv.Itoa(ecap.eca.readRole(id)))})
 		eraw, err := ecap.eca.createCertificateFromSpec(spec, ts, nil)
 		if err != nil {
 			ecap.eca.db.Exec("DELETE FROM Certificates Where id=?", id)
@@ -341,8 +342,14 @@ func (ecap *ECAP) ReadCertificatePair(ctx context.Context, in *pb.ECertReadReq)
 		}
 		err = rows.Err()
 	}
+	if err == nil && len(certs) < 2 {
+		err = errors.New("no enrollment certificate pair for " + in.Id.Id)
+	}
+	if err != nil {
+		return nil, err
+	}
 
-	return &pb.CertPair{certs[0], certs[1]}, err
+	return &pb.CertPair{certs[0], certs[1]}, nil
 }
 
 // ReadCertificateByHash reads a single enrollment certificate by hash from the ECA.
@@ -426,18 +433,101 @@ func (ecaa *ECAA) ReadUserSet(ctx context.Context, in *pb.ReadUserSetReq) (*pb.U
 	return &pb.UserSet{users}, err
 }
 
-// RevokeCertificate revokes a certificate from the ECA.  Not yet implemented.
+// RevokeCertificate revokes a certificate from the ECA.  Users can only revoke their own
+// certificates, auditors any certificate.
 //
-func (ecaa *ECAA) RevokeCertificate(context.Context, *pb.ECertRevokeReq) (*pb.CAStatus, error) {
+func (ecaa *ECAA) RevokeCertificate(ctx context.Context, in *pb.ECertRevokeReq) (*pb.CAStatus, error) {
 	Trace.Println("gRPC ECAA:RevokeCertificate")
 
-	return nil, errors.New("ECAA:RevokeCertificate method not (yet) implemented")
+	if in.Id == nil || in.Cert == nil {
+		return nil, errors.New("Invalid request.")
+	}
+	sig := in.Sig
+	in.Sig = nil
+	if err := ecaa.eca.verifyRequest(in.Id.Id, in, sig); err != nil {
+		return nil, err
+	}
+	if err := ecaa.eca.authorizeRevocation(ecaa.eca.CA, in.Id.Id, in.Cert.Cert); err != nil {
+		return nil, err
+	}
+
+	if err := ecaa.eca.revokeCertificate(in.Cert.Cert); err != nil {
+		return nil, err
+	}
+
+	return &pb.CAStatus{Status: pb.CAStatus_OK}, nil
 }
 
-// PublishCRL requests the creation of a certificate revocation list from the ECA.  Not yet implemented.
+// PublishCRL creates the certificate revocation list of the ECA, written to eca.crl in the CA
+// directory.  Only auditors can request it.
 //
-func (ecaa *ECAA) PublishCRL(context.Context, *pb.ECertCRLReq) (*pb.CAStatus, error) {
+func (ecaa *ECAA) PublishCRL(ctx context.Context, in *pb.ECertCRLReq) (*pb.CAStatus, error) {
 	Trace.Println("gRPC ECAA:CreateCRL")
 
-	return nil, errors.New("ECAA:PublishCRL method not (yet) implemented")
+	if in.Id == nil {
+		return nil, errors.New("Invalid request.")
+	}
+	if ecaa.eca.readRole(in.Id.Id)&int(pb.Role_AUDITOR) == 0 {
+		return nil, errors.New("Access denied.")
+	}
+	sig := in.Sig
+	in.Sig = nil
+	if err := ecaa.eca.verifyRequest(in.Id.Id, in, sig); err != nil {
+		return nil, err
+	}
+
+	if _, err := ecaa.eca.createCRL("eca"); err != nil {
+		return nil, err
+	}
+
+	return &pb.CAStatus{Status: pb.CAStatus_OK}, nil
+}
+
+// verifyRequest verifies that the request, whose signature sig has been cleared, is signed
+// with the enrollment certificate of the user id, which must not be revoked
+//
+func (eca *ECA) verifyRequest(id string, in proto.Message, sig *pb.Signature) error {
+	if sig == nil {
+		return errors.New("Signature verification failed.")
+	}
+
+	raw, err := eca.readCertificate(id, x509.KeyUsageDigitalSignature)
+	if err != nil {
+		return err
+	}
+	cert, err := x509.ParseCertificate(raw)
+	if err != nil {
+		return err
+	}
+	if eca.isRevoked(cert) {
+		return errors.New("Enrollment certificate revoked.")
+	}
+
+	r, s := big.NewInt(0), big.NewInt(0)
+	r.UnmarshalText(sig.R)
+	s.UnmarshalText(sig.S)
+
+	hash := primitives.NewHash()
+	raw, _ = proto.Marshal(in)
+	hash.Write(raw)
+	if ecdsa.Verify(cert.PublicKey.(*ecdsa.PublicKey), hash.Sum(nil), r, s) == false {
+		return errors.New("Signature verification failed.")
+	}
+
+	return nil
+}
+
+// authorizeRevocation returns an error unless the user id can revoke the certificate issued
+// by ca: it must have been issued to the user, or the user be an auditor
+//
+func (eca *ECA) authorizeRevocation(ca *CA, id string, raw []byte) error {
+	owner, err := ca.readCertificateOwner(raw)
+	if err != nil {
+		return errors.New("Certificate not issued by the CA.")
+	}
+	if owner != id && eca.readRole(id)&int(pb.Role_AUDITOR) == 0 {
+		return errors.New("Access denied.")
+	}
+
+	return nil
 }
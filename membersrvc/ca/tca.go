@@ -627,23 +627,109 @@ func (tcaa *TCAA) ReadCertificateSets(ctx context.Context, in *pb.TCertReadSetsR
 	return &pb.CertSets{sets}, nil
 }
 
-// RevokeCertificate revokes a certificate from the TCA.  Not yet implemented.
-func (tcaa *TCAA) RevokeCertificate(context.Context, *pb.TCertRevokeReq) (*pb.CAStatus, error) {
+// RevokeCertificate revokes a certificate from the TCA.  Users can only revoke their own
+// certificates, auditors any certificate.
+func (tcaa *TCAA) RevokeCertificate(ctx context.Context, in *pb.TCertRevokeReq) (*pb.CAStatus, error) {
 	Trace.Println("gRPC TCAA:RevokeCertificate")
 
-	return nil, errors.New("TCAA:RevokeCertificate method not (yet) implemented")
+	if in.Id == nil || in.Cert == nil {
+		return nil, errors.New("Invalid request")
+	}
+	sig := in.Sig
+	in.Sig = nil
+	if err := tcaa.tca.eca.verifyRequest(in.Id.Id, in, sig); err != nil {
+		return nil, err
+	}
+	if err := tcaa.tca.eca.authorizeRevocation(tcaa.tca.CA, in.Id.Id, in.Cert.Cert); err != nil {
+		return nil, err
+	}
+
+	if err := tcaa.tca.revokeCertificate(in.Cert.Cert); err != nil {
+		return nil, err
+	}
+
+	return &pb.CAStatus{Status: pb.CAStatus_OK}, nil
 }
 
-// RevokeCertificateSet revokes a certificate set from the TCA.  Not yet implemented.
-func (tcaa *TCAA) RevokeCertificateSet(context.Context, *pb.TCertRevokeSetReq) (*pb.CAStatus, error) {
+// RevokeCertificateSet revokes all the certificates of a certificate set of the user from the
+// TCA, the latest set if no timestamp is given.
+func (tcaa *TCAA) RevokeCertificateSet(ctx context.Context, in *pb.TCertRevokeSetReq) (*pb.CAStatus, error) {
 	Trace.Println("gRPC TCAA:RevokeCertificateSet")
 
-	return nil, errors.New("TCAA:RevokeCertificateSet method not (yet) implemented")
+	if in.Id == nil {
+		return nil, errors.New("Invalid request")
+	}
+	sig := in.Sig
+	in.Sig = nil
+	if err := tcaa.tca.eca.verifyRequest(in.Id.Id, in, sig); err != nil {
+		return nil, err
+	}
+
+	var ts int64
+	if in.Ts != nil {
+		ts = in.Ts.Seconds
+	}
+	if ts == 0 {
+		var latest *int64
+		if err := tcaa.tca.db.QueryRow("SELECT MAX(timestamp) FROM Certificates WHERE id=?", in.Id.Id).Scan(&latest); err != nil {
+			return nil, err
+		}
+		if latest == nil {
+			return nil, errors.New("No certificate set for " + in.Id.Id)
+		}
+		ts = *latest
+	}
+
+	rows, err := tcaa.tca.readCertificates(in.Id.Id, ts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var certs [][]byte
+	for rows.Next() {
+		var raw, kdfKey []byte
+		if err = rows.Scan(&raw, &kdfKey); err != nil {
+			return nil, err
+		}
+		certs = append(certs, raw)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("No certificate set for " + in.Id.Id)
+	}
+
+	for _, raw := range certs {
+		if err = tcaa.tca.revokeCertificate(raw); err != nil {
+			return nil, err
+		}
+	}
+
+	return &pb.CAStatus{Status: pb.CAStatus_OK}, nil
 }
 
-// PublishCRL requests the creation of a certificate revocation list from the TCA.  Not yet implemented.
-func (tcaa *TCAA) PublishCRL(context.Context, *pb.TCertCRLReq) (*pb.CAStatus, error) {
+// PublishCRL creates the certificate revocation list of the TCA, written to tca.crl in the CA
+// directory.  Only auditors can request it.
+func (tcaa *TCAA) PublishCRL(ctx context.Context, in *pb.TCertCRLReq) (*pb.CAStatus, error) {
 	Trace.Println("gRPC TCAA:CreateCRL")
 
-	return nil, errors.New("TCAA:PublichCRL method not (yet) implemented")
+	if in.Id == nil {
+		return nil, errors.New("Invalid request")
+	}
+	if tcaa.tca.eca.readRole(in.Id.Id)&int(pb.Role_AUDITOR) == 0 {
+		return nil, errors.New("Access denied")
+	}
+	sig := in.Sig
+	in.Sig = nil
+	if err := tcaa.tca.eca.verifyRequest(in.Id.Id, in, sig); err != nil {
+		return nil, err
+	}
+
+	if _, err := tcaa.tca.createCRL("tca"); err != nil {
+		return nil, err
+	}
+
+	return &pb.CAStatus{Status: pb.CAStatus_OK}, nil
 }
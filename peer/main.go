@@ -19,11 +19,14 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"encoding/pem"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"runtime"
 	"strconv"
@@ -36,27 +39,37 @@ import (
 
 	google_protobuf "google/protobuf"
 
+	"github.com/golang/protobuf/proto"
 	"github.com/howeyc/gopass"
 	"github.com/op/go-logging"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/grpclog"
 
 	"net/http"
-	_ "net/http/pprof"
 
 	"github.com/hyperledger/fabric/consensus/helper"
 	"github.com/hyperledger/fabric/core"
 	"github.com/hyperledger/fabric/core/chaincode"
+	"github.com/hyperledger/fabric/core/chaincode/devmode"
 	"github.com/hyperledger/fabric/core/comm"
 	"github.com/hyperledger/fabric/core/crypto"
+	"github.com/hyperledger/fabric/core/db"
+	"github.com/hyperledger/fabric/core/debug"
+	"github.com/hyperledger/fabric/core/health"
+	"github.com/hyperledger/fabric/core/ledger"
 	"github.com/hyperledger/fabric/core/ledger/genesis"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt/buckettree"
+	"github.com/hyperledger/fabric/core/metrics"
 	"github.com/hyperledger/fabric/core/peer"
 	"github.com/hyperledger/fabric/core/rest"
 	"github.com/hyperledger/fabric/core/system_chaincode"
+	"github.com/hyperledger/fabric/core/tracing"
 	"github.com/hyperledger/fabric/events/producer"
+	"github.com/hyperledger/fabric/events/webhook"
 	pb "github.com/hyperledger/fabric/protos"
 )
 
@@ -67,6 +80,7 @@ const fabric = "hyperledger"
 const nodeFuncName = "node"
 const networkFuncName = "network"
 const chainFuncName = "chaincode"
+const ledgerFuncName = "ledger"
 const cmdRoot = "core"
 const undefinedParamValue = ""
 
@@ -119,6 +133,343 @@ var nodeStopCmd = &cobra.Command{
 	},
 }
 
+var (
+	rebucketNumBuckets             int
+	rebucketMaxGroupingAtEachLevel int
+	rebucketHashFunction           string
+)
+
+var nodeRebucketCmd = &cobra.Command{
+	Use:   "rebucket",
+	Short: "Rebuilds the bucket tree of the state with new configurations.",
+	Long: `Rebuilds the bucket tree of the state with the configurations passed as flags. The node must be stopped.
+The configurations in 'ledger.state.dataStructure.configs' have to be updated afterwards.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return rebucket()
+	},
+}
+
+var nodeBackupCmd = &cobra.Command{
+	Use:   "backup <backupDir>",
+	Short: "Takes a backup of the DB of the running node.",
+	Long: `Takes a consistent backup of the DB of the running node, and of the DBs of its other chains, into the given directory
+on the file system of the node. The directory is relative to 'peer.db.backupDir'. Backups are incremental, hence, a
+directory can hold several backups.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("Expected the backup directory")
+		}
+		return backup(args[0])
+	},
+}
+
+var nodeRestoreCmd = &cobra.Command{
+	Use:   "restore <backupDir>",
+	Short: "Restores the DB of the node from the latest backup.",
+	Long:  `Replaces the DB of the node, and the DBs of its other chains, with the latest backup in the given directory. The node must be stopped.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("Expected the backup directory")
+		}
+		return restore(args[0])
+	},
+}
+
+var nodeCompactCmd = &cobra.Command{
+	Use:   "compact [columnFamily...]",
+	Short: "Compacts column families of the DB of the running node.",
+	Long: `Compacts the given column families of the DB of the running node, which drops the tombstones of the deleted keys.
+The state and state delta column families are compacted if none is given.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return compact(args)
+	},
+}
+
+var nodePruneCmd = &cobra.Command{
+	Use:   "prune [chainID]",
+	Short: "Prunes the state of the running node.",
+	Long: `Removes the nodes of the state data structure of the given chain that are no longer reachable from the current root,
+e.g. left over from a crash or a state transfer. The state of the default chain is pruned if no chain is given.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) > 1 {
+			return errors.New("Expected at most one chain ID")
+		}
+		chainID := ""
+		if len(args) == 1 {
+			chainID = args[0]
+		}
+		return pruneState(chainID)
+	},
+}
+
+var (
+	batchMaxMessageCount uint32
+	batchMaxBytes        uint64
+	batchTimeout         time.Duration
+)
+
+var nodeBatchPolicyCmd = &cobra.Command{
+	Use:   "batchpolicy",
+	Short: "Shows or changes the batch policy of the running node.",
+	Long: `Shows the batch policy by which the consensus plugin of the running node forms blocks, after replacing the
+values passed as flags. A block is cut once it holds maxMessageCount transactions, once the next transaction would take
+it over maxBytes (0 is no limit), or once timeout has elapsed since its first transaction.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return batchPolicy(cmd.Flags())
+	},
+}
+
+var nodeAdmissionCmd = &cobra.Command{
+	Use:   "admission",
+	Short: "Shows the admission control counters of the running node.",
+	Long: `Shows, for the submit (deploy, invoke) and query requests of the clients of the running node, the number of
+requests admitted and rejected for exceeding the limits of peer.admission, and the number of requests in flight.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return admissionStats()
+	},
+}
+
+var nodeACLCmd = &cobra.Command{
+	Use:   "acl [policy.json]",
+	Short: "Shows or replaces the access control list of the running node.",
+	Long: `Shows, in JSON, the access control list of the client APIs of the running node: the role (reader, submitter,
+admin) of each client identity and the lowest role allowed to call each API (deploy, invoke, query, admin, events).
+The list is first replaced by the one of the given JSON file if any, until the node restarts.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) > 1 {
+			return errors.New("Expected at most the JSON file of the access control list")
+		}
+		return aclPolicy(args)
+	},
+}
+
+var (
+	debugChainID string
+	debugSeconds uint32
+	debugLevel   int32
+	debugOutput  string
+	debugCFs     []string
+)
+
+var nodeDebugCmd = &cobra.Command{
+	Use:   "debug",
+	Short: "Diagnoses the running node.",
+	Long:  `Collects from the running node what is needed to diagnose commits that do not progress.`,
+}
+
+var nodeDebugProfileCmd = &cobra.Command{
+	Use:   "profile <name>",
+	Short: "Writes a pprof profile of the running node.",
+	Long: `Writes the pprof profile of the given name (goroutine, heap, threadcreate, block, cpu or trace) of the running node
+to the output file, or to stdout. The cpu profile and the trace are recorded for the given number of seconds. With
+--debug=2, the goroutine profile is a dump of the stack of each goroutine.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("Expected the name of the profile")
+		}
+		return debugProfile(args[0])
+	},
+}
+
+var nodeDebugLedgerCmd = &cobra.Command{
+	Use:   "ledger",
+	Short: "Shows the transaction-batch in progress of the running node.",
+	Long: `Shows the size of the blockchain of the running node, the time its last block was added and, for the transaction-batch
+in progress, the transaction being executed, the number of transactions executed and of keys changed in memory.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return debugLedger()
+	},
+}
+
+var nodeDebugDBCmd = &cobra.Command{
+	Use:   "db [property...]",
+	Short: "Dumps rocksdb properties of the running node.",
+	Long: `Dumps the values of the given rocksdb properties, such as rocksdb.cfstats, of the column families of the DB of the
+running node. Without properties, dumps the ones that tell whether flushes, compactions or writes are stalled.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return debugDB(args)
+	},
+}
+
+var nodeLogLevelCmd = &cobra.Command{
+	Use:   "loglevel [<module> <level>]",
+	Short: "Shows or changes the logging levels of the running node.",
+	Long: `Shows the default logging level of the running node and the levels of the modules that have one of their
+own, after setting the level of the given module, until the node restarts. The level of a module, such as state,
+buckettree, db or consensus, also applies to its submodules (consensus/obcpbft). The module "" is the default level.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 0 && len(args) != 2 {
+			return errors.New("Expected either no arguments or a module and a level")
+		}
+		return logLevel(args)
+	},
+}
+
+var nodeStateDiffCmd = &cobra.Command{
+	Use:   "statediff <peerAddress>",
+	Short: "Reports the state keys whose values differ from another peer.",
+	Long: `Compares the world state of the running node with the world state of the peer at the given address and prints
+the keys whose values differ. Only the subtrees of the state tree whose crypto-hashes differ are transferred.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("Expected the address of the peer to compare with")
+		}
+		return stateDiff(args[0])
+	},
+}
+
+var ledgerCmd = &cobra.Command{
+	Use:   ledgerFuncName,
+	Short: fmt.Sprintf("%s specific commands.", ledgerFuncName),
+	Long:  fmt.Sprintf("%s specific commands.", ledgerFuncName),
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		core.LoggingInit(ledgerFuncName)
+	},
+}
+
+var ledgerScrubCmd = &cobra.Command{
+	Use:   "scrub",
+	Short: "Reports the corrupt records of the DB of the node.",
+	Long: `Reads all the records of the DB of the node and reports those whose checksum does not match or that cannot be decrypted.
+The node must be stopped.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return scrub()
+	},
+}
+
+var ledgerChainID string
+
+var ledgerCheckpointsCmd = &cobra.Command{
+	Use:   "checkpoints",
+	Short: "Lists the state checkpoints of the node.",
+	Long:  `Lists the block number, block hash and state hash of the state checkpoints of the node. The node must be stopped.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return listCheckpoints()
+	},
+}
+
+var ledgerRestoreCheckpointCmd = &cobra.Command{
+	Use:   "restore-checkpoint <blockNumber>",
+	Short: "Rebuilds the state of the node from a checkpoint.",
+	Long: `Rebuilds the state of the node from the checkpoint of the given block and rolls it forward to the last block with the
+retained state deltas. The state is left unchanged if the result does not match the state hash of the last block.
+The node must be stopped.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("Expected the block number of the checkpoint")
+		}
+		blockNumber, err := strconv.ParseUint(args[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("Invalid block number [%s]", args[0])
+		}
+		return restoreCheckpoint(blockNumber)
+	},
+}
+
+var (
+	verifyFrom        uint64
+	verifyTo          int64
+	verifyReplayState bool
+)
+
+var ledgerVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Verifies the hash chain and the state hashes of the blockchain of the node.",
+	Long: `Re-hashes the blocks of the node, checks that each of them links to the hash of its previous block and, optionally,
+replays the retained state deltas backwards from the current state to check the state hash of each block. Reports the
+first inconsistency found. The node must be stopped.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return verifyLedger()
+	},
+}
+
+var (
+	ledgerUsr string
+	ledgerHex bool
+)
+
+var ledgerHeightCmd = &cobra.Command{
+	Use:   "height",
+	Short: "Prints the height of the blockchain of the running node.",
+	Long:  `Prints the height of the blockchain of the running node and the hashes of its last two blocks.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return ledgerHeight()
+	},
+}
+
+var ledgerBlockCmd = &cobra.Command{
+	Use:   "block <blockNumber>",
+	Short: "Prints a block of the running node as JSON.",
+	Long: `Prints the block of the given number of the running node as JSON, with the payloads and chaincode IDs of the
+non-confidential transactions decoded and the code packages of the deployments left out.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("Expected the block number")
+		}
+		blockNumber, err := strconv.ParseUint(args[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("Invalid block number [%s]", args[0])
+		}
+		return ledgerBlock(blockNumber)
+	},
+}
+
+var ledgerTxCmd = &cobra.Command{
+	Use:   "tx <uuid>",
+	Short: "Prints a committed transaction of the running node as JSON.",
+	Long:  `Prints the committed transaction of the given uuid as JSON, decoded as for the block command.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("Expected the uuid of the transaction")
+		}
+		return ledgerTx(args[0])
+	},
+}
+
+var ledgerStateCmd = &cobra.Command{
+	Use:   "state",
+	Short: "Reads the world state of the running node.",
+	Long:  `Reads the committed world state of the running node.`,
+}
+
+var ledgerStateGetCmd = &cobra.Command{
+	Use:   "get <chaincodeID> <key>",
+	Short: "Prints the committed value of a key of a chaincode.",
+	Long:  `Prints the committed value of the key of the chaincode, as stored in the world state, i.e. encrypted if the chaincode is confidential.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 2 {
+			return errors.New("Expected the chaincode ID and the key")
+		}
+		return ledgerStateGet(args[0], args[1])
+	},
+}
+
+var ledgerStateDeltaCmd = &cobra.Command{
+	Use:   "statedelta <blockNumber>",
+	Short: "Prints the changes made to the world state by a block.",
+	Long: `Prints the keys set and deleted by the block of the given number, sorted by chaincode and key. The running node
+retains the state deltas of its last ledger.state.deltaHistorySize blocks only.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("Expected the block number")
+		}
+		blockNumber, err := strconv.ParseUint(args[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("Invalid block number [%s]", args[0])
+		}
+		return ledgerStateDelta(blockNumber)
+	},
+}
+
+var ledgerStateHashCmd = &cobra.Command{
+	Use:   "statehash",
+	Short: "Prints the hash of the committed world state of the running node.",
+	Long:  `Prints the crypto-hash of the committed world state of the running node and the crypto-hashes of the children of the root of the state tree.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return ledgerStateHash()
+	},
+}
+
 var networkCmd = &cobra.Command{
 	Use:   networkFuncName,
 	Short: fmt.Sprintf("%s specific commands.", networkFuncName),
@@ -155,6 +506,63 @@ var networkLoginCmd = &cobra.Command{
 // 	},
 // }
 
+var networkDiscoveryCmd = &cobra.Command{
+	Use:   "discovery",
+	Short: "Lists the peers the running node reconnects to.",
+	Long:  `Returns the addresses of the peers the running node reconnects to when disconnected, and of the peers it bans.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return networkDiscovery("", "")
+	},
+}
+
+var networkAddCmd = &cobra.Command{
+	Use:   "add <address>",
+	Short: "Connects the running node to a peer.",
+	Long:  `Adds the address (host:port) of a peer to the discovery list of the running node, which connects to it, lifting its ban if any.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("Expected the peer address")
+		}
+		return networkDiscovery("add", args[0])
+	},
+}
+
+var networkRemoveCmd = &cobra.Command{
+	Use:   "remove <address>",
+	Short: "Stops the running node from reconnecting to a peer.",
+	Long:  `Removes the address (host:port) of a peer from the discovery list of the running node, which no longer reconnects to it. The peer is added back if discovered again.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("Expected the peer address")
+		}
+		return networkDiscovery("remove", args[0])
+	},
+}
+
+var networkBanCmd = &cobra.Command{
+	Use:   "ban <address>",
+	Short: "Bans a peer from the running node.",
+	Long:  `Bans the address (host:port) of a peer, the running node disconnects from it and refuses its connections until it is added again.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("Expected the peer address")
+		}
+		return networkDiscovery("ban", args[0])
+	},
+}
+
+var networkRevokeCmd = &cobra.Command{
+	Use:   "revoke <crl file>",
+	Short: "Records a certificate revocation list on the ledger.",
+	Long:  `Submits a config transaction recording the certificate revocation list (PEM or DER) issued by the ECA or the TCA, the peers then reject the transactions signed by the certificates it revokes. The list replaces the one recorded for the same CA if it is more recent.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("Expected the certificate revocation list file")
+		}
+		return networkRevoke(args[0])
+	},
+}
+
 var networkListCmd = &cobra.Command{
 	Use:     "list",
 	Aliases: []string{"ls"},
@@ -170,6 +578,11 @@ var (
 	loginPW string
 )
 
+// revocation related variables.
+var (
+	revokeUsr string
+)
+
 // Chaincode-related variables.
 var (
 	chaincodeLang     string
@@ -180,6 +593,20 @@ var (
 	chaincodeUsr      string
 	chaincodeQueryRaw bool
 	chaincodeQueryHex bool
+	chaincodeReadOnly bool
+	chaincodeEndorse  bool
+
+	chaincodeExpiryHeight uint64
+	chaincodeExpiry       time.Duration
+
+	chaincodeTimeout     int32
+	chaincodeMemoryLimit int64
+	chaincodeCPUShares   int64
+	chaincodePolicy      string
+	chaincodeVersion     string
+	chaincodeStatePeers  []string
+
+	chaincodeReloadInterval time.Duration
 )
 
 var chaincodeCmd = &cobra.Command{
@@ -203,6 +630,47 @@ var chaincodeDeployCmd = &cobra.Command{
 	},
 }
 
+var chaincodeInstallCmd = &cobra.Command{
+	Use:       "install",
+	Short:     fmt.Sprintf("Install the specified %s on the local peer.", chainFuncName),
+	Long:      fmt.Sprintf(`Install the package of the specified %s on the local peer, without deploying it.`, chainFuncName),
+	ValidArgs: []string{"1"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return chaincodeInstall(cmd, args)
+	},
+}
+
+var chaincodeInstantiateCmd = &cobra.Command{
+	Use:       "instantiate",
+	Short:     fmt.Sprintf("Instantiate the specified installed %s on the network.", chainFuncName),
+	Long:      fmt.Sprintf(`Instantiate on the network the %s installed on the peers under the given name.`, chainFuncName),
+	ValidArgs: []string{"1"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return chaincodeInstantiate(cmd, args)
+	},
+}
+
+var chaincodeUpgradeCmd = &cobra.Command{
+	Use:       "upgrade",
+	Short:     fmt.Sprintf("Upgrade the specified %s to the code at the given path.", chainFuncName),
+	Long:      fmt.Sprintf(`Upgrade the specified %s to the code at the given path, keeping its state.`, chainFuncName),
+	ValidArgs: []string{"1"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return chaincodeUpgrade(cmd, args)
+	},
+}
+
+var chaincodeDevCmd = &cobra.Command{
+	Use:   "dev",
+	Short: fmt.Sprintf("Run the specified %s against a peer in development mode, relaunching it when its code changes.", chainFuncName),
+	Long: fmt.Sprintf(`Build and run the Go %s at the given path under the given name against a peer in development mode,
+and rebuild and relaunch it whenever its code changes. The relaunched %s keeps its state and does not need to be deployed again.`, chainFuncName, chainFuncName),
+	ValidArgs: []string{"1"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return chaincodeDev(cmd, args)
+	},
+}
+
 var chaincodeInvokeCmd = &cobra.Command{
 	Use:       "invoke",
 	Short:     fmt.Sprintf("Invoke the specified %s.", chainFuncName),
@@ -223,6 +691,16 @@ var chaincodeQueryCmd = &cobra.Command{
 	},
 }
 
+var chaincodeSimulateCmd = &cobra.Command{
+	Use:       "simulate",
+	Short:     fmt.Sprintf("Simulate an invoke of the specified %s.", chainFuncName),
+	Long:      fmt.Sprintf(`Simulate an invoke of the specified %s against the committed state, without submitting a transaction.`, chainFuncName),
+	ValidArgs: []string{"1"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return chaincodeSimulate(cmd, args)
+	},
+}
+
 func main() {
 	// For environment variables.
 	viper.SetEnvPrefix(cmdRoot)
@@ -275,8 +753,53 @@ func main() {
 	nodeStopCmd.Flags().StringVarP(&stopPidFile, "stop-peer-pid-file", "", viper.GetString("peer.fileSystemPath"), "Location of peer pid local file, for forces kill")
 	nodeCmd.AddCommand(nodeStopCmd)
 
+	nodeRebucketCmd.Flags().IntVarP(&rebucketNumBuckets, "numBuckets", "", 0, "New number of buckets of the state bucket tree")
+	nodeRebucketCmd.Flags().IntVarP(&rebucketMaxGroupingAtEachLevel, "maxGroupingAtEachLevel", "", 0, "New grouping of buckets at each level of the state bucket tree")
+	nodeRebucketCmd.Flags().StringVarP(&rebucketHashFunction, "bucketHashFunction", "", undefinedParamValue, "New function used for assigning the keys to the buckets")
+	nodeCmd.AddCommand(nodeRebucketCmd)
+	nodeCmd.AddCommand(nodeStateDiffCmd)
+	nodeCmd.AddCommand(nodeBackupCmd)
+	nodeCmd.AddCommand(nodeRestoreCmd)
+	nodeCmd.AddCommand(nodeCompactCmd)
+	nodeCmd.AddCommand(nodePruneCmd)
+	nodeBatchPolicyCmd.Flags().Uint32VarP(&batchMaxMessageCount, "maxMessageCount", "", 0, "Number of transactions after which a block is cut")
+	nodeBatchPolicyCmd.Flags().Uint64VarP(&batchMaxBytes, "maxBytes", "", 0, "Maximum cumulative size in bytes of the transactions of a block, 0 is no limit")
+	nodeBatchPolicyCmd.Flags().DurationVarP(&batchTimeout, "timeout", "", 0, "Time a block waits for more transactions after its first one")
+	nodeCmd.AddCommand(nodeBatchPolicyCmd)
+	nodeCmd.AddCommand(nodeAdmissionCmd)
+	nodeCmd.AddCommand(nodeACLCmd)
+	nodeCmd.AddCommand(nodeLogLevelCmd)
+	nodeDebugCmd.PersistentFlags().StringVarP(&debugChainID, "chainID", "", "", "ID of the chain, the default chain if empty")
+	nodeDebugProfileCmd.Flags().Uint32VarP(&debugSeconds, "seconds", "", 30, "Duration of the cpu profile and of the trace")
+	nodeDebugProfileCmd.Flags().Int32VarP(&debugLevel, "debug", "", 0, "Format of the profile: 0 for the binary format of pprof, 1 for text, 2 for the goroutine dump")
+	nodeDebugProfileCmd.Flags().StringVarP(&debugOutput, "output", "o", "", "File the profile is written to, stdout if empty")
+	nodeDebugDBCmd.Flags().StringSliceVarP(&debugCFs, "columnFamily", "", nil, "Column families, all of them if none is given")
+	nodeDebugCmd.AddCommand(nodeDebugProfileCmd)
+	nodeDebugCmd.AddCommand(nodeDebugLedgerCmd)
+	nodeDebugCmd.AddCommand(nodeDebugDBCmd)
+	nodeCmd.AddCommand(nodeDebugCmd)
+
 	mainCmd.AddCommand(nodeCmd)
 
+	ledgerCmd.AddCommand(ledgerScrubCmd)
+	for _, cmd := range []*cobra.Command{ledgerCheckpointsCmd, ledgerRestoreCheckpointCmd, ledgerVerifyCmd} {
+		cmd.Flags().StringVarP(&ledgerChainID, "chainID", "", "", "ID of the chain, the default chain if empty")
+	}
+	ledgerCmd.AddCommand(ledgerCheckpointsCmd)
+	ledgerCmd.AddCommand(ledgerRestoreCheckpointCmd)
+	ledgerVerifyCmd.Flags().Uint64VarP(&verifyFrom, "from", "", 0, "Number of the first block to verify")
+	ledgerVerifyCmd.Flags().Int64VarP(&verifyTo, "to", "", -1, "Number of the last block to verify, the last block of the blockchain if negative")
+	ledgerVerifyCmd.Flags().BoolVarP(&verifyReplayState, "replayState", "", false, "If true, replay the state deltas to verify the state hash of each block")
+	ledgerCmd.AddCommand(ledgerVerifyCmd)
+	for _, cmd := range []*cobra.Command{ledgerHeightCmd, ledgerBlockCmd, ledgerTxCmd, ledgerStateCmd, ledgerStateDeltaCmd, ledgerStateHashCmd} {
+		cmd.PersistentFlags().StringVarP(&ledgerUsr, "username", "u", undefinedParamValue, "Username reading the ledger when security is enabled")
+		ledgerCmd.AddCommand(cmd)
+	}
+	ledgerStateGetCmd.Flags().BoolVarP(&ledgerHex, "hex", "x", false, "If true, print the value in hexadecimal")
+	ledgerStateDeltaCmd.Flags().BoolVarP(&ledgerHex, "hex", "x", false, "If true, print the values in hexadecimal")
+	ledgerStateCmd.AddCommand(ledgerStateGetCmd)
+	mainCmd.AddCommand(ledgerCmd)
+
 	// Set the flags on the login command.
 	networkLoginCmd.PersistentFlags().StringVarP(&loginPW, "password", "p", undefinedParamValue, "The password for user. You will be requested to enter the password if this flag is not specified.")
 
@@ -286,6 +809,13 @@ func main() {
 	// mainCmd.AddCommand(vmCmd)
 
 	networkCmd.AddCommand(networkListCmd)
+	networkCmd.AddCommand(networkDiscoveryCmd)
+	networkCmd.AddCommand(networkAddCmd)
+	networkCmd.AddCommand(networkRemoveCmd)
+	networkCmd.AddCommand(networkBanCmd)
+
+	networkRevokeCmd.Flags().StringVarP(&revokeUsr, "username", "u", undefinedParamValue, "Username submitting the revocation list when security is enabled")
+	networkCmd.AddCommand(networkRevokeCmd)
 
 	mainCmd.AddCommand(networkCmd)
 
@@ -297,10 +827,37 @@ func main() {
 
 	chaincodeQueryCmd.Flags().BoolVarP(&chaincodeQueryRaw, "raw", "r", false, "If true, output the query value as raw bytes, otherwise format as a printable string")
 	chaincodeQueryCmd.Flags().BoolVarP(&chaincodeQueryHex, "hex", "x", false, "If true, output the query value byte array in hexadecimal. Incompatible with --raw")
+	chaincodeQueryCmd.Flags().BoolVar(&chaincodeReadOnly, "readonly", false, "If true, query the committed state of the validator without a transaction, and show the block height and the validator that served it")
+
+	chaincodeInvokeCmd.Flags().BoolVar(&chaincodeEndorse, "endorse", false, "If true, have the validator simulate the invoke and submit its endorsed changes, which are applied if the state the invoke read is unchanged")
+	chaincodeInvokeCmd.Flags().Uint64VarP(&chaincodeExpiryHeight, "expiry-height", "", 0, "Block height at which the transaction expires if it was not executed yet, never if 0")
+	chaincodeInvokeCmd.Flags().DurationVarP(&chaincodeExpiry, "expiry", "", 0, "Time after which the transaction expires if it was not executed yet, never if 0")
+
+	chaincodeDeployCmd.Flags().Int32VarP(&chaincodeTimeout, "timeout", "", 0, "Timeout in milliseconds of the execution of a transaction or query, chaincode.executetimeout of the peers if 0")
+	chaincodeDeployCmd.Flags().Int64VarP(&chaincodeMemoryLimit, "memory", "", 0, "Memory limit in bytes of the chaincode container, vm.docker.resources.memory of the peers if 0")
+	chaincodeDeployCmd.Flags().Int64VarP(&chaincodeCPUShares, "cpu-shares", "", 0, "Relative CPU weight of the chaincode container, vm.docker.resources.cpuShares of the peers if 0")
+
+	chaincodeInstantiateCmd.Flags().Int32VarP(&chaincodeTimeout, "timeout", "", 0, "Timeout in milliseconds of the execution of a transaction or query, chaincode.executetimeout of the peers if 0")
+	chaincodeInstantiateCmd.Flags().Int64VarP(&chaincodeMemoryLimit, "memory", "", 0, "Memory limit in bytes of the chaincode container, vm.docker.resources.memory of the peers if 0")
+	chaincodeInstantiateCmd.Flags().Int64VarP(&chaincodeCPUShares, "cpu-shares", "", 0, "Relative CPU weight of the chaincode container, vm.docker.resources.cpuShares of the peers if 0")
+	chaincodeInstantiateCmd.Flags().StringVarP(&chaincodePolicy, "policy", "", "", "Endorsement policy recorded with the instantiation of the chaincode")
+	chaincodeInstantiateCmd.Flags().StringSliceVarP(&chaincodeStatePeers, "state-peers", "", nil, "Enrollment IDs of the validating peers authorized to read the state of the chaincode, which is then encrypted")
+
+	chaincodeUpgradeCmd.Flags().StringVarP(&chaincodeVersion, "version", "", "", "Name of the installed chaincode to upgrade to, instead of the path of its code")
+	chaincodeUpgradeCmd.Flags().Int32VarP(&chaincodeTimeout, "timeout", "", 0, "Timeout in milliseconds of the execution of a transaction or query, chaincode.executetimeout of the peers if 0")
+	chaincodeUpgradeCmd.Flags().Int64VarP(&chaincodeMemoryLimit, "memory", "", 0, "Memory limit in bytes of the chaincode container, vm.docker.resources.memory of the peers if 0")
+	chaincodeUpgradeCmd.Flags().Int64VarP(&chaincodeCPUShares, "cpu-shares", "", 0, "Relative CPU weight of the chaincode container, vm.docker.resources.cpuShares of the peers if 0")
+
+	chaincodeDevCmd.Flags().DurationVarP(&chaincodeReloadInterval, "interval", "", time.Second, "Interval at which the code of the chaincode is checked for changes")
 
 	chaincodeCmd.AddCommand(chaincodeDeployCmd)
+	chaincodeCmd.AddCommand(chaincodeInstallCmd)
+	chaincodeCmd.AddCommand(chaincodeInstantiateCmd)
+	chaincodeCmd.AddCommand(chaincodeUpgradeCmd)
+	chaincodeCmd.AddCommand(chaincodeDevCmd)
 	chaincodeCmd.AddCommand(chaincodeInvokeCmd)
 	chaincodeCmd.AddCommand(chaincodeQueryCmd)
+	chaincodeCmd.AddCommand(chaincodeSimulateCmd)
 
 	mainCmd.AddCommand(chaincodeCmd)
 
@@ -323,24 +880,28 @@ func createEventHubServer() (net.Listener, *grpc.Server, error) {
 	var grpcServer *grpc.Server
 	var err error
 	if peer.ValidatorEnabled() {
-		lis, err = net.Listen("tcp", viper.GetString("peer.validator.events.address"))
+		lis, err = comm.Listen(viper.GetString("peer.validator.events.address"))
 		if err != nil {
 			return nil, nil, fmt.Errorf("failed to listen: %v", err)
 		}
 
 		//TODO - do we need different SSL material for events ?
-		var opts []grpc.ServerOption
-		if comm.TLSEnabled() {
-			creds, err := credentials.NewServerTLSFromFile(viper.GetString("peer.tls.cert.file"), viper.GetString("peer.tls.key.file"))
-			if err != nil {
-				return nil, nil, fmt.Errorf("Failed to generate credentials %v", err)
-			}
-			opts = []grpc.ServerOption{grpc.Creds(creds)}
+		opts, err := comm.ServerOptions()
+		if err != nil {
+			return nil, nil, fmt.Errorf("Failed to generate credentials %v", err)
 		}
 
 		grpcServer = grpc.NewServer(opts...)
 		ehServer := producer.NewEventsServer(uint(viper.GetInt("peer.validator.events.buffersize")), viper.GetInt("peer.validator.events.timeout"))
 		pb.RegisterEventsServer(grpcServer, ehServer)
+		producer.SetConsumerBufferSize(viper.GetInt("peer.validator.events.consumerbuffersize"))
+
+		// the consumers may ask for the committed blocks to be replayed
+		peerLedger, err := ledger.GetLedger()
+		if err != nil {
+			return nil, nil, fmt.Errorf("Failed to get the ledger for the event hub: %v", err)
+		}
+		producer.SetReplaySource(peerLedger)
 	}
 	return lis, grpcServer, err
 }
@@ -404,6 +965,7 @@ func serve(args []string) error {
 	if err := peer.CacheConfiguration(); err != nil {
 		return err
 	}
+	tracing.InitFromConfig()
 
 	//register all system chaincodes. This just registers chaincodes, they must be
 	//still be deployed and launched
@@ -421,7 +983,7 @@ func serve(args []string) error {
 		listenAddr = peerEndpoint.Address
 	}
 
-	lis, err := net.Listen("tcp", listenAddr)
+	lis, err := comm.Listen(listenAddr)
 	if err != nil {
 		grpclog.Fatalf("Failed to listen: %v", err)
 	}
@@ -434,13 +996,9 @@ func serve(args []string) error {
 	logger.Info("Security enabled status: %t", core.SecurityEnabled())
 	logger.Info("Privacy enabled status: %t", viper.GetBool("security.privacy"))
 
-	var opts []grpc.ServerOption
-	if comm.TLSEnabled() {
-		creds, err := credentials.NewServerTLSFromFile(viper.GetString("peer.tls.cert.file"), viper.GetString("peer.tls.key.file"))
-		if err != nil {
-			grpclog.Fatalf("Failed to generate credentials %v", err)
-		}
-		opts = []grpc.ServerOption{grpc.Creds(creds)}
+	opts, err := comm.ServerOptions()
+	if err != nil {
+		grpclog.Fatalf("Failed to generate credentials %v", err)
 	}
 
 	grpcServer := grpc.NewServer(opts...)
@@ -483,11 +1041,22 @@ func serve(args []string) error {
 	pb.RegisterPeerServer(grpcServer, peerServer)
 
 	// Register the Admin server
-	pb.RegisterAdminServer(grpcServer, core.NewAdminServer())
+	pb.RegisterAdminServer(grpcServer, core.NewAdminServerWithPeer(peerServer))
+
+	if compactionScheduler := db.NewCompactionSchedulerFromConfig(); compactionScheduler != nil {
+		compactionScheduler.Start()
+		defer compactionScheduler.Stop()
+	}
+
+	if pruningScheduler := ledger.NewStatePruningSchedulerFromConfig(); pruningScheduler != nil {
+		pruningScheduler.Start()
+		defer pruningScheduler.Stop()
+	}
 
 	// Register Devops server
 	serverDevops := core.NewDevopsServer(peerServer)
 	pb.RegisterDevopsServer(grpcServer, serverDevops)
+	pb.RegisterDevopsV2Server(grpcServer, core.NewDevopsV2Server(serverDevops))
 
 	// Register the ServerOpenchain server
 	serverOpenchain, err := rest.NewOpenchainServerWithPeerInfo(peerServer)
@@ -496,7 +1065,7 @@ func serve(args []string) error {
 		return err
 	}
 
-	pb.RegisterOpenchainServer(grpcServer, serverOpenchain)
+	pb.RegisterOpenchainServer(grpcServer, rest.NewOpenchainGRPCServer(serverOpenchain))
 
 	// Create and register the REST service if configured
 	if viper.GetBool("rest.enabled") {
@@ -532,13 +1101,54 @@ func serve(args []string) error {
 	//start the event hub server
 	if ehubGrpcServer != nil && ehubLis != nil {
 		go ehubGrpcServer.Serve(ehubLis)
+
+		if viper.GetBool("peer.validator.events.webhooks.enabled") {
+			if err := startWebhooks(); err != nil {
+				logger.Error(fmt.Sprintf("Error starting the webhooks: %s", err))
+			}
+		}
+	}
+
+	// the metrics and the health endpoints share a server if they share a listen address
+	muxes := make(map[string]*http.ServeMux)
+	getMux := func(listenAddress string) *http.ServeMux {
+		if _, ok := muxes[listenAddress]; !ok {
+			muxes[listenAddress] = http.NewServeMux()
+		}
+		return muxes[listenAddress]
+	}
+	if viper.GetBool("peer.metrics.enabled") {
+		metricsListenAddress := viper.GetString("peer.metrics.listenAddress")
+		logger.Info(fmt.Sprintf("Serving metrics with listenAddress = %s", metricsListenAddress))
+		getMux(metricsListenAddress).Handle("/metrics", metrics.Handler())
+	}
+	if viper.GetBool("peer.health.enabled") {
+		if err := registerHealthChecks(peerServer, ehubGrpcServer != nil); err != nil {
+			return err
+		}
+		healthListenAddress := viper.GetString("peer.health.listenAddress")
+		logger.Info(fmt.Sprintf("Serving health checks with listenAddress = %s", healthListenAddress))
+		health.Register(getMux(healthListenAddress))
+	}
+	for listenAddress, mux := range muxes {
+		go func(listenAddress string, mux *http.ServeMux) {
+			if httpErr := http.ListenAndServe(listenAddress, mux); httpErr != nil {
+				logger.Error(fmt.Sprintf("Error starting HTTP server with listenAddress = %s: %s", listenAddress, httpErr))
+			}
+		}(listenAddress, mux)
 	}
 
 	if viper.GetBool("peer.profile.enabled") {
 		go func() {
 			profileListenAddress := viper.GetString("peer.profile.listenAddress")
 			logger.Info(fmt.Sprintf("Starting profiling server with listenAddress = %s", profileListenAddress))
-			if profileErr := http.ListenAndServe(profileListenAddress, nil); profileErr != nil {
+			var profileErr error
+			if comm.TLSEnabled() {
+				profileErr = http.ListenAndServeTLS(profileListenAddress, viper.GetString("peer.tls.cert.file"), viper.GetString("peer.tls.key.file"), debug.Handler())
+			} else {
+				profileErr = http.ListenAndServe(profileListenAddress, debug.Handler())
+			}
+			if profileErr != nil {
 				logger.Error(fmt.Sprintf("Error starting profiler: %s", profileErr))
 			}
 		}()
@@ -548,6 +1158,50 @@ func serve(args []string) error {
 	return <-serve
 }
 
+// registerHealthChecks registers the checks served at /healthz and /readyz: the DB, the
+// progress of the commits and the event hub for the liveness, and the connections to the
+// validating peers for the readiness
+func registerHealthChecks(peerServer *peer.PeerImpl, eventHub bool) error {
+	peerLedger, err := ledger.GetLedger()
+	if err != nil {
+		return fmt.Errorf("Error getting the ledger: %s", err)
+	}
+	health.RegisterLiveness("db", peerLedger.CheckHealth)
+	if maxCommitAge := viper.GetDuration("peer.health.maxCommitAge"); maxCommitAge > 0 {
+		health.RegisterLiveness("commit", func() error {
+			return peerLedger.CheckCommitProgress(maxCommitAge)
+		})
+	}
+	if maxEventStall := viper.GetDuration("peer.health.maxEventStall"); eventHub && maxEventStall > 0 {
+		health.RegisterLiveness("eventhub", func() error {
+			return producer.CheckHealth(maxEventStall)
+		})
+	}
+	minValidatorConnections := viper.GetInt("peer.health.minValidatorConnections")
+	health.RegisterReadiness("consensus", func() error {
+		return peerServer.CheckValidatorConnections(minValidatorConnections)
+	})
+	return nil
+}
+
+// startWebhooks posts the events of the event hub to the configured HTTP endpoints
+func startWebhooks() error {
+	var endpoints []webhook.Endpoint
+	if err := viper.UnmarshalKey("peer.validator.events.webhooks.endpoints", &endpoints); err != nil {
+		return fmt.Errorf("Invalid webhook endpoints: %s", err)
+	}
+	if len(endpoints) == 0 {
+		return errors.New("No webhook endpoint configured")
+	}
+	adapter := webhook.NewAdapter(endpoints,
+		viper.GetInt("peer.validator.events.webhooks.retries"),
+		viper.GetDuration("peer.validator.events.webhooks.retryinterval"),
+		viper.GetDuration("peer.validator.events.webhooks.timeout"),
+		viper.GetString("peer.validator.events.webhooks.consumerid"))
+	logger.Info("Posting the events to %d webhook endpoints", len(endpoints))
+	return adapter.Start(viper.GetString("peer.validator.events.address"))
+}
+
 func status() (err error) {
 	clientConn, err := peer.NewPeerClientConnection()
 	if err != nil {
@@ -603,35 +1257,552 @@ func stop() (err error) {
 	return err
 }
 
-// login confirms the enrollmentID and secret password of the client with the
-// CA and stores the enrollment certificate and key in the Devops server.
-func networkLogin(args []string) (err error) {
-	logger.Info("CLI client login...")
-
-	// Check for username argument
-	if len(args) == 0 {
-		err = errors.New("Must supply username")
-		return
+func rebucket() error {
+	if name := viper.GetString("ledger.state.dataStructure.name"); name != "" && name != "buckettree" {
+		return fmt.Errorf("Rebucketing is supported only for the 'buckettree' state data structure. Configured data structure is '%s'", name)
 	}
-
-	// Check for other extraneous arguments
-	if len(args) != 1 {
-		err = errors.New("Must supply username as the 1st and only parameter")
-		return
+	oldConfigs := viper.GetStringMap("ledger.state.dataStructure.configs")
+	newConfigs := make(map[string]interface{})
+	for k, v := range oldConfigs {
+		newConfigs[k] = v
 	}
-
-	// Retrieve the CLI data storage path
-	// Returns /var/openchain/production/client/
-	localStore := getCliFilePath()
-	logger.Info("Local data store for client loginToken: %s", localStore)
-
-	// If the user is already logged in, return
-	if _, err = os.Stat(localStore + "loginToken_" + args[0]); err == nil {
-		logger.Info("User '%s' is already logged in.\n", args[0])
-		return
+	if rebucketNumBuckets > 0 {
+		newConfigs[buckettree.ConfigNumBuckets] = rebucketNumBuckets
 	}
-
-	// If the '--password' flag is not specified, need read it from the terminal
+	if rebucketMaxGroupingAtEachLevel > 0 {
+		newConfigs[buckettree.ConfigMaxGroupingAtEachLevel] = rebucketMaxGroupingAtEachLevel
+	}
+	if rebucketHashFunction != undefinedParamValue {
+		newConfigs[buckettree.ConfigBucketHashFunction] = rebucketHashFunction
+	}
+	openchainDB := db.GetDBHandle()
+	defer openchainDB.CloseDB()
+	rootHash, err := buckettree.Rebucket(openchainDB.KVStore(), oldConfigs, newConfigs)
+	if err != nil {
+		return fmt.Errorf("Error rebucketing the state: %s", err)
+	}
+	fmt.Printf("State rebucketed. New state hash: %x\n", rootHash)
+	fmt.Printf("Update 'ledger.state.dataStructure.configs' to %s=%v, %s=%v, %s=%v before starting the node\n",
+		buckettree.ConfigNumBuckets, newConfigs[buckettree.ConfigNumBuckets],
+		buckettree.ConfigMaxGroupingAtEachLevel, newConfigs[buckettree.ConfigMaxGroupingAtEachLevel],
+		buckettree.ConfigBucketHashFunction, newConfigs[buckettree.ConfigBucketHashFunction])
+	return nil
+}
+
+func backup(backupDir string) error {
+	clientConn, err := peer.NewPeerClientConnection()
+	if err != nil {
+		return fmt.Errorf("Error connecting to the node: %s", err)
+	}
+	defer clientConn.Close()
+	serverClient := pb.NewAdminClient(clientConn)
+	info, err := serverClient.CreateBackup(context.Background(), &pb.BackupRequest{BackupDir: backupDir})
+	if err != nil {
+		return fmt.Errorf("Error creating backup: %s", err)
+	}
+	fmt.Printf("Backup [%d] created in [%s] (%d files, %d bytes)\n", info.BackupID, backupDir, info.NumFiles, info.Size)
+	return nil
+}
+
+func compact(columnFamilies []string) error {
+	clientConn, err := peer.NewPeerClientConnection()
+	if err != nil {
+		return fmt.Errorf("Error connecting to the node: %s", err)
+	}
+	defer clientConn.Close()
+	serverClient := pb.NewAdminClient(clientConn)
+	result, err := serverClient.CompactDB(context.Background(), &pb.CompactionRequest{ColumnFamilies: columnFamilies})
+	if err != nil {
+		return fmt.Errorf("Error compacting the DB: %s", err)
+	}
+	for _, cf := range result.ColumnFamilies {
+		fmt.Printf("%s: %d bytes before, %d bytes after, %dms\n", cf.Name, cf.BytesBefore, cf.BytesAfter, cf.DurationMillis)
+	}
+	return nil
+}
+
+func pruneState(chainID string) error {
+	clientConn, err := peer.NewPeerClientConnection()
+	if err != nil {
+		return fmt.Errorf("Error connecting to the node: %s", err)
+	}
+	defer clientConn.Close()
+	serverClient := pb.NewAdminClient(clientConn)
+	result, err := serverClient.PruneState(context.Background(), &pb.PruneStateRequest{ChainID: chainID})
+	if err != nil {
+		return fmt.Errorf("Error pruning the state: %s", err)
+	}
+	fmt.Printf("Removed %d data nodes, %d intermediate nodes and %d other nodes, %d bytes reclaimed\n",
+		result.DataNodesRemoved, result.IntermediateNodesRemoved, result.OtherNodesRemoved, result.BytesReclaimed)
+	return nil
+}
+
+func batchPolicy(flags *pflag.FlagSet) error {
+	clientConn, err := peer.NewPeerClientConnection()
+	if err != nil {
+		return fmt.Errorf("Error connecting to the node: %s", err)
+	}
+	defer clientConn.Close()
+	serverClient := pb.NewAdminClient(clientConn)
+	policy, err := serverClient.GetBatchPolicy(context.Background(), &google_protobuf.Empty{})
+	if err != nil {
+		return fmt.Errorf("Error getting the batch policy: %s", err)
+	}
+	if flags.Changed("maxMessageCount") || flags.Changed("maxBytes") || flags.Changed("timeout") {
+		if flags.Changed("maxMessageCount") {
+			policy.MaxMessageCount = batchMaxMessageCount
+		}
+		if flags.Changed("maxBytes") {
+			policy.MaxBytes = batchMaxBytes
+		}
+		if flags.Changed("timeout") {
+			policy.TimeoutMillis = int64(batchTimeout / time.Millisecond)
+		}
+		if policy, err = serverClient.SetBatchPolicy(context.Background(), policy); err != nil {
+			return fmt.Errorf("Error setting the batch policy: %s", err)
+		}
+	}
+	fmt.Printf("maxMessageCount: %d, maxBytes: %d, timeout: %s\n",
+		policy.MaxMessageCount, policy.MaxBytes, time.Duration(policy.TimeoutMillis)*time.Millisecond)
+	return nil
+}
+
+func restore(backupDir string) error {
+	if err := db.RestoreFromBackup(backupDir); err != nil {
+		return err
+	}
+	info, err := db.GetLatestBackupInfo(backupDir)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("DB restored from backup [%d] taken at %s\n", info.ID, time.Unix(info.Timestamp, 0))
+	return nil
+}
+
+// getChainLedger opens the ledger of the chain given with --chainID, the one of the default chain if
+// none is given. The returned function closes the ledger of a chain other than the default one
+func getChainLedger() (*ledger.Ledger, func(), error) {
+	peerLedger, err := ledger.GetLedgerByChainID(ledgerChainID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return peerLedger, func() {
+		if ledgerChainID != ledger.DefaultChainID {
+			ledger.CloseLedgerByChainID(ledgerChainID)
+		}
+	}, nil
+}
+
+func listCheckpoints() error {
+	defer db.GetDBHandle().CloseDB()
+	peerLedger, closeLedger, err := getChainLedger()
+	if err != nil {
+		return err
+	}
+	defer closeLedger()
+	checkpoints, err := peerLedger.GetCheckpoints()
+	if err != nil {
+		return err
+	}
+	for _, checkpoint := range checkpoints {
+		fmt.Printf("%d: blockHash=%x stateHash=%x size=%d\n", checkpoint.BlockNumber, checkpoint.BlockHash, checkpoint.StateHash, checkpoint.Size)
+	}
+	return nil
+}
+
+func restoreCheckpoint(blockNumber uint64) error {
+	defer db.GetDBHandle().CloseDB()
+	peerLedger, closeLedger, err := getChainLedger()
+	if err != nil {
+		return err
+	}
+	defer closeLedger()
+	if err := peerLedger.RestoreStateFromCheckpoint(blockNumber); err != nil {
+		return err
+	}
+	stateHash, err := peerLedger.GetTempStateHash()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("State restored from the checkpoint of block %d. State hash: %x\n", blockNumber, stateHash)
+	return nil
+}
+
+func verifyLedger() error {
+	defer db.GetDBHandle().CloseDB()
+	peerLedger, closeLedger, err := getChainLedger()
+	if err != nil {
+		return err
+	}
+	defer closeLedger()
+	size := peerLedger.GetBlockchainSize()
+	if size == 0 {
+		fmt.Println("The blockchain is empty")
+		return nil
+	}
+	to := size - 1
+	if verifyTo >= 0 {
+		to = uint64(verifyTo)
+	}
+	inconsistency, err := peerLedger.VerifyLedger(verifyFrom, to, verifyReplayState)
+	if err != nil {
+		return err
+	}
+	if inconsistency != nil {
+		return inconsistency
+	}
+	if verifyReplayState {
+		fmt.Printf("Verified the hashes and the state hashes of blocks %d to %d\n", verifyFrom, to)
+	} else {
+		fmt.Printf("Verified the hashes of blocks %d to %d\n", verifyFrom, to)
+	}
+	return nil
+}
+
+func scrub() error {
+	openchainDB := db.GetDBHandle()
+	defer openchainDB.CloseDB()
+	report, err := openchainDB.Scrub()
+	if err != nil {
+		return err
+	}
+	if !report.ChecksumsEnabled {
+		fmt.Println("Checksums are not enabled for this DB, only the encrypted values, if any, have been verified")
+	}
+	for _, corrupt := range report.Corrupt {
+		fmt.Println(corrupt)
+	}
+	var numRecords uint64
+	for _, n := range report.NumRecords {
+		numRecords += n
+	}
+	fmt.Printf("Scrubbed %d records, %d corrupt\n", numRecords, len(report.Corrupt))
+	if len(report.Corrupt) > 0 {
+		return fmt.Errorf("Found %d corrupt records", len(report.Corrupt))
+	}
+	return nil
+}
+
+// ledgerClient returns a client of the Devops service of the running node, along with the
+// query identifying the user if security is enabled
+func ledgerClient() (pb.DevopsClient, *pb.LedgerQuery, error) {
+	query := &pb.LedgerQuery{}
+	if core.SecurityEnabled() && ledgerUsr != undefinedParamValue {
+		token, err := ioutil.ReadFile(getCliFilePath() + "loginToken_" + ledgerUsr)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil, nil, fmt.Errorf("User '%s' not logged in. Use the 'login' command to obtain a security token.", ledgerUsr)
+			}
+			return nil, nil, fmt.Errorf("Error reading client login token: %s", err)
+		}
+		query.SecureContext = string(token)
+	}
+	devopsClient, err := getDevopsClient(nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return devopsClient, query, nil
+}
+
+func ledgerHeight() error {
+	devopsClient, query, err := ledgerClient()
+	if err != nil {
+		return err
+	}
+	info, err := devopsClient.GetBlockchainInfo(context.Background(), query)
+	if err != nil {
+		return fmt.Errorf("Error getting the height of the blockchain: %s", err)
+	}
+	fmt.Printf("height: %d\ncurrentBlockHash: %x\npreviousBlockHash: %x\n", info.Height, info.CurrentBlockHash, info.PreviousBlockHash)
+	return nil
+}
+
+func ledgerBlock(blockNumber uint64) error {
+	devopsClient, query, err := ledgerClient()
+	if err != nil {
+		return err
+	}
+	query.BlockNumber = blockNumber
+	block, err := devopsClient.GetBlockByNumber(context.Background(), query)
+	if err != nil {
+		return fmt.Errorf("Error getting block %d: %s", blockNumber, err)
+	}
+	decoded := &decodedBlock{Block: block}
+	for _, tx := range block.Transactions {
+		decoded.Transactions = append(decoded.Transactions, decodeTransaction(tx))
+	}
+	return printJSON(decoded)
+}
+
+func ledgerTx(uuid string) error {
+	devopsClient, query, err := ledgerClient()
+	if err != nil {
+		return err
+	}
+	query.Uuid = uuid
+	tx, err := devopsClient.GetTransactionByUUID(context.Background(), query)
+	if err != nil {
+		return fmt.Errorf("Error getting transaction [%s]: %s", uuid, err)
+	}
+	return printJSON(decodeTransaction(tx))
+}
+
+func ledgerStateGet(chaincodeID, key string) error {
+	devopsClient, query, err := ledgerClient()
+	if err != nil {
+		return err
+	}
+	query.ChaincodeID, query.Key = chaincodeID, key
+	value, err := devopsClient.GetState(context.Background(), query)
+	if err != nil {
+		return fmt.Errorf("Error getting key [%s] of chaincode [%s]: %s", key, chaincodeID, err)
+	}
+	fmt.Println(formatLedgerValue(value.Value))
+	return nil
+}
+
+func ledgerStateDelta(blockNumber uint64) error {
+	devopsClient, query, err := ledgerClient()
+	if err != nil {
+		return err
+	}
+	query.BlockNumber = blockNumber
+	delta, err := devopsClient.GetStateDelta(context.Background(), query)
+	if err != nil {
+		return fmt.Errorf("Error getting the state delta of block %d: %s", blockNumber, err)
+	}
+	for _, kv := range delta.KeyValues {
+		if kv.Deleted {
+			fmt.Printf("%s %s deleted\n", kv.ChaincodeID, kv.Key)
+		} else {
+			fmt.Printf("%s %s = %s\n", kv.ChaincodeID, kv.Key, formatLedgerValue(kv.Value))
+		}
+	}
+	return nil
+}
+
+func ledgerStateHash() error {
+	devopsClient, query, err := ledgerClient()
+	if err != nil {
+		return err
+	}
+	detail, err := devopsClient.GetStateHashDetail(context.Background(), query)
+	if err != nil {
+		return fmt.Errorf("Error getting the state hash: %s", err)
+	}
+	fmt.Printf("stateHash: %x\n", detail.RootHash)
+	for _, child := range detail.Children {
+		fmt.Printf("%d: %x\n", child.Index, child.Hash)
+	}
+	return nil
+}
+
+func formatLedgerValue(value []byte) string {
+	if ledgerHex {
+		return fmt.Sprintf("%x", value)
+	}
+	return string(value)
+}
+
+// decodedBlock is a block with its transactions decoded (see decodeTransaction)
+type decodedBlock struct {
+	*pb.Block
+	Transactions []*decodedTransaction `json:"transactions,omitempty"`
+}
+
+// decodedTransaction is a transaction with its chaincode ID and payload decoded, unless
+// they are encrypted
+type decodedTransaction struct {
+	*pb.Transaction
+	ChaincodeID interface{} `json:"chaincodeID,omitempty"`
+	Payload     interface{} `json:"payload,omitempty"`
+}
+
+func decodeTransaction(tx *pb.Transaction) *decodedTransaction {
+	decoded := &decodedTransaction{Transaction: tx, ChaincodeID: tx.ChaincodeID, Payload: tx.Payload}
+	if tx.ConfidentialityLevel != pb.ConfidentialityLevel_PUBLIC {
+		return decoded
+	}
+	chaincodeID := &pb.ChaincodeID{}
+	if err := proto.Unmarshal(tx.ChaincodeID, chaincodeID); err == nil {
+		decoded.ChaincodeID = chaincodeID
+	}
+	var payload proto.Message
+	switch tx.Type {
+	case pb.Transaction_CHAINCODE_DEPLOY, pb.Transaction_CHAINCODE_UPGRADE:
+		payload = &pb.ChaincodeDeploymentSpec{}
+	case pb.Transaction_CHAINCODE_INVOKE, pb.Transaction_CHAINCODE_QUERY:
+		payload = &pb.ChaincodeInvocationSpec{}
+	case pb.Transaction_CHAINCODE_ENDORSED:
+		payload = &pb.EndorsedTransaction{}
+	case pb.Transaction_CONFIG:
+		payload = &pb.ConfigUpdate{}
+	default:
+		return decoded
+	}
+	if err := proto.Unmarshal(tx.Payload, payload); err != nil {
+		return decoded
+	}
+	if deploymentSpec, ok := payload.(*pb.ChaincodeDeploymentSpec); ok {
+		deploymentSpec.CodePackage = nil
+	}
+	decoded.Payload = payload
+	return decoded
+}
+
+func printJSON(v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+func stateDiff(remoteAddress string) error {
+	localConn, err := peer.NewPeerClientConnection()
+	if err != nil {
+		return fmt.Errorf("Error connecting to the node: %s", err)
+	}
+	defer localConn.Close()
+	remoteConn, err := peer.NewPeerClientConnectionWithAddress(remoteAddress)
+	if err != nil {
+		return fmt.Errorf("Error connecting to peer [%s]: %s", remoteAddress, err)
+	}
+	defer remoteConn.Close()
+	local := pb.NewOpenchainClient(localConn)
+	remote := pb.NewOpenchainClient(remoteConn)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	localDetail, err := local.GetStateHashDetail(ctx, &google_protobuf.Empty{})
+	if err != nil {
+		return fmt.Errorf("Error retrieving the state hash of the node: %s", err)
+	}
+	remoteDetail, err := remote.GetStateHashDetail(ctx, &google_protobuf.Empty{})
+	if err != nil {
+		return fmt.Errorf("Error retrieving the state hash of peer [%s]: %s", remoteAddress, err)
+	}
+	if bytes.Equal(localDetail.RootHash, remoteDetail.RootHash) {
+		fmt.Printf("The states are identical. State hash: %x\n", localDetail.RootHash)
+		return nil
+	}
+	// state implementations without a tree (e.g., raw) do not report children, hence their
+	// states are compared in full
+	req := &pb.StateSnapshotRequest{}
+	if len(localDetail.Children) > 0 || len(remoteDetail.Children) > 0 {
+		for _, child := range statemgmt.DiffStateHashDetails(toStateHashDetail(localDetail), toStateHashDetail(remoteDetail)) {
+			req.Children = append(req.Children, uint32(child))
+		}
+		logger.Info("Comparing the key-values of the subtrees %v of the state", req.Children)
+	}
+
+	localStream, err := local.GetStateSnapshot(ctx, req)
+	if err != nil {
+		return fmt.Errorf("Error retrieving the state snapshot of the node: %s", err)
+	}
+	remoteStream, err := remote.GetStateSnapshot(ctx, req)
+	if err != nil {
+		return fmt.Errorf("Error retrieving the state snapshot of peer [%s]: %s", remoteAddress, err)
+	}
+	localItr := &stateSnapshotStreamIterator{stream: localStream}
+	remoteItr := &stateSnapshotStreamIterator{stream: remoteStream}
+	differences := statemgmt.DiffSnapshots(localItr, remoteItr)
+	if localItr.err != nil {
+		return fmt.Errorf("Error receiving the state snapshot of the node: %s", localItr.err)
+	}
+	if remoteItr.err != nil {
+		return fmt.Errorf("Error receiving the state snapshot of peer [%s]: %s", remoteAddress, remoteItr.err)
+	}
+	if localItr.blockNumber != remoteItr.blockNumber {
+		logger.Warning("The snapshot of the node is as of block [%d] while the snapshot of peer [%s] is as of block [%d]",
+			localItr.blockNumber, remoteAddress, remoteItr.blockNumber)
+	}
+	for _, difference := range differences {
+		fmt.Printf("chaincodeID=[%s], key=[%s], local=[%s], remote=[%s]\n", difference.ChaincodeID, difference.Key,
+			formatStateValue(difference.ValueA), formatStateValue(difference.ValueB))
+	}
+	fmt.Printf("%d keys differ\n", len(differences))
+	return nil
+}
+
+func toStateHashDetail(detail *pb.StateHashDetail) *statemgmt.StateHashDetail {
+	stateHashDetail := &statemgmt.StateHashDetail{RootHash: detail.RootHash}
+	for _, child := range detail.Children {
+		stateHashDetail.Children = append(stateHashDetail.Children, &statemgmt.StateHashChild{Index: int(child.Index), Hash: child.Hash})
+	}
+	return stateHashDetail
+}
+
+func formatStateValue(value []byte) string {
+	if value == nil {
+		return "<missing>"
+	}
+	return fmt.Sprintf("%x", value)
+}
+
+// stateSnapshotStreamIterator adapts the stream of chunks returned by GetStateSnapshot to the
+// statemgmt.StateSnapshotIterator interface. An error ends the iteration and is recorded in err
+type stateSnapshotStreamIterator struct {
+	stream      pb.Openchain_GetStateSnapshotClient
+	chunk       *pb.StateSnapshotChunk
+	index       int
+	blockNumber uint64
+	err         error
+}
+
+func (itr *stateSnapshotStreamIterator) Next() bool {
+	itr.index++
+	for itr.chunk == nil || itr.index >= len(itr.chunk.KeyValues) {
+		chunk, err := itr.stream.Recv()
+		if err != nil {
+			if err != io.EOF {
+				itr.err = err
+			}
+			return false
+		}
+		itr.chunk, itr.index, itr.blockNumber = chunk, 0, chunk.BlockNumber
+	}
+	return true
+}
+
+func (itr *stateSnapshotStreamIterator) GetRawKeyValue() ([]byte, []byte) {
+	keyValue := itr.chunk.KeyValues[itr.index]
+	return statemgmt.ConstructCompositeKey(keyValue.ChaincodeID, keyValue.Key), keyValue.Value
+}
+
+func (itr *stateSnapshotStreamIterator) Close() {
+}
+
+// login confirms the enrollmentID and secret password of the client with the
+// CA and stores the enrollment certificate and key in the Devops server.
+func networkLogin(args []string) (err error) {
+	logger.Info("CLI client login...")
+
+	// Check for username argument
+	if len(args) == 0 {
+		err = errors.New("Must supply username")
+		return
+	}
+
+	// Check for other extraneous arguments
+	if len(args) != 1 {
+		err = errors.New("Must supply username as the 1st and only parameter")
+		return
+	}
+
+	// Retrieve the CLI data storage path
+	// Returns /var/openchain/production/client/
+	localStore := getCliFilePath()
+	logger.Info("Local data store for client loginToken: %s", localStore)
+
+	// If the user is already logged in, return
+	if _, err = os.Stat(localStore + "loginToken_" + args[0]); err == nil {
+		logger.Info("User '%s' is already logged in.\n", args[0])
+		return
+	}
+
+	// If the '--password' flag is not specified, need read it from the terminal
 	if loginPW == "" {
 		// User is not logged in, prompt for password
 		fmt.Printf("Enter password for user '%s': ", args[0])
@@ -758,22 +1929,89 @@ func checkChaincodeCmdParams(cmd *cobra.Command) (err error) {
 		return
 	}
 
-	return
-}
-
-func getDevopsClient(cmd *cobra.Command) (pb.DevopsClient, error) {
-	clientConn, err := peer.NewPeerClientConnection()
+	return
+}
+
+func getDevopsClient(cmd *cobra.Command) (pb.DevopsClient, error) {
+	clientConn, err := peer.NewPeerClientConnection()
+	if err != nil {
+		return nil, fmt.Errorf("Error trying to connect to local peer: %s", err)
+	}
+	devopsClient := pb.NewDevopsClient(clientConn)
+	return devopsClient, nil
+}
+
+// chaincodeDeploy deploys the chaincode. On success, the chaincode name
+// (hash) is printed to STDOUT for use by subsequent chaincode-related CLI
+// commands.
+func chaincodeDeploy(cmd *cobra.Command, args []string) (err error) {
+	if err = checkChaincodeCmdParams(cmd); err != nil {
+		return
+	}
+	devopsClient, err := getDevopsClient(cmd)
+	if err != nil {
+		err = fmt.Errorf("Error building %s: %s", chainFuncName, err)
+		return
+	}
+	// Build the spec
+	input := &pb.ChaincodeInput{}
+	if err = json.Unmarshal([]byte(chaincodeCtorJSON), &input); err != nil {
+		err = fmt.Errorf("Chaincode argument error: %s", err)
+		return
+	}
+	chaincodeLang = strings.ToUpper(chaincodeLang)
+	spec := &pb.ChaincodeSpec{Type: pb.ChaincodeSpec_Type(pb.ChaincodeSpec_Type_value[chaincodeLang]),
+		ChaincodeID: &pb.ChaincodeID{Path: chaincodePath, Name: chaincodeName}, CtorMsg: input,
+		Timeout: chaincodeTimeout, MemoryLimit: chaincodeMemoryLimit, CpuShares: chaincodeCPUShares}
+
+	// If security is enabled, add client login token
+	if err = setDeploySecureContext(spec); err != nil {
+		return
+	}
+
+	chaincodeDeploymentSpec, err := devopsClient.Deploy(context.Background(), spec)
+	if err != nil {
+		err = fmt.Errorf("Error building %s: %s\n", chainFuncName, err)
+		return
+	}
+	logger.Info("Deploy result: %s", chaincodeDeploymentSpec.ChaincodeSpec)
+	fmt.Println(chaincodeDeploymentSpec.ChaincodeSpec.ChaincodeID.Name)
+	return nil
+}
+
+// chaincodeInstall installs the chaincode on the local peer. On success, the
+// chaincode name (hash) under which it can be instantiated is printed to STDOUT.
+func chaincodeInstall(cmd *cobra.Command, args []string) (err error) {
+	if chaincodePath == undefinedParamValue {
+		err = fmt.Errorf("Must supply value for %s path parameter.\n", chainFuncName)
+		return
+	}
+	devopsClient, err := getDevopsClient(cmd)
+	if err != nil {
+		err = fmt.Errorf("Error building %s: %s", chainFuncName, err)
+		return
+	}
+	chaincodeLang = strings.ToUpper(chaincodeLang)
+	spec := &pb.ChaincodeSpec{Type: pb.ChaincodeSpec_Type(pb.ChaincodeSpec_Type_value[chaincodeLang]),
+		ChaincodeID: &pb.ChaincodeID{Path: chaincodePath}}
+
+	chaincodeDeploymentSpec, err := devopsClient.Install(context.Background(), spec)
 	if err != nil {
-		return nil, fmt.Errorf("Error trying to connect to local peer: %s", err)
+		err = fmt.Errorf("Error installing %s: %s\n", chainFuncName, err)
+		return
 	}
-	devopsClient := pb.NewDevopsClient(clientConn)
-	return devopsClient, nil
+	logger.Info("Install result: %s", chaincodeDeploymentSpec.ChaincodeSpec)
+	fmt.Println(chaincodeDeploymentSpec.ChaincodeSpec.ChaincodeID.Name)
+	return nil
 }
 
-// chaincodeDeploy deploys the chaincode. On success, the chaincode name
-// (hash) is printed to STDOUT for use by subsequent chaincode-related CLI
-// commands.
-func chaincodeDeploy(cmd *cobra.Command, args []string) (err error) {
+// chaincodeInstantiate instantiates the chaincode installed under the given
+// name. On success, the chaincode name is printed to STDOUT.
+func chaincodeInstantiate(cmd *cobra.Command, args []string) (err error) {
+	if chaincodeName == undefinedParamValue {
+		err = fmt.Errorf("Must supply value for %s name parameter.\n", chainFuncName)
+		return
+	}
 	if err = checkChaincodeCmdParams(cmd); err != nil {
 		return
 	}
@@ -782,65 +2020,127 @@ func chaincodeDeploy(cmd *cobra.Command, args []string) (err error) {
 		err = fmt.Errorf("Error building %s: %s", chainFuncName, err)
 		return
 	}
-	// Build the spec
 	input := &pb.ChaincodeInput{}
 	if err = json.Unmarshal([]byte(chaincodeCtorJSON), &input); err != nil {
 		err = fmt.Errorf("Chaincode argument error: %s", err)
 		return
 	}
+	spec := &pb.ChaincodeSpec{ChaincodeID: &pb.ChaincodeID{Name: chaincodeName}, CtorMsg: input,
+		Timeout: chaincodeTimeout, MemoryLimit: chaincodeMemoryLimit, CpuShares: chaincodeCPUShares}
+	if err = setDeploySecureContext(spec); err != nil {
+		return
+	}
+
+	chaincodeDeploymentSpec, err := devopsClient.Instantiate(context.Background(),
+		&pb.ChaincodeDeploymentSpec{ChaincodeSpec: spec, EndorsementPolicy: chaincodePolicy, StatePeers: chaincodeStatePeers})
+	if err != nil {
+		err = fmt.Errorf("Error instantiating %s: %s\n", chainFuncName, err)
+		return
+	}
+	logger.Info("Instantiate result: %s", chaincodeDeploymentSpec.ChaincodeSpec)
+	fmt.Println(chaincodeDeploymentSpec.ChaincodeSpec.ChaincodeID.Name)
+	return nil
+}
+
+// chaincodeUpgrade upgrades the chaincode of the given name to the code at the
+// given path, or to the chaincode installed under the given version. On success, the version (hash) of the new code is printed to STDOUT.
+func chaincodeUpgrade(cmd *cobra.Command, args []string) (err error) {
+	if chaincodeName == undefinedParamValue || (chaincodePath == undefinedParamValue && chaincodeVersion == "") {
+		err = fmt.Errorf("Must supply value for %s name and path (or version) parameters.\n", chainFuncName)
+		return
+	}
+	if core.SecurityEnabled() {
+		err = fmt.Errorf("Upgrade of %s is not supported when security is enabled", chainFuncName)
+		return
+	}
+	devopsClient, err := getDevopsClient(cmd)
+	if err != nil {
+		err = fmt.Errorf("Error building %s: %s", chainFuncName, err)
+		return
+	}
 	chaincodeLang = strings.ToUpper(chaincodeLang)
 	spec := &pb.ChaincodeSpec{Type: pb.ChaincodeSpec_Type(pb.ChaincodeSpec_Type_value[chaincodeLang]),
-		ChaincodeID: &pb.ChaincodeID{Path: chaincodePath, Name: chaincodeName}, CtorMsg: input}
+		ChaincodeID: &pb.ChaincodeID{Path: chaincodePath, Name: chaincodeName, Version: chaincodeVersion},
+		Timeout:     chaincodeTimeout, MemoryLimit: chaincodeMemoryLimit, CpuShares: chaincodeCPUShares}
 
-	// If security is enabled, add client login token
-	if core.SecurityEnabled() {
-		logger.Debug("Security is enabled. Include security context in deploy spec")
-		if chaincodeUsr == undefinedParamValue {
-			err = errors.New("Must supply username for chaincode when security is enabled")
-			return
-		}
+	chaincodeDeploymentSpec, err := devopsClient.Upgrade(context.Background(), spec)
+	if err != nil {
+		err = fmt.Errorf("Error upgrading %s: %s\n", chainFuncName, err)
+		return
+	}
+	logger.Info("Upgrade result: %s", chaincodeDeploymentSpec.ChaincodeSpec)
+	fmt.Println(chaincodeDeploymentSpec.ChaincodeSpec.ChaincodeID.Version)
+	return nil
+}
 
-		// Retrieve the CLI data storage path
-		// Returns /var/openchain/production/client/
-		localStore := getCliFilePath()
+// chaincodeDev runs a Go chaincode against the peer at peer.address, which must be in
+// development mode, until interrupted. The chaincode is relaunched on each change of its code
+func chaincodeDev(cmd *cobra.Command, args []string) error {
+	if chaincodeName == undefinedParamValue || chaincodePath == undefinedParamValue {
+		return fmt.Errorf("Must supply value for %s name and path parameters.\n", chainFuncName)
+	}
+	if strings.ToUpper(chaincodeLang) != pb.ChaincodeSpec_GOLANG.String() {
+		return fmt.Errorf("Only Go %ss can be run by the %s dev command", chainFuncName, chainFuncName)
+	}
+	reloader, err := devmode.NewReloader(chaincodeName, chaincodePath, viper.GetString("peer.address"), chaincodeReloadInterval)
+	if err != nil {
+		return fmt.Errorf("Error running %s: %s", chainFuncName, err)
+	}
 
-		// Check if the user is logged in before sending transaction
-		if _, err = os.Stat(localStore + "loginToken_" + chaincodeUsr); err == nil {
-			logger.Info("Local user '%s' is already logged in. Retrieving login token.\n", chaincodeUsr)
+	stop := make(chan struct{})
+	interrupted := make(chan os.Signal, 1)
+	signal.Notify(interrupted, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-interrupted
+		close(stop)
+	}()
+	return reloader.Run(stop)
+}
 
-			// Read in the login token
-			token, err := ioutil.ReadFile(localStore + "loginToken_" + chaincodeUsr)
-			if err != nil {
-				panic(fmt.Errorf("Fatal error when reading client login token: %s\n", err))
-			}
+// setDeploySecureContext adds the login token of the user to the spec of a chaincode to
+// deploy or instantiate, if security is enabled
+func setDeploySecureContext(spec *pb.ChaincodeSpec) (err error) {
+	if !core.SecurityEnabled() {
+		return
+	}
+	logger.Debug("Security is enabled. Include security context in deploy spec")
+	if chaincodeUsr == undefinedParamValue {
+		err = errors.New("Must supply username for chaincode when security is enabled")
+		return
+	}
 
-			// Add the login token to the chaincodeSpec
-			spec.SecureContext = string(token)
+	// Retrieve the CLI data storage path
+	// Returns /var/openchain/production/client/
+	localStore := getCliFilePath()
 
-			// If privacy is enabled, mark chaincode as confidential
-			if viper.GetBool("security.privacy") {
-				logger.Info("Set confidentiality level to CONFIDENTIAL.\n")
-				spec.ConfidentialityLevel = pb.ConfidentialityLevel_CONFIDENTIAL
-			}
-		} else {
-			// Check if the token is not there and fail
-			if os.IsNotExist(err) {
-				err = fmt.Errorf("User '%s' not logged in. Use the 'login' command to obtain a security token.", chaincodeUsr)
-				return
-			}
-			// Unexpected error
-			panic(fmt.Errorf("Fatal error when checking for client login token: %s\n", err))
+	// Check if the user is logged in before sending transaction
+	if _, err = os.Stat(localStore + "loginToken_" + chaincodeUsr); err == nil {
+		logger.Info("Local user '%s' is already logged in. Retrieving login token.\n", chaincodeUsr)
+
+		// Read in the login token
+		token, err := ioutil.ReadFile(localStore + "loginToken_" + chaincodeUsr)
+		if err != nil {
+			panic(fmt.Errorf("Fatal error when reading client login token: %s\n", err))
 		}
-	}
 
-	chaincodeDeploymentSpec, err := devopsClient.Deploy(context.Background(), spec)
-	if err != nil {
-		err = fmt.Errorf("Error building %s: %s\n", chainFuncName, err)
-		return
+		// Add the login token to the chaincodeSpec
+		spec.SecureContext = string(token)
+
+		// If privacy is enabled, mark chaincode as confidential
+		if viper.GetBool("security.privacy") {
+			logger.Info("Set confidentiality level to CONFIDENTIAL.\n")
+			spec.ConfidentialityLevel = pb.ConfidentialityLevel_CONFIDENTIAL
+		}
+	} else {
+		// Check if the token is not there and fail
+		if os.IsNotExist(err) {
+			err = fmt.Errorf("User '%s' not logged in. Use the 'login' command to obtain a security token.", chaincodeUsr)
+			return
+		}
+		// Unexpected error
+		panic(fmt.Errorf("Fatal error when checking for client login token: %s\n", err))
 	}
-	logger.Info("Deploy result: %s", chaincodeDeploymentSpec.ChaincodeSpec)
-	fmt.Println(chaincodeDeploymentSpec.ChaincodeSpec.ChaincodeID.Name)
-	return nil
+	return
 }
 
 func chaincodeInvoke(cmd *cobra.Command, args []string) error {
@@ -851,14 +2151,9 @@ func chaincodeQuery(cmd *cobra.Command, args []string) error {
 	return chaincodeInvokeOrQuery(cmd, args, false)
 }
 
-// chaincodeInvokeOrQuery invokes or queries the chaincode. If successful, the
-// INVOKE form prints the transaction ID on STDOUT, and the QUERY form prints
-// the query result on STDOUT. A command-line flag (-r, --raw) determines
-// whether the query result is output as raw bytes, or as a printable string.
-// The printable form is optionally (-x, --hex) a hexadecimal representation
-// of the query response. If the query response is NIL, nothing is output.
-func chaincodeInvokeOrQuery(cmd *cobra.Command, args []string, invoke bool) (err error) {
-
+// getChaincodeInvocation returns the invocation of the chaincode given on the
+// command line, and the devops client to send it to
+func getChaincodeInvocation(cmd *cobra.Command) (devopsClient pb.DevopsClient, invocation *pb.ChaincodeInvocationSpec, err error) {
 	if err = checkChaincodeCmdParams(cmd); err != nil {
 		return
 	}
@@ -868,7 +2163,7 @@ func chaincodeInvokeOrQuery(cmd *cobra.Command, args []string, invoke bool) (err
 		return
 	}
 
-	devopsClient, err := getDevopsClient(cmd)
+	devopsClient, err = getDevopsClient(cmd)
 	if err != nil {
 		err = fmt.Errorf("Error building %s: %s", chainFuncName, err)
 		return
@@ -924,11 +2219,44 @@ func chaincodeInvokeOrQuery(cmd *cobra.Command, args []string, invoke bool) (err
 	}
 
 	// Build the ChaincodeInvocationSpec message
-	invocation := &pb.ChaincodeInvocationSpec{ChaincodeSpec: spec}
+	invocation = &pb.ChaincodeInvocationSpec{ChaincodeSpec: spec}
+	return
+}
+
+// chaincodeInvokeOrQuery invokes or queries the chaincode. If successful, the
+// INVOKE form prints the transaction ID on STDOUT, and the QUERY form prints
+// the query result on STDOUT. A command-line flag (-r, --raw) determines
+// whether the query result is output as raw bytes, or as a printable string.
+// The printable form is optionally (-x, --hex) a hexadecimal representation
+// of the query response. If the query response is NIL, nothing is output.
+// The QUERY form is served from the committed state of the validator, without
+// a transaction, when --readonly is given.
+func chaincodeInvokeOrQuery(cmd *cobra.Command, args []string, invoke bool) (err error) {
+	devopsClient, invocation, err := getChaincodeInvocation(cmd)
+	if err != nil {
+		return
+	}
+
+	if invoke && (chaincodeExpiryHeight > 0 || chaincodeExpiry > 0) {
+		invocation.Expiry = &pb.TransactionExpiry{BlockHeight: chaincodeExpiryHeight}
+		if chaincodeExpiry > 0 {
+			expiresAt := time.Now().Add(chaincodeExpiry)
+			invocation.Expiry.Timestamp = &google_protobuf.Timestamp{Seconds: expiresAt.Unix(), Nanos: int32(expiresAt.Nanosecond())}
+		}
+	}
 
 	var resp *pb.Response
-	if invoke {
+	if invoke && chaincodeEndorse {
+		resp, err = invokeEndorsed(devopsClient, invocation)
+	} else if invoke {
 		resp, err = devopsClient.Invoke(context.Background(), invocation)
+	} else if chaincodeReadOnly {
+		var queryResp *pb.QueryResponse
+		queryResp, err = devopsClient.ReadOnlyQuery(context.Background(), invocation)
+		if err == nil {
+			logger.Info("Query %s served by %s at block height %d", queryResp.Uuid, queryResp.GetPeerID(), queryResp.BlockHeight)
+			resp = queryResp.Response
+		}
 	} else {
 		resp, err = devopsClient.Query(context.Background(), invocation)
 	}
@@ -966,6 +2294,42 @@ func chaincodeInvokeOrQuery(cmd *cobra.Command, args []string, invoke bool) (err
 	return nil
 }
 
+// invokeEndorsed has the invocation simulated and endorsed by the validator, and submits
+// the changes it endorsed in an endorsed transaction
+func invokeEndorsed(devopsClient pb.DevopsClient, invocation *pb.ChaincodeInvocationSpec) (*pb.Response, error) {
+	result, err := devopsClient.SimulateTransaction(context.Background(), invocation)
+	if err != nil {
+		return nil, err
+	}
+	if result.Response.Status != pb.Response_SUCCESS {
+		return nil, fmt.Errorf("Simulation failed: %s", result.Response.Msg)
+	}
+	logger.Info("Invoke simulated by %s at block height %d", result.GetPeerID(), result.BlockHeight)
+	endorsed := &pb.EndorsedTransaction{ChaincodeID: invocation.ChaincodeSpec.ChaincodeID, Endorsements: []*pb.SimulationResult{result}, SecureContext: invocation.ChaincodeSpec.SecureContext, Expiry: invocation.Expiry}
+	return devopsClient.SubmitEndorsedTransaction(context.Background(), endorsed)
+}
+
+// chaincodeSimulate runs an invoke of the chaincode against the committed state
+// of the validator without submitting it, and prints the simulation result,
+// with the response and the state read and written, as JSON on STDOUT.
+func chaincodeSimulate(cmd *cobra.Command, args []string) (err error) {
+	devopsClient, invocation, err := getChaincodeInvocation(cmd)
+	if err != nil {
+		return
+	}
+
+	result, err := devopsClient.SimulateTransaction(context.Background(), invocation)
+	if err != nil {
+		err = fmt.Errorf("Error simulating %s: %s\n", chainFuncName, err)
+		return
+	}
+	logger.Info("Successfully simulated transaction: %s(%s)", invocation, result.Uuid)
+
+	jsonOutput, _ := json.MarshalIndent(result, "", "  ")
+	fmt.Println(string(jsonOutput))
+	return nil
+}
+
 // Show a list of all existing network connections for the target peer node,
 // includes both validating and non-validating peers
 func networkList() (err error) {
@@ -987,6 +2351,211 @@ func networkList() (err error) {
 	return nil
 }
 
+// admissionStats prints the admission control counters of the running node
+func admissionStats() error {
+	clientConn, err := peer.NewPeerClientConnection()
+	if err != nil {
+		return fmt.Errorf("Error connecting to the node: %s", err)
+	}
+	defer clientConn.Close()
+	serverClient := pb.NewAdminClient(clientConn)
+	stats, err := serverClient.GetAdmissionStats(context.Background(), &google_protobuf.Empty{})
+	if err != nil {
+		return fmt.Errorf("Error getting the admission stats: %s", err)
+	}
+	for _, class := range stats.Classes {
+		fmt.Printf("%s: admitted: %d, rejected: %d, inFlight: %d, maxInFlight: %d, clients: %d\n",
+			class.Name, class.Admitted, class.Rejected, class.InFlight, class.MaxInFlight, class.Clients)
+	}
+	return nil
+}
+
+func debugProfile(name string) error {
+	clientConn, err := peer.NewPeerClientConnection()
+	if err != nil {
+		return fmt.Errorf("Error connecting to the node: %s", err)
+	}
+	defer clientConn.Close()
+	serverClient := pb.NewAdminClient(clientConn)
+	profile, err := serverClient.GetProfile(context.Background(), &pb.ProfileRequest{Name: name, Seconds: debugSeconds, Debug: debugLevel})
+	if err != nil {
+		return fmt.Errorf("Error getting the %s profile: %s", name, err)
+	}
+	if debugOutput == "" {
+		_, err = os.Stdout.Write(profile.Data)
+		return err
+	}
+	return ioutil.WriteFile(debugOutput, profile.Data, 0644)
+}
+
+func debugLedger() error {
+	clientConn, err := peer.NewPeerClientConnection()
+	if err != nil {
+		return fmt.Errorf("Error connecting to the node: %s", err)
+	}
+	defer clientConn.Close()
+	serverClient := pb.NewAdminClient(clientConn)
+	stats, err := serverClient.GetLedgerRuntimeStats(context.Background(), &pb.LedgerRuntimeStatsRequest{ChainID: debugChainID})
+	if err != nil {
+		return fmt.Errorf("Error getting the ledger stats: %s", err)
+	}
+	lastBlockCommitted := "none since the node started"
+	if stats.LastBlockCommitted != 0 {
+		lastBlockCommitted = time.Unix(0, stats.LastBlockCommitted*int64(time.Millisecond)).String()
+	}
+	fmt.Printf("blockchainSize: %d, lastBlockCommitted: %s\n", stats.BlockchainSize, lastBlockCommitted)
+	if stats.TxInProgress != "" {
+		fmt.Printf("txInProgress: %s, for %s\n", stats.TxInProgress, time.Duration(stats.TxInProgressMillis)*time.Millisecond)
+	}
+	fmt.Printf("batchTxs: %d, pendingKeys: %d, stagedChanges: %t, walInUse: %t\n",
+		stats.BatchTxs, stats.PendingKeys, stats.StagedChanges, stats.WalInUse)
+	return nil
+}
+
+func debugDB(properties []string) error {
+	clientConn, err := peer.NewPeerClientConnection()
+	if err != nil {
+		return fmt.Errorf("Error connecting to the node: %s", err)
+	}
+	defer clientConn.Close()
+	serverClient := pb.NewAdminClient(clientConn)
+	result, err := serverClient.GetDBProperties(context.Background(),
+		&pb.DBPropertiesRequest{ChainID: debugChainID, Properties: properties, ColumnFamilies: debugCFs})
+	if err != nil {
+		return fmt.Errorf("Error getting the DB properties: %s", err)
+	}
+	for _, property := range result.Properties {
+		if strings.Contains(property.Value, "\n") {
+			fmt.Printf("%s %s:\n%s\n", property.ColumnFamily, property.Name, property.Value)
+		} else {
+			fmt.Printf("%s %s: %s\n", property.ColumnFamily, property.Name, property.Value)
+		}
+	}
+	return nil
+}
+
+// logLevel sets the logging level of the module if given, and prints the levels of the
+// running node
+func logLevel(args []string) error {
+	clientConn, err := peer.NewPeerClientConnection()
+	if err != nil {
+		return fmt.Errorf("Error connecting to the node: %s", err)
+	}
+	defer clientConn.Close()
+	serverClient := pb.NewAdminClient(clientConn)
+	var levels *pb.LogLevels
+	if len(args) == 2 {
+		levels, err = serverClient.SetLogLevel(context.Background(), &pb.LogLevel{Module: args[0], Level: args[1]})
+	} else {
+		levels, err = serverClient.GetLogLevels(context.Background(), &google_protobuf.Empty{})
+	}
+	if err != nil {
+		return fmt.Errorf("Error getting the logging levels: %s", err)
+	}
+	fmt.Printf("default: %s\n", levels.DefaultLevel)
+	for _, module := range levels.Modules {
+		fmt.Printf("%s: %s\n", module.Module, module.Level)
+	}
+	return nil
+}
+
+// aclPolicy replaces the access control list of the running node by the one of the JSON
+// file if given, and prints the list in effect
+func aclPolicy(args []string) error {
+	clientConn, err := peer.NewPeerClientConnection()
+	if err != nil {
+		return fmt.Errorf("Error connecting to the node: %s", err)
+	}
+	defer clientConn.Close()
+	serverClient := pb.NewAdminClient(clientConn)
+	var policy *pb.ACLPolicy
+	if len(args) == 1 {
+		data, err := ioutil.ReadFile(args[0])
+		if err != nil {
+			return fmt.Errorf("Error reading the access control list: %s", err)
+		}
+		policy = &pb.ACLPolicy{}
+		if err = json.Unmarshal(data, policy); err != nil {
+			return fmt.Errorf("Error parsing the access control list: %s", err)
+		}
+		policy, err = serverClient.SetACLPolicy(context.Background(), policy)
+	} else {
+		policy, err = serverClient.GetACLPolicy(context.Background(), &google_protobuf.Empty{})
+	}
+	if err != nil {
+		return fmt.Errorf("Error managing the access control list: %s", err)
+	}
+	jsonOutput, _ := json.MarshalIndent(policy, "", "  ")
+	fmt.Println(string(jsonOutput))
+	return nil
+}
+
+// networkDiscovery applies the action to the peer address in the discovery list of the
+// running node, and prints the resulting list
+func networkDiscovery(action string, address string) error {
+	clientConn, err := peer.NewPeerClientConnection()
+	if err != nil {
+		return fmt.Errorf("Error connecting to the node: %s", err)
+	}
+	defer clientConn.Close()
+	serverClient := pb.NewAdminClient(clientConn)
+	req := &pb.DiscoveryRequest{Address: address}
+	var list *pb.DiscoveryList
+	switch action {
+	case "add":
+		list, err = serverClient.AddPeer(context.Background(), req)
+	case "remove":
+		list, err = serverClient.RemovePeer(context.Background(), req)
+	case "ban":
+		list, err = serverClient.BanPeer(context.Background(), req)
+	default:
+		list, err = serverClient.GetDiscoveryList(context.Background(), &google_protobuf.Empty{})
+	}
+	if err != nil {
+		return fmt.Errorf("Error managing the discovery list: %s", err)
+	}
+	jsonOutput, _ := json.Marshal(list)
+	fmt.Println(string(jsonOutput))
+	return nil
+}
+
+// networkRevoke submits a config transaction recording the certificate revocation list of the
+// file on the ledger
+func networkRevoke(crlFile string) error {
+	crl, err := ioutil.ReadFile(crlFile)
+	if err != nil {
+		return fmt.Errorf("Error reading the certificate revocation list: %s", err)
+	}
+	if block, _ := pem.Decode(crl); block != nil {
+		crl = block.Bytes
+	}
+	update := &pb.ConfigUpdate{RevocationList: crl}
+	if core.SecurityEnabled() {
+		if revokeUsr == undefinedParamValue {
+			return errors.New("Must supply username when security is enabled")
+		}
+		token, err := ioutil.ReadFile(getCliFilePath() + "loginToken_" + revokeUsr)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return fmt.Errorf("User '%s' not logged in. Use the 'login' command to obtain a security token.", revokeUsr)
+			}
+			return fmt.Errorf("Error reading client login token: %s", err)
+		}
+		update.SecureContext = string(token)
+	}
+	devopsClient, err := getDevopsClient(nil)
+	if err != nil {
+		return err
+	}
+	resp, err := devopsClient.UpdateConfig(context.Background(), update)
+	if err != nil {
+		return fmt.Errorf("Error recording the certificate revocation list: %s", err)
+	}
+	logger.Info("Successfully submitted certificate revocation list")
+	fmt.Println(string(resp.Msg))
+	return nil
+}
+
 func writePid(fileName string, pid int) error {
 	err := os.MkdirAll(filepath.Dir(fileName), 0755)
 	if err != nil {
@@ -12,19 +12,38 @@ It is generated from these files:
 	events.proto
 	fabric.proto
 	server_admin.proto
+	statedelta.proto
 
 It has these top-level messages:
 	BlockNumber
+	BlockRange
 	BlockCount
+	StateHashDetail
+	StateHashChild
+	StateSnapshotRequest
+	StateSnapshotChunk
+	StateKeyValue
+	StateDeltasSubscription
+	BlockStateDelta
 	ChaincodeID
 	ChaincodeInput
 	ChaincodeSpec
 	ChaincodeDeploymentSpec
+	ChaincodeStateKeys
+	ChaincodeInstantiation
 	ChaincodeInvocationSpec
+	TransientField
+	TransactionExpiry
 	ChaincodeSecurityContext
 	ChaincodeMessage
+	ChaincodeEvent
 	PutStateInfo
+	MarkWriteOnce
 	RangeQueryState
+	GetQueryResult
+	GetHistoryForKey
+	KeyModification
+	GetHistoryForKeyResponse
 	RangeQueryStateNext
 	RangeQueryStateClose
 	RangeQueryStateKeyValue
@@ -32,8 +51,13 @@ It has these top-level messages:
 	Secret
 	BuildResult
 	Interest
+	EventFilter
 	Register
+	Replay
 	Generic
+	BlockCommit
+	TxStateDeltaHash
+	Ack
 	Event
 	Transaction
 	TransactionBlock
@@ -48,6 +72,13 @@ It has these top-level messages:
 	HelloMessage
 	Message
 	Response
+	QueryResponse
+	SimulationResult
+	StateRead
+	StateRangeRead
+	StateWrite
+	EndorsedTransaction
+	ConfigUpdate
 	BlockState
 	SyncBlockRange
 	SyncBlocks
@@ -56,6 +87,10 @@ It has these top-level messages:
 	SyncStateDeltasRequest
 	SyncStateDeltas
 	ServerStatus
+	StateDeltaMessage
+	ChaincodeStateDeltaMessage
+	UpdatedValueMessage
+	StateValue
 */
 package protos
 
@@ -69,6 +104,79 @@ import (
 	grpc "google.golang.org/grpc"
 )
 
+// Specifies the key-values of the world state to be returned. If children is
+// empty, all the key-values are returned. Otherwise only those held by the
+// subtrees of the given children of the root of the state tree.
+type StateSnapshotRequest struct {
+	Children []uint32 `protobuf:"varint,1,rep,packed,name=children" json:"children,omitempty"`
+}
+
+func (m *StateSnapshotRequest) Reset()         { *m = StateSnapshotRequest{} }
+func (m *StateSnapshotRequest) String() string { return proto.CompactTextString(m) }
+func (*StateSnapshotRequest) ProtoMessage()    {}
+
+// A chunk of the key-values of the world state as of the given block.
+type StateSnapshotChunk struct {
+	BlockNumber uint64           `protobuf:"varint,1,opt,name=blockNumber" json:"blockNumber,omitempty"`
+	KeyValues   []*StateKeyValue `protobuf:"bytes,2,rep,name=keyValues" json:"keyValues,omitempty"`
+}
+
+func (m *StateSnapshotChunk) Reset()         { *m = StateSnapshotChunk{} }
+func (m *StateSnapshotChunk) String() string { return proto.CompactTextString(m) }
+func (*StateSnapshotChunk) ProtoMessage()    {}
+
+func (m *StateSnapshotChunk) GetKeyValues() []*StateKeyValue {
+	if m != nil {
+		return m.KeyValues
+	}
+	return nil
+}
+
+// A key of a chaincode along with its value in the world state.
+type StateKeyValue struct {
+	ChaincodeID string `protobuf:"bytes,1,opt,name=chaincodeID" json:"chaincodeID,omitempty"`
+	Key         string `protobuf:"bytes,2,opt,name=key" json:"key,omitempty"`
+	Value       []byte `protobuf:"bytes,3,opt,name=value,proto3" json:"value,omitempty"`
+	// Set in a BlockStateDelta when the key was deleted by the block.
+	Deleted bool `protobuf:"varint,4,opt,name=deleted" json:"deleted,omitempty"`
+}
+
+func (m *StateKeyValue) Reset()         { *m = StateKeyValue{} }
+func (m *StateKeyValue) String() string { return proto.CompactTextString(m) }
+func (*StateKeyValue) ProtoMessage()    {}
+
+// Specifies the block from which the state deltas are streamed. If chaincodeID
+// is set, only the changes to the keys of this chaincode are streamed, and if
+// keyPrefix is set, only the changes to the keys starting with it.
+type StateDeltasSubscription struct {
+	FromBlock   uint64 `protobuf:"varint,1,opt,name=fromBlock" json:"fromBlock,omitempty"`
+	ChaincodeID string `protobuf:"bytes,2,opt,name=chaincodeID" json:"chaincodeID,omitempty"`
+	KeyPrefix   string `protobuf:"bytes,3,opt,name=keyPrefix" json:"keyPrefix,omitempty"`
+}
+
+func (m *StateDeltasSubscription) Reset()         { *m = StateDeltasSubscription{} }
+func (m *StateDeltasSubscription) String() string { return proto.CompactTextString(m) }
+func (*StateDeltasSubscription) ProtoMessage()    {}
+
+// The changes made to the world state by the given block, sorted by chaincode
+// and key. A block is streamed even if none of its changes is subscribed to,
+// so that the subscriber knows which blocks it has seen.
+type BlockStateDelta struct {
+	BlockNumber uint64           `protobuf:"varint,1,opt,name=blockNumber" json:"blockNumber,omitempty"`
+	KeyValues   []*StateKeyValue `protobuf:"bytes,2,rep,name=keyValues" json:"keyValues,omitempty"`
+}
+
+func (m *BlockStateDelta) Reset()         { *m = BlockStateDelta{} }
+func (m *BlockStateDelta) String() string { return proto.CompactTextString(m) }
+func (*BlockStateDelta) ProtoMessage()    {}
+
+func (m *BlockStateDelta) GetKeyValues() []*StateKeyValue {
+	if m != nil {
+		return m.KeyValues
+	}
+	return nil
+}
+
 // Reference imports to suppress errors if they are not otherwise used.
 var _ = proto.Marshal
 var _ = fmt.Errorf
@@ -83,6 +191,17 @@ func (m *BlockNumber) Reset()         { *m = BlockNumber{} }
 func (m *BlockNumber) String() string { return proto.CompactTextString(m) }
 func (*BlockNumber) ProtoMessage()    {}
 
+// Specifies the range of blocks to be returned from the blockchain. The blocks
+// are returned in descending order if start is greater than end.
+type BlockRange struct {
+	Start uint64 `protobuf:"varint,1,opt,name=start" json:"start,omitempty"`
+	End   uint64 `protobuf:"varint,2,opt,name=end" json:"end,omitempty"`
+}
+
+func (m *BlockRange) Reset()         { *m = BlockRange{} }
+func (m *BlockRange) String() string { return proto.CompactTextString(m) }
+func (*BlockRange) ProtoMessage()    {}
+
 // Specifies the current number of blocks in the blockchain.
 type BlockCount struct {
 	Count uint64 `protobuf:"varint,1,opt,name=count" json:"count,omitempty"`
@@ -92,6 +211,35 @@ func (m *BlockCount) Reset()         { *m = BlockCount{} }
 func (m *BlockCount) String() string { return proto.CompactTextString(m) }
 func (*BlockCount) ProtoMessage()    {}
 
+// Crypto-hash of the committed world state along with the crypto-hashes of
+// the non-empty children of the root of the state tree.
+type StateHashDetail struct {
+	RootHash []byte            `protobuf:"bytes,1,opt,name=rootHash,proto3" json:"rootHash,omitempty"`
+	Children []*StateHashChild `protobuf:"bytes,2,rep,name=children" json:"children,omitempty"`
+}
+
+func (m *StateHashDetail) Reset()         { *m = StateHashDetail{} }
+func (m *StateHashDetail) String() string { return proto.CompactTextString(m) }
+func (*StateHashDetail) ProtoMessage()    {}
+
+func (m *StateHashDetail) GetChildren() []*StateHashChild {
+	if m != nil {
+		return m.Children
+	}
+	return nil
+}
+
+// Crypto-hash of a child of the root of the state tree. The meaning of the
+// index depends on the state implementation.
+type StateHashChild struct {
+	Index uint32 `protobuf:"varint,1,opt,name=index" json:"index,omitempty"`
+	Hash  []byte `protobuf:"bytes,2,opt,name=hash,proto3" json:"hash,omitempty"`
+}
+
+func (m *StateHashChild) Reset()         { *m = StateHashChild{} }
+func (m *StateHashChild) String() string { return proto.CompactTextString(m) }
+func (*StateHashChild) ProtoMessage()    {}
+
 // Reference imports to suppress errors if they are not otherwise used.
 var _ context.Context
 var _ grpc.ClientConn
@@ -111,6 +259,22 @@ type OpenchainClient interface {
 	// GetPeers returns a list of all peer nodes currently connected to the target
 	// peer.
 	GetPeers(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*PeersMessage, error)
+	// GetStateHashDetail returns the crypto-hash of the committed world state
+	// along with the crypto-hashes of the children of the root of the state
+	// tree, so that the subtree which diverged between two peers can be found.
+	GetStateHashDetail(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*StateHashDetail, error)
+	// GetStateSnapshot streams the key-values of the committed world state,
+	// possibly restricted to the subtrees of some children of the root of the
+	// state tree, as taken at a single point in time.
+	GetStateSnapshot(ctx context.Context, in *StateSnapshotRequest, opts ...grpc.CallOption) (Openchain_GetStateSnapshotClient, error)
+	// GetBlocks streams the blocks of the given range, both ends included. The
+	// blocks are read as they are sent, hence the stream is paced by the
+	// flow control of the client.
+	GetBlocks(ctx context.Context, in *BlockRange, opts ...grpc.CallOption) (Openchain_GetBlocksClient, error)
+	// SubscribeStateDeltas streams the changes made to the world state by each
+	// block, from the given block on, first those of the blocks already
+	// committed and then those of the blocks as they are committed.
+	SubscribeStateDeltas(ctx context.Context, in *StateDeltasSubscription, opts ...grpc.CallOption) (Openchain_SubscribeStateDeltasClient, error)
 }
 
 type openchainClient struct {
@@ -157,6 +321,111 @@ func (c *openchainClient) GetPeers(ctx context.Context, in *google_protobuf1.Emp
 	return out, nil
 }
 
+func (c *openchainClient) GetStateHashDetail(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*StateHashDetail, error) {
+	out := new(StateHashDetail)
+	err := grpc.Invoke(ctx, "/protos.Openchain/GetStateHashDetail", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *openchainClient) GetStateSnapshot(ctx context.Context, in *StateSnapshotRequest, opts ...grpc.CallOption) (Openchain_GetStateSnapshotClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_Openchain_serviceDesc.Streams[0], c.cc, "/protos.Openchain/GetStateSnapshot", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &openchainGetStateSnapshotClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Openchain_GetStateSnapshotClient interface {
+	Recv() (*StateSnapshotChunk, error)
+	grpc.ClientStream
+}
+
+type openchainGetStateSnapshotClient struct {
+	grpc.ClientStream
+}
+
+func (x *openchainGetStateSnapshotClient) Recv() (*StateSnapshotChunk, error) {
+	m := new(StateSnapshotChunk)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *openchainClient) GetBlocks(ctx context.Context, in *BlockRange, opts ...grpc.CallOption) (Openchain_GetBlocksClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_Openchain_serviceDesc.Streams[1], c.cc, "/protos.Openchain/GetBlocks", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &openchainGetBlocksClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Openchain_GetBlocksClient interface {
+	Recv() (*Block, error)
+	grpc.ClientStream
+}
+
+type openchainGetBlocksClient struct {
+	grpc.ClientStream
+}
+
+func (x *openchainGetBlocksClient) Recv() (*Block, error) {
+	m := new(Block)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *openchainClient) SubscribeStateDeltas(ctx context.Context, in *StateDeltasSubscription, opts ...grpc.CallOption) (Openchain_SubscribeStateDeltasClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_Openchain_serviceDesc.Streams[2], c.cc, "/protos.Openchain/SubscribeStateDeltas", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &openchainSubscribeStateDeltasClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Openchain_SubscribeStateDeltasClient interface {
+	Recv() (*BlockStateDelta, error)
+	grpc.ClientStream
+}
+
+type openchainSubscribeStateDeltasClient struct {
+	grpc.ClientStream
+}
+
+func (x *openchainSubscribeStateDeltasClient) Recv() (*BlockStateDelta, error) {
+	m := new(BlockStateDelta)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // Server API for Openchain service
 
 type OpenchainServer interface {
@@ -172,6 +441,22 @@ type OpenchainServer interface {
 	// GetPeers returns a list of all peer nodes currently connected to the target
 	// peer.
 	GetPeers(context.Context, *google_protobuf1.Empty) (*PeersMessage, error)
+	// GetStateHashDetail returns the crypto-hash of the committed world state
+	// along with the crypto-hashes of the children of the root of the state
+	// tree, so that the subtree which diverged between two peers can be found.
+	GetStateHashDetail(context.Context, *google_protobuf1.Empty) (*StateHashDetail, error)
+	// GetStateSnapshot streams the key-values of the committed world state,
+	// possibly restricted to the subtrees of some children of the root of the
+	// state tree, as taken at a single point in time.
+	GetStateSnapshot(*StateSnapshotRequest, Openchain_GetStateSnapshotServer) error
+	// GetBlocks streams the blocks of the given range, both ends included. The
+	// blocks are read as they are sent, hence the stream is paced by the
+	// flow control of the client.
+	GetBlocks(*BlockRange, Openchain_GetBlocksServer) error
+	// SubscribeStateDeltas streams the changes made to the world state by each
+	// block, from the given block on, first those of the blocks already
+	// committed and then those of the blocks as they are committed.
+	SubscribeStateDeltas(*StateDeltasSubscription, Openchain_SubscribeStateDeltasServer) error
 }
 
 func RegisterOpenchainServer(s *grpc.Server, srv OpenchainServer) {
@@ -226,6 +511,81 @@ func _Openchain_GetPeers_Handler(srv interface{}, ctx context.Context, dec func(
 	return out, nil
 }
 
+func _Openchain_GetStateHashDetail_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(google_protobuf1.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(OpenchainServer).GetStateHashDetail(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Openchain_GetStateSnapshot_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StateSnapshotRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(OpenchainServer).GetStateSnapshot(m, &openchainGetStateSnapshotServer{stream})
+}
+
+type Openchain_GetStateSnapshotServer interface {
+	Send(*StateSnapshotChunk) error
+	grpc.ServerStream
+}
+
+type openchainGetStateSnapshotServer struct {
+	grpc.ServerStream
+}
+
+func (x *openchainGetStateSnapshotServer) Send(m *StateSnapshotChunk) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Openchain_GetBlocks_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(BlockRange)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(OpenchainServer).GetBlocks(m, &openchainGetBlocksServer{stream})
+}
+
+type Openchain_GetBlocksServer interface {
+	Send(*Block) error
+	grpc.ServerStream
+}
+
+type openchainGetBlocksServer struct {
+	grpc.ServerStream
+}
+
+func (x *openchainGetBlocksServer) Send(m *Block) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Openchain_SubscribeStateDeltas_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StateDeltasSubscription)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(OpenchainServer).SubscribeStateDeltas(m, &openchainSubscribeStateDeltasServer{stream})
+}
+
+type Openchain_SubscribeStateDeltasServer interface {
+	Send(*BlockStateDelta) error
+	grpc.ServerStream
+}
+
+type openchainSubscribeStateDeltasServer struct {
+	grpc.ServerStream
+}
+
+func (x *openchainSubscribeStateDeltasServer) Send(m *BlockStateDelta) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 var _Openchain_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "protos.Openchain",
 	HandlerType: (*OpenchainServer)(nil),
@@ -246,6 +606,26 @@ var _Openchain_serviceDesc = grpc.ServiceDesc{
 			MethodName: "GetPeers",
 			Handler:    _Openchain_GetPeers_Handler,
 		},
+		{
+			MethodName: "GetStateHashDetail",
+			Handler:    _Openchain_GetStateHashDetail_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "GetStateSnapshot",
+			Handler:       _Openchain_GetStateSnapshot_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "GetBlocks",
+			Handler:       _Openchain_GetBlocks_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "SubscribeStateDeltas",
+			Handler:       _Openchain_SubscribeStateDeltas_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams: []grpc.StreamDesc{},
 }
@@ -47,6 +47,7 @@ const (
 	ChaincodeSpec_GOLANG    ChaincodeSpec_Type = 1
 	ChaincodeSpec_NODE      ChaincodeSpec_Type = 2
 	ChaincodeSpec_CAR       ChaincodeSpec_Type = 3
+	ChaincodeSpec_JAVA      ChaincodeSpec_Type = 4
 )
 
 var ChaincodeSpec_Type_name = map[int32]string{
@@ -54,12 +55,14 @@ var ChaincodeSpec_Type_name = map[int32]string{
 	1: "GOLANG",
 	2: "NODE",
 	3: "CAR",
+	4: "JAVA",
 }
 var ChaincodeSpec_Type_value = map[string]int32{
 	"UNDEFINED": 0,
 	"GOLANG":    1,
 	"NODE":      2,
 	"CAR":       3,
+	"JAVA":      4,
 }
 
 func (x ChaincodeSpec_Type) String() string {
@@ -109,6 +112,12 @@ const (
 	ChaincodeMessage_RANGE_QUERY_STATE       ChaincodeMessage_Type = 17
 	ChaincodeMessage_RANGE_QUERY_STATE_NEXT  ChaincodeMessage_Type = 18
 	ChaincodeMessage_RANGE_QUERY_STATE_CLOSE ChaincodeMessage_Type = 19
+	ChaincodeMessage_GET_QUERY_RESULT        ChaincodeMessage_Type = 20
+	ChaincodeMessage_GET_HISTORY_FOR_KEY     ChaincodeMessage_Type = 21
+	ChaincodeMessage_GET_PRIVATE_STATE       ChaincodeMessage_Type = 22
+	ChaincodeMessage_PUT_PRIVATE_STATE       ChaincodeMessage_Type = 23
+	ChaincodeMessage_DEL_PRIVATE_STATE       ChaincodeMessage_Type = 24
+	ChaincodeMessage_MARK_WRITE_ONCE         ChaincodeMessage_Type = 25
 )
 
 var ChaincodeMessage_Type_name = map[int32]string{
@@ -132,6 +141,12 @@ var ChaincodeMessage_Type_name = map[int32]string{
 	17: "RANGE_QUERY_STATE",
 	18: "RANGE_QUERY_STATE_NEXT",
 	19: "RANGE_QUERY_STATE_CLOSE",
+	20: "GET_QUERY_RESULT",
+	21: "GET_HISTORY_FOR_KEY",
+	22: "GET_PRIVATE_STATE",
+	23: "PUT_PRIVATE_STATE",
+	24: "DEL_PRIVATE_STATE",
+	25: "MARK_WRITE_ONCE",
 }
 var ChaincodeMessage_Type_value = map[string]int32{
 	"UNDEFINED":               0,
@@ -154,6 +169,12 @@ var ChaincodeMessage_Type_value = map[string]int32{
 	"RANGE_QUERY_STATE":       17,
 	"RANGE_QUERY_STATE_NEXT":  18,
 	"RANGE_QUERY_STATE_CLOSE": 19,
+	"GET_QUERY_RESULT":        20,
+	"GET_HISTORY_FOR_KEY":     21,
+	"GET_PRIVATE_STATE":       22,
+	"PUT_PRIVATE_STATE":       23,
+	"DEL_PRIVATE_STATE":       24,
+	"MARK_WRITE_ONCE":         25,
 }
 
 func (x ChaincodeMessage_Type) String() string {
@@ -173,6 +194,9 @@ type ChaincodeID struct {
 	// all other requests will use the name (really a hashcode) generated by
 	// the deploy transaction
 	Name string `protobuf:"bytes,2,opt,name=name" json:"name,omitempty"`
+	// set by an upgrade transaction to the hashcode of the code which replaced
+	// the one deployed under name
+	Version string `protobuf:"bytes,3,opt,name=version" json:"version,omitempty"`
 }
 
 func (m *ChaincodeID) Reset()         { *m = ChaincodeID{} }
@@ -199,6 +223,8 @@ type ChaincodeSpec struct {
 	SecureContext        string               `protobuf:"bytes,5,opt,name=secureContext" json:"secureContext,omitempty"`
 	ConfidentialityLevel ConfidentialityLevel `protobuf:"varint,6,opt,name=confidentialityLevel,enum=protos.ConfidentialityLevel" json:"confidentialityLevel,omitempty"`
 	Metadata             []byte               `protobuf:"bytes,7,opt,name=metadata,proto3" json:"metadata,omitempty"`
+	MemoryLimit          int64                `protobuf:"varint,8,opt,name=memoryLimit" json:"memoryLimit,omitempty"`
+	CpuShares            int64                `protobuf:"varint,9,opt,name=cpuShares" json:"cpuShares,omitempty"`
 }
 
 func (m *ChaincodeSpec) Reset()         { *m = ChaincodeSpec{} }
@@ -227,6 +253,15 @@ type ChaincodeDeploymentSpec struct {
 	EffectiveDate *google_protobuf.Timestamp                   `protobuf:"bytes,2,opt,name=effectiveDate" json:"effectiveDate,omitempty"`
 	CodePackage   []byte                                       `protobuf:"bytes,3,opt,name=codePackage,proto3" json:"codePackage,omitempty"`
 	ExecEnv       ChaincodeDeploymentSpec_ExecutionEnvironment `protobuf:"varint,4,opt,name=execEnv,enum=protos.ChaincodeDeploymentSpec_ExecutionEnvironment" json:"execEnv,omitempty"`
+	// Policy the endorsements of the transactions of the chaincode have to
+	// satisfy, recorded on the ledger when the chaincode is instantiated.
+	EndorsementPolicy string `protobuf:"bytes,5,opt,name=endorsementPolicy" json:"endorsementPolicy,omitempty"`
+	// Enrollment IDs of the validating peers authorized to read the state of
+	// the chaincode, which is then encrypted with a key of its own.
+	StatePeers []string `protobuf:"bytes,6,rep,name=statePeers" json:"statePeers,omitempty"`
+	// Key encrypting the state of the chaincode, wrapped for each of the
+	// statePeers by the peer deploying it.
+	StateKeys *ChaincodeStateKeys `protobuf:"bytes,7,opt,name=stateKeys" json:"stateKeys,omitempty"`
 }
 
 func (m *ChaincodeDeploymentSpec) Reset()         { *m = ChaincodeDeploymentSpec{} }
@@ -247,9 +282,75 @@ func (m *ChaincodeDeploymentSpec) GetEffectiveDate() *google_protobuf.Timestamp
 	return nil
 }
 
+func (m *ChaincodeDeploymentSpec) GetStateKeys() *ChaincodeStateKeys {
+	if m != nil {
+		return m.StateKeys
+	}
+	return nil
+}
+
+// Key encrypting the state values of a chaincode, wrapped for each of the
+// validating peers authorized to read them.
+type ChaincodeStateKeys struct {
+	Keys []*ChaincodeStateKeys_WrappedKey `protobuf:"bytes,1,rep,name=keys" json:"keys,omitempty"`
+}
+
+func (m *ChaincodeStateKeys) Reset()         { *m = ChaincodeStateKeys{} }
+func (m *ChaincodeStateKeys) String() string { return proto.CompactTextString(m) }
+func (*ChaincodeStateKeys) ProtoMessage()    {}
+
+func (m *ChaincodeStateKeys) GetKeys() []*ChaincodeStateKeys_WrappedKey {
+	if m != nil {
+		return m.Keys
+	}
+	return nil
+}
+
+type ChaincodeStateKeys_WrappedKey struct {
+	// enrollment ID of the peer
+	Peer string `protobuf:"bytes,1,opt,name=peer" json:"peer,omitempty"`
+	// hash of the enrollment certificate of the peer, its PKI-ID
+	PkiID []byte `protobuf:"bytes,2,opt,name=pkiID,proto3" json:"pkiID,omitempty"`
+	// key encrypted with ECIES under the enrollment public key of the peer
+	Key []byte `protobuf:"bytes,3,opt,name=key,proto3" json:"key,omitempty"`
+}
+
+func (m *ChaincodeStateKeys_WrappedKey) Reset()         { *m = ChaincodeStateKeys_WrappedKey{} }
+func (m *ChaincodeStateKeys_WrappedKey) String() string { return proto.CompactTextString(m) }
+func (*ChaincodeStateKeys_WrappedKey) ProtoMessage()    {}
+
+// Lifecycle record of an instantiated chaincode, kept by the ledger.
+type ChaincodeInstantiation struct {
+	Name string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	// starts at 1 and is incremented by each upgrade of the chaincode
+	Version uint64 `protobuf:"varint,2,opt,name=version" json:"version,omitempty"`
+	// hash of the code package the chaincode runs
+	CodeHash          string `protobuf:"bytes,3,opt,name=codeHash" json:"codeHash,omitempty"`
+	EndorsementPolicy string `protobuf:"bytes,4,opt,name=endorsementPolicy" json:"endorsementPolicy,omitempty"`
+	// deploy or upgrade transaction which carries the code package
+	TxUUID string `protobuf:"bytes,5,opt,name=txUUID" json:"txUUID,omitempty"`
+	// keys of the state of the chaincode, if encrypted
+	StateKeys *ChaincodeStateKeys `protobuf:"bytes,6,opt,name=stateKeys" json:"stateKeys,omitempty"`
+}
+
+func (m *ChaincodeInstantiation) Reset()         { *m = ChaincodeInstantiation{} }
+func (m *ChaincodeInstantiation) String() string { return proto.CompactTextString(m) }
+func (*ChaincodeInstantiation) ProtoMessage()    {}
+
+func (m *ChaincodeInstantiation) GetStateKeys() *ChaincodeStateKeys {
+	if m != nil {
+		return m.StateKeys
+	}
+	return nil
+}
+
 // Carries the chaincode function and its arguments.
 type ChaincodeInvocationSpec struct {
 	ChaincodeSpec *ChaincodeSpec `protobuf:"bytes,1,opt,name=chaincodeSpec" json:"chaincodeSpec,omitempty"`
+	// data passed to the chaincode but never stored on the ledger
+	Transient []*TransientField `protobuf:"bytes,3,rep,name=transient" json:"transient,omitempty"`
+	// expiry of the invoke transaction, see TransactionExpiry
+	Expiry *TransactionExpiry `protobuf:"bytes,4,opt,name=expiry" json:"expiry,omitempty"`
 }
 
 func (m *ChaincodeInvocationSpec) Reset()         { *m = ChaincodeInvocationSpec{} }
@@ -263,6 +364,51 @@ func (m *ChaincodeInvocationSpec) GetChaincodeSpec() *ChaincodeSpec {
 	return nil
 }
 
+func (m *ChaincodeInvocationSpec) GetTransient() []*TransientField {
+	if m != nil {
+		return m.Transient
+	}
+	return nil
+}
+
+func (m *ChaincodeInvocationSpec) GetExpiry() *TransactionExpiry {
+	if m != nil {
+		return m.Expiry
+	}
+	return nil
+}
+
+// TransientField is a named piece of data passed to a chaincode along with a
+// transaction, but not stored on the ledger with it.
+type TransientField struct {
+	Key   string `protobuf:"bytes,1,opt,name=key" json:"key,omitempty"`
+	Value []byte `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+}
+
+func (m *TransientField) Reset()         { *m = TransientField{} }
+func (m *TransientField) String() string { return proto.CompactTextString(m) }
+func (*TransientField) ProtoMessage()    {}
+
+// TransactionExpiry bounds when a transaction can be executed: it is dropped
+// unless executed in a block whose number is below blockHeight, if set, and
+// in a batch whose time is before timestamp, if set. The time of a batch is
+// the latest timestamp of its transactions.
+type TransactionExpiry struct {
+	BlockHeight uint64                     `protobuf:"varint,1,opt,name=blockHeight" json:"blockHeight,omitempty"`
+	Timestamp   *google_protobuf.Timestamp `protobuf:"bytes,2,opt,name=timestamp" json:"timestamp,omitempty"`
+}
+
+func (m *TransactionExpiry) Reset()         { *m = TransactionExpiry{} }
+func (m *TransactionExpiry) String() string { return proto.CompactTextString(m) }
+func (*TransactionExpiry) ProtoMessage()    {}
+
+func (m *TransactionExpiry) GetTimestamp() *google_protobuf.Timestamp {
+	if m != nil {
+		return m.Timestamp
+	}
+	return nil
+}
+
 // This structure contain transaction data that we send to the chaincode
 // container shim and allow the chaincode to access through the shim interface.
 // TODO: Consider remove this message and just pass the transaction object
@@ -275,6 +421,7 @@ type ChaincodeSecurityContext struct {
 	Metadata       []byte                     `protobuf:"bytes,5,opt,name=metadata,proto3" json:"metadata,omitempty"`
 	ParentMetadata []byte                     `protobuf:"bytes,6,opt,name=parentMetadata,proto3" json:"parentMetadata,omitempty"`
 	TxTimestamp    *google_protobuf.Timestamp `protobuf:"bytes,7,opt,name=txTimestamp" json:"txTimestamp,omitempty"`
+	Transient      []*TransientField          `protobuf:"bytes,8,rep,name=transient" json:"transient,omitempty"`
 }
 
 func (m *ChaincodeSecurityContext) Reset()         { *m = ChaincodeSecurityContext{} }
@@ -288,12 +435,20 @@ func (m *ChaincodeSecurityContext) GetTxTimestamp() *google_protobuf.Timestamp {
 	return nil
 }
 
+func (m *ChaincodeSecurityContext) GetTransient() []*TransientField {
+	if m != nil {
+		return m.Transient
+	}
+	return nil
+}
+
 type ChaincodeMessage struct {
 	Type            ChaincodeMessage_Type      `protobuf:"varint,1,opt,name=type,enum=protos.ChaincodeMessage_Type" json:"type,omitempty"`
 	Timestamp       *google_protobuf.Timestamp `protobuf:"bytes,2,opt,name=timestamp" json:"timestamp,omitempty"`
 	Payload         []byte                     `protobuf:"bytes,3,opt,name=payload,proto3" json:"payload,omitempty"`
 	Uuid            string                     `protobuf:"bytes,4,opt,name=uuid" json:"uuid,omitempty"`
 	SecurityContext *ChaincodeSecurityContext  `protobuf:"bytes,5,opt,name=securityContext" json:"securityContext,omitempty"`
+	ChaincodeEvent  *ChaincodeEvent            `protobuf:"bytes,6,opt,name=chaincodeEvent" json:"chaincodeEvent,omitempty"`
 }
 
 func (m *ChaincodeMessage) Reset()         { *m = ChaincodeMessage{} }
@@ -314,6 +469,27 @@ func (m *ChaincodeMessage) GetSecurityContext() *ChaincodeSecurityContext {
 	return nil
 }
 
+func (m *ChaincodeMessage) GetChaincodeEvent() *ChaincodeEvent {
+	if m != nil {
+		return m.ChaincodeEvent
+	}
+	return nil
+}
+
+// ChaincodeEvent is a named event set by a chaincode through the shim. It is
+// returned to the peer with the completed transaction and persisted with the
+// transaction result in the block.
+type ChaincodeEvent struct {
+	ChaincodeID string `protobuf:"bytes,1,opt,name=chaincodeID" json:"chaincodeID,omitempty"`
+	TxID        string `protobuf:"bytes,2,opt,name=txID" json:"txID,omitempty"`
+	EventName   string `protobuf:"bytes,3,opt,name=eventName" json:"eventName,omitempty"`
+	Payload     []byte `protobuf:"bytes,4,opt,name=payload,proto3" json:"payload,omitempty"`
+}
+
+func (m *ChaincodeEvent) Reset()         { *m = ChaincodeEvent{} }
+func (m *ChaincodeEvent) String() string { return proto.CompactTextString(m) }
+func (*ChaincodeEvent) ProtoMessage()    {}
+
 type PutStateInfo struct {
 	Key   string `protobuf:"bytes,1,opt,name=key" json:"key,omitempty"`
 	Value []byte `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
@@ -323,15 +499,77 @@ func (m *PutStateInfo) Reset()         { *m = PutStateInfo{} }
 func (m *PutStateInfo) String() string { return proto.CompactTextString(m) }
 func (*PutStateInfo) ProtoMessage()    {}
 
+// MarkWriteOnce marks a key of the chaincode as write-once or, if prefix is
+// set, all the keys of the chaincode starting with the key.
+type MarkWriteOnce struct {
+	Key    string `protobuf:"bytes,1,opt,name=key" json:"key,omitempty"`
+	Prefix bool   `protobuf:"varint,2,opt,name=prefix" json:"prefix,omitempty"`
+}
+
+func (m *MarkWriteOnce) Reset()         { *m = MarkWriteOnce{} }
+func (m *MarkWriteOnce) String() string { return proto.CompactTextString(m) }
+func (*MarkWriteOnce) ProtoMessage()    {}
+
 type RangeQueryState struct {
 	StartKey string `protobuf:"bytes,1,opt,name=startKey" json:"startKey,omitempty"`
 	EndKey   string `protobuf:"bytes,2,opt,name=endKey" json:"endKey,omitempty"`
+	// return the keys in lexical order
+	Ordered bool `protobuf:"varint,3,opt,name=ordered" json:"ordered,omitempty"`
 }
 
 func (m *RangeQueryState) Reset()         { *m = RangeQueryState{} }
 func (m *RangeQueryState) String() string { return proto.CompactTextString(m) }
 func (*RangeQueryState) ProtoMessage()    {}
 
+// GetQueryResult is a rich query of the committed values of the chaincode,
+// in the syntax of the state implementation. The results are paged as the
+// results of a RangeQueryState.
+type GetQueryResult struct {
+	Query string `protobuf:"bytes,1,opt,name=query" json:"query,omitempty"`
+}
+
+func (m *GetQueryResult) Reset()         { *m = GetQueryResult{} }
+func (m *GetQueryResult) String() string { return proto.CompactTextString(m) }
+func (*GetQueryResult) ProtoMessage()    {}
+
+// GetHistoryForKey asks for the values taken by a key of the chaincode in the
+// committed blocks for which the state delta is retained.
+type GetHistoryForKey struct {
+	Key string `protobuf:"bytes,1,opt,name=key" json:"key,omitempty"`
+}
+
+func (m *GetHistoryForKey) Reset()         { *m = GetHistoryForKey{} }
+func (m *GetHistoryForKey) String() string { return proto.CompactTextString(m) }
+func (*GetHistoryForKey) ProtoMessage()    {}
+
+// KeyModification is the value of a key at the end of a committed block,
+// along with the last transaction of the block which changed the key.
+type KeyModification struct {
+	TxUUID      string `protobuf:"bytes,1,opt,name=txUUID" json:"txUUID,omitempty"`
+	BlockNumber uint64 `protobuf:"varint,2,opt,name=blockNumber" json:"blockNumber,omitempty"`
+	Value       []byte `protobuf:"bytes,3,opt,name=value,proto3" json:"value,omitempty"`
+	IsDelete    bool   `protobuf:"varint,4,opt,name=isDelete" json:"isDelete,omitempty"`
+}
+
+func (m *KeyModification) Reset()         { *m = KeyModification{} }
+func (m *KeyModification) String() string { return proto.CompactTextString(m) }
+func (*KeyModification) ProtoMessage()    {}
+
+type GetHistoryForKeyResponse struct {
+	Modifications []*KeyModification `protobuf:"bytes,1,rep,name=modifications" json:"modifications,omitempty"`
+}
+
+func (m *GetHistoryForKeyResponse) Reset()         { *m = GetHistoryForKeyResponse{} }
+func (m *GetHistoryForKeyResponse) String() string { return proto.CompactTextString(m) }
+func (*GetHistoryForKeyResponse) ProtoMessage()    {}
+
+func (m *GetHistoryForKeyResponse) GetModifications() []*KeyModification {
+	if m != nil {
+		return m.Modifications
+	}
+	return nil
+}
+
 type RangeQueryStateNext struct {
 	ID string `protobuf:"bytes,1,opt,name=ID" json:"ID,omitempty"`
 }
@@ -41,6 +41,45 @@ func (x BuildResult_StatusCode) String() string {
 	return proto.EnumName(BuildResult_StatusCode_name, int32(x))
 }
 
+type TransactionStatus_StatusCode int32
+
+const (
+	// Neither committed nor submitted through this peer recently.
+	TransactionStatus_UNKNOWN TransactionStatus_StatusCode = 0
+	// Submitted, waiting to be ordered and committed.
+	TransactionStatus_PENDING TransactionStatus_StatusCode = 1
+	// Not accepted by the validator, it will not be committed.
+	TransactionStatus_REJECTED TransactionStatus_StatusCode = 2
+	// Committed and executed successfully.
+	TransactionStatus_SUCCEEDED TransactionStatus_StatusCode = 3
+	// Committed, but its execution failed and its changes were discarded.
+	TransactionStatus_FAILED TransactionStatus_StatusCode = 4
+	// Committed, with an outcome not recorded by this peer (block
+	// received by state transfer).
+	TransactionStatus_COMMITTED TransactionStatus_StatusCode = 5
+)
+
+var TransactionStatus_StatusCode_name = map[int32]string{
+	0: "UNKNOWN",
+	1: "PENDING",
+	2: "REJECTED",
+	3: "SUCCEEDED",
+	4: "FAILED",
+	5: "COMMITTED",
+}
+var TransactionStatus_StatusCode_value = map[string]int32{
+	"UNKNOWN":   0,
+	"PENDING":   1,
+	"REJECTED":  2,
+	"SUCCEEDED": 3,
+	"FAILED":    4,
+	"COMMITTED": 5,
+}
+
+func (x TransactionStatus_StatusCode) String() string {
+	return proto.EnumName(TransactionStatus_StatusCode_name, int32(x))
+}
+
 // Secret is a temporary object to establish security with the Devops.
 // A better solution using certificate will be introduced later
 type Secret struct {
@@ -69,8 +108,59 @@ func (m *BuildResult) GetDeploymentSpec() *ChaincodeDeploymentSpec {
 	return nil
 }
 
+// Specifies what is read from the ledger of the peer: blockNumber for
+// GetBlockByNumber and GetStateDelta, uuid for GetTransactionByUUID, and
+// chaincodeID and key for GetState. secureContext identifies the client when
+// security is enabled.
+type LedgerQuery struct {
+	SecureContext string `protobuf:"bytes,1,opt,name=secureContext" json:"secureContext,omitempty"`
+	BlockNumber   uint64 `protobuf:"varint,2,opt,name=blockNumber" json:"blockNumber,omitempty"`
+	Uuid          string `protobuf:"bytes,3,opt,name=uuid" json:"uuid,omitempty"`
+	ChaincodeID   string `protobuf:"bytes,4,opt,name=chaincodeID" json:"chaincodeID,omitempty"`
+	Key           string `protobuf:"bytes,5,opt,name=key" json:"key,omitempty"`
+}
+
+func (m *LedgerQuery) Reset()         { *m = LedgerQuery{} }
+func (m *LedgerQuery) String() string { return proto.CompactTextString(m) }
+func (*LedgerQuery) ProtoMessage()    {}
+
+// The transaction submitted by a DevopsV2 Deploy or Invoke. chaincodeID is
+// the ID of the chaincode deployed, named after its code package.
+type SubmitResponse struct {
+	Uuid        string       `protobuf:"bytes,1,opt,name=uuid" json:"uuid,omitempty"`
+	ChaincodeID *ChaincodeID `protobuf:"bytes,2,opt,name=chaincodeID" json:"chaincodeID,omitempty"`
+}
+
+func (m *SubmitResponse) Reset()         { *m = SubmitResponse{} }
+func (m *SubmitResponse) String() string { return proto.CompactTextString(m) }
+func (*SubmitResponse) ProtoMessage()    {}
+
+func (m *SubmitResponse) GetChaincodeID() *ChaincodeID {
+	if m != nil {
+		return m.ChaincodeID
+	}
+	return nil
+}
+
+// The status of a transaction. blockNumber and txIndex tell where a committed
+// transaction is in the blockchain, errorCode and error why its execution
+// failed.
+type TransactionStatus struct {
+	Uuid        string                       `protobuf:"bytes,1,opt,name=uuid" json:"uuid,omitempty"`
+	Status      TransactionStatus_StatusCode `protobuf:"varint,2,opt,name=status,enum=protos.TransactionStatus_StatusCode" json:"status,omitempty"`
+	BlockNumber uint64                       `protobuf:"varint,3,opt,name=blockNumber" json:"blockNumber,omitempty"`
+	TxIndex     uint64                       `protobuf:"varint,4,opt,name=txIndex" json:"txIndex,omitempty"`
+	ErrorCode   uint32                       `protobuf:"varint,5,opt,name=errorCode" json:"errorCode,omitempty"`
+	Error       string                       `protobuf:"bytes,6,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *TransactionStatus) Reset()         { *m = TransactionStatus{} }
+func (m *TransactionStatus) String() string { return proto.CompactTextString(m) }
+func (*TransactionStatus) ProtoMessage()    {}
+
 func init() {
 	proto.RegisterEnum("protos.BuildResult_StatusCode", BuildResult_StatusCode_name, BuildResult_StatusCode_value)
+	proto.RegisterEnum("protos.TransactionStatus_StatusCode", TransactionStatus_StatusCode_name, TransactionStatus_StatusCode_value)
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -87,10 +177,41 @@ type DevopsClient interface {
 	Build(ctx context.Context, in *ChaincodeSpec, opts ...grpc.CallOption) (*ChaincodeDeploymentSpec, error)
 	// Deploy the chaincode package to the chain.
 	Deploy(ctx context.Context, in *ChaincodeSpec, opts ...grpc.CallOption) (*ChaincodeDeploymentSpec, error)
+	// Install the chaincode package on the peer, without deploying it.
+	Install(ctx context.Context, in *ChaincodeSpec, opts ...grpc.CallOption) (*ChaincodeDeploymentSpec, error)
+	// Instantiate on the chain the chaincode installed under the given name.
+	Instantiate(ctx context.Context, in *ChaincodeDeploymentSpec, opts ...grpc.CallOption) (*ChaincodeDeploymentSpec, error)
+	// Upgrade a deployed chaincode to the supplied package, keeping its state.
+	Upgrade(ctx context.Context, in *ChaincodeSpec, opts ...grpc.CallOption) (*ChaincodeDeploymentSpec, error)
 	// Invoke chaincode.
 	Invoke(ctx context.Context, in *ChaincodeInvocationSpec, opts ...grpc.CallOption) (*Response, error)
 	// Invoke chaincode.
 	Query(ctx context.Context, in *ChaincodeInvocationSpec, opts ...grpc.CallOption) (*Response, error)
+	// Query chaincode against the committed state of a validator, without a
+	// transaction, returning the result signed by the validator.
+	ReadOnlyQuery(ctx context.Context, in *ChaincodeInvocationSpec, opts ...grpc.CallOption) (*QueryResponse, error)
+	// Simulate the invocation of chaincode against the committed state of a
+	// validator, without submitting the transaction.
+	SimulateTransaction(ctx context.Context, in *ChaincodeInvocationSpec, opts ...grpc.CallOption) (*SimulationResult, error)
+	// Submit for ordering the changes of an invocation simulated and endorsed
+	// by validating peers, which are applied if the state it read is unchanged.
+	SubmitEndorsedTransaction(ctx context.Context, in *EndorsedTransaction, opts ...grpc.CallOption) (*Response, error)
+	// Submit a CONFIG transaction updating the configuration of the network
+	// recorded by the ledger, such as the certificate revocation list.
+	UpdateConfig(ctx context.Context, in *ConfigUpdate, opts ...grpc.CallOption) (*Response, error)
+	// Get the height and the hashes of the last blocks of the blockchain of the peer.
+	GetBlockchainInfo(ctx context.Context, in *LedgerQuery, opts ...grpc.CallOption) (*BlockchainInfo, error)
+	// Get the block of the given blockNumber.
+	GetBlockByNumber(ctx context.Context, in *LedgerQuery, opts ...grpc.CallOption) (*Block, error)
+	// Get the committed transaction of the given uuid.
+	GetTransactionByUUID(ctx context.Context, in *LedgerQuery, opts ...grpc.CallOption) (*Transaction, error)
+	// Get the committed value of the key of the chaincode in the world state.
+	GetState(ctx context.Context, in *LedgerQuery, opts ...grpc.CallOption) (*StateValue, error)
+	// Get the changes made to the world state by the block of the given blockNumber.
+	GetStateDelta(ctx context.Context, in *LedgerQuery, opts ...grpc.CallOption) (*BlockStateDelta, error)
+	// Get the crypto-hash of the committed world state along with the crypto-hashes
+	// of the children of the root of the state tree.
+	GetStateHashDetail(ctx context.Context, in *LedgerQuery, opts ...grpc.CallOption) (*StateHashDetail, error)
 }
 
 type devopsClient struct {
@@ -128,6 +249,33 @@ func (c *devopsClient) Deploy(ctx context.Context, in *ChaincodeSpec, opts ...gr
 	return out, nil
 }
 
+func (c *devopsClient) Install(ctx context.Context, in *ChaincodeSpec, opts ...grpc.CallOption) (*ChaincodeDeploymentSpec, error) {
+	out := new(ChaincodeDeploymentSpec)
+	err := grpc.Invoke(ctx, "/protos.Devops/Install", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *devopsClient) Instantiate(ctx context.Context, in *ChaincodeDeploymentSpec, opts ...grpc.CallOption) (*ChaincodeDeploymentSpec, error) {
+	out := new(ChaincodeDeploymentSpec)
+	err := grpc.Invoke(ctx, "/protos.Devops/Instantiate", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *devopsClient) Upgrade(ctx context.Context, in *ChaincodeSpec, opts ...grpc.CallOption) (*ChaincodeDeploymentSpec, error) {
+	out := new(ChaincodeDeploymentSpec)
+	err := grpc.Invoke(ctx, "/protos.Devops/Upgrade", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *devopsClient) Invoke(ctx context.Context, in *ChaincodeInvocationSpec, opts ...grpc.CallOption) (*Response, error) {
 	out := new(Response)
 	err := grpc.Invoke(ctx, "/protos.Devops/Invoke", in, out, c.cc, opts...)
@@ -146,6 +294,96 @@ func (c *devopsClient) Query(ctx context.Context, in *ChaincodeInvocationSpec, o
 	return out, nil
 }
 
+func (c *devopsClient) ReadOnlyQuery(ctx context.Context, in *ChaincodeInvocationSpec, opts ...grpc.CallOption) (*QueryResponse, error) {
+	out := new(QueryResponse)
+	err := grpc.Invoke(ctx, "/protos.Devops/ReadOnlyQuery", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *devopsClient) SimulateTransaction(ctx context.Context, in *ChaincodeInvocationSpec, opts ...grpc.CallOption) (*SimulationResult, error) {
+	out := new(SimulationResult)
+	err := grpc.Invoke(ctx, "/protos.Devops/SimulateTransaction", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *devopsClient) SubmitEndorsedTransaction(ctx context.Context, in *EndorsedTransaction, opts ...grpc.CallOption) (*Response, error) {
+	out := new(Response)
+	err := grpc.Invoke(ctx, "/protos.Devops/SubmitEndorsedTransaction", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *devopsClient) UpdateConfig(ctx context.Context, in *ConfigUpdate, opts ...grpc.CallOption) (*Response, error) {
+	out := new(Response)
+	err := grpc.Invoke(ctx, "/protos.Devops/UpdateConfig", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *devopsClient) GetBlockchainInfo(ctx context.Context, in *LedgerQuery, opts ...grpc.CallOption) (*BlockchainInfo, error) {
+	out := new(BlockchainInfo)
+	err := grpc.Invoke(ctx, "/protos.Devops/GetBlockchainInfo", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *devopsClient) GetBlockByNumber(ctx context.Context, in *LedgerQuery, opts ...grpc.CallOption) (*Block, error) {
+	out := new(Block)
+	err := grpc.Invoke(ctx, "/protos.Devops/GetBlockByNumber", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *devopsClient) GetTransactionByUUID(ctx context.Context, in *LedgerQuery, opts ...grpc.CallOption) (*Transaction, error) {
+	out := new(Transaction)
+	err := grpc.Invoke(ctx, "/protos.Devops/GetTransactionByUUID", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *devopsClient) GetState(ctx context.Context, in *LedgerQuery, opts ...grpc.CallOption) (*StateValue, error) {
+	out := new(StateValue)
+	err := grpc.Invoke(ctx, "/protos.Devops/GetState", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *devopsClient) GetStateDelta(ctx context.Context, in *LedgerQuery, opts ...grpc.CallOption) (*BlockStateDelta, error) {
+	out := new(BlockStateDelta)
+	err := grpc.Invoke(ctx, "/protos.Devops/GetStateDelta", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *devopsClient) GetStateHashDetail(ctx context.Context, in *LedgerQuery, opts ...grpc.CallOption) (*StateHashDetail, error) {
+	out := new(StateHashDetail)
+	err := grpc.Invoke(ctx, "/protos.Devops/GetStateHashDetail", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // Server API for Devops service
 
 type DevopsServer interface {
@@ -156,10 +394,41 @@ type DevopsServer interface {
 	Build(context.Context, *ChaincodeSpec) (*ChaincodeDeploymentSpec, error)
 	// Deploy the chaincode package to the chain.
 	Deploy(context.Context, *ChaincodeSpec) (*ChaincodeDeploymentSpec, error)
+	// Install the chaincode package on the peer, without deploying it.
+	Install(context.Context, *ChaincodeSpec) (*ChaincodeDeploymentSpec, error)
+	// Instantiate on the chain the chaincode installed under the given name.
+	Instantiate(context.Context, *ChaincodeDeploymentSpec) (*ChaincodeDeploymentSpec, error)
+	// Upgrade a deployed chaincode to the supplied package, keeping its state.
+	Upgrade(context.Context, *ChaincodeSpec) (*ChaincodeDeploymentSpec, error)
 	// Invoke chaincode.
 	Invoke(context.Context, *ChaincodeInvocationSpec) (*Response, error)
 	// Invoke chaincode.
 	Query(context.Context, *ChaincodeInvocationSpec) (*Response, error)
+	// Query chaincode against the committed state of a validator, without a
+	// transaction, returning the result signed by the validator.
+	ReadOnlyQuery(context.Context, *ChaincodeInvocationSpec) (*QueryResponse, error)
+	// Simulate the invocation of chaincode against the committed state of a
+	// validator, without submitting the transaction.
+	SimulateTransaction(context.Context, *ChaincodeInvocationSpec) (*SimulationResult, error)
+	// Submit for ordering the changes of an invocation simulated and endorsed
+	// by validating peers, which are applied if the state it read is unchanged.
+	SubmitEndorsedTransaction(context.Context, *EndorsedTransaction) (*Response, error)
+	// Submit a CONFIG transaction updating the configuration of the network
+	// recorded by the ledger, such as the certificate revocation list.
+	UpdateConfig(context.Context, *ConfigUpdate) (*Response, error)
+	// Get the height and the hashes of the last blocks of the blockchain of the peer.
+	GetBlockchainInfo(context.Context, *LedgerQuery) (*BlockchainInfo, error)
+	// Get the block of the given blockNumber.
+	GetBlockByNumber(context.Context, *LedgerQuery) (*Block, error)
+	// Get the committed transaction of the given uuid.
+	GetTransactionByUUID(context.Context, *LedgerQuery) (*Transaction, error)
+	// Get the committed value of the key of the chaincode in the world state.
+	GetState(context.Context, *LedgerQuery) (*StateValue, error)
+	// Get the changes made to the world state by the block of the given blockNumber.
+	GetStateDelta(context.Context, *LedgerQuery) (*BlockStateDelta, error)
+	// Get the crypto-hash of the committed world state along with the crypto-hashes
+	// of the children of the root of the state tree.
+	GetStateHashDetail(context.Context, *LedgerQuery) (*StateHashDetail, error)
 }
 
 func RegisterDevopsServer(s *grpc.Server, srv DevopsServer) {
@@ -202,6 +471,42 @@ func _Devops_Deploy_Handler(srv interface{}, ctx context.Context, dec func(inter
 	return out, nil
 }
 
+func _Devops_Install_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(ChaincodeSpec)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(DevopsServer).Install(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Devops_Instantiate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(ChaincodeDeploymentSpec)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(DevopsServer).Instantiate(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Devops_Upgrade_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(ChaincodeSpec)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(DevopsServer).Upgrade(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func _Devops_Invoke_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
 	in := new(ChaincodeInvocationSpec)
 	if err := dec(in); err != nil {
@@ -226,6 +531,126 @@ func _Devops_Query_Handler(srv interface{}, ctx context.Context, dec func(interf
 	return out, nil
 }
 
+func _Devops_ReadOnlyQuery_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(ChaincodeInvocationSpec)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(DevopsServer).ReadOnlyQuery(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Devops_SimulateTransaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(ChaincodeInvocationSpec)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(DevopsServer).SimulateTransaction(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Devops_SubmitEndorsedTransaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(EndorsedTransaction)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(DevopsServer).SubmitEndorsedTransaction(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Devops_UpdateConfig_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(ConfigUpdate)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(DevopsServer).UpdateConfig(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Devops_GetBlockchainInfo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(LedgerQuery)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(DevopsServer).GetBlockchainInfo(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Devops_GetBlockByNumber_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(LedgerQuery)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(DevopsServer).GetBlockByNumber(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Devops_GetTransactionByUUID_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(LedgerQuery)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(DevopsServer).GetTransactionByUUID(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Devops_GetState_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(LedgerQuery)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(DevopsServer).GetState(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Devops_GetStateDelta_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(LedgerQuery)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(DevopsServer).GetStateDelta(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Devops_GetStateHashDetail_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(LedgerQuery)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(DevopsServer).GetStateHashDetail(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 var _Devops_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "protos.Devops",
 	HandlerType: (*DevopsServer)(nil),
@@ -242,6 +667,18 @@ var _Devops_serviceDesc = grpc.ServiceDesc{
 			MethodName: "Deploy",
 			Handler:    _Devops_Deploy_Handler,
 		},
+		{
+			MethodName: "Install",
+			Handler:    _Devops_Install_Handler,
+		},
+		{
+			MethodName: "Instantiate",
+			Handler:    _Devops_Instantiate_Handler,
+		},
+		{
+			MethodName: "Upgrade",
+			Handler:    _Devops_Upgrade_Handler,
+		},
 		{
 			MethodName: "Invoke",
 			Handler:    _Devops_Invoke_Handler,
@@ -250,6 +687,228 @@ var _Devops_serviceDesc = grpc.ServiceDesc{
 			MethodName: "Query",
 			Handler:    _Devops_Query_Handler,
 		},
+		{
+			MethodName: "ReadOnlyQuery",
+			Handler:    _Devops_ReadOnlyQuery_Handler,
+		},
+		{
+			MethodName: "SimulateTransaction",
+			Handler:    _Devops_SimulateTransaction_Handler,
+		},
+		{
+			MethodName: "SubmitEndorsedTransaction",
+			Handler:    _Devops_SubmitEndorsedTransaction_Handler,
+		},
+		{
+			MethodName: "UpdateConfig",
+			Handler:    _Devops_UpdateConfig_Handler,
+		},
+		{
+			MethodName: "GetBlockchainInfo",
+			Handler:    _Devops_GetBlockchainInfo_Handler,
+		},
+		{
+			MethodName: "GetBlockByNumber",
+			Handler:    _Devops_GetBlockByNumber_Handler,
+		},
+		{
+			MethodName: "GetTransactionByUUID",
+			Handler:    _Devops_GetTransactionByUUID_Handler,
+		},
+		{
+			MethodName: "GetState",
+			Handler:    _Devops_GetState_Handler,
+		},
+		{
+			MethodName: "GetStateDelta",
+			Handler:    _Devops_GetStateDelta_Handler,
+		},
+		{
+			MethodName: "GetStateHashDetail",
+			Handler:    _Devops_GetStateHashDetail_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{},
 }
+
+// Client API for DevopsV2 service
+
+type DevopsV2Client interface {
+	// Submit the deploy transaction of the chaincode package.
+	Deploy(ctx context.Context, in *ChaincodeSpec, opts ...grpc.CallOption) (*SubmitResponse, error)
+	// Submit the invoke transaction of the chaincode.
+	Invoke(ctx context.Context, in *ChaincodeInvocationSpec, opts ...grpc.CallOption) (*SubmitResponse, error)
+	// Get the status of the transaction of the given uuid.
+	GetTransactionStatus(ctx context.Context, in *LedgerQuery, opts ...grpc.CallOption) (*TransactionStatus, error)
+	// Stream the status of the transaction of the given uuid, then each of
+	// its changes until the transaction is rejected or committed.
+	WatchTransactionStatus(ctx context.Context, in *LedgerQuery, opts ...grpc.CallOption) (DevopsV2_WatchTransactionStatusClient, error)
+}
+
+type devopsV2Client struct {
+	cc *grpc.ClientConn
+}
+
+func NewDevopsV2Client(cc *grpc.ClientConn) DevopsV2Client {
+	return &devopsV2Client{cc}
+}
+
+func (c *devopsV2Client) Deploy(ctx context.Context, in *ChaincodeSpec, opts ...grpc.CallOption) (*SubmitResponse, error) {
+	out := new(SubmitResponse)
+	err := grpc.Invoke(ctx, "/protos.DevopsV2/Deploy", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *devopsV2Client) Invoke(ctx context.Context, in *ChaincodeInvocationSpec, opts ...grpc.CallOption) (*SubmitResponse, error) {
+	out := new(SubmitResponse)
+	err := grpc.Invoke(ctx, "/protos.DevopsV2/Invoke", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *devopsV2Client) GetTransactionStatus(ctx context.Context, in *LedgerQuery, opts ...grpc.CallOption) (*TransactionStatus, error) {
+	out := new(TransactionStatus)
+	err := grpc.Invoke(ctx, "/protos.DevopsV2/GetTransactionStatus", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *devopsV2Client) WatchTransactionStatus(ctx context.Context, in *LedgerQuery, opts ...grpc.CallOption) (DevopsV2_WatchTransactionStatusClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_DevopsV2_serviceDesc.Streams[0], c.cc, "/protos.DevopsV2/WatchTransactionStatus", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &devopsV2WatchTransactionStatusClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type DevopsV2_WatchTransactionStatusClient interface {
+	Recv() (*TransactionStatus, error)
+	grpc.ClientStream
+}
+
+type devopsV2WatchTransactionStatusClient struct {
+	grpc.ClientStream
+}
+
+func (x *devopsV2WatchTransactionStatusClient) Recv() (*TransactionStatus, error) {
+	m := new(TransactionStatus)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Server API for DevopsV2 service
+
+type DevopsV2Server interface {
+	// Submit the deploy transaction of the chaincode package.
+	Deploy(context.Context, *ChaincodeSpec) (*SubmitResponse, error)
+	// Submit the invoke transaction of the chaincode.
+	Invoke(context.Context, *ChaincodeInvocationSpec) (*SubmitResponse, error)
+	// Get the status of the transaction of the given uuid.
+	GetTransactionStatus(context.Context, *LedgerQuery) (*TransactionStatus, error)
+	// Stream the status of the transaction of the given uuid, then each of
+	// its changes until the transaction is rejected or committed.
+	WatchTransactionStatus(*LedgerQuery, DevopsV2_WatchTransactionStatusServer) error
+}
+
+func RegisterDevopsV2Server(s *grpc.Server, srv DevopsV2Server) {
+	s.RegisterService(&_DevopsV2_serviceDesc, srv)
+}
+
+func _DevopsV2_Deploy_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(ChaincodeSpec)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(DevopsV2Server).Deploy(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _DevopsV2_Invoke_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(ChaincodeInvocationSpec)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(DevopsV2Server).Invoke(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _DevopsV2_GetTransactionStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(LedgerQuery)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(DevopsV2Server).GetTransactionStatus(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _DevopsV2_WatchTransactionStatus_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(LedgerQuery)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(DevopsV2Server).WatchTransactionStatus(m, &devopsV2WatchTransactionStatusServer{stream})
+}
+
+type DevopsV2_WatchTransactionStatusServer interface {
+	Send(*TransactionStatus) error
+	grpc.ServerStream
+}
+
+type devopsV2WatchTransactionStatusServer struct {
+	grpc.ServerStream
+}
+
+func (x *devopsV2WatchTransactionStatusServer) Send(m *TransactionStatus) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+var _DevopsV2_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "protos.DevopsV2",
+	HandlerType: (*DevopsV2Server)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Deploy",
+			Handler:    _DevopsV2_Deploy_Handler,
+		},
+		{
+			MethodName: "Invoke",
+			Handler:    _DevopsV2_Invoke_Handler,
+		},
+		{
+			MethodName: "GetTransactionStatus",
+			Handler:    _DevopsV2_GetTransactionStatus_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchTransactionStatus",
+			Handler:       _DevopsV2_WatchTransactionStatus_Handler,
+			ServerStreams: true,
+		},
+	},
+}
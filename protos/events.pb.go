@@ -44,20 +44,72 @@ func (x Interest_ResponseType) String() string {
 	return proto.EnumName(Interest_ResponseType_name, int32(x))
 }
 
+type EventFilter_TxResult int32
+
+const (
+	// any result
+	EventFilter_ANY EventFilter_TxResult = 0
+	// the transactions which succeeded
+	EventFilter_SUCCEEDED EventFilter_TxResult = 1
+	// the transactions which failed
+	EventFilter_FAILED EventFilter_TxResult = 2
+)
+
+var EventFilter_TxResult_name = map[int32]string{
+	0: "ANY",
+	1: "SUCCEEDED",
+	2: "FAILED",
+}
+var EventFilter_TxResult_value = map[string]int32{
+	"ANY":       0,
+	"SUCCEEDED": 1,
+	"FAILED":    2,
+}
+
+func (x EventFilter_TxResult) String() string {
+	return proto.EnumName(EventFilter_TxResult_name, int32(x))
+}
+
 type Interest struct {
 	EventType    string                `protobuf:"bytes,1,opt,name=eventType" json:"eventType,omitempty"`
 	ResponseType Interest_ResponseType `protobuf:"varint,2,opt,name=responseType,enum=protos.Interest_ResponseType" json:"responseType,omitempty"`
+	Filter       *EventFilter          `protobuf:"bytes,3,opt,name=filter" json:"filter,omitempty"`
 }
 
 func (m *Interest) Reset()         { *m = Interest{} }
 func (m *Interest) String() string { return proto.CompactTextString(m) }
 func (*Interest) ProtoMessage()    {}
 
+func (m *Interest) GetFilter() *EventFilter {
+	if m != nil {
+		return m.Filter
+	}
+	return nil
+}
+
+// EventFilter restricts the block events sent to a consumer to the transactions
+// matching all the criteria set. Blocks without a matching transaction are not
+// sent, the others are sent with the matching transactions only
+type EventFilter struct {
+	ChaincodeID string `protobuf:"bytes,1,opt,name=chaincodeID" json:"chaincodeID,omitempty"`
+	// name of the chaincode event set by the transaction
+	EventName string               `protobuf:"bytes,2,opt,name=eventName" json:"eventName,omitempty"`
+	TxResult  EventFilter_TxResult `protobuf:"varint,3,opt,name=txResult,enum=protos.EventFilter_TxResult" json:"txResult,omitempty"`
+}
+
+func (m *EventFilter) Reset()         { *m = EventFilter{} }
+func (m *EventFilter) String() string { return proto.CompactTextString(m) }
+func (*EventFilter) ProtoMessage()    {}
+
 // ---------- consumer events ---------
 // Register is sent by consumers for registering events
 // string type - "register"
 type Register struct {
 	Events []*Interest `protobuf:"bytes,1,rep,name=events" json:"events,omitempty"`
+	Replay *Replay     `protobuf:"bytes,2,opt,name=replay" json:"replay,omitempty"`
+	// if not 0, the events are numbered and at most maxUnackedEvents events
+	// are sent which the consumer has not acknowledged with an Ack
+	MaxUnackedEvents uint32 `protobuf:"varint,3,opt,name=maxUnackedEvents" json:"maxUnackedEvents,omitempty"`
 }
 
 func (m *Register) Reset()         { *m = Register{} }
@@ -71,6 +123,27 @@ func (m *Register) GetEvents() []*Interest {
 	return nil
 }
 
+func (m *Register) GetReplay() *Replay {
+	if m != nil {
+		return m.Replay
+	}
+	return nil
+}
+
+// Replay asks the event hub to send the events of the blocks committed from
+// fromBlock before the live events. If consumerID is set, the hub checkpoints
+// the blocks sent to the consumer and, when resume is set, a consumer which
+// reconnects continues after the last block it was sent
+type Replay struct {
+	FromBlock  uint64 `protobuf:"varint,1,opt,name=fromBlock" json:"fromBlock,omitempty"`
+	ConsumerID string `protobuf:"bytes,2,opt,name=consumerID" json:"consumerID,omitempty"`
+	Resume     bool   `protobuf:"varint,3,opt,name=resume" json:"resume,omitempty"`
+}
+
+func (m *Replay) Reset()         { *m = Replay{} }
+func (m *Replay) String() string { return proto.CompactTextString(m) }
+func (*Replay) ProtoMessage()    {}
+
 // ---------- producer events ---------
 // Generic is used for encoding payload as JSON or raw bytes
 // string type - "generic"
@@ -83,6 +156,50 @@ func (m *Generic) Reset()         { *m = Generic{} }
 func (m *Generic) String() string { return proto.CompactTextString(m) }
 func (*Generic) ProtoMessage()    {}
 
+// BlockCommit is sent once a block is committed, along with the crypto-hash of
+// the changes made to the state by each of the successful transactions of the
+// block. The transactions which failed are not listed, and the crypto-hash is
+// empty for the transactions which did not change the state.
+// string type - "blockCommit"
+type BlockCommit struct {
+	BlockNumber        uint64              `protobuf:"varint,1,opt,name=blockNumber" json:"blockNumber,omitempty"`
+	StateHash          []byte              `protobuf:"bytes,2,opt,name=stateHash,proto3" json:"stateHash,omitempty"`
+	TxStateDeltaHashes []*TxStateDeltaHash `protobuf:"bytes,3,rep,name=txStateDeltaHashes" json:"txStateDeltaHashes,omitempty"`
+}
+
+func (m *BlockCommit) Reset()         { *m = BlockCommit{} }
+func (m *BlockCommit) String() string { return proto.CompactTextString(m) }
+func (*BlockCommit) ProtoMessage()    {}
+
+func (m *BlockCommit) GetTxStateDeltaHashes() []*TxStateDeltaHash {
+	if m != nil {
+		return m.TxStateDeltaHashes
+	}
+	return nil
+}
+
+// TxStateDeltaHash is the crypto-hash of the changes made to the state by a
+// transaction
+type TxStateDeltaHash struct {
+	TxUuid         string `protobuf:"bytes,1,opt,name=txUuid" json:"txUuid,omitempty"`
+	StateDeltaHash []byte `protobuf:"bytes,2,opt,name=stateDeltaHash,proto3" json:"stateDeltaHash,omitempty"`
+}
+
+func (m *TxStateDeltaHash) Reset()         { *m = TxStateDeltaHash{} }
+func (m *TxStateDeltaHash) String() string { return proto.CompactTextString(m) }
+func (*TxStateDeltaHash) ProtoMessage()    {}
+
+// Ack is sent by consumers to acknowledge the events up to the one with the
+// given sequence number
+// string type - "ack"
+type Ack struct {
+	Sequence uint64 `protobuf:"varint,1,opt,name=sequence" json:"sequence,omitempty"`
+}
+
+func (m *Ack) Reset()         { *m = Ack{} }
+func (m *Ack) String() string { return proto.CompactTextString(m) }
+func (*Ack) ProtoMessage()    {}
+
 // Event is used by
 //  - consumers (adapters) to send Register
 //  - producer to advertise supported types and events
@@ -91,9 +208,13 @@ type Event struct {
 	//
 	// Types that are valid to be assigned to Event:
 	//	*Event_Register
+	//	*Event_Ack
 	//	*Event_Block
 	//	*Event_Generic
+	//	*Event_BlockCommit
 	Event isEvent_Event `protobuf_oneof:"Event"`
+	// sequence number of the events sent to the consumers which acknowledge them
+	Sequence uint64 `protobuf:"varint,6,opt,name=sequence" json:"sequence,omitempty"`
 }
 
 func (m *Event) Reset()         { *m = Event{} }
@@ -107,16 +228,24 @@ type isEvent_Event interface {
 type Event_Register struct {
 	Register *Register `protobuf:"bytes,1,opt,name=register,oneof"`
 }
+type Event_Ack struct {
+	Ack *Ack `protobuf:"bytes,5,opt,name=ack,oneof"`
+}
 type Event_Block struct {
 	Block *Block `protobuf:"bytes,2,opt,name=block,oneof"`
 }
 type Event_Generic struct {
 	Generic *Generic `protobuf:"bytes,3,opt,name=generic,oneof"`
 }
+type Event_BlockCommit struct {
+	BlockCommit *BlockCommit `protobuf:"bytes,4,opt,name=blockCommit,oneof"`
+}
 
-func (*Event_Register) isEvent_Event() {}
-func (*Event_Block) isEvent_Event()    {}
-func (*Event_Generic) isEvent_Event()  {}
+func (*Event_Register) isEvent_Event()    {}
+func (*Event_Ack) isEvent_Event()         {}
+func (*Event_Block) isEvent_Event()       {}
+func (*Event_Generic) isEvent_Event()     {}
+func (*Event_BlockCommit) isEvent_Event() {}
 
 func (m *Event) GetEvent() isEvent_Event {
 	if m != nil {
@@ -132,6 +261,13 @@ func (m *Event) GetRegister() *Register {
 	return nil
 }
 
+func (m *Event) GetAck() *Ack {
+	if x, ok := m.GetEvent().(*Event_Ack); ok {
+		return x.Ack
+	}
+	return nil
+}
+
 func (m *Event) GetBlock() *Block {
 	if x, ok := m.GetEvent().(*Event_Block); ok {
 		return x.Block
@@ -146,12 +282,21 @@ func (m *Event) GetGeneric() *Generic {
 	return nil
 }
 
+func (m *Event) GetBlockCommit() *BlockCommit {
+	if x, ok := m.GetEvent().(*Event_BlockCommit); ok {
+		return x.BlockCommit
+	}
+	return nil
+}
+
 // XXX_OneofFuncs is for the internal use of the proto package.
 func (*Event) XXX_OneofFuncs() (func(msg proto.Message, b *proto.Buffer) error, func(msg proto.Message, tag, wire int, b *proto.Buffer) (bool, error), []interface{}) {
 	return _Event_OneofMarshaler, _Event_OneofUnmarshaler, []interface{}{
 		(*Event_Register)(nil),
+		(*Event_Ack)(nil),
 		(*Event_Block)(nil),
 		(*Event_Generic)(nil),
+		(*Event_BlockCommit)(nil),
 	}
 }
 
@@ -164,6 +309,11 @@ func _Event_OneofMarshaler(msg proto.Message, b *proto.Buffer) error {
 		if err := b.EncodeMessage(x.Register); err != nil {
 			return err
 		}
+	case *Event_Ack:
+		b.EncodeVarint(5<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.Ack); err != nil {
+			return err
+		}
 	case *Event_Block:
 		b.EncodeVarint(2<<3 | proto.WireBytes)
 		if err := b.EncodeMessage(x.Block); err != nil {
@@ -174,6 +324,11 @@ func _Event_OneofMarshaler(msg proto.Message, b *proto.Buffer) error {
 		if err := b.EncodeMessage(x.Generic); err != nil {
 			return err
 		}
+	case *Event_BlockCommit:
+		b.EncodeVarint(4<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.BlockCommit); err != nil {
+			return err
+		}
 	case nil:
 	default:
 		return fmt.Errorf("Event.Event has unexpected type %T", x)
@@ -192,6 +347,14 @@ func _Event_OneofUnmarshaler(msg proto.Message, tag, wire int, b *proto.Buffer)
 		err := b.DecodeMessage(msg)
 		m.Event = &Event_Register{msg}
 		return true, err
+	case 5: // Event.ack
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(Ack)
+		err := b.DecodeMessage(msg)
+		m.Event = &Event_Ack{msg}
+		return true, err
 	case 2: // Event.block
 		if wire != proto.WireBytes {
 			return true, proto.ErrInternalBadWireType
@@ -208,6 +371,14 @@ func _Event_OneofUnmarshaler(msg proto.Message, tag, wire int, b *proto.Buffer)
 		err := b.DecodeMessage(msg)
 		m.Event = &Event_Generic{msg}
 		return true, err
+	case 4: // Event.blockCommit
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(BlockCommit)
+		err := b.DecodeMessage(msg)
+		m.Event = &Event_BlockCommit{msg}
+		return true, err
 	default:
 		return false, nil
 	}
@@ -215,6 +386,7 @@ func _Event_OneofUnmarshaler(msg proto.Message, tag, wire int, b *proto.Buffer)
 
 func init() {
 	proto.RegisterEnum("protos.Interest_ResponseType", Interest_ResponseType_name, Interest_ResponseType_value)
+	proto.RegisterEnum("protos.EventFilter_TxResult", EventFilter_TxResult_name, EventFilter_TxResult_value)
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -19,6 +19,36 @@ var _ = proto.Marshal
 var _ = fmt.Errorf
 var _ = math.Inf
 
+// SyncCompression identifies the codec applied to the delta payloads of
+// SyncStateSnapshot and SyncStateDeltas. The codec used on a chat session is
+// negotiated from the syncCompressions advertised in the HelloMessage of both
+// peers, falling back to NONE when there is no common codec.
+type SyncCompression int32
+
+const (
+	SyncCompression_NONE   SyncCompression = 0
+	SyncCompression_GZIP   SyncCompression = 1
+	SyncCompression_SNAPPY SyncCompression = 2
+	SyncCompression_ZSTD   SyncCompression = 3
+)
+
+var SyncCompression_name = map[int32]string{
+	0: "NONE",
+	1: "GZIP",
+	2: "SNAPPY",
+	3: "ZSTD",
+}
+var SyncCompression_value = map[string]int32{
+	"NONE":   0,
+	"GZIP":   1,
+	"SNAPPY": 2,
+	"ZSTD":   3,
+}
+
+func (x SyncCompression) String() string {
+	return proto.EnumName(SyncCompression_name, int32(x))
+}
+
 type Transaction_Type int32
 
 const (
@@ -31,6 +61,15 @@ const (
 	Transaction_CHAINCODE_QUERY Transaction_Type = 3
 	// terminate a chaincode; not implemented yet
 	Transaction_CHAINCODE_TERMINATE Transaction_Type = 4
+	// replace the code of a deployed chaincode, keeping its state, and
+	// call its `Migrate` function
+	Transaction_CHAINCODE_UPGRADE Transaction_Type = 5
+	// apply the changes of an invoke simulated and endorsed by validating
+	// peers, if the state it read is unchanged
+	Transaction_CHAINCODE_ENDORSED Transaction_Type = 6
+	// update the configuration of the network recorded by the ledger,
+	// see ConfigUpdate
+	Transaction_CONFIG Transaction_Type = 7
 )
 
 var Transaction_Type_name = map[int32]string{
@@ -39,6 +78,9 @@ var Transaction_Type_name = map[int32]string{
 	2: "CHAINCODE_INVOKE",
 	3: "CHAINCODE_QUERY",
 	4: "CHAINCODE_TERMINATE",
+	5: "CHAINCODE_UPGRADE",
+	6: "CHAINCODE_ENDORSED",
+	7: "CONFIG",
 }
 var Transaction_Type_value = map[string]int32{
 	"UNDEFINED":           0,
@@ -46,6 +88,9 @@ var Transaction_Type_value = map[string]int32{
 	"CHAINCODE_INVOKE":    2,
 	"CHAINCODE_QUERY":     3,
 	"CHAINCODE_TERMINATE": 4,
+	"CHAINCODE_UPGRADE":   5,
+	"CHAINCODE_ENDORSED":  6,
+	"CONFIG":              7,
 }
 
 func (x Transaction_Type) String() string {
@@ -78,22 +123,28 @@ func (x PeerEndpoint_Type) String() string {
 type Message_Type int32
 
 const (
-	Message_UNDEFINED               Message_Type = 0
-	Message_DISC_HELLO              Message_Type = 1
-	Message_DISC_DISCONNECT         Message_Type = 2
-	Message_DISC_GET_PEERS          Message_Type = 3
-	Message_DISC_PEERS              Message_Type = 4
-	Message_DISC_NEWMSG             Message_Type = 5
-	Message_CHAIN_TRANSACTION       Message_Type = 6
-	Message_SYNC_GET_BLOCKS         Message_Type = 11
-	Message_SYNC_BLOCKS             Message_Type = 12
-	Message_SYNC_BLOCK_ADDED        Message_Type = 13
-	Message_SYNC_STATE_GET_SNAPSHOT Message_Type = 14
-	Message_SYNC_STATE_SNAPSHOT     Message_Type = 15
-	Message_SYNC_STATE_GET_DELTAS   Message_Type = 16
-	Message_SYNC_STATE_DELTAS       Message_Type = 17
-	Message_RESPONSE                Message_Type = 20
-	Message_CONSENSUS               Message_Type = 21
+	Message_UNDEFINED                    Message_Type = 0
+	Message_DISC_HELLO                   Message_Type = 1
+	Message_DISC_DISCONNECT              Message_Type = 2
+	Message_DISC_GET_PEERS               Message_Type = 3
+	Message_DISC_PEERS                   Message_Type = 4
+	Message_DISC_NEWMSG                  Message_Type = 5
+	Message_CHAIN_TRANSACTION            Message_Type = 6
+	Message_SYNC_GET_BLOCKS              Message_Type = 11
+	Message_SYNC_BLOCKS                  Message_Type = 12
+	Message_SYNC_BLOCK_ADDED             Message_Type = 13
+	Message_SYNC_STATE_GET_SNAPSHOT      Message_Type = 14
+	Message_SYNC_STATE_SNAPSHOT          Message_Type = 15
+	Message_SYNC_STATE_GET_DELTAS        Message_Type = 16
+	Message_SYNC_STATE_DELTAS            Message_Type = 17
+	Message_SYNC_STATE_GET_BUCKET_HASHES Message_Type = 18
+	Message_SYNC_STATE_BUCKET_HASHES     Message_Type = 19
+	Message_RESPONSE                     Message_Type = 20
+	Message_CONSENSUS                    Message_Type = 21
+	Message_SYNC_STATE_GET_BUCKETS       Message_Type = 22
+	Message_SYNC_STATE_BUCKETS           Message_Type = 23
+	Message_GOSSIP_BLOCK                 Message_Type = 24
+	Message_GOSSIP_DIGEST                Message_Type = 25
 )
 
 var Message_Type_name = map[int32]string{
@@ -111,26 +162,38 @@ var Message_Type_name = map[int32]string{
 	15: "SYNC_STATE_SNAPSHOT",
 	16: "SYNC_STATE_GET_DELTAS",
 	17: "SYNC_STATE_DELTAS",
+	18: "SYNC_STATE_GET_BUCKET_HASHES",
+	19: "SYNC_STATE_BUCKET_HASHES",
 	20: "RESPONSE",
 	21: "CONSENSUS",
+	22: "SYNC_STATE_GET_BUCKETS",
+	23: "SYNC_STATE_BUCKETS",
+	24: "GOSSIP_BLOCK",
+	25: "GOSSIP_DIGEST",
 }
 var Message_Type_value = map[string]int32{
-	"UNDEFINED":               0,
-	"DISC_HELLO":              1,
-	"DISC_DISCONNECT":         2,
-	"DISC_GET_PEERS":          3,
-	"DISC_PEERS":              4,
-	"DISC_NEWMSG":             5,
-	"CHAIN_TRANSACTION":       6,
-	"SYNC_GET_BLOCKS":         11,
-	"SYNC_BLOCKS":             12,
-	"SYNC_BLOCK_ADDED":        13,
-	"SYNC_STATE_GET_SNAPSHOT": 14,
-	"SYNC_STATE_SNAPSHOT":     15,
-	"SYNC_STATE_GET_DELTAS":   16,
-	"SYNC_STATE_DELTAS":       17,
-	"RESPONSE":                20,
-	"CONSENSUS":               21,
+	"UNDEFINED":                    0,
+	"DISC_HELLO":                   1,
+	"DISC_DISCONNECT":              2,
+	"DISC_GET_PEERS":               3,
+	"DISC_PEERS":                   4,
+	"DISC_NEWMSG":                  5,
+	"CHAIN_TRANSACTION":            6,
+	"SYNC_GET_BLOCKS":              11,
+	"SYNC_BLOCKS":                  12,
+	"SYNC_BLOCK_ADDED":             13,
+	"SYNC_STATE_GET_SNAPSHOT":      14,
+	"SYNC_STATE_SNAPSHOT":          15,
+	"SYNC_STATE_GET_DELTAS":        16,
+	"SYNC_STATE_DELTAS":            17,
+	"SYNC_STATE_GET_BUCKET_HASHES": 18,
+	"SYNC_STATE_BUCKET_HASHES":     19,
+	"RESPONSE":                     20,
+	"CONSENSUS":                    21,
+	"SYNC_STATE_GET_BUCKETS":       22,
+	"SYNC_STATE_BUCKETS":           23,
+	"GOSSIP_BLOCK":                 24,
+	"GOSSIP_DIGEST":                25,
 }
 
 func (x Message_Type) String() string {
@@ -179,6 +242,12 @@ type Transaction struct {
 	ToValidators                   []byte                     `protobuf:"bytes,10,opt,name=toValidators,proto3" json:"toValidators,omitempty"`
 	Cert                           []byte                     `protobuf:"bytes,11,opt,name=cert,proto3" json:"cert,omitempty"`
 	Signature                      []byte                     `protobuf:"bytes,12,opt,name=signature,proto3" json:"signature,omitempty"`
+	// data passed to the chaincode, which is not signed nor stored in the
+	// blocks; the transientHash binds it to the transaction
+	Transient     []*TransientField `protobuf:"bytes,13,rep,name=transient" json:"transient,omitempty"`
+	TransientHash []byte            `protobuf:"bytes,14,opt,name=transientHash,proto3" json:"transientHash,omitempty"`
+	// bounds when the transaction can be executed, nil if never expiring
+	Expiry *TransactionExpiry `protobuf:"bytes,15,opt,name=expiry" json:"expiry,omitempty"`
 }
 
 func (m *Transaction) Reset()         { *m = Transaction{} }
@@ -192,6 +261,20 @@ func (m *Transaction) GetTimestamp() *google_protobuf.Timestamp {
 	return nil
 }
 
+func (m *Transaction) GetTransient() []*TransientField {
+	if m != nil {
+		return m.Transient
+	}
+	return nil
+}
+
+func (m *Transaction) GetExpiry() *TransactionExpiry {
+	if m != nil {
+		return m.Expiry
+	}
+	return nil
+}
+
 // TransactionBlock carries a batch of transactions.
 type TransactionBlock struct {
 	Transactions []*Transaction `protobuf:"bytes,1,rep,name=transactions" json:"transactions,omitempty"`
@@ -214,17 +297,26 @@ func (m *TransactionBlock) GetTransactions() []*Transaction {
 // result - The return value of the transaction.
 // errorCode - An error code. 5xx will be logged as a failure in the dashboard.
 // error - An error string for logging an issue.
+// chaincodeEvent - The event set by the chaincode, if any.
 type TransactionResult struct {
-	Uuid      string `protobuf:"bytes,1,opt,name=uuid" json:"uuid,omitempty"`
-	Result    []byte `protobuf:"bytes,2,opt,name=result,proto3" json:"result,omitempty"`
-	ErrorCode uint32 `protobuf:"varint,3,opt,name=errorCode" json:"errorCode,omitempty"`
-	Error     string `protobuf:"bytes,4,opt,name=error" json:"error,omitempty"`
+	Uuid           string          `protobuf:"bytes,1,opt,name=uuid" json:"uuid,omitempty"`
+	Result         []byte          `protobuf:"bytes,2,opt,name=result,proto3" json:"result,omitempty"`
+	ErrorCode      uint32          `protobuf:"varint,3,opt,name=errorCode" json:"errorCode,omitempty"`
+	Error          string          `protobuf:"bytes,4,opt,name=error" json:"error,omitempty"`
+	ChaincodeEvent *ChaincodeEvent `protobuf:"bytes,5,opt,name=chaincodeEvent" json:"chaincodeEvent,omitempty"`
 }
 
 func (m *TransactionResult) Reset()         { *m = TransactionResult{} }
 func (m *TransactionResult) String() string { return proto.CompactTextString(m) }
 func (*TransactionResult) ProtoMessage()    {}
 
+func (m *TransactionResult) GetChaincodeEvent() *ChaincodeEvent {
+	if m != nil {
+		return m.ChaincodeEvent
+	}
+	return nil
+}
+
 // Block carries The data that describes a block in the blockchain.
 // version - Version used to track any protocol changes.
 // timestamp - The time at which the block or transaction order
@@ -292,6 +384,7 @@ func (*BlockchainInfo) ProtoMessage()    {}
 type NonHashData struct {
 	LocalLedgerCommitTimestamp *google_protobuf.Timestamp `protobuf:"bytes,1,opt,name=localLedgerCommitTimestamp" json:"localLedgerCommitTimestamp,omitempty"`
 	TransactionResults         []*TransactionResult       `protobuf:"bytes,2,rep,name=transactionResults" json:"transactionResults,omitempty"`
+	TxEffectsRoot              []byte                     `protobuf:"bytes,3,opt,name=txEffectsRoot,proto3" json:"txEffectsRoot,omitempty"`
 }
 
 func (m *NonHashData) Reset()         { *m = NonHashData{} }
@@ -365,6 +458,12 @@ func (m *PeersMessage) GetPeers() []*PeerEndpoint {
 type HelloMessage struct {
 	PeerEndpoint   *PeerEndpoint   `protobuf:"bytes,1,opt,name=peerEndpoint" json:"peerEndpoint,omitempty"`
 	BlockchainInfo *BlockchainInfo `protobuf:"bytes,2,opt,name=blockchainInfo" json:"blockchainInfo,omitempty"`
+	// syncCompressions lists the codecs the sender is able to decode for
+	// state sync payloads, in the sender's order of preference.
+	SyncCompressions []SyncCompression `protobuf:"varint,3,rep,name=syncCompressions,enum=protos.SyncCompression" json:"syncCompressions,omitempty"`
+	// syncStateVersion is the version of the state sync protocol supported by
+	// the sender. Version 2 adds the transfer of the state by buckets.
+	SyncStateVersion uint32 `protobuf:"varint,4,opt,name=syncStateVersion" json:"syncStateVersion,omitempty"`
 }
 
 func (m *HelloMessage) Reset()         { *m = HelloMessage{} }
@@ -412,6 +511,193 @@ func (m *Response) Reset()         { *m = Response{} }
 func (m *Response) String() string { return proto.CompactTextString(m) }
 func (*Response) ProtoMessage()    {}
 
+// QueryResponse is the result of a read-only query of a chaincode, executed
+// by a validating peer against its committed state and signed by the peer.
+// The signature covers the marshalled QueryResponse without it.
+type QueryResponse struct {
+	Uuid        string    `protobuf:"bytes,1,opt,name=uuid" json:"uuid,omitempty"`
+	Response    *Response `protobuf:"bytes,2,opt,name=response" json:"response,omitempty"`
+	BlockHeight uint64    `protobuf:"varint,3,opt,name=blockHeight" json:"blockHeight,omitempty"`
+	PeerID      *PeerID   `protobuf:"bytes,4,opt,name=peerID" json:"peerID,omitempty"`
+	Signature   []byte    `protobuf:"bytes,5,opt,name=signature,proto3" json:"signature,omitempty"`
+}
+
+func (m *QueryResponse) Reset()         { *m = QueryResponse{} }
+func (m *QueryResponse) String() string { return proto.CompactTextString(m) }
+func (*QueryResponse) ProtoMessage()    {}
+
+func (m *QueryResponse) GetResponse() *Response {
+	if m != nil {
+		return m.Response
+	}
+	return nil
+}
+
+func (m *QueryResponse) GetPeerID() *PeerID {
+	if m != nil {
+		return m.PeerID
+	}
+	return nil
+}
+
+// SimulationResult is the outcome of the simulation of an invoke transaction
+// against the committed state of a validating peer. The transaction is not
+// submitted: the changes it would make are reported along with the keys it
+// read, which tell the transactions it would conflict with. The validating
+// peer endorses the result: when security is enabled, signature is its
+// signature over the marshalled result without signature, under pkiID.
+type SimulationResult struct {
+	Uuid           string            `protobuf:"bytes,1,opt,name=uuid" json:"uuid,omitempty"`
+	Response       *Response         `protobuf:"bytes,2,opt,name=response" json:"response,omitempty"`
+	BlockHeight    uint64            `protobuf:"varint,3,opt,name=blockHeight" json:"blockHeight,omitempty"`
+	Reads          []*StateRead      `protobuf:"bytes,4,rep,name=reads" json:"reads,omitempty"`
+	RangeReads     []*StateRangeRead `protobuf:"bytes,5,rep,name=rangeReads" json:"rangeReads,omitempty"`
+	Writes         []*StateWrite     `protobuf:"bytes,6,rep,name=writes" json:"writes,omitempty"`
+	ChaincodeEvent *ChaincodeEvent   `protobuf:"bytes,7,opt,name=chaincodeEvent" json:"chaincodeEvent,omitempty"`
+	PeerID         *PeerID           `protobuf:"bytes,8,opt,name=peerID" json:"peerID,omitempty"`
+	PkiID          []byte            `protobuf:"bytes,9,opt,name=pkiID,proto3" json:"pkiID,omitempty"`
+	Signature      []byte            `protobuf:"bytes,10,opt,name=signature,proto3" json:"signature,omitempty"`
+}
+
+func (m *SimulationResult) Reset()         { *m = SimulationResult{} }
+func (m *SimulationResult) String() string { return proto.CompactTextString(m) }
+func (*SimulationResult) ProtoMessage()    {}
+
+func (m *SimulationResult) GetResponse() *Response {
+	if m != nil {
+		return m.Response
+	}
+	return nil
+}
+
+func (m *SimulationResult) GetReads() []*StateRead {
+	if m != nil {
+		return m.Reads
+	}
+	return nil
+}
+
+func (m *SimulationResult) GetRangeReads() []*StateRangeRead {
+	if m != nil {
+		return m.RangeReads
+	}
+	return nil
+}
+
+func (m *SimulationResult) GetWrites() []*StateWrite {
+	if m != nil {
+		return m.Writes
+	}
+	return nil
+}
+
+func (m *SimulationResult) GetChaincodeEvent() *ChaincodeEvent {
+	if m != nil {
+		return m.ChaincodeEvent
+	}
+	return nil
+}
+
+func (m *SimulationResult) GetPeerID() *PeerID {
+	if m != nil {
+		return m.PeerID
+	}
+	return nil
+}
+
+// StateRead is a key read by a simulated transaction. valueHash is the hash
+// of the value read, empty if the key was not set.
+type StateRead struct {
+	ChaincodeID string `protobuf:"bytes,1,opt,name=chaincodeID" json:"chaincodeID,omitempty"`
+	Key         string `protobuf:"bytes,2,opt,name=key" json:"key,omitempty"`
+	ValueHash   []byte `protobuf:"bytes,3,opt,name=valueHash,proto3" json:"valueHash,omitempty"`
+}
+
+func (m *StateRead) Reset()         { *m = StateRead{} }
+func (m *StateRead) String() string { return proto.CompactTextString(m) }
+func (*StateRead) ProtoMessage()    {}
+
+// StateRangeRead is a range query of a simulated transaction, along with the
+// keys it returned. valueHash is the hash of the hashes of their values, in
+// the order of the keys.
+type StateRangeRead struct {
+	ChaincodeID string   `protobuf:"bytes,1,opt,name=chaincodeID" json:"chaincodeID,omitempty"`
+	StartKey    string   `protobuf:"bytes,2,opt,name=startKey" json:"startKey,omitempty"`
+	EndKey      string   `protobuf:"bytes,3,opt,name=endKey" json:"endKey,omitempty"`
+	Keys        []string `protobuf:"bytes,4,rep,name=keys" json:"keys,omitempty"`
+	ValueHash   []byte   `protobuf:"bytes,5,opt,name=valueHash,proto3" json:"valueHash,omitempty"`
+}
+
+func (m *StateRangeRead) Reset()         { *m = StateRangeRead{} }
+func (m *StateRangeRead) String() string { return proto.CompactTextString(m) }
+func (*StateRangeRead) ProtoMessage()    {}
+
+// StateWrite is a change of the state made by a simulated transaction.
+type StateWrite struct {
+	ChaincodeID string `protobuf:"bytes,1,opt,name=chaincodeID" json:"chaincodeID,omitempty"`
+	Key         string `protobuf:"bytes,2,opt,name=key" json:"key,omitempty"`
+	Value       []byte `protobuf:"bytes,3,opt,name=value,proto3" json:"value,omitempty"`
+	IsDelete    bool   `protobuf:"varint,4,opt,name=isDelete" json:"isDelete,omitempty"`
+}
+
+func (m *StateWrite) Reset()         { *m = StateWrite{} }
+func (m *StateWrite) String() string { return proto.CompactTextString(m) }
+func (*StateWrite) ProtoMessage()    {}
+
+// EndorsedTransaction is the payload of a CHAINCODE_ENDORSED transaction: the
+// results of the simulation of the same invoke by one or more validating
+// peers, which have to agree. Their changes have to be in the state of the
+// chaincode. secureContext is the user submitting the transaction through
+// Devops when security is enabled, cleared before the transaction is created.
+type EndorsedTransaction struct {
+	ChaincodeID   *ChaincodeID        `protobuf:"bytes,1,opt,name=chaincodeID" json:"chaincodeID,omitempty"`
+	Endorsements  []*SimulationResult `protobuf:"bytes,2,rep,name=endorsements" json:"endorsements,omitempty"`
+	SecureContext string              `protobuf:"bytes,3,opt,name=secureContext" json:"secureContext,omitempty"`
+	// expiry of the transaction, see TransactionExpiry
+	Expiry *TransactionExpiry `protobuf:"bytes,4,opt,name=expiry" json:"expiry,omitempty"`
+}
+
+func (m *EndorsedTransaction) Reset()         { *m = EndorsedTransaction{} }
+func (m *EndorsedTransaction) String() string { return proto.CompactTextString(m) }
+func (*EndorsedTransaction) ProtoMessage()    {}
+
+func (m *EndorsedTransaction) GetChaincodeID() *ChaincodeID {
+	if m != nil {
+		return m.ChaincodeID
+	}
+	return nil
+}
+
+func (m *EndorsedTransaction) GetEndorsements() []*SimulationResult {
+	if m != nil {
+		return m.Endorsements
+	}
+	return nil
+}
+
+func (m *EndorsedTransaction) GetExpiry() *TransactionExpiry {
+	if m != nil {
+		return m.Expiry
+	}
+	return nil
+}
+
+// ConfigUpdate is the payload of a CONFIG transaction, which updates the
+// configuration of the network recorded by the ledger. revocationList is a DER
+// encoded X.509 CRL of the enrollment or transaction certificates revoked by
+// the ECA or the TCA; it replaces the recorded list of the same CA if it is
+// more recent and, when security is enabled, signed by the CA. secureContext
+// is the user submitting the update through Devops when security is enabled,
+// cleared before the transaction is created.
+type ConfigUpdate struct {
+	RevocationList []byte `protobuf:"bytes,1,opt,name=revocationList,proto3" json:"revocationList,omitempty"`
+	SecureContext  string `protobuf:"bytes,2,opt,name=secureContext" json:"secureContext,omitempty"`
+}
+
+func (m *ConfigUpdate) Reset()         { *m = ConfigUpdate{} }
+func (m *ConfigUpdate) String() string { return proto.CompactTextString(m) }
+func (*ConfigUpdate) ProtoMessage()    {}
+
 // BlockState is the payload of Message.SYNC_BLOCK_ADDED. When a VP
 // commits a new block to the ledger, it will notify its connected NVPs of the
 // block and the delta state. The NVP may call the ledger APIs to apply the
@@ -474,8 +760,14 @@ func (m *SyncBlocks) GetBlocks() []*Block {
 }
 
 // SyncSnapshotRequest Payload for the penchainMessage.SYNC_GET_SNAPSHOT message.
+// A non zero startSequence asks to resume the stream of the snapshot of
+// blockNumber from the chunk with this sequence, after a dropped connection.
+// If the snapshot is no longer available, the stream of a new snapshot is
+// sent from sequence 0.
 type SyncStateSnapshotRequest struct {
 	CorrelationId uint64 `protobuf:"varint,1,opt,name=correlationId" json:"correlationId,omitempty"`
+	BlockNumber   uint64 `protobuf:"varint,2,opt,name=blockNumber" json:"blockNumber,omitempty"`
+	StartSequence uint64 `protobuf:"varint,3,opt,name=startSequence" json:"startSequence,omitempty"`
 }
 
 func (m *SyncStateSnapshotRequest) Reset()         { *m = SyncStateSnapshotRequest{} }
@@ -491,6 +783,7 @@ type SyncStateSnapshot struct {
 	Sequence    uint64                    `protobuf:"varint,2,opt,name=sequence" json:"sequence,omitempty"`
 	BlockNumber uint64                    `protobuf:"varint,3,opt,name=blockNumber" json:"blockNumber,omitempty"`
 	Request     *SyncStateSnapshotRequest `protobuf:"bytes,4,opt,name=request" json:"request,omitempty"`
+	Compression SyncCompression           `protobuf:"varint,5,opt,name=compression,enum=protos.SyncCompression" json:"compression,omitempty"`
 }
 
 func (m *SyncStateSnapshot) Reset()         { *m = SyncStateSnapshot{} }
@@ -526,8 +819,9 @@ func (m *SyncStateDeltasRequest) GetRange() *SyncBlockRange {
 // SyncStateDeltas is the payload of the Message.SYNC_STATE in response to
 // the Message.SYNC_GET_STATE message.
 type SyncStateDeltas struct {
-	Range  *SyncBlockRange `protobuf:"bytes,1,opt,name=range" json:"range,omitempty"`
-	Deltas [][]byte        `protobuf:"bytes,2,rep,name=deltas,proto3" json:"deltas,omitempty"`
+	Range       *SyncBlockRange `protobuf:"bytes,1,opt,name=range" json:"range,omitempty"`
+	Deltas      [][]byte        `protobuf:"bytes,2,rep,name=deltas,proto3" json:"deltas,omitempty"`
+	Compression SyncCompression `protobuf:"varint,3,opt,name=compression,enum=protos.SyncCompression" json:"compression,omitempty"`
 }
 
 func (m *SyncStateDeltas) Reset()         { *m = SyncStateDeltas{} }
@@ -541,7 +835,136 @@ func (m *SyncStateDeltas) GetRange() *SyncBlockRange {
 	return nil
 }
 
+// SyncStateBucketHashesRequest is the payload of Message.SYNC_STATE_GET_BUCKET_HASHES.
+// It requests the crypto-hashes of the buckets with the given numbers at the
+// given level of the bucket tree, the root being at level 0.
+type SyncStateBucketHashesRequest struct {
+	CorrelationId uint64   `protobuf:"varint,1,opt,name=correlationId" json:"correlationId,omitempty"`
+	Level         uint32   `protobuf:"varint,2,opt,name=level" json:"level,omitempty"`
+	BucketNumbers []uint32 `protobuf:"varint,3,rep,name=bucketNumbers" json:"bucketNumbers,omitempty"`
+}
+
+func (m *SyncStateBucketHashesRequest) Reset()         { *m = SyncStateBucketHashesRequest{} }
+func (m *SyncStateBucketHashesRequest) String() string { return proto.CompactTextString(m) }
+func (*SyncStateBucketHashesRequest) ProtoMessage()    {}
+
+// SyncBucketTreeShape describes the tree of buckets in which a peer organizes
+// its state. Bucket hashes are comparable only between trees of the same shape.
+type SyncBucketTreeShape struct {
+	NumBuckets             uint32 `protobuf:"varint,1,opt,name=numBuckets" json:"numBuckets,omitempty"`
+	MaxGroupingAtEachLevel uint32 `protobuf:"varint,2,opt,name=maxGroupingAtEachLevel" json:"maxGroupingAtEachLevel,omitempty"`
+	BucketHashFunction     string `protobuf:"bytes,3,opt,name=bucketHashFunction" json:"bucketHashFunction,omitempty"`
+}
+
+func (m *SyncBucketTreeShape) Reset()         { *m = SyncBucketTreeShape{} }
+func (m *SyncBucketTreeShape) String() string { return proto.CompactTextString(m) }
+func (*SyncBucketTreeShape) ProtoMessage()    {}
+
+// SyncStateBucketHashes is the payload of Message.SYNC_STATE_BUCKET_HASHES in
+// response to Message.SYNC_STATE_GET_BUCKET_HASHES. The hashes are in the order
+// of the requested bucket numbers, an empty hash denoting an empty bucket. The
+// error is set when the hashes could not be computed.
+type SyncStateBucketHashes struct {
+	Request     *SyncStateBucketHashesRequest `protobuf:"bytes,1,opt,name=request" json:"request,omitempty"`
+	BlockNumber uint64                        `protobuf:"varint,2,opt,name=blockNumber" json:"blockNumber,omitempty"`
+	Shape       *SyncBucketTreeShape          `protobuf:"bytes,3,opt,name=shape" json:"shape,omitempty"`
+	Hashes      [][]byte                      `protobuf:"bytes,4,rep,name=hashes,proto3" json:"hashes,omitempty"`
+	Error       string                        `protobuf:"bytes,5,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *SyncStateBucketHashes) Reset()         { *m = SyncStateBucketHashes{} }
+func (m *SyncStateBucketHashes) String() string { return proto.CompactTextString(m) }
+func (*SyncStateBucketHashes) ProtoMessage()    {}
+
+func (m *SyncStateBucketHashes) GetRequest() *SyncStateBucketHashesRequest {
+	if m != nil {
+		return m.Request
+	}
+	return nil
+}
+
+func (m *SyncStateBucketHashes) GetShape() *SyncBucketTreeShape {
+	if m != nil {
+		return m.Shape
+	}
+	return nil
+}
+
+// SyncStateBucketsRequest is the payload of Message.SYNC_STATE_GET_BUCKETS. It
+// requests the key-values of the buckets with the given numbers at the lowest
+// level of the bucket tree.
+type SyncStateBucketsRequest struct {
+	CorrelationId uint64   `protobuf:"varint,1,opt,name=correlationId" json:"correlationId,omitempty"`
+	BucketNumbers []uint32 `protobuf:"varint,2,rep,name=bucketNumbers" json:"bucketNumbers,omitempty"`
+}
+
+func (m *SyncStateBucketsRequest) Reset()         { *m = SyncStateBucketsRequest{} }
+func (m *SyncStateBucketsRequest) String() string { return proto.CompactTextString(m) }
+func (*SyncStateBucketsRequest) ProtoMessage()    {}
+
+// SyncStateBuckets is the payload of Message.SYNC_STATE_BUCKETS in response to
+// Message.SYNC_STATE_GET_BUCKETS. Like SyncStateSnapshot, the key-values are
+// streamed in chunks ordered by sequence and the terminating message has
+// len(delta) == 0. The error is set when the buckets could not be read.
+type SyncStateBuckets struct {
+	Request     *SyncStateBucketsRequest `protobuf:"bytes,1,opt,name=request" json:"request,omitempty"`
+	BlockNumber uint64                   `protobuf:"varint,2,opt,name=blockNumber" json:"blockNumber,omitempty"`
+	Sequence    uint64                   `protobuf:"varint,3,opt,name=sequence" json:"sequence,omitempty"`
+	Delta       []byte                   `protobuf:"bytes,4,opt,name=delta,proto3" json:"delta,omitempty"`
+	Compression SyncCompression          `protobuf:"varint,5,opt,name=compression,enum=protos.SyncCompression" json:"compression,omitempty"`
+	Error       string                   `protobuf:"bytes,6,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *SyncStateBuckets) Reset()         { *m = SyncStateBuckets{} }
+func (m *SyncStateBuckets) String() string { return proto.CompactTextString(m) }
+func (*SyncStateBuckets) ProtoMessage()    {}
+
+func (m *SyncStateBuckets) GetRequest() *SyncStateBucketsRequest {
+	if m != nil {
+		return m.Request
+	}
+	return nil
+}
+
+// GossipBlock is the payload of Message.GOSSIP_BLOCK. A committed block and
+// the state delta it applies, which the VPs send to some of the NVPs and the
+// NVPs relay to some others, up to a number of hops. The block is signed by
+// the VP it originates from, identified by its PKI ID, over the block number,
+// the hash of the block and the hash of the state delta, so that the NVPs only
+// commit the blocks produced by the VPs.
+type GossipBlock struct {
+	BlockNumber uint64      `protobuf:"varint,1,opt,name=blockNumber" json:"blockNumber,omitempty"`
+	BlockState  *BlockState `protobuf:"bytes,2,opt,name=blockState" json:"blockState,omitempty"`
+	Hops        uint32      `protobuf:"varint,3,opt,name=hops" json:"hops,omitempty"`
+	OriginPkiID []byte      `protobuf:"bytes,4,opt,name=originPkiID,proto3" json:"originPkiID,omitempty"`
+	Signature   []byte      `protobuf:"bytes,5,opt,name=signature,proto3" json:"signature,omitempty"`
+}
+
+func (m *GossipBlock) Reset()         { *m = GossipBlock{} }
+func (m *GossipBlock) String() string { return proto.CompactTextString(m) }
+func (*GossipBlock) ProtoMessage()    {}
+
+func (m *GossipBlock) GetBlockState() *BlockState {
+	if m != nil {
+		return m.BlockState
+	}
+	return nil
+}
+
+// GossipDigest is the payload of Message.GOSSIP_DIGEST. The height of the
+// blockchain of a peer and the hash of its head block, which the NVPs exchange
+// periodically to send each other the blocks the other one misses.
+type GossipDigest struct {
+	Height        uint64 `protobuf:"varint,1,opt,name=height" json:"height,omitempty"`
+	HeadBlockHash []byte `protobuf:"bytes,2,opt,name=headBlockHash,proto3" json:"headBlockHash,omitempty"`
+}
+
+func (m *GossipDigest) Reset()         { *m = GossipDigest{} }
+func (m *GossipDigest) String() string { return proto.CompactTextString(m) }
+func (*GossipDigest) ProtoMessage()    {}
+
 func init() {
+	proto.RegisterEnum("protos.SyncCompression", SyncCompression_name, SyncCompression_value)
 	proto.RegisterEnum("protos.Transaction_Type", Transaction_Type_name, Transaction_Type_value)
 	proto.RegisterEnum("protos.PeerEndpoint_Type", PeerEndpoint_Type_name, PeerEndpoint_Type_value)
 	proto.RegisterEnum("protos.Message_Type", Message_Type_name, Message_Type_value)
@@ -560,6 +983,12 @@ type PeerClient interface {
 	Chat(ctx context.Context, opts ...grpc.CallOption) (Peer_ChatClient, error)
 	// Process a transaction from a remote source.
 	ProcessTransaction(ctx context.Context, in *Transaction, opts ...grpc.CallOption) (*Response, error)
+	// Process a read-only query from a remote source against the committed
+	// state, without consensus.
+	ProcessQuery(ctx context.Context, in *Transaction, opts ...grpc.CallOption) (*QueryResponse, error)
+	// Simulate an invoke transaction from a remote source against the
+	// committed state, without submitting it.
+	SimulateTransaction(ctx context.Context, in *Transaction, opts ...grpc.CallOption) (*SimulationResult, error)
 }
 
 type peerClient struct {
@@ -610,6 +1039,24 @@ func (c *peerClient) ProcessTransaction(ctx context.Context, in *Transaction, op
 	return out, nil
 }
 
+func (c *peerClient) ProcessQuery(ctx context.Context, in *Transaction, opts ...grpc.CallOption) (*QueryResponse, error) {
+	out := new(QueryResponse)
+	err := grpc.Invoke(ctx, "/protos.Peer/ProcessQuery", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *peerClient) SimulateTransaction(ctx context.Context, in *Transaction, opts ...grpc.CallOption) (*SimulationResult, error) {
+	out := new(SimulationResult)
+	err := grpc.Invoke(ctx, "/protos.Peer/SimulateTransaction", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // Server API for Peer service
 
 type PeerServer interface {
@@ -618,6 +1065,12 @@ type PeerServer interface {
 	Chat(Peer_ChatServer) error
 	// Process a transaction from a remote source.
 	ProcessTransaction(context.Context, *Transaction) (*Response, error)
+	// Process a read-only query from a remote source against the committed
+	// state, without consensus.
+	ProcessQuery(context.Context, *Transaction) (*QueryResponse, error)
+	// Simulate an invoke transaction from a remote source against the
+	// committed state, without submitting it.
+	SimulateTransaction(context.Context, *Transaction) (*SimulationResult, error)
 }
 
 func RegisterPeerServer(s *grpc.Server, srv PeerServer) {
@@ -662,6 +1115,30 @@ func _Peer_ProcessTransaction_Handler(srv interface{}, ctx context.Context, dec
 	return out, nil
 }
 
+func _Peer_ProcessQuery_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(Transaction)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(PeerServer).ProcessQuery(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Peer_SimulateTransaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(Transaction)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(PeerServer).SimulateTransaction(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 var _Peer_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "protos.Peer",
 	HandlerType: (*PeerServer)(nil),
@@ -670,6 +1147,14 @@ var _Peer_serviceDesc = grpc.ServiceDesc{
 			MethodName: "ProcessTransaction",
 			Handler:    _Peer_ProcessTransaction_Handler,
 		},
+		{
+			MethodName: "ProcessQuery",
+			Handler:    _Peer_ProcessQuery_Handler,
+		},
+		{
+			MethodName: "SimulateTransaction",
+			Handler:    _Peer_SimulateTransaction_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -59,6 +59,345 @@ func (m *ServerStatus) Reset()         { *m = ServerStatus{} }
 func (m *ServerStatus) String() string { return proto.CompactTextString(m) }
 func (*ServerStatus) ProtoMessage()    {}
 
+type BackupRequest struct {
+	// Directory of the backups on the file system of the peer. Backups are
+	// incremental, hence, a directory can hold several backups.
+	BackupDir string `protobuf:"bytes,1,opt,name=backupDir" json:"backupDir,omitempty"`
+}
+
+func (m *BackupRequest) Reset()         { *m = BackupRequest{} }
+func (m *BackupRequest) String() string { return proto.CompactTextString(m) }
+func (*BackupRequest) ProtoMessage()    {}
+
+type BackupInfo struct {
+	BackupID int64 `protobuf:"varint,1,opt,name=backupID" json:"backupID,omitempty"`
+	// Unix time (in seconds) at which the backup has been taken.
+	Timestamp int64 `protobuf:"varint,2,opt,name=timestamp" json:"timestamp,omitempty"`
+	Size      int64 `protobuf:"varint,3,opt,name=size" json:"size,omitempty"`
+	NumFiles  int32 `protobuf:"varint,4,opt,name=numFiles" json:"numFiles,omitempty"`
+}
+
+func (m *BackupInfo) Reset()         { *m = BackupInfo{} }
+func (m *BackupInfo) String() string { return proto.CompactTextString(m) }
+func (*BackupInfo) ProtoMessage()    {}
+
+type CompactionRequest struct {
+	// Column families to compact. The state and state delta column families are
+	// compacted if none is given.
+	ColumnFamilies []string `protobuf:"bytes,1,rep,name=columnFamilies" json:"columnFamilies,omitempty"`
+}
+
+func (m *CompactionRequest) Reset()         { *m = CompactionRequest{} }
+func (m *CompactionRequest) String() string { return proto.CompactTextString(m) }
+func (*CompactionRequest) ProtoMessage()    {}
+
+type CompactionResult struct {
+	ColumnFamilies []*CompactionResult_ColumnFamily `protobuf:"bytes,1,rep,name=columnFamilies" json:"columnFamilies,omitempty"`
+}
+
+func (m *CompactionResult) Reset()         { *m = CompactionResult{} }
+func (m *CompactionResult) String() string { return proto.CompactTextString(m) }
+func (*CompactionResult) ProtoMessage()    {}
+
+func (m *CompactionResult) GetColumnFamilies() []*CompactionResult_ColumnFamily {
+	if m != nil {
+		return m.ColumnFamilies
+	}
+	return nil
+}
+
+type CompactionResult_ColumnFamily struct {
+	Name string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	// Size of the table files before and after the compaction.
+	BytesBefore    uint64 `protobuf:"varint,2,opt,name=bytesBefore" json:"bytesBefore,omitempty"`
+	BytesAfter     uint64 `protobuf:"varint,3,opt,name=bytesAfter" json:"bytesAfter,omitempty"`
+	DurationMillis int64  `protobuf:"varint,4,opt,name=durationMillis" json:"durationMillis,omitempty"`
+}
+
+func (m *CompactionResult_ColumnFamily) Reset()         { *m = CompactionResult_ColumnFamily{} }
+func (m *CompactionResult_ColumnFamily) String() string { return proto.CompactTextString(m) }
+func (*CompactionResult_ColumnFamily) ProtoMessage()    {}
+
+type PruneStateRequest struct {
+	// ID of the chain whose state is pruned, the default chain if empty.
+	ChainID string `protobuf:"bytes,1,opt,name=chainID" json:"chainID,omitempty"`
+}
+
+func (m *PruneStateRequest) Reset()         { *m = PruneStateRequest{} }
+func (m *PruneStateRequest) String() string { return proto.CompactTextString(m) }
+func (*PruneStateRequest) ProtoMessage()    {}
+
+type PruneStateResult struct {
+	DataNodesRemoved         uint64 `protobuf:"varint,1,opt,name=dataNodesRemoved" json:"dataNodesRemoved,omitempty"`
+	IntermediateNodesRemoved uint64 `protobuf:"varint,2,opt,name=intermediateNodesRemoved" json:"intermediateNodesRemoved,omitempty"`
+	OtherNodesRemoved        uint64 `protobuf:"varint,3,opt,name=otherNodesRemoved" json:"otherNodesRemoved,omitempty"`
+	BytesReclaimed           uint64 `protobuf:"varint,4,opt,name=bytesReclaimed" json:"bytesReclaimed,omitempty"`
+}
+
+func (m *PruneStateResult) Reset()         { *m = PruneStateResult{} }
+func (m *PruneStateResult) String() string { return proto.CompactTextString(m) }
+func (*PruneStateResult) ProtoMessage()    {}
+
+type BatchPolicy struct {
+	// Number of transactions after which a block is cut. Must be > 0.
+	MaxMessageCount uint32 `protobuf:"varint,1,opt,name=maxMessageCount" json:"maxMessageCount,omitempty"`
+	// Maximum cumulative marshalled size of the transactions of a block; a
+	// transaction which does not fit starts the next block. 0 is no limit.
+	MaxBytes uint64 `protobuf:"varint,2,opt,name=maxBytes" json:"maxBytes,omitempty"`
+	// Time a block waits for more transactions after its first one.
+	TimeoutMillis int64 `protobuf:"varint,3,opt,name=timeoutMillis" json:"timeoutMillis,omitempty"`
+}
+
+func (m *BatchPolicy) Reset()         { *m = BatchPolicy{} }
+func (m *BatchPolicy) String() string { return proto.CompactTextString(m) }
+func (*BatchPolicy) ProtoMessage()    {}
+
+type DiscoveryRequest struct {
+	// Address of the peer endpoint, host:port.
+	Address string `protobuf:"bytes,1,opt,name=address" json:"address,omitempty"`
+}
+
+func (m *DiscoveryRequest) Reset()         { *m = DiscoveryRequest{} }
+func (m *DiscoveryRequest) String() string { return proto.CompactTextString(m) }
+func (*DiscoveryRequest) ProtoMessage()    {}
+
+type DiscoveryList struct {
+	// Addresses of the peers to reconnect to, the ones discovered and the ones
+	// added through the Admin service. Kept across restarts.
+	Addresses []string `protobuf:"bytes,1,rep,name=addresses" json:"addresses,omitempty"`
+	// Addresses of the peers that are neither connected to nor accepted.
+	Banned []string `protobuf:"bytes,2,rep,name=banned" json:"banned,omitempty"`
+	// Identities of the peers banned by their address, which are refused
+	// whatever address they advertise: their PKI-ID when security is enabled,
+	// the host they connect from otherwise.
+	BannedIdentities []*DiscoveryList_BannedIdentity `protobuf:"bytes,3,rep,name=bannedIdentities" json:"bannedIdentities,omitempty"`
+}
+
+func (m *DiscoveryList) Reset()         { *m = DiscoveryList{} }
+func (m *DiscoveryList) String() string { return proto.CompactTextString(m) }
+func (*DiscoveryList) ProtoMessage()    {}
+
+func (m *DiscoveryList) GetBannedIdentities() []*DiscoveryList_BannedIdentity {
+	if m != nil {
+		return m.BannedIdentities
+	}
+	return nil
+}
+
+type DiscoveryList_BannedIdentity struct {
+	Identity string `protobuf:"bytes,1,opt,name=identity" json:"identity,omitempty"`
+	// Banned address of the peer, lifting its ban lifts this one.
+	Address string `protobuf:"bytes,2,opt,name=address" json:"address,omitempty"`
+}
+
+func (m *DiscoveryList_BannedIdentity) Reset()         { *m = DiscoveryList_BannedIdentity{} }
+func (m *DiscoveryList_BannedIdentity) String() string { return proto.CompactTextString(m) }
+func (*DiscoveryList_BannedIdentity) ProtoMessage()    {}
+
+type AdmissionStats struct {
+	Classes []*AdmissionStats_Class `protobuf:"bytes,1,rep,name=classes" json:"classes,omitempty"`
+}
+
+func (m *AdmissionStats) Reset()         { *m = AdmissionStats{} }
+func (m *AdmissionStats) String() string { return proto.CompactTextString(m) }
+func (*AdmissionStats) ProtoMessage()    {}
+
+func (m *AdmissionStats) GetClasses() []*AdmissionStats_Class {
+	if m != nil {
+		return m.Classes
+	}
+	return nil
+}
+
+type AdmissionStats_Class struct {
+	// Kind of the requests, submit (deploy, invoke) or query.
+	Name     string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Admitted uint64 `protobuf:"varint,2,opt,name=admitted" json:"admitted,omitempty"`
+	// Requests rejected for exceeding a rate or in-flight limit.
+	Rejected uint64 `protobuf:"varint,3,opt,name=rejected" json:"rejected,omitempty"`
+	// Requests admitted and still being processed, the queue depth.
+	InFlight uint32 `protobuf:"varint,4,opt,name=inFlight" json:"inFlight,omitempty"`
+	// Highest number of requests processed at once since the peer started.
+	MaxInFlight uint32 `protobuf:"varint,5,opt,name=maxInFlight" json:"maxInFlight,omitempty"`
+	// Clients tracked by the per client rate limit.
+	Clients uint32 `protobuf:"varint,6,opt,name=clients" json:"clients,omitempty"`
+}
+
+func (m *AdmissionStats_Class) Reset()         { *m = AdmissionStats_Class{} }
+func (m *AdmissionStats_Class) String() string { return proto.CompactTextString(m) }
+func (*AdmissionStats_Class) ProtoMessage()    {}
+
+type ACLPolicy struct {
+	// Whether the access to the client APIs is controlled.
+	Enabled bool `protobuf:"varint,1,opt,name=enabled" json:"enabled,omitempty"`
+	// Role of the clients not in members: reader, submitter, admin, or none if
+	// empty.
+	DefaultRole string                  `protobuf:"bytes,2,opt,name=defaultRole" json:"defaultRole,omitempty"`
+	Members     []*ACLPolicy_Member     `protobuf:"bytes,3,rep,name=members" json:"members,omitempty"`
+	Permissions []*ACLPolicy_Permission `protobuf:"bytes,4,rep,name=permissions" json:"permissions,omitempty"`
+}
+
+func (m *ACLPolicy) Reset()         { *m = ACLPolicy{} }
+func (m *ACLPolicy) String() string { return proto.CompactTextString(m) }
+func (*ACLPolicy) ProtoMessage()    {}
+
+func (m *ACLPolicy) GetMembers() []*ACLPolicy_Member {
+	if m != nil {
+		return m.Members
+	}
+	return nil
+}
+
+func (m *ACLPolicy) GetPermissions() []*ACLPolicy_Permission {
+	if m != nil {
+		return m.Permissions
+	}
+	return nil
+}
+
+type ACLPolicy_Member struct {
+	// Common name of the TLS client certificate, identity of a bearer token or
+	// enrollment ID of the client.
+	Identity string `protobuf:"bytes,1,opt,name=identity" json:"identity,omitempty"`
+	Role     string `protobuf:"bytes,2,opt,name=role" json:"role,omitempty"`
+}
+
+func (m *ACLPolicy_Member) Reset()         { *m = ACLPolicy_Member{} }
+func (m *ACLPolicy_Member) String() string { return proto.CompactTextString(m) }
+func (*ACLPolicy_Member) ProtoMessage()    {}
+
+type ACLPolicy_Permission struct {
+	// deploy, invoke, query, admin or events.
+	Api string `protobuf:"bytes,1,opt,name=api" json:"api,omitempty"`
+	// Lowest role allowed to call the API.
+	Role string `protobuf:"bytes,2,opt,name=role" json:"role,omitempty"`
+}
+
+func (m *ACLPolicy_Permission) Reset()         { *m = ACLPolicy_Permission{} }
+func (m *ACLPolicy_Permission) String() string { return proto.CompactTextString(m) }
+func (*ACLPolicy_Permission) ProtoMessage()    {}
+
+type LogLevel struct {
+	// Module such as state, buckettree, db or consensus, empty for the default
+	// level.
+	Module string `protobuf:"bytes,1,opt,name=module" json:"module,omitempty"`
+	// CRITICAL, ERROR, WARNING, NOTICE, INFO or DEBUG.
+	Level string `protobuf:"bytes,2,opt,name=level" json:"level,omitempty"`
+}
+
+func (m *LogLevel) Reset()         { *m = LogLevel{} }
+func (m *LogLevel) String() string { return proto.CompactTextString(m) }
+func (*LogLevel) ProtoMessage()    {}
+
+type LogLevels struct {
+	// Level of the modules without a level of their own.
+	DefaultLevel string `protobuf:"bytes,1,opt,name=defaultLevel" json:"defaultLevel,omitempty"`
+	// Levels of the modules, by module name.
+	Modules []*LogLevel `protobuf:"bytes,2,rep,name=modules" json:"modules,omitempty"`
+}
+
+func (m *LogLevels) Reset()         { *m = LogLevels{} }
+func (m *LogLevels) String() string { return proto.CompactTextString(m) }
+func (*LogLevels) ProtoMessage()    {}
+
+func (m *LogLevels) GetModules() []*LogLevel {
+	if m != nil {
+		return m.Modules
+	}
+	return nil
+}
+
+type ProfileRequest struct {
+	// goroutine, heap, threadcreate, block, cpu or trace.
+	Name string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	// Duration of the cpu profile and of the trace, 30 seconds if 0.
+	Seconds uint32 `protobuf:"varint,2,opt,name=seconds" json:"seconds,omitempty"`
+	// Format of the other profiles: 0 for the binary format of pprof, 1 for
+	// text, 2 for the goroutine dump with the stack of each goroutine.
+	Debug int32 `protobuf:"varint,3,opt,name=debug" json:"debug,omitempty"`
+}
+
+func (m *ProfileRequest) Reset()         { *m = ProfileRequest{} }
+func (m *ProfileRequest) String() string { return proto.CompactTextString(m) }
+func (*ProfileRequest) ProtoMessage()    {}
+
+type Profile struct {
+	Data []byte `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+}
+
+func (m *Profile) Reset()         { *m = Profile{} }
+func (m *Profile) String() string { return proto.CompactTextString(m) }
+func (*Profile) ProtoMessage()    {}
+
+type LedgerRuntimeStatsRequest struct {
+	// ID of the chain, the default chain if empty.
+	ChainID string `protobuf:"bytes,1,opt,name=chainID" json:"chainID,omitempty"`
+}
+
+func (m *LedgerRuntimeStatsRequest) Reset()         { *m = LedgerRuntimeStatsRequest{} }
+func (m *LedgerRuntimeStatsRequest) String() string { return proto.CompactTextString(m) }
+func (*LedgerRuntimeStatsRequest) ProtoMessage()    {}
+
+type LedgerRuntimeStats struct {
+	BlockchainSize uint64 `protobuf:"varint,1,opt,name=blockchainSize" json:"blockchainSize,omitempty"`
+	// Unix time (in milliseconds) at which the last block was added, 0 if none
+	// since the peer started.
+	LastBlockCommitted int64 `protobuf:"varint,2,opt,name=lastBlockCommitted" json:"lastBlockCommitted,omitempty"`
+	// Transaction between TxBegin and TxFinish, empty if none.
+	TxInProgress string `protobuf:"bytes,3,opt,name=txInProgress" json:"txInProgress,omitempty"`
+	// Time elapsed since TxBegin of txInProgress.
+	TxInProgressMillis int64 `protobuf:"varint,4,opt,name=txInProgressMillis" json:"txInProgressMillis,omitempty"`
+	// Number of successful transactions of the batch in progress.
+	BatchTxs uint32 `protobuf:"varint,5,opt,name=batchTxs" json:"batchTxs,omitempty"`
+	// Number of keys changed by the batch and held in memory.
+	PendingKeys   uint64 `protobuf:"varint,6,opt,name=pendingKeys" json:"pendingKeys,omitempty"`
+	StagedChanges bool   `protobuf:"varint,7,opt,name=stagedChanges" json:"stagedChanges,omitempty"`
+	WalInUse      bool   `protobuf:"varint,8,opt,name=walInUse" json:"walInUse,omitempty"`
+}
+
+func (m *LedgerRuntimeStats) Reset()         { *m = LedgerRuntimeStats{} }
+func (m *LedgerRuntimeStats) String() string { return proto.CompactTextString(m) }
+func (*LedgerRuntimeStats) ProtoMessage()    {}
+
+type DBPropertiesRequest struct {
+	// ID of the chain, the default chain if empty.
+	ChainID string `protobuf:"bytes,1,opt,name=chainID" json:"chainID,omitempty"`
+	// Names of the properties, such as rocksdb.cfstats. The properties that
+	// tell whether flushes, compactions or writes are stalled if none is given.
+	Properties []string `protobuf:"bytes,2,rep,name=properties" json:"properties,omitempty"`
+	// All the column families if none is given.
+	ColumnFamilies []string `protobuf:"bytes,3,rep,name=columnFamilies" json:"columnFamilies,omitempty"`
+}
+
+func (m *DBPropertiesRequest) Reset()         { *m = DBPropertiesRequest{} }
+func (m *DBPropertiesRequest) String() string { return proto.CompactTextString(m) }
+func (*DBPropertiesRequest) ProtoMessage()    {}
+
+type DBProperties struct {
+	Properties []*DBProperties_Property `protobuf:"bytes,1,rep,name=properties" json:"properties,omitempty"`
+}
+
+func (m *DBProperties) Reset()         { *m = DBProperties{} }
+func (m *DBProperties) String() string { return proto.CompactTextString(m) }
+func (*DBProperties) ProtoMessage()    {}
+
+func (m *DBProperties) GetProperties() []*DBProperties_Property {
+	if m != nil {
+		return m.Properties
+	}
+	return nil
+}
+
+type DBProperties_Property struct {
+	ColumnFamily string `protobuf:"bytes,1,opt,name=columnFamily" json:"columnFamily,omitempty"`
+	Name         string `protobuf:"bytes,2,opt,name=name" json:"name,omitempty"`
+	Value        string `protobuf:"bytes,3,opt,name=value" json:"value,omitempty"`
+}
+
+func (m *DBProperties_Property) Reset()         { *m = DBProperties_Property{} }
+func (m *DBProperties_Property) String() string { return proto.CompactTextString(m) }
+func (*DBProperties_Property) ProtoMessage()    {}
+
 func init() {
 	proto.RegisterEnum("protos.ServerStatus_StatusCode", ServerStatus_StatusCode_name, ServerStatus_StatusCode_value)
 }
@@ -74,6 +413,46 @@ type AdminClient interface {
 	GetStatus(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*ServerStatus, error)
 	StartServer(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*ServerStatus, error)
 	StopServer(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*ServerStatus, error)
+	// Take a consistent backup of the DB of the peer while it is running.
+	CreateBackup(ctx context.Context, in *BackupRequest, opts ...grpc.CallOption) (*BackupInfo, error)
+	// Compact column families of the DB of the peer, which drops the tombstones of
+	// the deleted keys.
+	CompactDB(ctx context.Context, in *CompactionRequest, opts ...grpc.CallOption) (*CompactionResult, error)
+	// Remove the nodes of the state data structure that are no longer reachable
+	// from the current root.
+	PruneState(ctx context.Context, in *PruneStateRequest, opts ...grpc.CallOption) (*PruneStateResult, error)
+	// Return the batch policy by which the consensus plugin forms blocks.
+	GetBatchPolicy(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*BatchPolicy, error)
+	// Replace the batch policy by which the consensus plugin forms blocks, it
+	// applies from the next transaction. Returns the policy in effect.
+	SetBatchPolicy(ctx context.Context, in *BatchPolicy, opts ...grpc.CallOption) (*BatchPolicy, error)
+	// Return the endpoints the peer reconnects to and the ones it bans.
+	GetDiscoveryList(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*DiscoveryList, error)
+	// Add an endpoint the peer connects to, lifting its ban if any.
+	AddPeer(ctx context.Context, in *DiscoveryRequest, opts ...grpc.CallOption) (*DiscoveryList, error)
+	// Remove an endpoint, the peer no longer reconnects to it.
+	RemovePeer(ctx context.Context, in *DiscoveryRequest, opts ...grpc.CallOption) (*DiscoveryList, error)
+	// Ban an endpoint, the peer disconnects from it and refuses its connections.
+	BanPeer(ctx context.Context, in *DiscoveryRequest, opts ...grpc.CallOption) (*DiscoveryList, error)
+	// Return the counters of the admission control of the client requests.
+	GetAdmissionStats(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*AdmissionStats, error)
+	// Return the access control list of the client APIs of the peer.
+	GetACLPolicy(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*ACLPolicy, error)
+	// Replace the access control list of the client APIs of the peer, returns the list in effect.
+	SetACLPolicy(ctx context.Context, in *ACLPolicy, opts ...grpc.CallOption) (*ACLPolicy, error)
+	// Return the logging levels of the modules of the peer.
+	GetLogLevels(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*LogLevels, error)
+	// Set the logging level of a module and of its submodules, or the default
+	// level if the module is empty. Returns the levels in effect.
+	SetLogLevel(ctx context.Context, in *LogLevel, opts ...grpc.CallOption) (*LogLevels, error)
+	// Return a pprof profile of the peer, such as goroutine, heap or cpu.
+	GetProfile(ctx context.Context, in *ProfileRequest, opts ...grpc.CallOption) (*Profile, error)
+	// Return the statistics of the transaction-batch in progress of a chain, for
+	// diagnosing commits that do not progress.
+	GetLedgerRuntimeStats(ctx context.Context, in *LedgerRuntimeStatsRequest, opts ...grpc.CallOption) (*LedgerRuntimeStats, error)
+	// Return the values of rocksdb properties of the column families of the DB of
+	// a chain.
+	GetDBProperties(ctx context.Context, in *DBPropertiesRequest, opts ...grpc.CallOption) (*DBProperties, error)
 }
 
 type adminClient struct {
@@ -111,6 +490,159 @@ func (c *adminClient) StopServer(ctx context.Context, in *google_protobuf1.Empty
 	return out, nil
 }
 
+func (c *adminClient) CreateBackup(ctx context.Context, in *BackupRequest, opts ...grpc.CallOption) (*BackupInfo, error) {
+	out := new(BackupInfo)
+	err := grpc.Invoke(ctx, "/protos.Admin/CreateBackup", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) CompactDB(ctx context.Context, in *CompactionRequest, opts ...grpc.CallOption) (*CompactionResult, error) {
+	out := new(CompactionResult)
+	err := grpc.Invoke(ctx, "/protos.Admin/CompactDB", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) PruneState(ctx context.Context, in *PruneStateRequest, opts ...grpc.CallOption) (*PruneStateResult, error) {
+	out := new(PruneStateResult)
+	err := grpc.Invoke(ctx, "/protos.Admin/PruneState", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) GetBatchPolicy(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*BatchPolicy, error) {
+	out := new(BatchPolicy)
+	err := grpc.Invoke(ctx, "/protos.Admin/GetBatchPolicy", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) SetBatchPolicy(ctx context.Context, in *BatchPolicy, opts ...grpc.CallOption) (*BatchPolicy, error) {
+	out := new(BatchPolicy)
+	err := grpc.Invoke(ctx, "/protos.Admin/SetBatchPolicy", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) GetDiscoveryList(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*DiscoveryList, error) {
+	out := new(DiscoveryList)
+	err := grpc.Invoke(ctx, "/protos.Admin/GetDiscoveryList", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) AddPeer(ctx context.Context, in *DiscoveryRequest, opts ...grpc.CallOption) (*DiscoveryList, error) {
+	out := new(DiscoveryList)
+	err := grpc.Invoke(ctx, "/protos.Admin/AddPeer", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) RemovePeer(ctx context.Context, in *DiscoveryRequest, opts ...grpc.CallOption) (*DiscoveryList, error) {
+	out := new(DiscoveryList)
+	err := grpc.Invoke(ctx, "/protos.Admin/RemovePeer", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) BanPeer(ctx context.Context, in *DiscoveryRequest, opts ...grpc.CallOption) (*DiscoveryList, error) {
+	out := new(DiscoveryList)
+	err := grpc.Invoke(ctx, "/protos.Admin/BanPeer", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) GetAdmissionStats(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*AdmissionStats, error) {
+	out := new(AdmissionStats)
+	err := grpc.Invoke(ctx, "/protos.Admin/GetAdmissionStats", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) GetACLPolicy(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*ACLPolicy, error) {
+	out := new(ACLPolicy)
+	err := grpc.Invoke(ctx, "/protos.Admin/GetACLPolicy", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) SetACLPolicy(ctx context.Context, in *ACLPolicy, opts ...grpc.CallOption) (*ACLPolicy, error) {
+	out := new(ACLPolicy)
+	err := grpc.Invoke(ctx, "/protos.Admin/SetACLPolicy", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) GetLogLevels(ctx context.Context, in *google_protobuf1.Empty, opts ...grpc.CallOption) (*LogLevels, error) {
+	out := new(LogLevels)
+	err := grpc.Invoke(ctx, "/protos.Admin/GetLogLevels", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) SetLogLevel(ctx context.Context, in *LogLevel, opts ...grpc.CallOption) (*LogLevels, error) {
+	out := new(LogLevels)
+	err := grpc.Invoke(ctx, "/protos.Admin/SetLogLevel", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) GetProfile(ctx context.Context, in *ProfileRequest, opts ...grpc.CallOption) (*Profile, error) {
+	out := new(Profile)
+	err := grpc.Invoke(ctx, "/protos.Admin/GetProfile", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) GetLedgerRuntimeStats(ctx context.Context, in *LedgerRuntimeStatsRequest, opts ...grpc.CallOption) (*LedgerRuntimeStats, error) {
+	out := new(LedgerRuntimeStats)
+	err := grpc.Invoke(ctx, "/protos.Admin/GetLedgerRuntimeStats", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminClient) GetDBProperties(ctx context.Context, in *DBPropertiesRequest, opts ...grpc.CallOption) (*DBProperties, error) {
+	out := new(DBProperties)
+	err := grpc.Invoke(ctx, "/protos.Admin/GetDBProperties", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // Server API for Admin service
 
 type AdminServer interface {
@@ -118,6 +650,46 @@ type AdminServer interface {
 	GetStatus(context.Context, *google_protobuf1.Empty) (*ServerStatus, error)
 	StartServer(context.Context, *google_protobuf1.Empty) (*ServerStatus, error)
 	StopServer(context.Context, *google_protobuf1.Empty) (*ServerStatus, error)
+	// Take a consistent backup of the DB of the peer while it is running.
+	CreateBackup(context.Context, *BackupRequest) (*BackupInfo, error)
+	// Compact column families of the DB of the peer, which drops the tombstones of
+	// the deleted keys.
+	CompactDB(context.Context, *CompactionRequest) (*CompactionResult, error)
+	// Remove the nodes of the state data structure that are no longer reachable
+	// from the current root.
+	PruneState(context.Context, *PruneStateRequest) (*PruneStateResult, error)
+	// Return the batch policy by which the consensus plugin forms blocks.
+	GetBatchPolicy(context.Context, *google_protobuf1.Empty) (*BatchPolicy, error)
+	// Replace the batch policy by which the consensus plugin forms blocks, it
+	// applies from the next transaction. Returns the policy in effect.
+	SetBatchPolicy(context.Context, *BatchPolicy) (*BatchPolicy, error)
+	// Return the endpoints the peer reconnects to and the ones it bans.
+	GetDiscoveryList(context.Context, *google_protobuf1.Empty) (*DiscoveryList, error)
+	// Add an endpoint the peer connects to, lifting its ban if any.
+	AddPeer(context.Context, *DiscoveryRequest) (*DiscoveryList, error)
+	// Remove an endpoint, the peer no longer reconnects to it.
+	RemovePeer(context.Context, *DiscoveryRequest) (*DiscoveryList, error)
+	// Ban an endpoint, the peer disconnects from it and refuses its connections.
+	BanPeer(context.Context, *DiscoveryRequest) (*DiscoveryList, error)
+	// Return the counters of the admission control of the client requests.
+	GetAdmissionStats(context.Context, *google_protobuf1.Empty) (*AdmissionStats, error)
+	// Return the access control list of the client APIs of the peer.
+	GetACLPolicy(context.Context, *google_protobuf1.Empty) (*ACLPolicy, error)
+	// Replace the access control list of the client APIs of the peer, returns the list in effect.
+	SetACLPolicy(context.Context, *ACLPolicy) (*ACLPolicy, error)
+	// Return the logging levels of the modules of the peer.
+	GetLogLevels(context.Context, *google_protobuf1.Empty) (*LogLevels, error)
+	// Set the logging level of a module and of its submodules, or the default
+	// level if the module is empty. Returns the levels in effect.
+	SetLogLevel(context.Context, *LogLevel) (*LogLevels, error)
+	// Return a pprof profile of the peer, such as goroutine, heap or cpu.
+	GetProfile(context.Context, *ProfileRequest) (*Profile, error)
+	// Return the statistics of the transaction-batch in progress of a chain, for
+	// diagnosing commits that do not progress.
+	GetLedgerRuntimeStats(context.Context, *LedgerRuntimeStatsRequest) (*LedgerRuntimeStats, error)
+	// Return the values of rocksdb properties of the column families of the DB of
+	// a chain.
+	GetDBProperties(context.Context, *DBPropertiesRequest) (*DBProperties, error)
 }
 
 func RegisterAdminServer(s *grpc.Server, srv AdminServer) {
@@ -160,6 +732,210 @@ func _Admin_StopServer_Handler(srv interface{}, ctx context.Context, dec func(in
 	return out, nil
 }
 
+func _Admin_CreateBackup_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(BackupRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(AdminServer).CreateBackup(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Admin_CompactDB_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(CompactionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(AdminServer).CompactDB(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Admin_PruneState_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(PruneStateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(AdminServer).PruneState(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Admin_GetBatchPolicy_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(google_protobuf1.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(AdminServer).GetBatchPolicy(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Admin_SetBatchPolicy_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(BatchPolicy)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(AdminServer).SetBatchPolicy(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Admin_GetDiscoveryList_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(google_protobuf1.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(AdminServer).GetDiscoveryList(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Admin_AddPeer_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(DiscoveryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(AdminServer).AddPeer(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Admin_RemovePeer_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(DiscoveryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(AdminServer).RemovePeer(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Admin_BanPeer_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(DiscoveryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(AdminServer).BanPeer(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Admin_GetAdmissionStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(google_protobuf1.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(AdminServer).GetAdmissionStats(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Admin_GetACLPolicy_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(google_protobuf1.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(AdminServer).GetACLPolicy(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Admin_SetACLPolicy_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(ACLPolicy)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(AdminServer).SetACLPolicy(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Admin_GetLogLevels_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(google_protobuf1.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(AdminServer).GetLogLevels(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Admin_SetLogLevel_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(LogLevel)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(AdminServer).SetLogLevel(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Admin_GetProfile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(ProfileRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(AdminServer).GetProfile(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Admin_GetLedgerRuntimeStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(LedgerRuntimeStatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(AdminServer).GetLedgerRuntimeStats(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func _Admin_GetDBProperties_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error) (interface{}, error) {
+	in := new(DBPropertiesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	out, err := srv.(AdminServer).GetDBProperties(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 var _Admin_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "protos.Admin",
 	HandlerType: (*AdminServer)(nil),
@@ -176,6 +952,74 @@ var _Admin_serviceDesc = grpc.ServiceDesc{
 			MethodName: "StopServer",
 			Handler:    _Admin_StopServer_Handler,
 		},
+		{
+			MethodName: "CreateBackup",
+			Handler:    _Admin_CreateBackup_Handler,
+		},
+		{
+			MethodName: "CompactDB",
+			Handler:    _Admin_CompactDB_Handler,
+		},
+		{
+			MethodName: "PruneState",
+			Handler:    _Admin_PruneState_Handler,
+		},
+		{
+			MethodName: "GetBatchPolicy",
+			Handler:    _Admin_GetBatchPolicy_Handler,
+		},
+		{
+			MethodName: "SetBatchPolicy",
+			Handler:    _Admin_SetBatchPolicy_Handler,
+		},
+		{
+			MethodName: "GetDiscoveryList",
+			Handler:    _Admin_GetDiscoveryList_Handler,
+		},
+		{
+			MethodName: "AddPeer",
+			Handler:    _Admin_AddPeer_Handler,
+		},
+		{
+			MethodName: "RemovePeer",
+			Handler:    _Admin_RemovePeer_Handler,
+		},
+		{
+			MethodName: "BanPeer",
+			Handler:    _Admin_BanPeer_Handler,
+		},
+		{
+			MethodName: "GetAdmissionStats",
+			Handler:    _Admin_GetAdmissionStats_Handler,
+		},
+		{
+			MethodName: "GetACLPolicy",
+			Handler:    _Admin_GetACLPolicy_Handler,
+		},
+		{
+			MethodName: "SetACLPolicy",
+			Handler:    _Admin_SetACLPolicy_Handler,
+		},
+		{
+			MethodName: "GetLogLevels",
+			Handler:    _Admin_GetLogLevels_Handler,
+		},
+		{
+			MethodName: "SetLogLevel",
+			Handler:    _Admin_SetLogLevel_Handler,
+		},
+		{
+			MethodName: "GetProfile",
+			Handler:    _Admin_GetProfile_Handler,
+		},
+		{
+			MethodName: "GetLedgerRuntimeStats",
+			Handler:    _Admin_GetLedgerRuntimeStats_Handler,
+		},
+		{
+			MethodName: "GetDBProperties",
+			Handler:    _Admin_GetDBProperties_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{},
 }
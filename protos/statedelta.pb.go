@@ -0,0 +1,110 @@
+// Code generated by protoc-gen-go.
+// source: statedelta.proto
+// DO NOT EDIT!
+
+package protos
+
+import proto "github.com/golang/protobuf/proto"
+import fmt "fmt"
+import math "math"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// StateDeltaMessage is the serialized form of the changes made to the world
+// state by a block (or by a chunk of blocks during state transfer). Persisted
+// deltas and the deltas exchanged in SyncStateDeltas/SyncStateSnapshot hold a
+// single 0x00 byte followed by this message; the leading byte distinguishes it
+// from the legacy encoding which never starts with 0x00 unless it is empty.
+// Chaincodes and keys are sorted lexicographically so that the encoding is
+// canonical.
+type StateDeltaMessage struct {
+	// version of the encoding, currently 2 (1 being the legacy encoding)
+	Version              uint32                        `protobuf:"varint,1,opt,name=version" json:"version,omitempty"`
+	ChaincodeStateDeltas []*ChaincodeStateDeltaMessage `protobuf:"bytes,2,rep,name=chaincodeStateDeltas" json:"chaincodeStateDeltas,omitempty"`
+}
+
+func (m *StateDeltaMessage) Reset()         { *m = StateDeltaMessage{} }
+func (m *StateDeltaMessage) String() string { return proto.CompactTextString(m) }
+func (*StateDeltaMessage) ProtoMessage()    {}
+
+func (m *StateDeltaMessage) GetChaincodeStateDeltas() []*ChaincodeStateDeltaMessage {
+	if m != nil {
+		return m.ChaincodeStateDeltas
+	}
+	return nil
+}
+
+// ChaincodeStateDeltaMessage holds the updates made to the keys of a chaincode
+type ChaincodeStateDeltaMessage struct {
+	ChaincodeID string                 `protobuf:"bytes,1,opt,name=chaincodeID" json:"chaincodeID,omitempty"`
+	UpdatedKVs  []*UpdatedValueMessage `protobuf:"bytes,2,rep,name=updatedKVs" json:"updatedKVs,omitempty"`
+}
+
+func (m *ChaincodeStateDeltaMessage) Reset()         { *m = ChaincodeStateDeltaMessage{} }
+func (m *ChaincodeStateDeltaMessage) String() string { return proto.CompactTextString(m) }
+func (*ChaincodeStateDeltaMessage) ProtoMessage()    {}
+
+func (m *ChaincodeStateDeltaMessage) GetUpdatedKVs() []*UpdatedValueMessage {
+	if m != nil {
+		return m.UpdatedKVs
+	}
+	return nil
+}
+
+// UpdatedValueMessage holds the new and the previous value of a key. A missing
+// value means that the key is deleted; a missing previousValue means that the
+// key did not exist before.
+type UpdatedValueMessage struct {
+	Key           string      `protobuf:"bytes,1,opt,name=key" json:"key,omitempty"`
+	Value         *StateValue `protobuf:"bytes,2,opt,name=value" json:"value,omitempty"`
+	PreviousValue *StateValue `protobuf:"bytes,3,opt,name=previousValue" json:"previousValue,omitempty"`
+}
+
+func (m *UpdatedValueMessage) Reset()         { *m = UpdatedValueMessage{} }
+func (m *UpdatedValueMessage) String() string { return proto.CompactTextString(m) }
+func (*UpdatedValueMessage) ProtoMessage()    {}
+
+func (m *UpdatedValueMessage) GetValue() *StateValue {
+	if m != nil {
+		return m.Value
+	}
+	return nil
+}
+
+func (m *UpdatedValueMessage) GetPreviousValue() *StateValue {
+	if m != nil {
+		return m.PreviousValue
+	}
+	return nil
+}
+
+// StateValue wraps a value so that an empty value can be told apart from a
+// missing one
+type StateValue struct {
+	Value []byte `protobuf:"bytes,1,opt,name=value,proto3" json:"value,omitempty"`
+}
+
+func (m *StateValue) Reset()         { *m = StateValue{} }
+func (m *StateValue) String() string { return proto.CompactTextString(m) }
+func (*StateValue) ProtoMessage()    {}
+
+// BlockStateDeltaMessage is the state delta retained for a block, as written to
+// the offline dumps of a ledger
+type BlockStateDeltaMessage struct {
+	BlockNumber uint64             `protobuf:"varint,1,opt,name=blockNumber" json:"blockNumber,omitempty"`
+	Delta       *StateDeltaMessage `protobuf:"bytes,2,opt,name=delta" json:"delta,omitempty"`
+}
+
+func (m *BlockStateDeltaMessage) Reset()         { *m = BlockStateDeltaMessage{} }
+func (m *BlockStateDeltaMessage) String() string { return proto.CompactTextString(m) }
+func (*BlockStateDeltaMessage) ProtoMessage()    {}
+
+func (m *BlockStateDeltaMessage) GetDelta() *StateDeltaMessage {
+	if m != nil {
+		return m.Delta
+	}
+	return nil
+}
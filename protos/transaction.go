@@ -17,7 +17,10 @@ limitations under the License.
 package protos
 
 import (
+	"bytes"
 	"fmt"
+	"time"
+
 	"github.com/golang/protobuf/proto"
 	"github.com/hyperledger/fabric/core/util"
 )
@@ -89,6 +92,22 @@ func NewChaincodeDeployTransaction(chaincodeDeploymentSpec *ChaincodeDeploymentS
 	return transaction, nil
 }
 
+// ChaincodeMigrateFunction is the function with which the chaincode is
+// initialized when it is launched by an upgrade transaction. The shim calls the
+// Migrate function of the chaincode for it, instead of Init.
+const ChaincodeMigrateFunction = "__migrate"
+
+// NewChaincodeUpgradeTransaction is used to upgrade a deployed chaincode to
+// the code package of chaincodeDeploymentSpec.
+func NewChaincodeUpgradeTransaction(chaincodeDeploymentSpec *ChaincodeDeploymentSpec, uuid string) (*Transaction, error) {
+	transaction, err := NewChaincodeDeployTransaction(chaincodeDeploymentSpec, uuid)
+	if err != nil {
+		return nil, err
+	}
+	transaction.Type = Transaction_CHAINCODE_UPGRADE
+	return transaction, nil
+}
+
 // NewChaincodeExecute is used to deploy chaincode.
 func NewChaincodeExecute(chaincodeInvocationSpec *ChaincodeInvocationSpec, uuid string, typ Transaction_Type) (*Transaction, error) {
 	transaction := new(Transaction)
@@ -103,6 +122,14 @@ func NewChaincodeExecute(chaincodeInvocationSpec *ChaincodeInvocationSpec, uuid
 		}
 		transaction.ChaincodeID = data
 	}
+	// the transient data travels beside the payload, so that it is not stored
+	// with the transaction in the block
+	if len(chaincodeInvocationSpec.Transient) > 0 {
+		transaction.Transient = chaincodeInvocationSpec.Transient
+		transaction.TransientHash = ComputeTransientHash(transaction.Transient)
+		chaincodeInvocationSpec = &ChaincodeInvocationSpec{ChaincodeSpec: chaincodeInvocationSpec.ChaincodeSpec, Expiry: chaincodeInvocationSpec.Expiry}
+	}
+	transaction.Expiry = chaincodeInvocationSpec.Expiry
 	data, err := proto.Marshal(chaincodeInvocationSpec)
 	if err != nil {
 		return nil, fmt.Errorf("Could not marshal payload for chaincode invocation: %s", err)
@@ -110,3 +137,124 @@ func NewChaincodeExecute(chaincodeInvocationSpec *ChaincodeInvocationSpec, uuid
 	transaction.Payload = data
 	return transaction, nil
 }
+
+// NewEndorsedTransaction is used to submit the changes of an invoke simulated
+// and endorsed by validating peers.
+func NewEndorsedTransaction(endorsed *EndorsedTransaction, uuid string) (*Transaction, error) {
+	transaction := new(Transaction)
+	transaction.Type = Transaction_CHAINCODE_ENDORSED
+	transaction.Uuid = uuid
+	transaction.Timestamp = util.CreateUtcTimestamp()
+	data, err := proto.Marshal(endorsed.ChaincodeID)
+	if err != nil {
+		return nil, fmt.Errorf("Could not marshal chaincode : %s", err)
+	}
+	transaction.ChaincodeID = data
+	transaction.Expiry = endorsed.Expiry
+	if endorsed.SecureContext != "" {
+		endorsed = &EndorsedTransaction{ChaincodeID: endorsed.ChaincodeID, Endorsements: endorsed.Endorsements, Expiry: endorsed.Expiry}
+	}
+	data, err = proto.Marshal(endorsed)
+	if err != nil {
+		return nil, fmt.Errorf("Could not marshal payload for endorsed transaction: %s", err)
+	}
+	transaction.Payload = data
+	return transaction, nil
+}
+
+// NewConfigTransaction is used to update the configuration of the network
+// recorded by the ledger.
+func NewConfigTransaction(update *ConfigUpdate, uuid string) (*Transaction, error) {
+	transaction := new(Transaction)
+	transaction.Type = Transaction_CONFIG
+	transaction.Uuid = uuid
+	transaction.Timestamp = util.CreateUtcTimestamp()
+	if update.SecureContext != "" {
+		update = &ConfigUpdate{RevocationList: update.RevocationList}
+	}
+	data, err := proto.Marshal(update)
+	if err != nil {
+		return nil, fmt.Errorf("Could not marshal payload for config transaction: %s", err)
+	}
+	transaction.Payload = data
+	return transaction, nil
+}
+
+// CheckExpiry returns an error if the transaction is expired when executed in the block
+// whose number is blockNumber, in a batch whose time is batchTime (see TransactionExpiry)
+func (transaction *Transaction) CheckExpiry(blockNumber uint64, batchTime time.Time) error {
+	expiry := transaction.Expiry
+	if expiry == nil {
+		return nil
+	}
+	if expiry.BlockHeight > 0 && blockNumber >= expiry.BlockHeight {
+		return fmt.Errorf("Transaction %s expired at block %d", transaction.Uuid, expiry.BlockHeight)
+	}
+	if expiry.Timestamp != nil {
+		expiresAt := time.Unix(expiry.Timestamp.Seconds, int64(expiry.Timestamp.Nanos)).UTC()
+		if !batchTime.Before(expiresAt) {
+			return fmt.Errorf("Transaction %s expired at %s", transaction.Uuid, expiresAt)
+		}
+	}
+	return nil
+}
+
+// CheckTimestamp returns an error if the timestamp of the transaction is more than maxSkew
+// after now. As the time of a batch is the latest timestamp of its transactions, a transaction
+// stamped in the future would otherwise expire the other transactions of its batch
+func (transaction *Transaction) CheckTimestamp(now time.Time, maxSkew time.Duration) error {
+	ts := transaction.Timestamp
+	if ts == nil {
+		return nil
+	}
+	if txTime := time.Unix(ts.Seconds, int64(ts.Nanos)).UTC(); txTime.After(now.Add(maxSkew)) {
+		return fmt.Errorf("Transaction %s is stamped in the future at %s", transaction.Uuid, txTime)
+	}
+	return nil
+}
+
+// ComputeTransientHash returns the hash binding the transient data to a
+// transaction: the crypto-hash of the fields in order
+func ComputeTransientHash(transient []*TransientField) []byte {
+	buffer := proto.NewBuffer(nil)
+	for _, field := range transient {
+		buffer.EncodeStringBytes(field.Key)
+		buffer.EncodeRawBytes(field.Value)
+	}
+	return util.ComputeCryptoHash(buffer.Bytes())
+}
+
+// VerifyTransient checks that the transient data of the transaction is the
+// one it was created with
+func (transaction *Transaction) VerifyTransient() error {
+	if len(transaction.Transient) == 0 && transaction.TransientHash == nil {
+		return nil
+	}
+	if len(transaction.Transient) == 0 {
+		return fmt.Errorf("The transient data of transaction %s is missing", transaction.Uuid)
+	}
+	if !bytes.Equal(ComputeTransientHash(transaction.Transient), transaction.TransientHash) {
+		return fmt.Errorf("The transient data of transaction %s does not match its hash", transaction.Uuid)
+	}
+	return nil
+}
+
+// WithoutTransient returns the transaction as stored in the blocks, that is a
+// copy without its transient data if it has some
+func (transaction *Transaction) WithoutTransient() *Transaction {
+	if len(transaction.Transient) == 0 {
+		return transaction
+	}
+	stored := *transaction
+	stored.Transient = nil
+	return &stored
+}
+
+// SignedBytes returns the bytes covered by the signature of the transaction:
+// the transaction without its signature nor its transient data, the latter
+// being covered by the transientHash instead
+func (transaction *Transaction) SignedBytes() ([]byte, error) {
+	signed := *transaction.WithoutTransient()
+	signed.Signature = nil
+	return proto.Marshal(&signed)
+}
@@ -17,9 +17,12 @@ limitations under the License.
 package protos
 
 import (
+	"bytes"
 	"testing"
+	"time"
 
 	"github.com/golang/protobuf/proto"
+	google_protobuf "google/protobuf"
 )
 
 func Test_Transaction_CreateNew(t *testing.T) {
@@ -46,3 +49,87 @@ func Test_Transaction_CreateNew(t *testing.T) {
 	}
 
 }
+
+func TestTransientNotInPayload(t *testing.T) {
+	transient := []*TransientField{{Key: "price", Value: []byte("100")}}
+	spec := &ChaincodeInvocationSpec{
+		ChaincodeSpec: &ChaincodeSpec{ChaincodeID: &ChaincodeID{Name: "mycc"}, CtorMsg: &ChaincodeInput{Function: "sell"}},
+		Transient:     transient}
+	tx, err := NewChaincodeExecute(spec, "uuid", Transaction_CHAINCODE_INVOKE)
+	if err != nil {
+		t.Fatalf("Error building transaction: %s", err)
+	}
+
+	cis := &ChaincodeInvocationSpec{}
+	if err = proto.Unmarshal(tx.Payload, cis); err != nil {
+		t.Fatalf("Error unmarshalling payload: %s", err)
+	}
+	if len(cis.Transient) != 0 {
+		t.Fatalf("Expected no transient data in the payload, found %v", cis.Transient)
+	}
+	if err = tx.VerifyTransient(); err != nil {
+		t.Fatalf("Error verifying transient data: %s", err)
+	}
+	if tx.WithoutTransient().Transient != nil || len(tx.Transient) != 1 {
+		t.Fatalf("Expected WithoutTransient to strip a copy of the transaction")
+	}
+
+	signed, err := tx.SignedBytes()
+	if err != nil {
+		t.Fatalf("Error marshalling transaction: %s", err)
+	}
+	tx.Transient[0].Value = []byte("90")
+	if err = tx.VerifyTransient(); err == nil {
+		t.Fatalf("Expected an error verifying tampered transient data")
+	}
+	signedAfter, _ := tx.SignedBytes()
+	if !bytes.Equal(signed, signedAfter) {
+		t.Fatalf("Expected the signed bytes not to depend on the transient data")
+	}
+}
+
+func TestTransactionExpiry(t *testing.T) {
+	now := time.Now()
+	spec := &ChaincodeInvocationSpec{
+		ChaincodeSpec: &ChaincodeSpec{ChaincodeID: &ChaincodeID{Name: "mycc"}},
+		Expiry:        &TransactionExpiry{BlockHeight: 10, Timestamp: &google_protobuf.Timestamp{Seconds: now.Unix()}},
+	}
+	tx, err := NewChaincodeExecute(spec, "uuid", Transaction_CHAINCODE_INVOKE)
+	if err != nil {
+		t.Fatalf("Error building transaction: %s", err)
+	}
+	if tx.GetExpiry() == nil || tx.Expiry.BlockHeight != 10 {
+		t.Fatalf("Expected the expiry of the spec on the transaction, found %v", tx.Expiry)
+	}
+
+	if err = tx.CheckExpiry(9, now.Add(-time.Second)); err != nil {
+		t.Fatalf("Expected the transaction not to be expired: %s", err)
+	}
+	if err = tx.CheckExpiry(10, now.Add(-time.Second)); err == nil {
+		t.Fatalf("Expected the transaction to be expired at block 10")
+	}
+	if err = tx.CheckExpiry(9, now); err == nil {
+		t.Fatalf("Expected the transaction to be expired at its timestamp")
+	}
+
+	tx.Expiry = nil
+	if err = tx.CheckExpiry(1000, now.Add(time.Hour)); err != nil {
+		t.Fatalf("Expected a transaction without expiry never to expire: %s", err)
+	}
+}
+
+func TestTransactionTimestampInFuture(t *testing.T) {
+	now := time.Now()
+	tx := &Transaction{Uuid: "uuid"}
+	if err := tx.CheckTimestamp(now, time.Minute); err != nil {
+		t.Fatalf("Expected a transaction without timestamp to be accepted: %s", err)
+	}
+	tx.Timestamp = &google_protobuf.Timestamp{Seconds: now.Add(30 * time.Second).Unix()}
+	if err := tx.CheckTimestamp(now, time.Minute); err != nil {
+		t.Fatalf("Expected a timestamp within the skew to be accepted: %s", err)
+	}
+	tx.Timestamp = &google_protobuf.Timestamp{Seconds: now.Add(2 * time.Minute).Unix()}
+	if err := tx.CheckTimestamp(now, time.Minute); err == nil {
+		t.Fatalf("Expected a timestamp beyond the skew to be rejected")
+	}
+}
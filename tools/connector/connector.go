@@ -0,0 +1,157 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/op/go-logging"
+	"github.com/spf13/viper"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+
+	"github.com/hyperledger/fabric/core/comm"
+	"github.com/hyperledger/fabric/protos"
+)
+
+var logger = logging.MustGetLogger("connector")
+
+// validPrefix matches the prefixes valid both as the start of a SQL table name and of a
+// CouchDB database name
+var validPrefix = regexp.MustCompile("^[a-z][a-z0-9_]{0,31}$")
+
+func main() {
+	peerAddress := flag.String("peer", "0.0.0.0:30303", "address of the gRPC services of the peer, streaming the state deltas")
+	sinkType := flag.String("sink", "", "store the world state is mirrored to: couchdb, postgres or sqlite3")
+	url := flag.String("url", "", "URL of the CouchDB server, or data source name of the SQL database")
+	chaincodes := flag.String("chaincodes", "", "comma separated IDs of the chaincodes mirrored, all of them if empty")
+	prefix := flag.String("prefix", "mirror_", "prefix of the names of the tables or databases of the mirror")
+	configPath := flag.String("config", "", "path to a core.yaml, for the TLS configuration of the connection to the peer (peer.tls)")
+	retryInterval := flag.Duration("retryInterval", 5*time.Second, "interval between the attempts to reconnect to the peer")
+	logLevel := flag.String("logLevel", "info", "logging level")
+	flag.Parse()
+
+	if *sinkType == "" || *url == "" {
+		flag.Usage()
+		os.Exit(3)
+	}
+	if !validPrefix.MatchString(*prefix) {
+		fmt.Fprintf(os.Stderr, "Invalid prefix %s, it must start with a lowercase letter followed by at most 31 lowercase letters, digits or underscores\n", *prefix)
+		os.Exit(3)
+	}
+	level, err := logging.LogLevel(*logLevel)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid logging level %s\n", *logLevel)
+		os.Exit(3)
+	}
+	logging.SetLevel(level, "")
+	if err := loadConfig(*configPath); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(4)
+	}
+
+	s, err := openSink(*sinkType, *url, *prefix)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(5)
+	}
+	defer s.Close()
+	m := &mirror{sink: s, filter: parseFilter(*chaincodes)}
+	for {
+		if err := connectAndRun(*peerAddress, m); err != nil {
+			logger.Error("Error mirroring the state deltas of the peer at %s: %s", *peerAddress, err)
+		}
+		time.Sleep(*retryInterval)
+	}
+}
+
+// openSink opens the sink of the type at the URL
+func openSink(sinkType, url, prefix string) (sink, error) {
+	switch sinkType {
+	case "couchdb":
+		return openCouchSink(url, prefix)
+	case "postgres", "sqlite3":
+		if !isDriverRegistered(sinkType) {
+			return nil, fmt.Errorf("The %s driver is not built in, build the connector with the %s tag", sinkType, sinkType)
+		}
+		return openSQLSink(sinkType, url, prefix)
+	}
+	return nil, fmt.Errorf("Unsupported sink %s", sinkType)
+}
+
+func isDriverRegistered(driver string) bool {
+	for _, registered := range sql.Drivers() {
+		if registered == driver {
+			return true
+		}
+	}
+	return false
+}
+
+// parseFilter returns the set of the chaincode IDs of the comma separated list, nil if empty
+func parseFilter(chaincodes string) map[string]bool {
+	var filter map[string]bool
+	for _, chaincodeID := range strings.Split(chaincodes, ",") {
+		if chaincodeID = strings.TrimSpace(chaincodeID); chaincodeID == "" {
+			continue
+		}
+		if filter == nil {
+			filter = make(map[string]bool)
+		}
+		filter[chaincodeID] = true
+	}
+	return filter
+}
+
+// connectAndRun connects to the peer at the given address and mirrors its state deltas,
+// until the stream fails
+func connectAndRun(peerAddress string, m *mirror) error {
+	var conn *grpc.ClientConn
+	var err error
+	if comm.TLSEnabled() {
+		conn, err = comm.NewClientConnectionWithAddress(peerAddress, true, true, comm.InitTLSForPeer())
+	} else {
+		conn, err = comm.NewClientConnectionWithAddress(peerAddress, true, false, nil)
+	}
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return m.run(context.Background(), protos.NewOpenchainClient(conn))
+}
+
+// loadConfig reads the configuration of the connection to the peer, which may also be
+// set by the CORE_ environment variables
+func loadConfig(configPath string) error {
+	viper.SetEnvPrefix("core")
+	viper.AutomaticEnv()
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	if configPath == "" {
+		return nil
+	}
+	viper.SetConfigFile(configPath)
+	if err := viper.ReadInConfig(); err != nil {
+		return fmt.Errorf("Error reading the config file [%s]: %s", configPath, err)
+	}
+	return nil
+}
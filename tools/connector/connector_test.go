@@ -0,0 +1,445 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+
+	"github.com/hyperledger/fabric/protos"
+)
+
+func TestMirrorName(t *testing.T) {
+	if name := mirrorName("mirror_", "mycc", maxSQLNameLength); name != "mirror_cc_mycc" {
+		t.Fatalf("Unexpected name %s", name)
+	}
+	upper, dashed := mirrorName("mirror_", "MyCC", maxSQLNameLength), mirrorName("mirror_", "my-cc", maxSQLNameLength)
+	if !strings.HasPrefix(upper, "mirror_cc_mycc_") || !strings.HasPrefix(dashed, "mirror_cc_my_cc_") {
+		t.Fatalf("Expected the hash of the chaincode ID to be appended, got %s and %s", upper, dashed)
+	}
+	long := mirrorName("mirror_", strings.Repeat("ab", 64), maxSQLNameLength)
+	if len(long) != maxSQLNameLength || long == mirrorName("mirror_", strings.Repeat("ab", 63), maxSQLNameLength) {
+		t.Fatalf("Expected long chaincode IDs to be truncated and told apart, got %s", long)
+	}
+}
+
+func testDeltas() []*protos.BlockStateDelta {
+	return []*protos.BlockStateDelta{
+		{BlockNumber: 0, KeyValues: []*protos.StateKeyValue{
+			{ChaincodeID: "cc1", Key: "a", Value: []byte(`{"owner":"alice"}`)},
+			{ChaincodeID: "cc1", Key: "_b", Value: []byte{0xff, 0x00}},
+			{ChaincodeID: "cc-2", Key: "c", Value: []byte("3")},
+		}},
+		{BlockNumber: 1, KeyValues: []*protos.StateKeyValue{
+			{ChaincodeID: "cc1", Key: "a", Value: []byte(`{"owner":"bob"}`)},
+			{ChaincodeID: "cc1", Key: "_b", Deleted: true},
+			{ChaincodeID: "cc-2", Key: "missing", Deleted: true},
+		}},
+	}
+}
+
+func openTestSQLSink(t *testing.T, path string) *sqlSink {
+	s, err := openSQLSink("sqlite3", path, "mirror_")
+	if err != nil {
+		t.Fatalf("Error opening the sqlite3 sink: %s", err)
+	}
+	return s
+}
+
+// sqlRows returns the values of the rows of the table of the chaincode, by key
+func sqlRows(t *testing.T, s *sqlSink, chaincodeID string) map[string]string {
+	rows, err := s.db.Query("SELECT key, value, value_json IS NOT NULL FROM " + s.tables[chaincodeID])
+	if err != nil {
+		t.Fatalf("Error reading the table of %s: %s", chaincodeID, err)
+	}
+	defer rows.Close()
+	values := make(map[string]string)
+	for rows.Next() {
+		var key string
+		var value []byte
+		var isJSON bool
+		if err := rows.Scan(&key, &value, &isJSON); err != nil {
+			t.Fatalf("Error reading the table of %s: %s", chaincodeID, err)
+		}
+		if isJSON != (jsonDocument(value) != nil) {
+			t.Fatalf("Unexpected JSON document of key %s", key)
+		}
+		values[key] = string(value)
+	}
+	return values
+}
+
+func TestSQLSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "connector-test")
+	if err != nil {
+		t.Fatalf("Error creating the db dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "mirror.db")
+
+	s := openTestSQLSink(t, path)
+	for _, delta := range testDeltas() {
+		if err := s.ApplyDelta(delta); err != nil {
+			t.Fatalf("Error applying the state delta of block %d: %s", delta.BlockNumber, err)
+		}
+	}
+	s.Close()
+
+	s = openTestSQLSink(t, path)
+	defer s.Close()
+	if nextBlock, err := s.Cursor(); err != nil || nextBlock != 2 {
+		t.Fatalf("Expected the cursor to be at block 2, got %d, %v", nextBlock, err)
+	}
+	if len(s.tables) != 2 {
+		t.Fatalf("Expected the tables of the 2 chaincodes to be registered, got %v", s.tables)
+	}
+	if values := sqlRows(t, s, "cc1"); len(values) != 1 || values["a"] != `{"owner":"bob"}` {
+		t.Fatalf("Unexpected rows of cc1: %v", values)
+	}
+
+	writer, err := s.BeginSnapshot()
+	if err != nil {
+		t.Fatalf("Error beginning a snapshot: %s", err)
+	}
+	if err := writer.Write([]*protos.StateKeyValue{{ChaincodeID: "cc3", Key: "d", Value: []byte("x")}}, 9); err != nil {
+		t.Fatalf("Error writing the snapshot: %s", err)
+	}
+	writer.Abort()
+	if nextBlock, _ := s.Cursor(); nextBlock != 2 || len(sqlRows(t, s, "cc1")) != 1 || s.tables["cc3"] != "" {
+		t.Fatal("Expected the aborted snapshot to leave the mirror unchanged")
+	}
+
+	if writer, err = s.BeginSnapshot(); err != nil {
+		t.Fatalf("Error beginning a snapshot: %s", err)
+	}
+	if err := writer.Write([]*protos.StateKeyValue{{ChaincodeID: "cc3", Key: "d", Value: []byte("x")}}, 9); err != nil {
+		t.Fatalf("Error writing the snapshot: %s", err)
+	}
+	if err := writer.Commit(10); err != nil {
+		t.Fatalf("Error committing the snapshot: %s", err)
+	}
+	if nextBlock, _ := s.Cursor(); nextBlock != 10 {
+		t.Fatalf("Expected the cursor to be at block 10, got %d", nextBlock)
+	}
+	if len(sqlRows(t, s, "cc1")) != 0 || len(sqlRows(t, s, "cc-2")) != 0 || sqlRows(t, s, "cc3")["d"] != "x" {
+		t.Fatal("Expected the snapshot to replace the mirror")
+	}
+}
+
+// fakeCouch is a CouchDB server holding the documents in memory, serving the requests
+// sent by couchSink
+type fakeCouch struct {
+	sync.Mutex
+	databases map[string]map[string]map[string]interface{}
+	revs      int
+}
+
+func (c *fakeCouch) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	c.Lock()
+	defer c.Unlock()
+	reply := func(status int, value interface{}) {
+		rw.WriteHeader(status)
+		json.NewEncoder(rw).Encode(value)
+	}
+	parts := strings.SplitN(strings.TrimPrefix(req.URL.Path, "/"), "/", 2)
+	if parts[0] == "_all_dbs" {
+		var names []string
+		for name := range c.databases {
+			names = append(names, name)
+		}
+		reply(http.StatusOK, names)
+		return
+	}
+	db, exists := c.databases[parts[0]]
+	if len(parts) == 1 {
+		switch {
+		case req.Method == "PUT" && exists:
+			reply(http.StatusPreconditionFailed, map[string]string{"error": "file_exists"})
+		case req.Method == "PUT":
+			c.databases[parts[0]] = make(map[string]map[string]interface{})
+			reply(http.StatusCreated, map[string]bool{"ok": true})
+		case req.Method == "DELETE":
+			delete(c.databases, parts[0])
+			reply(http.StatusOK, map[string]bool{"ok": true})
+		}
+		return
+	}
+	if !exists {
+		reply(http.StatusNotFound, map[string]string{"error": "not_found"})
+		return
+	}
+	body := make(map[string]json.RawMessage)
+	json.NewDecoder(req.Body).Decode(&body)
+	switch parts[1] {
+	case "_all_docs":
+		var ids []string
+		json.Unmarshal(body["keys"], &ids)
+		var rows []map[string]interface{}
+		for _, id := range ids {
+			if doc, ok := db[id]; ok {
+				rows = append(rows, map[string]interface{}{"id": id, "value": map[string]interface{}{"rev": doc["_rev"], "deleted": doc["_deleted"] == true}})
+			} else {
+				rows = append(rows, map[string]interface{}{"key": id, "error": "not_found"})
+			}
+		}
+		reply(http.StatusOK, map[string]interface{}{"rows": rows})
+	case "_bulk_docs":
+		var docs []map[string]interface{}
+		json.Unmarshal(body["docs"], &docs)
+		var results []map[string]interface{}
+		for _, doc := range docs {
+			results = append(results, c.put(db, doc["_id"].(string), doc))
+		}
+		reply(http.StatusCreated, results)
+	default:
+		switch req.Method {
+		case "GET":
+			if doc, ok := db[parts[1]]; ok && doc["_deleted"] != true {
+				reply(http.StatusOK, doc)
+			} else {
+				reply(http.StatusNotFound, map[string]string{"error": "not_found"})
+			}
+		case "PUT":
+			doc := make(map[string]interface{})
+			for k, v := range body {
+				var value interface{}
+				json.Unmarshal(v, &value)
+				doc[k] = value
+			}
+			result := c.put(db, parts[1], doc)
+			if result["error"] != nil {
+				reply(http.StatusConflict, result)
+			} else {
+				reply(http.StatusCreated, result)
+			}
+		}
+	}
+}
+
+// put writes the document if its revision is the current one, or if the current one is
+// deleted and no revision is given
+func (c *fakeCouch) put(db map[string]map[string]interface{}, id string, doc map[string]interface{}) map[string]interface{} {
+	current, ok := db[id]
+	if ok && current["_rev"] != doc["_rev"] && !(current["_deleted"] == true && doc["_rev"] == nil) {
+		return map[string]interface{}{"id": id, "error": "conflict", "reason": "Document update conflict."}
+	}
+	c.revs++
+	doc["_rev"] = string(rune('a' + c.revs))
+	db[id] = doc
+	return map[string]interface{}{"id": id, "rev": doc["_rev"]}
+}
+
+func TestCouchSink(t *testing.T) {
+	couch := &fakeCouch{databases: make(map[string]map[string]map[string]interface{})}
+	server := httptest.NewServer(couch)
+	defer server.Close()
+
+	s, err := openCouchSink(server.URL+"/", "mirror_")
+	if err != nil {
+		t.Fatalf("Error opening the CouchDB sink: %s", err)
+	}
+	for _, delta := range testDeltas() {
+		if err := s.ApplyDelta(delta); err != nil {
+			t.Fatalf("Error applying the state delta of block %d: %s", delta.BlockNumber, err)
+		}
+	}
+	// a connector resuming in the middle of block 1 applies it again
+	if err := s.ApplyDelta(testDeltas()[1]); err != nil {
+		t.Fatalf("Error applying the state delta of block 1 again: %s", err)
+	}
+	if nextBlock, err := s.Cursor(); err != nil || nextBlock != 2 {
+		t.Fatalf("Expected the cursor to be at block 2, got %d, %v", nextBlock, err)
+	}
+
+	cc1 := couch.databases["mirror_cc_cc1"]
+	if owner := cc1["a"]["value"].(map[string]interface{})["owner"]; owner != "bob" || cc1["a"]["blockNumber"] != 1.0 {
+		t.Fatalf("Unexpected document of key a: %v", cc1["a"])
+	}
+	if cc1[`\_b`]["_deleted"] != true {
+		t.Fatalf("Expected the document of key _b to be deleted, got %v", cc1[`\_b`])
+	}
+	cc2 := couch.databases[mirrorName("mirror_", "cc-2", maxCouchNameLength)]
+	if cc2["c"]["value"] != 3.0 || cc2["missing"] != nil {
+		t.Fatalf("Unexpected documents of cc-2: %v", cc2)
+	}
+	if err := s.ApplyDelta(&protos.BlockStateDelta{BlockNumber: 2, KeyValues: []*protos.StateKeyValue{{ChaincodeID: "cc1", Key: "_b", Value: []byte{1}}}}); err != nil {
+		t.Fatalf("Error setting a deleted key again: %s", err)
+	}
+	if cc1[`\_b`]["valueBase64"] != "AQ==" || cc1[`\_b`]["key"] != "_b" {
+		t.Fatalf("Unexpected document of key _b: %v", cc1[`\_b`])
+	}
+
+	writer, err := s.BeginSnapshot()
+	if err != nil {
+		t.Fatalf("Error beginning a snapshot: %s", err)
+	}
+	if nextBlock, _ := s.Cursor(); nextBlock != 0 {
+		t.Fatalf("Expected the cursor to be reset by the snapshot, got %d", nextBlock)
+	}
+	if err := writer.Write([]*protos.StateKeyValue{{ChaincodeID: "cc1", Key: "e", Value: []byte("{}")}}, 9); err != nil {
+		t.Fatalf("Error writing the snapshot: %s", err)
+	}
+	if err := writer.Commit(10); err != nil {
+		t.Fatalf("Error committing the snapshot: %s", err)
+	}
+	if nextBlock, _ := s.Cursor(); nextBlock != 10 {
+		t.Fatalf("Expected the cursor to be at block 10, got %d", nextBlock)
+	}
+	cc1 = couch.databases["mirror_cc_cc1"]
+	if len(cc1) != 1 || cc1["e"] == nil || couch.databases[mirrorName("mirror_", "cc-2", maxCouchNameLength)] != nil {
+		t.Fatalf("Expected the snapshot to replace the mirror, got %v", couch.databases)
+	}
+}
+
+// memSink is a sink holding the mirror in memory
+type memSink struct {
+	nextBlock uint64
+	values    map[string]string
+}
+
+func (s *memSink) Cursor() (uint64, error) { return s.nextBlock, nil }
+
+func (s *memSink) ApplyDelta(delta *protos.BlockStateDelta) error {
+	s.write(delta.KeyValues)
+	s.nextBlock = delta.BlockNumber + 1
+	return nil
+}
+
+func (s *memSink) write(kvs []*protos.StateKeyValue) {
+	for _, kv := range kvs {
+		if kv.Deleted {
+			delete(s.values, kv.ChaincodeID+"/"+kv.Key)
+		} else {
+			s.values[kv.ChaincodeID+"/"+kv.Key] = string(kv.Value)
+		}
+	}
+}
+
+func (s *memSink) BeginSnapshot() (snapshotWriter, error) {
+	s.values = make(map[string]string)
+	return s, nil
+}
+
+func (s *memSink) Write(kvs []*protos.StateKeyValue, blockNumber uint64) error {
+	s.write(kvs)
+	return nil
+}
+
+func (s *memSink) Commit(nextBlock uint64) error {
+	s.nextBlock = nextBlock
+	return nil
+}
+
+func (s *memSink) Abort() {}
+
+func (s *memSink) Close() error { return nil }
+
+// fakeSource streams the state deltas it holds, failing with code NotFound for the blocks
+// before firstRetained and with errEndOfDeltas past the last one
+type fakeSource struct {
+	deltas        []*protos.BlockStateDelta
+	firstRetained uint64
+	snapshot      *protos.StateSnapshotChunk
+	subscriptions []*protos.StateDeltasSubscription
+}
+
+var errEndOfDeltas = errors.New("end of the state deltas")
+
+type fakeDeltaStream struct {
+	grpc.ClientStream
+	source    *fakeSource
+	nextBlock uint64
+}
+
+func (s *fakeDeltaStream) Recv() (*protos.BlockStateDelta, error) {
+	if s.nextBlock < s.source.firstRetained {
+		return nil, grpc.Errorf(codes.NotFound, "The state delta of block %d is not retained", s.nextBlock)
+	}
+	if s.nextBlock >= uint64(len(s.source.deltas)) {
+		return nil, errEndOfDeltas
+	}
+	delta := *s.source.deltas[s.nextBlock]
+	s.nextBlock++
+	return &delta, nil
+}
+
+type fakeSnapshotStream struct {
+	grpc.ClientStream
+	chunks []*protos.StateSnapshotChunk
+}
+
+func (s *fakeSnapshotStream) Recv() (*protos.StateSnapshotChunk, error) {
+	if len(s.chunks) == 0 {
+		return nil, io.EOF
+	}
+	chunk := s.chunks[0]
+	s.chunks = s.chunks[1:]
+	return chunk, nil
+}
+
+func (s *fakeSource) SubscribeStateDeltas(ctx context.Context, in *protos.StateDeltasSubscription, opts ...grpc.CallOption) (protos.Openchain_SubscribeStateDeltasClient, error) {
+	s.subscriptions = append(s.subscriptions, in)
+	return &fakeDeltaStream{source: s, nextBlock: in.FromBlock}, nil
+}
+
+func (s *fakeSource) GetStateSnapshot(ctx context.Context, in *protos.StateSnapshotRequest, opts ...grpc.CallOption) (protos.Openchain_GetStateSnapshotClient, error) {
+	return &fakeSnapshotStream{chunks: []*protos.StateSnapshotChunk{s.snapshot}}, nil
+}
+
+func TestMirrorRun(t *testing.T) {
+	deltas := append(testDeltas(), &protos.BlockStateDelta{BlockNumber: 2, KeyValues: []*protos.StateKeyValue{
+		{ChaincodeID: "cc1", Key: "a", Deleted: true},
+		{ChaincodeID: "cc3", Key: "f", Value: []byte("6")},
+	}})
+	source := &fakeSource{
+		deltas:        deltas,
+		firstRetained: 2,
+		snapshot: &protos.StateSnapshotChunk{BlockNumber: 1, KeyValues: []*protos.StateKeyValue{
+			{ChaincodeID: "cc1", Key: "a", Value: []byte("snapshot")},
+			{ChaincodeID: "cc-2", Key: "c", Value: []byte("3")},
+		}},
+	}
+	s := &memSink{values: make(map[string]string)}
+	m := &mirror{sink: s, filter: parseFilter("cc1, cc3")}
+	if err := m.run(context.Background(), source); err != errEndOfDeltas {
+		t.Fatalf("Expected the mirror to run until the end of the state deltas, got %v", err)
+	}
+	if s.nextBlock != 3 || len(s.values) != 1 || s.values["cc3/f"] != "6" {
+		t.Fatalf("Unexpected mirror at block %d: %v", s.nextBlock, s.values)
+	}
+	if len(source.subscriptions) != 2 || source.subscriptions[0].FromBlock != 0 || source.subscriptions[1].FromBlock != 2 {
+		t.Fatalf("Expected to subscribe from block 0, then from the block following the snapshot, got %v", source.subscriptions)
+	}
+
+	m = &mirror{sink: &memSink{values: make(map[string]string)}, filter: parseFilter("cc1")}
+	if subscription := m.subscription(5); subscription.ChaincodeID != "cc1" || subscription.FromBlock != 5 {
+		t.Fatalf("Expected the subscription to be restricted to cc1, got %v", subscription)
+	}
+}
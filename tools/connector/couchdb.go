@@ -0,0 +1,346 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/hyperledger/fabric/protos"
+)
+
+const (
+	// maxCouchNameLength is the maximum length of the names of the CouchDB databases
+	maxCouchNameLength = 238
+	// couchCursorID is the ID of the document of the cursor
+	couchCursorID = "cursor"
+)
+
+// couchSink mirrors the world state to CouchDB, the key-values of each chaincode to a
+// database of its own. CouchDB has no transactions across documents: the cursor, held by
+// the database <prefix>cursor, is written once the changes of a block are, and the changes
+// of a block written again, as a connector resumes, leave the same documents.
+type couchSink struct {
+	url    string
+	prefix string
+	client *http.Client
+	// databases holds the databases known to exist
+	databases map[string]bool
+	// cursorRev is the revision of the document of the cursor
+	cursorRev string
+}
+
+// couchDoc is the document of a key of a chaincode. The value is held as is by Value if it
+// is a JSON document, base64 encoded by ValueBase64 otherwise.
+type couchDoc struct {
+	ID          string          `json:"_id"`
+	Rev         string          `json:"_rev,omitempty"`
+	Deleted     bool            `json:"_deleted,omitempty"`
+	Key         string          `json:"key,omitempty"`
+	Value       json.RawMessage `json:"value,omitempty"`
+	ValueBase64 []byte          `json:"valueBase64,omitempty"`
+	BlockNumber uint64          `json:"blockNumber"`
+}
+
+// couchCursor is the document of the cursor
+type couchCursor struct {
+	ID        string `json:"_id"`
+	Rev       string `json:"_rev,omitempty"`
+	NextBlock uint64 `json:"nextBlock"`
+}
+
+// couchError is an error response of CouchDB
+type couchError struct {
+	Status int
+	Err    string `json:"error"`
+	Reason string `json:"reason"`
+}
+
+func (e *couchError) Error() string {
+	return fmt.Sprintf("CouchDB error %d %s: %s", e.Status, e.Err, e.Reason)
+}
+
+// openCouchSink connects to the CouchDB server at the URL and creates the database of the
+// cursor if needed
+func openCouchSink(url, prefix string) (*couchSink, error) {
+	s := &couchSink{
+		url:       strings.TrimSuffix(url, "/"),
+		prefix:    prefix,
+		client:    &http.Client{},
+		databases: make(map[string]bool),
+	}
+	if err := s.createDatabase(prefix + "cursor"); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// do sends the request, encoding the body and decoding the response into result if not nil
+func (s *couchSink) do(method, path string, body, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(encoded)
+	}
+	req, err := http.NewRequest(method, s.url+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		couchErr := &couchError{Status: resp.StatusCode}
+		json.NewDecoder(resp.Body).Decode(couchErr)
+		return couchErr
+	}
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+func isCouchStatus(err error, status int) bool {
+	couchErr, ok := err.(*couchError)
+	return ok && couchErr.Status == status
+}
+
+// createDatabase creates the database unless it exists
+func (s *couchSink) createDatabase(name string) error {
+	if s.databases[name] {
+		return nil
+	}
+	if err := s.do("PUT", "/"+name, nil, nil); err != nil && !isCouchStatus(err, http.StatusPreconditionFailed) {
+		return fmt.Errorf("Error creating database %s: %s", name, err)
+	}
+	s.databases[name] = true
+	return nil
+}
+
+// Cursor implements sink
+func (s *couchSink) Cursor() (uint64, error) {
+	cursor := &couchCursor{}
+	err := s.do("GET", "/"+s.prefix+"cursor/"+couchCursorID, nil, cursor)
+	if isCouchStatus(err, http.StatusNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("Error reading the cursor of the mirror: %s", err)
+	}
+	s.cursorRev = cursor.Rev
+	return cursor.NextBlock, nil
+}
+
+// setCursor writes the cursor
+func (s *couchSink) setCursor(nextBlock uint64) error {
+	if s.cursorRev == "" {
+		if _, err := s.Cursor(); err != nil {
+			return err
+		}
+	}
+	result := &struct {
+		Rev string `json:"rev"`
+	}{}
+	cursor := &couchCursor{ID: couchCursorID, Rev: s.cursorRev, NextBlock: nextBlock}
+	if err := s.do("PUT", "/"+s.prefix+"cursor/"+couchCursorID, cursor, result); err != nil {
+		// the revision is read again on the next attempt
+		s.cursorRev = ""
+		return fmt.Errorf("Error moving the cursor of the mirror: %s", err)
+	}
+	s.cursorRev = result.Rev
+	return nil
+}
+
+// ApplyDelta implements sink
+func (s *couchSink) ApplyDelta(delta *protos.BlockStateDelta) error {
+	if err := s.write(delta.KeyValues, delta.BlockNumber, true); err != nil {
+		return err
+	}
+	return s.setCursor(delta.BlockNumber + 1)
+}
+
+// BeginSnapshot implements sink by deleting the databases of the chaincodes, once the
+// cursor is reset so that a connector stopping before the end of the snapshot does not
+// resume from a block the mirror is not up to
+func (s *couchSink) BeginSnapshot() (snapshotWriter, error) {
+	if err := s.setCursor(0); err != nil {
+		return nil, err
+	}
+	var databases []string
+	if err := s.do("GET", "/_all_dbs", nil, &databases); err != nil {
+		return nil, fmt.Errorf("Error listing the databases: %s", err)
+	}
+	for _, name := range databases {
+		if !strings.HasPrefix(name, s.prefix+"cc_") {
+			continue
+		}
+		if err := s.do("DELETE", "/"+name, nil, nil); err != nil && !isCouchStatus(err, http.StatusNotFound) {
+			return nil, fmt.Errorf("Error deleting database %s: %s", name, err)
+		}
+		delete(s.databases, name)
+	}
+	return &couchSnapshotWriter{sink: s}, nil
+}
+
+// Close implements sink
+func (s *couchSink) Close() error {
+	return nil
+}
+
+// write writes the documents of the key-values set or deleted by the block. The revisions
+// of the documents are read first unless the databases were just emptied.
+func (s *couchSink) write(kvs []*protos.StateKeyValue, blockNumber uint64, readRevs bool) error {
+	byDatabase := make(map[string][]*protos.StateKeyValue)
+	var names []string
+	for _, kv := range kvs {
+		name := mirrorName(s.prefix, kv.ChaincodeID, maxCouchNameLength)
+		if _, ok := byDatabase[name]; !ok {
+			names = append(names, name)
+		}
+		byDatabase[name] = append(byDatabase[name], kv)
+	}
+	for _, name := range names {
+		if err := s.createDatabase(name); err != nil {
+			return err
+		}
+		revs := make(map[string]string)
+		if readRevs {
+			var err error
+			if revs, err = s.readRevs(name, byDatabase[name]); err != nil {
+				return err
+			}
+		}
+		var docs []*couchDoc
+		for _, kv := range byDatabase[name] {
+			doc := &couchDoc{ID: couchDocID(kv.Key), Rev: revs[kv.Key], BlockNumber: blockNumber}
+			if kv.Deleted {
+				if doc.Rev == "" {
+					continue
+				}
+				doc.Deleted = true
+			} else {
+				doc.Key = kv.Key
+				if jsonDocument(kv.Value) != nil {
+					doc.Value = kv.Value
+				} else {
+					doc.ValueBase64 = kv.Value
+				}
+			}
+			docs = append(docs, doc)
+		}
+		if err := s.bulkDocs(name, docs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readRevs returns the current revisions of the documents of the keys, the keys whose
+// document does not exist or is deleted are left out
+func (s *couchSink) readRevs(name string, kvs []*protos.StateKeyValue) (map[string]string, error) {
+	ids := make([]string, len(kvs))
+	for i, kv := range kvs {
+		ids[i] = couchDocID(kv.Key)
+	}
+	result := &struct {
+		Rows []struct {
+			ID    string `json:"id"`
+			Value *struct {
+				Rev     string `json:"rev"`
+				Deleted bool   `json:"deleted"`
+			} `json:"value"`
+		} `json:"rows"`
+	}{}
+	if err := s.do("POST", "/"+name+"/_all_docs", map[string][]string{"keys": ids}, result); err != nil {
+		return nil, fmt.Errorf("Error reading the revisions of the documents of %s: %s", name, err)
+	}
+	revs := make(map[string]string)
+	for _, row := range result.Rows {
+		if row.Value != nil && !row.Value.Deleted {
+			revs[couchKey(row.ID)] = row.Value.Rev
+		}
+	}
+	return revs, nil
+}
+
+// bulkDocs writes the documents to the database
+func (s *couchSink) bulkDocs(name string, docs []*couchDoc) error {
+	if len(docs) == 0 {
+		return nil
+	}
+	var results []struct {
+		ID     string `json:"id"`
+		Error  string `json:"error"`
+		Reason string `json:"reason"`
+	}
+	if err := s.do("POST", "/"+name+"/_bulk_docs", map[string][]*couchDoc{"docs": docs}, &results); err != nil {
+		return fmt.Errorf("Error writing the documents of %s: %s", name, err)
+	}
+	for _, result := range results {
+		if result.Error != "" {
+			return fmt.Errorf("Error writing document %s of %s: %s: %s", result.ID, name, result.Error, result.Reason)
+		}
+	}
+	return nil
+}
+
+// couchSnapshotWriter writes a snapshot to the emptied databases of a couchSink
+type couchSnapshotWriter struct {
+	sink *couchSink
+}
+
+// Write implements snapshotWriter
+func (w *couchSnapshotWriter) Write(kvs []*protos.StateKeyValue, blockNumber uint64) error {
+	return w.sink.write(kvs, blockNumber, false)
+}
+
+// Commit implements snapshotWriter
+func (w *couchSnapshotWriter) Commit(nextBlock uint64) error {
+	return w.sink.setCursor(nextBlock)
+}
+
+// Abort implements snapshotWriter, the cursor was reset when the snapshot began
+func (w *couchSnapshotWriter) Abort() {
+}
+
+// couchDocID returns the ID of the document of the key. The IDs starting with an
+// underscore being reserved by CouchDB, the keys starting with an underscore or a
+// backslash, and the empty key, are escaped by a backslash.
+func couchDocID(key string) string {
+	if key == "" || key[0] == '_' || key[0] == '\\' {
+		return "\\" + key
+	}
+	return key
+}
+
+// couchKey returns the key of the document ID
+func couchKey(id string) string {
+	return strings.TrimPrefix(id, "\\")
+}
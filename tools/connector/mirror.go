@@ -0,0 +1,214 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+
+	"github.com/hyperledger/fabric/protos"
+)
+
+// sink is the store the world state is mirrored to, one table or database per chaincode.
+// The sink records, along with the changes, the cursor of the mirror: the number of the
+// next block whose state delta is to be applied. A connector which stops in the middle of
+// a block resumes from the cursor, applying the block again.
+type sink interface {
+	// Cursor returns the number of the next block to mirror, 0 if the mirror is empty
+	Cursor() (uint64, error)
+	// ApplyDelta applies the changes of the state delta of the block and moves the cursor
+	// past the block
+	ApplyDelta(delta *protos.BlockStateDelta) error
+	// BeginSnapshot starts replacing the whole mirror with a snapshot of the world state
+	BeginSnapshot() (snapshotWriter, error)
+	Close() error
+}
+
+// snapshotWriter writes a snapshot of the world state to a sink
+type snapshotWriter interface {
+	// Write writes key-values of the snapshot of the world state as of the block
+	Write(kvs []*protos.StateKeyValue, blockNumber uint64) error
+	// Commit sets the cursor to the given block, the one following the block of the
+	// snapshot, once all the key-values of the snapshot are written
+	Commit(nextBlock uint64) error
+	// Abort gives up the snapshot, the mirror has to be replaced again
+	Abort()
+}
+
+// deltaSource streams the state deltas and the state snapshots of the peer
+type deltaSource interface {
+	SubscribeStateDeltas(ctx context.Context, in *protos.StateDeltasSubscription, opts ...grpc.CallOption) (protos.Openchain_SubscribeStateDeltasClient, error)
+	GetStateSnapshot(ctx context.Context, in *protos.StateSnapshotRequest, opts ...grpc.CallOption) (protos.Openchain_GetStateSnapshotClient, error)
+}
+
+// mirror mirrors to the sink the key-values of the chaincodes accepted by the filter,
+// all of them if nil
+type mirror struct {
+	sink   sink
+	filter map[string]bool
+}
+
+func (m *mirror) accepts(chaincodeID string) bool {
+	return m.filter == nil || m.filter[chaincodeID]
+}
+
+// subscription returns the subscription to the state deltas from the block, restricted
+// to the chaincode mirrored if there is only one
+func (m *mirror) subscription(fromBlock uint64) *protos.StateDeltasSubscription {
+	subscription := &protos.StateDeltasSubscription{FromBlock: fromBlock}
+	if len(m.filter) == 1 {
+		for chaincodeID := range m.filter {
+			subscription.ChaincodeID = chaincodeID
+		}
+	}
+	return subscription
+}
+
+// run applies the state deltas streamed by the source from the cursor of the sink on, until
+// the stream fails. When the peer no longer retains the state delta of the next block, the
+// mirror is replaced with a snapshot of the world state and the deltas are streamed again
+// from the block following the snapshot.
+func (m *mirror) run(ctx context.Context, source deltaSource) error {
+	for {
+		err := m.streamDeltas(ctx, source)
+		if grpc.Code(err) != codes.NotFound {
+			return err
+		}
+		logger.Warning("Replacing the mirror with a snapshot of the world state: %s", err)
+		if err := m.snapshot(ctx, source); err != nil {
+			return err
+		}
+	}
+}
+
+// streamDeltas applies the state deltas streamed by the source from the cursor of the sink
+// on, until the stream fails
+func (m *mirror) streamDeltas(ctx context.Context, source deltaSource) error {
+	nextBlock, err := m.sink.Cursor()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := source.SubscribeStateDeltas(ctx, m.subscription(nextBlock))
+	if err != nil {
+		return err
+	}
+	logger.Info("Mirroring the state deltas from block %d", nextBlock)
+	for {
+		delta, err := stream.Recv()
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return err
+		}
+		if delta.BlockNumber != nextBlock {
+			return fmt.Errorf("Received the state delta of block %d, expected block %d", delta.BlockNumber, nextBlock)
+		}
+		delta.KeyValues = m.filterKeyValues(delta.KeyValues)
+		if err := m.sink.ApplyDelta(delta); err != nil {
+			return err
+		}
+		logger.Debug("Mirrored the state delta of block %d", delta.BlockNumber)
+		nextBlock++
+	}
+}
+
+func (m *mirror) filterKeyValues(kvs []*protos.StateKeyValue) []*protos.StateKeyValue {
+	if m.filter == nil {
+		return kvs
+	}
+	filtered := kvs[:0]
+	for _, kv := range kvs {
+		if m.accepts(kv.ChaincodeID) {
+			filtered = append(filtered, kv)
+		}
+	}
+	return filtered
+}
+
+// snapshot replaces the mirror with a snapshot of the world state of the peer
+func (m *mirror) snapshot(ctx context.Context, source deltaSource) error {
+	stream, err := source.GetStateSnapshot(ctx, &protos.StateSnapshotRequest{})
+	if err != nil {
+		return err
+	}
+	writer, err := m.sink.BeginSnapshot()
+	if err != nil {
+		return err
+	}
+	var blockNumber uint64
+	received := false
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			writer.Abort()
+			return err
+		}
+		blockNumber, received = chunk.BlockNumber, true
+		if err := writer.Write(m.filterKeyValues(chunk.KeyValues), chunk.BlockNumber); err != nil {
+			writer.Abort()
+			return err
+		}
+	}
+	if !received {
+		writer.Abort()
+		return io.ErrUnexpectedEOF
+	}
+	if err := writer.Commit(blockNumber + 1); err != nil {
+		return err
+	}
+	logger.Info("Replaced the mirror with the snapshot of the world state as of block %d", blockNumber)
+	return nil
+}
+
+// mirrorName returns the name of the table or of the database the key-values of the
+// chaincode are mirrored to, <prefix>cc_ followed by the chaincode ID restricted to
+// lowercase letters, digits and underscores. A hash of the chaincode ID is appended when
+// the ID had to be changed, or the name truncated to maxLength, to tell the chaincodes apart.
+func mirrorName(prefix, chaincodeID string, maxLength int) string {
+	sanitized := []byte(chaincodeID)
+	for i, c := range sanitized {
+		switch {
+		case c >= 'a' && c <= 'z', c >= '0' && c <= '9', c == '_':
+		case c >= 'A' && c <= 'Z':
+			sanitized[i] = c - 'A' + 'a'
+		default:
+			sanitized[i] = '_'
+		}
+	}
+	name := prefix + "cc_" + string(sanitized)
+	if string(sanitized) == chaincodeID && len(name) <= maxLength {
+		return name
+	}
+	hash := sha256.Sum256([]byte(chaincodeID))
+	suffix := "_" + hex.EncodeToString(hash[:8])
+	if len(name)+len(suffix) > maxLength {
+		name = name[:maxLength-len(suffix)]
+	}
+	return name + suffix
+}
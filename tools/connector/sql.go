@@ -0,0 +1,261 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/hyperledger/fabric/protos"
+)
+
+// maxSQLNameLength is the maximum length of the names of the tables, the one of PostgreSQL
+const maxSQLNameLength = 63
+
+// sqlDialect holds what differs between the SQL databases supported
+type sqlDialect struct {
+	// blobType and jsonType are the column types of the values and of their JSON documents
+	blobType string
+	jsonType string
+	// param returns the placeholder of the nth parameter of a statement, from 1
+	param func(n int) string
+	// upsert is the statement, formatted with the name of the table, inserting or replacing
+	// the row of a key with the parameters key, value, value_json and block_number
+	upsert string
+}
+
+var sqlDialects = map[string]*sqlDialect{
+	"postgres": {
+		blobType: "BYTEA",
+		jsonType: "JSONB",
+		param:    func(n int) string { return "$" + strconv.Itoa(n) },
+		upsert: "INSERT INTO %s (key, value, value_json, block_number) VALUES ($1, $2, $3, $4) " +
+			"ON CONFLICT (key) DO UPDATE SET value = excluded.value, value_json = excluded.value_json, block_number = excluded.block_number",
+	},
+	"sqlite3": {
+		blobType: "BLOB",
+		jsonType: "TEXT",
+		param:    func(n int) string { return "?" },
+		upsert:   "INSERT OR REPLACE INTO %s (key, value, value_json, block_number) VALUES (?, ?, ?, ?)",
+	},
+}
+
+// sqlSink mirrors the world state to a SQL database, the key-values of each chaincode to a
+// table of its own listed by the table <prefix>tables. The cursor, held by the table
+// <prefix>cursor, is updated in the transaction applying the changes.
+type sqlSink struct {
+	db      *sql.DB
+	dialect *sqlDialect
+	prefix  string
+	// tables maps the chaincodes mirrored to their tables
+	tables map[string]string
+}
+
+// openSQLSink opens the database of the driver at the data source name and creates the
+// tables of the mirror if needed
+func openSQLSink(driver, dataSourceName, prefix string) (*sqlSink, error) {
+	dialect, ok := sqlDialects[driver]
+	if !ok {
+		return nil, fmt.Errorf("Unsupported SQL database %s", driver)
+	}
+	db, err := sql.Open(driver, dataSourceName)
+	if err != nil {
+		return nil, fmt.Errorf("Error opening the %s database: %s", driver, err)
+	}
+	s := &sqlSink{db: db, dialect: dialect, prefix: prefix, tables: make(map[string]string)}
+	if err := s.init(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *sqlSink) init() error {
+	statements := []string{
+		fmt.Sprintf("CREATE TABLE IF NOT EXISTS %scursor (id INTEGER PRIMARY KEY, next_block BIGINT NOT NULL)", s.prefix),
+		fmt.Sprintf("INSERT INTO %scursor (id, next_block) SELECT 1, 0 WHERE NOT EXISTS (SELECT 1 FROM %scursor WHERE id = 1)", s.prefix, s.prefix),
+		fmt.Sprintf("CREATE TABLE IF NOT EXISTS %stables (chaincode_id TEXT PRIMARY KEY, table_name TEXT NOT NULL)", s.prefix),
+	}
+	for _, statement := range statements {
+		if _, err := s.db.Exec(statement); err != nil {
+			return fmt.Errorf("Error creating the tables of the mirror: %s", err)
+		}
+	}
+	rows, err := s.db.Query(fmt.Sprintf("SELECT chaincode_id, table_name FROM %stables", s.prefix))
+	if err != nil {
+		return fmt.Errorf("Error reading the tables of the mirror: %s", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var chaincodeID, table string
+		if err := rows.Scan(&chaincodeID, &table); err != nil {
+			return fmt.Errorf("Error reading the tables of the mirror: %s", err)
+		}
+		s.tables[chaincodeID] = table
+	}
+	return rows.Err()
+}
+
+// Cursor implements sink
+func (s *sqlSink) Cursor() (uint64, error) {
+	var nextBlock int64
+	err := s.db.QueryRow(fmt.Sprintf("SELECT next_block FROM %scursor WHERE id = 1", s.prefix)).Scan(&nextBlock)
+	if err != nil {
+		return 0, fmt.Errorf("Error reading the cursor of the mirror: %s", err)
+	}
+	return uint64(nextBlock), nil
+}
+
+// ApplyDelta implements sink
+func (s *sqlSink) ApplyDelta(delta *protos.BlockStateDelta) error {
+	tx, err := s.begin()
+	if err != nil {
+		return err
+	}
+	if err := tx.write(delta.KeyValues, delta.BlockNumber); err != nil {
+		tx.Abort()
+		return err
+	}
+	return tx.Commit(delta.BlockNumber + 1)
+}
+
+// BeginSnapshot implements sink by emptying the tables of the mirror in the transaction
+// writing the snapshot
+func (s *sqlSink) BeginSnapshot() (snapshotWriter, error) {
+	tx, err := s.begin()
+	if err != nil {
+		return nil, err
+	}
+	for _, table := range s.tables {
+		if _, err := tx.tx.Exec("DELETE FROM " + table); err != nil {
+			tx.Abort()
+			return nil, fmt.Errorf("Error emptying table %s: %s", table, err)
+		}
+	}
+	return tx, nil
+}
+
+// Close implements sink
+func (s *sqlSink) Close() error {
+	return s.db.Close()
+}
+
+func (s *sqlSink) begin() (*sqlTx, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("Error beginning a transaction: %s", err)
+	}
+	return &sqlTx{sink: s, tx: tx, created: make(map[string]string)}, nil
+}
+
+// sqlTx is a transaction of a sqlSink. The tables it creates are only known to the sink
+// once it is committed.
+type sqlTx struct {
+	sink    *sqlSink
+	tx      *sql.Tx
+	created map[string]string
+}
+
+// table returns the table of the chaincode, creating it if needed
+func (tx *sqlTx) table(chaincodeID string) (string, error) {
+	if table, ok := tx.sink.tables[chaincodeID]; ok {
+		return table, nil
+	}
+	if table, ok := tx.created[chaincodeID]; ok {
+		return table, nil
+	}
+	s := tx.sink
+	table := mirrorName(s.prefix, chaincodeID, maxSQLNameLength)
+	create := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (key TEXT PRIMARY KEY, value %s NOT NULL, value_json %s, block_number BIGINT NOT NULL)",
+		table, s.dialect.blobType, s.dialect.jsonType)
+	if _, err := tx.tx.Exec(create); err != nil {
+		return "", fmt.Errorf("Error creating the table of chaincode %s: %s", chaincodeID, err)
+	}
+	register := fmt.Sprintf("INSERT INTO %stables (chaincode_id, table_name) VALUES (%s, %s)", s.prefix, s.dialect.param(1), s.dialect.param(2))
+	if _, err := tx.tx.Exec(register, chaincodeID, table); err != nil {
+		return "", fmt.Errorf("Error registering the table of chaincode %s: %s", chaincodeID, err)
+	}
+	tx.created[chaincodeID] = table
+	return table, nil
+}
+
+// write writes the key-values set or deleted by the block
+func (tx *sqlTx) write(kvs []*protos.StateKeyValue, blockNumber uint64) error {
+	for _, kv := range kvs {
+		if kv.Deleted {
+			table, ok := tx.sink.tables[kv.ChaincodeID]
+			if !ok {
+				if table, ok = tx.created[kv.ChaincodeID]; !ok {
+					continue
+				}
+			}
+			statement := fmt.Sprintf("DELETE FROM %s WHERE key = %s", table, tx.sink.dialect.param(1))
+			if _, err := tx.tx.Exec(statement, kv.Key); err != nil {
+				return fmt.Errorf("Error deleting key %s of chaincode %s: %s", kv.Key, kv.ChaincodeID, err)
+			}
+			continue
+		}
+		table, err := tx.table(kv.ChaincodeID)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.tx.Exec(fmt.Sprintf(tx.sink.dialect.upsert, table), kv.Key, kv.Value, jsonDocument(kv.Value), int64(blockNumber)); err != nil {
+			return fmt.Errorf("Error writing key %s of chaincode %s: %s", kv.Key, kv.ChaincodeID, err)
+		}
+	}
+	return nil
+}
+
+// Write implements snapshotWriter
+func (tx *sqlTx) Write(kvs []*protos.StateKeyValue, blockNumber uint64) error {
+	return tx.write(kvs, blockNumber)
+}
+
+// Commit implements snapshotWriter
+func (tx *sqlTx) Commit(nextBlock uint64) error {
+	s := tx.sink
+	statement := fmt.Sprintf("UPDATE %scursor SET next_block = %s WHERE id = 1", s.prefix, s.dialect.param(1))
+	if _, err := tx.tx.Exec(statement, int64(nextBlock)); err != nil {
+		tx.Abort()
+		return fmt.Errorf("Error moving the cursor of the mirror: %s", err)
+	}
+	if err := tx.tx.Commit(); err != nil {
+		return fmt.Errorf("Error committing the changes of block %d: %s", nextBlock-1, err)
+	}
+	for chaincodeID, table := range tx.created {
+		s.tables[chaincodeID] = table
+	}
+	return nil
+}
+
+// Abort implements snapshotWriter
+func (tx *sqlTx) Abort() {
+	tx.tx.Rollback()
+}
+
+// jsonDocument returns the value as a string if it is a JSON document, nil otherwise
+func jsonDocument(value []byte) interface{} {
+	var document json.RawMessage
+	if json.Unmarshal(value, &document) != nil {
+		return nil
+	}
+	return string(value)
+}
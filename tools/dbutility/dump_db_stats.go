@@ -70,10 +70,40 @@ func main() {
 	fmt.Println()
 	scan(openchainDB, "persistCF", openchainDB.PersistCF, nil)
 	fmt.Println()
+	scan(openchainDB, "stagingCF", openchainDB.StagingCF, nil)
+	fmt.Println()
+	scan(openchainDB, "walCF", openchainDB.WalCF, nil)
+	fmt.Println()
+	scan(openchainDB, "secondaryIndexesCF", openchainDB.SecondaryIndexesCF, nil)
+	scan(openchainDB, "privateCF", openchainDB.PrivateCF, nil)
+	scan(openchainDB, "consensusCF", openchainDB.ConsensusCF, nil)
+	scan(openchainDB, "documentsCF", openchainDB.DocumentsCF, nil)
+	fmt.Println()
 	printLiveFilesMetaData(openchainDB)
 	fmt.Println()
 	printProperties(openchainDB)
 	fmt.Println()
+	printSpaceReport(openchainDB)
+	fmt.Println()
+}
+
+func printSpaceReport(openchainDB *db.OpenchainDB) {
+	fmt.Println("------ Space report ---")
+	report, err := openchainDB.GetSpaceReport()
+	if err != nil {
+		fmt.Printf("Error while computing the space report: %s\n", err)
+		return
+	}
+	for _, cf := range report.ColumnFamilies {
+		lastCompaction := "never"
+		if cf.LastCompaction != nil {
+			lastCompaction = cf.LastCompaction.String()
+		}
+		fmt.Printf("%s: numKeys=[%d], totalBytes=[%d], liveBytes=[%d], deadBytes=[%d], memTableBytes=[%d], pendingCompactionBytes=[%d], compactionPending=[%t], lastCompaction=[%s]\n",
+			cf.Name, cf.NumKeys, cf.TotalBytes, cf.LiveBytes, cf.DeadBytes, cf.MemTableBytes, cf.PendingCompactionBytes, cf.CompactionPending, lastCompaction)
+	}
+	fmt.Printf("totalBytes=[%d], liveBytes=[%d], reclaimableBytes=[%d], diskBytes=[%d], runningCompactions=[%d]\n",
+		report.TotalBytes, report.LiveBytes, report.ReclaimableBytes, report.DiskBytes, report.RunningCompactions)
 }
 
 func printLiveFilesMetaData(openchainDB *db.OpenchainDB) {
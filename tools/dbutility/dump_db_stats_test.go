@@ -49,7 +49,7 @@ func TestDBStatsOversizedKV(t *testing.T) {
 	writeBatch.PutCF(openchainDB.BlockchainCF, []byte("key2"), generateOversizedValue(0))
 	writeBatch.PutCF(openchainDB.BlockchainCF, []byte("key3"), generateOversizedValue(100))
 	writeBatch.PutCF(openchainDB.BlockchainCF, []byte("key4"), []byte("value4"))
-	dbTestWrapper.WriteToDB(t, writeBatch)
+	dbTestWrapper.WriteToDB(t, openchainDB.WrapWriteBatch(writeBatch))
 
 	totalKVs, numOverSizedKVs := scan(openchainDB, "blockchainCF", openchainDB.BlockchainCF, testDetailPrinter)
 
@@ -0,0 +1,315 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric/protos"
+)
+
+const (
+	// defaultPageSize is the number of blocks or history entries listed when no limit is given
+	defaultPageSize = 20
+	// maxPageSize is the maximum number of blocks or history entries listed
+	maxPageSize = 100
+)
+
+// explorerStatus tells how far the indexing went
+type explorerStatus struct {
+	// Height is the number of blocks indexed
+	Height uint64 `json:"height"`
+	// StateDeltaHeight is the number of blocks whose state delta was indexed
+	StateDeltaHeight uint64 `json:"stateDeltaHeight"`
+	// MissingStateDeltas is the number of state deltas the peer no longer retained
+	MissingStateDeltas uint64 `json:"missingStateDeltas,omitempty"`
+}
+
+// blockDetail is a block along with its number and hash
+type blockDetail struct {
+	Number uint64        `json:"number"`
+	Hash   []byte        `json:"hash,omitempty"`
+	Block  *protos.Block `json:"block"`
+}
+
+// historyPage is a page of the history of a key. NextFromBlock, if set, is the fromBlock
+// of the next page
+type historyPage struct {
+	ChaincodeID   string          `json:"chaincodeID"`
+	Key           string          `json:"key"`
+	Entries       []*historyEntry `json:"entries"`
+	NextFromBlock *uint64         `json:"nextFromBlock,omitempty"`
+}
+
+// newHandler returns the handler of the web UI and of the REST API of the explorer
+func newHandler(idx *index) http.Handler {
+	api := &explorerAPI{index: idx}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/status", api.status)
+	mux.HandleFunc("/api/blocks", api.listBlocks)
+	mux.HandleFunc("/api/blocks/", api.getBlock)
+	mux.HandleFunc("/api/transactions/", api.getTransaction)
+	mux.HandleFunc("/api/history", api.keyHistory)
+	mux.HandleFunc("/", func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/" {
+			http.NotFound(rw, req)
+			return
+		}
+		rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(rw, indexPage)
+	})
+	return mux
+}
+
+type explorerAPI struct {
+	index *index
+}
+
+func writeJSON(rw http.ResponseWriter, status int, value interface{}) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+	json.NewEncoder(rw).Encode(value)
+}
+
+func writeError(rw http.ResponseWriter, status int, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	if status == http.StatusInternalServerError {
+		logger.Error(msg)
+	}
+	writeJSON(rw, status, map[string]string{"Error": msg})
+}
+
+// uintParam returns the value of the query parameter, def if it is not set
+func uintParam(req *http.Request, name string, def uint64) (uint64, error) {
+	value := req.URL.Query().Get(name)
+	if value == "" {
+		return def, nil
+	}
+	n, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid %s: %s", name, value)
+	}
+	return n, nil
+}
+
+// limitParam returns the value of the limit query parameter, within maxPageSize
+func limitParam(req *http.Request) (int, error) {
+	limit, err := uintParam(req, "limit", defaultPageSize)
+	if err != nil {
+		return 0, err
+	}
+	if limit == 0 || limit > maxPageSize {
+		limit = maxPageSize
+	}
+	return int(limit), nil
+}
+
+func (api *explorerAPI) status(rw http.ResponseWriter, req *http.Request) {
+	status := &explorerStatus{}
+	var err error
+	if status.Height, err = api.index.nextBlock(); err == nil {
+		if status.StateDeltaHeight, err = api.index.nextStateDelta(); err == nil {
+			status.MissingStateDeltas, err = api.index.missingStateDeltas()
+		}
+	}
+	if err != nil {
+		writeError(rw, http.StatusInternalServerError, "Error reading the status of the index: %s", err)
+		return
+	}
+	writeJSON(rw, http.StatusOK, status)
+}
+
+// listBlocks lists the blocks preceding the block of the query parameter before, the
+// latest block included if not given, the latest first
+func (api *explorerAPI) listBlocks(rw http.ResponseWriter, req *http.Request) {
+	height, err := api.index.nextBlock()
+	if err != nil {
+		writeError(rw, http.StatusInternalServerError, "Error reading the height: %s", err)
+		return
+	}
+	before, err := uintParam(req, "before", height)
+	if err != nil {
+		writeError(rw, http.StatusBadRequest, "%s", err)
+		return
+	}
+	limit, err := limitParam(req)
+	if err != nil {
+		writeError(rw, http.StatusBadRequest, "%s", err)
+		return
+	}
+	if before > height {
+		before = height
+	}
+	blocks, err := api.index.listBlocks(before, limit)
+	if err != nil {
+		writeError(rw, http.StatusInternalServerError, "Error listing the blocks: %s", err)
+		return
+	}
+	writeJSON(rw, http.StatusOK, blocks)
+}
+
+func (api *explorerAPI) getBlock(rw http.ResponseWriter, req *http.Request) {
+	value := strings.TrimPrefix(req.URL.Path, "/api/blocks/")
+	blockNumber, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		writeError(rw, http.StatusBadRequest, "Invalid block number: %s", value)
+		return
+	}
+	block, err := api.index.getBlock(blockNumber)
+	if err != nil {
+		writeError(rw, http.StatusInternalServerError, "Error reading block %d: %s", blockNumber, err)
+		return
+	}
+	if block == nil {
+		writeError(rw, http.StatusNotFound, "Block %d not indexed", blockNumber)
+		return
+	}
+	hash, err := api.index.blockHash(blockNumber)
+	if err != nil {
+		writeError(rw, http.StatusInternalServerError, "Error reading the hash of block %d: %s", blockNumber, err)
+		return
+	}
+	writeJSON(rw, http.StatusOK, &blockDetail{Number: blockNumber, Hash: hash, Block: block})
+}
+
+func (api *explorerAPI) getTransaction(rw http.ResponseWriter, req *http.Request) {
+	txUUID := strings.TrimPrefix(req.URL.Path, "/api/transactions/")
+	if txUUID == "" {
+		writeError(rw, http.StatusBadRequest, "Transaction UUID not given")
+		return
+	}
+	tx, err := api.index.getTransaction(txUUID)
+	if err != nil {
+		writeError(rw, http.StatusInternalServerError, "Error reading transaction %s: %s", txUUID, err)
+		return
+	}
+	if tx == nil {
+		writeError(rw, http.StatusNotFound, "Transaction %s not indexed", txUUID)
+		return
+	}
+	writeJSON(rw, http.StatusOK, tx)
+}
+
+// keyHistory lists the values of the key of the chaincode given by the query parameters,
+// from the block fromBlock on
+func (api *explorerAPI) keyHistory(rw http.ResponseWriter, req *http.Request) {
+	chaincodeID, key := req.URL.Query().Get("chaincodeID"), req.URL.Query().Get("key")
+	if chaincodeID == "" || key == "" {
+		writeError(rw, http.StatusBadRequest, "chaincodeID and key must be given")
+		return
+	}
+	fromBlock, err := uintParam(req, "fromBlock", 0)
+	if err != nil {
+		writeError(rw, http.StatusBadRequest, "%s", err)
+		return
+	}
+	limit, err := limitParam(req)
+	if err != nil {
+		writeError(rw, http.StatusBadRequest, "%s", err)
+		return
+	}
+	// one more entry tells whether there is a next page
+	entries, err := api.index.keyHistory(chaincodeID, key, fromBlock, limit+1)
+	if err != nil {
+		writeError(rw, http.StatusInternalServerError, "Error reading the history of key %s: %s", key, err)
+		return
+	}
+	page := &historyPage{ChaincodeID: chaincodeID, Key: key, Entries: entries}
+	if len(entries) > limit {
+		page.Entries = entries[:limit]
+		page.NextFromBlock = &entries[limit].BlockNumber
+	}
+	writeJSON(rw, http.StatusOK, page)
+}
+
+// indexPage is the web UI of the explorer, a single page reading the REST API
+const indexPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Fabric explorer</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+td, th { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; }
+pre { background: #f4f4f4; padding: 1em; overflow: auto; }
+</style>
+</head>
+<body>
+<h1>Fabric explorer</h1>
+<p id="status"></p>
+<form id="search">
+Block number or transaction UUID <input id="query" size="40"> <button>Show</button>
+</form>
+<form id="history">
+Key history: chaincode <input id="chaincodeID" size="30"> key <input id="key"> <button>Show</button>
+</form>
+<pre id="detail" hidden></pre>
+<h2>Latest blocks</h2>
+<table>
+<thead><tr><th>Number</th><th>Time</th><th>Transactions</th><th>Hash</th></tr></thead>
+<tbody id="blocks"></tbody>
+</table>
+<script>
+function get(path) {
+	return fetch(path).then(function(resp) { return resp.json(); });
+}
+function show(value) {
+	var detail = document.getElementById("detail");
+	detail.textContent = JSON.stringify(value, null, 2);
+	detail.hidden = false;
+}
+function refresh() {
+	get("/api/status").then(function(status) {
+		document.getElementById("status").textContent = status.height + " blocks indexed, state deltas of " + status.stateDeltaHeight;
+	});
+	get("/api/blocks").then(function(blocks) {
+		var rows = document.getElementById("blocks");
+		rows.innerHTML = "";
+		blocks.forEach(function(b) {
+			var row = rows.insertRow();
+			var link = document.createElement("a");
+			link.href = "#";
+			link.textContent = b.number;
+			link.onclick = function() { get("/api/blocks/" + b.number).then(show); return false; };
+			row.insertCell().appendChild(link);
+			row.insertCell().textContent = b.timestamp ? new Date(b.timestamp.seconds * 1000).toISOString() : "";
+			row.insertCell().textContent = b.transactions;
+			row.insertCell().textContent = b.hash || "";
+		});
+	});
+}
+document.getElementById("search").onsubmit = function() {
+	var q = document.getElementById("query").value.trim();
+	get(/^[0-9]+$/.test(q) ? "/api/blocks/" + q : "/api/transactions/" + encodeURIComponent(q)).then(show);
+	return false;
+};
+document.getElementById("history").onsubmit = function() {
+	get("/api/history?chaincodeID=" + encodeURIComponent(document.getElementById("chaincodeID").value) +
+		"&key=" + encodeURIComponent(document.getElementById("key").value)).then(show);
+	return false;
+};
+refresh();
+setInterval(refresh, 5000);
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,90 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/op/go-logging"
+	"github.com/spf13/viper"
+)
+
+var logger = logging.MustGetLogger("explorer")
+
+func main() {
+	peerAddress := flag.String("peer", "0.0.0.0:30303", "address of the gRPC services of the peer, streaming the state deltas")
+	eventsAddress := flag.String("events", "0.0.0.0:31315", "address of the event hub of the peer, a validating peer, streaming the blocks")
+	dbDir := flag.String("dbDir", "", "path to the directory of the index db of the explorer")
+	listenAddress := flag.String("listen", "0.0.0.0:5080", "address the web UI and the REST API are served on")
+	configPath := flag.String("config", "", "path to a core.yaml, for the TLS configuration of the connections to the peer (peer.tls)")
+	retryInterval := flag.Duration("retryInterval", 5*time.Second, "interval between the attempts to reconnect to the peer")
+	logLevel := flag.String("logLevel", "info", "logging level")
+	flag.Parse()
+
+	if *dbDir == "" {
+		flag.Usage()
+		os.Exit(3)
+	}
+	level, err := logging.LogLevel(*logLevel)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid logging level %s\n", *logLevel)
+		os.Exit(3)
+	}
+	logging.SetLevel(level, "")
+	if err := loadConfig(*configPath); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(4)
+	}
+
+	store, err := openRocksdbStore(*dbDir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(5)
+	}
+	defer store.Close()
+	idx := &index{store: store}
+
+	go newBlockFollower(idx).follow(*eventsAddress, *retryInterval)
+	go followStateDeltas(*peerAddress, idx, *retryInterval)
+
+	logger.Info("Serving the explorer on %s", *listenAddress)
+	if err := http.ListenAndServe(*listenAddress, newHandler(idx)); err != nil {
+		logger.Error("Error serving the explorer: %s", err)
+		os.Exit(6)
+	}
+}
+
+// loadConfig reads the configuration of the connections to the peer, which may also be
+// set by the CORE_ environment variables
+func loadConfig(configPath string) error {
+	viper.SetEnvPrefix("core")
+	viper.AutomaticEnv()
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	if configPath == "" {
+		return nil
+	}
+	viper.SetConfigFile(configPath)
+	if err := viper.ReadInConfig(); err != nil {
+		return fmt.Errorf("Error reading the config file [%s]: %s", configPath, err)
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/hyperledger/fabric/protos"
+)
+
+func newTestIndex(t *testing.T) (*index, func()) {
+	dir, err := ioutil.TempDir("", "explorer-test")
+	if err != nil {
+		t.Fatalf("Error creating the db dir: %s", err)
+	}
+	store, err := openRocksdbStore(dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("Error opening the index: %s", err)
+	}
+	return &index{store: store}, func() {
+		store.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+// addTestBlocks adds blocks holding a transaction each, txN in block N
+func addTestBlocks(t *testing.T, idx *index, count int) {
+	var previousHash []byte
+	for i := 0; i < count; i++ {
+		tx := &protos.Transaction{Uuid: fmt.Sprintf("tx%d", i)}
+		block := protos.NewBlock([]*protos.Transaction{tx}, nil)
+		block.PreviousBlockHash = previousHash
+		block.NonHashData = &protos.NonHashData{TransactionResults: []*protos.TransactionResult{{Uuid: tx.Uuid, ErrorCode: uint32(i % 2)}}}
+		blockNumber, err := idx.addBlock(block)
+		if err != nil {
+			t.Fatalf("Error adding block %d: %s", i, err)
+		}
+		if blockNumber != uint64(i) {
+			t.Fatalf("Expected block %d to be added as block %d", blockNumber, i)
+		}
+		if previousHash, err = block.GetHash(); err != nil {
+			t.Fatalf("Error hashing block %d: %s", i, err)
+		}
+	}
+}
+
+func TestIndexBlocks(t *testing.T) {
+	idx, cleanup := newTestIndex(t)
+	defer cleanup()
+	addTestBlocks(t, idx, 3)
+
+	blocks, err := idx.listBlocks(3, 2)
+	if err != nil {
+		t.Fatalf("Error listing the blocks: %s", err)
+	}
+	if len(blocks) != 2 || blocks[0].Number != 2 || blocks[1].Number != 1 {
+		t.Fatalf("Expected blocks 2 and 1, got %v", blocks)
+	}
+	if blocks[0].Hash != nil || string(blocks[1].Hash) != string(blocks[0].PreviousBlockHash) {
+		t.Fatal("Expected the hash of a block to be recorded by the next block")
+	}
+
+	tx, err := idx.getTransaction("tx1")
+	if err != nil || tx == nil {
+		t.Fatalf("Expected tx1 to be indexed, got %v", err)
+	}
+	if tx.BlockNumber != 1 || tx.TxIndex != 0 || tx.Transaction.Uuid != "tx1" || tx.Result.ErrorCode != 1 {
+		t.Fatalf("Unexpected location of tx1: %v", tx)
+	}
+	if tx, err = idx.getTransaction("tx3"); err != nil || tx != nil {
+		t.Fatalf("Expected tx3 not to be indexed, got %v, %v", tx, err)
+	}
+}
+
+func TestIndexKeyHistory(t *testing.T) {
+	idx, cleanup := newTestIndex(t)
+	defer cleanup()
+
+	deltas := []*protos.BlockStateDelta{
+		{BlockNumber: 0, KeyValues: []*protos.StateKeyValue{{ChaincodeID: "cc", Key: "a", Value: []byte("1")}}},
+		{BlockNumber: 1, KeyValues: []*protos.StateKeyValue{{ChaincodeID: "cc", Key: "a\x00b", Value: []byte("x")}}},
+		{BlockNumber: 3, KeyValues: []*protos.StateKeyValue{{ChaincodeID: "cc", Key: "a", Deleted: true}}},
+	}
+	if err := idx.addStateDelta(deltas[0]); err != nil {
+		t.Fatalf("Error adding the state delta of block 0: %s", err)
+	}
+	if err := idx.addStateDelta(deltas[1]); err != nil {
+		t.Fatalf("Error adding the state delta of block 1: %s", err)
+	}
+	if err := idx.addStateDelta(deltas[2]); err == nil {
+		t.Fatal("Expected the state delta of block 3 to be refused before the one of block 2")
+	}
+	if skipped, err := idx.skipStateDelta(); err != nil || skipped != 2 {
+		t.Fatalf("Expected the state delta of block 2 to be skipped, got %d, %v", skipped, err)
+	}
+	if err := idx.addStateDelta(deltas[2]); err != nil {
+		t.Fatalf("Error adding the state delta of block 3: %s", err)
+	}
+	if missing, _ := idx.missingStateDeltas(); missing != 1 {
+		t.Fatalf("Expected 1 missing state delta, got %d", missing)
+	}
+
+	history, err := idx.keyHistory("cc", "a", 0, 10)
+	if err != nil {
+		t.Fatalf("Error reading the history: %s", err)
+	}
+	if len(history) != 2 || history[0].BlockNumber != 0 || string(history[0].Value) != "1" || history[1].BlockNumber != 3 || !history[1].Deleted {
+		t.Fatalf("Unexpected history of key a: %v", history)
+	}
+	if history, _ = idx.keyHistory("cc", "a", 1, 10); len(history) != 1 || history[0].BlockNumber != 3 {
+		t.Fatalf("Expected the history from block 1 to hold block 3 only, got %v", history)
+	}
+}
+
+func TestExplorerAPI(t *testing.T) {
+	idx, cleanup := newTestIndex(t)
+	defer cleanup()
+	addTestBlocks(t, idx, 5)
+	for i := uint64(0); i < 3; i++ {
+		delta := &protos.BlockStateDelta{BlockNumber: i, KeyValues: []*protos.StateKeyValue{{ChaincodeID: "cc", Key: "k", Value: []byte{byte(i)}}}}
+		if err := idx.addStateDelta(delta); err != nil {
+			t.Fatalf("Error adding the state delta of block %d: %s", i, err)
+		}
+	}
+	server := httptest.NewServer(newHandler(idx))
+	defer server.Close()
+
+	get := func(path string, expectedStatus int, value interface{}) {
+		resp, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatalf("Error getting %s: %s", path, err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != expectedStatus {
+			t.Fatalf("Expected status %d for %s, got %s", expectedStatus, path, resp.Status)
+		}
+		if value != nil {
+			if err := json.NewDecoder(resp.Body).Decode(value); err != nil {
+				t.Fatalf("Error decoding the response of %s: %s", path, err)
+			}
+		}
+	}
+
+	status := &explorerStatus{}
+	get("/api/status", http.StatusOK, status)
+	if status.Height != 5 || status.StateDeltaHeight != 3 {
+		t.Fatalf("Unexpected status %v", status)
+	}
+
+	var blocks []*blockSummary
+	get("/api/blocks?before=4&limit=2", http.StatusOK, &blocks)
+	if len(blocks) != 2 || blocks[0].Number != 3 || blocks[1].Number != 2 {
+		t.Fatalf("Expected blocks 3 and 2, got %v", blocks)
+	}
+	get("/api/blocks?before=x", http.StatusBadRequest, nil)
+
+	block := &blockDetail{}
+	get("/api/blocks/4", http.StatusOK, block)
+	if block.Number != 4 || len(block.Block.Transactions) != 1 || block.Block.Transactions[0].Uuid != "tx4" {
+		t.Fatalf("Unexpected block 4: %v", block)
+	}
+	get("/api/blocks/5", http.StatusNotFound, nil)
+
+	tx := &txLocation{}
+	get("/api/transactions/tx2", http.StatusOK, tx)
+	if tx.BlockNumber != 2 || tx.Transaction.Uuid != "tx2" {
+		t.Fatalf("Unexpected location of tx2: %v", tx)
+	}
+	get("/api/transactions/unknown", http.StatusNotFound, nil)
+
+	page := &historyPage{}
+	get("/api/history?chaincodeID=cc&key=k&limit=2", http.StatusOK, page)
+	if len(page.Entries) != 2 || page.NextFromBlock == nil || *page.NextFromBlock != 2 {
+		t.Fatalf("Unexpected first page of the history: %v", page)
+	}
+	page = &historyPage{}
+	get("/api/history?chaincodeID=cc&key=k&fromBlock=2", http.StatusOK, page)
+	if len(page.Entries) != 1 || page.Entries[0].BlockNumber != 2 || page.NextFromBlock != nil {
+		t.Fatalf("Unexpected last page of the history: %v", page)
+	}
+	get("/api/history?chaincodeID=cc", http.StatusBadRequest, nil)
+
+	get("/", http.StatusOK, nil)
+	get("/unknown", http.StatusNotFound, nil)
+}
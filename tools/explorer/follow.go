@@ -0,0 +1,171 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+
+	"github.com/hyperledger/fabric/core/comm"
+	"github.com/hyperledger/fabric/events/consumer"
+	"github.com/hyperledger/fabric/protos"
+)
+
+// maxUnackedBlocks is the number of block events sent by the event hub before the
+// previous ones are indexed
+const maxUnackedBlocks = 10
+
+// blockFollower is an event adapter adding the committed blocks to the index. It asks
+// the event hub to replay the blocks from the next one to index, so that no block is
+// missed while the explorer is stopped or disconnected
+type blockFollower struct {
+	index        *index
+	disconnected chan error
+}
+
+func newBlockFollower(idx *index) *blockFollower {
+	return &blockFollower{index: idx, disconnected: make(chan error, 1)}
+}
+
+// GetInterestedEvents implements consumer.EventAdapter
+func (f *blockFollower) GetInterestedEvents() ([]*protos.Interest, error) {
+	return []*protos.Interest{{EventType: "block", ResponseType: protos.Interest_PROTOBUF}}, nil
+}
+
+// GetReplay implements consumer.ReplayAdapter
+func (f *blockFollower) GetReplay() (*protos.Replay, error) {
+	nextBlock, err := f.index.nextBlock()
+	if err != nil {
+		return nil, err
+	}
+	return &protos.Replay{FromBlock: nextBlock}, nil
+}
+
+// GetMaxUnackedEvents implements consumer.AckAdapter
+func (f *blockFollower) GetMaxUnackedEvents() uint32 {
+	return maxUnackedBlocks
+}
+
+// Recv implements consumer.EventAdapter by adding the block to the index. The follower
+// disconnects if the block can't be added, to replay the blocks from the next one to index
+func (f *blockFollower) Recv(msg *protos.Event) (bool, error) {
+	block := msg.GetBlock()
+	if block == nil {
+		return true, nil
+	}
+	blockNumber, err := f.index.addBlock(block)
+	if err != nil {
+		logger.Error("Error indexing a block: %s", err)
+		f.Disconnected(err)
+		return false, err
+	}
+	logger.Debug("Indexed block %d", blockNumber)
+	return true, nil
+}
+
+// Disconnected implements consumer.EventAdapter
+func (f *blockFollower) Disconnected(err error) {
+	select {
+	case f.disconnected <- err:
+	default:
+	}
+}
+
+// follow connects the follower to the event hub at the given address and reconnects it
+// whenever it is disconnected, until the hub closes the stream
+func (f *blockFollower) follow(eventsAddress string, retryInterval time.Duration) {
+	for {
+		client := consumer.NewEventsClient(eventsAddress, f)
+		if err := client.Start(); err != nil {
+			logger.Error("Error connecting to the event hub at %s: %s", eventsAddress, err)
+			time.Sleep(retryInterval)
+			continue
+		}
+		logger.Info("Following the blocks of the event hub at %s", eventsAddress)
+		err := <-f.disconnected
+		client.Stop()
+		if err == nil {
+			logger.Warning("The event hub at %s closed the stream of blocks", eventsAddress)
+			return
+		}
+		logger.Warning("Disconnected from the event hub: %s", err)
+		time.Sleep(retryInterval)
+	}
+}
+
+// followStateDeltas adds to the index the state deltas streamed by the Openchain service
+// of the peer at the given address, from the next one to index, and subscribes again
+// whenever the stream fails
+func followStateDeltas(peerAddress string, idx *index, retryInterval time.Duration) {
+	for {
+		err := streamStateDeltas(peerAddress, idx)
+		if grpc.Code(err) == codes.NotFound {
+			// the peer does not retain the state delta, the history of the keys misses it
+			blockNumber, err := idx.skipStateDelta()
+			if err == nil {
+				logger.Warning("The state delta of block %d is not retained by the peer, the history of the keys misses it", blockNumber)
+				continue
+			}
+			logger.Error("Error skipping a state delta: %s", err)
+		} else {
+			logger.Error("Error following the state deltas of the peer at %s: %s", peerAddress, err)
+		}
+		time.Sleep(retryInterval)
+	}
+}
+
+// streamStateDeltas subscribes to the state deltas from the next one to index and adds them
+// to the index, until the stream fails
+func streamStateDeltas(peerAddress string, idx *index) error {
+	var conn *grpc.ClientConn
+	var err error
+	if comm.TLSEnabled() {
+		conn, err = comm.NewClientConnectionWithAddress(peerAddress, true, true, comm.InitTLSForPeer())
+	} else {
+		conn, err = comm.NewClientConnectionWithAddress(peerAddress, true, false, nil)
+	}
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	fromBlock, err := idx.nextStateDelta()
+	if err != nil {
+		return err
+	}
+	stream, err := protos.NewOpenchainClient(conn).SubscribeStateDeltas(context.Background(), &protos.StateDeltasSubscription{FromBlock: fromBlock})
+	if err != nil {
+		return err
+	}
+	logger.Info("Following the state deltas of the peer at %s from block %d", peerAddress, fromBlock)
+	for {
+		delta, err := stream.Recv()
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return err
+		}
+		if err := idx.addStateDelta(delta); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,315 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	google_protobuf "google/protobuf"
+
+	"github.com/hyperledger/fabric/protos"
+)
+
+// The index holds, under keys prefixed by the kind of record:
+//   b<blockNumber>                     the blocks, as received in the block events
+//   t<txUUID>                          the block number and index of the transactions
+//   h<chaincodeID>0<key>0<blockNumber> the value of the key set by the block, a StateKeyValue
+//   m<name>                            the progress of the indexing
+// The numbers are big endian uint64, so that the records are in the order of the blocks.
+
+var (
+	blockPrefix   = []byte("b")
+	txPrefix      = []byte("t")
+	historyPrefix = []byte("h")
+
+	nextBlockKey     = []byte("mnextBlock")
+	nextDeltaKey     = []byte("mnextDelta")
+	missingDeltasKey = []byte("mmissingDeltas")
+)
+
+// kvStore is the key-value store of the index
+type kvStore interface {
+	Get(key []byte) ([]byte, error)
+	// Write puts the key-values at once
+	Write(kvs []*keyValue) error
+	// Scan calls f with the key-values whose key starts with prefix, from the key start on,
+	// in the order of the keys, until f returns false
+	Scan(prefix []byte, start []byte, f func(key, value []byte) bool) error
+	Close()
+}
+
+type keyValue struct {
+	key   []byte
+	value []byte
+}
+
+// index is the index of the blocks, the transactions and the history of the keys built
+// by the explorer. The blocks and the state deltas are added in the order of the blocks,
+// each by its own follower.
+type index struct {
+	store kvStore
+	// lock serializes the updates of the counters
+	lock sync.Mutex
+}
+
+// blockSummary is a block as listed by the explorer
+type blockSummary struct {
+	Number            uint64                     `json:"number"`
+	Hash              []byte                     `json:"hash,omitempty"`
+	PreviousBlockHash []byte                     `json:"previousBlockHash,omitempty"`
+	Timestamp         *google_protobuf.Timestamp `json:"timestamp,omitempty"`
+	Transactions      int                        `json:"transactions"`
+}
+
+// txLocation is a committed transaction along with where it is in the blockchain and
+// its result
+type txLocation struct {
+	BlockNumber uint64                    `json:"blockNumber"`
+	TxIndex     uint64                    `json:"txIndex"`
+	Transaction *protos.Transaction       `json:"transaction"`
+	Result      *protos.TransactionResult `json:"result,omitempty"`
+}
+
+// historyEntry is a value of a key, set or deleted by a block
+type historyEntry struct {
+	BlockNumber uint64 `json:"blockNumber"`
+	Value       []byte `json:"value,omitempty"`
+	Deleted     bool   `json:"deleted,omitempty"`
+}
+
+func encodeUint64(n uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, n)
+	return b
+}
+
+func join(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+func blockKey(blockNumber uint64) []byte {
+	return join(blockPrefix, encodeUint64(blockNumber))
+}
+
+func txKey(txUUID string) []byte {
+	return join(txPrefix, []byte(txUUID))
+}
+
+func historyKeyPrefix(chaincodeID, key string) []byte {
+	return join(historyPrefix, []byte(chaincodeID), []byte{0}, []byte(key), []byte{0})
+}
+
+func (idx *index) getUint64(key []byte) (uint64, error) {
+	value, err := idx.store.Get(key)
+	if err != nil || value == nil {
+		return 0, err
+	}
+	if len(value) != 8 {
+		return 0, fmt.Errorf("Corrupted index: invalid value of %s", key)
+	}
+	return binary.BigEndian.Uint64(value), nil
+}
+
+// nextBlock returns the number of the next block to add, i.e. the number of blocks indexed
+func (idx *index) nextBlock() (uint64, error) {
+	return idx.getUint64(nextBlockKey)
+}
+
+// nextStateDelta returns the number of the block whose state delta is added next
+func (idx *index) nextStateDelta() (uint64, error) {
+	return idx.getUint64(nextDeltaKey)
+}
+
+// missingStateDeltas returns the number of blocks whose state delta could not be added,
+// because the peer no longer retained it
+func (idx *index) missingStateDeltas() (uint64, error) {
+	return idx.getUint64(missingDeltasKey)
+}
+
+// addBlock adds the next block. The event hub sends each block once and in the order of
+// the blocks, from the one the follower asked for, so the block is the one following the
+// last block added.
+func (idx *index) addBlock(block *protos.Block) (uint64, error) {
+	idx.lock.Lock()
+	defer idx.lock.Unlock()
+	blockNumber, err := idx.nextBlock()
+	if err != nil {
+		return 0, err
+	}
+	blockBytes, err := proto.Marshal(block)
+	if err != nil {
+		return 0, err
+	}
+	kvs := []*keyValue{{blockKey(blockNumber), blockBytes}}
+	for i, tx := range block.Transactions {
+		kvs = append(kvs, &keyValue{txKey(tx.Uuid), join(encodeUint64(blockNumber), encodeUint64(uint64(i)))})
+	}
+	kvs = append(kvs, &keyValue{nextBlockKey, encodeUint64(blockNumber + 1)})
+	return blockNumber, idx.store.Write(kvs)
+}
+
+// addStateDelta adds the history of the keys changed by the state delta of the next block
+func (idx *index) addStateDelta(delta *protos.BlockStateDelta) error {
+	idx.lock.Lock()
+	defer idx.lock.Unlock()
+	next, err := idx.nextStateDelta()
+	if err != nil {
+		return err
+	}
+	if delta.BlockNumber != next {
+		return fmt.Errorf("Received the state delta of block %d, expected the one of block %d", delta.BlockNumber, next)
+	}
+	kvs := make([]*keyValue, 0, len(delta.KeyValues)+1)
+	for _, kv := range delta.KeyValues {
+		entry, err := proto.Marshal(kv)
+		if err != nil {
+			return err
+		}
+		kvs = append(kvs, &keyValue{join(historyKeyPrefix(kv.ChaincodeID, kv.Key), encodeUint64(delta.BlockNumber)), entry})
+	}
+	kvs = append(kvs, &keyValue{nextDeltaKey, encodeUint64(next + 1)})
+	return idx.store.Write(kvs)
+}
+
+// skipStateDelta skips the state delta of the next block, which the peer does not retain
+func (idx *index) skipStateDelta() (uint64, error) {
+	idx.lock.Lock()
+	defer idx.lock.Unlock()
+	next, err := idx.nextStateDelta()
+	if err != nil {
+		return 0, err
+	}
+	missing, err := idx.missingStateDeltas()
+	if err != nil {
+		return 0, err
+	}
+	return next, idx.store.Write([]*keyValue{
+		{nextDeltaKey, encodeUint64(next + 1)},
+		{missingDeltasKey, encodeUint64(missing + 1)},
+	})
+}
+
+// getBlock returns the block of the given number, nil if it is not indexed
+func (idx *index) getBlock(blockNumber uint64) (*protos.Block, error) {
+	blockBytes, err := idx.store.Get(blockKey(blockNumber))
+	if err != nil || blockBytes == nil {
+		return nil, err
+	}
+	block := &protos.Block{}
+	if err := proto.Unmarshal(blockBytes, block); err != nil {
+		return nil, fmt.Errorf("Corrupted index: invalid block %d: %s", blockNumber, err)
+	}
+	return block, nil
+}
+
+// blockHash returns the hash of the block, recorded by the next block. The hash can't be
+// computed from the block as the event hub sends it, without the payload of its deploy
+// transactions. It is nil for the last block indexed
+func (idx *index) blockHash(blockNumber uint64) ([]byte, error) {
+	next, err := idx.getBlock(blockNumber + 1)
+	if err != nil || next == nil {
+		return nil, err
+	}
+	return next.PreviousBlockHash, nil
+}
+
+// listBlocks returns the summaries of at most limit blocks preceding the block before,
+// the latest first
+func (idx *index) listBlocks(before uint64, limit int) ([]*blockSummary, error) {
+	summaries := []*blockSummary{}
+	if before == 0 {
+		return summaries, nil
+	}
+	hash, err := idx.blockHash(before - 1)
+	if err != nil {
+		return nil, err
+	}
+	for n := before; n > 0 && len(summaries) < limit; n-- {
+		block, err := idx.getBlock(n - 1)
+		if err != nil {
+			return nil, err
+		}
+		if block == nil {
+			break
+		}
+		summaries = append(summaries, &blockSummary{
+			Number:            n - 1,
+			Hash:              hash,
+			PreviousBlockHash: block.PreviousBlockHash,
+			Timestamp:         block.Timestamp,
+			Transactions:      len(block.Transactions),
+		})
+		hash = block.PreviousBlockHash
+	}
+	return summaries, nil
+}
+
+// getTransaction returns the committed transaction of the given uuid, nil if it is not
+// indexed
+func (idx *index) getTransaction(txUUID string) (*txLocation, error) {
+	location, err := idx.store.Get(txKey(txUUID))
+	if err != nil || location == nil {
+		return nil, err
+	}
+	if len(location) != 16 {
+		return nil, fmt.Errorf("Corrupted index: invalid location of transaction %s", txUUID)
+	}
+	blockNumber, txIndex := binary.BigEndian.Uint64(location), binary.BigEndian.Uint64(location[8:])
+	block, err := idx.getBlock(blockNumber)
+	if err != nil {
+		return nil, err
+	}
+	if block == nil || txIndex >= uint64(len(block.Transactions)) {
+		return nil, fmt.Errorf("Corrupted index: transaction %s not found in block %d", txUUID, blockNumber)
+	}
+	tx := &txLocation{BlockNumber: blockNumber, TxIndex: txIndex, Transaction: block.Transactions[txIndex]}
+	for _, result := range block.GetNonHashData().GetTransactionResults() {
+		if result.Uuid == txUUID {
+			tx.Result = result
+			break
+		}
+	}
+	return tx, nil
+}
+
+// keyHistory returns at most limit values of the key of the chaincode, from the block
+// fromBlock on, in the order of the blocks
+func (idx *index) keyHistory(chaincodeID, key string, fromBlock uint64, limit int) ([]*historyEntry, error) {
+	prefix := historyKeyPrefix(chaincodeID, key)
+	entries := []*historyEntry{}
+	var decodeErr error
+	err := idx.store.Scan(prefix, join(prefix, encodeUint64(fromBlock)), func(k, v []byte) bool {
+		// skip the keys which only start with the key, containing a 0 byte
+		if len(k) != len(prefix)+8 {
+			return true
+		}
+		kv := &protos.StateKeyValue{}
+		if decodeErr = proto.Unmarshal(v, kv); decodeErr != nil {
+			return false
+		}
+		entries = append(entries, &historyEntry{BlockNumber: binary.BigEndian.Uint64(k[len(prefix):]), Value: kv.Value, Deleted: kv.Deleted})
+		return len(entries) < limit
+	})
+	if err == nil && decodeErr != nil {
+		err = fmt.Errorf("Corrupted index: invalid history of key %s of chaincode %s: %s", key, chaincodeID, decodeErr)
+	}
+	return entries, err
+}
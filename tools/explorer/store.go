@@ -0,0 +1,97 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/tecbot/gorocksdb"
+)
+
+// rocksdbStore is a kvStore kept in a rocksdb of its own, distinct from the db of the peer
+type rocksdbStore struct {
+	db *gorocksdb.DB
+}
+
+// openRocksdbStore opens the store in dbPath, creating it if missing
+func openRocksdbStore(dbPath string) (*rocksdbStore, error) {
+	if err := os.MkdirAll(dbPath, 0755); err != nil {
+		return nil, fmt.Errorf("Error making directory path [%s]: %s", dbPath, err)
+	}
+	opts := gorocksdb.NewDefaultOptions()
+	defer opts.Destroy()
+	opts.SetCreateIfMissing(true)
+	db, err := gorocksdb.OpenDb(opts, dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("Error opening the index db [%s]: %s", dbPath, err)
+	}
+	return &rocksdbStore{db: db}, nil
+}
+
+func (s *rocksdbStore) Get(key []byte) ([]byte, error) {
+	opt := gorocksdb.NewDefaultReadOptions()
+	defer opt.Destroy()
+	slice, err := s.db.Get(opt, key)
+	if err != nil {
+		return nil, fmt.Errorf("Error while retrieving key [%x]: %s", key, err)
+	}
+	defer slice.Free()
+	if slice.Data() == nil {
+		return nil, nil
+	}
+	return append([]byte(nil), slice.Data()...), nil
+}
+
+func (s *rocksdbStore) Write(kvs []*keyValue) error {
+	batch := gorocksdb.NewWriteBatch()
+	defer batch.Destroy()
+	for _, kv := range kvs {
+		batch.Put(kv.key, kv.value)
+	}
+	opt := gorocksdb.NewDefaultWriteOptions()
+	defer opt.Destroy()
+	if err := s.db.Write(opt, batch); err != nil {
+		return fmt.Errorf("Error while writing the index: %s", err)
+	}
+	return nil
+}
+
+func (s *rocksdbStore) Scan(prefix []byte, start []byte, f func(key, value []byte) bool) error {
+	opt := gorocksdb.NewDefaultReadOptions()
+	defer opt.Destroy()
+	itr := s.db.NewIterator(opt)
+	defer itr.Close()
+	if !bytes.HasPrefix(start, prefix) {
+		start = prefix
+	}
+	for itr.Seek(start); itr.ValidForPrefix(prefix); itr.Next() {
+		key, value := itr.Key(), itr.Value()
+		cont := f(append([]byte(nil), key.Data()...), append([]byte(nil), value.Data()...))
+		key.Free()
+		value.Free()
+		if !cont {
+			break
+		}
+	}
+	return itr.Err()
+}
+
+func (s *rocksdbStore) Close() {
+	s.db.Close()
+}
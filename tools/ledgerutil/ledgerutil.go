@@ -0,0 +1,399 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/ledger"
+	"github.com/hyperledger/fabric/core/ledger/statemgmt"
+	"github.com/hyperledger/fabric/protos"
+	"github.com/spf13/viper"
+)
+
+const (
+	manifestFileName = "manifest.json"
+	blocksFileName   = "blocks"
+	stateFileName    = "state"
+	deltasFileName   = "deltas"
+
+	// stateChunkSize is the number of key-values committed at once when restoring the state
+	stateChunkSize = 1000
+)
+
+// manifest describes the content of a dump
+type manifest struct {
+	Format           string `json:"format"`
+	BlockchainHeight uint64 `json:"blockchainHeight"`
+	CurrentBlockHash string `json:"currentBlockHash"`
+	StateHash        string `json:"stateHash"`
+	NumStateKeys     uint64 `json:"numStateKeys"`
+	NumStateDeltas   uint64 `json:"numStateDeltas"`
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage of %s:\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s dump -dbDir <dir> -out <dumpDir> [-format json|proto] [-config <core.yaml>]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s restore -dbDir <dir> -in <dumpDir> [-config <core.yaml>]\n", os.Args[0])
+	os.Exit(3)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	command := os.Args[1]
+	flagSet := flag.NewFlagSet(command, flag.ExitOnError)
+	dbDirPtr := flagSet.String("dbDir", "", "path to the directory containing the dir named 'db'")
+	configPtr := flagSet.String("config", "", "path to the core.yaml of the peer, for the configuration of the state")
+	outPtr := flagSet.String("out", "", "path to the directory to write the dump to")
+	inPtr := flagSet.String("in", "", "path to the directory to read the dump from")
+	formatPtr := flagSet.String("format", formatJSON, "format of the dump, either 'json' or 'proto'")
+	flagSet.Parse(os.Args[2:])
+
+	dbDir := *dbDirPtr
+	if dbDir == "" {
+		usage()
+	}
+	if err := loadConfig(*configPtr); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(4)
+	}
+	viper.Set("peer.fileSystemPath", dbDir)
+	fmt.Printf("dbDir = [%s]\n", dbDir)
+
+	_, err := os.Stat(filepath.Join(dbDir, "db"))
+	dbExists := err == nil
+	switch command {
+	case "dump":
+		if *outPtr == "" || (*formatPtr != formatJSON && *formatPtr != formatProto) {
+			usage()
+		}
+		if !dbExists {
+			fmt.Fprintln(os.Stderr, "dbDir does not contain a sub-dir named 'db'")
+			os.Exit(5)
+		}
+		err = withLedger(func(l *ledger.Ledger) error {
+			m, err := dump(l, *outPtr, *formatPtr)
+			if err == nil {
+				fmt.Printf("Dumped %d blocks, %d state keys and %d state deltas to [%s]\n",
+					m.BlockchainHeight, m.NumStateKeys, m.NumStateDeltas, *outPtr)
+			}
+			return err
+		})
+	case "restore":
+		if *inPtr == "" {
+			usage()
+		}
+		if dbExists {
+			fmt.Fprintln(os.Stderr, "dbDir already contains a sub-dir named 'db'")
+			os.Exit(5)
+		}
+		err = withLedger(func(l *ledger.Ledger) error {
+			m, err := restore(l, *inPtr)
+			if err == nil {
+				fmt.Printf("Restored %d blocks, %d state keys and %d state deltas from [%s]\n",
+					m.BlockchainHeight, m.NumStateKeys, m.NumStateDeltas, *inPtr)
+			}
+			return err
+		})
+	default:
+		usage()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// loadConfig reads the configuration of the peer, so that the state is organized the same way
+// as on the peer. The defaults of the ledger are used if configPath is empty
+func loadConfig(configPath string) error {
+	viper.SetEnvPrefix("core")
+	viper.AutomaticEnv()
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	if configPath == "" {
+		return nil
+	}
+	viper.SetConfigFile(configPath)
+	if err := viper.ReadInConfig(); err != nil {
+		return fmt.Errorf("Error reading the config file [%s]: %s", configPath, err)
+	}
+	return nil
+}
+
+func withLedger(f func(l *ledger.Ledger) error) error {
+	l, err := ledger.GetLedger()
+	if err != nil {
+		return fmt.Errorf("Error opening the ledger: %s", err)
+	}
+	return f(l)
+}
+
+// dump writes the blocks, the state and the retained state deltas of the ledger to dumpDir
+func dump(l *ledger.Ledger, dumpDir, format string) (*manifest, error) {
+	info, err := l.GetBlockchainInfo()
+	if err != nil {
+		return nil, err
+	}
+	if info.Height == 0 {
+		return nil, fmt.Errorf("The ledger has no blocks")
+	}
+	stateHash, err := l.GetTempStateHash()
+	if err != nil {
+		return nil, fmt.Errorf("Error computing the state hash: %s", err)
+	}
+	if err := os.MkdirAll(dumpDir, 0755); err != nil {
+		return nil, err
+	}
+	m := &manifest{Format: format, BlockchainHeight: info.Height,
+		CurrentBlockHash: hex.EncodeToString(info.CurrentBlockHash), StateHash: hex.EncodeToString(stateHash)}
+
+	err = writeRecords(dumpDir, blocksFileName, format, func(f *recordFile) error {
+		for blockNumber := uint64(0); blockNumber < info.Height; blockNumber++ {
+			block, err := l.GetBlockByNumber(blockNumber)
+			if err != nil {
+				return fmt.Errorf("Error reading block [%d]: %s", blockNumber, err)
+			}
+			if err := f.write(block); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = writeRecords(dumpDir, stateFileName, format, func(f *recordFile) error {
+		snapshot, err := l.GetStateSnapshot()
+		if err != nil {
+			return fmt.Errorf("Error taking a snapshot of the state: %s", err)
+		}
+		defer snapshot.Release()
+		for snapshot.Next() {
+			k, v := snapshot.GetRawKeyValue()
+			chaincodeID, key := statemgmt.DecodeCompositeKey(k)
+			if err := f.write(&protos.StateKeyValue{ChaincodeID: chaincodeID, Key: key, Value: v}); err != nil {
+				return err
+			}
+			m.NumStateKeys++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = writeRecords(dumpDir, deltasFileName, format, func(f *recordFile) error {
+		for blockNumber := uint64(0); blockNumber < info.Height; blockNumber++ {
+			delta, err := l.GetStateDelta(blockNumber)
+			if err != nil {
+				return fmt.Errorf("Error reading the state delta of block [%d]: %s", blockNumber, err)
+			}
+			if delta == nil {
+				continue
+			}
+			if err := f.write(&protos.BlockStateDeltaMessage{BlockNumber: blockNumber, Delta: delta.ToMessage()}); err != nil {
+				return err
+			}
+			m.NumStateDeltas++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	manifestBytes, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return m, ioutil.WriteFile(filepath.Join(dumpDir, manifestFileName), manifestBytes, 0644)
+}
+
+// restore rebuilds the empty ledger from the dump in dumpDir, and checks that the chain of
+// blocks and the state hash match the ones of the dumped ledger
+func restore(l *ledger.Ledger, dumpDir string) (*manifest, error) {
+	manifestBytes, err := ioutil.ReadFile(filepath.Join(dumpDir, manifestFileName))
+	if err != nil {
+		return nil, fmt.Errorf("Error reading the manifest of the dump: %s", err)
+	}
+	m := &manifest{}
+	if err := json.Unmarshal(manifestBytes, m); err != nil {
+		return nil, fmt.Errorf("Error parsing the manifest of the dump: %s", err)
+	}
+	if l.GetBlockchainSize() != 0 {
+		return nil, fmt.Errorf("The ledger is not empty")
+	}
+
+	var numBlocks uint64
+	err = readRecords(dumpDir, blocksFileName, m.Format, func(f *recordFile) error {
+		for {
+			block := &protos.Block{}
+			if err := f.read(block); err != nil {
+				return err
+			}
+			if err := l.PutRawBlock(block, numBlocks); err != nil {
+				return fmt.Errorf("Error writing block [%d]: %s", numBlocks, err)
+			}
+			numBlocks++
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if numBlocks != m.BlockchainHeight {
+		return nil, fmt.Errorf("Expected %d blocks in the dump, found %d", m.BlockchainHeight, numBlocks)
+	}
+
+	var numStateKeys uint64
+	err = readRecords(dumpDir, stateFileName, m.Format, func(f *recordFile) error {
+		delta := statemgmt.NewStateDelta()
+		for {
+			kv := &protos.StateKeyValue{}
+			err := f.read(kv)
+			if err == io.EOF {
+				if err := applyStateDelta(l, delta); err != nil {
+					return err
+				}
+			}
+			if err != nil {
+				return err
+			}
+			// protobuf does not tell an empty value from a missing one, which would be a delete
+			if kv.Value == nil {
+				kv.Value = []byte{}
+			}
+			delta.Set(kv.ChaincodeID, kv.Key, kv.Value, nil)
+			numStateKeys++
+			if numStateKeys%stateChunkSize == 0 {
+				if err := applyStateDelta(l, delta); err != nil {
+					return err
+				}
+				delta = statemgmt.NewStateDelta()
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if numStateKeys != m.NumStateKeys {
+		return nil, fmt.Errorf("Expected %d state keys in the dump, found %d", m.NumStateKeys, numStateKeys)
+	}
+
+	var numStateDeltas uint64
+	err = readRecords(dumpDir, deltasFileName, m.Format, func(f *recordFile) error {
+		for {
+			msg := &protos.BlockStateDeltaMessage{}
+			if err := f.read(msg); err != nil {
+				return err
+			}
+			delta := statemgmt.NewStateDelta()
+			if err := delta.FromMessage(msg.GetDelta()); err != nil {
+				return fmt.Errorf("Error reading the state delta of block [%d]: %s", msg.BlockNumber, err)
+			}
+			if err := l.PutStateDelta(msg.BlockNumber, delta); err != nil {
+				return fmt.Errorf("Error writing the state delta of block [%d]: %s", msg.BlockNumber, err)
+			}
+			numStateDeltas++
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if numStateDeltas != m.NumStateDeltas {
+		return nil, fmt.Errorf("Expected %d state deltas in the dump, found %d", m.NumStateDeltas, numStateDeltas)
+	}
+
+	return m, verify(l, m)
+}
+
+func applyStateDelta(l *ledger.Ledger, delta *statemgmt.StateDelta) error {
+	if delta.IsEmpty() {
+		return nil
+	}
+	if err := l.ApplyStateDelta("restore", delta); err != nil {
+		return err
+	}
+	if err := l.CommitStateDelta("restore"); err != nil {
+		return fmt.Errorf("Error writing the state: %s", err)
+	}
+	return nil
+}
+
+// verify checks that the restored ledger matches the dumped one
+func verify(l *ledger.Ledger, m *manifest) error {
+	info, err := l.GetBlockchainInfo()
+	if err != nil {
+		return err
+	}
+	if hex.EncodeToString(info.CurrentBlockHash) != m.CurrentBlockHash {
+		return fmt.Errorf("The hash of the last block [%x] does not match the one of the dump [%s]", info.CurrentBlockHash, m.CurrentBlockHash)
+	}
+	if info.Height > 1 {
+		badBlock, err := l.VerifyChain(info.Height-1, 0)
+		if err != nil {
+			return fmt.Errorf("Error verifying the chain: %s", err)
+		}
+		if badBlock != 0 {
+			return fmt.Errorf("The previous block hash of block [%d] does not match the hash of block [%d]", badBlock, badBlock-1)
+		}
+	}
+	stateHash, err := l.GetTempStateHash()
+	if err != nil {
+		return err
+	}
+	if expected, _ := hex.DecodeString(m.StateHash); !bytes.Equal(stateHash, expected) {
+		return fmt.Errorf("The state hash [%x] does not match the one of the dump [%s]. Was the dump taken with the same state configuration?", stateHash, m.StateHash)
+	}
+	return nil
+}
+
+func writeRecords(dumpDir, name, format string, f func(*recordFile) error) error {
+	file, err := createRecordFile(filepath.Join(dumpDir, name+"."+fileExtension(format)), format)
+	if err != nil {
+		return err
+	}
+	if err := f(file); err != nil {
+		file.close()
+		return err
+	}
+	return file.close()
+}
+
+// readRecords calls f with the file of records, which is read until f returns io.EOF
+func readRecords(dumpDir, name, format string, f func(*recordFile) error) error {
+	file, err := openRecordFile(filepath.Join(dumpDir, name+"."+fileExtension(format)), format)
+	if err != nil {
+		return err
+	}
+	defer file.close()
+	if err := f(file); err != io.EOF {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/hyperledger/fabric/core/ledger"
+	"github.com/hyperledger/fabric/core/ledger/testutil"
+	"github.com/hyperledger/fabric/protos"
+	"github.com/spf13/viper"
+)
+
+func TestMain(m *testing.M) {
+	setupTestConfig()
+	os.Exit(m.Run())
+}
+
+func TestDumpAndRestore(t *testing.T) {
+	defer deleteTestDBDir()
+	for _, format := range []string{formatJSON, formatProto} {
+		l := ledger.InitTestLedger(t)
+		for i := 0; i < 3; i++ {
+			l.BeginTxBatch(i)
+			txUUID := fmt.Sprintf("tx%d", i)
+			l.TxBegin(txUUID)
+			l.SetState("chaincode1", fmt.Sprintf("key%d", i), []byte(fmt.Sprintf("value%d", i)))
+			l.SetState("chaincode2", "empty", []byte{})
+			if i == 2 {
+				l.DeleteState("chaincode1", "key0")
+			}
+			l.TxFinished(txUUID, true)
+			tx, err := protos.NewTransaction(protos.ChaincodeID{Path: "testUrl"}, txUUID, "anyfunction", []string{"param1"})
+			testutil.AssertNoError(t, err, "Error building a transaction")
+			testutil.AssertNoError(t, l.CommitTxBatch(i, []*protos.Transaction{tx}, nil, []byte("proof")), "Error committing a batch")
+		}
+		expectedDelta, _ := l.GetStateDelta(2)
+
+		dumpDir, err := ioutil.TempDir("", "ledgerutil-test")
+		testutil.AssertNoError(t, err, "Error creating the dump dir")
+		defer os.RemoveAll(dumpDir)
+		m, err := dump(l, dumpDir, format)
+		testutil.AssertNoError(t, err, "Error dumping the ledger")
+		testutil.AssertEquals(t, m.BlockchainHeight, uint64(3))
+		testutil.AssertEquals(t, m.NumStateKeys, uint64(3))
+		testutil.AssertEquals(t, m.NumStateDeltas, uint64(3))
+
+		restored := ledger.InitTestLedger(t)
+		_, err = restore(restored, dumpDir)
+		testutil.AssertNoError(t, err, fmt.Sprintf("Error restoring the %s dump", format))
+		value, _ := restored.GetState("chaincode1", "key2", true)
+		testutil.AssertEquals(t, value, []byte("value2"))
+		value, _ = restored.GetState("chaincode1", "key0", true)
+		testutil.AssertNil(t, value)
+		value, _ = restored.GetState("chaincode2", "empty", true)
+		testutil.AssertEquals(t, value, []byte{})
+		delta, _ := restored.GetStateDelta(2)
+		testutil.AssertEquals(t, delta, expectedDelta)
+		tx, _ := restored.GetTransactionByUUID("tx1")
+		testutil.AssertNotNil(t, tx)
+
+		_, err = restore(restored, dumpDir)
+		testutil.AssertError(t, err, "Expected restoring a ledger which is not empty to fail")
+	}
+}
+
+func setupTestConfig() {
+	tempDir, err := ioutil.TempDir("", "ledgerutil-test")
+	if err != nil {
+		panic(err)
+	}
+	viper.Set("peer.fileSystemPath", tempDir)
+}
+
+func deleteTestDBDir() {
+	path := viper.GetString("peer.fileSystemPath")
+	os.RemoveAll(path)
+}
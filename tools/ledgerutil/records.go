@@ -0,0 +1,118 @@
+/*
+Copyright IBM Corp. 2016 All Rights Reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+		 http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+)
+
+const (
+	// formatJSON writes one message per line in the JSON mapping of protobuf
+	formatJSON = "json"
+	// formatProto writes the messages in the binary encoding of protobuf, each
+	// preceded by its varint encoded length
+	formatProto = "proto"
+)
+
+// recordFile holds a sequence of protobuf messages of the same type
+type recordFile struct {
+	format string
+	file   *os.File
+	reader *bufio.Reader
+	writer *bufio.Writer
+}
+
+func fileExtension(format string) string {
+	if format == formatProto {
+		return "pb"
+	}
+	return "json"
+}
+
+func createRecordFile(path, format string) (*recordFile, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &recordFile{format: format, file: file, writer: bufio.NewWriter(file)}, nil
+}
+
+func openRecordFile(path, format string) (*recordFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &recordFile{format: format, file: file, reader: bufio.NewReader(file)}, nil
+}
+
+// write appends the message to the file
+func (f *recordFile) write(msg proto.Message) error {
+	if f.format == formatProto {
+		buffer := proto.NewBuffer(nil)
+		if err := buffer.EncodeMessage(msg); err != nil {
+			return err
+		}
+		_, err := f.writer.Write(buffer.Bytes())
+		return err
+	}
+	if err := (&jsonpb.Marshaler{}).Marshal(f.writer, msg); err != nil {
+		return err
+	}
+	return f.writer.WriteByte('\n')
+}
+
+// read reads the next message of the file into msg, and returns io.EOF once all
+// the messages have been read
+func (f *recordFile) read(msg proto.Message) error {
+	if f.format == formatProto {
+		size, err := binary.ReadUvarint(f.reader)
+		if err != nil {
+			return err
+		}
+		msgBytes := make([]byte, size)
+		if _, err := io.ReadFull(f.reader, msgBytes); err != nil {
+			return fmt.Errorf("Truncated record: %s", err)
+		}
+		return proto.Unmarshal(msgBytes, msg)
+	}
+	line, err := f.reader.ReadBytes('\n')
+	if err == io.EOF && len(line) > 0 {
+		return fmt.Errorf("Truncated record")
+	}
+	if err != nil {
+		return err
+	}
+	return jsonpb.UnmarshalString(string(line), msg)
+}
+
+// close flushes the messages written and closes the file
+func (f *recordFile) close() error {
+	if f.writer != nil {
+		if err := f.writer.Flush(); err != nil {
+			f.file.Close()
+			return err
+		}
+	}
+	return f.file.Close()
+}